@@ -0,0 +1,10 @@
+// Command backup-monitor verifies that Velero schedules cover and back up
+// the namespaces ConfigHub units deploy to, and reports the workloads left
+// unprotected. The same app runs as "devops-apps backups".
+package main
+
+import backupmonitor "github.com/monadic/devops-examples/backup-monitor"
+
+func main() {
+	backupmonitor.Main()
+}
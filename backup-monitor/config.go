@@ -0,0 +1,104 @@
+package backupmonitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Config holds the backup monitor's settings. They are read from
+// CONFIG_FILE (default /etc/backup-monitor/config.yaml); each can be
+// overridden by the environment variable in its env tag.
+type Config struct {
+	CubAPIURL   string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken    string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir  string `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Port        int    `yaml:"backups_port" env:"BACKUPS_PORT"`
+	AuthConfig  string `yaml:"auth_config" env:"AUTH_CONFIG"`   // OIDC login for the dashboard; a missing file leaves it open
+	ClusterName string `yaml:"cluster_name" env:"CLUSTER_NAME"` // cluster label of the /metrics samples
+
+	// Spaces whose workload units must be backed up, and the namespace of
+	// units that don't name one
+	Spaces    []string `yaml:"cub_spaces" env:"CUB_SPACES"`
+	Namespace string   `yaml:"namespace" env:"NAMESPACE"`
+	// Namespace Velero's Schedules and Backups live in
+	VeleroNamespace string `yaml:"velero_namespace" env:"VELERO_NAMESPACE"`
+	// A namespace whose last completed backup is older is stale; a day's
+	// schedule plus slack by default
+	MaxBackupAge time.Duration `yaml:"max_backup_age" env:"MAX_BACKUP_AGE"`
+	RunInterval  time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:  "https://hub.confighub.com/api",
+		Port:       8094,
+		AuthConfig: "/etc/backup-monitor/auth.yaml",
+
+		Namespace:       "default",
+		VeleroNamespace: "velero",
+		MaxBackupAge:    26 * time.Hour,
+		RunInterval:     15 * time.Minute,
+
+		CubRateLimit:     5,
+		CubRateBurst:     10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if len(c.Spaces) == 0 {
+		return fmt.Errorf("cub_spaces is required: the namespaces checked are those the units deploy to")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.VeleroNamespace == "" {
+		return fmt.Errorf("velero_namespace is required")
+	}
+	if c.MaxBackupAge <= 0 {
+		return fmt.Errorf("max_backup_age must be positive, got %s", c.MaxBackupAge)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("backups_port %d is not a valid port", c.Port)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/backup-monitor/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
@@ -0,0 +1,107 @@
+package backupmonitor
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+)
+
+// webFiles holds the dashboard page template
+//
+//go:embed web/index.html.tmpl
+var webFiles embed.FS
+
+var dashboardTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"money": func(f float64) string { return fmt.Sprintf("$%.2f", f) },
+	"ago": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).ParseFS(webFiles, "web/index.html.tmpl"))
+
+// handler serves the dashboard, its API under /api/v1 and /metrics
+func (m *Monitor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		report := m.Report()
+		if report == nil {
+			http.Error(w, "backups have not been checked yet", http.StatusServiceUnavailable)
+			return
+		}
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	})
+	v1 := api.New(mux, "backup-monitor")
+	v1.HandleFunc("/backups", m.handleBackups, api.Operation{
+		Summary: "Latest backup check",
+		Query: []api.Param{
+			{Name: "status", Description: "unprotected, failing, stale or protected"},
+			{Name: "namespace", Description: "one namespace's"},
+		},
+		Response: Report{},
+	})
+	v1.Handle("/breakers", breaker.Handler(m.cubBreaker), breaker.Operations...)
+	mux.HandleFunc("/health", health.Live)
+	m.health.Mount(mux)
+	mux.Handle("/metrics", m.metrics)
+	return mux
+}
+
+// handleBackups serves the latest check as JSON, with the namespaces and
+// workloads filtered by ?status= and ?namespace=
+func (m *Monitor) handleBackups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	status, namespace := query.Get("status"), query.Get("namespace")
+	if _, ok := statusRank[status]; status != "" && !ok {
+		http.Error(w, "status must be unprotected, failing, stale or protected", http.StatusBadRequest)
+		return
+	}
+	report := m.Report()
+	if report == nil {
+		http.Error(w, "backups have not been checked yet", http.StatusServiceUnavailable)
+		return
+	}
+	if status != "" || namespace != "" {
+		filtered := *report
+		filtered.Namespaces, filtered.Workloads = []NamespaceProtection{}, []Workload{}
+		for _, np := range report.Namespaces {
+			if (status == "" || np.Status == status) && (namespace == "" || np.Namespace == namespace) {
+				filtered.Namespaces = append(filtered.Namespaces, np)
+			}
+		}
+		for _, wl := range report.Workloads {
+			if (status == "" || wl.Status == status) && (namespace == "" || wl.Namespace == namespace) {
+				filtered.Workloads = append(filtered.Workloads, wl)
+			}
+		}
+		report = &filtered
+	}
+	writeJSON(w, report)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,281 @@
+// Package backupmonitor verifies that the namespaces ConfigHub units deploy
+// to are backed up: that a Velero schedule covers each of them and that its
+// backups complete. Workloads left unprotected are reported with their
+// criticality and what they cost, so the ones that matter are fixed first.
+package backupmonitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/client-go/dynamic"
+)
+
+const version = "1.0.0"
+
+// Report is the backup protection of the units' namespaces, served at
+// GET /api/v1/backups
+type Report struct {
+	ScannedAt  time.Time             `json:"scanned_at"`
+	Velero     bool                  `json:"velero"` // whether Velero's schedules could be listed
+	Schedules  []Schedule            `json:"schedules"`
+	Namespaces []NamespaceProtection `json:"namespaces"` // worst first
+	Workloads  []Workload            `json:"workloads"`  // unprotected, critical and costly first
+	// Workloads whose namespace is not protected, and their monthly cost
+	AtRisk     int               `json:"at_risk"`
+	AtRiskCost float64           `json:"at_risk_cost"`
+	Errors     map[string]string `json:"errors,omitempty"` // why a space could not be read, by slug
+}
+
+type Monitor struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	dynamic    dynamic.Interface
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu     sync.RWMutex
+	report *Report
+}
+
+// Main checks the backups every run_interval until interrupted, or once with
+// --mode=job. It is the entry point of cmd/backup-monitor and of
+// "devops-apps backups".
+func Main() {
+	logger := logging.Setup("backup-monitor")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "backup-monitor", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "backup-monitor",
+		Version:     version,
+		Description: "Verifies Velero backups protect the namespaces ConfigHub units deploy to",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	dyn, err := dynamic.NewForConfig(app.K8s.Config)
+	if err != nil {
+		logging.Fatal("Failed to create dynamic client", logging.Err(err))
+	}
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	monitor := &Monitor{
+		app:        app,
+		config:     cfg,
+		dynamic:    dyn,
+		metrics:    metrics.New("backup-monitor", version, cfg.ClusterName),
+		cubLimit:   ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker),
+		cubBreaker: cubBreaker,
+	}
+	monitor.metrics.Collect(metrics.Limiter(monitor.cubLimit))
+	monitor.metrics.Collect(metrics.ListCache(listcache.Install()))
+	monitor.metrics.Collect(metrics.Breakers(cubBreaker))
+	monitor.health = health.New()
+	monitor.health.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	monitor.health.Register("confighub", health.Optional, health.Breaker(cubBreaker))
+	monitor.metrics.Collect(monitor.collect)
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, monitor.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "backup-monitor", monitor.scan, monitor.Report, os.Stdout, store); err != nil {
+			logging.Fatal("Backup check failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Backup dashboard listening", "addr", addr)
+	group.Serve(addr, guard.Protect(monitor.handler(), "/metrics", "/health"))
+	group.Go(monitor.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Backup dashboard stopped", logging.Err(err))
+	}
+}
+
+// run checks now and every run_interval until interrupted
+func (m *Monitor) run(ctx context.Context) error {
+	ticker := time.NewTicker(m.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.scan(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Backup check failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan reads the workload units of every space and Velero's schedules, and
+// checks the namespaces are backed up. A space that can't be read is left
+// out; the scan only fails when none could be read.
+func (m *Monitor) scan(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "backups.scan")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := m.metrics.Cycle("scan", "")
+	defer func() { cycleDone(err) }()
+
+	schedules, err := readSchedules(ctx, m.dynamic, m.config.VeleroNamespace)
+	if err != nil {
+		return err
+	}
+	workloads, errs, err := m.workloads(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	report := build(workloads, schedules, m.config.MaxBackupAge, now)
+	report.Velero = schedules != nil
+	if len(errs) > 0 {
+		report.Errors = errs
+	}
+	m.mu.Lock()
+	m.report = report
+	m.mu.Unlock()
+	slog.Info("Backups checked", "schedules", len(schedules), "namespaces", len(report.Namespaces),
+		"workloads", len(workloads), "at_risk", report.AtRisk)
+	return nil
+}
+
+// workloads reads the workload units of the configured spaces, with why
+// any space could not be read
+func (m *Monitor) workloads(ctx context.Context) ([]Workload, map[string]string, error) {
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, m.cubLimit, "ListSpaces", "all", m.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("list spaces: %w", err)
+	}
+	ids := map[string]uuid.UUID{}
+	for _, sp := range spaces {
+		ids[sp.Slug] = sp.SpaceID
+	}
+	var workloads []Workload
+	errs := map[string]string{}
+	for _, slug := range m.config.Spaces {
+		id, ok := ids[slug]
+		if !ok {
+			errs[slug] = "space not found"
+			continue
+		}
+		units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, m.cubLimit, "ListUnits", id.String(), func() ([]*sdk.Unit, error) {
+				return m.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: id})
+			})
+		}, tracing.SpaceKey.String(slug))
+		if err != nil {
+			errs[slug] = fmt.Sprintf("list units: %v", err)
+			continue
+		}
+		workloads = append(workloads, parseUnits(slug, units, m.config.Namespace)...)
+	}
+	for slug, msg := range errs {
+		slog.Warn("Space not read", "space", slug, "reason", msg)
+	}
+	if len(errs) == len(m.config.Spaces) {
+		return nil, nil, fmt.Errorf("no space could be read")
+	}
+	return workloads, errs, nil
+}
+
+// build assesses the workloads against the schedules at now
+func build(workloads []Workload, schedules []Schedule, maxAge time.Duration, now time.Time) *Report {
+	namespaces, assessed := assess(workloads, schedules, maxAge, now)
+	report := &Report{ScannedAt: now, Schedules: schedules, Namespaces: namespaces, Workloads: assessed}
+	if report.Schedules == nil {
+		report.Schedules = []Schedule{}
+	}
+	if report.Workloads == nil {
+		report.Workloads = []Workload{}
+	}
+	for _, w := range assessed {
+		if w.Status != StatusProtected {
+			report.AtRisk++
+			report.AtRiskCost += w.MonthlyCost
+		}
+	}
+	return report
+}
+
+// Report returns the latest check, nil before the first one
+func (m *Monitor) Report() *Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.report
+}
+
+// collect exports each namespace's protection and the workloads at risk
+func (m *Monitor) collect(emit metrics.Emit) {
+	report := m.Report()
+	if report == nil {
+		return
+	}
+	for _, np := range report.Namespaces {
+		labels := metrics.Labels{"namespace": np.Namespace}
+		protected := 0.0
+		if np.Status == StatusProtected {
+			protected = 1
+		}
+		emit("backup_namespace_protected", "Whether a namespace's backups are covered, completing and recent.", "gauge", labels, protected)
+		if np.Succeeded != nil {
+			emit("backup_last_success_timestamp_seconds", "When the latest backup covering a namespace completed.", "gauge", labels, float64(finished(*np.Succeeded).Unix()))
+		}
+	}
+	at := map[string]int{}
+	for _, w := range report.Workloads {
+		if w.Status != StatusProtected {
+			at[w.Status]++
+		}
+	}
+	for _, status := range []string{StatusUnprotected, StatusFailing, StatusStale} {
+		emit("backup_workloads_at_risk", "Workload units whose namespace is not protected, by status.", "gauge", metrics.Labels{"status": status}, float64(at[status]))
+	}
+	emit("backup_at_risk_monthly_cost_dollars", "Monthly cost of the workload units whose namespace is not protected.", "gauge", nil, report.AtRiskCost)
+}
@@ -0,0 +1,281 @@
+package backupmonitor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/pricinghints"
+	sdk "github.com/monadic/devops-sdk"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Protection statuses of a namespace, worst first
+const (
+	StatusUnprotected = "unprotected" // no active schedule backs it up
+	StatusFailing     = "failing"     // its latest backup failed
+	StatusStale       = "stale"       // no backup completed within max_backup_age
+	StatusProtected   = "protected"
+)
+
+var statusRank = map[string]int{StatusUnprotected: 0, StatusFailing: 1, StatusStale: 2, StatusProtected: 3}
+
+// tierLabel is the unit label giving a workload's criticality
+const tierLabel = "tier"
+
+// Workload is a workload unit and what losing it would cost
+type Workload struct {
+	Space     string    `json:"space"`
+	UnitID    uuid.UUID `json:"unit_id"`
+	UnitSlug  string    `json:"unit_slug"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Tier      string    `json:"tier,omitempty"`
+	// Keeps data in volume claims, which only a backup brings back
+	Stateful    bool    `json:"stateful,omitempty"`
+	MonthlyCost float64 `json:"monthly_cost"`
+	Status      string  `json:"status"` // of its namespace
+}
+
+// Resource names the workload as Kind/namespace/name
+func (w Workload) Resource() string {
+	return fmt.Sprintf("%s/%s/%s", w.Kind, w.Namespace, w.Name)
+}
+
+// NamespaceProtection is how a namespace holding workload units is backed up
+type NamespaceProtection struct {
+	Namespace string   `json:"namespace"`
+	Status    string   `json:"status"`
+	Schedules []string `json:"schedules,omitempty"` // active ones covering it
+	// Most recent run of those schedules, and most recent completed one
+	Latest      *Run     `json:"latest,omitempty"`
+	Succeeded   *Run     `json:"succeeded,omitempty"`
+	Workloads   int      `json:"workloads"`
+	MonthlyCost float64  `json:"monthly_cost"` // of its workloads
+	Notes       []string `json:"notes,omitempty"`
+}
+
+// workloadKinds are the unit kinds checked
+var workloadKinds = map[string]bool{
+	"Deployment": true, "StatefulSet": true, "DaemonSet": true, "CronJob": true, "PersistentVolumeClaim": true,
+}
+
+// parseUnits returns the workloads of a space's units; units holding
+// anything else are skipped
+func parseUnits(space string, units []*sdk.Unit, defaultNamespace string) []Workload {
+	var workloads []Workload
+	for _, u := range units {
+		var head struct {
+			Kind     string   `json:"kind"`
+			Metadata unitMeta `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(u.Data), &head); err != nil || !workloadKinds[head.Kind] {
+			continue
+		}
+		w := Workload{Space: space, UnitID: u.UnitID, UnitSlug: u.Slug, Kind: head.Kind,
+			Namespace: head.Metadata.Namespace, Name: head.Metadata.Name, Tier: u.Labels[tierLabel]}
+		if w.Namespace == "" {
+			w.Namespace = defaultNamespace
+		}
+		w.Stateful, w.MonthlyCost = price(head.Kind, []byte(u.Data))
+		if hints, _ := pricinghints.Parse(u.Labels, head.Metadata.Annotations); hints.HasResources() {
+			w.MonthlyCost = hints.MonthlyCost(pricinghints.DefaultRates) // the unit's own estimate wins
+		}
+		workloads = append(workloads, w)
+	}
+	return workloads
+}
+
+// unitMeta is the part of a unit's metadata read
+type unitMeta struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// price returns whether a workload keeps data in volume claims, and its
+// monthly cost from its requests
+func price(kind string, data []byte) (stateful bool, cost float64) {
+	switch kind {
+	case "Deployment":
+		var d appsv1.Deployment
+		if yaml.Unmarshal(data, &d) == nil {
+			return usesClaims(&d.Spec.Template.Spec), podCost(&d.Spec.Template.Spec, replicas(d.Spec.Replicas), 0)
+		}
+	case "StatefulSet":
+		var s appsv1.StatefulSet
+		if yaml.Unmarshal(data, &s) == nil {
+			var storage float64
+			for _, t := range s.Spec.VolumeClaimTemplates {
+				if q, ok := t.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+					storage += q.AsApproximateFloat64() / (1 << 30)
+				}
+			}
+			return len(s.Spec.VolumeClaimTemplates) > 0 || usesClaims(&s.Spec.Template.Spec),
+				podCost(&s.Spec.Template.Spec, replicas(s.Spec.Replicas), storage)
+		}
+	case "DaemonSet":
+		var d appsv1.DaemonSet
+		if yaml.Unmarshal(data, &d) == nil {
+			return usesClaims(&d.Spec.Template.Spec), podCost(&d.Spec.Template.Spec, 1, 0) // per node
+		}
+	case "PersistentVolumeClaim":
+		var p corev1.PersistentVolumeClaim
+		if yaml.Unmarshal(data, &p) == nil {
+			if q, ok := p.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+				cost = pricinghints.Hints{StorageGB: q.AsApproximateFloat64() / (1 << 30), Replicas: 1}.MonthlyCost(pricinghints.DefaultRates)
+			}
+			return true, cost
+		}
+	}
+	return false, 0 // CronJobs run now and then and are not priced
+}
+
+// podCost prices n replicas of a pod spec, each with storageGB of claims
+func podCost(spec *corev1.PodSpec, n int, storageGB float64) float64 {
+	h := pricinghints.Hints{Replicas: n, StorageGB: storageGB}
+	for _, c := range spec.Containers {
+		if q, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+			h.CPUCores += q.AsApproximateFloat64()
+		}
+		if q, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+			h.MemoryGB += q.AsApproximateFloat64() / (1 << 30)
+		}
+		if q, ok := c.Resources.Limits["nvidia.com/gpu"]; ok {
+			h.GPUs += int(q.Value())
+		}
+	}
+	return h.MonthlyCost(pricinghints.DefaultRates)
+}
+
+// usesClaims reports whether a pod mounts a persistent volume claim
+func usesClaims(spec *corev1.PodSpec) bool {
+	for _, v := range spec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// replicas is a workload's replica count, 1 when unset
+func replicas(n *int32) int {
+	if n == nil {
+		return 1
+	}
+	return int(*n)
+}
+
+// assess checks the namespaces of the workloads against the schedules at
+// now. It returns each namespace's protection, worst first, and the
+// workloads with their namespace's status, critical and costly ones of
+// unprotected namespaces first.
+func assess(workloads []Workload, schedules []Schedule, maxAge time.Duration, now time.Time) ([]NamespaceProtection, []Workload) {
+	byNamespace := map[string]*NamespaceProtection{}
+	for _, w := range workloads {
+		np := byNamespace[w.Namespace]
+		if np == nil {
+			np = protection(w.Namespace, schedules, maxAge, now)
+			byNamespace[w.Namespace] = np
+		}
+		np.Workloads++
+		np.MonthlyCost += w.MonthlyCost
+	}
+
+	out := make([]Workload, len(workloads))
+	for i, w := range workloads {
+		w.Status = byNamespace[w.Namespace].Status
+		out[i] = w
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if statusRank[a.Status] != statusRank[b.Status] {
+			return statusRank[a.Status] < statusRank[b.Status]
+		}
+		if tierRank(a.Tier) != tierRank(b.Tier) {
+			return tierRank(a.Tier) < tierRank(b.Tier)
+		}
+		if a.MonthlyCost != b.MonthlyCost {
+			return a.MonthlyCost > b.MonthlyCost
+		}
+		return a.Resource() < b.Resource()
+	})
+
+	namespaces := make([]NamespaceProtection, 0, len(byNamespace))
+	for _, np := range byNamespace {
+		namespaces = append(namespaces, *np)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		a, b := namespaces[i], namespaces[j]
+		if statusRank[a.Status] != statusRank[b.Status] {
+			return statusRank[a.Status] < statusRank[b.Status]
+		}
+		return a.Namespace < b.Namespace
+	})
+	return namespaces, out
+}
+
+// protection finds the schedules backing up a namespace and how their
+// latest runs went
+func protection(namespace string, schedules []Schedule, maxAge time.Duration, now time.Time) *NamespaceProtection {
+	np := &NamespaceProtection{Namespace: namespace}
+	for _, s := range schedules {
+		if !s.covers(namespace) {
+			continue
+		}
+		if s.Paused {
+			np.Notes = append(np.Notes, fmt.Sprintf("schedule %s covers it but is paused", s.Name))
+			continue
+		}
+		np.Schedules = append(np.Schedules, s.Name)
+		if s.Selective {
+			np.Notes = append(np.Notes, fmt.Sprintf("schedule %s only backs up resources matching its label selector", s.Name))
+		}
+		if s.Latest != nil && (np.Latest == nil || s.Latest.Started.After(np.Latest.Started)) {
+			np.Latest = s.Latest
+		}
+		if s.Succeeded != nil && (np.Succeeded == nil || s.Succeeded.Started.After(np.Succeeded.Started)) {
+			np.Succeeded = s.Succeeded
+		}
+	}
+
+	recent := np.Succeeded != nil && now.Sub(finished(*np.Succeeded)) <= maxAge
+	switch {
+	case len(np.Schedules) == 0:
+		np.Status = StatusUnprotected
+	case np.Latest != nil && np.Latest.failed() && !recent:
+		np.Status = StatusFailing
+		np.Notes = append(np.Notes, fmt.Sprintf("backup %s ended %s", np.Latest.Name, np.Latest.Phase))
+	case !recent:
+		np.Status = StatusStale
+		if np.Succeeded == nil {
+			np.Notes = append(np.Notes, "no backup has completed")
+		}
+	default:
+		np.Status = StatusProtected
+	}
+	return np
+}
+
+// finished is when a run completed, or started if it didn't record that
+func finished(r Run) time.Time {
+	if r.Completed.IsZero() {
+		return r.Started
+	}
+	return r.Completed
+}
+
+// tierRank orders critical workloads first and untiered ones last
+func tierRank(tier string) int {
+	switch tier {
+	case "critical":
+		return 0
+	case "":
+		return 2
+	}
+	return 1
+}
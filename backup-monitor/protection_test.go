@@ -0,0 +1,180 @@
+package backupmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/metrics"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+const statefulSet = `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: postgres
+  namespace: payments
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: postgres
+        resources:
+          requests:
+            cpu: "1"
+            memory: 2Gi
+  volumeClaimTemplates:
+  - metadata:
+      name: data
+    spec:
+      resources:
+        requests:
+          storage: 100Gi
+`
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        resources:
+          requests:
+            cpu: 500m
+`
+
+func TestParseUnits(t *testing.T) {
+	units := []*sdk.Unit{
+		{Slug: "postgres", Data: statefulSet, Labels: map[string]string{"tier": "critical"}},
+		{Slug: "web", Data: deployment},
+		{Slug: "web-priced", Data: deployment, Labels: map[string]string{"cpu": "4", "replicas": "2"}},
+		{Slug: "config", Data: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n"},
+		{Slug: "notes", Data: "not: [yaml"},
+	}
+	workloads := parseUnits("prod", units, "shop")
+	if len(workloads) != 3 {
+		t.Fatalf("want the 3 workload units, got %+v", workloads)
+	}
+	pg, web, priced := workloads[0], workloads[1], workloads[2]
+	if pg.Resource() != "StatefulSet/payments/postgres" || pg.Tier != "critical" || !pg.Stateful {
+		t.Errorf("postgres read as %+v", pg)
+	}
+	if web.Namespace != "shop" || web.Stateful {
+		t.Errorf("web should default to namespace shop and be stateless, got %+v", web)
+	}
+	// 3 replicas of 1 CPU, 2Gi and 100Gi cost more than one of half a CPU
+	if pg.MonthlyCost <= web.MonthlyCost || web.MonthlyCost <= 0 {
+		t.Errorf("costs not priced from requests: postgres %.2f, web %.2f", pg.MonthlyCost, web.MonthlyCost)
+	}
+	if priced.MonthlyCost <= web.MonthlyCost*10 {
+		t.Errorf("pricing hint labels should win over the requests, got %.2f", priced.MonthlyCost)
+	}
+}
+
+func TestAssess(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	at := func(name, phase string, ago time.Duration) *Run {
+		started := now.Add(-ago)
+		return &Run{Name: name, Phase: phase, Started: started, Completed: started.Add(10 * time.Minute)}
+	}
+	schedules := []Schedule{
+		{Name: "shop-daily", Included: []string{"shop"}, Latest: at("shop-2", "Completed", 3*time.Hour), Succeeded: at("shop-2", "Completed", 3*time.Hour)},
+		{Name: "payments-daily", Included: []string{"payments"}, Latest: at("pay-2", "Failed", 2*time.Hour), Succeeded: at("pay-1", "Completed", 50*time.Hour)},
+		{Name: "logs", Included: []string{"logs"}, Latest: at("logs-1", "Completed", 72*time.Hour), Succeeded: at("logs-1", "Completed", 72*time.Hour)},
+		{Name: "batch", Included: []string{"batch"}, Paused: true},
+	}
+	workloads := []Workload{
+		{UnitSlug: "web", Kind: "Deployment", Namespace: "shop", Name: "web", MonthlyCost: 40},
+		{UnitSlug: "postgres", Kind: "StatefulSet", Namespace: "payments", Name: "postgres", MonthlyCost: 300},
+		{UnitSlug: "fluentd", Kind: "DaemonSet", Namespace: "logs", Name: "fluentd", MonthlyCost: 20},
+		{UnitSlug: "cheap", Kind: "CronJob", Namespace: "batch", Name: "report", Tier: "critical"},
+		{UnitSlug: "costly", Kind: "Deployment", Namespace: "batch", Name: "worker", MonthlyCost: 500},
+	}
+
+	namespaces, assessed := assess(workloads, schedules, 26*time.Hour, now)
+	got := map[string]NamespaceProtection{}
+	var order []string
+	for _, np := range namespaces {
+		got[np.Namespace] = np
+		order = append(order, np.Namespace)
+	}
+	want := map[string]string{"batch": StatusUnprotected, "payments": StatusFailing, "logs": StatusStale, "shop": StatusProtected}
+	for ns, status := range want {
+		if got[ns].Status != status {
+			t.Errorf("%s: status %s, want %s (%+v)", ns, got[ns].Status, status, got[ns])
+		}
+	}
+	if strings.Join(order, ",") != "batch,payments,logs,shop" {
+		t.Errorf("namespaces should be worst first, got %v", order)
+	}
+	if b := got["batch"]; b.Workloads != 2 || b.MonthlyCost != 500 || len(b.Notes) != 1 || !strings.Contains(b.Notes[0], "paused") {
+		t.Errorf("batch should note its paused schedule and total its workloads, got %+v", b)
+	}
+
+	// critical before costly, both before the workloads of better namespaces
+	var slugs []string
+	for _, w := range assessed {
+		slugs = append(slugs, w.UnitSlug)
+	}
+	if strings.Join(slugs, ",") != "cheap,costly,postgres,fluentd,web" {
+		t.Errorf("workloads in wrong order: %v", slugs)
+	}
+
+	// a failed run followed by a recent success is still protected
+	schedules[1].Succeeded = at("pay-3", "Completed", time.Hour)
+	if namespaces, _ := assess(workloads[1:2], schedules, 26*time.Hour, now); namespaces[0].Status != StatusProtected {
+		t.Errorf("payments should be protected by its recent success, got %+v", namespaces[0])
+	}
+}
+
+func TestBackupsHandler(t *testing.T) {
+	m := &Monitor{config: DefaultConfig(), metrics: metrics.New("backup-monitor", version, "test")}
+	handler := m.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/backups", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first check: status %d, want 503", rec.Code)
+	}
+
+	now := time.Now()
+	workloads := []Workload{
+		{UnitSlug: "web", Kind: "Deployment", Namespace: "shop", Name: "web", MonthlyCost: 40},
+		{UnitSlug: "postgres", Kind: "StatefulSet", Namespace: "payments", Name: "postgres", MonthlyCost: 300},
+	}
+	schedules := []Schedule{{Name: "shop", Included: []string{"shop"},
+		Succeeded: &Run{Name: "shop-1", Phase: PhaseCompleted, Started: now.Add(-time.Hour)}}}
+	m.report = build(workloads, schedules, 26*time.Hour, now)
+	if m.report.AtRisk != 1 || m.report.AtRiskCost != 300 {
+		t.Errorf("want postgres at risk, got %d costing %.2f", m.report.AtRisk, m.report.AtRiskCost)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/backups?status=unprotected", nil))
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Workloads) != 1 || report.Workloads[0].UnitSlug != "postgres" || len(report.Namespaces) != 1 {
+		t.Errorf("status filter: got %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/backups?status=lost", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "StatefulSet/payments/postgres") {
+		t.Errorf("dashboard: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package backupmonitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Velero's resources
+var (
+	scheduleGVR = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "schedules"}
+	backupGVR   = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "backups"}
+)
+
+// scheduleLabel names the Schedule that created a Backup
+const scheduleLabel = "velero.io/schedule-name"
+
+// PhaseCompleted is the phase of a successful Backup; PartiallyFailed,
+// Failed and FailedValidation are failures, the others in progress
+const PhaseCompleted = "Completed"
+
+// Run is one Backup of a Schedule
+type Run struct {
+	Name      string    `json:"name"`
+	Phase     string    `json:"phase"`
+	Started   time.Time `json:"started"`
+	Completed time.Time `json:"completed,omitempty"`
+	Errors    int64     `json:"errors,omitempty"`
+	Warnings  int64     `json:"warnings,omitempty"`
+}
+
+// failed reports whether the run ended without backing everything up
+func (r Run) failed() bool {
+	return r.Phase == "PartiallyFailed" || r.Phase == "Failed" || r.Phase == "FailedValidation"
+}
+
+// Schedule is a Velero Schedule and its latest runs
+type Schedule struct {
+	Name     string   `json:"name"`
+	Cron     string   `json:"cron"`
+	Paused   bool     `json:"paused,omitempty"`
+	Included []string `json:"included_namespaces,omitempty"` // empty or "*" for all
+	Excluded []string `json:"excluded_namespaces,omitempty"`
+	// Only resources matching the backup template's label selector are
+	// backed up
+	Selective bool `json:"selective,omitempty"`
+	Latest    *Run `json:"latest,omitempty"`    // most recent run, in progress or not
+	Succeeded *Run `json:"succeeded,omitempty"` // most recent completed run
+}
+
+// covers reports whether the schedule backs up namespace
+func (s Schedule) covers(namespace string) bool {
+	for _, ns := range s.Excluded {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(s.Included) == 0 {
+		return true
+	}
+	for _, ns := range s.Included {
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// readSchedules lists the Schedules and Backups of Velero's namespace. A
+// cluster without Velero's CRDs has nil schedules.
+func readSchedules(ctx context.Context, dyn dynamic.Interface, namespace string) ([]Schedule, error) {
+	schedules, err := dyn.Resource(scheduleGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+	backups, err := dyn.Resource(backupGVR).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: scheduleLabel})
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+
+	runs := map[string][]Run{} // by schedule, newest first
+	for i := range backups.Items {
+		b := &backups.Items[i]
+		if name := b.GetLabels()[scheduleLabel]; name != "" {
+			runs[name] = append(runs[name], backupRun(b))
+		}
+	}
+	out := []Schedule{} // not nil: Velero is installed
+	for i := range schedules.Items {
+		s := scheduleOf(&schedules.Items[i])
+		list := runs[s.Name]
+		sort.Slice(list, func(i, j int) bool { return list[i].Started.After(list[j].Started) })
+		for j := range list {
+			if s.Latest == nil {
+				s.Latest = &list[j]
+			}
+			if list[j].Phase == PhaseCompleted {
+				s.Succeeded = &list[j]
+				break
+			}
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// scheduleOf reads a Schedule's spec
+func scheduleOf(u *unstructured.Unstructured) Schedule {
+	s := Schedule{Name: u.GetName()}
+	s.Cron, _, _ = unstructured.NestedString(u.Object, "spec", "schedule")
+	s.Paused, _, _ = unstructured.NestedBool(u.Object, "spec", "paused")
+	s.Included, _, _ = unstructured.NestedStringSlice(u.Object, "spec", "template", "includedNamespaces")
+	s.Excluded, _, _ = unstructured.NestedStringSlice(u.Object, "spec", "template", "excludedNamespaces")
+	_, selector, _ := unstructured.NestedMap(u.Object, "spec", "template", "labelSelector")
+	_, selectors, _ := unstructured.NestedSlice(u.Object, "spec", "template", "orLabelSelectors")
+	s.Selective = selector || selectors
+	return s
+}
+
+// backupRun reads a Backup's status
+func backupRun(u *unstructured.Unstructured) Run {
+	r := Run{Name: u.GetName(), Started: u.GetCreationTimestamp().Time}
+	r.Phase, _, _ = unstructured.NestedString(u.Object, "status", "phase")
+	if v, ok, _ := unstructured.NestedString(u.Object, "status", "startTimestamp"); ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			r.Started = t
+		}
+	}
+	if v, ok, _ := unstructured.NestedString(u.Object, "status", "completionTimestamp"); ok {
+		r.Completed, _ = time.Parse(time.RFC3339, v)
+	}
+	r.Errors, _, _ = unstructured.NestedInt64(u.Object, "status", "errors")
+	r.Warnings, _, _ = unstructured.NestedInt64(u.Object, "status", "warnings")
+	return r
+}
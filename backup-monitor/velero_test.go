@@ -0,0 +1,106 @@
+package backupmonitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func schedule(name string, template map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Schedule",
+		"metadata":   map[string]interface{}{"namespace": "velero", "name": name},
+		"spec":       map[string]interface{}{"schedule": "0 2 * * *", "template": template},
+	}}
+}
+
+func backup(name, scheduleName, phase string, started time.Time) *unstructured.Unstructured {
+	labels := map[string]interface{}{}
+	if scheduleName != "" {
+		labels[scheduleLabel] = scheduleName
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Backup",
+		"metadata":   map[string]interface{}{"namespace": "velero", "name": name, "labels": labels},
+		"status": map[string]interface{}{
+			"phase":               phase,
+			"startTimestamp":      started.Format(time.RFC3339),
+			"completionTimestamp": started.Add(10 * time.Minute).Format(time.RFC3339),
+		},
+	}}
+}
+
+func fakeVelero(objs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		scheduleGVR: "ScheduleList",
+		backupGVR:   "BackupList",
+	}, objs...)
+}
+
+func TestReadSchedules(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	dyn := fakeVelero(
+		schedule("daily", map[string]interface{}{
+			"includedNamespaces": []interface{}{"shop", "payments"},
+			"labelSelector":      map[string]interface{}{"matchLabels": map[string]interface{}{"backup": "true"}},
+		}),
+		schedule("weekly", map[string]interface{}{"excludedNamespaces": []interface{}{"kube-system"}}),
+		backup("daily-1", "daily", "Completed", now.Add(-48*time.Hour)),
+		backup("daily-2", "daily", "PartiallyFailed", now.Add(-24*time.Hour)),
+		backup("daily-3", "daily", "InProgress", now.Add(-time.Hour)),
+		backup("manual", "", "Completed", now), // not from a schedule
+	)
+
+	schedules, err := readSchedules(context.Background(), dyn, "velero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 2 {
+		t.Fatalf("want 2 schedules, got %+v", schedules)
+	}
+	daily, weekly := schedules[0], schedules[1]
+	if daily.Name != "daily" || daily.Cron != "0 2 * * *" || !daily.Selective {
+		t.Errorf("daily schedule read as %+v", daily)
+	}
+	if daily.Latest == nil || daily.Latest.Name != "daily-3" {
+		t.Errorf("latest run should be the one in progress, got %+v", daily.Latest)
+	}
+	if daily.Succeeded == nil || daily.Succeeded.Name != "daily-1" || !daily.Succeeded.Completed.Equal(now.Add(-48*time.Hour+10*time.Minute)) {
+		t.Errorf("last success should be daily-1, got %+v", daily.Succeeded)
+	}
+	if weekly.Latest != nil || weekly.Succeeded != nil || weekly.Selective {
+		t.Errorf("weekly has no runs, got %+v", weekly)
+	}
+
+	if !daily.covers("shop") || daily.covers("default") {
+		t.Error("daily covers only its included namespaces")
+	}
+	if !weekly.covers("default") || weekly.covers("kube-system") {
+		t.Error("weekly covers every namespace but the excluded one")
+	}
+}
+
+func TestReadSchedulesWithoutVelero(t *testing.T) {
+	dyn := fakeVelero()
+	dyn.PrependReactor("list", "schedules", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(scheduleGVR.GroupResource(), "")
+	})
+	schedules, err := readSchedules(context.Background(), dyn, "velero")
+	if err != nil || schedules != nil {
+		t.Fatalf("want no schedules without Velero's CRDs, got %v, %v", schedules, err)
+	}
+
+	schedules, err = readSchedules(context.Background(), fakeVelero(), "velero")
+	if err != nil || schedules == nil || len(schedules) != 0 {
+		t.Fatalf("an installed Velero without schedules should give an empty list, got %v, %v", schedules, err)
+	}
+}
@@ -15,7 +15,7 @@ NC='\033[0m'
 # Build drift-detector
 echo "Building drift-detector..."
 cd drift-detector
-if go build -o drift-detector .; then
+if go build -o drift-detector ./cmd/drift-detector; then
     echo -e "${GREEN}✅ drift-detector built${NC}"
 else
     echo -e "${RED}❌ drift-detector build failed${NC}"
@@ -26,7 +26,7 @@ cd ..
 # Build cost-optimizer
 echo "Building cost-optimizer..."
 cd cost-optimizer
-if go build -o cost-optimizer .; then
+if go build -o cost-optimizer ./cmd/cost-optimizer; then
     echo -e "${GREEN}✅ cost-optimizer built${NC}"
 else
     echo -e "${RED}❌ cost-optimizer build failed${NC}"
@@ -37,7 +37,7 @@ cd ..
 # Build cost-impact-monitor (live dashboard)
 echo "Building live-dashboard..."
 cd cost-impact-monitor
-if go build -o live-dashboard live-dashboard.go; then
+if go build -o live-dashboard live-dashboard.go confighub-dynamic.go; then
     echo -e "${GREEN}✅ live-dashboard built${NC}"
 else
     echo -e "${RED}❌ live-dashboard build failed${NC}"
@@ -45,6 +45,138 @@ else
 fi
 cd ..
 
+# Build control-panel
+echo "Building control-panel..."
+cd control-panel
+if go build -o control-panel ./cmd/control-panel; then
+    echo -e "${GREEN}✅ control-panel built${NC}"
+else
+    echo -e "${RED}❌ control-panel build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build security-drift-detector
+echo "Building security-drift-detector..."
+cd security-drift-detector
+if go build -o security-drift-detector ./cmd/security-drift-detector; then
+    echo -e "${GREEN}✅ security-drift-detector built${NC}"
+else
+    echo -e "${RED}❌ security-drift-detector build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build compliance-checker
+echo "Building compliance-checker..."
+cd compliance-checker
+if go build -o compliance-checker ./cmd/compliance-checker; then
+    echo -e "${GREEN}✅ compliance-checker built${NC}"
+else
+    echo -e "${RED}❌ compliance-checker build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build orphan-cleaner
+echo "Building orphan-cleaner..."
+cd orphan-cleaner
+if go build -o orphan-cleaner ./cmd/orphan-cleaner; then
+    echo -e "${GREEN}✅ orphan-cleaner built${NC}"
+else
+    echo -e "${RED}❌ orphan-cleaner build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build cert-expiry-monitor
+echo "Building cert-expiry-monitor..."
+cd cert-expiry-monitor
+if go build -o cert-expiry-monitor ./cmd/cert-expiry-monitor; then
+    echo -e "${GREEN}✅ cert-expiry-monitor built${NC}"
+else
+    echo -e "${RED}❌ cert-expiry-monitor build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build quota-advisor
+echo "Building quota-advisor..."
+cd quota-advisor
+if go build -o quota-advisor ./cmd/quota-advisor; then
+    echo -e "${GREEN}✅ quota-advisor built${NC}"
+else
+    echo -e "${RED}❌ quota-advisor build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build slo-monitor
+echo "Building slo-monitor..."
+cd slo-monitor
+if go build -o slo-monitor ./cmd/slo-monitor; then
+    echo -e "${GREEN}✅ slo-monitor built${NC}"
+else
+    echo -e "${RED}❌ slo-monitor build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build upgrade-advisor
+echo "Building upgrade-advisor..."
+cd upgrade-advisor
+if go build -o upgrade-advisor ./cmd/upgrade-advisor; then
+    echo -e "${GREEN}✅ upgrade-advisor built${NC}"
+else
+    echo -e "${RED}❌ upgrade-advisor build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build secret-rotation-monitor
+echo "Building secret-rotation-monitor..."
+cd secret-rotation-monitor
+if go build -o secret-rotation-monitor ./cmd/secret-rotation-monitor; then
+    echo -e "${GREEN}✅ secret-rotation-monitor built${NC}"
+else
+    echo -e "${RED}❌ secret-rotation-monitor build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build backup-monitor
+echo "Building backup-monitor..."
+cd backup-monitor
+if go build -o backup-monitor ./cmd/backup-monitor; then
+    echo -e "${GREEN}✅ backup-monitor built${NC}"
+else
+    echo -e "${RED}❌ backup-monitor build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build devops-operator (runs the apps from DevOpsApp resources)
+echo "Building devops-operator..."
+cd operator
+if go build -o devops-operator ./cmd/devops-operator; then
+    echo -e "${GREEN}✅ devops-operator built${NC}"
+else
+    echo -e "${RED}❌ devops-operator build failed${NC}"
+    exit 1
+fi
+cd ..
+
+# Build devops-apps (all apps as subcommands of one binary)
+echo "Building devops-apps..."
+cd devops-apps
+if go build -o devops-apps .; then
+    echo -e "${GREEN}✅ devops-apps built${NC}"
+else
+    echo -e "${RED}❌ devops-apps build failed${NC}"
+    exit 1
+fi
+cd ..
+
 echo ""
 echo -e "${GREEN}=========================================${NC}"
 echo -e "${GREEN}All applications built successfully!${NC}"
@@ -53,4 +185,4 @@ echo ""
 echo "Next steps:"
 echo "  1. Start the dashboard: cd cost-impact-monitor && ./live-dashboard"
 echo "  2. Access at: http://localhost:8082"
-echo "  3. Run health check: curl http://localhost:8082/api/health | jq '.'"
\ No newline at end of file
+echo "  3. Run health check: curl http://localhost:8082/api/v1/health | jq '.'"
\ No newline at end of file
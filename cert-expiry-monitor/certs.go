@@ -0,0 +1,201 @@
+package certmonitor
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Where a certificate was found
+const (
+	SourceSecret      = "secret"      // a kubernetes.io/tls Secret
+	SourceCertificate = "certificate" // a cert-manager Certificate
+)
+
+// Expiry statuses, most urgent first
+const (
+	StatusExpired  = "expired"
+	StatusCritical = "critical" // expires within critical_before
+	StatusWarning  = "warning"  // expires within warn_before
+	StatusOK       = "ok"
+)
+
+var statusRank = map[string]int{StatusExpired: 0, StatusCritical: 1, StatusWarning: 2, StatusOK: 3}
+
+// certificateGVR is cert-manager's Certificate resource
+var certificateGVR = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+
+// certManagerAnnotation names the Certificate that issued a Secret
+const certManagerAnnotation = "cert-manager.io/certificate-name"
+
+// Cert is a certificate served from the cluster
+type Cert struct {
+	Source     string    `json:"source"`
+	Namespace  string    `json:"namespace"`
+	Name       string    `json:"name"`        // of the Secret or Certificate
+	SecretName string    `json:"secret_name"` // holding the certificate
+	CommonName string    `json:"common_name,omitempty"`
+	DNSNames   []string  `json:"dns_names,omitempty"`
+	Issuer     string    `json:"issuer,omitempty"`
+	NotAfter   time.Time `json:"not_after"`
+	// cert-manager's renewBefore and its Ready condition when false
+	RenewBefore time.Duration `json:"renew_before,omitempty"`
+	NotReady    string        `json:"not_ready,omitempty"`
+	Error       string        `json:"error,omitempty"` // why the certificate could not be read
+
+	Status   string          `json:"status"`
+	DaysLeft int             `json:"days_left"`
+	Units    []string        `json:"units,omitempty"` // slugs of the units it belongs to
+	Patches  []ProposedPatch `json:"patches,omitempty"`
+}
+
+// Resource names the certificate as Kind/namespace/name
+func (c Cert) Resource() string {
+	if c.Source == SourceCertificate {
+		return fmt.Sprintf("Certificate/%s/%s", c.Namespace, c.Name)
+	}
+	return fmt.Sprintf("Secret/%s/%s", c.Namespace, c.Name)
+}
+
+// parsePEM returns the first certificate of a PEM bundle, the leaf
+func parsePEM(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM certificate in tls.crt")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// secretCert reads the certificate of a TLS Secret
+func secretCert(s *corev1.Secret) Cert {
+	c := Cert{Source: SourceSecret, Namespace: s.Namespace, Name: s.Name, SecretName: s.Name}
+	leaf, err := parsePEM(s.Data[corev1.TLSCertKey])
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.CommonName, c.DNSNames, c.Issuer, c.NotAfter = leaf.Subject.CommonName, leaf.DNSNames, leaf.Issuer.CommonName, leaf.NotAfter
+	return c
+}
+
+// certificateCert reads a cert-manager Certificate's spec and status
+func certificateCert(u *unstructured.Unstructured) Cert {
+	c := Cert{Source: SourceCertificate, Namespace: u.GetNamespace(), Name: u.GetName()}
+	c.SecretName, _, _ = unstructured.NestedString(u.Object, "spec", "secretName")
+	c.CommonName, _, _ = unstructured.NestedString(u.Object, "spec", "commonName")
+	c.DNSNames, _, _ = unstructured.NestedStringSlice(u.Object, "spec", "dnsNames")
+	c.Issuer, _, _ = unstructured.NestedString(u.Object, "spec", "issuerRef", "name")
+	if v, ok, _ := unstructured.NestedString(u.Object, "spec", "renewBefore"); ok {
+		c.RenewBefore, _ = time.ParseDuration(v)
+	}
+	if v, ok, _ := unstructured.NestedString(u.Object, "status", "notAfter"); ok {
+		c.NotAfter, _ = time.Parse(time.RFC3339, v)
+	}
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, cond := range conditions {
+		m, _ := cond.(map[string]interface{})
+		if m["type"] == "Ready" && m["status"] == "False" {
+			c.NotReady, _ = m["message"].(string)
+			if c.NotReady == "" {
+				c.NotReady, _ = m["reason"].(string)
+			}
+		}
+	}
+	return c
+}
+
+// readCerts lists the TLS Secrets and cert-manager Certificates of the
+// watched namespace. A Secret issued by a Certificate is reported as the
+// Certificate, with the Secret's expiry when the Certificate has none yet.
+// Without cert-manager's CRD only Secrets are read.
+func readCerts(ctx context.Context, clientset kubernetes.Interface, dyn dynamic.Interface, namespace string) ([]Cert, error) {
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{FieldSelector: "type=" + string(corev1.SecretTypeTLS)})
+	if err != nil {
+		return nil, fmt.Errorf("list secrets: %w", err)
+	}
+	var certificates []unstructured.Unstructured
+	if dyn != nil {
+		list, err := dyn.Resource(certificateGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			// cert-manager is not installed
+		case err != nil:
+			return nil, fmt.Errorf("list certificates: %w", err)
+		default:
+			certificates = list.Items
+		}
+	}
+
+	issued := map[string]Cert{} // Secret certificates by namespace/Certificate name
+	var certs []Cert
+	for i := range secrets.Items {
+		s := &secrets.Items[i]
+		if s.Type != corev1.SecretTypeTLS {
+			continue // field selectors are not applied by every client
+		}
+		c := secretCert(s)
+		if name := s.Annotations[certManagerAnnotation]; name != "" {
+			issued[s.Namespace+"/"+name] = c
+			continue
+		}
+		certs = append(certs, c)
+	}
+	for i := range certificates {
+		c := certificateCert(&certificates[i])
+		secret, ok := issued[c.Namespace+"/"+c.Name]
+		delete(issued, c.Namespace+"/"+c.Name)
+		if c.NotAfter.IsZero() && ok {
+			c.NotAfter, c.Error = secret.NotAfter, secret.Error
+		}
+		certs = append(certs, c)
+	}
+	for _, c := range issued {
+		certs = append(certs, c) // its Certificate was deleted, or cert-manager's CRD is missing
+	}
+	return certs, nil
+}
+
+// classify sets the status and days left of each certificate at now and
+// sorts them by expiry, soonest first; unreadable ones come first
+func classify(certs []Cert, cfg Config, now time.Time) {
+	for i := range certs {
+		c := &certs[i]
+		left := c.NotAfter.Sub(now)
+		c.DaysLeft = int(left.Hours() / 24)
+		switch {
+		case c.NotAfter.IsZero():
+			c.Status, c.DaysLeft = StatusCritical, 0 // not issued, or not readable
+		case left <= 0:
+			c.Status = StatusExpired
+		case left <= cfg.CriticalBefore:
+			c.Status = StatusCritical
+		case left <= cfg.WarnBefore:
+			c.Status = StatusWarning
+		default:
+			c.Status = StatusOK
+		}
+	}
+	sort.SliceStable(certs, func(i, j int) bool {
+		if certs[i].NotAfter.Equal(certs[j].NotAfter) {
+			return certs[i].Resource() < certs[j].Resource()
+		}
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+}
@@ -0,0 +1,174 @@
+package certmonitor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// pemCert returns a self-signed PEM certificate for names expiring at notAfter
+func pemCert(t *testing.T, notAfter time.Time, names ...string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func tlsSecret(namespace, name string, crt []byte, annotations map[string]string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: []byte("key")},
+	}
+}
+
+func certificate(namespace, name, secretName string, status map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"namespace": namespace, "name": name},
+		"spec": map[string]interface{}{
+			"secretName":  secretName,
+			"dnsNames":    []interface{}{name + ".example.com"},
+			"issuerRef":   map[string]interface{}{"name": "letsencrypt"},
+			"renewBefore": "360h",
+		},
+	}}
+	if status != nil {
+		u.Object["status"] = status
+	}
+	return u
+}
+
+func TestSecretCert(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	// a key block before the leaf is skipped
+	bundle := append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("x")}), pemCert(t, notAfter, "shop.example.com", "www.example.com")...)
+	c := secretCert(tlsSecret("web", "shop-tls", bundle, nil))
+	if c.Error != "" {
+		t.Fatal(c.Error)
+	}
+	if !c.NotAfter.Equal(notAfter) || c.CommonName != "shop.example.com" || !reflect.DeepEqual(c.DNSNames, []string{"shop.example.com", "www.example.com"}) {
+		t.Errorf("got %+v", c)
+	}
+	if c.Resource() != "Secret/web/shop-tls" || c.SecretName != "shop-tls" {
+		t.Errorf("resource %s, secret %s", c.Resource(), c.SecretName)
+	}
+
+	if c := secretCert(tlsSecret("web", "broken", []byte("not a certificate"), nil)); c.Error == "" || !c.NotAfter.IsZero() {
+		t.Errorf("unreadable certificate: got %+v", c)
+	}
+}
+
+func TestCertificateCert(t *testing.T) {
+	c := certificateCert(certificate("web", "shop", "shop-tls", map[string]interface{}{
+		"notAfter": "2030-01-02T00:00:00Z",
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "Failing", "message": "ACME order failed"},
+		},
+	}))
+	want := Cert{
+		Source: SourceCertificate, Namespace: "web", Name: "shop", SecretName: "shop-tls",
+		DNSNames: []string{"shop.example.com"}, Issuer: "letsencrypt",
+		NotAfter: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC), RenewBefore: 360 * time.Hour, NotReady: "ACME order failed",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got  %+v\nwant %+v", c, want)
+	}
+}
+
+func TestReadCerts(t *testing.T) {
+	now := time.Now().Truncate(time.Second).UTC()
+	clientset := fake.NewSimpleClientset(
+		tlsSecret("web", "plain-tls", pemCert(t, now.Add(48*time.Hour), "plain.example.com"), nil),
+		tlsSecret("web", "shop-tls", pemCert(t, now.Add(240*time.Hour), "shop.example.com"), map[string]string{certManagerAnnotation: "shop"}),
+		tlsSecret("web", "orphan-tls", pemCert(t, now.Add(720*time.Hour), "old.example.com"), map[string]string{certManagerAnnotation: "old"}),
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "password"}, Type: corev1.SecretTypeOpaque},
+	)
+	listKinds := map[schema.GroupVersionResource]string{certificateGVR: "CertificateList"}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds,
+		certificate("web", "shop", "shop-tls", nil), // not issued yet: the Secret's expiry is used
+		certificate("web", "api", "api-tls", map[string]interface{}{"notAfter": now.Add(1000 * time.Hour).Format(time.RFC3339)}),
+	)
+
+	certs, err := readCerts(context.Background(), clientset, dyn, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]time.Time{}
+	for _, c := range certs {
+		got[c.Resource()] = c.NotAfter
+	}
+	want := map[string]time.Time{
+		"Secret/web/plain-tls":  now.Add(48 * time.Hour),
+		"Certificate/web/shop":  now.Add(240 * time.Hour),
+		"Certificate/web/api":   now.Add(1000 * time.Hour),
+		"Secret/web/orphan-tls": now.Add(720 * time.Hour),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+
+	// without cert-manager only the Secrets are read
+	certs, err = readCerts(context.Background(), clientset, nil, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 3 {
+		t.Errorf("without cert-manager: got %d certs, want 3", len(certs))
+	}
+}
+
+func TestClassify(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := DefaultConfig() // warn 30 days, critical 7 days ahead
+	certs := []Cert{
+		{Name: "ok", NotAfter: now.Add(60 * 24 * time.Hour)},
+		{Name: "warning", NotAfter: now.Add(20 * 24 * time.Hour)},
+		{Name: "unreadable", Error: "no PEM certificate in tls.crt"},
+		{Name: "expired", NotAfter: now.Add(-time.Hour)},
+		{Name: "critical", NotAfter: now.Add(3*24*time.Hour + time.Hour)},
+	}
+	classify(certs, cfg, now)
+
+	var got []string
+	for _, c := range certs {
+		got = append(got, c.Name+"="+c.Status)
+	}
+	want := []string{"unreadable=critical", "expired=expired", "critical=critical", "warning=warning", "ok=ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if certs[2].DaysLeft != 3 || certs[3].DaysLeft != 20 {
+		t.Errorf("days left %d and %d, want 3 and 20", certs[2].DaysLeft, certs[3].DaysLeft)
+	}
+}
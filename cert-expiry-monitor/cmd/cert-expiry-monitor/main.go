@@ -0,0 +1,10 @@
+// Command cert-expiry-monitor alerts ahead of the expiry of the cluster's
+// TLS Secrets and cert-manager Certificates and proposes the ConfigHub unit
+// patches that renew them. The same app runs as "devops-apps certs".
+package main
+
+import certmonitor "github.com/monadic/devops-examples/cert-expiry-monitor"
+
+func main() {
+	certmonitor.Main()
+}
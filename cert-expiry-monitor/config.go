@@ -0,0 +1,102 @@
+package certmonitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Config holds the certificate expiry monitor's settings. They are read
+// from CONFIG_FILE (default /etc/cert-expiry-monitor/config.yaml); each can
+// be overridden by the environment variable in its env tag.
+type Config struct {
+	Space        string `yaml:"cub_space" env:"CUB_SPACE"` // units the certificates are correlated with
+	CubAPIURL    string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken     string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir   string `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Namespace    string `yaml:"namespace" env:"NAMESPACE"`     // of units that don't name one
+	Port         int    `yaml:"certs_port" env:"CERTS_PORT"`
+	NotifyConfig string `yaml:"notify_config" env:"NOTIFY_CONFIG"`
+	AuthConfig   string `yaml:"auth_config" env:"AUTH_CONFIG"`   // OIDC login for the dashboard; a missing file leaves it open
+	ClusterName  string `yaml:"cluster_name" env:"CLUSTER_NAME"` // cluster label of the /metrics samples
+
+	WatchNamespace string `yaml:"watch_namespace" env:"WATCH_NAMESPACE"` // empty watches all namespaces
+	// Certificates expiring within warn_before alert as warnings, within
+	// critical_before as critical
+	WarnBefore     time.Duration `yaml:"warn_before" env:"WARN_BEFORE"`
+	CriticalBefore time.Duration `yaml:"critical_before" env:"CRITICAL_BEFORE"`
+	RunInterval    time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		Space:        "cert-expiry-monitor",
+		CubAPIURL:    "https://hub.confighub.com/api",
+		Namespace:    "default",
+		Port:         8089,
+		NotifyConfig: "/etc/cert-expiry-monitor/notify.yaml",
+		AuthConfig:   "/etc/cert-expiry-monitor/auth.yaml",
+
+		WarnBefore:     30 * 24 * time.Hour,
+		CriticalBefore: 7 * 24 * time.Hour,
+		RunInterval:    time.Hour,
+
+		CubRateLimit:     5,
+		CubRateBurst:     10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if c.Space == "" {
+		return fmt.Errorf("cub_space is required")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("certs_port %d is not a valid port", c.Port)
+	}
+	if c.CriticalBefore <= 0 || c.WarnBefore <= c.CriticalBefore {
+		return fmt.Errorf("need 0 < critical_before < warn_before, got %s and %s", c.CriticalBefore, c.WarnBefore)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/cert-expiry-monitor/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
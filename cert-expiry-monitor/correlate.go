@@ -0,0 +1,136 @@
+package certmonitor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+	"sigs.k8s.io/yaml"
+)
+
+// ProposedPatch is a change to a unit that renews or rotates a certificate,
+// for an operator to review and apply
+type ProposedPatch struct {
+	UnitID      uuid.UUID   `json:"unit_id"`
+	UnitSlug    string      `json:"unit_slug"`
+	PatchPath   string      `json:"patch_path"`
+	PatchValue  interface{} `json:"patch_value"`
+	Explanation string      `json:"explanation"`
+}
+
+// unitRef is a unit a certificate belongs to
+type unitRef struct {
+	ID   uuid.UUID
+	Slug string
+	Kind string
+}
+
+// unitIndex finds the units of certificates: by the resource they describe,
+// and by the TLS Secret they name - their own, a Certificate's secretName or
+// an Ingress's tls secretName
+type unitIndex struct {
+	byResource map[string][]unitRef // Kind/namespace/name
+	bySecret   map[string][]unitRef // namespace/name
+}
+
+func indexUnits(units []*sdk.Unit, defaultNamespace string) unitIndex {
+	idx := unitIndex{byResource: map[string][]unitRef{}, bySecret: map[string][]unitRef{}}
+	for _, u := range units {
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				SecretName string `json:"secretName"` // Certificate
+				TLS        []struct {
+					SecretName string `json:"secretName"`
+				} `json:"tls"` // Ingress
+			} `json:"spec"`
+		}
+		if err := yaml.Unmarshal([]byte(u.Data), &obj); err != nil || obj.Kind == "" {
+			continue
+		}
+		ns := obj.Metadata.Namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		ref := unitRef{ID: u.UnitID, Slug: u.Slug, Kind: obj.Kind}
+		resource := fmt.Sprintf("%s/%s/%s", obj.Kind, ns, obj.Metadata.Name)
+		idx.byResource[resource] = append(idx.byResource[resource], ref)
+
+		var secrets []string
+		switch obj.Kind {
+		case "Secret":
+			secrets = []string{obj.Metadata.Name}
+		case "Certificate":
+			secrets = []string{obj.Spec.SecretName}
+		case "Ingress":
+			for _, tls := range obj.Spec.TLS {
+				secrets = append(secrets, tls.SecretName)
+			}
+		}
+		for _, name := range secrets {
+			if name != "" {
+				idx.bySecret[ns+"/"+name] = append(idx.bySecret[ns+"/"+name], ref)
+			}
+		}
+	}
+	return idx
+}
+
+// refs returns the units of a certificate, each once
+func (idx unitIndex) refs(c Cert) []unitRef {
+	var refs []unitRef
+	seen := map[uuid.UUID]bool{}
+	for _, ref := range append(append([]unitRef(nil), idx.byResource[c.Resource()]...), idx.bySecret[c.Namespace+"/"+c.SecretName]...) {
+		if !seen[ref.ID] {
+			seen[ref.ID] = true
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Slug < refs[j].Slug })
+	return refs
+}
+
+// correlate records the units of each certificate and, for those expiring
+// within warn_before, proposes the patches that renew or rotate them:
+//
+//   - a cert-manager Certificate renews once less than its renewBefore is
+//     left, so raising renewBefore to warn_before renews it now, and ahead of
+//     the alerts from then on. A Certificate that is not Ready gets no patch;
+//     its condition says what to fix.
+//   - a TLS Secret kept in a unit is rotated by replacing its tls.crt and
+//     tls.key with the renewed pair.
+func correlate(certs []Cert, idx unitIndex, cfg Config) {
+	renewBefore := fmt.Sprintf("%dh", int(cfg.WarnBefore.Round(time.Hour).Hours()))
+	for i := range certs {
+		c := &certs[i]
+		refs := idx.refs(*c)
+		for _, ref := range refs {
+			c.Units = append(c.Units, ref.Slug)
+		}
+		if c.Status == StatusOK {
+			continue
+		}
+		for _, ref := range refs {
+			switch {
+			case ref.Kind == "Certificate" && c.Source == SourceCertificate && c.NotReady == "" && c.RenewBefore < cfg.WarnBefore:
+				c.Patches = append(c.Patches, ProposedPatch{
+					UnitID: ref.ID, UnitSlug: ref.Slug, PatchPath: "/spec/renewBefore", PatchValue: renewBefore,
+					Explanation: fmt.Sprintf("cert-manager renews once less than renewBefore is left; %s renews it now and before every later warning", renewBefore),
+				})
+			case ref.Kind == "Secret" && c.Source == SourceSecret:
+				for _, key := range []string{"tls.crt", "tls.key"} {
+					c.Patches = append(c.Patches, ProposedPatch{
+						UnitID: ref.ID, UnitSlug: ref.Slug, PatchPath: "/data/" + key, PatchValue: "<base64 of the renewed " + key + ">",
+						Explanation: "rotate: replace with the renewed certificate and key, then apply the unit",
+					})
+				}
+			}
+		}
+	}
+}
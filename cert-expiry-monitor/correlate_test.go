@@ -0,0 +1,123 @@
+package certmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func testUnits() []*sdk.Unit {
+	return []*sdk.Unit{
+		{UnitID: uuid.New(), Slug: "shop-cert", Data: "apiVersion: cert-manager.io/v1\nkind: Certificate\nmetadata:\n  name: shop\n  namespace: web\nspec:\n  secretName: shop-tls\n"},
+		{UnitID: uuid.New(), Slug: "shop-ingress", Data: "apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: shop\n  namespace: web\nspec:\n  tls:\n  - secretName: shop-tls\n  - secretName: legacy-tls\n"},
+		{UnitID: uuid.New(), Slug: "legacy-secret", Data: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: legacy-tls\ntype: kubernetes.io/tls\n"},
+		{UnitID: uuid.New(), Slug: "notes", Data: "not: a resource\n"},
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	idx := indexUnits(testUnits(), "web")
+	cfg := DefaultConfig()
+	certs := []Cert{
+		{Source: SourceCertificate, Namespace: "web", Name: "shop", SecretName: "shop-tls", RenewBefore: 360 * time.Hour, Status: StatusWarning},
+		{Source: SourceSecret, Namespace: "web", Name: "legacy-tls", SecretName: "legacy-tls", Status: StatusCritical},
+		{Source: SourceCertificate, Namespace: "web", Name: "failing", SecretName: "failing-tls", NotReady: "ACME order failed", Status: StatusCritical},
+		{Source: SourceSecret, Namespace: "other", Name: "legacy-tls", SecretName: "legacy-tls", Status: StatusOK},
+	}
+	correlate(certs, idx, cfg)
+
+	for _, tc := range []struct {
+		units   []string
+		patches []string // unit path=value
+	}{
+		{units: []string{"shop-cert", "shop-ingress"}, patches: []string{"shop-cert /spec/renewBefore=720h"}},
+		{units: []string{"legacy-secret", "shop-ingress"}, patches: []string{
+			"legacy-secret /data/tls.crt=<base64 of the renewed tls.crt>",
+			"legacy-secret /data/tls.key=<base64 of the renewed tls.key>",
+		}},
+		{}, // not Ready, and no unit
+		{}, // another namespace
+	} {
+		c := certs[0]
+		certs = certs[1:]
+		var patches []string
+		for _, p := range c.Patches {
+			patches = append(patches, p.UnitSlug+" "+p.PatchPath+"="+p.PatchValue.(string))
+		}
+		if !reflect.DeepEqual(c.Units, tc.units) || !reflect.DeepEqual(patches, tc.patches) {
+			t.Errorf("%s: units %v patches %v, want %v %v", c.Resource(), c.Units, patches, tc.units, tc.patches)
+		}
+	}
+
+	// a renewBefore already at warn_before is left alone
+	certs = []Cert{{Source: SourceCertificate, Namespace: "web", Name: "shop", SecretName: "shop-tls", RenewBefore: cfg.WarnBefore, Status: StatusWarning}}
+	correlate(certs, idx, cfg)
+	if len(certs[0].Patches) != 0 {
+		t.Errorf("renewBefore at warn_before: got patches %+v", certs[0].Patches)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := &Monitor{config: DefaultConfig(), metrics: metrics.New("cert-expiry-monitor", version, "test")}
+	handler := m.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/certs", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first check: status %d, want 503", rec.Code)
+	}
+
+	now := time.Now()
+	certs := []Cert{
+		{Source: SourceSecret, Namespace: "web", Name: "a", NotAfter: now.Add(-time.Hour)},
+		{Source: SourceSecret, Namespace: "web", Name: "b", NotAfter: now.Add(10 * 24 * time.Hour)},
+		{Source: SourceSecret, Namespace: "web", Name: "c", NotAfter: now.Add(90 * 24 * time.Hour)},
+	}
+	classify(certs, m.config, now)
+	m.report = &Report{CheckedAt: now, Space: m.config.Space, Certs: certs, ByStatus: map[string]int{StatusExpired: 1, StatusWarning: 1, StatusOK: 1}}
+
+	for _, tc := range []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{query: "", code: http.StatusOK, want: []string{"a", "b", "c"}},
+		{query: "?status=warning", code: http.StatusOK, want: []string{"a", "b"}},
+		{query: "?status=expired", code: http.StatusOK, want: []string{"a"}},
+		{query: "?status=soon", code: http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/certs"+tc.query, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.query, rec.Code, tc.code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var report Report
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range report.Certs {
+			got = append(got, c.Name)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("dashboard: status %d: %s", rec.Code, rec.Body)
+	}
+}
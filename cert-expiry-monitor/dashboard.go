@@ -0,0 +1,100 @@
+package certmonitor
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+)
+
+// webFiles holds the dashboard page template
+//
+//go:embed web/index.html.tmpl
+var webFiles embed.FS
+
+var dashboardTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "unknown"
+		}
+		return t.Format("2006-01-02")
+	},
+	"ago": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).ParseFS(webFiles, "web/index.html.tmpl"))
+
+// handler serves the dashboard, its API under /api/v1 and /metrics
+func (m *Monitor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		report := m.Report()
+		if report == nil {
+			http.Error(w, "certificates have not been checked yet", http.StatusServiceUnavailable)
+			return
+		}
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	})
+	v1 := api.New(mux, "cert-expiry-monitor")
+	v1.HandleFunc("/certs", m.handleCerts, api.Operation{
+		Summary:  "Latest certificate check",
+		Query:    []api.Param{{Name: "status", Description: "this status and more urgent ones: expired, critical, warning or ok"}},
+		Response: Report{},
+	})
+	v1.Handle("/breakers", breaker.Handler(m.cubBreaker), breaker.Operations...)
+	mux.HandleFunc("/health", health.Live)
+	m.health.Mount(mux)
+	mux.Handle("/metrics", m.metrics)
+	return mux
+}
+
+// handleCerts serves the latest report as JSON; ?status=warning keeps the
+// certificates of that status and more urgent ones
+func (m *Monitor) handleCerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := m.Report()
+	if report == nil {
+		http.Error(w, "certificates have not been checked yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		rank, ok := statusRank[status]
+		if !ok {
+			http.Error(w, "status must be expired, critical, warning or ok", http.StatusBadRequest)
+			return
+		}
+		filtered := *report
+		filtered.Certs = []Cert{}
+		for _, c := range report.Certs {
+			if statusRank[c.Status] <= rank {
+				filtered.Certs = append(filtered.Certs, c)
+			}
+		}
+		report = &filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
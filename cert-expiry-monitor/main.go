@@ -0,0 +1,286 @@
+// Package certmonitor watches the TLS Secrets and cert-manager Certificates
+// of a cluster, correlates them with the ConfigHub units they come from,
+// alerts ahead of their expiry and proposes the unit patches that renew or
+// rotate them.
+package certmonitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/notify"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const version = "1.0.0"
+
+// Report is the result of the latest check, served at GET /api/v1/certs
+type Report struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	Space     string         `json:"space"`
+	Certs     []Cert         `json:"certs"`
+	ByStatus  map[string]int `json:"by_status"`
+	Degraded  string         `json:"degraded,omitempty"` // why the units could not be read
+}
+
+type Monitor struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	clientset  kubernetes.Interface
+	dynamic    dynamic.Interface // reads cert-manager Certificates
+	notifier   *notify.Notifier
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu     sync.RWMutex
+	report *Report
+}
+
+// Main checks the certificates every run_interval until interrupted, or once
+// with --mode=job. It is the entry point of cmd/cert-expiry-monitor and of
+// "devops-apps certs".
+func Main() {
+	logger := logging.Setup("cert-expiry-monitor")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "cert-expiry-monitor", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "cert-expiry-monitor",
+		Version:     version,
+		Description: "Alerts ahead of TLS certificate expiry and proposes renewals",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	dyn, err := dynamic.NewForConfig(app.K8s.Config)
+	if err != nil {
+		logging.Fatal("Failed to create dynamic client", logging.Err(err))
+	}
+	notifier, err := notify.Load(cfg.NotifyConfig)
+	if err != nil {
+		logging.Fatal("Failed to load notify config", logging.Err(err))
+	}
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	monitor := &Monitor{
+		app:        app,
+		config:     cfg,
+		clientset:  app.K8s.Clientset,
+		dynamic:    dyn,
+		notifier:   notifier,
+		metrics:    metrics.New("cert-expiry-monitor", version, cfg.ClusterName),
+		cubLimit:   ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker),
+		cubBreaker: cubBreaker,
+	}
+	monitor.metrics.Collect(metrics.Limiter(monitor.cubLimit))
+	monitor.metrics.Collect(metrics.ListCache(listcache.Install()))
+	monitor.metrics.Collect(metrics.Breakers(cubBreaker))
+	monitor.health = health.New()
+	monitor.health.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	monitor.health.Register("confighub", health.Optional, health.Breaker(cubBreaker))
+	monitor.metrics.Collect(monitor.collect)
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, monitor.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "cert-expiry-monitor", monitor.check, monitor.Report, os.Stdout, store); err != nil {
+			logging.Fatal("Certificate check failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Certificate dashboard listening", "addr", addr)
+	group.Serve(addr, guard.Protect(monitor.handler(), "/metrics", "/health"))
+	group.Go(monitor.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Certificate dashboard stopped", logging.Err(err))
+	}
+}
+
+// run checks now and every run_interval until interrupted
+func (m *Monitor) run(ctx context.Context) error {
+	ticker := time.NewTicker(m.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.check(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Certificate check failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check reads the certificates and the space's units and alerts on those
+// expiring. Without the units the certificates are still checked and
+// alerted on, just not correlated.
+func (m *Monitor) check(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "certs.check")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := m.metrics.Cycle("check", m.config.Space)
+	defer func() { cycleDone(err) }()
+
+	certs, err := readCerts(ctx, m.clientset, m.dynamic, m.config.WatchNamespace)
+	if err != nil {
+		return err
+	}
+	report := &Report{CheckedAt: time.Now(), Space: m.config.Space, ByStatus: map[string]int{StatusExpired: 0, StatusCritical: 0, StatusWarning: 0, StatusOK: 0}}
+	classify(certs, m.config, report.CheckedAt)
+	units, err := m.units(ctx)
+	if err != nil {
+		report.Degraded = fmt.Sprintf("units not read: %v", err)
+		slog.Warn("Checking certificates without their units", logging.Space(m.config.Space), logging.Err(err))
+	}
+	correlate(certs, indexUnits(units, m.config.Namespace), m.config)
+
+	report.Certs = certs
+	if report.Certs == nil {
+		report.Certs = []Cert{}
+	}
+	for _, c := range certs {
+		report.ByStatus[c.Status]++
+		if c.Status != StatusOK {
+			m.notifyExpiry(c)
+		}
+	}
+	m.mu.Lock()
+	m.report = report
+	m.mu.Unlock()
+	slog.Info("Certificates checked", "certs", len(certs), "expiring", len(certs)-report.ByStatus[StatusOK])
+	return nil
+}
+
+// units reads the units of the space
+func (m *Monitor) units(ctx context.Context) ([]*sdk.Unit, error) {
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, m.cubLimit, "ListSpaces", "all", m.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list spaces: %w", err)
+	}
+	for _, s := range spaces {
+		if s.Slug != m.config.Space {
+			continue
+		}
+		return tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, m.cubLimit, "ListUnits", s.SpaceID.String(), func() ([]*sdk.Unit, error) {
+				return m.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.SpaceID})
+			})
+		}, tracing.SpaceKey.String(s.Slug))
+	}
+	return nil, fmt.Errorf("space %s not found", m.config.Space)
+}
+
+// notifyExpiry alerts on a certificate once per status and expiry, so a
+// renewal or a new stage alerts again
+func (m *Monitor) notifyExpiry(c Cert) {
+	if !m.notifier.Enabled() {
+		return
+	}
+	severity := notify.Warning
+	if c.Status != StatusWarning {
+		severity = notify.Critical
+	}
+	title := fmt.Sprintf("Certificate %s/%s expires in %d days", c.Namespace, c.Name, c.DaysLeft)
+	switch {
+	case c.NotAfter.IsZero():
+		title = fmt.Sprintf("Certificate %s/%s has no readable expiry", c.Namespace, c.Name)
+	case c.Status == StatusExpired:
+		title = fmt.Sprintf("Certificate %s/%s has expired", c.Namespace, c.Name)
+	}
+	fields := map[string]string{
+		"secret":    c.SecretName,
+		"not_after": c.NotAfter.Format(time.RFC3339),
+		"dns_names": strings.Join(c.DNSNames, ", "),
+		"issuer":    c.Issuer,
+		"units":     strings.Join(c.Units, ", "),
+		"not_ready": c.NotReady,
+		"error":     c.Error,
+	}
+	for _, p := range c.Patches {
+		fields["patch "+p.UnitSlug+" "+p.PatchPath] = fmt.Sprintf("%v", p.PatchValue)
+	}
+
+	err := m.notifier.Notify(context.Background(), notify.Notification{
+		App:      "cert-expiry-monitor",
+		Kind:     "cert-expiry",
+		Severity: severity,
+		Title:    title,
+		Summary:  fmt.Sprintf("%s %s is %s; %d renewal patch(es) proposed", c.Source, c.Resource(), c.Status, len(c.Patches)),
+		Fields:   fields,
+		DedupKey: fmt.Sprintf("cert-expiry/%s/%s/%s", c.Resource(), c.Status, c.NotAfter.Format(time.RFC3339)),
+	})
+	if err != nil {
+		slog.Warn("Failed to send certificate expiry notification", logging.Err(err))
+	}
+}
+
+// Report returns the latest report, nil before the first check
+func (m *Monitor) Report() *Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.report
+}
+
+// collect exports the time left on each certificate
+func (m *Monitor) collect(emit metrics.Emit) {
+	report := m.Report()
+	if report == nil {
+		return
+	}
+	for _, c := range report.Certs {
+		if !c.NotAfter.IsZero() {
+			emit("cert_expiry_seconds", "Time until the certificate expires, negative once expired.", "gauge",
+				metrics.Labels{"source": c.Source, "namespace": c.Namespace, "name": c.Name}, c.NotAfter.Sub(time.Now()).Seconds())
+		}
+	}
+	for status, n := range report.ByStatus {
+		emit("certs", "Certificates by expiry status at the latest check.", "gauge", metrics.Labels{"status": status}, float64(n))
+	}
+}
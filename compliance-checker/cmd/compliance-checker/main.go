@@ -0,0 +1,10 @@
+// Command compliance-checker checks ConfigHub units and live workloads
+// against compliance rule packs and serves the findings on a dashboard. The
+// same app runs as "devops-apps compliance".
+package main
+
+import compliancechecker "github.com/monadic/devops-examples/compliance-checker"
+
+func main() {
+	compliancechecker.Main()
+}
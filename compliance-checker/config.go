@@ -0,0 +1,112 @@
+package compliancechecker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Config holds the compliance checker's settings. They are read from
+// CONFIG_FILE (default /etc/compliance-checker/config.yaml); each can be
+// overridden by the environment variable in its env tag.
+type Config struct {
+	CubAPIURL   string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken    string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir  string `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Port        int    `yaml:"compliance_port" env:"COMPLIANCE_PORT"`
+	AuthConfig  string `yaml:"auth_config" env:"AUTH_CONFIG"`   // OIDC login for the dashboard; a missing file leaves it open
+	ClusterName string `yaml:"cluster_name" env:"CLUSTER_NAME"` // cluster label of the /metrics samples
+
+	// Spaces whose workload units are checked, and the namespace of units
+	// that don't name one
+	Spaces    []string `yaml:"cub_spaces" env:"CUB_SPACES"`
+	Namespace string   `yaml:"namespace" env:"NAMESPACE"`
+	// Check the workloads running in the cluster too, in watch_namespace
+	// (empty for all) but not in exclude_namespaces
+	CheckLive         bool     `yaml:"check_live" env:"CHECK_LIVE"`
+	WatchNamespace    string   `yaml:"watch_namespace" env:"WATCH_NAMESPACE"`
+	ExcludeNamespaces []string `yaml:"exclude_namespaces" env:"EXCLUDE_NAMESPACES"`
+
+	// Rule packs checked, built in (cis-basic, restricted) or from the packs file
+	RulePacks   []string `yaml:"rule_packs" env:"RULE_PACKS"`
+	PacksConfig string   `yaml:"packs_config" env:"PACKS_CONFIG"`
+	// Space findings are stored in as units, created when missing; empty
+	// keeps them in memory only
+	FindingsSpace string        `yaml:"findings_space" env:"FINDINGS_SPACE"`
+	RunInterval   time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:  "https://hub.confighub.com/api",
+		Port:       8087,
+		AuthConfig: "/etc/compliance-checker/auth.yaml",
+
+		Namespace:         "default",
+		CheckLive:         true,
+		ExcludeNamespaces: []string{"kube-system"},
+
+		RulePacks:     []string{"cis-basic"},
+		PacksConfig:   "/etc/compliance-checker/packs.yaml",
+		FindingsSpace: "compliance-checker",
+		RunInterval:   10 * time.Minute,
+
+		CubRateLimit:     5,
+		CubRateBurst:     10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if len(c.Spaces) == 0 && !c.CheckLive {
+		return fmt.Errorf("nothing to check: set cub_spaces or check_live")
+	}
+	if len(c.RulePacks) == 0 {
+		return fmt.Errorf("rule_packs is required")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("compliance_port %d is not a valid port", c.Port)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/compliance-checker/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
@@ -0,0 +1,130 @@
+package compliancechecker
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+)
+
+// webFiles holds the dashboard page template
+//
+//go:embed web/index.html.tmpl
+var webFiles embed.FS
+
+var dashboardTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.0f%%", v) },
+	"ago": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).ParseFS(webFiles, "web/index.html.tmpl"))
+
+// dashboardPage is the data the page is rendered with
+type dashboardPage struct {
+	*Report
+	Rules []Rule
+}
+
+// RuleListing is the body of GET /api/v1/rules
+type RuleListing struct {
+	Packs []Pack `json:"packs"`
+	Rules []Rule `json:"rules"`
+}
+
+// handler serves the dashboard, its API under /api/v1 and /metrics
+func (c *Checker) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		report := c.Report()
+		if report == nil {
+			http.Error(w, "compliance has not been checked yet", http.StatusServiceUnavailable)
+			return
+		}
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, dashboardPage{report, c.rules}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	})
+	v1 := api.New(mux, "compliance-checker")
+	v1.HandleFunc("/compliance", c.handleReport, api.Operation{
+		Summary: "Latest compliance report",
+		Query: []api.Param{
+			{Name: "severity", Description: "findings of this severity and above"},
+			{Name: "source", Description: "unit or live"},
+		},
+		Response: Report{},
+	})
+	v1.HandleFunc("/rules", c.handleRules, api.Operation{Summary: "Enabled rule packs and their rules", Response: RuleListing{}})
+	v1.Handle("/breakers", breaker.Handler(c.cubBreaker), breaker.Operations...)
+	mux.HandleFunc("/health", health.Live)
+	c.health.Mount(mux)
+	mux.Handle("/metrics", c.metrics)
+	return mux
+}
+
+// handleReport serves the latest report as JSON. ?severity=high keeps the
+// findings of that severity and above, ?source=unit or ?source=live those
+// of one source.
+func (c *Checker) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := c.Report()
+	if report == nil {
+		http.Error(w, "compliance has not been checked yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	severity, source := r.URL.Query().Get("severity"), r.URL.Query().Get("source")
+	rank, ok := severityRank[severity]
+	if severity != "" && !ok {
+		http.Error(w, "severity must be critical, high, medium or low", http.StatusBadRequest)
+		return
+	}
+	if source != "" && source != SourceUnit && source != SourceLive {
+		http.Error(w, "source must be unit or live", http.StatusBadRequest)
+		return
+	}
+	if severity != "" || source != "" {
+		filtered := *report
+		filtered.Findings = []Finding{}
+		for _, f := range report.Findings {
+			if (severity == "" || severityRank[f.Severity] <= rank) && (source == "" || f.Source == source) {
+				filtered.Findings = append(filtered.Findings, f)
+			}
+		}
+		report = &filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleRules serves the enabled packs and their rules
+func (c *Checker) handleRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(RuleListing{Packs: c.packs, Rules: c.rules}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
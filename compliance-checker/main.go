@@ -0,0 +1,363 @@
+// Package compliancechecker checks ConfigHub units and the workloads running
+// in the cluster against compliance rule packs - CIS-style checks such as no
+// :latest images, resource limits required and runAsNonRoot - stores the
+// findings as units of a findings space, and serves them on a dashboard.
+package compliancechecker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/client-go/kubernetes"
+)
+
+const version = "1.0.0"
+
+// Finding is a rule broken by a workload
+type Finding struct {
+	Source    string   `json:"source"` // SourceUnit or SourceLive
+	Space     string   `json:"space,omitempty"`
+	Unit      string   `json:"unit,omitempty"`
+	Resource  string   `json:"resource"`
+	Container string   `json:"container,omitempty"`
+	Rule      string   `json:"rule"`
+	Packs     []string `json:"packs"`
+	Severity  string   `json:"severity"`
+	Message   string   `json:"message"`
+
+	slug string // of the workload's findings unit
+}
+
+// PackScore is how many workloads pass every rule of a pack
+type PackScore struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Rules       int     `json:"rules"`
+	Passing     int     `json:"passing"`
+	Score       float64 `json:"score"` // percent of the workloads passing
+}
+
+// Report is the result of the latest run, served at GET /api/v1/compliance
+type Report struct {
+	CheckedAt  time.Time      `json:"checked_at"`
+	Workloads  int            `json:"workloads"`
+	Compliant  int            `json:"compliant"` // workloads breaking no rule
+	Packs      []PackScore    `json:"packs"`
+	BySeverity map[string]int `json:"by_severity"`
+	Findings   []Finding      `json:"findings"`
+	Errors     []string       `json:"errors,omitempty"` // sources that could not be read
+}
+
+type Checker struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	clientset  kubernetes.Interface
+	packs      []Pack              // enabled, in rule_packs order
+	rules      []Rule              // of the enabled packs
+	packsOf    map[string][]string // enabled packs of each rule
+	store      Store               // nil without a findings space
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu     sync.RWMutex
+	report *Report
+}
+
+// NewChecker returns a checker of the named rule packs
+func NewChecker(cfg Config, packs map[string]Pack) (*Checker, error) {
+	rules, packsOf, err := enabledRules(packs, cfg.RulePacks)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checker{config: cfg, rules: rules, packsOf: packsOf}
+	for _, name := range cfg.RulePacks {
+		c.packs = append(c.packs, packs[name])
+	}
+	return c, nil
+}
+
+// Main checks compliance every run_interval until interrupted, or once with
+// --mode=job. It is the entry point of cmd/compliance-checker and of
+// "devops-apps compliance".
+func Main() {
+	logger := logging.Setup("compliance-checker")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "compliance-checker", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "compliance-checker",
+		Version:     version,
+		Description: "Checks ConfigHub units and live workloads against compliance rule packs",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	packs, err := loadPacks(cfg.PacksConfig)
+	if err != nil {
+		logging.Fatal("Failed to load rule packs", logging.Err(err))
+	}
+	checker, err := NewChecker(cfg, packs)
+	if err != nil {
+		logging.Fatal("Failed to load rule packs", logging.Err(err))
+	}
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	checker.app = app
+	checker.clientset = app.K8s.Clientset
+	checker.metrics = metrics.New("compliance-checker", version, cfg.ClusterName)
+	checker.cubBreaker = breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	checker.cubLimit = ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(checker.cubBreaker)
+	checker.metrics.Collect(metrics.Limiter(checker.cubLimit))
+	checker.metrics.Collect(metrics.ListCache(listcache.Install()))
+	checker.metrics.Collect(metrics.Breakers(checker.cubBreaker))
+	checker.health = health.New()
+	checker.health.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	checker.health.Register("confighub", health.Optional, health.Breaker(checker.cubBreaker))
+	checker.metrics.Collect(checker.collect)
+
+	if err := checker.initialize(); err != nil {
+		logging.Fatal("Failed to initialize ConfigHub resources", logging.Err(err))
+	}
+	for _, p := range checker.packs {
+		slog.Info("Checking rule pack", "pack", p.Name, "rules", len(p.Rules))
+	}
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, checker.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "compliance-checker", checker.check, checker.Report, os.Stdout, store); err != nil {
+			logging.Fatal("Compliance check failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Compliance dashboard listening", "addr", addr)
+	group.Serve(addr, guard.Protect(checker.handler(), "/metrics", "/health"))
+	group.Go(checker.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Compliance dashboard stopped", logging.Err(err))
+	}
+}
+
+// initialize finds the findings space, creating it when missing
+func (c *Checker) initialize() error {
+	if c.config.FindingsSpace == "" {
+		slog.Info("No findings space, findings are kept in memory")
+		return nil
+	}
+	spaces, err := ratelimit.Call(context.Background(), c.cubLimit, "ListSpaces", "all", c.app.Cub.ListSpaces)
+	if err != nil {
+		return fmt.Errorf("list spaces: %w", err)
+	}
+	for _, s := range spaces {
+		if s.Slug == c.config.FindingsSpace {
+			c.store = cubStore{cub: c.app.Cub, limit: c.cubLimit, space: s.SpaceID}
+			slog.Info("Using existing findings space", logging.Space(s.Slug), "space_id", s.SpaceID)
+			return nil
+		}
+	}
+
+	space, err := c.app.Cub.CreateSpace(sdk.CreateSpaceRequest{
+		Slug:        c.config.FindingsSpace,
+		DisplayName: "Compliance Findings",
+		Labels: map[string]string{
+			"app":  "compliance-checker",
+			"team": "security",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create space: %w", err)
+	}
+	c.store = cubStore{cub: c.app.Cub, limit: c.cubLimit, space: space.SpaceID}
+	slog.Info("Created findings space", logging.Space(space.Slug), "space_id", space.SpaceID)
+	return nil
+}
+
+// run checks now and every run_interval until interrupted
+func (c *Checker) run(ctx context.Context) error {
+	ticker := time.NewTicker(c.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.check(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Compliance check failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check evaluates the units and live workloads and stores the findings.
+// When a source cannot be read the report says so and the findings units
+// are left alone, so the source's workloads aren't marked gone.
+func (c *Checker) check(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "compliance.check")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := c.metrics.Cycle("check", "")
+	defer func() { cycleDone(err) }()
+
+	var workloads []workload
+	var errs []string
+	if len(c.config.Spaces) > 0 {
+		units, err := c.unitWorkloads(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("units: %v", err))
+		}
+		workloads = append(workloads, units...)
+	}
+	if c.config.CheckLive {
+		live, err := liveWorkloads(ctx, c.clientset, c.config)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("cluster: %v", err))
+		}
+		workloads = append(workloads, live...)
+	}
+
+	findings := c.evaluate(workloads)
+	report := c.buildReport(workloads, findings, errs)
+	c.mu.Lock()
+	c.report = report
+	c.mu.Unlock()
+	slog.Info("Compliance checked", "workloads", report.Workloads, "compliant", report.Compliant, "findings", len(findings))
+
+	if len(errs) > 0 {
+		return fmt.Errorf("read workloads: %s", strings.Join(errs, "; "))
+	}
+	if c.store == nil {
+		return nil
+	}
+	written, err := saveFindings(ctx, c.store, workloads, findings)
+	if err != nil {
+		return fmt.Errorf("store findings: %w", err)
+	}
+	if written > 0 {
+		slog.Info("Stored findings", "units", written)
+	}
+	return nil
+}
+
+// evaluate runs the enabled rules on every workload
+func (c *Checker) evaluate(workloads []workload) []Finding {
+	var findings []Finding
+	for _, w := range workloads {
+		slug := findingSlug(w)
+		for _, r := range c.rules {
+			for _, v := range r.Check(w.Spec) {
+				findings = append(findings, Finding{
+					Source: w.Source, Space: w.Space, Unit: w.Unit, Resource: w.Resource(), Container: v.Container,
+					Rule: r.ID, Packs: c.packsOf[r.ID], Severity: r.Severity, Message: v.Message, slug: slug,
+				})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		return a.slug < b.slug
+	})
+	return findings
+}
+
+// buildReport scores the packs on the findings of a run
+func (c *Checker) buildReport(workloads []workload, findings []Finding, errs []string) *Report {
+	report := &Report{
+		CheckedAt:  time.Now(),
+		Workloads:  len(workloads),
+		BySeverity: map[string]int{SeverityCritical: 0, SeverityHigh: 0, SeverityMedium: 0, SeverityLow: 0},
+		Findings:   findings,
+		Errors:     errs,
+	}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+
+	failing := map[string]bool{}                // workloads breaking any rule
+	failingPack := map[string]map[string]bool{} // by pack
+	for _, f := range findings {
+		report.BySeverity[f.Severity]++
+		failing[f.slug] = true
+		for _, p := range f.Packs {
+			if failingPack[p] == nil {
+				failingPack[p] = map[string]bool{}
+			}
+			failingPack[p][f.slug] = true
+		}
+	}
+	report.Compliant = len(workloads) - len(failing)
+	for _, p := range c.packs {
+		score := PackScore{Name: p.Name, Description: p.Description, Rules: len(p.Rules), Passing: len(workloads) - len(failingPack[p.Name]), Score: 100}
+		if len(workloads) > 0 {
+			score.Score = float64(score.Passing) * 100 / float64(len(workloads))
+		}
+		report.Packs = append(report.Packs, score)
+	}
+	return report
+}
+
+// Report returns the latest report, nil before the first run
+func (c *Checker) Report() *Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.report
+}
+
+// collect exports the latest report's scores and findings
+func (c *Checker) collect(emit metrics.Emit) {
+	report := c.Report()
+	if report == nil {
+		return
+	}
+	for _, p := range report.Packs {
+		emit("compliance_score_percent", "Workloads passing every rule of a pack, in percent.", "gauge", metrics.Labels{"pack": p.Name}, p.Score)
+	}
+	for severity, n := range report.BySeverity {
+		emit("compliance_findings", "Rules broken by the workloads at the latest check.", "gauge", metrics.Labels{"severity": severity}, float64(n))
+	}
+}
@@ -0,0 +1,284 @@
+package compliancechecker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newChecker(t *testing.T, packs ...string) *Checker {
+	t.Helper()
+	all, err := loadPacks("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.RulePacks = packs
+	c, err := NewChecker(cfg, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.metrics = metrics.New("compliance-checker", version, "test")
+	return c
+}
+
+func TestParseWorkload(t *testing.T) {
+	for _, tc := range []struct {
+		name, data string
+		want       string // resource, empty for no workload
+		containers int
+	}{
+		{
+			name: "deployment in the default namespace",
+			data: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\nspec:\n  template:\n    spec:\n      containers:\n        - name: api\n          image: api:1\n",
+			want: "Deployment/default/api", containers: 1,
+		},
+		{
+			name: "cronjob",
+			data: `{"kind": "CronJob", "metadata": {"name": "report", "namespace": "batch"}, "spec": {"jobTemplate": {"spec": {"template": {"spec": {"containers": [{"name": "report"}, {"name": "upload"}]}}}}}}`,
+			want: "CronJob/batch/report", containers: 2,
+		},
+		{name: "service", data: "kind: Service\nmetadata:\n  name: api\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := parseWorkload(tc.data, "default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want == "" {
+				if w != nil {
+					t.Errorf("got workload %s, want none", w.Resource())
+				}
+				return
+			}
+			if w == nil || w.Resource() != tc.want || len(w.Spec.Containers) != tc.containers {
+				t.Errorf("got %+v, want %s with %d containers", w, tc.want, tc.containers)
+			}
+		})
+	}
+	if _, err := parseWorkload("kind: [", "default"); err == nil {
+		t.Error("invalid YAML accepted")
+	}
+}
+
+func TestLiveWorkloads(t *testing.T) {
+	spec := corev1.PodTemplateSpec{Spec: compliant()}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api"}, Spec: appsv1.DeploymentSpec{Template: spec}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "db"}, Spec: appsv1.StatefulSetSpec{Template: spec}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "kube-proxy"}, Spec: appsv1.DaemonSetSpec{Template: spec}},
+		&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "reports", Name: "nightly"}, Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: spec}}}},
+	)
+
+	for _, tc := range []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{name: "all namespaces", want: []string{"Deployment/payments/api", "StatefulSet/payments/db", "CronJob/reports/nightly"}},
+		{name: "watched namespace", namespace: "payments", want: []string{"Deployment/payments/api", "StatefulSet/payments/db"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.WatchNamespace = tc.namespace
+			workloads, err := liveWorkloads(context.Background(), clientset, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, w := range workloads {
+				got = append(got, w.Resource())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("workloads = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := newChecker(t, "cis-basic", "restricted")
+	root := compliant()
+	root.SecurityContext = nil
+	root.Containers[0].Image = "api:latest"
+	workloads := []workload{
+		{Source: SourceLive, Kind: "Deployment", Namespace: "payments", Name: "api", Spec: &root},
+		{Source: SourceUnit, Space: "prod", Unit: "web", Kind: "Deployment", Namespace: "web", Name: "web", Spec: func() *corev1.PodSpec { s := compliant(); return &s }()},
+	}
+
+	findings := c.evaluate(workloads)
+	var got []string
+	for _, f := range findings {
+		got = append(got, fmt.Sprintf("%s/%s/%s", f.Rule, f.Severity, strings.Join(f.Packs, "+")))
+	}
+	want := []string{"no-latest-tag/high/cis-basic+restricted", "run-as-non-root/high/cis-basic+restricted"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("findings = %v, want %v", got, want)
+	}
+
+	report := c.buildReport(workloads, findings, nil)
+	if report.Workloads != 2 || report.Compliant != 1 || report.BySeverity[SeverityHigh] != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	for _, p := range report.Packs {
+		if p.Passing != 1 || p.Score != 50 {
+			t.Errorf("pack %s: %d passing, score %g; want 1 and 50", p.Name, p.Passing, p.Score)
+		}
+	}
+}
+
+// memoryStore is a Store in memory
+type memoryStore struct {
+	units  map[string]StoredUnit
+	writes []string
+}
+
+func (s *memoryStore) Units(context.Context) (map[string]StoredUnit, error) {
+	units := map[string]StoredUnit{}
+	for slug, u := range s.units {
+		units[slug] = u
+	}
+	return units, nil
+}
+
+func (s *memoryStore) Create(_ context.Context, slug string, labels map[string]string, data string) error {
+	s.units[slug] = StoredUnit{ID: uuid.New(), Labels: labels, Data: data}
+	s.writes = append(s.writes, "create "+slug)
+	return nil
+}
+
+func (s *memoryStore) Update(_ context.Context, id uuid.UUID, labels map[string]string, data string) error {
+	for slug, u := range s.units {
+		if u.ID == id {
+			s.units[slug] = StoredUnit{ID: id, Labels: labels, Data: data}
+			s.writes = append(s.writes, "update "+slug)
+			return nil
+		}
+	}
+	return fmt.Errorf("unit %s not found", id)
+}
+
+func TestSaveFindings(t *testing.T) {
+	c := newChecker(t, "cis-basic")
+	store := &memoryStore{units: map[string]StoredUnit{}}
+	privileged := compliant()
+	yes := true
+	privileged.Containers[0].SecurityContext.Privileged = &yes
+	api := workload{Source: SourceLive, Kind: "Deployment", Namespace: "payments", Name: "api", Spec: &privileged}
+	web := workload{Source: SourceUnit, Space: "prod", Unit: "web", Kind: "Deployment", Namespace: "web", Name: "web", Spec: &privileged}
+
+	save := func(workloads ...workload) []string {
+		t.Helper()
+		store.writes = nil
+		if _, err := saveFindings(context.Background(), store, workloads, c.evaluate(workloads)); err != nil {
+			t.Fatal(err)
+		}
+		return store.writes
+	}
+
+	if got := save(api, web); !reflect.DeepEqual(got, []string{"create compliance-live-deployment-payments-api", "create compliance-unit-prod-web"}) {
+		t.Errorf("first run wrote %v", got)
+	}
+	u := store.units["compliance-unit-prod-web"]
+	if u.Labels["status"] != StatusFailing || u.Labels["severity"] != SeverityCritical || u.Labels["space"] != "prod" {
+		t.Errorf("labels = %v", u.Labels)
+	}
+	var r record
+	if err := json.Unmarshal([]byte(u.Data), &r); err != nil || len(r.Findings) != 1 || r.Findings[0].Rule != "no-privileged" {
+		t.Errorf("data = %s (%v)", u.Data, err)
+	}
+
+	if got := save(api, web); len(got) != 0 {
+		t.Errorf("unchanged run wrote %v", got)
+	}
+
+	fixed := compliant()
+	web.Spec = &fixed
+	if got := save(web); !reflect.DeepEqual(got, []string{"update compliance-live-deployment-payments-api", "update compliance-unit-prod-web"}) {
+		t.Errorf("fix run wrote %v", got)
+	}
+	if got := store.units["compliance-unit-prod-web"].Labels["status"]; got != StatusPassing {
+		t.Errorf("fixed unit status = %s, want %s", got, StatusPassing)
+	}
+	if got := store.units["compliance-live-deployment-payments-api"].Labels["status"]; got != StatusGone {
+		t.Errorf("removed workload status = %s, want %s", got, StatusGone)
+	}
+
+	if got := save(web); len(got) != 0 {
+		t.Errorf("second run after the fix wrote %v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := newChecker(t, "cis-basic")
+	handler := c.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/compliance", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first check: status %d, want 503", rec.Code)
+	}
+
+	root := compliant()
+	root.SecurityContext = nil
+	yes := true
+	privileged := compliant()
+	privileged.Containers[0].SecurityContext.Privileged = &yes
+	workloads := []workload{
+		{Source: SourceLive, Kind: "Deployment", Namespace: "payments", Name: "api", Spec: &root},
+		{Source: SourceUnit, Space: "prod", Unit: "web", Kind: "Deployment", Namespace: "web", Name: "web", Spec: &privileged},
+	}
+	c.report = c.buildReport(workloads, c.evaluate(workloads), nil)
+
+	for _, tc := range []struct {
+		query string
+		code  int
+		want  []string // rules of the findings
+	}{
+		{query: "", code: http.StatusOK, want: []string{"no-privileged", "run-as-non-root"}},
+		{query: "?severity=critical", code: http.StatusOK, want: []string{"no-privileged"}},
+		{query: "?source=live", code: http.StatusOK, want: []string{"run-as-non-root"}},
+		{query: "?severity=urgent", code: http.StatusBadRequest},
+		{query: "?source=git", code: http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/compliance"+tc.query, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.query, rec.Code, tc.code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var report Report
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range report.Findings {
+			got = append(got, f.Rule)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: findings %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Deployment/web/web") || !strings.Contains(rec.Body.String(), "0%") {
+		t.Errorf("dashboard: status %d\n%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,265 @@
+package compliancechecker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Severities, most severe first
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+var severityRank = map[string]int{SeverityCritical: 0, SeverityHigh: 1, SeverityMedium: 2, SeverityLow: 3}
+
+// Rule is one check of a workload's pod template. Check returns a message
+// per violation, naming the container when it is one's.
+type Rule struct {
+	ID       string                                 `json:"id"`
+	Title    string                                 `json:"title"`
+	Severity string                                 `json:"severity"`
+	Check    func(spec *corev1.PodSpec) []Violation `json:"-"`
+}
+
+// Violation is a rule broken by a pod template or one of its containers
+type Violation struct {
+	Container string
+	Message   string
+}
+
+// Rules are the checks rule packs choose from
+var Rules = []Rule{
+	{ID: "no-latest-tag", Title: "Images are pinned to a tag other than latest, or a digest", Severity: SeverityHigh, Check: noLatestTag},
+	{ID: "resource-limits", Title: "Containers set CPU and memory limits", Severity: SeverityMedium, Check: resourceLimits},
+	{ID: "resource-requests", Title: "Containers set CPU and memory requests", Severity: SeverityLow, Check: resourceRequests},
+	{ID: "run-as-non-root", Title: "Containers must run as non-root", Severity: SeverityHigh, Check: runAsNonRoot},
+	{ID: "no-privileged", Title: "No privileged containers", Severity: SeverityCritical, Check: noPrivileged},
+	{ID: "no-privilege-escalation", Title: "Containers set allowPrivilegeEscalation: false", Severity: SeverityMedium, Check: noPrivilegeEscalation},
+	{ID: "read-only-root-filesystem", Title: "Containers have a read-only root filesystem", Severity: SeverityLow, Check: readOnlyRootFilesystem},
+	{ID: "no-host-namespaces", Title: "No hostNetwork, hostPID or hostIPC", Severity: SeverityCritical, Check: noHostNamespaces},
+	{ID: "no-host-path", Title: "No hostPath volumes", Severity: SeverityHigh, Check: noHostPath},
+}
+
+// Pack is a named set of rules, enabled with rule_packs
+type Pack struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description,omitempty"`
+	Rules       []string `yaml:"rules" json:"rules"`
+}
+
+// BuiltinPacks are the packs every checker knows
+var BuiltinPacks = []Pack{
+	{
+		Name:        "cis-basic",
+		Description: "CIS Kubernetes Benchmark style workload checks (section 5)",
+		Rules:       []string{"no-latest-tag", "resource-limits", "run-as-non-root", "no-privileged", "no-host-namespaces", "no-host-path"},
+	},
+	{
+		Name:        "restricted",
+		Description: "cis-basic plus the hardening of the restricted Pod Security Standard",
+		Rules: []string{"no-latest-tag", "resource-limits", "resource-requests", "run-as-non-root", "no-privileged",
+			"no-privilege-escalation", "read-only-root-filesystem", "no-host-namespaces", "no-host-path"},
+	},
+}
+
+// rule returns the rule with id
+func rule(id string) (Rule, bool) {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// loadPacks returns the built-in packs plus those of the packs file at path,
+// which a missing file leaves out:
+//
+//	packs:
+//	  - name: payments
+//	    description: PCI workloads
+//	    rules: [no-latest-tag, no-privileged, read-only-root-filesystem]
+func loadPacks(path string) (map[string]Pack, error) {
+	packs := map[string]Pack{}
+	for _, p := range BuiltinPacks {
+		packs[p.Name] = p
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return packs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read packs config: %w", err)
+	}
+	var file struct {
+		Packs []Pack `yaml:"packs"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse packs config: %w", err)
+	}
+	for i, p := range file.Packs {
+		if p.Name == "" {
+			return nil, fmt.Errorf("pack %d has no name", i)
+		}
+		if len(p.Rules) == 0 {
+			return nil, fmt.Errorf("pack %s has no rules", p.Name)
+		}
+		for _, id := range p.Rules {
+			if _, ok := rule(id); !ok {
+				return nil, fmt.Errorf("pack %s: unknown rule %q", p.Name, id)
+			}
+		}
+		packs[p.Name] = p
+	}
+	return packs, nil
+}
+
+// enabledRules returns the rules of the named packs, each once, and the
+// packs each rule belongs to
+func enabledRules(packs map[string]Pack, names []string) ([]Rule, map[string][]string, error) {
+	var rules []Rule
+	packsOf := map[string][]string{}
+	for _, name := range names {
+		p, ok := packs[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown rule pack %q", name)
+		}
+		for _, id := range p.Rules {
+			r, ok := rule(id)
+			if !ok {
+				return nil, nil, fmt.Errorf("pack %s: unknown rule %q", name, id)
+			}
+			if len(packsOf[id]) == 0 {
+				rules = append(rules, r)
+			}
+			packsOf[id] = append(packsOf[id], name)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return severityRank[rules[i].Severity] < severityRank[rules[j].Severity] })
+	return rules, packsOf, nil
+}
+
+// containers returns the init and regular containers of a pod template
+func containers(spec *corev1.PodSpec) []corev1.Container {
+	return append(append([]corev1.Container(nil), spec.InitContainers...), spec.Containers...)
+}
+
+func noLatestTag(spec *corev1.PodSpec) []Violation {
+	var violations []Violation
+	for _, c := range containers(spec) {
+		image := c.Image
+		if strings.Contains(image, "@sha256:") {
+			continue
+		}
+		tag := ""
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			tag = image[i+1:]
+		}
+		if tag == "" || tag == "latest" {
+			violations = append(violations, Violation{c.Name, fmt.Sprintf("image %s is not pinned", image)})
+		}
+	}
+	return violations
+}
+
+func resourceLimits(spec *corev1.PodSpec) []Violation {
+	return missingResources(spec, "limits", func(c corev1.Container) corev1.ResourceList { return c.Resources.Limits })
+}
+
+func resourceRequests(spec *corev1.PodSpec) []Violation {
+	return missingResources(spec, "requests", func(c corev1.Container) corev1.ResourceList { return c.Resources.Requests })
+}
+
+func missingResources(spec *corev1.PodSpec, what string, list func(corev1.Container) corev1.ResourceList) []Violation {
+	var violations []Violation
+	for _, c := range containers(spec) {
+		var missing []string
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if _, ok := list(c)[name]; !ok {
+				missing = append(missing, string(name))
+			}
+		}
+		if len(missing) > 0 {
+			violations = append(violations, Violation{c.Name, fmt.Sprintf("no %s %s", strings.Join(missing, " or "), what)})
+		}
+	}
+	return violations
+}
+
+func runAsNonRoot(spec *corev1.PodSpec) []Violation {
+	podLevel := spec.SecurityContext != nil && spec.SecurityContext.RunAsNonRoot != nil && *spec.SecurityContext.RunAsNonRoot
+	var violations []Violation
+	for _, c := range containers(spec) {
+		nonRoot := podLevel
+		if c.SecurityContext != nil && c.SecurityContext.RunAsNonRoot != nil {
+			nonRoot = *c.SecurityContext.RunAsNonRoot
+		}
+		if !nonRoot {
+			violations = append(violations, Violation{c.Name, "runAsNonRoot is not true"})
+		}
+	}
+	return violations
+}
+
+func noPrivileged(spec *corev1.PodSpec) []Violation {
+	var violations []Violation
+	for _, c := range containers(spec) {
+		if sc := c.SecurityContext; sc != nil && sc.Privileged != nil && *sc.Privileged {
+			violations = append(violations, Violation{c.Name, "privileged"})
+		}
+	}
+	return violations
+}
+
+func noPrivilegeEscalation(spec *corev1.PodSpec) []Violation {
+	var violations []Violation
+	for _, c := range containers(spec) {
+		if sc := c.SecurityContext; sc == nil || sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violations = append(violations, Violation{c.Name, "allowPrivilegeEscalation is not false"})
+		}
+	}
+	return violations
+}
+
+func readOnlyRootFilesystem(spec *corev1.PodSpec) []Violation {
+	var violations []Violation
+	for _, c := range containers(spec) {
+		if sc := c.SecurityContext; sc == nil || sc.ReadOnlyRootFilesystem == nil || !*sc.ReadOnlyRootFilesystem {
+			violations = append(violations, Violation{c.Name, "root filesystem is writable"})
+		}
+	}
+	return violations
+}
+
+func noHostNamespaces(spec *corev1.PodSpec) []Violation {
+	var violations []Violation
+	for _, ns := range []struct {
+		name string
+		on   bool
+	}{{"hostNetwork", spec.HostNetwork}, {"hostPID", spec.HostPID}, {"hostIPC", spec.HostIPC}} {
+		if ns.on {
+			violations = append(violations, Violation{"", ns.name + " is true"})
+		}
+	}
+	return violations
+}
+
+func noHostPath(spec *corev1.PodSpec) []Violation {
+	var violations []Violation
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			violations = append(violations, Violation{"", fmt.Sprintf("volume %s mounts %s from the node", v.Name, v.HostPath.Path)})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,181 @@
+package compliancechecker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// compliant is a pod template that passes every rule
+func compliant() corev1.PodSpec {
+	yes, no := true, false
+	resources := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")}
+	return corev1.PodSpec{
+		SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &yes},
+		Containers: []corev1.Container{{
+			Name:      "api",
+			Image:     "registry.example.com/api:1.4.2",
+			Resources: corev1.ResourceRequirements{Limits: resources, Requests: resources},
+			SecurityContext: &corev1.SecurityContext{
+				AllowPrivilegeEscalation: &no,
+				ReadOnlyRootFilesystem:   &yes,
+			},
+		}},
+	}
+}
+
+func TestRules(t *testing.T) {
+	yes, no := true, false
+	for _, tc := range []struct {
+		name   string
+		change func(*corev1.PodSpec)
+		want   []string // rule/container of each violation
+	}{
+		{name: "compliant", change: func(*corev1.PodSpec) {}},
+		{
+			name:   "latest tag",
+			change: func(s *corev1.PodSpec) { s.Containers[0].Image = "registry.example.com:5000/api:latest" },
+			want:   []string{"no-latest-tag/api"},
+		},
+		{
+			name:   "no tag on a registry with a port",
+			change: func(s *corev1.PodSpec) { s.Containers[0].Image = "registry.example.com:5000/api" },
+			want:   []string{"no-latest-tag/api"},
+		},
+		{
+			name:   "digest",
+			change: func(s *corev1.PodSpec) { s.Containers[0].Image = "registry.example.com/api@sha256:1111" },
+		},
+		{
+			name: "init container without limits",
+			change: func(s *corev1.PodSpec) {
+				s.InitContainers = []corev1.Container{{Name: "migrate", Image: "migrate:2", SecurityContext: s.Containers[0].SecurityContext}}
+			},
+			want: []string{"resource-limits/migrate", "resource-requests/migrate"},
+		},
+		{
+			name: "root",
+			change: func(s *corev1.PodSpec) {
+				s.SecurityContext = nil
+				s.Containers[0].SecurityContext.RunAsNonRoot = nil
+			},
+			want: []string{"run-as-non-root/api"},
+		},
+		{
+			name:   "runAsNonRoot overridden on the container",
+			change: func(s *corev1.PodSpec) { s.Containers[0].SecurityContext.RunAsNonRoot = &no },
+			want:   []string{"run-as-non-root/api"},
+		},
+		{
+			name: "privileged",
+			change: func(s *corev1.PodSpec) {
+				s.Containers[0].SecurityContext.Privileged = &yes
+				s.Containers[0].SecurityContext.AllowPrivilegeEscalation = nil
+			},
+			want: []string{"no-privileged/api", "no-privilege-escalation/api"},
+		},
+		{
+			name:   "writable root filesystem",
+			change: func(s *corev1.PodSpec) { s.Containers[0].SecurityContext.ReadOnlyRootFilesystem = nil },
+			want:   []string{"read-only-root-filesystem/api"},
+		},
+		{
+			name: "host namespaces and paths",
+			change: func(s *corev1.PodSpec) {
+				s.HostNetwork, s.HostPID = true, true
+				s.Volumes = []corev1.Volume{{Name: "docker", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}}}
+			},
+			want: []string{"no-host-namespaces/", "no-host-namespaces/", "no-host-path/"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := compliant()
+			tc.change(&spec)
+			var got []string
+			for _, r := range Rules {
+				for _, v := range r.Check(&spec) {
+					got = append(got, r.ID+"/"+v.Container)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("violations = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadPacks(t *testing.T) {
+	dir := t.TempDir()
+	packs, err := loadPacks(filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if len(packs) != len(BuiltinPacks) {
+		t.Errorf("missing file gave %d packs, want the %d built in", len(packs), len(BuiltinPacks))
+	}
+
+	for _, tc := range []struct {
+		name, file, wantErr string
+	}{
+		{name: "valid", file: "packs:\n  - name: payments\n    rules: [no-privileged, read-only-root-filesystem]\n"},
+		{name: "unknown rule", file: "packs:\n  - name: payments\n    rules: [no-root]\n", wantErr: `unknown rule "no-root"`},
+		{name: "no rules", file: "packs:\n  - name: payments\n", wantErr: "has no rules"},
+		{name: "no name", file: "packs:\n  - rules: [no-privileged]\n", wantErr: "has no name"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".yaml")
+			if err := os.WriteFile(path, []byte(tc.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			packs, err := loadPacks(path)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := packs["payments"].Rules; !reflect.DeepEqual(got, []string{"no-privileged", "read-only-root-filesystem"}) {
+				t.Errorf("payments rules = %v", got)
+			}
+			if _, ok := packs["cis-basic"]; !ok {
+				t.Error("built-in packs dropped")
+			}
+		})
+	}
+}
+
+func TestEnabledRules(t *testing.T) {
+	packs := map[string]Pack{}
+	for _, p := range BuiltinPacks {
+		packs[p.Name] = p
+	}
+	packs["payments"] = Pack{Name: "payments", Rules: []string{"read-only-root-filesystem", "no-privileged"}}
+
+	rules, packsOf, err := enabledRules(packs, []string{"cis-basic", "payments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range rules {
+		ids = append(ids, r.ID)
+	}
+	want := []string{"no-privileged", "no-host-namespaces", "no-latest-tag", "run-as-non-root", "no-host-path", "resource-limits", "read-only-root-filesystem"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("rules = %v, want %v (once each, most severe first)", ids, want)
+	}
+	if got := packsOf["no-privileged"]; !reflect.DeepEqual(got, []string{"cis-basic", "payments"}) {
+		t.Errorf("packs of no-privileged = %v", got)
+	}
+
+	if _, _, err := enabledRules(packs, []string{"pci"}); err == nil {
+		t.Error("unknown pack accepted")
+	}
+}
@@ -0,0 +1,181 @@
+package compliancechecker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Sources of the workloads checked
+const (
+	SourceUnit = "unit" // a ConfigHub unit
+	SourceLive = "live" // a resource in the cluster
+)
+
+// workload is a pod template to check: of a unit, or of a live resource
+type workload struct {
+	Source    string
+	Space     string // of a unit
+	Unit      string // slug of a unit
+	Kind      string
+	Namespace string
+	Name      string
+	Spec      *corev1.PodSpec
+}
+
+// Resource names the workload as Kind/namespace/name
+func (w workload) Resource() string {
+	return fmt.Sprintf("%s/%s/%s", w.Kind, w.Namespace, w.Name)
+}
+
+// parseWorkload reads the pod template of a unit's YAML or JSON; nil when
+// the unit is not a workload
+func parseWorkload(data, defaultNamespace string) (*workload, error) {
+	var meta struct {
+		metav1.TypeMeta   `json:",inline"`
+		metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(data), &meta); err != nil {
+		return nil, err
+	}
+
+	var spec *corev1.PodSpec
+	switch meta.Kind {
+	case "Deployment":
+		var d appsv1.Deployment
+		if err := yaml.Unmarshal([]byte(data), &d); err != nil {
+			return nil, err
+		}
+		spec = &d.Spec.Template.Spec
+	case "StatefulSet":
+		var s appsv1.StatefulSet
+		if err := yaml.Unmarshal([]byte(data), &s); err != nil {
+			return nil, err
+		}
+		spec = &s.Spec.Template.Spec
+	case "DaemonSet":
+		var d appsv1.DaemonSet
+		if err := yaml.Unmarshal([]byte(data), &d); err != nil {
+			return nil, err
+		}
+		spec = &d.Spec.Template.Spec
+	case "CronJob":
+		var c batchv1.CronJob
+		if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+			return nil, err
+		}
+		spec = &c.Spec.JobTemplate.Spec.Template.Spec
+	default:
+		return nil, nil
+	}
+
+	namespace := meta.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	return &workload{Source: SourceUnit, Kind: meta.Kind, Namespace: namespace, Name: meta.Name, Spec: spec}, nil
+}
+
+// unitWorkloads reads the workload units of the spaces with the given slugs
+func (c *Checker) unitWorkloads(ctx context.Context) ([]workload, error) {
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, c.cubLimit, "ListSpaces", "all", c.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list spaces: %w", err)
+	}
+	ids := map[string]*sdk.Space{}
+	for _, s := range spaces {
+		ids[s.Slug] = s
+	}
+
+	var workloads []workload
+	for _, slug := range c.config.Spaces {
+		space, ok := ids[slug]
+		if !ok {
+			return nil, fmt.Errorf("space %s not found", slug)
+		}
+		units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, c.cubLimit, "ListUnits", space.SpaceID.String(), func() ([]*sdk.Unit, error) {
+				return c.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: space.SpaceID})
+			})
+		}, tracing.SpaceKey.String(slug))
+		if err != nil {
+			return nil, fmt.Errorf("list units of %s: %w", slug, err)
+		}
+		for _, u := range units {
+			w, err := parseWorkload(u.Data, c.config.Namespace)
+			if err != nil {
+				slog.Debug("Skipping unit that does not parse", logging.Space(slug), logging.Unit(u.Slug), logging.Err(err))
+				continue
+			}
+			if w == nil {
+				continue // not a workload
+			}
+			w.Space, w.Unit = slug, u.Slug
+			workloads = append(workloads, *w)
+		}
+	}
+	return workloads, nil
+}
+
+// liveWorkloads lists the Deployments, StatefulSets, DaemonSets and CronJobs
+// of the cluster, in watch_namespace when set, leaving out exclude_namespaces
+func liveWorkloads(ctx context.Context, clientset kubernetes.Interface, cfg Config) ([]workload, error) {
+	excluded := map[string]bool{}
+	for _, ns := range cfg.ExcludeNamespaces {
+		excluded[ns] = true
+	}
+	var workloads []workload
+	add := func(kind string, meta metav1.ObjectMeta, spec *corev1.PodSpec) {
+		if !excluded[meta.Namespace] {
+			workloads = append(workloads, workload{Source: SourceLive, Kind: kind, Namespace: meta.Namespace, Name: meta.Name, Spec: spec})
+		}
+	}
+	list := metav1.ListOptions{}
+
+	deployments, err := clientset.AppsV1().Deployments(cfg.WatchNamespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		add("Deployment", d.ObjectMeta, &d.Spec.Template.Spec)
+	}
+	statefulSets, err := clientset.AppsV1().StatefulSets(cfg.WatchNamespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		add("StatefulSet", s.ObjectMeta, &s.Spec.Template.Spec)
+	}
+	daemonSets, err := clientset.AppsV1().DaemonSets(cfg.WatchNamespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list daemonsets: %w", err)
+	}
+	for i := range daemonSets.Items {
+		d := &daemonSets.Items[i]
+		add("DaemonSet", d.ObjectMeta, &d.Spec.Template.Spec)
+	}
+	cronJobs, err := clientset.BatchV1().CronJobs(cfg.WatchNamespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list cronjobs: %w", err)
+	}
+	for i := range cronJobs.Items {
+		c := &cronJobs.Items[i]
+		add("CronJob", c.ObjectMeta, &c.Spec.JobTemplate.Spec.Template.Spec)
+	}
+	return workloads, nil
+}
@@ -0,0 +1,174 @@
+package compliancechecker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// FindingType is the "type" label of the units holding findings
+const FindingType = "compliance-finding"
+
+// Statuses of a findings unit, in its "status" label
+const (
+	StatusFailing = "failing" // the workload breaks rules
+	StatusPassing = "passing" // it broke rules before and no longer does
+	StatusGone    = "gone"    // it is no longer checked
+)
+
+// Store keeps one unit per workload that broke a rule in the findings
+// space, so findings have ConfigHub's history and can be queried by label
+type Store interface {
+	// Units returns the findings units by slug
+	Units(ctx context.Context) (map[string]StoredUnit, error)
+	Create(ctx context.Context, slug string, labels map[string]string, data string) error
+	Update(ctx context.Context, id uuid.UUID, labels map[string]string, data string) error
+}
+
+// StoredUnit is a findings unit in ConfigHub
+type StoredUnit struct {
+	ID     uuid.UUID
+	Labels map[string]string
+	Data   string
+}
+
+// record is the data of a findings unit
+type record struct {
+	Source   string    `json:"source"`
+	Space    string    `json:"space,omitempty"`
+	Unit     string    `json:"unit,omitempty"`
+	Resource string    `json:"resource"`
+	Status   string    `json:"status"`
+	Findings []Finding `json:"findings"`
+}
+
+// findingSlug is the slug of a workload's findings unit
+func findingSlug(w workload) string {
+	name := fmt.Sprintf("compliance-live-%s-%s-%s", w.Kind, w.Namespace, w.Name)
+	if w.Source == SourceUnit {
+		name = fmt.Sprintf("compliance-unit-%s-%s", w.Space, w.Unit)
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, name)
+}
+
+// saveFindings writes the findings of a run to store: a unit for every
+// workload breaking rules, and the status of those that broke rules before.
+// Units whose data would not change are not written. It returns how many
+// units were written.
+func saveFindings(ctx context.Context, store Store, workloads []workload, findings []Finding) (int, error) {
+	existing, err := store.Units(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list findings units: %w", err)
+	}
+
+	bySlug := map[string][]Finding{}
+	for _, f := range findings {
+		bySlug[f.slug] = append(bySlug[f.slug], f)
+	}
+	records := map[string]record{}
+	for _, w := range workloads {
+		slug := findingSlug(w)
+		r := record{Source: w.Source, Space: w.Space, Unit: w.Unit, Resource: w.Resource(), Status: StatusFailing, Findings: bySlug[slug]}
+		if len(r.Findings) == 0 {
+			if _, ok := existing[slug]; !ok {
+				continue
+			}
+			r.Status, r.Findings = StatusPassing, []Finding{}
+		}
+		records[slug] = r
+	}
+	for slug, u := range existing {
+		if _, ok := records[slug]; ok || u.Labels["status"] == StatusGone {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal([]byte(u.Data), &r); err != nil {
+			continue
+		}
+		r.Status, r.Findings = StatusGone, []Finding{}
+		records[slug] = r
+	}
+
+	slugs := make([]string, 0, len(records))
+	for slug := range records {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	written := 0
+	for _, slug := range slugs {
+		r := records[slug]
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return written, err
+		}
+		labels := map[string]string{"type": FindingType, "source": r.Source, "status": r.Status}
+		if r.Space != "" {
+			labels["space"] = r.Space
+		}
+		if len(r.Findings) > 0 {
+			labels["severity"] = r.Findings[0].Severity // sorted most severe first
+		}
+
+		u, ok := existing[slug]
+		switch {
+		case ok && u.Data == string(data):
+			continue
+		case ok:
+			err = store.Update(ctx, u.ID, labels, string(data))
+		default:
+			err = store.Create(ctx, slug, labels, string(data))
+		}
+		if err != nil {
+			return written, fmt.Errorf("write %s: %w", slug, err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// cubStore is a Store in the ConfigHub space with ID space
+type cubStore struct {
+	cub   *sdk.ConfigHubClient
+	limit *ratelimit.Limiter
+	space uuid.UUID
+}
+
+func (s cubStore) Units(ctx context.Context) (map[string]StoredUnit, error) {
+	where := fmt.Sprintf("Labels['type'] = '%s'", FindingType)
+	units, err := ratelimit.Call(ctx, s.limit, "ListUnits", s.space.String()+"/"+where, func() ([]*sdk.Unit, error) {
+		return s.cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.space, Where: where})
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]StoredUnit, len(units))
+	for _, u := range units {
+		stored[u.Slug] = StoredUnit{ID: u.UnitID, Labels: u.Labels, Data: u.Data}
+	}
+	return stored, nil
+}
+
+func (s cubStore) Create(ctx context.Context, slug string, labels map[string]string, data string) error {
+	_, err := s.cub.CreateUnit(s.space, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+	return err
+}
+
+func (s cubStore) Update(ctx context.Context, id uuid.UUID, labels map[string]string, data string) error {
+	_, err := s.cub.UpdateUnit(s.space, id, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+	return err
+}
@@ -0,0 +1,89 @@
+package controlpanel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Cluster is one cluster's set of apps, as listed in the clusters file:
+//
+//	clusters:
+//	  - name: prod-eu
+//	    drift_detector: http://drift-detector.devops-apps.svc:8084
+//	    cost_optimizer: http://cost-optimizer.devops-apps.svc:8081
+//	    cost_impact_monitor: http://cost-impact-monitor.cost-monitoring.svc:8083
+//
+// A cluster's API token is read from <name>-api-token in SECRETS_DIR, or
+// given as api_token, which may reference an environment variable as ${NAME}.
+type Cluster struct {
+	Name              string `yaml:"name" json:"name"`
+	DriftDetector     string `yaml:"drift_detector" json:"drift_detector,omitempty"`
+	CostOptimizer     string `yaml:"cost_optimizer" json:"cost_optimizer,omitempty"`
+	CostImpactMonitor string `yaml:"cost_impact_monitor" json:"cost_impact_monitor,omitempty"`
+	APIToken          string `yaml:"api_token" json:"-"`
+}
+
+// localCluster is the cluster of the URLs in the config, named "local"
+// unless cluster_name says otherwise
+func localCluster(cfg Config) Cluster {
+	name := cfg.ClusterName
+	if name == "" {
+		name = "local"
+	}
+	return Cluster{
+		Name:              name,
+		DriftDetector:     cfg.DriftDetectorURL,
+		CostOptimizer:     cfg.CostOptimizerURL,
+		CostImpactMonitor: cfg.CostImpactMonitorURL,
+		APIToken:          cfg.APIToken,
+	}
+}
+
+// loadClusters reads the clusters file at path, looking up tokens with
+// secret (see config.Effective.ReadSecretFile). Without the file the panel
+// federates the one cluster of cfg.
+func loadClusters(path string, cfg Config, secret func(name string) (string, error)) ([]Cluster, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Cluster{localCluster(cfg)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read clusters config: %w", err)
+	}
+
+	var file struct {
+		Clusters []Cluster `yaml:"clusters"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse clusters config: %w", err)
+	}
+	if len(file.Clusters) == 0 {
+		return nil, errors.New("clusters config has no clusters")
+	}
+	seen := map[string]bool{}
+	for i := range file.Clusters {
+		c := &file.Clusters[i]
+		switch {
+		case c.Name == "":
+			return nil, fmt.Errorf("cluster %d has no name", i)
+		case seen[c.Name]:
+			return nil, fmt.Errorf("cluster %d: duplicate name %q", i, c.Name)
+		case c.DriftDetector == "" && c.CostOptimizer == "" && c.CostImpactMonitor == "":
+			return nil, fmt.Errorf("cluster %s: no app URLs", c.Name)
+		}
+		seen[c.Name] = true
+
+		c.APIToken = os.ExpandEnv(c.APIToken)
+		token, err := secret(c.Name + "-api-token")
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", c.Name, err)
+		}
+		if token != "" {
+			c.APIToken = token
+		}
+	}
+	return file.Clusters, nil
+}
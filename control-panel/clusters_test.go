@@ -0,0 +1,55 @@
+package controlpanel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadClusters(t *testing.T) {
+	secrets := map[string]string{"prod-api-token": "from-file"}
+	secret := func(name string) (string, error) { return secrets[name], nil }
+	dir := t.TempDir()
+
+	// Without the file the config's URLs are the one cluster
+	cfg := DefaultConfig()
+	cfg.APIToken = "tok"
+	clusters, err := loadClusters(filepath.Join(dir, "missing.yaml"), cfg, secret)
+	if err != nil || len(clusters) != 1 || clusters[0].Name != "local" ||
+		clusters[0].DriftDetector != cfg.DriftDetectorURL || clusters[0].APIToken != "tok" {
+		t.Errorf("loadClusters without a file = %+v, %v", clusters, err)
+	}
+
+	t.Setenv("EDGE_TOKEN", "from-env")
+	path := filepath.Join(dir, "clusters.yaml")
+	os.WriteFile(path, []byte(`clusters:
+  - name: prod
+    drift_detector: http://drift.prod:8084
+    cost_impact_monitor: http://impact.prod:8083
+  - name: edge
+    cost_optimizer: http://cost.edge:8081
+    api_token: ${EDGE_TOKEN}
+`), 0o600)
+	clusters, err = loadClusters(path, cfg, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 || clusters[0].APIToken != "from-file" || clusters[0].CostOptimizer != "" ||
+		clusters[1].APIToken != "from-env" {
+		t.Errorf("Unexpected clusters %+v", clusters)
+	}
+
+	for name, content := range map[string]string{
+		"no clusters": "clusters: []",
+		"duplicate":   "clusters: [{name: a, drift_detector: x}, {name: a, drift_detector: y}]",
+		"no URLs":     "clusters: [{name: a}]",
+	} {
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := loadClusters(path, cfg, secret); err == nil {
+			t.Errorf("%s: expected an error", name)
+		} else if !strings.Contains(err.Error(), "cluster") {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+}
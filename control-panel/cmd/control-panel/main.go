@@ -0,0 +1,10 @@
+// Command control-panel serves one dashboard over the drift-detector,
+// cost-optimizer and cost-impact-monitor of one or more clusters. The same
+// app runs as "devops-apps panel".
+package main
+
+import controlpanel "github.com/monadic/devops-examples/control-panel"
+
+func main() {
+	controlpanel.Main()
+}
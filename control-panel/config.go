@@ -0,0 +1,79 @@
+package controlpanel
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+)
+
+// Config holds the control panel's settings. Field tags give the config file
+// keys and the names of the environment variables that override them.
+type Config struct {
+	Port        int    `yaml:"panel_port" env:"PANEL_PORT"`
+	ClusterName string `yaml:"cluster_name" env:"CLUSTER_NAME"` // cluster of the apps below, and the /metrics label
+	SecretsDir  string `yaml:"secrets_dir" env:"SECRETS_DIR"`   // api-token and <cluster>-api-token files
+
+	// APIs of the apps in this cluster; an empty URL leaves the app out
+	DriftDetectorURL     string `yaml:"drift_detector_url" env:"DRIFT_DETECTOR_URL"`
+	CostOptimizerURL     string `yaml:"cost_optimizer_url" env:"COST_OPTIMIZER_URL"`
+	CostImpactMonitorURL string `yaml:"cost_impact_monitor_url" env:"COST_IMPACT_MONITOR_URL"`
+
+	// A team's API token, sent to apps that serve teams
+	APIToken string `yaml:"api_token" env:"API_TOKEN" secret:"true"`
+
+	// Apps of more clusters; when the file exists it replaces the URLs above
+	ClustersConfig string `yaml:"clusters_config" env:"CLUSTERS_CONFIG"`
+	// OIDC login for the panel; a missing file leaves it open
+	AuthConfig string `yaml:"auth_config" env:"AUTH_CONFIG"`
+
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"REFRESH_INTERVAL"`
+	RequestTimeout  time.Duration `yaml:"request_timeout" env:"REQUEST_TIMEOUT"`
+	RecentActions   int           `yaml:"recent_actions" env:"RECENT_ACTIONS"` // audit entries listed per cluster
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them: the apps' services in one namespace
+func DefaultConfig() Config {
+	return Config{
+		Port:                 8085,
+		DriftDetectorURL:     "http://drift-detector:8084",
+		CostOptimizerURL:     "http://cost-optimizer:8081",
+		CostImpactMonitorURL: "http://cost-impact-monitor:8083",
+		ClustersConfig:       "/etc/control-panel/clusters.yaml",
+		AuthConfig:           "/etc/control-panel/auth.yaml",
+		RefreshInterval:      30 * time.Second,
+		RequestTimeout:       10 * time.Second,
+		RecentActions:        20,
+	}
+}
+
+// Validate rejects settings the panel can't run with
+func (c *Config) Validate() error {
+	switch {
+	case c.Port < 1 || c.Port > 65535:
+		return fmt.Errorf("panel_port %d is not a valid port", c.Port)
+	case c.RefreshInterval <= 0:
+		return fmt.Errorf("refresh_interval must be positive, got %s", c.RefreshInterval)
+	case c.RequestTimeout <= 0:
+		return fmt.Errorf("request_timeout must be positive, got %s", c.RequestTimeout)
+	case c.RecentActions < 0:
+		return fmt.Errorf("recent_actions must not be negative, got %d", c.RecentActions)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = "/etc/control-panel/config.yaml"
+	}
+	effective, err := config.Load(path, &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
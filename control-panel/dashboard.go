@@ -0,0 +1,116 @@
+package controlpanel
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/metrics"
+)
+
+// webFiles holds the dashboard page template
+//
+//go:embed web/index.html.tmpl
+var webFiles embed.FS
+
+var dashboardTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"money": func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+	"delta": func(v float64) string {
+		if v >= 0 {
+			return fmt.Sprintf("+$%.2f", v)
+		}
+		return fmt.Sprintf("-$%.2f", -v)
+	},
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).ParseFS(webFiles, "web/index.html.tmpl"))
+
+// dashboardPage is the data the page is rendered with
+type dashboardPage struct {
+	*Overview
+	RefreshSeconds int
+}
+
+// handler serves the dashboard, its API under /api/v1 and /metrics
+func (p *Panel) handler(refresh time.Duration, reg *metrics.Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		overview := p.Overview()
+		if overview == nil {
+			http.Error(w, "the apps have not been read yet", http.StatusServiceUnavailable)
+			return
+		}
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, dashboardPage{overview, int(refresh.Seconds())}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	})
+	v1 := api.New(mux, "control-panel")
+	v1.HandleFunc("/overview", p.handleOverview, api.Operation{
+		Summary: "Drift, cost and pending changes across the clusters",
+		Query: []api.Param{
+			{Name: "cluster", Description: "one cluster's"},
+			{Name: "space", Description: "one space's"},
+		},
+		Response: Overview{},
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.Handle("/metrics", reg)
+	return mux
+}
+
+// handleOverview serves the overview as JSON, optionally narrowed to one
+// cluster (?cluster=) or space (?space=)
+func (p *Panel) handleOverview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	overview := p.Overview()
+	if overview == nil {
+		http.Error(w, "the apps have not been read yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	cluster, space := r.URL.Query().Get("cluster"), r.URL.Query().Get("space")
+	if cluster != "" || space != "" {
+		filtered := *overview
+		filtered.Clusters, filtered.Spaces = nil, nil
+		for _, c := range overview.Clusters {
+			if cluster == "" || c.Name == cluster {
+				filtered.Clusters = append(filtered.Clusters, c)
+			}
+		}
+		for _, s := range overview.Spaces {
+			if (cluster == "" || s.Cluster == cluster) && (space == "" || strings.EqualFold(s.Space, space)) {
+				filtered.Spaces = append(filtered.Spaces, s)
+			}
+		}
+		filtered.Totals = totals(&filtered)
+		overview = &filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(overview); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
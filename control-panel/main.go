@@ -0,0 +1,80 @@
+// Package controlpanel federates the APIs of drift-detector, cost-optimizer
+// and cost-impact-monitor into one dashboard: drift, spend, pending changes
+// and recent actions for every space of every cluster. It only reads the
+// apps' APIs and needs no ConfigHub token of its own.
+package controlpanel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/runmode"
+)
+
+const version = "1.0.0"
+
+// Main serves the control panel until it fails or is interrupted, or refreshes
+// it once with --mode=job. It is the entry point of cmd/control-panel and of
+// "devops-apps panel".
+func Main() {
+	logger := logging.Setup("control-panel")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+	effective.Log(logging.Printf(logger))
+
+	clusters, err := loadClusters(cfg.ClustersConfig, cfg, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load clusters config", logging.Err(err))
+	}
+	for _, c := range clusters {
+		slog.Info("Federating cluster", logging.Cluster(c.Name),
+			"drift_detector", c.DriftDetector, "cost_optimizer", c.CostOptimizer, "cost_impact_monitor", c.CostImpactMonitor)
+	}
+
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	reg := metrics.New("control-panel", version, cfg.ClusterName)
+	panel := NewPanel(clusters, cfg.RequestTimeout, cfg.RecentActions, reg)
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		refresh := func(ctx context.Context) error {
+			if down := panel.Refresh(ctx).Totals.AppsDown; down > 0 {
+				return fmt.Errorf("%d apps down", down)
+			}
+			return nil
+		}
+		// The panel has no ConfigHub token, so the overview is only printed
+		if err := runmode.Once(ctx, "control-panel", refresh, panel.Overview, os.Stdout, nil); err != nil {
+			logging.Fatal("Refresh failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	group.Go(func(ctx context.Context) error {
+		panel.Run(ctx, cfg.RefreshInterval)
+		return nil
+	})
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Control panel listening", "addr", addr)
+	group.Serve(addr, guard.Protect(panel.handler(cfg.RefreshInterval, reg), "/metrics", "/health"))
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Control panel stopped", logging.Err(err))
+	}
+}
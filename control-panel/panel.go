@@ -0,0 +1,350 @@
+package controlpanel
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+)
+
+// The federated apps
+const (
+	driftDetector     = "drift-detector"
+	costOptimizer     = "cost-optimizer"
+	costImpactMonitor = "cost-impact-monitor"
+)
+
+// App status on the panel
+const (
+	statusOK       = "ok"
+	statusWaiting  = "waiting" // reachable, before its first run
+	statusError    = "error"
+	statusDisabled = "disabled"
+)
+
+// Overview is everything the panel shows, served at GET /api/v1/overview
+type Overview struct {
+	UpdatedAt time.Time       `json:"updated_at"`
+	Totals    Totals          `json:"totals"`
+	Clusters  []ClusterStatus `json:"clusters"`
+	Spaces    []SpaceStatus   `json:"spaces"`
+}
+
+// Totals sums the spaces of every cluster
+type Totals struct {
+	MonthlyCost      float64 `json:"monthly_cost"`
+	ProjectedCost    float64 `json:"projected_cost"`
+	PotentialSavings float64 `json:"potential_savings"`
+	DriftedSpaces    int     `json:"drifted_spaces"`
+	PendingChanges   int     `json:"pending_changes"`
+	HighRiskChanges  int     `json:"high_risk_changes"`
+	AppsDown         int     `json:"apps_down"`
+}
+
+// ClusterStatus is whether a cluster's apps answer, and what they did lately
+type ClusterStatus struct {
+	Name    string        `json:"name"`
+	Apps    []AppStatus   `json:"apps"`
+	Actions []audit.Entry `json:"actions"` // recent mutating actions, newest first
+}
+
+// AppStatus is the outcome of the latest read of one app
+type AppStatus struct {
+	App    string `json:"app"`
+	URL    string `json:"url,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SpaceStatus joins what the apps know about one space
+type SpaceStatus struct {
+	Cluster string          `json:"cluster"`
+	Space   string          `json:"space"` // slug, or ID when only the cost-optimizer knows it
+	Team    string          `json:"team,omitempty"`
+	Drift   *DriftStatus    `json:"drift,omitempty"`
+	Spend   *SpendStatus    `json:"spend,omitempty"`
+	Pending []PendingChange `json:"pending,omitempty"`
+	Savings *SavingsStatus  `json:"savings,omitempty"`
+}
+
+// DriftStatus is the drift-detector's latest report on a space
+type DriftStatus struct {
+	CheckedAt time.Time   `json:"checked_at"`
+	Namespace string      `json:"namespace"`
+	Items     []DriftItem `json:"items"`
+	Summary   string      `json:"summary,omitempty"`
+	Degraded  string      `json:"degraded,omitempty"`
+}
+
+// SpendStatus is the cost-impact-monitor's view of a space's spend
+type SpendStatus struct {
+	AnalyzedAt    time.Time `json:"analyzed_at"`
+	MonthlyCost   float64   `json:"monthly_cost"`
+	ProjectedCost float64   `json:"projected_cost"`
+}
+
+// SavingsStatus is the cost-optimizer's latest analysis of a space
+type SavingsStatus struct {
+	AnalyzedAt       time.Time `json:"analyzed_at"`
+	MonthlyCost      float64   `json:"monthly_cost"`
+	PotentialSavings float64   `json:"potential_savings"`
+	Recommendations  int       `json:"recommendations"` // not yet applied
+}
+
+// Panel polls the apps of every cluster and keeps the latest Overview
+type Panel struct {
+	clusters []Cluster
+	timeout  time.Duration
+	actions  int
+	metrics  *metrics.Registry
+
+	mu       sync.RWMutex
+	overview *Overview
+}
+
+// NewPanel returns a panel over clusters, reading each app with timeout and
+// listing up to actions audit entries per cluster
+func NewPanel(clusters []Cluster, timeout time.Duration, actions int, reg *metrics.Registry) *Panel {
+	return &Panel{clusters: clusters, timeout: timeout, actions: actions, metrics: reg}
+}
+
+// Run refreshes the overview every interval until ctx ends
+func (p *Panel) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Overview returns the latest overview, nil before the first refresh
+func (p *Panel) Overview() *Overview {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.overview
+}
+
+// Refresh reads every cluster at once and replaces the overview
+func (p *Panel) Refresh(ctx context.Context) *Overview {
+	done := p.metrics.Cycle("refresh", "")
+	clusters := make([]ClusterStatus, len(p.clusters))
+	spaces := make([][]SpaceStatus, len(p.clusters))
+	var wg sync.WaitGroup
+	for i, c := range p.clusters {
+		wg.Add(1)
+		go func(i int, c Cluster) {
+			defer wg.Done()
+			clusters[i], spaces[i] = p.readCluster(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	overview := &Overview{UpdatedAt: time.Now(), Clusters: clusters}
+	for _, s := range spaces {
+		overview.Spaces = append(overview.Spaces, s...)
+	}
+	overview.Totals = totals(overview)
+	if overview.Totals.AppsDown > 0 {
+		done(errors.New("apps down"))
+	} else {
+		done(nil)
+	}
+
+	p.mu.Lock()
+	p.overview = overview
+	p.mu.Unlock()
+	return overview
+}
+
+// readCluster reads the three apps of a cluster and joins their spaces
+func (p *Panel) readCluster(ctx context.Context, c Cluster) (ClusterStatus, []SpaceStatus) {
+	var (
+		wg       sync.WaitGroup
+		report   driftReport
+		analysis costAnalysis
+		snapshot monitoringSnapshot
+		actions  = make([][]audit.Entry, 3)
+	)
+	status := ClusterStatus{Name: c.Name, Apps: []AppStatus{
+		{App: driftDetector, URL: c.DriftDetector},
+		{App: costOptimizer, URL: c.CostOptimizer},
+		{App: costImpactMonitor, URL: c.CostImpactMonitor},
+	}}
+	targets := []struct {
+		path string
+		v    interface{}
+	}{{"/api/v1/drift", &report}, {"/api/v1/analysis", &analysis}, {"/api/v1/snapshot", &snapshot}}
+
+	for i := range status.Apps {
+		app := &status.Apps[i]
+		if app.URL == "" {
+			app.Status = statusDisabled
+			continue
+		}
+		wg.Add(1)
+		go func(i int, app *AppStatus) {
+			defer wg.Done()
+			client := newAppClient(app.URL, c.APIToken, p.timeout)
+			start := time.Now()
+			err := client.get(ctx, targets[i].path, targets[i].v)
+			p.metrics.Call(app.App, targets[i].path, time.Since(start), err)
+			switch {
+			case errors.Is(err, errNotReady):
+				app.Status = statusWaiting
+			case err != nil:
+				app.Status, app.Error = statusError, err.Error()
+				slog.Warn("App unreachable", logging.Cluster(c.Name), "app", app.App, logging.Err(err))
+				return
+			default:
+				app.Status = statusOK
+			}
+			if p.actions > 0 {
+				entries, err := client.recentActions(ctx, p.actions)
+				if err != nil {
+					slog.Debug("No audit entries", logging.Cluster(c.Name), "app", app.App, logging.Err(err))
+				}
+				actions[i] = entries
+			}
+		}(i, app)
+	}
+	wg.Wait()
+
+	if status.Apps[1].Status == statusOK && analysis.Status == "waiting" {
+		status.Apps[1].Status = statusWaiting
+	}
+	status.Actions = mergeActions(actions, p.actions)
+
+	j := newJoin(c.Name)
+	if status.Apps[0].Status == statusOK {
+		j.addDrift(report)
+	}
+	if status.Apps[2].Status == statusOK {
+		j.addSpend(snapshot)
+	}
+	if status.Apps[1].Status == statusOK {
+		j.addSavings(analysis)
+	}
+	return status, j.spaces
+}
+
+// join collects one cluster's spaces by slug
+type join struct {
+	cluster string
+	spaces  []SpaceStatus
+	bySlug  map[string]int
+	names   map[string]string // space ID to slug, from the cost-impact-monitor
+}
+
+func newJoin(cluster string) *join {
+	return &join{cluster: cluster, bySlug: map[string]int{}, names: map[string]string{}}
+}
+
+func (j *join) space(slug string) *SpaceStatus {
+	i, ok := j.bySlug[slug]
+	if !ok {
+		i = len(j.spaces)
+		j.bySlug[slug] = i
+		j.spaces = append(j.spaces, SpaceStatus{Cluster: j.cluster, Space: slug})
+	}
+	return &j.spaces[i]
+}
+
+func (j *join) addDrift(report driftReport) {
+	drift := &DriftStatus{CheckedAt: report.CheckedAt, Namespace: report.Namespace, Degraded: report.Degraded, Items: []DriftItem{}}
+	if report.Analysis != nil {
+		drift.Summary = report.Analysis.Summary
+		drift.Items = append(drift.Items, report.Analysis.Items...)
+	}
+	j.space(report.Space).Drift = drift
+}
+
+func (j *join) addSpend(snapshot monitoringSnapshot) {
+	for _, s := range snapshot.Spaces {
+		j.names[s.SpaceID] = s.SpaceName
+		space := j.space(s.SpaceName)
+		space.Team = s.Team
+		space.Spend = &SpendStatus{AnalyzedAt: s.LastAnalysis, MonthlyCost: s.CurrentCost, ProjectedCost: s.ProjectedCost}
+		space.Pending = s.PendingChanges
+	}
+}
+
+// addSavings files the optimizer's analysis under its space, which it only
+// knows by ID
+func (j *join) addSavings(analysis costAnalysis) {
+	slug, ok := j.names[analysis.ConfigHubSpace]
+	if !ok {
+		slug = analysis.ConfigHubSpace
+	}
+	savings := &SavingsStatus{
+		AnalyzedAt:       analysis.Timestamp,
+		MonthlyCost:      analysis.TotalMonthlyCost,
+		PotentialSavings: analysis.PotentialSavings,
+	}
+	for _, rec := range analysis.Recommendations {
+		if !rec.Applied {
+			savings.Recommendations++
+		}
+	}
+	j.space(slug).Savings = savings
+}
+
+// mergeActions interleaves the apps' audit entries, newest first. Apps
+// sharing an audit space list the same entries, so they are deduplicated.
+func mergeActions(lists [][]audit.Entry, limit int) []audit.Entry {
+	seen := map[string]bool{}
+	merged := []audit.Entry{}
+	for _, list := range lists {
+		for _, e := range list {
+			if !seen[e.ID] {
+				seen[e.ID] = true
+				merged = append(merged, e)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(a, b int) bool { return merged[a].Time.After(merged[b].Time) })
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+func totals(o *Overview) Totals {
+	var t Totals
+	for _, c := range o.Clusters {
+		for _, app := range c.Apps {
+			if app.Status == statusError {
+				t.AppsDown++
+			}
+		}
+	}
+	for _, s := range o.Spaces {
+		if s.Spend != nil {
+			t.MonthlyCost += s.Spend.MonthlyCost
+			t.ProjectedCost += s.Spend.ProjectedCost
+		}
+		if s.Savings != nil {
+			t.PotentialSavings += s.Savings.PotentialSavings
+		}
+		if s.Drift != nil && len(s.Drift.Items) > 0 {
+			t.DriftedSpaces++
+		}
+		t.PendingChanges += len(s.Pending)
+		for _, change := range s.Pending {
+			if change.RiskLevel == "high" || change.RiskLevel == "critical" {
+				t.HighRiskChanges++
+			}
+		}
+	}
+	return t
+}
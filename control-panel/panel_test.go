@@ -0,0 +1,125 @@
+package controlpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeApp serves canned JSON by path, checking the bearer token
+func fakeApp(t *testing.T, token string, responses map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if body == "" {
+			http.Error(w, "not yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRefresh(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	stamp := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+
+	drift := fakeApp(t, "tok", map[string]string{
+		"/api/v1/drift": `{"checked_at":"` + stamp(time.Minute) + `","space":"payments-prod","namespace":"payments",
+			"analysis":{"has_drift":true,"summary":"1 drifted","items":[{"unit_slug":"api","resource":"Deployment/api","field":"spec.replicas","expected":"2","actual":"5"}]}}`,
+		"/api/v1/audit": `{"entries":[{"id":"a1","time":"` + stamp(3*time.Minute) + `","app":"drift-detector","actor":"system","action":"fix.applied","target":"api","result":"ok"},
+			{"id":"shared","time":"` + stamp(time.Minute) + `","app":"cost-impact-monitor","actor":"alice","action":"approval.granted","result":"ok"}]}`,
+	})
+	optimizer := fakeApp(t, "tok", map[string]string{
+		"/api/v1/analysis": `{"timestamp":"` + stamp(time.Hour) + `","total_monthly_cost":400,"potential_savings":120,"confighub_space":"5f1c0000-0000-0000-0000-000000000001",
+			"recommendations":[{"applied":false},{"applied":true}]}`,
+		"/api/v1/audit": `{"entries":[]}`,
+	})
+	monitor := fakeApp(t, "tok", map[string]string{
+		"/api/v1/snapshot": `{"spaces":[{"space_id":"5f1c0000-0000-0000-0000-000000000001","space_name":"payments-prod","team":"payments",
+			"current_cost":380,"projected_cost":440,"pending_changes":[{"unit_name":"api","change_type":"drift","cost_delta":60,"risk_level":"high"}]},
+			{"space_id":"other","space_name":"payments-dev","current_cost":20,"projected_cost":20}]}`,
+		"/api/v1/audit": `{"entries":[{"id":"shared","time":"` + stamp(time.Minute) + `","app":"cost-impact-monitor","actor":"alice","action":"approval.granted","result":"ok"}]}`,
+	})
+	waiting := fakeApp(t, "", map[string]string{"/api/v1/drift": ""})
+
+	panel := NewPanel([]Cluster{
+		{Name: "prod", DriftDetector: drift.URL, CostOptimizer: optimizer.URL, CostImpactMonitor: monitor.URL, APIToken: "tok"},
+		{Name: "edge", DriftDetector: waiting.URL, CostOptimizer: "http://127.0.0.1:1"},
+	}, time.Second, 10, nil)
+	overview := panel.Refresh(context.Background())
+
+	prod, edge := overview.Clusters[0], overview.Clusters[1]
+	for _, app := range prod.Apps {
+		if app.Status != statusOK {
+			t.Errorf("prod %s: %s %s", app.App, app.Status, app.Error)
+		}
+	}
+	if edge.Apps[0].Status != statusWaiting || edge.Apps[1].Status != statusError || edge.Apps[2].Status != statusDisabled {
+		t.Errorf("Unexpected edge apps %+v", edge.Apps)
+	}
+	if len(prod.Actions) != 2 || prod.Actions[0].ID != "shared" || prod.Actions[1].ID != "a1" {
+		t.Errorf("Expected the deduplicated actions newest first, got %+v", prod.Actions)
+	}
+
+	if len(overview.Spaces) != 2 {
+		t.Fatalf("Expected two spaces, got %+v", overview.Spaces)
+	}
+	space := overview.Spaces[0]
+	if space.Space != "payments-prod" || space.Team != "payments" || space.Drift == nil || len(space.Drift.Items) != 1 ||
+		space.Spend == nil || space.Spend.MonthlyCost != 380 || len(space.Pending) != 1 ||
+		space.Savings == nil || space.Savings.PotentialSavings != 120 || space.Savings.Recommendations != 1 {
+		t.Errorf("Expected the three apps joined on payments-prod, got %+v", space)
+	}
+	want := Totals{MonthlyCost: 400, ProjectedCost: 460, PotentialSavings: 120, DriftedSpaces: 1, PendingChanges: 1, HighRiskChanges: 1, AppsDown: 1}
+	if overview.Totals != want {
+		t.Errorf("totals = %+v, want %+v", overview.Totals, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	panel := NewPanel(nil, time.Second, 10, nil)
+	handler := panel.handler(30*time.Second, nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/overview", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the first refresh, got %d", rec.Code)
+	}
+
+	panel.overview = &Overview{
+		Clusters: []ClusterStatus{{Name: "prod"}, {Name: "edge"}},
+		Spaces: []SpaceStatus{
+			{Cluster: "prod", Space: "payments-prod", Spend: &SpendStatus{MonthlyCost: 100}},
+			{Cluster: "edge", Space: "payments-prod", Spend: &SpendStatus{MonthlyCost: 5}, Drift: &DriftStatus{Items: []DriftItem{{Field: "spec.replicas"}}}},
+		},
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/overview?cluster=edge", nil))
+	var overview Overview
+	if err := json.NewDecoder(rec.Body).Decode(&overview); err != nil {
+		t.Fatal(err)
+	}
+	if len(overview.Clusters) != 1 || len(overview.Spaces) != 1 || overview.Totals.MonthlyCost != 5 || overview.Totals.DriftedSpaces != 1 {
+		t.Errorf("Expected only the edge cluster, got %+v", overview)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "payments-prod") || !strings.Contains(rec.Body.String(), "1 drifted") {
+		t.Errorf("Unexpected dashboard (%d):\n%s", rec.Code, rec.Body)
+	}
+}
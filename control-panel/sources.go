@@ -0,0 +1,133 @@
+package controlpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+)
+
+// The types below mirror the parts of the apps' API responses the panel
+// shows. The panel only reads the APIs, so the apps stay free to add fields.
+
+// driftReport mirrors drift-detector's GET /api/v1/drift
+type driftReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Space     string    `json:"space"`
+	Namespace string    `json:"namespace"`
+	Analysis  *struct {
+		HasDrift bool        `json:"has_drift"`
+		Items    []DriftItem `json:"items"`
+		Summary  string      `json:"summary"`
+	} `json:"analysis"`
+	Degraded string `json:"degraded"`
+}
+
+// DriftItem is one drifted field
+type DriftItem struct {
+	UnitSlug string `json:"unit_slug"`
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// costAnalysis mirrors cost-optimizer's GET /api/v1/analysis
+type costAnalysis struct {
+	Status           string    `json:"status"` // "waiting" until the first analysis
+	Timestamp        time.Time `json:"timestamp"`
+	TotalMonthlyCost float64   `json:"total_monthly_cost"`
+	PotentialSavings float64   `json:"potential_savings"`
+	ConfigHubSpace   string    `json:"confighub_space"` // space ID
+	Recommendations  []struct {
+		Applied bool `json:"applied"`
+	} `json:"recommendations"`
+}
+
+// monitoringSnapshot mirrors cost-impact-monitor's GET /api/v1/snapshot
+type monitoringSnapshot struct {
+	Timestamp time.Time `json:"timestamp"`
+	Spaces    []struct {
+		SpaceID        string          `json:"space_id"`
+		SpaceName      string          `json:"space_name"`
+		Team           string          `json:"team"`
+		LastAnalysis   time.Time       `json:"last_analysis"`
+		CurrentCost    float64         `json:"current_cost"`
+		ProjectedCost  float64         `json:"projected_cost"`
+		PendingChanges []PendingChange `json:"pending_changes"`
+	} `json:"spaces"`
+}
+
+// PendingChange is a priced change waiting to deploy
+type PendingChange struct {
+	UnitName   string  `json:"unit_name"`
+	ChangeType string  `json:"change_type"`
+	CostDelta  float64 `json:"cost_delta"`
+	RiskLevel  string  `json:"risk_level"`
+}
+
+// auditPage mirrors GET /api/v1/audit, served by every app
+type auditPage struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// errNotReady is an app's answer before its first run
+var errNotReady = errors.New("no results yet")
+
+// appClient reads one app's API
+type appClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newAppClient(baseURL, token string, timeout time.Duration) *appClient {
+	return &appClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// get decodes the JSON at path into v; a 503 is errNotReady
+func (c *appClient) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return errNotReady
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("get %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// recentActions returns the app's latest audit entries
+func (c *appClient) recentActions(ctx context.Context, limit int) ([]audit.Entry, error) {
+	var page auditPage
+	if err := c.get(ctx, fmt.Sprintf("/api/v1/audit?limit=%d", limit), &page); err != nil {
+		return nil, err
+	}
+	return page.Entries, nil
+}
@@ -0,0 +1,144 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"github.com/monadic/devops-examples/pkg/tenants"
+)
+
+// AccuracyConfig controls how predictions are scored against actual cost
+type AccuracyConfig struct {
+	TolerancePercent float64 `json:"tolerance_percent"` // |variance| within this counts as accurate
+}
+
+// isAccurate reports whether a variance percentage is within tolerance
+func (c AccuracyConfig) isAccurate(variance float64) bool {
+	return math.Abs(variance) <= c.TolerancePercent
+}
+
+// AccuracyStats summarises prediction quality for a set of deployments
+type AccuracyStats struct {
+	Records      int     `json:"records"`
+	Accurate     int     `json:"accurate"`
+	AccuracyRate float64 `json:"accuracy_rate"` // % of records within tolerance
+	MAPE         float64 `json:"mape"`          // mean absolute percentage error
+	WeightedAPE  float64 `json:"weighted_ape"`  // absolute error weighted by predicted cost
+	MeanBias     float64 `json:"mean_bias"`     // mean signed variance; positive means we under-predict
+}
+
+// AccuracyReport breaks prediction accuracy down by change type and space
+type AccuracyReport struct {
+	TolerancePercent float64                  `json:"tolerance_percent"`
+	Overall          AccuracyStats            `json:"overall"`
+	ByChangeType     map[string]AccuracyStats `json:"by_change_type"`
+	BySpace          map[string]AccuracyStats `json:"by_space"`
+}
+
+// accuracyAccumulator gathers the sums needed for AccuracyStats
+type accuracyAccumulator struct {
+	records, accurate           int
+	absPct, signedPct           float64
+	absError, predictedWeighted float64
+}
+
+func (a *accuracyAccumulator) add(record DeploymentCostRecord, config AccuracyConfig) {
+	a.records++
+	if config.isAccurate(record.Variance) {
+		a.accurate++
+	}
+	a.absPct += math.Abs(record.Variance)
+	a.signedPct += record.Variance
+	a.absError += math.Abs(record.ActualCost - record.PredictedCost)
+	a.predictedWeighted += record.PredictedCost
+}
+
+func (a *accuracyAccumulator) stats() AccuracyStats {
+	if a.records == 0 {
+		return AccuracyStats{}
+	}
+	stats := AccuracyStats{
+		Records:      a.records,
+		Accurate:     a.accurate,
+		AccuracyRate: float64(a.accurate) / float64(a.records) * 100,
+		MAPE:         a.absPct / float64(a.records),
+		MeanBias:     a.signedPct / float64(a.records),
+	}
+	if a.predictedWeighted > 0 {
+		stats.WeightedAPE = a.absError / a.predictedWeighted * 100
+	}
+	return stats
+}
+
+// computeAccuracy scores deployment records with the current configuration.
+// Records without a prediction can't be scored and are skipped.
+func computeAccuracy(records []DeploymentCostRecord, config AccuracyConfig) AccuracyReport {
+	var overall accuracyAccumulator
+	byType := make(map[string]*accuracyAccumulator)
+	bySpace := make(map[string]*accuracyAccumulator)
+
+	for _, record := range records {
+		if record.PredictedCost <= 0 {
+			continue
+		}
+		overall.add(record, config)
+
+		changeType := record.ChangeType
+		if changeType == "" {
+			changeType = "unknown"
+		}
+		if byType[changeType] == nil {
+			byType[changeType] = &accuracyAccumulator{}
+		}
+		byType[changeType].add(record, config)
+
+		if bySpace[record.SpaceName] == nil {
+			bySpace[record.SpaceName] = &accuracyAccumulator{}
+		}
+		bySpace[record.SpaceName].add(record, config)
+	}
+
+	report := AccuracyReport{
+		TolerancePercent: config.TolerancePercent,
+		Overall:          overall.stats(),
+		ByChangeType:     make(map[string]AccuracyStats, len(byType)),
+		BySpace:          make(map[string]AccuracyStats, len(bySpace)),
+	}
+	for k, acc := range byType {
+		report.ByChangeType[k] = acc.stats()
+	}
+	for k, acc := range bySpace {
+		report.BySpace[k] = acc.stats()
+	}
+	return report
+}
+
+// allDeploymentHistory collects deployment records across spaces
+func (m *CostImpactMonitor) allDeploymentHistory() []DeploymentCostRecord {
+	return m.historyFor(nil)
+}
+
+// historyFor collects the deployment records of the spaces team may see
+func (m *CostImpactMonitor) historyFor(team *tenants.Team) []DeploymentCostRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var all []DeploymentCostRecord
+	for _, space := range m.monitoredSpaces {
+		if team.OwnsSpace(space.SpaceName) {
+			all = append(all, space.DeploymentHistory...)
+		}
+	}
+	return all
+}
+
+// handleAccuracy returns the accuracy report
+func (d *MonitorDashboard) handleAccuracy(w http.ResponseWriter, r *http.Request) {
+	report := computeAccuracy(d.monitor.historyFor(tenants.FromContext(r.Context())), d.monitor.accuracy)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,105 @@
+package costimpactmonitor
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/metrics"
+)
+
+func TestComputeAccuracy(t *testing.T) {
+	records := []DeploymentCostRecord{
+		{SpaceName: "prod", ChangeType: "create", PredictedCost: 100, ActualCost: 105, Variance: 5},
+		{SpaceName: "prod", ChangeType: "update", PredictedCost: 100, ActualCost: 130, Variance: 30},
+		{SpaceName: "dev", ChangeType: "update", PredictedCost: 300, ActualCost: 270, Variance: -10},
+		{SpaceName: "dev", ChangeType: "update", PredictedCost: 0, ActualCost: 50}, // unscored
+	}
+
+	report := computeAccuracy(records, AccuracyConfig{TolerancePercent: 10})
+
+	overall := report.Overall
+	if overall.Records != 3 || overall.Accurate != 2 {
+		t.Fatalf("records/accurate = %d/%d, want 3/2", overall.Records, overall.Accurate)
+	}
+	if math.Abs(overall.MAPE-15) > 0.001 {
+		t.Errorf("MAPE = %.3f, want 15", overall.MAPE)
+	}
+	// (5 + 30 + 30) / (100 + 100 + 300)
+	if math.Abs(overall.WeightedAPE-13) > 0.001 {
+		t.Errorf("weighted APE = %.3f, want 13", overall.WeightedAPE)
+	}
+	if math.Abs(overall.MeanBias-25.0/3) > 0.001 {
+		t.Errorf("mean bias = %.3f, want 8.333", overall.MeanBias)
+	}
+
+	if got := report.ByChangeType["update"]; got.Records != 2 || got.AccuracyRate != 50 {
+		t.Errorf("update stats = %+v, want 2 records at 50%%", got)
+	}
+	if got := report.BySpace["prod"]; got.Records != 2 || got.Accurate != 1 {
+		t.Errorf("prod stats = %+v, want 1 of 2 accurate", got)
+	}
+}
+
+func TestAccuracyTolerance(t *testing.T) {
+	tests := []struct {
+		tolerance float64
+		variance  float64
+		want      bool
+	}{
+		{10, 9.9, true},
+		{10, -10, true},
+		{10, 10.1, false},
+		{25, -20, true},
+		{5, 6, false},
+	}
+
+	for _, tt := range tests {
+		config := AccuracyConfig{TolerancePercent: tt.tolerance}
+		if got := config.isAccurate(tt.variance); got != tt.want {
+			t.Errorf("isAccurate(%.1f) with ±%.0f%% = %v, want %v", tt.variance, tt.tolerance, got, tt.want)
+		}
+	}
+}
+
+// TestCollect checks the space metrics, and that the Grafana dashboard only
+// queries impact metrics the monitor exports
+func TestCollect(t *testing.T) {
+	space := &SpaceMonitor{
+		SpaceName:      "prod",
+		CurrentCost:    100,
+		ProjectedCost:  150,
+		PendingChanges: []PendingChange{{RiskLevel: "high"}, {RiskLevel: "low"}},
+		DeploymentHistory: []DeploymentCostRecord{
+			{SpaceName: "prod", PredictedCost: 100, ActualCost: 105, Variance: 5},
+			{SpaceName: "prod", PredictedCost: 100, ActualCost: 130, Variance: 30},
+		},
+	}
+	m := &CostImpactMonitor{monitoredSpaces: map[uuid.UUID]*SpaceMonitor{uuid.New(): space}, accuracy: AccuracyConfig{TolerancePercent: 10}}
+	emitted := map[string]float64{}
+	m.collect(func(name, help, kind string, labels metrics.Labels, value float64) {
+		if labels["space"] != "prod" {
+			t.Errorf("%s labels = %v", name, labels)
+		}
+		emitted[name] = value
+	})
+
+	for name, want := range map[string]float64{
+		"impact_projected_monthly_cost_dollars": 150,
+		"impact_pending_changes":                2,
+		"impact_high_risk_changes":              1,
+		"impact_prediction_records":             2,
+		"impact_prediction_accuracy_ratio":      0.5,
+		"impact_prediction_error_ratio":         0.175,
+	} {
+		if got := emitted[name]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	for _, name := range metrics.GrafanaMetrics() {
+		if _, ok := emitted[name]; strings.HasPrefix(name, "impact_") && !ok {
+			t.Errorf("The Grafana dashboard queries %s, which the monitor doesn't export", name)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// SpaceAnalysisStats records how analysis of a space has been going
+type SpaceAnalysisStats struct {
+	Runs         int           `json:"runs"`
+	Failures     int           `json:"failures"`
+	Timeouts     int           `json:"timeouts"`
+	LastDuration time.Duration `json:"last_duration_ns"`
+	LastError    string        `json:"last_error,omitempty"`
+	LastRun      time.Time     `json:"last_run"`
+}
+
+// analyzeSpaceWithDeadline analyzes one space under the per-space deadline
+// and records its duration and outcome. It returns only once the ConfigHub
+// calls the analysis started have, so that a call stalled past the deadline
+// keeps its worker busy instead of piling up with the next pass's.
+func (m *CostImpactMonitor) analyzeSpaceWithDeadline(ctx context.Context, space *SpaceMonitor) {
+	ctx, span := tracing.Start(ctx, "impact.analyzeSpace", tracing.SpaceKey.String(space.SpaceName))
+	ctx, cancel := context.WithTimeout(ctx, m.spaceTimeout)
+	defer cancel()
+
+	start := time.Now()
+	cycleDone := m.metrics.Cycle("analyze", space.SpaceName)
+	var calls sync.WaitGroup
+	defer calls.Wait()
+	err := m.analyzeSpace(ctx, space, &calls)
+	duration := time.Since(start)
+	tracing.End(span, err)
+	cycleDone(err)
+
+	m.mu.Lock()
+	stats := &space.AnalysisStats
+	stats.Runs++
+	stats.LastRun = start
+	stats.LastDuration = duration
+	stats.LastError = ""
+	if err != nil {
+		stats.Failures++
+		stats.LastError = err.Error()
+		if ctx.Err() == context.DeadlineExceeded {
+			stats.Timeouts++
+		}
+	}
+	m.mu.Unlock()
+
+	switch {
+	case errors.Is(err, breaker.ErrOpen):
+		slog.Info("ConfigHub unavailable, keeping the last analysis", logging.Space(space.SpaceName))
+	case err != nil:
+		slog.Warn("Failed to analyze space", logging.Space(space.SpaceName), "duration", duration.Round(time.Millisecond), logging.Err(err))
+	}
+}
+
+// listUnits lists a space's units but gives up when ctx expires.
+// The SDK call itself can't be cancelled, so a stalled call finishes in the
+// background and its result is discarded; it is added to calls, if not nil,
+// for the caller to wait for.
+func (m *CostImpactMonitor) listUnits(ctx context.Context, spaceID uuid.UUID, calls *sync.WaitGroup) ([]*sdk.Unit, error) {
+	type result struct {
+		units []*sdk.Unit
+		err   error
+	}
+	done := make(chan result, 1)
+
+	_, span := tracing.Start(ctx, "confighub.ListUnits")
+	if calls != nil {
+		calls.Add(1)
+	}
+	go func() {
+		if calls != nil {
+			defer calls.Done()
+		}
+		units, err := ratelimit.Call(ctx, m.cubLimit, "ListUnits", spaceID.String(), func() ([]*sdk.Unit, error) {
+			return m.cubFor(spaceID).ListUnits(spaceID)
+		})
+		done <- result{units, err}
+	}()
+
+	select {
+	case res := <-done:
+		tracing.End(span, res.err)
+		return res.units, res.err
+	case <-ctx.Done():
+		tracing.End(span, ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+// handleAnalysisStats returns per-space analysis durations and errors, slowest first
+func (d *MonitorDashboard) handleAnalysisStats(w http.ResponseWriter, r *http.Request) {
+	d.monitor.mu.RLock()
+	all := make([]SpaceStats, 0, len(d.monitor.monitoredSpaces))
+	team := tenants.FromContext(r.Context())
+	for _, space := range d.monitor.monitoredSpaces {
+		if team.OwnsSpace(space.SpaceName) {
+			all = append(all, SpaceStats{space.SpaceID, space.SpaceName, space.AnalysisStats})
+		}
+	}
+	d.monitor.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Stats.LastDuration > all[j].Stats.LastDuration
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(AnalysisList{
+		Spaces:      all,
+		Concurrency: d.monitor.analysisWorkers,
+		Timeout:     d.monitor.spaceTimeout.String(),
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,185 @@
+package costimpactmonitor
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/prompts"
+)
+
+// SpaceList is the body of GET /api/v1/spaces
+type SpaceList struct {
+	Spaces     []*SpaceMonitor `json:"spaces"`
+	Total      int             `json:"total"`
+	Available  int             `json:"available"` // before filtering
+	LastUpdate time.Time       `json:"last_update"`
+}
+
+// PendingList is the body of GET /api/v1/pending
+type PendingList struct {
+	PendingChanges []map[string]interface{} `json:"pending_changes"`
+	Total          int                      `json:"total"`
+	Available      int                      `json:"available"` // before filtering
+	LastUpdate     time.Time                `json:"last_update"`
+}
+
+// RecentTrigger is a unit whose trigger was processed in the last hour
+type RecentTrigger struct {
+	UnitID        string    `json:"unit_id"`
+	LastProcessed time.Time `json:"last_processed"`
+	Age           string    `json:"age"`
+}
+
+// TriggerList is the body of GET /api/v1/triggers
+type TriggerList struct {
+	RecentTriggers []RecentTrigger `json:"recent_triggers"`
+	Total          int             `json:"total"`
+	Leader         bool            `json:"leader"`
+	Replica        string          `json:"replica"`
+	LastUpdate     time.Time       `json:"last_update"`
+}
+
+// HistoryList is the body of GET /api/v1/history, newest deployment first
+type HistoryList struct {
+	History      []DeploymentCostRecord `json:"history"`
+	Total        int                    `json:"total"`
+	AccuracyRate float64                `json:"accuracy_rate"`
+	LastUpdate   time.Time              `json:"last_update"`
+}
+
+// TargetList is the body of GET /api/v1/targets
+type TargetList struct {
+	Targets []TargetCost `json:"targets"`
+	Total   int          `json:"total"`
+}
+
+// EscalationList is the body of GET /api/v1/escalations
+type EscalationList struct {
+	Escalations []Escalation `json:"escalations"`
+}
+
+// SpaceStats are the analysis timings of one space
+type SpaceStats struct {
+	SpaceID   uuid.UUID          `json:"space_id"`
+	SpaceName string             `json:"space_name"`
+	Stats     SpaceAnalysisStats `json:"stats"`
+}
+
+// AnalysisList is the body of GET /api/v1/analysis, slowest space first
+type AnalysisList struct {
+	Spaces      []SpaceStats `json:"spaces"`
+	Concurrency int          `json:"concurrency"`
+	Timeout     string       `json:"timeout"`
+}
+
+// PlanList is the body of GET /api/v1/terraform/plans, newest first
+type PlanList struct {
+	Plans []*TerraformPlanImpact `json:"plans"`
+	Total int                    `json:"total"`
+}
+
+// SpaceUnits is the body of GET /api/v1/spaces/{id}/units
+type SpaceUnits struct {
+	SpaceID       uuid.UUID  `json:"space_id"`
+	SpaceName     string     `json:"space_name"`
+	CurrentCost   float64    `json:"current_cost"`
+	ProjectedCost float64    `json:"projected_cost"`
+	LastAnalysis  time.Time  `json:"last_analysis"`
+	Units         []UnitCost `json:"units"`
+	Total         int        `json:"total"`
+}
+
+// SpaceSpendLimit is the body of GET /api/v1/spaces/{id}/limit
+type SpaceSpendLimit struct {
+	SpaceID    uuid.UUID   `json:"space_id"`
+	SpaceName  string      `json:"space_name"`
+	SpendLimit *SpendLimit `json:"spend_limit"`
+}
+
+// SpaceBaseline is the body of the /api/v1/spaces/{id}/baseline routes
+type SpaceBaseline struct {
+	SpaceID   uuid.UUID     `json:"space_id"`
+	SpaceName string        `json:"space_name"`
+	Baseline  *CostBaseline `json:"baseline"`
+}
+
+// BaselineRequest pins a baseline; without a cost, the current one
+type BaselineRequest struct {
+	Cost *float64 `json:"cost"`
+	Note string   `json:"note"`
+}
+
+// listParams document the listQuery parameters
+var listParams = []api.Param{
+	{Name: "risk", Description: "comma separated risk levels"},
+	{Name: "min_delta", Description: "minimum cost delta, $/month"},
+	{Name: "space", Description: "space name contains this"},
+	{Name: "sort", Description: "sort key"},
+	{Name: "order", Description: "asc or desc"},
+	{Name: "limit", Description: "at most this many entries"},
+}
+
+// routes mounts the dashboard's API on mux
+func (d *MonitorDashboard) routes(mux *http.ServeMux) {
+	v1 := api.New(mux, "cost-impact-monitor")
+	v1.HandleFunc("/snapshot", d.handleSnapshot,
+		api.Operation{Summary: "Everything the dashboard shows", Response: MonitoringSnapshot{}})
+	v1.HandleFunc("/spaces", d.handleSpaces,
+		api.Operation{Summary: "Monitored spaces", Query: listParams, Response: SpaceList{}})
+	v1.HandleFunc("/spaces/", d.handleSpaceRoutes,
+		api.Operation{Path: "/spaces/{id}", Summary: "A space's costs, units and trend", Response: SpaceDetail{}},
+		api.Operation{Path: "/spaces/{id}/report", Summary: "A space's cost report", ContentType: "text/markdown"},
+		api.Operation{Path: "/spaces/{id}/report/{unit}", Summary: "A unit's cost report", ContentType: "text/markdown"},
+		api.Operation{Path: "/spaces/{id}/units", Summary: "Cost attributed to each unit of a space", Response: SpaceUnits{}},
+		api.Operation{Path: "/spaces/{id}/limit", Summary: "A space's spend limit", Response: SpaceSpendLimit{}},
+		api.Operation{Path: "/spaces/{id}/baseline", Summary: "A space's pinned cost baseline", Response: SpaceBaseline{}},
+		api.Operation{Method: http.MethodPost, Path: "/spaces/{id}/baseline", Summary: "Pin a cost baseline", Request: BaselineRequest{}, Response: SpaceBaseline{}},
+		api.Operation{Method: http.MethodDelete, Path: "/spaces/{id}/baseline", Summary: "Remove a space's baseline"},
+		api.Operation{Method: http.MethodPost, Path: "/spaces/{id}/baseline/reset", Summary: "Pin the current cost as baseline", Response: SpaceBaseline{}},
+	)
+	v1.HandleFunc("/pending", d.handlePendingChanges,
+		api.Operation{Summary: "Pending changes and their cost impact", Query: listParams, Response: PendingList{}})
+	v1.HandleFunc("/pending/export", d.handlePendingExport,
+		api.Operation{Summary: "Pending changes as CSV", Query: listParams, ContentType: "text/csv"})
+	v1.HandleFunc("/triggers", d.handleTriggers,
+		api.Operation{Summary: "Triggers processed in the last hour", Response: TriggerList{}})
+	v1.HandleFunc("/history", d.handleHistory,
+		api.Operation{Summary: "Deployments with predicted and actual cost", Response: HistoryList{}})
+	v1.HandleFunc("/history/export", d.handleHistoryExport,
+		api.Operation{Summary: "Deployment history as CSV", ContentType: "text/csv"})
+	v1.HandleFunc("/accuracy", d.handleAccuracy,
+		api.Operation{Summary: "How well predictions matched actual cost", Response: AccuracyReport{}})
+	v1.HandleFunc("/targets", d.handleTargets,
+		api.Operation{Summary: "Cost by deployment target", Response: TargetList{}})
+	v1.HandleFunc("/whatif", d.handleWhatIf,
+		api.Operation{Method: http.MethodPost, Summary: "Cost of a hypothetical change", Request: WhatIfRequest{}, Response: WhatIfResult{}})
+	v1.HandleFunc("/escalations/", d.handleEscalations,
+		api.Operation{Summary: "Cost escalations", Response: EscalationList{}},
+		api.Operation{Method: http.MethodPost, Path: "/escalations/{id}/approve", Summary: "Approve an escalation", Request: pending.Approval{}, Response: Escalation{}},
+		api.Operation{Method: http.MethodPost, Path: "/escalations/{id}/acknowledge", Summary: "Acknowledge an escalation", Response: Escalation{}},
+	)
+	v1.HandleFunc("/events", d.handleEvents,
+		api.Operation{Summary: "Snapshot updates as server-sent events", ContentType: "text/event-stream"})
+	v1.HandleFunc("/analysis", d.handleAnalysisStats,
+		api.Operation{Summary: "How long each space's analysis takes", Response: AnalysisList{}})
+	v1.HandleFunc("/terraform/plans", d.handleTerraformPlans,
+		api.Operation{Summary: "Analyzed Terraform plans", Response: PlanList{}},
+		api.Operation{Method: http.MethodPost, Summary: "Analyze a Terraform plan (terraform show -json)", Query: []api.Param{
+			{Name: "name", Description: "the plan's name"},
+			{Name: "space", Description: "the space it deploys to"},
+		}, Response: TerraformPlanImpact{}},
+	)
+	v1.Handle("/flags", d.monitor.flags, flags.Operations...)
+	v1.Handle("/prompts", d.monitor.prompts, prompts.Operations...)
+	v1.Handle("/audit", d.monitor.audit, audit.Operations...)
+	v1.Handle("/queue/", d.monitor.pending, pending.Operations...)
+	v1.Handle("/breakers", breaker.Handler(d.monitor.cubBreaker, d.monitor.claudeBreaker), breaker.Operations...)
+	v1.Handle("/llm/usage", d.monitor.llmClient, llm.Operations...)
+}
@@ -0,0 +1,69 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/monadic/devops-examples/pkg/pricinghints"
+)
+
+// UnitCost is one unit's share of its space's cost
+type UnitCost struct {
+	UnitID              string  `json:"unit_id"`
+	UnitName            string  `json:"unit_name"`
+	CurrentCost         float64 `json:"current_cost"`
+	ProjectedCost       float64 `json:"projected_cost"`
+	CostDelta           float64 `json:"cost_delta"`
+	ContributionPercent float64 `json:"contribution_percent"` // share of the space's projected cost
+	ChangeType          string  `json:"change_type,omitempty"`
+	RiskLevel           string  `json:"risk_level,omitempty"`
+
+	HintErrors []pricinghints.FieldError `json:"hint_errors,omitempty"` // invalid pricing-hint labels
+}
+
+// attributeUnitCosts fills in each unit's contribution and sorts the most expensive first
+func attributeUnitCosts(units []UnitCost) []UnitCost {
+	total := 0.0
+	for _, u := range units {
+		total += u.ProjectedCost
+	}
+
+	for i := range units {
+		if total > 0 {
+			units[i].ContributionPercent = units[i].ProjectedCost / total * 100
+		}
+	}
+
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ProjectedCost > units[j].ProjectedCost
+	})
+
+	return units
+}
+
+// handleSpaceUnits returns the per-unit cost breakdown for a space
+func (d *MonitorDashboard) handleSpaceUnits(w http.ResponseWriter, r *http.Request, space *SpaceMonitor) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.monitor.mu.RLock()
+	units := append([]UnitCost(nil), space.UnitCosts...)
+	response := SpaceUnits{
+		SpaceID:       space.SpaceID,
+		SpaceName:     space.SpaceName,
+		CurrentCost:   space.CurrentCost,
+		ProjectedCost: space.ProjectedCost,
+		LastAnalysis:  space.LastAnalysis,
+		Units:         units,
+		Total:         len(units),
+	}
+	d.monitor.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,59 @@
+package costimpactmonitor
+
+import (
+	"math"
+	"testing"
+
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestAttributeUnitCosts(t *testing.T) {
+	units := attributeUnitCosts([]UnitCost{
+		{UnitName: "frontend", CurrentCost: 25, ProjectedCost: 25},
+		{UnitName: "backend", CurrentCost: 45, ProjectedCost: 65, CostDelta: 20},
+		{UnitName: "cache", CurrentCost: 0, ProjectedCost: 10, CostDelta: 10},
+	})
+
+	wantOrder := []string{"backend", "frontend", "cache"}
+	wantShare := []float64{65, 25, 10}
+	for i, u := range units {
+		if u.UnitName != wantOrder[i] {
+			t.Errorf("units[%d] = %s, want %s", i, u.UnitName, wantOrder[i])
+		}
+		if math.Abs(u.ContributionPercent-wantShare[i]) > 0.01 {
+			t.Errorf("%s contribution = %.2f%%, want %.2f%%", u.UnitName, u.ContributionPercent, wantShare[i])
+		}
+	}
+}
+
+func TestAttributeUnitCostsZeroTotal(t *testing.T) {
+	units := attributeUnitCosts([]UnitCost{{UnitName: "empty"}})
+	if units[0].ContributionPercent != 0 {
+		t.Errorf("contribution = %v, want 0", units[0].ContributionPercent)
+	}
+}
+
+func TestCalculateUnitCostFromHints(t *testing.T) {
+	m := &CostImpactMonitor{}
+
+	tests := []struct {
+		labels     map[string]string
+		want       float64
+		hintErrors int
+	}{
+		{nil, 10, 0},
+		{map[string]string{"replicas": "3"}, 30, 0},
+		{map[string]string{"cpu": "500m", "memory": "1Gi", "replicas": "2"}, 2 * (8.64 + 4.32), 0},
+		{map[string]string{"cpu": "lots", "memory": "2Gi"}, 8.64, 1},
+	}
+
+	for _, tt := range tests {
+		unit := &sdk.Unit{Slug: "api", Labels: tt.labels}
+		if got := m.calculateUnitCost(unit); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("%v: cost = %.2f, want %.2f", tt.labels, got, tt.want)
+		}
+		if _, errs := unitHints(unit); len(errs) != tt.hintErrors {
+			t.Errorf("%v: %d hint errors, want %d", tt.labels, len(errs), tt.hintErrors)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// CostBaseline is a cost level pinned for a space (e.g. after a budget review).
+// Unlike CostTrend, which compares recent deployments, it measures how far the
+// space has moved since the pin and how much that has cost in total.
+type CostBaseline struct {
+	Cost                float64   `json:"cost"`
+	PinnedAt            time.Time `json:"pinned_at"`
+	Note                string    `json:"note,omitempty"`
+	Deviation           float64   `json:"deviation"`            // current monthly cost minus baseline
+	DeviationPercent    float64   `json:"deviation_percent"`    // deviation relative to baseline
+	CumulativeDeviation float64   `json:"cumulative_deviation"` // dollars spent above (or below) baseline since the pin
+	LastUpdated         time.Time `json:"last_updated"`
+}
+
+// newCostBaseline pins cost as the baseline at now
+func newCostBaseline(cost float64, note string, now time.Time) *CostBaseline {
+	return &CostBaseline{
+		Cost:        cost,
+		PinnedAt:    now,
+		Note:        note,
+		LastUpdated: now,
+	}
+}
+
+// update accrues the deviation since the last update and recomputes it for currentCost.
+// The previous deviation is charged for the elapsed time, pro-rated from a 30-day month.
+func (b *CostBaseline) update(currentCost float64, now time.Time) {
+	if elapsed := now.Sub(b.LastUpdated); elapsed > 0 {
+		b.CumulativeDeviation += b.Deviation * elapsed.Hours() / (24 * 30)
+	}
+
+	b.Deviation = currentCost - b.Cost
+	b.DeviationPercent = 0
+	if b.Cost > 0 {
+		b.DeviationPercent = b.Deviation / b.Cost * 100
+	}
+	b.LastUpdated = now
+}
+
+// findSpace resolves a space slug or ID to a monitored space
+func (m *CostImpactMonitor) findSpace(ref string) (*SpaceMonitor, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if id, err := uuid.Parse(ref); err == nil {
+		space, exists := m.monitoredSpaces[id]
+		return space, exists
+	}
+
+	for _, space := range m.monitoredSpaces {
+		if space.SpaceName == ref {
+			return space, true
+		}
+	}
+	return nil, false
+}
+
+// handleSpaceRoutes dispatches /api/v1/spaces/{id}/... requests
+func (d *MonitorDashboard) handleSpaceRoutes(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, api.Prefix+"/spaces/"), "/"), "/")
+
+	space, ok := d.findVisibleSpace(r, parts[0])
+	if !ok {
+		http.Error(w, "space not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 3 && parts[1] == "report" {
+		d.handleReport(w, r, space, parts[2])
+		return
+	}
+
+	switch strings.Join(parts[1:], "/") {
+	case "":
+		d.handleSpaceDetail(w, r, space)
+	case "report":
+		d.handleReport(w, r, space, "")
+	case "units":
+		d.handleSpaceUnits(w, r, space)
+	case "limit":
+		d.handleSpendLimit(w, r, space)
+	case "baseline":
+		d.handleBaseline(w, r, space)
+	case "baseline/reset":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		d.pinBaseline(w, space, space.CurrentCost, "reset")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleBaseline shows (GET), pins (POST) or removes (DELETE) a space baseline.
+// POST pins the current cost unless the body supplies {"cost": ..., "note": ...}.
+func (d *MonitorDashboard) handleBaseline(w http.ResponseWriter, r *http.Request, space *SpaceMonitor) {
+	switch r.Method {
+	case http.MethodGet:
+		d.monitor.mu.RLock()
+		baseline := space.Baseline
+		d.monitor.mu.RUnlock()
+
+		if baseline == nil {
+			http.Error(w, "no baseline pinned", http.StatusNotFound)
+			return
+		}
+		writeBaseline(w, space, baseline)
+
+	case http.MethodPost:
+		var req BaselineRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "decode baseline: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		cost := space.CurrentCost
+		if req.Cost != nil {
+			cost = *req.Cost
+		}
+		d.pinBaseline(w, space, cost, req.Note)
+
+	case http.MethodDelete:
+		d.monitor.mu.Lock()
+		space.Baseline = nil
+		d.monitor.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pinBaseline replaces the space baseline and returns it
+func (d *MonitorDashboard) pinBaseline(w http.ResponseWriter, space *SpaceMonitor, cost float64, note string) {
+	now := time.Now()
+	baseline := newCostBaseline(cost, note, now)
+	baseline.update(space.CurrentCost, now)
+
+	d.monitor.mu.Lock()
+	space.Baseline = baseline
+	d.monitor.mu.Unlock()
+
+	slog.Info("Pinned cost baseline", logging.Space(space.SpaceName), "monthly_cost", cost)
+	writeBaseline(w, space, baseline)
+}
+
+func writeBaseline(w http.ResponseWriter, space *SpaceMonitor, baseline *CostBaseline) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(SpaceBaseline{
+		SpaceID:   space.SpaceID,
+		SpaceName: space.SpaceName,
+		Baseline:  baseline,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
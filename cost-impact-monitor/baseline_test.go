@@ -0,0 +1,38 @@
+package costimpactmonitor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCostBaselineAccruesDeviation(t *testing.T) {
+	pinned := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	b := newCostBaseline(300, "Q4 budget review", pinned)
+	b.update(300, pinned)
+
+	// Run-rate jumps to $360/month for 15 days (half a month)...
+	b.update(360, pinned.Add(time.Hour))
+	if b.Deviation != 60 || b.DeviationPercent != 20 {
+		t.Errorf("deviation = %.2f (%.1f%%), want 60 (20%%)", b.Deviation, b.DeviationPercent)
+	}
+
+	// ...then drops back below baseline
+	b.update(270, pinned.Add(time.Hour+15*24*time.Hour))
+	if math.Abs(b.CumulativeDeviation-30) > 0.01 {
+		t.Errorf("cumulative deviation = %.2f, want 30 (half a month at +$60)", b.CumulativeDeviation)
+	}
+	if b.Deviation != -30 {
+		t.Errorf("deviation = %.2f, want -30", b.Deviation)
+	}
+}
+
+func TestCostBaselineZeroCost(t *testing.T) {
+	now := time.Now()
+	b := newCostBaseline(0, "", now)
+	b.update(50, now)
+
+	if b.Deviation != 50 || b.DeviationPercent != 0 {
+		t.Errorf("deviation = %.2f (%.1f%%), want 50 (0%%)", b.Deviation, b.DeviationPercent)
+	}
+}
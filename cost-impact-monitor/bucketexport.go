@@ -0,0 +1,44 @@
+package costimpactmonitor
+
+import (
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/export"
+)
+
+// costSnapshot is a row of the cost_snapshots dataset, one per space analysis
+type costSnapshot struct {
+	AnalyzedAt      time.Time `json:"analyzed_at"`
+	SpaceID         string    `json:"space_id"`
+	Space           string    `json:"space"`
+	Team            string    `json:"team"`
+	MonthlyCost     float64   `json:"monthly_cost"`
+	ProjectedCost   float64   `json:"projected_monthly_cost"`
+	PendingChanges  int       `json:"pending_changes"`
+	HighRiskChanges int       `json:"high_risk_changes"`
+	CostTrend       string    `json:"cost_trend"`
+}
+
+// exportCost buffers the space's latest analysis for the export bucket
+func (m *CostImpactMonitor) exportCost(space *SpaceMonitor) {
+	if m.exporter == nil {
+		return
+	}
+	highRisk := 0
+	for _, change := range space.PendingChanges {
+		if change.RiskLevel == "high" || change.RiskLevel == "critical" {
+			highRisk++
+		}
+	}
+	m.exporter.Add(export.CostSnapshots, space.SpaceName, costSnapshot{
+		AnalyzedAt:      space.LastAnalysis.UTC(),
+		SpaceID:         space.SpaceID.String(),
+		Space:           space.SpaceName,
+		Team:            space.Team,
+		MonthlyCost:     space.CurrentCost,
+		ProjectedCost:   space.ProjectedCost,
+		PendingChanges:  len(space.PendingChanges),
+		HighRiskChanges: highRisk,
+		CostTrend:       space.CostTrend.Direction,
+	})
+}
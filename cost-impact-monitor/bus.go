@@ -0,0 +1,51 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/driftstream"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// publishPending announces a priced change about to deploy as change.pending
+func (m *CostImpactMonitor) publishPending(impact *CostImpact) {
+	if m.bus == nil {
+		return
+	}
+	space := impact.SpaceID
+	id, _ := uuid.Parse(impact.SpaceID)
+	m.mu.RLock()
+	if s, ok := m.monitoredSpaces[id]; ok {
+		space = s.SpaceName
+	}
+	m.mu.RUnlock()
+	m.bus.Publish(m.auditContext(id), events.ChangePending, space, impact.UnitName, impact)
+}
+
+// applyDriftEvent prices the drift of a drift.detected or drift.resolved
+// event, which carries the same snapshot as the drift stream
+func (m *CostImpactMonitor) applyDriftEvent(e events.Event) {
+	var snapshot driftstream.Snapshot
+	if len(e.Data) > 0 {
+		if err := json.Unmarshal(e.Data, &snapshot); err != nil {
+			slog.Warn("Ignoring malformed drift event", "id", e.ID, logging.Err(err))
+			return
+		}
+	}
+	if snapshot.Space == "" {
+		snapshot.Space = e.Space
+	}
+	if snapshot.DetectedAt.IsZero() {
+		snapshot.DetectedAt = e.Time
+	}
+	if snapshot.CorrelationID == "" {
+		snapshot.CorrelationID = e.CorrelationID
+	}
+	if e.Type == events.DriftResolved {
+		snapshot.Items = nil
+	}
+	m.applyDrift(snapshot)
+}
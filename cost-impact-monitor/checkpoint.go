@@ -0,0 +1,86 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newCheckpointer returns the checkpointer keeping the monitor's state in
+// the checkpoint-cost-impact-monitor unit of the space with slug space; it saves
+// nothing without one
+func newCheckpointer(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) *checkpoint.Checkpointer {
+	if cub == nil || space == "" {
+		return checkpoint.New("cost-impact-monitor", nil, nil)
+	}
+	spaceID := func(ctx context.Context) (uuid.UUID, error) {
+		spaces, err := ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug == space {
+				return s.SpaceID, nil
+			}
+		}
+		return uuid.Nil, fmt.Errorf("checkpoint space %s not found", space)
+	}
+
+	writer := func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return err
+		}
+		units, err := cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: fmt.Sprintf("Slug = '%s'", slug)})
+		if err != nil {
+			return fmt.Errorf("list units: %w", err)
+		}
+		if len(units) > 0 {
+			_, err = cub.UpdateUnit(id, units[0].UnitID, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+			return err
+		}
+		_, err = cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+		return err
+	}
+	reader := func(ctx context.Context, where string) ([]string, error) {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		units, err := ratelimit.Call(ctx, limit, "ListUnits", id.String()+"/"+where, func() ([]*sdk.Unit, error) {
+			return cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: where})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list units: %w", err)
+		}
+		data := make([]string, 0, len(units))
+		for _, u := range units {
+			data = append(data, u.Data)
+		}
+		return data, nil
+	}
+	return checkpoint.New("cost-impact-monitor", writer, reader)
+}
+
+// registerCheckpoint adds the monitor's state, the one STATE_FILE keeps, to
+// cp. Only the leader saves it, since standby replicas don't process
+// triggers.
+func (m *CostImpactMonitor) registerCheckpoint(cp *checkpoint.Checkpointer) {
+	cp.Leader = m.leader.IsLeader
+	cp.Register("monitor", func() (interface{}, error) {
+		data, err := m.encodeState()
+		return json.RawMessage(data), err
+	}, func(data json.RawMessage) error {
+		var state monitorState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+		m.applyState(&state)
+		return nil
+	})
+}
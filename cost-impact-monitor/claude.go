@@ -0,0 +1,86 @@
+package costimpactmonitor
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/llm"
+)
+
+// claudeClient is the part of the AI client the monitor uses
+type claudeClient interface {
+	Complete(prompt string) (string, error)
+}
+
+// newClaudeClient returns canned assessments with claude_mode stub, otherwise
+// a client of the configured provider and budget, nil when the provider needs
+// an API key and none is set
+func newClaudeClient(cfg Config) claudeClient {
+	if cfg.ClaudeMode == claudestub.ModeStub {
+		return claudestub.New(stubChangeAssessment, stubWhatIfAssessment)
+	}
+	key := cfg.llmAPIKey()
+	if key == "" && cfg.llmConfig().NeedsKey() {
+		return nil
+	}
+	return llm.New(key, cfg.llmConfig())
+}
+
+// guardedClaude passes prompts through a circuit breaker
+type guardedClaude struct {
+	client  claudeClient
+	breaker *breaker.Breaker
+}
+
+// guardClaude wraps client in b, keeping a nil client nil
+func guardClaude(client claudeClient, b *breaker.Breaker) claudeClient {
+	if client == nil {
+		return nil
+	}
+	return guardedClaude{client: client, breaker: b}
+}
+
+func (g guardedClaude) Complete(prompt string) (string, error) {
+	return breaker.Call(g.breaker, func() (string, error) { return g.client.Complete(prompt) })
+}
+
+var (
+	promptRiskLevel = regexp.MustCompile(`Risk Level: (\w+)`)
+	promptCostDelta = regexp.MustCompile(`(?:Cost Delta: |delta )\$(-?[\d.]+)/month`)
+)
+
+// stubAssessment words a canned assessment from the risk and delta in the prompt
+func stubAssessment(prompt string) string {
+	risk, delta := "unknown", "an unknown amount"
+	if m := promptRiskLevel.FindStringSubmatch(prompt); m != nil {
+		risk = m[1]
+	}
+	if m := promptCostDelta.FindStringSubmatch(prompt); m != nil {
+		delta = "$" + m[1] + "/month"
+	}
+
+	advice := "Safe to proceed."
+	switch risk {
+	case "medium":
+		advice = "Proceed, but confirm the new requests match observed usage."
+	case "high", "critical":
+		advice = "Get owner approval first and consider a smaller replica count or lighter instance type."
+	}
+	return fmt.Sprintf("[stub] %s risk: the change moves cost by %s. %s", risk, delta, advice)
+}
+
+// stubChangeAssessment answers getClaudeAssessment
+var stubChangeAssessment = claudestub.Responder{
+	Name:   "change-assessment",
+	Match:  claudestub.Contains("Assess this ConfigHub deployment cost change"),
+	Answer: func(prompt string, _ interface{}) (string, error) { return stubAssessment(prompt), nil },
+}
+
+// stubWhatIfAssessment answers getWhatIfAssessment
+var stubWhatIfAssessment = claudestub.Responder{
+	Name:   "whatif-assessment",
+	Match:  claudestub.Contains("A team is considering this ConfigHub change"),
+	Answer: func(prompt string, _ interface{}) (string, error) { return stubAssessment(prompt), nil },
+}
@@ -0,0 +1,128 @@
+package costimpactmonitor
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/logging"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// deletionTTL is how long a deleted unit stays listed as a pending
+// cost-reducing change, giving the worker time to remove the live resources
+const deletionTTL = 1 * time.Hour
+
+// reconcileUnits refreshes the unit cache for a space and returns the
+// cached units that are no longer present in ConfigHub
+func (c *ChangeDetector) reconcileUnits(spaceID uuid.UUID, units []*sdk.Unit) []*sdk.Unit {
+	current := make(map[string]bool, len(units))
+	for _, unit := range units {
+		key := unit.UnitID.String()
+		current[key] = true
+		c.unitCache[key] = unit
+	}
+
+	var deleted []*sdk.Unit
+	for key, cached := range c.unitCache {
+		if cached.SpaceID == spaceID && !current[key] {
+			deleted = append(deleted, cached)
+			delete(c.unitCache, key)
+			delete(c.revisionCache, key)
+			delete(c.statusCache, key)
+		}
+	}
+
+	return deleted
+}
+
+// handleUnitDeletion records a deleted unit as a cost-reducing pending change,
+// runs the pre-apply hooks for it and drops its trigger bookkeeping
+func (t *TriggerProcessor) handleUnitDeletion(unit *sdk.Unit) {
+	m := t.monitor
+	cost := m.calculateUnitCost(unit)
+	now := time.Now()
+
+	change := PendingChange{
+		UnitID:        unit.UnitID.String(),
+		UnitName:      unit.Slug,
+		ChangeType:    "delete",
+		CurrentCost:   cost,
+		ProjectedCost: 0,
+		CostDelta:     -cost,
+		RiskLevel:     "low",
+		RiskFactors:   []string{"Unit deleted"},
+		AnalysisTime:  now,
+	}
+
+	m.mu.Lock()
+	if space, exists := m.monitoredSpaces[unit.SpaceID]; exists {
+		if space.DeletedUnits == nil {
+			space.DeletedUnits = make(map[string]PendingChange)
+		}
+		space.DeletedUnits[change.UnitID] = change
+	}
+	m.mu.Unlock()
+
+	t.mu.Lock()
+	delete(t.lastProcessed, change.UnitID)
+	t.mu.Unlock()
+	m.escalations.Resolve(change.UnitID)
+	m.forgetWarnings(change.UnitID)
+
+	slog.Info("Unit deleted", logging.Unit(unit.Slug), "monthly_savings", cost)
+
+	impact := &CostImpact{
+		UnitID:      change.UnitID,
+		UnitName:    unit.Slug,
+		MonthlyCost: 0,
+		CostDelta:   -cost,
+		RiskAssessment: RiskAssessment{
+			Level:          "low",
+			Factors:        []string{"Unit deleted"},
+			Recommendation: "Safe to deploy",
+			AutoApprove:    true,
+		},
+	}
+	for _, hook := range t.preApplyHooks {
+		if err := hook(unit, impact); err != nil {
+			slog.Warn("Pre-apply hook failed", logging.Unit(unit.Slug), logging.Err(err))
+		}
+	}
+}
+
+// pruneLastProcessed forgets trigger timestamps and revisions for units that
+// no longer exist in any monitored space (e.g. deleted while the monitor was down)
+func (t *TriggerProcessor) pruneLastProcessed(seen map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.lastProcessed {
+		if !seen[key] {
+			delete(t.lastProcessed, key)
+		}
+	}
+
+	if c := t.changeDetector; c != nil {
+		for key := range c.revisionCache {
+			if !seen[key] {
+				delete(c.revisionCache, key)
+				delete(c.statusCache, key)
+			}
+		}
+	}
+}
+
+// activeDeletions returns a space's recent unit deletions, expiring old ones.
+// Callers must hold m.mu.
+func activeDeletions(space *SpaceMonitor, now time.Time) []PendingChange {
+	var changes []PendingChange
+	for key, change := range space.DeletedUnits {
+		if now.Sub(change.AnalysisTime) > deletionTTL {
+			delete(space.DeletedUnits, key)
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
@@ -0,0 +1,70 @@
+package costimpactmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestReconcileUnitsDetectsDeletions(t *testing.T) {
+	c := &ChangeDetector{
+		unitCache:     make(map[string]*sdk.Unit),
+		revisionCache: make(map[string]int),
+	}
+	space, other := uuid.New(), uuid.New()
+	backend := &sdk.Unit{UnitID: uuid.New(), SpaceID: space, Slug: "backend"}
+	worker := &sdk.Unit{UnitID: uuid.New(), SpaceID: space, Slug: "worker"}
+	elsewhere := &sdk.Unit{UnitID: uuid.New(), SpaceID: other, Slug: "elsewhere"}
+
+	c.reconcileUnits(space, []*sdk.Unit{backend, worker})
+	c.reconcileUnits(other, []*sdk.Unit{elsewhere})
+	c.revisionCache[worker.UnitID.String()] = 3
+
+	deleted := c.reconcileUnits(space, []*sdk.Unit{backend})
+	if len(deleted) != 1 || deleted[0].Slug != "worker" {
+		t.Fatalf("deleted = %v, want [worker]", deleted)
+	}
+	if _, cached := c.unitCache[worker.UnitID.String()]; cached {
+		t.Error("deleted unit still cached")
+	}
+	if _, cached := c.revisionCache[worker.UnitID.String()]; cached {
+		t.Error("deleted unit revision still cached")
+	}
+	if _, cached := c.unitCache[elsewhere.UnitID.String()]; !cached {
+		t.Error("unit from another space was evicted")
+	}
+}
+
+func TestActiveDeletionsExpire(t *testing.T) {
+	now := time.Now()
+	space := &SpaceMonitor{DeletedUnits: map[string]PendingChange{
+		"recent": {UnitName: "recent", AnalysisTime: now.Add(-time.Minute)},
+		"old":    {UnitName: "old", AnalysisTime: now.Add(-2 * deletionTTL)},
+	}}
+
+	changes := activeDeletions(space, now)
+	if len(changes) != 1 || changes[0].UnitName != "recent" {
+		t.Errorf("active deletions = %v, want [recent]", changes)
+	}
+	if _, kept := space.DeletedUnits["old"]; kept {
+		t.Error("expired deletion was not removed")
+	}
+}
+
+func TestPruneLastProcessed(t *testing.T) {
+	tp := &TriggerProcessor{lastProcessed: map[string]time.Time{
+		"live": time.Now(),
+		"gone": time.Now(),
+	}}
+
+	tp.pruneLastProcessed(map[string]bool{"live": true})
+
+	if _, ok := tp.lastProcessed["gone"]; ok {
+		t.Error("timestamp for deleted unit was kept")
+	}
+	if _, ok := tp.lastProcessed["live"]; !ok {
+		t.Error("timestamp for live unit was pruned")
+	}
+}
@@ -0,0 +1,173 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// defaultTarget is used for units without a target label; it is the cluster
+// the monitor itself runs in
+const defaultTarget = "default"
+
+// ClusterRegistry holds a Kubernetes client per ConfigHub target so actual
+// usage is measured in the cluster each unit was applied to
+type ClusterRegistry struct {
+	clients map[string]kubernetes.Interface
+	pricing costmodel.Pricing // measured usage is priced at
+}
+
+// NewClusterRegistry builds clients from a target_kubeconfigs spec
+// ("target=/path/to/kubeconfig,..."), plus the monitor's own cluster as the default
+func NewClusterRegistry(app *sdk.DevOpsApp, spec string, pricing costmodel.Pricing) (*ClusterRegistry, error) {
+	r := &ClusterRegistry{clients: make(map[string]kubernetes.Interface), pricing: pricing}
+	if app.K8s != nil && app.K8s.Clientset != nil {
+		r.clients[defaultTarget] = app.K8s.Clientset
+	}
+
+	if spec == "" {
+		return r, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		target, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || target == "" || path == "" {
+			return nil, fmt.Errorf("invalid TARGET_KUBECONFIGS entry %q", entry)
+		}
+		config, err := clientcmd.BuildConfigFromFlags("", path)
+		if err != nil {
+			return nil, fmt.Errorf("load kubeconfig for target %s: %w", target, err)
+		}
+		config.Wrap(tracing.WrapTransport)
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("create client for target %s: %w", target, err)
+		}
+		r.clients[target] = client
+		slog.Info("Measuring target", logging.Cluster(target), "kubeconfig", path)
+	}
+
+	return r, nil
+}
+
+// targetOf returns the target a unit is applied to, from its "target" label
+func targetOf(unit *sdk.Unit) string {
+	if target := unit.Labels["target"]; target != "" {
+		return target
+	}
+	return defaultTarget
+}
+
+// measure reads a unit's deployment from its target cluster and prices the
+// resources requested by its ready replicas
+func (r *ClusterRegistry) measure(ctx context.Context, unit *sdk.Unit) (*ActualUsage, error) {
+	target := targetOf(unit)
+	client, ok := r.clients[target]
+	if !ok {
+		return nil, fmt.Errorf("no cluster configured for target %s", target)
+	}
+
+	namespace := unit.Labels["namespace"]
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	deployment, err := client.AppsV1().Deployments(namespace).Get(ctx, unit.Slug, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get deployment %s/%s on %s: %w", namespace, unit.Slug, target, err)
+	}
+
+	cpu, memory := 0.0, 0.0
+	for _, c := range deployment.Spec.Template.Spec.Containers {
+		cpu += float64(c.Resources.Requests.Cpu().MilliValue()) / 1000
+		memory += float64(c.Resources.Requests.Memory().Value()) / (1 << 30)
+	}
+	replicas := float64(deployment.Status.ReadyReplicas)
+
+	actual := &ActualUsage{
+		UnitID:     unit.UnitID.String(),
+		UnitName:   unit.Slug,
+		Target:     target,
+		CPUCores:   cpu * replicas,
+		MemoryGB:   memory * replicas,
+		MeasuredAt: time.Now(),
+	}
+	actual.MonthlyCost = r.pricing.OrDefault().MonthlyCost(costmodel.Resources{CPUCores: cpu, MemoryGB: memory, Replicas: int(replicas)})
+
+	return actual, nil
+}
+
+// TargetCost compares predicted and actual cost for the units on one target
+type TargetCost struct {
+	Target        string    `json:"target"`
+	Units         int       `json:"units"`
+	PredictedCost float64   `json:"predicted_cost"`
+	ActualCost    float64   `json:"actual_cost"`
+	Variance      float64   `json:"variance"` // percent, actual vs predicted
+	LastMeasured  time.Time `json:"last_measured"`
+}
+
+// targetCosts totals the latest deployment record of each unit per target
+func targetCosts(records []DeploymentCostRecord) []TargetCost {
+	latest := make(map[string]DeploymentCostRecord)
+	for _, record := range records {
+		target := record.Target
+		if target == "" {
+			target = defaultTarget
+		}
+		key := target + "/" + record.UnitID
+		if prev, ok := latest[key]; !ok || record.DeployTime.After(prev.DeployTime) {
+			record.Target = target
+			latest[key] = record
+		}
+	}
+
+	byTarget := make(map[string]*TargetCost)
+	for _, record := range latest {
+		tc, ok := byTarget[record.Target]
+		if !ok {
+			tc = &TargetCost{Target: record.Target}
+			byTarget[record.Target] = tc
+		}
+		tc.Units++
+		tc.PredictedCost += record.PredictedCost
+		tc.ActualCost += record.ActualCost
+		if record.DeployTime.After(tc.LastMeasured) {
+			tc.LastMeasured = record.DeployTime
+		}
+	}
+
+	costs := make([]TargetCost, 0, len(byTarget))
+	for _, tc := range byTarget {
+		if tc.PredictedCost > 0 {
+			tc.Variance = (tc.ActualCost - tc.PredictedCost) / tc.PredictedCost * 100
+		}
+		costs = append(costs, *tc)
+	}
+	sort.Slice(costs, func(i, j int) bool { return costs[i].Target < costs[j].Target })
+	return costs
+}
+
+// handleTargets returns actual vs predicted cost per target cluster
+func (d *MonitorDashboard) handleTargets(w http.ResponseWriter, r *http.Request) {
+	targets := targetCosts(d.monitor.historyFor(tenants.FromContext(r.Context())))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(TargetList{Targets: targets, Total: len(targets)}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,97 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestDeployment(name, namespace string, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name: name,
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("500m"),
+								corev1.ResourceMemory: resource.MustParse("1Gi"),
+							},
+						},
+					}},
+				},
+			},
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+func TestClusterRegistryMeasuresUnitTarget(t *testing.T) {
+	registry := &ClusterRegistry{clients: map[string]kubernetes.Interface{
+		"k8s-us-east": fake.NewSimpleClientset(newTestDeployment("api", "shop", 2)),
+		"k8s-eu-west": fake.NewSimpleClientset(newTestDeployment("api", "shop", 6)),
+	}}
+
+	unit := &sdk.Unit{
+		UnitID: uuid.New(),
+		Slug:   "api",
+		Labels: map[string]string{"target": "k8s-eu-west", "namespace": "shop"},
+	}
+
+	actual, err := registry.measure(context.Background(), unit)
+	if err != nil {
+		t.Fatalf("measure: %v", err)
+	}
+	if actual.Target != "k8s-eu-west" || actual.CPUCores != 3 || actual.MemoryGB != 6 {
+		t.Errorf("actual = %+v, want 3 cores and 6GB on k8s-eu-west", actual)
+	}
+	if want := 3*720*0.024 + 6*720*0.006; math.Abs(actual.MonthlyCost-want) > 0.01 {
+		t.Errorf("monthly cost = %.2f, want %.2f", actual.MonthlyCost, want)
+	}
+
+	unit.Labels["target"] = "k8s-ap-south"
+	if _, err := registry.measure(context.Background(), unit); err == nil {
+		t.Error("expected an error for an unconfigured target")
+	}
+}
+
+func TestTargetCosts(t *testing.T) {
+	now := time.Now()
+	records := []DeploymentCostRecord{
+		{UnitID: "api", Target: "k8s-us-east", PredictedCost: 40, ActualCost: 30, DeployTime: now.Add(-2 * time.Hour)},
+		{UnitID: "api", Target: "k8s-us-east", PredictedCost: 40, ActualCost: 50, DeployTime: now}, // supersedes the first
+		{UnitID: "api", Target: "k8s-eu-west", PredictedCost: 40, ActualCost: 40, DeployTime: now},
+		{UnitID: "db", Target: "k8s-eu-west", PredictedCost: 60, ActualCost: 90, DeployTime: now},
+		{UnitID: "worker", PredictedCost: 10, ActualCost: 10, DeployTime: now},
+	}
+
+	costs := targetCosts(records)
+	if len(costs) != 3 {
+		t.Fatalf("got %d targets, want 3: %+v", len(costs), costs)
+	}
+
+	want := map[string]TargetCost{
+		"default":     {Units: 1, PredictedCost: 10, ActualCost: 10, Variance: 0},
+		"k8s-eu-west": {Units: 2, PredictedCost: 100, ActualCost: 130, Variance: 30},
+		"k8s-us-east": {Units: 1, PredictedCost: 40, ActualCost: 50, Variance: 25},
+	}
+	for _, tc := range costs {
+		w := want[tc.Target]
+		if tc.Units != w.Units || tc.PredictedCost != w.PredictedCost ||
+			tc.ActualCost != w.ActualCost || math.Abs(tc.Variance-w.Variance) > 0.001 {
+			t.Errorf("%s = %+v, want %+v", tc.Target, tc, w)
+		}
+	}
+}
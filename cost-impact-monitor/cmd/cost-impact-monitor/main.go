@@ -0,0 +1,10 @@
+// Command cost-impact-monitor watches every ConfigHub space and predicts the
+// cost impact of changes before they are applied. It also runs as
+// "devops-apps impact".
+package main
+
+import costimpactmonitor "github.com/monadic/devops-examples/cost-impact-monitor"
+
+func main() {
+	costimpactmonitor.Main()
+}
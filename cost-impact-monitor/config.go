@@ -0,0 +1,243 @@
+package costimpactmonitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/llm"
+)
+
+// Config holds the monitor's settings, read from CONFIG_FILE (default
+// /etc/cost-impact-monitor/config.yaml). YAML keys are the lower-case names
+// of the environment variables that override them.
+type Config struct {
+	CubToken     string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	CubAPIURL    string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	ClaudeAPIKey string `yaml:"claude_api_key" env:"CLAUDE_API_KEY" secret:"true"`
+	ClaudeMode   string `yaml:"claude_mode" env:"CLAUDE_MODE"` // "api", or "stub" for canned assessments
+
+	// Directory of token files (cub-token, claude-api-key, webhook-secret),
+	// e.g. from Vault or external-secrets; they win over the settings above
+	SecretsDir string `yaml:"secrets_dir" env:"SECRETS_DIR"`
+
+	// Replicas and leader election
+	LeaderElect      bool   `yaml:"leader_elect" env:"LEADER_ELECT"`
+	LeaderElectLease string `yaml:"leader_elect_lease" env:"LEADER_ELECT_LEASE"`
+	PodName          string `yaml:"pod_name" env:"POD_NAME"` // defaults to the hostname
+	PodNamespace     string `yaml:"pod_namespace" env:"POD_NAMESPACE"`
+
+	// Companion config files
+	HooksConfig      string `yaml:"hooks_config" env:"HOOKS_CONFIG"`
+	EscalationConfig string `yaml:"escalation_config" env:"ESCALATION_CONFIG"`
+	NotifyConfig     string `yaml:"notify_config" env:"NOTIFY_CONFIG"`
+	PolicyConfig     string `yaml:"policy_config" env:"POLICY_CONFIG"`               // auto-approval rules, see pkg/policy
+	TeamsConfig      string `yaml:"teams_config" env:"TEAMS_CONFIG"`                 // per-team spaces and tokens; missing is single-tenant
+	AuthConfig       string `yaml:"auth_config" env:"AUTH_CONFIG"`                   // OIDC login for the dashboard; missing leaves it open
+	MetricsSinks     string `yaml:"metrics_sinks_config" env:"METRICS_SINKS_CONFIG"` // Datadog and New Relic pushes, see pkg/metrics; missing only serves /metrics
+	ExportConfig     string `yaml:"export_config" env:"EXPORT_CONFIG"`               // cost snapshots and deployment history to S3 or GCS, see pkg/export
+
+	// Risk gating and feature flags
+	CostGating   bool          `yaml:"cost_gating" env:"COST_GATING"` // escalate and block risky changes
+	FlagsSpace   string        `yaml:"flags_space" env:"FLAGS_SPACE"` // space of the feature-flags unit
+	FlagsRefresh time.Duration `yaml:"flags_refresh" env:"FLAGS_REFRESH"`
+
+	// Space audit entries are written to; empty keeps them in memory
+	AuditSpace string `yaml:"audit_space" env:"AUDIT_SPACE"`
+
+	// Space escalated changes awaiting approval are queued in, see
+	// pkg/pending; without it the directory they are queued in, and without
+	// either they are kept in memory
+	PendingSpace string `yaml:"pending_space" env:"PENDING_SPACE"`
+	PendingDir   string `yaml:"pending_dir" env:"PENDING_DIR"`
+
+	// Cluster label of the /metrics samples
+	ClusterName string `yaml:"cluster_name" env:"CLUSTER_NAME"`
+
+	// Serve net/http/pprof under /debug/pprof/ on the health port
+	Pprof bool `yaml:"pprof" env:"PPROF"`
+
+	// drift-detector's gRPC drift stream (host:port), whose drift shows up as
+	// pending cost impacts; empty leaves drift out. The token is a team's API
+	// token when the detector serves teams.
+	DriftStreamAddr    string `yaml:"drift_stream_addr" env:"DRIFT_STREAM_ADDR"`
+	DriftDetectorToken string `yaml:"drift_detector_token" env:"DRIFT_DETECTOR_TOKEN" secret:"true"`
+
+	// NATS event bus: pending changes are published to it and drift events
+	// read from it, as an alternative to the drift stream. Empty disables it.
+	NATSURL           string `yaml:"nats_url" env:"NATS_URL"`
+	NATSToken         string `yaml:"nats_token" env:"NATS_TOKEN" secret:"true"`
+	NATSSubjectPrefix string `yaml:"nats_subject_prefix" env:"NATS_SUBJECT_PREFIX"`
+
+	// ConfigHub request budget; 0 reads per second is unlimited
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"`
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+
+	// Circuit breakers: consecutive ConfigHub or Claude failures before calls
+	// fail fast, and the wait before a probe call
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+
+	// AI behind the risk assessments: "claude", "openai" (any OpenAI-compatible
+	// endpoint) or "ollama", at llm_base_url (empty for the provider's own).
+	// llm_api_key authenticates openai and ollama; claude uses claude_api_key.
+	LLMProvider string `yaml:"llm_provider" env:"LLM_PROVIDER"`
+	LLMBaseURL  string `yaml:"llm_base_url" env:"LLM_BASE_URL"`
+	LLMAPIKey   string `yaml:"llm_api_key" env:"LLM_API_KEY" secret:"true"`
+	// Model (empty for the provider's default), response limit and
+	// temperature, and the tokens used a month (0 for no limit). Prices, in
+	// dollars per million tokens, estimate the spend at /api/v1/llm/usage.
+	LLMModel       string  `yaml:"llm_model" env:"LLM_MODEL"`
+	LLMMaxTokens   int     `yaml:"llm_max_tokens" env:"LLM_MAX_TOKENS"`
+	LLMTemperature float64 `yaml:"llm_temperature" env:"LLM_TEMPERATURE"`
+	LLMTokenBudget int64   `yaml:"llm_monthly_token_budget" env:"LLM_MONTHLY_TOKEN_BUDGET"`
+	LLMInputPrice  float64 `yaml:"llm_input_price" env:"LLM_INPUT_PRICE"`
+	LLMOutputPrice float64 `yaml:"llm_output_price" env:"LLM_OUTPUT_PRICE"`
+
+	// Space of the prompt-<name> units that replace the built-in risk
+	// assessment prompts (empty keeps them), re-read every prompts_refresh
+	PromptsSpace   string        `yaml:"prompts_space" env:"PROMPTS_SPACE"`
+	PromptsRefresh time.Duration `yaml:"prompts_refresh" env:"PROMPTS_REFRESH"`
+
+	// Analysis
+	RunInterval              time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+	TerraformPlanDir         string        `yaml:"terraform_plan_dir" env:"TERRAFORM_PLAN_DIR"`
+	AnalysisConcurrency      int           `yaml:"analysis_concurrency" env:"ANALYSIS_CONCURRENCY"`
+	SpaceAnalysisTimeout     time.Duration `yaml:"space_analysis_timeout" env:"SPACE_ANALYSIS_TIMEOUT"`
+	TargetKubeconfigs        string        `yaml:"target_kubeconfigs" env:"TARGET_KUBECONFIGS"` // "target=/path/to/kubeconfig,..."
+	AccuracyTolerancePercent float64       `yaml:"accuracy_tolerance_percent" env:"ACCURACY_TOLERANCE_PERCENT"`
+	CostWarningRetention     time.Duration `yaml:"cost_warning_retention" env:"COST_WARNING_RETENTION"`
+	// Cloud whose rates price units and measured usage (aws, gcp or azure),
+	// in cloud_region; empty is the provider's reference region
+	CloudProvider string `yaml:"cloud_provider" env:"CLOUD_PROVIDER"`
+	CloudRegion   string `yaml:"cloud_region" env:"CLOUD_REGION"`
+
+	// State, dashboard and webhooks
+	StateFile        string        `yaml:"state_file" env:"STATE_FILE"`
+	SSEFlushInterval time.Duration `yaml:"sse_flush_interval" env:"SSE_FLUSH_INTERVAL"`
+	WebhookSecret    string        `yaml:"webhook_secret" env:"WEBHOOK_SECRET" secret:"true"`
+
+	// Space the state is also checkpointed to, see pkg/checkpoint, so pods
+	// without a volume for state_file keep it; empty checkpoints nothing
+	CheckpointSpace    string        `yaml:"checkpoint_space" env:"CHECKPOINT_SPACE"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env:"CHECKPOINT_INTERVAL"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them. Keep the config section of deploy/charts/cost-impact-monitor's
+// values.yaml in sync.
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:                "https://hub.confighub.com/api",
+		ClaudeMode:               claudestub.ModeAPI,
+		LeaderElectLease:         "cost-impact-monitor",
+		PodNamespace:             "cost-monitoring",
+		HooksConfig:              "/etc/cost-impact-monitor/hooks.yaml",
+		EscalationConfig:         "/etc/cost-impact-monitor/escalation.yaml",
+		NotifyConfig:             "/etc/cost-impact-monitor/notify.yaml",
+		PolicyConfig:             "/etc/cost-impact-monitor/policy.yaml",
+		TeamsConfig:              "/etc/cost-impact-monitor/teams.yaml",
+		AuthConfig:               "/etc/cost-impact-monitor/auth.yaml",
+		MetricsSinks:             "/etc/cost-impact-monitor/metrics-sinks.yaml",
+		ExportConfig:             "/etc/cost-impact-monitor/export.yaml",
+		CostGating:               true,
+		FlagsRefresh:             30 * time.Second,
+		CubRateLimit:             5,
+		CubRateBurst:             10,
+		BreakerThreshold:         5,
+		BreakerCooldown:          30 * time.Second,
+		RunInterval:              time.Minute,
+		AnalysisConcurrency:      8,
+		SpaceAnalysisTimeout:     30 * time.Second,
+		AccuracyTolerancePercent: 10,
+		CostWarningRetention:     7 * 24 * time.Hour,
+		CloudProvider:            costmodel.AWS,
+		StateFile:                "/var/lib/cost-impact-monitor/state.json",
+		SSEFlushInterval:         1 * time.Second,
+		CheckpointInterval:       checkpoint.DefaultInterval,
+		NATSSubjectPrefix:        events.DefaultPrefix,
+		LLMProvider:              llm.ProviderClaude,
+		LLMMaxTokens:             llm.DefaultMaxTokens,
+		LLMInputPrice:            llm.DefaultInputPrice,
+		LLMOutputPrice:           llm.DefaultOutputPrice,
+		PromptsRefresh:           time.Minute,
+	}
+}
+
+// llmConfig is the provider, model and budget of the AI client
+func (c *Config) llmConfig() llm.Config {
+	return llm.Config{
+		Provider:           c.LLMProvider,
+		BaseURL:            c.LLMBaseURL,
+		Model:              c.LLMModel,
+		MaxTokens:          c.LLMMaxTokens,
+		Temperature:        c.LLMTemperature,
+		MonthlyTokenBudget: c.LLMTokenBudget,
+		InputPrice:         c.LLMInputPrice,
+		OutputPrice:        c.LLMOutputPrice,
+	}
+}
+
+// llmAPIKey is the API key of the configured provider
+func (c *Config) llmAPIKey() string {
+	if c.LLMProvider == llm.ProviderClaude {
+		return c.ClaudeAPIKey
+	}
+	return c.LLMAPIKey
+}
+
+// Validate rejects values the monitor can't run with
+func (c *Config) Validate() error {
+	switch {
+	case c.CubRateLimit < 0:
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	case c.CubRateBurst < 1:
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	case c.BreakerThreshold < 1:
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	case c.BreakerCooldown <= 0:
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	case c.FlagsRefresh <= 0:
+		return fmt.Errorf("flags_refresh must be positive, got %s", c.FlagsRefresh)
+	case c.CheckpointInterval <= 0:
+		return fmt.Errorf("checkpoint_interval must be positive, got %s", c.CheckpointInterval)
+	case c.PromptsRefresh <= 0:
+		return fmt.Errorf("prompts_refresh must be positive, got %s", c.PromptsRefresh)
+	case c.RunInterval <= 0:
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	case c.AnalysisConcurrency < 1:
+		return fmt.Errorf("analysis_concurrency must be at least 1, got %d", c.AnalysisConcurrency)
+	case c.SpaceAnalysisTimeout <= 0:
+		return fmt.Errorf("space_analysis_timeout must be positive, got %s", c.SpaceAnalysisTimeout)
+	case c.AccuracyTolerancePercent <= 0:
+		return fmt.Errorf("accuracy_tolerance_percent must be positive, got %g", c.AccuracyTolerancePercent)
+	case c.CostWarningRetention <= 0:
+		return fmt.Errorf("cost_warning_retention must be positive, got %s", c.CostWarningRetention)
+	case c.SSEFlushInterval <= 0:
+		return fmt.Errorf("sse_flush_interval must be positive, got %s", c.SSEFlushInterval)
+	case c.LeaderElect && c.LeaderElectLease == "":
+		return fmt.Errorf("leader_elect_lease is required when leader_elect is on")
+	}
+	if _, err := costmodel.Lookup(c.CloudProvider, c.CloudRegion); err != nil {
+		return fmt.Errorf("cloud_provider: %w", err)
+	}
+	if err := c.llmConfig().Validate(); err != nil {
+		return err
+	}
+	return claudestub.ValidateMode(c.ClaudeMode)
+}
+
+// loadConfig reads the config file named by COST_IMPACT_MONITOR_CONFIG_FILE or
+// CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(config.File("cost-impact-monitor", "/etc/cost-impact-monitor/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
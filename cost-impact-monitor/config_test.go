@@ -0,0 +1,78 @@
+package costimpactmonitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+analysis_concurrency: 4
+cost_warning_retention: 72h
+leader_elect: true
+webhook_secret: from-file
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("ANALYSIS_CONCURRENCY", "2")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AnalysisConcurrency != 2 || cfg.CostWarningRetention != 72*time.Hour || !cfg.LeaderElect {
+		t.Errorf("config = %+v", cfg)
+	}
+	if cfg.SpaceAnalysisTimeout != 30*time.Second || cfg.StateFile != "/var/lib/cost-impact-monitor/state.json" {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+	if s, _ := effective.Get("webhook_secret"); s.Value != "****" {
+		t.Errorf("webhook_secret reported as %q, want it masked", s.Value)
+	}
+	if s, _ := effective.Get("analysis_concurrency"); s.Env != "ANALYSIS_CONCURRENCY" || s.Value != "2" {
+		t.Errorf("analysis_concurrency = %+v", s)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"negative rate limit", func(c *Config) { c.CubRateLimit = -1 }, "cub_rate_limit"},
+		{"no burst", func(c *Config) { c.CubRateBurst = 0 }, "cub_rate_burst"},
+		{"no breaker threshold", func(c *Config) { c.BreakerThreshold = 0 }, "breaker_threshold"},
+		{"no breaker cooldown", func(c *Config) { c.BreakerCooldown = 0 }, "breaker_cooldown"},
+		{"no flags refresh", func(c *Config) { c.FlagsRefresh = 0 }, "flags_refresh"},
+		{"no interval", func(c *Config) { c.RunInterval = 0 }, "run_interval"},
+		{"no workers", func(c *Config) { c.AnalysisConcurrency = 0 }, "analysis_concurrency"},
+		{"no timeout", func(c *Config) { c.SpaceAnalysisTimeout = 0 }, "space_analysis_timeout"},
+		{"no tolerance", func(c *Config) { c.AccuracyTolerancePercent = -1 }, "accuracy_tolerance_percent"},
+		{"no retention", func(c *Config) { c.CostWarningRetention = 0 }, "cost_warning_retention"},
+		{"no flush", func(c *Config) { c.SSEFlushInterval = 0 }, "sse_flush_interval"},
+		{"no lease", func(c *Config) { c.LeaderElect, c.LeaderElectLease = true, "" }, "leader_elect_lease"},
+		{"unknown claude mode", func(c *Config) { c.ClaudeMode = "mock" }, "claude_mode"},
+		{"unknown cloud", func(c *Config) { c.CloudProvider = "oracle" }, "cloud_provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("err = %v, want mention of %s", err, tt.want)
+			}
+		})
+	}
+}
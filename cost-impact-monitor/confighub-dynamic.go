@@ -1,10 +1,19 @@
+//go:build ignore
+
+// Part of the live dashboard program, see live-dashboard.go.
+
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
 // ConfigHubSpace represents a space from ConfigHub
@@ -59,8 +68,18 @@ func GetConfigHubSpaces() ([]string, error) {
 
 // GetConfigHubUnits dynamically fetches units from a space
 func GetConfigHubUnits(space string) ([]string, error) {
+	return GetConfigHubUnitsWhere(space, "")
+}
+
+// GetConfigHubUnitsWhere fetches the units of a space matching a cub --where
+// filter (e.g. "Labels.tier = 'backend'"); an empty filter matches every unit
+func GetConfigHubUnitsWhere(space, where string) ([]string, error) {
 	// Use cub CLI to get units (without --json)
-	cmd := exec.Command("cub", "unit", "list", "--space", space)
+	args := []string{"unit", "list", "--space", space}
+	if where != "" {
+		args = append(args, "--where", where)
+	}
+	cmd := exec.Command("cub", args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return []string{}, fmt.Errorf("failed to get units: %v", err)
@@ -109,4 +128,99 @@ func GetDynamicConfigHubInfo() ConfigHubInfo {
 	}
 
 	return info
-}
\ No newline at end of file
+}
+// ExpectedDeployment is a Deployment declared by a ConfigHub unit
+type ExpectedDeployment struct {
+	Unit     string `json:"unit"`
+	Replicas int32  `json:"replicas"`
+}
+
+// GetConfigHubExpectedState reads the Deployments declared by the units of a
+// space, keyed by deployment name
+func GetConfigHubExpectedState(space, where string) (map[string]ExpectedDeployment, error) {
+	units, err := GetConfigHubUnitsWhere(space, where)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[string]ExpectedDeployment)
+	for _, unit := range units {
+		cmd := exec.Command("cub", "unit", "get", unit, "--space", space, "--data-only")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get unit %s: %v", unit, err)
+		}
+		replicas, err := parseExpectedReplicas(output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse unit %s: %v", unit, err)
+		}
+		for name, count := range replicas {
+			expected[name] = ExpectedDeployment{Unit: unit, Replicas: count}
+		}
+	}
+
+	return expected, nil
+}
+
+// parseExpectedReplicas extracts the replica count of every Deployment in a
+// (possibly multi-document) manifest
+func parseExpectedReplicas(data []byte) (map[string]int32, error) {
+	replicas := make(map[string]int32)
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var doc struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Replicas *int32 `json:"replicas"`
+			} `json:"spec"`
+		}
+		if err := decoder.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if doc.Kind != "Deployment" || doc.Metadata.Name == "" {
+			continue
+		}
+		// Kubernetes defaults an unset replica count to 1
+		count := int32(1)
+		if doc.Spec.Replicas != nil {
+			count = *doc.Spec.Replicas
+		}
+		replicas[doc.Metadata.Name] = count
+	}
+	return replicas, nil
+}
+
+// ApplyConfigHubReplicas patches a unit's replica count and applies the unit
+// to its target, returning the cub output
+func ApplyConfigHubReplicas(space, unit string, replicas int32) (string, error) {
+	update := exec.Command("cub", "unit", "update", unit, "--space", space, "--patch", "--from-stdin")
+	update.Stdin = strings.NewReader(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	updateOutput, err := update.CombinedOutput()
+	if err != nil {
+		return string(updateOutput), fmt.Errorf("failed to patch unit %s: %v", unit, err)
+	}
+
+	apply := exec.Command("cub", "unit", "apply", unit, "--space", space)
+	applyOutput, err := apply.CombinedOutput()
+	output := string(updateOutput) + string(applyOutput)
+	if err != nil {
+		return output, fmt.Errorf("failed to apply unit %s: %v", unit, err)
+	}
+
+	return output, nil
+}
+
+// CheckConfigHubConnection verifies the cub CLI can list spaces, without the
+// CUB_SPACE fallback GetConfigHubSpaces uses
+func CheckConfigHubConnection(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "cub", "space", "list")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cub space list: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -1,13 +1,17 @@
-package main
+package costimpactmonitor
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 	"sort"
 	"time"
+
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/tenants"
 )
 
 // MonitorDashboard provides web interface for cost impact monitoring
@@ -15,37 +19,52 @@ type MonitorDashboard struct {
 	monitor      *CostImpactMonitor
 	currentData  *MonitoringSnapshot
 	lastUpdate   time.Time
+	events       *EventBroker
 }
 
 // NewMonitorDashboard creates a new dashboard
 func NewMonitorDashboard(monitor *CostImpactMonitor) *MonitorDashboard {
+	// The flush interval bounds how often browsers receive updates under load
 	return &MonitorDashboard{
 		monitor:    monitor,
 		lastUpdate: time.Now(),
+		events:     NewEventBroker(monitor.config.SSEFlushInterval),
 	}
 }
 
-// Start begins serving the dashboard
-func (d *MonitorDashboard) Start() {
+// Start serves the dashboard until ctx is done, then lets the requests in
+// flight finish
+func (d *MonitorDashboard) Start(ctx context.Context) {
 	mux := http.NewServeMux()
 
-	// API endpoints
-	mux.HandleFunc("/api/snapshot", d.handleSnapshot)
-	mux.HandleFunc("/api/spaces", d.handleSpaces)
-	mux.HandleFunc("/api/pending", d.handlePendingChanges)
-	mux.HandleFunc("/api/triggers", d.handleTriggers)
-	mux.HandleFunc("/api/history", d.handleHistory)
+	// API endpoints, under /api/v1
+	d.routes(mux)
+	mux.Handle("/metrics", d.monitor.metrics)
+
+	// Inbound webhooks (HMAC verified)
+	mux.HandleFunc("/webhooks/confighub", d.monitor.webhooks.handleConfigHub)
+	mux.HandleFunc("/webhooks/generic", d.monitor.webhooks.handleGeneric)
 
-	// Main dashboard
+	// Main dashboard and per-space drill-down pages
 	mux.HandleFunc("/", d.handleDashboard)
+	mux.HandleFunc("/spaces/", d.handleSpacePage)
 
 	// Static resources
 	mux.HandleFunc("/static/", d.handleStatic)
 
+	go d.events.Start()
+
+	// With a teams config every view needs a team's credentials and shows
+	// only that team's spaces; with an auth config, a login and a role fit
+	// for the call. Calls that may change state are audited.
+	public := []string{"/metrics", "/webhooks/", "/static/"}
+	handler := d.monitor.guard.Protect(d.monitor.teams.Protect("cost-impact-monitor", d.monitor.audit.Calls(mux), public...), public...)
+
 	port := ":8083"
-	log.Printf("📊 Cost Impact Monitor Dashboard: http://localhost%s", port)
-	if err := http.ListenAndServe(port, mux); err != nil {
-		log.Printf("Dashboard server error: %v", err)
+	slog.Info("Cost Impact Monitor Dashboard", "url", "http://localhost"+port)
+	srv := &http.Server{Addr: port, Handler: handler}
+	if err := lifecycle.Serve(ctx, srv, lifecycle.DefaultGrace); err != nil {
+		slog.Error("Dashboard server failed", logging.Err(err))
 	}
 }
 
@@ -53,6 +72,43 @@ func (d *MonitorDashboard) Start() {
 func (d *MonitorDashboard) UpdateMonitoringData(snapshot *MonitoringSnapshot) {
 	d.currentData = snapshot
 	d.lastUpdate = time.Now()
+
+	d.events.Publish("snapshot", snapshot)
+	d.events.Publish("history", d.historySummary(nil))
+}
+
+// handleEvents streams snapshot, history and impact updates as server-sent events
+func (d *MonitorDashboard) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if d.currentData == nil {
+		d.currentData = d.monitor.getMonitoringSnapshot()
+	}
+
+	team := tenants.FromContext(r.Context())
+	if !team.Unrestricted() {
+		d.events.ServeFiltered(w, r, map[string]interface{}{
+			"snapshot": d.monitor.snapshotFor(team),
+			"history":  d.historySummary(team),
+		}, d.teamEvents(team))
+		return
+	}
+
+	d.events.ServeHTTP(w, r, map[string]interface{}{
+		"snapshot": d.currentData,
+		"history":  d.historySummary(nil),
+	})
+}
+
+// historySummary returns the prediction accuracy across the spaces team may see
+func (d *MonitorDashboard) historySummary(team *tenants.Team) map[string]interface{} {
+	history := d.monitor.historyFor(team)
+	report := computeAccuracy(history, d.monitor.accuracy)
+
+	return map[string]interface{}{
+		"total":         len(history),
+		"accuracy_rate": report.Overall.AccuracyRate,
+		"mape":          report.Overall.MAPE,
+		"tolerance":     report.TolerancePercent,
+	}
 }
 
 // handleSnapshot returns current monitoring snapshot
@@ -62,32 +118,44 @@ func (d *MonitorDashboard) handleSnapshot(w http.ResponseWriter, r *http.Request
 	if d.currentData == nil {
 		d.currentData = d.monitor.getMonitoringSnapshot()
 	}
+	snapshot := d.currentData
+	if team := tenants.FromContext(r.Context()); !team.Unrestricted() {
+		snapshot = d.monitor.snapshotFor(team)
+	}
 
-	if err := json.NewEncoder(w).Encode(d.currentData); err != nil {
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-// handleSpaces returns detailed space information
+// handleSpaces returns detailed space information, filtered and sorted by
+// the listQuery parameters (highest projected cost first by default)
 func (d *MonitorDashboard) handleSpaces(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r.URL.Query(), spaceSortKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
+	team := tenants.FromContext(r.Context())
 	d.monitor.mu.RLock()
 	spaces := make([]*SpaceMonitor, 0, len(d.monitor.monitoredSpaces))
 	for _, space := range d.monitor.monitoredSpaces {
-		spaces = append(spaces, space)
+		if team.OwnsSpace(space.SpaceName) {
+			spaces = append(spaces, space)
+		}
 	}
+	available := len(spaces)
+	spaces = filterSpaces(spaces, q)
 	d.monitor.mu.RUnlock()
 
-	// Sort by projected cost (highest first)
-	sort.Slice(spaces, func(i, j int) bool {
-		return spaces[i].ProjectedCost > spaces[j].ProjectedCost
-	})
-
-	response := map[string]interface{}{
-		"spaces":      spaces,
-		"total":       len(spaces),
-		"last_update": d.lastUpdate,
+	response := SpaceList{
+		Spaces:     spaces,
+		Total:      len(spaces),
+		Available:  available,
+		LastUpdate: d.lastUpdate,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -95,14 +163,42 @@ func (d *MonitorDashboard) handleSpaces(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-// handlePendingChanges returns all pending changes across spaces
+// handlePendingChanges returns pending changes across spaces, filtered and
+// sorted by the listQuery parameters (riskiest first by default)
 func (d *MonitorDashboard) handlePendingChanges(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r.URL.Query(), pendingSortKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
+	allChanges := d.pendingChanges(tenants.FromContext(r.Context()))
+	changes := filterPending(allChanges, q)
+
+	response := PendingList{
+		PendingChanges: changes,
+		Total:          len(changes),
+		Available:      len(allChanges),
+		LastUpdate:     d.lastUpdate,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// pendingChanges flattens the pending changes of every space and Terraform
+// plan team may see, most risky and most expensive first
+func (d *MonitorDashboard) pendingChanges(team *tenants.Team) []map[string]interface{} {
 	var allChanges []map[string]interface{}
 
 	d.monitor.mu.RLock()
 	for _, space := range d.monitor.monitoredSpaces {
+		if !team.OwnsSpace(space.SpaceName) {
+			continue
+		}
 		for _, change := range space.PendingChanges {
 			changeData := map[string]interface{}{
 				"space_name":        space.SpaceName,
@@ -119,51 +215,65 @@ func (d *MonitorDashboard) handlePendingChanges(w http.ResponseWriter, r *http.R
 			allChanges = append(allChanges, changeData)
 		}
 	}
+	for _, plan := range d.monitor.terraformPlans {
+		if !team.Unrestricted() {
+			break
+		}
+		for _, change := range plan.Changes {
+			allChanges = append(allChanges, map[string]interface{}{
+				"space_name":     "terraform: " + plan.Name,
+				"unit_name":      change.UnitName,
+				"change_type":    change.ChangeType,
+				"current_cost":   change.CurrentCost,
+				"projected_cost": change.ProjectedCost,
+				"cost_delta":     change.CostDelta,
+				"risk_level":     change.RiskLevel,
+				"analysis_time":  change.AnalysisTime,
+			})
+		}
+	}
 	d.monitor.mu.RUnlock()
 
 	// Sort by risk level and cost delta
 	sort.Slice(allChanges, func(i, j int) bool {
-		riskOrder := map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3}
-		if riskOrder[allChanges[i]["risk_level"].(string)] != riskOrder[allChanges[j]["risk_level"].(string)] {
-			return riskOrder[allChanges[i]["risk_level"].(string)] < riskOrder[allChanges[j]["risk_level"].(string)]
+		ri, rj := riskRank(allChanges[i]["risk_level"].(string)), riskRank(allChanges[j]["risk_level"].(string))
+		if ri != rj {
+			return ri > rj
 		}
 		return allChanges[i]["cost_delta"].(float64) > allChanges[j]["cost_delta"].(float64)
 	})
 
-	response := map[string]interface{}{
-		"pending_changes": allChanges,
-		"total":          len(allChanges),
-		"last_update":    d.lastUpdate,
-	}
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	return allChanges
 }
 
 // handleTriggers returns trigger activity
 func (d *MonitorDashboard) handleTriggers(w http.ResponseWriter, r *http.Request) {
+	if !requireUnrestricted(w, r) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 
 	// Get recent trigger activity
 	d.monitor.triggerProcessor.mu.Lock()
-	recentTriggers := make([]map[string]interface{}, 0)
+	recentTriggers := make([]RecentTrigger, 0)
 	for unitID, lastProcessed := range d.monitor.triggerProcessor.lastProcessed {
 		if time.Since(lastProcessed) < 1*time.Hour {
-			trigger := map[string]interface{}{
-				"unit_id":        unitID,
-				"last_processed": lastProcessed,
-				"age":           time.Since(lastProcessed).String(),
+			trigger := RecentTrigger{
+				UnitID:        unitID,
+				LastProcessed: lastProcessed,
+				Age:           time.Since(lastProcessed).String(),
 			}
 			recentTriggers = append(recentTriggers, trigger)
 		}
 	}
 	d.monitor.triggerProcessor.mu.Unlock()
 
-	response := map[string]interface{}{
-		"recent_triggers": recentTriggers,
-		"total":          len(recentTriggers),
-		"last_update":    d.lastUpdate,
+	response := TriggerList{
+		RecentTriggers: recentTriggers,
+		Total:          len(recentTriggers),
+		Leader:         d.monitor.leader.IsLeader(),
+		Replica:        d.monitor.leader.Identity(),
+		LastUpdate:     d.lastUpdate,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -175,38 +285,18 @@ func (d *MonitorDashboard) handleTriggers(w http.ResponseWriter, r *http.Request
 func (d *MonitorDashboard) handleHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var allHistory []DeploymentCostRecord
-
-	d.monitor.mu.RLock()
-	for _, space := range d.monitor.monitoredSpaces {
-		allHistory = append(allHistory, space.DeploymentHistory...)
-	}
-	d.monitor.mu.RUnlock()
+	allHistory := d.monitor.historyFor(tenants.FromContext(r.Context()))
 
 	// Sort by deploy time (newest first)
 	sort.Slice(allHistory, func(i, j int) bool {
 		return allHistory[i].DeployTime.After(allHistory[j].DeployTime)
 	})
 
-	// Calculate accuracy metrics
-	totalRecords := len(allHistory)
-	accurateCount := 0
-	for _, record := range allHistory {
-		if record.Accurate {
-			accurateCount++
-		}
-	}
-
-	accuracyRate := 0.0
-	if totalRecords > 0 {
-		accuracyRate = (float64(accurateCount) / float64(totalRecords)) * 100
-	}
-
-	response := map[string]interface{}{
-		"history":       allHistory,
-		"total":         totalRecords,
-		"accuracy_rate": accuracyRate,
-		"last_update":   d.lastUpdate,
+	response := HistoryList{
+		History:      allHistory,
+		Total:        len(allHistory),
+		AccuracyRate: computeAccuracy(allHistory, d.monitor.accuracy).Overall.AccuracyRate,
+		LastUpdate:   d.lastUpdate,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -290,6 +380,29 @@ func (d *MonitorDashboard) handleDashboard(w http.ResponseWriter, r *http.Reques
             margin-bottom: 20px;
             color: #333;
         }
+        .export-link {
+            float: right;
+            font-size: 14px;
+            font-weight: normal;
+            color: #667eea;
+        }
+        .filters {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 10px;
+            align-items: center;
+            margin-bottom: 15px;
+        }
+        .filters select, .filters input {
+            padding: 6px 10px;
+            border: 1px solid #e5e7eb;
+            border-radius: 6px;
+            font-size: 14px;
+        }
+        .filter-count {
+            color: #666;
+            font-size: 14px;
+        }
         .pending-changes {
             display: grid;
             gap: 15px;
@@ -416,7 +529,31 @@ func (d *MonitorDashboard) handleDashboard(w http.ResponseWriter, r *http.Reques
         </div>
 
         <div class="section">
-            <h2 class="section-title">⚠️ Pending Changes (Pre-Deployment Analysis)</h2>
+            <h2 class="section-title">⚠️ Pending Changes (Pre-Deployment Analysis) <a class="export-link" id="pending-export" href="/api/v1/pending/export">⬇ CSV</a></h2>
+            <div class="filters" onchange="refreshPending()">
+                <select id="pending-risk">
+                    <option value="">All risk levels</option>
+                    <option value="critical">Critical</option>
+                    <option value="critical,high">High and above</option>
+                    <option value="critical,high,medium">Medium and above</option>
+                </select>
+                <input id="pending-min_delta" type="number" step="10" placeholder="Min delta $/mo">
+                <input id="pending-space" type="search" placeholder="Space name">
+                <select id="pending-sort">
+                    <option value="">Sort: risk</option>
+                    <option value="cost_delta">Sort: cost delta</option>
+                    <option value="projected_cost">Sort: projected cost</option>
+                    <option value="analysis_time">Sort: newest</option>
+                    <option value="unit_name">Sort: unit name</option>
+                </select>
+                <select id="pending-limit">
+                    <option value="">Show all</option>
+                    <option value="10">Top 10</option>
+                    <option value="25">Top 25</option>
+                    <option value="50">Top 50</option>
+                </select>
+                <span class="filter-count" id="pending-shown"></span>
+            </div>
             <div class="pending-changes" id="pending-changes">
                 <div class="loading">Loading pending changes...</div>
             </div>
@@ -424,13 +561,34 @@ func (d *MonitorDashboard) handleDashboard(w http.ResponseWriter, r *http.Reques
 
         <div class="section">
             <h2 class="section-title">📦 ConfigHub Spaces</h2>
+            <div class="filters" onchange="refreshSpaces()">
+                <input id="spaces-space" type="search" placeholder="Space name">
+                <select id="spaces-risk">
+                    <option value="">Any pending risk</option>
+                    <option value="critical,high">With high-risk changes</option>
+                </select>
+                <select id="spaces-sort">
+                    <option value="">Sort: projected cost</option>
+                    <option value="current_cost">Sort: current cost</option>
+                    <option value="cost_delta">Sort: cost delta</option>
+                    <option value="pending">Sort: pending changes</option>
+                    <option value="trend">Sort: weekly trend</option>
+                    <option value="space_name">Sort: name</option>
+                </select>
+                <select id="spaces-limit">
+                    <option value="">Show all</option>
+                    <option value="12">Top 12</option>
+                    <option value="50">Top 50</option>
+                </select>
+                <span class="filter-count" id="spaces-shown"></span>
+            </div>
             <div class="space-list" id="space-list">
                 <div class="loading">Loading spaces...</div>
             </div>
         </div>
 
         <div class="section">
-            <h2 class="section-title">📊 Deployment History & Accuracy</h2>
+            <h2 class="section-title">📊 Deployment History & Accuracy <a class="export-link" href="/api/v1/history/export">⬇ CSV</a></h2>
             <div id="history-chart">
                 <canvas id="accuracy-chart" height="100"></canvas>
             </div>
@@ -442,52 +600,72 @@ func (d *MonitorDashboard) handleDashboard(w http.ResponseWriter, r *http.Reques
     </div>
 
     <script>
-        // Fetch and display data
-        async function updateDashboard() {
-            try {
-                // Get snapshot
-                const snapshotRes = await fetch('/api/snapshot');
-                const snapshot = await snapshotRes.json();
-
-                // Update main metrics
-                document.getElementById('total-cost').textContent = '$' + snapshot.total_cost.toFixed(2);
-                document.getElementById('projected-cost').textContent = '$' + snapshot.projected_cost.toFixed(2);
-                document.getElementById('pending-count').textContent = snapshot.pending_changes;
-                document.getElementById('space-count').textContent = snapshot.total_spaces;
-
-                // Calculate deltas
-                const costDelta = snapshot.projected_cost - snapshot.total_cost;
-                const deltaElement = document.getElementById('projected-delta');
-                deltaElement.textContent = (costDelta >= 0 ? '+' : '') + '$' + costDelta.toFixed(2);
-                deltaElement.className = 'metric-delta ' + (costDelta > 0 ? 'negative' : 'positive');
-
-                // High risk count
-                if (snapshot.high_risk_changes > 0) {
-                    document.getElementById('high-risk').textContent = snapshot.high_risk_changes + ' high risk';
-                }
-
-                // Get pending changes
-                const pendingRes = await fetch('/api/pending');
-                const pendingData = await pendingRes.json();
-                displayPendingChanges(pendingData.pending_changes);
-
-                // Get spaces
-                const spacesRes = await fetch('/api/spaces');
-                const spacesData = await spacesRes.json();
-                displaySpaces(spacesData.spaces);
-
-                // Get history for accuracy
-                const historyRes = await fetch('/api/history');
-                const historyData = await historyRes.json();
-                document.getElementById('accuracy').textContent =
-                    'Prediction accuracy: ' + historyData.accuracy_rate.toFixed(1) + '%';
-
-                // Update timestamp
-                document.getElementById('last-update').textContent = new Date().toLocaleTimeString();
-
-            } catch (error) {
-                console.error('Failed to update dashboard:', error);
-            }
+        // Render a monitoring snapshot pushed by the server
+        function renderSnapshot(snapshot) {
+            // Update main metrics
+            document.getElementById('total-cost').textContent = '$' + snapshot.total_cost.toFixed(2);
+            document.getElementById('projected-cost').textContent = '$' + snapshot.projected_cost.toFixed(2);
+            document.getElementById('pending-count').textContent = snapshot.pending_changes;
+            document.getElementById('space-count').textContent = snapshot.total_spaces;
+
+            // Calculate deltas
+            const costDelta = snapshot.projected_cost - snapshot.total_cost;
+            const deltaElement = document.getElementById('projected-delta');
+            deltaElement.textContent = (costDelta >= 0 ? '+' : '') + '$' + costDelta.toFixed(2);
+            deltaElement.className = 'metric-delta ' + (costDelta > 0 ? 'negative' : 'positive');
+
+            // High risk count
+            document.getElementById('high-risk').textContent =
+                snapshot.high_risk_changes > 0 ? snapshot.high_risk_changes + ' high risk' : '';
+
+            // Lists are filtered and sorted by the server
+            refreshPending();
+            refreshSpaces();
+
+            touch();
+        }
+
+        // Query string from the filter controls whose ids start with prefix
+        function listQuery(prefix) {
+            const params = new URLSearchParams();
+            ['risk', 'min_delta', 'space', 'sort', 'limit'].forEach(name => {
+                const control = document.getElementById(prefix + '-' + name);
+                if (control && control.value) params.set(name, control.value);
+            });
+            const query = params.toString();
+            return query ? '?' + query : '';
+        }
+
+        function showCount(id, shown, available) {
+            document.getElementById(id).textContent =
+                shown < available ? 'Showing ' + shown + ' of ' + available : '';
+        }
+
+        function refreshPending() {
+            const query = listQuery('pending');
+            document.getElementById('pending-export').href = '/api/v1/pending/export' + query;
+            fetch('/api/v1/pending' + query)
+                .then(r => r.ok ? r.json() : r.text().then(text => Promise.reject(text)))
+                .then(data => {
+                    displayPendingChanges(data.pending_changes);
+                    showCount('pending-shown', data.total, data.available);
+                })
+                .catch(err => touch('pending changes: ' + err));
+        }
+
+        function refreshSpaces() {
+            fetch('/api/v1/spaces' + listQuery('spaces'))
+                .then(r => r.ok ? r.json() : r.text().then(text => Promise.reject(text)))
+                .then(data => {
+                    displaySpaces(data.spaces);
+                    showCount('spaces-shown', data.total, data.available);
+                })
+                .catch(err => touch('spaces: ' + err));
+        }
+
+        function touch(message) {
+            document.getElementById('last-update').textContent =
+                new Date().toLocaleTimeString() + (message ? ' • ' + message : '');
         }
 
         function displayPendingChanges(changes) {
@@ -529,7 +707,9 @@ func (d *MonitorDashboard) handleDashboard(w http.ResponseWriter, r *http.Reques
 
                 return ` + "`" + `
                     <div class="space-row">
-                        <div class="space-name">${space.space_name}</div>
+                        <div class="space-name"><a href="/spaces/${space.space_id}">${space.space_name}</a>${space.baseline ? ` + "`" + `
+                            <div class="change-details">vs baseline: ${space.baseline.deviation >= 0 ? '+' : ''}${space.baseline.deviation_percent.toFixed(1)}%
+                            (${space.baseline.cumulative_deviation >= 0 ? '+' : ''}$${space.baseline.cumulative_deviation.toFixed(2)} since pin)</div>` + "`" + ` : ''}</div>
                         <div>$${space.current_cost.toFixed(2)}/mo</div>
                         <div>$${space.projected_cost.toFixed(2)}/mo</div>
                         <div>${space.pending_changes.length} pending</div>
@@ -542,9 +722,22 @@ func (d *MonitorDashboard) handleDashboard(w http.ResponseWriter, r *http.Reques
             }).join('');
         }
 
-        // Initial load and refresh every 10 seconds
-        updateDashboard();
-        setInterval(updateDashboard, 10000);
+        // Live updates via server-sent events; the server controls the cadence
+        // and EventSource reconnects on its own if the connection drops
+        const events = new EventSource('/api/v1/events');
+        events.addEventListener('snapshot', e => renderSnapshot(JSON.parse(e.data)));
+        events.addEventListener('history', e => {
+            const history = JSON.parse(e.data);
+            document.getElementById('accuracy').textContent =
+                'Prediction accuracy: ' + history.accuracy_rate.toFixed(1) + '% (±' + history.tolerance + '%, MAPE ' +
+                history.mape.toFixed(1) + '%)';
+        });
+        events.addEventListener('impact', e => {
+            const impact = JSON.parse(e.data);
+            touch('analyzed ' + impact.unit_name + ' (' + (impact.cost_delta >= 0 ? '+' : '') +
+                '$' + impact.cost_delta.toFixed(2) + ', ' + impact.risk_assessment.level + ')');
+        });
+        events.onerror = () => touch('reconnecting...');
     </script>
 </body>
 </html>`
@@ -1,3 +1,7 @@
+//go:build ignore
+
+// Standalone demo: go run demo-kind-cost-analysis.go
+
 package main
 
 import (
@@ -7,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/monadic/devops-examples/pkg/costmodel"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -170,18 +175,11 @@ func analyzeDeployment(dep appsv1.Deployment) ResourceCost {
 		}
 	}
 
-	// Calculate monthly cost (AWS pricing estimates)
+	// Calculate monthly cost at the dashboards' AWS rates; the requests
+	// are already totals over all replicas
 	cpuCores := float64(resource.CPURequested) / 1000.0
 	memGB := float64(resource.MemRequested) / (1024 * 1024 * 1024)
-
-	// AWS m5.large equivalent pricing
-	cpuCostPerCore := 0.024 * 24 * 30  // $0.024 per vCPU-hour
-	memCostPerGB := 0.006 * 24 * 30     // $0.006 per GB-hour
-
-	resource.MonthlyCost = (cpuCores * cpuCostPerCore) + (memGB * memCostPerGB)
-
-	// Add minimum cost for pod overhead
-	resource.MonthlyCost += float64(resource.Replicas) * 2.0 // $2 per pod/month overhead
+	resource.MonthlyCost = costmodel.Default.MonthlyCost(costmodel.Resources{CPUCores: cpuCores, MemoryGB: memGB, Replicas: 1})
 
 	return resource
 }
@@ -0,0 +1,134 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/driftstream"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// driftTTL is how long a space's drift stays listed without a new snapshot,
+// in case the drift-detector stops publishing
+const driftTTL = 1 * time.Hour
+
+// followDrift turns the drift-detector's drift into pending cost impacts
+// until ctx ends
+func (m *CostImpactMonitor) followDrift(ctx context.Context) {
+	slog.Info("Following drift stream", "addr", m.config.DriftStreamAddr)
+	driftstream.Follow(ctx, m.config.DriftStreamAddr, m.config.DriftDetectorToken, driftstream.SubscribeRequest{}, m.applyDrift)
+}
+
+// applyDrift replaces a space's drift with the latest snapshot and updates
+// its projected cost at once, without waiting for the next analysis
+func (m *CostImpactMonitor) applyDrift(snapshot driftstream.Snapshot) {
+	m.mu.Lock()
+	var space *SpaceMonitor
+	for _, s := range m.monitoredSpaces {
+		if s.SpaceName == snapshot.Space {
+			space = s
+			break
+		}
+	}
+	if space == nil {
+		m.mu.Unlock()
+		slog.Debug("Drift in an unmonitored space", logging.Space(snapshot.Space))
+		return
+	}
+
+	space.Drift = make([]PendingChange, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		change := m.driftChange(space, item, snapshot.DetectedAt)
+		change.CorrelationID = snapshot.CorrelationID
+		space.Drift = append(space.Drift, change)
+	}
+	costly := highCostDrift(space.Drift)
+
+	// Swap the previous drift for the new one among the pending changes
+	pending := make([]PendingChange, 0, len(space.PendingChanges)+len(space.Drift))
+	for _, change := range space.PendingChanges {
+		if change.ChangeType != "drift" {
+			pending = append(pending, change)
+		}
+	}
+	space.PendingChanges = append(pending, space.Drift...)
+	space.ProjectedCost = space.CurrentCost
+	for _, change := range space.PendingChanges {
+		space.ProjectedCost += change.CostDelta
+	}
+	m.mu.Unlock()
+
+	slog.Info("Drift received", logging.Space(snapshot.Space), logging.Correlation(snapshot.CorrelationID), "items", len(snapshot.Items))
+	for _, change := range costly {
+		slog.Warn("High cost drift", logging.Space(snapshot.Space), logging.Unit(change.UnitName),
+			logging.Correlation(change.CorrelationID), "cost_delta", change.CostDelta)
+		m.notifyDriftCost(snapshot.Space, change)
+	}
+	if m.dashboard != nil {
+		m.dashboard.UpdateMonitoringData(m.getMonitoringSnapshot())
+	}
+}
+
+// driftChange prices a drifted field as a pending change back to ConfigHub's
+// value. Only replica counts change the cost: the unit's cost per expected
+// replica times the replicas actually running. Callers must hold m.mu.
+func (m *CostImpactMonitor) driftChange(space *SpaceMonitor, item driftstream.Item, detectedAt time.Time) PendingChange {
+	change := PendingChange{
+		UnitID:       item.UnitID,
+		UnitName:     item.UnitSlug,
+		ChangeType:   "drift",
+		RiskLevel:    "low",
+		RiskFactors:  []string{fmt.Sprintf("Drift on %s: %s is %s in the cluster, %s in ConfigHub", item.Resource, item.Field, item.Actual, item.Expected)},
+		AnalysisTime: detectedAt,
+	}
+
+	expected, errExpected := strconv.Atoi(item.Expected)
+	actual, errActual := strconv.Atoi(item.Actual)
+	if item.Field != "spec.replicas" || errExpected != nil || errActual != nil {
+		return change
+	}
+
+	cost := defaultUnitCost * float64(expected)
+	for _, unit := range space.UnitCosts {
+		if unit.UnitID == item.UnitID {
+			cost = unit.CurrentCost
+			break
+		}
+	}
+	change.CurrentCost = cost
+	if expected > 0 {
+		change.ProjectedCost = cost / float64(expected) * float64(actual)
+	} else {
+		change.ProjectedCost = defaultUnitCost * float64(actual)
+	}
+	change.CostDelta = change.ProjectedCost - change.CurrentCost
+	change.RiskLevel = m.assessRisk(change.CostDelta)
+	return change
+}
+
+// highCostDrift returns the drifted changes that add more than the high
+// cost warning threshold
+func highCostDrift(drift []PendingChange) []PendingChange {
+	var costly []PendingChange
+	for _, change := range drift {
+		if change.CostDelta > highCostDelta {
+			costly = append(costly, change)
+		}
+	}
+	return costly
+}
+
+// activeDrift returns a space's drift unless the drift-detector has gone
+// quiet for longer than driftTTL. Callers must hold m.mu.
+func activeDrift(space *SpaceMonitor, now time.Time) []PendingChange {
+	var changes []PendingChange
+	for _, change := range space.Drift {
+		if now.Sub(change.AnalysisTime) <= driftTTL {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,98 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/driftstream"
+	"github.com/monadic/devops-examples/pkg/events"
+)
+
+func TestApplyDrift(t *testing.T) {
+	id := uuid.New()
+	space := &SpaceMonitor{
+		SpaceID:        id,
+		SpaceName:      "acme-prod",
+		CurrentCost:    100,
+		ProjectedCost:  130,
+		PendingChanges: []PendingChange{{UnitName: "cache", ChangeType: "create", CostDelta: 30}},
+		UnitCosts:      []UnitCost{{UnitID: "u-backend", UnitName: "backend-api", CurrentCost: 40}},
+	}
+	m := &CostImpactMonitor{monitoredSpaces: map[uuid.UUID]*SpaceMonitor{id: space}}
+	now := time.Now()
+
+	// Replicas bumped 2 -> 5: the unit's $20 per replica now runs 5 times
+	m.applyDrift(driftstream.Snapshot{Space: "acme-prod", DetectedAt: now, CorrelationID: "incident-1", Items: []driftstream.Item{
+		{UnitID: "u-backend", UnitSlug: "backend-api", Resource: "Deployment/backend-api", Field: "spec.replicas", Expected: "2", Actual: "5"},
+		{UnitID: "u-web", UnitSlug: "web", Resource: "Deployment/web", Field: "spec.template.spec.containers[0].image", Expected: "web:1", Actual: "web:2"},
+	}})
+	if len(space.PendingChanges) != 3 {
+		t.Fatalf("Expected the create and two drift changes, got %+v", space.PendingChanges)
+	}
+	drift := space.PendingChanges[1]
+	if drift.ChangeType != "drift" || drift.CurrentCost != 40 || drift.ProjectedCost != 100 || drift.CostDelta != 60 || drift.RiskLevel != "medium" ||
+		drift.CorrelationID != "incident-1" {
+		t.Errorf("Unexpected replica drift %+v", drift)
+	}
+	if image := space.PendingChanges[2]; image.CostDelta != 0 || len(image.RiskFactors) != 1 {
+		t.Errorf("Expected image drift to be listed without a cost, got %+v", image)
+	}
+	if space.ProjectedCost != 190 {
+		t.Errorf("Expected projected cost 100 + 30 + 60, got %v", space.ProjectedCost)
+	}
+
+	// Once fixed, the drift goes and the other changes stay
+	m.applyDrift(driftstream.Snapshot{Space: "acme-prod", DetectedAt: now})
+	if len(space.PendingChanges) != 1 || space.ProjectedCost != 130 {
+		t.Errorf("Expected only the create left, got %+v (projected %v)", space.PendingChanges, space.ProjectedCost)
+	}
+
+	// Drift in other spaces is ignored
+	m.applyDrift(driftstream.Snapshot{Space: "elsewhere", DetectedAt: now, Items: []driftstream.Item{{Field: "spec.replicas", Expected: "1", Actual: "9"}}})
+	if len(space.Drift) != 0 {
+		t.Errorf("Unexpected drift %+v", space.Drift)
+	}
+}
+
+func TestApplyDriftEvent(t *testing.T) {
+	id := uuid.New()
+	space := &SpaceMonitor{SpaceID: id, SpaceName: "acme-prod"}
+	m := &CostImpactMonitor{monitoredSpaces: map[uuid.UUID]*SpaceMonitor{id: space}}
+	now := time.Now()
+
+	m.applyDriftEvent(events.Event{Type: events.DriftDetected, Space: "acme-prod", Time: now, CorrelationID: "incident-2",
+		Data: json.RawMessage(`{"space":"acme-prod","items":[{"unit_slug":"web","field":"spec.replicas","expected":"1","actual":"3"}]}`)})
+	if len(space.Drift) != 1 || space.Drift[0].UnitName != "web" || !space.Drift[0].AnalysisTime.Equal(now) ||
+		space.Drift[0].CorrelationID != "incident-2" {
+		t.Fatalf("Expected the event's drift, got %+v", space.Drift)
+	}
+
+	m.applyDriftEvent(events.Event{Type: events.DriftResolved, Space: "acme-prod", Time: now})
+	if len(space.Drift) != 0 {
+		t.Errorf("Expected drift.resolved to clear the drift, got %+v", space.Drift)
+	}
+}
+
+func TestHighCostDrift(t *testing.T) {
+	costly := highCostDrift([]PendingChange{
+		{UnitName: "backend-api", CostDelta: 150},
+		{UnitName: "web", CostDelta: 60},
+		{UnitName: "cache", CostDelta: -200},
+	})
+	if len(costly) != 1 || costly[0].UnitName != "backend-api" {
+		t.Errorf("high cost drift = %v, want [backend-api]", costly)
+	}
+}
+
+func TestActiveDriftExpires(t *testing.T) {
+	now := time.Now()
+	space := &SpaceMonitor{Drift: []PendingChange{
+		{UnitName: "recent", AnalysisTime: now.Add(-time.Minute)},
+		{UnitName: "stale", AnalysisTime: now.Add(-2 * driftTTL)},
+	}}
+	if changes := activeDrift(space, now); len(changes) != 1 || changes[0].UnitName != "recent" {
+		t.Errorf("active drift = %v, want [recent]", changes)
+	}
+}
@@ -0,0 +1,539 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	sdk "github.com/monadic/devops-sdk"
+	"gopkg.in/yaml.v3"
+)
+
+// EscalationConfigFile is the YAML document listing per-environment escalation policies
+type EscalationConfigFile struct {
+	Policies []EscalationPolicy `yaml:"policies"`
+}
+
+// EscalationPolicy decides how far a risky change escalates in one environment.
+// Each threshold is the lowest risk level that reaches the stage; empty disables it.
+// Timers move a change to the next stage when nobody acts on it.
+type EscalationPolicy struct {
+	Environment     string        `yaml:"environment"` // unit "env" label, or "default"
+	NotifyAt        string        `yaml:"notify_at"`
+	ApproveAt       string        `yaml:"approve_at"`
+	BlockAt         string        `yaml:"block_at"`
+	NotifyTimeout   time.Duration `yaml:"notify_timeout"`   // unacknowledged notifications then require approval
+	ApprovalTimeout time.Duration `yaml:"approval_timeout"` // unapproved changes are then blocked
+}
+
+// Escalation tracks one pending change through notify → approval → block
+type Escalation struct {
+	UnitID       string     `json:"unit_id"`
+	UnitName     string     `json:"unit_name"`
+	SpaceID      string     `json:"space_id,omitempty"`
+	Environment  string     `json:"environment"`
+	Revision     int64      `json:"revision"`
+	RiskLevel    string     `json:"risk_level"`
+	CostDelta    float64    `json:"cost_delta"`
+	Stage        string     `json:"stage"` // "notify", "approval", "block", "approved"
+	StartedAt    time.Time  `json:"started_at"`
+	StageSince   time.Time  `json:"stage_since"`
+	Deadline     *time.Time `json:"deadline,omitempty"` // when the change escalates if nobody acts
+	Acknowledged bool       `json:"acknowledged"`
+	ApprovedBy   string     `json:"approved_by,omitempty"`
+	Note         string     `json:"note,omitempty"`
+}
+
+// EscalationEngine applies escalation policies to pending changes and runs their timers
+type EscalationEngine struct {
+	policies map[string]EscalationPolicy
+	active   map[string]*Escalation
+	notify   func(esc Escalation, previous string)
+	mu       sync.Mutex
+}
+
+// stageRank orders escalation stages; "approved" ends an escalation
+func stageRank(stage string) int {
+	switch stage {
+	case "notify":
+		return 1
+	case "approval":
+		return 2
+	case "block":
+		return 3
+	default:
+		return 0
+	}
+}
+
+// defaultEscalationPolicies reproduce the built-in behaviour: production changes
+// always need approval and critical increases are blocked everywhere
+func defaultEscalationPolicies() []EscalationPolicy {
+	return []EscalationPolicy{
+		{
+			Environment:     "default",
+			NotifyAt:        "medium",
+			ApproveAt:       "high",
+			BlockAt:         "critical",
+			ApprovalTimeout: 24 * time.Hour,
+		},
+		{
+			Environment:     "production",
+			NotifyAt:        "low",
+			ApproveAt:       "medium",
+			BlockAt:         "critical",
+			ApprovalTimeout: 4 * time.Hour,
+		},
+	}
+}
+
+// LoadEscalationPolicies reads and validates an escalation policy file.
+// A missing file yields the default policies.
+func LoadEscalationPolicies(path string) ([]EscalationPolicy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultEscalationPolicies(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read escalation config: %w", err)
+	}
+
+	var file EscalationConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse escalation config: %w", err)
+	}
+
+	for i := range file.Policies {
+		if err := file.Policies[i].validate(); err != nil {
+			return nil, fmt.Errorf("policy %d (%s): %w", i, file.Policies[i].Environment, err)
+		}
+	}
+
+	return file.Policies, nil
+}
+
+// validate checks risk levels and that the thresholds escalate in order
+func (p *EscalationPolicy) validate() error {
+	if p.Environment == "" {
+		return fmt.Errorf("environment is required")
+	}
+
+	last := -1
+	for _, threshold := range []struct{ name, level string }{
+		{"notify_at", p.NotifyAt},
+		{"approve_at", p.ApproveAt},
+		{"block_at", p.BlockAt},
+	} {
+		if threshold.level == "" {
+			continue
+		}
+		if threshold.level != "low" && riskRank(threshold.level) == 0 {
+			return fmt.Errorf("%s: unknown risk level %q", threshold.name, threshold.level)
+		}
+		if riskRank(threshold.level) < last {
+			return fmt.Errorf("%s must not be below earlier stages", threshold.name)
+		}
+		last = riskRank(threshold.level)
+	}
+
+	if p.NotifyTimeout < 0 || p.ApprovalTimeout < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	return nil
+}
+
+// stageFor returns the stage a change at this risk level starts in
+func (p EscalationPolicy) stageFor(risk string) string {
+	reaches := func(threshold string) bool {
+		return threshold != "" && riskRank(risk) >= riskRank(threshold)
+	}
+
+	switch {
+	case reaches(p.BlockAt):
+		return "block"
+	case reaches(p.ApproveAt):
+		return "approval"
+	case reaches(p.NotifyAt):
+		return "notify"
+	default:
+		return ""
+	}
+}
+
+// timeoutFor returns how long a change may sit in a stage, zero meaning forever
+func (p EscalationPolicy) timeoutFor(stage string) time.Duration {
+	switch stage {
+	case "notify":
+		return p.NotifyTimeout
+	case "approval":
+		return p.ApprovalTimeout
+	default:
+		return 0
+	}
+}
+
+// NewEscalationEngine creates an engine; notify is called on every stage change
+func NewEscalationEngine(policies []EscalationPolicy, notify func(esc Escalation, previous string)) *EscalationEngine {
+	e := &EscalationEngine{
+		policies: make(map[string]EscalationPolicy, len(policies)),
+		active:   make(map[string]*Escalation),
+		notify:   notify,
+	}
+	for _, p := range policies {
+		e.policies[p.Environment] = p
+	}
+	return e
+}
+
+// policy returns the policy for an environment, falling back to "default"
+func (e *EscalationEngine) policy(env string) EscalationPolicy {
+	if p, ok := e.policies[env]; ok {
+		return p
+	}
+	return e.policies["default"]
+}
+
+// Evaluate escalates a pending change according to its environment's policy and
+// fills in the assessment's stage, recommendation and auto-approval. Re-evaluating
+// the same revision never lowers its stage or discards an approval.
+func (e *EscalationEngine) Evaluate(unit *sdk.Unit, assessment *RiskAssessment, costDelta float64, now time.Time) {
+	env := unit.Labels["env"]
+	policy := e.policy(env)
+	stage := policy.stageFor(assessment.Level)
+	key := unit.UnitID.String()
+
+	e.mu.Lock()
+	esc, exists := e.active[key]
+	if exists && esc.Revision != unit.HeadRevisionNum {
+		delete(e.active, key)
+		exists = false
+	}
+
+	previous := ""
+	switch {
+	case !exists && stage != "":
+		esc = &Escalation{
+			UnitID:      key,
+			UnitName:    unit.Slug,
+			SpaceID:     unit.SpaceID.String(),
+			Environment: env,
+			Revision:    unit.HeadRevisionNum,
+			StartedAt:   now,
+		}
+		e.active[key] = esc
+		e.setStage(esc, policy, stage, now)
+	case exists && esc.Stage != "approved" && stageRank(stage) > stageRank(esc.Stage):
+		previous = esc.Stage
+		e.setStage(esc, policy, stage, now)
+	default:
+		stage = ""
+	}
+
+	var snapshot *Escalation
+	if esc != nil {
+		esc.RiskLevel = assessment.Level
+		esc.CostDelta = costDelta
+		copied := *esc
+		snapshot = &copied
+	}
+	e.mu.Unlock()
+
+	applyEscalation(assessment, snapshot)
+	if stage != "" && e.notify != nil {
+		e.notify(*snapshot, previous)
+	}
+}
+
+// Preview fills in the stage a change would start in without recording it
+func (e *EscalationEngine) Preview(unit *sdk.Unit, assessment *RiskAssessment) {
+	stage := e.policy(unit.Labels["env"]).stageFor(assessment.Level)
+	if stage == "" {
+		applyEscalation(assessment, nil)
+		return
+	}
+	applyEscalation(assessment, &Escalation{Stage: stage})
+}
+
+// setStage moves an escalation to stage and restarts its timer. Callers must hold e.mu.
+func (e *EscalationEngine) setStage(esc *Escalation, policy EscalationPolicy, stage string, now time.Time) {
+	esc.Stage = stage
+	esc.StageSince = now
+	esc.Deadline = nil
+	if timeout := policy.timeoutFor(stage); timeout > 0 {
+		deadline := now.Add(timeout)
+		esc.Deadline = &deadline
+	}
+}
+
+// Tick escalates every change whose deadline has passed
+func (e *EscalationEngine) Tick(now time.Time) {
+	type transition struct {
+		esc      Escalation
+		previous string
+	}
+	var fired []transition
+
+	e.mu.Lock()
+	for _, esc := range e.active {
+		if esc.Deadline == nil || now.Before(*esc.Deadline) {
+			continue
+		}
+		previous := esc.Stage
+		next := "block"
+		if previous == "notify" {
+			next = "approval"
+		}
+		e.setStage(esc, e.policy(esc.Environment), next, now)
+		fired = append(fired, transition{*esc, previous})
+	}
+	e.mu.Unlock()
+
+	if e.notify == nil {
+		return
+	}
+	for _, t := range fired {
+		e.notify(t.esc, t.previous)
+	}
+}
+
+// Start runs the escalation timers
+func (e *EscalationEngine) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		e.Tick(now)
+	}
+}
+
+// find looks up an active escalation by unit ID or slug. Callers must hold e.mu.
+func (e *EscalationEngine) find(ref string) (*Escalation, bool) {
+	if esc, ok := e.active[ref]; ok {
+		return esc, true
+	}
+	for _, esc := range e.active {
+		if esc.UnitName == ref {
+			return esc, true
+		}
+	}
+	return nil, false
+}
+
+// Get returns the active escalation of a unit, by ID or slug
+func (e *EscalationEngine) Get(ref string) (Escalation, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	esc, ok := e.find(ref)
+	if !ok {
+		return Escalation{}, false
+	}
+	return *esc, true
+}
+
+// Approve releases a change waiting for approval or blocked
+func (e *EscalationEngine) Approve(ref, approver, note string, now time.Time) (Escalation, error) {
+	e.mu.Lock()
+	esc, ok := e.find(ref)
+	if !ok {
+		e.mu.Unlock()
+		return Escalation{}, fmt.Errorf("no escalation for %s", ref)
+	}
+	if esc.Stage != "approval" && esc.Stage != "block" {
+		e.mu.Unlock()
+		return *esc, fmt.Errorf("%s is in stage %s and needs no approval", esc.UnitName, esc.Stage)
+	}
+
+	previous := esc.Stage
+	esc.Stage = "approved"
+	esc.StageSince = now
+	esc.Deadline = nil
+	esc.ApprovedBy = approver
+	esc.Note = note
+	approved := *esc
+	e.mu.Unlock()
+
+	if e.notify != nil {
+		e.notify(approved, previous)
+	}
+	return approved, nil
+}
+
+// Acknowledge stops a notification from escalating further
+func (e *EscalationEngine) Acknowledge(ref string) (Escalation, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	esc, ok := e.find(ref)
+	if !ok {
+		return Escalation{}, fmt.Errorf("no escalation for %s", ref)
+	}
+	if esc.Stage != "notify" {
+		return *esc, fmt.Errorf("%s is in stage %s; approve it instead", esc.UnitName, esc.Stage)
+	}
+
+	esc.Acknowledged = true
+	esc.Deadline = nil
+	return *esc, nil
+}
+
+// Resolve forgets a unit's escalation once it has been applied or deleted
+func (e *EscalationEngine) Resolve(unitID string) {
+	e.mu.Lock()
+	delete(e.active, unitID)
+	e.mu.Unlock()
+}
+
+// List returns the active escalations, most severe first
+func (e *EscalationEngine) List() []Escalation {
+	e.mu.Lock()
+	all := make([]Escalation, 0, len(e.active))
+	for _, esc := range e.active {
+		all = append(all, *esc)
+	}
+	e.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if stageRank(all[i].Stage) != stageRank(all[j].Stage) {
+			return stageRank(all[i].Stage) > stageRank(all[j].Stage)
+		}
+		return all[i].StartedAt.Before(all[j].StartedAt)
+	})
+	return all
+}
+
+// applyEscalation derives the recommendation and auto-approval from an escalation
+func applyEscalation(assessment *RiskAssessment, esc *Escalation) {
+	if esc == nil {
+		assessment.Stage = ""
+		assessment.Recommendation = "Safe to deploy"
+		assessment.AutoApprove = true
+		return
+	}
+
+	assessment.Stage = esc.Stage
+	assessment.Deadline = esc.Deadline
+	switch esc.Stage {
+	case "notify":
+		assessment.Recommendation = "Safe to deploy - owners notified of the cost change"
+		assessment.AutoApprove = true
+	case "approval":
+		assessment.Recommendation = "Requires approval before deployment"
+		if esc.Deadline != nil {
+			assessment.Recommendation += " - blocked after " + esc.Deadline.Format(time.RFC3339)
+		}
+		assessment.AutoApprove = false
+	case "block":
+		assessment.Recommendation = "DO NOT DEPLOY - blocked until explicitly approved"
+		assessment.AutoApprove = false
+	case "approved":
+		assessment.Recommendation = "Approved by " + esc.ApprovedBy
+		assessment.AutoApprove = true
+	}
+}
+
+// onEscalation reports stage changes; only the leader notifies
+func (m *CostImpactMonitor) onEscalation(esc Escalation, previous string) {
+	if !m.leader.IsLeader() {
+		return
+	}
+
+	if previous == "" {
+		slog.Warn("Change escalated", logging.Unit(esc.UnitName), "stage", esc.Stage,
+			"environment", esc.Environment, "risk", esc.RiskLevel, "cost_delta", esc.CostDelta)
+	} else {
+		slog.Warn("Escalation stage changed", logging.Unit(esc.UnitName), "from", previous, "stage", esc.Stage)
+	}
+
+	if m.dashboard != nil {
+		m.dashboard.events.Publish("escalation", esc)
+	}
+	m.queueEscalation(esc)
+}
+
+// handleEscalations lists escalations (GET /api/v1/escalations) and approves or
+// acknowledges them (POST /api/v1/escalations/{unit}/approve|acknowledge)
+func (d *MonitorDashboard) handleEscalations(w http.ResponseWriter, r *http.Request) {
+	engine := d.monitor.escalations
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, api.Prefix+"/escalations"), "/"), "/")
+
+	if parts[0] == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		team := tenants.FromContext(r.Context())
+		escalations := make([]Escalation, 0)
+		for _, esc := range engine.List() {
+			if d.monitor.spaceVisible(team, esc.SpaceID, "") {
+				escalations = append(escalations, esc)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(EscalationList{Escalations: escalations}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if esc, ok := engine.Get(parts[0]); ok && !d.monitor.spaceVisible(tenants.FromContext(r.Context()), esc.SpaceID, "") {
+		tenants.Forbidden(w)
+		return
+	}
+
+	var esc Escalation
+	var err error
+	switch parts[1] {
+	case "approve":
+		var req pending.Approval
+		decodeErr := json.NewDecoder(r.Body).Decode(&req)
+		if user := auth.FromContext(r.Context()); user != nil {
+			req.Approver = user.Email // signed-in users approve as themselves
+		}
+		if decodeErr != nil || req.Approver == "" {
+			http.Error(w, "approver is required", http.StatusBadRequest)
+			return
+		}
+		esc, err = engine.Approve(parts[0], req.Approver, req.Note, time.Now())
+		d.monitor.audit.Record(audit.WithActor(r.Context(), req.Approver), audit.ApprovalGranted, parts[0], req, err)
+	case "acknowledge":
+		esc, err = engine.Acknowledge(parts[0])
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		status := http.StatusConflict
+		if esc.UnitID == "" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(esc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
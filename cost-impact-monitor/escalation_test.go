@@ -0,0 +1,229 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/policy"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func newEscalationTestUnit(env string, revision int64) *sdk.Unit {
+	return &sdk.Unit{
+		UnitID:          uuid.MustParse("6f1c2a52-8d0e-4a63-9a55-1f1d3c0d2b10"),
+		Slug:            "api",
+		Labels:          map[string]string{"env": env},
+		HeadRevisionNum: revision,
+	}
+}
+
+func TestEscalationStagesByEnvironment(t *testing.T) {
+	engine := NewEscalationEngine(defaultEscalationPolicies(), nil)
+
+	tests := []struct {
+		env       string
+		risk      string
+		wantStage string
+		wantAuto  bool
+	}{
+		{"dev", "low", "", true},
+		{"dev", "medium", "notify", true},
+		{"dev", "high", "approval", false},
+		{"dev", "critical", "block", false},
+		{"production", "low", "notify", true},
+		{"production", "medium", "approval", false},
+	}
+
+	for i, tt := range tests {
+		unit := newEscalationTestUnit(tt.env, int64(i+1))
+		assessment := RiskAssessment{Level: tt.risk}
+		engine.Evaluate(unit, &assessment, 100, time.Now())
+
+		if assessment.Stage != tt.wantStage || assessment.AutoApprove != tt.wantAuto {
+			t.Errorf("%s/%s: stage %q auto %v, want %q auto %v",
+				tt.env, tt.risk, assessment.Stage, assessment.AutoApprove, tt.wantStage, tt.wantAuto)
+		}
+		if assessment.Recommendation == "" {
+			t.Errorf("%s/%s: empty recommendation", tt.env, tt.risk)
+		}
+	}
+}
+
+func TestEscalationTimersAndApproval(t *testing.T) {
+	var transitions []string
+	engine := NewEscalationEngine([]EscalationPolicy{{
+		Environment:     "default",
+		NotifyAt:        "medium",
+		ApproveAt:       "high",
+		NotifyTimeout:   time.Hour,
+		ApprovalTimeout: 2 * time.Hour,
+	}}, func(esc Escalation, previous string) {
+		transitions = append(transitions, previous+">"+esc.Stage)
+	})
+
+	start := time.Date(2024, 11, 1, 9, 0, 0, 0, time.UTC)
+	unit := newEscalationTestUnit("staging", 7)
+	assessment := RiskAssessment{Level: "medium"}
+	engine.Evaluate(unit, &assessment, 80, start)
+
+	// Re-evaluating the same revision at the same risk doesn't restart anything
+	engine.Evaluate(unit, &assessment, 80, start.Add(30*time.Minute))
+
+	engine.Tick(start.Add(59 * time.Minute))
+	engine.Tick(start.Add(time.Hour))
+	engine.Tick(start.Add(3 * time.Hour))
+
+	if got := engine.List()[0].Stage; got != "block" {
+		t.Fatalf("stage = %q, want block", got)
+	}
+
+	esc, err := engine.Approve("api", "alice", "budget approved", start.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if esc.Stage != "approved" || esc.ApprovedBy != "alice" || esc.Deadline != nil {
+		t.Errorf("approved escalation = %+v", esc)
+	}
+
+	// An approval survives re-evaluation of the same revision...
+	assessment = RiskAssessment{Level: "high"}
+	engine.Evaluate(unit, &assessment, 250, start.Add(5*time.Hour))
+	if assessment.Stage != "approved" || !assessment.AutoApprove {
+		t.Errorf("same revision: stage %q auto %v, want approved", assessment.Stage, assessment.AutoApprove)
+	}
+
+	// ...but not a new revision
+	engine.Evaluate(newEscalationTestUnit("staging", 8), &assessment, 250, start.Add(6*time.Hour))
+	if assessment.Stage != "approval" {
+		t.Errorf("new revision: stage %q, want approval", assessment.Stage)
+	}
+
+	want := []string{">notify", "notify>approval", "approval>block", "block>approved", ">approval"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition %d = %s, want %s", i, transitions[i], want[i])
+		}
+	}
+}
+
+func TestEscalationAcknowledgeStopsTimer(t *testing.T) {
+	engine := NewEscalationEngine([]EscalationPolicy{{
+		Environment: "default", NotifyAt: "low", NotifyTimeout: time.Minute,
+	}}, nil)
+
+	now := time.Now()
+	assessment := RiskAssessment{Level: "low"}
+	engine.Evaluate(newEscalationTestUnit("dev", 1), &assessment, 10, now)
+
+	if _, err := engine.Approve("api", "bob", "", now); err == nil {
+		t.Error("expected approving a notification to fail")
+	}
+	if _, err := engine.Acknowledge("api"); err != nil {
+		t.Fatalf("Acknowledge: %v", err)
+	}
+
+	engine.Tick(now.Add(time.Hour))
+	if got := engine.List()[0].Stage; got != "notify" {
+		t.Errorf("acknowledged notification escalated to %s", got)
+	}
+}
+
+func TestLoadEscalationPolicies(t *testing.T) {
+	policies, err := LoadEscalationPolicies(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || len(policies) != len(defaultEscalationPolicies()) {
+		t.Fatalf("missing file: %d policies, err %v; want defaults", len(policies), err)
+	}
+
+	tests := map[string]bool{
+		"policies: [{environment: prod, notify_at: low, approve_at: medium, approval_timeout: 2h}]": true,
+		"policies: [{notify_at: low}]":                                         false,
+		"policies: [{environment: prod, block_at: severe}]":                    false,
+		"policies: [{environment: prod, notify_at: high, approve_at: medium}]": false,
+	}
+
+	for config, valid := range tests {
+		path := filepath.Join(t.TempDir(), "escalation.yaml")
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadEscalationPolicies(path)
+		if (err == nil) != valid {
+			t.Errorf("%s: err = %v, want valid=%v", config, err, valid)
+		}
+	}
+}
+
+func TestApprovalIsAudited(t *testing.T) {
+	engine := NewEscalationEngine([]EscalationPolicy{{Environment: "default", ApproveAt: "medium"}}, nil)
+	engine.Evaluate(newEscalationTestUnit("prod", 1), &RiskAssessment{Level: "high"}, 120, time.Now())
+	d := &MonitorDashboard{monitor: &CostImpactMonitor{escalations: engine, audit: audit.New("cost-impact-monitor", nil, nil)}}
+
+	for _, want := range []int{http.StatusOK, http.StatusConflict} {
+		rec := httptest.NewRecorder()
+		d.handleEscalations(rec, httptest.NewRequest(http.MethodPost, "/api/v1/escalations/api/approve",
+			strings.NewReader(`{"approver": "alice", "note": "budgeted"}`)))
+		if rec.Code != want {
+			t.Fatalf("approve = %d, want %d: %s", rec.Code, want, rec.Body)
+		}
+	}
+
+	entries, _, _ := d.monitor.audit.Entries(context.Background(), audit.Query{Action: audit.ApprovalGranted})
+	if len(entries) != 2 || entries[1].Actor != "alice" || entries[1].Target != "api" || entries[1].Result != "ok" || entries[0].Result != "error" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
+
+func TestPolicyHoldsBackEscalatedChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(path, []byte(`
+rules:
+  - {name: costly-production, kinds: [deployment], environments: [production], cost_above: 100, decision: approve}
+  - {name: frozen, kinds: [deployment], environments: [frozen], decision: deny}
+default: allow
+`), 0o644)
+	pol, err := policy.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &CostImpactMonitor{policy: pol, audit: audit.New("cost-impact-monitor", nil, nil)}
+
+	tests := []struct {
+		env      string
+		delta    float64
+		stage    string
+		wantAuto bool
+	}{
+		{"dev", 300, "", true},
+		{"production", 40, "notify", true},
+		{"production", 150, "notify", false},
+		{"frozen", 1, "", false},
+		{"frozen", 1, "approved", true}, // approved by hand
+	}
+	for _, tt := range tests {
+		assessment := RiskAssessment{Stage: tt.stage}
+		applyEscalation(&assessment, nil)
+		if tt.stage != "" {
+			applyEscalation(&assessment, &Escalation{Stage: tt.stage, ApprovedBy: "alice"})
+		}
+		m.applyPolicy(newEscalationTestUnit(tt.env, 1), &assessment, tt.delta, false)
+		if assessment.AutoApprove != tt.wantAuto {
+			t.Errorf("%s %+.0f: assessment = %+v, want auto-approve %v", tt.env, tt.delta, assessment, tt.wantAuto)
+		}
+	}
+
+	entries, _, _ := m.audit.Entries(context.Background(), audit.Query{Action: audit.PolicyDecided})
+	if len(entries) != 4 {
+		t.Errorf("audit entries = %d, want a decision for each change not approved by hand", len(entries))
+	}
+}
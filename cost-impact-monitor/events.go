@@ -0,0 +1,158 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// sseEvent is a single server-sent event ready to be written to a client
+type sseEvent struct {
+	Name string
+	Data []byte
+}
+
+// EventBroker fans dashboard updates out to server-sent-event clients.
+// Publishes are coalesced per event name and flushed on a fixed interval,
+// so a burst of analyses produces one update per interval instead of one
+// write per change to every connected browser.
+type EventBroker struct {
+	interval time.Duration
+	clients  map[chan sseEvent]struct{}
+	pending  map[string][]byte
+	order    []string
+	mu       sync.Mutex
+}
+
+// NewEventBroker creates a broker that flushes at most once per interval
+func NewEventBroker(interval time.Duration) *EventBroker {
+	return &EventBroker{
+		interval: interval,
+		clients:  make(map[chan sseEvent]struct{}),
+		pending:  make(map[string][]byte),
+	}
+}
+
+// Start flushes coalesced events to clients until the process exits
+func (b *EventBroker) Start() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		b.flush()
+	}
+}
+
+// Publish queues an event; a newer event with the same name replaces it
+func (b *EventBroker) Publish(name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Warn("Failed to encode event", "event", name, logging.Err(err))
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, queued := b.pending[name]; !queued {
+		b.order = append(b.order, name)
+	}
+	b.pending[name] = data
+}
+
+// flush sends queued events to every client, dropping them for clients
+// that are too slow to keep up (they catch up on the next snapshot)
+func (b *EventBroker) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, name := range b.order {
+		event := sseEvent{Name: name, Data: b.pending[name]}
+		for ch := range b.clients {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+
+	b.pending = make(map[string][]byte)
+	b.order = nil
+}
+
+func (b *EventBroker) subscribe() chan sseEvent {
+	ch := make(chan sseEvent, 16)
+	b.mu.Lock()
+	b.clients[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *EventBroker) unsubscribe(ch chan sseEvent) {
+	b.mu.Lock()
+	delete(b.clients, ch)
+	b.mu.Unlock()
+}
+
+// ServeHTTP streams events to a client; initial is sent immediately so the
+// page renders without waiting for the next flush
+func (b *EventBroker) ServeHTTP(w http.ResponseWriter, r *http.Request, initial map[string]interface{}) {
+	b.ServeFiltered(w, r, initial, nil)
+}
+
+// ServeFiltered streams events through filter, which may rewrite an event or
+// drop it by returning false; a nil filter passes every event
+func (b *EventBroker) ServeFiltered(w http.ResponseWriter, r *http.Request, initial map[string]interface{}, filter func(sseEvent) (sseEvent, bool)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	for name, v := range initial {
+		data, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		writeEvent(w, sseEvent{Name: name, Data: data})
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-ch:
+			if filter != nil {
+				var ok bool
+				if event, ok = filter(event); !ok {
+					continue
+				}
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event sseEvent) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data)
+}
@@ -0,0 +1,49 @@
+package costimpactmonitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventBrokerCoalescesPublishes(t *testing.T) {
+	b := NewEventBroker(time.Hour)
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	b.Publish("snapshot", map[string]int{"pending_changes": 1})
+	b.Publish("snapshot", map[string]int{"pending_changes": 2})
+	b.Publish("history", map[string]int{"total": 3})
+	b.flush()
+
+	if got := len(ch); got != 2 {
+		t.Fatalf("received %d events, want 2", got)
+	}
+
+	first := <-ch
+	if first.Name != "snapshot" || string(first.Data) != `{"pending_changes":2}` {
+		t.Errorf("first event = %s %s, want latest snapshot", first.Name, first.Data)
+	}
+	if second := <-ch; second.Name != "history" {
+		t.Errorf("second event = %s, want history", second.Name)
+	}
+
+	b.flush()
+	if got := len(ch); got != 0 {
+		t.Errorf("flush without publishes sent %d events", got)
+	}
+}
+
+func TestEventBrokerDropsForSlowClients(t *testing.T) {
+	b := NewEventBroker(time.Hour)
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	for i := 0; i < cap(ch)+5; i++ {
+		b.Publish("snapshot", i)
+		b.flush()
+	}
+
+	if got := len(ch); got != cap(ch) {
+		t.Errorf("buffered %d events, want %d", got, cap(ch))
+	}
+}
@@ -0,0 +1,119 @@
+package costimpactmonitor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/tenants"
+)
+
+// handlePendingExport downloads the pending changes as CSV (/api/v1/pending/export),
+// accepting the same filters as /api/v1/pending
+func (d *MonitorDashboard) handlePendingExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := parseListQuery(r.URL.Query(), pendingSortKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows := [][]string{{
+		"space_name", "space_id", "unit_name", "change_type", "current_cost",
+		"projected_cost", "cost_delta", "risk_level", "analysis_time", "claude_assessment",
+	}}
+	for _, change := range filterPending(d.pendingChanges(tenants.FromContext(r.Context())), q) {
+		spaceID := ""
+		if id, ok := change["space_id"]; ok {
+			spaceID = fmt.Sprint(id)
+		}
+		assessment, _ := change["claude_assessment"].(string)
+		rows = append(rows, []string{
+			csvText(change["space_name"].(string)),
+			spaceID,
+			csvText(change["unit_name"].(string)),
+			change["change_type"].(string),
+			csvMoney(change["current_cost"].(float64)),
+			csvMoney(change["projected_cost"].(float64)),
+			csvMoney(change["cost_delta"].(float64)),
+			change["risk_level"].(string),
+			csvTime(change["analysis_time"].(time.Time)),
+			csvText(assessment),
+		})
+	}
+
+	writeCSV(w, "pending-changes", rows)
+}
+
+// handleHistoryExport downloads the deployment history as CSV, newest first (/api/v1/history/export)
+func (d *MonitorDashboard) handleHistoryExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	history := d.monitor.historyFor(tenants.FromContext(r.Context()))
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].DeployTime.After(history[j].DeployTime)
+	})
+
+	rows := [][]string{{
+		"deploy_time", "space_name", "unit_id", "unit_name", "target", "change_type",
+		"predicted_cost", "actual_cost", "variance_percent", "accurate",
+	}}
+	for _, record := range history {
+		rows = append(rows, []string{
+			csvTime(record.DeployTime),
+			csvText(record.SpaceName),
+			record.UnitID,
+			csvText(record.UnitName),
+			csvText(record.Target),
+			record.ChangeType,
+			csvMoney(record.PredictedCost),
+			csvMoney(record.ActualCost),
+			strconv.FormatFloat(record.Variance, 'f', 1, 64),
+			strconv.FormatBool(record.Accurate),
+		})
+	}
+
+	writeCSV(w, "deployment-history", rows)
+}
+
+// writeCSV sends rows as a CSV attachment named <name>-<date>.csv
+func writeCSV(w http.ResponseWriter, name string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="%s-%s.csv"`, name, time.Now().Format("20060102")))
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func csvMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// csvText keeps free text (unit names, Claude assessments) from being
+// evaluated as a formula when the file is opened in a spreadsheet
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package costimpactmonitor
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCSVExports(t *testing.T) {
+	id := uuid.New()
+	deployed := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	space := &SpaceMonitor{
+		SpaceID:   id,
+		SpaceName: "prod",
+		PendingChanges: []PendingChange{
+			{UnitName: "cache", ChangeType: "update", CostDelta: -5, RiskLevel: "low"},
+			{UnitName: "=HYPERLINK(\"x\")", ChangeType: "create", ProjectedCost: 250.5, CostDelta: 250.5,
+				RiskLevel: "high", ClaudeAssessment: "Scale down, then retry"},
+		},
+		DeploymentHistory: []DeploymentCostRecord{
+			{UnitID: "u1", UnitName: "api", SpaceName: "prod", ChangeType: "update", DeployTime: deployed,
+				PredictedCost: 100, ActualCost: 108, Variance: 8, Accurate: true},
+		},
+	}
+	d := &MonitorDashboard{monitor: &CostImpactMonitor{monitoredSpaces: map[uuid.UUID]*SpaceMonitor{id: space}}}
+
+	rec := httptest.NewRecorder()
+	d.handlePendingExport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/pending/export", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("content type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="pending-changes-`) {
+		t.Errorf("content disposition = %q", cd)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse pending CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "space_name" {
+		t.Fatalf("pending rows = %q", rows)
+	}
+	// High risk first, formula neutralized
+	if rows[1][2] != `'=HYPERLINK("x")` || rows[1][5] != "250.50" || rows[1][3] != "create" {
+		t.Errorf("first change = %q", rows[1])
+	}
+	if rows[2][6] != "-5.00" || rows[2][1] != id.String() {
+		t.Errorf("second change = %q", rows[2])
+	}
+
+	rec = httptest.NewRecorder()
+	d.handleHistoryExport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history/export", nil))
+	rows, err = csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse history CSV: %v", err)
+	}
+	want := []string{"2026-10-01T09:30:00Z", "prod", "u1", "api", "", "update", "100.00", "108.00", "8.0", "true"}
+	if len(rows) != 2 || strings.Join(rows[1], ",") != strings.Join(want, ",") {
+		t.Errorf("history rows = %q", rows)
+	}
+}
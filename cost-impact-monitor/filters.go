@@ -0,0 +1,206 @@
+package costimpactmonitor
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listQuery holds the filtering and sorting parameters accepted by
+// /api/v1/pending and /api/v1/spaces:
+//
+//	risk=high,critical   only these risk levels (spaces: with a pending change at one of them)
+//	min_delta=50         cost delta of at least $50/month (spaces: projected minus current)
+//	space=prod           space name contains "prod" (case-insensitive)
+//	sort=cost_delta      sort key, see pendingSortKeys and spaceSortKeys
+//	order=asc|desc       sort direction (default: desc, asc for names)
+//	limit=20             return at most 20 entries
+type listQuery struct {
+	Risk     map[string]bool
+	MinDelta *float64
+	Space    string
+	Sort     string
+	Asc      bool
+	Limit    int
+}
+
+// pendingSortKeys are the sort keys of /api/v1/pending; "risk" sorts by risk then delta
+var pendingSortKeys = []string{"risk", "cost_delta", "projected_cost", "current_cost", "analysis_time", "unit_name", "space_name"}
+
+// spaceSortKeys are the sort keys of /api/v1/spaces
+var spaceSortKeys = []string{"projected_cost", "current_cost", "cost_delta", "pending", "trend", "space_name"}
+
+// parseListQuery validates the list parameters; sortKeys[0] is the default sort
+func parseListQuery(values url.Values, sortKeys []string) (listQuery, error) {
+	q := listQuery{Sort: sortKeys[0]}
+
+	if risk := values.Get("risk"); risk != "" {
+		q.Risk = make(map[string]bool)
+		for _, level := range strings.Split(risk, ",") {
+			level = strings.ToLower(strings.TrimSpace(level))
+			if level != "low" && riskRank(level) == 0 {
+				return q, fmt.Errorf("invalid risk %q (critical, high, medium or low)", level)
+			}
+			q.Risk[level] = true
+		}
+	}
+
+	if v := values.Get("min_delta"); v != "" {
+		delta, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return q, fmt.Errorf("invalid min_delta %q", v)
+		}
+		q.MinDelta = &delta
+	}
+
+	q.Space = strings.ToLower(strings.TrimSpace(values.Get("space")))
+
+	if key := values.Get("sort"); key != "" {
+		valid := false
+		for _, k := range sortKeys {
+			valid = valid || k == key
+		}
+		if !valid {
+			return q, fmt.Errorf("invalid sort %q (one of %s)", key, strings.Join(sortKeys, ", "))
+		}
+		q.Sort = key
+	}
+
+	switch order := values.Get("order"); order {
+	case "":
+		q.Asc = strings.HasSuffix(q.Sort, "_name")
+	case "asc", "desc":
+		q.Asc = order == "asc"
+	default:
+		return q, fmt.Errorf("invalid order %q (asc or desc)", order)
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return q, fmt.Errorf("invalid limit %q", v)
+		}
+		q.Limit = limit
+	}
+
+	return q, nil
+}
+
+// spaceMatches reports whether a space name passes the space filter
+func (q listQuery) spaceMatches(name string) bool {
+	return q.Space == "" || strings.Contains(strings.ToLower(name), q.Space)
+}
+
+// less orders two values in the query's direction
+func (q listQuery) less(a, b float64) bool {
+	if q.Asc {
+		return a < b
+	}
+	return a > b
+}
+
+// filterPending applies q to rows from pendingChanges, which come sorted by risk
+func filterPending(changes []map[string]interface{}, q listQuery) []map[string]interface{} {
+	filtered := make([]map[string]interface{}, 0, len(changes))
+	for _, change := range changes {
+		if q.Risk != nil && !q.Risk[change["risk_level"].(string)] {
+			continue
+		}
+		if q.MinDelta != nil && change["cost_delta"].(float64) < *q.MinDelta {
+			continue
+		}
+		if !q.spaceMatches(change["space_name"].(string)) {
+			continue
+		}
+		filtered = append(filtered, change)
+	}
+
+	switch q.Sort {
+	case "risk":
+		if q.Asc {
+			for i, j := 0, len(filtered)-1; i < j; i, j = i+1, j-1 {
+				filtered[i], filtered[j] = filtered[j], filtered[i]
+			}
+		}
+	case "unit_name", "space_name":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			a, b := filtered[i][q.Sort].(string), filtered[j][q.Sort].(string)
+			if q.Asc {
+				return a < b
+			}
+			return a > b
+		})
+	case "analysis_time":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			a, b := filtered[i]["analysis_time"].(time.Time), filtered[j]["analysis_time"].(time.Time)
+			if q.Asc {
+				return a.Before(b)
+			}
+			return a.After(b)
+		})
+	default:
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return q.less(filtered[i][q.Sort].(float64), filtered[j][q.Sort].(float64))
+		})
+	}
+
+	if q.Limit > 0 && len(filtered) > q.Limit {
+		filtered = filtered[:q.Limit]
+	}
+	return filtered
+}
+
+// filterSpaces applies q to spaces; callers hold the monitor lock
+func filterSpaces(spaces []*SpaceMonitor, q listQuery) []*SpaceMonitor {
+	filtered := make([]*SpaceMonitor, 0, len(spaces))
+	for _, space := range spaces {
+		if !q.spaceMatches(space.SpaceName) {
+			continue
+		}
+		if q.MinDelta != nil && space.ProjectedCost-space.CurrentCost < *q.MinDelta {
+			continue
+		}
+		if q.Risk != nil {
+			atRisk := false
+			for _, change := range space.PendingChanges {
+				atRisk = atRisk || q.Risk[change.RiskLevel]
+			}
+			if !atRisk {
+				continue
+			}
+		}
+		filtered = append(filtered, space)
+	}
+
+	value := func(s *SpaceMonitor) float64 {
+		switch q.Sort {
+		case "current_cost":
+			return s.CurrentCost
+		case "cost_delta":
+			return s.ProjectedCost - s.CurrentCost
+		case "pending":
+			return float64(len(s.PendingChanges))
+		case "trend":
+			return s.CostTrend.WeeklyChange
+		default:
+			return s.ProjectedCost
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if q.Sort == "space_name" {
+			if q.Asc {
+				return filtered[i].SpaceName < filtered[j].SpaceName
+			}
+			return filtered[i].SpaceName > filtered[j].SpaceName
+		}
+		return q.less(value(filtered[i]), value(filtered[j]))
+	})
+
+	if q.Limit > 0 && len(filtered) > q.Limit {
+		filtered = filtered[:q.Limit]
+	}
+	return filtered
+}
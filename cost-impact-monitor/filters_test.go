@@ -0,0 +1,105 @@
+package costimpactmonitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseListQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+		check   func(listQuery) bool
+	}{
+		{"", false, func(q listQuery) bool { return q.Sort == "risk" && !q.Asc && q.Risk == nil && q.MinDelta == nil }},
+		{"risk=High,critical&min_delta=-10&limit=5", false, func(q listQuery) bool {
+			return q.Risk["high"] && q.Risk["critical"] && !q.Risk["low"] && *q.MinDelta == -10 && q.Limit == 5
+		}},
+		{"sort=unit_name", false, func(q listQuery) bool { return q.Asc }},
+		{"sort=cost_delta&order=asc", false, func(q listQuery) bool { return q.Asc }},
+		{"space=%20Prod%20", false, func(q listQuery) bool { return q.Space == "prod" }},
+		{"risk=severe", true, nil},
+		{"min_delta=lots", true, nil},
+		{"sort=pending", true, nil},
+		{"order=up", true, nil},
+		{"limit=0", true, nil},
+	}
+
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		q, err := parseListQuery(values, pendingSortKeys)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if tt.check != nil && !tt.check(q) {
+			t.Errorf("%q: unexpected query %+v", tt.query, q)
+		}
+	}
+}
+
+func TestFilteredLists(t *testing.T) {
+	now := time.Now()
+	prod, staging := uuid.New(), uuid.New()
+	d := &MonitorDashboard{monitor: &CostImpactMonitor{monitoredSpaces: map[uuid.UUID]*SpaceMonitor{
+		prod: {SpaceID: prod, SpaceName: "prod", CurrentCost: 500, ProjectedCost: 800, PendingChanges: []PendingChange{
+			{UnitName: "api", CostDelta: 250, RiskLevel: "high", AnalysisTime: now},
+			{UnitName: "worker", CostDelta: 50, RiskLevel: "low", AnalysisTime: now.Add(-time.Hour)},
+		}},
+		staging: {SpaceID: staging, SpaceName: "staging", CurrentCost: 900, ProjectedCost: 880, PendingChanges: []PendingChange{
+			{UnitName: "cache", CostDelta: -20, RiskLevel: "low", AnalysisTime: now.Add(-2 * time.Hour)},
+		}},
+	}}}
+
+	pending := func(query string) []map[string]interface{} {
+		values, _ := url.ParseQuery(query)
+		q, err := parseListQuery(values, pendingSortKeys)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		return filterPending(d.pendingChanges(nil), q)
+	}
+
+	if got := pending(""); len(got) != 3 || got[0]["unit_name"] != "api" || got[2]["unit_name"] != "cache" {
+		t.Errorf("default order = %v", got)
+	}
+	if got := pending("risk=low&sort=cost_delta&order=asc"); len(got) != 2 || got[0]["unit_name"] != "cache" {
+		t.Errorf("low risk by delta = %v", got)
+	}
+	if got := pending("min_delta=0&space=PROD&limit=1"); len(got) != 1 || got[0]["unit_name"] != "api" {
+		t.Errorf("prod, positive delta, top 1 = %v", got)
+	}
+	if got := pending("sort=analysis_time&order=asc"); got[0]["unit_name"] != "cache" {
+		t.Errorf("oldest first = %v", got)
+	}
+
+	spaces := func(query string) []*SpaceMonitor {
+		values, _ := url.ParseQuery(query)
+		q, err := parseListQuery(values, spaceSortKeys)
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		return filterSpaces([]*SpaceMonitor{d.monitor.monitoredSpaces[staging], d.monitor.monitoredSpaces[prod]}, q)
+	}
+
+	if got := spaces(""); got[0].SpaceName != "staging" {
+		t.Errorf("default order starts with %s", got[0].SpaceName)
+	}
+	if got := spaces("sort=cost_delta"); got[0].SpaceName != "prod" {
+		t.Errorf("by delta starts with %s", got[0].SpaceName)
+	}
+	if got := spaces("risk=high"); len(got) != 1 || got[0].SpaceName != "prod" {
+		t.Errorf("high-risk spaces = %d", len(got))
+	}
+
+	rec := httptest.NewRecorder()
+	d.handlePendingChanges(rec, httptest.NewRequest(http.MethodGet, "/api/v1/pending?sort=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid sort status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,277 @@
+package costimpactmonitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/webhook"
+	sdk "github.com/monadic/devops-sdk"
+	"gopkg.in/yaml.v3"
+)
+
+// HookConfigFile is the YAML document listing user-defined hooks
+type HookConfigFile struct {
+	Hooks []HookConfig `yaml:"hooks"`
+}
+
+// HookConfig describes a single configured hook
+type HookConfig struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`  // "webhook", "command", "slack"
+	Phase   string            `yaml:"phase"` // "pre-apply", "post-apply"
+	When    HookCondition     `yaml:"when"`
+	URL     string            `yaml:"url"`     // webhook and slack
+	Headers map[string]string `yaml:"headers"` // webhook only
+	Secret  string            `yaml:"secret"`  // webhook only: HMAC keys signing the event, ${NAME} expanded, comma separated while rotating
+	Command []string          `yaml:"command"` // command only, event JSON on stdin
+	Timeout time.Duration     `yaml:"timeout"`
+}
+
+// HookCondition limits when a hook fires. Empty fields match everything.
+// For post-apply hooks MinRisk is ignored and MinCostDelta is compared
+// against the measured monthly cost.
+type HookCondition struct {
+	MinRisk      string   `yaml:"min_risk"` // "low", "medium", "high", "critical"
+	Spaces       []string `yaml:"spaces"`
+	MinCostDelta float64  `yaml:"min_cost_delta"`
+}
+
+// HookEvent is the payload delivered to webhook and command hooks
+type HookEvent struct {
+	Hook      string       `json:"hook"`
+	Phase     string       `json:"phase"`
+	Space     string       `json:"space"`
+	Unit      string       `json:"unit"`
+	Impact    *CostImpact  `json:"impact,omitempty"`
+	Actual    *ActualUsage `json:"actual,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// riskRank orders risk levels from least to most severe
+func riskRank(level string) int {
+	switch level {
+	case "medium":
+		return 1
+	case "high":
+		return 2
+	case "critical":
+		return 3
+	default:
+		return 0
+	}
+}
+
+// LoadHookConfig reads and validates a hook configuration file.
+// A missing file yields no hooks.
+func LoadHookConfig(path string) ([]HookConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read hook config: %w", err)
+	}
+
+	var file HookConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse hook config: %w", err)
+	}
+
+	for i := range file.Hooks {
+		if err := file.Hooks[i].validate(); err != nil {
+			return nil, fmt.Errorf("hook %d (%s): %w", i, file.Hooks[i].Name, err)
+		}
+	}
+
+	return file.Hooks, nil
+}
+
+// validate checks required fields and fills in defaults
+func (h *HookConfig) validate() error {
+	if h.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if h.Phase == "" {
+		h.Phase = "pre-apply"
+	}
+	if h.Phase != "pre-apply" && h.Phase != "post-apply" {
+		return fmt.Errorf("unknown phase %q", h.Phase)
+	}
+	if h.Timeout == 0 {
+		h.Timeout = 10 * time.Second
+	}
+
+	switch h.Type {
+	case "webhook", "slack":
+		if h.URL == "" {
+			return fmt.Errorf("%s hook requires url", h.Type)
+		}
+	case "command":
+		if len(h.Command) == 0 {
+			return fmt.Errorf("command hook requires command")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", h.Type)
+	}
+
+	return nil
+}
+
+// Matches reports whether the hook should fire for this space, risk and cost
+func (c HookCondition) Matches(space, risk string, costDelta float64) bool {
+	if c.MinRisk != "" && risk != "" && riskRank(risk) < riskRank(c.MinRisk) {
+		return false
+	}
+	if costDelta < c.MinCostDelta {
+		return false
+	}
+	if len(c.Spaces) == 0 {
+		return true
+	}
+	for _, s := range c.Spaces {
+		if s == space {
+			return true
+		}
+	}
+	return false
+}
+
+// registerConfiguredHooks adds hooks from the YAML registry alongside the built-in ones
+func (m *CostImpactMonitor) registerConfiguredHooks(path string) error {
+	hooks, err := LoadHookConfig(path)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hooks {
+		h := h
+		switch h.Phase {
+		case "pre-apply":
+			m.triggerProcessor.preApplyHooks = append(m.triggerProcessor.preApplyHooks,
+				func(unit *sdk.Unit, impact *CostImpact) error {
+					space := m.spaceName(unit)
+					if !h.When.Matches(space, impact.RiskAssessment.Level, impact.CostDelta) {
+						return nil
+					}
+					return h.fire(HookEvent{
+						Hook: h.Name, Phase: h.Phase, Space: space, Unit: unit.Slug,
+						Impact: impact, Timestamp: time.Now(),
+					})
+				})
+		case "post-apply":
+			m.triggerProcessor.postApplyHooks = append(m.triggerProcessor.postApplyHooks,
+				func(unit *sdk.Unit, actual *ActualUsage) error {
+					space := m.spaceName(unit)
+					if !h.When.Matches(space, "", actual.MonthlyCost) {
+						return nil
+					}
+					return h.fire(HookEvent{
+						Hook: h.Name, Phase: h.Phase, Space: space, Unit: unit.Slug,
+						Actual: actual, Timestamp: time.Now(),
+					})
+				})
+		}
+		slog.Info("Registered hook", "hook", h.Name, "type", h.Type, "phase", h.Phase)
+	}
+
+	return nil
+}
+
+// spaceName returns the slug of the space a unit belongs to
+func (m *CostImpactMonitor) spaceName(unit *sdk.Unit) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if space, exists := m.monitoredSpaces[unit.SpaceID]; exists {
+		return space.SpaceName
+	}
+	return unit.SpaceID.String()
+}
+
+// fire delivers the event using the hook's transport
+func (h HookConfig) fire(event HookEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	switch h.Type {
+	case "webhook":
+		body, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		return h.post(ctx, body)
+
+	case "slack":
+		body, err := json.Marshal(map[string]string{"text": slackText(event)})
+		if err != nil {
+			return fmt.Errorf("encode slack message: %w", err)
+		}
+		return h.post(ctx, body)
+
+	case "command":
+		body, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Env = append(os.Environ(),
+			"HOOK_NAME="+event.Hook,
+			"HOOK_PHASE="+event.Phase,
+			"HOOK_SPACE="+event.Space,
+			"HOOK_UNIT="+event.Unit,
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("hook %s: %w: %s", h.Name, err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("hook %s: unknown type %q", h.Name, h.Type)
+}
+
+// post sends a JSON body to the hook URL
+func (h HookConfig) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("hook %s: build request: %w", h.Name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	webhook.ParseKeys(os.ExpandEnv(h.Secret)).SignRequest(req, body)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("hook %s: %w", h.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("hook %s: unexpected status %s", h.Name, resp.Status)
+	}
+	return nil
+}
+
+// slackText formats an event as a short Slack message
+func slackText(event HookEvent) string {
+	if event.Impact != nil {
+		return fmt.Sprintf("💰 *%s* in `%s`: %+.2f $/month (%s risk) - %s",
+			event.Unit, event.Space, event.Impact.CostDelta,
+			event.Impact.RiskAssessment.Level, event.Impact.RiskAssessment.Recommendation)
+	}
+	if event.Actual != nil {
+		return fmt.Sprintf("✅ *%s* deployed in `%s`: actual cost $%.2f/month",
+			event.Unit, event.Space, event.Actual.MonthlyCost)
+	}
+	return fmt.Sprintf("%s: %s in %s", event.Phase, event.Unit, event.Space)
+}
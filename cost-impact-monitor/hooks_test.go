@@ -0,0 +1,128 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadHookConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	config := `
+hooks:
+  - name: ci-gate
+    type: webhook
+    url: https://ci.example.com/cost
+    when:
+      min_risk: high
+      spaces: [prod]
+  - name: audit
+    type: command
+    phase: post-apply
+    command: ["/bin/true"]
+    timeout: 3s
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hooks, err := LoadHookConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHookConfig: %v", err)
+	}
+	if len(hooks) != 2 {
+		t.Fatalf("loaded %d hooks, want 2", len(hooks))
+	}
+	if hooks[0].Phase != "pre-apply" || hooks[0].Timeout != 10*time.Second {
+		t.Errorf("defaults not applied: %+v", hooks[0])
+	}
+	if hooks[1].Timeout != 3*time.Second {
+		t.Errorf("timeout = %v, want 3s", hooks[1].Timeout)
+	}
+}
+
+func TestLoadHookConfigRejectsInvalidHooks(t *testing.T) {
+	tests := map[string]string{
+		"missing name":  "hooks: [{type: webhook, url: http://x}]",
+		"unknown type":  "hooks: [{name: a, type: email}]",
+		"missing url":   "hooks: [{name: a, type: slack}]",
+		"unknown phase": "hooks: [{name: a, type: webhook, url: http://x, phase: during}]",
+		"no command":    "hooks: [{name: a, type: command}]",
+	}
+
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hooks.yaml")
+			if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadHookConfig(path); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+func TestHookConditionMatches(t *testing.T) {
+	cond := HookCondition{MinRisk: "high", Spaces: []string{"prod"}, MinCostDelta: 100}
+
+	tests := []struct {
+		space string
+		risk  string
+		delta float64
+		want  bool
+	}{
+		{"prod", "critical", 600, true},
+		{"prod", "high", 100, true},
+		{"prod", "medium", 600, false},
+		{"dev", "critical", 600, false},
+		{"prod", "critical", 50, false},
+		{"prod", "", 150, true}, // post-apply hooks carry no risk level
+	}
+
+	for _, tt := range tests {
+		if got := cond.Matches(tt.space, tt.risk, tt.delta); got != tt.want {
+			t.Errorf("Matches(%q, %q, %v) = %v, want %v", tt.space, tt.risk, tt.delta, got, tt.want)
+		}
+	}
+
+	if !(HookCondition{}).Matches("any", "low", 0) {
+		t.Error("empty condition should match everything")
+	}
+}
+
+func TestWebhookHookDeliversEvent(t *testing.T) {
+	t.Setenv("TEST_HOOK_SECRET", "hook-key")
+	var received HookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "Bearer token" || !NewWebhookReceiver(nil, "hook-key").verifySignature(body, r.Header.Get("X-Signature-256")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	hook := HookConfig{
+		Name:    "ci-gate",
+		Type:    "webhook",
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Secret:  "${TEST_HOOK_SECRET}",
+		Timeout: time.Second,
+	}
+	event := HookEvent{Hook: "ci-gate", Unit: "backend", Impact: &CostImpact{CostDelta: 250}}
+
+	if err := hook.fire(event); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if received.Unit != "backend" || received.Impact == nil || received.Impact.CostDelta != 250 {
+		t.Errorf("received %+v", received)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package costimpactmonitor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/confighubtest"
+)
+
+// Runs discovery, analysis and what-if against the in-memory ConfigHub, so
+// the full flow runs in CI without credentials.
+// Run with: go test -tags=integration -v
+func TestMonitorAgainstFakeConfigHub(t *testing.T) {
+	fake := confighubtest.NewServer("integration-token")
+	defer fake.Close()
+
+	space := fake.AddSpace("payments-dev", map[string]string{"environment": "dev"})
+	fake.AddUnit(space.SpaceID, "api", "kind: Deployment\n", map[string]string{"cpu": "500m", "memory": "1Gi", "replicas": "3"})
+	fake.AddUnit(space.SpaceID, "worker", "kind: Deployment\n", map[string]string{"cpu": "250m", "memory": "512Mi"})
+
+	dir := t.TempDir()
+	t.Setenv("CONFIG_FILE", filepath.Join(dir, "config.yaml"))
+	t.Setenv("CUB_API_URL", fake.URL)
+	t.Setenv("CUB_TOKEN", fake.Token)
+	t.Setenv("CLAUDE_API_KEY", "")
+	t.Setenv("STATE_FILE", filepath.Join(dir, "state.json"))
+	t.Setenv("HOOKS_CONFIG", filepath.Join(dir, "hooks.yaml"))
+	t.Setenv("ESCALATION_CONFIG", filepath.Join(dir, "escalation.yaml"))
+	t.Setenv("NOTIFY_CONFIG", filepath.Join(dir, "notify.yaml"))
+
+	m, err := NewCostImpactMonitor()
+	if err != nil {
+		t.Fatalf("NewCostImpactMonitor: %v", err)
+	}
+	if err := m.monitorAllSpaces(); err != nil {
+		t.Fatalf("monitorAllSpaces: %v", err)
+	}
+
+	monitored, ok := m.findSpace("payments-dev")
+	if !ok {
+		t.Fatal("payments-dev not discovered")
+	}
+	m.mu.RLock()
+	projected := monitored.ProjectedCost
+	m.mu.RUnlock()
+	if projected <= 0 {
+		t.Errorf("projected cost = %.2f, want > 0", projected)
+	}
+
+	replicas := 6
+	result, err := m.WhatIf(context.Background(), WhatIfRequest{Space: "payments-dev", Unit: "api", Replicas: &replicas})
+	if err != nil {
+		t.Fatalf("WhatIf: %v", err)
+	}
+	if result.ChangeType != "update" || result.CostDelta <= 0 {
+		t.Errorf("what-if = %+v, want an update costing more", result)
+	}
+}
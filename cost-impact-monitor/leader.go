@@ -0,0 +1,125 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	sdk "github.com/monadic/devops-sdk"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElector decides which replica performs analysis and runs hooks.
+// Every replica keeps serving the dashboard; only the leader calls Claude,
+// executes pre/post-apply hooks and writes cost-warning units to ConfigHub.
+type LeaderElector struct {
+	enabled   bool
+	identity  string
+	namespace string
+	leaseName string
+	clientset kubernetes.Interface
+	leading   atomic.Bool
+}
+
+// NewLeaderElector creates an elector from the monitor config. Leader
+// election is off unless leader_elect is set; when off (or when no cluster
+// is reachable) this replica always acts as the leader.
+func NewLeaderElector(app *sdk.DevOpsApp, cfg Config) *LeaderElector {
+	identity := cfg.PodName
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+
+	le := &LeaderElector{
+		enabled:   cfg.LeaderElect,
+		identity:  identity,
+		namespace: cfg.PodNamespace,
+		leaseName: cfg.LeaderElectLease,
+	}
+
+	if app.K8s != nil && app.K8s.Clientset != nil {
+		le.clientset = app.K8s.Clientset
+	}
+
+	if !le.enabled || le.clientset == nil {
+		le.enabled = false
+		le.leading.Store(true)
+	}
+
+	return le
+}
+
+// IsLeader reports whether this replica currently holds the lease
+func (le *LeaderElector) IsLeader() bool {
+	return le.leading.Load()
+}
+
+// lead makes this replica the leader without campaigning, for a monitor
+// that runs alone
+func (le *LeaderElector) lead() {
+	le.enabled = false
+	le.leading.Store(true)
+}
+
+// Identity returns the name this replica uses on the lease
+func (le *LeaderElector) Identity() string {
+	return le.identity
+}
+
+// Run campaigns for the lease until ctx is cancelled, re-joining the
+// election whenever leadership is lost
+func (le *LeaderElector) Run(ctx context.Context) error {
+	if !le.enabled {
+		return nil
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      le.leaseName,
+			Namespace: le.namespace,
+		},
+		Client: le.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: le.identity,
+		},
+	}
+
+	config := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				le.leading.Store(true)
+				slog.Info("Acquired lease, running analysis and hooks", "identity", le.identity, "lease", le.namespace+"/"+le.leaseName)
+			},
+			OnStoppedLeading: func() {
+				le.leading.Store(false)
+				slog.Warn("Lost lease, serving dashboard only", "identity", le.identity, "lease", le.namespace+"/"+le.leaseName)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != le.identity {
+					slog.Info("New leader elected", "leader", identity)
+				}
+			},
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(config)
+	if err != nil {
+		return fmt.Errorf("create leader elector: %w", err)
+	}
+
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
@@ -1,21 +1,46 @@
+//go:build ignore
+
+// The live dashboard is a separate program from the monitor package in this
+// directory. Build it by file list:
+//
+//	go build -o live-dashboard live-dashboard.go confighub-dynamic.go
+
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/logging"
+	sdk "github.com/monadic/devops-sdk"
+	"gopkg.in/yaml.v3"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 type DashboardData struct {
@@ -26,6 +51,8 @@ type DashboardData struct {
 	PotentialSavings float64    `json:"potential_savings"`
 	Resources       []Resource  `json:"resources"`
 	DriftDetected   bool        `json:"drift_detected"`
+	CorrectionsApplicable bool  `json:"corrections_applicable"` // CORRECTION_APPROVAL=AUTO
+	MetricsAvailable bool       `json:"metrics_available"` // false when metrics-server can't be reached
 	ClusterInfo     ClusterInfo `json:"cluster_info"`
 	ConfigHubInfo   ConfigHubInfo `json:"confighub_info"`
 	Corrections     []Correction `json:"corrections"`
@@ -49,6 +76,7 @@ type ConfigHubInfo struct {
 
 type Correction struct {
 	Resource string `json:"resource"`
+	Unit     string `json:"unit"`
 	Issue    string `json:"issue"`
 	Command  string `json:"command"`
 	Impact   string `json:"impact"`
@@ -64,7 +92,8 @@ type ClaudeInfo struct {
 	Enabled bool   `json:"enabled"`
 	LastRun string `json:"last_run"`
 	Summary string `json:"summary"`
-	Logs    []string `json:"logs"`
+	Error   string `json:"error,omitempty"`
+	Logs    []sdk.ClaudeAPICall `json:"logs"`
 }
 
 type HealthCheckResult struct {
@@ -91,23 +120,635 @@ type Resource struct {
 	ExpectedReplicas int32  `json:"expected_replicas,omitempty"`
 	MonthlyCost     float64 `json:"monthly_cost"`
 	IsDrifted       bool    `json:"is_drifted"`
+	CPURequested    float64 `json:"cpu_requested"`    // cores, all replicas
+	CPUUsed         float64 `json:"cpu_used"`         // cores, from metrics-server
+	MemoryRequested float64 `json:"memory_requested"` // GB, all replicas
+	MemoryUsed      float64 `json:"memory_used"`      // GB, from metrics-server
 }
 
 var (
 	clientset *kubernetes.Clientset
+	metricsClient *metricsclient.Clientset
 	k8sConfig *rest.Config
 	kubeContext string
-	// ConfigHub expected states (from our created units)
-	expectedState = map[string]int32{
-		"test-app":    2,
-		"complex-app": 3,
-		"backend-api": 3,
-		"frontend-web": 1,
-	}
-	claudeLogs []string
+	// Namespace whose deployments are compared against ConfigHub (LIVE_NAMESPACE)
+	namespace = "drift-test"
+	// Rates deployments are priced at, of CLOUD_PROVIDER in CLOUD_REGION
+	pricing = costmodel.Default
+	// ConfigHub expected states, read from the units of CUB_SPACE
+	expectedState = &expectedStateCache{}
+	// drift-detector's API (DRIFT_DETECTOR_URL); when set it decides what has drifted
+	driftDetector *driftDetectorClient
+	// Drift and total cost over time, persisted to LIVE_HISTORY_FILE
+	costHistory = &historyStore{}
+	// Checks run by /api/v1/health, configured from LIVE_HEALTH_CONFIG
+	healthChecks *healthRegistry
+	// Scheduled drift analysis, enabled by CLAUDE_API_KEY
+	claudeAnalysis = &claudeAnalyzer{}
 )
 
+// expectedStateTTL is how long expected replica counts are reused before ConfigHub is queried again
+const expectedStateTTL = 30 * time.Second
+
+// expectedStateCache holds the replica counts declared by ConfigHub units
+type expectedStateCache struct {
+	mu       sync.Mutex
+	space    string
+	replicas map[string]ExpectedDeployment
+	fetched  time.Time
+}
+
+// get returns the expected replica counts and the space they came from,
+// keeping the last known state when ConfigHub can't be reached
+func (c *expectedStateCache) get() (map[string]ExpectedDeployment, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.fetched) < expectedStateTTL {
+		return c.replicas, c.space
+	}
+	c.fetched = time.Now()
+
+	if driftDetector != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		replicas, space, err := driftDetector.expectedState(ctx)
+		if err != nil {
+			slog.Warn("Failed to read drift from drift-detector", logging.Err(err))
+			return c.replicas, c.space
+		}
+		c.space, c.replicas = space, replicas
+		return replicas, space
+	}
+
+	space := os.Getenv("CUB_SPACE")
+	if space == "" {
+		spaces, err := GetConfigHubSpaces()
+		if err != nil || len(spaces) == 0 {
+			slog.Warn("No ConfigHub space to read expected state from", logging.Err(err))
+			return c.replicas, c.space
+		}
+		space = spaces[0]
+	}
+
+	// CUB_UNIT_WHERE narrows the units compared, e.g. "Labels.tier = 'backend'"
+	replicas, err := GetConfigHubExpectedState(space, os.Getenv("CUB_UNIT_WHERE"))
+	if err != nil {
+		slog.Warn("Failed to read expected state from ConfigHub", logging.Space(space), logging.Err(err))
+		return c.replicas, c.space
+	}
+
+	c.space, c.replicas = space, replicas
+	return replicas, space
+}
+
+// historyRetention is how far back drift cost history is kept
+const historyRetention = 7 * 24 * time.Hour
+
+// historyInterval is the minimum spacing of recorded snapshots
+const historyInterval = time.Minute
+
+// HistoryPoint is one recorded /api/v1/live snapshot
+type HistoryPoint struct {
+	Time      time.Time `json:"time"`
+	TotalCost float64   `json:"total_monthly_cost"`
+	DriftCost float64   `json:"drift_cost"`
+	Drifted   int       `json:"drifted_resources"`
+}
+
+// HistorySeries is the body of GET /api/v1/history
+type HistorySeries struct {
+	Range  string         `json:"range"`
+	Step   string         `json:"step"`
+	Points []HistoryPoint `json:"points"`
+}
+
+// historyStore keeps recent snapshots in memory and appends them to a JSON-lines file
+type historyStore struct {
+	mu     sync.Mutex
+	path   string
+	points []HistoryPoint
+}
+
+// load reads the history file, dropping points older than historyRetention,
+// and rewrites it so the file doesn't grow without bound. A missing file is not an error.
+func (h *historyStore) load(path string, now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read history: %w", err)
+	}
+
+	var kept bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var point HistoryPoint
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &point) != nil {
+			continue
+		}
+		if now.Sub(point.Time) > historyRetention {
+			continue
+		}
+		h.points = append(h.points, point)
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// record stores a snapshot unless one was recorded within historyInterval
+func (h *historyStore) record(data DashboardData) {
+	if strings.HasPrefix(data.Status, "error") {
+		return
+	}
+	drifted := 0
+	for _, r := range data.Resources {
+		if r.IsDrifted {
+			drifted++
+		}
+	}
+	point := HistoryPoint{
+		Time:      data.LastRefresh,
+		TotalCost: data.TotalCost,
+		DriftCost: data.DriftCost,
+		Drifted:   drifted,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n := len(h.points); n > 0 && point.Time.Sub(h.points[n-1].Time) < historyInterval {
+		return
+	}
+	h.points = append(h.points, point)
+	for len(h.points) > 0 && point.Time.Sub(h.points[0].Time) > historyRetention {
+		h.points = h.points[1:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	line, _ := json.Marshal(point)
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		slog.Warn("Could not persist cost history", "file", h.path, logging.Err(err))
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		slog.Warn("Could not persist cost history", "file", h.path, logging.Err(err))
+	}
+}
+
+// series averages the points of the last window into buckets of step
+func (h *historyStore) series(window, step time.Duration, now time.Time) []HistoryPoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	start := now.Add(-window).Truncate(step)
+	var series []HistoryPoint
+	count := 0
+	for _, point := range h.points {
+		if point.Time.Before(start) {
+			continue
+		}
+		bucket := point.Time.Truncate(step)
+		if n := len(series); n == 0 || !series[n-1].Time.Equal(bucket) {
+			if n > 0 {
+				series[n-1].TotalCost /= float64(count)
+				series[n-1].DriftCost /= float64(count)
+			}
+			series = append(series, HistoryPoint{Time: bucket})
+			count = 0
+		}
+		last := &series[len(series)-1]
+		last.TotalCost += point.TotalCost
+		last.DriftCost += point.DriftCost
+		if point.Drifted > last.Drifted {
+			last.Drifted = point.Drifted
+		}
+		count++
+	}
+	if n := len(series); n > 0 {
+		series[n-1].TotalCost /= float64(count)
+		series[n-1].DriftCost /= float64(count)
+	}
+	return series
+}
+
+// sampleHistory records a snapshot every interval, so history is kept while nobody has the dashboard open
+func sampleHistory(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		costHistory.record(collectLiveData(ctx))
+		cancel()
+	}
+}
+
+// driftDetectorClient reads drift from drift-detector's /api/v1/drift, so the
+// dashboard and the detector never disagree about what has drifted
+type driftDetectorClient struct {
+	baseURL string
+	token   string // API token of a team, when the detector is multi-tenant
+	http    *http.Client
+}
+
+// detectorReport mirrors drift-detector's DriftReport
+type detectorReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Space     string    `json:"space"`
+	Namespace string    `json:"namespace"`
+	Analysis  *struct {
+		HasDrift bool                `json:"has_drift"`
+		Items    []detectorDriftItem `json:"items"`
+		Summary  string              `json:"summary"`
+	} `json:"analysis"`
+}
+
+// detectorDriftItem mirrors drift-detector's DriftItem
+type detectorDriftItem struct {
+	UnitSlug string `json:"unit_slug"`
+	Resource string `json:"resource"` // Kind/name
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func newDriftDetectorClient(baseURL string) *driftDetectorClient {
+	return &driftDetectorClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// report fetches the detector's latest drift report
+func (c *driftDetectorClient) report(ctx context.Context) (*detectorReport, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/drift", nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get drift report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("get drift report: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var report detectorReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("decode drift report: %w", err)
+	}
+	return &report, nil
+}
+
+// expectedState returns the expected replicas of the deployments the detector
+// reports as drifted, and the space its units live in
+func (c *driftDetectorClient) expectedState(ctx context.Context) (map[string]ExpectedDeployment, string, error) {
+	report, err := c.report(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if report.Namespace != "" && report.Namespace != namespace {
+		slog.Warn("drift-detector watches a different namespace", "detector_namespace", report.Namespace, "namespace", namespace)
+	}
+	return driftedDeployments(report), report.Space, nil
+}
+
+// driftedDeployments picks the replica drift of Deployments out of a report;
+// other fields don't change cost and are left to drift-detector
+func driftedDeployments(report *detectorReport) map[string]ExpectedDeployment {
+	expected := make(map[string]ExpectedDeployment)
+	if report.Analysis == nil {
+		return expected
+	}
+	for _, item := range report.Analysis.Items {
+		kind, name, ok := strings.Cut(item.Resource, "/")
+		if !ok || !strings.EqualFold(kind, "Deployment") || item.Field != "spec.replicas" {
+			continue
+		}
+		replicas, err := strconv.ParseInt(item.Expected, 10, 32)
+		if err != nil {
+			slog.Warn("drift-detector reported invalid replicas", logging.Unit(item.UnitSlug), "deployment", name, "replicas", item.Expected)
+			continue
+		}
+		expected[name] = ExpectedDeployment{Unit: item.UnitSlug, Replicas: int32(replicas)}
+	}
+	return expected
+}
+
+// claudeLogLimit is how many prompt/response pairs are kept for the dashboard
+const claudeLogLimit = 20
+
+// claudeAnalyzer asks Claude about the detected drift and keeps the
+// prompt/response log that the dashboard streams
+type claudeAnalyzer struct {
+	mu          sync.Mutex
+	complete    func(prompt string) (string, error) // nil when Claude is disabled
+	lastRun     time.Time
+	summary     string
+	err         string
+	logs        []sdk.ClaudeAPICall
+	subscribers map[chan sdk.ClaudeAPICall]struct{}
+}
+
+// info reports the latest analysis for /api/v1/live
+func (a *claudeAnalyzer) info() ClaudeInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info := ClaudeInfo{
+		Enabled: a.complete != nil,
+		Summary: a.summary,
+		Error:   a.err,
+		Logs:    append([]sdk.ClaudeAPICall{}, a.logs...),
+	}
+	if !a.lastRun.IsZero() {
+		info.LastRun = a.lastRun.Format("2006-01-02 15:04:05")
+	}
+	return info
+}
+
+// analyze asks Claude to explain the drift in data; nothing is sent when
+// there is no drift
+func (a *claudeAnalyzer) analyze(data DashboardData) {
+	a.mu.Lock()
+	complete := a.complete
+	a.mu.Unlock()
+	if complete == nil {
+		return
+	}
+
+	if !data.DriftDetected {
+		a.mu.Lock()
+		a.lastRun, a.summary, a.err = time.Now(), "No drift detected", ""
+		a.mu.Unlock()
+		return
+	}
+
+	prompt := driftPrompt(data)
+	response, err := complete(prompt)
+
+	a.mu.Lock()
+	a.lastRun = time.Now()
+	if err != nil {
+		a.err = err.Error()
+		a.mu.Unlock()
+		slog.Warn("Claude analysis failed", logging.Err(err))
+		return
+	}
+	a.summary, a.err = firstLine(response), ""
+	a.mu.Unlock()
+
+	a.record(sdk.ClaudeAPICall{Timestamp: time.Now(), Prompt: prompt, Response: response})
+}
+
+// record adds a call to the log and sends it to every open stream
+func (a *claudeAnalyzer) record(call sdk.ClaudeAPICall) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.logs = append(a.logs, call)
+	if len(a.logs) > claudeLogLimit {
+		a.logs = a.logs[len(a.logs)-claudeLogLimit:]
+	}
+	for ch := range a.subscribers {
+		select {
+		case ch <- call:
+		default: // slow reader; it will catch up from /api/v1/live
+		}
+	}
+}
+
+// subscribe returns the calls logged so far and a channel receiving new ones
+func (a *claudeAnalyzer) subscribe() ([]sdk.ClaudeAPICall, chan sdk.ClaudeAPICall, func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.subscribers == nil {
+		a.subscribers = make(map[chan sdk.ClaudeAPICall]struct{})
+	}
+	ch := make(chan sdk.ClaudeAPICall, 4)
+	a.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		a.mu.Lock()
+		delete(a.subscribers, ch)
+		a.mu.Unlock()
+	}
+	return append([]sdk.ClaudeAPICall{}, a.logs...), ch, unsubscribe
+}
+
+// driftPrompt describes the drifted deployments and their cost to Claude
+func driftPrompt(data DashboardData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "These Kubernetes deployments in namespace %s differ from the state declared in ConfigHub:\n",
+		data.ClusterInfo.Namespace)
+	for _, r := range data.Resources {
+		if !r.IsDrifted {
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: %d replicas running, %d expected, $%.2f/month\n",
+			r.Name, r.ActualReplicas, r.ExpectedReplicas, r.MonthlyCost)
+	}
+	fmt.Fprintf(&b, "Drift costs $%.2f/month of a $%.2f/month total.\n", data.DriftCost, data.TotalCost)
+	b.WriteString("Start with a one-sentence summary, then give the likely cause and whether to correct it in ConfigHub or accept the change.")
+	return b.String()
+}
+
+// firstLine returns the first non-empty line of a response
+func firstLine(response string) string {
+	for _, line := range strings.Split(response, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// scheduleClaudeAnalysis analyzes the current drift every interval
+func scheduleClaudeAnalysis(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		claudeAnalysis.analyze(collectLiveData(ctx))
+		cancel()
+		<-ticker.C
+	}
+}
+
+// serveClaudeStream streams Claude prompts and responses as server-sent events (GET /api/v1/claude/stream)
+func serveClaudeStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	backlog, calls, unsubscribe := claudeAnalysis.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func(call sdk.ClaudeAPICall) {
+		event, _ := json.Marshal(call)
+		fmt.Fprintf(w, "data: %s\n\n", event)
+		flusher.Flush()
+	}
+	for _, call := range backlog {
+		send(call)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case call := <-calls:
+			send(call)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// historyRanges maps the supported /api/v1/history ranges to their bucket size
+var historyRanges = map[string]time.Duration{
+	"24h": 5 * time.Minute,
+	"7d":  time.Hour,
+}
+
+// serveHistory returns drift and total cost over the last 24h or 7d (GET /api/v1/history?range=24h|7d)
+func serveHistory(w http.ResponseWriter, r *http.Request) {
+	rangeName := r.URL.Query().Get("range")
+	if rangeName == "" {
+		rangeName = "24h"
+	}
+	step, ok := historyRanges[rangeName]
+	if !ok {
+		http.Error(w, "range must be 24h or 7d", http.StatusBadRequest)
+		return
+	}
+	window := 24 * time.Hour
+	if rangeName == "7d" {
+		window = historyRetention
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HistorySeries{
+		Range:  rangeName,
+		Step:   step.String(),
+		Points: costHistory.series(window, step, time.Now()),
+	})
+}
+
+// authenticator guards the dashboard. authenticate writes the challenge or
+// redirect itself and returns false when the request may not proceed.
+type authenticator interface {
+	authenticate(w http.ResponseWriter, r *http.Request) bool
+}
+
+// requireAuth wraps the dashboard routes with an authenticator; nil allows everything
+func requireAuth(auth authenticator, next http.Handler) http.Handler {
+	if auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.authenticate(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// basicAuth checks a single username and password
+type basicAuth struct {
+	user     string
+	password string
+}
+
+func (a *basicAuth) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if ok && subtle.ConstantTimeCompare([]byte(user), []byte(a.user)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(a.password)) == 1 {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="live-dashboard"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// newOIDCGuard sets up the login shared with the other dashboards
+// (pkg/auth): from the auth file at AUTH_CONFIG when set, else from the
+// OIDC_* variables
+func newOIDCGuard(ctx context.Context) (*auth.Guard, error) {
+	if path := os.Getenv("AUTH_CONFIG"); path != "" {
+		guard, err := auth.Load(ctx, path, func(string) (string, error) { return "", nil })
+		if err == nil && guard == nil {
+			err = fmt.Errorf("auth config %s not found", path)
+		}
+		return guard, err
+	}
+
+	cfg := auth.Config{
+		IssuerURL:     os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		SessionSecret: os.Getenv("LIVE_SESSION_SECRET"),
+	}
+	for _, email := range strings.Split(os.Getenv("OIDC_ALLOWED_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			cfg.AllowedEmails = append(cfg.AllowedEmails, email)
+		}
+	}
+	return auth.New(ctx, cfg)
+}
+
+// configureAuth returns the middleware selected by LIVE_AUTH ("none", "basic"
+// or "oidc"); nil leaves the dashboard open
+func configureAuth(ctx context.Context) (func(http.Handler) http.Handler, error) {
+	switch mode := strings.ToLower(os.Getenv("LIVE_AUTH")); mode {
+	case "", "none":
+		return nil, nil
+	case "basic":
+		user, password := os.Getenv("LIVE_BASIC_AUTH_USER"), os.Getenv("LIVE_BASIC_AUTH_PASSWORD")
+		if user == "" || password == "" {
+			return nil, errors.New("LIVE_BASIC_AUTH_USER and LIVE_BASIC_AUTH_PASSWORD are required for basic auth")
+		}
+		return func(next http.Handler) http.Handler {
+			return requireAuth(&basicAuth{user: user, password: password}, next)
+		}, nil
+	case "oidc":
+		guard, err := newOIDCGuard(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return func(next http.Handler) http.Handler { return guard.Protect(next) }, nil
+	default:
+		return nil, fmt.Errorf("unknown LIVE_AUTH %q (use none, basic or oidc)", mode)
+	}
+}
+
 func main() {
+	logging.Setup("live-dashboard")
+
 	// Connect to Kubernetes
 	kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
 
@@ -119,75 +760,165 @@ func main() {
 
 	rawConfig, _ := kubeConfig.RawConfig()
 	kubeContext = rawConfig.CurrentContext
+	if ns := os.Getenv("LIVE_NAMESPACE"); ns != "" {
+		namespace = ns
+	}
+	if provider := os.Getenv("CLOUD_PROVIDER"); provider != "" {
+		p, err := costmodel.Lookup(provider, os.Getenv("CLOUD_REGION"))
+		if err != nil {
+			logging.Fatal("Invalid CLOUD_PROVIDER", logging.Err(err))
+		}
+		pricing = p
+	}
 
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to load kubeconfig", "kubeconfig", kubeconfig, logging.Err(err))
 	}
 	k8sConfig = config
 
 	clientset, err = kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to create Kubernetes client", logging.Err(err))
+	}
+
+	metricsClient, err = metricsclient.NewForConfig(config)
+	if err != nil {
+		slog.Warn("Metrics client unavailable, showing requested resources only", logging.Err(err))
+	}
+
+	historyFile := os.Getenv("LIVE_HISTORY_FILE")
+	if historyFile == "" {
+		historyFile = "live-history.jsonl"
 	}
+	if err := costHistory.load(historyFile, time.Now()); err != nil {
+		slog.Warn("Could not load cost history", "file", historyFile, logging.Err(err))
+	}
+	go sampleHistory(time.Minute)
 
-	fmt.Println("[INFO] Starting Live Cost Impact Dashboard on :8082")
-	fmt.Println("[INFO] Monitoring drift-test namespace...")
-	fmt.Printf("[INFO] Connected to cluster: %s\n", kubeContext)
+	healthConfigFile := os.Getenv("LIVE_HEALTH_CONFIG")
+	if healthConfigFile == "" {
+		healthConfigFile = "live-health.yaml"
+	}
+	healthConfig, err := LoadHealthConfig(healthConfigFile)
+	if err != nil {
+		logging.Fatal("Failed to load health config", "file", healthConfigFile, logging.Err(err))
+	}
+	healthChecks = newHealthRegistry(healthConfig)
+
+	if url := os.Getenv("DRIFT_DETECTOR_URL"); url != "" {
+		driftDetector = newDriftDetectorClient(url)
+		driftDetector.token = os.Getenv("DRIFT_DETECTOR_TOKEN")
+		slog.Info("Reading drift from drift-detector", "url", url)
+	}
+
+	if apiKey := os.Getenv("CLAUDE_API_KEY"); apiKey != "" && os.Getenv("ENABLE_CLAUDE") != "false" {
+		interval, err := time.ParseDuration(os.Getenv("CLAUDE_ANALYSIS_INTERVAL"))
+		if err != nil || interval <= 0 {
+			interval = 10 * time.Minute
+		}
+		claudeAnalysis.complete = sdk.NewClaudeClient(apiKey).Complete
+		go scheduleClaudeAnalysis(interval)
+		slog.Info("Claude drift analysis enabled", "interval", interval)
+	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", serveDashboard)
-	mux.HandleFunc("/api/live", serveLiveData)
-	mux.HandleFunc("/api/health", serveHealthCheck)
+	slog.Info("Starting Live Cost Impact Dashboard", "addr", ":8082", "namespace", namespace, logging.Cluster(kubeContext))
+
+	dashboard := http.NewServeMux()
+	dashboard.HandleFunc("/", serveDashboard)
+	dashboard.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFiles))))
+	v1 := api.New(dashboard, "live-dashboard")
+	v1.HandleFunc("/live", serveLiveData,
+		api.Operation{Summary: "Cluster costs, drift and corrections", Response: DashboardData{}})
+	v1.HandleFunc("/history", serveHistory,
+		api.Operation{Summary: "Total and drift cost over time", Query: []api.Param{{Name: "range", Description: "24h (default) or 7d"}}, Response: HistorySeries{}})
+	v1.HandleFunc("/health", serveHealthCheck,
+		api.Operation{Summary: "Results of the health checks", Response: HealthCheckResult{}})
+	v1.HandleFunc("/corrections/", serveApplyCorrection,
+		api.Operation{Method: http.MethodPost, Path: "/corrections/{resource}/apply", Summary: "Apply a drift correction through ConfigHub", Request: ApplyCorrectionRequest{}, Response: ApplyCorrectionResult{}})
+	v1.HandleFunc("/claude/stream", serveClaudeStream,
+		api.Operation{Summary: "Claude prompts and responses as server-sent events", ContentType: "text/event-stream"})
+
+	// Everything goes through LIVE_AUTH
+	var handler http.Handler = dashboard
+	protect, err := configureAuth(context.Background())
+	if err != nil {
+		logging.Fatal("Failed to configure authentication", logging.Err(err))
+	}
+	if protect != nil {
+		handler = protect(dashboard)
+	}
 
-	log.Fatal(http.ListenAndServe(":8082", mux))
+	certFile, keyFile := os.Getenv("LIVE_TLS_CERT"), os.Getenv("LIVE_TLS_KEY")
+	if certFile != "" && keyFile != "" {
+		slog.Info("Serving over TLS")
+		logging.Fatal("Dashboard server failed", logging.Err(http.ListenAndServeTLS(":8082", certFile, keyFile, handler)))
+	}
+	if protect != nil {
+		slog.Warn("Authentication is enabled without TLS; credentials travel in clear text")
+	}
+	logging.Fatal("Dashboard server failed", logging.Err(http.ListenAndServe(":8082", handler)))
 }
 
 func serveLiveData(w http.ResponseWriter, r *http.Request) {
-	ctx := context.TODO()
+	data := collectLiveData(r.Context())
+	costHistory.record(data)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// collectLiveData compares the namespace's deployments with ConfigHub and prices them
+func collectLiveData(ctx context.Context) DashboardData {
 	data := DashboardData{
 		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
 		LastRefresh: time.Now(),
 		Resources: []Resource{},
 		Corrections: []Correction{},
+		CorrectionsApplicable: correctionsAutoApproved(),
 		Optimizations: []Optimization{},
 		ClusterInfo: ClusterInfo{
 			Context:   kubeContext,
 			Cluster:   strings.TrimPrefix(kubeContext, "kind-"),
-			Namespace: "drift-test",
+			Namespace: namespace,
 			APIServer: k8sConfig.Host,
 		},
 		ConfigHubInfo: getConfigHubInfo(),
-		ClaudeAnalysis: ClaudeInfo{
-			Enabled: os.Getenv("CLAUDE_API_KEY") != "",
-			LastRun: time.Now().Add(-5 * time.Minute).Format("15:04:05"),
-			Summary: "Drift detected on backend-api causing cost overrun",
-			Logs:    claudeLogs,
-		},
+		ClaudeAnalysis: claudeAnalysis.info(),
 	}
 
+	// Expected state comes from the ConfigHub units
+	declared, space := expectedState.get()
+
 	// Get deployments from Kubernetes
-	deployments, err := clientset.AppsV1().Deployments("drift-test").List(ctx, metav1.ListOptions{})
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		data.Status = "error: " + err.Error()
 	} else {
 		totalCost := 0.0
 		driftCost := 0.0
 		driftCount := 0
+		podMetrics, metricsAvailable := listPodMetrics(ctx)
+		data.MetricsAvailable = metricsAvailable
 
 		for _, dep := range deployments.Items {
 			replicas := *dep.Spec.Replicas
 			cost := calculateCost(dep)
+			cpu, mem := requestedResources(dep)
 
 			resource := Resource{
-				Name:           dep.Name,
-				Type:           "Deployment",
-				ActualReplicas: replicas,
-				MonthlyCost:    cost,
+				Name:            dep.Name,
+				Type:            "Deployment",
+				ActualReplicas:  replicas,
+				MonthlyCost:     cost,
+				CPURequested:    cpu * float64(replicas),
+				MemoryRequested: mem * float64(replicas),
 			}
+			resource.CPUUsed, resource.MemoryUsed = usedResources(dep, podMetrics)
 
 			// Check for drift
-			if expected, exists := expectedState[dep.Name]; exists {
+			if unit, exists := declared[dep.Name]; exists {
+				expected := unit.Replicas
 				resource.ExpectedReplicas = expected
 				if replicas != expected {
 					resource.IsDrifted = true
@@ -197,16 +928,12 @@ func serveLiveData(w http.ResponseWriter, r *http.Request) {
 					driftImpact := float64(replicas - expected) * costPerReplica
 					driftCost += driftImpact
 
-					// Add correction command
-					// Use environment variable or allow user to specify space
-					space := os.Getenv("CUB_SPACE")
-					if space == "" {
-						space = "<your-space>"
-					}
+					// Add correction command against the space the expected state came from
 					correction := Correction{
 						Resource: dep.Name,
+						Unit:     unit.Unit,
 						Issue:    fmt.Sprintf("Running %d replicas (expected: %d)", replicas, expected),
-						Command:  fmt.Sprintf("cub unit update %s --space %s --patch --from-stdin <<< '{\"spec\":{\"replicas\":%d}}'", dep.Name, space, expected),
+						Command:  fmt.Sprintf("cub unit update %s --space %s --patch --from-stdin <<< '{\"spec\":{\"replicas\":%d}}' && cub unit apply %s --space %s", unit.Unit, space, expected, unit.Unit, space),
 						Impact:   fmt.Sprintf("Save $%.2f/month", driftImpact),
 					}
 					data.Corrections = append(data.Corrections, correction)
@@ -240,20 +967,16 @@ func serveLiveData(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+	return data
 }
 
-func getExpectedReplicas(deploymentName string) int {
-	if expected, exists := expectedState[deploymentName]; exists {
-		return int(expected)
-	}
-	// Default to 2 if not found
-	return 2
+func calculateCost(dep appsv1.Deployment) float64 {
+	cpuTotal, memTotal := requestedResources(dep)
+	return pricing.MonthlyCost(costmodel.Resources{CPUCores: cpuTotal, MemoryGB: memTotal, Replicas: int(*dep.Spec.Replicas)})
 }
 
-func calculateCost(dep appsv1.Deployment) float64 {
-	replicas := float64(*dep.Spec.Replicas)
+// requestedResources returns the CPU cores and memory GB requested by one replica
+func requestedResources(dep appsv1.Deployment) (float64, float64) {
 	cpuTotal := 0.0
 	memTotal := 0.0
 
@@ -266,12 +989,42 @@ func calculateCost(dep appsv1.Deployment) float64 {
 		}
 	}
 
-	// AWS pricing estimate
-	cpuCost := cpuTotal * replicas * 0.024 * 24 * 30
-	memCost := memTotal * replicas * 0.006 * 24 * 30
-	podCost := replicas * 2.0
+	return cpuTotal, memTotal
+}
+
+// listPodMetrics reads current pod usage in the namespace from metrics-server
+func listPodMetrics(ctx context.Context) ([]metricsv1beta1.PodMetrics, bool) {
+	if metricsClient == nil {
+		return nil, false
+	}
+	list, err := metricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		slog.Warn("Could not get pod metrics", logging.Err(err))
+		return nil, false
+	}
+	return list.Items, true
+}
+
+// usedResources sums the CPU cores and memory GB used by the pods a deployment selects
+func usedResources(dep appsv1.Deployment, podMetrics []metricsv1beta1.PodMetrics) (float64, float64) {
+	selector, err := metav1.LabelSelectorAsSelector(dep.Spec.Selector)
+	if err != nil || selector.Empty() {
+		return 0, 0
+	}
 
-	return cpuCost + memCost + podCost
+	cpuUsed := 0.0
+	memUsed := 0.0
+	for _, pod := range podMetrics {
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		for _, container := range pod.Containers {
+			cpuUsed += float64(container.Usage.Cpu().MilliValue()) / 1000.0
+			memUsed += float64(container.Usage.Memory().Value()) / (1024 * 1024 * 1024)
+		}
+	}
+
+	return cpuUsed, memUsed
 }
 
 func getConfigHubInfo() ConfigHubInfo {
@@ -279,485 +1032,432 @@ func getConfigHubInfo() ConfigHubInfo {
 	return GetDynamicConfigHubInfo()
 }
 
+// webFiles holds the dashboard page template and its static CSS and JS
+//
+//go:embed web/live-dashboard
+var webFiles embed.FS
+
+var (
+	dashboardTemplate = template.Must(template.ParseFS(webFiles, "web/live-dashboard/index.html.tmpl"))
+	staticFiles       = mustSub(webFiles, "web/live-dashboard/static")
+)
+
+// dashboardPage is the data the page template is rendered with; everything
+// else is filled in by dashboard.js from the JSON API
+type dashboardPage struct {
+	Namespace      string
+	Context        string
+	RefreshSeconds int
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// renderDashboard executes the page template
+func renderDashboard(w io.Writer, page dashboardPage) error {
+	return dashboardTemplate.Execute(w, page)
+}
+
+// serveDashboard renders the dashboard page; data is loaded by dashboard.js from /api/v1/live
 func serveDashboard(w http.ResponseWriter, r *http.Request) {
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <title>Live Cost Impact Monitor</title>
-    <style>
-        body { font-family: -apple-system, sans-serif; background: linear-gradient(135deg, #667eea 0%, #764ba2 100%); padding: 20px; min-height: 100vh; }
-        .container { max-width: 1400px; margin: 0 auto; }
-        .header { background: white; padding: 25px; border-radius: 12px; margin-bottom: 20px; box-shadow: 0 10px 30px rgba(0,0,0,0.1); }
-        h1 { margin: 0 0 10px 0; color: #333; }
-        h2 { color: #333; font-size: 20px; margin-bottom: 15px; }
-        .status { color: #666; }
-        .drift { color: #ff6b6b; }
-        .aligned { color: #51cf66; }
-        .metrics { display: grid; grid-template-columns: repeat(auto-fit, minmax(280px, 1fr)); gap: 20px; margin-bottom: 20px; }
-        .metric { background: white; padding: 20px; border-radius: 12px; box-shadow: 0 5px 20px rgba(0,0,0,0.08); }
-        .metric-label { color: #666; font-size: 14px; margin-bottom: 8px; }
-        .metric-value { font-size: 28px; font-weight: bold; color: #333; }
-        .metric-delta { font-size: 14px; margin-top: 5px; }
-        .section { background: white; padding: 25px; border-radius: 12px; margin-bottom: 20px; box-shadow: 0 5px 20px rgba(0,0,0,0.08); }
-        table { width: 100%; border-collapse: collapse; }
-        th { text-align: left; padding: 10px; border-bottom: 2px solid #e5e7eb; color: #666; font-weight: 600; }
-        td { padding: 10px; border-bottom: 1px solid #e5e7eb; }
-        .drifted { background: #fef2f2; }
-        .info-grid { display: grid; grid-template-columns: 1fr 1fr; gap: 20px; margin-bottom: 20px; }
-        .info-box { background: white; padding: 20px; border-radius: 12px; box-shadow: 0 5px 20px rgba(0,0,0,0.08); }
-        .refresh { margin-top: 10px; color: #999; font-size: 12px; }
-        .correction { background: #f0fdf4; padding: 15px; border-radius: 8px; margin-bottom: 10px; }
-        .correction code { background: #dcfce7; padding: 2px 6px; border-radius: 4px; font-size: 12px; }
-        .optimization { background: #fef3c7; padding: 15px; border-radius: 8px; margin-bottom: 10px; }
-        .claude-log { background: #f3f4f6; padding: 10px; border-radius: 6px; margin-bottom: 8px; font-size: 14px; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Kubernetes Cost Monitoring Dashboard</h1>
-            <div class="status">Real-time cost analysis and drift detection</div>
-            <div class="refresh">Auto-refresh: every 5 seconds | Last update: <span id="last-update">-</span> | Timestamp: <span id="timestamp">-</span> | <button onclick="runHealthCheck()">Run Health Check</button></div>
-        </div>
-
-        <div class="info-grid">
-            <div class="info-box">
-                <h2>Cluster Info</h2>
-                <div><strong>Context:</strong> <span id="cluster-context">-</span></div>
-                <div><strong>Cluster:</strong> <span id="cluster-name">-</span></div>
-                <div><strong>Namespace:</strong> <span id="namespace">drift-test</span></div>
-                <div><strong>API Server:</strong> <span id="api-server">-</span></div>
-            </div>
-            <div class="info-box">
-                <h2>ConfigHub Info</h2>
-                <div><strong>Connected:</strong> <span id="cub-connected">-</span></div>
-                <div><strong>Spaces:</strong> <span id="cub-spaces">-</span></div>
-                <div><strong>Units:</strong> <span id="cub-units">-</span></div>
-                <div><strong>Claude AI:</strong> <span id="claude-enabled">-</span></div>
-            </div>
-        </div>
-
-        <div class="metrics">
-            <div class="metric">
-                <div class="metric-label">Current Monthly Cost</div>
-                <div class="metric-value" id="total-cost">$50.87</div>
-                <div class="metric-delta" id="cluster-type">Kind cluster (drift-test namespace)</div>
-            </div>
-            <div class="metric">
-                <div class="metric-label">Drift Cost Impact</div>
-                <div class="metric-value" id="drift-cost">+$12.80</div>
-                <div class="metric-delta">Over-provisioned resources</div>
-            </div>
-            <div class="metric">
-                <div class="metric-label">Potential Savings</div>
-                <div class="metric-value" id="savings">$21.34</div>
-                <div class="metric-delta">42% reduction possible</div>
-            </div>
-            <div class="metric">
-                <div class="metric-label">Resources Monitored</div>
-                <div class="metric-value" id="resources-count">4</div>
-                <div class="metric-delta">Deployments + ConfigMaps</div>
-            </div>
-        </div>
-
-        <div class="section">
-            <h2>Resource Breakdown</h2>
-            <table>
-                <thead>
-                    <tr>
-                        <th>Resource</th>
-                        <th>Type</th>
-                        <th>ConfigHub Expected</th>
-                        <th>K8s Actual</th>
-                        <th>CPU</th>
-                        <th>Memory</th>
-                        <th>Monthly Cost</th>
-                        <th>Status</th>
-                    </tr>
-                </thead>
-                <tbody id="resources-table">
-                </tbody>
-            </table>
-        </div>
-
-        <div class="section" id="corrections-section">
-            <h2>ConfigHub Corrections Needed</h2>
-            <div id="corrections-list"></div>
-        </div>
-
-        <div class="section" id="optimizations-section">
-            <h2>Additional Optimization Opportunities</h2>
-            <div id="optimizations-list"></div>
-        </div>
-
-        <div class="section">
-            <h2>Claude AI Analysis</h2>
-            <div><strong>Status:</strong> <span id="claude-status">-</span></div>
-            <div><strong>Last Run:</strong> <span id="claude-last-run">-</span></div>
-            <div><strong>Summary:</strong> <span id="claude-summary">-</span></div>
-            <div style="margin-top: 15px;"><strong>Logs:</strong></div>
-            <div id="claude-logs"></div>
-        </div>
-    </div>
-
-    <script>
-    function updateDashboard() {
-        fetch('/api/live')
-            .then(r => r.json())
-            .then(data => {
-                // Update timestamps
-                document.getElementById('timestamp').textContent = data.timestamp;
-                document.getElementById('last-update').textContent = new Date().toLocaleTimeString();
-
-                // Update cluster info
-                document.getElementById('cluster-context').textContent = data.cluster_info.context || '-';
-                document.getElementById('cluster-name').textContent = data.cluster_info.cluster || 'kind';
-                document.getElementById('namespace').textContent = data.cluster_info.namespace || 'drift-test';
-                document.getElementById('api-server').textContent = data.cluster_info.api_server || '-';
-
-                // Update ConfigHub info
-                document.getElementById('cub-connected').textContent = data.confighub_info.connected ? 'Yes' : 'No';
-                document.getElementById('cub-spaces').textContent = data.confighub_info.spaces ? data.confighub_info.spaces.join(', ') : '-';
-                document.getElementById('cub-units').textContent = data.confighub_info.units ? data.confighub_info.units.length + ' units' : '-';
-
-                // Update metrics
-                document.getElementById('total-cost').textContent = '$' + data.total_monthly_cost.toFixed(2);
-                document.getElementById('drift-cost').textContent = (data.drift_cost >= 0 ? '+' : '') + '$' + Math.abs(data.drift_cost).toFixed(2);
-                document.getElementById('savings').textContent = '$' + data.potential_savings.toFixed(2);
-                document.getElementById('resources-count').textContent = data.resources ? data.resources.length : 0;
-
-                // Update resources table
-                const tbody = document.getElementById('resources-table');
-                tbody.innerHTML = '';
-
-                if (data.resources) {
-                    data.resources.forEach(r => {
-                        const row = tbody.insertRow();
-                        row.className = r.is_drifted ? 'drifted' : '';
-                        const cpuCores = 0.1; // Placeholder
-                        const memGB = 0.128; // Placeholder
-                        row.innerHTML =
-                            '<td>' + r.name + '</td>' +
-                            '<td>' + r.type + '</td>' +
-                            '<td>' + (r.expected_replicas || '-') + '</td>' +
-                            '<td>' + r.replicas + '</td>' +
-                            '<td>' + cpuCores + ' cores</td>' +
-                            '<td>' + memGB + ' GB</td>' +
-                            '<td>$' + r.monthly_cost.toFixed(2) + '</td>' +
-                            '<td>' + (r.is_drifted ? '[!] DRIFTED' : '[OK]') + '</td>';
-                    });
-                }
-
-                // Update corrections
-                const correctionsList = document.getElementById('corrections-list');
-                correctionsList.innerHTML = '';
-                if (data.corrections && data.corrections.length > 0) {
-                    data.corrections.forEach(c => {
-                        correctionsList.innerHTML +=
-                            '<div class="correction">' +
-                            '<strong>' + c.resource + '</strong>: ' + c.issue + '<br>' +
-                            '<code>' + c.command + '</code><br>' +
-                            '<span style="color: #10b981;">' + c.impact + '</span>' +
-                            '</div>';
-                    });
-                } else {
-                    correctionsList.innerHTML = '<div style="color: #10b981;">All resources aligned with ConfigHub</div>';
-                }
-
-                // Update optimizations
-                const optimizationsList = document.getElementById('optimizations-list');
-                optimizationsList.innerHTML = '';
-                if (data.optimizations && data.optimizations.length > 0) {
-                    data.optimizations.forEach(o => {
-                        optimizationsList.innerHTML +=
-                            '<div class="optimization">' +
-                            o.description + ': <strong>Save $' + o.savings.toFixed(2) + '/month</strong> (Risk: ' + o.risk + ')' +
-                            '</div>';
-                    });
-                } else {
-                    optimizationsList.innerHTML = '<div>No additional optimizations available</div>';
-                }
-
-                // Update Claude info
-                document.getElementById('claude-enabled').textContent = data.claude_analysis.enabled ? 'Enabled' : 'Disabled';
-                document.getElementById('claude-status').textContent = data.claude_analysis.enabled ? 'Active' : 'Inactive';
-                document.getElementById('claude-last-run').textContent = data.claude_analysis.last_run || '-';
-                document.getElementById('claude-summary').textContent = data.claude_analysis.summary || 'No analysis available';
-
-                const claudeLogsDiv = document.getElementById('claude-logs');
-                claudeLogsDiv.innerHTML = '';
-                if (data.claude_analysis.logs && data.claude_analysis.logs.length > 0) {
-                    data.claude_analysis.logs.forEach(log => {
-                        claudeLogsDiv.innerHTML += '<div class="claude-log">' + log + '</div>';
-                    });
-                } else {
-                    claudeLogsDiv.innerHTML = '<div class="claude-log">No logs available</div>';
-                }
-            })
-            .catch(err => {
-                console.error('Dashboard update error:', err);
-            });
-    }
-
-    function runHealthCheck() {
-        const btn = event.target;
-        btn.disabled = true;
-        btn.textContent = 'Running...';
-
-        fetch('/api/health')
-            .then(response => response.json())
-            .then(data => {
-                // Display health check results
-                const modal = document.createElement('div');
-                modal.style.cssText = 'position:fixed;top:50%;left:50%;transform:translate(-50%,-50%);background:white;border:2px solid #333;padding:20px;z-index:1000;max-width:80%;max-height:80%;overflow:auto;';
-
-                let checksHtml = '<h2>Health Check Results</h2>';
-                checksHtml += '<p>Timestamp: ' + data.timestamp + '</p>';
-                checksHtml += '<p>Health Score: <strong>' + data.health_score + '/100</strong></p>';
-                checksHtml += '<p>Status: <strong style="color:' + (data.status === 'HEALTHY' ? '#10b981' : data.status === 'DEGRADED' ? '#f59e0b' : '#ef4444') + '">' + data.status + '</strong></p>';
-                checksHtml += '<p>' + data.status_text + '</p>';
-
-                checksHtml += '<h3>Checks:</h3>';
-                checksHtml += '<table border="1" style="width:100%;border-collapse:collapse;">';
-                checksHtml += '<tr><th>Component</th><th>Check</th><th>Status</th><th>Details</th></tr>';
-                data.checks.forEach(check => {
-                    const color = check.status === 'HEALTHY' ? '#10b981' : check.status === 'DEGRADED' ? '#f59e0b' : '#ef4444';
-                    checksHtml += '<tr>';
-                    checksHtml += '<td>' + check.component + '</td>';
-                    checksHtml += '<td>' + check.check + '</td>';
-                    checksHtml += '<td style="color:' + color + '">' + check.status + '</td>';
-                    checksHtml += '<td>' + check.details + '</td>';
-                    checksHtml += '</tr>';
-                });
-                checksHtml += '</table>';
-
-                if (data.issues && data.issues.length > 0) {
-                    checksHtml += '<h3>Issues Found:</h3>';
-                    checksHtml += '<ul>';
-                    data.issues.forEach(issue => {
-                        checksHtml += '<li>' + issue + '</li>';
-                    });
-                    checksHtml += '</ul>';
-                }
-
-                if (data.quick_actions && data.quick_actions.length > 0) {
-                    checksHtml += '<h3>Quick Actions:</h3>';
-                    checksHtml += '<ul>';
-                    data.quick_actions.forEach(action => {
-                        checksHtml += '<li>' + action + '</li>';
-                    });
-                    checksHtml += '</ul>';
-                }
-
-                checksHtml += '<br><button onclick="this.parentElement.remove()">Close</button>';
-                modal.innerHTML = checksHtml;
-                document.body.appendChild(modal);
-
-                btn.disabled = false;
-                btn.textContent = 'Run Health Check';
-            })
-            .catch(err => {
-                console.error('Health check error:', err);
-                alert('Health check failed: ' + err);
-                btn.disabled = false;
-                btn.textContent = 'Run Health Check';
-            });
-    }
-
-    updateDashboard();
-    setInterval(updateDashboard, 5000);
-    </script>
-</body>
-</html>`
-
-	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprint(w, html)
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var page bytes.Buffer
+	if err := renderDashboard(&page, dashboardPage{
+		Namespace:      namespace,
+		Context:        kubeContext,
+		RefreshSeconds: 5,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.WriteTo(w)
 }
 
-func serveHealthCheck(w http.ResponseWriter, r *http.Request) {
-	ctx := context.TODO()
-	healthScore := 100
-	var issues []string
-	var checks []HealthCheck
-
-	// Check ConfigHub connectivity
-	checks = append(checks, HealthCheck{
-		Component: "ConfigHub",
-		Check:     "Connection",
-		Status:    "HEALTHY",
-		Details:   "ConfigHub API accessible",
-	})
+// correctionsAutoApproved reports whether CORRECTION_APPROVAL=AUTO allows
+// corrections to be applied from the dashboard; otherwise (MANUAL, the
+// default) operators run the cub commands themselves
+func correctionsAutoApproved() bool {
+	return strings.EqualFold(os.Getenv("CORRECTION_APPROVAL"), "AUTO")
+}
+
+// ApplyCorrectionRequest confirms a correction by repeating the resource name
+type ApplyCorrectionRequest struct {
+	Confirm string `json:"confirm"`
+}
+
+// ApplyCorrectionResult reports a correction applied through ConfigHub
+type ApplyCorrectionResult struct {
+	Resource string `json:"resource"`
+	Unit     string `json:"unit"`
+	Space    string `json:"space"`
+	Replicas int32  `json:"replicas"`
+	Output   string `json:"output"`
+}
 
-	// Check Kubernetes connectivity
-	_, err := clientset.ServerVersion()
+// serveApplyCorrection patches and applies the ConfigHub unit behind a drifted
+// deployment (POST /api/v1/corrections/{resource}/apply)
+func serveApplyCorrection(w http.ResponseWriter, r *http.Request) {
+	resource, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, api.Prefix+"/corrections/"), "/apply")
+	if !ok || resource == "" || strings.Contains(resource, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !correctionsAutoApproved() {
+		http.Error(w, "corrections need manual approval; set CORRECTION_APPROVAL=AUTO to apply them from the dashboard", http.StatusForbidden)
+		return
+	}
+
+	var req ApplyCorrectionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "decode correction request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Confirm != resource {
+		http.Error(w, "confirm must repeat the resource name", http.StatusBadRequest)
+		return
+	}
+
+	declared, space := expectedState.get()
+	unit, exists := declared[resource]
+	if !exists {
+		http.Error(w, fmt.Sprintf("no ConfigHub unit declares %s", resource), http.StatusNotFound)
+		return
+	}
+
+	// Re-check the drift so a stale page can't apply an outdated correction
+	dep, err := clientset.AppsV1().Deployments(namespace).Get(r.Context(), resource, metav1.GetOptions{})
 	if err != nil {
-		healthScore -= 20
-		issues = append(issues, "Kubernetes: API not accessible")
-		checks = append(checks, HealthCheck{
-			Component: "Kubernetes",
-			Check:     "API Connection",
-			Status:    "UNHEALTHY",
-			Details:   err.Error(),
-		})
-	} else {
-		checks = append(checks, HealthCheck{
-			Component: "Kubernetes",
-			Check:     "API Connection",
-			Status:    "HEALTHY",
-			Details:   "Kubernetes API accessible",
-		})
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if *dep.Spec.Replicas == unit.Replicas {
+		http.Error(w, fmt.Sprintf("%s is not drifted", resource), http.StatusConflict)
+		return
 	}
 
-	// Check namespace
-	_, err = clientset.CoreV1().Namespaces().Get(ctx, "drift-test", metav1.GetOptions{})
+	output, err := ApplyConfigHubReplicas(space, unit.Unit, unit.Replicas)
 	if err != nil {
-		healthScore -= 10
-		issues = append(issues, "Kubernetes: Namespace drift-test not found")
-		checks = append(checks, HealthCheck{
-			Component: "Kubernetes",
-			Check:     "Namespace",
-			Status:    "UNHEALTHY",
-			Details:   "drift-test namespace not found",
-		})
-	} else {
-		checks = append(checks, HealthCheck{
-			Component: "Kubernetes",
-			Check:     "Namespace",
-			Status:    "HEALTHY",
-			Details:   "drift-test namespace exists",
-		})
+		http.Error(w, err.Error()+": "+output, http.StatusBadGateway)
+		return
+	}
+	slog.Info("Applied correction", "resource", resource,
+		logging.Space(space), logging.Unit(unit.Unit), "replicas", unit.Replicas)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ApplyCorrectionResult{
+		Resource: resource,
+		Unit:     unit.Unit,
+		Space:    space,
+		Replicas: unit.Replicas,
+		Output:   output,
+	})
+}
+
+// HealthConfigFile is the YAML document configuring the live dashboard health checks
+type HealthConfigFile struct {
+	ConfigHub           bool             `yaml:"confighub"`            // verify the cub CLI can reach ConfigHub
+	Namespaces          []string         `yaml:"namespaces"`           // must exist; deployments in them must be ready
+	ExpectedDeployments []string         `yaml:"expected_deployments"` // must exist in the first namespace
+	Endpoints           []HealthEndpoint `yaml:"endpoints"`
+	Timeout             time.Duration    `yaml:"timeout"` // per check, default 5s
+}
+
+// HealthEndpoint is an HTTP endpoint that must answer 200
+type HealthEndpoint struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// defaultHealthConfig checks ConfigHub, the monitored namespace and the local dashboards
+func defaultHealthConfig() HealthConfigFile {
+	return HealthConfigFile{
+		ConfigHub:  true,
+		Namespaces: []string{namespace},
+		Endpoints: []HealthEndpoint{
+			{Name: "Cost Optimizer", URL: "http://localhost:8081/api/v1/live"},
+			{Name: "Live Dashboard", URL: "http://localhost:8082/api/v1/live"},
+		},
 	}
+}
 
-	// Check deployments
-	deployments, err := clientset.AppsV1().Deployments("drift-test").List(ctx, metav1.ListOptions{})
+// LoadHealthConfig reads the health check configuration. A missing file
+// yields the defaults.
+func LoadHealthConfig(path string) (HealthConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultHealthConfig(), nil
+	}
 	if err != nil {
-		healthScore -= 15
-		issues = append(issues, "Kubernetes: Cannot list deployments")
-		checks = append(checks, HealthCheck{
-			Component: "Kubernetes",
-			Check:     "Deployments",
-			Status:    "UNHEALTHY",
-			Details:   err.Error(),
-		})
-	} else {
-		totalDeployments := len(deployments.Items)
-		healthyDeployments := 0
-		for _, dep := range deployments.Items {
-			if dep.Status.ReadyReplicas == *dep.Spec.Replicas && dep.Status.ReadyReplicas > 0 {
-				healthyDeployments++
-			} else {
-				issues = append(issues, fmt.Sprintf("Deployment %s: %d/%d replicas ready",
-					dep.Name, dep.Status.ReadyReplicas, *dep.Spec.Replicas))
-				healthScore -= 5
-			}
+		return HealthConfigFile{}, fmt.Errorf("read health config: %w", err)
+	}
+
+	var config HealthConfigFile
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return HealthConfigFile{}, fmt.Errorf("parse health config: %w", err)
+	}
+	for i, endpoint := range config.Endpoints {
+		if endpoint.Name == "" || endpoint.URL == "" {
+			return HealthConfigFile{}, fmt.Errorf("endpoint %d: name and url are required", i)
 		}
+	}
+	return config, nil
+}
 
-		checks = append(checks, HealthCheck{
-			Component: "Kubernetes",
-			Check:     "Deployments",
-			Status:    func() string {
-				if healthyDeployments == totalDeployments {
-					return "HEALTHY"
-				} else if healthyDeployments > 0 {
-					return "DEGRADED"
-				}
-				return "UNHEALTHY"
-			}(),
-			Details: fmt.Sprintf("%d/%d deployments healthy", healthyDeployments, totalDeployments),
-		})
+// HealthOutcome is the result of one health check with its effect on the score
+type HealthOutcome struct {
+	HealthCheck
+	Penalty     int      // subtracted from the health score
+	Issues      []string // problems found
+	QuickAction string   // suggested fix, reported when non-empty
+}
+
+// HealthCheckFunc runs one registered health check
+type HealthCheckFunc func(ctx context.Context) HealthOutcome
+
+// healthRegistry holds the health checks run by /api/v1/health, in order
+type healthRegistry struct {
+	checks  []HealthCheckFunc
+	timeout time.Duration
+}
+
+// Register adds a health check to the registry
+func (r *healthRegistry) Register(check HealthCheckFunc) {
+	r.checks = append(r.checks, check)
+}
+
+// newHealthRegistry registers the checks described by config
+func newHealthRegistry(config HealthConfigFile) *healthRegistry {
+	r := &healthRegistry{timeout: config.Timeout}
+	if r.timeout <= 0 {
+		r.timeout = 5 * time.Second
 	}
 
-	// Check for drift
-	driftDetected := false
-	driftCount := 0
-	for _, dep := range deployments.Items {
-		expectedReplicas := getExpectedReplicas(dep.Name)
-		actualReplicas := int(*dep.Spec.Replicas)
-		if expectedReplicas != actualReplicas {
-			driftDetected = true
-			driftCount++
-			healthScore -= 5
-			issues = append(issues, fmt.Sprintf("Drift: %s has %d replicas, expected %d",
-				dep.Name, actualReplicas, expectedReplicas))
+	if config.ConfigHub {
+		r.Register(checkConfigHub)
+	}
+	r.Register(checkKubernetesAPI)
+	for i, ns := range config.Namespaces {
+		var expected []string
+		if i == 0 {
+			expected = config.ExpectedDeployments
 		}
+		r.Register(checkNamespace(ns))
+		r.Register(checkDeployments(ns, expected))
+	}
+	r.Register(checkDrift)
+	for _, endpoint := range config.Endpoints {
+		r.Register(checkEndpoint(endpoint))
 	}
+	return r
+}
 
-	checks = append(checks, HealthCheck{
-		Component: "Drift Detection",
-		Check:     "Configuration Drift",
-		Status: func() string {
-			if !driftDetected {
-				return "HEALTHY"
-			}
-			return "DRIFTED"
-		}(),
-		Details: fmt.Sprintf("%d resources with drift", driftCount),
-	})
+// Run executes every check, each under its own timeout
+func (r *healthRegistry) Run(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
+		HealthScore: 100,
+	}
 
-	// Check API endpoints
-	apiEndpoints := []struct{
-		port string
-		name string
-	}{
-		{"8081", "Cost Optimizer"},
-		{"8082", "Live Dashboard"},
-	}
-
-	for _, endpoint := range apiEndpoints {
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%s/api/live", endpoint.port))
-		if err != nil || resp.StatusCode != 200 {
-			healthScore -= 10
-			issues = append(issues, fmt.Sprintf("API: %s not responding on port %s", endpoint.name, endpoint.port))
-			checks = append(checks, HealthCheck{
-				Component: "API",
-				Check:     endpoint.name,
-				Status:    "OFFLINE",
-				Details:   fmt.Sprintf("Port %s not responding", endpoint.port),
-			})
-		} else {
-			checks = append(checks, HealthCheck{
-				Component: "API",
-				Check:     endpoint.name,
-				Status:    "ONLINE",
-				Details:   fmt.Sprintf("Port %s responding", endpoint.port),
-			})
-			resp.Body.Close()
+	for _, check := range r.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		outcome := check(checkCtx)
+		cancel()
+
+		result.Checks = append(result.Checks, outcome.HealthCheck)
+		result.HealthScore -= outcome.Penalty
+		result.Issues = append(result.Issues, outcome.Issues...)
+		if outcome.QuickAction != "" {
+			result.QuickActions = append(result.QuickActions, outcome.QuickAction)
 		}
 	}
 
 	// Determine overall status
-	status := "HEALTHY"
-	statusText := "System is fully operational"
-	if healthScore >= 90 {
-		status = "HEALTHY"
-		statusText = "System is fully operational"
-	} else if healthScore >= 70 {
-		status = "DEGRADED"
-		statusText = "System has minor issues"
+	if result.HealthScore >= 90 {
+		result.Status = "HEALTHY"
+		result.StatusText = "System is fully operational"
+	} else if result.HealthScore >= 70 {
+		result.Status = "DEGRADED"
+		result.StatusText = "System has minor issues"
 	} else {
-		status = "CRITICAL"
-		statusText = "System has critical issues"
+		result.Status = "CRITICAL"
+		result.StatusText = "System has critical issues"
+	}
+	if result.HealthScore < 90 {
+		result.QuickActions = append(result.QuickActions, "Review issues above and take corrective action")
 	}
 
-	// Generate quick actions
-	var quickActions []string
-	if driftDetected {
-		quickActions = append(quickActions, "Fix drift: curl -s http://localhost:8082/api/live | jq -r '.corrections[].command'")
+	return result
+}
+
+// checkConfigHub verifies the cub CLI can reach ConfigHub
+func checkConfigHub(ctx context.Context) HealthOutcome {
+	check := HealthCheck{Component: "ConfigHub", Check: "Connection"}
+	if err := CheckConfigHubConnection(ctx); err != nil {
+		check.Status = "UNHEALTHY"
+		check.Details = err.Error()
+		return HealthOutcome{HealthCheck: check, Penalty: 20, Issues: []string{"ConfigHub: API not accessible"},
+			QuickAction: "Check ConfigHub access: cub auth login && cub space list"}
 	}
-	if healthScore < 90 {
-		quickActions = append(quickActions, "Review issues above and take corrective action")
+	check.Status = "HEALTHY"
+	check.Details = "ConfigHub API accessible"
+	return HealthOutcome{HealthCheck: check}
+}
+
+// checkKubernetesAPI verifies the cluster API server answers
+func checkKubernetesAPI(ctx context.Context) HealthOutcome {
+	check := HealthCheck{Component: "Kubernetes", Check: "API Connection"}
+	if _, err := clientset.ServerVersion(); err != nil {
+		check.Status = "UNHEALTHY"
+		check.Details = err.Error()
+		return HealthOutcome{HealthCheck: check, Penalty: 20, Issues: []string{"Kubernetes: API not accessible"}}
 	}
+	check.Status = "HEALTHY"
+	check.Details = "Kubernetes API accessible"
+	return HealthOutcome{HealthCheck: check}
+}
 
-	result := HealthCheckResult{
-		Timestamp:     time.Now().Format("2006-01-02 15:04:05"),
-		HealthScore:   healthScore,
-		Status:        status,
-		StatusText:    statusText,
-		Checks:        checks,
-		Issues:        issues,
-		QuickActions:  quickActions,
+// checkNamespace verifies a namespace exists
+func checkNamespace(ns string) HealthCheckFunc {
+	return func(ctx context.Context) HealthOutcome {
+		check := HealthCheck{Component: "Kubernetes", Check: "Namespace " + ns}
+		if _, err := clientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{}); err != nil {
+			check.Status = "UNHEALTHY"
+			check.Details = fmt.Sprintf("%s namespace not found", ns)
+			return HealthOutcome{HealthCheck: check, Penalty: 10, Issues: []string{fmt.Sprintf("Kubernetes: Namespace %s not found", ns)}}
+		}
+		check.Status = "HEALTHY"
+		check.Details = fmt.Sprintf("%s namespace exists", ns)
+		return HealthOutcome{HealthCheck: check}
+	}
+}
+
+// checkDeployments verifies the deployments in a namespace are ready and
+// that the expected ones exist
+func checkDeployments(ns string, expected []string) HealthCheckFunc {
+	return func(ctx context.Context) HealthOutcome {
+		check := HealthCheck{Component: "Kubernetes", Check: "Deployments in " + ns}
+		deployments, err := clientset.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			check.Status = "UNHEALTHY"
+			check.Details = err.Error()
+			return HealthOutcome{HealthCheck: check, Penalty: 15, Issues: []string{"Kubernetes: Cannot list deployments in " + ns}}
+		}
+
+		outcome := HealthOutcome{}
+		present := make(map[string]bool)
+		healthy := 0
+		for _, dep := range deployments.Items {
+			present[dep.Name] = true
+			if dep.Status.ReadyReplicas == *dep.Spec.Replicas && dep.Status.ReadyReplicas > 0 {
+				healthy++
+				continue
+			}
+			outcome.Issues = append(outcome.Issues, fmt.Sprintf("Deployment %s: %d/%d replicas ready",
+				dep.Name, dep.Status.ReadyReplicas, *dep.Spec.Replicas))
+			outcome.Penalty += 5
+		}
+		missing := 0
+		for _, name := range expected {
+			if !present[name] {
+				outcome.Issues = append(outcome.Issues, fmt.Sprintf("Deployment %s: missing from %s", name, ns))
+				outcome.Penalty += 10
+				missing++
+			}
+		}
+
+		total := len(deployments.Items) + missing
+		switch {
+		case healthy == total:
+			check.Status = "HEALTHY"
+		case healthy > 0:
+			check.Status = "DEGRADED"
+		default:
+			check.Status = "UNHEALTHY"
+		}
+		check.Details = fmt.Sprintf("%d/%d deployments healthy", healthy, total)
+		outcome.HealthCheck = check
+		return outcome
 	}
+}
+
+// checkDrift compares the monitored namespace with the ConfigHub expected state
+func checkDrift(ctx context.Context) HealthOutcome {
+	check := HealthCheck{Component: "Drift Detection", Check: "Configuration Drift"}
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		check.Status = "UNKNOWN"
+		check.Details = err.Error()
+		return HealthOutcome{HealthCheck: check}
+	}
+
+	outcome := HealthOutcome{}
+	expected, _ := expectedState.get()
+	driftCount := 0
+	for _, dep := range deployments.Items {
+		unit, declared := expected[dep.Name]
+		actualReplicas := *dep.Spec.Replicas
+		if declared && unit.Replicas != actualReplicas {
+			driftCount++
+			outcome.Penalty += 5
+			outcome.Issues = append(outcome.Issues, fmt.Sprintf("Drift: %s has %d replicas, expected %d",
+				dep.Name, actualReplicas, unit.Replicas))
+		}
+	}
+
+	check.Status = "HEALTHY"
+	if driftCount > 0 {
+		check.Status = "DRIFTED"
+		outcome.QuickAction = "Fix drift: curl -s http://localhost:8082/api/v1/live | jq -r '.corrections[].command'"
+	}
+	check.Details = fmt.Sprintf("%d resources with drift", driftCount)
+	outcome.HealthCheck = check
+	return outcome
+}
+
+// checkEndpoint verifies an HTTP endpoint answers 200
+func checkEndpoint(endpoint HealthEndpoint) HealthCheckFunc {
+	return func(ctx context.Context) HealthOutcome {
+		check := HealthCheck{Component: "API", Check: endpoint.Name}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.URL, nil)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					err = fmt.Errorf("status %d", resp.StatusCode)
+				}
+			}
+		}
+		if err != nil {
+			check.Status = "OFFLINE"
+			check.Details = fmt.Sprintf("%s not responding: %v", endpoint.URL, err)
+			return HealthOutcome{HealthCheck: check, Penalty: 10,
+				Issues: []string{fmt.Sprintf("API: %s not responding at %s", endpoint.Name, endpoint.URL)}}
+		}
+		check.Status = "ONLINE"
+		check.Details = fmt.Sprintf("%s responding", endpoint.URL)
+		return HealthOutcome{HealthCheck: check}
+	}
+}
+
+func serveHealthCheck(w http.ResponseWriter, r *http.Request) {
+	result := healthChecks.Run(r.Context())
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
-}
\ No newline at end of file
+}
@@ -0,0 +1,436 @@
+//go:build ignore
+
+// Tests of the live dashboard program, run by file list:
+//
+//	go test live-dashboard.go confighub-dynamic.go live-dashboard_test.go
+
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+func TestParseExpectedReplicas(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: Service
+metadata:
+  name: backend-api
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: backend-api
+spec:
+  replicas: 3
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: frontend-web
+`
+
+	replicas, err := parseExpectedReplicas([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parseExpectedReplicas: %v", err)
+	}
+	want := map[string]int32{"backend-api": 3, "frontend-web": 1}
+	if len(replicas) != len(want) {
+		t.Fatalf("replicas = %v, want %v", replicas, want)
+	}
+	for name, count := range want {
+		if replicas[name] != count {
+			t.Errorf("%s: replicas = %d, want %d", name, replicas[name], count)
+		}
+	}
+
+	if _, err := parseExpectedReplicas([]byte("kind: [")); err == nil {
+		t.Error("expected an error for malformed YAML")
+	}
+}
+
+func TestUsedResourcesMatchesSelector(t *testing.T) {
+	dep := appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "backend-api"}},
+		},
+	}
+	usage := func(app, cpu, memory string) metricsv1beta1.PodMetrics {
+		return metricsv1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": app}},
+			Containers: []metricsv1beta1.ContainerMetrics{{
+				Usage: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				},
+			}},
+		}
+	}
+
+	pods := []metricsv1beta1.PodMetrics{
+		usage("backend-api", "250m", "512Mi"),
+		usage("backend-api", "150m", "512Mi"),
+		usage("backend-api-canary", "1", "4Gi"),
+	}
+
+	cpu, memory := usedResources(dep, pods)
+	if math.Abs(cpu-0.4) > 1e-9 {
+		t.Errorf("cpu = %v, want 0.4", cpu)
+	}
+	if math.Abs(memory-1) > 1e-9 {
+		t.Errorf("memory = %v, want 1", memory)
+	}
+}
+
+func TestApplyCorrectionGuards(t *testing.T) {
+	tests := []struct {
+		name     string
+		approval string
+		method   string
+		path     string
+		body     string
+		want     int
+	}{
+		{"unknown route", "AUTO", http.MethodPost, "/api/v1/corrections/backend-api", `{}`, http.StatusNotFound},
+		{"not a post", "AUTO", http.MethodGet, "/api/v1/corrections/backend-api/apply", ``, http.StatusMethodNotAllowed},
+		{"manual approval", "MANUAL", http.MethodPost, "/api/v1/corrections/backend-api/apply", `{"confirm":"backend-api"}`, http.StatusForbidden},
+		{"approval unset", "", http.MethodPost, "/api/v1/corrections/backend-api/apply", `{"confirm":"backend-api"}`, http.StatusForbidden},
+		{"wrong confirmation", "AUTO", http.MethodPost, "/api/v1/corrections/backend-api/apply", `{"confirm":"frontend-web"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORRECTION_APPROVAL", tt.approval)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			serveApplyCorrection(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHistorySeries(t *testing.T) {
+	h := &historyStore{}
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	snapshot := func(offset time.Duration, total, drift float64) DashboardData {
+		return DashboardData{LastRefresh: start.Add(offset), TotalCost: total, DriftCost: drift}
+	}
+
+	h.record(snapshot(0, 100, 10))
+	h.record(snapshot(30*time.Second, 500, 50)) // within historyInterval, dropped
+	h.record(snapshot(2*time.Minute, 120, 30))
+	h.record(snapshot(6*time.Minute, 140, 0))
+	h.record(DashboardData{LastRefresh: start.Add(8 * time.Minute), Status: "error: forbidden"})
+
+	series := h.series(24*time.Hour, 5*time.Minute, start.Add(10*time.Minute))
+	if len(series) != 2 {
+		t.Fatalf("series = %+v, want 2 buckets", series)
+	}
+	if series[0].TotalCost != 110 || series[0].DriftCost != 20 {
+		t.Errorf("first bucket = %+v, want averages 110/20", series[0])
+	}
+	if series[1].TotalCost != 140 || series[1].DriftCost != 0 {
+		t.Errorf("second bucket = %+v, want 140/0", series[1])
+	}
+
+	if got := h.series(time.Minute, time.Minute, start.Add(48*time.Hour)); len(got) != 0 {
+		t.Errorf("series outside window = %+v, want none", got)
+	}
+}
+
+func TestHistoryLoadDropsExpiredPoints(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	lines := `{"time":"2026-10-01T12:00:00Z","total_monthly_cost":90,"drift_cost":5}
+{"time":"2026-10-17T11:00:00Z","total_monthly_cost":100,"drift_cost":10}
+not json
+`
+	if err := os.WriteFile(path, []byte(lines), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &historyStore{}
+	if err := h.load(path, now); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(h.points) != 1 || h.points[0].TotalCost != 100 {
+		t.Fatalf("points = %+v, want only the recent one", h.points)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\n") != 1 {
+		t.Errorf("history file not compacted:\n%s", data)
+	}
+}
+
+func TestLoadHealthConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := LoadHealthConfig(filepath.Join(dir, "missing.yaml"))
+	if err != nil || !config.ConfigHub || len(config.Endpoints) != 2 {
+		t.Fatalf("missing file: config = %+v, err = %v; want defaults", config, err)
+	}
+
+	path := filepath.Join(dir, "health.yaml")
+	os.WriteFile(path, []byte(`confighub: false
+namespaces: [prod, staging]
+expected_deployments: [backend-api]
+endpoints:
+  - name: Drift Detector
+    url: http://drift-detector:8080/health
+timeout: 2s
+`), 0o600)
+	config, err = LoadHealthConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHealthConfig: %v", err)
+	}
+	if config.ConfigHub || len(config.Namespaces) != 2 || config.Timeout != 2*time.Second ||
+		config.Endpoints[0].Name != "Drift Detector" {
+		t.Errorf("config = %+v", config)
+	}
+
+	os.WriteFile(path, []byte("endpoints:\n  - name: No URL\n"), 0o600)
+	if _, err := LoadHealthConfig(path); err == nil {
+		t.Error("expected an error for an endpoint without a url")
+	}
+}
+
+func TestHealthRegistryRun(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	r := &healthRegistry{timeout: time.Second}
+	r.Register(checkEndpoint(HealthEndpoint{Name: "Up", URL: up.URL}))
+	r.Register(checkEndpoint(HealthEndpoint{Name: "Down", URL: down.URL}))
+	r.Register(func(ctx context.Context) HealthOutcome {
+		return HealthOutcome{
+			HealthCheck: HealthCheck{Component: "Custom", Check: "Plugged in", Status: "DEGRADED"},
+			Penalty:     15,
+			Issues:      []string{"custom issue"},
+			QuickAction: "fix the custom thing",
+		}
+	})
+
+	result := r.Run(context.Background())
+	if result.HealthScore != 75 || result.Status != "DEGRADED" {
+		t.Errorf("score = %d (%s), want 75 (DEGRADED)", result.HealthScore, result.Status)
+	}
+	if len(result.Checks) != 3 || result.Checks[0].Status != "ONLINE" || result.Checks[1].Status != "OFFLINE" {
+		t.Errorf("checks = %+v", result.Checks)
+	}
+	if len(result.Issues) != 2 || result.Issues[1] != "custom issue" {
+		t.Errorf("issues = %v", result.Issues)
+	}
+	if len(result.QuickActions) != 2 || result.QuickActions[0] != "fix the custom thing" {
+		t.Errorf("quick actions = %v", result.QuickActions)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	handler := requireAuth(&basicAuth{user: "ops", password: "s3cret"},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		want     int
+	}{
+		{"valid", "ops", "s3cret", http.StatusOK},
+		{"wrong password", "ops", "guess", http.StatusUnauthorized},
+		{"no credentials", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/live", nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureAuth(t *testing.T) {
+	tests := []struct {
+		mode    string
+		user    string
+		wantErr bool
+		wantNil bool
+	}{
+		{mode: "", wantNil: true},
+		{mode: "none", wantNil: true},
+		{mode: "basic", user: "ops"},
+		{mode: "basic", wantErr: true},
+		{mode: "oidc", wantErr: true}, // no issuer configured
+		{mode: "ldap", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("LIVE_AUTH", tt.mode)
+		t.Setenv("LIVE_BASIC_AUTH_USER", tt.user)
+		t.Setenv("LIVE_BASIC_AUTH_PASSWORD", "s3cret")
+		t.Setenv("OIDC_ISSUER_URL", "")
+		t.Setenv("AUTH_CONFIG", "")
+
+		protect, err := configureAuth(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LIVE_AUTH=%q user=%q: err = %v, wantErr %v", tt.mode, tt.user, err, tt.wantErr)
+		}
+		if !tt.wantErr && (protect == nil) != tt.wantNil {
+			t.Errorf("LIVE_AUTH=%q: protect set %v, wantNil %v", tt.mode, protect != nil, tt.wantNil)
+		}
+	}
+}
+
+func TestRenderDashboard(t *testing.T) {
+	var page strings.Builder
+	err := renderDashboard(&page, dashboardPage{
+		Namespace:      `<script>alert("x")</script>`,
+		Context:        "kind-devops-test",
+		RefreshSeconds: 10,
+	})
+	if err != nil {
+		t.Fatalf("renderDashboard: %v", err)
+	}
+
+	html := page.String()
+	for _, want := range []string{
+		`data-refresh-seconds="10"`,
+		`<span id="cluster-context">kind-devops-test</span>`,
+		`&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;`,
+		`href="/static/dashboard.css"`,
+		`src="/static/dashboard.js"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("page missing %s", want)
+		}
+	}
+	if strings.Contains(html, `<script>alert`) {
+		t.Error("namespace was not escaped")
+	}
+}
+
+func TestServeStaticFiles(t *testing.T) {
+	static := http.StripPrefix("/static/", http.FileServer(http.FS(staticFiles)))
+	for _, path := range []string{"/static/dashboard.css", "/static/dashboard.js"} {
+		rec := httptest.NewRecorder()
+		static.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("%s: status %d, %d bytes", path, rec.Code, rec.Body.Len())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	serveDashboard(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown page: status %d", rec.Code)
+	}
+}
+
+func TestClaudeAnalyzer(t *testing.T) {
+	var prompts []string
+	a := &claudeAnalyzer{complete: func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return "\nbackend-api was scaled up by hand.\nScale it back in ConfigHub.", nil
+	}}
+	_, calls, unsubscribe := a.subscribe()
+	defer unsubscribe()
+
+	a.analyze(DashboardData{})
+	if len(prompts) != 0 || a.info().Summary != "No drift detected" {
+		t.Fatalf("no drift: prompts=%d info=%+v", len(prompts), a.info())
+	}
+
+	a.analyze(DashboardData{
+		DriftDetected: true,
+		DriftCost:     12.8,
+		ClusterInfo:   ClusterInfo{Namespace: "drift-test"},
+		Resources: []Resource{
+			{Name: "backend-api", ActualReplicas: 5, ExpectedReplicas: 3, MonthlyCost: 32, IsDrifted: true},
+			{Name: "frontend", ActualReplicas: 2, ExpectedReplicas: 2},
+		},
+	})
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "backend-api: 5 replicas running, 3 expected") ||
+		strings.Contains(prompts[0], "frontend") {
+		t.Fatalf("prompts = %q", prompts)
+	}
+
+	info := a.info()
+	if info.Summary != "backend-api was scaled up by hand." || info.LastRun == "" || len(info.Logs) != 1 {
+		t.Errorf("info = %+v", info)
+	}
+	select {
+	case call := <-calls:
+		if call.Prompt != prompts[0] {
+			t.Errorf("streamed prompt = %q", call.Prompt)
+		}
+	default:
+		t.Error("call was not streamed")
+	}
+}
+
+func TestDriftDetectorClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/drift" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+			"checked_at": "2026-10-17T12:00:00Z",
+			"space": "drift-detector",
+			"namespace": "drift-test",
+			"analysis": {"has_drift": true, "items": [
+				{"unit_slug": "backend-api-unit", "resource": "Deployment/backend-api", "field": "spec.replicas", "expected": "3", "actual": "5"},
+				{"unit_slug": "frontend-unit", "resource": "Deployment/frontend", "field": "spec.template.spec.containers[0].image", "expected": "v1", "actual": "v2"},
+				{"unit_slug": "cache-unit", "resource": "ConfigMap/cache", "field": "spec.replicas", "expected": "1", "actual": "2"}
+			]}
+		}`))
+	}))
+	defer server.Close()
+
+	expected, space, err := newDriftDetectorClient(server.URL + "/").expectedState(context.Background())
+	if err != nil {
+		t.Fatalf("expectedState: %v", err)
+	}
+	if space != "drift-detector" {
+		t.Errorf("space = %q", space)
+	}
+	want := map[string]ExpectedDeployment{"backend-api": {Unit: "backend-api-unit", Replicas: 3}}
+	if len(expected) != len(want) || expected["backend-api"] != want["backend-api"] {
+		t.Errorf("expected = %+v, want %+v", expected, want)
+	}
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "drift detection has not run yet", http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	if _, _, err := newDriftDetectorClient(unavailable.URL).expectedState(context.Background()); err == nil ||
+		!strings.Contains(err.Error(), "has not run yet") {
+		t.Errorf("err = %v", err)
+	}
+}
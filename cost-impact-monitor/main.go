@@ -1,26 +1,83 @@
-package main
+package costimpactmonitor
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/export"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/notify"
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/pricinghints"
+	"github.com/monadic/devops-examples/pkg/prompts"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/shared"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	"github.com/monadic/devops-examples/pkg/tracing"
 	sdk "github.com/monadic/devops-sdk"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // CostImpactMonitor monitors ConfigHub for cost impacts of deployments
 type CostImpactMonitor struct {
 	app              *sdk.DevOpsApp
+	config           Config
 	monitoredSpaces  map[uuid.UUID]*SpaceMonitor
+	terraformPlans   map[string]*TerraformPlanImpact
 	triggerProcessor *TriggerProcessor
 	dashboard        *MonitorDashboard
+	webhooks         *WebhookReceiver
+	escalations      *EscalationEngine
+	clusters         *ClusterRegistry
+	pricing          costmodel.Pricing // rates of the configured cloud
+	leader           *LeaderElector
+	notifier         *notify.Notifier
+	claude           claudeClient // nil without an API key, unless claude_mode is stub
+	llmClient        *llm.Client // usage and budget of claude; nil unless calling the API
+	flags            *flags.Set
+	prompts          *prompts.Set // AI prompts, replaceable from ConfigHub
+	cubLimit         *ratelimit.Limiter // throttles and coalesces ConfigHub reads
+	audit            *audit.Log
+	policy           *policy.Policy // may hold back changes the escalation lets through
+	pending          *pending.Queue // escalated changes awaiting approval
+	metrics          *metrics.Registry
+	pusher           *metrics.Pusher    // Datadog and New Relic pushes; nil without a sinks file
+	exporter         *export.Exporter   // cost snapshots and deployment history to S3 or GCS; nil without an export file
+	bus              *events.Bus        // change.pending out, drift in; nil without nats_url
+	teams            *tenants.Registry  // nil when single-tenant
+	guard            *auth.Guard        // OIDC login on the dashboard; nil leaves it open
+	teamCubs         map[string]*sdk.ConfigHubClient
+	cubBreaker       *breaker.Breaker
+	claudeBreaker    *breaker.Breaker
+	stateFile        string
+	analysisWorkers  int
+	spaceTimeout     time.Duration
+	accuracy         AccuracyConfig
+	warningRetention time.Duration
+	warnedRevisions  map[string]int64 // last unit revision a cost warning was raised for
+	shutdownOnce     sync.Once
 	mu               sync.RWMutex
 }
 
@@ -28,12 +85,19 @@ type CostImpactMonitor struct {
 type SpaceMonitor struct {
 	SpaceID          uuid.UUID              `json:"space_id"`
 	SpaceName        string                 `json:"space_name"`
+	Team             string                 `json:"team,omitempty"` // owning team, see TEAMS_CONFIG
 	LastAnalysis     time.Time              `json:"last_analysis"`
 	CurrentCost      float64                `json:"current_cost"`
 	ProjectedCost    float64                `json:"projected_cost"`
 	PendingChanges   []PendingChange        `json:"pending_changes"`
 	DeploymentHistory []DeploymentCostRecord `json:"deployment_history"`
 	CostTrend        CostTrend              `json:"cost_trend"`
+	Baseline         *CostBaseline          `json:"baseline,omitempty"`
+	SpendLimit       *SpendLimit            `json:"spend_limit,omitempty"`
+	UnitCosts        []UnitCost             `json:"-"` // served by /api/v1/spaces/{id}/units
+	DeletedUnits     map[string]PendingChange `json:"-"` // recent deletions, see activeDeletions
+	Drift            []PendingChange        `json:"-"` // drift-detector's latest drift, see activeDrift
+	AnalysisStats    SpaceAnalysisStats     `json:"analysis_stats"`
 }
 
 // PendingChange represents a unit change awaiting deployment
@@ -45,19 +109,24 @@ type PendingChange struct {
 	ProjectedCost    float64   `json:"projected_cost"`
 	CostDelta        float64   `json:"cost_delta"`
 	RiskLevel        string    `json:"risk_level"` // "low", "medium", "high"
+	RiskFactors      []string  `json:"risk_factors,omitempty"`
 	AnalysisTime     time.Time `json:"analysis_time"`
 	ClaudeAssessment string    `json:"claude_assessment"`
+	CorrelationID    string    `json:"correlation_id,omitempty"` // the drift it undoes, see pkg/correlation
 }
 
 // DeploymentCostRecord tracks actual vs predicted costs
 type DeploymentCostRecord struct {
 	UnitID        string    `json:"unit_id"`
 	UnitName      string    `json:"unit_name"`
+	SpaceName     string    `json:"space_name"`
+	Target        string    `json:"target"` // cluster the actual cost was measured on
+	ChangeType    string    `json:"change_type"` // "create" or "update"
 	DeployTime    time.Time `json:"deploy_time"`
 	PredictedCost float64   `json:"predicted_cost"`
 	ActualCost    float64   `json:"actual_cost"`
 	Variance      float64   `json:"variance"`
-	Accurate      bool      `json:"accurate"` // Within 10% of prediction
+	Accurate      bool      `json:"accurate"` // Within ACCURACY_TOLERANCE_PERCENT of prediction
 }
 
 // CostTrend tracks cost direction over time
@@ -88,6 +157,7 @@ type PostApplyHook func(unit *sdk.Unit, actual *ActualUsage) error
 type CostImpact struct {
 	UnitID         string                 `json:"unit_id"`
 	UnitName       string                 `json:"unit_name"`
+	SpaceID        string                 `json:"space_id,omitempty"`
 	MonthlyCost    float64                `json:"monthly_cost"`
 	CostDelta      float64                `json:"cost_delta"`
 	ResourceChanges map[string]interface{} `json:"resource_changes"`
@@ -100,12 +170,15 @@ type RiskAssessment struct {
 	Factors     []string `json:"factors"`
 	Recommendation string   `json:"recommendation"`
 	AutoApprove bool     `json:"auto_approve"`
+	Stage       string     `json:"escalation_stage,omitempty"` // "notify", "approval", "block", "approved"
+	Deadline    *time.Time `json:"escalation_deadline,omitempty"`
 }
 
 // ActualUsage represents real resource consumption
 type ActualUsage struct {
 	UnitID       string  `json:"unit_id"`
 	UnitName     string  `json:"unit_name"`
+	Target       string  `json:"target"`
 	CPUCores     float64 `json:"cpu_cores"`
 	MemoryGB     float64 `json:"memory_gb"`
 	StorageGB    float64 `json:"storage_gb"`
@@ -118,48 +191,242 @@ type ChangeDetector struct {
 	monitor       *CostImpactMonitor
 	pollInterval  time.Duration
 	unitCache     map[string]*sdk.Unit
-	revisionCache map[string]int
+	revisionCache map[string]int    // last processed HeadRevisionNum per unit
+	statusCache   map[string]string // last processed live status per unit
 }
 
-func main() {
+// Main runs the monitor until it is interrupted, or once with --mode=job. It
+// backs cmd/cost-impact-monitor and "devops-apps impact".
+func Main() {
+	logging.Setup("cost-impact-monitor")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "cost-impact-monitor", "1.0.0")
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+
 	monitor, err := NewCostImpactMonitor()
 	if err != nil {
-		log.Fatalf("Failed to initialize cost impact monitor: %v", err)
+		logging.Fatal("Failed to initialize cost impact monitor", logging.Err(err))
+	}
+
+	slog.Info("Cost Impact Monitor started, monitoring all ConfigHub spaces")
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		// A job runs alone, so it acts as the leader without a lease
+		monitor.leader.lead()
+		analyze := func(context.Context) error { return monitor.monitorAllSpaces() }
+		store := newJobStore(monitor.app.Cub, monitor.cubLimit, runmode.Space())
+		err := runmode.Once(ctx, "cost-impact-monitor", analyze, monitor.getMonitoringSnapshot, os.Stdout, store)
+		monitor.pusher.Push(ctx)
+		err = errors.Join(err, monitor.exporter.Flush(ctx))
+		monitor.shutdown()
+		shutdownTracing(context.Background())
+		if err != nil {
+			logging.Fatal("Monitoring failed", logging.Err(err))
+		}
+		return
+	}
+
+	// Pick up what the previous leader checkpointed to ConfigHub
+	checkpoints := newCheckpointer(monitor.app.Cub, monitor.cubLimit, monitor.config.CheckpointSpace)
+	monitor.registerCheckpoint(checkpoints)
+	if err := checkpoints.Restore(ctx); err != nil {
+		slog.Warn("Failed to restore checkpoint", logging.Err(err))
 	}
+	group := lifecycle.New(ctx)
+
+	// Campaign for leadership; every replica serves the dashboard
+	group.Go(func(ctx context.Context) error {
+		if err := monitor.leader.Run(ctx); err != nil {
+			slog.Error("Leader election stopped", logging.Err(err))
+		}
+		return nil
+	})
+	group.Go(func(ctx context.Context) error {
+		monitor.dashboard.Start(ctx)
+		return nil
+	})
+	group.Go(func(ctx context.Context) error {
+		monitor.pusher.Run(ctx) // flushes on shutdown before the group is done
+		return nil
+	})
+	group.Go(func(ctx context.Context) error {
+		monitor.exporter.Run(ctx)
+		return nil
+	})
+	group.Go(func(ctx context.Context) error {
+		checkpoints.Run(ctx, monitor.config.CheckpointInterval) // saves once more on shutdown
+		return nil
+	})
 
-	log.Println("🚀 Cost Impact Monitor started - Monitoring all ConfigHub spaces")
+	// On SIGTERM, release the lease, let the dashboard finish its requests
+	// and persist state before exiting
+	go func() {
+		<-ctx.Done()
+		stopped := make(chan struct{})
+		go func() {
+			group.Wait()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(lifecycle.DefaultGrace):
+		}
+		monitor.shutdown()
+		shutdownTracing(context.Background())
+		os.Exit(0)
+	}()
 
-	// Start dashboard
-	go monitor.dashboard.Start()
+	// Follow feature-flag and prompt overrides
+	go monitor.flags.Watch(ctx, monitor.config.FlagsRefresh)
+	go monitor.prompts.Watch(ctx, monitor.config.PromptsRefresh)
 
-	// Start trigger processor
+	// Start trigger processor and escalation timers
 	go monitor.triggerProcessor.Start()
+	go monitor.escalations.Start(1 * time.Minute)
+	go monitor.processPending(ctx, 1*time.Minute)
+
+	// Price the drift-detector's drift as it is detected, from its stream
+	// or else from the event bus
+	if monitor.config.DriftStreamAddr != "" {
+		go monitor.followDrift(ctx)
+	} else {
+		monitor.bus.Subscribe("drift.*", monitor.applyDriftEvent)
+	}
+
+	// Analyze Terraform plans dropped into a shared directory
+	if dir := monitor.config.TerraformPlanDir; dir != "" {
+		go monitor.WatchTerraformPlans(dir)
+	}
 
 	// Run main monitoring loop with informers
 	err = monitor.app.RunWithInformers(func() error {
 		return monitor.monitorAllSpaces()
 	})
+	monitor.shutdown()
+	shutdownTracing(context.Background())
 	if err != nil {
-		log.Fatalf("Monitoring failed: %v", err)
+		logging.Fatal("Monitoring failed", logging.Err(err))
 	}
 }
 
+// shutdown persists monitor state so a restarted pod resumes where this one stopped
+func (m *CostImpactMonitor) shutdown() {
+	m.shutdownOnce.Do(func() {
+		if m.stateFile == "" {
+			return
+		}
+		if err := m.saveState(m.stateFile); err != nil {
+			slog.Error("Failed to save state", "file", m.stateFile, logging.Err(err))
+			return
+		}
+		slog.Info("Saved monitor state", "file", m.stateFile)
+	})
+}
+
 // NewCostImpactMonitor creates a new cost impact monitor
 func NewCostImpactMonitor() (*CostImpactMonitor, error) {
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
 	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
-		Name:        "cost-impact-monitor",
-		Version:     "1.0.0",
-		Description: "Monitor ConfigHub deployments for cost impact",
-		RunInterval: 1 * time.Minute, // Check for changes every minute
-		HealthPort:  8082,
+		Name:         "cost-impact-monitor",
+		Version:      "1.0.0",
+		Description:  "Monitor ConfigHub deployments for cost impact",
+		RunInterval:  cfg.RunInterval, // Check for changes every minute by default
+		HealthPort:   shared.HealthPort("cost-impact-monitor", 8082),
+		ClaudeAPIKey: cfg.ClaudeAPIKey,
+		CubToken:     cfg.CubToken,
+		CubBaseURL:   cfg.CubAPIURL,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create DevOps app: %w", err)
 	}
+	app.Logger = logging.StdLogger(slog.Default())
+	effective.Log(logging.Printf(slog.Default()))
+
+	teams, err := tenants.Load(cfg.TeamsConfig, effective.ReadSecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("load teams config: %w", err)
+	}
+	if teams != nil {
+		slog.Info("Multi-tenant mode, each team sees its own spaces", "teams", len(teams.Teams()))
+	}
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("load auth config: %w", err)
+	}
+	guard.AcceptTokens(teams.HasToken)
+	go effective.WatchSecrets(context.Background(), 30*time.Second, config.RestartOnRotation)
 
 	monitor := &CostImpactMonitor{
-		app:             app,
-		monitoredSpaces: make(map[uuid.UUID]*SpaceMonitor),
+		app:              app,
+		config:           cfg,
+		monitoredSpaces:  make(map[uuid.UUID]*SpaceMonitor),
+		terraformPlans:   make(map[string]*TerraformPlanImpact),
+		warnedRevisions:  make(map[string]int64),
+		leader:           NewLeaderElector(app, cfg),
+		teams:            teams,
+		guard:            guard,
+		teamCubs:         newTeamClients(teams, cfg.CubAPIURL),
+		stateFile:        cfg.StateFile,
+		analysisWorkers:  cfg.AnalysisConcurrency,
+		spaceTimeout:     cfg.SpaceAnalysisTimeout,
+		accuracy:         AccuracyConfig{TolerancePercent: cfg.AccuracyTolerancePercent},
+		warningRetention: cfg.CostWarningRetention,
+		cubBreaker: shared.Value("confighub-breaker", func() *breaker.Breaker {
+			return breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+		}),
+		claudeBreaker: breaker.New("claude", cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+	claude := newClaudeClient(cfg)
+	monitor.llmClient, _ = claude.(*llm.Client)
+	monitor.claude = guardClaude(claude, monitor.claudeBreaker)
+	monitor.cubLimit = shared.Value("confighub-limiter", func() *ratelimit.Limiter {
+		return ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(monitor.cubBreaker)
+	})
+	monitor.flags = flags.New("cost-impact-monitor", map[string]bool{flags.CostGating: cfg.CostGating}, flagsSource(app.Cub, monitor.cubLimit, cfg.FlagsSpace))
+	monitor.prompts = prompts.New([]prompts.Prompt{changeAssessmentPrompt, whatIfAssessmentPrompt}, promptsSource(app.Cub, monitor.cubLimit, cfg.PromptsSpace))
+	monitor.audit = newAuditLog(app.Cub, monitor.cubLimit, cfg.AuditSpace)
+	if monitor.pending, err = newPendingQueue(app.Cub, monitor.cubLimit, cfg.PendingSpace, cfg.PendingDir); err != nil {
+		return nil, fmt.Errorf("set up pending queue: %w", err)
+	}
+	monitor.metrics = metrics.Setup("cost-impact-monitor", app.Version, cfg.ClusterName)
+	if cfg.Pprof {
+		metrics.Profile(http.DefaultServeMux)
+	}
+	monitor.metrics.Collect(metrics.Limiter(monitor.cubLimit))
+	monitor.metrics.Collect(metrics.ListCache(listcache.Install()))
+	monitor.metrics.Collect(metrics.Breakers(monitor.cubBreaker, monitor.claudeBreaker))
+	monitor.metrics.Collect(metrics.LLM(monitor.llmClient))
+	monitor.metrics.Collect(monitor.collect)
+	if monitor.pusher, err = metrics.LoadSinks(cfg.MetricsSinks, effective.ReadSecretFile, monitor.metrics); err != nil {
+		return nil, fmt.Errorf("load metrics sinks config: %w", err)
+	}
+	if monitor.exporter, err = export.Load(cfg.ExportConfig, "cost-impact-monitor", effective.ReadSecretFile); err != nil {
+		return nil, fmt.Errorf("load export config: %w", err)
+	}
+	checker := health.New()
+	checker.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	checker.Register("confighub", health.Required, health.Breaker(monitor.cubBreaker))
+	checker.Register("claude", health.Optional, health.Breaker(monitor.claudeBreaker))
+	checker.Mount(http.DefaultServeMux)
+	if monitor.bus, err = events.Connect(cfg.NATSURL, cfg.NATSToken, cfg.NATSSubjectPrefix, "cost-impact-monitor"); err != nil {
+		return nil, fmt.Errorf("connect event bus: %w", err)
+	}
+	if cfg.ClaudeMode == claudestub.ModeStub {
+		slog.Info("Claude stub mode, risk assessments are canned")
+	}
+
+	monitor.pricing, _ = costmodel.Lookup(cfg.CloudProvider, cfg.CloudRegion)
+	monitor.clusters, err = NewClusterRegistry(app, cfg.TargetKubeconfigs, monitor.pricing)
+	if err != nil {
+		return nil, fmt.Errorf("configure target clusters: %w", err)
 	}
 
 	// Initialize trigger processor
@@ -171,55 +438,110 @@ func NewCostImpactMonitor() (*CostImpactMonitor, error) {
 			pollInterval:  30 * time.Second,
 			unitCache:     make(map[string]*sdk.Unit),
 			revisionCache: make(map[string]int),
+			statusCache:   make(map[string]string),
 		},
 	}
 
-	// Register default hooks
+	// Register default hooks, then any user-defined ones
 	monitor.registerDefaultHooks()
+	if err := monitor.registerConfiguredHooks(cfg.HooksConfig); err != nil {
+		return nil, fmt.Errorf("load hooks: %w", err)
+	}
+
+	// Per-environment escalation policies decide what happens to risky changes
+	policies, err := LoadEscalationPolicies(cfg.EscalationConfig)
+	if err != nil {
+		return nil, fmt.Errorf("load escalation policies: %w", err)
+	}
+	monitor.escalations = NewEscalationEngine(policies, monitor.onEscalation)
+	monitor.handlePending()
+	if monitor.policy, err = policy.Load(cfg.PolicyConfig); err != nil {
+		return nil, fmt.Errorf("load policy: %w", err)
+	}
 
-	// Initialize dashboard
+	// Slack, webhook and PagerDuty routing shared with the other apps
+	monitor.notifier, err = notify.Load(cfg.NotifyConfig)
+	if err != nil {
+		return nil, fmt.Errorf("load notify config: %w", err)
+	}
+
+	// Initialize dashboard and inbound webhooks
 	monitor.dashboard = NewMonitorDashboard(monitor)
+	monitor.webhooks = NewWebhookReceiver(monitor, cfg.WebhookSecret)
 
 	// Discover and register all ConfigHub spaces
 	if err := monitor.discoverSpaces(); err != nil {
 		return nil, fmt.Errorf("discover spaces: %w", err)
 	}
 
+	// Restore history and trigger timestamps from the previous run
+	if monitor.stateFile != "" {
+		state, err := monitor.restoreState(monitor.stateFile)
+		if err != nil {
+			slog.Warn("Failed to restore state", "file", monitor.stateFile, logging.Err(err))
+		} else if state != nil {
+			slog.Info("Restored state",
+				"spaces", len(state.Spaces), "saved_at", state.SavedAt.Format(time.RFC3339))
+		}
+	}
+
 	return monitor, nil
 }
 
 // discoverSpaces finds all ConfigHub spaces to monitor
 func (m *CostImpactMonitor) discoverSpaces() error {
-	if m.app.Cub == nil {
-		m.app.Logger.Println("⚠️  ConfigHub not configured - running in demo mode")
+	sources := m.spaceSources()
+	if len(sources) == 0 {
+		slog.Warn("ConfigHub not configured, running in demo mode")
 		return nil
 	}
 
-	// List all spaces
-	spaces, err := m.app.Cub.ListSpaces()
-	if err != nil {
-		return fmt.Errorf("list spaces: %w", err)
+	// List the spaces each team's token sees; a space shared by several
+	// teams belongs to the first one listed in the teams config
+	discovered := make([]*SpaceMonitor, 0)
+	claimed := make(map[uuid.UUID]bool)
+	for _, source := range sources {
+		spaces, err := ratelimit.Call(context.Background(), m.cubLimit, "ListSpaces", source.key(), source.cub.ListSpaces)
+		if err != nil {
+			return fmt.Errorf("list spaces: %w", err)
+		}
+		for _, space := range spaces {
+			if claimed[space.SpaceID] || !source.team.OwnsSpace(space.Slug) {
+				continue
+			}
+			claimed[space.SpaceID] = true
+			monitor := &SpaceMonitor{
+				SpaceID:          space.SpaceID,
+				SpaceName:        space.Slug,
+				LastAnalysis:     time.Now(),
+				DeploymentHistory: make([]DeploymentCostRecord, 0),
+			}
+			if source.team != nil {
+				monitor.Team = source.team.Name
+			}
+			discovered = append(discovered, monitor)
+		}
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, space := range spaces {
-		m.monitoredSpaces[space.SpaceID] = &SpaceMonitor{
-			SpaceID:          space.SpaceID,
-			SpaceName:        space.Slug,
-			LastAnalysis:     time.Now(),
-			DeploymentHistory: make([]DeploymentCostRecord, 0),
-		}
-		m.app.Logger.Printf("📦 Monitoring space: %s (%s)", space.Slug, space.SpaceID)
+	for _, space := range discovered {
+		m.monitoredSpaces[space.SpaceID] = space
+		slog.Info("Monitoring space", logging.Space(space.SpaceName), "space_id", space.SpaceID, "team", space.Team)
 	}
 
-	m.app.Logger.Printf("🔍 Discovered %d ConfigHub spaces to monitor", len(spaces))
+	slog.Info("Discovered ConfigHub spaces to monitor", "spaces", len(discovered))
 	return nil
 }
 
 // monitorAllSpaces analyzes costs across all ConfigHub spaces
 func (m *CostImpactMonitor) monitorAllSpaces() error {
+	// One trace per pass, with a child span per space
+	ctx, span := tracing.Start(context.Background(), "impact.monitorSpaces")
+	defer span.End()
+	defer m.metrics.Cycle("monitor", "")(nil)
+
 	m.mu.RLock()
 	spaces := make([]*SpaceMonitor, 0, len(m.monitoredSpaces))
 	for _, space := range m.monitoredSpaces {
@@ -227,15 +549,19 @@ func (m *CostImpactMonitor) monitorAllSpaces() error {
 	}
 	m.mu.RUnlock()
 
-	// Analyze each space in parallel
+	// Pick up monthly-spend-limit label changes
+	m.refreshSpendLimits(ctx)
+
+	// Analyze spaces in parallel, but never more than analysisWorkers at once
+	sem := make(chan struct{}, m.analysisWorkers)
 	var wg sync.WaitGroup
 	for _, space := range spaces {
 		wg.Add(1)
+		sem <- struct{}{}
 		go func(s *SpaceMonitor) {
 			defer wg.Done()
-			if err := m.analyzeSpace(s); err != nil {
-				m.app.Logger.Printf("⚠️  Failed to analyze space %s: %v", s.SpaceName, err)
-			}
+			defer func() { <-sem }()
+			m.analyzeSpaceWithDeadline(ctx, s)
 		}(space)
 	}
 	wg.Wait()
@@ -246,30 +572,61 @@ func (m *CostImpactMonitor) monitorAllSpaces() error {
 	return nil
 }
 
-// analyzeSpace analyzes cost for a specific space
-func (m *CostImpactMonitor) analyzeSpace(space *SpaceMonitor) error {
+// analyzeSpace analyzes cost for a specific space, giving up when ctx expires.
+// ConfigHub calls still running then are added to calls.
+func (m *CostImpactMonitor) analyzeSpace(ctx context.Context, space *SpaceMonitor, calls *sync.WaitGroup) error {
 	// Get all units in the space
-	units, err := m.app.Cub.ListUnits(space.SpaceID)
+	units, err := m.listUnits(ctx, space.SpaceID, calls)
 	if err != nil {
 		return fmt.Errorf("list units: %w", err)
 	}
 
 	totalCost := 0.0
 	pendingChanges := []PendingChange{}
+	unitCosts := make([]UnitCost, 0, len(units))
 
 	// Analyze each unit
 	for _, unit := range units {
+		// Stop before further (possibly Claude-backed) analysis once out of time
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("analyze units: %w", err)
+		}
+
 		// Calculate current cost
 		cost := m.calculateUnitCost(unit)
 		totalCost += cost
 
+		unitCost := UnitCost{
+			UnitID:        unit.UnitID.String(),
+			UnitName:      unit.Slug,
+			CurrentCost:   cost,
+			ProjectedCost: cost,
+		}
+		if _, hintErrors := unitHints(unit); len(hintErrors) > 0 {
+			unitCost.HintErrors = hintErrors
+		}
+
 		// Check for pending changes (units not yet applied)
 		if unit.LiveState == nil || unit.LiveState.Status != "Applied" {
-			change := m.analyzePendingChange(unit, cost)
+			change := m.analyzePendingChange(ctx, unit, cost)
 			pendingChanges = append(pendingChanges, change)
+
+			unitCost.CurrentCost = change.CurrentCost
+			unitCost.CostDelta = change.CostDelta
+			unitCost.ProjectedCost = change.CurrentCost + change.CostDelta
+			unitCost.ChangeType = change.ChangeType
+			unitCost.RiskLevel = change.RiskLevel
 		}
+		unitCosts = append(unitCosts, unitCost)
 	}
 
+	// Recently deleted units stay visible as cost-reducing changes, and
+	// drifted ones as the cost of what actually runs
+	m.mu.Lock()
+	pendingChanges = append(pendingChanges, activeDeletions(space, time.Now())...)
+	pendingChanges = append(pendingChanges, activeDrift(space, time.Now())...)
+	m.mu.Unlock()
+
 	// Update space monitor
 	space.CurrentCost = totalCost
 	space.ProjectedCost = totalCost // Will be updated by pending changes
@@ -280,42 +637,45 @@ func (m *CostImpactMonitor) analyzeSpace(space *SpaceMonitor) error {
 	for _, change := range pendingChanges {
 		space.ProjectedCost += change.CostDelta
 	}
+	space.UnitCosts = attributeUnitCosts(unitCosts)
 
-	// Update cost trend
+	// Update cost trend and deviation from any pinned baseline
 	space.CostTrend = m.calculateCostTrend(space)
+	if space.Baseline != nil {
+		space.Baseline.update(space.CurrentCost, space.LastAnalysis)
+	}
+	m.trackSpend(space, space.LastAnalysis)
+	m.exportCost(space)
+	m.pruneCostWarnings(space, units, space.LastAnalysis)
 
-	m.app.Logger.Printf("💰 Space %s: Current $%.2f/month, Projected $%.2f/month (%d pending changes)",
-		space.SpaceName, space.CurrentCost, space.ProjectedCost, len(pendingChanges))
+	slog.Info("Analyzed space", logging.Space(space.SpaceName),
+		"current_cost", space.CurrentCost, "projected_cost", space.ProjectedCost, "pending_changes", len(pendingChanges))
 
 	return nil
 }
 
-// calculateUnitCost estimates monthly cost for a unit
-func (m *CostImpactMonitor) calculateUnitCost(unit *sdk.Unit) float64 {
-	// Parse unit data to extract resource requirements
-	// This is simplified - real implementation would parse YAML/JSON manifests
-
-	// Default cost calculation based on labels
-	baseCost := 10.0 // Base cost per unit
+// defaultUnitCost is charged per replica for units without resource hints
+const defaultUnitCost = 10.0
 
-	// Check for resource hints in labels
-	if cpu, ok := unit.Labels["cpu"]; ok {
-		// Parse CPU and calculate cost
-		_ = cpu
-		baseCost += 20.0 // Simplified
-	}
+// unitHints parses a unit's pricing-hint labels. Invalid hints are skipped
+// and returned so they can be surfaced with the unit.
+func unitHints(unit *sdk.Unit) (pricinghints.Hints, pricinghints.ValidationError) {
+	hints, err := pricinghints.Parse(unit.Labels, nil)
+	verr, _ := err.(pricinghints.ValidationError)
+	return hints, verr
+}
 
-	if memory, ok := unit.Labels["memory"]; ok {
-		// Parse memory and calculate cost
-		_ = memory
-		baseCost += 15.0 // Simplified
+// calculateUnitCost estimates monthly cost for a unit from its pricing hints
+func (m *CostImpactMonitor) calculateUnitCost(unit *sdk.Unit) float64 {
+	hints, _ := unitHints(unit)
+	if !hints.HasResources() {
+		return defaultUnitCost * float64(hints.Replicas)
 	}
-
-	return baseCost
+	return hints.MonthlyCost(m.pricing.OrDefault())
 }
 
 // analyzePendingChange analyzes a unit that hasn't been applied yet
-func (m *CostImpactMonitor) analyzePendingChange(unit *sdk.Unit, projectedCost float64) PendingChange {
+func (m *CostImpactMonitor) analyzePendingChange(ctx context.Context, unit *sdk.Unit, projectedCost float64) PendingChange {
 	change := PendingChange{
 		UnitID:        unit.UnitID.String(),
 		UnitName:      unit.Slug,
@@ -337,10 +697,11 @@ func (m *CostImpactMonitor) analyzePendingChange(unit *sdk.Unit, projectedCost f
 
 	// Risk assessment
 	change.RiskLevel = m.assessRisk(change.CostDelta)
+	change.RiskFactors = m.triggerProcessor.assessRisk(unit, change.CostDelta).Factors
 
-	// Get Claude assessment if available
-	if m.app.Claude != nil {
-		change.ClaudeAssessment = m.getClaudeAssessment(unit, change)
+	// Get Claude assessment if available (leader only, so replicas don't pay twice)
+	if m.claude != nil && m.leader.IsLeader() {
+		change.ClaudeAssessment = m.getClaudeAssessment(ctx, unit, change)
 	}
 
 	return change
@@ -366,19 +727,21 @@ func (m *CostImpactMonitor) assessRisk(costDelta float64) string {
 }
 
 // getClaudeAssessment gets AI assessment of the change
-func (m *CostImpactMonitor) getClaudeAssessment(unit *sdk.Unit, change PendingChange) string {
-	prompt := fmt.Sprintf(`Assess this ConfigHub deployment cost change:
-Unit: %s
-Change Type: %s
-Cost Delta: $%.2f/month
-Risk Level: %s
-
-Provide a brief risk assessment and recommendation.`,
-		unit.Slug, change.ChangeType, change.CostDelta, change.RiskLevel)
+func (m *CostImpactMonitor) getClaudeAssessment(ctx context.Context, unit *sdk.Unit, change PendingChange) string {
+	prompt, err := m.prompts.Render(changeAssessmentPrompt, changePromptData{Unit: unit, Change: change})
+	if err != nil {
+		slog.Warn("Claude assessment failed", logging.Unit(unit.Slug), logging.Err(err))
+		return "AI assessment unavailable"
+	}
 
-	response, err := m.app.Claude.Complete(prompt)
+	response, err := tracing.Call(ctx, "claude.Complete", func() (string, error) {
+		return m.claude.Complete(prompt)
+	}, tracing.UnitKey.String(unit.Slug))
+	if errors.Is(err, breaker.ErrOpen) {
+		return "AI assessment unavailable (Claude circuit open)"
+	}
 	if err != nil {
-		m.app.Logger.Printf("⚠️  Claude assessment failed: %v", err)
+		slog.Warn("Claude assessment failed", logging.Unit(unit.Slug), logging.Err(err))
 		return "AI assessment unavailable"
 	}
 
@@ -410,17 +773,23 @@ func (m *CostImpactMonitor) calculateCostTrend(space *SpaceMonitor) CostTrend {
 	return trend
 }
 
+// highCostDelta is the monthly cost increase, in dollars, above which a
+// change or drift is warned about
+const highCostDelta = 100.0
+
 // registerDefaultHooks sets up default trigger hooks
 func (m *CostImpactMonitor) registerDefaultHooks() {
 	// Pre-apply hook: Warn about high costs
 	m.triggerProcessor.preApplyHooks = append(m.triggerProcessor.preApplyHooks,
 		func(unit *sdk.Unit, impact *CostImpact) error {
-			if impact.CostDelta > 100 {
-				m.app.Logger.Printf("⚠️  HIGH COST WARNING: %s will increase costs by $%.2f/month",
-					unit.Slug, impact.CostDelta)
-
-				// Store warning in ConfigHub
-				m.createCostWarning(unit, impact)
+			if impact.CostDelta > highCostDelta {
+				slog.Warn("High cost warning", logging.Unit(unit.Slug), "cost_delta", impact.CostDelta)
+
+				// Store warning in ConfigHub, once per unit revision
+				if m.shouldWarn(unit) {
+					m.createCostWarning(unit, impact)
+					m.notifyCostWarning(unit, impact)
+				}
 			}
 			return nil
 		})
@@ -428,37 +797,45 @@ func (m *CostImpactMonitor) registerDefaultHooks() {
 	// Post-apply hook: Track accuracy
 	m.triggerProcessor.postApplyHooks = append(m.triggerProcessor.postApplyHooks,
 		func(unit *sdk.Unit, actual *ActualUsage) error {
-			m.app.Logger.Printf("✅ Deployed %s - Actual cost: $%.2f/month",
-				unit.Slug, actual.MonthlyCost)
+			slog.Info("Deployed", logging.Unit(unit.Slug), "monthly_cost", actual.MonthlyCost)
 
 			// Update deployment history
 			m.updateDeploymentHistory(unit, actual)
+
+			// The change is live, so its escalation is over
+			m.escalations.Resolve(unit.UnitID.String())
 			return nil
 		})
 }
 
 // createCostWarning creates a warning unit in ConfigHub
 func (m *CostImpactMonitor) createCostWarning(unit *sdk.Unit, impact *CostImpact) {
-	if m.app.Cub == nil {
+	cub := m.cubFor(unit.SpaceID)
+	if cub == nil {
 		return
 	}
 
 	warningData, _ := json.MarshalIndent(impact, "", "  ")
 
-	_, err := m.app.Cub.CreateUnit(unit.SpaceID, sdk.CreateUnitRequest{
-		Slug:        fmt.Sprintf("cost-warning-%s-%d", unit.Slug, time.Now().Unix()),
+	slug := warningSlug(unit)
+	_, err := cub.CreateUnit(unit.SpaceID, sdk.CreateUnitRequest{
+		Slug:        slug,
 		DisplayName: fmt.Sprintf("Cost Warning: %s", unit.Slug),
 		Data:        string(warningData),
 		Labels: map[string]string{
-			"type":        "cost-warning",
+			"type":        costWarningType,
 			"unit":        unit.Slug,
+			"unit_id":     unit.UnitID.String(),
+			"revision":    fmt.Sprintf("%d", unit.HeadRevisionNum),
 			"cost_delta":  fmt.Sprintf("%.2f", impact.CostDelta),
 			"risk":        impact.RiskAssessment.Level,
 		},
 	})
+	m.audit.Record(m.auditContext(unit.SpaceID), audit.UnitCreated, slug, impact, err)
 
 	if err != nil {
-		m.app.Logger.Printf("⚠️  Failed to create cost warning: %v", err)
+		slog.Warn("Failed to create cost warning", logging.Unit(unit.Slug), logging.Err(err))
+		m.forgetWarnings(unit.UnitID.String()) // retry on the next detection
 	}
 }
 
@@ -475,18 +852,30 @@ func (m *CostImpactMonitor) updateDeploymentHistory(unit *sdk.Unit, actual *Actu
 	record := DeploymentCostRecord{
 		UnitID:        unit.UnitID.String(),
 		UnitName:      unit.Slug,
+		SpaceName:     space.SpaceName,
+		Target:        actual.Target,
+		ChangeType:    "create",
 		DeployTime:    time.Now(),
 		ActualCost:    actual.MonthlyCost,
 		PredictedCost: m.calculateUnitCost(unit),
 	}
 
+	// A unit already in the history is being updated, not created
+	for _, previous := range space.DeploymentHistory {
+		if previous.UnitID == record.UnitID {
+			record.ChangeType = "update"
+			break
+		}
+	}
+
 	// Calculate variance
 	if record.PredictedCost > 0 {
 		record.Variance = ((record.ActualCost - record.PredictedCost) / record.PredictedCost) * 100
-		record.Accurate = record.Variance >= -10 && record.Variance <= 10
+		record.Accurate = m.accuracy.isAccurate(record.Variance)
 	}
 
 	space.DeploymentHistory = append(space.DeploymentHistory, record)
+	m.exporter.Add(export.DeploymentHistory, space.SpaceName, record)
 
 	// Keep only last 100 records
 	if len(space.DeploymentHistory) > 100 {
@@ -496,12 +885,17 @@ func (m *CostImpactMonitor) updateDeploymentHistory(unit *sdk.Unit, actual *Actu
 
 // getMonitoringSnapshot returns current monitoring state
 func (m *CostImpactMonitor) getMonitoringSnapshot() *MonitoringSnapshot {
+	return m.snapshotFor(nil)
+}
+
+// snapshotFor is the monitoring snapshot limited to the spaces team may see;
+// Terraform plans belong to no space and are left to unrestricted teams
+func (m *CostImpactMonitor) snapshotFor(team *tenants.Team) *MonitoringSnapshot {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	snapshot := &MonitoringSnapshot{
 		Timestamp:       time.Now(),
-		TotalSpaces:     len(m.monitoredSpaces),
 		TotalCost:       0,
 		ProjectedCost:   0,
 		PendingChanges:  0,
@@ -509,6 +903,10 @@ func (m *CostImpactMonitor) getMonitoringSnapshot() *MonitoringSnapshot {
 	}
 
 	for _, space := range m.monitoredSpaces {
+		if !team.OwnsSpace(space.SpaceName) {
+			continue
+		}
+		snapshot.TotalSpaces++
 		snapshot.TotalCost += space.CurrentCost
 		snapshot.ProjectedCost += space.ProjectedCost
 		snapshot.PendingChanges += len(space.PendingChanges)
@@ -522,9 +920,50 @@ func (m *CostImpactMonitor) getMonitoringSnapshot() *MonitoringSnapshot {
 		snapshot.Spaces = append(snapshot.Spaces, space)
 	}
 
+	// Infrastructure changes from Terraform plans count as pending changes too
+	for _, plan := range m.terraformPlans {
+		if !team.Unrestricted() {
+			break
+		}
+		snapshot.ProjectedCost += plan.CostDelta
+		snapshot.PendingChanges += len(plan.Changes)
+		for _, change := range plan.Changes {
+			if change.RiskLevel == "high" || change.RiskLevel == "critical" {
+				snapshot.HighRiskChanges++
+			}
+		}
+		snapshot.TerraformPlans = append(snapshot.TerraformPlans, plan)
+	}
+
 	return snapshot
 }
 
+// collect exports each space's cost, pending changes and prediction
+// accuracy, for /metrics and the metrics sinks
+func (m *CostImpactMonitor) collect(emit metrics.Emit) {
+	accuracy := computeAccuracy(m.allDeploymentHistory(), m.accuracy)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, space := range m.monitoredSpaces {
+		labels := metrics.Labels{"space": space.SpaceName, "team": space.Team}
+		emit("impact_monthly_cost_dollars", "Monthly cost of the space's deployed units.", "gauge", labels, space.CurrentCost)
+		emit("impact_projected_monthly_cost_dollars", "Monthly cost of the space once its pending changes deploy.", "gauge", labels, space.ProjectedCost)
+		emit("impact_pending_changes", "Unit changes awaiting deployment.", "gauge", labels, float64(len(space.PendingChanges)))
+		highRisk := 0
+		for _, change := range space.PendingChanges {
+			if change.RiskLevel == "high" || change.RiskLevel == "critical" {
+				highRisk++
+			}
+		}
+		emit("impact_high_risk_changes", "Pending changes assessed as high or critical risk.", "gauge", labels, float64(highRisk))
+		if stats, ok := accuracy.BySpace[space.SpaceName]; ok {
+			emit("impact_prediction_records", "Deployments whose predicted cost was scored against the actual cost.", "gauge", labels, float64(stats.Records))
+			emit("impact_prediction_accuracy_ratio", "Share of scored deployments within the accuracy tolerance.", "gauge", labels, stats.AccuracyRate/100)
+			emit("impact_prediction_error_ratio", "Mean absolute percentage error of the cost predictions.", "gauge", labels, stats.MAPE/100)
+		}
+	}
+}
+
 // MonitoringSnapshot represents current state of all monitoring
 type MonitoringSnapshot struct {
 	Timestamp       time.Time       `json:"timestamp"`
@@ -534,6 +973,7 @@ type MonitoringSnapshot struct {
 	PendingChanges  int             `json:"pending_changes"`
 	HighRiskChanges int             `json:"high_risk_changes"`
 	Spaces          []*SpaceMonitor `json:"spaces"`
+	TerraformPlans  []*TerraformPlanImpact `json:"terraform_plans"`
 }
 
 // TriggerProcessor methods
@@ -550,7 +990,7 @@ func (t *TriggerProcessor) Start() {
 
 // checkForChanges polls ConfigHub for unit changes
 func (t *TriggerProcessor) checkForChanges() {
-	if t.monitor.app.Cub == nil {
+	if !t.monitor.hasConfigHub() || !t.monitor.leader.IsLeader() {
 		return
 	}
 
@@ -561,15 +1001,38 @@ func (t *TriggerProcessor) checkForChanges() {
 	}
 	t.monitor.mu.RUnlock()
 
+	ctx, span := tracing.Start(context.Background(), "impact.checkForChanges")
+	defer span.End()
+
+	seen := make(map[string]bool)
+	complete := true
 	for _, spaceID := range spaces {
-		units, err := t.monitor.app.Cub.ListUnits(spaceID)
+		units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, t.monitor.cubLimit, "ListUnits", spaceID.String(), func() ([]*sdk.Unit, error) {
+				return t.monitor.cubFor(spaceID).ListUnits(spaceID)
+			})
+		}, tracing.SpaceKey.String(spaceID.String()))
 		if err != nil {
+			complete = false
 			continue
 		}
 
 		for _, unit := range units {
+			seen[unit.UnitID.String()] = true
 			t.processUnitChange(unit)
 		}
+
+		t.mu.Lock()
+		deleted := t.changeDetector.reconcileUnits(spaceID, units)
+		t.mu.Unlock()
+		for _, unit := range deleted {
+			t.handleUnitDeletion(unit)
+		}
+	}
+
+	// Only prune after a full sweep so a failed listing doesn't look like a mass deletion
+	if complete {
+		t.pruneLastProcessed(seen)
 	}
 }
 
@@ -577,24 +1040,19 @@ func (t *TriggerProcessor) checkForChanges() {
 func (t *TriggerProcessor) processUnitChange(unit *sdk.Unit) {
 	unitKey := unit.UnitID.String()
 
+	// Only process when the revision advances or the live status changes;
+	// comparing ConfigHub revisions avoids clock skew between us and ConfigHub
 	t.mu.Lock()
-	lastProcessed, exists := t.lastProcessed[unitKey]
+	advanced := t.changeDetector.advance(unit)
 	t.mu.Unlock()
-
-	// Check if unit has been modified since last processing
-	if exists && unit.UpdatedAt.Before(lastProcessed) {
+	if !advanced {
 		return
 	}
 
 	// Check if unit is about to be applied
 	if unit.LiveState == nil || unit.LiveState.Status == "Pending" {
 		// Pre-apply trigger
-		impact := t.analyzeImpact(unit)
-		for _, hook := range t.preApplyHooks {
-			if err := hook(unit, impact); err != nil {
-				t.monitor.app.Logger.Printf("⚠️  Pre-apply hook error: %v", err)
-			}
-		}
+		t.runPreApplyHooks(unit)
 	}
 
 	// Check if unit was recently applied
@@ -603,7 +1061,7 @@ func (t *TriggerProcessor) processUnitChange(unit *sdk.Unit) {
 		actual := t.measureActualUsage(unit)
 		for _, hook := range t.postApplyHooks {
 			if err := hook(unit, actual); err != nil {
-				t.monitor.app.Logger.Printf("⚠️  Post-apply hook error: %v", err)
+				slog.Warn("Post-apply hook failed", logging.Unit(unit.Slug), logging.Err(err))
 			}
 		}
 	}
@@ -613,11 +1071,51 @@ func (t *TriggerProcessor) processUnitChange(unit *sdk.Unit) {
 	t.mu.Unlock()
 }
 
+// runPreApplyHooks analyzes a unit about to be deployed and runs the pre-apply hooks.
+// Hooks only run on the leader; followers return the analysis alone.
+func (t *TriggerProcessor) runPreApplyHooks(unit *sdk.Unit) *CostImpact {
+	impact := t.analyzeImpact(unit)
+	if !t.monitor.leader.IsLeader() {
+		return impact
+	}
+	for _, hook := range t.preApplyHooks {
+		if err := hook(unit, impact); err != nil {
+			slog.Warn("Pre-apply hook failed", logging.Unit(unit.Slug), logging.Err(err))
+		}
+	}
+	t.monitor.dashboard.events.Publish("impact", impact)
+	t.monitor.publishPending(impact)
+	return impact
+}
+
+// advance records the unit's revision and live status, reporting whether
+// either moved since the last time it was processed. Callers must hold t.mu.
+func (c *ChangeDetector) advance(unit *sdk.Unit) bool {
+	key := unit.UnitID.String()
+	revision := int(unit.HeadRevisionNum)
+	status := ""
+	if unit.LiveState != nil {
+		status = unit.LiveState.Status
+	}
+
+	lastRevision, seen := c.revisionCache[key]
+	if seen && revision <= lastRevision && status == c.statusCache[key] {
+		return false
+	}
+
+	if revision > lastRevision {
+		c.revisionCache[key] = revision
+	}
+	c.statusCache[key] = status
+	return true
+}
+
 // analyzeImpact predicts cost impact of a unit deployment
 func (t *TriggerProcessor) analyzeImpact(unit *sdk.Unit) *CostImpact {
 	impact := &CostImpact{
 		UnitID:      unit.UnitID.String(),
 		UnitName:    unit.Slug,
+		SpaceID:     unit.SpaceID.String(),
 		MonthlyCost: t.monitor.calculateUnitCost(unit),
 	}
 
@@ -629,73 +1127,82 @@ func (t *TriggerProcessor) analyzeImpact(unit *sdk.Unit) *CostImpact {
 		impact.CostDelta = impact.MonthlyCost
 	}
 
-	// Risk assessment
+	// Risk assessment, escalated according to the environment's policy unless
+	// cost gating is switched off
 	impact.RiskAssessment = t.assessRisk(unit, impact.CostDelta)
+	if t.monitor.flags.Enabled(flags.CostGating) {
+		t.monitor.escalations.Evaluate(unit, &impact.RiskAssessment, impact.CostDelta, time.Now())
+	} else {
+		applyEscalation(&impact.RiskAssessment, nil)
+	}
+	t.monitor.applyPolicy(unit, &impact.RiskAssessment, impact.CostDelta, false)
 
 	return impact
 }
 
-// measureActualUsage gets real resource usage for a deployed unit
+// measureActualUsage gets real resource usage for a deployed unit from the
+// cluster of its target, falling back to estimates when it can't be measured
 func (t *TriggerProcessor) measureActualUsage(unit *sdk.Unit) *ActualUsage {
+	if clusters := t.monitor.clusters; clusters != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		actual, err := clusters.measure(ctx, unit)
+		if err == nil {
+			return actual
+		}
+		slog.Warn("Using estimated usage", logging.Unit(unit.Slug), logging.Err(err))
+	}
+
 	actual := &ActualUsage{
 		UnitID:     unit.UnitID.String(),
 		UnitName:   unit.Slug,
+		Target:     targetOf(unit),
 		MeasuredAt: time.Now(),
 	}
 
-	// In real implementation, would query metrics-server or Prometheus
-	// For now, use estimates
+	// No cluster to measure, so use estimates
 	actual.CPUCores = 0.5
 	actual.MemoryGB = 1.0
 	actual.StorageGB = 10.0
-	actual.MonthlyCost = (actual.CPUCores * 24 * 30 * 0.024) +
-	                   (actual.MemoryGB * 24 * 30 * 0.006)
+	actual.MonthlyCost = t.monitor.pricing.OrDefault().MonthlyCost(costmodel.Resources{
+		CPUCores: actual.CPUCores, MemoryGB: actual.MemoryGB, Replicas: 1})
 
 	return actual
 }
 
-// assessRisk evaluates deployment risk
+// assessRisk evaluates deployment risk with the apps' shared scoring. The
+// recommendation and auto-approval are decided by the escalation policy and
+// pkg/policy.
 func (t *TriggerProcessor) assessRisk(unit *sdk.Unit, costDelta float64) RiskAssessment {
-	assessment := RiskAssessment{
-		Level:       "low",
-		Factors:     []string{},
-		AutoApprove: true,
-	}
+	level, factors := policy.Score(costDelta, unit.Labels["env"])
+	return RiskAssessment{Level: level, Factors: factors}
+}
 
-	// Check cost delta
-	if costDelta > 500 {
-		assessment.Level = "critical"
-		assessment.Factors = append(assessment.Factors, "Very high cost increase")
-		assessment.AutoApprove = false
-	} else if costDelta > 200 {
-		assessment.Level = "high"
-		assessment.Factors = append(assessment.Factors, "Significant cost increase")
-		assessment.AutoApprove = false
-	} else if costDelta > 50 {
-		assessment.Level = "medium"
-		assessment.Factors = append(assessment.Factors, "Moderate cost increase")
+// applyPolicy holds back a change the escalation would let through when the
+// auto-approval policy denies it or wants it approved; changes approved by
+// hand stay approved. A preview decides without recording the decision.
+func (m *CostImpactMonitor) applyPolicy(unit *sdk.Unit, assessment *RiskAssessment, costDelta float64, preview bool) {
+	if !assessment.AutoApprove || assessment.Stage == "approved" {
+		return
 	}
-
-	// Check for production environment
-	if unit.Labels["env"] == "production" {
-		if assessment.Level == "low" {
-			assessment.Level = "medium"
-		}
-		assessment.Factors = append(assessment.Factors, "Production environment")
-		assessment.AutoApprove = false
+	action := policy.Action{
+		App: "cost-impact-monitor", Kind: policy.Deployment, Target: unit.Slug,
+		Environment: unit.Labels["env"], Severity: assessment.Level, CostDelta: costDelta,
 	}
-
-	// Generate recommendation
-	switch assessment.Level {
-	case "critical":
-		assessment.Recommendation = "DO NOT DEPLOY without executive approval"
-	case "high":
-		assessment.Recommendation = "Review with team lead before deployment"
-	case "medium":
-		assessment.Recommendation = "Review cost optimization opportunities"
-	default:
-		assessment.Recommendation = "Safe to deploy"
+	var decision policy.Decision
+	if preview {
+		decision = m.policy.Decide(action)
+	} else {
+		decision = m.policy.Check(m.auditContext(unit.SpaceID), m.audit, action)
 	}
 
-	return assessment
+	switch decision.Verdict {
+	case policy.Approve:
+		assessment.AutoApprove = false
+		assessment.Recommendation = "Requires approval before deployment - policy rule " + decision.Rule
+	case policy.Deny:
+		assessment.AutoApprove = false
+		assessment.Recommendation = "DO NOT DEPLOY - denied by policy rule " + decision.Rule
+	}
 }
\ No newline at end of file
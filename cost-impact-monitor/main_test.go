@@ -1,3 +1,5 @@
+//go:build ignore
+
 // Cost Impact Monitor Test Suite
 // Copy to: /Users/alexis/Public/github-repos/devops-examples/cost-impact-monitor/main_test.go
 
@@ -0,0 +1,103 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/notify"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// notifyApp identifies this app in shared notification routes
+const notifyApp = "cost-impact-monitor"
+
+// riskSeverity maps a change's risk level to a notification severity
+func riskSeverity(level string) notify.Severity {
+	switch riskRank(level) {
+	case 3:
+		return notify.Critical
+	case 2:
+		return notify.Warning
+	default:
+		return notify.Info
+	}
+}
+
+// notifyCostWarning announces a high-cost pending change
+func (m *CostImpactMonitor) notifyCostWarning(unit *sdk.Unit, impact *CostImpact) {
+	space := m.spaceName(unit)
+	m.sendNotification(notify.Notification{
+		App:      notifyApp,
+		Kind:     "cost-warning",
+		Severity: riskSeverity(impact.RiskAssessment.Level),
+		Title:    fmt.Sprintf("Cost increase pending for %s", unit.Slug),
+		Summary: fmt.Sprintf("Deploying %s in %s adds $%.2f/month (unit total $%.2f/month)",
+			unit.Slug, space, impact.CostDelta, impact.MonthlyCost),
+		Fields: map[string]string{
+			"space":    space,
+			"unit":     unit.Slug,
+			"revision": fmt.Sprintf("%d", unit.HeadRevisionNum),
+			"risk":     impact.RiskAssessment.Level,
+		},
+		DedupKey: fmt.Sprintf("cost-warning/%s/%d", unit.UnitID, unit.HeadRevisionNum),
+	})
+}
+
+// notifyDriftCost announces drift that raises a unit's cost, under the
+// correlation ID of the drift so it can be matched with the drift-detector's
+// notification and fix
+func (m *CostImpactMonitor) notifyDriftCost(space string, change PendingChange) {
+	m.sendNotification(notify.Notification{
+		App:      notifyApp,
+		Kind:     "drift-cost",
+		Severity: riskSeverity(change.RiskLevel),
+		Title:    fmt.Sprintf("Drift raises the cost of %s", change.UnitName),
+		Summary: fmt.Sprintf("Drift on %s in %s adds $%.2f/month until it is fixed",
+			change.UnitName, space, change.CostDelta),
+		Fields: map[string]string{
+			"space": space,
+			"unit":  change.UnitName,
+			"risk":  change.RiskLevel,
+		},
+		DedupKey: fmt.Sprintf("drift-cost/%s/%s/%.2f", change.CorrelationID, change.UnitID, change.CostDelta),
+
+		CorrelationID: change.CorrelationID,
+	})
+}
+
+// notifySpendAlert announces a crossed monthly spend threshold; reaching
+// the limit is critical
+func (m *CostImpactMonitor) notifySpendAlert(space *SpaceMonitor, threshold float64, status SpendLimit) {
+	severity := notify.Warning
+	if threshold >= 100 {
+		severity = notify.Critical
+	}
+	m.sendNotification(notify.Notification{
+		App:      notifyApp,
+		Kind:     "spend-alert",
+		Severity: severity,
+		Title:    fmt.Sprintf("%s reached %.0f%% of its monthly spend limit", space.SpaceName, threshold),
+		Summary: fmt.Sprintf("$%.2f spent of $%.2f, $%.2f projected for %s",
+			status.ConsumedToDate, status.Limit, status.ProjectedMonth, status.Month),
+		Fields: map[string]string{
+			"space":     space.SpaceName,
+			"threshold": fmt.Sprintf("%.0f%%", threshold),
+			"used":      fmt.Sprintf("%.0f%%", status.PercentUsed),
+		},
+		DedupKey: fmt.Sprintf("spend-alert/%s/%s/%.0f", space.SpaceID, status.Month, threshold),
+	})
+}
+
+// sendNotification delivers n in the background so slow channels never hold up analysis
+func (m *CostImpactMonitor) sendNotification(n notify.Notification) {
+	if !m.notifier.Enabled() {
+		return
+	}
+	go func() {
+		if err := m.notifier.Notify(context.Background(), n); err != nil {
+			slog.Warn("Failed to send notification", "kind", n.Kind, logging.Err(err))
+		}
+	}()
+}
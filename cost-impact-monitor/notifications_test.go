@@ -0,0 +1,68 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/notify"
+)
+
+func TestNotifySpendAlert(t *testing.T) {
+	received := make(chan notify.Notification, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notify.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		received <- n
+	}))
+	defer server.Close()
+
+	notifier, err := notify.Config{
+		Channels: []notify.ChannelConfig{{Name: "hook", Type: "webhook", URL: server.URL}},
+		Routes:   []notify.RouteConfig{{MinSeverity: notify.Critical, Channels: []string{"hook"}}},
+	}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &CostImpactMonitor{notifier: notifier}
+	space := &SpaceMonitor{SpaceID: uuid.New(), SpaceName: "prod"}
+	status := SpendLimit{Limit: 1000, Month: "2024-05", ConsumedToDate: 1010, PercentUsed: 101}
+
+	m.notifySpendAlert(space, 80, status) // warning, not routed
+	m.notifySpendAlert(space, 100, status)
+
+	select {
+	case n := <-received:
+		if n.App != "cost-impact-monitor" || n.Kind != "spend-alert" || n.Severity != notify.Critical {
+			t.Errorf("notification = %+v", n)
+		}
+		if n.Fields["threshold"] != "100%" || n.Fields["space"] != "prod" {
+			t.Errorf("fields = %v", n.Fields)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification delivered")
+	}
+	select {
+	case n := <-received:
+		t.Errorf("unexpected notification %q", n.Title)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRiskSeverity(t *testing.T) {
+	for level, want := range map[string]notify.Severity{
+		"critical": notify.Critical,
+		"high":     notify.Warning,
+		"medium":   notify.Info,
+		"":         notify.Info,
+	} {
+		if got := riskSeverity(level); got != want {
+			t.Errorf("riskSeverity(%q) = %v, want %v", level, got, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newPendingQueue returns the monitor's queue of escalated changes awaiting
+// approval, kept as units of the space with slug space, else as files in
+// dir; in memory only without either
+func newPendingQueue(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space, dir string) (*pending.Queue, error) {
+	if cub == nil || space == "" {
+		if dir == "" {
+			return pending.New("cost-impact-monitor", nil, nil), nil
+		}
+		writer, reader, err := pending.Dir(dir)
+		if err != nil {
+			return nil, err
+		}
+		return pending.New("cost-impact-monitor", writer, reader), nil
+	}
+	spaceID := func(ctx context.Context) (uuid.UUID, error) {
+		spaces, err := ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug == space {
+				return s.SpaceID, nil
+			}
+		}
+		return uuid.Nil, fmt.Errorf("pending space %s not found", space)
+	}
+
+	writer := func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return err
+		}
+		units, err := cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: fmt.Sprintf("Slug = '%s'", slug)})
+		if err != nil {
+			return fmt.Errorf("list units: %w", err)
+		}
+		if len(units) > 0 {
+			_, err = cub.UpdateUnit(id, units[0].UnitID, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+			return err
+		}
+		_, err = cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+		return err
+	}
+	reader := func(ctx context.Context, where string) ([]string, error) {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		units, err := ratelimit.Call(ctx, limit, "ListUnits", id.String()+"/"+where, func() ([]*sdk.Unit, error) {
+			return cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: where})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list units: %w", err)
+		}
+		data := make([]string, 0, len(units))
+		for _, u := range units {
+			data = append(data, u.Data)
+		}
+		return data, nil
+	}
+	return pending.New("cost-impact-monitor", writer, reader), nil
+}
+
+// queueEscalation keeps the queue in step with an escalation: changes
+// waiting for approval or blocked are queued, approved ones are taken off
+func (m *CostImpactMonitor) queueEscalation(esc Escalation) {
+	ctx := context.Background()
+	var err error
+	switch esc.Stage {
+	case "approval", "block":
+		reason := fmt.Sprintf("escalated to %s: %s risk, $%.2f/month", esc.Stage, esc.RiskLevel, esc.CostDelta)
+		_, err = m.pending.Wait(ctx, policy.Deployment, "", esc.UnitName, esc, reason)
+	case "approved":
+		err = m.pending.Done(ctx, policy.Deployment, "", esc.UnitName)
+	}
+	if err != nil {
+		slog.Warn("Failed to queue escalated change", logging.Unit(esc.UnitName), logging.Err(err))
+	}
+}
+
+// handlePending approves the escalations of queued changes. After a restart
+// a change escalates again on its next evaluation, so an approval that finds
+// no escalation yet is retried.
+func (m *CostImpactMonitor) handlePending() {
+	m.pending.Handle(policy.Deployment, func(ctx context.Context, a pending.Action) error {
+		esc, ok := m.escalations.Get(a.Target)
+		if !ok {
+			return fmt.Errorf("no escalation for %s yet", a.Target)
+		}
+		if esc.Stage != "approval" && esc.Stage != "block" {
+			return nil
+		}
+		_, err := m.escalations.Approve(a.Target, audit.Actor(ctx), a.Note, time.Now())
+		m.audit.Record(ctx, audit.ApprovalGranted, a.Target, a, err)
+		return err
+	})
+}
+
+// processPending runs the queue every interval; only the leader acts on it
+func (m *CostImpactMonitor) processPending(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if m.leader.IsLeader() {
+				m.pending.Process(ctx)
+			}
+		}
+	}
+}
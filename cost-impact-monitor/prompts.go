@@ -0,0 +1,87 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/prompts"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// changeAssessmentPrompt asks for the risk of a detected change; the data is
+// changePromptData
+var changeAssessmentPrompt = prompts.Prompt{
+	Name: "change-assessment",
+	Text: `Assess this ConfigHub deployment cost change:
+Unit: {{.Unit.Slug}}
+Change Type: {{.Change.ChangeType}}
+Cost Delta: ${{printf "%.2f" .Change.CostDelta}}/month
+Risk Level: {{.Change.RiskLevel}}
+
+Provide a brief risk assessment and recommendation.`,
+}
+
+// whatIfAssessmentPrompt asks about a change nobody has made yet; the data
+// is whatIfPromptData
+var whatIfAssessmentPrompt = prompts.Prompt{
+	Name: "whatif-assessment",
+	Text: `A team is considering this ConfigHub change and has not made it yet:
+Space: {{.Result.Space}}
+Unit: {{.Result.Unit}} ({{.Result.ChangeType}})
+Current labels: {{.Current.Labels}}
+Proposed labels: {{.Changed.Labels}}
+Cost: ${{printf "%.2f" .Result.CurrentCost}}/month -> ${{printf "%.2f" .Result.ProjectedCost}}/month (delta ${{printf "%.2f" .Result.CostDelta}}/month)
+Risk Level: {{.Result.RiskAssessment.Level}}
+
+Assess the cost and operational risk, and suggest cheaper alternatives if any.`,
+}
+
+// changePromptData is what changeAssessmentPrompt can refer to
+type changePromptData struct {
+	Unit   *sdk.Unit
+	Change PendingChange
+}
+
+// whatIfPromptData is what whatIfAssessmentPrompt can refer to
+type whatIfPromptData struct {
+	Current, Changed *sdk.Unit
+	Result           *WhatIfResult
+}
+
+// promptsSource reads the prompt units of the space with slug space, or is
+// nil without a prompts space
+func promptsSource(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) prompts.Source {
+	if cub == nil || space == "" {
+		return nil
+	}
+	return func(ctx context.Context) ([]prompts.Unit, error) {
+		spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+			return ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug != space {
+				continue
+			}
+			units, err := ratelimit.Call(ctx, limit, "ListUnits", s.SpaceID.String(), func() ([]*sdk.Unit, error) {
+				return cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.SpaceID})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("list units: %w", err)
+			}
+			var found []prompts.Unit
+			for _, u := range units {
+				if strings.HasPrefix(u.Slug, prompts.UnitPrefix) {
+					found = append(found, prompts.Unit{Slug: u.Slug, Data: u.Data, Revision: u.HeadRevisionNum})
+				}
+			}
+			return found, nil
+		}
+		return nil, fmt.Errorf("prompts space %s not found", space)
+	}
+}
@@ -0,0 +1,202 @@
+package costimpactmonitor
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleReport renders a markdown impact report for a whole space
+// (/api/v1/spaces/{id}/report) or one pending change (/api/v1/spaces/{id}/report/{unit}),
+// ready to paste into a change request ticket
+func (d *MonitorDashboard) handleReport(w http.ResponseWriter, r *http.Request, space *SpaceMonitor, unitRef string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.monitor.mu.RLock()
+	snapshot := SpaceMonitor{
+		SpaceID:        space.SpaceID,
+		SpaceName:      space.SpaceName,
+		LastAnalysis:   space.LastAnalysis,
+		CurrentCost:    space.CurrentCost,
+		ProjectedCost:  space.ProjectedCost,
+		PendingChanges: append([]PendingChange(nil), space.PendingChanges...),
+	}
+	if space.Baseline != nil {
+		baseline := *space.Baseline
+		snapshot.Baseline = &baseline
+	}
+	d.monitor.mu.RUnlock()
+
+	escalations := make(map[string]Escalation)
+	for _, esc := range d.monitor.escalations.List() {
+		escalations[esc.UnitID] = esc
+	}
+
+	var report string
+	if unitRef == "" {
+		report = spaceReport(snapshot, escalations, time.Now())
+	} else {
+		found := false
+		for _, change := range snapshot.PendingChanges {
+			if change.UnitID == unitRef || change.UnitName == unitRef {
+				report = changeReport(snapshot.SpaceName, change, escalationFor(escalations, change.UnitID), time.Now())
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "no pending change for unit "+unitRef, http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	fmt.Fprint(w, report)
+}
+
+// escalationFor returns the unit's escalation, or nil when it has none
+func escalationFor(escalations map[string]Escalation, unitID string) *Escalation {
+	if esc, ok := escalations[unitID]; ok {
+		return &esc
+	}
+	return nil
+}
+
+// changeReport renders one pending change
+func changeReport(spaceName string, change PendingChange, esc *Escalation, now time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Cost Impact Report: %s\n\n", change.UnitName)
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Space | %s |\n", mdCell(spaceName))
+	fmt.Fprintf(&b, "| Unit | %s |\n", mdCell(change.UnitName))
+	fmt.Fprintf(&b, "| Change | %s |\n", change.ChangeType)
+	fmt.Fprintf(&b, "| Analyzed | %s |\n", change.AnalysisTime.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "| Generated | %s |\n\n", now.UTC().Format(time.RFC3339))
+
+	writeChangeDetails(&b, change, esc, "##")
+	return b.String()
+}
+
+// spaceReport renders a space summary followed by every pending change
+func spaceReport(space SpaceMonitor, escalations map[string]Escalation, now time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Cost Impact Report: %s\n\n", space.SpaceName)
+	fmt.Fprintf(&b, "Generated %s from the analysis at %s.\n\n",
+		now.UTC().Format(time.RFC3339), space.LastAnalysis.UTC().Format(time.RFC3339))
+
+	b.WriteString("## Summary\n\n| | Monthly |\n|---|---:|\n")
+	fmt.Fprintf(&b, "| Current cost | %s |\n", money(space.CurrentCost))
+	fmt.Fprintf(&b, "| Projected cost | %s |\n", money(space.ProjectedCost))
+	fmt.Fprintf(&b, "| Pending delta | %s |\n", signedMoney(space.ProjectedCost-space.CurrentCost))
+	if space.Baseline != nil {
+		fmt.Fprintf(&b, "| Baseline (pinned %s) | %s |\n",
+			space.Baseline.PinnedAt.UTC().Format("2006-01-02"), money(space.Baseline.Cost))
+		fmt.Fprintf(&b, "| Deviation from baseline | %s (%+.1f%%) |\n",
+			signedMoney(space.Baseline.Deviation), space.Baseline.DeviationPercent)
+	}
+	b.WriteString("\n")
+
+	if len(space.PendingChanges) == 0 {
+		b.WriteString("No pending changes.\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "## Pending Changes (%d)\n\n", len(space.PendingChanges))
+	b.WriteString("| Unit | Change | Current | Projected | Delta | Risk | Approval |\n")
+	b.WriteString("|---|---|---:|---:|---:|---|---|\n")
+	for _, change := range space.PendingChanges {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s |\n",
+			mdCell(change.UnitName), change.ChangeType,
+			money(change.CurrentCost), money(change.ProjectedCost), signedMoney(change.CostDelta),
+			change.RiskLevel, mdCell(approvalStatus(escalationFor(escalations, change.UnitID))))
+	}
+	b.WriteString("\n")
+
+	for _, change := range space.PendingChanges {
+		fmt.Fprintf(&b, "## %s\n\n", change.UnitName)
+		writeChangeDetails(&b, change, escalationFor(escalations, change.UnitID), "###")
+	}
+
+	return b.String()
+}
+
+// writeChangeDetails writes the cost table, risk factors, approval status and
+// Claude assessment for a change under headings at the given level
+func writeChangeDetails(b *strings.Builder, change PendingChange, esc *Escalation, heading string) {
+	fmt.Fprintf(b, "%s Cost\n\n", heading)
+	b.WriteString("| Current | Projected | Delta |\n|---:|---:|---:|\n")
+	delta := signedMoney(change.CostDelta)
+	if change.CurrentCost > 0 {
+		delta += fmt.Sprintf(" (%+.1f%%)", change.CostDelta/change.CurrentCost*100)
+	}
+	fmt.Fprintf(b, "| %s | %s | %s |\n\n", money(change.CurrentCost), money(change.ProjectedCost), delta)
+
+	fmt.Fprintf(b, "%s Risk\n\n**Level:** %s\n\n", heading, change.RiskLevel)
+	for _, factor := range change.RiskFactors {
+		fmt.Fprintf(b, "- %s\n", factor)
+	}
+	if len(change.RiskFactors) > 0 {
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(b, "%s Approval\n\n**Status:** %s\n\n", heading, approvalStatus(esc))
+
+	if change.ClaudeAssessment != "" {
+		fmt.Fprintf(b, "%s Claude Assessment\n\n", heading)
+		for _, line := range strings.Split(strings.TrimSpace(change.ClaudeAssessment), "\n") {
+			fmt.Fprintf(b, "> %s\n", line)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// approvalStatus describes where a change stands in its escalation
+func approvalStatus(esc *Escalation) string {
+	if esc == nil {
+		return "Not required"
+	}
+
+	switch esc.Stage {
+	case "notify":
+		if esc.Acknowledged {
+			return "Not required (owners notified and acknowledged)"
+		}
+		return "Not required (owners notified)"
+	case "approval":
+		if esc.Deadline != nil {
+			return "Awaiting approval (blocked after " + esc.Deadline.UTC().Format(time.RFC3339) + ")"
+		}
+		return "Awaiting approval"
+	case "block":
+		return "Blocked until approved"
+	case "approved":
+		status := "Approved by " + esc.ApprovedBy + " at " + esc.StageSince.UTC().Format(time.RFC3339)
+		if esc.Note != "" {
+			status += ": " + esc.Note
+		}
+		return status
+	}
+	return esc.Stage
+}
+
+func money(v float64) string {
+	return fmt.Sprintf("$%.2f", v)
+}
+
+func signedMoney(v float64) string {
+	if v < 0 {
+		return fmt.Sprintf("-$%.2f", -v)
+	}
+	return fmt.Sprintf("+$%.2f", v)
+}
+
+// mdCell keeps a value from breaking out of a markdown table cell
+func mdCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
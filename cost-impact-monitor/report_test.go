@@ -0,0 +1,71 @@
+package costimpactmonitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangeReport(t *testing.T) {
+	now := time.Date(2024, 11, 4, 10, 0, 0, 0, time.UTC)
+	deadline := now.Add(4 * time.Hour)
+	change := PendingChange{
+		UnitID:           "u1",
+		UnitName:         "api|server",
+		ChangeType:       "update",
+		CurrentCost:      100,
+		ProjectedCost:    350,
+		CostDelta:        250,
+		RiskLevel:        "high",
+		RiskFactors:      []string{"Significant cost increase", "Production environment"},
+		AnalysisTime:     now,
+		ClaudeAssessment: "Replica count tripled.\nConsider an HPA.",
+	}
+	esc := &Escalation{UnitID: "u1", Stage: "approval", Deadline: &deadline}
+
+	report := changeReport("prod", change, esc, now)
+
+	for _, want := range []string{
+		"# Cost Impact Report: api|server",
+		`| Unit | api\|server |`,
+		"| $100.00 | $350.00 | +$250.00 (+250.0%) |",
+		"**Level:** high",
+		"- Production environment",
+		"**Status:** Awaiting approval (blocked after 2024-11-04T14:00:00Z)",
+		"> Replica count tripled.\n> Consider an HPA.",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestSpaceReport(t *testing.T) {
+	now := time.Now()
+	space := SpaceMonitor{
+		SpaceName:     "prod",
+		CurrentCost:   500,
+		ProjectedCost: 480,
+		PendingChanges: []PendingChange{
+			{UnitID: "u1", UnitName: "api", ChangeType: "create", ProjectedCost: 30, CostDelta: 30, RiskLevel: "low"},
+			{UnitID: "u2", UnitName: "batch", ChangeType: "delete", CurrentCost: 50, CostDelta: -50, RiskLevel: "low"},
+		},
+	}
+	escalations := map[string]Escalation{
+		"u1": {UnitID: "u1", Stage: "approved", ApprovedBy: "alice", StageSince: now},
+	}
+
+	report := spaceReport(space, escalations, now)
+
+	for _, want := range []string{
+		"| Pending delta | -$20.00 |",
+		"## Pending Changes (2)",
+		"| batch | delete | $50.00 | $0.00 | -$50.00 | low | Not required |",
+		"## api\n\n### Cost",
+		"**Status:** Approved by alice",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SpaceDetail is everything the monitor knows about one space, served by
+// /api/v1/spaces/{id} and rendered by the /spaces/{id} page
+type SpaceDetail struct {
+	SpaceID           uuid.UUID              `json:"space_id"`
+	SpaceName         string                 `json:"space_name"`
+	LastAnalysis      time.Time              `json:"last_analysis"`
+	CurrentCost       float64                `json:"current_cost"`
+	ProjectedCost     float64                `json:"projected_cost"`
+	CostTrend         CostTrend              `json:"cost_trend"`
+	Baseline          *CostBaseline          `json:"baseline,omitempty"`
+	SpendLimit        *SpendLimit            `json:"spend_limit,omitempty"`
+	Units             []UnitCost             `json:"units"`
+	PendingChanges    []PendingChange        `json:"pending_changes"`
+	DeploymentHistory []DeploymentCostRecord `json:"deployment_history"` // newest first
+	CostSeries        []SpaceCostPoint       `json:"cost_series"`
+}
+
+// SpaceCostPoint is the space's deployed cost after one deployment
+type SpaceCostPoint struct {
+	Time          time.Time `json:"time"`
+	ActualCost    float64   `json:"actual_cost"`
+	PredictedCost float64   `json:"predicted_cost"`
+}
+
+// spaceDetail copies a space's state under the monitor lock
+func (m *CostImpactMonitor) spaceDetail(space *SpaceMonitor) SpaceDetail {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	detail := SpaceDetail{
+		SpaceID:           space.SpaceID,
+		SpaceName:         space.SpaceName,
+		LastAnalysis:      space.LastAnalysis,
+		CurrentCost:       space.CurrentCost,
+		ProjectedCost:     space.ProjectedCost,
+		CostTrend:         space.CostTrend,
+		Units:             append([]UnitCost{}, space.UnitCosts...),
+		PendingChanges:    append([]PendingChange{}, space.PendingChanges...),
+		DeploymentHistory: append([]DeploymentCostRecord{}, space.DeploymentHistory...),
+	}
+	if space.Baseline != nil {
+		baseline := *space.Baseline
+		detail.Baseline = &baseline
+	}
+	if space.SpendLimit != nil {
+		limit := *space.SpendLimit
+		detail.SpendLimit = &limit
+	}
+
+	detail.CostSeries = spaceCostSeries(detail.DeploymentHistory)
+	sort.Slice(detail.DeploymentHistory, func(i, j int) bool {
+		return detail.DeploymentHistory[i].DeployTime.After(detail.DeploymentHistory[j].DeployTime)
+	})
+	sort.Slice(detail.Units, func(i, j int) bool {
+		return detail.Units[i].ProjectedCost > detail.Units[j].ProjectedCost
+	})
+	return detail
+}
+
+// spaceCostSeries replays deployments in order and totals the latest
+// actual and predicted cost of every unit after each one
+func spaceCostSeries(records []DeploymentCostRecord) []SpaceCostPoint {
+	ordered := append([]DeploymentCostRecord(nil), records...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].DeployTime.Before(ordered[j].DeployTime) })
+
+	latest := make(map[string]DeploymentCostRecord)
+	series := make([]SpaceCostPoint, 0, len(ordered))
+	for _, record := range ordered {
+		latest[record.UnitID] = record
+
+		point := SpaceCostPoint{Time: record.DeployTime}
+		for _, unit := range latest {
+			point.ActualCost += unit.ActualCost
+			point.PredictedCost += unit.PredictedCost
+		}
+		series = append(series, point)
+	}
+	return series
+}
+
+// handleSpaceDetail returns a space's units, pending changes, history and cost series
+func (d *MonitorDashboard) handleSpaceDetail(w http.ResponseWriter, r *http.Request, space *SpaceMonitor) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d.monitor.spaceDetail(space)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleSpacePage renders the drill-down page for one space (/spaces/{id})
+func (d *MonitorDashboard) handleSpacePage(w http.ResponseWriter, r *http.Request) {
+	ref := strings.Trim(strings.TrimPrefix(r.URL.Path, "/spaces/"), "/")
+	space, ok := d.findVisibleSpace(r, ref)
+	if ref == "" || strings.Contains(ref, "/") || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	detail := d.monitor.spaceDetail(space)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := spacePageTemplate.Execute(w, struct {
+		SpaceDetail
+		Chart costChart
+	}{detail, newCostChart(detail.CostSeries, 900, 200)}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// costChart holds SVG polyline points for a cost series
+type costChart struct {
+	Width, Height int
+	Actual        string
+	Predicted     string
+	Max           float64
+}
+
+// newCostChart scales a series to a width x height SVG, zero at the bottom
+func newCostChart(series []SpaceCostPoint, width, height int) costChart {
+	chart := costChart{Width: width, Height: height}
+	if len(series) == 0 {
+		return chart
+	}
+
+	for _, p := range series {
+		chart.Max = max(chart.Max, p.ActualCost, p.PredictedCost)
+	}
+	if chart.Max == 0 {
+		chart.Max = 1
+	}
+
+	var actual, predicted []string
+	for i, p := range series {
+		x := float64(width) / 2
+		if len(series) > 1 {
+			x = float64(i) * float64(width) / float64(len(series)-1)
+		}
+		y := func(cost float64) float64 { return float64(height) - cost/chart.Max*float64(height) }
+		actual = append(actual, fmt.Sprintf("%.1f,%.1f", x, y(p.ActualCost)))
+		predicted = append(predicted, fmt.Sprintf("%.1f,%.1f", x, y(p.PredictedCost)))
+	}
+	chart.Actual = strings.Join(actual, " ")
+	chart.Predicted = strings.Join(predicted, " ")
+	return chart
+}
+
+var spacePageTemplate = template.Must(template.New("space").Funcs(template.FuncMap{
+	"money": func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+	"delta": func(v float64) string {
+		if v >= 0 {
+			return fmt.Sprintf("+$%.2f", v)
+		}
+		return fmt.Sprintf("-$%.2f", -v)
+	},
+	"when": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format("2006-01-02 15:04")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>{{.SpaceName}} - ConfigHub Cost Impact Monitor</title>
+    <meta http-equiv="refresh" content="30">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            min-height: 100vh;
+            padding: 20px;
+        }
+        .container { max-width: 1400px; margin: 0 auto; }
+        .section {
+            background: white;
+            border-radius: 12px;
+            padding: 25px;
+            margin-bottom: 20px;
+            box-shadow: 0 5px 20px rgba(0,0,0,0.08);
+        }
+        h1 { color: #333; font-size: 32px; margin-bottom: 10px; }
+        .section-title { font-size: 20px; font-weight: 600; margin-bottom: 20px; color: #333; }
+        .subtitle, .muted { color: #666; font-size: 14px; }
+        a { color: #667eea; }
+        table { width: 100%; border-collapse: collapse; font-size: 14px; }
+        th, td { text-align: left; padding: 8px; border-bottom: 1px solid #e5e7eb; }
+        th { color: #666; font-weight: 600; }
+        .positive { color: #10b981; }
+        .negative { color: #ef4444; }
+        .risk-badge { padding: 2px 10px; border-radius: 20px; font-size: 12px; font-weight: 600; text-transform: uppercase; }
+        .risk-critical { background: #fef2f2; color: #991b1b; }
+        .risk-high { background: #fef3c7; color: #92400e; }
+        .risk-medium { background: #fef9c3; color: #713f12; }
+        .risk-low { background: #f0fdf4; color: #166534; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="section">
+            <div class="subtitle"><a href="/">← All spaces</a></div>
+            <h1>📦 {{.SpaceName}}</h1>
+            <div class="subtitle">
+                Current {{money .CurrentCost}}/mo • Projected {{money .ProjectedCost}}/mo •
+                Trend {{.CostTrend.Direction}}{{if .CostTrend.WeeklyChange}} ({{printf "%.1f" .CostTrend.WeeklyChange}}%){{end}} •
+                Last analysis {{when .LastAnalysis}}
+                {{with .Baseline}} • vs baseline {{delta .Deviation}}/mo{{end}}
+                {{with .SpendLimit}} • {{printf "%.0f" .PercentUsed}}% of {{money .Limit}} limit used{{end}}
+            </div>
+        </div>
+
+        <div class="section">
+            <h2 class="section-title">📈 Cost Trend</h2>
+            {{if .CostSeries}}
+            <svg viewBox="0 0 {{.Chart.Width}} {{.Chart.Height}}" preserveAspectRatio="none" style="width: 100%; height: 200px;">
+                <polyline fill="none" stroke="#667eea" stroke-width="2" points="{{.Chart.Actual}}"/>
+                <polyline fill="none" stroke="#9ca3af" stroke-width="2" stroke-dasharray="6 4" points="{{.Chart.Predicted}}"/>
+            </svg>
+            <div class="muted">Deployed cost after each deployment: actual (solid) vs predicted (dashed), peak {{money .Chart.Max}}/mo</div>
+            {{else}}
+            <div class="muted">No deployments recorded yet</div>
+            {{end}}
+        </div>
+
+        <div class="section">
+            <h2 class="section-title">⚠️ Pending Changes</h2>
+            {{if .PendingChanges}}
+            <table>
+                <tr><th>Unit</th><th>Change</th><th>Current</th><th>Projected</th><th>Delta</th><th>Risk</th><th>Assessment</th></tr>
+                {{range .PendingChanges}}
+                <tr>
+                    <td>{{.UnitName}}</td>
+                    <td>{{.ChangeType}}</td>
+                    <td>{{money .CurrentCost}}</td>
+                    <td>{{money .ProjectedCost}}</td>
+                    <td class="{{if gt .CostDelta 0.0}}negative{{else}}positive{{end}}">{{delta .CostDelta}}</td>
+                    <td><span class="risk-badge risk-{{.RiskLevel}}">{{.RiskLevel}}</span></td>
+                    <td class="muted">{{.ClaudeAssessment}}</td>
+                </tr>
+                {{end}}
+            </table>
+            {{else}}
+            <div class="muted">No pending changes</div>
+            {{end}}
+        </div>
+
+        <div class="section">
+            <h2 class="section-title">🧩 Units</h2>
+            {{if .Units}}
+            <table>
+                <tr><th>Unit</th><th>Current</th><th>Projected</th><th>Delta</th><th>Share</th></tr>
+                {{range .Units}}
+                <tr>
+                    <td>{{.UnitName}}{{if .HintErrors}} <span class="negative">({{len .HintErrors}} invalid pricing hints)</span>{{end}}</td>
+                    <td>{{money .CurrentCost}}</td>
+                    <td>{{money .ProjectedCost}}</td>
+                    <td>{{delta .CostDelta}}</td>
+                    <td>{{printf "%.1f" .ContributionPercent}}%</td>
+                </tr>
+                {{end}}
+            </table>
+            {{else}}
+            <div class="muted">No units analyzed yet</div>
+            {{end}}
+        </div>
+
+        <div class="section">
+            <h2 class="section-title">📊 Deployment History</h2>
+            {{if .DeploymentHistory}}
+            <table>
+                <tr><th>Deployed</th><th>Unit</th><th>Change</th><th>Target</th><th>Predicted</th><th>Actual</th><th>Variance</th></tr>
+                {{range .DeploymentHistory}}
+                <tr>
+                    <td>{{when .DeployTime}}</td>
+                    <td>{{.UnitName}}</td>
+                    <td>{{.ChangeType}}</td>
+                    <td>{{.Target}}</td>
+                    <td>{{money .PredictedCost}}</td>
+                    <td>{{money .ActualCost}}</td>
+                    <td class="{{if .Accurate}}positive{{else}}negative{{end}}">{{printf "%.1f" .Variance}}%</td>
+                </tr>
+                {{end}}
+            </table>
+            {{else}}
+            <div class="muted">No deployments recorded yet</div>
+            {{end}}
+        </div>
+    </div>
+</body>
+</html>`))
@@ -0,0 +1,91 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSpaceCostSeries(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	records := []DeploymentCostRecord{
+		{UnitID: "api", DeployTime: t0.Add(2 * time.Hour), PredictedCost: 120, ActualCost: 130},
+		{UnitID: "api", DeployTime: t0, PredictedCost: 100, ActualCost: 90},
+		{UnitID: "db", DeployTime: t0.Add(time.Hour), PredictedCost: 50, ActualCost: 55},
+	}
+
+	series := spaceCostSeries(records)
+	want := []SpaceCostPoint{
+		{Time: t0, ActualCost: 90, PredictedCost: 100},
+		{Time: t0.Add(time.Hour), ActualCost: 145, PredictedCost: 150},
+		{Time: t0.Add(2 * time.Hour), ActualCost: 185, PredictedCost: 170},
+	}
+	if len(series) != len(want) {
+		t.Fatalf("series = %+v", series)
+	}
+	for i := range want {
+		if series[i] != want[i] {
+			t.Errorf("point %d = %+v, want %+v", i, series[i], want[i])
+		}
+	}
+}
+
+func TestSpaceDrillDown(t *testing.T) {
+	id := uuid.New()
+	space := &SpaceMonitor{
+		SpaceID:       id,
+		SpaceName:     "<prod>",
+		CurrentCost:   200,
+		ProjectedCost: 260,
+		PendingChanges: []PendingChange{
+			{UnitID: "u1", UnitName: "backend", ChangeType: "update", CostDelta: 60, RiskLevel: "medium"},
+		},
+		UnitCosts: []UnitCost{
+			{UnitID: "u2", UnitName: "cache", ProjectedCost: 60},
+			{UnitID: "u1", UnitName: "backend", ProjectedCost: 200},
+		},
+		DeploymentHistory: []DeploymentCostRecord{
+			{UnitID: "u1", UnitName: "backend", DeployTime: time.Now().Add(-time.Hour), PredictedCost: 140, ActualCost: 150},
+		},
+	}
+	d := &MonitorDashboard{monitor: &CostImpactMonitor{monitoredSpaces: map[uuid.UUID]*SpaceMonitor{id: space}}}
+
+	rec := httptest.NewRecorder()
+	d.handleSpaceRoutes(rec, httptest.NewRequest(http.MethodGet, "/api/v1/spaces/"+id.String(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("api status = %d", rec.Code)
+	}
+	var detail SpaceDetail
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatalf("decode detail: %v", err)
+	}
+	if len(detail.Units) != 2 || detail.Units[0].UnitName != "backend" {
+		t.Errorf("units not sorted by projected cost: %+v", detail.Units)
+	}
+	if len(detail.PendingChanges) != 1 || len(detail.CostSeries) != 1 {
+		t.Errorf("detail = %+v", detail)
+	}
+
+	rec = httptest.NewRecorder()
+	d.handleSpacePage(rec, httptest.NewRequest(http.MethodGet, "/spaces/%3Cprod%3E", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("page status = %d", rec.Code)
+	}
+	page := rec.Body.String()
+	for _, want := range []string{"&lt;prod&gt;", "backend", "$60.00", "<polyline"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	d.handleSpacePage(rec, httptest.NewRequest(http.MethodGet, "/spaces/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown space status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,241 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// spendLimitLabel is the ConfigHub space label holding the monthly limit in dollars
+const spendLimitLabel = "monthly-spend-limit"
+
+// spendAlertThresholds are the percentages of the limit that raise an alert, once per month
+var spendAlertThresholds = []float64{80, 100, 120}
+
+// SpendLimit tracks a space's spend for the calendar month against its limit
+type SpendLimit struct {
+	Limit          float64   `json:"limit"`
+	Month          string    `json:"month"` // "2006-01"
+	RunRate        float64   `json:"run_rate"`
+	ConsumedToDate float64   `json:"consumed_to_date"`
+	ProjectedMonth float64   `json:"projected_month"` // consumed so far plus the run-rate for the rest of the month
+	PercentUsed    float64   `json:"percent_used"`
+	AlertsSent     []float64 `json:"alerts_sent"`
+	LastUpdated    time.Time `json:"last_updated"`
+	LastReport     time.Time `json:"last_report"`
+}
+
+// monthBounds returns the start of now's calendar month and its length
+func monthBounds(now time.Time) (time.Time, time.Duration) {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 1, 0).Sub(start)
+}
+
+// update charges the previous run-rate for the time since the last update,
+// starting over at each new month, and returns thresholds crossed for the first time
+func (s *SpendLimit) update(currentCost float64, now time.Time) []float64 {
+	monthStart, monthLength := monthBounds(now)
+
+	if month := now.Format("2006-01"); month != s.Month {
+		s.Month = month
+		s.ConsumedToDate = 0
+		s.AlertsSent = nil
+		if s.LastUpdated.Before(monthStart) && !s.LastUpdated.IsZero() {
+			s.LastUpdated = monthStart
+		}
+	}
+
+	if !s.LastUpdated.IsZero() {
+		if elapsed := now.Sub(s.LastUpdated); elapsed > 0 {
+			s.ConsumedToDate += s.RunRate * float64(elapsed) / float64(monthLength)
+		}
+	}
+	s.RunRate = currentCost
+	s.LastUpdated = now
+
+	remaining := monthStart.Add(monthLength).Sub(now)
+	s.ProjectedMonth = s.ConsumedToDate + currentCost*float64(remaining)/float64(monthLength)
+	s.PercentUsed = 0
+	if s.Limit > 0 {
+		s.PercentUsed = s.ConsumedToDate / s.Limit * 100
+	}
+
+	var crossed []float64
+	for _, threshold := range spendAlertThresholds {
+		if s.PercentUsed < threshold || containsThreshold(s.AlertsSent, threshold) {
+			continue
+		}
+		s.AlertsSent = append(s.AlertsSent, threshold)
+		crossed = append(crossed, threshold)
+	}
+	return crossed
+}
+
+func containsThreshold(sent []float64, threshold float64) bool {
+	for _, t := range sent {
+		if t == threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// reportDue reports whether a weekly status hasn't been published this ISO week
+func (s *SpendLimit) reportDue(now time.Time) bool {
+	year, week := now.ISOWeek()
+	lastYear, lastWeek := s.LastReport.ISOWeek()
+	return year != lastYear || week != lastWeek
+}
+
+// syncSpendLimits applies the monthly-spend-limit label of each ConfigHub space,
+// keeping consumption already tracked for the month
+func (m *CostImpactMonitor) syncSpendLimits(spaces []*sdk.Space) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range spaces {
+		space, exists := m.monitoredSpaces[s.SpaceID]
+		if !exists {
+			continue
+		}
+
+		raw, ok := s.Labels[spendLimitLabel]
+		if !ok {
+			space.SpendLimit = nil
+			continue
+		}
+		limit, err := strconv.ParseFloat(raw, 64)
+		if err != nil || limit <= 0 {
+			slog.Warn("Ignoring invalid spend limit", logging.Space(s.Slug), "label", spendLimitLabel, "value", raw)
+			continue
+		}
+
+		if space.SpendLimit == nil {
+			space.SpendLimit = &SpendLimit{}
+		}
+		space.SpendLimit.Limit = limit
+	}
+}
+
+// refreshSpendLimits re-reads space labels so limit changes apply without a restart
+func (m *CostImpactMonitor) refreshSpendLimits(ctx context.Context) {
+	var spaces []*sdk.Space
+	for _, source := range m.spaceSources() {
+		listed, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+			return ratelimit.Call(ctx, m.cubLimit, "ListSpaces", source.key(), source.cub.ListSpaces)
+		})
+		if err != nil {
+			slog.Warn("Failed to refresh spend limits", logging.Err(err))
+			return
+		}
+		spaces = append(spaces, listed...)
+	}
+	m.syncSpendLimits(spaces)
+}
+
+// trackSpend updates a space's month-to-date spend, alerting on new thresholds
+// and publishing the weekly status unit
+func (m *CostImpactMonitor) trackSpend(space *SpaceMonitor, now time.Time) {
+	m.mu.Lock()
+	limit := space.SpendLimit
+	if limit == nil {
+		m.mu.Unlock()
+		return
+	}
+	crossed := limit.update(space.CurrentCost, now)
+	status := *limit
+	report := m.leader.IsLeader() && limit.reportDue(now)
+	if report {
+		limit.LastReport = now
+	}
+	m.mu.Unlock()
+
+	// Only the leader alerts and writes to ConfigHub
+	if !m.leader.IsLeader() {
+		return
+	}
+
+	for _, threshold := range crossed {
+		slog.Warn("Spend limit threshold crossed", logging.Space(space.SpaceName), "threshold", threshold,
+			"percent_used", status.PercentUsed, "limit", status.Limit, "consumed", status.ConsumedToDate, "projected", status.ProjectedMonth)
+		m.dashboard.events.Publish("spend-alert", map[string]interface{}{
+			"space_name": space.SpaceName,
+			"threshold":  threshold,
+			"status":     status,
+		})
+		m.notifySpendAlert(space, threshold, status)
+		m.createSpendUnit(space, fmt.Sprintf("spend-alert-%s-%.0f", status.Month, threshold),
+			fmt.Sprintf("Spend Alert: %.0f%% of monthly limit", threshold), "spend-alert", status)
+	}
+
+	if report {
+		year, week := now.ISOWeek()
+		m.createSpendUnit(space, fmt.Sprintf("spend-status-%d-w%02d", year, week),
+			fmt.Sprintf("Spend Status: week %d of %d", week, year), "spend-status", status)
+	}
+}
+
+// createSpendUnit stores a spend alert or weekly status in the space
+func (m *CostImpactMonitor) createSpendUnit(space *SpaceMonitor, slug, displayName, kind string, status SpendLimit) {
+	cub := m.cubFor(space.SpaceID)
+	if cub == nil {
+		return
+	}
+
+	data, _ := json.MarshalIndent(status, "", "  ")
+
+	_, err := cub.CreateUnit(space.SpaceID, sdk.CreateUnitRequest{
+		Slug:        slug,
+		DisplayName: displayName,
+		Data:        string(data),
+		Labels: map[string]string{
+			"type":         kind,
+			"month":        status.Month,
+			"percent_used": fmt.Sprintf("%.1f", status.PercentUsed),
+		},
+	})
+	m.audit.Record(m.auditContext(space.SpaceID), audit.UnitCreated, slug, status, err)
+	if err != nil {
+		slog.Warn("Failed to create spend unit", logging.Space(space.SpaceName), logging.Unit(slug), "kind", kind, logging.Err(err))
+	}
+}
+
+// handleSpendLimit returns a space's month-to-date spend against its limit
+func (d *MonitorDashboard) handleSpendLimit(w http.ResponseWriter, r *http.Request, space *SpaceMonitor) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.monitor.mu.RLock()
+	var status *SpendLimit
+	if space.SpendLimit != nil {
+		copied := *space.SpendLimit
+		status = &copied
+	}
+	d.monitor.mu.RUnlock()
+
+	if status == nil {
+		http.Error(w, "no "+spendLimitLabel+" label on space", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(SpaceSpendLimit{
+		SpaceID:    space.SpaceID,
+		SpaceName:  space.SpaceName,
+		SpendLimit: status,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
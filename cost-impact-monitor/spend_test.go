@@ -0,0 +1,99 @@
+package costimpactmonitor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestSpendLimitAlertsOncePerThreshold(t *testing.T) {
+	// November has 30 days, so $3000/month is $100/day
+	start := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	s := &SpendLimit{Limit: 1000}
+	s.update(3000, start)
+
+	tests := []struct {
+		day  int
+		want []float64
+	}{
+		{5, nil},                  // $500
+		{8, []float64{80}},        // $800
+		{9, nil},                  // $900
+		{13, []float64{100, 120}}, // $1200
+		{14, nil},
+	}
+
+	for _, tt := range tests {
+		crossed := s.update(3000, start.AddDate(0, 0, tt.day))
+		if len(crossed) != len(tt.want) {
+			t.Fatalf("day %d: crossed %v, want %v", tt.day, crossed, tt.want)
+		}
+		for i := range tt.want {
+			if crossed[i] != tt.want[i] {
+				t.Errorf("day %d: crossed %v, want %v", tt.day, crossed, tt.want)
+			}
+		}
+	}
+
+	if math.Abs(s.ConsumedToDate-1400) > 0.01 || math.Abs(s.ProjectedMonth-3000) > 0.01 {
+		t.Errorf("consumed $%.2f projected $%.2f, want $1400 and $3000", s.ConsumedToDate, s.ProjectedMonth)
+	}
+}
+
+func TestSpendLimitResetsEachMonth(t *testing.T) {
+	s := &SpendLimit{Limit: 80}
+	s.update(3000, time.Date(2024, 11, 29, 0, 0, 0, 0, time.UTC))
+	s.update(3000, time.Date(2024, 11, 30, 0, 0, 0, 0, time.UTC))
+	if len(s.AlertsSent) != 3 {
+		t.Fatalf("alerts sent = %v, want all thresholds", s.AlertsSent)
+	}
+
+	// Only the hours after midnight on December 1st count towards December
+	s.update(3100, time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC))
+	if s.Month != "2024-12" || len(s.AlertsSent) != 0 {
+		t.Errorf("month %s alerts %v, want 2024-12 with alerts reset", s.Month, s.AlertsSent)
+	}
+	if math.Abs(s.ConsumedToDate-3000.0/31/2) > 0.01 {
+		t.Errorf("consumed = $%.2f, want half a day of December", s.ConsumedToDate)
+	}
+}
+
+func TestSpendLimitWeeklyReport(t *testing.T) {
+	s := &SpendLimit{}
+	monday := time.Date(2024, 11, 4, 9, 0, 0, 0, time.UTC)
+
+	if !s.reportDue(monday) {
+		t.Fatal("first report should be due")
+	}
+	s.LastReport = monday
+	if s.reportDue(monday.AddDate(0, 0, 6)) {
+		t.Error("report due again in the same week")
+	}
+	if !s.reportDue(monday.AddDate(0, 0, 7)) {
+		t.Error("report not due the following week")
+	}
+}
+
+func TestSyncSpendLimits(t *testing.T) {
+	m := newStateTestMonitor()
+	prod, dev := uuid.New(), uuid.New()
+	m.monitoredSpaces[prod] = &SpaceMonitor{SpaceID: prod, SpaceName: "prod",
+		SpendLimit: &SpendLimit{Limit: 500, ConsumedToDate: 120}}
+	m.monitoredSpaces[dev] = &SpaceMonitor{SpaceID: dev, SpaceName: "dev",
+		SpendLimit: &SpendLimit{Limit: 50}}
+
+	m.syncSpendLimits([]*sdk.Space{
+		{SpaceID: prod, Slug: "prod", Labels: map[string]string{spendLimitLabel: "800"}},
+		{SpaceID: dev, Slug: "dev"},
+	})
+
+	if got := m.monitoredSpaces[prod].SpendLimit; got == nil || got.Limit != 800 || got.ConsumedToDate != 120 {
+		t.Errorf("prod limit = %+v, want 800 with consumption kept", got)
+	}
+	if m.monitoredSpaces[dev].SpendLimit != nil {
+		t.Error("dev limit should be removed with its label")
+	}
+}
@@ -0,0 +1,147 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// monitorState is what survives a restart: per-space analysis, pending
+// changes and trend history, plus trigger timestamps and processed
+// revisions so hooks don't re-fire
+type monitorState struct {
+	SavedAt       time.Time            `json:"saved_at"`
+	Spaces        []*SpaceMonitor      `json:"spaces"`
+	LastProcessed map[string]time.Time `json:"last_processed"`
+	Revisions     map[string]int       `json:"revisions"`
+	Statuses      map[string]string    `json:"statuses"`
+}
+
+// saveState writes the monitor state to path atomically
+func (m *CostImpactMonitor) saveState(path string) error {
+	data, err := m.encodeState()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename state: %w", err)
+	}
+
+	return nil
+}
+
+// encodeState returns the monitor state as JSON, encoded under the monitor's
+// lock since the spaces keep changing
+func (m *CostImpactMonitor) encodeState() ([]byte, error) {
+	state := monitorState{
+		SavedAt:       time.Now(),
+		LastProcessed: make(map[string]time.Time),
+		Revisions:     make(map[string]int),
+		Statuses:      make(map[string]string),
+	}
+
+	// Copy trigger bookkeeping first so we never hold both locks at once
+	t := m.triggerProcessor
+	t.mu.Lock()
+	for unitID, ts := range t.lastProcessed {
+		state.LastProcessed[unitID] = ts
+	}
+	if c := t.changeDetector; c != nil {
+		for unitID, rev := range c.revisionCache {
+			state.Revisions[unitID] = rev
+		}
+		for unitID, status := range c.statusCache {
+			state.Statuses[unitID] = status
+		}
+	}
+	t.mu.Unlock()
+
+	m.mu.RLock()
+	for _, space := range m.monitoredSpaces {
+		state.Spaces = append(state.Spaces, space)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("encode state: %w", err)
+	}
+	return data, nil
+}
+
+// restoreState loads a previously saved state. Spaces that were discovered
+// on startup get their history back; if nothing was discovered (ConfigHub
+// unreachable) the saved spaces are adopted as-is. A missing file is not an error.
+func (m *CostImpactMonitor) restoreState(path string) (*monitorState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+
+	var state monitorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+	m.applyState(&state)
+	return &state, nil
+}
+
+// applyState merges a saved state into the monitor. A space's saved analysis
+// older than the one it has is skipped, so the state file and the checkpoint
+// can both be restored; trigger timestamps and revisions keep the latest.
+func (m *CostImpactMonitor) applyState(state *monitorState) {
+	m.mu.Lock()
+	adopt := len(m.monitoredSpaces) == 0
+	for _, saved := range state.Spaces {
+		current, exists := m.monitoredSpaces[saved.SpaceID]
+		switch {
+		case exists && saved.LastAnalysis.Before(current.LastAnalysis):
+			// keep the newer analysis
+		case exists:
+			current.LastAnalysis = saved.LastAnalysis
+			current.CurrentCost = saved.CurrentCost
+			current.ProjectedCost = saved.ProjectedCost
+			current.PendingChanges = saved.PendingChanges
+			current.DeploymentHistory = saved.DeploymentHistory
+			current.CostTrend = saved.CostTrend
+			current.Baseline = saved.Baseline
+			current.SpendLimit = saved.SpendLimit
+		case adopt:
+			m.monitoredSpaces[saved.SpaceID] = saved
+		}
+	}
+	m.mu.Unlock()
+
+	t := m.triggerProcessor
+	t.mu.Lock()
+	for unitID, ts := range state.LastProcessed {
+		if ts.After(t.lastProcessed[unitID]) {
+			t.lastProcessed[unitID] = ts
+		}
+	}
+	if c := t.changeDetector; c != nil {
+		for unitID, rev := range state.Revisions {
+			if rev > c.revisionCache[unitID] {
+				c.revisionCache[unitID] = rev
+			}
+		}
+		for unitID, status := range state.Statuses {
+			c.statusCache[unitID] = status
+		}
+	}
+	t.mu.Unlock()
+}
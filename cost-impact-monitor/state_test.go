@@ -0,0 +1,144 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+)
+
+func newStateTestMonitor() *CostImpactMonitor {
+	m := &CostImpactMonitor{monitoredSpaces: make(map[uuid.UUID]*SpaceMonitor)}
+	m.triggerProcessor = &TriggerProcessor{monitor: m, lastProcessed: make(map[string]time.Time)}
+	m.leader = &LeaderElector{}
+	m.leader.leading.Store(true)
+	return m
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	spaceID := uuid.New()
+	processed := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	saved := newStateTestMonitor()
+	saved.monitoredSpaces[spaceID] = &SpaceMonitor{
+		SpaceID:        spaceID,
+		SpaceName:      "prod",
+		CurrentCost:    120,
+		PendingChanges: []PendingChange{{UnitName: "backend", CostDelta: 40}},
+		DeploymentHistory: []DeploymentCostRecord{
+			{UnitName: "backend", ActualCost: 80},
+		},
+	}
+	saved.triggerProcessor.lastProcessed["unit-1"] = processed
+
+	if err := saved.saveState(path); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	// Space rediscovered on startup keeps its identity but gets history back
+	restored := newStateTestMonitor()
+	restored.monitoredSpaces[spaceID] = &SpaceMonitor{SpaceID: spaceID, SpaceName: "prod"}
+
+	state, err := restored.restoreState(path)
+	if err != nil {
+		t.Fatalf("restoreState: %v", err)
+	}
+	if state == nil || len(state.Spaces) != 1 {
+		t.Fatalf("restored state = %+v, want 1 space", state)
+	}
+
+	space := restored.monitoredSpaces[spaceID]
+	if space.CurrentCost != 120 || len(space.PendingChanges) != 1 || len(space.DeploymentHistory) != 1 {
+		t.Errorf("space not restored: %+v", space)
+	}
+	if got := restored.triggerProcessor.lastProcessed["unit-1"]; !got.Equal(processed) {
+		t.Errorf("lastProcessed = %v, want %v", got, processed)
+	}
+}
+
+func TestRestoreStateMissingFile(t *testing.T) {
+	m := newStateTestMonitor()
+	state, err := m.restoreState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || state != nil {
+		t.Errorf("restoreState() = %v, %v; want nil, nil", state, err)
+	}
+}
+
+func TestRestoreStateSkipsVanishedSpaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	saved := newStateTestMonitor()
+	gone := uuid.New()
+	saved.monitoredSpaces[gone] = &SpaceMonitor{SpaceID: gone, SpaceName: "deleted"}
+	if err := saved.saveState(path); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	restored := newStateTestMonitor()
+	kept := uuid.New()
+	restored.monitoredSpaces[kept] = &SpaceMonitor{SpaceID: kept, SpaceName: "prod"}
+	if _, err := restored.restoreState(path); err != nil {
+		t.Fatalf("restoreState: %v", err)
+	}
+
+	if _, exists := restored.monitoredSpaces[gone]; exists {
+		t.Error("space deleted from ConfigHub was restored")
+	}
+}
+
+func TestStateCheckpoint(t *testing.T) {
+	var saved string
+	writer := func(_ context.Context, _ string, _ map[string]string, data string) error {
+		saved = data
+		return nil
+	}
+	reader := func(context.Context, string) ([]string, error) { return []string{saved}, nil }
+	spaceID := uuid.New()
+	analyzed := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	leader := newStateTestMonitor()
+	leader.monitoredSpaces[spaceID] = &SpaceMonitor{SpaceID: spaceID, SpaceName: "prod", CurrentCost: 120, LastAnalysis: analyzed}
+	leader.triggerProcessor.lastProcessed["unit-1"] = analyzed
+	cp := checkpoint.New("cost-impact-monitor", writer, reader)
+	leader.registerCheckpoint(cp)
+	if err := cp.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A standby replica doesn't overwrite the leader's checkpoint
+	standby := newStateTestMonitor()
+	standby.leader.leading.Store(false)
+	cp = checkpoint.New("cost-impact-monitor", writer, reader)
+	standby.registerCheckpoint(cp)
+	if err := cp.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A space analyzed since the checkpoint keeps its newer analysis
+	restarted := newStateTestMonitor()
+	restarted.monitoredSpaces[spaceID] = &SpaceMonitor{SpaceID: spaceID, SpaceName: "prod"}
+	cp = checkpoint.New("cost-impact-monitor", writer, reader)
+	restarted.registerCheckpoint(cp)
+	if err := cp.Restore(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if space := restarted.monitoredSpaces[spaceID]; space.CurrentCost != 120 {
+		t.Errorf("space not restored: %+v", space)
+	}
+	if got := restarted.triggerProcessor.lastProcessed["unit-1"]; !got.Equal(analyzed) {
+		t.Errorf("lastProcessed = %v, want %v", got, analyzed)
+	}
+
+	restarted.monitoredSpaces[spaceID].CurrentCost = 150
+	restarted.monitoredSpaces[spaceID].LastAnalysis = time.Now()
+	if err := cp.Restore(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if space := restarted.monitoredSpaces[spaceID]; space.CurrentCost != 150 {
+		t.Errorf("newer analysis overwritten: %+v", space)
+	}
+}
@@ -0,0 +1,151 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// spaceSource is a ConfigHub client spaces are discovered with: a team's,
+// or the app's in single-tenant mode
+type spaceSource struct {
+	team *tenants.Team // nil for the app's own client
+	cub  *sdk.ConfigHubClient
+}
+
+// key is the coalescing key of the source's ListSpaces call; teams never
+// share results, as their tokens see different spaces
+func (s spaceSource) key() string {
+	if s.team == nil {
+		return "all"
+	}
+	return "team:" + s.team.Name
+}
+
+// spaceSources returns one source per team, or the app's client
+func (m *CostImpactMonitor) spaceSources() []spaceSource {
+	if m.teams != nil {
+		sources := make([]spaceSource, 0, len(m.teams.Teams()))
+		for _, team := range m.teams.Teams() {
+			sources = append(sources, spaceSource{team: team, cub: m.teamCubs[team.Name]})
+		}
+		return sources
+	}
+	if m.app.Cub == nil {
+		return nil
+	}
+	return []spaceSource{{cub: m.app.Cub}}
+}
+
+// newTeamClients creates a ConfigHub client per team, with the team's token
+func newTeamClients(teams *tenants.Registry, baseURL string) map[string]*sdk.ConfigHubClient {
+	clients := make(map[string]*sdk.ConfigHubClient, len(teams.Teams()))
+	for _, team := range teams.Teams() {
+		clients[team.Name] = sdk.NewConfigHubClient(baseURL, team.CubToken)
+	}
+	return clients
+}
+
+// cubFor returns the ConfigHub client for a space: the one of the team that
+// owns it, otherwise the app's. It is nil when ConfigHub is not configured.
+func (m *CostImpactMonitor) cubFor(spaceID uuid.UUID) *sdk.ConfigHubClient {
+	if m.teams == nil {
+		return m.app.Cub
+	}
+	m.mu.RLock()
+	space, ok := m.monitoredSpaces[spaceID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return m.teamCubs[space.Team]
+}
+
+// hasConfigHub reports whether any ConfigHub client is configured
+func (m *CostImpactMonitor) hasConfigHub() bool {
+	return len(m.spaceSources()) > 0
+}
+
+// auditContext returns a context recording actions in a space as those of
+// the team owning it
+func (m *CostImpactMonitor) auditContext(spaceID uuid.UUID) context.Context {
+	ctx := context.Background()
+	m.mu.RLock()
+	space, ok := m.monitoredSpaces[spaceID]
+	m.mu.RUnlock()
+	if !ok {
+		return ctx
+	}
+	if team := m.teams.Team(space.Team); team != nil {
+		ctx = tenants.WithTeam(ctx, team)
+	}
+	return ctx
+}
+
+// spaceVisible reports whether team may see a space, given by ID or name
+func (m *CostImpactMonitor) spaceVisible(team *tenants.Team, spaceID, spaceName string) bool {
+	if team.Unrestricted() {
+		return true
+	}
+	if id, err := uuid.Parse(spaceID); err == nil {
+		m.mu.RLock()
+		space, ok := m.monitoredSpaces[id]
+		m.mu.RUnlock()
+		if ok {
+			spaceName = space.SpaceName
+		}
+	}
+	return spaceName != "" && team.OwnsSpace(spaceName)
+}
+
+// findVisibleSpace resolves a space slug or ID the request's team may see
+func (d *MonitorDashboard) findVisibleSpace(r *http.Request, ref string) (*SpaceMonitor, bool) {
+	space, ok := d.monitor.findSpace(ref)
+	if !ok || !tenants.FromContext(r.Context()).OwnsSpace(space.SpaceName) {
+		return nil, false
+	}
+	return space, true
+}
+
+// requireUnrestricted rejects requests from teams limited to some spaces,
+// for views that are not split by space
+func requireUnrestricted(w http.ResponseWriter, r *http.Request) bool {
+	if tenants.FromContext(r.Context()).Unrestricted() {
+		return true
+	}
+	tenants.Forbidden(w)
+	return false
+}
+
+// teamEvents rewrites the dashboard's events for a team: snapshots and
+// history summaries are recomputed from its spaces, other events are only
+// passed on for its spaces
+func (d *MonitorDashboard) teamEvents(team *tenants.Team) func(sseEvent) (sseEvent, bool) {
+	return func(event sseEvent) (sseEvent, bool) {
+		var v interface{}
+		switch event.Name {
+		case "snapshot":
+			v = d.monitor.snapshotFor(team)
+		case "history":
+			v = d.historySummary(team)
+		default:
+			var owner struct {
+				SpaceID   string `json:"space_id"`
+				SpaceName string `json:"space_name"`
+			}
+			if err := json.Unmarshal(event.Data, &owner); err != nil {
+				return event, false
+			}
+			return event, d.monitor.spaceVisible(team, owner.SpaceID, owner.SpaceName)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return event, false
+		}
+		return sseEvent{Name: event.Name, Data: data}, true
+	}
+}
@@ -0,0 +1,167 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newTeamsDashboard serves two teams: payments sees its own space, platform every space
+func newTeamsDashboard(t *testing.T) (http.Handler, *MonitorDashboard, uuid.UUID, uuid.UUID) {
+	t.Helper()
+	teams, err := tenants.Config{Teams: []*tenants.Team{
+		{Name: "payments", Spaces: []string{"payments-*"}, CubToken: "c1", APIToken: "payments-token"},
+		{Name: "platform", Spaces: []string{"*"}, CubToken: "c2", APIToken: "platform-token"},
+	}}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payments, checkout := uuid.New(), uuid.New()
+	m := &CostImpactMonitor{
+		teams: teams,
+		monitoredSpaces: map[uuid.UUID]*SpaceMonitor{
+			payments: {SpaceID: payments, SpaceName: "payments-prod", Team: "payments", CurrentCost: 100,
+				PendingChanges: []PendingChange{{UnitName: "api", RiskLevel: "low"}}},
+			checkout: {SpaceID: checkout, SpaceName: "checkout-prod", Team: "platform", CurrentCost: 300,
+				PendingChanges: []PendingChange{{UnitName: "cart", RiskLevel: "high"}}},
+		},
+		terraformPlans:   map[string]*TerraformPlanImpact{},
+		triggerProcessor: &TriggerProcessor{lastProcessed: map[string]time.Time{}},
+		leader:           NewLeaderElector(&sdk.DevOpsApp{}, Config{}),
+	}
+	d := &MonitorDashboard{monitor: m}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/snapshot", d.handleSnapshot)
+	mux.HandleFunc("/api/v1/spaces", d.handleSpaces)
+	mux.HandleFunc("/api/v1/spaces/", d.handleSpaceRoutes)
+	mux.HandleFunc("/api/v1/pending", d.handlePendingChanges)
+	mux.HandleFunc("/api/v1/triggers", d.handleTriggers)
+	return teams.Protect("test", mux), d, payments, checkout
+}
+
+func get(t *testing.T, h http.Handler, path, token string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK && v != nil {
+		if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestTeamViews(t *testing.T) {
+	h, _, payments, checkout := newTeamsDashboard(t)
+
+	if code := get(t, h, "/api/v1/spaces", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous /api/v1/spaces = %d, want 401", code)
+	}
+
+	var spaces struct {
+		Spaces []SpaceMonitor `json:"spaces"`
+	}
+	get(t, h, "/api/v1/spaces", "payments-token", &spaces)
+	if len(spaces.Spaces) != 1 || spaces.Spaces[0].SpaceName != "payments-prod" {
+		t.Errorf("payments sees %+v", spaces.Spaces)
+	}
+	get(t, h, "/api/v1/spaces", "platform-token", &spaces)
+	if len(spaces.Spaces) != 2 {
+		t.Errorf("platform sees %d spaces, want 2", len(spaces.Spaces))
+	}
+
+	if code := get(t, h, "/api/v1/spaces/"+checkout.String(), "payments-token", nil); code != http.StatusNotFound {
+		t.Errorf("payments reading checkout-prod = %d, want 404", code)
+	}
+	if code := get(t, h, "/api/v1/spaces/"+payments.String(), "payments-token", nil); code != http.StatusOK {
+		t.Errorf("payments reading its space = %d", code)
+	}
+
+	var snapshot MonitoringSnapshot
+	get(t, h, "/api/v1/snapshot", "payments-token", &snapshot)
+	if snapshot.TotalSpaces != 1 || snapshot.TotalCost != 100 {
+		t.Errorf("payments snapshot = %d spaces, $%.0f", snapshot.TotalSpaces, snapshot.TotalCost)
+	}
+
+	var pending struct {
+		Changes []map[string]interface{} `json:"pending_changes"`
+	}
+	get(t, h, "/api/v1/pending", "payments-token", &pending)
+	if len(pending.Changes) != 1 || pending.Changes[0]["unit_name"] != "api" {
+		t.Errorf("payments pending = %v", pending.Changes)
+	}
+
+	if code := get(t, h, "/api/v1/triggers", "payments-token", nil); code != http.StatusForbidden {
+		t.Errorf("payments /api/v1/triggers = %d, want 403", code)
+	}
+	if code := get(t, h, "/api/v1/triggers", "platform-token", nil); code != http.StatusOK {
+		t.Errorf("platform /api/v1/triggers = %d", code)
+	}
+}
+
+func TestTeamEvents(t *testing.T) {
+	_, d, payments, checkout := newTeamsDashboard(t)
+	filter := d.teamEvents(d.monitor.teams.Team("payments"))
+
+	for _, tt := range []struct {
+		space string
+		want  bool
+	}{{payments.String(), true}, {checkout.String(), false}} {
+		data, _ := json.Marshal(CostImpact{UnitName: "x", SpaceID: tt.space})
+		if _, ok := filter(sseEvent{Name: "impact", Data: data}); ok != tt.want {
+			t.Errorf("impact in %s passed = %t, want %t", tt.space, ok, tt.want)
+		}
+	}
+
+	event, ok := filter(sseEvent{Name: "snapshot", Data: []byte(`{}`)})
+	var snapshot MonitoringSnapshot
+	if err := json.Unmarshal(event.Data, &snapshot); !ok || err != nil || snapshot.TotalSpaces != 1 {
+		t.Errorf("snapshot for payments = %s", event.Data)
+	}
+}
+
+// BenchmarkSnapshotFor builds the dashboard snapshot of a large install,
+// once for every space and once for a team owning a tenth of them
+func BenchmarkSnapshotFor(b *testing.B) {
+	m := &CostImpactMonitor{monitoredSpaces: map[uuid.UUID]*SpaceMonitor{}, terraformPlans: map[string]*TerraformPlanImpact{}}
+	for i := 0; i < 500; i++ {
+		name := fmt.Sprintf("app-%d-prod", i)
+		if i%10 == 0 {
+			name = fmt.Sprintf("payments-%d-prod", i)
+		}
+		space := &SpaceMonitor{SpaceID: uuid.New(), SpaceName: name, CurrentCost: 100, ProjectedCost: 120}
+		for j := 0; j < 20; j++ {
+			space.PendingChanges = append(space.PendingChanges, PendingChange{UnitName: fmt.Sprintf("unit-%d", j), RiskLevel: []string{"low", "medium", "high"}[j%3]})
+		}
+		m.monitoredSpaces[space.SpaceID] = space
+	}
+	team := &tenants.Team{Name: "payments", Spaces: []string{"payments-*"}}
+
+	for _, bench := range []struct {
+		name   string
+		team   *tenants.Team
+		spaces int
+	}{{"all", nil, 500}, {"team", team, 50}} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if s := m.snapshotFor(bench.team); s.TotalSpaces != bench.spaces {
+					b.Fatalf("Expected %d spaces, got %d", bench.spaces, s.TotalSpaces)
+				}
+			}
+		})
+	}
+}
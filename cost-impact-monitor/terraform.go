@@ -0,0 +1,307 @@
+package costimpactmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// TerraformPlanImpact is the Kubernetes-relevant cost impact of one Terraform plan
+type TerraformPlanImpact struct {
+	Name       string          `json:"name"`
+	Source     string          `json:"source"` // "upload" or the watched file path
+	Space      string          `json:"space,omitempty"`
+	AnalyzedAt time.Time       `json:"analyzed_at"`
+	Changes    []PendingChange `json:"changes"`
+	CostDelta  float64         `json:"cost_delta"`
+}
+
+// terraformPlan is the subset of `terraform show -json <plan>` we read
+type terraformPlan struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Type    string `json:"type"`
+		Change  struct {
+			Actions []string               `json:"actions"`
+			Before  map[string]interface{} `json:"before"`
+			After   map[string]interface{} `json:"after"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// nodeShape is the capacity of a single node, and the cloud selling it
+type nodeShape struct {
+	CPU      float64
+	MemoryGB float64
+	Provider string
+}
+
+// nodeShapes covers the instance types commonly used for node pools
+var nodeShapes = map[string]nodeShape{
+	"t3.medium": {2, 4, costmodel.AWS}, "t3.large": {2, 8, costmodel.AWS}, "t3.xlarge": {4, 16, costmodel.AWS},
+	"m5.large": {2, 8, costmodel.AWS}, "m5.xlarge": {4, 16, costmodel.AWS}, "m5.2xlarge": {8, 32, costmodel.AWS},
+	"c5.large": {2, 4, costmodel.AWS}, "c5.xlarge": {4, 8, costmodel.AWS},
+	"r5.large": {2, 16, costmodel.AWS}, "r5.xlarge": {4, 32, costmodel.AWS},
+
+	"e2-medium": {2, 4, costmodel.GCP}, "e2-standard-2": {2, 8, costmodel.GCP}, "e2-standard-4": {4, 16, costmodel.GCP},
+	"n2-standard-2": {2, 8, costmodel.GCP}, "n2-standard-4": {4, 16, costmodel.GCP}, "n2-standard-8": {8, 32, costmodel.GCP},
+
+	"Standard_B2s": {2, 4, costmodel.Azure}, "Standard_DS2_v2": {2, 7, costmodel.Azure},
+	"Standard_D2s_v3": {2, 8, costmodel.Azure}, "Standard_D4s_v3": {4, 16, costmodel.Azure}, "Standard_D8s_v3": {8, 32, costmodel.Azure},
+}
+
+// defaultNodeShape is assumed for instance types we don't know
+var defaultNodeShape = nodeShape{CPU: 2, MemoryGB: 8, Provider: costmodel.AWS}
+
+// nodePoolMonthlyCost prices a node pool at the reference rates of the
+// cloud selling its instance type
+func nodePoolMonthlyCost(instanceType string, nodes float64) float64 {
+	shape, ok := nodeShapes[instanceType]
+	if !ok {
+		shape = defaultNodeShape
+	}
+	pricing, err := costmodel.Lookup(shape.Provider, "")
+	if err != nil {
+		pricing = costmodel.Default
+	}
+	return nodes * pricing.MonthlyCost(costmodel.Resources{CPUCores: shape.CPU, MemoryGB: shape.MemoryGB, Replicas: 1})
+}
+
+// nodePoolTypes are the Terraform resources that change cluster capacity
+var nodePoolTypes = map[string]bool{
+	"aws_eks_node_group":                   true,
+	"google_container_node_pool":           true,
+	"azurerm_kubernetes_cluster_node_pool": true,
+	"azurerm_kubernetes_cluster":           true,
+}
+
+// nodePool extracts instance type and node count from a node pool resource's
+// attributes (nil before a create or after a delete)
+func nodePool(resourceType string, attrs map[string]interface{}) (instanceType string, nodes float64) {
+	if attrs == nil {
+		return "", 0
+	}
+
+	switch resourceType {
+	case "aws_eks_node_group":
+		instanceType = firstString(attrs["instance_types"], "t3.medium")
+		nodes = number(firstBlock(attrs["scaling_config"])["desired_size"])
+	case "google_container_node_pool":
+		instanceType = firstString(firstBlock(attrs["node_config"])["machine_type"], "e2-medium")
+		nodes = number(attrs["node_count"])
+		if nodes == 0 {
+			nodes = number(firstBlock(attrs["autoscaling"])["min_node_count"])
+		}
+		if nodes == 0 {
+			nodes = number(attrs["initial_node_count"])
+		}
+	case "azurerm_kubernetes_cluster_node_pool":
+		instanceType = firstString(attrs["vm_size"], "Standard_D2s_v3")
+		nodes = number(attrs["node_count"])
+	case "azurerm_kubernetes_cluster":
+		pool := firstBlock(attrs["default_node_pool"])
+		instanceType = firstString(pool["vm_size"], "Standard_D2s_v3")
+		nodes = number(pool["node_count"])
+	}
+
+	return instanceType, nodes
+}
+
+// AnalyzeTerraformPlan estimates the cost impact of node pool changes in a plan
+func (m *CostImpactMonitor) AnalyzeTerraformPlan(name, source, space string, planJSON []byte) (*TerraformPlanImpact, error) {
+	var plan terraformPlan
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("decode terraform plan: %w", err)
+	}
+
+	impact := &TerraformPlanImpact{
+		Name:       name,
+		Source:     source,
+		Space:      space,
+		AnalyzedAt: time.Now(),
+		Changes:    []PendingChange{},
+	}
+
+	for _, rc := range plan.ResourceChanges {
+		if !nodePoolTypes[rc.Type] {
+			continue
+		}
+		action := strings.Join(rc.Change.Actions, "/")
+		if action == "no-op" || action == "read" {
+			continue
+		}
+
+		change := PendingChange{
+			UnitID:       rc.Address,
+			UnitName:     rc.Address,
+			ChangeType:   planChangeType(rc.Change.Actions),
+			AnalysisTime: impact.AnalyzedAt,
+		}
+		if typ, nodes := nodePool(rc.Type, rc.Change.Before); nodes > 0 {
+			change.CurrentCost = nodePoolMonthlyCost(typ, nodes)
+		}
+		if typ, nodes := nodePool(rc.Type, rc.Change.After); nodes > 0 {
+			change.ProjectedCost = nodePoolMonthlyCost(typ, nodes)
+		}
+		change.CostDelta = change.ProjectedCost - change.CurrentCost
+		change.RiskLevel = m.assessRisk(change.CostDelta)
+
+		impact.Changes = append(impact.Changes, change)
+		impact.CostDelta += change.CostDelta
+	}
+
+	m.mu.Lock()
+	m.terraformPlans[name] = impact
+	m.mu.Unlock()
+
+	return impact, nil
+}
+
+// planChangeType maps Terraform actions to the monitor's change types
+func planChangeType(actions []string) string {
+	switch strings.Join(actions, "/") {
+	case "create":
+		return "create"
+	case "delete":
+		return "delete"
+	default:
+		return "update" // update, delete/create and create/delete replacements
+	}
+}
+
+// WatchTerraformPlans analyzes plan JSON files dropped into dir, re-analyzing
+// a file whenever it changes
+func (m *CostImpactMonitor) WatchTerraformPlans(dir string) {
+	seen := make(map[string]time.Time)
+
+	scan := func() {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return
+		}
+		for _, path := range files {
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().After(seen[path]) {
+				continue
+			}
+			seen[path] = info.ModTime()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				slog.Warn("Failed to read terraform plan", "path", path, logging.Err(err))
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(path), ".json")
+			impact, err := m.AnalyzeTerraformPlan(name, path, "", data)
+			if err != nil {
+				slog.Warn("Failed to analyze terraform plan", "path", path, logging.Err(err))
+				continue
+			}
+			slog.Info("Analyzed terraform plan", "plan", name,
+				"node_pool_changes", len(impact.Changes), "cost_delta", impact.CostDelta)
+		}
+	}
+
+	scan()
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		scan()
+	}
+}
+
+// handleTerraformPlans accepts plan uploads (POST) and lists analyzed plans (GET)
+func (d *MonitorDashboard) handleTerraformPlans(w http.ResponseWriter, r *http.Request) {
+	if !requireUnrestricted(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 16<<20))
+		if err != nil {
+			http.Error(w, "read plan: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = fmt.Sprintf("plan-%d", time.Now().Unix())
+		}
+
+		impact, err := d.monitor.AnalyzeTerraformPlan(name, "upload", r.URL.Query().Get("space"), body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.UpdateMonitoringData(d.monitor.getMonitoringSnapshot())
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(impact); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+	case http.MethodGet:
+		d.monitor.mu.RLock()
+		plans := make([]*TerraformPlanImpact, 0, len(d.monitor.terraformPlans))
+		for _, plan := range d.monitor.terraformPlans {
+			plans = append(plans, plan)
+		}
+		d.monitor.mu.RUnlock()
+
+		sort.Slice(plans, func(i, j int) bool {
+			return plans[i].AnalyzedAt.After(plans[j].AnalyzedAt)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(PlanList{Plans: plans, Total: len(plans)}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// firstBlock returns the first element of a Terraform nested block list
+func firstBlock(v interface{}) map[string]interface{} {
+	if list, ok := v.([]interface{}); ok && len(list) > 0 {
+		if block, ok := list[0].(map[string]interface{}); ok {
+			return block
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// firstString returns v as a string, or its first element if it is a list
+func firstString(v interface{}, fallback string) string {
+	switch s := v.(type) {
+	case string:
+		if s != "" {
+			return s
+		}
+	case []interface{}:
+		if len(s) > 0 {
+			if str, ok := s[0].(string); ok && str != "" {
+				return str
+			}
+		}
+	}
+	return fallback
+}
+
+// number converts a JSON number to float64
+func number(v interface{}) float64 {
+	if f, ok := v.(float64); ok {
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package costimpactmonitor
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const samplePlan = `{
+  "resource_changes": [
+    {
+      "address": "aws_eks_node_group.workers",
+      "type": "aws_eks_node_group",
+      "change": {
+        "actions": ["update"],
+        "before": {"instance_types": ["m5.large"], "scaling_config": [{"desired_size": 3}]},
+        "after":  {"instance_types": ["m5.large"], "scaling_config": [{"desired_size": 5}]}
+      }
+    },
+    {
+      "address": "google_container_node_pool.batch",
+      "type": "google_container_node_pool",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"node_count": 2, "node_config": [{"machine_type": "e2-standard-4"}]}
+      }
+    },
+    {
+      "address": "azurerm_kubernetes_cluster_node_pool.legacy",
+      "type": "azurerm_kubernetes_cluster_node_pool",
+      "change": {
+        "actions": ["delete"],
+        "before": {"node_count": 1, "vm_size": "Standard_D2s_v3"},
+        "after": null
+      }
+    },
+    {
+      "address": "aws_s3_bucket.logs",
+      "type": "aws_s3_bucket",
+      "change": {"actions": ["create"], "before": null, "after": {"bucket": "logs"}}
+    },
+    {
+      "address": "aws_eks_node_group.system",
+      "type": "aws_eks_node_group",
+      "change": {"actions": ["no-op"]}
+    }
+  ]
+}`
+
+func TestAnalyzeTerraformPlan(t *testing.T) {
+	m := &CostImpactMonitor{
+		monitoredSpaces: make(map[uuid.UUID]*SpaceMonitor),
+		terraformPlans:  make(map[string]*TerraformPlanImpact),
+	}
+
+	impact, err := m.AnalyzeTerraformPlan("infra", "upload", "prod", []byte(samplePlan))
+	if err != nil {
+		t.Fatalf("AnalyzeTerraformPlan: %v", err)
+	}
+
+	if len(impact.Changes) != 3 {
+		t.Fatalf("got %d changes, want 3 node pool changes", len(impact.Changes))
+	}
+
+	m5 := nodePoolMonthlyCost("m5.large", 1)
+	e2 := nodePoolMonthlyCost("e2-standard-4", 1)
+	d2 := nodePoolMonthlyCost("Standard_D2s_v3", 1)
+
+	want := map[string]struct {
+		changeType string
+		delta      float64
+	}{
+		"aws_eks_node_group.workers":                  {"update", 2 * m5},
+		"google_container_node_pool.batch":            {"create", 2 * e2},
+		"azurerm_kubernetes_cluster_node_pool.legacy": {"delete", -d2},
+	}
+
+	for _, change := range impact.Changes {
+		w, ok := want[change.UnitName]
+		if !ok {
+			t.Errorf("unexpected change %s", change.UnitName)
+			continue
+		}
+		if change.ChangeType != w.changeType {
+			t.Errorf("%s: change type = %s, want %s", change.UnitName, change.ChangeType, w.changeType)
+		}
+		if math.Abs(change.CostDelta-w.delta) > 0.01 {
+			t.Errorf("%s: cost delta = %.2f, want %.2f", change.UnitName, change.CostDelta, w.delta)
+		}
+	}
+
+	if _, stored := m.terraformPlans["infra"]; !stored {
+		t.Error("plan impact was not stored for the dashboard")
+	}
+}
+
+func TestAnalyzeTerraformPlanRejectsInvalidJSON(t *testing.T) {
+	m := &CostImpactMonitor{terraformPlans: make(map[string]*TerraformPlanImpact)}
+	if _, err := m.AnalyzeTerraformPlan("bad", "upload", "", []byte("not json")); err == nil {
+		t.Error("expected decode error")
+	}
+}
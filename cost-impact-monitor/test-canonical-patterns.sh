@@ -73,8 +73,8 @@ run_test "Units have upstream relationships" "cub unit list --space drift-test-d
 # Test 1.5: Verify NO kubectl commands (ConfigHub-driven)
 echo ""
 echo "1.5 Testing ConfigHub-Driven Deployment..."
-run_test "No direct kubectl in corrections" "curl -s http://localhost:8082/api/live | jq -r '.corrections[].command' | grep kubectl" "fail"
-run_test "Uses cub commands for corrections" "curl -s http://localhost:8082/api/live | jq -r '.corrections[].command' | grep 'cub unit'" "pass"
+run_test "No direct kubectl in corrections" "curl -s http://localhost:8082/api/v1/live | jq -r '.corrections[].command' | grep kubectl" "fail"
+run_test "Uses cub commands for corrections" "curl -s http://localhost:8082/api/v1/live | jq -r '.corrections[].command' | grep 'cub unit'" "pass"
 
 echo ""
 echo "2. GLOBAL-APP CANONICAL PATTERNS"
@@ -117,7 +117,7 @@ run_test "Uses sdk.DevOpsApp pattern" "grep 'sdk.DevOpsApp' *.go" "pass"
 echo ""
 echo "3.2 Testing Component Isolation..."
 run_test "Dashboard on separate port" "curl -s http://localhost:8082/ > /dev/null" "pass"
-run_test "API endpoint available" "curl -s http://localhost:8082/api/live | jq '.timestamp' > /dev/null" "pass"
+run_test "API endpoint available" "curl -s http://localhost:8082/api/v1/live | jq '.timestamp' > /dev/null" "pass"
 
 echo ""
 echo "4. ROBUSTNESS TESTS"
@@ -127,14 +127,14 @@ echo "==================="
 echo ""
 echo "4.1 Testing API Robustness..."
 for i in {1..5}; do
-    run_test "API request $i" "curl -s http://localhost:8082/api/live | jq '.total_monthly_cost' > /dev/null" "pass"
+    run_test "API request $i" "curl -s http://localhost:8082/api/v1/live | jq '.total_monthly_cost' > /dev/null" "pass"
     sleep 1
 done
 
 # Test 4.2: Drift detection accuracy
 echo ""
 echo "4.2 Testing Drift Detection..."
-DRIFT_COUNT=$(curl -s http://localhost:8082/api/live | jq '[.resources[] | select(.is_drifted == true)] | length')
+DRIFT_COUNT=$(curl -s http://localhost:8082/api/v1/live | jq '[.resources[] | select(.is_drifted == true)] | length')
 if [ "$DRIFT_COUNT" -gt 0 ]; then
     echo -e "${GREEN}PASS: Detected $DRIFT_COUNT drifted resources${NC}"
     ((TESTS_PASSED++))
@@ -145,9 +145,9 @@ fi
 # Test 4.3: Cost calculation consistency
 echo ""
 echo "4.3 Testing Cost Calculations..."
-COST1=$(curl -s http://localhost:8082/api/live | jq '.total_monthly_cost')
+COST1=$(curl -s http://localhost:8082/api/v1/live | jq '.total_monthly_cost')
 sleep 2
-COST2=$(curl -s http://localhost:8082/api/live | jq '.total_monthly_cost')
+COST2=$(curl -s http://localhost:8082/api/v1/live | jq '.total_monthly_cost')
 if [ "$COST1" = "$COST2" ]; then
     echo -e "${GREEN}PASS: Cost calculations are consistent${NC}"
     ((TESTS_PASSED++))
@@ -158,14 +158,14 @@ fi
 # Test 4.4: ConfigHub connection
 echo ""
 echo "4.4 Testing ConfigHub Connection..."
-run_test "ConfigHub connected" "curl -s http://localhost:8082/api/live | jq -r '.confighub_info.connected'" "pass"
-run_test "ConfigHub units listed" "curl -s http://localhost:8082/api/live | jq '.confighub_info.units | length > 0'" "pass"
+run_test "ConfigHub connected" "curl -s http://localhost:8082/api/v1/live | jq -r '.confighub_info.connected'" "pass"
+run_test "ConfigHub units listed" "curl -s http://localhost:8082/api/v1/live | jq '.confighub_info.units | length > 0'" "pass"
 
 # Test 4.5: Kubernetes connection
 echo ""
 echo "4.5 Testing Kubernetes Connection..."
-run_test "Cluster context available" "curl -s http://localhost:8082/api/live | jq -r '.cluster_info.context' | grep -E 'kind|eks|gke'" "pass"
-run_test "Resources being monitored" "curl -s http://localhost:8082/api/live | jq '.resources | length > 0'" "pass"
+run_test "Cluster context available" "curl -s http://localhost:8082/api/v1/live | jq -r '.cluster_info.context' | grep -E 'kind|eks|gke'" "pass"
+run_test "Resources being monitored" "curl -s http://localhost:8082/api/v1/live | jq '.resources | length > 0'" "pass"
 
 echo ""
 echo "5. DEVOPS-AS-APPS PHILOSOPHY"
@@ -28,7 +28,7 @@ test_api() {
 
     echo -n "Testing: $test_name ... "
 
-    result=$(curl -s http://localhost:8082/api/live | jq -r "$jq_query" 2>/dev/null || echo "ERROR")
+    result=$(curl -s http://localhost:8082/api/v1/live | jq -r "$jq_query" 2>/dev/null || echo "ERROR")
 
     if [[ "$result" == "$expected" ]] || [[ "$result" =~ $expected ]]; then
         echo -e "${GREEN}PASS${NC}"
@@ -100,7 +100,7 @@ echo ""
 echo -n "Rapid requests (10x): "
 failed=0
 for i in {1..10}; do
-    if curl -s http://localhost:8082/api/live > /dev/null 2>&1; then
+    if curl -s http://localhost:8082/api/v1/live > /dev/null 2>&1; then
         echo -n "."
     else
         echo -n "X"
@@ -173,13 +173,13 @@ echo "KEY FINDINGS:"
 echo "============="
 
 # Check adherence to patterns
-if curl -s http://localhost:8082/api/live | jq -r '.corrections[0].command' | grep -q "cub unit"; then
+if curl -s http://localhost:8082/api/v1/live | jq -r '.corrections[0].command' | grep -q "cub unit"; then
     echo -e "${GREEN}✓ Adheres to ConfigHub patterns (uses cub commands)${NC}"
 else
     echo -e "${RED}✗ Not using ConfigHub patterns${NC}"
 fi
 
-if curl -s http://localhost:8082/api/live | jq '.confighub_info.connected' | grep -q "true"; then
+if curl -s http://localhost:8082/api/v1/live | jq '.confighub_info.connected' | grep -q "true"; then
     echo -e "${GREEN}✓ ConfigHub integration working${NC}"
 else
     echo -e "${RED}✗ ConfigHub not connected${NC}"
@@ -0,0 +1,43 @@
+package costimpactmonitor
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestChangeDetectorAdvance(t *testing.T) {
+	c := &ChangeDetector{
+		revisionCache: make(map[string]int),
+		statusCache:   make(map[string]string),
+	}
+	unit := &sdk.Unit{UnitID: uuid.New(), HeadRevisionNum: 4, LiveState: &sdk.LiveState{Status: "Pending"}}
+
+	steps := []struct {
+		name     string
+		revision int64
+		status   string
+		want     bool
+	}{
+		{"first sighting", 4, "Pending", true},
+		{"unchanged", 4, "Pending", false},
+		{"new revision", 5, "Pending", true},
+		{"rapid successive revisions", 7, "Pending", true},
+		{"applied without new revision", 7, "Applied", true},
+		{"still applied", 7, "Applied", false},
+		{"stale read of older revision", 6, "Applied", false},
+	}
+
+	for _, step := range steps {
+		unit.HeadRevisionNum = step.revision
+		unit.LiveState.Status = step.status
+		if got := c.advance(unit); got != step.want {
+			t.Errorf("%s: advance() = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	if got := c.revisionCache[unit.UnitID.String()]; got != 7 {
+		t.Errorf("cached revision = %d, want 7", got)
+	}
+}
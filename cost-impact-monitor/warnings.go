@@ -0,0 +1,128 @@
+package costimpactmonitor
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// costWarningType is the type label of units created by createCostWarning
+const costWarningType = "cost-warning"
+
+// warningSlug names the warning for one revision of a unit, so repeated
+// detections of the same change map to the same ConfigHub unit
+func warningSlug(unit *sdk.Unit) string {
+	return fmt.Sprintf("cost-warning-%s-r%d", unit.Slug, unit.HeadRevisionNum)
+}
+
+// shouldWarn reports whether no warning has been raised yet for the unit's
+// current revision, and records that one is being raised
+func (m *CostImpactMonitor) shouldWarn(unit *sdk.Unit) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := unit.UnitID.String()
+	if revision, ok := m.warnedRevisions[key]; ok && revision >= unit.HeadRevisionNum {
+		return false
+	}
+	m.warnedRevisions[key] = unit.HeadRevisionNum
+	return true
+}
+
+// staleWarning is a cost-warning unit due for pruning
+type staleWarning struct {
+	unit   *sdk.Unit
+	reason string
+}
+
+// warningRevision returns the unit revision a warning was raised for, or 0 for
+// warnings created before warnings were tied to revisions
+func warningRevision(warning *sdk.Unit) int64 {
+	revision, _ := strconv.ParseInt(warning.Labels["revision"], 10, 64)
+	return revision
+}
+
+// staleWarnings returns the cost-warning units among a space's units whose
+// change has been superseded or deleted, or which are older than retention
+func staleWarnings(units []*sdk.Unit, now time.Time, retention time.Duration) []staleWarning {
+	bySlug := make(map[string]*sdk.Unit, len(units))
+	for _, unit := range units {
+		if unit.Labels["type"] != costWarningType {
+			bySlug[unit.Slug] = unit
+		}
+	}
+
+	var stale []staleWarning
+	for _, warning := range units {
+		if warning.Labels["type"] != costWarningType {
+			continue
+		}
+
+		if !warning.CreatedAt.IsZero() && now.Sub(warning.CreatedAt) > retention {
+			stale = append(stale, staleWarning{warning, "expired"})
+			continue
+		}
+
+		source, exists := bySlug[warning.Labels["unit"]]
+		if !exists {
+			stale = append(stale, staleWarning{warning, "unit deleted"})
+			continue
+		}
+		if revision := warningRevision(warning); revision > 0 && source.HeadRevisionNum > revision {
+			stale = append(stale, staleWarning{warning,
+				fmt.Sprintf("superseded by revision %d", source.HeadRevisionNum)})
+		}
+	}
+	return stale
+}
+
+// pruneCostWarnings deletes a space's stale cost-warning units and remembers
+// the revisions the remaining ones cover, so a restart doesn't warn twice
+func (m *CostImpactMonitor) pruneCostWarnings(space *SpaceMonitor, units []*sdk.Unit, now time.Time) {
+	m.mu.Lock()
+	for _, unit := range units {
+		if unit.Labels["type"] != costWarningType {
+			continue
+		}
+		key := unit.Labels["unit_id"]
+		if revision := warningRevision(unit); key != "" && revision > m.warnedRevisions[key] {
+			m.warnedRevisions[key] = revision
+		}
+	}
+	m.mu.Unlock()
+
+	// Only the leader writes to ConfigHub
+	if m.app.Cub == nil || !m.leader.IsLeader() {
+		return
+	}
+
+	for _, stale := range staleWarnings(units, now, m.warningRetention) {
+		if err := deleteUnit(space.SpaceName, stale.unit.Slug); err != nil {
+			slog.Warn("Failed to prune cost warning", logging.Space(space.SpaceName), logging.Unit(stale.unit.Slug), logging.Err(err))
+			continue
+		}
+		slog.Info("Pruned cost warning", logging.Space(space.SpaceName), logging.Unit(stale.unit.Slug), "reason", stale.reason)
+	}
+}
+
+// forgetWarnings drops the warning bookkeeping of a deleted unit
+func (m *CostImpactMonitor) forgetWarnings(unitID string) {
+	m.mu.Lock()
+	delete(m.warnedRevisions, unitID)
+	m.mu.Unlock()
+}
+
+// deleteUnit removes a unit from ConfigHub with the cub CLI
+func deleteUnit(space, slug string) error {
+	cmd := exec.Command("cub", "unit", "delete", "--space", space, slug)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cub unit delete: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package costimpactmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestShouldWarnOncePerRevision(t *testing.T) {
+	m := &CostImpactMonitor{warnedRevisions: make(map[string]int64)}
+	unit := &sdk.Unit{UnitID: uuid.New(), Slug: "backend", HeadRevisionNum: 4}
+
+	if !m.shouldWarn(unit) {
+		t.Fatal("first detection should warn")
+	}
+	if m.shouldWarn(unit) {
+		t.Error("same revision warned twice")
+	}
+
+	unit.HeadRevisionNum = 5
+	if !m.shouldWarn(unit) {
+		t.Error("new revision should warn")
+	}
+	if got := warningSlug(unit); got != "cost-warning-backend-r5" {
+		t.Errorf("warningSlug = %q", got)
+	}
+
+	m.forgetWarnings(unit.UnitID.String())
+	if !m.shouldWarn(unit) {
+		t.Error("forgotten unit should warn again")
+	}
+}
+
+func TestStaleWarnings(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	warning := func(slug, unit, revision string, age time.Duration) *sdk.Unit {
+		return &sdk.Unit{
+			Slug:      slug,
+			Labels:    map[string]string{"type": costWarningType, "unit": unit, "revision": revision},
+			CreatedAt: now.Add(-age),
+		}
+	}
+
+	units := []*sdk.Unit{
+		{Slug: "backend", HeadRevisionNum: 3, Labels: map[string]string{}},
+		{Slug: "worker", HeadRevisionNum: 7, Labels: map[string]string{}},
+		warning("cost-warning-backend-r3", "backend", "3", time.Hour),
+		warning("cost-warning-backend-r2", "backend", "2", time.Hour),
+		warning("cost-warning-worker-r7", "worker", "7", 8*24*time.Hour),
+		warning("cost-warning-cache-r1", "cache", "1", time.Hour),
+		warning("cost-warning-worker-1700000000", "worker", "", time.Hour),
+	}
+
+	got := make(map[string]string)
+	for _, stale := range staleWarnings(units, now, 7*24*time.Hour) {
+		got[stale.unit.Slug] = stale.reason
+	}
+
+	want := map[string]string{
+		"cost-warning-backend-r2": "superseded by revision 3",
+		"cost-warning-worker-r7":  "expired",
+		"cost-warning-cache-r1":   "unit deleted",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("stale = %v, want %v", got, want)
+	}
+	for slug, reason := range want {
+		if got[slug] != reason {
+			t.Errorf("%s: reason = %q, want %q", slug, got[slug], reason)
+		}
+	}
+}
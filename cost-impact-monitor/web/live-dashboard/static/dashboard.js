@@ -0,0 +1,236 @@
+function updateDashboard() {
+    fetch('/api/v1/live')
+        .then(r => r.json())
+        .then(data => {
+            // Update timestamps
+            document.getElementById('timestamp').textContent = data.timestamp;
+            document.getElementById('last-update').textContent = new Date().toLocaleTimeString();
+
+            // Update cluster info
+            document.getElementById('cluster-context').textContent = data.cluster_info.context || '-';
+            document.getElementById('cluster-name').textContent = data.cluster_info.cluster || 'kind';
+            document.getElementById('namespace').textContent = data.cluster_info.namespace || '-';
+            document.getElementById('api-server').textContent = data.cluster_info.api_server || '-';
+
+            // Update ConfigHub info
+            document.getElementById('cub-connected').textContent = data.confighub_info.connected ? 'Yes' : 'No';
+            document.getElementById('cub-spaces').textContent = data.confighub_info.spaces ? data.confighub_info.spaces.join(', ') : '-';
+            document.getElementById('cub-units').textContent = data.confighub_info.units ? data.confighub_info.units.length + ' units' : '-';
+
+            // Update metrics
+            document.getElementById('total-cost').textContent = '$' + data.total_monthly_cost.toFixed(2);
+            document.getElementById('drift-cost').textContent = (data.drift_cost >= 0 ? '+' : '') + '$' + Math.abs(data.drift_cost).toFixed(2);
+            document.getElementById('savings').textContent = '$' + data.potential_savings.toFixed(2);
+            document.getElementById('resources-count').textContent = data.resources ? data.resources.length : 0;
+
+            // Update resources table
+            const tbody = document.getElementById('resources-table');
+            tbody.innerHTML = '';
+
+            if (data.resources) {
+                data.resources.forEach(r => {
+                    const row = tbody.insertRow();
+                    row.className = r.is_drifted ? 'drifted' : '';
+                    // Show usage against requests when metrics-server is available
+                    const cpu = data.metrics_available
+                        ? r.cpu_used.toFixed(3) + ' / ' + r.cpu_requested.toFixed(2) + ' cores'
+                        : r.cpu_requested.toFixed(2) + ' cores requested';
+                    const mem = data.metrics_available
+                        ? r.memory_used.toFixed(3) + ' / ' + r.memory_requested.toFixed(2) + ' GB'
+                        : r.memory_requested.toFixed(2) + ' GB requested';
+                    row.innerHTML =
+                        '<td>' + r.name + '</td>' +
+                        '<td>' + r.type + '</td>' +
+                        '<td>' + (r.expected_replicas || '-') + '</td>' +
+                        '<td>' + r.replicas + '</td>' +
+                        '<td>' + cpu + '</td>' +
+                        '<td>' + mem + '</td>' +
+                        '<td>$' + r.monthly_cost.toFixed(2) + '</td>' +
+                        '<td>' + (r.is_drifted ? '[!] DRIFTED' : '[OK]') + '</td>';
+                });
+            }
+
+            // Update corrections
+            const correctionsList = document.getElementById('corrections-list');
+            correctionsList.innerHTML = '';
+            if (data.corrections && data.corrections.length > 0) {
+                data.corrections.forEach(c => {
+                    correctionsList.innerHTML +=
+                        '<div class="correction">' +
+                        '<strong>' + c.resource + '</strong>: ' + c.issue + '<br>' +
+                        '<code>' + c.command + '</code><br>' +
+                        '<span style="color: #10b981;">' + c.impact + '</span>' +
+                        (data.corrections_applicable
+                            ? ' <button onclick="applyCorrection(\'' + c.resource + '\')">Apply</button>'
+                            : '') +
+                        '</div>';
+                });
+            } else {
+                correctionsList.innerHTML = '<div style="color: #10b981;">All resources aligned with ConfigHub</div>';
+            }
+
+            // Update optimizations
+            const optimizationsList = document.getElementById('optimizations-list');
+            optimizationsList.innerHTML = '';
+            if (data.optimizations && data.optimizations.length > 0) {
+                data.optimizations.forEach(o => {
+                    optimizationsList.innerHTML +=
+                        '<div class="optimization">' +
+                        o.description + ': <strong>Save $' + o.savings.toFixed(2) + '/month</strong> (Risk: ' + o.risk + ')' +
+                        '</div>';
+                });
+            } else {
+                optimizationsList.innerHTML = '<div>No additional optimizations available</div>';
+            }
+
+            // Update Claude info
+            document.getElementById('claude-enabled').textContent = data.claude_analysis.enabled ? 'Enabled' : 'Disabled';
+            document.getElementById('claude-status').textContent = !data.claude_analysis.enabled ? 'Inactive' :
+                (data.claude_analysis.error ? 'Error: ' + data.claude_analysis.error : 'Active');
+            document.getElementById('claude-last-run').textContent = data.claude_analysis.last_run || '-';
+            document.getElementById('claude-summary').textContent = data.claude_analysis.summary || 'No analysis available';
+        })
+        .catch(err => {
+            console.error('Dashboard update error:', err);
+        });
+}
+
+function applyCorrection(resource) {
+    if (!confirm('Patch and apply the ConfigHub unit for ' + resource + '?')) {
+        return;
+    }
+
+    fetch('/api/v1/corrections/' + encodeURIComponent(resource) + '/apply', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({confirm: resource})
+    })
+        .then(response => response.ok
+            ? response.json().then(result => alert('Applied ' + result.unit + ' (' + result.replicas + ' replicas)'))
+            : response.text().then(text => alert('Correction failed: ' + text)))
+        .then(updateDashboard)
+        .catch(err => alert('Correction failed: ' + err));
+}
+
+function runHealthCheck() {
+    const btn = event.target;
+    btn.disabled = true;
+    btn.textContent = 'Running...';
+
+    fetch('/api/v1/health')
+        .then(response => response.json())
+        .then(data => {
+            // Display health check results
+            const modal = document.createElement('div');
+            modal.style.cssText = 'position:fixed;top:50%;left:50%;transform:translate(-50%,-50%);background:white;border:2px solid #333;padding:20px;z-index:1000;max-width:80%;max-height:80%;overflow:auto;';
+
+            let checksHtml = '<h2>Health Check Results</h2>';
+            checksHtml += '<p>Timestamp: ' + data.timestamp + '</p>';
+            checksHtml += '<p>Health Score: <strong>' + data.health_score + '/100</strong></p>';
+            checksHtml += '<p>Status: <strong style="color:' + (data.status === 'HEALTHY' ? '#10b981' : data.status === 'DEGRADED' ? '#f59e0b' : '#ef4444') + '">' + data.status + '</strong></p>';
+            checksHtml += '<p>' + data.status_text + '</p>';
+
+            checksHtml += '<h3>Checks:</h3>';
+            checksHtml += '<table border="1" style="width:100%;border-collapse:collapse;">';
+            checksHtml += '<tr><th>Component</th><th>Check</th><th>Status</th><th>Details</th></tr>';
+            data.checks.forEach(check => {
+                const color = check.status === 'HEALTHY' ? '#10b981' : check.status === 'DEGRADED' ? '#f59e0b' : '#ef4444';
+                checksHtml += '<tr>';
+                checksHtml += '<td>' + check.component + '</td>';
+                checksHtml += '<td>' + check.check + '</td>';
+                checksHtml += '<td style="color:' + color + '">' + check.status + '</td>';
+                checksHtml += '<td>' + check.details + '</td>';
+                checksHtml += '</tr>';
+            });
+            checksHtml += '</table>';
+
+            if (data.issues && data.issues.length > 0) {
+                checksHtml += '<h3>Issues Found:</h3>';
+                checksHtml += '<ul>';
+                data.issues.forEach(issue => {
+                    checksHtml += '<li>' + issue + '</li>';
+                });
+                checksHtml += '</ul>';
+            }
+
+            if (data.quick_actions && data.quick_actions.length > 0) {
+                checksHtml += '<h3>Quick Actions:</h3>';
+                checksHtml += '<ul>';
+                data.quick_actions.forEach(action => {
+                    checksHtml += '<li>' + action + '</li>';
+                });
+                checksHtml += '</ul>';
+            }
+
+            checksHtml += '<br><button onclick="this.parentElement.remove()">Close</button>';
+            modal.innerHTML = checksHtml;
+            document.body.appendChild(modal);
+
+            btn.disabled = false;
+            btn.textContent = 'Run Health Check';
+        })
+        .catch(err => {
+            console.error('Health check error:', err);
+            alert('Health check failed: ' + err);
+            btn.disabled = false;
+            btn.textContent = 'Run Health Check';
+        });
+}
+
+let historyRange = '24h';
+
+function loadHistory(range) {
+    historyRange = range || historyRange;
+    fetch('/api/v1/history?range=' + historyRange)
+        .then(response => response.json())
+        .then(data => drawHistory(data.points || []))
+        .catch(err => console.error('History update error:', err));
+}
+
+function drawHistory(points) {
+    const svg = document.getElementById('history-chart');
+    const summary = document.getElementById('history-summary');
+    if (points.length === 0) {
+        svg.innerHTML = '';
+        summary.textContent = 'No history recorded yet';
+        return;
+    }
+
+    const max = Math.max(1, ...points.map(p => Math.max(p.total_monthly_cost, Math.abs(p.drift_cost))));
+    const x = i => points.length === 1 ? 500 : i * 1000 / (points.length - 1);
+    const y = v => 195 - Math.max(0, v) / max * 185;
+    const line = (key, color) => '<polyline fill="none" stroke="' + color + '" stroke-width="2" points="' +
+        points.map((p, i) => x(i) + ',' + y(p[key])).join(' ') + '"/>';
+    svg.innerHTML = line('total_monthly_cost', '#3b82f6') + line('drift_cost', '#ef4444');
+
+    // Compare the latest drift cost with the start of the range
+    const first = points[0].drift_cost, last = points[points.length - 1].drift_cost;
+    const trend = last < first ? 'improving' : last > first ? 'getting worse' : 'unchanged';
+    summary.textContent = 'Drift cost $' + first.toFixed(2) + ' → $' + last.toFixed(2) +
+        ' (' + trend + ') since ' + new Date(points[0].time).toLocaleString() + ', peak total $' + max.toFixed(2);
+}
+
+// Claude prompts and responses arrive as they happen, newest first
+function appendClaudeLog(call) {
+    const logs = document.getElementById('claude-logs');
+    const empty = document.getElementById('claude-logs-empty');
+    if (empty) empty.remove();
+
+    const entry = document.createElement('div');
+    entry.className = 'claude-log';
+    const time = document.createElement('div');
+    time.textContent = new Date(call.timestamp).toLocaleString();
+    const prompt = document.createElement('pre');
+    prompt.textContent = '▶ ' + call.prompt;
+    const response = document.createElement('pre');
+    response.textContent = '◀ ' + call.response;
+    entry.append(time, prompt, response);
+    logs.prepend(entry);
+}
+
+new EventSource('/api/v1/claude/stream').onmessage = e => appendClaudeLog(JSON.parse(e.data));
+
+updateDashboard();
+setInterval(updateDashboard, (Number(document.body.dataset.refreshSeconds) || 5) * 1000);
+loadHistory();
+setInterval(loadHistory, 60000);
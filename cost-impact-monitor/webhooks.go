@@ -0,0 +1,188 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/webhook"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// maxWebhookBody caps how much of a webhook payload we are willing to read
+const maxWebhookBody = 1 << 20
+
+// WebhookReceiver accepts "about to deploy" events pushed by external systems
+// (ConfigHub, CI pipelines, Helm hooks) and runs impact analysis on demand
+type WebhookReceiver struct {
+	monitor *CostImpactMonitor
+	keys    webhook.Keys // any of them verifies, to rotate the secret
+}
+
+// ConfigHubWebhookEvent is the payload sent by ConfigHub triggers
+type ConfigHubWebhookEvent struct {
+	Event   string    `json:"event"` // e.g. "unit.pre-apply"
+	SpaceID uuid.UUID `json:"space_id"`
+	UnitID  uuid.UUID `json:"unit_id"`
+}
+
+// GenericDeployEvent is a loosely structured payload for CI systems and Helm hooks
+type GenericDeployEvent struct {
+	Source string            `json:"source"` // "ci", "helm", "argo", ...
+	Space  string            `json:"space"`  // space slug or ID
+	Unit   string            `json:"unit"`   // unit slug
+	Labels map[string]string `json:"labels"` // resource hints when the unit is not in ConfigHub yet
+}
+
+// NewWebhookReceiver creates a receiver that verifies payloads with the shared
+// secret, or with any of its comma separated keys while it is rotated
+func NewWebhookReceiver(monitor *CostImpactMonitor, secret string) *WebhookReceiver {
+	return &WebhookReceiver{
+		monitor: monitor,
+		keys:    webhook.ParseKeys(secret),
+	}
+}
+
+// verifySignature checks the signature header against the HMAC of body
+func (wr *WebhookReceiver) verifySignature(body []byte, header string) bool {
+	return wr.keys.Verify(body, header)
+}
+
+// readVerified reads the request body and rejects unsigned or tampered payloads.
+// It writes the error response itself and returns nil when the request is refused.
+func (wr *WebhookReceiver) readVerified(w http.ResponseWriter, r *http.Request) []byte {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if len(wr.keys) == 0 {
+		http.Error(w, "webhook secret not configured", http.StatusServiceUnavailable)
+		return nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	if !wr.verifySignature(body, r.Header.Get(webhook.SignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return nil
+	}
+
+	return body
+}
+
+// handleConfigHub processes events sent by ConfigHub triggers
+func (wr *WebhookReceiver) handleConfigHub(w http.ResponseWriter, r *http.Request) {
+	body := wr.readVerified(w, r)
+	if body == nil {
+		return
+	}
+
+	var event ConfigHubWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "decode event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unit, err := wr.findUnit(event.SpaceID, func(u *sdk.Unit) bool { return u.UnitID == event.UnitID })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Received webhook", "source", "confighub", "event", event.Event, logging.Unit(unit.Slug))
+	wr.respond(w, "confighub", unit)
+}
+
+// handleGeneric processes events from CI pipelines, Helm hooks and other tools
+func (wr *WebhookReceiver) handleGeneric(w http.ResponseWriter, r *http.Request) {
+	body := wr.readVerified(w, r)
+	if body == nil {
+		return
+	}
+
+	var event GenericDeployEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "decode event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event.Unit == "" {
+		http.Error(w, "unit is required", http.StatusBadRequest)
+		return
+	}
+
+	source := event.Source
+	if source == "" {
+		source = "generic"
+	}
+
+	space, ok := wr.monitor.findSpace(event.Space)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown space %q", event.Space), http.StatusNotFound)
+		return
+	}
+	spaceID := space.SpaceID
+
+	unit, err := wr.findUnit(spaceID, func(u *sdk.Unit) bool { return u.Slug == event.Unit })
+	if err != nil {
+		// The unit may not exist in ConfigHub yet (e.g. a first Helm install),
+		// so analyze the resource hints we were given instead
+		unit = &sdk.Unit{
+			SpaceID: spaceID,
+			Slug:    event.Unit,
+			Labels:  event.Labels,
+		}
+	}
+
+	slog.Info("Received webhook", "source", source, logging.Unit(unit.Slug))
+	wr.respond(w, source, unit)
+}
+
+// respond runs the pre-apply analysis and returns the predicted impact
+func (wr *WebhookReceiver) respond(w http.ResponseWriter, source string, unit *sdk.Unit) {
+	impact := wr.monitor.triggerProcessor.runPreApplyHooks(unit)
+
+	response := map[string]interface{}{
+		"source":         source,
+		"unit":           unit.Slug,
+		"impact":         impact,
+		"hooks_executed": wr.monitor.leader.IsLeader(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// findUnit looks up the first unit in a space that satisfies match
+func (wr *WebhookReceiver) findUnit(spaceID uuid.UUID, match func(*sdk.Unit) bool) (*sdk.Unit, error) {
+	cub := wr.monitor.cubFor(spaceID)
+	if cub == nil {
+		return nil, fmt.Errorf("ConfigHub not configured")
+	}
+
+	units, err := ratelimit.Call(context.Background(), wr.monitor.cubLimit, "ListUnits", spaceID.String(), func() ([]*sdk.Unit, error) {
+		return cub.ListUnits(spaceID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list units: %w", err)
+	}
+
+	for _, unit := range units {
+		if match(unit) {
+			return unit, nil
+		}
+	}
+	return nil, fmt.Errorf("unit not found in space %s", spaceID)
+}
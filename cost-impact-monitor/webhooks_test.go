@@ -0,0 +1,82 @@
+package costimpactmonitor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/webhook"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	wr := NewWebhookReceiver(nil, "s3cret")
+	body := []byte(`{"unit":"backend"}`)
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"valid", sign("s3cret", string(body)), true},
+		{"wrong secret", sign("other", string(body)), false},
+		{"sender rotating", sign("next", string(body)) + ", " + sign("s3cret", string(body)), true},
+		{"missing prefix", strings.TrimPrefix(sign("s3cret", string(body)), "sha256="), false},
+		{"not hex", "sha256=zz", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wr.verifySignature(body, tt.header); got != tt.want {
+				t.Errorf("verifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// While the secret is rotated, senders on either key are accepted
+	rotating := NewWebhookReceiver(nil, "next,s3cret")
+	if !rotating.verifySignature(body, sign("s3cret", string(body))) || !rotating.verifySignature(body, sign("next", string(body))) {
+		t.Error("a rotating receiver rejected one of its keys")
+	}
+}
+
+func TestWebhookRejectsUnverifiedRequests(t *testing.T) {
+	body := `{"unit":"backend"}`
+
+	tests := []struct {
+		name   string
+		secret string
+		method string
+		header string
+		want   int
+	}{
+		{"wrong method", "s3cret", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"no secret configured", "", http.MethodPost, sign("", body), http.StatusServiceUnavailable},
+		{"bad signature", "s3cret", http.MethodPost, sign("other", body), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wr := NewWebhookReceiver(nil, tt.secret)
+			req := httptest.NewRequest(tt.method, "/webhooks/generic", strings.NewReader(body))
+			req.Header.Set(webhook.SignatureHeader, tt.header)
+			rec := httptest.NewRecorder()
+
+			wr.handleGeneric(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
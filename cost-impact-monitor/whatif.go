@@ -0,0 +1,182 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// errWhatIfNotFound marks what-if requests naming an unknown space or unit
+var errWhatIfNotFound = errors.New("not found")
+
+// WhatIfRequest describes a hypothetical change to an existing unit, or a new
+// unit when Unit is empty. Labels override the unit's labels; an empty value
+// removes the label.
+type WhatIfRequest struct {
+	Space    string            `json:"space"`
+	Unit     string            `json:"unit,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Replicas *int              `json:"replicas,omitempty"`
+	GPUs     *int              `json:"gpus,omitempty"`
+}
+
+// WhatIfResult is the projected impact of a hypothetical change
+type WhatIfResult struct {
+	Space              string         `json:"space"`
+	Unit               string         `json:"unit"`
+	ChangeType         string         `json:"change_type"` // "create" or "update"
+	CurrentCost        float64        `json:"current_cost"`
+	ProjectedCost      float64        `json:"projected_cost"`
+	CostDelta          float64        `json:"cost_delta"`
+	SpaceProjectedCost float64        `json:"space_projected_cost"`
+	RiskAssessment     RiskAssessment `json:"risk_assessment"`
+	ClaudeAssessment   string         `json:"claude_assessment,omitempty"`
+}
+
+// hypotheticalUnit applies the requested overrides to a copy of unit
+func (req WhatIfRequest) hypotheticalUnit(unit *sdk.Unit) *sdk.Unit {
+	changed := *unit
+	changed.Labels = make(map[string]string, len(unit.Labels)+len(req.Labels)+2)
+	for k, v := range unit.Labels {
+		changed.Labels[k] = v
+	}
+	for k, v := range req.Labels {
+		if v == "" {
+			delete(changed.Labels, k)
+		} else {
+			changed.Labels[k] = v
+		}
+	}
+	if req.Replicas != nil {
+		changed.Labels["replicas"] = strconv.Itoa(*req.Replicas)
+	}
+	if req.GPUs != nil {
+		changed.Labels["gpu"] = strconv.Itoa(*req.GPUs)
+	}
+	return &changed
+}
+
+// WhatIf projects the cost and risk of a hypothetical change. Nothing is
+// written to ConfigHub and no hooks or escalations are triggered.
+func (m *CostImpactMonitor) WhatIf(ctx context.Context, req WhatIfRequest) (*WhatIfResult, error) {
+	space, ok := m.findSpace(req.Space)
+	if !ok {
+		return nil, fmt.Errorf("space %q %w", req.Space, errWhatIfNotFound)
+	}
+
+	result := &WhatIfResult{Space: space.SpaceName, Unit: req.Unit, ChangeType: "create"}
+	current := &sdk.Unit{SpaceID: space.SpaceID, Slug: req.Unit, Labels: map[string]string{}}
+
+	if req.Unit != "" && m.cubFor(space.SpaceID) != nil {
+		units, err := m.listUnits(ctx, space.SpaceID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list units: %w", err)
+		}
+		found := false
+		for _, unit := range units {
+			if unit.Slug == req.Unit || unit.UnitID.String() == req.Unit {
+				current, found = unit, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unit %q in space %s %w", req.Unit, space.SpaceName, errWhatIfNotFound)
+		}
+		result.Unit = current.Slug
+		result.ChangeType = "update"
+		result.CurrentCost = m.calculateUnitCost(current)
+	}
+	if result.Unit == "" {
+		result.Unit = "new-unit"
+	}
+
+	changed := req.hypotheticalUnit(current)
+	result.ProjectedCost = m.calculateUnitCost(changed)
+	result.CostDelta = result.ProjectedCost - result.CurrentCost
+
+	m.mu.RLock()
+	result.SpaceProjectedCost = space.ProjectedCost + result.CostDelta
+	m.mu.RUnlock()
+
+	result.RiskAssessment = m.triggerProcessor.assessRisk(changed, result.CostDelta)
+	m.escalations.Preview(changed, &result.RiskAssessment)
+	m.applyPolicy(changed, &result.RiskAssessment, result.CostDelta, true)
+
+	if m.claude != nil {
+		result.ClaudeAssessment = m.getWhatIfAssessment(ctx, current, changed, result)
+	}
+
+	return result, nil
+}
+
+// getWhatIfAssessment asks Claude about a hypothetical change
+func (m *CostImpactMonitor) getWhatIfAssessment(ctx context.Context, current, changed *sdk.Unit, result *WhatIfResult) string {
+	prompt, err := m.prompts.Render(whatIfAssessmentPrompt, whatIfPromptData{Current: current, Changed: changed, Result: result})
+	if err != nil {
+		slog.Warn("Claude what-if assessment failed", logging.Unit(result.Unit), logging.Err(err))
+		return "AI assessment unavailable"
+	}
+
+	response, err := tracing.Call(ctx, "claude.Complete", func() (string, error) {
+		return m.claude.Complete(prompt)
+	}, tracing.UnitKey.String(result.Unit))
+	if errors.Is(err, breaker.ErrOpen) {
+		return "AI assessment unavailable (Claude circuit open)"
+	}
+	if err != nil {
+		slog.Warn("Claude what-if assessment failed", logging.Unit(result.Unit), logging.Err(err))
+		return "AI assessment unavailable"
+	}
+
+	return response
+}
+
+// handleWhatIf projects a hypothetical change (POST /api/v1/whatif)
+func (d *MonitorDashboard) handleWhatIf(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req WhatIfRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "decode what-if request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Space == "" {
+		http.Error(w, "space is required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := d.findVisibleSpace(r, req.Space); !ok {
+		http.Error(w, fmt.Sprintf("space %q not found", req.Space), http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := d.monitor.WhatIf(ctx, req)
+	if errors.Is(err, errWhatIfNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
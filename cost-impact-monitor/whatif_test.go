@@ -0,0 +1,128 @@
+package costimpactmonitor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/prompts"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestWhatIfHypotheticalUnit(t *testing.T) {
+	replicas, gpus := 10, 1
+	req := WhatIfRequest{
+		Labels:   map[string]string{"memory": "", "env": "production"},
+		Replicas: &replicas,
+		GPUs:     &gpus,
+	}
+	unit := &sdk.Unit{Slug: "api", Labels: map[string]string{"cpu": "1", "memory": "2Gi", "replicas": "3"}}
+
+	changed := req.hypotheticalUnit(unit)
+
+	want := map[string]string{"cpu": "1", "env": "production", "replicas": "10", "gpu": "1"}
+	if len(changed.Labels) != len(want) {
+		t.Fatalf("labels = %v, want %v", changed.Labels, want)
+	}
+	for k, v := range want {
+		if changed.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, changed.Labels[k], v)
+		}
+	}
+	if unit.Labels["replicas"] != "3" || unit.Labels["memory"] != "2Gi" {
+		t.Errorf("original unit modified: %v", unit.Labels)
+	}
+}
+
+func TestWhatIfNewUnit(t *testing.T) {
+	m := newStateTestMonitor()
+	m.app = &sdk.DevOpsApp{}
+	m.escalations = NewEscalationEngine(defaultEscalationPolicies(), nil)
+	spaceID := uuid.New()
+	m.monitoredSpaces[spaceID] = &SpaceMonitor{SpaceID: spaceID, SpaceName: "prod", ProjectedCost: 1000}
+
+	replicas, gpus := 2, 1
+	result, err := m.WhatIf(context.Background(), WhatIfRequest{
+		Space:    "prod",
+		Labels:   map[string]string{"cpu": "2"},
+		Replicas: &replicas,
+		GPUs:     &gpus,
+	})
+	if err != nil {
+		t.Fatalf("WhatIf: %v", err)
+	}
+
+	// (2 cores $34.56 + one GPU $252) x 2 replicas
+	if result.ChangeType != "create" || math.Abs(result.CostDelta-573.12) > 0.001 {
+		t.Errorf("result = %+v, want a $573.12 create", result)
+	}
+	if result.ClaudeAssessment != "" {
+		t.Errorf("assessment without Claude = %q", result.ClaudeAssessment)
+	}
+	if math.Abs(result.SpaceProjectedCost-1573.12) > 0.001 {
+		t.Errorf("space projected = %.2f, want 1573.12", result.SpaceProjectedCost)
+	}
+	if result.RiskAssessment.Level != "critical" || result.RiskAssessment.Stage != "block" {
+		t.Errorf("risk = %+v, want critical and blocked", result.RiskAssessment)
+	}
+	if len(m.escalations.List()) != 0 {
+		t.Error("what-if recorded an escalation")
+	}
+
+	if _, err := m.WhatIf(context.Background(), WhatIfRequest{Space: "staging"}); !errors.Is(err, errWhatIfNotFound) {
+		t.Errorf("unknown space: err = %v, want not found", err)
+	}
+}
+
+func TestWhatIfStubAssessment(t *testing.T) {
+	m := newStateTestMonitor()
+	m.app = &sdk.DevOpsApp{}
+	m.escalations = NewEscalationEngine(defaultEscalationPolicies(), nil)
+	m.claude = newClaudeClient(Config{ClaudeMode: "stub"})
+	m.monitoredSpaces[uuid.New()] = &SpaceMonitor{SpaceName: "prod"}
+
+	gpus := 2
+	result, err := m.WhatIf(context.Background(), WhatIfRequest{Space: "prod", GPUs: &gpus})
+	if err != nil {
+		t.Fatalf("WhatIf: %v", err)
+	}
+
+	want := "[stub] " + result.RiskAssessment.Level + " risk: the change moves cost by $504.00/month."
+	if !strings.HasPrefix(result.ClaudeAssessment, want) {
+		t.Errorf("assessment = %q, want prefix %q", result.ClaudeAssessment, want)
+	}
+}
+
+// recordingClaude answers every prompt with "ok" and keeps the last one
+type recordingClaude struct{ prompt string }
+
+func (r *recordingClaude) Complete(prompt string) (string, error) {
+	r.prompt = prompt
+	return "ok", nil
+}
+
+func TestWhatIfPromptFromConfigHub(t *testing.T) {
+	m := newStateTestMonitor()
+	m.app = &sdk.DevOpsApp{}
+	m.escalations = NewEscalationEngine(defaultEscalationPolicies(), nil)
+	recorder := &recordingClaude{}
+	m.claude = recorder
+	m.prompts = prompts.New([]prompts.Prompt{whatIfAssessmentPrompt}, func(context.Context) ([]prompts.Unit, error) {
+		return []prompts.Unit{{Slug: "prompt-whatif-assessment", Data: `{{.Result.Space}} {{printf "%.0f" .Result.CostDelta}}`, Revision: 7}}, nil
+	})
+	if err := m.prompts.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m.monitoredSpaces[uuid.New()] = &SpaceMonitor{SpaceName: "prod"}
+
+	gpus := 2
+	if _, err := m.WhatIf(context.Background(), WhatIfRequest{Space: "prod", GPUs: &gpus}); err != nil {
+		t.Fatalf("WhatIf: %v", err)
+	}
+	if recorder.prompt != "prod 504" {
+		t.Errorf("prompt = %q, want the ConfigHub template", recorder.prompt)
+	}
+}
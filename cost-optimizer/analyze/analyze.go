@@ -0,0 +1,133 @@
+// Package analyze estimates the cost of the units in a ConfigHub space
+// before they are deployed. It backs cmd/analyze-confighub and
+// "devops-apps analyze".
+package analyze
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/logging"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Main parses args (without the program name) and runs the analysis
+func Main(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var (
+		spaceID    = flags.String("space", "", "ConfigHub space ID to analyze")
+		hierarchy  = flags.Bool("hierarchy", false, "Analyze full environment hierarchy")
+		storeBack  = flags.Bool("store", false, "Store cost annotations back to ConfigHub")
+		jsonOutput = flags.Bool("json", false, "Output as JSON")
+	)
+	flags.Parse(args)
+
+	logging.Setup("analyze-confighub")
+
+	// Initialize SDK app
+	app, err := sdk.NewApp("confighub-cost-analyzer", "1.0.0")
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+
+	// Get space ID from environment or flag
+	if *spaceID == "" {
+		*spaceID = os.Getenv("CONFIGHUB_SPACE_ID")
+	}
+	if *spaceID == "" {
+		// Try to read from .cub-project file
+		if data, err := os.ReadFile(".cub-project"); err == nil {
+			*spaceID = string(data)
+		}
+	}
+	if *spaceID == "" {
+		fmt.Printf("Usage: %s -space <space-id>\n", name)
+		fmt.Println("   or: export CONFIGHUB_SPACE_ID=<space-id>")
+		fmt.Println("   or: create .cub-project file with space ID")
+		os.Exit(1)
+	}
+
+	// Parse space ID
+	spaceUUID, err := uuid.Parse(*spaceID)
+	if err != nil {
+		logging.Fatal("Invalid space ID format", logging.Space(*spaceID), logging.Err(err))
+	}
+
+	// Create SDK cost analyzer
+	analyzer := sdk.NewCostAnalyzer(app, spaceUUID)
+
+	fmt.Println("🔍 ConfigHub Cost Analyzer")
+	fmt.Println("══════════════════════════")
+	fmt.Printf("Space: %s\n", *spaceID)
+	fmt.Printf("Time: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	// Perform analysis
+	var analysis *sdk.SpaceCostAnalysis
+	if *hierarchy {
+		fmt.Println("Analyzing full environment hierarchy...")
+		analysis, err = analyzer.AnalyzeHierarchy(*spaceID)
+	} else {
+		fmt.Println("Analyzing single space...")
+		analysis, err = analyzer.AnalyzeSpace()
+	}
+
+	if err != nil {
+		logging.Fatal("Analysis failed", logging.Space(*spaceID), logging.Err(err))
+	}
+
+	// Store annotations if requested
+	if *storeBack {
+		fmt.Println("\n📝 Storing cost annotations in ConfigHub...")
+		if err := analyzer.StoreAnalysisInConfigHub(analysis); err != nil {
+			slog.Warn("Failed to store some annotations", logging.Err(err))
+		}
+	}
+
+	// Output results
+	if *jsonOutput {
+		// JSON output for programmatic use
+		outputJSON(analysis)
+	} else {
+		// Human-readable report
+		report := analyzer.GenerateReport(analysis)
+		fmt.Println(report)
+
+		// Summary
+		fmt.Println("\n💡 Key Insights:")
+		fmt.Printf("• Total estimated monthly cost: $%.2f\n", analysis.TotalMonthlyCost)
+		fmt.Printf("• Number of workloads analyzed: %d\n", len(analysis.Units))
+
+		if len(analysis.Units) > 0 {
+			// Find most expensive unit
+			maxCost := 0.0
+			var maxUnit sdk.UnitCostEstimate
+			for _, unit := range analysis.Units {
+				if unit.MonthlyCost > maxCost {
+					maxCost = unit.MonthlyCost
+					maxUnit = unit
+				}
+			}
+			fmt.Printf("• Most expensive: %s ($%.2f/month)\n", maxUnit.UnitName, maxUnit.MonthlyCost)
+		}
+
+		fmt.Println("\n🚀 Next Steps:")
+		fmt.Println("1. Deploy these configs to see actual usage")
+		fmt.Println("2. Run cost-optimizer with OpenCost for real metrics")
+		fmt.Println("3. Use Claude AI for optimization recommendations")
+	}
+}
+
+func outputJSON(analysis *sdk.SpaceCostAnalysis) {
+	// Use proper JSON marshaling
+	data, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		slog.Error("Failed to marshal analysis", logging.Err(err))
+		return
+	}
+	fmt.Println(string(data))
+}
\ No newline at end of file
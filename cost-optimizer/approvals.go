@@ -0,0 +1,178 @@
+package costoptimizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/approval"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/workloads"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newApprovals returns the optimizer's recommendations under review, kept
+// in the recommendations-cost-optimizer unit of the space with slug space,
+// else in a file in dir; in memory only without either
+func newApprovals(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space, dir string) (*approval.Board, error) {
+	if cub == nil || space == "" {
+		if dir == "" {
+			return approval.New("cost-optimizer", nil, nil), nil
+		}
+		writer, reader, err := approval.Dir(dir)
+		if err != nil {
+			return nil, err
+		}
+		return approval.New("cost-optimizer", writer, reader), nil
+	}
+	store := unitStore{cub: cub, limit: limit, space: space}
+	return approval.New("cost-optimizer", store.write, store.read), nil
+}
+
+// handleApprovals applies and rolls back the reviewed recommendations, and
+// watches the workloads of the applied ones
+func (a *CostRecommendationApplier) handleApprovals() {
+	a.optimizer.approvals.Handle(func(ctx context.Context, r approval.Recommendation) error {
+		var rec CostRecommendation
+		if err := json.Unmarshal(r.Input, &rec); err != nil {
+			return fmt.Errorf("recommendation %s: %w", r.ID, err)
+		}
+		return a.ApplyRecommendation(ctx, rec)
+	}, func(ctx context.Context, r approval.Recommendation) error {
+		return a.RollbackRecommendation(ctx, r.Resource)
+	})
+	a.optimizer.approvals.Watch(a.optimizer.workloadHealth)
+}
+
+// reviewRecommendations files the analysis's recommendations for review and
+// marks each with its ID and state. With auto-apply on, the policy approves
+// those it allows and rejects those it denies on its own; the rest wait for
+// someone. It then applies the approved ones and verifies the applied ones
+// against the analysis's costs.
+func (c *CostOptimizer) reviewRecommendations(ctx context.Context, analysis *CostAnalysis) {
+	auto := c.flags.Enabled(flags.AutoApply)
+	for i := range analysis.Recommendations {
+		rec := &analysis.Recommendations[i]
+		input, err := json.Marshal(rec)
+		if err != nil {
+			slog.Warn("Failed to encode recommendation", logging.Unit(rec.Resource), logging.Err(err))
+			continue
+		}
+		baseline, _ := resourceCost(analysis, rec.Resource, rec.Namespace)
+		filed, err := c.approvals.File(ctx, approval.Recommendation{
+			Resource: rec.Resource, Namespace: rec.Namespace, Change: describeChange(rec.Recommended),
+			Input: input, MonthlySavings: rec.MonthlySavings, BaselineCost: baseline,
+		})
+		if err != nil {
+			slog.Warn("Failed to store recommendation", logging.Unit(rec.Resource), logging.Err(err))
+		}
+
+		if auto && filed.State == approval.Pending {
+			decision := c.policy.Check(ctx, c.audit, optimizationAction(c.applier.getUnitSlug(*rec), rec.Risk, rec.MonthlySavings))
+			note := fmt.Sprintf("policy rule %s", decision.Rule)
+			switch decision.Verdict {
+			case policy.Allow:
+				filed, err = c.approvals.Approve(ctx, filed.ID, "policy", note)
+			case policy.Deny:
+				filed, err = c.approvals.Reject(ctx, filed.ID, "policy", note)
+			}
+			if err != nil {
+				slog.Warn("Failed to review recommendation", logging.Unit(rec.Resource), logging.Err(err))
+			}
+		}
+		rec.ID, rec.State = filed.ID, filed.State
+	}
+
+	cost := func(r approval.Recommendation) (float64, bool) {
+		return resourceCost(analysis, r.Resource, r.Namespace)
+	}
+	if applied := c.approvals.Process(ctx, cost); applied > 0 {
+		slog.Info("Applied approved cost recommendations", "count", applied)
+	}
+	c.approvals.Verify(ctx, cost)
+	for i := range analysis.Recommendations {
+		if r, ok := c.approvals.Get(analysis.Recommendations[i].ID); ok {
+			analysis.Recommendations[i].State = r.State
+		}
+	}
+}
+
+// latestCost is the monthly cost of a recommendation's resource in the
+// latest analysis, for approvals applied between analyses
+func (c *CostOptimizer) latestCost(r approval.Recommendation) (float64, bool) {
+	analysis := c.dashboard.LatestAnalysis()
+	if analysis == nil {
+		return 0, false
+	}
+	return resourceCost(analysis, r.Resource, r.Namespace)
+}
+
+// workloadHealth is how the workload of an applied recommendation has done
+// since: its utilization measured by the latest analysis, and its container
+// restarts. Simulated utilization is unknown.
+func (c *CostOptimizer) workloadHealth(r approval.Recommendation) (approval.Health, bool) {
+	resources, cluster, measured := c.observed()
+	usage, ok := findUsage(resources, r.Resource, r.Namespace)
+	if !ok || !measured || r.AppliedAt == nil {
+		return approval.Health{}, false
+	}
+	return approval.Health{
+		CPUUtilization:    usage.CPUUtilization,
+		MemoryUtilization: usage.MemUtilization,
+		Restarts:          cluster.Restarts(workloads.Key(usage.Namespace, usage.Type, usage.Name), *r.AppliedAt),
+	}, true
+}
+
+// observed returns the resources and workloads of the latest analysis, and
+// whether their utilization was measured
+func (c *CostOptimizer) observed() ([]ResourceUsage, workloads.Cluster, bool) {
+	c.observedMu.RLock()
+	defer c.observedMu.RUnlock()
+	return c.resources, c.workloads, c.measured
+}
+
+// observe records the resources of an analysis
+func (c *CostOptimizer) observe(resources []ResourceUsage, measured bool) {
+	c.observedMu.Lock()
+	defer c.observedMu.Unlock()
+	c.resources, c.measured = resources, measured
+}
+
+// resourceCost returns the monthly cost of resource, e.g. deployment/web,
+// in namespace; recommendations spanning several resources have none
+func resourceCost(analysis *CostAnalysis, resource, namespace string) (float64, bool) {
+	usage, ok := findUsage(analysis.ResourceDetails, resource, namespace)
+	return usage.MonthlyCost, ok
+}
+
+// findUsage returns the usage of resource in namespace
+func findUsage(resources []ResourceUsage, resource, namespace string) (ResourceUsage, bool) {
+	for _, usage := range resources {
+		if usage.Namespace == namespace &&
+			(resource == strings.ToLower(usage.Type)+"/"+usage.Name || resource == usage.Name) {
+			return usage, true
+		}
+	}
+	return ResourceUsage{}, false
+}
+
+// describeChange describes a recommended configuration, e.g. cpu=250m
+// memory=256Mi, so the same change is filed once
+func describeChange(recommended map[string]interface{}) string {
+	keys := make([]string, 0, len(recommended))
+	for k := range recommended {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, recommended[k]))
+	}
+	return strings.Join(parts, " ")
+}
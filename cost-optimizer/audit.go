@@ -0,0 +1,58 @@
+package costoptimizer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newAuditLog returns the optimizer's audit log, writing entries as units of
+// the space with slug space; kept in memory only without a space or client
+func newAuditLog(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) *audit.Log {
+	if cub == nil || space == "" {
+		return audit.New("cost-optimizer", nil, nil)
+	}
+	spaceID := func(ctx context.Context) (uuid.UUID, error) {
+		spaces, err := ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug == space {
+				return s.SpaceID, nil
+			}
+		}
+		return uuid.Nil, fmt.Errorf("audit space %s not found", space)
+	}
+
+	writer := func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+		return err
+	}
+	reader := func(ctx context.Context, where string) ([]string, error) {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		units, err := ratelimit.Call(ctx, limit, "ListUnits", id.String()+"/"+where, func() ([]*sdk.Unit, error) {
+			return cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: where})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list units: %w", err)
+		}
+		data := make([]string, 0, len(units))
+		for _, u := range units {
+			data = append(data, u.Data)
+		}
+		return data, nil
+	}
+	return audit.New("cost-optimizer", writer, reader)
+}
@@ -0,0 +1,117 @@
+package costoptimizer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/workloads"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+)
+
+// autoscalerResource prefixes the resource of recommendations for an HPA,
+// e.g. horizontalpodautoscaler/web
+const autoscalerResource = "horizontalpodautoscaler/"
+
+// autoscaleRecommendations keeps the recommendations from fighting the
+// HPAs. A replica count recommended for a workload an HPA scales would be
+// undone by the HPA, so it is recommended as a change of the HPA's bounds
+// instead (see workloads.Rescale), with the savings of running fewer pods.
+// The workload keeps the rest of its recommendation, if any.
+func (c *CostOptimizer) autoscaleRecommendations(analysis *CostAnalysis) {
+	recommendations := make([]CostRecommendation, 0, len(analysis.Recommendations))
+	for _, rec := range analysis.Recommendations {
+		replicas, ok := number(rec.Recommended["replicas"])
+		usage, found := findUsage(analysis.ResourceDetails, rec.Resource, rec.Namespace)
+		var hpa *autoscalingv2.HorizontalPodAutoscaler
+		if ok && found {
+			hpa = c.workloads.Autoscaler(usage.Namespace, usage.Type, usage.Name)
+		}
+		if hpa == nil {
+			recommendations = append(recommendations, rec)
+			continue
+		}
+
+		saved := 0.0
+		if usage.Replicas > replicas {
+			saved = usage.MonthlyCost * float64(usage.Replicas-replicas) / float64(usage.Replicas)
+		}
+		if saved > rec.MonthlySavings {
+			saved = rec.MonthlySavings
+		}
+		workload := rec
+		workload.Current, workload.Recommended = without(rec.Current, "replicas"), without(rec.Recommended, "replicas")
+		if change, ok := workloads.Rescale(*hpa, replicas); ok {
+			recommendations = append(recommendations, autoscalerRecommendation(rec, *hpa, change, replicas, saved))
+			workload.MonthlySavings -= saved
+		}
+		_, cpu := workload.Recommended["cpu"]
+		_, memory := workload.Recommended["memory"]
+		if cpu || memory {
+			recommendations = append(recommendations, workload)
+		}
+	}
+	analysis.Recommendations = recommendations
+}
+
+// autoscalerRecommendation recommends change of hpa, instead of running
+// replicas pods of the workload rec is for
+func autoscalerRecommendation(rec CostRecommendation, hpa autoscalingv2.HorizontalPodAutoscaler, change workloads.Change, replicas int32, saved float64) CostRecommendation {
+	minReplicas := int32(1)
+	if hpa.Spec.MinReplicas != nil {
+		minReplicas = *hpa.Spec.MinReplicas
+	}
+	current := map[string]interface{}{"minReplicas": minReplicas, "maxReplicas": hpa.Spec.MaxReplicas}
+	if target := workloads.TargetCPUUtilization(hpa); target > 0 {
+		current["targetCPUUtilization"] = target
+	}
+	recommended := make(map[string]interface{})
+	if change.MinReplicas != nil {
+		recommended["minReplicas"] = *change.MinReplicas
+	}
+	if change.MaxReplicas != nil {
+		recommended["maxReplicas"] = *change.MaxReplicas
+	}
+	if change.TargetCPUUtilization != nil {
+		recommended["targetCPUUtilization"] = *change.TargetCPUUtilization
+	}
+
+	return CostRecommendation{
+		Resource:       autoscalerResource + hpa.Name,
+		Namespace:      hpa.Namespace,
+		Type:           "autoscale",
+		Priority:       rec.Priority,
+		Current:        current,
+		Recommended:    recommended,
+		MonthlySavings: saved,
+		Risk:           rec.Risk,
+		Explanation: fmt.Sprintf("%s is scaled by HorizontalPodAutoscaler %s, which would undo %d replicas; changing its bounds lets it run them. %s",
+			rec.Resource, hpa.Name, replicas, rec.Explanation),
+		ConfigHubAction: "Update the HorizontalPodAutoscaler unit's replica bounds and CPU target",
+	}
+}
+
+// autoscalerPatch returns the patch of the HPA a recommendation is for, as
+// last read from the cluster
+func (a *CostRecommendationApplier) autoscalerPatch(rec CostRecommendation) (map[string]interface{}, error) {
+	name := strings.TrimPrefix(rec.Resource, autoscalerResource)
+	_, cluster, _ := a.optimizer.observed()
+	manifest, ok := cluster.Manifest(workloads.Key(rec.Namespace, workloads.HorizontalPodAutoscaler, name))
+	if !ok {
+		return nil, fmt.Errorf("HorizontalPodAutoscaler %s not found in %s", name, rec.Namespace)
+	}
+	return workloads.Patch(manifest, recommendedChange(rec.Recommended))
+}
+
+// without returns values but for key
+func without(values map[string]interface{}, key string) map[string]interface{} {
+	if _, ok := values[key]; !ok {
+		return values
+	}
+	rest := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if k != key {
+			rest[k] = v
+		}
+	}
+	return rest
+}
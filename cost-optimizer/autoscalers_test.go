@@ -0,0 +1,112 @@
+package costoptimizer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/workloads"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func autoscaler(target string, minReplicas, maxReplicas, current, cpu int32) autoscalingv2.HorizontalPodAutoscaler {
+	return autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: target, Namespace: "shop"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: workloads.Deployment, Name: target},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    maxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{
+					Name:   corev1.ResourceCPU,
+					Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &cpu},
+				},
+			}},
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: current},
+	}
+}
+
+func TestAutoscaleRecommendations(t *testing.T) {
+	c := &CostOptimizer{workloads: workloads.Cluster{Autoscalers: []autoscalingv2.HorizontalPodAutoscaler{
+		autoscaler("api", 2, 10, 6, 50),    // 4 replicas need a 75% target
+		autoscaler("worker", 1, 10, 3, 80), // already allows 3 replicas
+		autoscaler("cache", 1, 10, 2, 80),  // already down to 2 replicas, from the 4 measured
+	}}}
+	usage := []ResourceUsage{
+		{Name: "api", Namespace: "shop", Type: workloads.Deployment, Replicas: 6, MonthlyCost: 600},
+		{Name: "worker", Namespace: "shop", Type: workloads.Deployment, Replicas: 3, MonthlyCost: 300},
+		{Name: "web", Namespace: "shop", Type: workloads.Deployment, Replicas: 4, MonthlyCost: 400},
+		{Name: "cache", Namespace: "shop", Type: workloads.Deployment, Replicas: 4, MonthlyCost: 400},
+	}
+	rec := func(resource string, savings float64, current, recommended map[string]interface{}) CostRecommendation {
+		return CostRecommendation{Resource: resource, Namespace: "shop", Type: "rightsize", Priority: "high",
+			Current: current, Recommended: recommended, MonthlySavings: savings, Risk: "low"}
+	}
+	hpaCurrent := "map[maxReplicas:10 minReplicas:2 targetCPUUtilization:50]"
+
+	tests := []struct {
+		name string
+		rec  CostRecommendation
+		want []string // resource $savings current -> recommended
+	}{
+		{
+			name: "no autoscaler",
+			rec:  rec("deployment/web", 100, map[string]interface{}{"replicas": 4}, map[string]interface{}{"replicas": 2.0}),
+			want: []string{"deployment/web $100 map[replicas:4] -> map[replicas:2]"},
+		},
+		{
+			name: "replicas only",
+			rec:  rec("deployment/api", 150, map[string]interface{}{"replicas": 6.0}, map[string]interface{}{"replicas": 4.0}),
+			want: []string{"horizontalpodautoscaler/api $150 " + hpaCurrent + " -> map[targetCPUUtilization:75]"},
+		},
+		{
+			name: "cpu and replicas",
+			rec: rec("deployment/api", 300,
+				map[string]interface{}{"cpu": "500m", "replicas": 6.0}, map[string]interface{}{"cpu": "250m", "replicas": 4.0}),
+			want: []string{
+				"horizontalpodautoscaler/api $200 " + hpaCurrent + " -> map[targetCPUUtilization:75]",
+				"deployment/api $100 map[cpu:500m] -> map[cpu:250m]",
+			},
+		},
+		{
+			name: "autoscaler allows the replicas",
+			rec:  rec("deployment/worker", 50, map[string]interface{}{"replicas": 3.0}, map[string]interface{}{"replicas": 3.0}),
+			want: nil,
+		},
+		{
+			name: "autoscaler allows the replicas, cpu kept",
+			rec: rec("deployment/worker", 50,
+				map[string]interface{}{"cpu": "200m", "replicas": 3.0}, map[string]interface{}{"cpu": "100m", "replicas": 3.0}),
+			want: []string{"deployment/worker $50 map[cpu:200m] -> map[cpu:100m]"},
+		},
+		{
+			name: "autoscaler allows the replicas, savings kept",
+			rec: rec("deployment/cache", 120,
+				map[string]interface{}{"cpu": "500m", "replicas": 4.0}, map[string]interface{}{"cpu": "250m", "replicas": 2.0}),
+			want: []string{"deployment/cache $120 map[cpu:500m] -> map[cpu:250m]"},
+		},
+		{
+			name: "autoscaled but no replicas",
+			rec:  rec("deployment/api", 80, map[string]interface{}{"cpu": "500m"}, map[string]interface{}{"cpu": "250m"}),
+			want: []string{"deployment/api $80 map[cpu:500m] -> map[cpu:250m]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := &CostAnalysis{Recommendations: []CostRecommendation{tt.rec}, ResourceDetails: usage}
+			c.autoscaleRecommendations(analysis)
+
+			var got []string
+			for _, r := range analysis.Recommendations {
+				got = append(got, fmt.Sprintf("%s $%.0f %v -> %v", r.Resource, r.MonthlySavings, r.Current, r.Recommended))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
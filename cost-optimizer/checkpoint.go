@@ -0,0 +1,41 @@
+package costoptimizer
+
+import (
+	"encoding/json"
+
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newCheckpointer returns the checkpointer keeping the optimizer's state in
+// the checkpoint-cost-optimizer unit of the space with slug space; it saves
+// nothing without one
+func newCheckpointer(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) *checkpoint.Checkpointer {
+	if cub == nil || space == "" {
+		return checkpoint.New("cost-optimizer", nil, nil)
+	}
+	store := unitStore{cub: cub, limit: limit, space: space}
+	return checkpoint.New("cost-optimizer", store.write, store.read)
+}
+
+// registerCheckpoint adds the applied recommendations to cp, so a restarted
+// optimizer still marks them applied on the dashboard
+func (a *CostRecommendationApplier) registerCheckpoint(cp *checkpoint.Checkpointer) {
+	cp.Register("applied", func() (interface{}, error) {
+		return a.GetAppliedRecommendations(), nil
+	}, func(data json.RawMessage) error {
+		var applied map[string]*AppliedRecommendation
+		if err := json.Unmarshal(data, &applied); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for resource, rec := range applied {
+			if _, ok := a.applied[resource]; !ok {
+				a.applied[resource] = rec
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package costoptimizer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/llm"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// claudeClient is the part of the AI client the optimizer uses
+type claudeClient interface {
+	Complete(prompt string) (string, error)
+	AnalyzeJSON(prompt string, data interface{}) (string, error)
+	RecentCalls() []sdk.ClaudeAPICall
+}
+
+// apiClaude calls the configured AI provider within its budget
+type apiClaude struct{ *llm.Client }
+
+func (c apiClaude) RecentCalls() []sdk.ClaudeAPICall {
+	var calls []sdk.ClaudeAPICall
+	for _, call := range c.Client.RecentCalls() {
+		calls = append(calls, sdk.ClaudeAPICall{Timestamp: call.Timestamp, Prompt: call.Prompt, Response: call.Response})
+	}
+	return calls
+}
+
+// stubClaude answers with canned analyses (claude_mode stub)
+type stubClaude struct{ *claudestub.Client }
+
+func (c stubClaude) RecentCalls() []sdk.ClaudeAPICall {
+	var calls []sdk.ClaudeAPICall
+	for _, call := range c.Client.RecentCalls() {
+		calls = append(calls, sdk.ClaudeAPICall{Timestamp: call.Timestamp, Prompt: call.Prompt, Response: call.Response})
+	}
+	return calls
+}
+
+// guardedClaude sends prompts through a circuit breaker, so a Claude outage
+// falls back to the rule-based analysis at once
+type guardedClaude struct {
+	claudeClient
+	breaker *breaker.Breaker
+}
+
+func (g guardedClaude) Complete(prompt string) (string, error) {
+	return breaker.Call(g.breaker, func() (string, error) { return g.claudeClient.Complete(prompt) })
+}
+
+func (g guardedClaude) AnalyzeJSON(prompt string, data interface{}) (string, error) {
+	return breaker.Call(g.breaker, func() (string, error) { return g.claudeClient.AnalyzeJSON(prompt, data) })
+}
+
+// newClaudeClient returns canned answers with claude_mode stub, otherwise the
+// API client, or nil when the provider needs an API key and none is set
+func (c *CostOptimizer) newClaudeClient() claudeClient {
+	if c.config.ClaudeMode == claudestub.ModeStub {
+		return guardedClaude{stubClaude{claudestub.New(c.stubRecommendations(), stubInsights)}, c.claudeBreaker}
+	}
+	if c.llmClient == nil {
+		return nil
+	}
+	return guardedClaude{apiClaude{c.llmClient}, c.claudeBreaker}
+}
+
+// stubRecommendations answers analyzeWithClaude with the rule-based analysis
+// in the JSON shape the prompt asks for
+func (c *CostOptimizer) stubRecommendations() claudestub.Responder {
+	return claudestub.Responder{
+		Name:  "cost-recommendations",
+		Match: claudestub.Contains("Analyze the following Kubernetes resource usage data"),
+		Answer: func(_ string, data interface{}) (string, error) {
+			usage, ok := data.([]ResourceUsage)
+			if !ok {
+				return "", fmt.Errorf("expected []ResourceUsage, got %T", data)
+			}
+
+			analysis := c.basicCostAnalysis(usage, false)
+			for i := range analysis.Recommendations {
+				analysis.Recommendations[i].Explanation = "[stub] " + analysis.Recommendations[i].Explanation
+			}
+			out, err := json.Marshal(map[string]interface{}{
+				"total_monthly_cost": analysis.TotalMonthlyCost,
+				"potential_savings":  analysis.PotentialSavings,
+				"savings_percentage": analysis.SavingsPercentage,
+				"recommendations":    analysis.Recommendations,
+			})
+			return string(out), err
+		},
+	}
+}
+
+// stubInsights answers enhanceWithClaudeAI
+var stubInsights = claudestub.Responder{
+	Name:  "cost-insights",
+	Match: claudestub.Contains("Analyze this ConfigHub-based cost optimization"),
+	Answer: func(string, interface{}) (string, error) {
+		return `[stub] Insights:
+1. Rightsize the largest under-utilized deployments first; they hold most of the savings.
+2. Keep at least two replicas for customer-facing services when scaling down.
+3. Risk: low for request changes below 50% utilization, medium for replica reductions.`, nil
+	},
+}
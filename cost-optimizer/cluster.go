@@ -0,0 +1,72 @@
+package costoptimizer
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/logging"
+	sdk "github.com/monadic/devops-sdk"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// clusterInfo is what the optimizer knows of the cluster it prices
+type clusterInfo struct {
+	Type   string // kind, eks, gke or aks
+	Region string // of the nodes; empty when they don't say
+}
+
+// detectCluster reads the cluster's type and region from a few of its
+// nodes, falling back to the pod's environment when they can't be listed
+func detectCluster(ctx context.Context, app *sdk.DevOpsApp) clusterInfo {
+	var nodes []corev1.Node
+	if app != nil && app.K8s != nil && app.K8s.Clientset != nil {
+		list, err := app.K8s.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 5})
+		if err != nil {
+			slog.Debug("Could not list nodes to detect the cloud", logging.Err(err))
+		} else {
+			nodes = list.Items
+		}
+	}
+	info := clusterFromNodes(nodes)
+	if info.Type == "kind" {
+		info.Type = clusterFromEnvironment()
+	}
+	return info
+}
+
+// clusterFromNodes tells the cluster type from the nodes' provider IDs and
+// the region from their topology label
+func clusterFromNodes(nodes []corev1.Node) clusterInfo {
+	info := clusterInfo{Type: "kind"} // local development cluster
+	for _, node := range nodes {
+		if provider := costmodel.ForProviderID(node.Spec.ProviderID); provider != "" {
+			info.Type = costmodel.ClusterType(provider)
+		}
+		if region := node.Labels[corev1.LabelTopologyRegion]; region != "" {
+			info.Region = region
+		}
+		if info.Type != "kind" && info.Region != "" {
+			break
+		}
+	}
+	return info
+}
+
+// clusterFromEnvironment tells the cluster type from what the managed
+// services mount into pods
+func clusterFromEnvironment() string {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return "kind"
+	}
+	if _, err := os.Stat("/var/run/secrets/eks.amazonaws.com"); err == nil {
+		return "eks"
+	} else if _, err := os.Stat("/var/run/secrets/azure"); err == nil {
+		return "aks"
+	} else if os.Getenv("GKE_CLUSTER_NAME") != "" {
+		return "gke"
+	}
+	return "kind"
+}
@@ -0,0 +1,9 @@
+// Command cost-optimizer analyzes cluster usage and recommends (and applies)
+// cost optimizations through ConfigHub. It also runs as "devops-apps cost".
+package main
+
+import costoptimizer "github.com/monadic/devops-examples/cost-optimizer"
+
+func main() {
+	costoptimizer.Main()
+}
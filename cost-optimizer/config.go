@@ -0,0 +1,251 @@
+package costoptimizer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/approval"
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/costhistory"
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/llm"
+)
+
+// Config holds the cost optimizer's settings, read from CONFIG_FILE
+// (default /etc/cost-optimizer/config.yaml). YAML keys are the lower-case
+// names of the environment variables that override them.
+type Config struct {
+	CubToken       string        `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	CubAPIURL      string        `yaml:"cub_api_url" env:"CUB_API_URL"`
+	ClaudeAPIKey   string        `yaml:"claude_api_key" env:"CLAUDE_API_KEY" secret:"true"`
+	ClaudeMode     string        `yaml:"claude_mode" env:"CLAUDE_MODE"`               // "api", or "stub" for canned answers
+	SecretsDir     string        `yaml:"secrets_dir" env:"SECRETS_DIR"`               // token files (cub-token, claude-api-key) overriding the above
+	SpaceID        string        `yaml:"confighub_space_id" env:"CONFIGHUB_SPACE_ID"` // empty creates a new space
+	AWSRegion      string        `yaml:"aws_region" env:"AWS_REGION"`
+	CloudProvider  string        `yaml:"cloud_provider" env:"CLOUD_PROVIDER"` // priced by costmodel: aws, gcp, azure, or auto for the cluster's
+	CloudRegion    string        `yaml:"cloud_region" env:"CLOUD_REGION"`     // empty is the nodes' region, then aws_region on aws, else the provider's reference region
+	EnableOpenCost bool          `yaml:"enable_opencost" env:"ENABLE_OPENCOST"`
+	OpenCostURL    string        `yaml:"opencost_url" env:"OPENCOST_URL"` // empty uses ConfigHub config, then in-cluster service
+	AutoApply      bool          `yaml:"auto_apply_optimizations" env:"AUTO_APPLY_OPTIMIZATIONS"`
+	NotifyConfig   string        `yaml:"notify_config" env:"NOTIFY_CONFIG"`
+	PolicyConfig   string        `yaml:"policy_config" env:"POLICY_CONFIG"`               // auto-apply rules, see pkg/policy; a missing file keeps the built-in ones
+	AuthConfig     string        `yaml:"auth_config" env:"AUTH_CONFIG"`                   // OIDC login for the dashboard; a missing file leaves it open
+	MetricsSinks   string        `yaml:"metrics_sinks_config" env:"METRICS_SINKS_CONFIG"` // Datadog and New Relic pushes, see pkg/metrics; missing only serves /metrics
+	RunInterval    time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`                 // fallback when no informer event arrives
+	FlagsSpace     string        `yaml:"flags_space" env:"FLAGS_SPACE"`                   // feature-flags unit overriding auto_apply_optimizations
+	FlagsRefresh   time.Duration `yaml:"flags_refresh" env:"FLAGS_REFRESH"`
+	AuditSpace     string        `yaml:"audit_space" env:"AUDIT_SPACE"`       // space audit entries are written to; empty keeps them in memory
+	PendingSpace   string        `yaml:"pending_space" env:"PENDING_SPACE"`   // space recommendations under review are kept in, see pkg/approval
+	PendingDir     string        `yaml:"pending_dir" env:"PENDING_DIR"`       // directory they are kept in without a space; empty keeps them in memory
+	ClusterName    string        `yaml:"cluster_name" env:"CLUSTER_NAME"`     // cluster label of the /metrics samples
+	Pprof          bool          `yaml:"pprof" env:"PPROF"`                   // /debug/pprof/ on the health port and dashboard
+	CubRateLimit   float64       `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst   int           `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Space the applied recommendations are checkpointed to, see
+	// pkg/checkpoint, and how often; empty forgets them on restart
+	CheckpointSpace    string        `yaml:"checkpoint_space" env:"CHECKPOINT_SPACE"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env:"CHECKPOINT_INTERVAL"`
+	// Space of the cost history unit, else directory of its file (neither
+	// keeps it in memory), and how long each analysis stays in it
+	HistorySpace     string        `yaml:"history_space" env:"HISTORY_SPACE"`
+	HistoryDir       string        `yaml:"history_dir" env:"HISTORY_DIR"`
+	HistoryRetention time.Duration `yaml:"history_retention" env:"HISTORY_RETENTION"`
+	// How long an applied recommendation is watched, and the utilization of
+	// its workload (percent of the new requests) and container restarts
+	// within that time that roll it back; 0 disables a threshold
+	VerifyWithin              time.Duration `yaml:"verify_within" env:"VERIFY_WITHIN"`
+	RollbackCPUUtilization    float64       `yaml:"rollback_cpu_utilization" env:"ROLLBACK_CPU_UTILIZATION"`
+	RollbackMemoryUtilization float64       `yaml:"rollback_memory_utilization" env:"ROLLBACK_MEMORY_UTILIZATION"`
+	RollbackRestarts          int           `yaml:"rollback_restarts" env:"ROLLBACK_RESTARTS"`
+	// Slack and Microsoft Teams webhooks the recommendation digest is posted
+	// to besides notify_config's routes, the monthly savings a
+	// recommendation needs to be in it, and the dashboard URL it links to
+	// (empty for no links)
+	SlackWebhookURL  string  `yaml:"slack_webhook_url" env:"SLACK_WEBHOOK_URL" secret:"true"`
+	TeamsWebhookURL  string  `yaml:"teams_webhook_url" env:"TEAMS_WEBHOOK_URL" secret:"true"`
+	NotifyMinSavings float64 `yaml:"notify_min_savings" env:"NOTIFY_MIN_SAVINGS"`
+	DashboardURL     string  `yaml:"dashboard_url" env:"DASHBOARD_URL"`
+	// NATS server recommendation events are published to; empty publishes none
+	NATSURL           string `yaml:"nats_url" env:"NATS_URL"`
+	NATSToken         string `yaml:"nats_token" env:"NATS_TOKEN" secret:"true"`
+	NATSSubjectPrefix string `yaml:"nats_subject_prefix" env:"NATS_SUBJECT_PREFIX"`
+	// Consecutive ConfigHub, Claude or OpenCost failures before calls fail
+	// fast, and how long until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+	// AI behind the cost recommendations: "claude", "openai" (any OpenAI-compatible
+	// endpoint) or "ollama", at llm_base_url (empty for the provider's own).
+	// llm_api_key authenticates openai and ollama; claude uses claude_api_key.
+	LLMProvider string `yaml:"llm_provider" env:"LLM_PROVIDER"`
+	LLMBaseURL  string `yaml:"llm_base_url" env:"LLM_BASE_URL"`
+	LLMAPIKey   string `yaml:"llm_api_key" env:"LLM_API_KEY" secret:"true"`
+	// Model (empty for the provider's default), response limit and
+	// temperature, and the tokens used a month (0 for no limit). Prices, in
+	// dollars per million tokens, estimate the spend at /api/v1/llm/usage.
+	LLMModel       string  `yaml:"llm_model" env:"LLM_MODEL"`
+	LLMMaxTokens   int     `yaml:"llm_max_tokens" env:"LLM_MAX_TOKENS"`
+	LLMTemperature float64 `yaml:"llm_temperature" env:"LLM_TEMPERATURE"`
+	LLMTokenBudget int64   `yaml:"llm_monthly_token_budget" env:"LLM_MONTHLY_TOKEN_BUDGET"`
+	LLMInputPrice  float64 `yaml:"llm_input_price" env:"LLM_INPUT_PRICE"`
+	LLMOutputPrice float64 `yaml:"llm_output_price" env:"LLM_OUTPUT_PRICE"`
+	// Space of the prompt-<name> units replacing the built-in prompts, if
+	// any, and the interval they are re-read at
+	PromptsSpace   string        `yaml:"prompts_space" env:"PROMPTS_SPACE"`
+	PromptsRefresh time.Duration `yaml:"prompts_refresh" env:"PROMPTS_REFRESH"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them; deploy/charts/cost-optimizer defaults to the same values
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:      "https://hub.confighub.com/api",
+		ClaudeMode:     claudestub.ModeAPI,
+		AWSRegion:      "us-east-1",
+		CloudProvider:  autoProvider,
+		EnableOpenCost: true,
+		NotifyConfig:   "/etc/cost-optimizer/notify.yaml",
+		PolicyConfig:   "/etc/cost-optimizer/policy.yaml",
+		AuthConfig:     "/etc/cost-optimizer/auth.yaml",
+		MetricsSinks:   "/etc/cost-optimizer/metrics-sinks.yaml",
+		RunInterval:    10 * time.Minute,
+		FlagsRefresh:   30 * time.Second,
+		CubRateLimit:   5,
+		CubRateBurst:   10,
+
+		CheckpointInterval: checkpoint.DefaultInterval,
+
+		HistoryRetention: costhistory.DefaultRetention,
+
+		VerifyWithin:              approval.DefaultVerifyWithin,
+		RollbackCPUUtilization:    95,
+		RollbackMemoryUtilization: 90,
+		RollbackRestarts:          3,
+
+		NotifyMinSavings: 50,
+
+		NATSSubjectPrefix: events.DefaultPrefix,
+
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+
+		LLMProvider:    llm.ProviderClaude,
+		LLMMaxTokens:   llm.DefaultMaxTokens,
+		LLMInputPrice:  llm.DefaultInputPrice,
+		LLMOutputPrice: llm.DefaultOutputPrice,
+
+		PromptsRefresh: time.Minute,
+	}
+}
+
+// autoProvider prices workloads at the rates of the cloud the cluster runs
+// on, AWS for kind and other clusters
+const autoProvider = "auto"
+
+// pricing is the cost model of the configured cloud and region, or with
+// cloud_provider auto of the cluster's
+func (c *Config) pricing(cluster clusterInfo) (costmodel.Pricing, error) {
+	provider := c.CloudProvider
+	if strings.EqualFold(provider, autoProvider) {
+		if provider = costmodel.ForCluster(cluster.Type); provider == "" {
+			provider = costmodel.AWS
+		}
+	}
+	region := c.CloudRegion
+	if region == "" && strings.EqualFold(provider, costmodel.ForCluster(cluster.Type)) {
+		region = cluster.Region
+	}
+	if region == "" && strings.EqualFold(provider, costmodel.AWS) {
+		region = c.AWSRegion
+	}
+	return costmodel.Lookup(provider, region)
+}
+
+// llmConfig is the provider, model and budget of the AI client
+func (c *Config) llmConfig() llm.Config {
+	return llm.Config{
+		Provider:           c.LLMProvider,
+		BaseURL:            c.LLMBaseURL,
+		Model:              c.LLMModel,
+		MaxTokens:          c.LLMMaxTokens,
+		Temperature:        c.LLMTemperature,
+		MonthlyTokenBudget: c.LLMTokenBudget,
+		InputPrice:         c.LLMInputPrice,
+		OutputPrice:        c.LLMOutputPrice,
+	}
+}
+
+// llmAPIKey is the API key of the configured provider
+func (c *Config) llmAPIKey() string {
+	if c.LLMProvider == llm.ProviderClaude {
+		return c.ClaudeAPIKey
+	}
+	return c.LLMAPIKey
+}
+
+// Validate rejects settings that would fail after startup
+func (c *Config) Validate() error {
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.FlagsRefresh <= 0 {
+		return fmt.Errorf("flags_refresh must be positive, got %s", c.FlagsRefresh)
+	}
+	if c.CheckpointInterval <= 0 {
+		return fmt.Errorf("checkpoint_interval must be positive, got %s", c.CheckpointInterval)
+	}
+	if c.HistoryRetention <= 0 {
+		return fmt.Errorf("history_retention must be positive, got %s", c.HistoryRetention)
+	}
+	if c.VerifyWithin <= 0 {
+		return fmt.Errorf("verify_within must be positive, got %s", c.VerifyWithin)
+	}
+	if c.RollbackCPUUtilization < 0 || c.RollbackMemoryUtilization < 0 || c.RollbackRestarts < 0 {
+		return fmt.Errorf("rollback thresholds must not be negative")
+	}
+	if c.NotifyMinSavings < 0 {
+		return fmt.Errorf("notify_min_savings must not be negative, got %g", c.NotifyMinSavings)
+	}
+	if c.PromptsRefresh <= 0 {
+		return fmt.Errorf("prompts_refresh must be positive, got %s", c.PromptsRefresh)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	if c.SpaceID != "" {
+		if _, err := uuid.Parse(c.SpaceID); err != nil {
+			return fmt.Errorf("confighub_space_id: %w", err)
+		}
+	}
+	if _, err := c.pricing(clusterInfo{}); err != nil {
+		return fmt.Errorf("cloud_provider: %w", err)
+	}
+	if err := c.llmConfig().Validate(); err != nil {
+		return err
+	}
+	return claudestub.ValidateMode(c.ClaudeMode)
+}
+
+// loadConfig reads the config file named by COST_OPTIMIZER_CONFIG_FILE or
+// CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(config.File("cost-optimizer", "/etc/cost-optimizer/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
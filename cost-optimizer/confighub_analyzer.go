@@ -1,9 +1,11 @@
-package main
+package costoptimizer
 
 import (
 	"fmt"
+	"log/slog"
 
 	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/logging"
 	sdk "github.com/monadic/devops-sdk"
 )
 
@@ -28,7 +30,7 @@ func NewConfigHubAnalyzer(app *sdk.DevOpsApp, spaceID string) *ConfigHubAnalyzer
 	// Parse spaceID as UUID
 	spaceUUID, err := uuid.Parse(spaceID)
 	if err != nil {
-		app.Logger.Printf("⚠️ Invalid space ID %s, using nil UUID: %v", spaceID, err)
+		slog.Warn("Invalid space ID, using nil UUID", logging.Space(spaceID), logging.Err(err))
 		spaceUUID = uuid.Nil
 	}
 
@@ -70,19 +72,19 @@ func (ca *ConfigHubAnalyzer) analyzeDaemonSet(unit sdk.Unit, manifest map[string
 // extractContainerResources is deprecated - use SDK CostAnalyzer directly
 func (ca *ConfigHubAnalyzer) extractContainerResources(container map[string]interface{}, estimate *UnitCostEstimate) {
 	// This functionality is now handled by the SDK CostAnalyzer
-	ca.app.Logger.Printf("⚠️ extractContainerResources is deprecated, use SDK CostAnalyzer instead")
+	slog.Warn("extractContainerResources is deprecated, use SDK CostAnalyzer instead")
 }
 
 // extractStorageResources is deprecated - use SDK CostAnalyzer directly
 func (ca *ConfigHubAnalyzer) extractStorageResources(vct map[string]interface{}, estimate *UnitCostEstimate) {
 	// This functionality is now handled by the SDK CostAnalyzer
-	ca.app.Logger.Printf("⚠️ extractStorageResources is deprecated, use SDK CostAnalyzer instead")
+	slog.Warn("extractStorageResources is deprecated, use SDK CostAnalyzer instead")
 }
 
 // calculateMonthlyCost is deprecated - use SDK CostAnalyzer directly
 // The SDK CostAnalyzer now handles all cost calculations internally
 func (ca *ConfigHubAnalyzer) calculateMonthlyCost(estimate *UnitCostEstimate) float64 {
-	ca.app.Logger.Printf("⚠️ calculateMonthlyCost is deprecated, SDK handles this internally")
+	slog.Warn("calculateMonthlyCost is deprecated, SDK handles this internally")
 	return 0.0
 }
 
@@ -1,16 +1,34 @@
-package main
+package costoptimizer
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/bulk"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/pricinghints"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	"github.com/monadic/devops-examples/pkg/unitdata"
+	"github.com/monadic/devops-examples/pkg/workloads"
+	sdk "github.com/monadic/devops-sdk"
 )
 
 // CostRecommendationApplier applies cost optimization recommendations via ConfigHub
 type CostRecommendationApplier struct {
 	optimizer *CostOptimizer
-	applied   map[string]*AppliedRecommendation // Track applied recommendations by resource name
+
+	mu      sync.RWMutex
+	applied map[string]*AppliedRecommendation // Track applied recommendations by resource name
 }
 
 // AppliedRecommendation tracks when a recommendation was applied
@@ -22,6 +40,8 @@ type AppliedRecommendation struct {
 	UnitSlug         string             `json:"unit_slug"`
 	Status           string             `json:"status"` // "applied", "failed", "rolled_back"
 	Error            string             `json:"error,omitempty"`
+	PreviousRevision int64              `json:"previous_revision,omitempty"` // head revision of the unit before it was applied
+	RolledBackAt     *time.Time         `json:"rolled_back_at,omitempty"`
 }
 
 // NewCostRecommendationApplier creates a new cost recommendation applier
@@ -32,52 +52,268 @@ func NewCostRecommendationApplier(optimizer *CostOptimizer) *CostRecommendationA
 	}
 }
 
-// ApplyRecommendation applies a single cost optimization recommendation via ConfigHub
+// ApplyRecommendation applies a single cost optimization recommendation via
+// ConfigHub: it patches the resource's unit, created from the live workload
+// the first time, pushing the change downstream, and applies the unit
 func (a *CostRecommendationApplier) ApplyRecommendation(ctx context.Context, rec CostRecommendation) error {
-	a.optimizer.app.Logger.Printf("🔧 Applying cost optimization for %s via ConfigHub", rec.Resource)
+	slog.Info("Applying cost optimization via ConfigHub", logging.Unit(rec.Resource))
 
 	// 1. Generate unit slug for this resource
 	unitSlug := a.getUnitSlug(rec)
 
-	// 2. Generate patch for optimization
-	patch, err := a.generateOptimizationPatch(rec)
+	// 2. Check the recommendation, and generate the ConfigHub command it shows
+	display, err := a.generateOptimizationPatch(rec)
+	if err != nil {
+		return a.failed(ctx, rec, "", unitSlug, fmt.Errorf("failed to generate patch: %w", err))
+	}
+	command := a.generateConfigHubCommand(unitSlug, display)
+
+	// 3. Find the unit, remembering the revision a rollback returns to
+	unit, err := a.workloadUnit(ctx, rec, unitSlug)
 	if err != nil {
-		return fmt.Errorf("failed to generate patch: %w", err)
+		return a.failed(ctx, rec, command, unitSlug, err)
 	}
 
-	// 3. Generate ConfigHub command for display
-	command := a.generateConfigHubCommand(unitSlug, patch)
+	// 4. Patch it with the recommended requests of its real containers
+	patch, err := a.unitPatch(unit, rec.Recommended)
+	if err != nil {
+		return a.failed(ctx, rec, command, unitSlug, fmt.Errorf("failed to generate patch: %w", err))
+	}
+	if err := a.patchAndApply(ctx, unit, patch); err != nil {
+		return a.failed(ctx, rec, command, unitSlug, err)
+	}
 
-	// 4. Log what would be done (for now)
-	a.optimizer.app.Logger.Printf("📝 Would update ConfigHub unit: %s", unitSlug)
-	a.optimizer.app.Logger.Printf("💻 Command: %s", command)
-	a.optimizer.app.Logger.Printf("📦 Patch: %v", patch)
+	a.recordSuccess(rec, command, unitSlug, unit.HeadRevisionNum)
+	a.optimizer.audit.Record(ctx, audit.OptimizationApplied, unitSlug, map[string]interface{}{
+		"recommendation":    rec,
+		"patch":             patch,
+		"previous_revision": unit.HeadRevisionNum,
+	}, nil)
 
-	//  5. TODO: Actually apply via ConfigHub (requires unit to exist first)
-	// For now, just record it as if it was applied
-	a.recordSuccess(rec, command, unitSlug)
+	slog.Info("Applied cost optimization",
+		logging.Unit(unitSlug), "monthly_savings", rec.MonthlySavings)
 
-	a.optimizer.app.Logger.Printf("✅ Recorded cost optimization for %s (saves $%.2f/month)",
-		rec.Resource, rec.MonthlySavings)
+	return nil
+}
+
+// failed records and audits a recommendation that could not be applied
+func (a *CostRecommendationApplier) failed(ctx context.Context, rec CostRecommendation, command, unitSlug string, err error) error {
+	a.recordFailure(rec, command, unitSlug, err)
+	a.optimizer.audit.Record(ctx, audit.OptimizationApplied, unitSlug, rec, err)
+	slog.Error("Failed to apply cost optimization", logging.Unit(unitSlug), logging.Err(err))
+	return err
+}
+
+// RollbackRecommendation restores the values the recommendation applied to
+// resource replaced: it patches the unit back to the current values of the
+// recommendation and applies it. Other changes made to the unit since are
+// kept; PreviousRevision is not restored.
+func (a *CostRecommendationApplier) RollbackRecommendation(ctx context.Context, resource string) error {
+	applied := a.GetAppliedRecommendation(resource)
+	if applied == nil || applied.Status != "applied" {
+		return fmt.Errorf("no recommendation applied to %s", resource)
+	}
+	rec := applied.Recommendation
+
+	unit, err := a.findUnit(ctx, applied.UnitSlug)
+	if err == nil && unit == nil {
+		err = fmt.Errorf("unit %s not found", applied.UnitSlug)
+	}
+	var patch map[string]interface{}
+	if err == nil {
+		patch, err = a.unitPatch(unit, rec.Current)
+		if err != nil {
+			err = fmt.Errorf("failed to generate rollback patch: %w", err)
+		}
+	}
+	if err == nil {
+		err = a.patchAndApply(ctx, unit, patch)
+	}
+	if err != nil {
+		a.optimizer.audit.Record(ctx, audit.OptimizationRolledBack, applied.UnitSlug, rec, err)
+		return err
+	}
 
+	a.mu.Lock()
+	if current, ok := a.applied[resource]; ok && current.Status == "applied" {
+		rolledBack := *current // readers may hold the old one
+		now := time.Now()
+		rolledBack.Status, rolledBack.RolledBackAt = "rolled_back", &now
+		a.applied[resource] = &rolledBack
+	}
+	a.mu.Unlock()
+	a.optimizer.audit.Record(ctx, audit.OptimizationRolledBack, applied.UnitSlug, map[string]interface{}{
+		"recommendation": rec,
+		"patch":          patch,
+	}, nil)
+	slog.Info("Rolled back cost optimization", logging.Unit(applied.UnitSlug))
 	return nil
 }
 
+// findUnit returns the unit with slug in the optimizer's space, nil when
+// there is none
+func (a *CostRecommendationApplier) findUnit(ctx context.Context, slug string) (*sdk.Unit, error) {
+	cub, space := a.optimizer.app.Cub, a.optimizer.spaceID
+	if cub == nil || space == uuid.Nil {
+		return nil, fmt.Errorf("ConfigHub is not configured")
+	}
+	where := fmt.Sprintf("Slug = '%s'", slug)
+	units, err := ratelimit.Call(ctx, a.optimizer.cubLimit, "ListUnits", space.String()+"/"+where, func() ([]*sdk.Unit, error) {
+		return cub.ListUnits(sdk.ListUnitsParams{SpaceID: space, Where: where})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list units: %w", err)
+	}
+	if len(units) == 0 {
+		return nil, nil
+	}
+	return units[0], nil
+}
+
+// workloadUnit returns the unit of the recommendation's workload or HPA,
+// creating it from the one last read from the cluster when there is none
+func (a *CostRecommendationApplier) workloadUnit(ctx context.Context, rec CostRecommendation, slug string) (*sdk.Unit, error) {
+	unit, err := a.findUnit(ctx, slug)
+	if err != nil || unit != nil {
+		return unit, err
+	}
+
+	resources, cluster, _ := a.optimizer.observed()
+	usage, ok := findUsage(resources, rec.Resource, rec.Namespace)
+	if name, autoscaler := strings.CutPrefix(rec.Resource, autoscalerResource); autoscaler {
+		usage, ok = ResourceUsage{Name: name, Namespace: rec.Namespace, Type: workloads.HorizontalPodAutoscaler}, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unit %s not found, and no workload %s in %s to create it from", slug, rec.Resource, rec.Namespace)
+	}
+	manifest, ok := cluster.Manifest(workloads.Key(usage.Namespace, usage.Type, usage.Name))
+	if !ok {
+		return nil, fmt.Errorf("unit %s not found, and %s %s cannot be managed by one", slug, usage.Type, usage.Name)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", usage.Name, err)
+	}
+
+	unit, err = tracing.Call(ctx, "confighub.CreateUnit", func() (*sdk.Unit, error) {
+		return a.optimizer.app.Cub.CreateUnit(a.optimizer.spaceID, sdk.CreateUnitRequest{
+			Slug:        slug,
+			DisplayName: fmt.Sprintf("%s %s/%s", usage.Type, usage.Namespace, usage.Name),
+			Data:        string(data),
+			Labels: map[string]string{
+				"type":      "workload",
+				"kind":      usage.Type,
+				"namespace": usage.Namespace,
+				"source":    "cost-optimizer",
+			},
+		})
+	}, tracing.UnitKey.String(slug))
+	a.optimizer.audit.Record(ctx, audit.UnitCreated, slug, map[string]string{"source": "cluster"}, err)
+	if err != nil {
+		return nil, fmt.Errorf("create unit %s: %w", slug, err)
+	}
+	slog.Info("Created unit for workload", logging.Unit(slug))
+	return unit, nil
+}
+
+// unitPatch returns the merge patch setting the unit's workload or HPA to
+// values, a recommendation's current or recommended ones
+func (a *CostRecommendationApplier) unitPatch(unit *sdk.Unit, values map[string]interface{}) (map[string]interface{}, error) {
+	manifest, err := unitdata.Parse(unit.Slug, unit.Data)
+	if err != nil {
+		return nil, err
+	}
+	return workloads.Patch(manifest.Object(), recommendedChange(values))
+}
+
+// recommendedChange reads values, a recommendation's current or
+// recommended ones, as a change of a workload or HPA
+func recommendedChange(values map[string]interface{}) workloads.Change {
+	change := workloads.Change{}
+	if cpu, ok := values["cpu"]; ok {
+		change.CPU = fmt.Sprintf("%v", cpu)
+	}
+	if mem, ok := values["memory"]; ok {
+		change.Memory = fmt.Sprintf("%v", mem)
+	}
+	for key, field := range map[string]**int32{
+		"replicas":             &change.Replicas,
+		"minReplicas":          &change.MinReplicas,
+		"maxReplicas":          &change.MaxReplicas,
+		"targetCPUUtilization": &change.TargetCPUUtilization,
+	} {
+		if n, ok := number(values[key]); ok {
+			*field = &n
+		}
+	}
+	return change
+}
+
+// number reads a count from a recommendation, whichever numeric type it was
+// decoded from JSON or built as
+func number(v interface{}) (int32, bool) {
+	switch n := reflect.ValueOf(v); n.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int32(n.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int32(n.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return int32(n.Float()), true
+	}
+	return 0, false
+}
+
+// patchAndApply patches the unit, pushing the change downstream, and
+// applies it, retrying what fails for a retryable reason
+func (a *CostRecommendationApplier) patchAndApply(ctx context.Context, unit *sdk.Unit, patch map[string]interface{}) error {
+	cub, space := a.optimizer.app.Cub, a.optimizer.spaceID
+	unitAttr := tracing.UnitKey.String(unit.Slug)
+	report := bulk.Run(ctx, "patch", []string{unit.Slug}, func(ctx context.Context, _ string) error {
+		return tracing.Do(ctx, "confighub.BulkPatchUnits", func(context.Context) error {
+			return cub.BulkPatchUnits(sdk.BulkPatchParams{
+				SpaceID: space,
+				Where:   fmt.Sprintf("UnitID = '%s'", unit.UnitID),
+				Patch:   patch,
+				Upgrade: true, // Push changes downstream
+			})
+		}, unitAttr)
+	})
+	if err := report.Err(); err != nil {
+		return err
+	}
+
+	// Apply the patched unit to Kubernetes
+	report = bulk.Run(ctx, "apply", []string{unit.Slug}, func(ctx context.Context, _ string) error {
+		return tracing.Do(ctx, "confighub.ApplyUnit", func(context.Context) error {
+			return cub.ApplyUnit(space, unit.UnitID)
+		}, unitAttr)
+	})
+	return report.Err()
+}
+
 // getUnitSlug generates a consistent unit slug for a resource
 func (a *CostRecommendationApplier) getUnitSlug(rec CostRecommendation) string {
-	// Remove "deployment/" prefix if present
-	resourceName := rec.Resource
-	if len(resourceName) > 11 && resourceName[:11] == "deployment/" {
-		resourceName = resourceName[11:]
+	// An HPA is often named like the workload it scales
+	if name, ok := strings.CutPrefix(rec.Resource, autoscalerResource); ok {
+		return fmt.Sprintf("%s-%s-hpa", rec.Namespace, name)
 	}
-	if len(resourceName) > 12 && resourceName[:12] == "statefulset/" {
-		resourceName = resourceName[12:]
+
+	// Remove the kind prefix, like "deployment/", if present
+	resourceName := rec.Resource
+	if _, name, ok := strings.Cut(resourceName, "/"); ok {
+		resourceName = name
 	}
 	return fmt.Sprintf("%s-%s", rec.Namespace, resourceName)
 }
 
-// generateOptimizationPatch creates a JSON patch for the recommendation
+// generateOptimizationPatch checks the recommended quantities and creates
+// the patch the dashboard shows; unitPatch builds the one applied from the
+// unit's real containers. An HPA's is its real one.
 func (a *CostRecommendationApplier) generateOptimizationPatch(rec CostRecommendation) (map[string]interface{}, error) {
+	if strings.HasPrefix(rec.Resource, autoscalerResource) {
+		return a.autoscalerPatch(rec)
+	}
+
 	// Extract recommended values
 	var cpuRequest, memoryRequest string
 
@@ -88,6 +324,17 @@ func (a *CostRecommendationApplier) generateOptimizationPatch(rec CostRecommenda
 		memoryRequest = fmt.Sprintf("%v", mem)
 	}
 
+	// Recommended values use the shared pricing-hint format, so the same
+	// quantities can be priced by cost-impact-monitor
+	for key, value := range map[string]string{pricinghints.KeyCPU: cpuRequest, pricinghints.KeyMemory: memoryRequest} {
+		if value == "" {
+			continue
+		}
+		if _, err := pricinghints.ParseQuantity(key, value); err != nil {
+			return nil, fmt.Errorf("invalid recommendation: %w", err)
+		}
+	}
+
 	// Build patch - simplified version
 	resources := make(map[string]interface{})
 	if cpuRequest != "" {
@@ -103,7 +350,7 @@ func (a *CostRecommendationApplier) generateOptimizationPatch(rec CostRecommenda
 				"spec": map[string]interface{}{
 					"containers": []map[string]interface{}{
 						{
-							"name": "app", // Generic - would need real container name
+							"name": "app", // unitPatch patches the unit's real containers
 							"resources": map[string]interface{}{
 								"requests": resources,
 							},
@@ -127,7 +374,9 @@ func (a *CostRecommendationApplier) generateConfigHubCommand(unitSlug string,
 }
 
 // recordSuccess records a successfully applied recommendation
-func (a *CostRecommendationApplier) recordSuccess(rec CostRecommendation, command, unitSlug string) {
+func (a *CostRecommendationApplier) recordSuccess(rec CostRecommendation, command, unitSlug string, previousRevision int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.applied[rec.Resource] = &AppliedRecommendation{
 		Resource:         rec.Resource,
 		Recommendation:   rec,
@@ -135,11 +384,14 @@ func (a *CostRecommendationApplier) recordSuccess(rec CostRecommendation, comman
 		ConfigHubCommand: command,
 		UnitSlug:         unitSlug,
 		Status:           "applied",
+		PreviousRevision: previousRevision,
 	}
 }
 
 // recordFailure records a failed recommendation application
 func (a *CostRecommendationApplier) recordFailure(rec CostRecommendation, command, unitSlug string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.applied[rec.Resource] = &AppliedRecommendation{
 		Resource:         rec.Resource,
 		Recommendation:   rec,
@@ -153,39 +405,37 @@ func (a *CostRecommendationApplier) recordFailure(rec CostRecommendation, comman
 
 // GetAppliedRecommendations returns all applied recommendations
 func (a *CostRecommendationApplier) GetAppliedRecommendations() map[string]*AppliedRecommendation {
-	return a.applied
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	applied := make(map[string]*AppliedRecommendation, len(a.applied))
+	for resource, rec := range a.applied {
+		applied[resource] = rec
+	}
+	return applied
 }
 
 // GetAppliedRecommendation returns a specific applied recommendation
 func (a *CostRecommendationApplier) GetAppliedRecommendation(resource string) *AppliedRecommendation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.applied[resource]
 }
 
 // IsApplied checks if a recommendation has been applied
 func (a *CostRecommendationApplier) IsApplied(resource string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	applied, exists := a.applied[resource]
 	return exists && applied.Status == "applied"
 }
 
-// ApplyRecommendationsAutomatically applies low-risk recommendations automatically
-func (a *CostRecommendationApplier) ApplyRecommendationsAutomatically(ctx context.Context,
-	recommendations []CostRecommendation) int {
-
-	applied := 0
-
-	for _, rec := range recommendations {
-		// Only auto-apply low-risk recommendations with meaningful savings
-		if rec.Risk == "low" && rec.MonthlySavings > 20 {
-			if err := a.ApplyRecommendation(ctx, rec); err != nil {
-				a.optimizer.app.Logger.Printf("⚠️  Failed to apply recommendation for %s: %v",
-					rec.Resource, err)
-				continue
-			}
-			applied++
-		}
+// optimizationAction is applying a recommendation for the policy to decide;
+// its savings lower the cost
+func optimizationAction(unit, risk string, monthlySavings float64) policy.Action {
+	return policy.Action{
+		App: "cost-optimizer", Kind: policy.Optimization, Target: unit,
+		Severity: strings.ToLower(risk), CostDelta: -monthlySavings,
 	}
-
-	return applied
 }
 
 // EnrichRecommendationsWithCommands adds ConfigHub commands to recommendations
@@ -196,7 +446,7 @@ func (a *CostRecommendationApplier) EnrichRecommendationsWithCommands(recommenda
 		unitSlug := a.getUnitSlug(rec)
 		patch, err := a.generateOptimizationPatch(rec)
 		if err != nil {
-			a.optimizer.app.Logger.Printf("⚠️  Failed to generate patch for %s: %v", rec.Resource, err)
+			slog.Warn("Failed to generate patch", logging.Unit(rec.Resource), logging.Err(err))
 			enriched[i] = rec
 			continue
 		}
@@ -207,7 +457,7 @@ func (a *CostRecommendationApplier) EnrichRecommendationsWithCommands(recommenda
 		rec.ConfigHubCommand = command
 
 		// Check if already applied
-		if applied := a.GetAppliedRecommendation(rec.Resource); applied != nil {
+		if applied := a.GetAppliedRecommendation(rec.Resource); applied != nil && applied.Status == "applied" {
 			rec.Applied = true
 			rec.AppliedAt = &applied.AppliedAt
 		}
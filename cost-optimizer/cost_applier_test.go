@@ -0,0 +1,47 @@
+package costoptimizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/workloads"
+)
+
+func TestRecommendedChange(t *testing.T) {
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"cpu": "250m", "memory": "256Mi", "replicas": 2}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   string // cpu/memory/replicas, - for unset
+	}{
+		{"decoded from JSON", decoded, "250m/256Mi/2"},
+		{"int replicas", map[string]interface{}{"replicas": 3}, "-/-/3"},
+		{"int32 replicas", map[string]interface{}{"cpu": "100m", "replicas": int32(1)}, "100m/-/1"},
+		{"uint replicas", map[string]interface{}{"replicas": uint(4)}, "-/-/4"},
+		{"replicas not a number", map[string]interface{}{"memory": "1Gi", "replicas": "two"}, "-/1Gi/-"},
+		{"none", map[string]interface{}{}, "-/-/-"},
+	}
+	show := func(change workloads.Change) string {
+		s := func(v string) string {
+			if v == "" {
+				return "-"
+			}
+			return v
+		}
+		replicas := "-"
+		if change.Replicas != nil {
+			replicas = fmt.Sprint(*change.Replicas)
+		}
+		return s(change.CPU) + "/" + s(change.Memory) + "/" + replicas
+	}
+	for _, tt := range tests {
+		if got := show(recommendedChange(tt.values)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,25 @@
-package main
+package costoptimizer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/approval"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/costhistory"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/prompts"
 )
 
 // Dashboard provides a web interface for cost optimization results
@@ -24,18 +38,32 @@ func NewDashboard(optimizer *CostOptimizer) *Dashboard {
 	}
 }
 
-// Start starts the dashboard web server
-func (d *Dashboard) Start() {
-	d.optimizer.app.Logger.Printf("🌐 Starting cost optimization dashboard on port %d", d.port)
+// Start serves the dashboard until ctx is done, then lets the requests in
+// flight finish
+func (d *Dashboard) Start(ctx context.Context) {
+	slog.Info("Starting cost optimization dashboard", "port", d.port)
 
 	http.HandleFunc("/", d.handleDashboard)
-	http.HandleFunc("/api/analysis", d.handleAPIAnalysis)
-	http.HandleFunc("/api/recommendations", d.handleAPIRecommendations)
+	v1 := api.New(http.DefaultServeMux, "cost-optimizer")
+	v1.HandleFunc("/analysis", d.handleAPIAnalysis,
+		api.Operation{Summary: "Latest cost analysis", Response: CostAnalysis{}})
+	v1.Handle("/recommendations/", d.recommendations(),
+		append([]api.Operation{{Path: "/recommendations", Summary: "Recommendations of the latest analysis", Response: []CostRecommendation{}}}, approval.ReviewOperations...)...)
+	v1.HandleFunc("/approvals", d.optimizer.approvals.ServeList, approval.ListOperation)
+	v1.HandleFunc("/history", d.optimizer.history.ServeHistory, costhistory.HistoryOperation)
+	v1.HandleFunc("/trends", d.optimizer.history.ServeTrends, costhistory.TrendsOperation)
+	v1.Handle("/flags", d.optimizer.flags, flags.Operations...)
+	v1.Handle("/prompts", d.optimizer.prompts, prompts.Operations...)
+	v1.Handle("/audit", d.optimizer.audit, audit.Operations...)
+	v1.Handle("/breakers", breaker.Handler(d.optimizer.cubBreaker, d.optimizer.claudeBreaker, d.optimizer.openCostBreaker), breaker.Operations...)
+	v1.Handle("/llm/usage", d.optimizer.llmClient, llm.Operations...)
 	http.HandleFunc("/static/", d.handleStatic)
 
 	addr := fmt.Sprintf(":%d", d.port)
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		d.optimizer.app.Logger.Printf("⚠️  Dashboard server failed: %v", err)
+	handler := d.optimizer.guard.Protect(d.optimizer.audit.Calls(http.DefaultServeMux), "/metrics", "/static/")
+	srv := &http.Server{Addr: addr, Handler: handler}
+	if err := lifecycle.Serve(ctx, srv, lifecycle.DefaultGrace); err != nil {
+		slog.Error("Dashboard server failed", logging.Err(err))
 	}
 }
 
@@ -44,14 +72,38 @@ func (d *Dashboard) UpdateAnalysis(analysis *CostAnalysis) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	d.latestAnalysis = analysis
-	d.optimizer.app.Logger.Printf("📊 Dashboard updated with analysis from %s", analysis.Timestamp.Format("15:04:05"))
+	slog.Debug("Dashboard updated", "analysis_time", analysis.Timestamp)
 }
 
-// handleDashboard serves the main dashboard HTML
-func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
+// LatestAnalysis returns the analysis shown, nil before the first one
+func (d *Dashboard) LatestAnalysis() *CostAnalysis {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.latestAnalysis
+}
+
+// reviewed returns the latest analysis with the current review state of its
+// recommendations, which changes between analyses
+func (d *Dashboard) reviewed() *CostAnalysis {
 	d.mutex.RLock()
 	analysis := d.latestAnalysis
 	d.mutex.RUnlock()
+	if analysis == nil {
+		return nil
+	}
+	copied := *analysis
+	copied.Recommendations = append([]CostRecommendation(nil), analysis.Recommendations...)
+	for i, rec := range copied.Recommendations {
+		if r, ok := d.optimizer.approvals.Get(rec.ID); ok {
+			copied.Recommendations[i].State = r.State
+		}
+	}
+	return &copied
+}
+
+// handleDashboard serves the main dashboard HTML
+func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	analysis := d.reviewed()
 
 	// Create dashboard HTML template
 	tmpl := `<!DOCTYPE html>
@@ -84,6 +136,12 @@ func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
         .rec-header { display: flex; justify-content: between; align-items: center; margin-bottom: 8px; }
         .rec-resource { font-weight: 600; }
         .rec-savings { color: #30a14e; font-weight: 600; }
+        .rec-state { padding: 2px 8px; border-radius: 10px; font-size: 0.75rem; font-weight: 600; background: #e1e4e8; color: #444; margin-left: 8px; }
+        .rec-state.approved, .rec-state.applied { background: #dbedff; color: #0366d6; }
+        .rec-state.verified { background: #d4edda; color: #155724; }
+        .rec-state.rejected, .rec-state.failed, .rec-state.rolled-back { background: #f8d7da; color: #721c24; }
+        .rec-actions { margin-top: 8px; }
+        .rec-actions button { padding: 4px 12px; margin-right: 8px; border-radius: 6px; border: 1px solid #d1d5da; background: white; cursor: pointer; }
         .rec-explanation { color: #666; font-size: 0.9rem; margin-bottom: 8px; }
         .rec-details { display: grid; grid-template-columns: 1fr 1fr; gap: 16px; font-size: 0.8rem; }
         .detail-group { }
@@ -103,6 +161,22 @@ func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
         setInterval(() => {
             window.location.reload();
         }, 30000);
+
+        // Approve, reject or roll back a recommendation; signed-in users
+        // review as themselves, others give a name once
+        async function review(id, action) {
+            let reviewer = localStorage.getItem('reviewer') || prompt('Your name, for the review');
+            if (!reviewer) return;
+            localStorage.setItem('reviewer', reviewer);
+            const note = prompt('Note (optional)') || '';
+            const resp = await fetch('/api/v1/recommendations/' + id + '/' + action, {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({reviewer: reviewer, note: note}),
+            });
+            if (!resp.ok) alert(await resp.text());
+            window.location.reload();
+        }
     </script>
 </head>
 <body>
@@ -165,9 +239,9 @@ func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
             {{if .Analysis.Recommendations}}
             <div class="recommendations">
                 {{range .Analysis.Recommendations}}
-                <div class="recommendation {{.Priority}}">
+                <div class="recommendation {{.Priority}}"{{if .ID}} id="rec-{{.ID}}"{{end}}>
                     <div class="rec-header">
-                        <div class="rec-resource">{{.Resource}}</div>
+                        <div class="rec-resource">{{.Resource}}{{if .State}}<span class="rec-state {{.State}}">{{.State}}</span>{{end}}</div>
                         <div class="rec-savings">Save ${{printf "%.2f" .MonthlySavings}}/month</div>
                     </div>
                     <div class="rec-explanation">{{.Explanation}}</div>
@@ -181,6 +255,16 @@ func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
                             <div>{{.Risk}}</div>
                         </div>
                     </div>
+                    {{if or (eq .State "pending") (eq .State "failed")}}
+                    <div class="rec-actions">
+                        <button onclick="review('{{.ID}}', 'approve')">✅ Approve</button>
+                        <button onclick="review('{{.ID}}', 'reject')">❌ Reject</button>
+                    </div>
+                    {{else if or (eq .State "applied") (eq .State "verified")}}
+                    <div class="rec-actions">
+                        <button onclick="review('{{.ID}}', 'rollback')">↩️ Roll back</button>
+                    </div>
+                    {{end}}
                 </div>
                 {{end}}
             </div>
@@ -350,7 +434,9 @@ func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
 
         <div class="refresh-info">
             Dashboard auto-refreshes every 30 seconds |
-            <a href="/api/analysis" target="_blank">Raw JSON API</a> |
+            <a href="/api/v1/analysis" target="_blank">Raw JSON API</a> |
+            <a href="/api/v1/trends" target="_blank">Cost trend</a> |
+            <a href="/api/v1/approvals" target="_blank">Approvals</a> |
             Health: <a href=":8080/health" target="_blank">:8080/health</a>
         </div>
     </div>
@@ -372,7 +458,7 @@ func (d *Dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html")
 	if err := t.Execute(w, data); err != nil {
-		d.optimizer.app.Logger.Printf("Template execution error: %v", err)
+		slog.Error("Template execution failed", logging.Err(err))
 		http.Error(w, "Template execution error", http.StatusInternalServerError)
 	}
 }
@@ -399,9 +485,7 @@ func (d *Dashboard) handleAPIAnalysis(w http.ResponseWriter, r *http.Request) {
 
 // handleAPIRecommendations serves just the recommendations as JSON
 func (d *Dashboard) handleAPIRecommendations(w http.ResponseWriter, r *http.Request) {
-	d.mutex.RLock()
-	analysis := d.latestAnalysis
-	d.mutex.RUnlock()
+	analysis := d.reviewed()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -414,6 +498,18 @@ func (d *Dashboard) handleAPIRecommendations(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(analysis.Recommendations)
 }
 
+// recommendations serves the latest recommendations at /recommendations and
+// their review below it
+func (d *Dashboard) recommendations() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Trim(strings.TrimPrefix(r.URL.Path, api.Prefix+"/recommendations"), "/") == "" {
+			d.handleAPIRecommendations(w, r)
+			return
+		}
+		d.optimizer.approvals.ServeReview(w, r)
+	})
+}
+
 // handleStatic serves static files (placeholder for future CSS/JS)
 func (d *Dashboard) handleStatic(w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
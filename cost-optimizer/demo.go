@@ -1,4 +1,4 @@
-package main
+package costoptimizer
 
 import (
 	"fmt"
@@ -112,7 +112,7 @@ func (d *CostOptimizerDemo) run() {
 	fmt.Println("🔗 Endpoints:")
 	fmt.Println("  Dashboard:  http://localhost:8081")
 	fmt.Println("  Health:     http://localhost:8080/health")
-	fmt.Println("  API:        http://localhost:8081/api/analysis")
+	fmt.Println("  API:        http://localhost:8081/api/v1/analysis")
 }
 
 func (d *CostOptimizerDemo) mockResourceUsage() []ResourceUsage {
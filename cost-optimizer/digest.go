@@ -0,0 +1,133 @@
+package costoptimizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/approval"
+	"github.com/monadic/devops-examples/pkg/notify"
+)
+
+// recommendationKind is the notification kind of the recommendation digest
+const recommendationKind = "recommendation"
+
+// Most recommendations a digest lists, and links to
+const (
+	digestListed = 10
+	digestLinked = 5
+)
+
+// loadNotifier builds the notifier of notify_config, adding the Slack and
+// Teams webhooks of slack_webhook_url and teams_webhook_url as channels
+// for the recommendation digest
+func loadNotifier(cfg Config) (*notify.Notifier, error) {
+	nc, err := notify.LoadConfig(cfg.NotifyConfig)
+	if err != nil {
+		return nil, err
+	}
+	var channels []string
+	for _, hook := range []struct{ name, kind, url string }{
+		{"slack_webhook_url", "slack", cfg.SlackWebhookURL},
+		{"teams_webhook_url", "teams", cfg.TeamsWebhookURL},
+	} {
+		if hook.url == "" {
+			continue
+		}
+		nc.Channels = append(nc.Channels, notify.ChannelConfig{Name: hook.name, Type: hook.kind, URL: hook.url})
+		channels = append(channels, hook.name)
+	}
+	if len(channels) > 0 {
+		nc.Routes = append(nc.Routes, notify.RouteConfig{
+			Apps: []string{"cost-optimizer"}, Kinds: []string{recommendationKind}, Channels: channels,
+		})
+	}
+	return nc.Build()
+}
+
+// recommendationDigest sums up the recommendations saving at least
+// minSavings a month that still wait to be applied, the largest first,
+// each with the cub command applying it. Its dedup key depends on which
+// recommendations it lists and their savings, not on their order. With a
+// dashboard URL it links to the dashboard and to the largest
+// recommendations on it. It reports false when there are none.
+func recommendationDigest(recommendations []CostRecommendation, minSavings float64, dashboard string) (notify.Notification, bool) {
+	var listed []CostRecommendation
+	for _, rec := range recommendations {
+		if rec.MonthlySavings < minSavings || rec.Applied || decided(rec.State) {
+			continue
+		}
+		listed = append(listed, rec)
+	}
+	if len(listed) == 0 {
+		return notify.Notification{}, false
+	}
+	sort.Slice(listed, func(i, j int) bool {
+		a, b := listed[i], listed[j]
+		if a.MonthlySavings != b.MonthlySavings {
+			return a.MonthlySavings > b.MonthlySavings
+		}
+		return a.Namespace+"/"+a.Resource+"/"+a.Type < b.Namespace+"/"+b.Resource+"/"+b.Type
+	})
+
+	total := 0.0
+	keys := make([]string, 0, len(listed))
+	for _, rec := range listed {
+		total += rec.MonthlySavings
+		keys = append(keys, fmt.Sprintf("%s/%s/%s/%.0f", rec.Namespace, rec.Resource, rec.Type, rec.MonthlySavings))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(keys, ",")))
+
+	var summary strings.Builder
+	for i, rec := range listed {
+		if i == digestListed {
+			fmt.Fprintf(&summary, "…and %d more\n", len(listed)-digestListed)
+			break
+		}
+		fmt.Fprintf(&summary, "• $%.2f/month: %s in %s (%s, %s risk)\n", rec.MonthlySavings, rec.Resource, rec.Namespace, rec.Type, rec.Risk)
+		if rec.ConfigHubCommand != "" {
+			fmt.Fprintf(&summary, "  `%s`\n", rec.ConfigHubCommand)
+		}
+	}
+
+	title := fmt.Sprintf("%d recommendations save $%.2f/month", len(listed), total)
+	if len(listed) == 1 {
+		title = fmt.Sprintf("1 recommendation saves $%.2f/month", total)
+	}
+	note := notify.Notification{
+		App:      "cost-optimizer",
+		Kind:     recommendationKind,
+		Severity: notify.Warning,
+		Title:    title,
+		Summary:  strings.TrimSuffix(summary.String(), "\n"),
+		Fields: map[string]string{
+			"recommendations": fmt.Sprint(len(listed)),
+			"monthly_savings": fmt.Sprintf("$%.2f", total),
+		},
+		DedupKey: "recommendations/" + hex.EncodeToString(sum[:8]),
+	}
+	if dashboard = strings.TrimSuffix(dashboard, "/"); dashboard != "" {
+		note.URL = dashboard
+		for _, rec := range listed {
+			if len(note.Links) == digestLinked {
+				break
+			}
+			if rec.ID != "" {
+				note.Links = append(note.Links, notify.Link{Text: "Review " + rec.Resource, URL: dashboard + "/#rec-" + rec.ID})
+			}
+		}
+	}
+	return note, true
+}
+
+// decided tells whether a recommendation in state was reviewed or applied
+// already, so a digest need not ask for it
+func decided(state string) bool {
+	switch state {
+	case approval.Rejected, approval.Applied, approval.Verified, approval.RolledBack:
+		return true
+	}
+	return false
+}
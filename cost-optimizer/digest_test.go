@@ -0,0 +1,161 @@
+package costoptimizer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/approval"
+)
+
+func digestRec(name string, savings float64) CostRecommendation {
+	return CostRecommendation{
+		Resource: "deployment/" + name, Namespace: "shop", Type: "rightsize", Risk: "low", MonthlySavings: savings,
+		ID: name, ConfigHubCommand: "cub unit update shop-" + name,
+	}
+}
+
+// listedResources returns the resources a digest lists, in order
+func listedResources(summary string) []string {
+	var resources []string
+	for _, line := range strings.Split(summary, "\n") {
+		if rest, ok := strings.CutPrefix(line, "• "); ok {
+			_, rest, _ = strings.Cut(rest, ": ")
+			resource, _, _ := strings.Cut(rest, " in ")
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+func TestRecommendationDigest(t *testing.T) {
+	applied := digestRec("applied", 200)
+	applied.Applied = true
+	state := func(rec CostRecommendation, s string) CostRecommendation {
+		rec.State = s
+		return rec
+	}
+
+	tests := []struct {
+		name    string
+		recs    []CostRecommendation
+		want    []string
+		title   string
+		savings string
+	}{
+		{
+			name: "threshold, decided and applied",
+			recs: []CostRecommendation{
+				digestRec("below", 40),
+				digestRec("at", 50),
+				applied,
+				state(digestRec("rejected", 300), approval.Rejected),
+				state(digestRec("verified", 500), approval.Verified),
+				state(digestRec("rolled-back", 400), approval.RolledBack),
+				state(digestRec("pending", 120), approval.Pending),
+				state(digestRec("approved", 80), approval.Approved),
+			},
+			want:    []string{"deployment/pending", "deployment/approved", "deployment/at"},
+			title:   "3 recommendations save $250.00/month",
+			savings: "$250.00",
+		},
+		{
+			name:    "one",
+			recs:    []CostRecommendation{digestRec("below", 10), digestRec("api", 75.5)},
+			want:    []string{"deployment/api"},
+			title:   "1 recommendation saves $75.50/month",
+			savings: "$75.50",
+		},
+		{
+			name: "none",
+			recs: []CostRecommendation{digestRec("below", 10), applied},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			note, ok := recommendationDigest(tt.recs, 50, "")
+			if ok != (tt.want != nil) {
+				t.Fatalf("ok = %v, want %v", ok, tt.want != nil)
+			}
+			if !ok {
+				return
+			}
+			if got := listedResources(note.Summary); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listed %q, want %q", got, tt.want)
+			}
+			if note.Title != tt.title {
+				t.Errorf("title %q, want %q", note.Title, tt.title)
+			}
+			if got := note.Fields["monthly_savings"]; got != tt.savings {
+				t.Errorf("monthly_savings %q, want %q", got, tt.savings)
+			}
+			if note.Kind != recommendationKind || note.URL != "" || len(note.Links) != 0 {
+				t.Errorf("kind %q, url %q, links %v without a dashboard", note.Kind, note.URL, note.Links)
+			}
+			if !strings.Contains(note.Summary, "`cub unit update shop-"+strings.TrimPrefix(tt.want[0], "deployment/")+"`") {
+				t.Errorf("summary lacks the cub command:\n%s", note.Summary)
+			}
+		})
+	}
+}
+
+func TestRecommendationDigestCaps(t *testing.T) {
+	var recs []CostRecommendation
+	for i := 1; i <= digestListed+2; i++ {
+		recs = append(recs, digestRec(fmt.Sprintf("app-%02d", i), float64(100*i)))
+	}
+	recs[len(recs)-2].ID = "" // not filed for review, so not linked
+
+	note, ok := recommendationDigest(recs, 50, "https://cost.example.com/")
+	if !ok {
+		t.Fatal("no digest")
+	}
+	if got := listedResources(note.Summary); len(got) != digestListed || got[0] != "deployment/app-12" {
+		t.Errorf("listed %q, want the %d largest from deployment/app-12", got, digestListed)
+	}
+	if !strings.HasSuffix(note.Summary, "…and 2 more") {
+		t.Errorf("summary does not end with the rest:\n%s", note.Summary)
+	}
+	if note.Fields["recommendations"] != "12" {
+		t.Errorf("recommendations %q, want 12", note.Fields["recommendations"])
+	}
+
+	if note.URL != "https://cost.example.com" {
+		t.Errorf("url %q", note.URL)
+	}
+	var links []string
+	for _, link := range note.Links {
+		links = append(links, link.Text+" "+link.URL)
+	}
+	want := []string{
+		"Review deployment/app-12 https://cost.example.com/#rec-app-12",
+		"Review deployment/app-10 https://cost.example.com/#rec-app-10",
+		"Review deployment/app-09 https://cost.example.com/#rec-app-09",
+		"Review deployment/app-08 https://cost.example.com/#rec-app-08",
+		"Review deployment/app-07 https://cost.example.com/#rec-app-07",
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("links %q\nwant %q", links, want)
+	}
+}
+
+func TestRecommendationDigestDedupKey(t *testing.T) {
+	recs := []CostRecommendation{digestRec("api", 120), digestRec("web", 80), digestRec("worker", 80)}
+	reordered := []CostRecommendation{recs[2], recs[0], recs[1]}
+	changed := []CostRecommendation{digestRec("api", 150), recs[1], recs[2]}
+
+	key := func(recs []CostRecommendation) string {
+		note, ok := recommendationDigest(recs, 50, "")
+		if !ok {
+			t.Fatal("no digest")
+		}
+		return note.DedupKey
+	}
+	if got, want := key(reordered), key(recs); got != want {
+		t.Errorf("reordered key %q, want %q", got, want)
+	}
+	if key(changed) == key(recs) {
+		t.Errorf("key %q unchanged by new savings", key(changed))
+	}
+}
@@ -0,0 +1,55 @@
+package costoptimizer
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/costhistory"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newCostHistory returns the optimizer's cost history, kept in the
+// cost-history-cost-optimizer unit of the space with slug space, else in a
+// file in dir; in memory only without either
+func newCostHistory(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space, dir string, retention time.Duration) (*costhistory.History, error) {
+	if cub == nil || space == "" {
+		if dir == "" {
+			return costhistory.New("cost-optimizer", nil, nil, retention), nil
+		}
+		writer, reader, err := costhistory.Dir(dir)
+		if err != nil {
+			return nil, err
+		}
+		return costhistory.New("cost-optimizer", writer, reader, retention), nil
+	}
+	store := unitStore{cub: cub, limit: limit, space: space}
+	return costhistory.New("cost-optimizer", store.write, store.read, retention), nil
+}
+
+// recordHistory adds an analysis to the cost history; a failed write is
+// logged, the point stays in memory
+func (c *CostOptimizer) recordHistory(ctx context.Context, analysis *CostAnalysis) {
+	open := 0
+	for _, r := range analysis.Recommendations {
+		if !r.Applied {
+			open++
+		}
+	}
+	applied := 0
+	if c.applier != nil {
+		applied = len(c.applier.GetAppliedRecommendations())
+	}
+	err := c.history.Record(ctx, costhistory.Point{
+		Time:             analysis.Timestamp,
+		MonthlyCost:      analysis.TotalMonthlyCost,
+		PotentialSavings: analysis.PotentialSavings,
+		Recommendations:  open,
+		Applied:          applied,
+	})
+	if err != nil {
+		slog.Warn("Failed to record cost history", logging.Err(err))
+	}
+}
@@ -0,0 +1,22 @@
+package costoptimizer
+
+import (
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newJobStore returns the writer a job stores its result with, as units of
+// the space with slug space; nil without one, so the result is only printed
+func newJobStore(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) runmode.Writer {
+	if cub == nil {
+		return nil
+	}
+	return runmode.Store(limit, space, cub.ListSpaces,
+		func(s *sdk.Space) (string, uuid.UUID) { return s.Slug, s.SpaceID },
+		func(id uuid.UUID, slug string, labels map[string]string, data string) error {
+			_, err := cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+			return err
+		})
+}
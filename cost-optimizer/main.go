@@ -1,18 +1,44 @@
-package main
+package costoptimizer
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/approval"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/costhistory"
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/notify"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/prompts"
+	"github.com/monadic/devops-examples/pkg/shared"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	"github.com/monadic/devops-examples/pkg/workloads"
 	sdk "github.com/monadic/devops-sdk"
 	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 )
@@ -24,12 +50,37 @@ type CostOptimizer struct {
 	criticalSetID uuid.UUID
 	dashboard     *Dashboard
 	applier       *CostRecommendationApplier
+	config        Config
+	notifier      *notify.Notifier
+	claude        claudeClient // nil without an API key, unless claude_mode is stub
+	llmClient     *llm.Client // usage and budget of claude; nil unless calling the API
+	flags         *flags.Set
+	prompts       *prompts.Set // AI prompts, replaceable from ConfigHub
+	cubLimit      *ratelimit.Limiter // throttles and coalesces ConfigHub reads
+	audit         *audit.Log
+	policy        *policy.Policy // which recommendations are applied on their own
+	approvals     *approval.Board // recommendations under review, applied once approved
+	history       *costhistory.History // cost of each analysis, for /api/v1/history and /api/v1/trends
+	metrics       *metrics.Registry
+	pusher        *metrics.Pusher // Datadog and New Relic pushes; nil without a sinks file
+	bus           *events.Bus // cost.recommendation.created on NATS; nil without nats_url
+	guard         *auth.Guard // OIDC login on the dashboard; nil leaves it open
+	// Circuit breakers for the external services
+	cubBreaker      *breaker.Breaker
+	claudeBreaker   *breaker.Breaker
+	openCostBreaker *breaker.Breaker
 	// SDK analyzers
 	costAnalyzer      *sdk.CostAnalyzer
 	wasteAnalyzer     *sdk.WasteAnalyzer
 	optimizationEngine *sdk.OptimizationEngine
 	// Current resources for dashboard
 	resources     []ResourceUsage
+	pricing       costmodel.Pricing // rates of cloud_provider in its region
+	cluster       clusterInfo       // detected at startup, picks the rates with cloud_provider auto
+	nodes         int32             // counted by the last gatherResourceUsage
+	workloads     workloads.Cluster // listed by the last analysis, for the restarts of applied recommendations
+	measured      bool              // whether the utilization of resources is metrics-server's
+	observedMu    sync.RWMutex      // guards resources, workloads and measured, read by approvals between analyses
 }
 
 // CostAnalysis represents the complete cost analysis for the dashboard
@@ -66,6 +117,9 @@ type CostRecommendation struct {
 	ConfigHubCommand string                `json:"confighub_command"` // Specific cub command
 	Applied         bool                   `json:"applied"` // Has this been applied?
 	AppliedAt       *time.Time             `json:"applied_at,omitempty"` // When was it applied?
+	// Review of the recommendation, see pkg/approval
+	ID    string `json:"id,omitempty"`
+	State string `json:"state,omitempty"` // "pending", "approved", "rejected", "applied", "failed", "verified", "rolled-back"
 }
 
 type ResourceBreakdown struct {
@@ -104,6 +158,11 @@ type ResourceUsage struct {
 	MemUtilization float64 `json:"memory_utilization_percent"`
 	MonthlyCost    float64 `json:"monthly_cost_estimate"`
 
+	// Persistent volume claims, and the share of the month a CronJob's pods
+	// run; unset for workloads that always run
+	StorageRequested int64   `json:"storage_requested_bytes,omitempty"`
+	DutyCycle        float64 `json:"duty_cycle,omitempty"`
+
 	// OpenCost fields
 	CPUCost     float64 `json:"cpu_cost_usd,omitempty"`
 	MemoryCost  float64 `json:"memory_cost_usd,omitempty"`
@@ -124,53 +183,192 @@ type DataSourceInfo struct {
 	LastUpdated      time.Time `json:"last_updated"`
 }
 
-func main() {
+// Main runs the cost optimizer, once with --mode=job, or the demo when the
+// first argument is "demo". It backs cmd/cost-optimizer and "devops-apps
+// cost".
+func Main() {
 	// Check for demo mode
 	if len(os.Args) > 1 && os.Args[1] == "demo" {
 		runDemo()
 		return
 	}
 
+	logging.Setup("cost-optimizer")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "cost-optimizer", "2.0.0")
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
 	optimizer, err := NewCostOptimizer()
 	if err != nil {
-		log.Fatalf("Failed to initialize cost optimizer: %v", err)
+		logging.Fatal("Failed to initialize cost optimizer", logging.Err(err))
 	}
 
-	log.Println("🚀 Cost Optimizer started using DevOps SDK")
+	slog.Info("Cost Optimizer started using DevOps SDK", logging.Space(optimizer.spaceID.String()))
 
-	// Start dashboard server
-	go optimizer.dashboard.Start()
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	// Continue the cost history from before a restart
+	if err := optimizer.history.Load(ctx); err != nil {
+		slog.Warn("Failed to load cost history", logging.Err(err))
+	}
+	// and the recommendations under review
+	if err := optimizer.approvals.Load(ctx); err != nil {
+		slog.Warn("Failed to load recommendations under review", logging.Err(err))
+	}
+	if runmode.IsJob() {
+		analyze := func(context.Context) error { return optimizer.optimizeCosts() }
+		store := newJobStore(optimizer.app.Cub, optimizer.cubLimit, runmode.Space())
+		err := runmode.Once(ctx, "cost-optimizer", analyze, optimizer.dashboard.LatestAnalysis, os.Stdout, store)
+		optimizer.pusher.Push(ctx)
+		if err != nil {
+			logging.Fatal("Cost optimization failed", logging.Err(err))
+		}
+		return
+	}
+
+	// Pick up the recommendations applied before a restart
+	checkpoints := newCheckpointer(optimizer.app.Cub, optimizer.cubLimit, optimizer.config.CheckpointSpace)
+	optimizer.applier.registerCheckpoint(checkpoints)
+	if err := checkpoints.Restore(ctx); err != nil {
+		slog.Warn("Failed to restore checkpoint", logging.Err(err))
+	}
 
-	// Run in event-driven mode using our enhanced SDK
+	// Start dashboard server and follow feature-flag and prompt overrides
+	group := lifecycle.New(ctx)
+	group.Go(func(ctx context.Context) error {
+		optimizer.dashboard.Start(ctx)
+		return nil
+	})
+	go optimizer.flags.Watch(group.Context(), optimizer.config.FlagsRefresh)
+	go optimizer.prompts.Watch(group.Context(), optimizer.config.PromptsRefresh)
+	go optimizer.approvals.Start(group.Context(), time.Minute, optimizer.latestCost)
+	group.Go(func(ctx context.Context) error {
+		optimizer.pusher.Run(ctx) // flushes on shutdown before the group is done
+		return nil
+	})
+	group.Go(func(ctx context.Context) error {
+		checkpoints.Run(ctx, optimizer.config.CheckpointInterval) // saves once more on shutdown
+		return nil
+	})
+
+	// Run in event-driven mode using our enhanced SDK, then let the
+	// dashboard finish its requests
 	err = optimizer.app.RunWithInformers(func() error {
 		return optimizer.optimizeCosts()
 	})
+	stop()
+	group.Wait()
 	if err != nil {
-		log.Fatalf("Cost optimization failed: %v", err)
+		logging.Fatal("Cost optimization failed", logging.Err(err))
 	}
 }
 
 // NewCostOptimizer creates a new cost optimizer using our enhanced SDK
 func NewCostOptimizer() (*CostOptimizer, error) {
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
 	// Initialize DevOps app with our enhanced SDK
 	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
-		Name:        "cost-optimizer",
-		Version:     "2.0.0",
-		Description: "AI-powered Kubernetes cost optimization using ConfigHub",
-		RunInterval: 10 * time.Minute, // Fallback interval
-		HealthPort:  8080,
+		Name:         "cost-optimizer",
+		Version:      "2.0.0",
+		Description:  "AI-powered Kubernetes cost optimization using ConfigHub",
+		RunInterval:  cfg.RunInterval, // Fallback interval
+		HealthPort:   shared.HealthPort("cost-optimizer", 8080),
+		ClaudeAPIKey: cfg.ClaudeAPIKey,
+		CubToken:     cfg.CubToken,
+		CubBaseURL:   cfg.CubAPIURL,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create DevOps app: %w", err)
 	}
+	app.Logger = logging.StdLogger(slog.Default())
+	effective.Log(logging.Printf(slog.Default()))
+	go effective.WatchSecrets(context.Background(), 30*time.Second, config.RestartOnRotation)
 
-	// Enable Claude debug logging for cost analysis
-	if app.Claude != nil {
-		app.Claude.EnableDebugLogging()
+	// Slack, webhook and PagerDuty routing shared with the other apps, and
+	// the webhooks of the recommendation digest
+	notifier, err := loadNotifier(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("load notify config: %w", err)
+	}
+	pol, err := policy.Load(cfg.PolicyConfig)
+	if err != nil {
+		return nil, fmt.Errorf("load policy: %w", err)
 	}
 
 	optimizer := &CostOptimizer{
-		app: app,
+		app:             app,
+		config:          cfg,
+		notifier:        notifier,
+		policy:          pol,
+		cubBreaker: shared.Value("confighub-breaker", func() *breaker.Breaker {
+			return breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+		}),
+		claudeBreaker:   breaker.New("claude", cfg.BreakerThreshold, cfg.BreakerCooldown),
+		openCostBreaker: breaker.New("opencost", cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+	optimizer.cluster = detectCluster(context.Background(), app)
+	optimizer.pricing, _ = cfg.pricing(optimizer.cluster) // checked by Validate
+	slog.Info("Pricing workloads", "service", optimizer.pricing.Service, "region", optimizer.pricing.Region, "cluster_type", optimizer.cluster.Type)
+	optimizer.cubLimit = shared.Value("confighub-limiter", func() *ratelimit.Limiter {
+		return ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(optimizer.cubBreaker)
+	})
+	if key := cfg.llmAPIKey(); (key != "" || !cfg.llmConfig().NeedsKey()) && cfg.ClaudeMode != claudestub.ModeStub {
+		optimizer.llmClient = llm.New(key, cfg.llmConfig())
+	}
+	optimizer.claude = optimizer.newClaudeClient()
+	optimizer.flags = flags.New("cost-optimizer", map[string]bool{flags.AutoApply: cfg.AutoApply}, flagsSource(app.Cub, optimizer.cubLimit, cfg.FlagsSpace))
+	optimizer.prompts = prompts.New([]prompts.Prompt{costRecommendationsPrompt, costInsightsPrompt}, promptsSource(app.Cub, optimizer.cubLimit, cfg.PromptsSpace))
+	optimizer.audit = newAuditLog(app.Cub, optimizer.cubLimit, cfg.AuditSpace)
+	if optimizer.approvals, err = newApprovals(app.Cub, optimizer.cubLimit, cfg.PendingSpace, cfg.PendingDir); err != nil {
+		return nil, fmt.Errorf("set up approvals: %w", err)
+	}
+	optimizer.approvals.VerifyWithin = cfg.VerifyWithin
+	optimizer.approvals.Thresholds = approval.Thresholds{
+		CPUUtilization:    cfg.RollbackCPUUtilization,
+		MemoryUtilization: cfg.RollbackMemoryUtilization,
+		Restarts:          cfg.RollbackRestarts,
+	}
+	if optimizer.history, err = newCostHistory(app.Cub, optimizer.cubLimit, cfg.HistorySpace, cfg.HistoryDir, cfg.HistoryRetention); err != nil {
+		return nil, fmt.Errorf("set up cost history: %w", err)
+	}
+	optimizer.metrics = metrics.Setup("cost-optimizer", app.Version, cfg.ClusterName)
+	if cfg.Pprof {
+		metrics.Profile(http.DefaultServeMux)
+	}
+	optimizer.metrics.Collect(metrics.Limiter(optimizer.cubLimit))
+	optimizer.metrics.Collect(metrics.ListCache(listcache.Install()))
+	optimizer.metrics.Collect(metrics.Breakers(optimizer.cubBreaker, optimizer.claudeBreaker, optimizer.openCostBreaker))
+	optimizer.metrics.Collect(metrics.LLM(optimizer.llmClient))
+	optimizer.metrics.Collect(optimizer.collect)
+	if optimizer.pusher, err = metrics.LoadSinks(cfg.MetricsSinks, effective.ReadSecretFile, optimizer.metrics); err != nil {
+		return nil, fmt.Errorf("load metrics sinks config: %w", err)
+	}
+	// Without ConfigHub the optimizer runs in local mode, without
+	// metrics-server on simulated utilization, without OpenCost on list prices
+	checker := health.New()
+	checker.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	checker.Register("confighub", health.Optional, health.Breaker(optimizer.cubBreaker))
+	checker.Register("claude", health.Optional, health.Breaker(optimizer.claudeBreaker))
+	checker.Register("metrics-server", health.Optional, health.MetricsServer(app.K8s.Clientset.Discovery()))
+	if cfg.EnableOpenCost {
+		checker.Register("opencost", health.Optional, health.Breaker(optimizer.openCostBreaker))
+	}
+	checker.Mount(http.DefaultServeMux)
+	if optimizer.bus, err = events.Connect(cfg.NATSURL, cfg.NATSToken, cfg.NATSSubjectPrefix, "cost-optimizer"); err != nil {
+		return nil, fmt.Errorf("connect event bus: %w", err)
+	}
+	if optimizer.guard, err = auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile); err != nil {
+		return nil, fmt.Errorf("load auth config: %w", err)
+	}
+	if cfg.ClaudeMode == claudestub.ModeStub {
+		slog.Info("Claude stub mode, AI recommendations are canned")
 	}
 
 	// Initialize ConfigHub space and sets
@@ -184,7 +382,7 @@ func NewCostOptimizer() (*CostOptimizer, error) {
 		optimizer.wasteAnalyzer = sdk.NewWasteAnalyzer(app, optimizer.spaceID)
 		optimizer.optimizationEngine = sdk.NewOptimizationEngine(app, optimizer.spaceID)
 	} else {
-		app.Logger.Println("⚠️  Running in Kubernetes-only mode (no ConfigHub)")
+		slog.Warn("Running in Kubernetes-only mode (no ConfigHub)")
 	}
 
 	// Initialize dashboard
@@ -192,30 +390,60 @@ func NewCostOptimizer() (*CostOptimizer, error) {
 
 	// Initialize cost recommendation applier
 	optimizer.applier = NewCostRecommendationApplier(optimizer)
+	optimizer.applier.handleApprovals()
 
 	return optimizer, nil
 }
 
+// collect exports the latest analysis's cost and savings, in total and per
+// workload, for /metrics and the metrics sinks
+func (c *CostOptimizer) collect(emit metrics.Emit) {
+	if c.dashboard == nil {
+		return
+	}
+	analysis := c.dashboard.LatestAnalysis()
+	if analysis == nil {
+		return
+	}
+	labels := metrics.Labels{"space": analysis.ConfigHubSpace}
+	emit("cost_monthly_dollars", "Estimated monthly cost of the analyzed workloads.", "gauge", labels, analysis.TotalMonthlyCost)
+	emit("cost_potential_savings_dollars", "Monthly savings of the open recommendations.", "gauge", labels, analysis.PotentialSavings)
+	emit("cost_savings_ratio", "Potential savings as a share of the monthly cost.", "gauge", labels, analysis.SavingsPercentage/100)
+	byPriority := map[string]int{"high": 0, "medium": 0, "low": 0}
+	for _, r := range analysis.Recommendations {
+		if !r.Applied {
+			byPriority[r.Priority]++
+		}
+	}
+	for priority, n := range byPriority {
+		emit("cost_recommendations", "Recommendations not applied yet, by priority.", "gauge", metrics.Labels{"space": analysis.ConfigHubSpace, "priority": priority}, float64(n))
+	}
+	for _, usage := range analysis.ResourceDetails {
+		emit("cost_workload_monthly_dollars", "Estimated monthly cost of a workload.", "gauge", metrics.Labels{
+			"space":     analysis.ConfigHubSpace,
+			"namespace": usage.Namespace,
+			"kind":      usage.Type,
+			"name":      usage.Name,
+		}, usage.MonthlyCost)
+	}
+}
+
 // initializeConfigHub sets up ConfigHub space and filters for cost optimization
 func (c *CostOptimizer) initializeConfigHub() error {
 	if c.app.Cub == nil {
-		c.app.Logger.Println("⚠️  ConfigHub not configured - running in local mode")
+		slog.Warn("ConfigHub not configured, running in local mode")
 		return nil
 	}
 
-	// Check if CONFIGHUB_SPACE_ID is provided
-	spaceIDStr := os.Getenv("CONFIGHUB_SPACE_ID")
+	// Check if a space ID is configured
 	var slug string
 
-	if spaceIDStr != "" {
-		// Use existing space
-		spaceID, err := uuid.Parse(spaceIDStr)
-		if err != nil {
-			return fmt.Errorf("parse CONFIGHUB_SPACE_ID: %w", err)
-		}
+	if c.config.SpaceID != "" {
+		// Use existing space (validated when the config was loaded)
+		spaceID := uuid.MustParse(c.config.SpaceID)
 		c.spaceID = spaceID
 		slug = "existing-space"
-		c.app.Logger.Printf("📦 Using existing ConfigHub space: %s", spaceID)
+		slog.Info("Using existing ConfigHub space", logging.Space(spaceID.String()))
 	} else {
 		// Create new space with unique prefix
 		space, newSlug, err := c.app.Cub.CreateSpaceWithUniquePrefix("cost-optimizer",
@@ -229,7 +457,7 @@ func (c *CostOptimizer) initializeConfigHub() error {
 		}
 		c.spaceID = space.SpaceID
 		slug = newSlug
-		c.app.Logger.Printf("📦 Created ConfigHub space: %s", slug)
+		slog.Info("Created ConfigHub space", logging.Space(slug), "space_id", space.SpaceID)
 	}
 
 	// Get or create set for critical cost items
@@ -237,7 +465,7 @@ func (c *CostOptimizer) initializeConfigHub() error {
 	sets, err := c.app.Cub.ListSets(c.spaceID)
 	if err != nil {
 		// If ListSets fails, try to create the set anyway
-		c.app.Logger.Printf("⚠️  Could not list sets: %v", err)
+		slog.Warn("Could not list sets", logging.Err(err))
 	}
 
 	var criticalSet *sdk.Set
@@ -245,7 +473,7 @@ func (c *CostOptimizer) initializeConfigHub() error {
 		for _, set := range sets {
 			if set.Slug == "critical-costs" {
 				criticalSet = set
-				c.app.Logger.Printf("📊 Using existing critical costs set: %s", set.SetID)
+				slog.Info("Using existing critical costs set", "set_id", set.SetID)
 				break
 			}
 		}
@@ -263,13 +491,12 @@ func (c *CostOptimizer) initializeConfigHub() error {
 		})
 		if err != nil {
 			// If creation fails, we can still continue without a set
-			c.app.Logger.Printf("⚠️  Could not create critical costs set: %v", err)
+			slog.Warn("Could not create critical costs set, continuing without set management", logging.Err(err))
 			// Use a dummy UUID so we can continue
 			c.criticalSetID = uuid.New()
-			c.app.Logger.Println("📊 Continuing without set management")
 			return nil
 		}
-		c.app.Logger.Printf("📊 Created critical costs set: %s", criticalSet.SetID)
+		slog.Info("Created critical costs set", "set_id", criticalSet.SetID)
 	}
 
 	c.criticalSetID = criticalSet.SetID
@@ -283,80 +510,93 @@ func (c *CostOptimizer) initializeConfigHub() error {
 	})
 	if err != nil {
 		// Filter likely already exists, which is fine
-		c.app.Logger.Println("📋 Filter creation skipped (may already exist)")
+		slog.Debug("Filter creation skipped (may already exist)", logging.Err(err))
 	} else {
-		c.app.Logger.Println("📋 Created high-cost filter")
+		slog.Info("Created high-cost filter")
 	}
 
 	return nil
 }
 
 // optimizeCosts performs the main cost optimization analysis using SDK modules
-func (c *CostOptimizer) optimizeCosts() error {
-	c.app.Logger.Println("🔍 Starting cost optimization analysis using SDK modules...")
+func (c *CostOptimizer) optimizeCosts() (err error) {
+	slog.Info("Starting cost optimization analysis", logging.Space(c.spaceID.String()))
+
+	// Each analysis cycle is one trace
+	ctx, span := tracing.Start(context.Background(), "cost.optimize", tracing.SpaceKey.String(c.spaceID.String()))
+	defer func() { tracing.End(span, err) }()
+	cycleDone := c.metrics.Cycle("optimize", c.spaceID.String())
+	defer func() { cycleDone(err) }()
 
 	// Check if running in Kubernetes-only mode (no ConfigHub)
 	if c.costAnalyzer == nil {
-		c.app.Logger.Println("🔍 Analyzing Kubernetes cluster directly (no ConfigHub space)")
-		return c.fallbackKubernetesAnalysis()
+		slog.Info("Analyzing Kubernetes cluster directly (no ConfigHub space)")
+		return c.fallbackKubernetesAnalysis(ctx)
 	}
 
 	// 1. Use SDK cost analyzer to analyze ConfigHub space
-	sdkCostAnalysis, err := c.costAnalyzer.AnalyzeSpace()
+	sdkCostAnalysis, err := tracing.Call(ctx, "confighub.AnalyzeSpace", c.costAnalyzer.AnalyzeSpace)
 	if err != nil {
-		c.app.Logger.Printf("⚠️  SDK cost analysis failed, falling back to Kubernetes analysis: %v", err)
+		slog.Warn("SDK cost analysis failed, falling back to Kubernetes analysis", logging.Err(err))
 		// Fallback to Kubernetes-based analysis for dashboard
-		return c.fallbackKubernetesAnalysis()
+		return c.fallbackKubernetesAnalysis(ctx)
 	}
 
-	c.app.Logger.Printf("📊 SDK analyzed %d ConfigHub units, total cost: $%.2f/month",
-		len(sdkCostAnalysis.Units), sdkCostAnalysis.TotalMonthlyCost)
+	slog.Info("Analyzed ConfigHub units",
+		"units", len(sdkCostAnalysis.Units), "monthly_cost", sdkCostAnalysis.TotalMonthlyCost)
 
 	// 2. Gather actual Kubernetes usage for waste detection
-	actualUsageMetrics, usingRealMetrics := c.gatherActualUsageMetrics()
+	actualUsageMetrics, usingRealMetrics := c.gatherActualUsageMetrics(ctx)
 
 	// 3. Use SDK waste analyzer if we have actual usage data
 	var sdkWasteAnalysis *sdk.SpaceWasteAnalysis
 	if len(actualUsageMetrics) > 0 {
-		sdkWasteAnalysis, err = c.wasteAnalyzer.AnalyzeWaste(actualUsageMetrics)
+		sdkWasteAnalysis, err = tracing.Call(ctx, "cost.AnalyzeWaste", func() (*sdk.SpaceWasteAnalysis, error) {
+			return c.wasteAnalyzer.AnalyzeWaste(actualUsageMetrics)
+		})
 		if err != nil {
-			c.app.Logger.Printf("⚠️  SDK waste analysis failed: %v", err)
+			slog.Warn("SDK waste analysis failed", logging.Err(err))
 		} else {
-			c.app.Logger.Printf("🗑️  Detected %.1f%% waste, $%.2f potential savings",
-				sdkWasteAnalysis.WastePercent, sdkWasteAnalysis.TotalWastedCost)
+			slog.Info("Detected waste",
+				"waste_percent", sdkWasteAnalysis.WastePercent, "wasted_cost", sdkWasteAnalysis.TotalWastedCost)
 		}
 	}
 
 	// 4. Try to integrate with OpenCost for additional cost data
-	if os.Getenv("ENABLE_OPENCOST") != "false" {
-		if err := c.IntegrateWithOpenCost(); err != nil {
-			c.app.Logger.Printf("⚠️  OpenCost integration failed, using estimates: %v", err)
+	if c.config.EnableOpenCost {
+		if err := tracing.Do(ctx, "opencost.Integrate", func(context.Context) error { return c.IntegrateWithOpenCost() }); err != nil {
+			slog.Warn("OpenCost integration failed, using estimates", logging.Err(err))
 		}
 	}
 
 	// 5. Convert SDK results to dashboard format and enhance with Claude AI
-	analysis, err := c.convertSDKToDashboardFormat(sdkCostAnalysis, sdkWasteAnalysis, usingRealMetrics)
+	analysis, err := c.convertSDKToDashboardFormat(ctx, sdkCostAnalysis, sdkWasteAnalysis, usingRealMetrics)
 	if err != nil {
 		return fmt.Errorf("convert SDK results: %w", err)
 	}
 
-	c.app.Logger.Printf("💰 Total potential monthly savings: $%.2f (%.1f%%)",
-		analysis.PotentialSavings, analysis.SavingsPercentage)
+	slog.Info("Total potential monthly savings",
+		"savings", analysis.PotentialSavings, "savings_percent", analysis.SavingsPercentage)
 
 	// 6. Store analysis in ConfigHub for tracking
 	if c.app.Cub != nil {
-		if err := c.storeAnalysisInConfigHub(analysis); err != nil {
-			c.app.Logger.Printf("⚠️  Failed to store in ConfigHub: %v", err)
+		if err := c.storeAnalysisInConfigHub(ctx, analysis); err != nil {
+			slog.Warn("Failed to store analysis in ConfigHub", logging.Err(err))
 		}
 	}
 
-	// 7. Update dashboard with latest data
+	// 7. File the recommendations for review and apply the approved ones,
+	// update dashboard with latest data, add it to the cost history and
+	// post the digest of the largest savings
+	c.reviewRecommendations(ctx, analysis)
 	c.dashboard.UpdateAnalysis(analysis)
+	c.recordHistory(ctx, analysis)
+	c.notifyRecommendations(analysis)
 
-	// 8. Apply high-confidence recommendations (if enabled)
-	if sdk.GetEnvBool("AUTO_APPLY_OPTIMIZATIONS", false) {
+	// 8. Report the SDK optimizations the policy would allow (if enabled)
+	if c.flags.Enabled(flags.AutoApply) {
 		if err := c.applySDKOptimizations(analysis); err != nil {
-			c.app.Logger.Printf("⚠️  Failed to apply optimizations: %v", err)
+			slog.Error("Failed to apply optimizations", logging.Err(err))
 		}
 	}
 
@@ -364,52 +604,50 @@ func (c *CostOptimizer) optimizeCosts() error {
 }
 
 // gatherActualUsageMetrics collects actual usage metrics for waste analysis
-func (c *CostOptimizer) gatherActualUsageMetrics() ([]sdk.ActualUsageMetrics, bool) {
-	ctx := context.Background()
+func (c *CostOptimizer) gatherActualUsageMetrics(ctx context.Context) ([]sdk.ActualUsageMetrics, bool) {
 	var actualMetrics []sdk.ActualUsageMetrics
 	hasRealMetrics := false
 
-	// Get all deployments for actual usage
-	deployments, err := c.app.K8s.Clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	// Get all workloads for actual usage, and the pods to attribute it by
+	cluster, err := c.listWorkloads(ctx)
 	if err != nil {
-		c.app.Logger.Printf("⚠️  Failed to list deployments: %v", err)
+		slog.Warn("Failed to list workloads", logging.Err(err))
 		return actualMetrics, false
 	}
 
 	// Get pod metrics for actual usage
 	var podMetrics *metricsv1beta1.PodMetricsList
 	if c.app.K8s.MetricsClient != nil {
-		podMetrics, err = c.app.K8s.MetricsClient.MetricsV1beta1().PodMetricses("").List(ctx, metav1.ListOptions{})
+		podMetrics, err = c.listPodMetrics(ctx)
 		if err != nil {
-			c.app.Logger.Printf("⚠️  Could not get pod metrics: %v", err)
+			slog.Warn("Could not get pod metrics", logging.Err(err))
 		}
 	}
 
-	// Build metrics map for quick lookup
-	metricsMap := make(map[string]metricsv1beta1.PodMetrics)
+	// Group the pod metrics by the workload running each pod
+	var byWorkload map[string][]metricsv1beta1.PodMetrics
 	if podMetrics != nil {
-		for _, metric := range podMetrics.Items {
-			metricsMap[metric.Namespace+"/"+metric.Name] = metric
-			hasRealMetrics = true
-		}
+		byWorkload = cluster.Attribute(podMetrics.Items)
+		hasRealMetrics = len(podMetrics.Items) > 0
 	}
 
 	// Convert each deployment to actual usage metrics
-	for _, deployment := range deployments.Items {
-		metric := c.convertDeploymentToActualUsage(deployment, metricsMap)
+	for _, deployment := range cluster.Deployments {
+		pods := byWorkload[workloads.Key(deployment.Namespace, workloads.Deployment, deployment.Name)]
+		metric := c.convertDeploymentToActualUsage(deployment, pods)
 		if metric != nil {
 			actualMetrics = append(actualMetrics, *metric)
 		}
 	}
 
-	c.app.Logger.Printf("📊 Gathered %d actual usage metrics (real metrics: %v)",
-		len(actualMetrics), hasRealMetrics)
+	slog.Info("Gathered actual usage metrics",
+		"metrics", len(actualMetrics), "real_metrics", hasRealMetrics)
 
 	return actualMetrics, hasRealMetrics
 }
 
 // convertDeploymentToActualUsage converts a deployment to SDK ActualUsageMetrics
-func (c *CostOptimizer) convertDeploymentToActualUsage(deployment appsv1.Deployment, metricsMap map[string]metricsv1beta1.PodMetrics) *sdk.ActualUsageMetrics {
+func (c *CostOptimizer) convertDeploymentToActualUsage(deployment appsv1.Deployment, pods []metricsv1beta1.PodMetrics) *sdk.ActualUsageMetrics {
 	// Create a unit ID based on deployment namespace/name
 	unitID := fmt.Sprintf("%s-%s", deployment.Namespace, deployment.Name)
 
@@ -423,52 +661,32 @@ func (c *CostOptimizer) convertDeploymentToActualUsage(deployment appsv1.Deploym
 		UptimePercent:  100.0, // Assume 100% uptime for simplicity
 	}
 
-	// Calculate actual usage from pod metrics
+	// Calculate actual usage from the metrics of the deployment's pods
 	actualCPU := 0.0
 	actualMemory := int64(0)
-	podCount := 0
-
-	// Look for pods that belong to this deployment
-	for podKey, podMetric := range metricsMap {
-		parts := strings.Split(podKey, "/")
-		if len(parts) != 2 {
-			continue
-		}
-		podNamespace, podName := parts[0], parts[1]
-
-		// Check if this pod belongs to the deployment
-		if podNamespace == deployment.Namespace && strings.HasPrefix(podName, deployment.Name) {
-			podCount++
-			for _, container := range podMetric.Containers {
-				if cpu := container.Usage.Cpu(); cpu != nil {
-					actualCPU += float64(cpu.MilliValue()) / 1000.0 // Convert to cores
-				}
-				if mem := container.Usage.Memory(); mem != nil {
-					actualMemory += mem.Value()
-				}
+	for _, podMetric := range pods {
+		for _, container := range podMetric.Containers {
+			if cpu := container.Usage.Cpu(); cpu != nil {
+				actualCPU += float64(cpu.MilliValue()) / 1000.0 // Convert to cores
+			}
+			if mem := container.Usage.Memory(); mem != nil {
+				actualMemory += mem.Value()
 			}
 		}
 	}
 
-	if podCount > 0 {
+	if len(pods) > 0 {
 		metric.CPUCoresUsed = actualCPU
 		metric.MemoryBytesUsed = actualMemory
 
-		// Calculate utilization percentages based on requests
-		if len(deployment.Spec.Template.Spec.Containers) > 0 {
-			container := deployment.Spec.Template.Spec.Containers[0]
-			if cpuReq := container.Resources.Requests["cpu"]; !cpuReq.IsZero() {
-				requestedCores := float64(cpuReq.MilliValue()) / 1000.0
-				if requestedCores > 0 {
-					metric.CPUUtilizationPercent = (actualCPU / requestedCores) * 100
-				}
-			}
-			if memReq := container.Resources.Requests["memory"]; !memReq.IsZero() {
-				requestedMem := memReq.Value()
-				if requestedMem > 0 {
-					metric.MemoryUtilizationPercent = (float64(actualMemory) / float64(requestedMem)) * 100
-				}
-			}
+		// Calculate utilization percentages based on the requests of the
+		// pods measured
+		cpuRequest, memRequest := workloads.Requests(deployment.Spec.Template.Spec)
+		if requestedCores := float64(cpuRequest) / 1000.0 * float64(len(pods)); requestedCores > 0 {
+			metric.CPUUtilizationPercent = (actualCPU / requestedCores) * 100
+		}
+		if requestedMem := memRequest * int64(len(pods)); requestedMem > 0 {
+			metric.MemoryUtilizationPercent = (float64(actualMemory) / float64(requestedMem)) * 100
 		}
 
 		// Set peak utilization as 150% of average for safety
@@ -482,68 +700,80 @@ func (c *CostOptimizer) convertDeploymentToActualUsage(deployment appsv1.Deploym
 		metric.MemoryPeakPercent = 75.0
 	}
 
-	// Estimate actual monthly cost (simplified)
-	cpuCost := metric.CPUCoresUsed * 0.024 * 24 * 30 // $0.024 per vCPU hour
-	memCost := float64(metric.MemoryBytesUsed) / (1024*1024*1024) * 0.006 * 24 * 30 // $0.006 per GB hour
-	metric.ActualMonthlyCost = cpuCost + memCost
+	// Estimate actual monthly cost from what is used
+	metric.ActualMonthlyCost = c.pricing.MonthlyCost(costmodel.Resources{
+		CPUCores: metric.CPUCoresUsed,
+		MemoryGB: float64(metric.MemoryBytesUsed) / (1024 * 1024 * 1024),
+		Replicas: 1,
+	})
 
 	return metric
 }
 
 // fallbackKubernetesAnalysis provides fallback analysis when SDK analysis fails
-func (c *CostOptimizer) fallbackKubernetesAnalysis() error {
-	c.app.Logger.Println("🔄 Using fallback Kubernetes analysis...")
+func (c *CostOptimizer) fallbackKubernetesAnalysis(ctx context.Context) error {
+	slog.Info("Using fallback Kubernetes analysis")
 
 	// Gather resource usage data from Kubernetes
-	resourceUsage, usingRealMetrics, err := c.gatherResourceUsage()
+	resourceUsage, usingRealMetrics, err := c.gatherResourceUsage(ctx)
 	if err != nil {
 		return fmt.Errorf("gather resource usage: %w", err)
 	}
-	c.resources = resourceUsage
+	c.observe(resourceUsage, usingRealMetrics)
 
 	// Analyze with Claude AI for intelligent recommendations
-	analysis, err := c.analyzeWithClaude(c.resources, usingRealMetrics)
+	analysis, err := c.analyzeWithClaude(ctx, resourceUsage, usingRealMetrics)
 	if err != nil {
 		return fmt.Errorf("AI analysis: %w", err)
 	}
 
-	// Update dashboard
+	// Recommend the replicas of autoscaled workloads as HPA changes, and
+	// add the ConfigHub commands
+	c.autoscaleRecommendations(analysis)
+	analysis.Recommendations = c.applier.EnrichRecommendationsWithCommands(analysis.Recommendations)
+
+	// Review the recommendations, update dashboard and cost history
+	c.reviewRecommendations(ctx, analysis)
 	c.dashboard.UpdateAnalysis(analysis)
+	c.recordHistory(ctx, analysis)
+	c.notifyRecommendations(analysis)
 	return nil
 }
 
 // gatherResourceUsage collects current resource usage from Kubernetes (fallback method)
-func (c *CostOptimizer) gatherResourceUsage() ([]ResourceUsage, bool, error) {
-	ctx := context.Background()
+func (c *CostOptimizer) gatherResourceUsage(ctx context.Context) ([]ResourceUsage, bool, error) {
 	var resourceUsage []ResourceUsage
 	hasRealMetrics := false
 
-	// Get all deployments
-	deployments, err := c.app.K8s.Clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	// Get all workloads: Deployments, StatefulSets, DaemonSets, Jobs,
+	// CronJobs and standalone Pods
+	cluster, err := c.listWorkloads(ctx)
 	if err != nil {
-		return nil, false, fmt.Errorf("list deployments: %w", err)
+		return nil, false, err
 	}
+	c.nodes = int32(cluster.Nodes)
+	c.observedMu.Lock()
+	c.workloads = cluster
+	c.observedMu.Unlock()
 
 	// Get pod metrics for actual usage
 	var podMetrics *metricsv1beta1.PodMetricsList
 	if c.app.K8s.MetricsClient != nil {
-		podMetrics, err = c.app.K8s.MetricsClient.MetricsV1beta1().PodMetricses("").List(ctx, metav1.ListOptions{})
+		podMetrics, err = c.listPodMetrics(ctx)
 		if err != nil {
-			c.app.Logger.Printf("⚠️  Could not get metrics: %v", err)
+			slog.Warn("Could not get pod metrics", logging.Err(err))
 		}
 	}
 
-	// Build metrics map for quick lookup
-	metricsMap := make(map[string]metricsv1beta1.PodMetrics)
+	// Group the pod metrics by the workload running each pod
+	var byWorkload map[string][]metricsv1beta1.PodMetrics
 	if podMetrics != nil {
-		for _, metric := range podMetrics.Items {
-			metricsMap[metric.Namespace+"/"+metric.Name] = metric
-		}
+		byWorkload = cluster.Attribute(podMetrics.Items)
 	}
 
-	// Analyze each deployment
-	for _, deployment := range deployments.Items {
-		usage, usedRealMetrics := c.analyzeDeployment(deployment, metricsMap)
+	// Analyze each workload
+	for _, workload := range cluster.Workloads() {
+		usage, usedRealMetrics := c.analyzeWorkload(workload, byWorkload[workload.Key()])
 		if usedRealMetrics {
 			hasRealMetrics = true
 		}
@@ -553,97 +783,25 @@ func (c *CostOptimizer) gatherResourceUsage() ([]ResourceUsage, bool, error) {
 	return resourceUsage, hasRealMetrics, nil
 }
 
-// analyzeDeployment analyzes a single deployment's resource usage
-func (c *CostOptimizer) analyzeDeployment(deployment appsv1.Deployment, metricsMap map[string]metricsv1beta1.PodMetrics) (ResourceUsage, bool) {
-	usage := ResourceUsage{
-		Name:      deployment.Name,
-		Namespace: deployment.Namespace,
-		Type:      "Deployment",
-		Replicas:  *deployment.Spec.Replicas,
-	}
-
-	// Calculate requested resources
-	if len(deployment.Spec.Template.Spec.Containers) > 0 {
-		container := deployment.Spec.Template.Spec.Containers[0]
-
-		if cpu := container.Resources.Requests[corev1.ResourceCPU]; !cpu.IsZero() {
-			usage.CPURequested = cpu.MilliValue() * int64(usage.Replicas)
-		}
-
-		if mem := container.Resources.Requests[corev1.ResourceMemory]; !mem.IsZero() {
-			usage.MemRequested = mem.Value() * int64(usage.Replicas)
-		}
-	}
-
-	// Get actual usage from metrics - need to find pods for this deployment
-	actualCPU := int64(0)
-	actualMem := int64(0)
-	podCount := 0
-
-	// Look for pods that belong to this deployment
-	for podKey, podMetric := range metricsMap {
-		// Extract namespace and pod name
-		parts := strings.Split(podKey, "/")
-		if len(parts) != 2 {
-			continue
-		}
-		podNamespace := parts[0]
-		podName := parts[1]
-
-		// Check if this pod belongs to the deployment
-		// Deployment pods typically have the deployment name as a prefix
-		if podNamespace == deployment.Namespace && strings.HasPrefix(podName, deployment.Name) {
-			podCount++
-			// Sum up container metrics
-			for _, container := range podMetric.Containers {
-				if cpu := container.Usage.Cpu(); cpu != nil {
-					actualCPU += cpu.MilliValue()
-				}
-				if mem := container.Usage.Memory(); mem != nil {
-					actualMem += mem.Value()
-				}
-			}
-		}
-	}
-
-	// Use actual metrics if we found pods, otherwise fallback to simulated
-	if podCount > 0 {
-		usage.CPUUsed = actualCPU
-		usage.MemUsed = actualMem
-		c.app.Logger.Printf("📊 Using real metrics for %s/%s: %d pods, %dm CPU, %dMi memory",
-			deployment.Namespace, deployment.Name, podCount, actualCPU, actualMem/(1024*1024))
-	} else {
-		// No metrics found - use conservative estimate
-		usage.CPUUsed = usage.CPURequested / 2 // Simulate 50% usage as fallback
-		usage.MemUsed = usage.MemRequested / 2
-		c.app.Logger.Printf("⚠️  No metrics found for %s/%s, using estimated 50%% utilization",
-			deployment.Namespace, deployment.Name)
-	}
-
-	// Calculate utilization percentages
-	if usage.CPURequested > 0 {
-		usage.CPUUtilization = float64(usage.CPUUsed) / float64(usage.CPURequested) * 100
-	}
-	if usage.MemRequested > 0 {
-		usage.MemUtilization = float64(usage.MemUsed) / float64(usage.MemRequested) * 100
-	}
-
-	// Use real AWS pricing
-	provider := GetAWSPricing(os.Getenv("AWS_REGION"))
-	if provider.Region == "" {
-		provider = GetAWSPricing("us-east-1") // Default region
-	}
-
-	cpuCores := float64(usage.CPURequested) / 1000.0
-	memoryGB := float64(usage.MemRequested) / (1024*1024*1024)
-
-	usage.MonthlyCost = CalculateRealCost(cpuCores, memoryGB, 0, provider)
+// listDeployments lists the deployments of all namespaces
+func (c *CostOptimizer) listDeployments(ctx context.Context) (*appsv1.DeploymentList, error) {
+	return tracing.Call(ctx, "k8s.ListDeployments", func() (*appsv1.DeploymentList, error) {
+		return c.app.K8s.Clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	})
+}
 
-	return usage, (podCount > 0)
+// listPodMetrics lists the metrics-server pod metrics of all namespaces
+func (c *CostOptimizer) listPodMetrics(ctx context.Context) (*metricsv1beta1.PodMetricsList, error) {
+	return tracing.Call(ctx, "k8s.ListPodMetrics", func() (*metricsv1beta1.PodMetricsList, error) {
+		return c.app.K8s.MetricsClient.MetricsV1beta1().PodMetricses("").List(ctx, metav1.ListOptions{})
+	})
 }
 
 // convertSDKToDashboardFormat converts SDK analysis results to dashboard format
-func (c *CostOptimizer) convertSDKToDashboardFormat(sdkCostAnalysis *sdk.SpaceCostAnalysis, sdkWasteAnalysis *sdk.SpaceWasteAnalysis, usingRealMetrics bool) (*CostAnalysis, error) {
+func (c *CostOptimizer) convertSDKToDashboardFormat(ctx context.Context, sdkCostAnalysis *sdk.SpaceCostAnalysis, sdkWasteAnalysis *sdk.SpaceWasteAnalysis, usingRealMetrics bool) (*CostAnalysis, error) {
+	// The SDK prices at AWS m5 rates; re-price at the cluster's
+	scale := c.repriceSDKUnits(sdkCostAnalysis)
+
 	// Create analysis structure for dashboard
 	analysis := &CostAnalysis{
 		Timestamp:        time.Now(),
@@ -655,11 +813,11 @@ func (c *CostOptimizer) convertSDKToDashboardFormat(sdkCostAnalysis *sdk.SpaceCo
 
 	// Calculate potential savings from waste analysis
 	if sdkWasteAnalysis != nil {
-		analysis.PotentialSavings = sdkWasteAnalysis.TotalWastedCost
+		analysis.PotentialSavings = sdkWasteAnalysis.TotalWastedCost * scale
 		analysis.SavingsPercentage = sdkWasteAnalysis.WastePercent
 
 		// Convert waste recommendations to cost recommendations
-		analysis.Recommendations = c.convertWasteToRecommendations(sdkWasteAnalysis.TopRecommendations)
+		analysis.Recommendations = c.convertWasteToRecommendations(sdkWasteAnalysis.TopRecommendations, scale)
 	} else {
 		// No waste analysis, use basic cost optimization
 		analysis.PotentialSavings = sdkCostAnalysis.TotalMonthlyCost * 0.15 // Conservative 15% estimate
@@ -669,7 +827,7 @@ func (c *CostOptimizer) convertSDKToDashboardFormat(sdkCostAnalysis *sdk.SpaceCo
 
 	// Convert SDK units to ResourceUsage for dashboard
 	analysis.ResourceDetails = c.convertSDKUnitsToResourceUsage(sdkCostAnalysis.Units)
-	c.resources = analysis.ResourceDetails // Update stored resources
+	c.observe(analysis.ResourceDetails, false) // Update stored resources, estimated at 50%
 
 	// Calculate resource breakdown
 	analysis.ResourceBreakdown = c.calculateResourceBreakdownFromSDK(sdkCostAnalysis.Units)
@@ -685,8 +843,8 @@ func (c *CostOptimizer) convertSDKToDashboardFormat(sdkCostAnalysis *sdk.SpaceCo
 
 	analysis.DataSource = DataSourceInfo{
 		MetricsSource: metricsSource,
-		PricingSource: "AWS m5 instance family via SDK",
-		Region:       os.Getenv("AWS_REGION"),
+		PricingSource: c.pricing.Service + " rates (pkg/costmodel)",
+		Region:       c.pricing.Region,
 		LastUpdated:  time.Now(),
 	}
 	if analysis.DataSource.Region == "" {
@@ -701,9 +859,9 @@ func (c *CostOptimizer) convertSDKToDashboardFormat(sdkCostAnalysis *sdk.SpaceCo
 	}
 
 	// Enhance with Claude AI if available
-	if c.app.Claude != nil {
-		c.enhanceWithClaudeAI(analysis)
-		analysis.ClaudeAPICalls = c.app.Claude.GetRecentCalls() // Add recent Claude API call history
+	if c.claude != nil {
+		c.enhanceWithClaudeAI(ctx, analysis)
+		analysis.ClaudeAPICalls = c.claude.RecentCalls() // Add recent Claude API call history
 	}
 
 	// Enrich recommendations with specific ConfigHub commands
@@ -712,8 +870,9 @@ func (c *CostOptimizer) convertSDKToDashboardFormat(sdkCostAnalysis *sdk.SpaceCo
 	return analysis, nil
 }
 
-// convertWasteToRecommendations converts SDK waste recommendations to dashboard recommendations
-func (c *CostOptimizer) convertWasteToRecommendations(wasteRecs []sdk.WasteRecommendation) []CostRecommendation {
+// convertWasteToRecommendations converts SDK waste recommendations to dashboard recommendations,
+// scaling their savings from the SDK's rates to the cluster's
+func (c *CostOptimizer) convertWasteToRecommendations(wasteRecs []sdk.WasteRecommendation, scale float64) []CostRecommendation {
 	var recommendations []CostRecommendation
 
 	for _, rec := range wasteRecs {
@@ -722,7 +881,7 @@ func (c *CostOptimizer) convertWasteToRecommendations(wasteRecs []sdk.WasteRecom
 			Namespace:       "multiple", // Waste recommendations can span namespaces
 			Type:            rec.Type,
 			Priority:        strings.ToLower(rec.Priority),
-			MonthlySavings:  rec.PotentialSavings,
+			MonthlySavings:  rec.PotentialSavings * scale,
 			Risk:            strings.ToLower(rec.Risk),
 			Explanation:     rec.RiskDescription,
 			ConfigHubAction: rec.Implementation,
@@ -736,7 +895,7 @@ func (c *CostOptimizer) convertWasteToRecommendations(wasteRecs []sdk.WasteRecom
 		costRec.Recommended = map[string]interface{}{
 			"action":      rec.Action,
 			"autoApply":   rec.AutoApplyable,
-			"savings":     fmt.Sprintf("$%.2f/month", rec.PotentialSavings),
+			"savings":     fmt.Sprintf("$%.2f/month", rec.PotentialSavings*scale),
 		}
 
 		recommendations = append(recommendations, costRec)
@@ -777,6 +936,29 @@ func (c *CostOptimizer) generateBasicRecommendations(units []sdk.UnitCostEstimat
 	return recommendations
 }
 
+// repriceSDKUnits prices the SDK's units at the cluster's rates in place,
+// keeping their storage cost, and returns the ratio of the new total to the
+// SDK's, to scale the SDK's savings by
+func (c *CostOptimizer) repriceSDKUnits(analysis *sdk.SpaceCostAnalysis) float64 {
+	before, after := analysis.TotalMonthlyCost, 0.0
+	for i := range analysis.Units {
+		unit := &analysis.Units[i]
+		b := c.pricing.Breakdown(costmodel.Resources{
+			CPUCores: float64(unit.CPU.MilliValue()) / 1000.0,
+			MemoryGB: float64(unit.Memory.BytesValue()) / (1024 * 1024 * 1024),
+			Replicas: int(unit.Replicas),
+		})
+		unit.Breakdown.CPUCost, unit.Breakdown.MemoryCost = b.CPU, b.Memory
+		unit.MonthlyCost = b.CPU + b.Memory + unit.Breakdown.StorageCost
+		after += unit.MonthlyCost
+	}
+	if len(analysis.Units) == 0 || before <= 0 {
+		return 1
+	}
+	analysis.TotalMonthlyCost = after
+	return after / before
+}
+
 // convertSDKUnitsToResourceUsage converts SDK units to ResourceUsage for dashboard
 func (c *CostOptimizer) convertSDKUnitsToResourceUsage(units []sdk.UnitCostEstimate) []ResourceUsage {
 	var resourceUsage []ResourceUsage
@@ -819,8 +1001,7 @@ func (c *CostOptimizer) calculateResourceBreakdownFromSDK(units []sdk.UnitCostEs
 		breakdown.Storage += unit.Breakdown.StorageCost
 	}
 
-	// Estimate network as 5% of compute
-	breakdown.Network = breakdown.Compute * 0.05
+	breakdown.Network = breakdown.Compute * costmodel.NetworkShare
 
 	return breakdown
 }
@@ -879,50 +1060,40 @@ func (c *CostOptimizer) calculateClusterSummaryFromSDK(units []sdk.UnitCostEstim
 }
 
 // enhanceWithClaudeAI enhances the analysis with Claude AI insights
-func (c *CostOptimizer) enhanceWithClaudeAI(analysis *CostAnalysis) {
-	c.app.Logger.Println("🤖 Enhancing analysis with Claude AI...")
+func (c *CostOptimizer) enhanceWithClaudeAI(ctx context.Context, analysis *CostAnalysis) {
+	slog.Info("Enhancing analysis with Claude AI")
 
 	// Prepare data for Claude analysis
-	prompt := c.buildClaudePromptFromSDK(analysis)
+	prompt, err := c.prompts.Render(costInsightsPrompt, analysis)
+	if err != nil {
+		slog.Warn("Claude AI enhancement failed", logging.Err(err))
+		return
+	}
 
-	response, err := c.app.Claude.Complete(prompt)
+	response, err := tracing.Call(ctx, "claude.Complete", func() (string, error) {
+		return c.claude.Complete(prompt)
+	})
+	if errors.Is(err, breaker.ErrOpen) {
+		slog.Info("Claude unavailable, skipping AI enhancement")
+		return
+	}
 	if err != nil {
-		c.app.Logger.Printf("⚠️  Claude AI enhancement failed: %v", err)
+		slog.Warn("Claude AI enhancement failed", logging.Err(err))
 		return
 	}
 
-	c.app.Logger.Printf("🤖 Claude AI provided enhanced recommendations (response length: %d chars)", len(response))
+	slog.Info("Claude AI provided enhanced recommendations", "response_chars", len(response))
 	// For now, just log the response. In a full implementation, you could parse
 	// Claude's response and integrate additional recommendations.
 }
 
-// buildClaudePromptFromSDK builds a Claude prompt from SDK analysis
-func (c *CostOptimizer) buildClaudePromptFromSDK(analysis *CostAnalysis) string {
-	return fmt.Sprintf(`
-Analyze this ConfigHub-based cost optimization:
-
-Space: %s
-Total Monthly Cost: $%.2f
-Potential Savings: $%.2f (%.1f%%)
-Units Analyzed: %d
-
-Provide additional optimization insights and risk assessment.
-`,
-		analysis.ConfigHubSpace,
-		analysis.TotalMonthlyCost,
-		analysis.PotentialSavings,
-		analysis.SavingsPercentage,
-		len(analysis.ResourceDetails),
-	)
-}
-
 // applySDKOptimizations applies optimizations using the SDK optimization engine
 func (c *CostOptimizer) applySDKOptimizations(analysis *CostAnalysis) error {
-	c.app.Logger.Println("🔧 Applying SDK-based optimizations...")
+	slog.Info("Applying SDK-based optimizations")
 
 	// Only apply if we have SDK cost analysis
 	if analysis.SDKCostAnalysis == nil {
-		c.app.Logger.Println("⚠️  No SDK cost analysis available for optimization")
+		slog.Warn("No SDK cost analysis available for optimization")
 		return nil
 	}
 
@@ -959,76 +1130,54 @@ func (c *CostOptimizer) applySDKOptimizations(analysis *CostAnalysis) error {
 		}, waste)
 
 		if err != nil {
-			c.app.Logger.Printf("⚠️  Failed to generate optimization for %s: %v", unit.UnitName, err)
+			slog.Warn("Failed to generate optimization", logging.Unit(unit.UnitName), logging.Err(err))
 			continue
 		}
 
-		// Only apply low-risk optimizations automatically
-		if optConfig.RiskAssessment.OverallRisk == "LOW" && optConfig.EstimatedSavings.MonthlySavings > 5.0 {
-			c.app.Logger.Printf("🔧 Would apply low-risk optimization for %s (saves $%.2f/month)",
-				unit.UnitName, optConfig.EstimatedSavings.MonthlySavings)
+		// Only apply the optimizations the policy allows automatically
+		action := optimizationAction(unit.UnitName, optConfig.RiskAssessment.OverallRisk, optConfig.EstimatedSavings.MonthlySavings)
+		if c.policy.Check(context.Background(), c.audit, action).Allowed() {
+			slog.Info("Would apply low-risk optimization",
+				logging.Unit(unit.UnitName), "monthly_savings", optConfig.EstimatedSavings.MonthlySavings)
 			count++
 			// In a real implementation, you'd create the optimized unit in ConfigHub
 			// _, err = c.optimizationEngine.CreateOptimizedUnitInConfigHub(optConfig)
 		}
 	}
 
-	c.app.Logger.Printf("✅ Applied %d SDK-based optimizations", count)
+	slog.Info("Applied SDK-based optimizations", "count", count)
 	return nil
 }
 
 // analyzeWithClaude uses Claude AI to generate intelligent cost optimization recommendations (fallback)
-func (c *CostOptimizer) analyzeWithClaude(resourceUsage []ResourceUsage, usingRealMetrics bool) (*CostAnalysis, error) {
-	if c.app.Claude == nil {
+func (c *CostOptimizer) analyzeWithClaude(ctx context.Context, resourceUsage []ResourceUsage, usingRealMetrics bool) (*CostAnalysis, error) {
+	if c.claude == nil {
 		// Fallback to basic analysis without AI
 		return c.basicCostAnalysis(resourceUsage, usingRealMetrics), nil
 	}
 
-	prompt := `Analyze the following Kubernetes resource usage data and provide cost optimization recommendations.
-
-We're running on AWS EKS with real pricing:
-- $0.024 per vCPU-hour ($17.28/month per core)
-- $0.006 per GB-hour ($4.32/month per GB)
-- Based on m5 instance family
-
-Focus on:
-1. Resources with low utilization (<50%) that can be right-sized
-2. Over-provisioned deployments that can be scaled down
-3. Resources that might be candidates for removal
-4. Storage optimization opportunities
-
-For each recommendation, provide:
-- Specific resource to modify
-- Current vs recommended configuration
-- Estimated monthly savings
-- Risk level (low/medium/high)
-- Clear explanation of the change
-
-IMPORTANT: Return ONLY valid JSON with no additional text before or after.
-Return your analysis as JSON matching this structure:
-{
-  "total_monthly_cost": 1234.56,
-  "potential_savings": 234.56,
-  "savings_percentage": 19.0,
-  "recommendations": [
-    {
-      "resource": "deployment/my-app",
-      "namespace": "default",
-      "type": "rightsize",
-      "priority": "high",
-      "current": {"cpu": "1000m", "memory": "1Gi", "replicas": 3},
-      "recommended": {"cpu": "500m", "memory": "512Mi", "replicas": 2},
-      "monthly_savings": 123.45,
-      "risk": "low",
-      "explanation": "Resource is only using 30% of allocated CPU and memory",
-      "confighub_action": "Update deployment unit with new resource limits"
-    }
-  ]
-}`
-
-	response, err := c.app.Claude.AnalyzeJSON(prompt, resourceUsage)
+	prompt, err := c.prompts.Render(costRecommendationsPrompt, costPromptData{
+		Region:        c.pricing.Region,
+		RealMetrics:   usingRealMetrics,
+		Resources:     resourceUsage,
+		Pricing:       c.pricing,
+		CPUMonthly:    c.pricing.CPUHourly * costmodel.HoursPerMonth,
+		MemoryMonthly: c.pricing.MemoryHourly * costmodel.HoursPerMonth,
+	})
+	if err != nil {
+		slog.Warn("Claude analysis failed", logging.Err(err))
+		return c.basicCostAnalysis(resourceUsage, usingRealMetrics), nil
+	}
+
+	response, err := tracing.Call(ctx, "claude.AnalyzeJSON", func() (string, error) {
+		return c.claude.AnalyzeJSON(prompt, resourceUsage)
+	})
+	if errors.Is(err, breaker.ErrOpen) {
+		slog.Info("Claude unavailable, using rule-based recommendations")
+		return c.basicCostAnalysis(resourceUsage, usingRealMetrics), nil
+	}
 	if err != nil {
-		c.app.Logger.Printf("⚠️  Claude analysis failed: %v", err)
+		slog.Warn("Claude analysis failed", logging.Err(err))
 		return c.basicCostAnalysis(resourceUsage, usingRealMetrics), nil
 	}
 
@@ -1042,13 +1191,13 @@ Return your analysis as JSON matching this structure:
 	if jsonStart != -1 && jsonEnd != -1 && jsonEnd > jsonStart {
 		jsonStr := response[jsonStart : jsonEnd+1]
 		if err := json.Unmarshal([]byte(jsonStr), &analysis); err != nil {
-			c.app.Logger.Printf("⚠️  Failed to parse Claude response: %v", err)
-			c.app.Logger.Printf("Attempted to parse: %s", jsonStr[:100])
+			slog.Warn("Failed to parse Claude response", logging.Err(err))
+			slog.Debug("Attempted to parse Claude response", "json", jsonStr[:100])
 			return c.basicCostAnalysis(resourceUsage, usingRealMetrics), nil
 		}
-		c.app.Logger.Printf("✅ Successfully parsed Claude recommendations: %d recommendations", len(analysis.Recommendations))
+		slog.Info("Parsed Claude recommendations", "recommendations", len(analysis.Recommendations))
 	} else {
-		c.app.Logger.Printf("⚠️  Could not find JSON in Claude response")
+		slog.Warn("Could not find JSON in Claude response")
 		return c.basicCostAnalysis(resourceUsage, usingRealMetrics), nil
 	}
 
@@ -1074,8 +1223,8 @@ Return your analysis as JSON matching this structure:
 
 	analysis.DataSource = DataSourceInfo{
 		MetricsSource: metricsSource,
-		PricingSource: "AWS m5 instance family",
-		Region:       os.Getenv("AWS_REGION"),
+		PricingSource: c.pricing.Service + " rates (pkg/costmodel)",
+		Region:       c.pricing.Region,
 		LastUpdated:  time.Now(),
 	}
 	if analysis.DataSource.Region == "" {
@@ -1094,10 +1243,11 @@ func (c *CostOptimizer) basicCostAnalysis(resourceUsage []ResourceUsage, usingRe
 	for _, usage := range resourceUsage {
 		totalCost += usage.MonthlyCost
 
-		// Simple rule: if utilization < 50%, recommend rightsizing
-		if usage.CPUUtilization < 50 && usage.MemUtilization < 50 {
+		// Simple rule: if utilization < 50%, recommend rightsizing; a
+		// standalone pod has no template to rightsize
+		if usage.CPUUtilization < 50 && usage.MemUtilization < 50 && usage.Type != workloads.Pod {
 			rec := CostRecommendation{
-				Resource:        fmt.Sprintf("deployment/%s", usage.Name),
+				Resource:        fmt.Sprintf("%s/%s", strings.ToLower(usage.Type), usage.Name),
 				Namespace:       usage.Namespace,
 				Type:            "rightsize",
 				Priority:        "medium",
@@ -1128,31 +1278,50 @@ func (c *CostOptimizer) basicCostAnalysis(resourceUsage []ResourceUsage, usingRe
 func (c *CostOptimizer) calculateResourceBreakdown(resourceUsage []ResourceUsage) ResourceBreakdown {
 	totalCompute := 0.0
 	totalMemory := 0.0
+	totalStorage := 0.0
 
 	for _, usage := range resourceUsage {
-		cpuCost := float64(usage.CPURequested) / 1000.0 * 0.0416 * 24 * 30
-		memCost := float64(usage.MemRequested) / (1024*1024*1024) * 0.00456 * 24 * 30
-
-		totalCompute += cpuCost
-		totalMemory += memCost
+		b := c.pricing.Breakdown(requestedResources(usage))
+		totalCompute += b.CPU
+		totalMemory += b.Memory
+		totalStorage += b.Storage
 	}
 
 	return ResourceBreakdown{
 		Compute: totalCompute,
 		Memory:  totalMemory,
-		Storage: totalCompute * 0.1, // Estimate storage as 10% of compute
-		Network: totalCompute * 0.05, // Estimate network as 5% of compute
+		Storage: totalStorage + totalCompute*0.1, // volume claims, plus 10% of compute for the rest
+		Network: totalCompute * costmodel.NetworkShare,
+	}
+}
+
+// requestedResources is what a workload requests over the month, for the
+// cost model; CPURequested, MemRequested and StorageRequested already count
+// every replica
+func requestedResources(usage ResourceUsage) costmodel.Resources {
+	duty := usage.DutyCycle
+	if duty == 0 {
+		duty = 1 // always running
+	}
+	return costmodel.Resources{
+		CPUCores:  float64(usage.CPURequested) / 1000.0 * duty,
+		MemoryGB:  float64(usage.MemRequested) / (1024 * 1024 * 1024) * duty,
+		StorageGB: float64(usage.StorageRequested) / (1024 * 1024 * 1024),
+		Replicas:  1,
 	}
 }
 
 // calculateClusterSummary calculates cluster-wide summary statistics
 func (c *CostOptimizer) calculateClusterSummary(resourceUsage []ResourceUsage) ClusterSummary {
-	totalDeployments := int32(len(resourceUsage))
+	totalDeployments := int32(0)
 	totalReplicas := int32(0)
 	totalCPUUtil := 0.0
 	totalMemUtil := 0.0
 
 	for _, usage := range resourceUsage {
+		if usage.Type == workloads.Deployment {
+			totalDeployments++
+		}
 		totalReplicas += usage.Replicas
 		totalCPUUtil += usage.CPUUtilization
 		totalMemUtil += usage.MemUtilization
@@ -1164,19 +1333,10 @@ func (c *CostOptimizer) calculateClusterSummary(resourceUsage []ResourceUsage) C
 	// Get cluster context name
 	clusterName := "kind-kind" // Default for kind cluster
 	clusterContext := "kind-kind"
-	clusterType := "kind" // Local development cluster
 	// TODO: Get actual cluster name from kubeconfig when SDK exposes it
-
-	// Detect cluster type
-	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
-		// Running inside Kubernetes
-		if _, err := os.Stat("/var/run/secrets/eks.amazonaws.com"); err == nil {
-			clusterType = "eks"
-		} else if _, err := os.Stat("/var/run/secrets/azure"); err == nil {
-			clusterType = "aks"
-		} else if os.Getenv("GKE_CLUSTER_NAME") != "" {
-			clusterType = "gke"
-		}
+	clusterType := c.cluster.Type // detected at startup
+	if clusterType == "" {
+		clusterType = "kind" // Local development cluster
 	}
 
 	// Get namespace information
@@ -1215,7 +1375,7 @@ func (c *CostOptimizer) calculateClusterSummary(resourceUsage []ResourceUsage) C
 		ClusterContext:   clusterContext,
 		ClusterType:      clusterType,
 		KubernetesVersion: "v1.27.3", // Kind default version
-		TotalNodes:       3, // Would get from actual node count
+		TotalNodes:       c.nodes,
 		TotalPods:        totalReplicas,
 		TotalDeployments: totalDeployments,
 		TotalNamespaces:  int32(len(namespaceMap)),
@@ -1227,24 +1387,31 @@ func (c *CostOptimizer) calculateClusterSummary(resourceUsage []ResourceUsage) C
 }
 
 // storeAnalysisInConfigHub stores the cost analysis in ConfigHub for tracking
-func (c *CostOptimizer) storeAnalysisInConfigHub(analysis *CostAnalysis) error {
+func (c *CostOptimizer) storeAnalysisInConfigHub(ctx context.Context, analysis *CostAnalysis) error {
 	// Store overall analysis
 	analysisData, err := json.MarshalIndent(analysis, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal analysis: %w", err)
 	}
 
-	_, err = c.app.Cub.CreateUnit(c.spaceID, sdk.CreateUnitRequest{
-		Slug:        fmt.Sprintf("cost-analysis-%d", time.Now().Unix()),
-		DisplayName: fmt.Sprintf("Cost Analysis %s", time.Now().Format("2006-01-02 15:04")),
-		Data:        string(analysisData),
-		Labels: map[string]string{
-			"type":           "cost-analysis",
-			"total_cost":     fmt.Sprintf("%.2f", analysis.TotalMonthlyCost),
-			"savings":        fmt.Sprintf("%.2f", analysis.PotentialSavings),
-			"timestamp":      analysis.Timestamp.Format(time.RFC3339),
-		},
+	analysisSlug := fmt.Sprintf("cost-analysis-%d", time.Now().Unix())
+	_, err = tracing.Call(ctx, "confighub.CreateUnit", func() (*sdk.Unit, error) {
+		return c.app.Cub.CreateUnit(c.spaceID, sdk.CreateUnitRequest{
+			Slug:        analysisSlug,
+			DisplayName: fmt.Sprintf("Cost Analysis %s", time.Now().Format("2006-01-02 15:04")),
+			Data:        string(analysisData),
+			Labels: map[string]string{
+				"type":           "cost-analysis",
+				"total_cost":     fmt.Sprintf("%.2f", analysis.TotalMonthlyCost),
+				"savings":        fmt.Sprintf("%.2f", analysis.PotentialSavings),
+				"timestamp":      analysis.Timestamp.Format(time.RFC3339),
+			},
+		})
 	})
+	c.audit.Record(ctx, audit.UnitCreated, analysisSlug, map[string]float64{
+		"total_monthly_cost": analysis.TotalMonthlyCost,
+		"potential_savings":  analysis.PotentialSavings,
+	}, err)
 	if err != nil {
 		return fmt.Errorf("create analysis unit: %w", err)
 	}
@@ -1253,26 +1420,31 @@ func (c *CostOptimizer) storeAnalysisInConfigHub(analysis *CostAnalysis) error {
 	for _, rec := range analysis.Recommendations {
 		if rec.Priority == "high" && rec.MonthlySavings > 50 {
 			recData, _ := json.MarshalIndent(rec, "", "  ")
-
-			unit, err := c.app.Cub.CreateUnit(c.spaceID, sdk.CreateUnitRequest{
-				Slug:        fmt.Sprintf("rec-%s-%d", strings.ReplaceAll(rec.Resource, "/", "-"), time.Now().Unix()),
-				DisplayName: fmt.Sprintf("High Priority: %s", rec.Resource),
-				Data:        string(recData),
-				Labels: map[string]string{
-					"type":            "recommendation",
-					"priority":        rec.Priority,
-					"monthly_savings": fmt.Sprintf("%.2f", rec.MonthlySavings),
-					"resource":        rec.Resource,
-				},
-				SetIDs: []uuid.UUID{c.criticalSetID},
-			})
+			recSlug := fmt.Sprintf("rec-%s-%d", strings.ReplaceAll(rec.Resource, "/", "-"), time.Now().Unix())
+
+			unit, err := tracing.Call(ctx, "confighub.CreateUnit", func() (*sdk.Unit, error) {
+				return c.app.Cub.CreateUnit(c.spaceID, sdk.CreateUnitRequest{
+					Slug:        recSlug,
+					DisplayName: fmt.Sprintf("High Priority: %s", rec.Resource),
+					Data:        string(recData),
+					Labels: map[string]string{
+						"type":            "recommendation",
+						"priority":        rec.Priority,
+						"monthly_savings": fmt.Sprintf("%.2f", rec.MonthlySavings),
+						"resource":        rec.Resource,
+					},
+					SetIDs: []uuid.UUID{c.criticalSetID},
+				})
+			}, tracing.UnitKey.String(rec.Resource))
+			c.audit.Record(ctx, audit.UnitCreated, recSlug, rec, err)
 			if err != nil {
-				c.app.Logger.Printf("⚠️  Failed to store recommendation: %v", err)
+				slog.Warn("Failed to store recommendation", logging.Unit(rec.Resource), logging.Err(err))
 				continue
 			}
 
-			c.app.Logger.Printf("💡 Stored high-priority recommendation: %s (saves $%.2f/month)",
-				rec.Resource, rec.MonthlySavings)
+			slog.Info("Stored high-priority recommendation",
+				logging.Unit(rec.Resource), "monthly_savings", rec.MonthlySavings)
+			c.bus.Publish(ctx, events.RecommendationCreated, c.spaceID.String(), rec.Resource, rec)
 
 			// Store unit ID for later reference
 			_ = unit
@@ -1282,33 +1454,22 @@ func (c *CostOptimizer) storeAnalysisInConfigHub(analysis *CostAnalysis) error {
 	return nil
 }
 
-// applyRecommendations applies safe recommendations automatically
-func (c *CostOptimizer) applyRecommendations(analysis *CostAnalysis) error {
-	ctx := context.Background()
-
-	// Check if auto-apply is enabled
-	autoApply := os.Getenv("AUTO_APPLY_OPTIMIZATIONS")
-	if autoApply != "true" {
-		c.app.Logger.Printf("ℹ️  Auto-apply disabled. Set AUTO_APPLY_OPTIMIZATIONS=true to enable")
-		// Still generate commands but don't apply
-		for _, rec := range analysis.Recommendations {
-			if rec.Risk == "low" && rec.MonthlySavings > 20 {
-				c.app.Logger.Printf("📝 Would apply: %s (saves $%.2f/month)", rec.Resource, rec.MonthlySavings)
-			}
-		}
-		return nil
+// notifyRecommendations posts a digest of the recommendations saving at
+// least notify_min_savings a month to the configured notification channels.
+// The same digest is posted again only after the dedup window, or when its
+// recommendations or their savings change.
+func (c *CostOptimizer) notifyRecommendations(analysis *CostAnalysis) {
+	if !c.notifier.Enabled() {
+		return
 	}
 
-	// Apply recommendations via ConfigHub
-	applied := c.applier.ApplyRecommendationsAutomatically(ctx, analysis.Recommendations)
-
-	if applied > 0 {
-		c.app.Logger.Printf("✅ Applied %d cost optimization recommendations via ConfigHub", applied)
-	} else {
-		c.app.Logger.Printf("ℹ️  No recommendations met auto-apply criteria (low risk, >$20/month savings)")
+	note, ok := recommendationDigest(analysis.Recommendations, c.config.NotifyMinSavings, c.config.DashboardURL)
+	if !ok {
+		return
+	}
+	if err := c.notifier.Notify(context.Background(), note); err != nil {
+		slog.Warn("Failed to send recommendation digest", logging.Err(err))
 	}
-
-	return nil
 }
 
 // applySingleRecommendation applies a single recommendation via ConfigHub
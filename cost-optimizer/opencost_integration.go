@@ -1,13 +1,21 @@
-package main
+package costoptimizer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/unitdata"
 	sdk "github.com/monadic/devops-sdk"
 )
 
@@ -59,30 +67,29 @@ func (oc *OpenCostClient) GetAllocationData(window string, aggregate string) (*O
 	url := fmt.Sprintf("%s/allocation/compute?window=%s&aggregate=%s",
 		oc.baseURL, window, aggregate)
 	
-	fmt.Printf("[OpenCost] Fetching allocation data from: %s\n", url)
+	slog.Info("Fetching OpenCost allocation data", "url", url)
 	
 	resp, err := oc.client.Get(url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch OpenCost data: %v", err)
+		return nil, fmt.Errorf("failed to fetch OpenCost data: %w", err)
 	}
 	defer resp.Body.Close()
 	
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %v", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 	
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenCost API error (status %d): %s",
-			resp.StatusCode, string(body))
+		return nil, errkind.FromResponse("opencost", resp, string(body))
 	}
 	
 	var result OpenCostResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse OpenCost response: %v", err)
+		return nil, fmt.Errorf("failed to parse OpenCost response: %w", err)
 	}
 	
-	fmt.Printf("[OpenCost] Retrieved %d allocation entries\n", len(result.Data))
+	slog.Info("Retrieved OpenCost allocation data", "entries", len(result.Data))
 	
 	return &result, nil
 }
@@ -132,19 +139,21 @@ func extractUtilization(props map[string]interface{}, key string) float64 {
 
 // IntegrateWithOpenCost enhances cost optimizer with real OpenCost data
 func (c *CostOptimizer) IntegrateWithOpenCost() error {
-	fmt.Println("\n🔌 Integrating with OpenCost for real cost data...")
+	slog.Info("Integrating with OpenCost for real cost data")
 
 	// First check ConfigHub for OpenCost configuration
 	opencostConfig, err := c.getOpenCostConfig()
 	if err != nil {
-		fmt.Printf("[OpenCost] No configuration found in ConfigHub: %v\n", err)
+		slog.Info("No OpenCost configuration in ConfigHub", logging.Err(err))
 	}
 
 	// Check if OpenCost is available
-	opencostURL := os.Getenv("OPENCOST_URL")
+	opencostURL := c.config.OpenCostURL
 	if opencostURL == "" && opencostConfig != nil {
 		// Use URL from ConfigHub config if available
-		if url, ok := opencostConfig["url"].(string); ok {
+		if url, _, err := opencostConfig.String("url"); err != nil {
+			slog.Warn("Ignoring OpenCost url from ConfigHub", logging.Unit("opencost-config"), logging.Err(err))
+		} else {
 			opencostURL = url
 		}
 	}
@@ -155,40 +164,38 @@ func (c *CostOptimizer) IntegrateWithOpenCost() error {
 	
 	oc := NewOpenCostClient(opencostURL)
 	
-	// Test OpenCost connectivity
-	testURL := fmt.Sprintf("%s/healthz", opencostURL)
-	resp, err := oc.client.Get(testURL)
-	if err != nil {
-		fmt.Printf("[OpenCost] Not available at %s, using estimated costs\n", opencostURL)
+	// Test OpenCost connectivity and fetch real cost data. The breaker skips
+	// OpenCost entirely after repeated failures, until its cooldown ends.
+	allocations, err := breaker.Call(c.openCostBreaker, func() (*OpenCostResponse, error) {
+		resp, err := oc.client.Get(fmt.Sprintf("%s/healthz", opencostURL))
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errkind.FromResponse("opencost", resp, "health check failed")
+		}
+		slog.Info("Connected to OpenCost", "url", opencostURL)
+		return oc.GetAllocationData("1d", "namespace")
+	})
+	if errors.Is(err, breaker.ErrOpen) {
+		slog.Info("OpenCost unavailable (circuit open), using estimated costs")
 		return nil // Fallback to estimates
 	}
-	resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("[OpenCost] Health check failed (status %d), using estimates\n",
-			resp.StatusCode)
-		return nil
-	}
-	
-	fmt.Printf("[OpenCost] ✓ Connected to OpenCost at %s\n", opencostURL)
-	
-	// Fetch real cost data from OpenCost
-	allocations, err := oc.GetAllocationData("1d", "namespace")
 	if err != nil {
-		fmt.Printf("[OpenCost] Error fetching data: %v\n", err)
-		return err
+		slog.Warn("OpenCost not available, using estimated costs", "url", opencostURL, logging.Err(err))
+		return nil // Fallback to estimates
 	}
 	
 	// Convert OpenCost data to our format
 	opencostResources := oc.ConvertToResourceUsage(allocations)
 	
 	if len(opencostResources) > 0 {
-		fmt.Printf("[OpenCost] ✓ Using real cost data for %d resources\n",
-			len(opencostResources))
+		slog.Info("Using real OpenCost cost data", "resources", len(opencostResources))
 		
 		// Merge with existing resource data
-		mergedResources := c.mergeResourceData(c.resources, opencostResources)
-		c.resources = mergedResources
+		resources, _, measured := c.observed()
+		c.observe(c.mergeResourceData(resources, opencostResources), measured)
 		
 		// Store OpenCost data in ConfigHub
 		c.storeOpenCostData(allocations)
@@ -227,8 +234,7 @@ func (c *CostOptimizer) mergeResourceData(k8sResources, opencostResources []Reso
 				k8sRes.MemUtilization = ocRes.MemUtilization
 			}
 			
-			fmt.Printf("[OpenCost] Updated %s with real costs: $%.2f/month\n",
-				key, k8sRes.MonthlyCost)
+			slog.Info("Updated workload with OpenCost costs", "workload", key, "monthly_cost", k8sRes.MonthlyCost)
 		}
 		
 		merged = append(merged, k8sRes)
@@ -239,7 +245,7 @@ func (c *CostOptimizer) mergeResourceData(k8sResources, opencostResources []Reso
 
 // storeOpenCostData stores OpenCost data in ConfigHub for audit trail
 func (c *CostOptimizer) storeOpenCostData(data *OpenCostResponse) error {
-	fmt.Println("[ConfigHub] Storing OpenCost data for audit trail...")
+	slog.Info("Storing OpenCost data in ConfigHub for the audit trail")
 	
 	// Create unit with OpenCost data
 	unitName := fmt.Sprintf("opencost-data-%d", time.Now().Unix())
@@ -263,21 +269,24 @@ func (c *CostOptimizer) storeOpenCostData(data *OpenCostResponse) error {
 			"source": "opencost",
 		},
 	})
+	c.audit.Record(context.Background(), audit.UnitCreated, unitName, map[string]string{"source": "opencost"}, err)
 	
 	if err != nil {
-		fmt.Printf("[ConfigHub] Warning: Could not store OpenCost data: %v\n", err)
+		slog.Warn("Could not store OpenCost data", logging.Unit(unitName), logging.Err(err))
 		return nil // Non-critical error
 	}
 	
-	fmt.Printf("[ConfigHub] ✓ Stored OpenCost data as unit: %s\n", unitName)
+	slog.Info("Stored OpenCost data", logging.Unit(unitName))
 	return nil
 }
 
 // getOpenCostConfig retrieves OpenCost configuration from ConfigHub
-func (c *CostOptimizer) getOpenCostConfig() (map[string]interface{}, error) {
+func (c *CostOptimizer) getOpenCostConfig() (*unitdata.Manifest, error) {
 	// Try to get OpenCost config unit from ConfigHub
-	units, err := c.app.Cub.ListUnits(sdk.ListUnitsParams{
-		SpaceID: c.spaceID,
+	units, err := ratelimit.Call(context.Background(), c.cubLimit, "ListUnits", c.spaceID.String(), func() ([]*sdk.Unit, error) {
+		return c.app.Cub.ListUnits(sdk.ListUnitsParams{
+			SpaceID: c.spaceID,
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list units: %v", err)
@@ -286,12 +295,13 @@ func (c *CostOptimizer) getOpenCostConfig() (map[string]interface{}, error) {
 	// Look for OpenCost config unit
 	for _, unit := range units {
 		if unit.Slug == "opencost-config" {
-			var config map[string]interface{}
-			if err := json.Unmarshal([]byte(unit.Data), &config); err != nil {
+			config, err := unitdata.ParseObject(unit.Slug, unit.Data)
+			if err != nil {
 				return nil, fmt.Errorf("failed to parse config: %v", err)
 			}
-			fmt.Printf("[ConfigHub] ✓ Found OpenCost config: enabled=%v, url=%v\n",
-				config["enabled"], config["url"])
+			enabled, _, _ := config.Get("enabled")
+			url, _, _ := config.Get("url")
+			slog.Info("Found OpenCost config", logging.Unit(unit.Slug), "enabled", enabled, "url", url)
 			return config, nil
 		}
 	}
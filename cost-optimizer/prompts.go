@@ -0,0 +1,121 @@
+package costoptimizer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"github.com/monadic/devops-examples/pkg/prompts"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// costRecommendationsPrompt asks for recommendations in the CostAnalysis
+// JSON shape; AnalyzeJSON appends the resource usage to it. The data is
+// costPromptData.
+var costRecommendationsPrompt = prompts.Prompt{
+	Name: "cost-recommendations",
+	Text: `Analyze the following Kubernetes resource usage data and provide cost optimization recommendations.
+
+We're running on {{.Pricing.Service}} in {{.Pricing.Region}} with real pricing:
+- ${{.Pricing.CPUHourly}} per vCPU-hour (${{printf "%.2f" .CPUMonthly}}/month per core)
+- ${{.Pricing.MemoryHourly}} per GB-hour (${{printf "%.2f" .MemoryMonthly}}/month per GB)
+
+Focus on:
+1. Resources with low utilization (<50%) that can be right-sized
+2. Over-provisioned deployments that can be scaled down
+3. Resources that might be candidates for removal
+4. Storage optimization opportunities
+
+For each recommendation, provide:
+- Specific resource to modify
+- Current vs recommended configuration
+- Estimated monthly savings
+- Risk level (low/medium/high)
+- Clear explanation of the change
+
+IMPORTANT: Return ONLY valid JSON with no additional text before or after.
+Return your analysis as JSON matching this structure:
+{
+  "total_monthly_cost": 1234.56,
+  "potential_savings": 234.56,
+  "savings_percentage": 19.0,
+  "recommendations": [
+    {
+      "resource": "deployment/my-app",
+      "namespace": "default",
+      "type": "rightsize",
+      "priority": "high",
+      "current": {"cpu": "1000m", "memory": "1Gi", "replicas": 3},
+      "recommended": {"cpu": "500m", "memory": "512Mi", "replicas": 2},
+      "monthly_savings": 123.45,
+      "risk": "low",
+      "explanation": "Resource is only using 30% of allocated CPU and memory",
+      "confighub_action": "Update deployment unit with new resource limits"
+    }
+  ]
+}`,
+}
+
+// costInsightsPrompt asks for insights on a finished analysis; the data is
+// the *CostAnalysis
+var costInsightsPrompt = prompts.Prompt{
+	Name: "cost-insights",
+	Text: `Analyze this ConfigHub-based cost optimization:
+
+Space: {{.ConfigHubSpace}}
+Total Monthly Cost: ${{printf "%.2f" .TotalMonthlyCost}}
+Potential Savings: ${{printf "%.2f" .PotentialSavings}} ({{printf "%.1f" .SavingsPercentage}}%)
+Units Analyzed: {{len .ResourceDetails}}
+
+Provide additional optimization insights and risk assessment.
+`,
+}
+
+// costPromptData is what costRecommendationsPrompt can refer to
+type costPromptData struct {
+	Region      string
+	RealMetrics bool // usage is measured, not estimated from requests
+	Resources   []ResourceUsage
+	// Rates the costs were priced at, and a core's and a GB's month
+	Pricing       costmodel.Pricing
+	CPUMonthly    float64
+	MemoryMonthly float64
+}
+
+// promptsSource returns the prompt units of the named space, or nil when no
+// prompts space is configured
+func promptsSource(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) prompts.Source {
+	if cub == nil || space == "" {
+		return nil
+	}
+	return func(ctx context.Context) ([]prompts.Unit, error) {
+		spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+			return ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug != space {
+				continue
+			}
+			units, err := ratelimit.Call(ctx, limit, "ListUnits", s.SpaceID.String(), func() ([]*sdk.Unit, error) {
+				return cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.SpaceID})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("list units: %w", err)
+			}
+			var found []prompts.Unit
+			for _, u := range units {
+				if strings.HasPrefix(u.Slug, prompts.UnitPrefix) {
+					found = append(found, prompts.Unit{Slug: u.Slug, Data: u.Data, Revision: u.HeadRevisionNum})
+				}
+			}
+			return found, nil
+		}
+		return nil, fmt.Errorf("prompts space %s not found", space)
+	}
+}
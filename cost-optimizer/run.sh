@@ -82,7 +82,7 @@ fi
 # Build if needed
 if [ ! -f cost-optimizer ] || [ main.go -nt cost-optimizer ]; then
     echo -e "${YELLOW}🔨 Building cost-optimizer...${NC}"
-    go build -o cost-optimizer .
+    go build -o cost-optimizer ./cmd/cost-optimizer
     echo -e "${GREEN}✅ Build complete${NC}"
 fi
 
@@ -0,0 +1,77 @@
+package costoptimizer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// unitStore keeps documents as the ConfigHub units of a space, for the
+// checkpoint, the cost history and the approvals. Every call goes through
+// the limiter, and so fails fast while its breaker is open.
+type unitStore struct {
+	cub   *sdk.ConfigHubClient
+	limit *ratelimit.Limiter
+	space string // slug
+}
+
+// spaceID returns the ID of the store's space
+func (s unitStore) spaceID(ctx context.Context) (uuid.UUID, error) {
+	spaces, err := ratelimit.Call(ctx, s.limit, "ListSpaces", "all", s.cub.ListSpaces)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("list spaces: %w", err)
+	}
+	for _, sp := range spaces {
+		if sp.Slug == s.space {
+			return sp.SpaceID, nil
+		}
+	}
+	return uuid.Nil, fmt.Errorf("space %s not found", s.space)
+}
+
+// write stores data as the unit with slug, creating it or replacing its
+// data and labels
+func (s unitStore) write(ctx context.Context, slug string, labels map[string]string, data string) error {
+	id, err := s.spaceID(ctx)
+	if err != nil {
+		return err
+	}
+	units, err := ratelimit.Call(ctx, s.limit, "ListUnits", "", func() ([]*sdk.Unit, error) {
+		return s.cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: fmt.Sprintf("Slug = '%s'", slug)})
+	})
+	if err != nil {
+		return fmt.Errorf("list units: %w", err)
+	}
+	if len(units) > 0 {
+		_, err = ratelimit.Call(ctx, s.limit, "UpdateUnit", "", func() (*sdk.Unit, error) {
+			return s.cub.UpdateUnit(id, units[0].UnitID, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+		})
+		return err
+	}
+	_, err = ratelimit.Call(ctx, s.limit, "CreateUnit", "", func() (*sdk.Unit, error) {
+		return s.cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+	})
+	return err
+}
+
+// read returns the data of the units matching where
+func (s unitStore) read(ctx context.Context, where string) ([]string, error) {
+	id, err := s.spaceID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	units, err := ratelimit.Call(ctx, s.limit, "ListUnits", id.String()+"/"+where, func() ([]*sdk.Unit, error) {
+		return s.cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: where})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list units: %w", err)
+	}
+	data := make([]string, 0, len(units))
+	for _, u := range units {
+		data = append(data, u.Data)
+	}
+	return data, nil
+}
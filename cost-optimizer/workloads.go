@@ -0,0 +1,146 @@
+package costoptimizer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	"github.com/monadic/devops-examples/pkg/workloads"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// listWorkloads lists the workloads of all namespaces, with the pods and
+// ReplicaSets their metrics are attributed by and the HPAs scaling them, and
+// counts the nodes. Deployments must list; the other kinds are left out,
+// with a warning, when they can't be, so a narrower role or a replayed
+// snapshot still prices what it sees.
+func (c *CostOptimizer) listWorkloads(ctx context.Context) (workloads.Cluster, error) {
+	deployments, err := c.listDeployments(ctx)
+	if err != nil {
+		return workloads.Cluster{}, fmt.Errorf("list deployments: %w", err)
+	}
+	cluster := workloads.Cluster{Deployments: deployments.Items}
+	k8s := c.app.K8s.Clientset
+
+	if list, err := tracing.Call(ctx, "k8s.ListNodes", func() (*corev1.NodeList, error) {
+		return k8s.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list nodes", logging.Err(err))
+	} else {
+		cluster.Nodes = len(list.Items)
+	}
+	if list, err := tracing.Call(ctx, "k8s.ListStatefulSets", func() (*appsv1.StatefulSetList, error) {
+		return k8s.AppsV1().StatefulSets("").List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list StatefulSets", logging.Err(err))
+	} else {
+		cluster.StatefulSets = list.Items
+	}
+	if list, err := tracing.Call(ctx, "k8s.ListDaemonSets", func() (*appsv1.DaemonSetList, error) {
+		return k8s.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list DaemonSets", logging.Err(err))
+	} else {
+		cluster.DaemonSets = list.Items
+	}
+	if list, err := tracing.Call(ctx, "k8s.ListJobs", func() (*batchv1.JobList, error) {
+		return k8s.BatchV1().Jobs("").List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list Jobs", logging.Err(err))
+	} else {
+		cluster.Jobs = list.Items
+	}
+	if list, err := tracing.Call(ctx, "k8s.ListCronJobs", func() (*batchv1.CronJobList, error) {
+		return k8s.BatchV1().CronJobs("").List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list CronJobs", logging.Err(err))
+	} else {
+		cluster.CronJobs = list.Items
+	}
+	if list, err := tracing.Call(ctx, "k8s.ListPods", func() (*corev1.PodList, error) {
+		return k8s.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list pods", logging.Err(err))
+	} else {
+		cluster.Pods = list.Items
+	}
+	if list, err := tracing.Call(ctx, "k8s.ListReplicaSets", func() (*appsv1.ReplicaSetList, error) {
+		return k8s.AppsV1().ReplicaSets("").List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list ReplicaSets", logging.Err(err))
+	} else {
+		cluster.ReplicaSets = list.Items
+	}
+	if list, err := tracing.Call(ctx, "k8s.ListHorizontalPodAutoscalers", func() (*autoscalingv2.HorizontalPodAutoscalerList, error) {
+		return k8s.AutoscalingV2().HorizontalPodAutoscalers("").List(ctx, metav1.ListOptions{})
+	}); err != nil {
+		slog.Warn("Could not list HorizontalPodAutoscalers", logging.Err(err))
+	} else {
+		cluster.Autoscalers = list.Items
+	}
+	return cluster, nil
+}
+
+// analyzeWorkload analyzes a single workload's resource usage
+func (c *CostOptimizer) analyzeWorkload(workload workloads.Workload, pods []metricsv1beta1.PodMetrics) (ResourceUsage, bool) {
+	usage := ResourceUsage{
+		Name:             workload.Name,
+		Namespace:        workload.Namespace,
+		Type:             workload.Kind,
+		Replicas:         workload.Replicas,
+		CPURequested:     workload.CPUMillicores * int64(workload.Replicas),
+		MemRequested:     workload.MemoryBytes * int64(workload.Replicas),
+		StorageRequested: workload.StorageBytes * int64(workload.Replicas),
+	}
+	if workload.Kind == workloads.CronJob {
+		usage.DutyCycle = workload.DutyCycle
+	}
+
+	// Sum the usage of the workload's pods
+	actualCPU := int64(0)
+	actualMem := int64(0)
+	podCount := len(pods)
+	for _, podMetric := range pods {
+		for _, container := range podMetric.Containers {
+			if cpu := container.Usage.Cpu(); cpu != nil {
+				actualCPU += cpu.MilliValue()
+			}
+			if mem := container.Usage.Memory(); mem != nil {
+				actualMem += mem.Value()
+			}
+		}
+	}
+
+	// Use actual metrics if we found pods, otherwise fallback to simulated
+	if podCount > 0 {
+		usage.CPUUsed = actualCPU
+		usage.MemUsed = actualMem
+		slog.Debug("Using real metrics", "namespace", workload.Namespace, "kind", workload.Kind, "name", workload.Name,
+			"pods", podCount, "cpu_millicores", actualCPU, "memory_mib", actualMem/(1024*1024))
+	} else {
+		// No metrics found - use conservative estimate
+		usage.CPUUsed = usage.CPURequested / 2 // Simulate 50% usage as fallback
+		usage.MemUsed = usage.MemRequested / 2
+		slog.Debug("No metrics found, using estimated 50% utilization",
+			"namespace", workload.Namespace, "kind", workload.Kind, "name", workload.Name)
+	}
+
+	// Calculate utilization percentages
+	if usage.CPURequested > 0 {
+		usage.CPUUtilization = float64(usage.CPUUsed) / float64(usage.CPURequested) * 100
+	}
+	if usage.MemRequested > 0 {
+		usage.MemUtilization = float64(usage.MemUsed) / float64(usage.MemRequested) * 100
+	}
+
+	usage.MonthlyCost = c.pricing.MonthlyCost(requestedResources(usage))
+
+	return usage, (podCount > 0)
+}
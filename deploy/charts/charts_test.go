@@ -0,0 +1,438 @@
+package charts
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	costimpactmonitor "github.com/monadic/devops-examples/cost-impact-monitor"
+	costoptimizer "github.com/monadic/devops-examples/cost-optimizer"
+	driftdetector "github.com/monadic/devops-examples/drift-detector"
+	"github.com/monadic/devops-examples/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// apps pairs each chart with the app's config, so chart defaults can be
+// checked against the code
+var apps = []struct {
+	chart     string
+	defaults  interface{} // the app's DefaultConfig()
+	newConfig func() interface{}
+}{
+	{"drift-detector", driftdetector.DefaultConfig(), func() interface{} { return &driftdetector.Config{} }},
+	{"cost-optimizer", costoptimizer.DefaultConfig(), func() interface{} { return &costoptimizer.Config{} }},
+	{"cost-impact-monitor", costimpactmonitor.DefaultConfig(), func() interface{} { return &costimpactmonitor.Config{} }},
+}
+
+// secretValues are the values a chart needs to render at all
+var secretValues = map[string]interface{}{
+	"secrets": map[string]interface{}{"cubToken": "test-token"},
+}
+
+// manifest is one rendered Kubernetes object
+type manifest map[string]interface{}
+
+func (m manifest) kind() string { return m["kind"].(string) }
+
+func (m manifest) name() string {
+	meta, _ := m["metadata"].(map[string]interface{})
+	name, _ := meta["name"].(string)
+	return name
+}
+
+// render renders chart as release "demo" and decodes every object
+func render(t *testing.T, chart string, values map[string]interface{}) []manifest {
+	t.Helper()
+	files, err := Render(chart, Release{Name: "demo", Namespace: "devops-apps"}, values)
+	if err != nil {
+		t.Fatalf("render %s: %v", chart, err)
+	}
+
+	var objects []manifest
+	for name, text := range files {
+		dec := yaml.NewDecoder(strings.NewReader(text))
+		for {
+			var doc map[string]interface{} // a manifest would make nested maps manifests too
+			if err := dec.Decode(&doc); err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("%s is not valid YAML: %v\n%s", name, err, text)
+			}
+			if doc == nil {
+				continue
+			}
+			obj := manifest(doc)
+			if obj["apiVersion"] == nil || obj["kind"] == nil || obj.name() == "" {
+				t.Errorf("%s: object without apiVersion, kind or name:\n%s", name, text)
+				continue
+			}
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+func find(t *testing.T, objects []manifest, kind string) manifest {
+	t.Helper()
+	for _, obj := range objects {
+		if obj.kind() == kind {
+			return obj
+		}
+	}
+	t.Fatalf("no %s rendered", kind)
+	return nil
+}
+
+// get walks nested maps and lists by key or index
+func get(v interface{}, path ...interface{}) interface{} {
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			m, _ := v.(map[string]interface{})
+			v = m[p]
+		case int:
+			l, _ := v.([]interface{})
+			if p >= len(l) {
+				return nil
+			}
+			v = l[p]
+		}
+	}
+	return v
+}
+
+func container(t *testing.T, objects []manifest) map[string]interface{} {
+	t.Helper()
+	c, ok := get(map[string]interface{}(find(t, objects, "Deployment")), "spec", "template", "spec", "containers", 0).(map[string]interface{})
+	if !ok {
+		t.Fatal("deployment has no container")
+	}
+	return c
+}
+
+func env(c map[string]interface{}) map[string]interface{} {
+	vars := map[string]interface{}{}
+	for _, e := range get(c, "env").([]interface{}) {
+		e := e.(map[string]interface{})
+		if v, ok := e["value"]; ok {
+			vars[e["name"].(string)] = v
+		} else {
+			vars[e["name"].(string)] = e["valueFrom"]
+		}
+	}
+	return vars
+}
+
+func TestChartsRender(t *testing.T) {
+	for _, app := range apps {
+		t.Run(app.chart, func(t *testing.T) {
+			objects := render(t, app.chart, secretValues)
+			for _, kind := range []string{"Deployment", "ConfigMap", "Secret", "Service", "ServiceAccount", "ClusterRole", "ClusterRoleBinding"} {
+				find(t, objects, kind)
+			}
+
+			c := container(t, objects)
+			if c["image"] != app.chart+":"+chartAppVersion(t, app.chart) {
+				t.Errorf("image = %v, want the chart appVersion tag", c["image"])
+			}
+			vars := env(c)
+			if vars["CONFIG_FILE"] != "/etc/"+app.chart+"/config.yaml" {
+				t.Errorf("CONFIG_FILE = %v", vars["CONFIG_FILE"])
+			}
+			if get(vars["CUB_TOKEN"], "secretKeyRef", "name") != "demo-"+app.chart+"-secrets" {
+				t.Errorf("CUB_TOKEN not read from the release secret: %v", vars["CUB_TOKEN"])
+			}
+			if _, ok := vars["OTEL_EXPORTER_OTLP_ENDPOINT"]; ok {
+				t.Error("tracing enabled without an endpoint")
+			}
+		})
+	}
+}
+
+func TestChartsRequireToken(t *testing.T) {
+	for _, app := range apps {
+		if _, err := Render(app.chart, Release{Name: "demo"}, nil); err == nil || !strings.Contains(err.Error(), "cubToken") {
+			t.Errorf("%s rendered without a ConfigHub token (err=%v)", app.chart, err)
+		}
+		existing := map[string]interface{}{"secrets": map[string]interface{}{"existingSecret": "confighub"}}
+		objects := render(t, app.chart, existing)
+		for _, obj := range objects {
+			if obj.kind() == "Secret" {
+				t.Errorf("%s created a Secret although secrets.existingSecret is set", app.chart)
+			}
+		}
+		if ref := get(env(container(t, objects))["CUB_TOKEN"], "secretKeyRef", "name"); ref != "confighub" {
+			t.Errorf("%s: CUB_TOKEN from %v, want the existing secret", app.chart, ref)
+		}
+	}
+}
+
+func TestChartsExternalSecret(t *testing.T) {
+	values := map[string]interface{}{"secrets": map[string]interface{}{
+		"externalSecret": map[string]interface{}{"enabled": true},
+	}}
+	for _, app := range apps {
+		objects := render(t, app.chart, values)
+		for _, obj := range objects {
+			if obj.kind() == "Secret" {
+				t.Errorf("%s created a Secret although it is synced by an ExternalSecret", app.chart)
+			}
+		}
+		es := find(t, objects, "ExternalSecret")
+		if target := get(map[string]interface{}(es), "spec", "target", "name"); target != "demo-"+app.chart+"-secrets" {
+			t.Errorf("%s: ExternalSecret target = %v", app.chart, target)
+		}
+		c := container(t, objects)
+		if dir := env(c)["SECRETS_DIR"]; dir != "/var/run/secrets/"+app.chart {
+			t.Errorf("%s: SECRETS_DIR = %v", app.chart, dir)
+		}
+		if get(env(c)["CUB_TOKEN"], "secretKeyRef", "name") != "demo-"+app.chart+"-secrets" {
+			t.Errorf("%s: CUB_TOKEN not read from the synced secret", app.chart)
+		}
+	}
+}
+
+func TestChartsVault(t *testing.T) {
+	values := map[string]interface{}{"secrets": map[string]interface{}{
+		"vault": map[string]interface{}{"enabled": true, "path": "secret/data/apps"},
+	}}
+	for _, app := range apps {
+		objects := render(t, app.chart, values)
+		for _, obj := range objects {
+			if obj.kind() == "Secret" {
+				t.Errorf("%s created a Secret although Vault injects the tokens", app.chart)
+			}
+		}
+		deployment := map[string]interface{}(find(t, objects, "Deployment"))
+		annotations, _ := get(deployment, "spec", "template", "metadata", "annotations").(map[string]interface{})
+		if annotations["vault.hashicorp.com/agent-inject"] != "true" || annotations["vault.hashicorp.com/role"] != app.chart {
+			t.Errorf("%s: annotations = %v", app.chart, annotations)
+		}
+		want := `{{ with secret "secret/data/apps" }}{{ with index .Data.data "cub-token" }}{{ . }}{{ end }}{{ end }}`
+		if got := annotations["vault.hashicorp.com/agent-inject-template-cub-token"]; got != want {
+			t.Errorf("%s: cub-token template = %v", app.chart, got)
+		}
+
+		vars := env(container(t, objects))
+		if vars["SECRETS_DIR"] != "/vault/secrets" {
+			t.Errorf("%s: SECRETS_DIR = %v", app.chart, vars["SECRETS_DIR"])
+		}
+		if _, ok := vars["CUB_TOKEN"]; ok {
+			t.Errorf("%s: CUB_TOKEN read from a Secret in Vault mode", app.chart)
+		}
+	}
+}
+
+// TestChartDefaultsMatchCode loads each chart's config.yaml the way the app
+// does: every key must be one the app knows, and with nothing overridden the
+// values must equal the app's DefaultConfig
+func TestChartDefaultsMatchCode(t *testing.T) {
+	for _, app := range apps {
+		t.Run(app.chart, func(t *testing.T) {
+			cfg := app.newConfig()
+			effective := loadChartConfig(t, render(t, app.chart, secretValues), cfg)
+
+			if got := reflect.ValueOf(cfg).Elem().Interface(); !reflect.DeepEqual(got, app.defaults) {
+				t.Errorf("chart config differs from DefaultConfig:\nchart: %+v\ncode:  %+v", got, app.defaults)
+			}
+			secrets := secretKeys(cfg)
+			for _, s := range effective.Settings {
+				if s.Source != config.FromFile && !secrets[s.Key] {
+					t.Errorf("chart config does not set %s", s.Key)
+				}
+				if s.Source == config.FromFile && secrets[s.Key] {
+					t.Errorf("secret %s is in the ConfigMap", s.Key)
+				}
+			}
+		})
+	}
+}
+
+func TestValuesOverrideConfig(t *testing.T) {
+	objects := render(t, "drift-detector", map[string]interface{}{
+		"secrets":   map[string]interface{}{"cubToken": "t", "claudeApiKey": "k"},
+		"config":    map[string]interface{}{"cub_space": "prod", "auto_fix": true},
+		"notify":    map[string]interface{}{"routes": []interface{}{map[string]interface{}{"channel": "slack"}}},
+		"logging":   map[string]interface{}{"format": "json"},
+		"tracing":   map[string]interface{}{"otlpEndpoint": "http://collector:4318"},
+		"resources": map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}},
+	})
+
+	var cfg driftdetector.Config
+	loadChartConfig(t, objects, &cfg)
+	want := driftdetector.DefaultConfig()
+	want.Space, want.AutoFix = "prod", true
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+
+	data := get(map[string]interface{}(find(t, objects, "ConfigMap")), "data").(map[string]interface{})
+	if !strings.Contains(data["notify.yaml"].(string), "channel: slack") {
+		t.Errorf("notify.yaml = %q", data["notify.yaml"])
+	}
+	secret := get(map[string]interface{}(find(t, objects, "Secret")), "stringData").(map[string]interface{})
+	if secret["cub-token"] != "t" || secret["claude-api-key"] != "k" {
+		t.Errorf("secret = %v", secret)
+	}
+
+	c := container(t, objects)
+	vars := env(c)
+	if vars["LOG_FORMAT"] != "json" || vars["OTEL_EXPORTER_OTLP_ENDPOINT"] != "http://collector:4318" {
+		t.Errorf("env = %v", vars)
+	}
+	if get(c, "resources", "limits", "memory") != "1Gi" || get(c, "resources", "requests", "cpu") != "100m" {
+		t.Errorf("resources = %v, want limits overridden and requests kept", c["resources"])
+	}
+}
+
+func TestCostImpactMonitorLeaderElection(t *testing.T) {
+	objects := render(t, "cost-impact-monitor", secretValues)
+	for _, obj := range objects {
+		if obj.kind() == "Role" {
+			t.Error("lease Role rendered without leader election")
+		}
+	}
+
+	objects = render(t, "cost-impact-monitor", map[string]interface{}{
+		"secrets":      map[string]interface{}{"cubToken": "t"},
+		"replicaCount": 2,
+		"config":       map[string]interface{}{"leader_elect": true},
+	})
+	role := find(t, objects, "Role")
+	if get(map[string]interface{}(role), "rules", 0, "apiGroups", 0) != "coordination.k8s.io" {
+		t.Errorf("lease role rules = %v", role["rules"])
+	}
+	vars := env(container(t, objects))
+	if get(vars["POD_NAME"], "fieldRef", "fieldPath") != "metadata.name" {
+		t.Errorf("POD_NAME = %v, want the downward API", vars["POD_NAME"])
+	}
+}
+
+// loadChartConfig runs the rendered config.yaml through the app's config
+// loader with the app's environment variables unset
+func loadChartConfig(t *testing.T, objects []manifest, cfg interface{}) *config.Effective {
+	t.Helper()
+	data, _ := get(map[string]interface{}(find(t, objects, "ConfigMap")), "data", "config.yaml").(string)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	typ := reflect.TypeOf(cfg).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		if name := typ.Field(i).Tag.Get("env"); name != "" {
+			t.Setenv(name, "") // restored after the test
+			os.Unsetenv(name)
+		}
+	}
+
+	effective, err := config.Load(path, cfg)
+	if err != nil {
+		t.Fatalf("app rejects the chart config: %v\n%s", err, data)
+	}
+	return effective
+}
+
+func secretKeys(cfg interface{}) map[string]bool {
+	keys := map[string]bool{}
+	typ := reflect.TypeOf(cfg).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.Tag.Get("secret") == "true" {
+			keys[f.Tag.Get("yaml")] = true
+		}
+	}
+	return keys
+}
+
+func chartAppVersion(t *testing.T, chart string) string {
+	t.Helper()
+	var c Chart
+	if err := readYAML(filepath.Join(chart, "Chart.yaml"), &c); err != nil {
+		t.Fatal(err)
+	}
+	return c.AppVersion
+}
+
+// TestChartsTeams renders teams.yaml and, in Vault mode, injects each team's tokens
+func TestChartsTeams(t *testing.T) {
+	values := map[string]interface{}{
+		"secrets": map[string]interface{}{"vault": map[string]interface{}{"enabled": true}},
+		"teams": map[string]interface{}{"teams": []interface{}{
+			map[string]interface{}{"name": "payments", "spaces": []interface{}{"payments-prod"}, "namespaces": []interface{}{"payments"}},
+		}},
+	}
+	for _, chart := range []string{"drift-detector", "cost-impact-monitor"} {
+		objects := render(t, chart, values)
+		data, _ := get(map[string]interface{}(find(t, objects, "ConfigMap")), "data").(map[string]interface{})
+		if teams, _ := data["teams.yaml"].(string); !strings.Contains(teams, "payments-prod") {
+			t.Errorf("%s: teams.yaml = %q", chart, teams)
+		}
+		deployment := map[string]interface{}(find(t, objects, "Deployment"))
+		annotations, _ := get(deployment, "spec", "template", "metadata", "annotations").(map[string]interface{})
+		for _, key := range []string{"payments-cub-token", "payments-api-token"} {
+			if _, ok := annotations["vault.hashicorp.com/agent-inject-secret-"+key]; !ok {
+				t.Errorf("%s: %s not injected", chart, key)
+			}
+		}
+	}
+}
+
+// TestChartsAuth renders auth.yaml and, in Vault mode, injects the OIDC client secret
+func TestChartsAuth(t *testing.T) {
+	values := map[string]interface{}{
+		"secrets": map[string]interface{}{"vault": map[string]interface{}{"enabled": true}},
+		"auth":    map[string]interface{}{"issuer_url": "https://login.example.com", "client_id": "devops-apps"},
+	}
+	for _, app := range apps {
+		objects := render(t, app.chart, values)
+		data, _ := get(map[string]interface{}(find(t, objects, "ConfigMap")), "data").(map[string]interface{})
+		if auth, _ := data["auth.yaml"].(string); !strings.Contains(auth, "login.example.com") {
+			t.Errorf("%s: auth.yaml = %q", app.chart, auth)
+		}
+		deployment := map[string]interface{}(find(t, objects, "Deployment"))
+		annotations, _ := get(deployment, "spec", "template", "metadata", "annotations").(map[string]interface{})
+		if _, ok := annotations["vault.hashicorp.com/agent-inject-secret-oidc-client-secret"]; !ok {
+			t.Errorf("%s: oidc-client-secret not injected", app.chart)
+		}
+	}
+}
+
+// TestChartsMetricsSinks renders metrics-sinks.yaml and puts the sink keys in the Secret
+func TestChartsMetricsSinks(t *testing.T) {
+	values := map[string]interface{}{
+		"secrets":      map[string]interface{}{"cubToken": "t", "datadogApiKey": "dd-key"},
+		"metricsSinks": map[string]interface{}{"tags": map[string]interface{}{"env": "prod"}, "datadog": map[string]interface{}{"site": "datadoghq.eu"}},
+	}
+	for _, app := range apps {
+		objects := render(t, app.chart, values)
+		data, _ := get(map[string]interface{}(find(t, objects, "ConfigMap")), "data").(map[string]interface{})
+		if sinks, _ := data["metrics-sinks.yaml"].(string); !strings.Contains(sinks, "datadoghq.eu") {
+			t.Errorf("%s: metrics-sinks.yaml = %q", app.chart, sinks)
+		}
+		if key := get(map[string]interface{}(find(t, objects, "Secret")), "stringData", "datadog-api-key"); key != "dd-key" {
+			t.Errorf("%s: datadog-api-key = %v", app.chart, key)
+		}
+	}
+}
+
+// TestChartsExport renders export.yaml and puts the bucket keys in the Secret
+func TestChartsExport(t *testing.T) {
+	values := map[string]interface{}{
+		"secrets": map[string]interface{}{"cubToken": "t", "exportAccessKeyId": "AKID", "exportSecretAccessKey": "s"},
+		"export":  map[string]interface{}{"bucket": "s3://devops-exports/prod", "format": "jsonl"},
+	}
+	for _, chart := range []string{"drift-detector", "cost-impact-monitor"} {
+		objects := render(t, chart, values)
+		data, _ := get(map[string]interface{}(find(t, objects, "ConfigMap")), "data").(map[string]interface{})
+		if export, _ := data["export.yaml"].(string); !strings.Contains(export, "s3://devops-exports/prod") {
+			t.Errorf("%s: export.yaml = %q", chart, export)
+		}
+		if key := get(map[string]interface{}(find(t, objects, "Secret")), "stringData", "export-access-key-id"); key != "AKID" {
+			t.Errorf("%s: export-access-key-id = %v", chart, key)
+		}
+	}
+}
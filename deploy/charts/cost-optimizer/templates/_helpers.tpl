@@ -0,0 +1,32 @@
+{{/* Full name of the release's resources */}}
+{{- define "cost-optimizer.fullname" -}}
+{{- if contains .Chart.Name .Release.Name }}
+{{- .Release.Name | trunc 63 | trimSuffix "-" }}
+{{- else }}
+{{- printf "%s-%s" .Release.Name .Chart.Name | trunc 63 | trimSuffix "-" }}
+{{- end }}
+{{- end }}
+
+{{- define "cost-optimizer.selectorLabels" -}}
+app.kubernetes.io/name: {{ .Chart.Name }}
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end }}
+
+{{- define "cost-optimizer.labels" -}}
+{{ include "cost-optimizer.selectorLabels" . }}
+app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+app.kubernetes.io/managed-by: {{ .Release.Service }}
+helm.sh/chart: {{ printf "%s-%s" .Chart.Name .Chart.Version }}
+{{- end }}
+
+{{- define "cost-optimizer.serviceAccountName" -}}
+{{- if .Values.serviceAccount.create }}
+{{- default (include "cost-optimizer.fullname" .) .Values.serviceAccount.name }}
+{{- else }}
+{{- default "default" .Values.serviceAccount.name }}
+{{- end }}
+{{- end }}
+
+{{- define "cost-optimizer.secretName" -}}
+{{- default (printf "%s-secrets" (include "cost-optimizer.fullname" .)) .Values.secrets.existingSecret }}
+{{- end }}
@@ -0,0 +1,160 @@
+// Package charts renders the Helm charts of the DevOps apps kept in this
+// directory. Render follows `helm template` closely enough to check the
+// charts in plain Go tests, without a helm binary or cluster: templates get
+// the sprig functions plus include, required, toYaml and fromYaml, and see
+// .Values, .Release, .Chart and .Template as they would under Helm.
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Release names the install the chart is rendered for
+type Release struct {
+	Name      string
+	Namespace string
+}
+
+// Chart is the part of Chart.yaml templates use
+type Chart struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	AppVersion  string `yaml:"appVersion"`
+	Description string `yaml:"description"`
+}
+
+// Render renders the chart in dir with its values.yaml overlaid by values,
+// like `helm template --set`. The result maps template paths, such as
+// "drift-detector/templates/deployment.yaml", to manifests; partials and
+// templates that render empty are left out.
+func Render(dir string, release Release, values map[string]interface{}) (map[string]string, error) {
+	var chart Chart
+	if err := readYAML(filepath.Join(dir, "Chart.yaml"), &chart); err != nil {
+		return nil, err
+	}
+	defaults := map[string]interface{}{}
+	if err := readYAML(filepath.Join(dir, "values.yaml"), &defaults); err != nil {
+		return nil, err
+	}
+	merged := mergeValues(defaults, values)
+
+	files, err := filepath.Glob(filepath.Join(dir, "templates", "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	tmpl := template.New(chart.Name).Option("missingkey=zero")
+	tmpl.Funcs(funcMap(tmpl))
+	var names []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := path.Join(chart.Name, "templates", filepath.Base(file))
+		if _, err := tmpl.New(name).Parse(string(data)); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(filepath.Base(file), "_") {
+			names = append(names, name)
+		}
+	}
+
+	if release.Namespace == "" {
+		release.Namespace = "default"
+	}
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		var buf bytes.Buffer
+		err := tmpl.ExecuteTemplate(&buf, name, map[string]interface{}{
+			"Values":   merged,
+			"Chart":    chart,
+			"Release":  map[string]interface{}{"Name": release.Name, "Namespace": release.Namespace, "Service": "Helm"},
+			"Template": map[string]interface{}{"Name": name, "BasePath": path.Join(chart.Name, "templates")},
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifest := strings.ReplaceAll(buf.String(), "<no value>", "")
+		if strings.TrimSpace(manifest) != "" {
+			out[name] = manifest
+		}
+	}
+	return out, nil
+}
+
+func funcMap(tmpl *template.Template) template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	// Helm leaves the environment out of templates
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+
+	funcs["include"] = func(name string, data interface{}) (string, error) {
+		var buf bytes.Buffer
+		err := tmpl.ExecuteTemplate(&buf, name, data)
+		return buf.String(), err
+	}
+	funcs["required"] = func(msg string, v interface{}) (interface{}, error) {
+		if v == nil || v == "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return v, nil
+	}
+	funcs["toYaml"] = func(v interface{}) string {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	funcs["fromYaml"] = func(s string) map[string]interface{} {
+		m := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+			m["Error"] = err.Error()
+		}
+		return m
+	}
+	return funcs
+}
+
+// mergeValues overlays src on dst; nested maps merge, anything else replaces
+func mergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(dst))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, v := range src {
+		if srcMap, ok := v.(map[string]interface{}); ok {
+			if dstMap, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(dstMap, srcMap)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func readYAML(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", file, err)
+	}
+	return nil
+}
@@ -21,7 +21,7 @@ NC='\033[0m'
 # Configuration
 SPACE=${1:-drift-test-demo}
 NAMESPACE=${2:-drift-test}
-API_ENDPOINT=${3:-http://localhost:8082/api/live}
+API_ENDPOINT=${3:-http://localhost:8082/api/v1/live}
 
 echo "Configuration:"
 echo "  ConfigHub Space: $SPACE"
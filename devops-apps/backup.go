@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/backup"
+	"github.com/monadic/devops-examples/pkg/logging"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// runBackup saves the apps' units and the sets of a space to a tarball
+func runBackup(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var (
+		space = flags.String("space", os.Getenv("CONFIGHUB_SPACE_ID"), "slug or ID of the space to back up")
+		out   = flags.String("o", "", "archive to write (default <space>-<date>.tar.gz)")
+		all   = flags.Bool("all", false, "save every unit of the space, not just those the apps created")
+	)
+	flags.Parse(args)
+	logging.Setup("backup")
+	if *space == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s -space <space> [-o file] [-all]\n", name)
+		os.Exit(2)
+	}
+
+	archive, err := backup.Export(hubFromEnv(), *space, *all)
+	if err != nil {
+		logging.Fatal("Backup failed", logging.Space(*space), logging.Err(err))
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("%s-%s.tar.gz", *space, time.Now().Format("20060102-150405"))
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		logging.Fatal("Failed to create archive", logging.Err(err))
+	}
+	if err := archive.Write(f); err != nil {
+		f.Close()
+		logging.Fatal("Failed to write archive", logging.Err(err))
+	}
+	if err := f.Close(); err != nil {
+		logging.Fatal("Failed to write archive", logging.Err(err))
+	}
+	fmt.Printf("Saved %d units and %d sets of %s to %s\n", archive.Manifest.Units, archive.Manifest.Sets, *space, *out)
+}
+
+// runRestore writes a tarball from runBackup into a space
+func runRestore(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var (
+		space     = flags.String("space", "", "slug of the space to restore into, created when missing (default: the space backed up)")
+		overwrite = flags.Bool("overwrite", false, "replace the data and labels of units that already exist")
+	)
+	flags.Parse(args)
+	logging.Setup("restore")
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-space <space>] [-overwrite] <archive.tar.gz>\n", name)
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		logging.Fatal("Failed to open archive", logging.Err(err))
+	}
+	archive, err := backup.Read(f)
+	f.Close()
+	if err != nil {
+		logging.Fatal("Failed to read archive", logging.Err(err))
+	}
+	if *space == "" {
+		*space = archive.Manifest.Space
+	}
+
+	result, err := backup.Restore(hubFromEnv(), archive, *space, *overwrite)
+	if err != nil {
+		logging.Fatal("Restore failed", logging.Space(*space), logging.Err(err))
+	}
+	data, _ := json.Marshal(result)
+	fmt.Printf("Restored %s into %s: %s\n", flags.Arg(0), *space, data)
+}
+
+// hubFromEnv connects to ConfigHub with CUB_API_URL and CUB_TOKEN
+func hubFromEnv() backup.Hub {
+	return sdkHub{cubFromEnv()}
+}
+
+// cubFromEnv returns a ConfigHub client for CUB_API_URL and CUB_TOKEN
+func cubFromEnv() *sdk.ConfigHubClient {
+	return sdk.NewConfigHubClient(sdk.GetEnvOrDefault("CUB_API_URL", "https://hub.confighub.com/api"), os.Getenv("CUB_TOKEN"))
+}
+
+// sdkHub is a backup.Hub over the SDK's ConfigHub client
+type sdkHub struct{ cub *sdk.ConfigHubClient }
+
+func (h sdkHub) FindSpace(ref string) (backup.Space, bool, error) {
+	spaces, err := h.cub.ListSpaces()
+	if err != nil {
+		return backup.Space{}, false, err
+	}
+	for _, s := range spaces {
+		if s.Slug == ref || s.SpaceID.String() == ref {
+			return backup.Space{ID: s.SpaceID, Labels: s.Labels}, true, nil
+		}
+	}
+	return backup.Space{}, false, nil
+}
+
+func (h sdkHub) CreateSpace(slug string, labels map[string]string) (uuid.UUID, error) {
+	space, err := h.cub.CreateSpace(sdk.CreateSpaceRequest{Slug: slug, DisplayName: slug, Labels: labels})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return space.SpaceID, nil
+}
+
+func (h sdkHub) Sets(space uuid.UUID) ([]backup.Set, error) {
+	sets, err := h.cub.ListSets(space)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]backup.Set, 0, len(sets))
+	for _, s := range sets {
+		out = append(out, backup.Set{ID: s.SetID, Slug: s.Slug, DisplayName: s.DisplayName, Labels: s.Labels})
+	}
+	return out, nil
+}
+
+func (h sdkHub) CreateSet(space uuid.UUID, set backup.Set) (uuid.UUID, error) {
+	created, err := h.cub.CreateSet(space, sdk.CreateSetRequest{Slug: set.Slug, DisplayName: set.DisplayName, Labels: set.Labels})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return created.SetID, nil
+}
+
+func (h sdkHub) Units(space uuid.UUID) ([]backup.Unit, error) {
+	units, err := h.cub.ListUnits(sdk.ListUnitsParams{SpaceID: space})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]backup.Unit, 0, len(units))
+	for _, u := range units {
+		out = append(out, backup.Unit{ID: u.UnitID, SetIDs: u.SetIDs, Slug: u.Slug, DisplayName: u.DisplayName, Labels: u.Labels, Data: u.Data})
+	}
+	return out, nil
+}
+
+func (h sdkHub) CreateUnit(space uuid.UUID, unit backup.Unit, setIDs []uuid.UUID) error {
+	_, err := h.cub.CreateUnit(space, sdk.CreateUnitRequest{
+		Slug: unit.Slug, DisplayName: unit.DisplayName, Data: unit.Data, Labels: unit.Labels, SetIDs: setIDs,
+	})
+	return err
+}
+
+func (h sdkHub) UpdateUnit(space, unit uuid.UUID, data string, labels map[string]string) error {
+	_, err := h.cub.UpdateUnit(space, unit, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/shared"
+)
+
+// combinable are the apps "combined" can run together, by command, with
+// the names they go by. They share the ConfigHub rate limiter, circuit
+// breaker and listing cache, one metrics registry and one readiness report
+// (see pkg/shared).
+var combinable = []struct{ command, app string }{
+	{"drift", "drift-detector"},
+	{"cost", "cost-optimizer"},
+	{"impact", "cost-impact-monitor"},
+}
+
+// combined runs other commands, so it joins commands once that is initialized
+func init() {
+	commands["combined"] = command{"run drift, cost and impact in one process, sharing clients and caches", runCombined}
+}
+
+// runCombined runs several apps as goroutines of one process, for small
+// clusters and demos. It returns once all of them have stopped.
+func runCombined(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	list := flags.String("apps", strings.Join(combinableCommands(), ","), "comma-separated apps to run; the first keeps its health port")
+	flags.Parse(args)
+	apps, err := combinedApps(*list)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(2)
+	}
+
+	for _, c := range combinable {
+		if c.command == apps[0] {
+			shared.Enable(c.app)
+		}
+	}
+	logging.Setup(shared.App)
+	slog.Info("Running apps in one process", "apps", strings.Join(apps, ","))
+
+	// The apps read os.Args for their own subcommands, such as "cost demo"
+	os.Args = []string{name}
+	var wg sync.WaitGroup
+	for _, app := range apps {
+		wg.Add(1)
+		go func(app string) {
+			defer wg.Done()
+			commands[app].run("devops-apps "+app, nil)
+			slog.Info("App stopped", "app", app)
+		}(app)
+	}
+	wg.Wait()
+}
+
+// combinedApps parses the -apps list, dropping repeats
+func combinedApps(list string) ([]string, error) {
+	var apps []string
+	seen := map[string]bool{}
+	for _, app := range strings.Split(list, ",") {
+		app = strings.TrimSpace(app)
+		if app == "" || seen[app] {
+			continue
+		}
+		ok := false
+		for _, c := range combinable {
+			ok = ok || c.command == app
+		}
+		if !ok {
+			return nil, fmt.Errorf("%q cannot run combined (one of %s)", app, strings.Join(combinableCommands(), ", "))
+		}
+		seen[app] = true
+		apps = append(apps, app)
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no apps given")
+	}
+	return apps, nil
+}
+
+func combinableCommands() []string {
+	commands := make([]string, len(combinable))
+	for i, c := range combinable {
+		commands[i] = c.command
+	}
+	return commands
+}
@@ -0,0 +1,110 @@
+module github.com/monadic/devops-examples/devops-apps
+
+go 1.21
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/monadic/devops-examples/backup-monitor v0.0.0
+	github.com/monadic/devops-examples/cert-expiry-monitor v0.0.0
+	github.com/monadic/devops-examples/compliance-checker v0.0.0
+	github.com/monadic/devops-examples/control-panel v0.0.0
+	github.com/monadic/devops-examples/cost-impact-monitor v0.0.0
+	github.com/monadic/devops-examples/cost-optimizer v0.0.0
+	github.com/monadic/devops-examples/drift-detector v0.0.0
+	github.com/monadic/devops-examples/orphan-cleaner v0.0.0
+	github.com/monadic/devops-examples/pkg v0.0.0
+	github.com/monadic/devops-examples/quota-advisor v0.0.0
+	github.com/monadic/devops-examples/secret-rotation-monitor v0.0.0
+	github.com/monadic/devops-examples/security-drift-detector v0.0.0
+	github.com/monadic/devops-examples/slo-monitor v0.0.0
+	github.com/monadic/devops-examples/upgrade-advisor v0.0.0
+	github.com/monadic/devops-sdk v0.1.0
+	k8s.io/client-go v0.29.0
+	k8s.io/metrics v0.29.0
+)
+
+require (
+	github.com/cenkalti/backoff/v4 v4.2.1 // indirect
+	github.com/coreos/go-oidc/v3 v3.11.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/emicklei/go-restful/v3 v3.11.0 // indirect
+	github.com/go-jose/go-jose/v4 v4.0.2 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/go-openapi/jsonpointer v0.19.6 // indirect
+	github.com/go-openapi/jsonreference v0.20.2 // indirect
+	github.com/go-openapi/swag v0.22.3 // indirect
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/golang/protobuf v1.5.4 // indirect
+	github.com/google/gnostic-models v0.6.8 // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/google/gofuzz v1.2.0 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.19.0 // indirect
+	github.com/imdario/mergo v0.3.6 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	go.opentelemetry.io/otel v1.24.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.24.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.24.0 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	go.opentelemetry.io/otel/sdk v1.24.0 // indirect
+	go.opentelemetry.io/otel/trace v1.24.0 // indirect
+	go.opentelemetry.io/proto/otlp v1.1.0 // indirect
+	golang.org/x/crypto v0.25.0 // indirect
+	golang.org/x/net v0.27.0 // indirect
+	golang.org/x/oauth2 v0.21.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	golang.org/x/term v0.22.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	golang.org/x/time v0.5.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20240123012728-ef4313101c80 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
+	google.golang.org/grpc v1.62.1 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+	gopkg.in/inf.v0 v0.9.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	k8s.io/api v0.29.0 // indirect
+	k8s.io/apimachinery v0.29.0 // indirect
+	k8s.io/klog/v2 v2.110.1 // indirect
+	k8s.io/kube-openapi v0.0.0-20231010175941-2dd684a91f00 // indirect
+	k8s.io/utils v0.0.0-20230726121419-3b25d923346b // indirect
+	sigs.k8s.io/json v0.0.0-20221116044647-bc3834ca7abd // indirect
+	sigs.k8s.io/structured-merge-diff/v4 v4.4.1 // indirect
+	sigs.k8s.io/yaml v1.3.0 // indirect
+)
+
+replace github.com/monadic/devops-sdk => ../../devops-sdk
+
+replace github.com/monadic/devops-examples/pkg => ../pkg
+
+replace github.com/monadic/devops-examples/drift-detector => ../drift-detector
+
+replace github.com/monadic/devops-examples/cost-optimizer => ../cost-optimizer
+
+replace github.com/monadic/devops-examples/cost-impact-monitor => ../cost-impact-monitor
+
+replace github.com/monadic/devops-examples/control-panel => ../control-panel
+
+replace github.com/monadic/devops-examples/security-drift-detector => ../security-drift-detector
+
+replace github.com/monadic/devops-examples/compliance-checker => ../compliance-checker
+
+replace github.com/monadic/devops-examples/orphan-cleaner => ../orphan-cleaner
+
+replace github.com/monadic/devops-examples/cert-expiry-monitor => ../cert-expiry-monitor
+
+replace github.com/monadic/devops-examples/quota-advisor => ../quota-advisor
+
+replace github.com/monadic/devops-examples/slo-monitor => ../slo-monitor
+
+replace github.com/monadic/devops-examples/upgrade-advisor => ../upgrade-advisor
+
+replace github.com/monadic/devops-examples/secret-rotation-monitor => ../secret-rotation-monitor
+replace github.com/monadic/devops-examples/backup-monitor => ../backup-monitor
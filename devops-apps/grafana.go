@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+)
+
+// runGrafana writes the Grafana dashboard of the apps' metrics, ready to
+// import or to provision from a ConfigMap
+func runGrafana(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var (
+		out        = flags.String("o", "-", "file to write, - for stdout")
+		title      = flags.String("title", "", `dashboard title (default "DevOps apps")`)
+		uid        = flags.String("uid", "", `dashboard UID; importing the same UID replaces it (default "devops-apps")`)
+		datasource = flags.String("datasource", "", "UID of the Prometheus data source to select (default: Grafana's default)")
+	)
+	flags.Parse(args)
+
+	data, err := metrics.Grafana(metrics.GrafanaOptions{Title: *title, UID: *uid, Datasource: *datasource})
+	if err != nil {
+		logging.Fatal("Failed to generate the dashboard", logging.Err(err))
+	}
+	data = append(data, '\n')
+	if *out == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		logging.Fatal("Failed to write the dashboard", logging.Err(err))
+	}
+	fmt.Printf("Wrote the dashboard to %s, import it in Grafana under Dashboards > New > Import\n", *out)
+}
@@ -0,0 +1,190 @@
+// Command devops-apps runs every DevOps example app from one binary:
+//
+//	devops-apps [shared flags] <command> [arguments]
+//
+// The commands are the apps' own mains, so each behaves exactly like the
+// standalone binary. Shared flags are handed to the app as the environment
+// variables it already reads, which keeps env-only deployments working.
+// With -replay the app runs against a recorded snapshot instead of the live
+// cluster and ConfigHub (see pkg/snapshot). "combined" runs the drift
+// detector, cost optimizer and cost impact monitor in one process, sharing
+// their ConfigHub budget, caches, metrics and readiness report.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	backupmonitor "github.com/monadic/devops-examples/backup-monitor"
+	certmonitor "github.com/monadic/devops-examples/cert-expiry-monitor"
+	compliancechecker "github.com/monadic/devops-examples/compliance-checker"
+	controlpanel "github.com/monadic/devops-examples/control-panel"
+	costimpactmonitor "github.com/monadic/devops-examples/cost-impact-monitor"
+	costoptimizer "github.com/monadic/devops-examples/cost-optimizer"
+	"github.com/monadic/devops-examples/cost-optimizer/analyze"
+	driftdetector "github.com/monadic/devops-examples/drift-detector"
+	orphancleaner "github.com/monadic/devops-examples/orphan-cleaner"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/snapshot"
+	quotaadvisor "github.com/monadic/devops-examples/quota-advisor"
+	secretrotation "github.com/monadic/devops-examples/secret-rotation-monitor"
+	securitydrift "github.com/monadic/devops-examples/security-drift-detector"
+	slomonitor "github.com/monadic/devops-examples/slo-monitor"
+	upgradeadvisor "github.com/monadic/devops-examples/upgrade-advisor"
+)
+
+// command is one app reachable as a subcommand
+type command struct {
+	summary string
+	run     func(name string, args []string)
+}
+
+var commands = map[string]command{
+	"drift": {"detect and fix Kubernetes drift against ConfigHub", func(string, []string) {
+		driftdetector.Main()
+	}},
+	"cost": {"recommend and apply cost optimizations (\"cost demo\" runs the demo)", func(string, []string) {
+		costoptimizer.Main()
+	}},
+	"impact": {"predict the cost impact of ConfigHub changes across spaces", func(string, []string) {
+		costimpactmonitor.Main()
+	}},
+	"analyze": {"estimate the cost of a space's units before deploying them", analyze.Main},
+	"panel": {"one dashboard over the other apps of every cluster", func(string, []string) {
+		controlpanel.Main()
+	}},
+	"security": {"report and undo security-relevant changes made outside ConfigHub", func(string, []string) {
+		securitydrift.Main()
+	}},
+	"compliance": {"check units and live workloads against compliance rule packs", func(string, []string) {
+		compliancechecker.Main()
+	}},
+	"orphans": {"find resources no unit describes and clean them up on approval", func(string, []string) {
+		orphancleaner.Main()
+	}},
+	"certs": {"alert ahead of TLS certificate expiry and propose renewal patches", func(string, []string) {
+		certmonitor.Main()
+	}},
+	"quotas": {"predict ResourceQuota exhaustion and recommend new limits", func(string, []string) {
+		quotaadvisor.Main()
+	}},
+	"slo": {"track SLO error budgets against ConfigHub changes and optimizations", func(string, []string) {
+		slomonitor.Main()
+	}},
+	"upgrade": {"find deprecated Kubernetes APIs in units and propose their migration", func(string, []string) {
+		upgradeadvisor.Main()
+	}},
+	"secrets": {"track the age of referenced Secrets and drive their rotation", func(string, []string) {
+		secretrotation.Main()
+	}},
+	"backups": {"verify Velero backups cover the namespaces units deploy to", func(string, []string) {
+		backupmonitor.Main()
+	}},
+	"backup":   {"save the units and sets the apps created in a space to a tarball", runBackup},
+	"restore":  {"restore a backup into a space, e.g. a new one to clone an environment", runRestore},
+	"set":      {"create and list sets and add or remove their units (cub has no set commands)", runSet},
+	"snapshot": {"record the cluster and ConfigHub spaces to a file for -replay", runSnapshot},
+	"grafana":  {"write a Grafana dashboard of the apps' metrics to import", runGrafana},
+}
+
+// sharedFlags maps each shared flag to the environment variable the apps read
+var sharedFlags = []struct {
+	name, env, usage string
+}{
+	{"config", "CONFIG_FILE", "app config file"},
+	{"notify-config", "NOTIFY_CONFIG", "notification routing config file"},
+	{"cub-url", "CUB_API_URL", "ConfigHub API URL"},
+	{"space", "CONFIGHUB_SPACE_ID", "ConfigHub space ID for cost and analyze"},
+	{"replay", snapshot.EnvVar, "snapshot to run against instead of the live cluster and ConfigHub"},
+	{"mode", runmode.EnvVar, "service, or job to run one analysis, print it and exit"},
+	{"job-space", runmode.SpaceEnvVar, "ConfigHub space job results are stored in"},
+}
+
+// invocation is a parsed command line
+type invocation struct {
+	command string
+	args    []string          // arguments after the command
+	env     map[string]string // variables set by shared flags
+}
+
+func main() {
+	inv, err := parse(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "devops-apps: %v\n", err)
+		os.Exit(2)
+	}
+
+	for k, v := range inv.env {
+		os.Setenv(k, v)
+	}
+	if path := os.Getenv(snapshot.EnvVar); path != "" && inv.command != "snapshot" {
+		defer startReplay(path)()
+	}
+
+	// The apps read os.Args themselves (e.g. "cost demo"), so present the
+	// subcommand as the program name
+	name := "devops-apps " + inv.command
+	os.Args = append([]string{name}, inv.args...)
+	commands[inv.command].run(name, inv.args)
+}
+
+// parse reads the shared flags and the command from args
+func parse(args []string, output io.Writer) (invocation, error) {
+	flags := flag.NewFlagSet("devops-apps", flag.ContinueOnError)
+	flags.SetOutput(output)
+	values := make(map[string]*string, len(sharedFlags))
+	for _, f := range sharedFlags {
+		values[f.env] = flags.String(f.name, "", f.usage+" (sets "+f.env+")")
+	}
+	flags.Usage = func() { usage(flags) }
+
+	if err := flags.Parse(args); err != nil {
+		return invocation{}, err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return invocation{}, fmt.Errorf("no command given")
+	}
+
+	inv := invocation{command: flags.Arg(0), args: flags.Args()[1:], env: map[string]string{}}
+	if inv.command == "help" {
+		flags.Usage()
+		return invocation{}, flag.ErrHelp
+	}
+	if _, ok := commands[inv.command]; !ok {
+		return invocation{}, fmt.Errorf("unknown command %q (one of %s)", inv.command, strings.Join(commandNames(), ", "))
+	}
+	for env, value := range values {
+		if *value != "" {
+			inv.env[env] = *value
+		}
+	}
+	return inv, nil
+}
+
+func usage(flags *flag.FlagSet) {
+	out := flags.Output()
+	fmt.Fprintln(out, "Usage: devops-apps [shared flags] <command> [arguments]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, name := range commandNames() {
+		fmt.Fprintf(out, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(out, "\nShared flags:")
+	flags.PrintDefaults()
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
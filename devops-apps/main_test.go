@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    invocation
+		wantErr string
+	}{
+		{
+			name: "command only",
+			args: []string{"drift"},
+			want: invocation{command: "drift", args: []string{}, env: map[string]string{}},
+		},
+		{
+			name: "shared flags become env",
+			args: []string{"-config", "/etc/apps.yaml", "-notify-config=notify.yaml", "impact"},
+			want: invocation{command: "impact", args: []string{}, env: map[string]string{
+				"CONFIG_FILE":   "/etc/apps.yaml",
+				"NOTIFY_CONFIG": "notify.yaml",
+			}},
+		},
+		{
+			name: "arguments after the command pass through",
+			args: []string{"-cub-url", "https://hub.example.com", "analyze", "-space", "abc", "-json"},
+			want: invocation{command: "analyze", args: []string{"-space", "abc", "-json"}, env: map[string]string{
+				"CUB_API_URL": "https://hub.example.com",
+			}},
+		},
+		{
+			name: "replay a snapshot",
+			args: []string{"-replay", "shop.json.gz", "drift"},
+			want: invocation{command: "drift", args: []string{}, env: map[string]string{"REPLAY_SNAPSHOT": "shop.json.gz"}},
+		},
+		{
+			name: "run once as a job",
+			args: []string{"-mode", "job", "-job-space", "ops-jobs", "orphans"},
+			want: invocation{command: "orphans", args: []string{}, env: map[string]string{"RUN_MODE": "job", "JOB_SPACE": "ops-jobs"}},
+		},
+		{
+			name: "cost demo",
+			args: []string{"cost", "demo"},
+			want: invocation{command: "cost", args: []string{"demo"}, env: map[string]string{}},
+		},
+		{name: "no command", args: nil, wantErr: "no command given"},
+		{name: "unknown command", args: []string{"deploy"}, wantErr: `unknown command "deploy" (one of analyze, backup, backups, certs, combined, compliance, cost, drift, grafana, impact, orphans, panel, quotas, restore, secrets, security, set, slo, snapshot, upgrade)`},
+		{name: "unknown flag", args: []string{"-verbose", "drift"}, wantErr: "flag provided but not defined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse(tt.args, &bytes.Buffer{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parse(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse(%q): %v", tt.args, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parse(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelpListsCommands(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := parse([]string{"help"}, &out); err != flag.ErrHelp {
+		t.Fatalf("parse(help) error = %v, want flag.ErrHelp", err)
+	}
+	for _, want := range []string{"drift", "cost", "impact", "analyze", "-notify-config", "NOTIFY_CONFIG", "snapshot", "-replay", "combined"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("help output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCombinedApps(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr string
+	}{
+		{list: "drift,cost,impact", want: []string{"drift", "cost", "impact"}},
+		{list: " impact, drift,impact ", want: []string{"impact", "drift"}},
+		{list: "drift,security", wantErr: `"security" cannot run combined`},
+		{list: " , ", wantErr: "no apps given"},
+	}
+	for _, tt := range tests {
+		got, err := combinedApps(tt.list)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("combinedApps(%q) error = %v, want %q", tt.list, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("combinedApps(%q) = %v, %v, want %v", tt.list, got, err, tt.want)
+		}
+	}
+}
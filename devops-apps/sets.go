@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/sethelper"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// setUsage lists the set subcommands
+const setUsage = `Usage: %[1]s <subcommand> [-space <space>] ...
+
+  %[1]s create [-label k=v]... [-display-name name] <set>
+  %[1]s list
+  %[1]s get <set>
+  %[1]s add-unit <set> <unit>...
+  %[1]s remove-unit <set> <unit>...
+
+create, list and get print JSON (set_id, slug, labels, units). create
+returns the existing set when there is one, and units already in or out of
+a set are left alone, so the commands can be run again.
+`
+
+// runSet manages the sets of a space through the API, for the scripts the
+// cub CLI cannot serve
+func runSet(name string, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, setUsage, name)
+		os.Exit(2)
+	}
+	sub, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet(name+" "+sub, flag.ExitOnError)
+	space := flags.String("space", os.Getenv("CONFIGHUB_SPACE_ID"), "slug or ID of the space")
+	labels := labelFlag{}
+	displayName := ""
+	if sub == "create" {
+		flags.Var(labels, "label", "label of the new set as key=value (repeatable)")
+		flags.StringVar(&displayName, "display-name", "", "display name of the new set (default: its slug)")
+	}
+	flags.Parse(args)
+	logging.Setup("set")
+
+	ok := false
+	switch sub {
+	case "list":
+		ok = flags.NArg() == 0
+	case "create", "get":
+		ok = flags.NArg() == 1
+	case "add-unit", "remove-unit":
+		ok = flags.NArg() >= 2
+	}
+	if !ok || *space == "" {
+		fmt.Fprintf(os.Stderr, setUsage, name)
+		os.Exit(2)
+	}
+
+	hub := setHub{cubFromEnv()}
+	var (
+		out interface{}
+		err error
+	)
+	switch sub {
+	case "create":
+		var set sethelper.Set
+		var created bool
+		set, created, err = sethelper.Create(hub, *space, sethelper.Set{Slug: flags.Arg(0), DisplayName: displayName, Labels: labels})
+		if err == nil && !created {
+			fmt.Fprintf(os.Stderr, "Set %s already exists in %s\n", set.Slug, *space)
+		}
+		out = set
+	case "list":
+		out, err = sethelper.List(hub, *space)
+	case "get":
+		out, err = sethelper.Get(hub, *space, flags.Arg(0))
+	case "add-unit":
+		var n int
+		n, err = sethelper.AddUnits(hub, *space, flags.Arg(0), flags.Args()[1:]...)
+		fmt.Fprintf(os.Stderr, "Added %d units to set %s\n", n, flags.Arg(0))
+	case "remove-unit":
+		var n int
+		n, err = sethelper.RemoveUnits(hub, *space, flags.Arg(0), flags.Args()[1:]...)
+		fmt.Fprintf(os.Stderr, "Removed %d units from set %s\n", n, flags.Arg(0))
+	}
+	if err != nil {
+		logging.Fatal("Set "+sub+" failed", logging.Space(*space), logging.Err(err))
+	}
+	if out != nil {
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	}
+}
+
+// labelFlag collects repeated -label key=value flags
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("label %q is not key=value", value)
+	}
+	l[k] = v
+	return nil
+}
+
+// setHub is a sethelper.Hub over the SDK's ConfigHub client
+type setHub struct{ cub *sdk.ConfigHubClient }
+
+func (h setHub) FindSpace(ref string) (uuid.UUID, bool, error) {
+	spaces, err := h.cub.ListSpaces()
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	for _, s := range spaces {
+		if s.Slug == ref || s.SpaceID.String() == ref {
+			return s.SpaceID, true, nil
+		}
+	}
+	return uuid.Nil, false, nil
+}
+
+func (h setHub) Sets(space uuid.UUID) ([]sethelper.Set, error) {
+	sets, err := h.cub.ListSets(space)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]sethelper.Set, 0, len(sets))
+	for _, s := range sets {
+		out = append(out, sethelper.Set{ID: s.SetID, Slug: s.Slug, DisplayName: s.DisplayName, Labels: s.Labels})
+	}
+	return out, nil
+}
+
+func (h setHub) CreateSet(space uuid.UUID, set sethelper.Set) (uuid.UUID, error) {
+	created, err := h.cub.CreateSet(space, sdk.CreateSetRequest{Slug: set.Slug, DisplayName: set.DisplayName, Labels: set.Labels})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return created.SetID, nil
+}
+
+func (h setHub) Units(space uuid.UUID) ([]sethelper.Unit, error) {
+	units, err := h.cub.ListUnits(sdk.ListUnitsParams{SpaceID: space})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]sethelper.Unit, 0, len(units))
+	for _, u := range units {
+		out = append(out, sethelper.Unit{ID: u.UnitID, Slug: u.Slug, SetIDs: u.SetIDs, Data: u.Data, Labels: u.Labels})
+	}
+	return out, nil
+}
+
+func (h setHub) SetUnitSets(space uuid.UUID, unit sethelper.Unit, setIDs []uuid.UUID) error {
+	_, err := h.cub.UpdateUnit(space, unit.ID, sdk.UpdateUnitRequest{Data: unit.Data, Labels: unit.Labels, SetIDs: setIDs})
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/backup"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/snapshot"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// runSnapshot records the cluster and ConfigHub spaces to a file that
+// -replay serves in their place
+func runSnapshot(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	var (
+		spaces    = flags.String("space", os.Getenv("CONFIGHUB_SPACE_ID"), "comma-separated slugs or IDs of the spaces to record")
+		namespace = flags.String("namespace", "", "namespace to record workloads of (default: all)")
+		out       = flags.String("o", "snapshot.json.gz", "file to write, gzipped when it ends in .gz")
+	)
+	flags.Parse(args)
+	logging.Setup("snapshot")
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: os.Getenv("K8S_CONTEXT")}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		logging.Fatal("Failed to load kubeconfig", logging.Err(err))
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		logging.Fatal("Failed to create Kubernetes client", logging.Err(err))
+	}
+	metrics, err := metricsclient.NewForConfig(config)
+	if err != nil {
+		logging.Fatal("Failed to create metrics client", logging.Err(err))
+	}
+
+	snap, err := snapshot.Capture(context.Background(), kube, metrics, *namespace)
+	if err != nil {
+		logging.Fatal("Failed to record the cluster", logging.Err(err))
+	}
+	if *spaces != "" {
+		hub := hubFromEnv()
+		for _, ref := range strings.Split(*spaces, ",") {
+			space, err := recordSpace(hub, strings.TrimSpace(ref))
+			if err != nil {
+				logging.Fatal("Failed to record space", logging.Space(ref), logging.Err(err))
+			}
+			snap.Spaces = append(snap.Spaces, space)
+		}
+	}
+
+	if err := snap.Save(*out); err != nil {
+		logging.Fatal("Failed to save snapshot", logging.Err(err))
+	}
+	fmt.Printf("Recorded %d deployments, %d pods, %d nodes and %d spaces to %s\n",
+		len(snap.Deployments), len(snap.Pods), len(snap.Nodes), len(snap.Spaces), *out)
+}
+
+// recordSpace reads a space's units and sets for a snapshot
+func recordSpace(hub backup.Hub, ref string) (snapshot.Space, error) {
+	space, found, err := hub.FindSpace(ref)
+	if err != nil {
+		return snapshot.Space{}, err
+	}
+	if !found {
+		return snapshot.Space{}, fmt.Errorf("space %s not found", ref)
+	}
+	sets, err := hub.Sets(space.ID)
+	if err != nil {
+		return snapshot.Space{}, fmt.Errorf("list sets: %w", err)
+	}
+	units, err := hub.Units(space.ID)
+	if err != nil {
+		return snapshot.Space{}, fmt.Errorf("list units: %w", err)
+	}
+
+	recorded := snapshot.Space{Slug: ref, Labels: space.Labels}
+	setSlugs := make(map[uuid.UUID]string, len(sets))
+	for _, set := range sets {
+		setSlugs[set.ID] = set.Slug
+		recorded.Sets = append(recorded.Sets, snapshot.Set{Slug: set.Slug, Labels: set.Labels})
+	}
+	for _, u := range units {
+		unit := snapshot.Unit{Slug: u.Slug, Labels: u.Labels, Data: u.Data}
+		for _, id := range u.SetIDs {
+			if slug, ok := setSlugs[id]; ok {
+				unit.Sets = append(unit.Sets, slug)
+			}
+		}
+		recorded.Units = append(recorded.Units, unit)
+	}
+	return recorded, nil
+}
+
+// startReplay serves a snapshot in place of the cluster and ConfigHub and
+// points the app at it through the environment
+func startReplay(path string) func() {
+	snap, err := snapshot.Load(path)
+	if err != nil {
+		logging.Fatal("Failed to load snapshot", logging.Err(err))
+	}
+	dir, err := os.MkdirTemp("", "devops-apps-replay")
+	if err != nil {
+		logging.Fatal("Failed to create replay directory", logging.Err(err))
+	}
+	replay, err := snapshot.Start(snap, dir)
+	if err != nil {
+		logging.Fatal("Failed to start replay", logging.Err(err))
+	}
+	for k, v := range replay.Env {
+		os.Setenv(k, v)
+	}
+	slog.Info("Replaying snapshot", "file", path, "captured_at", snap.CapturedAt, "spaces", len(snap.Spaces), "kubernetes", replay.Env["KUBECONFIG"], "confighub", replay.Env["CUB_API_URL"])
+	return func() {
+		replay.Close()
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,183 @@
+package driftdetector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/driftstream"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/prompts"
+	"github.com/monadic/devops-examples/pkg/tenants"
+)
+
+// DriftReport is the result of the latest drift detection, served at GET /api/v1/drift
+// so other apps (like the live cost dashboard) use this detector's view of drift
+type DriftReport struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	Space     string         `json:"space"`
+	Namespace string         `json:"namespace"`
+	Analysis  *DriftAnalysis `json:"analysis"`
+	Degraded  string         `json:"degraded,omitempty"` // why the report may be out of date
+}
+
+// recordReport keeps the outcome of a detection run for the API
+func (d *DriftDetector) recordReport(analysis *DriftAnalysis) {
+	report := &DriftReport{
+		CheckedAt: time.Now(),
+		Space:     d.spaceSlug,
+		Namespace: d.config.Namespace,
+		Analysis:  analysis,
+	}
+
+	d.mu.Lock()
+	d.report = report
+	d.mu.Unlock()
+}
+
+// collect exports the latest report's drift, for /metrics and the metrics sinks
+func (d *DriftDetector) collect(emit metrics.Emit) {
+	d.mu.RLock()
+	report := d.report
+	d.mu.RUnlock()
+	if report == nil || report.Analysis == nil {
+		return
+	}
+	labels := metrics.Labels{"space": report.Space, "namespace": report.Namespace}
+	detected := 0.0
+	if report.Analysis.HasDrift {
+		detected = 1
+	}
+	emit("drift_detected", "Whether the last detection found drift.", "gauge", labels, detected)
+	emit("drift_items", "Drifted fields found by the last detection.", "gauge", labels, float64(len(report.Analysis.Items)))
+	emit("drift_fixes_proposed", "Fixes proposed for the drift found by the last detection.", "gauge", labels, float64(len(report.Analysis.Fixes)))
+	emit("drift_last_check_timestamp_seconds", "When the last detection completed.", "gauge", labels, float64(report.CheckedAt.Unix()))
+}
+
+// publishDrift streams the drift found by a detection run to the
+// cost-impact-monitor; no items tells it the space has none left. On the
+// event bus drift.detected carries the same snapshot, and drift.resolved
+// follows the first clean run after drift. Both carry the drift's
+// correlation ID, so the cost impact it caused is closed under the same ID.
+func (d *DriftDetector) publishDrift(ctx context.Context, items []DriftItem) {
+	snapshot := driftstream.Snapshot{
+		Space:         d.spaceSlug,
+		Namespace:     d.config.Namespace,
+		DetectedAt:    time.Now(),
+		Items:         make([]driftstream.Item, 0, len(items)),
+		CorrelationID: correlation.FromContext(ctx),
+	}
+	if d.team != nil {
+		snapshot.Team = d.team.Name
+	}
+	for _, item := range items {
+		snapshot.Items = append(snapshot.Items, driftstream.Item{
+			UnitID:   item.UnitID.String(),
+			UnitSlug: item.UnitSlug,
+			Resource: item.Resource,
+			Field:    item.Field,
+			Expected: item.Expected,
+			Actual:   item.Actual,
+		})
+	}
+
+	d.mu.Lock()
+	resolved := len(items) == 0 && d.drifted
+	d.drifted = len(items) > 0
+	if resolved {
+		snapshot.CorrelationID = d.correlation
+	}
+	if len(items) == 0 {
+		d.correlation = ""
+	}
+	d.mu.Unlock()
+	d.stream.Publish(snapshot)
+
+	ctx = correlation.With(tenants.WithTeam(context.Background(), d.team), snapshot.CorrelationID)
+	switch {
+	case len(items) > 0:
+		d.bus.Publish(ctx, events.DriftDetected, d.spaceSlug, "", snapshot)
+	case resolved:
+		d.bus.Publish(ctx, events.DriftResolved, d.spaceSlug, "", snapshot)
+	}
+}
+
+// correlate returns the correlation ID of the space's drift, starting one
+// when the drift is new. The ID lasts until a run finds the space clean, so
+// every run, fix and cost impact of the same drift shares it.
+func (d *DriftDetector) correlate() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.correlation == "" {
+		d.correlation = correlation.New()
+	}
+	return d.correlation
+}
+
+// markDegraded flags the current report as stale until the next completed run
+func (d *DriftDetector) markDegraded(reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.report == nil || d.report.Degraded == reason {
+		return
+	}
+	report := *d.report
+	report.Degraded = reason
+	d.report = &report
+}
+
+// handleDrift returns the latest drift report, or 503 until the first detection has run
+func (d *DriftDetector) handleDrift(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.mu.RLock()
+	report := d.report
+	d.mu.RUnlock()
+	if report == nil {
+		http.Error(w, "drift detection has not run yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// apiHandler serves the drift API under /api/v1. With teams, every request
+// but /metrics needs a team's API token and /api/v1/drift serves the
+// detectors' reports by team. With guard, users sign in through the identity
+// provider and their role decides what they may call. Calls that may change
+// state are audited.
+func (d *DriftDetector) apiHandler(teams *tenants.Registry, guard *auth.Guard, detectors []*DriftDetector) http.Handler {
+	mux := http.NewServeMux()
+	v1 := api.New(mux, "drift-detector")
+	drift := api.Operation{Summary: "Latest drift report", Response: DriftReport{}}
+	if teams != nil {
+		drift.Query = []api.Param{{Name: "team", Description: "the caller's team when unset"}}
+		v1.HandleFunc("/drift", handleTeamDrift(detectors), drift)
+	} else {
+		v1.HandleFunc("/drift", d.handleDrift, drift)
+	}
+	v1.Handle("/flags", d.flags, flags.Operations...)
+	v1.Handle("/prompts", d.prompts, prompts.Operations...)
+	v1.Handle("/audit", d.audit, audit.Operations...)
+	v1.Handle("/queue/", d.pending, pending.Operations...)
+	v1.Handle("/breakers", breaker.Handler(d.cubBreaker, d.claudeBreaker), breaker.Operations...)
+	v1.Handle("/llm/usage", d.llmClient, llm.Operations...)
+	mux.Handle("/metrics", d.metrics)
+	return guard.Protect(teams.Protect("drift-detector", d.audit.Calls(mux), "/metrics"), "/metrics")
+}
@@ -0,0 +1,155 @@
+package driftdetector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestHandleDrift(t *testing.T) {
+	detector := &DriftDetector{spaceSlug: "drift-test"}
+
+	rec := httptest.NewRecorder()
+	detector.handleDrift(rec, httptest.NewRequest(http.MethodGet, "/api/v1/drift", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the first detection, got %d", rec.Code)
+	}
+
+	detector.recordReport(&DriftAnalysis{
+		HasDrift: true,
+		Items: []DriftItem{
+			{UnitSlug: "backend-api", Resource: "Deployment/backend-api", Field: "spec.replicas", Expected: "3", Actual: "5"},
+		},
+		Summary: "Detected 1 drift items across 1 units",
+	})
+
+	rec = httptest.NewRecorder()
+	detector.handleDrift(rec, httptest.NewRequest(http.MethodGet, "/api/v1/drift", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var report DriftReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Space != "drift-test" || report.CheckedAt.IsZero() {
+		t.Errorf("Unexpected report metadata: %+v", report)
+	}
+	if report.Analysis == nil || !report.Analysis.HasDrift || len(report.Analysis.Items) != 1 {
+		t.Errorf("Unexpected analysis: %+v", report.Analysis)
+	}
+
+	rec = httptest.NewRecorder()
+	detector.handleDrift(rec, httptest.NewRequest(http.MethodPost, "/api/v1/drift", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestMarkDegraded(t *testing.T) {
+	detector := &DriftDetector{spaceSlug: "drift-test"}
+	detector.markDegraded("ConfigHub unavailable")
+	if detector.report != nil {
+		t.Fatal("Degraded report created before the first detection")
+	}
+
+	detector.recordReport(&DriftAnalysis{Summary: "No drift detected"})
+	checkedAt := detector.report.CheckedAt
+	detector.markDegraded("ConfigHub unavailable")
+	if detector.report.Degraded != "ConfigHub unavailable" || detector.report.CheckedAt != checkedAt {
+		t.Errorf("Expected the last report marked degraded, got %+v", detector.report)
+	}
+
+	detector.recordReport(&DriftAnalysis{Summary: "No drift detected"})
+	if detector.report.Degraded != "" {
+		t.Errorf("Expected a completed run to clear degraded, got %q", detector.report.Degraded)
+	}
+}
+
+func TestHandleTeamDrift(t *testing.T) {
+	teams, err := tenants.Config{Teams: []*tenants.Team{
+		{Name: "payments", Spaces: []string{"payments-prod"}, Namespaces: []string{"payments"}, CubToken: "c1", APIToken: "payments-token"},
+		{Name: "checkout", Spaces: []string{"checkout-prod"}, Namespaces: []string{"checkout"}, CubToken: "c2", APIToken: "checkout-token"},
+		{Name: "platform", Spaces: []string{"*"}, CubToken: "c3", APIToken: "platform-token"},
+	}}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &DriftDetector{app: &sdk.DevOpsApp{}, config: DefaultConfig()}
+	detectors, err := base.teamDetectors(teams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detectors) != 2 || detectors[0].config.Namespace != "payments" || detectors[1].cubKey() != "team:checkout" {
+		t.Fatalf("Unexpected detectors: %d", len(detectors))
+	}
+	for _, d := range detectors {
+		d.spaceSlug = d.config.Space
+		d.recordReport(&DriftAnalysis{Summary: "No drift detected"})
+	}
+
+	handler := teams.Protect("drift-detector", handleTeamDrift(detectors))
+	for _, tt := range []struct {
+		token, query string
+		code         int
+		space        string
+	}{
+		{"payments-token", "", http.StatusOK, "payments-prod"},
+		{"payments-token", "?team=checkout", http.StatusForbidden, ""},
+		{"platform-token", "?team=checkout", http.StatusOK, "checkout-prod"},
+		{"platform-token", "", http.StatusNotFound, ""},
+		{"", "", http.StatusUnauthorized, ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/drift"+tt.query, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s%s: got %d, want %d", tt.token, tt.query, rec.Code, tt.code)
+			continue
+		}
+		var report DriftReport
+		if tt.space != "" && (json.NewDecoder(rec.Body).Decode(&report) != nil || report.Space != tt.space) {
+			t.Errorf("%s%s: report for %q, want %q", tt.token, tt.query, report.Space, tt.space)
+		}
+	}
+
+	mixed, _ := tenants.Config{Teams: []*tenants.Team{
+		{Name: "web", Spaces: []string{"web-*"}, Namespaces: []string{"web"}, CubToken: "c", APIToken: "t"},
+	}}.Build()
+	if _, err := base.teamDetectors(mixed); err == nil {
+		t.Error("Expected an error for a team with a space pattern")
+	}
+}
+
+// TestCollect checks the drift metrics, and that the Grafana dashboard only
+// queries drift metrics the detector exports
+func TestCollect(t *testing.T) {
+	detector := &DriftDetector{spaceSlug: "drift-test"}
+	emitted := map[string]float64{}
+	emit := func(name, help, kind string, labels metrics.Labels, value float64) { emitted[name] = value }
+	detector.collect(emit)
+	if len(emitted) != 0 {
+		t.Errorf("Expected no metrics before the first detection, got %v", emitted)
+	}
+
+	detector.recordReport(&DriftAnalysis{HasDrift: true, Items: []DriftItem{{UnitSlug: "backend-api"}, {UnitSlug: "web"}}})
+	detector.collect(emit)
+	if emitted["drift_detected"] != 1 || emitted["drift_items"] != 2 || emitted["drift_fixes_proposed"] != 0 {
+		t.Errorf("Unexpected drift metrics %v", emitted)
+	}
+	for _, name := range metrics.GrafanaMetrics() {
+		if _, ok := emitted[name]; strings.HasPrefix(name, "drift_") && !ok {
+			t.Errorf("The Grafana dashboard queries %s, which the detector doesn't export", name)
+		}
+	}
+}
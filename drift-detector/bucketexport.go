@@ -0,0 +1,56 @@
+package driftdetector
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/export"
+)
+
+// driftEvent is a row of the drift_events dataset, one per drifted field
+type driftEvent struct {
+	DetectedAt    time.Time `json:"detected_at"`
+	Space         string    `json:"space"`
+	Namespace     string    `json:"namespace"`
+	Team          string    `json:"team"`
+	UnitID        uuid.UUID `json:"unit_id"`
+	UnitSlug      string    `json:"unit_slug"`
+	Environment   string    `json:"environment"`
+	Resource      string    `json:"resource"`
+	Field         string    `json:"field"`
+	Expected      string    `json:"expected"`
+	Actual        string    `json:"actual"`
+	CorrelationID string    `json:"correlation_id"`
+}
+
+// exportDrift buffers the drift found by a run for the export bucket
+func (d *DriftDetector) exportDrift(ctx context.Context, items []DriftItem) {
+	if d.exporter == nil || len(items) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	var team string
+	if d.team != nil {
+		team = d.team.Name
+	}
+	rows := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, driftEvent{
+			DetectedAt:    now,
+			Space:         d.spaceSlug,
+			Namespace:     d.config.Namespace,
+			Team:          team,
+			UnitID:        item.UnitID,
+			UnitSlug:      item.UnitSlug,
+			Environment:   item.Environment,
+			Resource:      item.Resource,
+			Field:         item.Field,
+			Expected:      item.Expected,
+			Actual:        item.Actual,
+			CorrelationID: correlation.FromContext(ctx),
+		})
+	}
+	d.exporter.Add(export.DriftEvents, d.spaceSlug, rows...)
+}
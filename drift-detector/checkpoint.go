@@ -0,0 +1,105 @@
+package driftdetector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newCheckpointer returns the checkpointer keeping the detectors' state in
+// the checkpoint-drift-detector unit of the space with slug space; it saves
+// nothing without one
+func newCheckpointer(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) *checkpoint.Checkpointer {
+	if cub == nil || space == "" {
+		return checkpoint.New("drift-detector", nil, nil)
+	}
+	spaceID := func(ctx context.Context) (uuid.UUID, error) {
+		spaces, err := ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug == space {
+				return s.SpaceID, nil
+			}
+		}
+		return uuid.Nil, fmt.Errorf("checkpoint space %s not found", space)
+	}
+
+	writer := func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return err
+		}
+		units, err := cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: fmt.Sprintf("Slug = '%s'", slug)})
+		if err != nil {
+			return fmt.Errorf("list units: %w", err)
+		}
+		if len(units) > 0 {
+			_, err = cub.UpdateUnit(id, units[0].UnitID, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+			return err
+		}
+		_, err = cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+		return err
+	}
+	reader := func(ctx context.Context, where string) ([]string, error) {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		units, err := ratelimit.Call(ctx, limit, "ListUnits", id.String()+"/"+where, func() ([]*sdk.Unit, error) {
+			return cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: where})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list units: %w", err)
+		}
+		data := make([]string, 0, len(units))
+		for _, u := range units {
+			data = append(data, u.Data)
+		}
+		return data, nil
+	}
+	return checkpoint.New("drift-detector", writer, reader)
+}
+
+// detectorState is what a detector keeps across restarts: its latest report,
+// served until the next detection, and the drift it was tracking, so its
+// resolution is still published under the same correlation ID
+type detectorState struct {
+	Report      *DriftReport `json:"report"`
+	Drifted     bool         `json:"drifted"`
+	Correlation string       `json:"correlation,omitempty"`
+}
+
+// registerCheckpoint adds the detector's state to cp, under its space
+func (d *DriftDetector) registerCheckpoint(cp *checkpoint.Checkpointer) {
+	cp.Register("detector/"+d.spaceSlug, d.checkpointState, d.restoreState)
+}
+
+func (d *DriftDetector) checkpointState() (interface{}, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return detectorState{Report: d.report, Drifted: d.drifted, Correlation: d.correlation}, nil
+}
+
+// restoreState puts back a checkpointed state. The report is marked degraded
+// until the first detection after the restart replaces it.
+func (d *DriftDetector) restoreState(data json.RawMessage) error {
+	var state detectorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if state.Report != nil && d.report == nil {
+		state.Report.Degraded = "restored from checkpoint"
+		d.report = state.Report
+	}
+	d.drifted, d.correlation = state.Drifted, state.Correlation
+	return nil
+}
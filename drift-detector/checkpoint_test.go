@@ -0,0 +1,45 @@
+package driftdetector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+)
+
+func TestCheckpoint(t *testing.T) {
+	var saved string
+	writer := func(_ context.Context, _ string, _ map[string]string, data string) error {
+		saved = data
+		return nil
+	}
+	reader := func(context.Context, string) ([]string, error) { return []string{saved}, nil }
+
+	before := &DriftDetector{spaceSlug: "drift-test"}
+	before.recordReport(&DriftAnalysis{HasDrift: true, Items: []DriftItem{{UnitSlug: "backend-api", Field: "spec.replicas"}}})
+	before.drifted = true
+	id := before.correlate()
+	cp := checkpoint.New("drift-detector", writer, reader)
+	before.registerCheckpoint(cp)
+	if err := cp.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	after := &DriftDetector{spaceSlug: "drift-test"}
+	other := &DriftDetector{spaceSlug: "other-space"}
+	cp = checkpoint.New("drift-detector", writer, reader)
+	after.registerCheckpoint(cp)
+	other.registerCheckpoint(cp)
+	if err := cp.Restore(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if after.report == nil || len(after.report.Analysis.Items) != 1 || after.report.Degraded == "" {
+		t.Errorf("Expected the report back, marked degraded, got %+v", after.report)
+	}
+	if !after.drifted || after.correlate() != id {
+		t.Errorf("Expected the drift back under %s, got %v %s", id, after.drifted, after.correlation)
+	}
+	if other.report != nil {
+		t.Error("Expected another space's detector to get nothing")
+	}
+}
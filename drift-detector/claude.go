@@ -0,0 +1,104 @@
+package driftdetector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/llm"
+)
+
+// claudeClient is the part of the AI client the detector uses
+type claudeClient interface {
+	Complete(prompt string) (string, error)
+}
+
+// newClaudeClient returns canned answers with claude_mode stub, otherwise a
+// client of the configured provider and budget, which is nil when the
+// provider needs an API key and none is set
+func newClaudeClient(cfg Config) claudeClient {
+	if cfg.ClaudeMode == claudestub.ModeStub {
+		return claudestub.New(driftResponder)
+	}
+	key := cfg.llmAPIKey()
+	if key == "" && cfg.llmConfig().NeedsKey() {
+		return nil
+	}
+	return llm.New(key, cfg.llmConfig())
+}
+
+// guardedClaude fails fast through a circuit breaker while Claude is down
+type guardedClaude struct {
+	client  claudeClient
+	breaker *breaker.Breaker
+}
+
+// guardClaude wraps client in b; a nil client stays nil so callers still
+// skip the AI analysis without a key
+func guardClaude(client claudeClient, b *breaker.Breaker) claudeClient {
+	if client == nil {
+		return nil
+	}
+	return guardedClaude{client: client, breaker: b}
+}
+
+func (g guardedClaude) Complete(prompt string) (string, error) {
+	return breaker.Call(g.breaker, func() (string, error) { return g.client.Complete(prompt) })
+}
+
+// driftResponder answers analyzeWithClaude by proposing to restore each
+// drifted field to the value declared in ConfigHub
+var driftResponder = claudestub.Responder{
+	Name:  "drift-analysis",
+	Match: claudestub.Contains("Analyze this Kubernetes configuration drift"),
+	Answer: func(prompt string, _ interface{}) (string, error) {
+		items, err := promptDriftItems(prompt)
+		if err != nil {
+			return "", err
+		}
+
+		analysis := DriftAnalysis{
+			HasDrift: len(items) > 0,
+			Items:    items,
+			Summary:  fmt.Sprintf("[stub] %d fields differ from ConfigHub; restoring the declared values removes the drift", len(items)),
+		}
+		for _, item := range items {
+			analysis.Fixes = append(analysis.Fixes, ProposedFix{
+				UnitID:      item.UnitID,
+				UnitSlug:    item.UnitSlug,
+				PatchPath:   "/" + strings.ReplaceAll(item.Field, ".", "/"),
+				PatchValue:  patchValue(item.Expected),
+				Explanation: fmt.Sprintf("%s %s is %s in the cluster but %s in ConfigHub", item.Resource, item.Field, item.Actual, item.Expected),
+			})
+		}
+
+		data, err := json.Marshal(analysis)
+		return string(data), err
+	},
+}
+
+// promptDriftItems reads back the drift items analyzeWithClaude put in its prompt
+func promptDriftItems(prompt string) ([]DriftItem, error) {
+	_, rest, ok := strings.Cut(prompt, "Drift Items:\n")
+	if !ok {
+		return nil, fmt.Errorf("no drift items in prompt")
+	}
+	rest, _, _ = strings.Cut(rest, "\n\nReturn JSON")
+
+	var items []DriftItem
+	if err := json.Unmarshal([]byte(rest), &items); err != nil {
+		return nil, fmt.Errorf("parse drift items: %w", err)
+	}
+	return items, nil
+}
+
+// patchValue keeps numbers numeric so a replicas patch stays an integer
+func patchValue(s string) interface{} {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return s
+}
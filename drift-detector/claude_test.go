@@ -0,0 +1,75 @@
+package driftdetector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/prompts"
+)
+
+func TestClaudeStubAnalysis(t *testing.T) {
+	if newClaudeClient(DefaultConfig()) != nil {
+		t.Fatal("api mode without a key returned a client")
+	}
+
+	cfg := DefaultConfig()
+	cfg.ClaudeMode = "stub"
+	d := &DriftDetector{config: cfg, claude: newClaudeClient(cfg)}
+
+	unitID := uuid.New()
+	items := []DriftItem{
+		{UnitID: unitID, UnitSlug: "backend-api", Resource: "Deployment/backend-api", Field: "spec.replicas", Expected: "2", Actual: "5"},
+		{UnitSlug: "frontend", Resource: "Deployment/frontend", Field: "spec.template.spec.containers[0].image", Expected: "web:1.2", Actual: "web:1.3"},
+	}
+	analysis, err := d.analyzeWithClaude(context.Background(), items, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !analysis.HasDrift || len(analysis.Items) != 2 || len(analysis.Fixes) != 2 {
+		t.Fatalf("analysis = %+v", analysis)
+	}
+	fix := analysis.Fixes[0]
+	if fix.UnitID != unitID || fix.PatchPath != "/spec/replicas" || fix.PatchValue != float64(2) {
+		t.Errorf("replicas fix = %+v", fix)
+	}
+	if analysis.Fixes[1].PatchValue != "web:1.2" {
+		t.Errorf("image fix = %+v", analysis.Fixes[1])
+	}
+}
+
+// promptRecorder is a Claude client that keeps the prompts it is sent
+type promptRecorder struct{ prompts []string }
+
+func (r *promptRecorder) Complete(prompt string) (string, error) {
+	r.prompts = append(r.prompts, prompt)
+	return `{"has_drift": true, "summary": "replicas scaled by hand"}`, nil
+}
+
+func TestAnalysisPromptFromConfigHub(t *testing.T) {
+	recorder := &promptRecorder{}
+	set := prompts.New([]prompts.Prompt{driftAnalysisPrompt}, func(context.Context) ([]prompts.Unit, error) {
+		return []prompts.Unit{{Slug: "prompt-drift-analysis", Data: "Space {{.Space}}: {{range .Items}}{{.Field}} {{end}}", Revision: 2}}, nil
+	})
+	d := &DriftDetector{spaceSlug: "prod", claude: recorder, prompts: set}
+	items := []DriftItem{{UnitSlug: "backend-api", Field: "spec.replicas", Expected: "2", Actual: "5"}}
+
+	if _, err := d.analyzeWithClaude(context.Background(), items, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.analyzeWithClaude(context.Background(), items, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(recorder.prompts[0], "Analyze this Kubernetes configuration drift") {
+		t.Errorf("built-in prompt = %q", recorder.prompts[0])
+	}
+	if recorder.prompts[1] != "Space prod: spec.replicas " {
+		t.Errorf("ConfigHub prompt = %q", recorder.prompts[1])
+	}
+}
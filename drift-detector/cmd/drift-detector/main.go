@@ -0,0 +1,9 @@
+// Command drift-detector detects and fixes Kubernetes configuration drift
+// against ConfigHub. The same app runs as "devops-apps drift".
+package main
+
+import driftdetector "github.com/monadic/devops-examples/drift-detector"
+
+func main() {
+	driftdetector.Main()
+}
@@ -0,0 +1,209 @@
+package driftdetector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/checkpoint"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Config holds the drift detector's settings. They are read from
+// CONFIG_FILE (default /etc/drift-detector/config.yaml); each can be
+// overridden by the environment variable in its env tag.
+type Config struct {
+	Space        string        `yaml:"cub_space" env:"CUB_SPACE"`
+	CubAPIURL    string        `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken     string        `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	ClaudeAPIKey string        `yaml:"claude_api_key" env:"CLAUDE_API_KEY" secret:"true"`
+	ClaudeMode   string        `yaml:"claude_mode" env:"CLAUDE_MODE"` // "api", or "stub" for canned answers
+	SecretsDir   string        `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token, claude-api-key) overriding the above
+	Namespace    string        `yaml:"namespace" env:"NAMESPACE"`
+	Target       string        `yaml:"target" env:"TARGET"`
+	K8sContext   string        `yaml:"k8s_context" env:"K8S_CONTEXT"`
+	AutoFix      bool          `yaml:"auto_fix" env:"AUTO_FIX"`
+	APIPort      int           `yaml:"drift_api_port" env:"DRIFT_API_PORT"`
+	GRPCPort     int           `yaml:"drift_grpc_port" env:"DRIFT_GRPC_PORT"` // drift stream to cost-impact-monitor
+	NotifyConfig string        `yaml:"notify_config" env:"NOTIFY_CONFIG"`
+	PolicyConfig string        `yaml:"policy_config" env:"POLICY_CONFIG"`               // auto-fix rules, see pkg/policy; a missing file keeps the built-in ones
+	TeamsConfig  string        `yaml:"teams_config" env:"TEAMS_CONFIG"`                 // one detector per team; a missing file is single-tenant
+	AuthConfig   string        `yaml:"auth_config" env:"AUTH_CONFIG"`                   // OIDC login for the API; a missing file leaves it open
+	MetricsSinks string        `yaml:"metrics_sinks_config" env:"METRICS_SINKS_CONFIG"` // Datadog and New Relic pushes, see pkg/metrics; missing only serves /metrics
+	ExportConfig string        `yaml:"export_config" env:"EXPORT_CONFIG"`               // drift events to S3 or GCS, see pkg/export; missing exports nothing
+	RunInterval  time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+	FlagsSpace   string        `yaml:"flags_space" env:"FLAGS_SPACE"` // space of the feature-flags unit; empty disables overrides
+	FlagsRefresh time.Duration `yaml:"flags_refresh" env:"FLAGS_REFRESH"`
+	AuditSpace   string        `yaml:"audit_space" env:"AUDIT_SPACE"`       // space audit entries are written to; empty keeps them in memory
+	PendingSpace string        `yaml:"pending_space" env:"PENDING_SPACE"`   // space fixes awaiting approval or retry are queued in, see pkg/pending
+	PendingDir   string        `yaml:"pending_dir" env:"PENDING_DIR"`       // directory they are queued in without a space; empty keeps them in memory
+	ClusterName  string        `yaml:"cluster_name" env:"CLUSTER_NAME"`     // cluster label of the /metrics samples
+	Pprof        bool          `yaml:"pprof" env:"PPROF"`                   // /debug/pprof/ on the health port
+	CubRateLimit float64       `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int           `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+
+	// Space each detector's latest report and drift correlation are
+	// checkpointed to, see pkg/checkpoint, and how often; empty loses them on
+	// restart
+	CheckpointSpace    string        `yaml:"checkpoint_space" env:"CHECKPOINT_SPACE"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env:"CHECKPOINT_INTERVAL"`
+
+	// NATS server drift events are published to (nats://host:4222); empty
+	// publishes none
+	NATSURL           string `yaml:"nats_url" env:"NATS_URL"`
+	NATSToken         string `yaml:"nats_token" env:"NATS_TOKEN" secret:"true"`
+	NATSSubjectPrefix string `yaml:"nats_subject_prefix" env:"NATS_SUBJECT_PREFIX"`
+
+	// Namespace and label selector the informers watch, to shrink their
+	// caches on big clusters; empty watches every namespace and object
+	WatchNamespace string `yaml:"watch_namespace" env:"WATCH_NAMESPACE"`
+	WatchSelector  string `yaml:"watch_selector" env:"WATCH_SELECTOR"`
+
+	// Consecutive ConfigHub or Claude failures before calls fail fast, and how
+	// long until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+
+	// AI behind the drift analyses: "claude", "openai" (any OpenAI-compatible
+	// endpoint) or "ollama", at llm_base_url (empty for the provider's own).
+	// llm_api_key authenticates openai and ollama; claude uses claude_api_key.
+	LLMProvider string `yaml:"llm_provider" env:"LLM_PROVIDER"`
+	LLMBaseURL  string `yaml:"llm_base_url" env:"LLM_BASE_URL"`
+	LLMAPIKey   string `yaml:"llm_api_key" env:"LLM_API_KEY" secret:"true"`
+	// Model (empty for the provider's default), response limit and
+	// temperature, and the tokens used a month (0 for no limit). Prices, in
+	// dollars per million tokens, estimate the spend at /api/v1/llm/usage.
+	LLMModel       string  `yaml:"llm_model" env:"LLM_MODEL"`
+	LLMMaxTokens   int     `yaml:"llm_max_tokens" env:"LLM_MAX_TOKENS"`
+	LLMTemperature float64 `yaml:"llm_temperature" env:"LLM_TEMPERATURE"`
+	LLMTokenBudget int64   `yaml:"llm_monthly_token_budget" env:"LLM_MONTHLY_TOKEN_BUDGET"`
+	LLMInputPrice  float64 `yaml:"llm_input_price" env:"LLM_INPUT_PRICE"`
+	LLMOutputPrice float64 `yaml:"llm_output_price" env:"LLM_OUTPUT_PRICE"`
+
+	// Space of the prompt-<name> units replacing the built-in AI prompts
+	// (empty keeps them), and how often they are re-read
+	PromptsSpace   string        `yaml:"prompts_space" env:"PROMPTS_SPACE"`
+	PromptsRefresh time.Duration `yaml:"prompts_refresh" env:"PROMPTS_REFRESH"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them. The chart's values.yaml (deploy/charts/drift-detector) repeats
+// them and a test in deploy/charts keeps the two in step.
+func DefaultConfig() Config {
+	return Config{
+		Space:        "drift-detector",
+		CubAPIURL:    "https://hub.confighub.com/api",
+		ClaudeMode:   claudestub.ModeAPI,
+		Namespace:    "default",
+		Target:       "kubernetes-cluster",
+		APIPort:      8084,
+		GRPCPort:     9084,
+		NotifyConfig: "/etc/drift-detector/notify.yaml",
+		PolicyConfig: "/etc/drift-detector/policy.yaml",
+		TeamsConfig:  "/etc/drift-detector/teams.yaml",
+		AuthConfig:   "/etc/drift-detector/auth.yaml",
+		MetricsSinks: "/etc/drift-detector/metrics-sinks.yaml",
+		ExportConfig: "/etc/drift-detector/export.yaml",
+		RunInterval:  5 * time.Minute,
+		FlagsRefresh: 30 * time.Second,
+		CubRateLimit: 5,
+		CubRateBurst: 10,
+
+		CheckpointInterval: checkpoint.DefaultInterval,
+
+		NATSSubjectPrefix: events.DefaultPrefix,
+
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+
+		LLMProvider:    llm.ProviderClaude,
+		LLMMaxTokens:   llm.DefaultMaxTokens,
+		LLMInputPrice:  llm.DefaultInputPrice,
+		LLMOutputPrice: llm.DefaultOutputPrice,
+
+		PromptsRefresh: time.Minute,
+	}
+}
+
+// llmConfig is the provider, model and budget of the AI client
+func (c *Config) llmConfig() llm.Config {
+	return llm.Config{
+		Provider:           c.LLMProvider,
+		BaseURL:            c.LLMBaseURL,
+		Model:              c.LLMModel,
+		MaxTokens:          c.LLMMaxTokens,
+		Temperature:        c.LLMTemperature,
+		MonthlyTokenBudget: c.LLMTokenBudget,
+		InputPrice:         c.LLMInputPrice,
+		OutputPrice:        c.LLMOutputPrice,
+	}
+}
+
+// llmAPIKey is the API key of the configured provider
+func (c *Config) llmAPIKey() string {
+	if c.LLMProvider == llm.ProviderClaude {
+		return c.ClaudeAPIKey
+	}
+	return c.LLMAPIKey
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if c.Space == "" {
+		return fmt.Errorf("cub_space is required")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.APIPort < 1 || c.APIPort > 65535 {
+		return fmt.Errorf("drift_api_port %d is not a valid port", c.APIPort)
+	}
+	if c.GRPCPort < 1 || c.GRPCPort > 65535 {
+		return fmt.Errorf("drift_grpc_port %d is not a valid port", c.GRPCPort)
+	}
+	if _, err := labels.Parse(c.WatchSelector); err != nil {
+		return fmt.Errorf("watch_selector: %w", err)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.FlagsRefresh <= 0 {
+		return fmt.Errorf("flags_refresh must be positive, got %s", c.FlagsRefresh)
+	}
+	if c.PromptsRefresh <= 0 {
+		return fmt.Errorf("prompts_refresh must be positive, got %s", c.PromptsRefresh)
+	}
+	if c.CheckpointInterval <= 0 {
+		return fmt.Errorf("checkpoint_interval must be positive, got %s", c.CheckpointInterval)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	if err := c.llmConfig().Validate(); err != nil {
+		return err
+	}
+	return claudestub.ValidateMode(c.ClaudeMode)
+}
+
+// loadConfig reads the config file named by DRIFT_DETECTOR_CONFIG_FILE or
+// CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(config.File("drift-detector", "/etc/drift-detector/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
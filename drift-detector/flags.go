@@ -0,0 +1,43 @@
+package driftdetector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// flagsSource reads the feature-flags unit of the space with slug space; nil
+// when overrides are off (no space or no ConfigHub client)
+func flagsSource(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) flags.Source {
+	if cub == nil || space == "" {
+		return nil
+	}
+	return func(ctx context.Context) (string, error) {
+		spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+			return ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		})
+		if err != nil {
+			return "", fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug != space {
+				continue
+			}
+			units, err := ratelimit.Call(ctx, limit, "ListUnits", s.SpaceID.String()+"/"+flags.Unit, func() ([]*sdk.Unit, error) {
+				return cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.SpaceID, Where: fmt.Sprintf("Slug = '%s'", flags.Unit)})
+			})
+			if err != nil {
+				return "", fmt.Errorf("list units: %w", err)
+			}
+			if len(units) == 0 {
+				return "", nil
+			}
+			return units[0].Data, nil
+		}
+		return "", fmt.Errorf("flags space %s not found", space)
+	}
+}
@@ -0,0 +1,53 @@
+package driftdetector
+
+import (
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newInformerFactory returns the informer factory of the detectors, limited
+// to WATCH_NAMESPACE and WATCH_SELECTOR when they are set. Its caches hold
+// stripped objects, see stripForCache.
+func newInformerFactory(clientset kubernetes.Interface, cfg Config) informers.SharedInformerFactory {
+	options := []informers.SharedInformerOption{informers.WithTransform(stripForCache)}
+	if cfg.WatchNamespace != "" {
+		options = append(options, informers.WithNamespace(cfg.WatchNamespace))
+	}
+	if cfg.WatchSelector != "" {
+		options = append(options, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = cfg.WatchSelector
+		}))
+	}
+	return informers.NewSharedInformerFactoryWithOptions(clientset, 10*time.Minute, options...)
+}
+
+// stripForCache drops what the event handlers never read before an object
+// is cached. Drift is compared against the live object fetched from the API,
+// so the cache only needs metadata; managedFields, status and ConfigMap data
+// are most of its size on a big cluster.
+func stripForCache(obj interface{}) (interface{}, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		stripped, err := stripForCache(tombstone.Obj)
+		tombstone.Obj = stripped
+		return tombstone, err
+	}
+	if accessor, ok := obj.(metav1.Object); ok {
+		accessor.SetManagedFields(nil)
+	}
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		o.Status = appsv1.DeploymentStatus{}
+	case *corev1.Service:
+		o.Status = corev1.ServiceStatus{}
+	case *corev1.ConfigMap:
+		o.Data = nil
+		o.BinaryData = nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,65 @@
+package driftdetector
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInformerFactory(t *testing.T) {
+	meta := func(namespace, name, app string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace: namespace, Name: name, Labels: map[string]string{"app": app},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: meta("payments", "api", "api"), Status: appsv1.DeploymentStatus{Replicas: 3}},
+		&appsv1.Deployment{ObjectMeta: meta("payments", "worker", "worker")},
+		&appsv1.Deployment{ObjectMeta: meta("checkout", "api", "api")},
+		&corev1.ConfigMap{ObjectMeta: meta("payments", "api-config", "api"), Data: map[string]string{"big": "value"}},
+	)
+
+	cfg := DefaultConfig()
+	cfg.WatchNamespace = "payments"
+	cfg.WatchSelector = "app=api"
+	factory := newInformerFactory(clientset, cfg)
+	deployments := factory.Apps().V1().Deployments().Informer()
+	configMaps := factory.Core().V1().ConfigMaps().Informer()
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, deployments.HasSynced, configMaps.HasSynced) {
+		t.Fatal("caches did not sync")
+	}
+
+	cached := deployments.GetStore().List()
+	if len(cached) != 1 {
+		t.Fatalf("Expected only payments/api to be cached, got %d deployments", len(cached))
+	}
+	deployment := cached[0].(*appsv1.Deployment)
+	if deployment.Namespace != "payments" || deployment.Name != "api" ||
+		deployment.ManagedFields != nil || deployment.Status.Replicas != 0 {
+		t.Errorf("Expected a stripped payments/api, got %+v", deployment)
+	}
+	if cm := configMaps.GetStore().List(); len(cm) != 1 || cm[0].(*corev1.ConfigMap).Data != nil {
+		t.Errorf("Expected the ConfigMap cached without its data, got %+v", cm)
+	}
+}
+
+func TestStripForCacheTombstone(t *testing.T) {
+	tombstone := cache.DeletedFinalStateUnknown{Key: "payments/api", Obj: &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api", ManagedFields: []metav1.ManagedFieldsEntry{{}}},
+	}}
+	obj, err := stripForCache(tombstone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc := obj.(cache.DeletedFinalStateUnknown).Obj.(*corev1.Service); svc.ManagedFields != nil {
+		t.Errorf("Expected the tombstone's object to be stripped, got %+v", svc)
+	}
+}
@@ -1,24 +1,33 @@
 //go:build integration
 // +build integration
 
-package main
+package driftdetector
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/monadic/devops-examples/pkg/confighubtest"
 	sdk "github.com/monadic/devops-sdk"
 )
 
-// Integration test that demonstrates real ConfigHub usage
+// confighubEndpoint returns the real ConfigHub when CUB_TOKEN is set, and an
+// in-memory fake otherwise so the flows also run in CI without credentials
+func confighubEndpoint(tb testing.TB) (baseURL, token string) {
+	if token := os.Getenv("CUB_TOKEN"); token != "" {
+		return sdk.GetEnvOrDefault("CUB_API_URL", "https://api.confighub.com/v1"), token
+	}
+	fake := confighubtest.NewServer("integration-token")
+	tb.Cleanup(fake.Close)
+	return fake.URL, fake.Token
+}
+
+// Integration test that demonstrates ConfigHub usage
 // Run with: go test -tags=integration -v
 func TestDriftDetectorIntegration(t *testing.T) {
-	// Skip if no ConfigHub token provided
-	cubToken := os.Getenv("CUB_TOKEN")
-	if cubToken == "" {
-		t.Skip("Skipping integration test - CUB_TOKEN not set")
-	}
+	cubURL, cubToken := confighubEndpoint(t)
 
 	// Create app with minimal config for testing
 	config := sdk.DevOpsAppConfig{
@@ -28,7 +37,7 @@ func TestDriftDetectorIntegration(t *testing.T) {
 		RunInterval: 1 * time.Minute,
 		HealthPort:  8081, // Different port to avoid conflicts
 		CubToken:    cubToken,
-		CubBaseURL:  sdk.GetEnvOrDefault("CUB_API_URL", "https://api.confighub.com/v1"),
+		CubBaseURL:  cubURL,
 	}
 
 	app, err := sdk.NewDevOpsApp(config)
@@ -36,8 +45,11 @@ func TestDriftDetectorIntegration(t *testing.T) {
 		t.Fatalf("Failed to create app: %v", err)
 	}
 
+	cfg := DefaultConfig()
+	cfg.Space = "drift-detector-test"
 	detector := &DriftDetector{
-		app: app,
+		app:    app,
+		config: cfg,
 	}
 
 	// Test initialization (creates spaces, sets, filters)
@@ -88,7 +100,7 @@ func TestDriftDetectorIntegration(t *testing.T) {
 	}
 
 	// Test drift detection (won't actually detect drift without K8s resources)
-	err = detector.detectAndFixDrift()
+	err = detector.detectAndFixDrift(context.Background())
 	if err != nil {
 		t.Logf("⚠️  Drift detection failed (expected without K8s): %v", err)
 	} else {
@@ -96,14 +108,9 @@ func TestDriftDetectorIntegration(t *testing.T) {
 	}
 }
 
-// Test real ConfigHub API operations
+// Test ConfigHub API operations
 func TestConfigHubAPIOperations(t *testing.T) {
-	cubToken := os.Getenv("CUB_TOKEN")
-	if cubToken == "" {
-		t.Skip("Skipping ConfigHub API test - CUB_TOKEN not set")
-	}
-
-	client := sdk.NewConfigHubClient("", cubToken)
+	client := sdk.NewConfigHubClient(confighubEndpoint(t))
 
 	// Test space operations
 	t.Run("SpaceOperations", func(t *testing.T) {
@@ -173,12 +180,7 @@ func TestConfigHubAPIOperations(t *testing.T) {
 
 // Test bulk operations (the key differentiator)
 func TestBulkOperations(t *testing.T) {
-	cubToken := os.Getenv("CUB_TOKEN")
-	if cubToken == "" {
-		t.Skip("Skipping bulk operations test - CUB_TOKEN not set")
-	}
-
-	client := sdk.NewConfigHubClient("", cubToken)
+	client := sdk.NewConfigHubClient(confighubEndpoint(t))
 
 	// Create a test space
 	space, err := client.CreateSpace(sdk.CreateSpaceRequest{
@@ -229,12 +231,7 @@ func TestBulkOperations(t *testing.T) {
 
 // Benchmark the key operations
 func BenchmarkConfigHubOperations(b *testing.B) {
-	cubToken := os.Getenv("CUB_TOKEN")
-	if cubToken == "" {
-		b.Skip("Skipping benchmark - CUB_TOKEN not set")
-	}
-
-	client := sdk.NewConfigHubClient("", cubToken)
+	client := sdk.NewConfigHubClient(confighubEndpoint(b))
 
 	b.Run("ListSpaces", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
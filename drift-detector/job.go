@@ -0,0 +1,54 @@
+package driftdetector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newJobStore returns the writer a job stores its result with, as units of
+// the space with slug space; nil without one, so the result is only printed
+func newJobStore(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) runmode.Writer {
+	if cub == nil {
+		return nil
+	}
+	return runmode.Store(limit, space, cub.ListSpaces,
+		func(s *sdk.Space) (string, uuid.UUID) { return s.Slug, s.SpaceID },
+		func(id uuid.UUID, slug string, labels map[string]string, data string) error {
+			_, err := cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+			return err
+		})
+}
+
+// detectOnce runs one detection in every detector's space, for --mode=job
+func detectOnce(detectors []*DriftDetector) func(context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, d := range detectors {
+			if err := d.detectAndFixDrift(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", d.spaceSlug, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// reports returns the latest report of every detector that has one
+func reports(detectors []*DriftDetector) func() []*DriftReport {
+	return func() []*DriftReport {
+		reports := []*DriftReport{}
+		for _, d := range detectors {
+			d.mu.RLock()
+			if d.report != nil {
+				reports = append(reports, d.report)
+			}
+			d.mu.RUnlock()
+		}
+		return reports
+	}
+}
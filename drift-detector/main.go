@@ -1,30 +1,82 @@
-package main
+package driftdetector
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
-	"os/signal"
+	"sort"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/bulk"
+	"github.com/monadic/devops-examples/pkg/claudestub"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/driftstream"
+	"github.com/monadic/devops-examples/pkg/events"
+	"github.com/monadic/devops-examples/pkg/export"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/notify"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/prompts"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/shared"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	"github.com/monadic/devops-examples/pkg/unitdata"
 	sdk "github.com/monadic/devops-sdk"
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 )
 
 type DriftDetector struct {
 	app              *sdk.DevOpsApp
+	team             *tenants.Team // nil unless one detector runs per team
 	spaceID          uuid.UUID
+	spaceSlug        string
 	criticalSetID    uuid.UUID
 	targetID         uuid.UUID
 	currentChangeSet *sdk.ChangeSet
+	config           Config
+	notifier         *notify.Notifier
+	claude           claudeClient // nil without an API key, unless claude_mode is stub
+	llmClient        *llm.Client  // its usage and budget; nil unless calling the API
+	flags            *flags.Set
+	prompts          *prompts.Set       // AI prompts, replaceable from ConfigHub
+	cubLimit         *ratelimit.Limiter // throttles and coalesces ConfigHub reads
+	audit            *audit.Log
+	policy           *policy.Policy // which fixes go ahead
+	pending          *pending.Queue // fixes awaiting approval or retry
+	metrics          *metrics.Registry
+	stream           *driftstream.Hub // publishes each detection to cost-impact-monitor
+	bus              *events.Bus      // drift events on NATS; nil without nats_url
+	exporter         *export.Exporter // drift events to S3 or GCS; nil without export_config
+	cubBreaker       *breaker.Breaker
+	claudeBreaker    *breaker.Breaker
+
+	mu          sync.RWMutex
+	report      *DriftReport // latest detection, served by the drift API
+	drifted     bool         // the latest detection found drift
+	correlation string       // ID of the drift until the space is clean, see correlate
 }
 
 type DriftAnalysis struct {
@@ -35,12 +87,13 @@ type DriftAnalysis struct {
 }
 
 type DriftItem struct {
-	UnitID   uuid.UUID `json:"unit_id"`
-	UnitSlug string    `json:"unit_slug"`
-	Resource string    `json:"resource"`
-	Field    string    `json:"field"`
-	Expected string    `json:"expected"`
-	Actual   string    `json:"actual"`
+	UnitID      uuid.UUID `json:"unit_id"`
+	UnitSlug    string    `json:"unit_slug"`
+	Environment string    `json:"environment,omitempty"` // the unit's "env" label
+	Resource    string    `json:"resource"`
+	Field       string    `json:"field"`
+	Expected    string    `json:"expected"`
+	Actual      string    `json:"actual"`
 }
 
 type ProposedFix struct {
@@ -51,47 +104,201 @@ type ProposedFix struct {
 	Explanation string      `json:"explanation"`
 }
 
-func main() {
+// Main runs the drift detector until it is interrupted, or once with
+// --mode=job. It is the entry point of cmd/drift-detector and of
+// "devops-apps drift".
+func Main() {
 	// Check if demo mode was requested
 	if runDemoMode() {
 		return
 	}
 
-	config := sdk.DevOpsAppConfig{
+	logger := logging.Setup("drift-detector")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "drift-detector", "2.0.0")
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
 		Name:         "drift-detector",
 		Version:      "2.0.0",
 		Description:  "Detects and fixes Kubernetes configuration drift using ConfigHub Sets and Filters",
-		RunInterval:  5 * time.Minute,
-		HealthPort:   8080,
-		ClaudeAPIKey: os.Getenv("CLAUDE_API_KEY"),
-		CubToken:     os.Getenv("CUB_TOKEN"),
-		CubBaseURL:   sdk.GetEnvOrDefault("CUB_API_URL", "https://hub.confighub.com/api"),
+		RunInterval:  cfg.RunInterval,
+		HealthPort:   shared.HealthPort("drift-detector", 8080),
+		ClaudeAPIKey: cfg.ClaudeAPIKey,
+		CubToken:     cfg.CubToken,
+		CubBaseURL:   cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+	reg := metrics.Setup("drift-detector", app.Version, cfg.ClusterName)
+	if cfg.Pprof {
+		metrics.Profile(http.DefaultServeMux)
 	}
 
-	app, err := sdk.NewDevOpsApp(config)
+	notifier, err := notify.Load(cfg.NotifyConfig)
+	if err != nil {
+		logging.Fatal("Failed to load notify config", logging.Err(err))
+	}
+	pol, err := policy.Load(cfg.PolicyConfig)
 	if err != nil {
-		log.Fatalf("Failed to initialize app: %v", err)
+		logging.Fatal("Failed to load policy", logging.Err(err))
 	}
 
+	cubBreaker := shared.Value("confighub-breaker", func() *breaker.Breaker {
+		return breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	})
+	claudeBreaker := breaker.New("claude", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	cubLimit := shared.Value("confighub-limiter", func() *ratelimit.Limiter {
+		return ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker)
+	})
+	claude := newClaudeClient(cfg)
+	llmClient, _ := claude.(*llm.Client)
 	detector := &DriftDetector{
-		app: app,
+		app:           app,
+		config:        cfg,
+		notifier:      notifier,
+		claude:        guardClaude(claude, claudeBreaker),
+		llmClient:     llmClient,
+		flags:         flags.New("drift-detector", map[string]bool{flags.AutoFix: cfg.AutoFix}, flagsSource(app.Cub, cubLimit, cfg.FlagsSpace)),
+		prompts:       prompts.New([]prompts.Prompt{driftAnalysisPrompt}, promptsSource(app.Cub, cubLimit, cfg.PromptsSpace)),
+		cubLimit:      cubLimit,
+		policy:        pol,
+		cubBreaker:    cubBreaker,
+		claudeBreaker: claudeBreaker,
+		metrics:       reg,
+	}
+	detector.audit = newAuditLog(app.Cub, cubLimit, cfg.AuditSpace)
+	if detector.pending, err = newPendingQueue(app.Cub, cubLimit, cfg.PendingSpace, cfg.PendingDir); err != nil {
+		logging.Fatal("Failed to set up the pending queue", logging.Err(err))
+	}
+	reg.Collect(metrics.Limiter(cubLimit))
+	reg.Collect(metrics.ListCache(listcache.Install()))
+	reg.Collect(metrics.Breakers(cubBreaker, claudeBreaker))
+	reg.Collect(metrics.LLM(llmClient))
+	checker := health.New()
+	checker.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	checker.Register("confighub", health.Required, health.Breaker(cubBreaker))
+	checker.Register("claude", health.Optional, health.Breaker(claudeBreaker))
+	checker.Mount(http.DefaultServeMux)
+	if cfg.ClaudeMode == claudestub.ModeStub {
+		slog.Info("Claude stub mode, drift analyses are canned")
+	}
+
+	teams, err := tenants.Load(cfg.TeamsConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load teams config", logging.Err(err))
+	}
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+	guard.AcceptTokens(teams.HasToken)
+	pusher, err := metrics.LoadSinks(cfg.MetricsSinks, effective.ReadSecretFile, reg)
+	if err != nil {
+		logging.Fatal("Failed to load metrics sinks config", logging.Err(err))
+	}
+	detector.stream = driftstream.NewHub(teams)
+	if detector.bus, err = events.Connect(cfg.NATSURL, cfg.NATSToken, cfg.NATSSubjectPrefix, "drift-detector"); err != nil {
+		logging.Fatal("Failed to set up the event bus", logging.Err(err))
+	}
+	if detector.exporter, err = export.Load(cfg.ExportConfig, "drift-detector", effective.ReadSecretFile); err != nil {
+		logging.Fatal("Failed to load export config", logging.Err(err))
+	}
+	detectors := []*DriftDetector{detector}
+	if teams != nil {
+		if detectors, err = detector.teamDetectors(teams); err != nil {
+			logging.Fatal("Failed to set up team detectors", logging.Err(err))
+		}
+		slog.Info("Multi-tenant mode, one detector per team", "teams", len(teams.Teams()), "detectors", len(detectors))
+	}
+	for _, d := range detectors {
+		reg.Collect(d.collect)
 	}
 
 	// Initialize ConfigHub resources on startup
-	if err := detector.initialize(); err != nil {
-		log.Fatalf("Failed to initialize ConfigHub resources: %v", err)
+	for _, d := range detectors {
+		if err := d.initialize(); err != nil {
+			logging.Fatal("Failed to initialize ConfigHub resources", logging.Err(err))
+		}
+	}
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, cubLimit, runmode.Space())
+		err := runmode.Once(ctx, "drift-detector", detectOnce(detectors), reports(detectors), os.Stdout, store)
+		pusher.Push(ctx)
+		err = errors.Join(err, detector.exporter.Flush(ctx))
+		if err != nil {
+			logging.Fatal("Drift detection failed", logging.Err(err))
+		}
+		return
 	}
 
+	// Pick up the reports and drift of the previous run
+	checkpoints := newCheckpointer(app.Cub, cubLimit, cfg.CheckpointSpace)
+	for _, d := range detectors {
+		d.registerCheckpoint(checkpoints)
+	}
+	if err := checkpoints.Restore(ctx); err != nil {
+		slog.Warn("Failed to restore checkpoint", logging.Err(err))
+	}
+	group := lifecycle.New(ctx)
+	go detector.flags.Watch(group.Context(), cfg.FlagsRefresh)
+	go detector.prompts.Watch(group.Context(), cfg.PromptsRefresh)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	group.Go(func(ctx context.Context) error {
+		pusher.Run(ctx) // flushes on shutdown before the group is done
+		return nil
+	})
+	group.Go(func(ctx context.Context) error {
+		detector.exporter.Run(ctx)
+		return nil
+	})
+	group.Go(func(ctx context.Context) error {
+		checkpoints.Run(ctx, cfg.CheckpointInterval) // saves once more on shutdown
+		return nil
+	})
+	handlePendingFixes(detector.pending, detectors)
+	go detector.pending.Start(group.Context(), time.Minute)
+	apiAddr := fmt.Sprintf(":%d", cfg.APIPort)
+	slog.Info("Drift API listening", "addr", apiAddr)
+	group.Serve(apiAddr, detector.apiHandler(teams, guard, detectors))
+	group.Go(func(ctx context.Context) error {
+		slog.Info("Drift stream listening", "port", cfg.GRPCPort)
+		if err := detector.stream.Serve(ctx, fmt.Sprintf(":%d", cfg.GRPCPort)); err != nil {
+			slog.Error("Drift stream stopped", logging.Err(err))
+		}
+		return nil
+	})
+
 	// Run drift detection using Kubernetes informers (event-driven)
-	detector.RunWithInformers()
+	if err := runWithInformers(group, app, cfg, detectors); err != nil {
+		logging.Fatal("Failed to start informers", logging.Err(err))
+	}
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Drift detector stopped", logging.Err(err))
+	}
+	slog.Info("Received shutdown signal")
 }
 
 func (d *DriftDetector) initialize() error {
-	d.app.Logger.Println("Initializing ConfigHub resources...")
+	slog.Info("Initializing ConfigHub resources")
 
 	// Get or create space
-	spaceName := sdk.GetEnvOrDefault("CUB_SPACE", "drift-detector")
-	spaces, err := d.app.Cub.ListSpaces()
+	spaceName := d.config.Space
+	spaces, err := ratelimit.Call(context.Background(), d.cubLimit, "ListSpaces", d.cubKey(), d.app.Cub.ListSpaces)
 	if err != nil {
 		return fmt.Errorf("list spaces: %w", err)
 	}
@@ -117,11 +324,12 @@ func (d *DriftDetector) initialize() error {
 		if err != nil {
 			return fmt.Errorf("create space: %w", err)
 		}
-		d.app.Logger.Printf("Created new space: %s", space.SpaceID)
+		slog.Info("Created new space", logging.Space(space.Slug), "space_id", space.SpaceID)
 	} else {
-		d.app.Logger.Printf("Using existing space: %s", space.SpaceID)
+		slog.Info("Using existing space", logging.Space(space.Slug), "space_id", space.SpaceID)
 	}
 	d.spaceID = space.SpaceID
+	d.spaceSlug = space.Slug
 
 	// Create or get critical services set
 	sets, err := d.app.Cub.ListSets(d.spaceID)
@@ -150,26 +358,26 @@ func (d *DriftDetector) initialize() error {
 		if err != nil {
 			return fmt.Errorf("create set: %w", err)
 		}
-		d.app.Logger.Printf("Created critical services set: %s", criticalSet.SetID)
+		slog.Info("Created critical services set", "set_id", criticalSet.SetID)
 	} else {
-		d.app.Logger.Printf("Using existing critical services set: %s", criticalSet.SetID)
+		slog.Info("Using existing critical services set", "set_id", criticalSet.SetID)
 	}
 	d.criticalSetID = criticalSet.SetID
 
 	// Create or get Kubernetes target
-	targetSlug := sdk.GetEnvOrDefault("TARGET", "kubernetes-cluster")
+	targetSlug := d.config.Target
 	target, err := d.app.Cub.CreateTarget(sdk.Target{
 		Slug:        targetSlug,
 		DisplayName: "Kubernetes Cluster",
 		TargetType:  "kubernetes",
 		Config: map[string]string{
-			"namespace": sdk.GetEnvOrDefault("NAMESPACE", "default"),
-			"context":   sdk.GetEnvOrDefault("K8S_CONTEXT", ""),
+			"namespace": d.config.Namespace,
+			"context":   d.config.K8sContext,
 		},
 	})
 	if err != nil {
 		// Try to get existing
-		d.app.Logger.Printf("Target might already exist: %v", err)
+		slog.Warn("Target might already exist", logging.Cluster(targetSlug), logging.Err(err))
 		// For now, use a placeholder UUID
 		d.targetID = uuid.New()
 	} else {
@@ -185,47 +393,70 @@ func (d *DriftDetector) initialize() error {
 		Select:      []string{"UnitID", "Slug", "Data", "Labels"},
 	})
 	if err != nil {
-		d.app.Logger.Printf("Filter might already exist: %v", err)
+		slog.Warn("Filter might already exist", logging.Err(err))
 	} else {
-		d.app.Logger.Printf("Created filter: %s", filter.FilterID)
+		slog.Info("Created filter", "filter_id", filter.FilterID)
 	}
 
 	return nil
 }
 
-func (d *DriftDetector) detectAndFixDrift() error {
-	d.app.Logger.Println("Detecting drift using Sets and Filters...")
+func (d *DriftDetector) detectAndFixDrift(ctx context.Context) (err error) {
+	slog.Info("Detecting drift using Sets and Filters", logging.Space(d.spaceSlug))
+
+	// Each detection is one trace; the calls below are its child spans
+	ctx, span := tracing.Start(ctx, "drift.detect", tracing.SpaceKey.String(d.spaceSlug))
+	defer func() { tracing.End(span, err) }()
+	cycleDone := d.metrics.Cycle("detect", d.spaceSlug)
+	defer func() { cycleDone(err) }()
+
+	// While ConfigHub is down the API keeps serving the last report, marked degraded
+	defer func() {
+		if errors.Is(err, breaker.ErrOpen) {
+			d.markDegraded("ConfigHub unavailable, showing the last completed detection")
+		}
+	}()
 
 	// 1. Get units using filter for critical services
-	filter, err := d.getOrCreateFilter()
+	filter, err := tracing.Call(ctx, "confighub.CreateFilter", func() (*sdk.Filter, error) {
+		return breaker.Call(d.cubBreaker, d.getOrCreateFilter)
+	})
 	if err != nil {
 		return fmt.Errorf("get filter: %w", err)
 	}
 
-	units, err := d.app.Cub.ListUnits(sdk.ListUnitsParams{
-		SpaceID:  d.spaceID,
-		FilterID: &filter.FilterID,
+	units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+		return ratelimit.Call(ctx, d.cubLimit, "ListUnits", filter.FilterID.String(), func() ([]*sdk.Unit, error) {
+			return d.app.Cub.ListUnits(sdk.ListUnitsParams{
+				SpaceID:  d.spaceID,
+				FilterID: &filter.FilterID,
+			})
+		})
 	})
 	if err != nil {
 		return fmt.Errorf("list units with filter: %w", err)
 	}
 
-	d.app.Logger.Printf("Found %d critical units to monitor", len(units))
+	slog.Info("Found critical units to monitor", logging.Space(d.spaceSlug), "units", len(units))
 
 	// 2. Check each unit's live state
 	var driftItems []DriftItem
 	for _, unit := range units {
-		liveState, err := d.app.Cub.GetUnitLiveState(d.spaceID, unit.UnitID)
+		liveState, err := tracing.Call(ctx, "confighub.GetUnitLiveState", func() (*sdk.LiveState, error) {
+			return ratelimit.Call(ctx, d.cubLimit, "GetUnitLiveState", unit.UnitID.String(), func() (*sdk.LiveState, error) {
+				return d.app.Cub.GetUnitLiveState(d.spaceID, unit.UnitID)
+			})
+		}, tracing.UnitKey.String(unit.Slug))
 		if err != nil {
-			d.app.Logger.Printf("Failed to get live state for %s: %v", unit.Slug, err)
+			slog.Warn("Failed to get live state", logging.Unit(unit.Slug), logging.Err(err))
 			continue
 		}
 
 		if liveState.DriftDetected {
 			// Get actual state from Kubernetes
-			actualState, err := d.getActualK8sState(unit)
+			actualState, err := d.getActualK8sState(ctx, unit)
 			if err != nil {
-				d.app.Logger.Printf("Failed to get actual state for %s: %v", unit.Slug, err)
+				slog.Warn("Failed to get actual state", logging.Unit(unit.Slug), logging.Err(err))
 				continue
 			}
 
@@ -236,26 +467,35 @@ func (d *DriftDetector) detectAndFixDrift() error {
 	}
 
 	if len(driftItems) == 0 {
-		d.app.Logger.Println("No drift detected")
+		slog.Info("No drift detected", logging.Space(d.spaceSlug))
+		d.recordReport(&DriftAnalysis{Summary: "No drift detected"})
+		d.publishDrift(ctx, nil)
 		return nil
 	}
 
+	// Everything done about this drift, here and in the other apps, shares its ID
+	id := d.correlate()
+	ctx = correlation.With(ctx, id)
+	span.SetAttributes(tracing.CorrelationKey.String(id))
+
 	// 3. Create a ChangeSet for grouping drift corrections
-	changeSet, err := d.app.Cub.CreateChangeSet(d.spaceID, sdk.CreateChangeSetRequest{
-		DisplayName: fmt.Sprintf("Drift Corrections - %s", time.Now().Format("2006-01-02 15:04")),
-		Description: fmt.Sprintf("Automated drift corrections for %d items", len(driftItems)),
-		Labels: map[string]string{
-			"type":      "drift-correction",
-			"automated": "true",
-		},
+	changeSet, err := tracing.Call(ctx, "confighub.CreateChangeSet", func() (*sdk.ChangeSet, error) {
+		return d.app.Cub.CreateChangeSet(d.spaceID, sdk.CreateChangeSetRequest{
+			DisplayName: fmt.Sprintf("Drift Corrections - %s", time.Now().Format("2006-01-02 15:04")),
+			Description: fmt.Sprintf("Automated drift corrections for %d items", len(driftItems)),
+			Labels: correlation.Labels(ctx, map[string]string{
+				"type":      "drift-correction",
+				"automated": "true",
+			}),
+		})
 	})
 	if err != nil {
-		d.app.Logger.Printf("Failed to create ChangeSet: %v", err)
+		slog.Warn("Failed to create ChangeSet", logging.Err(err))
 		// Continue without ChangeSet
 		changeSet = nil
 	} else {
 		d.currentChangeSet = changeSet
-		d.app.Logger.Printf("Created ChangeSet %s for drift corrections", changeSet.ChangeSetID)
+		slog.Info("Created ChangeSet for drift corrections", "changeset_id", changeSet.ChangeSetID, logging.Correlation(id))
 	}
 
 	// 4. Analyze drift with Claude if available
@@ -265,28 +505,64 @@ func (d *DriftDetector) detectAndFixDrift() error {
 		Summary:  fmt.Sprintf("Detected %d drift items across %d units", len(driftItems), len(units)),
 	}
 
-	if d.app.Claude != nil {
-		enhancedAnalysis, err := d.analyzeWithClaude(driftItems, units)
-		if err != nil {
-			d.app.Logger.Printf("Claude analysis failed: %v", err)
-		} else {
+	if d.claude != nil {
+		enhancedAnalysis, err := d.analyzeWithClaude(ctx, driftItems, units)
+		switch {
+		case errors.Is(err, breaker.ErrOpen):
+			slog.Info("Claude unavailable, reporting drift without AI analysis")
+			analysis.Summary += " (AI analysis unavailable)"
+		case err != nil:
+			slog.Warn("Claude analysis failed", logging.Err(err))
+		default:
 			analysis = enhancedAnalysis
 		}
 	}
 
 	// 4. Report drift
-	d.reportDrift(analysis)
+	d.recordReport(analysis)
+	d.publishDrift(ctx, driftItems)
+	d.exportDrift(ctx, driftItems)
+	d.reportDrift(ctx, analysis)
+	d.notifyDrift(ctx, analysis)
 
 	// 5. Auto-fix using bulk operations if enabled
-	if sdk.GetEnvBool("AUTO_FIX", false) && len(analysis.Fixes) > 0 {
-		if err := d.applyFixes(analysis); err != nil {
-			d.app.Logger.Printf("Failed to apply fixes: %v", err)
+	if d.flags.Enabled(flags.AutoFix) && len(analysis.Fixes) > 0 {
+		if err := d.applyFixes(ctx, analysis); err != nil {
+			slog.Error("Failed to apply fixes", logging.Correlation(id), logging.Err(err))
 		}
 	}
 
 	return nil
 }
 
+// allowedFixes returns the units the policy lets the detector fix on its own
+// and queues the fixes it wants approved. Drift carries no cost, so its
+// severity comes from the environment alone.
+func (d *DriftDetector) allowedFixes(ctx context.Context, analysis *DriftAnalysis, fixesByUnit map[string][]ProposedFix, units []string) []string {
+	environments := make(map[string]string, len(analysis.Items))
+	for _, item := range analysis.Items {
+		environments[item.UnitSlug] = item.Environment
+	}
+	var allowed []string
+	for _, slug := range units {
+		severity, _ := policy.Score(0, environments[slug])
+		decision := d.policy.Check(ctx, d.audit, policy.Action{
+			App: "drift-detector", Kind: policy.Fix, Target: slug,
+			Environment: environments[slug], Severity: severity,
+		})
+		switch decision.Verdict {
+		case policy.Allow:
+			allowed = append(allowed, slug)
+		case policy.Approve:
+			reason := fmt.Sprintf("policy rule %s asks for approval", decision.Rule)
+			if _, err := d.pending.Wait(ctx, policy.Fix, d.spaceSlug, slug, fixesByUnit[slug], reason); err != nil {
+				slog.Warn("Failed to queue fix for approval", logging.Unit(slug), logging.Err(err))
+			}
+		}
+	}
+	return allowed
+}
+
 func (d *DriftDetector) getOrCreateFilter() (*sdk.Filter, error) {
 	// In production, would cache this or get by ID
 	return d.app.Cub.CreateFilter(d.spaceID, sdk.CreateFilterRequest{
@@ -297,29 +573,28 @@ func (d *DriftDetector) getOrCreateFilter() (*sdk.Filter, error) {
 	})
 }
 
-func (d *DriftDetector) getActualK8sState(unit *sdk.Unit) (map[string]interface{}, error) {
+func (d *DriftDetector) getActualK8sState(ctx context.Context, unit *sdk.Unit) (map[string]interface{}, error) {
 	// Parse unit data to understand what resource to check
-	var unitData map[string]interface{}
-	if err := json.Unmarshal([]byte(unit.Data), &unitData); err != nil {
-		return nil, fmt.Errorf("parse unit data: %w", err)
+	manifest, err := unitdata.Parse(unit.Slug, unit.Data)
+	if err != nil {
+		return nil, err
 	}
-
-	// Extract resource type and name
-	resourceType := unitData["kind"].(string)
-	metadata := unitData["metadata"].(map[string]interface{})
-	name := metadata["name"].(string)
-	namespace := sdk.GetEnvOrDefault("NAMESPACE", "default")
+	resourceType, name := manifest.Kind(), manifest.Name()
+	namespace := d.config.Namespace
 
 	// Use Kubernetes client to get the resource
 	switch strings.ToLower(resourceType) {
 	case "deployment":
-		deployment, err := d.app.K8s.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		deployment, err := tracing.Call(ctx, "k8s.GetDeployment", func() (*appsv1.Deployment, error) {
+			return d.app.K8s.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		}, tracing.UnitKey.String(unit.Slug))
 		if err != nil {
 			return nil, err
 		}
+		// float64 like the unit's decoded JSON, so compareStates sees drift
 		return map[string]interface{}{
 			"spec": map[string]interface{}{
-				"replicas": *deployment.Spec.Replicas,
+				"replicas": float64(*deployment.Spec.Replicas),
 			},
 		}, nil
 	default:
@@ -347,60 +622,43 @@ func (d *DriftDetector) compareStates(unit *sdk.Unit, actualState map[string]int
 	var items []DriftItem
 
 	// Parse expected state from unit
-	var expectedState map[string]interface{}
-	if err := json.Unmarshal([]byte(unit.Data), &expectedState); err != nil {
-		d.app.Logger.Printf("Failed to parse unit data: %v", err)
+	expected, err := unitdata.Parse(unit.Slug, unit.Data)
+	if err != nil {
+		slog.Warn("Failed to parse unit data", logging.Unit(unit.Slug), logging.Err(err))
 		return items
 	}
 
-	// Simple comparison - check key fields
-	if expectedSpec, ok := expectedState["spec"].(map[string]interface{}); ok {
-		if actualSpec, ok := actualState["spec"].(map[string]interface{}); ok {
-			// Check replicas for deployments
-			if expectedReplicas, ok := expectedSpec["replicas"].(float64); ok {
-				if actualReplicas, ok := actualSpec["replicas"].(float64); ok {
-					if expectedReplicas != actualReplicas {
-						items = append(items, DriftItem{
-							UnitID:   unit.UnitID,
-							UnitSlug: unit.Slug,
-							Resource: fmt.Sprintf("%s/%s", expectedState["kind"], expectedState["metadata"].(map[string]interface{})["name"]),
-							Field:    "spec.replicas",
-							Expected: fmt.Sprintf("%.0f", expectedReplicas),
-							Actual:   fmt.Sprintf("%.0f", actualReplicas),
-						})
-					}
-				}
-			}
-		}
+	// Simple comparison - check replicas for deployments
+	expectedReplicas, found, err := expected.Int("spec", "replicas")
+	if err != nil {
+		slog.Warn("Invalid unit data", logging.Unit(unit.Slug), logging.Err(err))
+		return items
+	}
+	actualSpec, _ := actualState["spec"].(map[string]interface{})
+	if actualReplicas, ok := actualSpec["replicas"].(float64); ok && found && float64(expectedReplicas) != actualReplicas {
+		items = append(items, DriftItem{
+			UnitID:      unit.UnitID,
+			UnitSlug:    unit.Slug,
+			Environment: unit.Labels["env"],
+			Resource:    fmt.Sprintf("%s/%s", expected.Kind(), expected.Name()),
+			Field:       "spec.replicas",
+			Expected:    fmt.Sprintf("%d", expectedReplicas),
+			Actual:      fmt.Sprintf("%.0f", actualReplicas),
+		})
 	}
 
 	return items
 }
 
-func (d *DriftDetector) analyzeWithClaude(driftItems []DriftItem, units []*sdk.Unit) (*DriftAnalysis, error) {
-	prompt := fmt.Sprintf(`Analyze this Kubernetes configuration drift and suggest fixes.
-
-Drift Items:
-%s
-
-Return JSON with this structure:
-{
-  "has_drift": true,
-  "items": [...existing items...],
-  "summary": "Clear explanation of the drift and its impact",
-  "fixes": [
-    {
-      "unit_id": "uuid",
-      "unit_slug": "unit-name",
-      "patch_path": "/spec/replicas",
-      "patch_value": 3,
-      "explanation": "Why this fix is needed"
-    }
-  ]
-}`,
-		d.jsonPretty(driftItems))
-
-	response, err := d.app.Claude.Complete(prompt)
+func (d *DriftDetector) analyzeWithClaude(ctx context.Context, driftItems []DriftItem, units []*sdk.Unit) (*DriftAnalysis, error) {
+	prompt, err := d.prompts.Render(driftAnalysisPrompt, driftPromptData{Space: d.spaceSlug, Items: driftItems})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := tracing.Call(ctx, "claude.Complete", func() (string, error) {
+		return d.claude.Complete(prompt)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -413,160 +671,257 @@ Return JSON with this structure:
 	return &analysis, nil
 }
 
-func (d *DriftDetector) reportDrift(analysis *DriftAnalysis) {
-	d.app.Logger.Println("=== DRIFT DETECTION REPORT ===")
-	d.app.Logger.Printf("Summary: %s", analysis.Summary)
-	d.app.Logger.Printf("Total Drift Items: %d", len(analysis.Items))
+func (d *DriftDetector) reportDrift(ctx context.Context, analysis *DriftAnalysis) {
+	incident := logging.Correlation(correlation.FromContext(ctx))
+	slog.Warn("Drift detected", logging.Space(d.spaceSlug), incident, "items", len(analysis.Items), "summary", analysis.Summary)
 
 	for _, item := range analysis.Items {
-		d.app.Logger.Printf("  ⚠️  %s [%s]: %s expected=%s, actual=%s",
-			item.UnitSlug, item.Resource, item.Field, item.Expected, item.Actual)
+		slog.Warn("Drift item", logging.Unit(item.UnitSlug), incident, "resource", item.Resource,
+			"field", item.Field, "expected", item.Expected, "actual", item.Actual)
 	}
 
-	if len(analysis.Fixes) > 0 {
-		d.app.Logger.Println("Proposed Fixes:")
-		for _, fix := range analysis.Fixes {
-			d.app.Logger.Printf("  ✅ %s: %s", fix.UnitSlug, fix.Explanation)
-		}
+	for _, fix := range analysis.Fixes {
+		slog.Info("Proposed fix", logging.Unit(fix.UnitSlug), incident, "path", fix.PatchPath, "explanation", fix.Explanation)
+	}
+}
+
+// notifyDrift sends the drift report to the configured notification channels.
+// The same set of drifted fields is only announced once per dedup window.
+func (d *DriftDetector) notifyDrift(ctx context.Context, analysis *DriftAnalysis) {
+	if !d.notifier.Enabled() {
+		return
+	}
+
+	fields := map[string]string{
+		"space":    d.spaceSlug,
+		"items":    fmt.Sprintf("%d", len(analysis.Items)),
+		"auto_fix": fmt.Sprintf("%t", d.flags.Enabled(flags.AutoFix)),
+	}
+	keys := make([]string, 0, len(analysis.Items))
+	for _, item := range analysis.Items {
+		fields[item.UnitSlug+" "+item.Field] = fmt.Sprintf("expected=%s, actual=%s", item.Expected, item.Actual)
+		keys = append(keys, fmt.Sprintf("%s/%s/%s=%s", item.UnitSlug, item.Resource, item.Field, item.Actual))
+	}
+	sort.Strings(keys)
+
+	err := d.notifier.Notify(context.Background(), notify.Notification{
+		App:      "drift-detector",
+		Kind:     "drift",
+		Severity: notify.Warning,
+		Title:    fmt.Sprintf("Drift detected in %s", d.spaceSlug),
+		Summary:  analysis.Summary,
+		Fields:   fields,
+		DedupKey: "drift/" + strings.Join(keys, ","),
+
+		CorrelationID: correlation.FromContext(ctx),
+	})
+	if err != nil {
+		slog.Warn("Failed to send drift notification", logging.Err(err))
 	}
 }
 
-func (d *DriftDetector) applyFixes(analysis *DriftAnalysis) error {
-	d.app.Logger.Println("Applying fixes using push-upgrade pattern...")
+func (d *DriftDetector) applyFixes(ctx context.Context, analysis *DriftAnalysis) error {
+	incident := logging.Correlation(correlation.FromContext(ctx))
+	slog.Info("Applying fixes using push-upgrade pattern", "fixes", len(analysis.Fixes), incident)
+	if d.team != nil {
+		ctx = tenants.WithTeam(ctx, d.team) // audited as fixes in the team's space
+	}
 
 	// Group fixes by unit
-	fixesByUnit := make(map[uuid.UUID][]ProposedFix)
+	fixesByUnit := make(map[string][]ProposedFix)
+	var units []string
 	for _, fix := range analysis.Fixes {
-		fixesByUnit[fix.UnitID] = append(fixesByUnit[fix.UnitID], fix)
-	}
-
-	// Apply fixes using bulk patch with upgrade
-	for unitID, fixes := range fixesByUnit {
-		patch := make(map[string]interface{})
-		for _, fix := range fixes {
-			// Build patch document
-			pathParts := strings.Split(fix.PatchPath, "/")
-			current := patch
-			for _, part := range pathParts[1 : len(pathParts)-1] {
-				if _, ok := current[part]; !ok {
-					current[part] = make(map[string]interface{})
-				}
-				current = current[part].(map[string]interface{})
+		if _, ok := fixesByUnit[fix.UnitSlug]; !ok {
+			units = append(units, fix.UnitSlug)
+		}
+		fixesByUnit[fix.UnitSlug] = append(fixesByUnit[fix.UnitSlug], fix)
+	}
+	units = d.allowedFixes(ctx, analysis, fixesByUnit, units)
+	if len(units) == 0 {
+		return nil
+	}
+
+	// Patch each unit with push-upgrade and apply it, so a failure is
+	// reported for its unit and retried without redoing the others
+	report := bulk.Run(ctx, "fix", units, func(ctx context.Context, slug string) error {
+		return d.fixUnit(ctx, fixesByUnit[slug])
+	})
+	for _, slug := range report.Succeeded {
+		slog.Info("Applied fix", logging.Unit(slug), incident)
+		d.audit.Record(ctx, audit.FixApplied, slug, fixesByUnit[slug], nil)
+	}
+	for _, f := range report.Failed {
+		slog.Error("Failed to fix unit", logging.Unit(f.Unit), "attempts", f.Attempts, "kind", f.Kind, incident, logging.Err(f.Err))
+		d.audit.Record(ctx, audit.FixApplied, f.Unit, fixesByUnit[f.Unit], f.Err)
+		if _, err := d.pending.Retry(ctx, policy.Fix, d.spaceSlug, f.Unit, fixesByUnit[f.Unit], f.Err); err != nil {
+			slog.Warn("Failed to queue fix for retry", logging.Unit(f.Unit), logging.Err(err))
+		}
+	}
+
+	// Bulk apply all units in the critical set
+	setErr := d.applyCriticalSet(ctx)
+	if setErr != nil {
+		slog.Error("Failed to apply critical services", incident, logging.Err(setErr))
+	}
+
+	slog.Info("Applied fixes", "units", len(report.Succeeded), "failed", len(report.Failed), "retried", report.Retried, incident)
+	return errors.Join(report.Err(), setErr)
+}
+
+// fixUnit patches one unit with its fixes, pushing the change downstream,
+// and applies it
+func (d *DriftDetector) fixUnit(ctx context.Context, fixes []ProposedFix) error {
+	unitID := fixes[0].UnitID
+	patch := make(map[string]interface{})
+	for _, fix := range fixes {
+		// Build patch document
+		pathParts := strings.Split(fix.PatchPath, "/")
+		current := patch
+		for _, part := range pathParts[1 : len(pathParts)-1] {
+			if _, ok := current[part]; !ok {
+				current[part] = make(map[string]interface{})
 			}
-			lastPart := pathParts[len(pathParts)-1]
-			current[lastPart] = fix.PatchValue
+			current = current[part].(map[string]interface{})
 		}
+		lastPart := pathParts[len(pathParts)-1]
+		current[lastPart] = fix.PatchValue
+	}
 
-		// Apply patch with push-upgrade
-		err := d.app.Cub.BulkPatchUnits(sdk.BulkPatchParams{
+	unitAttr := tracing.UnitKey.String(unitID.String())
+	err := tracing.Do(ctx, "confighub.BulkPatchUnits", func(context.Context) error {
+		return d.app.Cub.BulkPatchUnits(sdk.BulkPatchParams{
 			SpaceID: d.spaceID,
 			Where:   fmt.Sprintf("UnitID = '%s'", unitID),
 			Patch:   patch,
 			Upgrade: true, // Push changes downstream
 		})
-		if err != nil {
-			d.app.Logger.Printf("Failed to patch unit %s: %v", unitID, err)
-			continue
-		}
+	}, unitAttr)
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
 
-		// Apply the fixed unit to Kubernetes
-		err = d.app.Cub.ApplyUnit(d.spaceID, unitID)
-		if err != nil {
-			d.app.Logger.Printf("Failed to apply unit %s: %v", unitID, err)
-			continue
-		}
+	// Apply the fixed unit to Kubernetes
+	err = tracing.Do(ctx, "confighub.ApplyUnit", func(context.Context) error {
+		return d.app.Cub.ApplyUnit(d.spaceID, unitID)
+	}, unitAttr)
+	if err != nil {
+		return fmt.Errorf("apply: %w", err)
+	}
+	return nil
+}
 
-		d.app.Logger.Printf("Successfully applied fix to unit %s", unitID)
+// applyCriticalSet applies the critical services in one bulk call. The call
+// fails or succeeds as a whole, so when it fails the set's units are applied
+// one by one to find, retry and report the ones that fail.
+func (d *DriftDetector) applyCriticalSet(ctx context.Context) error {
+	where := fmt.Sprintf("SetIDs contains '%s'", d.criticalSetID)
+	err := tracing.Do(ctx, "confighub.BulkApplyUnits", func(context.Context) error {
+		return d.app.Cub.BulkApplyUnits(sdk.BulkApplyParams{
+			SpaceID: d.spaceID,
+			Where:   where,
+			DryRun:  false,
+		})
+	})
+	if err == nil {
+		return nil
 	}
+	slog.Warn("Bulk apply of critical services failed, applying units one by one", logging.Err(err))
 
-	// Bulk apply all units in the critical set
-	err := d.app.Cub.BulkApplyUnits(sdk.BulkApplyParams{
-		SpaceID: d.spaceID,
-		Where:   fmt.Sprintf("SetIDs contains '%s'", d.criticalSetID),
-		DryRun:  false,
+	units, listErr := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+		return d.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: d.spaceID, Where: where})
 	})
-	if err != nil {
+	if listErr != nil {
 		return fmt.Errorf("bulk apply critical services: %w", err)
 	}
-
-	d.app.Logger.Printf("Applied fixes to %d units", len(fixesByUnit))
-	return nil
+	ids := make(map[string]uuid.UUID, len(units))
+	slugs := make([]string, 0, len(units))
+	for _, u := range units {
+		ids[u.Slug] = u.UnitID
+		slugs = append(slugs, u.Slug)
+	}
+	report := bulk.Run(ctx, "apply critical services", slugs, func(ctx context.Context, slug string) error {
+		return tracing.Do(ctx, "confighub.ApplyUnit", func(context.Context) error {
+			return d.app.Cub.ApplyUnit(d.spaceID, ids[slug])
+		}, tracing.UnitKey.String(slug))
+	})
+	for _, f := range report.Failed {
+		slog.Error("Failed to apply unit", logging.Unit(f.Unit), "attempts", f.Attempts, "kind", f.Kind, logging.Err(f.Err))
+	}
+	return report.Err()
 }
 
-// RunWithInformers implements event-driven architecture using Kubernetes informers
-func (d *DriftDetector) RunWithInformers() error {
-	d.app.Logger.Printf("%s v%s started with informers", d.app.Name, d.app.Version)
+// runWithInformers implements event-driven architecture using Kubernetes
+// informers, shared by the detectors and stopped with the group
+func runWithInformers(group *lifecycle.Group, app *sdk.DevOpsApp, cfg Config, detectors []*DriftDetector) error {
+	slog.Info("Started with informers", "version", app.Version)
 
 	// Create informer factory
-	informerFactory := informers.NewSharedInformerFactory(d.app.K8s.Clientset, time.Minute*10)
+	informerFactory := newInformerFactory(app.K8s.Clientset, cfg)
 
 	// Register handlers for relevant resources
 	deploymentInformer := informerFactory.Apps().V1().Deployments().Informer()
-	deploymentInformer.AddEventHandler(&ResourceEventHandler{
-		detector: d,
-	})
-
 	serviceInformer := informerFactory.Core().V1().Services().Informer()
-	serviceInformer.AddEventHandler(&ResourceEventHandler{
-		detector: d,
-	})
-
 	configMapInformer := informerFactory.Core().V1().ConfigMaps().Informer()
-	configMapInformer.AddEventHandler(&ResourceEventHandler{
-		detector: d,
-	})
+	ctx := group.Context()
+	for _, d := range detectors {
+		deploymentInformer.AddEventHandler(&ResourceEventHandler{ctx: ctx, detector: d})
+		serviceInformer.AddEventHandler(&ResourceEventHandler{ctx: ctx, detector: d})
+		configMapInformer.AddEventHandler(&ResourceEventHandler{ctx: ctx, detector: d})
+	}
 
 	// Start informers
-	stopCh := make(chan struct{})
-	defer close(stopCh)
-	informerFactory.Start(stopCh)
+	group.Informers(informerFactory)
 
 	// Wait for caches to sync
-	if !cache.WaitForCacheSync(stopCh, deploymentInformer.HasSynced, serviceInformer.HasSynced, configMapInformer.HasSynced) {
+	if !cache.WaitForCacheSync(ctx.Done(), deploymentInformer.HasSynced, serviceInformer.HasSynced, configMapInformer.HasSynced) {
 		return fmt.Errorf("failed to sync caches")
 	}
 
-	d.app.Logger.Println("Informers started, watching for changes...")
-
-	// Setup signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	slog.Info("Informers started, watching for changes")
 
 	// Run initial detection
-	if err := d.detectAndFixDrift(); err != nil {
-		d.app.Logger.Printf("Initial detection error: %v", err)
+	for _, d := range detectors {
+		if err := d.detectAndFixDrift(ctx); err != nil {
+			slog.Error("Initial detection failed", logging.Space(d.spaceSlug), logging.Err(err))
+		}
 	}
-
-	// Wait for shutdown signal
-	<-sigChan
-	d.app.Logger.Println("Received shutdown signal")
 	return nil
 }
 
+// ResourceEventHandler runs a detection on informer events. Informers have
+// no per-event context, so detections run under the app's, which is
+// cancelled on shutdown.
 type ResourceEventHandler struct {
+	ctx      context.Context
 	detector *DriftDetector
 }
 
 func (h *ResourceEventHandler) OnAdd(obj interface{}, isInInitialList bool) {
-	if !isInInitialList {
-		h.detector.app.Logger.Printf("Resource added, triggering drift detection...")
-		if err := h.detector.detectAndFixDrift(); err != nil {
-			h.detector.app.Logger.Printf("Handler error: %v", err)
+	if !isInInitialList && h.detector.watches(obj) {
+		slog.Debug("Resource added, triggering drift detection")
+		if err := h.detector.detectAndFixDrift(h.ctx); err != nil {
+			slog.Error("Drift detection failed", "event", "add", logging.Err(err))
 		}
 	}
 }
 
 func (h *ResourceEventHandler) OnUpdate(oldObj, newObj interface{}) {
-	h.detector.app.Logger.Printf("Resource updated, triggering drift detection...")
-	if err := h.detector.detectAndFixDrift(); err != nil {
-		h.detector.app.Logger.Printf("Handler error: %v", err)
+	if !h.detector.watches(newObj) {
+		return
+	}
+	slog.Debug("Resource updated, triggering drift detection")
+	if err := h.detector.detectAndFixDrift(h.ctx); err != nil {
+		slog.Error("Drift detection failed", "event", "update", logging.Err(err))
 	}
 }
 
 func (h *ResourceEventHandler) OnDelete(obj interface{}) {
-	h.detector.app.Logger.Printf("Resource deleted, triggering drift detection...")
-	if err := h.detector.detectAndFixDrift(); err != nil {
-		h.detector.app.Logger.Printf("Handler error: %v", err)
+	if !h.detector.watches(obj) {
+		return
+	}
+	slog.Debug("Resource deleted, triggering drift detection")
+	if err := h.detector.detectAndFixDrift(h.ctx); err != nil {
+		slog.Error("Drift detection failed", "event", "delete", logging.Err(err))
 	}
 }
 
@@ -1,9 +1,20 @@
-package main
+package driftdetector
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/notify"
 	sdk "github.com/monadic/devops-sdk"
 )
 
@@ -49,6 +60,28 @@ func TestCompareStates(t *testing.T) {
 	}
 }
 
+func TestMalformedUnitData(t *testing.T) {
+	detector := &DriftDetector{config: Config{Namespace: "default"}}
+	actualState := map[string]interface{}{"spec": map[string]interface{}{"replicas": float64(5)}}
+
+	for _, data := range []string{
+		`{"metadata":{"name":"test"},"spec":{"replicas":3}}`,            // no kind
+		`{"kind":"Deployment","metadata":"test","spec":{"replicas":3}}`, // metadata not an object
+		`{"kind":"Deployment","metadata":{"name":"test"},"spec":{"replicas":"3"}}`,
+		`not json`,
+	} {
+		unit := &sdk.Unit{UnitID: uuid.New(), Slug: "broken", Data: data}
+		if items := detector.compareStates(unit, actualState); len(items) != 0 {
+			t.Errorf("%s: want no drift from a malformed unit, got %+v", data, items)
+		}
+	}
+
+	unit := &sdk.Unit{UnitID: uuid.New(), Slug: "broken", Data: `{"kind":"Deployment","metadata":{"name":7}}`}
+	if _, err := detector.getActualK8sState(context.Background(), unit); err == nil || !strings.Contains(err.Error(), "unit broken: invalid manifest: metadata.name") {
+		t.Errorf("want an error naming the unit and field, got %v", err)
+	}
+}
+
 func TestDriftAnalysisJSON(t *testing.T) {
 	analysis := &DriftAnalysis{
 		HasDrift: true,
@@ -288,3 +321,126 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestNotifyDrift(t *testing.T) {
+	var received []notify.Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notify.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		received = append(received, n)
+	}))
+	defer server.Close()
+
+	notifier, err := notify.Config{
+		Channels: []notify.ChannelConfig{{Name: "hook", Type: "webhook", URL: server.URL}},
+		Routes:   []notify.RouteConfig{{MinSeverity: notify.Warning, Channels: []string{"hook"}}},
+	}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := &DriftDetector{spaceSlug: "drift-test", notifier: notifier, flags: flags.New("drift-detector", nil, nil)}
+
+	analysis := &DriftAnalysis{
+		HasDrift: true,
+		Items: []DriftItem{
+			{UnitSlug: "backend-api", Resource: "Deployment/backend-api", Field: "spec.replicas", Expected: "3", Actual: "5"},
+		},
+		Summary: "Detected 1 drift items across 1 units",
+	}
+	ctx := correlation.With(context.Background(), "incident-1")
+	detector.notifyDrift(ctx, analysis)
+	detector.notifyDrift(ctx, analysis) // unchanged drift is not announced again
+
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(received))
+	}
+	n := received[0]
+	if n.Kind != "drift" || n.Severity != notify.Warning || n.Fields["backend-api spec.replicas"] != "expected=3, actual=5" ||
+		n.CorrelationID != "incident-1" {
+		t.Errorf("Unexpected notification: %+v", n)
+	}
+
+	analysis.Items[0].Actual = "7"
+	detector.notifyDrift(ctx, analysis)
+	if len(received) != 2 {
+		t.Errorf("Expected changed drift to be announced, got %d notifications", len(received))
+	}
+}
+
+func TestDriftCorrelation(t *testing.T) {
+	detector := &DriftDetector{spaceSlug: "drift-test"}
+	items := []DriftItem{{UnitSlug: "backend-api", Field: "spec.replicas", Expected: "2", Actual: "5"}}
+
+	// The drift keeps its ID across runs until the space is clean
+	id := detector.correlate()
+	detector.publishDrift(correlation.With(context.Background(), id), items)
+	if again := detector.correlate(); id == "" || again != id {
+		t.Fatalf("Expected the ongoing drift to keep ID %q, got %q", id, again)
+	}
+
+	detector.publishDrift(context.Background(), nil)
+	if next := detector.correlate(); next == id {
+		t.Errorf("Expected new drift after a clean run to get a new ID, got %q again", next)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("cub_space: acorn-bear-qa\nnamespace: qa\nauto_fix: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("NAMESPACE", "staging")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Space != "acorn-bear-qa" || cfg.Namespace != "staging" || !cfg.AutoFix || cfg.APIPort != 8084 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if setting, _ := effective.Get("namespace"); setting.Env != "NAMESPACE" || setting.Value != "staging" {
+		t.Errorf("Expected NAMESPACE override to be reported, got %+v", setting)
+	}
+
+	t.Setenv("DRIFT_API_PORT", "70000")
+	if _, _, err := loadConfig(); err == nil {
+		t.Error("Expected invalid drift_api_port to be rejected")
+	}
+}
+
+// BenchmarkCompareStates compares a large cluster's worth of units, as one
+// detection run does
+func BenchmarkCompareStates(b *testing.B) {
+	detector := &DriftDetector{}
+	const units = 1000
+	unitList := make([]*sdk.Unit, units)
+	actual := make([]map[string]interface{}, units)
+	for i := range unitList {
+		unitList[i] = &sdk.Unit{
+			UnitID: uuid.New(),
+			Slug:   fmt.Sprintf("app-%d", i),
+			Data:   fmt.Sprintf(`{"kind":"Deployment","metadata":{"name":"app-%d"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"name":"app","image":"app:1.%d"}]}}}}`, i, i),
+		}
+		// every tenth deployment has drifted
+		replicas := float64(3)
+		if i%10 == 0 {
+			replicas = 5
+		}
+		actual[i] = map[string]interface{}{"spec": map[string]interface{}{"replicas": replicas}}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		drifted := 0
+		for i, unit := range unitList {
+			drifted += len(detector.compareStates(unit, actual[i]))
+		}
+		if drifted != units/10 {
+			b.Fatalf("Expected %d drifted units, got %d", units/10, drifted)
+		}
+	}
+}
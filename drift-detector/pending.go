@@ -0,0 +1,101 @@
+package driftdetector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newPendingQueue returns the detector's queue of fixes awaiting approval or
+// retry, kept as units of the space with slug space, else as files in dir;
+// in memory only without either
+func newPendingQueue(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space, dir string) (*pending.Queue, error) {
+	if cub == nil || space == "" {
+		if dir == "" {
+			return pending.New("drift-detector", nil, nil), nil
+		}
+		writer, reader, err := pending.Dir(dir)
+		if err != nil {
+			return nil, err
+		}
+		return pending.New("drift-detector", writer, reader), nil
+	}
+	spaceID := func(ctx context.Context) (uuid.UUID, error) {
+		spaces, err := ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug == space {
+				return s.SpaceID, nil
+			}
+		}
+		return uuid.Nil, fmt.Errorf("pending space %s not found", space)
+	}
+
+	writer := func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return err
+		}
+		units, err := cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: fmt.Sprintf("Slug = '%s'", slug)})
+		if err != nil {
+			return fmt.Errorf("list units: %w", err)
+		}
+		if len(units) > 0 {
+			_, err = cub.UpdateUnit(id, units[0].UnitID, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+			return err
+		}
+		_, err = cub.CreateUnit(id, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+		return err
+	}
+	reader := func(ctx context.Context, where string) ([]string, error) {
+		id, err := spaceID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		units, err := ratelimit.Call(ctx, limit, "ListUnits", id.String()+"/"+where, func() ([]*sdk.Unit, error) {
+			return cub.ListUnits(sdk.ListUnitsParams{SpaceID: id, Where: where})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list units: %w", err)
+		}
+		data := make([]string, 0, len(units))
+		for _, u := range units {
+			data = append(data, u.Data)
+		}
+		return data, nil
+	}
+	return pending.New("drift-detector", writer, reader), nil
+}
+
+// handlePendingFixes runs the queued fixes of every detector, each with the
+// detector watching the fix's space
+func handlePendingFixes(queue *pending.Queue, detectors []*DriftDetector) {
+	queue.Handle(policy.Fix, func(ctx context.Context, a pending.Action) error {
+		var fixes []ProposedFix
+		if err := json.Unmarshal(a.Input, &fixes); err != nil || len(fixes) == 0 {
+			return fmt.Errorf("queued fix of %s has no patches", a.Target)
+		}
+		for _, d := range detectors {
+			if d.spaceSlug != a.Space {
+				continue
+			}
+			if d.team != nil {
+				ctx = tenants.WithTeam(ctx, d.team)
+			}
+			err := d.fixUnit(ctx, fixes)
+			d.audit.Record(ctx, audit.FixApplied, a.Target, fixes, err)
+			return err
+		}
+		return fmt.Errorf("no detector watches space %s", a.Space)
+	})
+}
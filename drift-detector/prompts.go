@@ -0,0 +1,80 @@
+package driftdetector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/prompts"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// driftAnalysisPrompt asks for an explanation of the drift and the patches
+// that remove it; the data is driftPromptData. The stub responder reads the
+// items back from the "Drift Items:" block.
+var driftAnalysisPrompt = prompts.Prompt{
+	Name: "drift-analysis",
+	Text: `Analyze this Kubernetes configuration drift and suggest fixes.
+
+Drift Items:
+{{json .Items}}
+
+Return JSON with this structure:
+{
+  "has_drift": true,
+  "items": [...existing items...],
+  "summary": "Clear explanation of the drift and its impact",
+  "fixes": [
+    {
+      "unit_id": "uuid",
+      "unit_slug": "unit-name",
+      "patch_path": "/spec/replicas",
+      "patch_value": 3,
+      "explanation": "Why this fix is needed"
+    }
+  ]
+}`,
+}
+
+// driftPromptData is what driftAnalysisPrompt can refer to
+type driftPromptData struct {
+	Space string
+	Items []DriftItem
+}
+
+// promptsSource reads the prompt units of the space with slug space; nil
+// when the built-in prompts are always used (no space or no ConfigHub client)
+func promptsSource(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) prompts.Source {
+	if cub == nil || space == "" {
+		return nil
+	}
+	return func(ctx context.Context) ([]prompts.Unit, error) {
+		spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+			return ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug != space {
+				continue
+			}
+			units, err := ratelimit.Call(ctx, limit, "ListUnits", s.SpaceID.String(), func() ([]*sdk.Unit, error) {
+				return cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.SpaceID})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("list units: %w", err)
+			}
+			var found []prompts.Unit
+			for _, u := range units {
+				if strings.HasPrefix(u.Slug, prompts.UnitPrefix) {
+					found = append(found, prompts.Unit{Slug: u.Slug, Data: u.Data, Revision: u.HeadRevisionNum})
+				}
+			}
+			return found, nil
+		}
+		return nil, fmt.Errorf("prompts space %s not found", space)
+	}
+}
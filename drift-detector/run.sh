@@ -82,7 +82,7 @@ fi
 # Build if needed
 if [ ! -f drift-detector ] || [ main.go -nt drift-detector ]; then
     echo -e "${YELLOW}🔨 Building drift-detector...${NC}"
-    go build -o drift-detector .
+    go build -o drift-detector ./cmd/drift-detector
     echo -e "${GREEN}✅ Build complete${NC}"
 fi
 
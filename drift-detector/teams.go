@@ -0,0 +1,120 @@
+package driftdetector
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/tenants"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/client-go/tools/cache"
+)
+
+// teamDetectors returns a detector per team, each watching the team's space
+// and namespace with the team's ConfigHub token. Teams that see every space
+// ("*") only read the others' reports and get no detector of their own.
+func (d *DriftDetector) teamDetectors(teams *tenants.Registry) ([]*DriftDetector, error) {
+	var detectors []*DriftDetector
+	for _, team := range teams.Teams() {
+		if team.Unrestricted() {
+			slog.Info("Team sees every space, not running a detector for it", "team", team.Name)
+			continue
+		}
+		detector, err := d.forTeam(team)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, detector)
+	}
+	if len(detectors) == 0 {
+		return nil, fmt.Errorf("no team has a space and namespace to watch")
+	}
+	return detectors, nil
+}
+
+// forTeam returns a copy of the detector for one team's space and namespace
+func (d *DriftDetector) forTeam(team *tenants.Team) (*DriftDetector, error) {
+	if len(team.Spaces) != 1 || len(team.Namespaces) != 1 ||
+		isPattern(team.Spaces[0]) || isPattern(team.Namespaces[0]) {
+		return nil, fmt.Errorf("team %s: the drift detector needs exactly one space and one namespace, without wildcards", team.Name)
+	}
+
+	app := *d.app
+	app.Cub = sdk.NewConfigHubClient(d.config.CubAPIURL, team.CubToken)
+	cfg := d.config
+	cfg.Space, cfg.Namespace = team.Spaces[0], team.Namespaces[0]
+
+	return &DriftDetector{
+		app:           &app,
+		team:          team,
+		config:        cfg,
+		notifier:      d.notifier,
+		claude:        d.claude,
+		llmClient:     d.llmClient,
+		flags:         d.flags,
+		prompts:       d.prompts,
+		cubLimit:      d.cubLimit,
+		audit:         d.audit,
+		policy:        d.policy,
+		pending:       d.pending,
+		metrics:       d.metrics,
+		stream:        d.stream,
+		bus:           d.bus,
+		exporter:      d.exporter,
+		cubBreaker:    d.cubBreaker,
+		claudeBreaker: d.claudeBreaker,
+	}, nil
+}
+
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// cubKey is the coalescing key of the detector's ListSpaces call; teams never
+// share results, as their tokens see different spaces
+func (d *DriftDetector) cubKey() string {
+	if d.team == nil {
+		return "all"
+	}
+	return "team:" + d.team.Name
+}
+
+// watches reports whether an informer event concerns the detector: a team's
+// detector only reacts to its namespace
+func (d *DriftDetector) watches(obj interface{}) bool {
+	if d.team == nil {
+		return true
+	}
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return false
+	}
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	return err == nil && namespace == d.config.Namespace
+}
+
+// handleTeamDrift serves GET /api/v1/drift in multi-tenant mode: the report of
+// the caller's team, or of the team named by ?team= if the caller may see
+// its space
+func handleTeamDrift(detectors []*DriftDetector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := tenants.FromContext(r.Context())
+		name := r.URL.Query().Get("team")
+		if name == "" && caller != nil {
+			name = caller.Name
+		}
+		for _, d := range detectors {
+			if d.team.Name != name {
+				continue
+			}
+			if !caller.OwnsSpace(d.config.Space) {
+				tenants.Forbidden(w)
+				return
+			}
+			d.handleDrift(w, r)
+			return
+		}
+		http.Error(w, fmt.Sprintf("no drift detection for team %q", name), http.StatusNotFound)
+	}
+}
@@ -0,0 +1,144 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// BuildApps compiles the devops-apps binary from repoRoot into dir
+func BuildApps(ctx context.Context, repoRoot, dir string) (string, error) {
+	binary := filepath.Join(dir, "devops-apps")
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", binary, ".")
+	cmd.Dir = filepath.Join(repoRoot, "devops-apps")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("build devops-apps: %w\n%s", err, out)
+	}
+	return binary, nil
+}
+
+// App is one app running as a devops-apps subcommand
+type App struct {
+	Name    string
+	BaseURL string // where its API or dashboard listens
+
+	cmd     *exec.Cmd
+	logs    *syncBuffer
+	exited  chan struct{} // closed once the process has exited
+	exitErr error
+}
+
+// StartApp runs `devops-apps <command>` with env on top of the test's
+// environment and waits for its health endpoint
+func StartApp(ctx context.Context, binary, command string, healthPort, apiPort int, env map[string]string) (*App, error) {
+	app := &App{
+		Name:    command,
+		BaseURL: fmt.Sprintf("http://localhost:%d", apiPort),
+		cmd:     exec.Command(binary, command),
+		logs:    &syncBuffer{},
+		exited:  make(chan struct{}),
+	}
+	app.cmd.Env = os.Environ()
+	for k, v := range env {
+		app.cmd.Env = append(app.cmd.Env, k+"="+v)
+	}
+	app.cmd.Stdout, app.cmd.Stderr = app.logs, app.logs
+
+	if err := app.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", command, err)
+	}
+	go func() {
+		app.exitErr = app.cmd.Wait()
+		close(app.exited)
+	}()
+
+	health := fmt.Sprintf("http://localhost:%d/health", healthPort)
+	err := wait.PollUntilContextTimeout(ctx, time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		select {
+		case <-app.exited:
+			return false, fmt.Errorf("%s exited: %v", command, app.exitErr)
+		default:
+		}
+		resp, err := http.Get(health)
+		if err != nil {
+			return false, nil
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK, nil
+	})
+	if err != nil {
+		app.Stop()
+		return nil, fmt.Errorf("%s not healthy: %w\n%s", command, err, app.Logs())
+	}
+	return app, nil
+}
+
+// GetJSON decodes GET path into v, failing on any status but 200
+func (a *App) GetJSON(ctx context.Context, path string, v interface{}) error {
+	return getJSON(ctx, a.BaseURL, path, v)
+}
+
+func getJSON(ctx context.Context, baseURL, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Logs returns everything the app has written so far
+func (a *App) Logs() string {
+	return a.logs.String()
+}
+
+// Stop interrupts the app so it shuts down cleanly, killing it after 10s
+func (a *App) Stop() {
+	select {
+	case <-a.exited:
+		return
+	default:
+	}
+	_ = a.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-a.exited:
+	case <-time.After(10 * time.Second):
+		_ = a.cmd.Process.Kill()
+		<-a.exited
+	}
+}
+
+// syncBuffer collects a process's output while tests read it
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
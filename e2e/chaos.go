@@ -0,0 +1,367 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Faults the chaos runs inject
+const (
+	FaultScale     = "scale"     // a Deployment's replicas changed, as kubectl scale would
+	FaultImage     = "image"     // a Deployment's first container runs another image
+	FaultConfigMap = "configmap" // a key added to a ConfigMap
+	FaultWaste     = "waste"     // a new idle Deployment requesting far more than it uses
+)
+
+// Faults lists every fault, in the order a chaos run cycles through them
+var Faults = []string{FaultScale, FaultImage, FaultConfigMap, FaultWaste}
+
+const (
+	chaosLabel = "devops-examples/chaos"
+	chaosKey   = "chaos-injected"
+	chaosImage = "busybox:1.35" // the workloads run busybox:1.36
+)
+
+// Injection is one fault injected into the cluster
+type Injection struct {
+	Fault    string    `json:"fault"`
+	Target   string    `json:"target"` // name of the Deployment or ConfigMap
+	At       time.Time `json:"at"`
+	Original string    `json:"original,omitempty"` // value the fault replaced, restored by remediation
+}
+
+// Chaos injects drift and waste into a namespace behind ConfigHub's back
+type Chaos struct {
+	Clientset kubernetes.Interface
+	Namespace string
+}
+
+// Inject applies fault to target; for FaultWaste target names the new
+// Deployment
+func (c *Chaos) Inject(ctx context.Context, fault, target string) (Injection, error) {
+	inj := Injection{Fault: fault, Target: target}
+	deployments := c.Clientset.AppsV1().Deployments(c.Namespace)
+	switch fault {
+	case FaultScale:
+		deployment, err := deployments.Get(ctx, target, metav1.GetOptions{})
+		if err != nil {
+			return inj, fmt.Errorf("get %s: %w", target, err)
+		}
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		inj.Original = strconv.Itoa(int(replicas))
+		inj.At = time.Now()
+		return inj, InjectDrift(ctx, c.Clientset, c.Namespace, target, replicas+3)
+
+	case FaultImage:
+		deployment, err := deployments.Get(ctx, target, metav1.GetOptions{})
+		if err != nil {
+			return inj, fmt.Errorf("get %s: %w", target, err)
+		}
+		containers := deployment.Spec.Template.Spec.Containers
+		if len(containers) == 0 {
+			return inj, fmt.Errorf("%s has no containers", target)
+		}
+		inj.Original = containers[0].Image
+		image := chaosImage
+		if inj.Original == chaosImage {
+			image = "busybox:1.36"
+		}
+		patch := fmt.Sprintf(`[{"op":"replace","path":"/spec/template/spec/containers/0/image","value":%q}]`, image)
+		inj.At = time.Now()
+		if _, err := deployments.Patch(ctx, target, types.JSONPatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+			return inj, fmt.Errorf("swap image of %s: %w", target, err)
+		}
+		return inj, nil
+
+	case FaultConfigMap:
+		inj.At = time.Now()
+		patch := fmt.Sprintf(`{"data":{%q:%q}}`, chaosKey, inj.At.UTC().Format(time.RFC3339))
+		_, err := c.Clientset.CoreV1().ConfigMaps(c.Namespace).Patch(ctx, target, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		if err != nil {
+			return inj, fmt.Errorf("edit ConfigMap %s: %w", target, err)
+		}
+		return inj, nil
+
+	case FaultWaste:
+		deployment := Workload{Name: target, Replicas: 1, CPU: "1", Memory: "1Gi"}.Deployment(c.Namespace)
+		deployment.Labels[chaosLabel] = "true"
+		inj.At = time.Now()
+		if _, err := deployments.Create(ctx, deployment, metav1.CreateOptions{}); err != nil {
+			return inj, fmt.Errorf("create %s: %w", target, err)
+		}
+		return inj, nil
+	}
+	return inj, fmt.Errorf("unknown fault %q (one of %v)", fault, Faults)
+}
+
+// Remediated reports whether the cluster is back to how it was before inj:
+// the replicas, image or ConfigMap restored, the wasteful Deployment removed
+// or scaled to zero
+func (c *Chaos) Remediated(ctx context.Context, inj Injection) (bool, error) {
+	if inj.Fault == FaultConfigMap {
+		cm, err := c.Clientset.CoreV1().ConfigMaps(c.Namespace).Get(ctx, inj.Target, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		_, edited := cm.Data[chaosKey]
+		return !edited, nil
+	}
+
+	deployment, err := c.Clientset.AppsV1().Deployments(c.Namespace).Get(ctx, inj.Target, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) && inj.Fault == FaultWaste {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	switch inj.Fault {
+	case FaultScale:
+		return strconv.Itoa(int(replicas)) == inj.Original, nil
+	case FaultImage:
+		containers := deployment.Spec.Template.Spec.Containers
+		return len(containers) > 0 && containers[0].Image == inj.Original, nil
+	case FaultWaste:
+		return replicas == 0, nil
+	}
+	return false, fmt.Errorf("unknown fault %q", inj.Fault)
+}
+
+// Cleanup deletes the Deployments FaultWaste created
+func (c *Chaos) Cleanup(ctx context.Context) error {
+	deployments := c.Clientset.AppsV1().Deployments(c.Namespace)
+	list, err := deployments.List(ctx, metav1.ListOptions{LabelSelector: chaosLabel + "=true"})
+	if err != nil {
+		return err
+	}
+	for _, d := range list.Items {
+		if err := deployments.Delete(ctx, d.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// Detector asks an app whether it has noticed an injection
+type Detector struct {
+	App    string
+	Faults []string // the faults the app is expected to notice
+	Check  func(ctx context.Context, inj Injection) (bool, error)
+}
+
+func (d Detector) covers(fault string) bool {
+	for _, f := range d.Faults {
+		if f == fault {
+			return true
+		}
+	}
+	return false
+}
+
+// DriftDetector notices scale and ConfigMap drift in the drift-detector's
+// /api/v1/drift report at baseURL. The detector compares replicas only, so
+// ConfigMap edits show up as missed until it compares ConfigMap data too.
+func DriftDetector(baseURL string) Detector {
+	return Detector{App: "drift-detector", Faults: []string{FaultScale, FaultConfigMap}, Check: func(ctx context.Context, inj Injection) (bool, error) {
+		var report struct {
+			Analysis struct {
+				Items []struct{ Resource string } `json:"items"`
+			} `json:"analysis"`
+		}
+		if err := getJSON(ctx, baseURL, "/api/v1/drift", &report); err != nil {
+			return false, err
+		}
+		resource := "Deployment/" + inj.Target
+		if inj.Fault == FaultConfigMap {
+			resource = "ConfigMap/" + inj.Target
+		}
+		for _, item := range report.Analysis.Items {
+			if item.Resource == resource {
+				return true, nil
+			}
+		}
+		return false, nil
+	}}
+}
+
+// SecurityDriftDetector notices image swaps in the security drift
+// detector's /api/v1/security report at baseURL
+func SecurityDriftDetector(baseURL string) Detector {
+	return Detector{App: "security-drift-detector", Faults: []string{FaultImage}, Check: func(ctx context.Context, inj Injection) (bool, error) {
+		var report struct {
+			Findings []struct{ Resource, Check string } `json:"findings"`
+		}
+		if err := getJSON(ctx, baseURL, "/api/v1/security", &report); err != nil {
+			return false, err
+		}
+		for _, f := range report.Findings {
+			if f.Resource == "Deployment/"+inj.Target && f.Check == "image" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}}
+}
+
+// CostOptimizer notices waste in the cost-optimizer's
+// /api/v1/recommendations at baseURL
+func CostOptimizer(baseURL string) Detector {
+	return Detector{App: "cost-optimizer", Faults: []string{FaultWaste}, Check: func(ctx context.Context, inj Injection) (bool, error) {
+		var recommendations []struct{ Resource string }
+		if err := getJSON(ctx, baseURL, "/api/v1/recommendations", &recommendations); err != nil {
+			return false, err
+		}
+		for _, r := range recommendations {
+			// Recommendations name the unit or "deployment/<name>"
+			if r.Resource == inj.Target || strings.EqualFold(r.Resource, "deployment/"+inj.Target) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}}
+}
+
+// Result is how long the apps took to notice and undo an injection; apps
+// missing from Detected did not notice it within the timeout
+type Result struct {
+	Injection  Injection                `json:"injection"`
+	Detected   map[string]time.Duration `json:"detected"`
+	Missed     []string                 `json:"missed,omitempty"`
+	Remediated time.Duration            `json:"remediated,omitempty"` // 0 when not undone within the timeout
+}
+
+// Measure polls the detectors covering inj's fault and the cluster every
+// interval until all have noticed it and it is undone, or timeout passes.
+// Errors from an app count as not noticed yet.
+func (c *Chaos) Measure(ctx context.Context, inj Injection, detectors []Detector, timeout, interval time.Duration) Result {
+	result := Result{Injection: inj, Detected: map[string]time.Duration{}}
+	var pending []Detector
+	for _, d := range detectors {
+		if d.covers(inj.Fault) {
+			pending = append(pending, d)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var still []Detector
+		for _, d := range pending {
+			if ok, err := d.Check(ctx, inj); err == nil && ok {
+				result.Detected[d.App] = time.Since(inj.At)
+			} else {
+				still = append(still, d)
+			}
+		}
+		pending = still
+		if result.Remediated == 0 {
+			if ok, err := c.Remediated(ctx, inj); err == nil && ok {
+				result.Remediated = time.Since(inj.At)
+			}
+		}
+		if len(pending) == 0 && result.Remediated > 0 {
+			return result
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			for _, d := range pending {
+				result.Missed = append(result.Missed, d.App)
+			}
+			return result
+		}
+	}
+}
+
+// Summary is the detection latency of one app for one fault over a run
+type Summary struct {
+	App      string        `json:"app"`
+	Fault    string        `json:"fault"`
+	Injected int           `json:"injected"`
+	Detected int           `json:"detected"`
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	Max      time.Duration `json:"max"`
+}
+
+// Summarize aggregates results per app and fault, sorted by app then fault.
+// Remediation is summarized as the app "remediation".
+func Summarize(results []Result) []Summary {
+	type key struct{ app, fault string }
+	latencies := map[key][]time.Duration{}
+	injected := map[key]int{}
+	for _, r := range results {
+		for app, d := range r.Detected {
+			k := key{app, r.Injection.Fault}
+			injected[k]++
+			latencies[k] = append(latencies[k], d)
+		}
+		for _, app := range r.Missed {
+			injected[key{app, r.Injection.Fault}]++
+		}
+		k := key{"remediation", r.Injection.Fault}
+		injected[k]++
+		if r.Remediated > 0 {
+			latencies[k] = append(latencies[k], r.Remediated)
+		}
+	}
+
+	summaries := make([]Summary, 0, len(injected))
+	for k, n := range injected {
+		s := Summary{App: k.app, Fault: k.fault, Injected: n}
+		if l := latencies[k]; len(l) > 0 {
+			sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+			s.Detected = len(l)
+			s.P50 = percentile(l, 50)
+			s.P95 = percentile(l, 95)
+			s.Max = l[len(l)-1]
+		}
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].App != summaries[j].App {
+			return summaries[i].App < summaries[j].App
+		}
+		return summaries[i].Fault < summaries[j].Fault
+	})
+	return summaries
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// EnsureConfigMap creates an empty ConfigMap name for FaultConfigMap to
+// edit, when there is none
+func (c *Chaos) EnsureConfigMap(ctx context.Context, name string) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.Namespace, Labels: map[string]string{chaosLabel: "true"}}}
+	_, err := c.Clientset.CoreV1().ConfigMaps(c.Namespace).Create(ctx, cm, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestChaosInjectAndRemediate(t *testing.T) {
+	ctx := context.Background()
+	chaos := &Chaos{Clientset: fake.NewSimpleClientset(Workloads[0].Deployment("e2e")), Namespace: "e2e"}
+	if err := chaos.EnsureConfigMap(ctx, "app-config"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		fault, target, original string
+		undo                    func() error
+	}{
+		{FaultScale, "backend-api", "2", func() error { return InjectDrift(ctx, chaos.Clientset, "e2e", "backend-api", 2) }},
+		{FaultImage, "backend-api", "busybox:1.36", func() error {
+			patch := `[{"op":"replace","path":"/spec/template/spec/containers/0/image","value":"busybox:1.36"}]`
+			_, err := chaos.Clientset.AppsV1().Deployments("e2e").Patch(ctx, "backend-api", types.JSONPatchType, []byte(patch), metav1.PatchOptions{})
+			return err
+		}},
+		{FaultConfigMap, "app-config", "", func() error {
+			_, err := chaos.Clientset.CoreV1().ConfigMaps("e2e").Update(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "e2e"}}, metav1.UpdateOptions{})
+			return err
+		}},
+		{FaultWaste, "chaos-waste-1", "", func() error { return chaos.Cleanup(ctx) }},
+	} {
+		inj, err := chaos.Inject(ctx, tc.fault, tc.target)
+		if err != nil {
+			t.Fatalf("Inject(%s) = %v", tc.fault, err)
+		}
+		if inj.Original != tc.original || inj.At.IsZero() {
+			t.Errorf("Inject(%s) = %+v, want original %q", tc.fault, inj, tc.original)
+		}
+		if ok, err := chaos.Remediated(ctx, inj); ok || err != nil {
+			t.Errorf("Remediated(%s) before undo = %v, %v", tc.fault, ok, err)
+		}
+		if err := tc.undo(); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := chaos.Remediated(ctx, inj); !ok || err != nil {
+			t.Errorf("Remediated(%s) after undo = %v, %v", tc.fault, ok, err)
+		}
+	}
+
+	if _, err := chaos.Inject(ctx, "reboot", "backend-api"); err == nil {
+		t.Error("Expected an error for an unknown fault")
+	}
+}
+
+func TestChaosMeasure(t *testing.T) {
+	ctx := context.Background()
+	chaos := &Chaos{Clientset: fake.NewSimpleClientset(Workloads[0].Deployment("e2e")), Namespace: "e2e"}
+
+	polls := 0
+	drift := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		report := map[string]interface{}{"analysis": map[string]interface{}{"items": []interface{}{}}}
+		if polls >= 2 {
+			report["analysis"] = map[string]interface{}{"items": []map[string]string{{"resource": "Deployment/backend-api"}}}
+			// The detector fixes what it found
+			InjectDrift(ctx, chaos.Clientset, "e2e", "backend-api", 2)
+		}
+		json.NewEncoder(w).Encode(report)
+	}))
+	defer drift.Close()
+	security := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer security.Close()
+	detectors := []Detector{DriftDetector(drift.URL), SecurityDriftDetector(security.URL)}
+
+	inj, err := chaos.Inject(ctx, FaultScale, "backend-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := chaos.Measure(ctx, inj, detectors, 5*time.Second, 10*time.Millisecond)
+	if _, ok := result.Detected["drift-detector"]; !ok || result.Remediated == 0 || len(result.Missed) != 0 {
+		t.Errorf("Measure(scale) = %+v", result)
+	}
+
+	inj, err = chaos.Inject(ctx, FaultImage, "backend-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = chaos.Measure(ctx, inj, detectors, 50*time.Millisecond, 10*time.Millisecond)
+	if len(result.Detected) != 0 || len(result.Missed) != 1 || result.Missed[0] != "security-drift-detector" || result.Remediated != 0 {
+		t.Errorf("Measure(image) = %+v", result)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	results := []Result{
+		{Injection: Injection{Fault: FaultScale}, Detected: map[string]time.Duration{"drift-detector": 3 * time.Second}, Remediated: 5 * time.Second},
+		{Injection: Injection{Fault: FaultScale}, Detected: map[string]time.Duration{"drift-detector": time.Second}, Remediated: 2 * time.Second},
+		{Injection: Injection{Fault: FaultScale}, Missed: []string{"drift-detector"}},
+	}
+	want := []Summary{
+		{App: "drift-detector", Fault: FaultScale, Injected: 3, Detected: 2, P50: time.Second, P95: 3 * time.Second, Max: 3 * time.Second},
+		{App: "remediation", Fault: FaultScale, Injected: 3, Detected: 2, P50: 2 * time.Second, P95: 5 * time.Second, Max: 5 * time.Second},
+	}
+	got := Summarize(results)
+	if len(got) != len(want) {
+		t.Fatalf("Summarize = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Summarize[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package e2e is the end-to-end harness for the DevOps apps. It creates a
+// kind cluster, deploys sample workloads through ConfigHub, injects drift and
+// waste, runs each app from the devops-apps binary and checks its API.
+//
+// The tests carry the e2e build tag and need Docker, the cub CLI logged in,
+// kubectl and CUB_TOKEN:
+//
+//	go test -tags e2e -v -timeout 30m ./...
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/kind/pkg/cluster"
+)
+
+// Cluster is a kind cluster used by a test run
+type Cluster struct {
+	Name       string
+	Kubeconfig string // path of a kubeconfig for this cluster only
+	Clientset  kubernetes.Interface
+
+	provider *cluster.Provider
+	created  bool
+}
+
+// StartCluster creates the kind cluster name, or reuses it if it exists, and
+// writes its kubeconfig into dir
+func StartCluster(name, dir string) (*Cluster, error) {
+	c := &Cluster{
+		Name:       name,
+		Kubeconfig: filepath.Join(dir, "kubeconfig"),
+		provider:   cluster.NewProvider(),
+	}
+
+	existing, err := c.provider.List()
+	if err != nil {
+		return nil, fmt.Errorf("list kind clusters: %w", err)
+	}
+	if !contains(existing, name) {
+		if err := c.provider.Create(name, cluster.CreateWithWaitForReady(3*time.Minute)); err != nil {
+			return nil, fmt.Errorf("create kind cluster %s: %w", name, err)
+		}
+		c.created = true
+	}
+
+	kubeconfig, err := c.provider.KubeConfig(name, false)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig for %s: %w", name, err)
+	}
+	if err := os.WriteFile(c.Kubeconfig, []byte(kubeconfig), 0o600); err != nil {
+		return nil, err
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return nil, fmt.Errorf("parse kubeconfig: %w", err)
+	}
+	if c.Clientset, err = kubernetes.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Context is the kubeconfig context kind gives the cluster
+func (c *Cluster) Context() string {
+	return "kind-" + c.Name
+}
+
+// Stop deletes the cluster if this run created it. keep leaves it for
+// debugging or the next run.
+func (c *Cluster) Stop(keep bool) error {
+	if !c.created || keep {
+		return nil
+	}
+	return c.provider.Delete(c.Name, c.Kubeconfig)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
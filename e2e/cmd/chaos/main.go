@@ -0,0 +1,151 @@
+// Command chaos injects drift and waste into a cluster on a schedule and
+// reports how long each DevOps app took to notice it and how long until it
+// was undone. Point it at a namespace the apps watch and at their APIs:
+//
+//	go run ./cmd/chaos -namespace e2e -rounds 10 -every 1m \
+//	  -drift-url http://localhost:8080 -security-url http://localhost:8082 \
+//	  -cost-url http://localhost:8081 -slo 2m
+//
+// With -slo it exits non-zero when any app's p95 detection latency is over
+// the SLO or it missed an injection.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/monadic/devops-examples/e2e"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func main() {
+	var (
+		kubeContext = flag.String("context", os.Getenv("K8S_CONTEXT"), "kubeconfig context (default: current)")
+		namespace   = flag.String("namespace", "e2e", "namespace of the workloads to disturb")
+		faults      = flag.String("faults", strings.Join(e2e.Faults, ","), "comma-separated faults to cycle through")
+		targets     = flag.String("targets", "backend-api,frontend-web", "comma-separated Deployments to scale and swap images of")
+		configMap   = flag.String("configmap", "chaos-config", "ConfigMap to edit, created when missing")
+		rounds      = flag.Int("rounds", 4, "number of injections")
+		every       = flag.Duration("every", time.Minute, "time between injections")
+		timeout     = flag.Duration("timeout", 5*time.Minute, "how long to wait for each injection to be noticed and undone")
+		interval    = flag.Duration("interval", 2*time.Second, "how often to poll the apps and the cluster")
+		driftURL    = flag.String("drift-url", "", "drift-detector API, e.g. http://localhost:8080")
+		securityURL = flag.String("security-url", "", "security-drift-detector API")
+		costURL     = flag.String("cost-url", "", "cost-optimizer API")
+		slo         = flag.Duration("slo", 0, "fail when an app's p95 detection latency exceeds this (0: report only)")
+		asJSON      = flag.Bool("json", false, "print the results and summary as JSON")
+	)
+	flag.Parse()
+
+	var detectors []e2e.Detector
+	if *driftURL != "" {
+		detectors = append(detectors, e2e.DriftDetector(*driftURL))
+	}
+	if *securityURL != "" {
+		detectors = append(detectors, e2e.SecurityDriftDetector(*securityURL))
+	}
+	if *costURL != "" {
+		detectors = append(detectors, e2e.CostOptimizer(*costURL))
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: *kubeContext}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		log.Fatalf("load kubeconfig: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("create Kubernetes client: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	chaos := &e2e.Chaos{Clientset: clientset, Namespace: *namespace}
+	defer chaos.Cleanup(context.Background())
+
+	faultList := strings.Split(*faults, ",")
+	targetList := strings.Split(*targets, ",")
+	if strings.Contains(*faults, e2e.FaultConfigMap) {
+		if err := chaos.EnsureConfigMap(ctx, *configMap); err != nil {
+			log.Fatalf("create ConfigMap %s: %v", *configMap, err)
+		}
+	}
+
+	var results []e2e.Result
+	for i := 0; i < *rounds && ctx.Err() == nil; i++ {
+		fault := strings.TrimSpace(faultList[i%len(faultList)])
+		target := strings.TrimSpace(targetList[i%len(targetList)])
+		switch fault {
+		case e2e.FaultConfigMap:
+			target = *configMap
+		case e2e.FaultWaste:
+			target = fmt.Sprintf("chaos-waste-%d", i+1)
+		}
+
+		started := time.Now()
+		inj, err := chaos.Inject(ctx, fault, target)
+		if err != nil {
+			log.Fatalf("inject %s into %s: %v", fault, target, err)
+		}
+		log.Printf("Injected %s into %s", fault, target)
+		result := chaos.Measure(ctx, inj, detectors, *timeout, *interval)
+		for app, latency := range result.Detected {
+			log.Printf("  %s noticed it after %s", app, latency.Round(time.Millisecond))
+		}
+		for _, app := range result.Missed {
+			log.Printf("  %s missed it", app)
+		}
+		if result.Remediated > 0 {
+			log.Printf("  undone after %s", result.Remediated.Round(time.Millisecond))
+		} else {
+			log.Printf("  not undone within %s", *timeout)
+		}
+		results = append(results, result)
+
+		if i+1 < *rounds {
+			select {
+			case <-time.After(*every - time.Since(started)):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	summaries := e2e.Summarize(results)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]interface{}{"results": results, "summary": summaries})
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "APP\tFAULT\tDETECTED\tP50\tP95\tMAX")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%s\t%s\n", s.App, s.Fault, s.Detected, s.Injected,
+				s.P50.Round(time.Millisecond), s.P95.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+		}
+		w.Flush()
+	}
+
+	if *slo > 0 {
+		failed := false
+		for _, s := range summaries {
+			if s.App != "remediation" && (s.Detected < s.Injected || s.P95 > *slo) {
+				log.Printf("%s misses the %s SLO for %s: %d/%d detected, p95 %s", s.App, *slo, s.Fault, s.Detected, s.Injected, s.P95)
+				failed = true
+			}
+		}
+		if failed {
+			chaos.Cleanup(context.Background())
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Command loadgen creates a realistic amount of work for the DevOps apps -
+// namespaces of Deployments, their ConfigHub units, and churn (scale events
+// and unit edits) - and reports what the apps spent handling it: cycles, mean
+// cycle time, ConfigHub requests and AI tokens, read from their /metrics.
+// Run it against a test cluster before rolling the apps out:
+//
+//	go run ./cmd/loadgen -namespaces 20 -deployments 25 -confighub \
+//	  -churn-every 10s -duration 30m \
+//	  -metrics-urls http://localhost:9090/metrics,http://localhost:9091/metrics
+//
+// With -confighub it creates a fresh space and prints its slug; point the
+// apps' CUB_SPACE at it. -cleanup deletes the namespaces, and the space,
+// when done.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/monadic/devops-examples/e2e"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func main() {
+	profile := e2e.DefaultLoadProfile
+	var (
+		kubeContext = flag.String("context", os.Getenv("K8S_CONTEXT"), "kubeconfig context (default: current)")
+		prefix      = flag.String("prefix", "load", "prefix of the namespaces and unit slugs")
+		namespaces  = flag.Int("namespaces", profile.Namespaces, "namespaces to create")
+		deployments = flag.Int("deployments", profile.Deployments, "Deployments per namespace")
+		replicas    = flag.Int("replicas", int(profile.Replicas), "replicas per Deployment")
+		cpu         = flag.String("cpu", profile.CPU, "CPU request per pod")
+		memory      = flag.String("memory", profile.Memory, "memory request per pod")
+		oversized   = flag.Float64("oversized", profile.Oversized, "share of Deployments requesting ten times as much")
+		critical    = flag.Float64("critical", profile.Critical, "share of units labelled tier=critical")
+		withCub     = flag.Bool("confighub", false, "create a ConfigHub space with a unit per Deployment (needs a logged-in cub)")
+		parallel    = flag.Int("parallel", 8, "concurrent creations")
+		seed        = flag.Int64("seed", 1, "seed of the shares and churn, for repeatable runs")
+		churnEvery  = flag.Duration("churn-every", 10*time.Second, "time between churn events")
+		events      = flag.Int("churn-events", 0, "stop after this many churn events (0: run for -duration)")
+		duration    = flag.Duration("duration", 10*time.Minute, "how long to churn")
+		metricsURLs = flag.String("metrics-urls", "", "comma-separated /metrics URLs of the apps to measure")
+		cleanup     = flag.Bool("cleanup", false, "delete the namespaces and space when done")
+		asJSON      = flag.Bool("json", false, "print the stats and usage as JSON")
+	)
+	flag.Parse()
+	profile.Namespaces, profile.Deployments, profile.Replicas = *namespaces, *deployments, int32(*replicas)
+	profile.CPU, profile.Memory, profile.Oversized, profile.Critical = *cpu, *memory, *oversized, *critical
+	if err := profile.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: *kubeContext}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		log.Fatalf("load kubeconfig: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("create Kubernetes client: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	gen := &e2e.LoadGen{Clientset: clientset, Prefix: *prefix, Profile: profile, Parallel: *parallel, Seed: *seed}
+	var cub *e2e.ConfigHub
+	if *withCub {
+		if cub, err = e2e.NewSpace(ctx, rules.GetDefaultFilename()); err != nil {
+			log.Fatalf("create ConfigHub space: %v", err)
+		}
+		log.Printf("Created space %s; set CUB_SPACE=%s for the apps", cub.Space, cub.Space)
+		gen.Units = cub
+	}
+	if *cleanup {
+		defer func() {
+			if err := gen.Cleanup(context.Background()); err != nil {
+				log.Printf("Cleanup failed: %v", err)
+			}
+			if cub != nil {
+				if err := cub.Delete(context.Background()); err != nil {
+					log.Printf("Delete space %s failed: %v", cub.Space, err)
+				}
+			}
+		}()
+	}
+
+	var urls []string
+	for _, u := range strings.Split(*metricsURLs, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	scrape := func() []e2e.Sample {
+		var samples []e2e.Sample
+		for _, u := range urls {
+			s, err := e2e.ScrapeMetrics(context.Background(), u)
+			if err != nil {
+				log.Printf("Scrape %s failed: %v", u, err)
+				continue
+			}
+			samples = append(samples, s...)
+		}
+		return samples
+	}
+
+	before := scrape()
+	if err := gen.Setup(ctx); err != nil {
+		log.Fatalf("set up load: %v", err)
+	}
+	stats := gen.Stats()
+	log.Printf("Created %d namespaces, %d Deployments and %d units in %s",
+		stats.Namespaces, stats.Deployments, stats.Units, stats.SetupTime.Round(time.Millisecond))
+
+	churnCtx, cancel := context.WithTimeout(ctx, *duration)
+	started := time.Now()
+	gen.Churn(churnCtx, *churnEvery, *events)
+	cancel()
+	elapsed := time.Since(started)
+	usage := e2e.Usage(before, scrape())
+
+	stats = gen.Stats()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]interface{}{"stats": stats, "churn_time": elapsed, "usage": usage})
+		return
+	}
+	log.Printf("Churned for %s: %d scale events, %d unit edits, %d errors",
+		elapsed.Round(time.Second), stats.Churn[e2e.ChurnScale], stats.Churn[e2e.ChurnEdit], stats.Errors)
+	if len(urls) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "APP\tCYCLES\tFAILED\tMEAN CYCLE\tCONFIGHUB REQUESTS\tCLAUDE CALLS\tTOKENS IN/OUT\tAI COST")
+		for _, u := range usage {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\t%d\t%d/%d\t$%.2f\n", u.App, u.Cycles, u.FailedCycles,
+				u.MeanCycle.Round(time.Millisecond), u.ConfigHubRequests, u.ClaudeCalls, u.InputTokens, u.OutputTokens, u.Cost)
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,203 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigHub drives the cub CLI, the same way the apps' bin/ scripts set up
+// spaces, workers and units. The CLI must be logged in.
+type ConfigHub struct {
+	Space   string // slug
+	SpaceID string
+
+	kubeconfig string // for applying the worker manifest
+}
+
+// NewSpace creates a space with a fresh prefix, labelled so leftovers from
+// aborted runs are easy to find
+func NewSpace(ctx context.Context, kubeconfig string) (*ConfigHub, error) {
+	c := &ConfigHub{kubeconfig: kubeconfig}
+
+	prefix, err := c.cub(ctx, nil, "space", "new-prefix")
+	if err != nil {
+		return nil, err
+	}
+	c.Space = strings.TrimSpace(string(prefix)) + "-e2e"
+	if _, err := c.cub(ctx, nil, "space", "create", c.Space, "--label", "e2e=true"); err != nil {
+		return nil, err
+	}
+
+	out, err := c.cub(ctx, nil, "space", "get", c.Space, "--json")
+	if err != nil {
+		return nil, err
+	}
+	if c.SpaceID, err = jsonField(out, "space_id"); err != nil {
+		return nil, fmt.Errorf("space %s: %w", c.Space, err)
+	}
+	return c, nil
+}
+
+// InstallWorker creates a worker in the space and runs it in the cluster,
+// so units applied in the space reach the cluster
+func (c *ConfigHub) InstallWorker(ctx context.Context, name string) error {
+	if _, err := c.cub(ctx, nil, "worker", "create", name, "--space", c.Space); err != nil {
+		return err
+	}
+	manifest, err := c.cub(ctx, nil, "worker", "install", name,
+		"--namespace", "confighub", "--space", c.Space, "--include-secret", "--export")
+	if err != nil {
+		return err
+	}
+
+	if err := c.kubectl(ctx, nil, "create", "namespace", "confighub"); err != nil && !strings.Contains(err.Error(), "AlreadyExists") {
+		return err
+	}
+	return c.kubectl(ctx, manifest, "apply", "-f", "-")
+}
+
+// CreateUnits stores each workload as a unit targeting the worker's cluster
+// and applies it. The manifests are written to dir for cub to read.
+func (c *ConfigHub) CreateUnits(ctx context.Context, worker, namespace, dir string) error {
+	for _, w := range Workloads {
+		manifest, err := w.Manifest(namespace)
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(dir, w.Name+".yaml")
+		if err := os.WriteFile(file, manifest, 0o644); err != nil {
+			return err
+		}
+		args := []string{"unit", "create", "--space", c.Space, w.Name, file, "--label", "app=" + w.Name}
+		if w.Critical {
+			args = append(args, "--label", "tier=critical", "--label", "monitor=true")
+		}
+		if _, err := c.cub(ctx, nil, args...); err != nil {
+			return err
+		}
+	}
+
+	where := fmt.Sprintf("Space.Slug = '%s'", c.Space)
+	if _, err := c.cub(ctx, nil, "unit", "set-target", "k8s-"+worker, "--where", where, "--space", c.Space); err != nil {
+		return err
+	}
+	for _, w := range Workloads {
+		if _, err := c.cub(ctx, nil, "unit", "apply", w.Name, "--space", c.Space); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddCriticalUnitsToSet puts the critical workloads' units into the set,
+// which the drift detector creates on its first run. The cub CLI has no set
+// commands, so it goes through the set command of the devops-apps binary.
+func (c *ConfigHub) AddCriticalUnitsToSet(ctx context.Context, binary, set string) error {
+	args := []string{"set", "add-unit", "-space", c.Space, set}
+	for _, w := range Workloads {
+		if w.Critical {
+			args = append(args, w.Name)
+		}
+	}
+	cmd := exec.CommandContext(ctx, binary, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("devops-apps %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// CreateUnit stores a manifest as an unapplied unit with the given labels.
+// It makes *ConfigHub a UnitStore for load runs.
+func (c *ConfigHub) CreateUnit(ctx context.Context, slug string, manifest []byte, labels map[string]string) error {
+	return c.withFile(manifest, func(file string) error {
+		args := []string{"unit", "create", "--space", c.Space, slug, file}
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			args = append(args, "--label", k+"="+labels[k])
+		}
+		_, err := c.cub(ctx, nil, args...)
+		return err
+	})
+}
+
+// UpdateUnit replaces a unit's data with manifest
+func (c *ConfigHub) UpdateUnit(ctx context.Context, slug string, manifest []byte) error {
+	return c.withFile(manifest, func(file string) error {
+		_, err := c.cub(ctx, nil, "unit", "update", "--space", c.Space, slug, file)
+		return err
+	})
+}
+
+// withFile writes data to a temporary file for cub to read
+func (c *ConfigHub) withFile(data []byte, f func(file string) error) error {
+	tmp, err := os.CreateTemp("", "unit-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return f(tmp.Name())
+}
+
+// Delete removes the space
+func (c *ConfigHub) Delete(ctx context.Context) error {
+	_, err := c.cub(ctx, nil, "space", "delete", c.Space)
+	return err
+}
+
+// cub runs the CLI, returning its output or an error that includes it
+func (c *ConfigHub) cub(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "cub", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cub %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func (c *ConfigHub) kubectl(ctx context.Context, stdin []byte, args ...string) error {
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+c.kubeconfig)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("kubectl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// jsonField reads a top-level string field of cub's --json output
+func jsonField(data []byte, field string) (string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", fmt.Errorf("parse cub output: %w", err)
+	}
+	value, _ := obj[field].(string)
+	if value == "" {
+		return "", fmt.Errorf("no %s in cub output", field)
+	}
+	return value, nil
+}
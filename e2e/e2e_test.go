@@ -0,0 +1,261 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const namespace = "e2e-workloads"
+
+// Shared by the tests; set up once in TestMain
+var (
+	kind   *Cluster
+	hub    *ConfigHub
+	binary string
+)
+
+// TestMain creates the cluster and ConfigHub space, deploys the workloads
+// through a worker and builds devops-apps. E2E_CLUSTER names the kind
+// cluster (default devops-e2e); E2E_KEEP=true keeps the cluster and space.
+func TestMain(m *testing.M) {
+	if os.Getenv("CUB_TOKEN") == "" {
+		fmt.Println("Skipping e2e tests - CUB_TOKEN not set")
+		os.Exit(0)
+	}
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+	keep := os.Getenv("E2E_KEEP") == "true"
+
+	dir, err := os.MkdirTemp("", "devops-e2e")
+	if err != nil {
+		return fail(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := os.Getenv("E2E_CLUSTER")
+	if name == "" {
+		name = "devops-e2e"
+	}
+	if kind, err = StartCluster(name, dir); err != nil {
+		return fail(err)
+	}
+	defer func() {
+		if err := kind.Stop(keep); err != nil {
+			fmt.Println("delete cluster:", err)
+		}
+	}()
+
+	if hub, err = NewSpace(ctx, kind.Kubeconfig); err != nil {
+		return fail(err)
+	}
+	defer func() {
+		if keep {
+			fmt.Println("Keeping space", hub.Space)
+			return
+		}
+		if err := hub.Delete(context.Background()); err != nil {
+			fmt.Println("delete space:", err)
+		}
+	}()
+
+	worker := hub.Space + "-worker"
+	if err := hub.InstallWorker(ctx, worker); err != nil {
+		return fail(err)
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if _, err := kind.Clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+		return fail(err)
+	}
+	if err := hub.CreateUnits(ctx, worker, namespace, dir); err != nil {
+		return fail(err)
+	}
+	if err := WaitForWorkloads(ctx, kind.Clientset, namespace, 5*time.Minute); err != nil {
+		return fail(fmt.Errorf("workloads not available: %w", err))
+	}
+
+	if binary, err = BuildApps(ctx, "..", dir); err != nil {
+		return fail(err)
+	}
+	return m.Run()
+}
+
+func fail(err error) int {
+	fmt.Println("e2e setup:", err)
+	return 1
+}
+
+// appEnv is the environment every app runs with
+func appEnv(extra map[string]string) map[string]string {
+	env := map[string]string{
+		"KUBECONFIG":  kind.Kubeconfig,
+		"K8S_CONTEXT": kind.Context(),
+		"LOG_FORMAT":  "json",
+		"LOG_LEVEL":   "debug",
+	}
+	for k, v := range extra {
+		env[k] = v
+	}
+	return env
+}
+
+func startApp(t *testing.T, command string, healthPort, apiPort int, env map[string]string) *App {
+	t.Helper()
+	app, err := StartApp(context.Background(), binary, command, healthPort, apiPort, appEnv(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.Stop()
+		if t.Failed() {
+			t.Logf("%s logs:\n%s", command, app.Logs())
+		}
+	})
+	return app
+}
+
+// eventually polls check until it returns nil or timeout passes
+func eventually(t *testing.T, timeout time.Duration, check func(ctx context.Context) error) {
+	t.Helper()
+	var last error
+	err := wait.PollUntilContextTimeout(context.Background(), 5*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		last = check(ctx)
+		return last == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("timed out after %s: %v", timeout, last)
+	}
+}
+
+func TestDriftDetector(t *testing.T) {
+	app := startApp(t, "drift", 8080, 8084, map[string]string{
+		"CUB_SPACE":    hub.Space,
+		"NAMESPACE":    namespace,
+		"RUN_INTERVAL": "15s",
+		"AUTO_FIX":     "false",
+	})
+
+	type report struct {
+		Space    string `json:"space"`
+		Analysis struct {
+			Items []struct {
+				Resource, Field, Expected, Actual string
+			} `json:"items"`
+		} `json:"analysis"`
+	}
+
+	// The first run creates the critical-services set the units join
+	eventually(t, 2*time.Minute, func(ctx context.Context) error {
+		var r report
+		return app.GetJSON(ctx, "/api/v1/drift", &r)
+	})
+	if err := hub.AddCriticalUnitsToSet(context.Background(), binary, "critical-services"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InjectDrift(context.Background(), kind.Clientset, namespace, "backend-api", 5); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, 3*time.Minute, func(ctx context.Context) error {
+		var r report
+		if err := app.GetJSON(ctx, "/api/v1/drift", &r); err != nil {
+			return err
+		}
+		if r.Space != hub.Space {
+			return fmt.Errorf("report for space %q", r.Space)
+		}
+		for _, item := range r.Analysis.Items {
+			if item.Resource == "Deployment/backend-api" && item.Field == "spec.replicas" {
+				if item.Expected != "2" || item.Actual != "5" {
+					return fmt.Errorf("replicas drift %s -> %s, want 2 -> 5", item.Expected, item.Actual)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("backend-api drift not reported in %+v", r.Analysis.Items)
+	})
+}
+
+func TestCostOptimizer(t *testing.T) {
+	app := startApp(t, "cost", 8080, 8081, map[string]string{
+		"CONFIGHUB_SPACE_ID":       hub.SpaceID,
+		"RUN_INTERVAL":             "30s",
+		"ENABLE_OPENCOST":          "false",
+		"AUTO_APPLY_OPTIMIZATIONS": "false",
+	})
+
+	eventually(t, 3*time.Minute, func(ctx context.Context) error {
+		var analysis struct {
+			Status           string  `json:"status"`
+			TotalMonthlyCost float64 `json:"total_monthly_cost"`
+			ResourceDetails  []struct {
+				Name         string `json:"name"`
+				Namespace    string `json:"namespace"`
+				CPURequested int64  `json:"cpu_requested_millicores"`
+			} `json:"resource_details"`
+		}
+		if err := app.GetJSON(ctx, "/api/v1/analysis", &analysis); err != nil {
+			return err
+		}
+		if analysis.Status == "waiting" {
+			return fmt.Errorf("no analysis yet")
+		}
+		if analysis.TotalMonthlyCost <= 0 {
+			return fmt.Errorf("total monthly cost %.2f", analysis.TotalMonthlyCost)
+		}
+
+		// The oversized workload's requests must be seen, as they're what it wastes
+		for _, r := range analysis.ResourceDetails {
+			if r.Namespace == namespace && r.Name == "oversized-batch" {
+				if r.CPURequested < 500 {
+					return fmt.Errorf("oversized-batch requests %dm CPU, want at least 500m", r.CPURequested)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("oversized-batch missing from %d resource details", len(analysis.ResourceDetails))
+	})
+}
+
+func TestCostImpactMonitor(t *testing.T) {
+	stateDir := t.TempDir()
+	app := startApp(t, "impact", 8082, 8083, map[string]string{
+		"RUN_INTERVAL":  "15s",
+		"STATE_FILE":    filepath.Join(stateDir, "state.json"),
+		"POD_NAMESPACE": namespace,
+	})
+
+	eventually(t, 3*time.Minute, func(ctx context.Context) error {
+		var resp struct {
+			Spaces []struct {
+				SpaceName     string  `json:"space_name"`
+				ProjectedCost float64 `json:"projected_cost"`
+			} `json:"spaces"`
+		}
+		if err := app.GetJSON(ctx, "/api/v1/spaces", &resp); err != nil {
+			return err
+		}
+		for _, s := range resp.Spaces {
+			if s.SpaceName == hub.Space {
+				if s.ProjectedCost <= 0 {
+					return fmt.Errorf("space %s projected cost %.2f", s.SpaceName, s.ProjectedCost)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("space %s not monitored (%d spaces)", hub.Space, len(resp.Spaces))
+	})
+}
@@ -0,0 +1,320 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Churn a load run generates
+const (
+	ChurnScale = "scale" // a Deployment scaled in the cluster, which the detectors see as drift
+	ChurnEdit  = "edit"  // a unit's CPU request edited in ConfigHub, which the cost apps price
+)
+
+// loadLabel marks the namespaces and units of a load run; its value is the
+// run's prefix
+const loadLabel = "devops-examples/loadgen"
+
+// LoadProfile shapes the workloads a load run creates
+type LoadProfile struct {
+	Namespaces  int    // created as <prefix>-1 to <prefix>-N
+	Deployments int    // per namespace
+	Replicas    int32  // per Deployment
+	CPU         string // request per pod
+	Memory      string
+	Oversized   float64 // share of Deployments requesting ten times as much, the waste the cost apps should find
+	Critical    float64 // share labelled for the drift detector's critical-services set
+}
+
+// DefaultLoadProfile is a modest cluster: 10 namespaces of 20 Deployments
+var DefaultLoadProfile = LoadProfile{
+	Namespaces: 10, Deployments: 20, Replicas: 1, CPU: "10m", Memory: "16Mi", Oversized: 0.1, Critical: 0.2,
+}
+
+// Validate checks the profile before anything is created
+func (p LoadProfile) Validate() error {
+	if p.Namespaces < 1 || p.Deployments < 1 {
+		return fmt.Errorf("need at least one namespace and Deployment, got %d and %d", p.Namespaces, p.Deployments)
+	}
+	if p.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got %d", p.Replicas)
+	}
+	for _, q := range []string{p.CPU, p.Memory} {
+		if _, err := resource.ParseQuantity(q); err != nil {
+			return fmt.Errorf("request %q: %w", q, err)
+		}
+	}
+	if p.Oversized < 0 || p.Oversized > 1 || p.Critical < 0 || p.Critical > 1 {
+		return fmt.Errorf("oversized and critical are shares between 0 and 1")
+	}
+	return nil
+}
+
+// UnitStore keeps the units of a load run's Deployments; *ConfigHub is one
+type UnitStore interface {
+	CreateUnit(ctx context.Context, slug string, manifest []byte, labels map[string]string) error
+	UpdateUnit(ctx context.Context, slug string, manifest []byte) error
+}
+
+// LoadStats count what a load run did
+type LoadStats struct {
+	Namespaces  int            `json:"namespaces"`
+	Deployments int            `json:"deployments"`
+	Units       int            `json:"units"`
+	Churn       map[string]int `json:"churn"` // events by kind
+	Errors      int            `json:"errors"`
+	SetupTime   time.Duration  `json:"setup_time"`
+}
+
+// LoadGen creates a load run's namespaces, Deployments and units, then
+// churns them, so the apps' cycle times and API calls can be measured at
+// scale
+type LoadGen struct {
+	Clientset kubernetes.Interface
+	Units     UnitStore // nil creates no units and churns only the cluster
+	Prefix    string    // of the namespaces and unit slugs
+	Profile   LoadProfile
+	Parallel  int   // concurrent creations; 1 when unset
+	Seed      int64 // makes the shares and churn repeatable
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	workloads []loadWorkload
+	stats     LoadStats
+}
+
+// loadWorkload is one generated Deployment
+type loadWorkload struct {
+	Workload
+	namespace string
+}
+
+// slug names the workload's unit, unique across namespaces
+func (w loadWorkload) slug() string {
+	return w.namespace + "-" + w.Name
+}
+
+// Stats returns what the run did so far
+func (g *LoadGen) Stats() LoadStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := g.stats
+	stats.Churn = make(map[string]int, len(g.stats.Churn))
+	for kind, n := range g.stats.Churn {
+		stats.Churn[kind] = n
+	}
+	return stats
+}
+
+// Setup creates the profile's namespaces, their Deployments and, with a
+// UnitStore, a unit for each. Namespaces left by an earlier run with the
+// same prefix are reused.
+func (g *LoadGen) Setup(ctx context.Context) error {
+	if err := g.Profile.Validate(); err != nil {
+		return err
+	}
+	started := time.Now()
+	g.mu.Lock()
+	g.rand = rand.New(rand.NewSource(g.Seed))
+	g.stats = LoadStats{Churn: map[string]int{}}
+	g.workloads = nil
+	for n := 1; n <= g.Profile.Namespaces; n++ {
+		namespace := fmt.Sprintf("%s-%d", g.Prefix, n)
+		for d := 1; d <= g.Profile.Deployments; d++ {
+			w := Workload{
+				Name:     fmt.Sprintf("load-%d", d),
+				Replicas: g.Profile.Replicas,
+				CPU:      g.Profile.CPU,
+				Memory:   g.Profile.Memory,
+				Critical: g.rand.Float64() < g.Profile.Critical,
+			}
+			if g.rand.Float64() < g.Profile.Oversized {
+				w.CPU, w.Memory = scale(w.CPU, 10), scale(w.Memory, 10)
+			}
+			g.workloads = append(g.workloads, loadWorkload{Workload: w, namespace: namespace})
+		}
+	}
+	g.mu.Unlock()
+
+	namespaces := g.Clientset.CoreV1().Namespaces()
+	for n := 1; n <= g.Profile.Namespaces; n++ {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   fmt.Sprintf("%s-%d", g.Prefix, n),
+			Labels: map[string]string{loadLabel: g.Prefix},
+		}}
+		if _, err := namespaces.Create(ctx, ns, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create namespace %s: %w", ns.Name, err)
+		}
+		g.count(func(s *LoadStats) { s.Namespaces++ })
+	}
+
+	err := g.each(ctx, func(w loadWorkload) error {
+		deployment := w.Deployment(w.namespace)
+		deployment.Labels[loadLabel] = g.Prefix
+		_, err := g.Clientset.AppsV1().Deployments(w.namespace).Create(ctx, deployment, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create Deployment %s/%s: %w", w.namespace, w.Name, err)
+		}
+		g.count(func(s *LoadStats) { s.Deployments++ })
+		if g.Units == nil {
+			return nil
+		}
+
+		manifest, err := w.Manifest(w.namespace)
+		if err != nil {
+			return err
+		}
+		labels := map[string]string{"app": w.Name, "namespace": w.namespace, loadLabel: g.Prefix}
+		if w.Critical {
+			labels["tier"], labels["monitor"] = "critical", "true"
+		}
+		if err := g.Units.CreateUnit(ctx, w.slug(), manifest, labels); err != nil {
+			return fmt.Errorf("create unit %s: %w", w.slug(), err)
+		}
+		g.count(func(s *LoadStats) { s.Units++ })
+		return nil
+	})
+	g.count(func(s *LoadStats) { s.SetupTime = time.Since(started) })
+	return err
+}
+
+// each runs f on every workload, Parallel at a time, and returns the first
+// error
+func (g *LoadGen) each(ctx context.Context, f func(loadWorkload) error) error {
+	parallel := g.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	work := make(chan loadWorkload)
+	errs := make(chan error, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w := range work {
+				if err := f(w); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for _, w := range g.workloads {
+		select {
+		case work <- w:
+		case err = <-errs:
+			break feed
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	close(errs)
+	if err == nil {
+		err = <-errs
+	}
+	return err
+}
+
+// Churn disturbs a random Deployment every interval until ctx is done or,
+// when events is positive, that many events have happened: it scales it in
+// the cluster or, with a UnitStore, edits its unit's CPU request. Failed
+// events are logged and counted, not fatal.
+func (g *LoadGen) Churn(ctx context.Context, every time.Duration, events int) {
+	kinds := []string{ChurnScale}
+	if g.Units != nil {
+		kinds = append(kinds, ChurnEdit)
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for i := 0; events <= 0 || i < events; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		g.mu.Lock()
+		if len(g.workloads) == 0 {
+			g.mu.Unlock()
+			return
+		}
+		kind := kinds[g.rand.Intn(len(kinds))]
+		index := g.rand.Intn(len(g.workloads))
+		w := &g.workloads[index]
+		switch kind {
+		case ChurnScale:
+			w.Replicas = int32(g.rand.Intn(3)) + g.Profile.Replicas
+		case ChurnEdit:
+			w.CPU = scale(g.Profile.CPU, int64(g.rand.Intn(4)+1))
+		}
+		target := *w
+		g.mu.Unlock()
+
+		if err := g.churn(ctx, kind, target); err != nil {
+			log.Printf("Churn %s of %s/%s failed: %v", kind, target.namespace, target.Name, err)
+			g.count(func(s *LoadStats) { s.Errors++ })
+			continue
+		}
+		g.count(func(s *LoadStats) { s.Churn[kind]++ })
+	}
+}
+
+// churn applies one event to w, which already has its new replicas or
+// request
+func (g *LoadGen) churn(ctx context.Context, kind string, w loadWorkload) error {
+	if kind == ChurnScale {
+		return InjectDrift(ctx, g.Clientset, w.namespace, w.Name, w.Replicas)
+	}
+	manifest, err := w.Manifest(w.namespace)
+	if err != nil {
+		return err
+	}
+	return g.Units.UpdateUnit(ctx, w.slug(), manifest)
+}
+
+// Cleanup deletes the run's namespaces, and with them its Deployments. The
+// units go with the ConfigHub space.
+func (g *LoadGen) Cleanup(ctx context.Context) error {
+	namespaces := g.Clientset.CoreV1().Namespaces()
+	list, err := namespaces.List(ctx, metav1.ListOptions{LabelSelector: loadLabel + "=" + g.Prefix})
+	if err != nil {
+		return fmt.Errorf("list namespaces: %w", err)
+	}
+	for _, ns := range list.Items {
+		if err := namespaces.Delete(ctx, ns.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete namespace %s: %w", ns.Name, err)
+		}
+	}
+	return nil
+}
+
+func (g *LoadGen) count(f func(*LoadStats)) {
+	g.mu.Lock()
+	f(&g.stats)
+	g.mu.Unlock()
+}
+
+// scale multiplies a quantity such as 10m or 16Mi, checked by Validate
+func scale(quantity string, factor int64) string {
+	q := resource.MustParse(quantity)
+	if q.MilliValue() < 1000*1000 { // CPU-sized: keep millicores exact
+		return resource.NewMilliQuantity(q.MilliValue()*factor, q.Format).String()
+	}
+	return resource.NewQuantity(q.Value()*factor, q.Format).String()
+}
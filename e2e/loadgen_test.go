@@ -0,0 +1,165 @@
+package e2e
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// units is an in-memory UnitStore
+type units struct {
+	mu      sync.Mutex
+	data    map[string]string
+	labels  map[string]map[string]string
+	updates int
+}
+
+func (u *units) CreateUnit(_ context.Context, slug string, manifest []byte, labels map[string]string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.data[slug], u.labels[slug] = string(manifest), labels
+	return nil
+}
+
+func (u *units) UpdateUnit(_ context.Context, slug string, manifest []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.data[slug] = string(manifest)
+	u.updates++
+	return nil
+}
+
+func TestLoadGen(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	store := &units{data: map[string]string{}, labels: map[string]map[string]string{}}
+	profile := LoadProfile{Namespaces: 3, Deployments: 10, Replicas: 1, CPU: "10m", Memory: "16Mi", Oversized: 0.5, Critical: 0.5}
+	gen := &LoadGen{Clientset: clientset, Units: store, Prefix: "load", Profile: profile, Parallel: 4, Seed: 1}
+
+	if err := gen.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats := gen.Stats()
+	if stats.Namespaces != 3 || stats.Deployments != 30 || stats.Units != 30 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	deployments, err := clientset.AppsV1().Deployments("load-2").List(ctx, metav1.ListOptions{})
+	if err != nil || len(deployments.Items) != 10 {
+		t.Fatalf("load-2 has %d Deployments, err %v", len(deployments.Items), err)
+	}
+	oversized, critical := 0, 0
+	for slug, manifest := range store.data {
+		if strings.Contains(manifest, "cpu: 100m") {
+			oversized++
+		}
+		if store.labels[slug]["tier"] == "critical" {
+			critical++
+		}
+	}
+	if oversized == 0 || oversized == 30 || critical == 0 || critical == 30 {
+		t.Errorf("Expected some of the 30 units oversized and critical, got %d and %d", oversized, critical)
+	}
+
+	// A second run with the same prefix reuses what is there
+	if err := gen.Setup(ctx); err != nil {
+		t.Errorf("Expected setup to be rerunnable, got %v", err)
+	}
+
+	gen.Churn(ctx, time.Millisecond, 20)
+	stats = gen.Stats()
+	if stats.Churn[ChurnScale]+stats.Churn[ChurnEdit] != 20 || stats.Errors != 0 {
+		t.Errorf("churn = %v, errors %d", stats.Churn, stats.Errors)
+	}
+	if store.updates != stats.Churn[ChurnEdit] {
+		t.Errorf("Expected %d unit edits, got %d", stats.Churn[ChurnEdit], store.updates)
+	}
+
+	if err := gen.Cleanup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	namespaces, _ := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if len(namespaces.Items) != 0 {
+		t.Errorf("Expected the namespaces deleted, got %d", len(namespaces.Items))
+	}
+}
+
+func TestLoadProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*LoadProfile)
+		wantErr bool
+	}{
+		{"default", func(*LoadProfile) {}, false},
+		{"no namespaces", func(p *LoadProfile) { p.Namespaces = 0 }, true},
+		{"bad cpu", func(p *LoadProfile) { p.CPU = "lots" }, true},
+		{"share over 1", func(p *LoadProfile) { p.Oversized = 1.5 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultLoadProfile
+			tt.change(&p)
+			if err := p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	before, err := ParseMetrics(strings.NewReader(`# HELP devops_cycles_total Detection and analysis runs.
+# TYPE devops_cycles_total counter
+devops_cycles_total{app="drift-detector",cycle="detect",result="ok",space="prod"} 10
+devops_cycle_duration_seconds_sum{app="drift-detector",cycle="detect",space="prod"} 5
+devops_cycle_duration_seconds_count{app="drift-detector",cycle="detect",space="prod"} 10
+confighub_requests_total{app="drift-detector",call="ListUnits"} 100
+llm_month_input_tokens{app="drift-detector",model="claude",provider="claude"} 1000
+llm_month_cost_dollars{app="drift-detector",model="claude",provider="claude"} 0.5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := ParseMetrics(strings.NewReader(`devops_cycles_total{app="drift-detector",cycle="detect",result="ok",space="prod"} 18
+devops_cycles_total{app="drift-detector",cycle="detect",result="error",space="prod"} 2
+devops_cycle_duration_seconds_sum{app="drift-detector",cycle="detect",space="prod"} 25
+devops_cycle_duration_seconds_count{app="drift-detector",cycle="detect",space="prod"} 20
+confighub_requests_total{app="drift-detector",call="ListUnits"} 160
+confighub_requests_total{app="drift-detector",call="GetUnit"} 40
+devops_external_calls_total{app="drift-detector",operation="analyze",result="ok",service="claude"} 3
+llm_month_input_tokens{app="drift-detector",model="claude",provider="claude"} 4000
+llm_month_cost_dollars{app="drift-detector",model="claude",provider="claude"} 0.75
+devops_cycles_total{app="cost-optimizer",cycle="analyze",result="ok",space="prod"} 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage := Usage(before, after)
+	if len(usage) != 2 || usage[0].App != "cost-optimizer" || usage[1].App != "drift-detector" {
+		t.Fatalf("usage = %+v", usage)
+	}
+	d := usage[1]
+	if d.Cycles != 10 || d.FailedCycles != 2 || d.MeanCycle != 2*time.Second {
+		t.Errorf("cycles = %d, failed %d, mean %s", d.Cycles, d.FailedCycles, d.MeanCycle)
+	}
+	if d.ConfigHubRequests != 100 || d.ClaudeCalls != 3 || d.InputTokens != 3000 || d.Cost != 0.25 {
+		t.Errorf("usage = %+v", d)
+	}
+}
+
+func TestParseMetricsLabels(t *testing.T) {
+	samples, err := ParseMetrics(strings.NewReader(`m{a="x,y",b="q\"uote"} 1.5` + "\n" + "plain 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].Labels["a"] != "x,y" || samples[0].Labels["b"] != `q"uote` || samples[1].Value != 2 {
+		t.Errorf("samples = %+v", samples)
+	}
+	if _, err := ParseMetrics(strings.NewReader("m{a=x} 1\n")); err == nil {
+		t.Error("Expected unquoted labels to fail")
+	}
+}
@@ -0,0 +1,151 @@
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample is one line of an app's /metrics
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// AppUsage is what one app spent between two scrapes of its /metrics
+type AppUsage struct {
+	App               string        `json:"app"`
+	Cycles            int           `json:"cycles"`
+	FailedCycles      int           `json:"failed_cycles"`
+	MeanCycle         time.Duration `json:"mean_cycle"`
+	ConfigHubRequests int           `json:"confighub_requests"`
+	ClaudeCalls       int           `json:"claude_calls"`
+	InputTokens       int           `json:"input_tokens"`
+	OutputTokens      int           `json:"output_tokens"`
+	Cost              float64       `json:"cost_dollars"` // estimated AI spend
+}
+
+// ScrapeMetrics reads the Prometheus text of an app's /metrics, on its
+// health port, e.g. http://localhost:9090/metrics
+func ScrapeMetrics(ctx context.Context, url string) ([]Sample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return ParseMetrics(resp.Body)
+}
+
+// ParseMetrics reads samples in the Prometheus text format written by
+// pkg/metrics, skipping comments
+func ParseMetrics(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cut := strings.LastIndexByte(line, ' ')
+		if cut < 0 {
+			return nil, fmt.Errorf("metrics line %q has no value", line)
+		}
+		value, err := strconv.ParseFloat(line[cut+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("metrics line %q: %w", line, err)
+		}
+		s := Sample{Name: line[:cut], Labels: map[string]string{}, Value: value}
+		if open := strings.IndexByte(s.Name, '{'); open >= 0 {
+			if s.Labels, err = parseLabels(strings.TrimSuffix(s.Name[open+1:], "}")); err != nil {
+				return nil, fmt.Errorf("metrics line %q: %w", line, err)
+			}
+			s.Name = s.Name[:open]
+		}
+		samples = append(samples, s)
+	}
+	return samples, scanner.Err()
+}
+
+// parseLabels reads k="v",... with Go-quoted values
+func parseLabels(text string) (map[string]string, error) {
+	labels := map[string]string{}
+	for text != "" {
+		eq := strings.IndexByte(text, '=')
+		if eq < 0 || len(text) < eq+2 || text[eq+1] != '"' {
+			return nil, fmt.Errorf("bad labels %q", text)
+		}
+		key, rest := text[:eq], text[eq+1:]
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, fmt.Errorf("bad label %s: %w", key, err)
+		}
+		if labels[key], err = strconv.Unquote(quoted); err != nil {
+			return nil, err
+		}
+		text = strings.TrimPrefix(rest[len(quoted):], ",")
+	}
+	return labels, nil
+}
+
+// Usage returns each app's spend between two scrapes, by the app label,
+// sorted by app. Counters are diffed; the llm_month gauges are too, so a run
+// crossing a month boundary undercounts.
+func Usage(before, after []Sample) []AppUsage {
+	totals := func(samples []Sample) map[string]map[string]float64 {
+		byApp := map[string]map[string]float64{}
+		for _, s := range samples {
+			app := s.Labels["app"]
+			if byApp[app] == nil {
+				byApp[app] = map[string]float64{}
+			}
+			key := s.Name
+			switch s.Name {
+			case "devops_cycles_total":
+				if s.Labels["result"] == "error" {
+					byApp[app]["failed_cycles"] += s.Value
+				}
+			case "devops_external_calls_total":
+				key = "calls/" + s.Labels["service"]
+			}
+			byApp[app][key] += s.Value
+		}
+		return byApp
+	}
+	was, now := totals(before), totals(after)
+
+	var usage []AppUsage
+	for app, n := range now {
+		w := was[app]
+		delta := func(key string) float64 { return n[key] - w[key] }
+		u := AppUsage{
+			App:               app,
+			Cycles:            int(delta("devops_cycles_total")),
+			FailedCycles:      int(delta("failed_cycles")),
+			ConfigHubRequests: int(delta("confighub_requests_total")),
+			ClaudeCalls:       int(delta("calls/claude")),
+			InputTokens:       int(delta("llm_month_input_tokens")),
+			OutputTokens:      int(delta("llm_month_output_tokens")),
+			Cost:              delta("llm_month_cost_dollars"),
+		}
+		if count := delta("devops_cycle_duration_seconds_count"); count > 0 {
+			u.MeanCycle = time.Duration(delta("devops_cycle_duration_seconds_sum") / count * float64(time.Second))
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].App < usage[j].App })
+	return usage
+}
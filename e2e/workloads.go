@@ -0,0 +1,112 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Workload is a sample Deployment the apps are tested against
+type Workload struct {
+	Name     string
+	Replicas int32
+	CPU      string // request per pod
+	Memory   string
+	Critical bool // labelled for the drift detector's critical-services set
+}
+
+// Workloads are deployed to every test cluster. oversized-batch requests far
+// more than its idle container uses, which is the waste the cost apps should
+// see.
+var Workloads = []Workload{
+	{Name: "backend-api", Replicas: 2, CPU: "100m", Memory: "64Mi", Critical: true},
+	{Name: "frontend-web", Replicas: 2, CPU: "50m", Memory: "32Mi", Critical: true},
+	{Name: "oversized-batch", Replicas: 1, CPU: "500m", Memory: "512Mi"},
+}
+
+// Deployment is the manifest of w in namespace
+func (w Workload) Deployment(namespace string) *appsv1.Deployment {
+	labels := map[string]string{"app": w.Name}
+	replicas := w.Replicas
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.Name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:    w.Name,
+						Image:   "busybox:1.36",
+						Command: []string{"sleep", "infinity"},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse(w.CPU),
+								corev1.ResourceMemory: resource.MustParse(w.Memory),
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// Manifest renders w as the YAML stored in its ConfigHub unit
+func (w Workload) Manifest(namespace string) ([]byte, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(w.Deployment(namespace))
+	if err != nil {
+		return nil, err
+	}
+	// Keep only the fields a user would write
+	delete(obj, "status")
+	unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(obj, "spec", "template", "metadata", "creationTimestamp")
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s: %w", w.Name, err)
+	}
+	return data, nil
+}
+
+// WaitForWorkloads waits until every workload's pods are available
+func WaitForWorkloads(ctx context.Context, clientset kubernetes.Interface, namespace string, timeout time.Duration) error {
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		for _, w := range Workloads {
+			deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, w.Name, metav1.GetOptions{})
+			if err != nil || deployment.Status.AvailableReplicas < w.Replicas {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// InjectDrift changes a deployment's replicas behind ConfigHub's back, as a
+// manual kubectl scale or edit would
+func InjectDrift(ctx context.Context, clientset kubernetes.Interface, namespace, name string, replicas int32) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	_, err := clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("scale %s: %w", name, err)
+	}
+	return nil
+}
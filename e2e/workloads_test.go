@@ -0,0 +1,66 @@
+package e2e
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWorkloadManifest(t *testing.T) {
+	data, err := Workloads[0].Manifest("e2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unwanted := range []string{"status", "creationTimestamp"} {
+		if strings.Contains(string(data), unwanted) {
+			t.Errorf("manifest contains %s:\n%s", unwanted, data)
+		}
+	}
+
+	var deployment appsv1.Deployment
+	if err := yaml.Unmarshal(data, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Kind != "Deployment" || deployment.Namespace != "e2e" || *deployment.Spec.Replicas != 2 {
+		t.Errorf("manifest = %s", data)
+	}
+	cpu := deployment.Spec.Template.Spec.Containers[0].Resources.Requests.Cpu()
+	if cpu.MilliValue() != 100 {
+		t.Errorf("cpu request = %s", cpu)
+	}
+}
+
+func TestInjectDrift(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(Workloads[0].Deployment("e2e"))
+
+	if err := InjectDrift(ctx, clientset, "e2e", "backend-api", 5); err != nil {
+		t.Fatal(err)
+	}
+	deployment, err := clientset.AppsV1().Deployments("e2e").Get(ctx, "backend-api", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *deployment.Spec.Replicas != 5 {
+		t.Errorf("replicas = %d, want 5", *deployment.Spec.Replicas)
+	}
+
+	if err := InjectDrift(ctx, clientset, "e2e", "missing", 1); err == nil {
+		t.Error("drift injected into a missing deployment")
+	}
+}
+
+func TestJSONField(t *testing.T) {
+	id, err := jsonField([]byte(`{"space_id":"5f1c","slug":"e2e"}`), "space_id")
+	if err != nil || id != "5f1c" {
+		t.Errorf("jsonField = %q, %v", id, err)
+	}
+	if _, err := jsonField([]byte(`{"slug":"e2e"}`), "space_id"); err == nil {
+		t.Error("missing field accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Apps the operator can run
+const (
+	DriftDetector     = "drift-detector"
+	CostOptimizer     = "cost-optimizer"
+	CostImpactMonitor = "cost-impact-monitor"
+)
+
+// DevOpsAppSpec declares which example app to run and how
+type DevOpsAppSpec struct {
+	// App is the example to run
+	// +kubebuilder:validation:Enum=drift-detector;cost-optimizer;cost-impact-monitor
+	App string `json:"app"`
+
+	// Space is the ConfigHub space the app works in: the space slug for
+	// drift-detector, the space ID for cost-optimizer (empty creates one).
+	// cost-impact-monitor watches every space and doesn't take one.
+	// +optional
+	Space string `json:"space,omitempty"`
+
+	// Interval between the app's scheduled runs; the app's default when unset
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// AutoApply lets drift-detector apply its fixes and cost-optimizer its
+	// optimizations without review
+	// +optional
+	AutoApply bool `json:"autoApply,omitempty"`
+
+	// SecretRef names a Secret in the DevOpsApp's namespace with the key
+	// cub-token and, optionally, claude-api-key (and webhook-secret for
+	// cost-impact-monitor)
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+
+	// Image overrides the app's default image
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Env sets further app settings by their environment variables
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Resources of the app container
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// Condition types and reasons reported in DevOpsAppStatus
+const (
+	// ConditionReady is true once the app's Deployment is available
+	ConditionReady = "Ready"
+
+	ReasonAvailable     = "Available"
+	ReasonProgressing   = "Progressing"
+	ReasonInvalidSpec   = "InvalidSpec"
+	ReasonSecretMissing = "SecretMissing"
+)
+
+// DevOpsAppStatus is the observed state of a DevOpsApp
+type DevOpsAppStatus struct {
+	// ObservedGeneration is the generation last reconciled
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Deployment is the name of the Deployment running the app
+	// +optional
+	Deployment string `json:"deployment,omitempty"`
+
+	// ReadyReplicas of the Deployment
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// DevOpsApp runs one of the example apps in the cluster
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=dapp
+// +kubebuilder:printcolumn:name="App",type=string,JSONPath=`.spec.app`
+// +kubebuilder:printcolumn:name="Space",type=string,JSONPath=`.spec.space`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type DevOpsApp struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DevOpsAppSpec   `json:"spec,omitempty"`
+	Status DevOpsAppStatus `json:"status,omitempty"`
+}
+
+// DevOpsAppList is a list of DevOpsApps
+// +kubebuilder:object:root=true
+type DevOpsAppList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DevOpsApp `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DevOpsApp{}, &DevOpsAppList{})
+}
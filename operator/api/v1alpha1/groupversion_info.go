@@ -0,0 +1,20 @@
+// Package v1alpha1 contains the DevOpsApp API of the devops.confighub.com group
+// +kubebuilder:object:generate=true
+// +groupName=devops.confighub.com
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the group and version of the DevOpsApp API
+	GroupVersion = schema.GroupVersion{Group: "devops.confighub.com", Version: "v1alpha1"}
+
+	// SchemeBuilder registers the API types with a scheme
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the API types to a scheme
+	AddToScheme = SchemeBuilder.AddToScheme
+)
@@ -0,0 +1,114 @@
+package operator
+
+import (
+	"fmt"
+
+	devopsv1alpha1 "github.com/monadic/devops-examples/operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// appDefaults describes how to run one example app: its image and ports, and
+// the environment variables that carry the DevOpsApp's settings. Every app
+// reads its config from the environment as well as CONFIG_FILE, so the
+// operator doesn't write config files.
+type appDefaults struct {
+	image        string
+	healthPort   int32
+	port         corev1.ContainerPort // the app's API or dashboard, exposed by the Service
+	spaceEnv     string               // empty when the app has no space setting
+	autoApplyEnv string               // empty when the app never applies changes itself
+}
+
+// apps maps DevOpsAppSpec.App to its defaults; images and ports match deploy/charts
+var apps = map[string]appDefaults{
+	devopsv1alpha1.DriftDetector: {
+		image:        "drift-detector:2.0.0",
+		healthPort:   8080,
+		port:         corev1.ContainerPort{Name: "api", ContainerPort: 8084},
+		spaceEnv:     "CUB_SPACE",
+		autoApplyEnv: "AUTO_FIX",
+	},
+	devopsv1alpha1.CostOptimizer: {
+		image:        "cost-optimizer:2.0.0",
+		healthPort:   8080,
+		port:         corev1.ContainerPort{Name: "dashboard", ContainerPort: 8081},
+		spaceEnv:     "CONFIGHUB_SPACE_ID",
+		autoApplyEnv: "AUTO_APPLY_OPTIMIZATIONS",
+	},
+	devopsv1alpha1.CostImpactMonitor: {
+		image:      "cost-impact-monitor:1.0.0",
+		healthPort: 8082,
+		port:       corev1.ContainerPort{Name: "dashboard", ContainerPort: 8083},
+	},
+}
+
+// lookupApp returns the defaults for spec.App, rejecting settings the app can't honour
+func lookupApp(spec devopsv1alpha1.DevOpsAppSpec) (appDefaults, error) {
+	app, ok := apps[spec.App]
+	switch {
+	case !ok:
+		return appDefaults{}, fmt.Errorf("unknown app %q", spec.App)
+	case spec.Space != "" && app.spaceEnv == "":
+		return appDefaults{}, fmt.Errorf("%s watches every space and takes no space", spec.App)
+	case spec.AutoApply && app.autoApplyEnv == "":
+		return appDefaults{}, fmt.Errorf("%s has no auto-apply policy", spec.App)
+	case spec.SecretRef.Name == "":
+		return appDefaults{}, fmt.Errorf("secretRef.name is required")
+	}
+	return app, nil
+}
+
+// env returns the container environment for a DevOpsApp: its settings, the
+// secret keys, then spec.Env, which wins over the others
+func (a appDefaults) env(app *devopsv1alpha1.DevOpsApp) []corev1.EnvVar {
+	spec := app.Spec
+	var env []corev1.EnvVar
+	if a.spaceEnv != "" && spec.Space != "" {
+		env = append(env, corev1.EnvVar{Name: a.spaceEnv, Value: spec.Space})
+	}
+	if a.autoApplyEnv != "" {
+		env = append(env, corev1.EnvVar{Name: a.autoApplyEnv, Value: fmt.Sprint(spec.AutoApply)})
+	}
+	if spec.Interval != nil {
+		env = append(env, corev1.EnvVar{Name: "RUN_INTERVAL", Value: spec.Interval.Duration.String()})
+	}
+	if spec.App == devopsv1alpha1.CostImpactMonitor {
+		env = append(env, corev1.EnvVar{Name: "POD_NAMESPACE", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+		}})
+	}
+
+	env = append(env,
+		secretEnv("CUB_TOKEN", spec.SecretRef.Name, secretKeyCubToken, false),
+		secretEnv("CLAUDE_API_KEY", spec.SecretRef.Name, "claude-api-key", true),
+	)
+	if spec.App == devopsv1alpha1.CostImpactMonitor {
+		env = append(env, secretEnv("WEBHOOK_SECRET", spec.SecretRef.Name, "webhook-secret", true))
+	}
+
+	for _, override := range spec.Env {
+		env = setEnv(env, override)
+	}
+	return env
+}
+
+func secretEnv(name, secret, key string, optional bool) corev1.EnvVar {
+	return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+			Key:                  key,
+			Optional:             &optional,
+		},
+	}}
+}
+
+// setEnv replaces the variable named like v, or appends v
+func setEnv(env []corev1.EnvVar, v corev1.EnvVar) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == v.Name {
+			env[i] = v
+			return env
+		}
+	}
+	return append(env, v)
+}
@@ -0,0 +1,9 @@
+// Command devops-operator runs the example apps declared as DevOpsApp
+// custom resources
+package main
+
+import "github.com/monadic/devops-examples/operator"
+
+func main() {
+	operator.Main()
+}
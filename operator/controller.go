@@ -0,0 +1,306 @@
+package operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	devopsv1alpha1 "github.com/monadic/devops-examples/operator/api/v1alpha1"
+	"github.com/monadic/devops-examples/pkg/logging"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// finalizer removes the app's ClusterRoleBinding, which as a
+	// cluster-scoped object can't be garbage collected through an owner reference
+	finalizer = "devops.confighub.com/cluster-role-binding"
+
+	// secretHashAnnotation on the pod template restarts the app when its secret changes
+	secretHashAnnotation = "devops.confighub.com/secret-hash"
+
+	secretKeyCubToken = "cub-token"
+
+	// appClusterRole is installed with the operator (config/rbac.yaml) and
+	// bound to every app's ServiceAccount
+	appClusterRole = "devops-app"
+
+	managedBy = "devops-operator"
+)
+
+// DevOpsAppReconciler runs each DevOpsApp as a ServiceAccount, a binding to
+// the devops-app ClusterRole, a single-replica Deployment and a Service
+type DevOpsAppReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=devops.confighub.com,resources=devopsapps,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=devops.confighub.com,resources=devopsapps/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=devops.confighub.com,resources=devopsapps/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=devops-app
+
+// Reconcile brings a DevOpsApp's objects in line with its spec
+func (r *DevOpsAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var app devopsv1alpha1.DevOpsApp
+	if err := r.Get(ctx, req.NamespacedName, &app); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	logger := slog.With("devopsapp", req.NamespacedName.String(), "kind", app.Spec.App)
+
+	if !app.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &app)
+	}
+	if controllerutil.AddFinalizer(&app, finalizer) {
+		if err := r.Update(ctx, &app); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	defaults, err := lookupApp(app.Spec)
+	if err != nil {
+		logger.Warn("Invalid DevOpsApp", logging.Err(err))
+		return ctrl.Result{}, r.setReady(ctx, &app, nil, metav1.ConditionFalse, devopsv1alpha1.ReasonInvalidSpec, err.Error())
+	}
+
+	// Without the token the pod can't start, so wait for the Secret; its watch
+	// brings the DevOpsApp back here once it exists
+	var secret corev1.Secret
+	err = r.Get(ctx, types.NamespacedName{Namespace: app.Namespace, Name: app.Spec.SecretRef.Name}, &secret)
+	if apierrors.IsNotFound(err) || err == nil && len(secret.Data[secretKeyCubToken]) == 0 {
+		msg := fmt.Sprintf("secret %s with key %s not found", app.Spec.SecretRef.Name, secretKeyCubToken)
+		return ctrl.Result{}, r.setReady(ctx, &app, nil, metav1.ConditionFalse, devopsv1alpha1.ReasonSecretMissing, msg)
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("get secret: %w", err)
+	}
+
+	if err := r.reconcileServiceAccount(ctx, &app); err != nil {
+		return ctrl.Result{}, fmt.Errorf("service account: %w", err)
+	}
+	if err := r.reconcileClusterRoleBinding(ctx, &app); err != nil {
+		return ctrl.Result{}, fmt.Errorf("cluster role binding: %w", err)
+	}
+	deployment, err := r.reconcileDeployment(ctx, &app, defaults, secretHash(&secret))
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("deployment: %w", err)
+	}
+	if err := r.reconcileService(ctx, &app, defaults); err != nil {
+		return ctrl.Result{}, fmt.Errorf("service: %w", err)
+	}
+
+	// Deployment status changes requeue the DevOpsApp through Owns
+	if deployment.Status.AvailableReplicas > 0 {
+		return ctrl.Result{}, r.setReady(ctx, &app, deployment, metav1.ConditionTrue, devopsv1alpha1.ReasonAvailable, "app is running")
+	}
+	return ctrl.Result{}, r.setReady(ctx, &app, deployment, metav1.ConditionFalse, devopsv1alpha1.ReasonProgressing, "waiting for the deployment to become available")
+}
+
+// finalize deletes the ClusterRoleBinding and releases the DevOpsApp; the
+// namespaced objects go with it through their owner references
+func (r *DevOpsAppReconciler) finalize(ctx context.Context, app *devopsv1alpha1.DevOpsApp) error {
+	if !controllerutil.ContainsFinalizer(app, finalizer) {
+		return nil
+	}
+	binding := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: clusterRoleBindingName(app)}}
+	if err := r.Delete(ctx, binding); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("delete cluster role binding: %w", err)
+	}
+	controllerutil.RemoveFinalizer(app, finalizer)
+	return r.Update(ctx, app)
+}
+
+// setReady records the Ready condition and the deployment's progress
+func (r *DevOpsAppReconciler) setReady(ctx context.Context, app *devopsv1alpha1.DevOpsApp, deployment *appsv1.Deployment, status metav1.ConditionStatus, reason, message string) error {
+	app.Status.ObservedGeneration = app.Generation
+	app.Status.Deployment, app.Status.ReadyReplicas = "", 0
+	if deployment != nil {
+		app.Status.Deployment = deployment.Name
+		app.Status.ReadyReplicas = deployment.Status.ReadyReplicas
+	}
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               devopsv1alpha1.ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.Generation,
+	})
+	return r.Status().Update(ctx, app)
+}
+
+func (r *DevOpsAppReconciler) reconcileServiceAccount(ctx context.Context, app *devopsv1alpha1.DevOpsApp) error {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, sa, func() error {
+		sa.Labels = labels(app)
+		return controllerutil.SetControllerReference(app, sa, r.Scheme)
+	})
+	return err
+}
+
+func (r *DevOpsAppReconciler) reconcileClusterRoleBinding(ctx context.Context, app *devopsv1alpha1.DevOpsApp) error {
+	binding := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: clusterRoleBindingName(app)}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		binding.Labels = labels(app)
+		binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: appClusterRole}
+		binding.Subjects = []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: app.Name, Namespace: app.Namespace}}
+		return nil
+	})
+	return err
+}
+
+func (r *DevOpsAppReconciler) reconcileDeployment(ctx context.Context, app *devopsv1alpha1.DevOpsApp, defaults appDefaults, secretHash string) (*appsv1.Deployment, error) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, deployment, func() error {
+		if deployment.CreationTimestamp.IsZero() {
+			deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: selectorLabels(app)}
+		}
+		deployment.Labels = labels(app)
+		// A second replica would run every check, fix and alert twice
+		replicas := int32(1)
+		deployment.Spec.Replicas = &replicas
+
+		template := &deployment.Spec.Template
+		template.Labels = labels(app)
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[secretHashAnnotation] = secretHash
+
+		pod := &template.Spec
+		pod.ServiceAccountName = app.Name
+		if len(pod.Containers) != 1 {
+			pod.Containers = make([]corev1.Container, 1)
+		}
+		container := &pod.Containers[0]
+		container.Name = app.Spec.App
+		container.Image = defaults.image
+		if app.Spec.Image != "" {
+			container.Image = app.Spec.Image
+		}
+		container.Env = defaults.env(app)
+		container.Ports = []corev1.ContainerPort{
+			{Name: "health", ContainerPort: defaults.healthPort, Protocol: corev1.ProtocolTCP},
+			{Name: defaults.port.Name, ContainerPort: defaults.port.ContainerPort, Protocol: corev1.ProtocolTCP},
+		}
+		container.LivenessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+				Path: "/health", Port: intstr.FromString("health"), Scheme: corev1.URISchemeHTTP,
+			}},
+			InitialDelaySeconds: 30,
+			PeriodSeconds:       30,
+			TimeoutSeconds:      1,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		}
+		container.ReadinessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+				Path: "/health/ready", Port: intstr.FromString("health"), Scheme: corev1.URISchemeHTTP,
+			}},
+			InitialDelaySeconds: 10,
+			PeriodSeconds:       15,
+			TimeoutSeconds:      6,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		}
+		container.Resources = app.Spec.Resources
+		return controllerutil.SetControllerReference(app, deployment, r.Scheme)
+	})
+	return deployment, err
+}
+
+func (r *DevOpsAppReconciler) reconcileService(ctx context.Context, app *devopsv1alpha1.DevOpsApp, defaults appDefaults) error {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		service.Labels = labels(app)
+		service.Spec.Selector = selectorLabels(app)
+		service.Spec.Ports = []corev1.ServicePort{{
+			Name:       defaults.port.Name,
+			Port:       defaults.port.ContainerPort,
+			TargetPort: intstr.FromString(defaults.port.Name),
+			Protocol:   corev1.ProtocolTCP,
+		}}
+		return controllerutil.SetControllerReference(app, service, r.Scheme)
+	})
+	return err
+}
+
+// SetupWithManager registers the reconciler, watching the objects it owns
+// and the Secrets DevOpsApps refer to
+func (r *DevOpsAppReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&devopsv1alpha1.DevOpsApp{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ServiceAccount{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.appsForSecret)).
+		Complete(r)
+}
+
+// appsForSecret returns the DevOpsApps in the secret's namespace that use it
+func (r *DevOpsAppReconciler) appsForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	var list devopsv1alpha1.DevOpsAppList
+	if err := r.List(ctx, &list, client.InNamespace(secret.GetNamespace())); err != nil {
+		slog.Warn("Failed to list DevOpsApps for secret", "secret", secret.GetName(), logging.Err(err))
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, app := range list.Items {
+		if app.Spec.SecretRef.Name == secret.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&app)})
+		}
+	}
+	return requests
+}
+
+func labels(app *devopsv1alpha1.DevOpsApp) map[string]string {
+	l := selectorLabels(app)
+	l["app.kubernetes.io/name"] = app.Spec.App
+	return l
+}
+
+// selectorLabels leave out the app name, so changing spec.app doesn't hit
+// the Deployment's immutable selector
+func selectorLabels(app *devopsv1alpha1.DevOpsApp) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/instance":   app.Name,
+		"app.kubernetes.io/managed-by": managedBy,
+	}
+}
+
+func clusterRoleBindingName(app *devopsv1alpha1.DevOpsApp) string {
+	return fmt.Sprintf("devops-app-%s-%s", app.Namespace, app.Name)
+}
+
+// secretHash fingerprints a secret's data
+func secretHash(secret *corev1.Secret) string {
+	keys := make([]string, 0, len(secret.Data))
+	for k := range secret.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%x\n", k, secret.Data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
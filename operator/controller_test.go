@@ -0,0 +1,287 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	devopsv1alpha1 "github.com/monadic/devops-examples/operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "devops-apps"
+
+func newReconciler(t *testing.T, objs ...client.Object) *DevOpsAppReconciler {
+	t.Helper()
+	scheme, err := NewScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&devopsv1alpha1.DevOpsApp{}).
+		Build()
+	return &DevOpsAppReconciler{Client: c, Scheme: scheme}
+}
+
+func newApp(name string, spec devopsv1alpha1.DevOpsAppSpec) *devopsv1alpha1.DevOpsApp {
+	if spec.SecretRef.Name == "" {
+		spec.SecretRef.Name = "devops-apps-secrets"
+	}
+	return &devopsv1alpha1.DevOpsApp{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Generation: 1},
+		Spec:       spec,
+	}
+}
+
+func newSecret(token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "devops-apps-secrets", Namespace: testNamespace},
+		Data:       map[string][]byte{"cub-token": []byte(token)},
+	}
+}
+
+func reconcileApp(t *testing.T, r *DevOpsAppReconciler, name string) *devopsv1alpha1.DevOpsApp {
+	t.Helper()
+	key := types.NamespacedName{Namespace: testNamespace, Name: name}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	var app devopsv1alpha1.DevOpsApp
+	if err := r.Get(context.Background(), key, &app); err != nil {
+		t.Fatalf("get DevOpsApp: %v", err)
+	}
+	return &app
+}
+
+func getDeployment(t *testing.T, r *DevOpsAppReconciler, name string) *appsv1.Deployment {
+	t.Helper()
+	var deployment appsv1.Deployment
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, &deployment); err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+	return &deployment
+}
+
+func envMap(container corev1.Container) map[string]corev1.EnvVar {
+	env := map[string]corev1.EnvVar{}
+	for _, v := range container.Env {
+		env[v.Name] = v
+	}
+	return env
+}
+
+func readyCondition(t *testing.T, app *devopsv1alpha1.DevOpsApp) *metav1.Condition {
+	t.Helper()
+	cond := meta.FindStatusCondition(app.Status.Conditions, devopsv1alpha1.ConditionReady)
+	if cond == nil {
+		t.Fatalf("no Ready condition in %+v", app.Status)
+	}
+	return cond
+}
+
+func TestReconcileDriftDetector(t *testing.T) {
+	app := newApp("drift", devopsv1alpha1.DevOpsAppSpec{
+		App:       devopsv1alpha1.DriftDetector,
+		Space:     "acorn-bear-qa",
+		Interval:  &metav1.Duration{Duration: 2 * time.Minute},
+		AutoApply: true,
+		Env:       []corev1.EnvVar{{Name: "NAMESPACE", Value: "qa"}, {Name: "AUTO_FIX", Value: "false"}},
+	})
+	r := newReconciler(t, app, newSecret("token"))
+
+	got := reconcileApp(t, r, "drift")
+	if len(got.Finalizers) != 1 || got.Finalizers[0] != finalizer {
+		t.Errorf("finalizers = %v", got.Finalizers)
+	}
+	if cond := readyCondition(t, got); cond.Status != metav1.ConditionFalse || cond.Reason != devopsv1alpha1.ReasonProgressing {
+		t.Errorf("Ready = %s/%s, want False/Progressing", cond.Status, cond.Reason)
+	}
+	if got.Status.Deployment != "drift" || got.Status.ObservedGeneration != 1 {
+		t.Errorf("status = %+v", got.Status)
+	}
+
+	deployment := getDeployment(t, r, "drift")
+	if len(deployment.OwnerReferences) != 1 || deployment.OwnerReferences[0].Name != "drift" {
+		t.Errorf("owner references = %v", deployment.OwnerReferences)
+	}
+	pod := deployment.Spec.Template.Spec
+	if pod.ServiceAccountName != "drift" {
+		t.Errorf("service account = %q", pod.ServiceAccountName)
+	}
+	container := pod.Containers[0]
+	if container.Image != "drift-detector:2.0.0" {
+		t.Errorf("image = %q", container.Image)
+	}
+	env := envMap(container)
+	for name, want := range map[string]string{
+		"CUB_SPACE":    "acorn-bear-qa",
+		"RUN_INTERVAL": "2m0s",
+		"NAMESPACE":    "qa",
+		"AUTO_FIX":     "false", // spec.env wins over autoApply
+	} {
+		if env[name].Value != want {
+			t.Errorf("%s = %q, want %q", name, env[name].Value, want)
+		}
+	}
+	token := env["CUB_TOKEN"].ValueFrom
+	if token == nil || token.SecretKeyRef.Name != "devops-apps-secrets" || token.SecretKeyRef.Key != "cub-token" {
+		t.Errorf("CUB_TOKEN = %+v", env["CUB_TOKEN"])
+	}
+	if _, ok := env["WEBHOOK_SECRET"]; ok {
+		t.Error("WEBHOOK_SECRET set for drift-detector")
+	}
+
+	var service corev1.Service
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "drift"}, &service); err != nil {
+		t.Fatalf("get service: %v", err)
+	}
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != 8084 {
+		t.Errorf("service ports = %+v", service.Spec.Ports)
+	}
+
+	var binding rbacv1.ClusterRoleBinding
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "devops-app-devops-apps-drift"}, &binding); err != nil {
+		t.Fatalf("get cluster role binding: %v", err)
+	}
+	if binding.RoleRef.Name != appClusterRole || binding.Subjects[0].Name != "drift" || binding.Subjects[0].Namespace != testNamespace {
+		t.Errorf("binding = %+v %+v", binding.RoleRef, binding.Subjects)
+	}
+
+	// Once the deployment is available the app is Ready
+	deployment.Status.AvailableReplicas, deployment.Status.ReadyReplicas = 1, 1
+	if err := r.Status().Update(context.Background(), deployment); err != nil {
+		t.Fatal(err)
+	}
+	got = reconcileApp(t, r, "drift")
+	if cond := readyCondition(t, got); cond.Status != metav1.ConditionTrue {
+		t.Errorf("Ready = %s/%s, want True", cond.Status, cond.Reason)
+	}
+	if got.Status.ReadyReplicas != 1 {
+		t.Errorf("ready replicas = %d", got.Status.ReadyReplicas)
+	}
+}
+
+func TestReconcileCostApps(t *testing.T) {
+	optimizer := newApp("optimizer", devopsv1alpha1.DevOpsAppSpec{
+		App:   devopsv1alpha1.CostOptimizer,
+		Space: "5f1c0c1e-3f0a-4d3e-9a55-5a1d0e9f0c11",
+		Image: "registry.example.com/cost-optimizer:dev",
+	})
+	monitor := newApp("monitor", devopsv1alpha1.DevOpsAppSpec{App: devopsv1alpha1.CostImpactMonitor})
+	r := newReconciler(t, optimizer, monitor, newSecret("token"))
+	reconcileApp(t, r, "optimizer")
+	reconcileApp(t, r, "monitor")
+
+	container := getDeployment(t, r, "optimizer").Spec.Template.Spec.Containers[0]
+	env := envMap(container)
+	if container.Image != "registry.example.com/cost-optimizer:dev" {
+		t.Errorf("image = %q", container.Image)
+	}
+	if env["CONFIGHUB_SPACE_ID"].Value != optimizer.Spec.Space || env["AUTO_APPLY_OPTIMIZATIONS"].Value != "false" {
+		t.Errorf("cost-optimizer env = %+v", container.Env)
+	}
+	if _, ok := env["RUN_INTERVAL"]; ok {
+		t.Error("RUN_INTERVAL set without spec.interval")
+	}
+
+	container = getDeployment(t, r, "monitor").Spec.Template.Spec.Containers[0]
+	env = envMap(container)
+	if container.Ports[0].ContainerPort != 8082 || container.Ports[1].ContainerPort != 8083 {
+		t.Errorf("cost-impact-monitor ports = %+v", container.Ports)
+	}
+	if ref := env["POD_NAMESPACE"].ValueFrom; ref == nil || ref.FieldRef.FieldPath != "metadata.namespace" {
+		t.Errorf("POD_NAMESPACE = %+v", env["POD_NAMESPACE"])
+	}
+	if ref := env["WEBHOOK_SECRET"].ValueFrom; ref == nil || !*ref.SecretKeyRef.Optional {
+		t.Errorf("WEBHOOK_SECRET = %+v", env["WEBHOOK_SECRET"])
+	}
+}
+
+func TestReconcileInvalidSpec(t *testing.T) {
+	app := newApp("monitor", devopsv1alpha1.DevOpsAppSpec{App: devopsv1alpha1.CostImpactMonitor, AutoApply: true})
+	r := newReconciler(t, app, newSecret("token"))
+
+	got := reconcileApp(t, r, "monitor")
+	if cond := readyCondition(t, got); cond.Reason != devopsv1alpha1.ReasonInvalidSpec {
+		t.Errorf("Ready reason = %s (%s), want InvalidSpec", cond.Reason, cond.Message)
+	}
+	var deployment appsv1.Deployment
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "monitor"}, &deployment)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("deployment created for an invalid spec: %v", err)
+	}
+}
+
+func TestReconcileWaitsForSecret(t *testing.T) {
+	app := newApp("drift", devopsv1alpha1.DevOpsAppSpec{App: devopsv1alpha1.DriftDetector})
+	r := newReconciler(t, app)
+
+	got := reconcileApp(t, r, "drift")
+	if cond := readyCondition(t, got); cond.Reason != devopsv1alpha1.ReasonSecretMissing {
+		t.Errorf("Ready reason = %s, want SecretMissing", cond.Reason)
+	}
+	var deployment appsv1.Deployment
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "drift"}, &deployment); !apierrors.IsNotFound(err) {
+		t.Errorf("deployment created without a secret: %v", err)
+	}
+
+	secret := newSecret("token")
+	if err := r.Create(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if requests := r.appsForSecret(context.Background(), secret); len(requests) != 1 || requests[0].Name != "drift" {
+		t.Errorf("secret maps to %v", requests)
+	}
+	reconcileApp(t, r, "drift")
+	getDeployment(t, r, "drift")
+}
+
+func TestSecretRotationRestartsApp(t *testing.T) {
+	secret := newSecret("old")
+	r := newReconciler(t, newApp("drift", devopsv1alpha1.DevOpsAppSpec{App: devopsv1alpha1.DriftDetector}), secret)
+	reconcileApp(t, r, "drift")
+	before := getDeployment(t, r, "drift").Spec.Template.Annotations[secretHashAnnotation]
+
+	secret.Data["cub-token"] = []byte("new")
+	if err := r.Update(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+	reconcileApp(t, r, "drift")
+	after := getDeployment(t, r, "drift").Spec.Template.Annotations[secretHashAnnotation]
+
+	if before == "" || before == after {
+		t.Errorf("secret hash %q -> %q, want a change", before, after)
+	}
+}
+
+func TestDeleteRemovesClusterRoleBinding(t *testing.T) {
+	r := newReconciler(t, newApp("drift", devopsv1alpha1.DevOpsAppSpec{App: devopsv1alpha1.DriftDetector}), newSecret("token"))
+	app := reconcileApp(t, r, "drift")
+
+	if err := r.Delete(context.Background(), app); err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: testNamespace, Name: "drift"}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	var binding rbacv1.ClusterRoleBinding
+	if err := r.Get(context.Background(), types.NamespacedName{Name: clusterRoleBindingName(app)}, &binding); !apierrors.IsNotFound(err) {
+		t.Errorf("cluster role binding still exists: %v", err)
+	}
+	if err := r.Get(context.Background(), key, app); !apierrors.IsNotFound(err) {
+		t.Errorf("DevOpsApp not released: %v", err)
+	}
+}
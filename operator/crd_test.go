@@ -0,0 +1,83 @@
+package operator
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	devopsv1alpha1 "github.com/monadic/devops-examples/operator/api/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// TestCRDMatchesTypes keeps the hand-maintained CRD in step with the Go
+// types and the app table
+func TestCRDMatchesTypes(t *testing.T) {
+	data, err := os.ReadFile("config/crd/devops.confighub.com_devopsapps.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var crd struct {
+		Spec struct {
+			Versions []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					OpenAPIV3Schema schema `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		t.Fatal(err)
+	}
+	version := crd.Spec.Versions[0]
+	if version.Name != devopsv1alpha1.GroupVersion.Version {
+		t.Errorf("CRD version %s, want %s", version.Name, devopsv1alpha1.GroupVersion.Version)
+	}
+	root := version.Schema.OpenAPIV3Schema.Properties
+
+	for field, typ := range map[string]reflect.Type{
+		"spec":   reflect.TypeOf(devopsv1alpha1.DevOpsAppSpec{}),
+		"status": reflect.TypeOf(devopsv1alpha1.DevOpsAppStatus{}),
+	} {
+		if got, want := keys(root[field].Properties), jsonFields(typ); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s properties = %v, want %v", field, got, want)
+		}
+	}
+
+	var names []string
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	enum := root["spec"].Properties["app"].Enum
+	sort.Strings(enum)
+	if !reflect.DeepEqual(enum, names) {
+		t.Errorf("spec.app enum = %v, want %v", enum, names)
+	}
+}
+
+type schema struct {
+	Properties map[string]schema `json:"properties"`
+	Enum       []string          `json:"enum"`
+}
+
+func keys(m map[string]schema) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func jsonFields(typ reflect.Type) []string {
+	var out []string
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,83 @@
+// Package operator runs the example apps from DevOpsApp custom resources.
+// Installing an app is a single apply:
+//
+//	apiVersion: devops.confighub.com/v1alpha1
+//	kind: DevOpsApp
+//	metadata:
+//	  name: drift
+//	spec:
+//	  app: drift-detector
+//	  space: acorn-bear-qa
+//	  interval: 5m
+//	  autoApply: false
+//	  secretRef:
+//	    name: devops-apps-secrets
+package operator
+
+import (
+	"flag"
+
+	"github.com/go-logr/logr"
+	devopsv1alpha1 "github.com/monadic/devops-examples/operator/api/v1alpha1"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// NewScheme returns a scheme with the Kubernetes and DevOpsApp types
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// Main runs the operator until it receives SIGTERM or SIGINT
+func Main() {
+	metricsAddr := flag.String("metrics-bind-address", ":8080", "address of the metrics endpoint")
+	probeAddr := flag.String("health-probe-bind-address", ":8081", "address of the health and readiness probes")
+	leaderElect := flag.Bool("leader-elect", false, "elect a leader so only one operator replica reconciles")
+	flag.Parse()
+
+	logger := logging.Setup("devops-operator")
+	ctrl.SetLogger(logr.FromSlogHandler(logger.Handler()))
+
+	scheme, err := NewScheme()
+	if err != nil {
+		logging.Fatal("Failed to build scheme", logging.Err(err))
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		Metrics:                metricsserver.Options{BindAddress: *metricsAddr},
+		HealthProbeBindAddress: *probeAddr,
+		LeaderElection:         *leaderElect,
+		LeaderElectionID:       "devops-operator.devops.confighub.com",
+	})
+	if err != nil {
+		logging.Fatal("Failed to create manager", logging.Err(err))
+	}
+
+	reconciler := &DevOpsAppReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
+		logging.Fatal("Failed to set up DevOpsApp controller", logging.Err(err))
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		logging.Fatal("Failed to add health check", logging.Err(err))
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		logging.Fatal("Failed to add readiness check", logging.Err(err))
+	}
+
+	logger.Info("Starting operator", "metrics", *metricsAddr, "probes", *probeAddr, "leader_elect", *leaderElect)
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		logging.Fatal("Operator stopped", logging.Err(err))
+	}
+}
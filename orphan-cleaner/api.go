@@ -0,0 +1,163 @@
+package orphancleaner
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/pending"
+)
+
+// Report lists the proposals, served at GET /api/v1/orphans
+type Report struct {
+	ScannedAt   time.Time      `json:"scanned_at"`
+	Error       string         `json:"error,omitempty"` // of the latest scan
+	Pending     int            `json:"pending"`
+	MonthlyCost float64        `json:"monthly_cost"` // of the pending orphans
+	ByReason    map[string]int `json:"by_reason"`    // of the pending orphans
+	Proposals   []Proposal     `json:"proposals"`
+}
+
+// handler serves the API under /api/v1, /metrics and /health
+func (c *Cleaner) handler() http.Handler {
+	mux := http.NewServeMux()
+	v1 := api.New(mux, "orphan-cleaner")
+	v1.HandleFunc("/orphans", c.handleOrphans, api.Operation{
+		Summary: "Cleanup proposals, most expensive first",
+		Query: []api.Param{
+			{Name: "status", Description: "pending (default), rejected, deleted, resolved or all"},
+			{Name: "reason", Description: "e.g. unused-pvc"},
+		},
+		Response: Report{},
+	})
+	v1.HandleFunc("/orphans/", c.handleDecision,
+		api.Operation{Method: http.MethodPost, Path: "/orphans/{slug}/approve", Summary: "Delete an orphan", Request: pending.Approval{}, Response: Proposal{}},
+		api.Operation{Method: http.MethodPost, Path: "/orphans/{slug}/reject", Summary: "Keep an orphan", Request: pending.Approval{}, Response: Proposal{}},
+	)
+	v1.Handle("/audit", c.audit, audit.Operations...)
+	v1.Handle("/breakers", breaker.Handler(c.cubBreaker), breaker.Operations...)
+	mux.Handle("/metrics", c.metrics)
+	mux.HandleFunc("/health", health.Live)
+	c.health.Mount(mux)
+	return c.audit.Calls(mux)
+}
+
+// handleOrphans lists the proposals, most expensive first: ?status=pending
+// (the default; "all" for every status) and ?reason=unused-pvc narrow it
+func (c *Cleaner) handleOrphans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status, reason := r.URL.Query().Get("status"), r.URL.Query().Get("reason")
+	if status == "" {
+		status = StatusPending
+	}
+	switch status {
+	case "all", StatusPending, StatusRejected, StatusDeleted, StatusResolved:
+	default:
+		http.Error(w, "status must be pending, rejected, deleted, resolved or all", http.StatusBadRequest)
+		return
+	}
+
+	report := c.Report(status, reason)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Report returns the last scan with the proposals of the given status ("all"
+// for every status) and, when not empty, reason, most expensive first
+func (c *Cleaner) Report(status, reason string) Report {
+	c.mu.RLock()
+	report := Report{ScannedAt: c.scannedAt, Error: c.scanError, ByReason: map[string]int{}, Proposals: []Proposal{}}
+	for _, p := range c.proposals {
+		if p.Status == StatusPending {
+			report.Pending++
+			report.MonthlyCost += p.MonthlyCost
+			for _, why := range p.Reasons {
+				report.ByReason[why]++
+			}
+		}
+		if (status == "all" || p.Status == status) && (reason == "" || containsString(p.Reasons, reason)) {
+			report.Proposals = append(report.Proposals, *p)
+		}
+	}
+	c.mu.RUnlock()
+	sort.Slice(report.Proposals, func(i, j int) bool {
+		a, b := report.Proposals[i], report.Proposals[j]
+		if a.MonthlyCost != b.MonthlyCost {
+			return a.MonthlyCost > b.MonthlyCost
+		}
+		return a.Slug < b.Slug
+	})
+	return report
+}
+
+// handleDecision serves POST /api/v1/orphans/{slug}/approve, which deletes the
+// resource, and POST /api/v1/orphans/{slug}/reject. Signed-in users decide as
+// themselves; otherwise the body names the approver:
+//
+//	{"approver": "alice@example.com", "note": "left over from the v1 migration"}
+func (c *Cleaner) handleDecision(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, api.Prefix+"/orphans/"), "/")
+	if len(parts) != 2 || (parts[1] != "approve" && parts[1] != "reject") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req pending.Approval
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if user := auth.FromContext(r.Context()); user != nil {
+		req.Approver = user.Email // signed-in users decide as themselves
+	}
+	if req.Approver == "" {
+		http.Error(w, "approver is required", http.StatusBadRequest)
+		return
+	}
+
+	approve := parts[1] == "approve"
+	p, err := c.decide(r.Context(), parts[0], approve, req.Approver, req.Note)
+	switch {
+	case errors.Is(err, errNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, errNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if approve {
+		c.audit.Record(audit.WithActor(r.Context(), req.Approver), audit.CleanupApplied, p.Resource(), req, err)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,10 @@
+// Command orphan-cleaner finds cluster resources no ConfigHub unit
+// describes, and unused volumes, Services and ConfigMaps, and proposes their
+// cleanup for approval. The same app runs as "devops-apps orphans".
+package main
+
+import orphancleaner "github.com/monadic/devops-examples/orphan-cleaner"
+
+func main() {
+	orphancleaner.Main()
+}
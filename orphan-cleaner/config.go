@@ -0,0 +1,117 @@
+package orphancleaner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Config holds the orphan cleaner's settings. They are read from
+// CONFIG_FILE (default /etc/orphan-cleaner/config.yaml); each can be
+// overridden by the environment variable in its env tag.
+type Config struct {
+	CubAPIURL   string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken    string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir  string `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Port        int    `yaml:"orphans_port" env:"ORPHANS_PORT"`
+	AuthConfig  string `yaml:"auth_config" env:"AUTH_CONFIG"`   // OIDC login for the API; a missing file leaves it open
+	ClusterName string `yaml:"cluster_name" env:"CLUSTER_NAME"` // cluster label of the /metrics samples
+
+	// Spaces whose units are the resources the cluster should have, and the
+	// namespace of units that don't name one
+	Spaces    []string `yaml:"cub_spaces" env:"CUB_SPACES"`
+	Namespace string   `yaml:"namespace" env:"NAMESPACE"`
+	// Namespaces scanned: watch_namespace (empty for all) but not exclude_namespaces
+	WatchNamespace    string   `yaml:"watch_namespace" env:"WATCH_NAMESPACE"`
+	ExcludeNamespaces []string `yaml:"exclude_namespaces" env:"EXCLUDE_NAMESPACES"`
+	// ConfigMaps no pod or pod template uses are stale once this old
+	StaleAfter time.Duration `yaml:"stale_after" env:"STALE_AFTER"`
+	// Monthly price of a LoadBalancer Service; workloads and volumes are
+	// priced at the pricinghints rates
+	LoadBalancerMonthly float64 `yaml:"load_balancer_monthly" env:"LOAD_BALANCER_MONTHLY"`
+
+	// Space the cleanup proposals are stored in as units, created when missing
+	ProposalsSpace string        `yaml:"proposals_space" env:"PROPOSALS_SPACE"`
+	AuditSpace     string        `yaml:"audit_space" env:"AUDIT_SPACE"` // where audit entries are written; empty keeps them in memory
+	RunInterval    time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:  "https://hub.confighub.com/api",
+		Port:       8088,
+		AuthConfig: "/etc/orphan-cleaner/auth.yaml",
+
+		Namespace:           "default",
+		ExcludeNamespaces:   []string{"kube-system", "kube-public", "kube-node-lease"},
+		StaleAfter:          30 * 24 * time.Hour,
+		LoadBalancerMonthly: 18,
+
+		ProposalsSpace: "orphan-cleaner",
+		RunInterval:    time.Hour,
+
+		CubRateLimit:     5,
+		CubRateBurst:     10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if len(c.Spaces) == 0 {
+		return fmt.Errorf("cub_spaces is required: without units every resource is an orphan")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.ProposalsSpace == "" {
+		return fmt.Errorf("proposals_space is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("orphans_port %d is not a valid port", c.Port)
+	}
+	if c.StaleAfter <= 0 {
+		return fmt.Errorf("stale_after must be positive, got %s", c.StaleAfter)
+	}
+	if c.LoadBalancerMonthly < 0 {
+		return fmt.Errorf("load_balancer_monthly must not be negative, got %g", c.LoadBalancerMonthly)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/orphan-cleaner/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
@@ -0,0 +1,286 @@
+// Package orphancleaner finds cluster resources no ConfigHub unit describes,
+// plus PersistentVolumeClaims no pod mounts, Services without endpoints and
+// ConfigMaps nothing uses, prices them, and proposes their cleanup. Each
+// proposal is a unit of the proposals space that an operator approves -
+// deleting the resource - or rejects.
+package orphancleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/client-go/kubernetes"
+)
+
+const version = "1.0.0"
+
+var (
+	errNotFound   = errors.New("no such proposal")
+	errNotPending = errors.New("proposal already decided")
+)
+
+type Cleaner struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	clientset  kubernetes.Interface
+	store      Store
+	delete     func(ctx context.Context, o Orphan) error
+	orphaned   func(ctx context.Context, o Orphan) (bool, error) // whether o is still an orphan
+	audit      *audit.Log
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu        sync.RWMutex
+	proposals map[string]*Proposal // by slug
+	unitIDs   map[string]uuid.UUID // of the stored proposals
+	deleting  map[string]bool      // slugs of the approved proposals being deleted
+	scannedAt time.Time
+	scanError string // of the latest scan, when it failed
+}
+
+// Main scans for orphans every run_interval until interrupted, or once with
+// --mode=job. It is the entry point of cmd/orphan-cleaner and of
+// "devops-apps orphans".
+func Main() {
+	logger := logging.Setup("orphan-cleaner")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "orphan-cleaner", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "orphan-cleaner",
+		Version:     version,
+		Description: "Finds cluster resources ConfigHub doesn't manage and proposes their cleanup",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	cubLimit := ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker)
+	cleaner := newCleaner(cfg)
+	cleaner.app = app
+	cleaner.clientset = app.K8s.Clientset
+	cleaner.delete = func(ctx context.Context, o Orphan) error { return deleteResource(ctx, app.K8s.Clientset, o) }
+	cleaner.audit = newAuditLog(app.Cub, cubLimit, cfg.AuditSpace)
+	cleaner.metrics = metrics.New("orphan-cleaner", version, cfg.ClusterName)
+	cleaner.cubLimit, cleaner.cubBreaker = cubLimit, cubBreaker
+	cleaner.metrics.Collect(metrics.Limiter(cubLimit))
+	cleaner.metrics.Collect(metrics.ListCache(listcache.Install()))
+	cleaner.metrics.Collect(metrics.Breakers(cubBreaker))
+	cleaner.health = health.New()
+	cleaner.health.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	cleaner.health.Register("confighub", health.Optional, health.Breaker(cubBreaker))
+	cleaner.metrics.Collect(cleaner.collect)
+
+	if err := cleaner.initialize(); err != nil {
+		logging.Fatal("Failed to initialize ConfigHub resources", logging.Err(err))
+	}
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, cleaner.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "orphan-cleaner", cleaner.scan, func() Report { return cleaner.Report(StatusPending, "") }, os.Stdout, store); err != nil {
+			logging.Fatal("Orphan scan failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Orphan cleaner API listening", "addr", addr)
+	group.Serve(addr, guard.Protect(cleaner.handler(), "/metrics", "/health"))
+	group.Go(cleaner.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Orphan cleaner API stopped", logging.Err(err))
+	}
+}
+
+func newCleaner(cfg Config) *Cleaner {
+	c := &Cleaner{config: cfg, proposals: map[string]*Proposal{}, unitIDs: map[string]uuid.UUID{}, deleting: map[string]bool{}}
+	c.orphaned = c.stillOrphan
+	return c
+}
+
+// initialize finds the proposals space, creating it when missing, and
+// reads the proposals already in it
+func (c *Cleaner) initialize() error {
+	ctx := context.Background()
+	spaces, err := ratelimit.Call(ctx, c.cubLimit, "ListSpaces", "all", c.app.Cub.ListSpaces)
+	if err != nil {
+		return fmt.Errorf("list spaces: %w", err)
+	}
+	for _, s := range spaces {
+		if s.Slug == c.config.ProposalsSpace {
+			c.store = cubStore{cub: c.app.Cub, limit: c.cubLimit, space: s.SpaceID}
+			slog.Info("Using existing proposals space", logging.Space(s.Slug), "space_id", s.SpaceID)
+			return c.loadProposals(ctx)
+		}
+	}
+
+	space, err := c.app.Cub.CreateSpace(sdk.CreateSpaceRequest{
+		Slug:        c.config.ProposalsSpace,
+		DisplayName: "Orphan Cleanup Proposals",
+		Labels: map[string]string{
+			"app":  "orphan-cleaner",
+			"team": "platform",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create space: %w", err)
+	}
+	c.store = cubStore{cub: c.app.Cub, limit: c.cubLimit, space: space.SpaceID}
+	slog.Info("Created proposals space", logging.Space(space.Slug), "space_id", space.SpaceID)
+	return nil
+}
+
+// run scans now and every run_interval until interrupted
+func (c *Cleaner) run(ctx context.Context) error {
+	ticker := time.NewTicker(c.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.scan(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Orphan scan failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan finds the orphans of the cluster and updates the proposals. A scan
+// that can't read the units or the cluster changes nothing, so no resource
+// is proposed for lack of its unit.
+func (c *Cleaner) scan(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "orphans.scan")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := c.metrics.Cycle("scan", "")
+	defer func() { cycleDone(err) }()
+	defer func() {
+		c.mu.Lock()
+		c.scanError = ""
+		if err != nil {
+			c.scanError = err.Error()
+		}
+		c.mu.Unlock()
+	}()
+
+	managed, err := c.managedResources(ctx)
+	if err != nil {
+		return err
+	}
+	inv, err := readInventory(ctx, c.clientset, c.config)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	orphans := findOrphans(inv, managed, c.config, now)
+	written, err := c.propose(ctx, orphans, now)
+	c.mu.Lock()
+	c.scannedAt = now
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	slog.Info("Scanned for orphans", "orphans", len(orphans), "proposals_written", written)
+	return nil
+}
+
+// managedResources returns the Kind/namespace/name of every unit of the
+// configured spaces
+func (c *Cleaner) managedResources(ctx context.Context) (map[string]bool, error) {
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, c.cubLimit, "ListSpaces", "all", c.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list spaces: %w", err)
+	}
+	ids := map[string]uuid.UUID{}
+	for _, s := range spaces {
+		ids[s.Slug] = s.SpaceID
+	}
+
+	managed := map[string]bool{}
+	for _, slug := range c.config.Spaces {
+		id, ok := ids[slug]
+		if !ok {
+			return nil, fmt.Errorf("space %s not found", slug)
+		}
+		units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, c.cubLimit, "ListUnits", id.String(), func() ([]*sdk.Unit, error) {
+				return c.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: id})
+			})
+		}, tracing.SpaceKey.String(slug))
+		if err != nil {
+			return nil, fmt.Errorf("list units of %s: %w", slug, err)
+		}
+		for _, u := range units {
+			if key, ok := unitResource(u.Data, c.config.Namespace); ok {
+				managed[key] = true
+			}
+		}
+	}
+	return managed, nil
+}
+
+// collect exports the pending proposals and what they would save
+func (c *Cleaner) collect(emit metrics.Emit) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	byStatus := map[string]int{StatusPending: 0, StatusRejected: 0, StatusDeleted: 0, StatusResolved: 0}
+	savings := 0.0
+	for _, p := range c.proposals {
+		byStatus[p.Status]++
+		if p.Status == StatusPending {
+			savings += p.MonthlyCost
+		}
+	}
+	for status, n := range byStatus {
+		emit("orphan_proposals", "Cleanup proposals by status.", "gauge", metrics.Labels{"status": status}, float64(n))
+	}
+	emit("orphan_monthly_cost_dollars", "Estimated monthly cost of the orphans pending cleanup.", "gauge", nil, savings)
+}
@@ -0,0 +1,357 @@
+package orphancleaner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/pricinghints"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Why a resource is an orphan
+const (
+	ReasonNoUnit         = "no-unit"         // no ConfigHub unit describes it
+	ReasonUnusedPVC      = "unused-pvc"      // no pod mounts the claim
+	ReasonNoEndpoints    = "no-endpoints"    // the Service selects no pod
+	ReasonStaleConfigMap = "stale-configmap" // nothing uses the ConfigMap and it is older than stale_after
+)
+
+// Orphan is a cluster resource that looks safe to remove
+type Orphan struct {
+	Kind        string    `json:"kind"`
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	Reasons     []string  `json:"reasons"`
+	Created     time.Time `json:"created"`
+	MonthlyCost float64   `json:"monthly_cost"`
+	Command     string    `json:"command"` // removes it by hand
+}
+
+// Resource names the orphan as Kind/namespace/name
+func (o Orphan) Resource() string {
+	return resourceKey(o.Kind, o.Namespace, o.Name)
+}
+
+func resourceKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// inventory is what a scan reads from the cluster
+type inventory struct {
+	Deployments  []appsv1.Deployment
+	StatefulSets []appsv1.StatefulSet
+	DaemonSets   []appsv1.DaemonSet
+	CronJobs     []batchv1.CronJob
+	Services     []corev1.Service
+	Endpoints    []corev1.Endpoints
+	ConfigMaps   []corev1.ConfigMap
+	PVCs         []corev1.PersistentVolumeClaim
+	Pods         []corev1.Pod
+}
+
+// readInventory lists the resources of watch_namespace, or of every
+// namespace, leaving out exclude_namespaces
+func readInventory(ctx context.Context, clientset kubernetes.Interface, cfg Config) (*inventory, error) {
+	ns, list := cfg.WatchNamespace, metav1.ListOptions{}
+	inv := &inventory{}
+
+	deployments, err := clientset.AppsV1().Deployments(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	statefulSets, err := clientset.AppsV1().StatefulSets(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	daemonSets, err := clientset.AppsV1().DaemonSets(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list daemonsets: %w", err)
+	}
+	cronJobs, err := clientset.BatchV1().CronJobs(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list cronjobs: %w", err)
+	}
+	services, err := clientset.CoreV1().Services(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+	endpoints, err := clientset.CoreV1().Endpoints(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list endpoints: %w", err)
+	}
+	configMaps, err := clientset.CoreV1().ConfigMaps(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list configmaps: %w", err)
+	}
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list persistentvolumeclaims: %w", err)
+	}
+	pods, err := clientset.CoreV1().Pods(ns).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+
+	excluded := map[string]bool{}
+	for _, n := range cfg.ExcludeNamespaces {
+		excluded[n] = true
+	}
+	keep := func(meta metav1.ObjectMeta) bool { return !excluded[meta.Namespace] }
+	for _, d := range deployments.Items {
+		if keep(d.ObjectMeta) {
+			inv.Deployments = append(inv.Deployments, d)
+		}
+	}
+	for _, s := range statefulSets.Items {
+		if keep(s.ObjectMeta) {
+			inv.StatefulSets = append(inv.StatefulSets, s)
+		}
+	}
+	for _, d := range daemonSets.Items {
+		if keep(d.ObjectMeta) {
+			inv.DaemonSets = append(inv.DaemonSets, d)
+		}
+	}
+	for _, c := range cronJobs.Items {
+		if keep(c.ObjectMeta) {
+			inv.CronJobs = append(inv.CronJobs, c)
+		}
+	}
+	for _, s := range services.Items {
+		if keep(s.ObjectMeta) {
+			inv.Services = append(inv.Services, s)
+		}
+	}
+	for _, e := range endpoints.Items {
+		if keep(e.ObjectMeta) {
+			inv.Endpoints = append(inv.Endpoints, e)
+		}
+	}
+	for _, c := range configMaps.Items {
+		if keep(c.ObjectMeta) {
+			inv.ConfigMaps = append(inv.ConfigMaps, c)
+		}
+	}
+	for _, p := range pvcs.Items {
+		if keep(p.ObjectMeta) {
+			inv.PVCs = append(inv.PVCs, p)
+		}
+	}
+	for _, p := range pods.Items {
+		if keep(p.ObjectMeta) {
+			inv.Pods = append(inv.Pods, p)
+		}
+	}
+	return inv, nil
+}
+
+// unitResource returns the Kind/namespace/name a unit's YAML or JSON
+// describes, false when it names none
+func unitResource(data, defaultNamespace string) (string, bool) {
+	var meta struct {
+		metav1.TypeMeta   `json:",inline"`
+		metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(data), &meta); err != nil || meta.Kind == "" || meta.Name == "" {
+		return "", false
+	}
+	namespace := meta.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	return resourceKey(meta.Kind, namespace, meta.Name), true
+}
+
+// podTemplate is a pod spec and the namespace its pods run in
+type podTemplate struct {
+	namespace string
+	spec      *corev1.PodSpec
+}
+
+// findOrphans returns the resources of inv that look safe to remove, most
+// expensive first. managed holds the Kind/namespace/name of every unit.
+// Resources owned by another object are left to their owner.
+func findOrphans(inv *inventory, managed map[string]bool, cfg Config, now time.Time) []Orphan {
+	found := map[string]*Orphan{}
+	flag := func(kind string, meta metav1.ObjectMeta, reason string, cost float64) {
+		if len(meta.OwnerReferences) > 0 {
+			return
+		}
+		key := resourceKey(kind, meta.Namespace, meta.Name)
+		o, ok := found[key]
+		if !ok {
+			o = &Orphan{
+				Kind: kind, Namespace: meta.Namespace, Name: meta.Name, Created: meta.CreationTimestamp.Time, MonthlyCost: cost,
+				Command: fmt.Sprintf("kubectl delete %s %s -n %s", strings.ToLower(kind), meta.Name, meta.Namespace),
+			}
+			found[key] = o
+		}
+		o.Reasons = append(o.Reasons, reason)
+	}
+	unmanaged := func(kind string, meta metav1.ObjectMeta, cost float64) {
+		if !managed[resourceKey(kind, meta.Namespace, meta.Name)] {
+			flag(kind, meta, ReasonNoUnit, cost)
+		}
+	}
+
+	// What the pods and pod templates use
+	claims, configMaps := map[string]bool{}, map[string]bool{}
+	var templates []podTemplate
+	for i := range inv.Pods {
+		p := &inv.Pods[i]
+		templates = append(templates, podTemplate{p.Namespace, &p.Spec})
+	}
+
+	for i := range inv.Deployments {
+		d := &inv.Deployments[i]
+		templates = append(templates, podTemplate{d.Namespace, &d.Spec.Template.Spec})
+		unmanaged("Deployment", d.ObjectMeta, podCost(&d.Spec.Template.Spec, replicas(d.Spec.Replicas)))
+	}
+	for i := range inv.StatefulSets {
+		s := &inv.StatefulSets[i]
+		templates = append(templates, podTemplate{s.Namespace, &s.Spec.Template.Spec})
+		unmanaged("StatefulSet", s.ObjectMeta, podCost(&s.Spec.Template.Spec, replicas(s.Spec.Replicas)))
+	}
+	for i := range inv.DaemonSets {
+		d := &inv.DaemonSets[i]
+		templates = append(templates, podTemplate{d.Namespace, &d.Spec.Template.Spec})
+		unmanaged("DaemonSet", d.ObjectMeta, podCost(&d.Spec.Template.Spec, int(d.Status.DesiredNumberScheduled)))
+	}
+	for i := range inv.CronJobs {
+		c := &inv.CronJobs[i]
+		templates = append(templates, podTemplate{c.Namespace, &c.Spec.JobTemplate.Spec.Template.Spec})
+		unmanaged("CronJob", c.ObjectMeta, 0) // runs now and then; not priced
+	}
+	for _, t := range templates {
+		for _, name := range usedConfigMaps(t.spec) {
+			configMaps[t.namespace+"/"+name] = true
+		}
+	}
+	for i := range inv.Pods {
+		p := &inv.Pods[i]
+		for _, v := range p.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				claims[p.Namespace+"/"+v.PersistentVolumeClaim.ClaimName] = true
+			}
+		}
+	}
+
+	endpoints := map[string]bool{} // services with an address, ready or not
+	for _, e := range inv.Endpoints {
+		for _, s := range e.Subsets {
+			if len(s.Addresses)+len(s.NotReadyAddresses) > 0 {
+				endpoints[e.Namespace+"/"+e.Name] = true
+			}
+		}
+	}
+	for _, s := range inv.Services {
+		if s.Namespace == "default" && s.Name == "kubernetes" {
+			continue
+		}
+		cost := 0.0
+		if s.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			cost = cfg.LoadBalancerMonthly
+		}
+		unmanaged("Service", s.ObjectMeta, cost)
+		if len(s.Spec.Selector) > 0 && s.Spec.Type != corev1.ServiceTypeExternalName && !endpoints[s.Namespace+"/"+s.Name] {
+			flag("Service", s.ObjectMeta, ReasonNoEndpoints, cost)
+		}
+	}
+
+	for _, c := range inv.ConfigMaps {
+		if c.Name == "kube-root-ca.crt" {
+			continue // published into every namespace by the control plane
+		}
+		unmanaged("ConfigMap", c.ObjectMeta, 0)
+		if !configMaps[c.Namespace+"/"+c.Name] && now.Sub(c.CreationTimestamp.Time) > cfg.StaleAfter {
+			flag("ConfigMap", c.ObjectMeta, ReasonStaleConfigMap, 0)
+		}
+	}
+
+	for _, p := range inv.PVCs {
+		cost := 0.0
+		if storage, ok := p.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			cost = pricinghints.Hints{StorageGB: storage.AsApproximateFloat64() / (1 << 30), Replicas: 1}.MonthlyCost(pricinghints.DefaultRates)
+		}
+		unmanaged("PersistentVolumeClaim", p.ObjectMeta, cost)
+		if !claims[p.Namespace+"/"+p.Name] {
+			flag("PersistentVolumeClaim", p.ObjectMeta, ReasonUnusedPVC, cost)
+		}
+	}
+
+	orphans := make([]Orphan, 0, len(found))
+	for _, o := range found {
+		orphans = append(orphans, *o)
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].MonthlyCost != orphans[j].MonthlyCost {
+			return orphans[i].MonthlyCost > orphans[j].MonthlyCost
+		}
+		return orphans[i].Resource() < orphans[j].Resource()
+	})
+	return orphans
+}
+
+// usedConfigMaps returns the ConfigMaps a pod template mounts or reads
+// environment variables from
+func usedConfigMaps(spec *corev1.PodSpec) []string {
+	var names []string
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			names = append(names, v.ConfigMap.Name)
+		}
+		if v.Projected != nil {
+			for _, s := range v.Projected.Sources {
+				if s.ConfigMap != nil {
+					names = append(names, s.ConfigMap.Name)
+				}
+			}
+		}
+	}
+	for _, c := range append(append([]corev1.Container(nil), spec.InitContainers...), spec.Containers...) {
+		for _, from := range c.EnvFrom {
+			if from.ConfigMapRef != nil {
+				names = append(names, from.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				names = append(names, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+	return names
+}
+
+// podCost prices n replicas of a pod template by its containers' requests
+func podCost(spec *corev1.PodSpec, n int) float64 {
+	h := pricinghints.Hints{Replicas: n}
+	for _, c := range spec.Containers {
+		if q, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+			h.CPUCores += q.AsApproximateFloat64()
+		}
+		if q, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+			h.MemoryGB += q.AsApproximateFloat64() / (1 << 30)
+		}
+		if q, ok := c.Resources.Limits["nvidia.com/gpu"]; ok {
+			h.GPUs += int(q.Value())
+		}
+	}
+	return h.MonthlyCost(pricinghints.DefaultRates)
+}
+
+// replicas is a workload's replica count, 1 when unset
+func replicas(n *int32) int {
+	if n == nil {
+		return 1
+	}
+	return int(*n)
+}
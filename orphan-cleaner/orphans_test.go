@@ -0,0 +1,130 @@
+package orphancleaner
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var now = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func meta(namespace, name string, age time.Duration) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))}
+}
+
+func deployment(name string, replicas int32, cpu, memory string, configMap string) appsv1.Deployment {
+	requests := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)}
+	d := appsv1.Deployment{ObjectMeta: meta("shop", name, time.Hour), Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+	d.Spec.Template.Spec.Containers = []corev1.Container{{Name: name, Resources: corev1.ResourceRequirements{Requests: requests}}}
+	if configMap != "" {
+		d.Spec.Template.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMap}}}}
+	}
+	return d
+}
+
+func TestFindOrphans(t *testing.T) {
+	storage := resource.MustParse("100Gi")
+	inv := &inventory{
+		Deployments: []appsv1.Deployment{
+			deployment("web", 2, "500m", "1Gi", "web-config"),
+			deployment("legacy-api", 3, "1", "2Gi", ""),
+		},
+		Services: []corev1.Service{
+			{ObjectMeta: meta("shop", "web", time.Hour), Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "web"}}},
+			{ObjectMeta: meta("shop", "old-lb", time.Hour), Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Selector: map[string]string{"app": "old"}}},
+			{ObjectMeta: meta("default", "kubernetes", time.Hour)},
+		},
+		Endpoints: []corev1.Endpoints{
+			{ObjectMeta: meta("shop", "web", time.Hour), Subsets: []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}}},
+		},
+		ConfigMaps: []corev1.ConfigMap{
+			{ObjectMeta: meta("shop", "web-config", 90*24*time.Hour)},
+			{ObjectMeta: meta("shop", "feature-toggles-2024", 90*24*time.Hour)},
+			{ObjectMeta: meta("shop", "fresh", time.Hour)},
+			{ObjectMeta: meta("shop", "kube-root-ca.crt", 90*24*time.Hour)},
+		},
+		PVCs: []corev1.PersistentVolumeClaim{
+			{ObjectMeta: meta("shop", "data-web", time.Hour)},
+			{ObjectMeta: meta("shop", "data-old", time.Hour), Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: storage}},
+			}},
+		},
+		Pods: []corev1.Pod{{ObjectMeta: meta("shop", "web-1", time.Hour), Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+			{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data-web"}}},
+		}}}},
+	}
+	owned := meta("shop", "generated", 90*24*time.Hour)
+	owned.OwnerReferences = []metav1.OwnerReference{{Kind: "Certificate", Name: "web"}}
+	inv.ConfigMaps = append(inv.ConfigMaps, corev1.ConfigMap{ObjectMeta: owned})
+
+	managed := map[string]bool{}
+	for _, r := range []string{"Deployment/shop/web", "Service/shop/web", "Service/shop/old-lb", "ConfigMap/shop/web-config",
+		"ConfigMap/shop/feature-toggles-2024", "ConfigMap/shop/fresh", "PersistentVolumeClaim/shop/data-web", "PersistentVolumeClaim/shop/data-old"} {
+		managed[r] = true
+	}
+
+	var got []string
+	costs := map[string]float64{}
+	for _, o := range findOrphans(inv, managed, DefaultConfig(), now) {
+		got = append(got, o.Resource()+" "+strings.Join(o.Reasons, ","))
+		costs[o.Resource()] = o.MonthlyCost
+	}
+	want := []string{
+		"Deployment/shop/legacy-api no-unit",
+		"Service/shop/old-lb no-endpoints",
+		"PersistentVolumeClaim/shop/data-old unused-pvc",
+		"ConfigMap/shop/feature-toggles-2024 stale-configmap",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("orphans =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for resource, want := range map[string]float64{
+		"Deployment/shop/legacy-api":          3 * (0.024 + 2*0.006) * 720,
+		"Service/shop/old-lb":                 18,
+		"PersistentVolumeClaim/shop/data-old": 10,
+	} {
+		if math.Abs(costs[resource]-want) > 0.001 {
+			t.Errorf("%s costs %.2f, want %.2f", resource, costs[resource], want)
+		}
+	}
+}
+
+func TestUnitResource(t *testing.T) {
+	for _, tc := range []struct {
+		data, want string
+	}{
+		{data: "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: shop\n", want: "Service/shop/web"},
+		{data: `{"kind": "ConfigMap", "metadata": {"name": "web-config"}}`, want: "ConfigMap/default/web-config"},
+		{data: "cost: 12\n"},
+		{data: "kind: ["},
+	} {
+		got, ok := unitResource(tc.data, "default")
+		if got != tc.want || ok != (tc.want != "") {
+			t.Errorf("unitResource(%q) = %q, %v; want %q", tc.data, got, ok, tc.want)
+		}
+	}
+}
+
+func TestReadInventory(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.ConfigMap{ObjectMeta: meta("shop", "web-config", time.Hour)},
+		&corev1.ConfigMap{ObjectMeta: meta("kube-system", "coredns", time.Hour)},
+		&corev1.PersistentVolumeClaim{ObjectMeta: meta("shop", "data", time.Hour)},
+	)
+	inv, err := readInventory(context.Background(), clientset, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.ConfigMaps) != 1 || inv.ConfigMaps[0].Name != "web-config" || len(inv.PVCs) != 1 {
+		t.Errorf("inventory = %+v, want kube-system left out", inv)
+	}
+}
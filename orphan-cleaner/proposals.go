@@ -0,0 +1,289 @@
+package orphancleaner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ProposalType is the "type" label of the units holding cleanup proposals
+const ProposalType = "cleanup-proposal"
+
+// Statuses of a proposal, in its unit's "status" label
+const (
+	StatusPending  = "pending"  // waiting for approval
+	StatusRejected = "rejected" // kept; not proposed again
+	StatusDeleted  = "deleted"  // approved and removed from the cluster
+	StatusResolved = "resolved" // no longer an orphan, or removed by someone else
+)
+
+// Proposal is the cleanup of one orphan, decided by an operator
+type Proposal struct {
+	Orphan
+	Slug       string     `json:"slug"` // of its unit, and its ID in the API
+	Status     string     `json:"status"`
+	ProposedAt time.Time  `json:"proposed_at"`
+	DecidedBy  string     `json:"decided_by,omitempty"`
+	DecidedAt  *time.Time `json:"decided_at,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	Error      string     `json:"error,omitempty"` // of the last failed deletion
+}
+
+// Store keeps one unit per proposal in the proposals space, so decisions
+// survive restarts and have ConfigHub's history
+type Store interface {
+	// Units returns the proposal units by slug
+	Units(ctx context.Context) (map[string]StoredUnit, error)
+	Create(ctx context.Context, slug string, labels map[string]string, data string) (uuid.UUID, error)
+	Update(ctx context.Context, id uuid.UUID, labels map[string]string, data string) error
+}
+
+// StoredUnit is a proposal unit in ConfigHub
+type StoredUnit struct {
+	ID     uuid.UUID
+	Labels map[string]string
+	Data   string
+}
+
+// proposalSlug is the slug of an orphan's proposal unit
+func proposalSlug(o Orphan) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, fmt.Sprintf("cleanup-%s-%s-%s", o.Kind, o.Namespace, o.Name))
+}
+
+// loadProposals reads the stored proposals; called once, before the first scan
+func (c *Cleaner) loadProposals(ctx context.Context) error {
+	units, err := c.store.Units(ctx)
+	if err != nil {
+		return fmt.Errorf("list proposal units: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for slug, u := range units {
+		var p Proposal
+		if err := json.Unmarshal([]byte(u.Data), &p); err != nil {
+			return fmt.Errorf("read proposal %s: %w", slug, err)
+		}
+		c.proposals[slug] = &p
+		c.unitIDs[slug] = u.ID
+	}
+	return nil
+}
+
+// propose brings the proposals in line with the orphans of a scan: new
+// orphans get a pending proposal, pending ones whose resource is no longer
+// an orphan are resolved, and rejected ones are left alone. It returns how
+// many proposals were written.
+func (c *Cleaner) propose(ctx context.Context, orphans []Orphan, now time.Time) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var changed []*Proposal
+	seen := map[string]bool{}
+	for _, o := range orphans {
+		slug := proposalSlug(o)
+		seen[slug] = true
+		p, ok := c.proposals[slug]
+		switch {
+		case !ok, p.Status == StatusDeleted, p.Status == StatusResolved:
+			// new, recreated after a deletion, or an orphan again
+			p = &Proposal{Orphan: o, Slug: slug, Status: StatusPending, ProposedAt: now}
+			c.proposals[slug] = p
+		case p.Status == StatusPending && !sameOrphan(p.Orphan, o):
+			p.Orphan = o
+		default:
+			continue
+		}
+		changed = append(changed, p)
+	}
+	for slug, p := range c.proposals {
+		if !seen[slug] && p.Status == StatusPending {
+			p.Status, p.DecidedAt = StatusResolved, &now
+			changed = append(changed, p)
+		}
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Slug < changed[j].Slug })
+	for i, p := range changed {
+		if err := c.save(ctx, p); err != nil {
+			return i, err
+		}
+	}
+	return len(changed), nil
+}
+
+// sameOrphan reports whether a scan found nothing new about an orphan
+func sameOrphan(a, b Orphan) bool {
+	return a.MonthlyCost == b.MonthlyCost && strings.Join(a.Reasons, ",") == strings.Join(b.Reasons, ",")
+}
+
+// decide approves or rejects the pending proposal with slug. Approving
+// checks the resource is still an orphan and deletes it, outside the lock;
+// it may already be gone. When the resource is no longer an orphan the
+// proposal is resolved, and when the deletion fails it stays pending.
+func (c *Cleaner) decide(ctx context.Context, slug string, approve bool, actor, note string) (Proposal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.proposals[slug]
+	if !ok {
+		return Proposal{}, errNotFound
+	}
+	if p.Status != StatusPending {
+		return *p, fmt.Errorf("%w: %s is %s", errNotPending, slug, p.Status)
+	}
+	if c.deleting[slug] {
+		return *p, fmt.Errorf("%w: %s is being deleted", errNotPending, slug)
+	}
+
+	now := time.Now()
+	if approve {
+		o := p.Orphan
+		c.deleting[slug] = true
+		c.mu.Unlock()
+		orphan, err := c.orphaned(ctx, o)
+		if err == nil && orphan {
+			if err = c.delete(ctx, o); apierrors.IsNotFound(err) {
+				err = nil
+			}
+		}
+		c.mu.Lock()
+		delete(c.deleting, slug)
+		now = time.Now()
+
+		switch {
+		case err != nil:
+			p.Error = err.Error()
+			c.save(ctx, p)
+			return *p, fmt.Errorf("delete %s: %w", o.Resource(), err)
+		case !orphan:
+			p.Status, p.DecidedAt, p.Error = StatusResolved, &now, ""
+			c.save(ctx, p)
+			return *p, fmt.Errorf("%w: %s is no longer an orphan", errNotPending, o.Resource())
+		}
+		p.Status, p.Error = StatusDeleted, ""
+	} else {
+		p.Status = StatusRejected
+	}
+	p.DecidedBy, p.DecidedAt, p.Note = actor, &now, note
+	return *p, c.save(ctx, p)
+}
+
+// stillOrphan reports whether o is an orphan of the cluster as it is now,
+// rather than at the last scan
+func (c *Cleaner) stillOrphan(ctx context.Context, o Orphan) (bool, error) {
+	managed, err := c.managedResources(ctx)
+	if err != nil {
+		return false, err
+	}
+	inv, err := readInventory(ctx, c.clientset, c.config)
+	if err != nil {
+		return false, err
+	}
+	for _, found := range findOrphans(inv, managed, c.config, time.Now()) {
+		if found.Resource() == o.Resource() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// save writes a proposal's unit
+func (c *Cleaner) save(ctx context.Context, p *Proposal) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	labels := map[string]string{
+		"type":      ProposalType,
+		"status":    p.Status,
+		"kind":      strings.ToLower(p.Kind),
+		"namespace": p.Namespace,
+	}
+	if id, ok := c.unitIDs[p.Slug]; ok {
+		err = c.store.Update(ctx, id, labels, string(data))
+	} else {
+		var id uuid.UUID
+		if id, err = c.store.Create(ctx, p.Slug, labels, string(data)); err == nil {
+			c.unitIDs[p.Slug] = id
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("write proposal %s: %w", p.Slug, err)
+	}
+	return nil
+}
+
+// deleteResource removes an orphan from the cluster, its dependents with it
+func deleteResource(ctx context.Context, clientset kubernetes.Interface, o Orphan) error {
+	background := metav1.DeletePropagationBackground
+	opts := metav1.DeleteOptions{PropagationPolicy: &background}
+	switch o.Kind {
+	case "Deployment":
+		return clientset.AppsV1().Deployments(o.Namespace).Delete(ctx, o.Name, opts)
+	case "StatefulSet":
+		return clientset.AppsV1().StatefulSets(o.Namespace).Delete(ctx, o.Name, opts)
+	case "DaemonSet":
+		return clientset.AppsV1().DaemonSets(o.Namespace).Delete(ctx, o.Name, opts)
+	case "CronJob":
+		return clientset.BatchV1().CronJobs(o.Namespace).Delete(ctx, o.Name, opts)
+	case "Service":
+		return clientset.CoreV1().Services(o.Namespace).Delete(ctx, o.Name, opts)
+	case "ConfigMap":
+		return clientset.CoreV1().ConfigMaps(o.Namespace).Delete(ctx, o.Name, opts)
+	case "PersistentVolumeClaim":
+		return clientset.CoreV1().PersistentVolumeClaims(o.Namespace).Delete(ctx, o.Name, opts)
+	}
+	return fmt.Errorf("cannot delete kind %s", o.Kind)
+}
+
+// cubStore is a Store in the ConfigHub space with ID space
+type cubStore struct {
+	cub   *sdk.ConfigHubClient
+	limit *ratelimit.Limiter
+	space uuid.UUID
+}
+
+func (s cubStore) Units(ctx context.Context) (map[string]StoredUnit, error) {
+	where := fmt.Sprintf("Labels['type'] = '%s'", ProposalType)
+	units, err := ratelimit.Call(ctx, s.limit, "ListUnits", s.space.String()+"/"+where, func() ([]*sdk.Unit, error) {
+		return s.cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.space, Where: where})
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]StoredUnit, len(units))
+	for _, u := range units {
+		stored[u.Slug] = StoredUnit{ID: u.UnitID, Labels: u.Labels, Data: u.Data}
+	}
+	return stored, nil
+}
+
+func (s cubStore) Create(ctx context.Context, slug string, labels map[string]string, data string) (uuid.UUID, error) {
+	u, err := s.cub.CreateUnit(s.space, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return u.UnitID, nil
+}
+
+func (s cubStore) Update(ctx context.Context, id uuid.UUID, labels map[string]string, data string) error {
+	_, err := s.cub.UpdateUnit(s.space, id, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+	return err
+}
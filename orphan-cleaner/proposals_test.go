@@ -0,0 +1,185 @@
+package orphancleaner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/metrics"
+)
+
+// memoryStore is a Store in memory
+type memoryStore struct {
+	units  map[string]StoredUnit
+	writes []string // "slug=status"
+}
+
+func (s *memoryStore) Units(context.Context) (map[string]StoredUnit, error) {
+	return s.units, nil
+}
+
+func (s *memoryStore) Create(_ context.Context, slug string, labels map[string]string, data string) (uuid.UUID, error) {
+	id := uuid.New()
+	s.units[slug] = StoredUnit{ID: id, Labels: labels, Data: data}
+	s.writes = append(s.writes, slug+"="+labels["status"])
+	return id, nil
+}
+
+func (s *memoryStore) Update(_ context.Context, id uuid.UUID, labels map[string]string, data string) error {
+	for slug, u := range s.units {
+		if u.ID == id {
+			s.units[slug] = StoredUnit{ID: id, Labels: labels, Data: data}
+			s.writes = append(s.writes, slug+"="+labels["status"])
+			return nil
+		}
+	}
+	return fmt.Errorf("unit %s not found", id)
+}
+
+func newTestCleaner(store *memoryStore) (*Cleaner, *[]string) {
+	var deleted []string
+	c := newCleaner(DefaultConfig())
+	c.store = store
+	c.delete = func(_ context.Context, o Orphan) error {
+		if o.Name == "protected" {
+			return errors.New("forbidden")
+		}
+		deleted = append(deleted, o.Resource())
+		return nil
+	}
+	c.orphaned = func(_ context.Context, o Orphan) (bool, error) { return o.Name != "adopted", nil }
+	c.audit = audit.New("orphan-cleaner", nil, nil)
+	c.metrics = metrics.New("orphan-cleaner", version, "test")
+	return c, &deleted
+}
+
+func orphan(kind, name string, cost float64, reasons ...string) Orphan {
+	return Orphan{Kind: kind, Namespace: "shop", Name: name, Reasons: reasons, MonthlyCost: cost}
+}
+
+func TestPropose(t *testing.T) {
+	store := &memoryStore{units: map[string]StoredUnit{}}
+	c, _ := newTestCleaner(store)
+	ctx := context.Background()
+	scan := func(orphans ...Orphan) []string {
+		t.Helper()
+		store.writes = nil
+		if _, err := c.propose(ctx, orphans, now); err != nil {
+			t.Fatal(err)
+		}
+		return store.writes
+	}
+
+	pvc, lb := orphan("PersistentVolumeClaim", "data-old", 10, ReasonUnusedPVC), orphan("Service", "old-lb", 18, ReasonNoUnit)
+	if got := scan(pvc, lb); !reflect.DeepEqual(got, []string{"cleanup-persistentvolumeclaim-shop-data-old=pending", "cleanup-service-shop-old-lb=pending"}) {
+		t.Errorf("first scan wrote %v", got)
+	}
+	if got := scan(pvc, lb); len(got) != 0 {
+		t.Errorf("unchanged scan wrote %v", got)
+	}
+
+	lb.Reasons = append(lb.Reasons, ReasonNoEndpoints)
+	if got := scan(lb); !reflect.DeepEqual(got, []string{"cleanup-persistentvolumeclaim-shop-data-old=resolved", "cleanup-service-shop-old-lb=pending"}) {
+		t.Errorf("scan without the claim wrote %v", got)
+	}
+	if _, err := c.decide(ctx, "cleanup-service-shop-old-lb", false, "alice@example.com", "still migrating"); err != nil {
+		t.Fatal(err)
+	}
+	if got := scan(pvc, lb); !reflect.DeepEqual(got, []string{"cleanup-persistentvolumeclaim-shop-data-old=pending"}) {
+		t.Errorf("scan after the rejection wrote %v; want the claim reopened and the rejection kept", got)
+	}
+
+	// a restarted cleaner reads the decisions back
+	restarted, _ := newTestCleaner(store)
+	if err := restarted.loadProposals(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p := restarted.proposals["cleanup-service-shop-old-lb"]; p == nil || p.Status != StatusRejected || p.DecidedBy != "alice@example.com" {
+		t.Errorf("reloaded proposal = %+v", p)
+	}
+}
+
+func TestDecisionAPI(t *testing.T) {
+	store := &memoryStore{units: map[string]StoredUnit{}}
+	c, deleted := newTestCleaner(store)
+	if _, err := c.propose(context.Background(), []Orphan{
+		orphan("PersistentVolumeClaim", "data-old", 10, ReasonUnusedPVC),
+		orphan("Service", "old-lb", 18, ReasonNoUnit, ReasonNoEndpoints),
+		orphan("ConfigMap", "protected", 0, ReasonStaleConfigMap),
+	}, now); err != nil {
+		t.Fatal(err)
+	}
+	handler := c.handler()
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	for _, tc := range []struct {
+		name, path, body string
+		code             int
+	}{
+		{name: "no approver", path: "/api/v1/orphans/cleanup-service-shop-old-lb/approve", body: `{}`, code: http.StatusBadRequest},
+		{name: "approve", path: "/api/v1/orphans/cleanup-service-shop-old-lb/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusOK},
+		{name: "approve again", path: "/api/v1/orphans/cleanup-service-shop-old-lb/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusConflict},
+		{name: "reject", path: "/api/v1/orphans/cleanup-persistentvolumeclaim-shop-data-old/reject", body: `{"approver": "bob@example.com"}`, code: http.StatusOK},
+		{name: "deletion fails", path: "/api/v1/orphans/cleanup-configmap-shop-protected/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusBadGateway},
+		{name: "unknown", path: "/api/v1/orphans/cleanup-service-shop-web/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusNotFound},
+	} {
+		if rec := post(tc.path, tc.body); rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, rec.Code, tc.code, rec.Body.String())
+		}
+	}
+	if !reflect.DeepEqual(*deleted, []string{"Service/shop/old-lb"}) {
+		t.Errorf("deleted %v", *deleted)
+	}
+	if p := c.proposals["cleanup-configmap-shop-protected"]; p.Status != StatusPending || p.Error != "forbidden" {
+		t.Errorf("failed deletion left %+v, want it pending with the error", p)
+	}
+	entries, _, err := c.audit.Entries(context.Background(), audit.Query{})
+	if err != nil || len(entries) != 2 {
+		t.Errorf("audit entries = %+v (%v), want the deletion and the failed one", entries, err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/orphans?status=all", nil))
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range report.Proposals {
+		got = append(got, p.Slug+"="+p.Status)
+	}
+	want := []string{"cleanup-service-shop-old-lb=deleted", "cleanup-persistentvolumeclaim-shop-data-old=rejected", "cleanup-configmap-shop-protected=pending"}
+	if !reflect.DeepEqual(got, want) || report.Pending != 1 || report.ByReason[ReasonStaleConfigMap] != 1 {
+		t.Errorf("report = %+v, proposals %v; want %v", report, got, want)
+	}
+}
+
+func TestDecideRechecks(t *testing.T) {
+	store := &memoryStore{units: map[string]StoredUnit{}}
+	c, deleted := newTestCleaner(store)
+	ctx := context.Background()
+	if _, err := c.propose(ctx, []Orphan{orphan("Service", "adopted", 18, ReasonNoUnit)}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// given a unit since the last scan
+	p, err := c.decide(ctx, "cleanup-service-shop-adopted", true, "alice@example.com", "")
+	if !errors.Is(err, errNotPending) {
+		t.Errorf("approving err = %v, want %v", err, errNotPending)
+	}
+	if len(*deleted) != 0 || p.Status != StatusResolved {
+		t.Errorf("deleted %v, proposal %s; want nothing deleted and it resolved", *deleted, p.Status)
+	}
+}
@@ -0,0 +1,151 @@
+// Package api mounts an app's HTTP API under /api/v1 and describes it in an
+// OpenAPI 3 document served at /api/v1/openapi.json, so clients can be
+// generated:
+//
+//	a := api.New(mux, "drift-detector")
+//	a.HandleFunc("/drift", d.handleDrift, api.Operation{Summary: "Latest drift report", Response: DriftReport{}})
+//
+// Request and response bodies are described by the Go types the handlers
+// encode; their json tags name the properties. The unversioned /api/...
+// paths of earlier releases stay as deprecated aliases of /api/v1/..., with
+// a Deprecation header pointing at the new path.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Prefix is where every route is mounted
+const Prefix = "/api/v1"
+
+// legacyPrefix is where the routes were mounted before versioning
+const legacyPrefix = "/api"
+
+// Mux is where routes are mounted, e.g. an *http.ServeMux
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// Operation describes one method of a route
+type Operation struct {
+	Method      string      // GET when empty
+	Path        string      // below Prefix, with {params}; the route's path when empty
+	Summary     string      // one line
+	Query       []Param     // query parameters
+	Request     interface{} // a value of the request body's type; nil without a body
+	Response    interface{} // a value of the 200 response body's type; nil for any JSON
+	ContentType string      // of the response, application/json when empty
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string
+	Description string
+}
+
+// API is the versioned API of one app
+type API struct {
+	mux   Mux
+	title string
+
+	mu  sync.Mutex
+	ops []Operation
+}
+
+// New returns the API of app on mux, serving its OpenAPI document at
+// /api/v1/openapi.json
+func New(mux Mux, app string) *API {
+	a := &API{mux: mux, title: app}
+	a.mux.Handle(Prefix+"/openapi.json", http.HandlerFunc(a.serveSpec))
+	return a
+}
+
+// Handle mounts h at path below Prefix, and its deprecated alias below /api.
+// A path ending in a slash also matches its subtree and the path without
+// the slash. ops document the methods; without them the route is served but
+// left out of the document.
+func (a *API) Handle(path string, h http.Handler, ops ...Operation) {
+	alias := deprecated(h)
+	a.mux.Handle(Prefix+path, h)
+	a.mux.Handle(legacyPrefix+path, alias)
+	if trimmed := strings.TrimSuffix(path, "/"); trimmed != path {
+		a.mux.Handle(Prefix+trimmed, h)
+		a.mux.Handle(legacyPrefix+trimmed, alias)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, op := range ops {
+		if op.Method == "" {
+			op.Method = http.MethodGet
+		}
+		if op.Path == "" {
+			op.Path = strings.TrimSuffix(path, "/")
+		}
+		a.ops = append(a.ops, op)
+	}
+}
+
+// HandleFunc mounts f like Handle
+func (a *API) HandleFunc(path string, f func(http.ResponseWriter, *http.Request), ops ...Operation) {
+	a.Handle(path, http.HandlerFunc(f), ops...)
+}
+
+// deprecated serves a request to /api/... as the /api/v1/... one
+func deprecated(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := *r.URL
+		u.Path = Prefix + strings.TrimPrefix(r.URL.Path, legacyPrefix)
+		u.RawPath = ""
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+u.Path+`>; rel="successor-version"`)
+		r2 := r.Clone(r.Context())
+		r2.URL = &u
+		r2.RequestURI = u.RequestURI()
+		h.ServeHTTP(w, r2)
+	})
+}
+
+// Spec returns the OpenAPI document of the routes mounted so far
+func (a *API) Spec() map[string]interface{} {
+	a.mu.Lock()
+	ops := append([]Operation(nil), a.ops...)
+	a.mu.Unlock()
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Path < ops[j].Path })
+
+	schemas := newSchemas()
+	paths := map[string]interface{}{}
+	for _, op := range ops {
+		item, _ := paths[Prefix+op.Path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[Prefix+op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = schemas.operation(op)
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": a.title, "version": "v1"},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+		},
+	}
+}
+
+func (a *API) serveSpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a.Spec()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
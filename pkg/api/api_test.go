@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type testItem struct {
+	ID       uuid.UUID         `json:"id"`
+	Name     string            `json:"name"`
+	Cost     float64           `json:"cost,omitempty"`
+	At       time.Time         `json:"at"`
+	Labels   map[string]string `json:"labels"`
+	Children []*testItem       `json:"children"`
+	Count    int64             `json:"count,string"`
+	Hidden   string            `json:"-"`
+	internal string
+	testEmbedded
+}
+
+type testEmbedded struct {
+	Source string `json:"source"`
+}
+
+type testList struct {
+	Items []testItem `json:"items"`
+}
+
+func TestHandle(t *testing.T) {
+	mux := http.NewServeMux()
+	a := New(mux, "test-app")
+	a.HandleFunc("/items/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Method+" "+r.URL.Path)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	tests := []struct {
+		path       string
+		want       string
+		deprecated bool
+	}{
+		{"/api/v1/items", "GET /api/v1/items", false},
+		{"/api/v1/items/a/approve", "GET /api/v1/items/a/approve", false},
+		{"/api/items", "GET /api/v1/items", true},
+		{"/api/items/a%2Fb/approve?x=1", "GET /api/v1/items/a/b/approve", true},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.want {
+			t.Errorf("GET %s served %q, want %q", tt.path, body, tt.want)
+		}
+		if got := resp.Header.Get("Deprecation") == "true"; got != tt.deprecated {
+			t.Errorf("GET %s deprecated = %v, want %v", tt.path, got, tt.deprecated)
+		}
+		if tt.deprecated && !strings.HasPrefix(resp.Header.Get("Link"), "</api/v1/items") {
+			t.Errorf("GET %s Link = %q", tt.path, resp.Header.Get("Link"))
+		}
+	}
+}
+
+func TestSpec(t *testing.T) {
+	mux := http.NewServeMux()
+	a := New(mux, "test-app")
+	noop := func(http.ResponseWriter, *http.Request) {}
+	a.HandleFunc("/items/", noop,
+		Operation{Summary: "List items", Query: []Param{{Name: "space", Description: "only this space"}}, Response: testList{}},
+		Operation{Method: http.MethodPost, Path: "/items/{id}/approve", Request: struct {
+			Note string `json:"note"`
+		}{}, Response: &testItem{}},
+	)
+	a.HandleFunc("/items/export", noop, Operation{ContentType: "text/csv"})
+	a.HandleFunc("/undocumented", noop)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Info       map[string]string                            `json:"info"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("openapi.json: %v\n%s", err, rec.Body)
+	}
+
+	if spec.OpenAPI != "3.0.3" || spec.Info["title"] != "test-app" || spec.Info["version"] != "v1" {
+		t.Errorf("header = %s %v", spec.OpenAPI, spec.Info)
+	}
+	if len(spec.Paths) != 3 || spec.Paths["/api/v1/undocumented"] != nil {
+		t.Errorf("paths = %v", spec.Paths)
+	}
+	list := spec.Paths["/api/v1/items"]["get"]
+	if list["operationId"] != "getItems" || list["summary"] != "List items" || !strings.Contains(marshal(list["parameters"]), `"in":"query","name":"space"`) {
+		t.Errorf("GET /items = %v", list)
+	}
+	if got := marshal(list["responses"]); !strings.Contains(got, `"application/json":{"schema":{"$ref":"#/components/schemas/testList"}}`) {
+		t.Errorf("GET /items responses = %s", got)
+	}
+	if got := marshal(spec.Components.Schemas["testList"].Properties["items"]); got != `{"items":{"$ref":"#/components/schemas/testItem"},"type":"array"}` {
+		t.Errorf("testList.items = %s", got)
+	}
+	approve := spec.Paths["/api/v1/items/{id}/approve"]["post"]
+	if approve["operationId"] != "postItemsIdApprove" || !strings.Contains(marshal(approve["parameters"]), `"in":"path","name":"id","required":true`) ||
+		!strings.Contains(marshal(approve["requestBody"]), `"note":{"type":"string"}`) {
+		t.Errorf("POST /items/{id}/approve = %v", approve)
+	}
+	if got := marshal(spec.Paths["/api/v1/items/export"]["get"]["responses"]); !strings.Contains(got, `"text/csv":{"schema":{"type":"string"}}`) {
+		t.Errorf("GET /items/export responses = %s", got)
+	}
+
+	item := spec.Components.Schemas["testItem"].Properties
+	want := map[string]string{
+		"id":       `{"type":"string"}`,
+		"cost":     `{"type":"number"}`,
+		"at":       `{"format":"date-time","type":"string"}`,
+		"labels":   `{"additionalProperties":{"type":"string"},"type":"object"}`,
+		"children": `{"items":{"$ref":"#/components/schemas/testItem"},"type":"array"}`,
+		"count":    `{"type":"string"}`,
+		"source":   `{"type":"string"}`,
+	}
+	for name, schema := range want {
+		if got := marshal(item[name]); got != schema {
+			t.Errorf("testItem.%s = %s, want %s", name, got, schema)
+		}
+	}
+	if len(item) != len(want)+1 { // and name
+		t.Errorf("testItem properties = %v", item)
+	}
+}
+
+func marshal(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
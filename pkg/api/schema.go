@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	jsonMarshaler     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	pathParam         = regexp.MustCompile(`\{([^}]+)\}`)
+	notComponentNamed = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+)
+
+// schemas builds the JSON schemas of Go types, named struct types becoming
+// components referenced by $ref
+type schemas struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]interface{}{}, names: map[reflect.Type]string{}}
+}
+
+// operation is the OpenAPI operation object of op
+func (s *schemas) operation(op Operation) map[string]interface{} {
+	var params []interface{}
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range op.Query {
+		param := map[string]interface{}{"name": p.Name, "in": "query", "schema": map[string]interface{}{"type": "string"}}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	body := map[string]interface{}{}
+	switch {
+	case contentType != "application/json":
+		body["schema"] = map[string]interface{}{"type": "string"}
+	case op.Response != nil:
+		body["schema"] = s.of(reflect.TypeOf(op.Response))
+	default:
+		body["schema"] = map[string]interface{}{}
+	}
+
+	o := map[string]interface{}{
+		"operationId": operationID(op),
+		"responses": map[string]interface{}{
+			"200":     map[string]interface{}{"description": "OK", "content": map[string]interface{}{contentType: body}},
+			"default": map[string]interface{}{"description": "Error, as plain text", "content": map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}},
+		},
+	}
+	if op.Summary != "" {
+		o["summary"] = op.Summary
+	}
+	if params != nil {
+		o["parameters"] = params
+	}
+	if op.Request != nil {
+		o["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": s.of(reflect.TypeOf(op.Request))}},
+		}
+	}
+	return o
+}
+
+// operationID names an operation after its method and path, e.g.
+// getSpacesIdReport for GET /spaces/{id}/report
+func operationID(op Operation) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '-' || r == '_' || r == '.' }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+// of returns the schema of t, as json.Marshal encodes it
+func (s *schemas) of(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "nanoseconds"}
+	case t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler):
+		if t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler) {
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{}
+	case t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + s.component(t)}
+	}
+	return map[string]interface{}{} // interface{}: any JSON
+}
+
+// component registers the schema of a named struct type and returns its
+// name, qualified by its package when two packages share a type name
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := notComponentNamed.ReplaceAllString(t.Name(), "_")
+	if _, taken := s.components[name]; taken {
+		name = notComponentNamed.ReplaceAllString(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:], "_") + "." + name
+	}
+	s.names[t] = name
+	s.components[name] = map[string]interface{}{} // placeholder for recursive types
+	s.components[name] = s.object(t)
+	return name
+}
+
+// object is the schema of a struct's exported, json encoded fields
+func (s *schemas) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	s.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (s *schemas) fields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			s.fields(ft, props) // embedded fields are promoted
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := s.of(f.Type)
+		if strings.Contains(opts, "string") {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		props[name] = schema
+	}
+}
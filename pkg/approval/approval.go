@@ -0,0 +1,721 @@
+// Package approval takes an app's cost recommendations through review
+// before they are applied:
+//
+//	pending → approved → applied → verified
+//	   ↓         ↓          ↓
+//	rejected   failed    rolled-back
+//
+// Every recommendation of an analysis is filed pending, once per resource
+// and change. Someone approves or rejects it, or the app approves it itself
+// when its policy allows it, and the app applies approved ones only:
+//
+//	GET  /api/v1/approvals?state=pending
+//	POST /api/v1/recommendations/{id}/approve   {"reviewer": "alice", "note": "..."}
+//	POST /api/v1/recommendations/{id}/reject    {"reviewer": "alice", "note": "..."}
+//	POST /api/v1/recommendations/{id}/rollback  {"reviewer": "alice", "note": "..."}
+//
+// Once applied, a recommendation is verified when its resource's monthly
+// cost has fallen by at least half the savings predicted, or rolled back
+// when it has not within VerifyWithin. It is also rolled back when, within
+// VerifyWithin, its resource runs hotter or restarts more than the
+// Thresholds allow. A failed one is applied again when
+// approved again. While its app applies or rolls it back, a recommendation
+// is applying or rolling-back, so that is done once however many passes
+// and reviewers reach it.
+//
+// The recommendations are written, as one JSON document, to the ConfigHub
+// unit recommendations-<app> of the space named by PENDING_SPACE, or to a
+// file in PENDING_DIR; without either they are kept in memory only.
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// States of a recommendation
+const (
+	Pending    = "pending"  // awaiting review
+	Approved   = "approved" // to be applied by its app
+	Rejected   = "rejected"
+	Applied    = "applied" // awaiting verification
+	Failed     = "failed"  // applying it failed; approve it again to retry
+	Verified   = "verified"
+	RolledBack = "rolled-back"
+
+	Applying    = "applying"     // approved, being applied
+	RollingBack = "rolling-back" // applied or verified, being rolled back
+)
+
+// Label marks the ConfigHub units holding recommendations; its value is the
+// app
+const Label = "recommendations"
+
+// Defaults of a new Board
+const (
+	DefaultVerifyWithin = 24 * time.Hour
+	DefaultRetention    = 30 * 24 * time.Hour
+)
+
+// Writer stores the recommendations as the ConfigHub unit with the given
+// slug, creating it or replacing its data and labels
+type Writer func(ctx context.Context, slug string, labels map[string]string, data string) error
+
+// Reader returns the data of the ConfigHub units matching a where clause
+type Reader func(ctx context.Context, where string) ([]string, error)
+
+// Handler applies or rolls back a recommendation
+type Handler func(ctx context.Context, r Recommendation) error
+
+// Cost returns the current monthly cost of a recommendation's resource, and
+// false when it is unknown
+type Cost func(r Recommendation) (float64, bool)
+
+// Recommendation is one reviewed change
+type Recommendation struct {
+	ID             string          `json:"id"`
+	App            string          `json:"app"`
+	Resource       string          `json:"resource"` // e.g. deployment/web
+	Namespace      string          `json:"namespace,omitempty"`
+	Change         string          `json:"change"`          // e.g. cpu=250m memory=256Mi; another change is another recommendation
+	Input          json.RawMessage `json:"input,omitempty"` // the app's recommendation
+	MonthlySavings float64         `json:"monthly_savings"` // predicted
+	BaselineCost   float64         `json:"baseline_cost"`   // of the resource when last filed or applied
+	State          string          `json:"state"`
+	ReviewedBy     string          `json:"reviewed_by,omitempty"`
+	Note           string          `json:"note,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	ActualSavings  *float64        `json:"actual_savings,omitempty"` // once verified or rolled back
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	AppliedAt      *time.Time      `json:"applied_at,omitempty"`
+}
+
+// Open reports whether r may still change state on its own
+func (r Recommendation) Open() bool {
+	switch r.State {
+	case Pending, Approved, Applying, Applied, RollingBack:
+		return true
+	}
+	return false
+}
+
+// Document is what a recommendations unit holds
+type Document struct {
+	App             string           `json:"app"`
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// Health is how a recommendation's resource has done since it was applied
+type Health struct {
+	CPUUtilization    float64 // percent of its requests
+	MemoryUtilization float64 // percent of its requests
+	Restarts          int     // of its containers
+}
+
+// Thresholds roll back an applied recommendation whose resource's Health
+// exceeds one within VerifyWithin; zero disables a threshold
+type Thresholds struct {
+	CPUUtilization    float64
+	MemoryUtilization float64
+	Restarts          int
+}
+
+// Exceeded describes the thresholds h exceeds, empty when none
+func (t Thresholds) Exceeded(h Health) string {
+	var exceeded []string
+	if t.CPUUtilization > 0 && h.CPUUtilization > t.CPUUtilization {
+		exceeded = append(exceeded, fmt.Sprintf("CPU at %.0f%% of its requests, above %.0f%%", h.CPUUtilization, t.CPUUtilization))
+	}
+	if t.MemoryUtilization > 0 && h.MemoryUtilization > t.MemoryUtilization {
+		exceeded = append(exceeded, fmt.Sprintf("memory at %.0f%% of its requests, above %.0f%%", h.MemoryUtilization, t.MemoryUtilization))
+	}
+	if t.Restarts > 0 && h.Restarts > t.Restarts {
+		exceeded = append(exceeded, fmt.Sprintf("%d container restarts, above %d", h.Restarts, t.Restarts))
+	}
+	return strings.Join(exceeded, "; ")
+}
+
+// Probe returns the health of a recommendation's resource since it was
+// applied, and false when it is unknown
+type Probe func(r Recommendation) (Health, bool)
+
+// Board is one app's recommendations under review. It is safe for
+// concurrent use; a nil Board files nothing.
+type Board struct {
+	app    string
+	writer Writer
+	reader Reader
+	now    func() time.Time
+
+	VerifyWithin time.Duration // how long an applied recommendation has to show its savings
+	Retention    time.Duration // how long closed recommendations are kept
+	Thresholds   Thresholds    // of the health Watch probes within VerifyWithin
+
+	mu       sync.Mutex
+	recs     []Recommendation // oldest first
+	apply    Handler
+	rollback Handler
+	probe    Probe
+}
+
+// New returns app's board, stored through writer and reader; nil keeps it in
+// memory
+func New(app string, writer Writer, reader Reader) *Board {
+	return &Board{
+		app: app, writer: writer, reader: reader, now: time.Now,
+		VerifyWithin: DefaultVerifyWithin, Retention: DefaultRetention,
+	}
+}
+
+// Slug names the unit of app's recommendations
+func Slug(app string) string {
+	return "recommendations-" + app
+}
+
+// Where returns the ConfigHub where clause of app's recommendations unit
+func Where(app string) string {
+	return fmt.Sprintf("Labels['%s'] = '%s'", Label, app)
+}
+
+// Dir returns a Writer and Reader keeping the recommendations as a JSON file
+// in dir, for a board that survives restarts without ConfigHub
+func Dir(dir string) (Writer, Reader, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("create recommendations dir: %w", err)
+	}
+	writer := func(_ context.Context, slug string, _ map[string]string, data string) error {
+		tmp := filepath.Join(dir, slug+".json.tmp")
+		if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, filepath.Join(dir, slug+".json"))
+	}
+	reader := func(context.Context, string) ([]string, error) {
+		paths, err := filepath.Glob(filepath.Join(dir, "recommendations-*.json"))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]string, 0, len(paths))
+		for _, path := range paths {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, string(b))
+		}
+		return data, nil
+	}
+	return writer, reader, nil
+}
+
+// Handle registers how the app applies and rolls back a recommendation
+func (b *Board) Handle(apply, rollback Handler) {
+	b.mu.Lock()
+	b.apply, b.rollback = apply, rollback
+	b.mu.Unlock()
+}
+
+// Watch registers how the app probes the health of an applied
+// recommendation's resource, for Verify to check against the Thresholds
+func (b *Board) Watch(probe Probe) {
+	b.mu.Lock()
+	b.probe = probe
+	b.mu.Unlock()
+}
+
+// Load reads the stored recommendations, keeping any filed since startup. A
+// missing document is not an error.
+func (b *Board) Load(ctx context.Context) error {
+	if b == nil || b.reader == nil {
+		return nil
+	}
+	data, err := b.reader(ctx, Where(b.app))
+	if err != nil {
+		return fmt.Errorf("read recommendations: %w", err)
+	}
+	for _, d := range data {
+		var doc Document
+		if err := json.Unmarshal([]byte(d), &doc); err != nil {
+			return fmt.Errorf("decode recommendations: %w", err)
+		}
+		if doc.App != b.app {
+			continue
+		}
+		b.mu.Lock()
+		known := make(map[string]bool, len(b.recs))
+		for _, r := range b.recs {
+			known[r.ID] = true
+		}
+		for _, r := range doc.Recommendations {
+			if known[r.ID] {
+				continue
+			}
+			if r.State == Applying || r.State == RollingBack { // interrupted by a restart
+				r.State, r.Error = Failed, "interrupted while "+r.State
+			}
+			b.recs = append(b.recs, r)
+		}
+		sort.SliceStable(b.recs, func(i, j int) bool { return b.recs[i].CreatedAt.Before(b.recs[j].CreatedAt) })
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// File adds r pending review and returns it with its ID and state. A
+// recommendation of the same resource and change already filed is returned
+// instead, its input, savings and baseline refreshed while it is pending,
+// so an analysis repeated every cycle files it once; one rejected or rolled
+// back is not filed again.
+func (b *Board) File(ctx context.Context, r Recommendation) (Recommendation, error) {
+	if b == nil {
+		return r, nil
+	}
+	now := b.now().UTC()
+	b.mu.Lock()
+	i := b.find(func(e Recommendation) bool {
+		return e.Resource == r.Resource && e.Namespace == r.Namespace && e.Change == r.Change
+	})
+	if i < 0 {
+		r.ID, r.App, r.State, r.CreatedAt, r.UpdatedAt = uuid.NewString(), b.app, Pending, now, now
+		r.ReviewedBy, r.Note, r.Error, r.ActualSavings, r.AppliedAt = "", "", "", nil, nil
+		b.recs = append(b.recs, r)
+	} else if e := &b.recs[i]; e.State == Pending {
+		e.Input, e.MonthlySavings, e.BaselineCost, e.UpdatedAt = r.Input, r.MonthlySavings, r.BaselineCost, now
+		r = *e
+	} else {
+		r = *e
+		b.mu.Unlock()
+		return r, nil
+	}
+	b.mu.Unlock()
+	return r, b.save(ctx)
+}
+
+// Approve lets a pending or failed recommendation be applied
+func (b *Board) Approve(ctx context.Context, id, reviewer, note string) (Recommendation, error) {
+	return b.transition(ctx, id, func(r *Recommendation) error {
+		if r.State != Pending && r.State != Failed {
+			return fmt.Errorf("%s is %s; only pending and failed recommendations are approved", r.ID, r.State)
+		}
+		r.State, r.ReviewedBy, r.Note, r.Error = Approved, reviewer, note, ""
+		return nil
+	})
+}
+
+// Reject closes a recommendation that was not applied
+func (b *Board) Reject(ctx context.Context, id, reviewer, note string) (Recommendation, error) {
+	return b.transition(ctx, id, func(r *Recommendation) error {
+		if r.State != Pending && r.State != Approved && r.State != Failed {
+			return fmt.Errorf("%s is %s; only recommendations not applied are rejected", r.ID, r.State)
+		}
+		r.State, r.ReviewedBy, r.Note = Rejected, reviewer, note
+		return nil
+	})
+}
+
+// RollBack undoes an applied or verified recommendation with the app's
+// rollback handler
+func (b *Board) RollBack(ctx context.Context, id, reviewer, note string) (Recommendation, error) {
+	if b == nil {
+		return Recommendation{}, fmt.Errorf("no recommendation %s", id)
+	}
+	b.mu.Lock()
+	rollback := b.rollback
+	b.mu.Unlock()
+	if rollback == nil {
+		return Recommendation{}, fmt.Errorf("%s cannot roll back recommendations", b.app)
+	}
+	r, err := b.claim(id, RollingBack, Applied, Verified)
+	if err != nil {
+		return r, fmt.Errorf("%w; only applied and verified recommendations are rolled back", err)
+	}
+	if err := rollback(ctx, r); err != nil {
+		b.update(id, func(e *Recommendation) { e.State = r.State })
+		return r, fmt.Errorf("roll back %s: %w", id, err)
+	}
+	b.update(id, func(e *Recommendation) {
+		e.State, e.ReviewedBy, e.Note = RolledBack, reviewer, note
+	})
+	r, _ = b.Get(id)
+	return r, b.save(ctx)
+}
+
+// Get returns the recommendation with id
+func (b *Board) Get(id string) (Recommendation, bool) {
+	if b == nil {
+		return Recommendation{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := b.find(func(r Recommendation) bool { return r.ID == id }); i >= 0 {
+		return b.recs[i], true
+	}
+	return Recommendation{}, false
+}
+
+// List returns the recommendations in state, all when it is empty, oldest
+// first
+func (b *Board) List(state string) []Recommendation {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var list []Recommendation
+	for _, r := range b.recs {
+		if state == "" || r.State == state {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+// Process applies the approved recommendations and returns how many were
+// applied. cost gives the baseline their savings are verified against.
+func (b *Board) Process(ctx context.Context, cost Cost) int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	apply := b.apply
+	var approved []Recommendation
+	for i, r := range b.recs {
+		if apply != nil && r.State == Approved {
+			b.recs[i].State = Applying // claimed, so another pass skips it
+			approved = append(approved, r)
+		}
+	}
+	b.mu.Unlock()
+	if len(approved) == 0 {
+		return 0
+	}
+
+	applied := 0
+	for _, r := range approved {
+		err := apply(ctx, r)
+		b.update(r.ID, func(e *Recommendation) {
+			if e.State != Applying {
+				return
+			}
+			if err != nil {
+				e.State, e.Error = Failed, err.Error()
+				slog.Warn("Failed to apply recommendation", logging.Unit(e.Resource), logging.Err(err))
+				return
+			}
+			at := b.now().UTC()
+			e.State, e.AppliedAt, e.Error = Applied, &at, ""
+			if cost != nil {
+				if c, ok := cost(*e); ok {
+					e.BaselineCost = c
+				}
+			}
+			applied++
+		})
+	}
+	if err := b.save(ctx); err != nil {
+		slog.Warn("Failed to store recommendations", logging.Err(err))
+	}
+	return applied
+}
+
+// Verify checks the applied recommendations against the current cost of
+// their resources: those that saved at least half the predicted savings are
+// verified, and those that did not within VerifyWithin are rolled back.
+// Within VerifyWithin, those whose resource's health exceeds the Thresholds
+// are rolled back too, verified or not. It also drops closed recommendations
+// past the retention.
+func (b *Board) Verify(ctx context.Context, cost Cost) {
+	if b == nil || cost == nil {
+		return
+	}
+	now := b.now().UTC()
+	b.mu.Lock()
+	rollback, probe := b.rollback, b.probe
+	var applied []Recommendation
+	for _, r := range b.recs {
+		if r.State == Applied || r.State == Verified && r.AppliedAt != nil && now.Sub(*r.AppliedAt) <= b.VerifyWithin {
+			applied = append(applied, r)
+		}
+	}
+	kept := b.recs[:0]
+	for _, r := range b.recs {
+		if r.Open() || r.State == Failed || now.Sub(r.UpdatedAt) <= b.Retention {
+			kept = append(kept, r)
+		}
+	}
+	b.recs = kept
+	b.mu.Unlock()
+
+	for _, r := range applied {
+		within := r.AppliedAt != nil && now.Sub(*r.AppliedAt) <= b.VerifyWithin
+		if probe != nil && within {
+			if h, ok := probe(r); ok {
+				if exceeded := b.Thresholds.Exceeded(h); exceeded != "" {
+					b.rollBack(ctx, rollback, r, nil, exceeded)
+					continue
+				}
+			}
+		}
+		if r.State != Applied {
+			continue
+		}
+		c, ok := cost(r)
+		if !ok {
+			continue
+		}
+		saved := r.BaselineCost - c
+		switch {
+		case saved >= r.MonthlySavings/2:
+			b.update(r.ID, func(e *Recommendation) {
+				if e.State == Applied {
+					e.State, e.ActualSavings = Verified, &saved
+				}
+			})
+		case !within && r.AppliedAt != nil:
+			b.rollBack(ctx, rollback, r, &saved,
+				fmt.Sprintf("saved $%.2f/month of the $%.2f predicted within %s", saved, r.MonthlySavings, b.VerifyWithin))
+		}
+	}
+	if err := b.save(ctx); err != nil {
+		slog.Warn("Failed to store recommendations", logging.Err(err))
+	}
+}
+
+// rollBack undoes r on its own, noting why
+func (b *Board) rollBack(ctx context.Context, rollback Handler, r Recommendation, saved *float64, why string) {
+	if rollback == nil {
+		return
+	}
+	if _, err := b.claim(r.ID, RollingBack, r.State); err != nil { // reviewed or rolled back meanwhile
+		return
+	}
+	if err := rollback(ctx, r); err != nil {
+		slog.Warn("Failed to roll back recommendation", logging.Unit(r.Resource), logging.Err(err))
+		b.update(r.ID, func(e *Recommendation) { e.State, e.Error = r.State, err.Error() })
+		return
+	}
+	slog.Info("Rolled back recommendation", logging.Unit(r.Resource), "reason", why)
+	b.update(r.ID, func(e *Recommendation) {
+		e.State, e.Note = RolledBack, why
+		if saved != nil {
+			e.ActualSavings = saved
+		}
+	})
+}
+
+// Start applies approved recommendations every interval until ctx is done,
+// so an approval takes effect without waiting for the next analysis
+func (b *Board) Start(ctx context.Context, interval time.Duration, cost Cost) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Process(ctx, cost)
+		}
+	}
+}
+
+// find returns the index of the newest recommendation matching, or -1;
+// b.mu must be held
+func (b *Board) find(match func(Recommendation) bool) int {
+	for i := len(b.recs) - 1; i >= 0; i-- {
+		if match(b.recs[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// update changes the recommendation with id in place
+func (b *Board) update(id string, change func(*Recommendation)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := b.find(func(r Recommendation) bool { return r.ID == id }); i >= 0 {
+		change(&b.recs[i])
+		b.recs[i].UpdatedAt = b.now().UTC()
+	}
+}
+
+// claim moves the recommendation with id to state when it is in one of
+// from, and returns it as it was
+func (b *Board) claim(id, state string, from ...string) (Recommendation, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := b.find(func(r Recommendation) bool { return r.ID == id })
+	if i < 0 {
+		return Recommendation{}, fmt.Errorf("no recommendation %s", id)
+	}
+	r := b.recs[i]
+	for _, s := range from {
+		if r.State == s {
+			b.recs[i].State, b.recs[i].UpdatedAt = state, b.now().UTC()
+			return r, nil
+		}
+	}
+	return r, fmt.Errorf("%s is %s", id, r.State)
+}
+
+// transition changes the recommendation with id under the lock, so change
+// sees the state it is changed from
+func (b *Board) transition(ctx context.Context, id string, change func(*Recommendation) error) (Recommendation, error) {
+	if b == nil {
+		return Recommendation{}, fmt.Errorf("no recommendation %s", id)
+	}
+	b.mu.Lock()
+	i := b.find(func(r Recommendation) bool { return r.ID == id })
+	if i < 0 {
+		b.mu.Unlock()
+		return Recommendation{}, fmt.Errorf("no recommendation %s", id)
+	}
+	r := b.recs[i]
+	if err := change(&r); err != nil {
+		b.mu.Unlock()
+		return r, err
+	}
+	r.UpdatedAt = b.now().UTC()
+	b.recs[i] = r
+	b.mu.Unlock()
+	return r, b.save(ctx)
+}
+
+// save writes the recommendations
+func (b *Board) save(ctx context.Context) error {
+	if b.writer == nil {
+		return nil
+	}
+	b.mu.Lock()
+	doc := Document{App: b.app, Recommendations: append([]Recommendation{}, b.recs...)}
+	b.mu.Unlock()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encode recommendations: %w", err)
+	}
+	if err := b.writer(ctx, Slug(b.app), map[string]string{Label: b.app}, string(data)); err != nil {
+		return fmt.Errorf("store recommendations: %w", err)
+	}
+	return nil
+}
+
+// Listing is the body of GET /api/v1/approvals
+type Listing struct {
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// Review is the body of POST /api/v1/recommendations/{id}/approve, reject
+// and rollback; signed-in users review as themselves
+type Review struct {
+	Reviewer string `json:"reviewer"`
+	Note     string `json:"note"`
+}
+
+// ListOperation documents GET /api/v1/approvals
+var ListOperation = api.Operation{
+	Path: "/approvals", Summary: "Recommendations under review and their outcome, oldest first", Response: Listing{},
+	Query: []api.Param{{Name: "state", Description: "pending, approved, rejected, applied, failed, verified or rolled-back"}},
+}
+
+// ReviewOperations document the routes of ServeReview
+var ReviewOperations = []api.Operation{
+	{Path: "/recommendations/{id}", Summary: "A recommendation under review", Response: Recommendation{}},
+	{Method: http.MethodPost, Path: "/recommendations/{id}/approve", Summary: "Approve a recommendation", Request: Review{}, Response: Recommendation{}},
+	{Method: http.MethodPost, Path: "/recommendations/{id}/reject", Summary: "Reject a recommendation", Request: Review{}, Response: Recommendation{}},
+	{Method: http.MethodPost, Path: "/recommendations/{id}/rollback", Summary: "Roll back an applied recommendation", Request: Review{}, Response: Recommendation{}},
+}
+
+// ServeList lists the recommendations (GET /api/v1/approvals?state=)
+func (b *Board) ServeList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	recs := b.List(r.URL.Query().Get("state"))
+	if recs == nil {
+		recs = []Recommendation{}
+	}
+	writeJSON(w, Listing{Recommendations: recs})
+}
+
+// ServeReview returns one recommendation (GET /api/v1/recommendations/{id})
+// and approves, rejects or rolls it back (POST
+// /api/v1/recommendations/{id}/approve|reject|rollback)
+func (b *Board) ServeReview(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, api.Prefix+"/recommendations"), "/"), "/")
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rec, ok := b.Get(parts[0])
+		if !ok {
+			http.Error(w, fmt.Sprintf("no recommendation %s", parts[0]), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, rec)
+		return
+	}
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Review
+	decodeErr := json.NewDecoder(r.Body).Decode(&req)
+	if user := auth.FromContext(r.Context()); user != nil {
+		req.Reviewer = user.Email // signed-in users review as themselves
+	}
+	if decodeErr != nil || req.Reviewer == "" {
+		http.Error(w, "reviewer is required", http.StatusBadRequest)
+		return
+	}
+	var rec Recommendation
+	var err error
+	switch parts[1] {
+	case "approve":
+		rec, err = b.Approve(r.Context(), parts[0], req.Reviewer, req.Note)
+	case "reject":
+		rec, err = b.Reject(r.Context(), parts[0], req.Reviewer, req.Note)
+	case "rollback":
+		rec, err = b.RollBack(r.Context(), parts[0], req.Reviewer, req.Note)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case err != nil && rec.ID == "":
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeJSON(w, rec)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
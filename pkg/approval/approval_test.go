@@ -0,0 +1,389 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newBoard(t *testing.T) (*Board, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	b := New("cost-optimizer", nil, nil)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func web(change string) Recommendation {
+	return Recommendation{Resource: "deployment/web", Namespace: "shop", Change: change, MonthlySavings: 40, BaselineCost: 100}
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newBoard(t)
+
+	first, err := b.File(ctx, web("cpu=250m"))
+	if err != nil || first.ID == "" || first.State != Pending || first.App != "cost-optimizer" {
+		t.Fatalf("File = %+v, %v", first, err)
+	}
+	again := web("cpu=250m")
+	again.MonthlySavings = 45
+	if r, _ := b.File(ctx, again); r.ID != first.ID || r.MonthlySavings != 45 {
+		t.Errorf("refiled pending: got %+v, want %s refreshed", r, first.ID)
+	}
+	if _, err := b.Reject(ctx, first.ID, "alice", "needs the headroom"); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := b.File(ctx, web("cpu=250m")); r.ID != first.ID || r.State != Rejected {
+		t.Errorf("refiled rejected: got %+v", r)
+	}
+	if r, _ := b.File(ctx, web("cpu=200m")); r.ID == first.ID || r.State != Pending {
+		t.Errorf("another change: got %+v", r)
+	}
+	if n := len(b.List("")); n != 2 {
+		t.Errorf("List = %d recommendations, want 2", n)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		setup []string // approve, reject, apply, fail
+		do    string
+		want  string // state, or "" for an error
+	}{
+		{"approve pending", nil, "approve", Approved},
+		{"reject pending", nil, "reject", Rejected},
+		{"reject approved", []string{"approve"}, "reject", Rejected},
+		{"approve failed", []string{"approve", "fail"}, "approve", Approved},
+		{"approve applied", []string{"approve", "apply"}, "approve", ""},
+		{"reject applied", []string{"approve", "apply"}, "reject", ""},
+		{"roll back applied", []string{"approve", "apply"}, "rollback", RolledBack},
+		{"roll back pending", nil, "rollback", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newBoard(t)
+			var applyErr error
+			b.Handle(func(context.Context, Recommendation) error { return applyErr },
+				func(context.Context, Recommendation) error { return nil })
+			r, _ := b.File(ctx, web("cpu=250m"))
+			run := func(step string) (Recommendation, error) {
+				switch step {
+				case "approve":
+					return b.Approve(ctx, r.ID, "alice", "")
+				case "reject":
+					return b.Reject(ctx, r.ID, "alice", "")
+				case "rollback":
+					return b.RollBack(ctx, r.ID, "alice", "")
+				case "fail":
+					applyErr = errors.New("unit not found")
+				}
+				b.Process(ctx, nil)
+				applyErr = nil
+				got, _ := b.Get(r.ID)
+				return got, nil
+			}
+			for _, step := range tt.setup {
+				if _, err := run(step); err != nil {
+					t.Fatalf("%s: %v", step, err)
+				}
+			}
+			got, err := run(tt.do)
+			switch {
+			case tt.want == "" && err == nil:
+				t.Errorf("%s: got %s, want an error", tt.do, got.State)
+			case tt.want != "" && (err != nil || got.State != tt.want):
+				t.Errorf("%s: got %s, %v, want %s", tt.do, got.State, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newBoard(t)
+	var applied []string
+	b.Handle(func(_ context.Context, r Recommendation) error {
+		applied = append(applied, r.Change)
+		if r.Change == "cpu=1m" {
+			return errors.New("too small")
+		}
+		return nil
+	}, nil)
+	pending, _ := b.File(ctx, web("cpu=250m"))
+	ok, _ := b.File(ctx, web("cpu=200m"))
+	bad, _ := b.File(ctx, web("cpu=1m"))
+	b.Approve(ctx, ok.ID, "policy", "")
+	b.Approve(ctx, bad.ID, "alice", "")
+
+	cost := func(Recommendation) (float64, bool) { return 90, true }
+	if n := b.Process(ctx, cost); n != 1 {
+		t.Errorf("Process applied %d, want 1", n)
+	}
+	if len(applied) != 2 {
+		t.Errorf("applied %v, want only the approved", applied)
+	}
+	if r, _ := b.Get(pending.ID); r.State != Pending {
+		t.Errorf("unapproved is %s", r.State)
+	}
+	if r, _ := b.Get(ok.ID); r.State != Applied || r.AppliedAt == nil || r.BaselineCost != 90 {
+		t.Errorf("applied: %+v", r)
+	}
+	if r, _ := b.Get(bad.ID); r.State != Failed || r.Error != "too small" {
+		t.Errorf("failed: %+v", r)
+	}
+	if n := b.Process(ctx, cost); n != 0 {
+		t.Errorf("second Process applied %d", n)
+	}
+}
+
+func TestProcessConcurrent(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newBoard(t)
+	var mu sync.Mutex
+	applied, rolledBack := map[string]int{}, map[string]int{}
+	count := func(m map[string]int) Handler {
+		return func(_ context.Context, r Recommendation) error {
+			mu.Lock()
+			m[r.ID]++
+			mu.Unlock()
+			time.Sleep(time.Millisecond) // both passes see it in flight
+			return nil
+		}
+	}
+	b.Handle(count(applied), count(rolledBack))
+	var ids []string
+	for _, change := range []string{"cpu=100m", "cpu=200m", "cpu=300m"} {
+		r, _ := b.File(ctx, web(change))
+		b.Approve(ctx, r.ID, "alice", "")
+		ids = append(ids, r.ID)
+	}
+
+	var wg sync.WaitGroup
+	total := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			total <- b.Process(ctx, nil)
+		}()
+	}
+	wg.Wait()
+	if n := <-total + <-total; n != len(ids) {
+		t.Errorf("Process applied %d, want %d", n, len(ids))
+	}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := b.RollBack(ctx, ids[0], "alice", "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	if err1, err2 := <-errs, <-errs; (err1 == nil) == (err2 == nil) {
+		t.Errorf("concurrent rollbacks returned %v and %v, want one error", err1, err2)
+	}
+
+	for _, id := range ids {
+		if applied[id] != 1 {
+			t.Errorf("%s applied %d times", id, applied[id])
+		}
+	}
+	if rolledBack[ids[0]] != 1 {
+		t.Errorf("rolled back %d times", rolledBack[ids[0]])
+	}
+	if r, _ := b.Get(ids[0]); r.State != RolledBack {
+		t.Errorf("rolled back is %s", r.State)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	b, now := newBoard(t)
+	var rolledBack []string
+	b.Handle(func(context.Context, Recommendation) error { return nil },
+		func(_ context.Context, r Recommendation) error { rolledBack = append(rolledBack, r.Change); return nil })
+
+	costs := map[string]float64{"cpu=250m": 100, "cpu=200m": 100, "cpu=150m": 100}
+	cost := func(r Recommendation) (float64, bool) { c, ok := costs[r.Change]; return c, ok }
+	var ids []string
+	for _, change := range []string{"cpu=250m", "cpu=200m", "cpu=150m"} {
+		r, _ := b.File(ctx, web(change))
+		b.Approve(ctx, r.ID, "alice", "")
+		ids = append(ids, r.ID)
+	}
+	b.Process(ctx, cost)
+
+	costs["cpu=250m"] = 65    // saved 35 of 40
+	costs["cpu=200m"] = 95    // saved 5
+	delete(costs, "cpu=150m") // unknown
+	*now = now.Add(b.VerifyWithin + time.Minute)
+	b.Verify(ctx, cost)
+
+	if r, _ := b.Get(ids[0]); r.State != Verified || r.ActualSavings == nil || *r.ActualSavings != 35 {
+		t.Errorf("saved: %+v", r)
+	}
+	if r, _ := b.Get(ids[1]); r.State != RolledBack || !strings.Contains(r.Note, "saved $5.00/month of the $40.00") {
+		t.Errorf("not saved: %+v", r)
+	}
+	if r, _ := b.Get(ids[2]); r.State != Applied {
+		t.Errorf("unknown cost: %s", r.State)
+	}
+	if len(rolledBack) != 1 || rolledBack[0] != "cpu=200m" {
+		t.Errorf("rolled back %v", rolledBack)
+	}
+
+	*now = now.Add(b.Retention + time.Hour)
+	b.Verify(ctx, cost)
+	if r := b.List(""); len(r) != 1 || r[0].ID != ids[2] {
+		t.Errorf("after the retention: %+v, want only the open one", r)
+	}
+}
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writer, reader, err := Dir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New("cost-optimizer", writer, reader)
+	r, _ := b.File(ctx, web("cpu=250m"))
+	if _, err := b.Approve(ctx, r.ID, "alice", "ok"); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := New("cost-optimizer", writer, reader)
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := restarted.Get(r.ID); !ok || got.State != Approved || got.ReviewedBy != "alice" {
+		t.Errorf("after restart: %+v, %v", got, ok)
+	}
+	if err := New("drift-detector", writer, reader).Load(ctx); err != nil {
+		t.Errorf("other app: %v", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	ctx := context.Background()
+	b, _ := newBoard(t)
+	r, _ := b.File(ctx, web("cpu=250m"))
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/api/v1/approvals?state=pending", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/recommendations/" + r.ID, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/recommendations/nope", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/recommendations/" + r.ID + "/approve", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/recommendations/nope/approve", `{"reviewer": "alice"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/recommendations/" + r.ID + "/approve", `{"reviewer": "alice"}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/recommendations/" + r.ID + "/approve", `{"reviewer": "alice"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/recommendations/" + r.ID + "/reject", `{"reviewer": "bob"}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/recommendations/" + r.ID + "/merge", `{"reviewer": "bob"}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/recommendations/" + r.ID, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		if strings.HasPrefix(tt.path, "/api/v1/approvals") {
+			b.ServeList(w, req)
+		} else {
+			b.ServeReview(w, req)
+		}
+		if w.Code != tt.want {
+			t.Errorf("%s %s: got %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+		}
+	}
+	if got, _ := b.Get(r.ID); got.State != Rejected || got.ReviewedBy != "bob" {
+		t.Errorf("after the requests: %+v", got)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	thresholds := Thresholds{CPUUtilization: 90, Restarts: 2}
+	tests := []struct {
+		health Health
+		want   string
+	}{
+		{Health{CPUUtilization: 60, MemoryUtilization: 99, Restarts: 2}, ""},
+		{Health{CPUUtilization: 95}, "CPU at 95% of its requests, above 90%"},
+		{Health{CPUUtilization: 95, Restarts: 3}, "CPU at 95% of its requests, above 90%; 3 container restarts, above 2"},
+	}
+	for _, tt := range tests {
+		if got := thresholds.Exceeded(tt.health); got != tt.want {
+			t.Errorf("Exceeded(%+v) = %q, want %q", tt.health, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyHealth(t *testing.T) {
+	ctx := context.Background()
+	b, now := newBoard(t)
+	b.Thresholds = Thresholds{MemoryUtilization: 90, Restarts: 1}
+	var rolledBack []string
+	b.Handle(func(context.Context, Recommendation) error { return nil },
+		func(_ context.Context, r Recommendation) error { rolledBack = append(rolledBack, r.Change); return nil })
+	health := map[string]Health{
+		"memory=128Mi": {MemoryUtilization: 97}, // too small
+		"memory=256Mi": {MemoryUtilization: 70},
+		"memory=512Mi": {MemoryUtilization: 40, Restarts: 2},
+	}
+	b.Watch(func(r Recommendation) (Health, bool) { h, ok := health[r.Change]; return h, ok })
+
+	cost := func(Recommendation) (float64, bool) { return 50, true } // all saved enough
+	ids := make(map[string]string)
+	for change := range health {
+		r, _ := b.File(ctx, web(change))
+		b.Approve(ctx, r.ID, "alice", "")
+		ids[change] = r.ID
+	}
+	b.Process(ctx, func(Recommendation) (float64, bool) { return 100, true })
+
+	b.Verify(ctx, cost)
+	want := map[string]string{"memory=128Mi": RolledBack, "memory=256Mi": Verified, "memory=512Mi": RolledBack}
+	for change, state := range want {
+		if r, _ := b.Get(ids[change]); r.State != state {
+			t.Errorf("%s: got %s (%s), want %s", change, r.State, r.Note, state)
+		}
+	}
+	if r, _ := b.Get(ids["memory=128Mi"]); r.Note != "memory at 97% of its requests, above 90%" {
+		t.Errorf("note %q", r.Note)
+	}
+
+	// A verified recommendation is still watched within VerifyWithin...
+	health["memory=256Mi"] = Health{MemoryUtilization: 95}
+	*now = now.Add(time.Hour)
+	b.Verify(ctx, cost)
+	if r, _ := b.Get(ids["memory=256Mi"]); r.State != RolledBack {
+		t.Errorf("verified, then too hot: %s", r.State)
+	}
+
+	// ...but not after
+	r, _ := b.File(ctx, web("memory=384Mi"))
+	b.Approve(ctx, r.ID, "alice", "")
+	b.Process(ctx, cost)
+	health["memory=384Mi"] = Health{}
+	b.Verify(ctx, func(Recommendation) (float64, bool) { return 0, true })
+	health["memory=384Mi"] = Health{Restarts: 5}
+	*now = now.Add(b.VerifyWithin + time.Minute)
+	b.Verify(ctx, cost)
+	if r, _ := b.Get(r.ID); r.State != Verified {
+		t.Errorf("after VerifyWithin: %s", r.State)
+	}
+	if len(rolledBack) != 3 {
+		t.Errorf("rolled back %v", rolledBack)
+	}
+}
@@ -0,0 +1,395 @@
+// Package audit records the apps' mutating actions (fixes and optimizations
+// applied, approvals granted, units created) with who took them, when, on
+// what input and with what result. Each app keeps its recent entries in
+// memory and writes every entry to ConfigHub as a unit of the space named by
+// AUDIT_SPACE, so GET /api/v1/audit on any app lists the entries of all of them:
+//
+//	GET /api/v1/audit?app=drift-detector&action=fix.applied&actor=alice&since=24h&limit=50
+//
+// Entries recorded under a correlation ID (see pkg/correlation) carry it,
+// so ?correlation=<id> lists what every app did about one incident.
+//
+// Without an audit space only the app's own recent entries are listed.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/tenants"
+)
+
+// Actions recorded by the apps
+const (
+	FixApplied             = "fix.applied"              // drift-detector or security-drift-detector corrected drifted units
+	OptimizationApplied    = "optimization.applied"     // cost-optimizer applied a recommendation
+	OptimizationRolledBack = "optimization.rolled-back" // cost-optimizer undid one that did not save what it predicted, or was told to
+	ApprovalGranted        = "approval.granted"         // cost-impact-monitor approved an escalated change
+	CleanupApplied         = "cleanup.applied"          // orphan-cleaner deleted a resource whose cleanup was approved
+	RotationApproved       = "rotation.approved"        // secret-rotation-monitor approved, or itself performed, a Secret rotation
+	UnitCreated            = "unit.created"             // an app created a ConfigHub unit
+	PolicyDecided          = "policy.decided"           // an app asked pkg/policy whether to act on its own
+	APICalled              = "api.called"               // a user or team called an endpoint that may change state, see Calls
+)
+
+// Label marks the ConfigHub units holding audit entries
+const Label = "audit-entry"
+
+// memoryEntries is how many recent entries an app keeps in memory
+const memoryEntries = 500
+
+// Entry is one recorded action
+type Entry struct {
+	ID            string          `json:"id"`
+	Time          time.Time       `json:"time"`
+	App           string          `json:"app"`
+	Actor         string          `json:"actor"`
+	Team          string          `json:"team,omitempty"` // whose request or space it was
+	Action        string          `json:"action"`
+	Target        string          `json:"target,omitempty"` // unit or space acted on
+	Input         json.RawMessage `json:"input,omitempty"`
+	Result        string          `json:"result"` // "ok" or "error"
+	Error         string          `json:"error,omitempty"`
+	CorrelationID string          `json:"correlation_id,omitempty"` // the incident acted on, see pkg/correlation
+}
+
+// Writer stores an entry as a ConfigHub unit with the given slug, labels and data
+type Writer func(ctx context.Context, slug string, labels map[string]string, data string) error
+
+// Reader returns the data of the ConfigHub units matching a where clause
+type Reader func(ctx context.Context, where string) ([]string, error)
+
+// Log is an app's audit trail. It is safe for concurrent use; a nil Log
+// records nothing.
+type Log struct {
+	app    string
+	writer Writer
+	reader Reader
+	now    func() time.Time
+
+	mu     sync.Mutex
+	recent []Entry // oldest first
+}
+
+// New returns the audit log of app; with a nil writer and reader entries are
+// only kept in memory
+func New(app string, writer Writer, reader Reader) *Log {
+	return &Log{app: app, writer: writer, reader: reader, now: time.Now}
+}
+
+type actorKey struct{}
+
+// WithActor names who is acting for the rest of ctx, e.g. an approver
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns who acts in ctx: the name given to WithActor, the user
+// signed in through pkg/auth, or "system" for the app itself. The team, if
+// any, is recorded apart.
+func Actor(ctx context.Context) string {
+	if actor, _ := ctx.Value(actorKey{}).(string); actor != "" {
+		return actor
+	}
+	if user := auth.FromContext(ctx); user != nil {
+		return user.Email
+	}
+	return "system"
+}
+
+// Record adds an entry for action on target, taken by the actor of ctx with
+// input and ending with err, and tags it with the correlation ID of ctx. A
+// failure to store it in ConfigHub is logged; the entry is still kept in
+// memory.
+func (l *Log) Record(ctx context.Context, action, target string, input interface{}, err error) Entry {
+	if l == nil {
+		return Entry{}
+	}
+	e := Entry{
+		ID:            uuid.NewString(),
+		Time:          l.now().UTC(),
+		App:           l.app,
+		Actor:         Actor(ctx),
+		Action:        action,
+		Target:        target,
+		Result:        "ok",
+		CorrelationID: correlation.FromContext(ctx),
+	}
+	if team := tenants.FromContext(ctx); team != nil {
+		e.Team = team.Name
+	}
+	if input != nil {
+		if data, jsonErr := json.Marshal(input); jsonErr == nil {
+			e.Input = data
+		}
+	}
+	if err != nil {
+		e.Result, e.Error = "error", err.Error()
+	}
+
+	l.mu.Lock()
+	l.recent = append(l.recent, e)
+	if len(l.recent) > memoryEntries {
+		l.recent = l.recent[len(l.recent)-memoryEntries:]
+	}
+	l.mu.Unlock()
+
+	if l.writer != nil {
+		data, _ := json.Marshal(e)
+		if err := l.writer(ctx, Slug(e), Labels(e), string(data)); err != nil {
+			slog.Warn("Failed to store audit entry", "action", action, "target", target, logging.Err(err))
+		}
+	}
+	return e
+}
+
+// Slug names the unit of an entry; slugs sort by time
+func Slug(e Entry) string {
+	return fmt.Sprintf("audit-%s-%s", e.Time.Format("20060102-150405"), e.ID[:8])
+}
+
+// Labels are the unit labels of an entry, the fields a Query filters on in ConfigHub
+func Labels(e Entry) map[string]string {
+	labels := map[string]string{
+		Label:    "true",
+		"app":    e.App,
+		"action": e.Action,
+		"result": e.Result,
+	}
+	if e.CorrelationID != "" {
+		labels[correlation.Label] = e.CorrelationID
+	}
+	return labels
+}
+
+// Query selects entries; empty fields match everything
+type Query struct {
+	App         string
+	Action      string
+	Actor       string
+	Team        string
+	Target      string
+	Correlation string
+	Since       time.Time
+	Limit       int // newest entries first; 0 for all
+}
+
+// Where returns the ConfigHub where clause of the units q may match; actor,
+// target and time are checked on the decoded entries
+func (q Query) Where() string {
+	clauses := []string{fmt.Sprintf("Labels['%s'] = 'true'", Label)}
+	if q.App != "" {
+		clauses = append(clauses, fmt.Sprintf("Labels['app'] = '%s'", quote(q.App)))
+	}
+	if q.Action != "" {
+		clauses = append(clauses, fmt.Sprintf("Labels['action'] = '%s'", quote(q.Action)))
+	}
+	if q.Correlation != "" {
+		clauses = append(clauses, fmt.Sprintf("Labels['%s'] = '%s'", correlation.Label, quote(q.Correlation)))
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+func quote(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// Matches reports whether e is selected by q
+func (q Query) Matches(e Entry) bool {
+	return (q.App == "" || e.App == q.App) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Team == "" || e.Team == q.Team) &&
+		(q.Target == "" || e.Target == q.Target) &&
+		(q.Correlation == "" || e.CorrelationID == q.Correlation) &&
+		!e.Time.Before(q.Since)
+}
+
+// Entries returns the entries selected by q, newest first, and where they
+// were read from: "confighub", or "memory" when there is no audit space or
+// it cannot be read, in which case only this app's recent entries are seen
+func (l *Log) Entries(ctx context.Context, q Query) ([]Entry, string, error) {
+	if l == nil {
+		return nil, "memory", nil
+	}
+	var candidates []Entry
+	source := "memory"
+	if l.reader != nil {
+		data, err := l.reader(ctx, q.Where())
+		if err == nil {
+			source = "confighub"
+			for _, d := range data {
+				var e Entry
+				if err := json.Unmarshal([]byte(d), &e); err != nil {
+					return nil, source, fmt.Errorf("decode audit entry: %w", err)
+				}
+				candidates = append(candidates, e)
+			}
+		} else {
+			slog.Warn("Failed to read audit entries from ConfigHub, listing recent ones", logging.Err(err))
+		}
+	}
+	if source == "memory" {
+		l.mu.Lock()
+		candidates = append(candidates, l.recent...)
+		l.mu.Unlock()
+	}
+
+	var entries []Entry
+	for _, e := range candidates {
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, source, nil
+}
+
+// ParseQuery reads a Query from the parameters app, action, actor, target,
+// correlation, since (a duration like 24h or an RFC 3339 time) and limit
+func ParseQuery(r *http.Request, now time.Time) (Query, error) {
+	params := r.URL.Query()
+	q := Query{
+		App:         params.Get("app"),
+		Action:      params.Get("action"),
+		Actor:       params.Get("actor"),
+		Target:      params.Get("target"),
+		Correlation: params.Get("correlation"),
+		Limit:       100,
+	}
+	if since := params.Get("since"); since != "" {
+		if d, err := time.ParseDuration(since); err == nil {
+			q.Since = now.Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			q.Since = t
+		} else {
+			return q, fmt.Errorf("since %q is neither a duration nor an RFC 3339 time", since)
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("limit %q is not a count", limit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// Page is the body of GET /api/v1/audit
+type Page struct {
+	Entries []Entry `json:"entries"`
+	Source  string  `json:"source"` // where the entries were read from
+}
+
+// Operations document the audit route of an app's API
+var Operations = []api.Operation{{
+	Summary: "Audit entries, newest first",
+	Query: []api.Param{
+		{Name: "app"}, {Name: "action"}, {Name: "actor"}, {Name: "target"}, {Name: "correlation"},
+		{Name: "since", Description: "a duration like 24h or an RFC 3339 time"},
+		{Name: "limit", Description: "at most this many entries, 100 when unset"},
+	},
+	Response: Page{},
+}}
+
+// ServeHTTP lists entries (GET /api/v1/audit) as JSON. A team that sees only
+// some spaces gets the actions taken with its token.
+func (l *Log) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := ParseQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if team := tenants.FromContext(r.Context()); !team.Unrestricted() {
+		q.Team = team.Name
+	}
+
+	entries, source, err := l.Entries(r.Context(), q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Page{Entries: entries, Source: source}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Call is the input of an APICalled entry
+type Call struct {
+	Role   string `json:"role,omitempty"` // the caller's, when signed in
+	Status int    `json:"status"`
+}
+
+// Calls records every API request (below /api/) to next that may change
+// state, by any method but GET, HEAD and OPTIONS, as an APICalled entry on
+// "<method> <path>", failed when the response is an error. Mount it inside
+// auth.Guard.Protect and tenants.Registry.Protect so the entry names the
+// user and team. A nil Log returns next unchanged.
+func (l *Log) Calls(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case !strings.HasPrefix(r.URL.Path, "/api/"),
+			r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		call := Call{Status: rec.status}
+		if user := auth.FromContext(r.Context()); user != nil {
+			call.Role = user.Role.String()
+		}
+		var err error
+		if rec.status >= http.StatusBadRequest {
+			err = fmt.Errorf("%d %s", rec.status, http.StatusText(rec.status))
+		}
+		l.Record(r.Context(), APICalled, r.Method+" "+r.URL.Path, call, err)
+	})
+}
+
+// statusRecorder remembers the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets server-sent events through
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/tenants"
+)
+
+// fakeHub keeps units by slug and answers where clauses on the app and action labels
+type fakeHub struct {
+	units  map[string]map[string]string // slug -> labels, plus "data"
+	down   bool
+	wheres []string
+}
+
+func (h *fakeHub) write(ctx context.Context, slug string, labels map[string]string, data string) error {
+	if h.down {
+		return errors.New("confighub unavailable")
+	}
+	unit := map[string]string{"data": data}
+	for k, v := range labels {
+		unit[k] = v
+	}
+	h.units[slug] = unit
+	return nil
+}
+
+func (h *fakeHub) read(ctx context.Context, where string) ([]string, error) {
+	if h.down {
+		return nil, errors.New("confighub unavailable")
+	}
+	h.wheres = append(h.wheres, where)
+	var data []string
+	for _, unit := range h.units {
+		if unit[Label] != "true" ||
+			strings.Contains(where, "Labels['app']") && !strings.Contains(where, "'"+unit["app"]+"'") {
+			continue
+		}
+		data = append(data, unit["data"])
+	}
+	return data, nil
+}
+
+func TestRecord(t *testing.T) {
+	hub := &fakeHub{units: map[string]map[string]string{}}
+	drift := New("drift-detector", hub.write, hub.read)
+	monitor := New("cost-impact-monitor", hub.write, hub.read)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	drift.now = func() time.Time { return now }
+	monitor.now = func() time.Time { return now.Add(time.Minute) }
+
+	fix := drift.Record(context.Background(), FixApplied, "backend-api", map[string]int{"replicas": 3}, nil)
+	if fix.Actor != "system" || fix.Result != "ok" || string(fix.Input) != `{"replicas":3}` {
+		t.Errorf("Unexpected fix entry: %+v", fix)
+	}
+	ctx := WithActor(tenants.WithTeam(context.Background(), &tenants.Team{Name: "payments"}), "alice")
+	ctx = correlation.With(ctx, "incident-1")
+	approval := monitor.Record(ctx, ApprovalGranted, "payments-api", nil, errors.New("not escalated"))
+	if approval.Actor != "alice" || approval.Team != "payments" || approval.Result != "error" || approval.Error != "not escalated" ||
+		approval.CorrelationID != "incident-1" {
+		t.Errorf("Unexpected approval entry: %+v", approval)
+	}
+	if actor := Actor(auth.WithUser(context.Background(), &auth.User{Email: "bob@example.com"})); actor != "bob@example.com" {
+		t.Errorf("Expected the signed-in user as actor, got %s", actor)
+	}
+	if len(hub.units) != 2 || hub.units[Slug(fix)]["action"] != FixApplied {
+		t.Fatalf("Expected both entries in ConfigHub, got %v", hub.units)
+	}
+	if _, ok := hub.units[Slug(fix)][correlation.Label]; ok || hub.units[Slug(approval)][correlation.Label] != "incident-1" {
+		t.Errorf("Expected only the approval labeled with its correlation ID, got %v", hub.units)
+	}
+	if !strings.HasPrefix(Slug(fix), "audit-20260301-120000-") {
+		t.Errorf("Unexpected slug %s", Slug(fix))
+	}
+
+	// Either app lists both, newest first
+	entries, source, err := drift.Entries(context.Background(), Query{})
+	if err != nil || source != "confighub" || len(entries) != 2 || entries[0].ID != approval.ID {
+		t.Fatalf("Entries = %+v, %s, %v", entries, source, err)
+	}
+	entries, _, _ = drift.Entries(context.Background(), Query{App: "cost-impact-monitor", Actor: "alice"})
+	if len(entries) != 1 || entries[0].Target != "payments-api" {
+		t.Errorf("Filtered entries = %+v", entries)
+	}
+	if want := "Labels['audit-entry'] = 'true' AND Labels['app'] = 'cost-impact-monitor'"; hub.wheres[len(hub.wheres)-1] != want {
+		t.Errorf("Where = %s", hub.wheres[len(hub.wheres)-1])
+	}
+	entries, _, _ = drift.Entries(context.Background(), Query{Correlation: "incident-1"})
+	if len(entries) != 1 || entries[0].ID != approval.ID {
+		t.Errorf("Entries of incident-1 = %+v", entries)
+	}
+	if want := "Labels['audit-entry'] = 'true' AND Labels['correlation-id'] = 'incident-1'"; hub.wheres[len(hub.wheres)-1] != want {
+		t.Errorf("Where = %s", hub.wheres[len(hub.wheres)-1])
+	}
+	if entries, _, _ := drift.Entries(context.Background(), Query{Since: now.Add(30 * time.Second)}); len(entries) != 1 {
+		t.Errorf("Expected 1 entry since 12:00:30, got %d", len(entries))
+	}
+
+	// While ConfigHub is down entries are still recorded, and listed from memory
+	hub.down = true
+	drift.now = func() time.Time { return now.Add(2 * time.Minute) }
+	drift.Record(context.Background(), FixApplied, "frontend", nil, nil)
+	entries, source, err = drift.Entries(context.Background(), Query{Limit: 1})
+	if err != nil || source != "memory" || len(entries) != 1 || entries[0].Target != "frontend" {
+		t.Errorf("Entries while down = %+v, %s, %v", entries, source, err)
+	}
+
+	var nilLog *Log
+	if e := nilLog.Record(context.Background(), UnitCreated, "x", nil, nil); e.ID != "" {
+		t.Error("Expected a nil Log to record nothing")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	log := New("cost-optimizer", nil, nil)
+	payments := &tenants.Team{Name: "payments", Spaces: []string{"payments-*"}}
+	log.Record(context.Background(), OptimizationApplied, "api", nil, nil)
+	log.Record(tenants.WithTeam(context.Background(), payments), UnitCreated, "payments-report", nil, nil)
+
+	for _, tt := range []struct {
+		name  string
+		query string
+		team  *tenants.Team
+		code  int
+		want  int
+	}{
+		{"all", "", nil, http.StatusOK, 2},
+		{"by action", "?action=optimization.applied", nil, http.StatusOK, 1},
+		{"since", "?since=1h&limit=5", nil, http.StatusOK, 2},
+		{"restricted team", "", payments, http.StatusOK, 1},
+		{"bad since", "?since=yesterday", nil, http.StatusBadRequest, 0},
+		{"bad limit", "?limit=-1", nil, http.StatusBadRequest, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/audit"+tt.query, nil)
+			if tt.team != nil {
+				req = req.WithContext(tenants.WithTeam(req.Context(), tt.team))
+			}
+			rec := httptest.NewRecorder()
+			log.ServeHTTP(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("Expected %d, got %d: %s", tt.code, rec.Code, rec.Body)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var body struct {
+				Entries []Entry `json:"entries"`
+				Source  string  `json:"source"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Entries) != tt.want || body.Source != "memory" {
+				t.Errorf("Expected %d entries from memory, got %d from %s", tt.want, len(body.Entries), body.Source)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	log.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/audit", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	log := New("cost-impact-monitor", nil, nil)
+	handler := log.Calls(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/queue/missing/approve" {
+			http.NotFound(w, r)
+		}
+	}))
+
+	alice := &auth.User{Email: "alice@example.com", Role: auth.RoleApprover}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/spaces", nil),
+		httptest.NewRequest(http.MethodPost, "/webhooks/generic", nil),
+		httptest.NewRequest(http.MethodPost, "/api/v1/queue/a1/approve", nil).WithContext(auth.WithUser(context.Background(), alice)),
+		httptest.NewRequest(http.MethodPost, "/api/v1/queue/missing/approve", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries, _, _ := log.Entries(context.Background(), Query{Action: APICalled})
+	if len(entries) != 2 {
+		t.Fatalf("Expected the 2 POSTs recorded, got %+v", entries)
+	}
+	byTarget := map[string]Entry{}
+	for _, e := range entries {
+		byTarget[e.Target] = e
+	}
+	approved := byTarget["POST /api/v1/queue/a1/approve"]
+	if approved.Actor != "alice@example.com" || approved.Result != "ok" || string(approved.Input) != `{"role":"approver","status":200}` {
+		t.Errorf("Unexpected entry %+v", approved)
+	}
+	if missing := byTarget["POST /api/v1/queue/missing/approve"]; missing.Actor != "system" || missing.Result != "error" || missing.Error != "404 Not Found" {
+		t.Errorf("Unexpected entry %+v", missing)
+	}
+
+	var nilLog *Log
+	if nilLog.Calls(handler) == nil {
+		t.Error("A nil log must return the handler")
+	}
+}
@@ -0,0 +1,522 @@
+// Package auth puts the apps' dashboards and APIs behind an organization's
+// identity provider. Each app mounts the same middleware (Guard.Protect),
+// configured by an auth file:
+//
+//	issuer_url: https://login.example.com/realms/platform
+//	client_id: devops-apps
+//	roles:
+//	  viewer: [engineering]     # may read dashboards and APIs
+//	  operator: [platform-team] # may also apply, retry and acknowledge
+//	  approver: [change-board]  # may also approve, reject and roll back
+//	  admin: [sre-leads]        # may also pin cost baselines
+//	users:
+//	  alice@example.com: admin  # wins over her groups
+//
+// Browsers are sent through the OpenID Connect authorization code flow and
+// get a signed session cookie; API clients send an ID token as a bearer
+// token. A user's role is the one users gives their email, else the highest
+// given to a group in the groups claim of the ID token. Each role may do
+// what the ones before it may. GET and HEAD requests need viewer, approving
+// or rejecting anything and rolling back a recommendation approver, pinning
+// a baseline admin and anything else operator (see DefaultRules); rules in
+// the file come first. Without roles
+// and users every user the provider signs in is an admin.
+//
+// The client secret is read from oidc-client-secret and the session key
+// from auth-session-secret in the app's SECRETS_DIR; client_secret and
+// session_secret may instead reference environment variables as ${NAME}.
+// Apps given the same session secret share one login, since cookies do not
+// depend on the port.
+//
+// Without an auth file the dashboards stay open, as before.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v3"
+)
+
+// Routes the guard serves itself
+const (
+	LoginPath    = "/auth/login"
+	CallbackPath = "/auth/callback"
+	LogoutPath   = "/auth/logout"
+)
+
+const (
+	sessionCookie = "devops_session"
+	stateCookie   = "devops_oidc_state"
+)
+
+// DefaultSessionTTL is how long a browser login lasts
+const DefaultSessionTTL = 8 * time.Hour
+
+// Config is the auth file
+type Config struct {
+	IssuerURL     string          `yaml:"issuer_url"`
+	ClientID      string          `yaml:"client_id"`
+	ClientSecret  string          `yaml:"client_secret"`  // usually left to oidc-client-secret
+	RedirectURL   string          `yaml:"redirect_url"`   // empty derives <scheme>://<host>/auth/callback from each request
+	GroupsClaim   string          `yaml:"groups_claim"`   // ID token claim listing the user's groups; default "groups"
+	Roles         Roles           `yaml:"roles"`          // empty, like users, makes every user an admin
+	Users         map[string]Role `yaml:"users"`          // email to role, whatever the groups
+	Rules         []Rule          `yaml:"rules"`          // checked before DefaultRules
+	AllowedEmails []string        `yaml:"allowed_emails"` // when set, only these users sign in
+	SessionTTL    time.Duration   `yaml:"session_ttl"`    // default 8h
+	SessionSecret string          `yaml:"session_secret"` // usually left to auth-session-secret; random when empty
+}
+
+// Roles maps identity provider groups to roles
+type Roles struct {
+	Viewer   []string `yaml:"viewer"`
+	Operator []string `yaml:"operator"` // operators can also view
+	Approver []string `yaml:"approver"` // approvers can also operate
+	Admin    []string `yaml:"admin"`    // admins can do everything
+}
+
+// Role is what a user may do; each role may do what the lower ones may
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleApprover
+	RoleAdmin
+)
+
+var roleNames = []string{"none", "viewer", "operator", "approver", "admin"}
+
+func (r Role) String() string {
+	if r < RoleNone || int(r) >= len(roleNames) {
+		return "none"
+	}
+	return roleNames[r]
+}
+
+// ParseRole returns the role named s
+func ParseRole(s string) (Role, error) {
+	for role := RoleViewer; role <= RoleAdmin; role++ {
+		if strings.EqualFold(s, role.String()) {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q (viewer, operator, approver or admin)", s)
+}
+
+// UnmarshalYAML reads a role by name
+func (r *Role) UnmarshalYAML(value *yaml.Node) error {
+	role, err := ParseRole(value.Value)
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// groups returns the groups given each role, the highest role first
+func (r Roles) groups() map[Role][]string {
+	return map[Role][]string{RoleAdmin: r.Admin, RoleApprover: r.Approver, RoleOperator: r.Operator, RoleViewer: r.Viewer}
+}
+
+// role returns the role of the user with email, a member of groups
+func (c Config) role(email string, groups []string) Role {
+	for user, role := range c.Users {
+		if strings.EqualFold(user, email) {
+			return role
+		}
+	}
+	byRole := c.Roles.groups()
+	if len(c.Users) == 0 && len(byRole[RoleViewer])+len(byRole[RoleOperator])+len(byRole[RoleApprover])+len(byRole[RoleAdmin]) == 0 {
+		return RoleAdmin
+	}
+	for role := RoleAdmin; role > RoleNone; role-- {
+		for _, g := range groups {
+			for _, want := range byRole[role] {
+				if g == want {
+					return role
+				}
+			}
+		}
+	}
+	return RoleNone
+}
+
+// Rule gives the role requests need: those with one of Methods (any when
+// empty) to a path matching Path once the /api/v1 or /api prefix is cut
+type Rule struct {
+	Methods []string `yaml:"methods"`
+	Path    string   `yaml:"path"` // a path.Match pattern, e.g. /queue/*/approve
+	Role    Role     `yaml:"role"`
+}
+
+// DefaultRules are checked after the auth file's; requests they do not
+// match need viewer to read and operator for anything else
+var DefaultRules = []Rule{
+	{Methods: []string{http.MethodPost}, Path: "/whatif", Role: RoleViewer}, // computes, changes nothing
+	{Methods: []string{http.MethodPost}, Path: "/*/*/approve", Role: RoleApprover},
+	{Methods: []string{http.MethodPost}, Path: "/*/*/reject", Role: RoleApprover},
+	{Methods: []string{http.MethodPost}, Path: "/recommendations/*/rollback", Role: RoleApprover},
+	{Methods: []string{http.MethodPost, http.MethodDelete}, Path: "/spaces/*/baseline", Role: RoleAdmin},
+	{Methods: []string{http.MethodPost}, Path: "/spaces/*/baseline/reset", Role: RoleAdmin},
+}
+
+// matches reports whether the rule applies to method on path
+func (rule Rule) matches(method, path string) bool {
+	if len(rule.Methods) > 0 && !containsFold(rule.Methods, method) {
+		return false
+	}
+	ok, _ := pathpkg.Match(rule.Path, path)
+	return ok
+}
+
+// User is who a request was signed in as
+type User struct {
+	Email  string   `json:"email"`
+	Groups []string `json:"groups,omitempty"`
+	Role   Role     `json:"-"`
+}
+
+// Guard checks a request's login. A nil Guard lets every request through.
+type Guard struct {
+	cfg          Config
+	clientSecret string
+	verifier     *oidc.IDTokenVerifier
+	endpoint     oauth2.Endpoint
+	secret       []byte // signs session cookies
+	tokens       func(*http.Request) bool
+}
+
+// Load reads the auth file at path, looking up secret files with secret
+// (see config.Effective.ReadSecretFile), and discovers the provider. A
+// missing file returns a nil Guard.
+func Load(ctx context.Context, path string, secret func(name string) (string, error)) (*Guard, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read auth config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse auth config: %w", err)
+	}
+	cfg.ClientSecret, cfg.SessionSecret = os.ExpandEnv(cfg.ClientSecret), os.ExpandEnv(cfg.SessionSecret)
+	for _, s := range []struct {
+		file  string
+		value *string
+	}{{"oidc-client-secret", &cfg.ClientSecret}, {"auth-session-secret", &cfg.SessionSecret}} {
+		value, err := secret(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("auth config: %w", err)
+		}
+		if value != "" {
+			*s.value = value
+		}
+	}
+	return New(ctx, cfg)
+}
+
+// New validates cfg and discovers the provider's endpoints and keys
+func New(ctx context.Context, cfg Config) (*Guard, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" {
+		return nil, errors.New("auth config needs issuer_url and client_id")
+	}
+	if cfg.RedirectURL != "" {
+		if u, err := url.Parse(cfg.RedirectURL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("auth config: bad redirect_url %q", cfg.RedirectURL)
+		}
+	}
+	for _, rule := range cfg.Rules {
+		if _, err := pathpkg.Match(rule.Path, ""); err != nil || rule.Path == "" || rule.Role == RoleNone {
+			return nil, fmt.Errorf("auth config: rule %q needs a valid path and a role", rule.Path)
+		}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = DefaultSessionTTL
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("discover OIDC provider: %w", err)
+	}
+
+	secret := []byte(cfg.SessionSecret)
+	if len(secret) == 0 {
+		// Logins won't survive a restart, but nothing has to be configured
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("generate session secret: %w", err)
+		}
+	}
+	clientSecret := cfg.ClientSecret
+	cfg.ClientSecret, cfg.SessionSecret = "", ""
+
+	return &Guard{
+		cfg:          cfg,
+		clientSecret: clientSecret,
+		verifier:     provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		endpoint:     provider.Endpoint(),
+		secret:       secret,
+	}, nil
+}
+
+// AcceptTokens lets requests that check accepts, such as those carrying a
+// team's API token, through without a login. Their role is not checked.
+func (g *Guard) AcceptTokens(check func(*http.Request) bool) {
+	if g != nil {
+		g.tokens = check
+	}
+}
+
+type contextKey struct{}
+
+// WithUser returns ctx carrying user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// FromContext returns the user a request was signed in as, nil without a
+// login (no auth file, a public path or an accepted token)
+func FromContext(ctx context.Context) *User {
+	user, _ := ctx.Value(contextKey{}).(*User)
+	return user
+}
+
+// Protect requires a login on every request to next except those under the
+// public path prefixes (metrics, webhooks with their own signatures, static
+// files), checks the user's role against the method and stores the user in
+// the request context. It serves the login routes itself. A nil Guard
+// returns next unchanged.
+func (g *Guard) Protect(next http.Handler, public ...string) http.Handler {
+	if g == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case LoginPath:
+			g.handleLogin(w, r)
+			return
+		case CallbackPath:
+			g.handleCallback(w, r)
+			return
+		case LogoutPath:
+			http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		for _, prefix := range public {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		user, err := g.authenticate(r)
+		switch {
+		case err != nil:
+			http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		case user == nil && g.tokens != nil && g.tokens(r):
+			next.ServeHTTP(w, r)
+			return
+		case user == nil && (r.Method != http.MethodGet || strings.HasPrefix(r.URL.Path, "/api/")):
+			// Only page loads are sent to the provider; API calls just fail
+			w.Header().Set("WWW-Authenticate", `Bearer realm="devops-apps"`)
+			http.Error(w, "login required", http.StatusUnauthorized)
+			return
+		case user == nil:
+			http.Redirect(w, r, LoginPath+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+
+		if need := g.Required(r); user.Role < need {
+			slog.Warn("Request denied", "email", user.Email, "role", user.Role.String(), "needs", need.String(), "method", r.Method, "path", r.URL.Path)
+			http.Error(w, fmt.Sprintf("%s needs the %s role", user.Email, need), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
+
+// Required returns the role r needs: that of the first of the auth file's
+// rules and DefaultRules it matches, else viewer to read and operator for
+// anything else
+func (g *Guard) Required(r *http.Request) Role {
+	path := r.URL.Path
+	for _, prefix := range []string{"/api/v1/", "/api/"} {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			path = "/" + rest
+			break
+		}
+	}
+	var rules []Rule
+	if g != nil {
+		rules = g.cfg.Rules
+	}
+	for _, rule := range append(append([]Rule(nil), rules...), DefaultRules...) {
+		if rule.matches(r.Method, path) {
+			return rule.Role
+		}
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RoleViewer
+	default:
+		return RoleOperator
+	}
+}
+
+// authenticate returns the user of a bearer ID token or session cookie, nil
+// when the request has neither. A bearer token that is not a JWT is left to
+// the tokens check.
+func (g *Guard) authenticate(r *http.Request) (*User, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.Count(token, ".") == 2 {
+		return g.verifyIDToken(r.Context(), token)
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if s, ok := verifySession(g.secret, cookie.Value, time.Now()); ok {
+			return &User{Email: s.Email, Groups: s.Groups, Role: g.cfg.role(s.Email, s.Groups)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// verifyIDToken checks an ID token and returns its user
+func (g *Guard) verifyIDToken(ctx context.Context, raw string) (*User, error) {
+	token, err := g.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, errors.New("email not verified")
+	}
+
+	user := &User{Groups: stringList(claims[g.cfg.GroupsClaim])}
+	if user.Email, _ = claims["email"].(string); user.Email == "" {
+		user.Email = token.Subject
+	}
+	if len(g.cfg.AllowedEmails) > 0 && !containsFold(g.cfg.AllowedEmails, user.Email) {
+		return nil, fmt.Errorf("%s is not allowed", user.Email)
+	}
+	user.Role = g.cfg.role(user.Email, user.Groups)
+	return user, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList reads a claim that is a list of strings or a single string
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// oauth returns the client config, redirecting back to the host r came to
+// unless redirect_url is set
+func (g *Guard) oauth(r *http.Request) *oauth2.Config {
+	redirect := g.cfg.RedirectURL
+	if redirect == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		redirect = scheme + "://" + r.Host + CallbackPath
+	}
+	return &oauth2.Config{
+		ClientID:     g.cfg.ClientID,
+		ClientSecret: g.clientSecret,
+		RedirectURL:  redirect,
+		Endpoint:     g.endpoint,
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile", g.cfg.GroupsClaim},
+	}
+}
+
+// handleLogin starts the authorization code flow, remembering the page to
+// return to
+func (g *Guard) handleLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := newState(r.URL.Query().Get("next"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Value: state, Path: "/auth/",
+		MaxAge: 300, HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, g.oauth(r).AuthCodeURL(state), http.StatusFound)
+}
+
+// handleCallback exchanges the code, verifies the ID token and starts a
+// session
+func (g *Guard) handleCallback(w http.ResponseWriter, r *http.Request) {
+	state, err := r.Cookie(stateCookie)
+	if err != nil || r.URL.Query().Get("state") != state.Value {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	token, err := g.oauth(r).Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, "exchange code: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "no id_token in token response", http.StatusUnauthorized)
+		return
+	}
+	user, err := g.verifyIDToken(r.Context(), rawIDToken)
+	if err != nil {
+		http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if user.Role == RoleNone {
+		http.Error(w, user.Email+" is in no group allowed to use this app", http.StatusForbidden)
+		return
+	}
+
+	slog.Info("User logged in", "email", user.Email, "role", user.Role.String())
+	value := signSession(g.secret, session{Email: user.Email, Groups: user.Groups, Expires: time.Now().Add(g.cfg.SessionTTL).Unix()})
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/auth/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: value, Path: "/",
+		MaxAge: int(g.cfg.SessionTTL.Seconds()), HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, stateNext(state.Value), http.StatusFound)
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// fakeProvider is an OIDC provider issuing ID tokens for the users below
+type fakeProvider struct {
+	*httptest.Server
+	signer jose.Signer
+	codes  map[string]map[string]interface{} // authorization code to claims
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "k1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{signer: signer, codes: map[string]map[string]interface{}{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "k1", Algorithm: "RS256", Use: "sig"}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		claims, ok := p.codes[r.PostForm.Get("code")]
+		if !ok {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at", "token_type": "Bearer", "expires_in": 3600, "id_token": p.idToken(t, claims),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// idToken signs an ID token for the test client with claims
+func (p *fakeProvider) idToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload := map[string]interface{}{
+		"iss": p.URL, "aud": "devops-apps", "sub": "u1",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	data, _ := json.Marshal(payload)
+	signed, err := p.signer.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newGuard(t *testing.T, p *fakeProvider) *Guard {
+	t.Helper()
+	guard, err := New(context.Background(), Config{
+		IssuerURL: p.URL,
+		ClientID:  "devops-apps",
+		Roles:     Roles{Viewer: []string{"engineering"}, Operator: []string{"platform"}, Approver: []string{"change-board"}, Admin: []string{"sre-leads"}},
+		Users:     map[string]Role{"Boss@example.com": RoleAdmin},
+		Rules:     []Rule{{Methods: []string{http.MethodPost}, Path: "/queue/*/expire", Role: RoleApprover}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return guard
+}
+
+// echo answers with the signed-in user's email
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if user := FromContext(r.Context()); user != nil {
+		w.Write([]byte(user.Email))
+	}
+})
+
+func TestProtect(t *testing.T) {
+	p := newFakeProvider(t)
+	guard := newGuard(t, p)
+	guard.AcceptTokens(func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer team-token" })
+	handler := guard.Protect(echo, "/metrics")
+
+	viewer := p.idToken(t, map[string]interface{}{"email": "vi@example.com", "groups": []string{"engineering"}})
+	operator := p.idToken(t, map[string]interface{}{"email": "op@example.com", "groups": []string{"engineering", "platform"}})
+	approver := p.idToken(t, map[string]interface{}{"email": "ap@example.com", "groups": []string{"change-board", "engineering"}})
+	admin := p.idToken(t, map[string]interface{}{"email": "ad@example.com", "groups": "sre-leads"})
+	boss := p.idToken(t, map[string]interface{}{"email": "boss@example.com", "groups": "sales"})
+	outsider := p.idToken(t, map[string]interface{}{"email": "out@example.com", "groups": "sales"})
+	unverified := p.idToken(t, map[string]interface{}{"email": "un@example.com", "email_verified": false, "groups": []string{"platform"}})
+
+	for _, tc := range []struct {
+		name, method, path, bearer string
+		want                       int
+		body                       string
+	}{
+		{"page without login", http.MethodGet, "/spaces/a?x=1", "", http.StatusFound, ""},
+		{"API without login", http.MethodGet, "/api/snapshot", "", http.StatusUnauthorized, ""},
+		{"public", http.MethodGet, "/metrics", "", http.StatusOK, ""},
+		{"viewer reads", http.MethodGet, "/api/snapshot", viewer, http.StatusOK, "vi@example.com"},
+		{"viewer approves", http.MethodPost, "/api/escalations/1/approve", viewer, http.StatusForbidden, ""},
+		{"viewer asks what if", http.MethodPost, "/api/v1/whatif", viewer, http.StatusOK, "vi@example.com"},
+		{"viewer acknowledges", http.MethodPost, "/api/v1/escalations/1/acknowledge", viewer, http.StatusForbidden, ""},
+		{"operator acknowledges", http.MethodPost, "/api/v1/escalations/1/acknowledge", operator, http.StatusOK, "op@example.com"},
+		{"operator approves", http.MethodPost, "/api/v1/escalations/1/approve", operator, http.StatusForbidden, ""},
+		{"operator expires", http.MethodPost, "/api/v1/queue/1/expire", operator, http.StatusForbidden, ""},
+		{"approver approves", http.MethodPost, "/api/escalations/1/approve", approver, http.StatusOK, "ap@example.com"},
+		{"approver rejects", http.MethodPost, "/api/v1/orphans/a/reject", approver, http.StatusOK, "ap@example.com"},
+		{"approver expires", http.MethodPost, "/api/v1/queue/1/expire", approver, http.StatusOK, "ap@example.com"},
+		{"operator rolls back", http.MethodPost, "/api/v1/recommendations/1/rollback", operator, http.StatusForbidden, ""},
+		{"approver rolls back", http.MethodPost, "/api/v1/recommendations/1/rollback", approver, http.StatusOK, "ap@example.com"},
+		{"approver pins a baseline", http.MethodPost, "/api/v1/spaces/a/baseline", approver, http.StatusForbidden, ""},
+		{"admin pins a baseline", http.MethodPost, "/api/v1/spaces/a/baseline", admin, http.StatusOK, "ad@example.com"},
+		{"admin approves", http.MethodPost, "/api/v1/queue/1/approve", admin, http.StatusOK, "ad@example.com"},
+		{"static admin", http.MethodDelete, "/api/v1/spaces/a/baseline", boss, http.StatusOK, "boss@example.com"},
+		{"outsider", http.MethodGet, "/api/snapshot", outsider, http.StatusForbidden, ""},
+		{"unverified email", http.MethodGet, "/api/snapshot", unverified, http.StatusUnauthorized, ""},
+		{"forged token", http.MethodGet, "/api/snapshot", viewer[:len(viewer)-4] + "AAAA", http.StatusUnauthorized, ""},
+		{"team token", http.MethodPost, "/api/flags", "team-token", http.StatusOK, ""},
+		{"unknown token", http.MethodGet, "/api/snapshot", "other", http.StatusUnauthorized, ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want || (tc.body != "" && rec.Body.String() != tc.body) {
+			t.Errorf("%s: %d %q, want %d %q", tc.name, rec.Code, rec.Body, tc.want, tc.body)
+		}
+		if tc.want == http.StatusFound && rec.Header().Get("Location") != LoginPath+"?next="+url.QueryEscape(tc.path) {
+			t.Errorf("%s: redirected to %s", tc.name, rec.Header().Get("Location"))
+		}
+	}
+
+	guard.cfg.AllowedEmails = []string{"OP@example.com"}
+	for token, want := range map[string]int{operator: http.StatusOK, viewer: http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/api/snapshot", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("With allowed_emails: %d, want %d", rec.Code, want)
+		}
+	}
+
+	var nilGuard *Guard
+	if h := nilGuard.Protect(echo); h == nil {
+		t.Error("A nil guard must return the handler")
+	}
+}
+
+func TestLoginFlow(t *testing.T) {
+	p := newFakeProvider(t)
+	guard := newGuard(t, p)
+	handler := guard.Protect(echo)
+
+	// The login redirects to the provider, back to the host the app was reached on
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://cost.example.com:8083/auth/login?next=/spaces/prod", nil))
+	authorize, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(authorize.String(), p.URL+"/authorize") {
+		t.Fatalf("Expected a redirect to the provider, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if got := authorize.Query().Get("redirect_uri"); got != "http://cost.example.com:8083/auth/callback" {
+		t.Errorf("redirect_uri = %s", got)
+	}
+	state := authorize.Query().Get("state")
+	stateCookie := rec.Result().Cookies()[0]
+
+	callback := func(code, state string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://cost.example.com:8083/auth/callback?code="+code+"&state="+url.QueryEscape(state), nil)
+		req.AddCookie(stateCookie)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := callback("c1", "other"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a mismatched state to fail, got %d", rec.Code)
+	}
+	p.codes["outsider"] = map[string]interface{}{"email": "out@example.com", "groups": []string{"sales"}}
+	if rec := callback("outsider", state); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a user without a role to be refused, got %d", rec.Code)
+	}
+
+	p.codes["c1"] = map[string]interface{}{"email": "vi@example.com", "groups": []string{"engineering"}}
+	rec = callback("c1", state)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/spaces/prod" {
+		t.Fatalf("Expected a redirect to the page, got %d %s: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	var session *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookie {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly {
+		t.Fatalf("Expected an HttpOnly session cookie, got %v", rec.Result().Cookies())
+	}
+
+	// The session signs in later page loads, with the viewer role
+	for method, want := range map[string]int{http.MethodGet: http.StatusOK, http.MethodPost: http.StatusForbidden} {
+		req := httptest.NewRequest(method, "/api/snapshot", nil)
+		req.AddCookie(session)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s with a session: %d, want %d", method, rec.Code, want)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Now()
+	value := signSession(secret, session{Email: "a@example.com", Groups: []string{"g"}, Expires: now.Add(time.Hour).Unix()})
+
+	if s, ok := verifySession(secret, value, now); !ok || s.Email != "a@example.com" || len(s.Groups) != 1 {
+		t.Errorf("verifySession = %+v, %v", s, ok)
+	}
+	if _, ok := verifySession(secret, value, now.Add(2*time.Hour)); ok {
+		t.Error("Expected an expired session to be refused")
+	}
+	if _, ok := verifySession([]byte("other"), value, now); ok {
+		t.Error("Expected a session signed with another secret to be refused")
+	}
+
+	for next, want := range map[string]string{"/spaces/a?x=1": "/spaces/a?x=1", "": "/", "https://evil.example.com": "/", "//evil.example.com": "/", "/\\evil": "/"} {
+		state, err := newState(next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := stateNext(state); got != want {
+			t.Errorf("stateNext(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	secret := func(name string) (string, error) {
+		if name == "oidc-client-secret" {
+			return "from-file", nil
+		}
+		return "", nil
+	}
+
+	guard, err := Load(context.Background(), filepath.Join(dir, "missing.yaml"), secret)
+	if err != nil || guard != nil {
+		t.Errorf("Expected no guard without a file, got %v, %v", guard, err)
+	}
+
+	p := newFakeProvider(t)
+	path := filepath.Join(dir, "auth.yaml")
+	os.WriteFile(path, []byte("issuer_url: "+p.URL+"\nclient_id: devops-apps\nsession_ttl: 1h\n"), 0o600)
+	guard, err = Load(context.Background(), path, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guard.clientSecret != "from-file" || guard.cfg.SessionTTL != time.Hour || guard.cfg.GroupsClaim != "groups" {
+		t.Errorf("Unexpected guard %+v", guard)
+	}
+
+	os.WriteFile(path, []byte("issuer_url: "+p.URL+"\nclient_id: devops-apps\nusers:\n  alice@example.com: admin\nrules:\n- {path: /flags, role: approver}\n"), 0o600)
+	if guard, err = Load(context.Background(), path, secret); err != nil || guard.cfg.Users["alice@example.com"] != RoleAdmin || guard.cfg.Rules[0].Role != RoleApprover {
+		t.Errorf("Expected users and rules, got %+v, %v", guard, err)
+	}
+	os.WriteFile(path, []byte("issuer_url: "+p.URL+"\nclient_id: devops-apps\nusers:\n  alice@example.com: root\n"), 0o600)
+	if _, err := Load(context.Background(), path, secret); err == nil || !strings.Contains(err.Error(), "unknown role") {
+		t.Errorf("Expected an unknown role to fail, got %v", err)
+	}
+
+	os.WriteFile(path, []byte("client_id: devops-apps\n"), 0o600)
+	if _, err := Load(context.Background(), path, secret); err == nil || !strings.Contains(err.Error(), "issuer_url") {
+		t.Errorf("Expected a missing issuer to fail, got %v", err)
+	}
+}
+
+func TestRole(t *testing.T) {
+	unset := Config{}
+	if got := unset.role("a@example.com", nil); got != RoleAdmin {
+		t.Errorf("Without roles: %s, want admin", got)
+	}
+	cfg := Config{Roles: Roles{Viewer: []string{"eng"}, Approver: []string{"board"}}, Users: map[string]Role{"ops@example.com": RoleOperator}}
+	for _, tc := range []struct {
+		email  string
+		groups []string
+		want   Role
+	}{
+		{"a@example.com", []string{"eng", "board"}, RoleApprover},
+		{"a@example.com", []string{"eng"}, RoleViewer},
+		{"a@example.com", []string{"sales"}, RoleNone},
+		{"OPS@example.com", []string{"board"}, RoleOperator}, // users wins, even over a higher group
+	} {
+		if got := cfg.role(tc.email, tc.groups); got != tc.want {
+			t.Errorf("role(%s, %v) = %s, want %s", tc.email, tc.groups, got, tc.want)
+		}
+	}
+
+	if _, err := ParseRole("none"); err == nil {
+		t.Error("Expected none not to parse")
+	}
+	if role, err := ParseRole("Approver"); err != nil || role != RoleApprover {
+		t.Errorf("ParseRole(Approver) = %s, %v", role, err)
+	}
+}
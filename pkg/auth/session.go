@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// session is the content of the session cookie. Groups are kept rather
+// than the role so a change to the roles applies to existing logins.
+type session struct {
+	Email   string   `json:"email"`
+	Groups  []string `json:"groups,omitempty"`
+	Expires int64    `json:"exp"`
+}
+
+// signSession encodes s with an HMAC so the cookie can't be forged
+func signSession(secret []byte, s session) string {
+	data, _ := json.Marshal(s)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + sign(secret, payload)
+}
+
+// verifySession returns the session of a valid, unexpired cookie
+func verifySession(secret []byte, value string, now time.Time) (session, bool) {
+	var s session
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(sign(secret, payload))) {
+		return s, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &s) != nil || now.Unix() >= s.Expires {
+		return s, false
+	}
+	return s, true
+}
+
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newState returns a random login state carrying the page to return to
+func newState(next string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce) + "." + base64.RawURLEncoding.EncodeToString([]byte(next)), nil
+}
+
+// stateNext returns the page a login state returns to. Only local paths
+// are followed, so the login can't be used as an open redirect.
+func stateNext(state string) string {
+	_, encoded, _ := strings.Cut(state, ".")
+	next, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !strings.HasPrefix(string(next), "/") || strings.HasPrefix(string(next), "//") || strings.Contains(string(next), "\\") {
+		return "/"
+	}
+	return string(next)
+}
@@ -0,0 +1,333 @@
+// Package backup saves the ConfigHub state the apps create in a space - cost
+// analyses and recommendations, cost warnings, spend alerts, audit entries,
+// feature flags and prompt units, and the space's sets - to a gzipped
+// tarball, and restores it into the same or another space:
+//
+//	devops-apps backup -space prod -o prod.tar.gz
+//	devops-apps restore -space prod-copy prod.tar.gz
+//
+// Units keep their slug, labels, data and set membership; a restore maps the
+// sets by slug, so it works across spaces. Existing units are left alone
+// unless the restore overwrites them. Filters are not saved: ConfigHub has no
+// call to list them, and each app creates its own again on start.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/prompts"
+)
+
+// Version is the archive format written by Archive.Write
+const Version = 1
+
+// managedTypes are the "type" labels of the units the apps create
+var managedTypes = map[string]bool{
+	"cost-analysis":      true, // cost-optimizer
+	"recommendation":     true,
+	"opencost-data":      true,
+	"cost-warning":       true, // cost-impact-monitor
+	"spend-alert":        true,
+	"spend-status":       true,
+	"compliance-finding": true, // compliance-checker
+	"cleanup-proposal":   true, // orphan-cleaner
+	"rotation-request":   true, // secret-rotation-monitor
+}
+
+// Hub is the part of ConfigHub a backup reads and a restore writes
+type Hub interface {
+	// FindSpace returns the space with slug, or false when there is none
+	FindSpace(slug string) (Space, bool, error)
+	CreateSpace(slug string, labels map[string]string) (uuid.UUID, error)
+	Sets(space uuid.UUID) ([]Set, error)
+	CreateSet(space uuid.UUID, set Set) (uuid.UUID, error)
+	Units(space uuid.UUID) ([]Unit, error)
+	CreateUnit(space uuid.UUID, unit Unit, setIDs []uuid.UUID) error
+	UpdateUnit(space, unit uuid.UUID, data string, labels map[string]string) error
+}
+
+// Space is a ConfigHub space
+type Space struct {
+	ID     uuid.UUID
+	Labels map[string]string
+}
+
+// Set is a ConfigHub set
+type Set struct {
+	ID          uuid.UUID         `json:"-"`
+	Slug        string            `json:"slug"`
+	DisplayName string            `json:"display_name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// Unit is a ConfigHub unit. Sets names its sets by slug in an archive;
+// SetIDs are their IDs in the hub.
+type Unit struct {
+	ID          uuid.UUID         `json:"-"`
+	SetIDs      []uuid.UUID       `json:"-"`
+	Slug        string            `json:"slug"`
+	DisplayName string            `json:"display_name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Sets        []string          `json:"sets,omitempty"`
+	Data        string            `json:"data"`
+}
+
+// Manifest describes an archive
+type Manifest struct {
+	Version     int               `json:"version"`
+	Space       string            `json:"space"`
+	SpaceLabels map[string]string `json:"space_labels,omitempty"`
+	Created     time.Time         `json:"created"`
+	All         bool              `json:"all"` // every unit, not just the apps'
+	Sets        int               `json:"sets"`
+	Units       int               `json:"units"`
+}
+
+// Archive is a space's saved state
+type Archive struct {
+	Manifest Manifest
+	Sets     []Set
+	Units    []Unit
+}
+
+// Managed reports whether an app created u
+func Managed(u Unit) bool {
+	switch {
+	case managedTypes[u.Labels["type"]], u.Labels[audit.Label] == "true":
+		return true
+	case u.Slug == flags.Unit, strings.HasPrefix(u.Slug, prompts.UnitPrefix):
+		return true
+	}
+	return false
+}
+
+// Export reads the sets of the space with slug space and its units, only
+// those the apps created unless all is set
+func Export(hub Hub, space string, all bool) (*Archive, error) {
+	sp, found, err := hub.FindSpace(space)
+	if err != nil {
+		return nil, fmt.Errorf("find space: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("space %s not found", space)
+	}
+	id := sp.ID
+
+	sets, err := hub.Sets(id)
+	if err != nil {
+		return nil, fmt.Errorf("list sets: %w", err)
+	}
+	setSlugs := make(map[uuid.UUID]string, len(sets))
+	for _, s := range sets {
+		setSlugs[s.ID] = s.Slug
+	}
+
+	units, err := hub.Units(id)
+	if err != nil {
+		return nil, fmt.Errorf("list units: %w", err)
+	}
+	a := &Archive{Sets: sets}
+	for _, u := range units {
+		if !all && !Managed(u) {
+			continue
+		}
+		for _, setID := range u.SetIDs {
+			if slug, ok := setSlugs[setID]; ok {
+				u.Sets = append(u.Sets, slug)
+			}
+		}
+		a.Units = append(a.Units, u)
+	}
+	sort.Slice(a.Sets, func(i, j int) bool { return a.Sets[i].Slug < a.Sets[j].Slug })
+	sort.Slice(a.Units, func(i, j int) bool { return a.Units[i].Slug < a.Units[j].Slug })
+
+	a.Manifest = Manifest{
+		Version: Version, Space: space, SpaceLabels: sp.Labels, Created: time.Now().UTC(), All: all,
+		Sets: len(a.Sets), Units: len(a.Units),
+	}
+	return a, nil
+}
+
+// Result counts what a restore did
+type Result struct {
+	SpaceCreated bool `json:"space_created"`
+	SetsCreated  int  `json:"sets_created"`
+	UnitsCreated int  `json:"units_created"`
+	UnitsUpdated int  `json:"units_updated"`
+	UnitsSkipped int  `json:"units_skipped"` // already there and not overwritten
+}
+
+// Restore writes a into the space with slug space, creating it (with the
+// labels it was saved with) and any missing sets. Units already in the space
+// keep their data unless overwrite is set; their set membership is not
+// changed either way.
+func Restore(hub Hub, a *Archive, space string, overwrite bool) (Result, error) {
+	var result Result
+	sp, found, err := hub.FindSpace(space)
+	if err != nil {
+		return result, fmt.Errorf("find space: %w", err)
+	}
+	id := sp.ID
+	if !found {
+		if id, err = hub.CreateSpace(space, a.Manifest.SpaceLabels); err != nil {
+			return result, fmt.Errorf("create space: %w", err)
+		}
+		result.SpaceCreated = true
+	}
+
+	sets, err := hub.Sets(id)
+	if err != nil {
+		return result, fmt.Errorf("list sets: %w", err)
+	}
+	setIDs := make(map[string]uuid.UUID, len(sets))
+	for _, s := range sets {
+		setIDs[s.Slug] = s.ID
+	}
+	for _, s := range a.Sets {
+		if _, ok := setIDs[s.Slug]; ok {
+			continue
+		}
+		if setIDs[s.Slug], err = hub.CreateSet(id, s); err != nil {
+			return result, fmt.Errorf("create set %s: %w", s.Slug, err)
+		}
+		result.SetsCreated++
+	}
+
+	units, err := hub.Units(id)
+	if err != nil {
+		return result, fmt.Errorf("list units: %w", err)
+	}
+	existing := make(map[string]uuid.UUID, len(units))
+	for _, u := range units {
+		existing[u.Slug] = u.ID
+	}
+	for _, u := range a.Units {
+		if unitID, ok := existing[u.Slug]; ok {
+			if !overwrite {
+				result.UnitsSkipped++
+				continue
+			}
+			if err := hub.UpdateUnit(id, unitID, u.Data, u.Labels); err != nil {
+				return result, fmt.Errorf("update unit %s: %w", u.Slug, err)
+			}
+			result.UnitsUpdated++
+			continue
+		}
+
+		var ids []uuid.UUID
+		for _, slug := range u.Sets {
+			if setID, ok := setIDs[slug]; ok {
+				ids = append(ids, setID)
+			}
+		}
+		if err := hub.CreateUnit(id, u, ids); err != nil {
+			return result, fmt.Errorf("create unit %s: %w", u.Slug, err)
+		}
+		result.UnitsCreated++
+	}
+	return result, nil
+}
+
+// Archive layout: manifest.json, sets.json and units/<slug>.json
+const (
+	manifestFile = "manifest.json"
+	setsFile     = "sets.json"
+	unitsDir     = "units/"
+)
+
+// Write stores a as a gzipped tarball
+func (a *Archive) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	modTime := a.Manifest.Created
+
+	add := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime}); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+	if err := add(manifestFile, a.Manifest); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := add(setsFile, a.Sets); err != nil {
+		return fmt.Errorf("write sets: %w", err)
+	}
+	for _, u := range a.Units {
+		if err := add(unitsDir+u.Slug+".json", u); err != nil {
+			return fmt.Errorf("write unit %s: %w", u.Slug, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read loads an archive written by Write
+func Read(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	defer gz.Close()
+
+	a := &Archive{}
+	var haveManifest bool
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dec := json.NewDecoder(tr)
+		switch name := path.Clean(header.Name); {
+		case name == manifestFile:
+			err = dec.Decode(&a.Manifest)
+			haveManifest = true
+		case name == setsFile:
+			err = dec.Decode(&a.Sets)
+		case strings.HasPrefix(name, unitsDir) && strings.HasSuffix(name, ".json"):
+			var u Unit
+			if err = dec.Decode(&u); err == nil {
+				a.Units = append(a.Units, u)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", header.Name, err)
+		}
+	}
+
+	if !haveManifest {
+		return nil, fmt.Errorf("read archive: no %s", manifestFile)
+	}
+	if a.Manifest.Version != Version {
+		return nil, fmt.Errorf("archive version %d, want %d", a.Manifest.Version, Version)
+	}
+	return a, nil
+}
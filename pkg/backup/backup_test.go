@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// memoryHub is an in-memory ConfigHub keyed by space slug
+type memoryHub struct {
+	spaces map[string]*memorySpace
+}
+
+type memorySpace struct {
+	Space
+	sets  []Set
+	units []Unit
+}
+
+func newMemoryHub() *memoryHub { return &memoryHub{spaces: map[string]*memorySpace{}} }
+
+func (h *memoryHub) space(id uuid.UUID) *memorySpace {
+	for _, s := range h.spaces {
+		if s.ID == id {
+			return s
+		}
+	}
+	panic(fmt.Sprintf("no space %s", id))
+}
+
+func (h *memoryHub) FindSpace(slug string) (Space, bool, error) {
+	s, ok := h.spaces[slug]
+	if !ok {
+		return Space{}, false, nil
+	}
+	return s.Space, true, nil
+}
+
+func (h *memoryHub) CreateSpace(slug string, labels map[string]string) (uuid.UUID, error) {
+	s := &memorySpace{Space: Space{ID: uuid.New(), Labels: labels}}
+	h.spaces[slug] = s
+	return s.ID, nil
+}
+
+func (h *memoryHub) Sets(space uuid.UUID) ([]Set, error) {
+	return append([]Set(nil), h.space(space).sets...), nil
+}
+
+func (h *memoryHub) CreateSet(space uuid.UUID, set Set) (uuid.UUID, error) {
+	set.ID = uuid.New()
+	s := h.space(space)
+	s.sets = append(s.sets, set)
+	return set.ID, nil
+}
+
+func (h *memoryHub) Units(space uuid.UUID) ([]Unit, error) {
+	return append([]Unit(nil), h.space(space).units...), nil
+}
+
+func (h *memoryHub) CreateUnit(space uuid.UUID, unit Unit, setIDs []uuid.UUID) error {
+	unit.ID, unit.SetIDs, unit.Sets = uuid.New(), setIDs, nil
+	s := h.space(space)
+	s.units = append(s.units, unit)
+	return nil
+}
+
+func (h *memoryHub) UpdateUnit(space, unit uuid.UUID, data string, labels map[string]string) error {
+	s := h.space(space)
+	for i := range s.units {
+		if s.units[i].ID == unit {
+			s.units[i].Data, s.units[i].Labels = data, labels
+			return nil
+		}
+	}
+	return fmt.Errorf("no unit %s", unit)
+}
+
+func TestManaged(t *testing.T) {
+	for _, tc := range []struct {
+		unit Unit
+		want bool
+	}{
+		{Unit{Slug: "cost-analysis-1", Labels: map[string]string{"type": "cost-analysis"}}, true},
+		{Unit{Slug: "warning-api", Labels: map[string]string{"type": "cost-warning"}}, true},
+		{Unit{Slug: "audit-1", Labels: map[string]string{"audit-entry": "true"}}, true},
+		{Unit{Slug: "feature-flags"}, true},
+		{Unit{Slug: "prompt-drift-analysis"}, true},
+		{Unit{Slug: "backend-api", Labels: map[string]string{"tier": "critical"}}, false},
+	} {
+		if got := Managed(tc.unit); got != tc.want {
+			t.Errorf("Managed(%s) = %t, want %t", tc.unit.Slug, got, tc.want)
+		}
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	hub := newMemoryHub()
+	prodID, _ := hub.CreateSpace("prod", map[string]string{"app": "cost-optimizer"})
+	critical, _ := hub.CreateSet(prodID, Set{Slug: "critical-services", Labels: map[string]string{"tier": "critical"}})
+	hub.CreateUnit(prodID, Unit{Slug: "rec-api", Labels: map[string]string{"type": "recommendation"}, Data: `{"monthly_savings": 80}`}, []uuid.UUID{critical})
+	hub.CreateUnit(prodID, Unit{Slug: "feature-flags", Data: "auto_fix: false"}, nil)
+	hub.CreateUnit(prodID, Unit{Slug: "backend-api", Data: "kind: Deployment"}, []uuid.UUID{critical})
+
+	a, err := Export(hub, "prod", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Manifest.Units != 2 || a.Manifest.Sets != 1 || a.Units[1].Slug != "rec-api" || a.Units[1].Sets[0] != "critical-services" {
+		t.Fatalf("archive = %+v", a)
+	}
+
+	var buf bytes.Buffer
+	if err := a.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Manifest.Space != "prod" || len(read.Units) != 2 || read.Units[0].Data != "auto_fix: false" {
+		t.Fatalf("read back %+v", read)
+	}
+
+	// Into a new space: the space, its set and both units are created
+	result, err := Restore(hub, read, "prod-copy", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.SpaceCreated || result.SetsCreated != 1 || result.UnitsCreated != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	copySpace := hub.spaces["prod-copy"]
+	if copySpace.Labels["app"] != "cost-optimizer" {
+		t.Errorf("space labels = %v", copySpace.Labels)
+	}
+	for _, u := range copySpace.units {
+		if u.Slug == "rec-api" && (len(u.SetIDs) != 1 || u.SetIDs[0] != copySpace.sets[0].ID) {
+			t.Errorf("rec-api sets = %v, want the new critical-services set", u.SetIDs)
+		}
+	}
+
+	// Again: existing units are kept unless overwritten
+	copySpace.units[0].Data = "changed"
+	if result, err = Restore(hub, read, "prod-copy", false); err != nil || result.UnitsSkipped != 2 || result.SetsCreated != 0 {
+		t.Errorf("second restore = %+v, %v", result, err)
+	}
+	if result, err = Restore(hub, read, "prod-copy", true); err != nil || result.UnitsUpdated != 2 {
+		t.Errorf("overwriting restore = %+v, %v", result, err)
+	}
+	if copySpace.units[0].Data == "changed" {
+		t.Error("overwrite kept the changed data")
+	}
+
+	if _, err := Export(hub, "staging", true); err == nil {
+		t.Error("export of a missing space succeeded")
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	if _, err := Read(bytes.NewReader([]byte("not gzip"))); err == nil {
+		t.Error("read garbage")
+	}
+
+	var buf bytes.Buffer
+	a := &Archive{Manifest: Manifest{Version: Version + 1}}
+	if err := a.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(&buf); err == nil {
+		t.Error("read a newer archive version")
+	}
+}
@@ -0,0 +1,205 @@
+// Package breaker stops the apps from waiting on an external service that is
+// down. After a number of consecutive failures a Breaker opens and calls fail
+// at once with ErrOpen, so the caller falls back (cached state, estimates,
+// rule-based analysis) instead of timing out every cycle. Once the cooldown
+// has passed a single half-open call probes the service: success closes the
+// breaker, failure opens it for another cooldown.
+//
+//	analysis, err := breaker.Call(claudeBreaker, func() (string, error) {
+//		return claude.Complete(prompt)
+//	})
+//	if errors.Is(err, breaker.ErrOpen) {
+//		// use the rule-based analysis
+//	}
+package breaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+// ErrOpen is returned, wrapped with the breaker's name, while a breaker rejects calls
+var ErrOpen = errors.New("circuit open")
+
+// ErrSkipped is wrapped by errors of calls that never reached the service,
+// such as one refused by a spent budget. Like cancelled calls they are not
+// counted against the service.
+var ErrSkipped = errors.New("call skipped")
+
+// State is a breaker's position
+type State string
+
+const (
+	Closed   State = "closed"    // calls pass
+	Open     State = "open"      // calls fail fast until the cooldown ends
+	HalfOpen State = "half-open" // one probe call is in flight
+)
+
+// Breaker guards calls to one service. It is safe for concurrent use; a nil
+// Breaker lets every call through.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int // consecutive failures while closed
+	openedAt time.Time
+	lastErr  error
+}
+
+// New returns a closed breaker for the service called name that opens after
+// threshold consecutive failures and probes again after cooldown
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now, state: Closed}
+}
+
+// Allow reports whether a call may go ahead; every allowed call must be
+// followed by Record with its result
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return fmt.Errorf("%s: %w", b.name, ErrOpen)
+		}
+		b.state = HalfOpen
+		slog.Info("Circuit breaker probing", "service", b.name)
+		return nil
+	case HalfOpen:
+		return fmt.Errorf("%s: %w", b.name, ErrOpen) // a probe is already in flight
+	}
+	return nil
+}
+
+// Record counts the result of an allowed call. Cancelled and skipped calls
+// say nothing about the service and are ignored; permanent errors such as a
+// missing unit or a rejected manifest show the service answering and count
+// as successes.
+func (b *Breaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrSkipped) {
+		if b.state == HalfOpen {
+			b.state = Open // let the next call probe
+		}
+		return
+	}
+	if err == nil || errkind.IsPermanent(err) {
+		if b.state != Closed {
+			slog.Info("Circuit breaker closed", "service", b.name)
+		}
+		b.state, b.failures, b.lastErr = Closed, 0, nil
+		return
+	}
+
+	b.lastErr = err
+	switch b.state {
+	case HalfOpen:
+		b.trip()
+	case Closed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.trip()
+		}
+	}
+}
+
+// trip opens the breaker; b.mu must be held
+func (b *Breaker) trip() {
+	b.state, b.failures, b.openedAt = Open, 0, b.now()
+	slog.Warn("Circuit breaker opened, using fallbacks", "service", b.name,
+		"cooldown", b.cooldown.String(), "error", b.lastErr.Error())
+}
+
+// Status is a breaker's state for the breakers API
+type Status struct {
+	Service       string       `json:"service"`
+	State         State        `json:"state"`
+	Failures      int          `json:"consecutive_failures"`
+	OpenedAt      *time.Time   `json:"opened_at,omitempty"`
+	LastError     string       `json:"last_error,omitempty"`
+	LastErrorKind errkind.Kind `json:"last_error_kind,omitempty"` // auth failures need new credentials, not time
+}
+
+// Status returns the breaker's current state
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Status{Service: b.name, State: b.state, Failures: b.failures}
+	if b.state != Closed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	if b.lastErr != nil {
+		s.LastError, s.LastErrorKind = b.lastErr.Error(), errkind.Of(b.lastErr)
+	}
+	return s
+}
+
+// Call runs fn if b allows it and records the result
+func Call[T any](b *Breaker, fn func() (T, error)) (T, error) {
+	if err := b.Allow(); err != nil {
+		var zero T
+		return zero, err
+	}
+	result, err := fn()
+	b.Record(err)
+	return result, err
+}
+
+// Do runs fn if b allows it and records the result
+func Do(b *Breaker, fn func() error) error {
+	_, err := Call(b, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+// Statuses is the body of GET /api/v1/breakers
+type Statuses struct {
+	Breakers []Status `json:"breakers"`
+}
+
+// Operations document the breakers route of an app's API
+var Operations = []api.Operation{{Summary: "Circuit breakers of the app's dependencies", Response: Statuses{}}}
+
+// Handler serves the status of breakers as JSON
+func Handler(breakers ...*Breaker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		statuses := make([]Status, 0, len(breakers))
+		for _, b := range breakers {
+			if b != nil {
+				statuses = append(statuses, b.Status())
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(Statuses{Breakers: statuses}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
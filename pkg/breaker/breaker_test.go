@@ -0,0 +1,106 @@
+package breaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+var errDown = errors.New("connection refused")
+
+func TestBreakerTripsAndRecovers(t *testing.T) {
+	now := time.Now()
+	b := New("confighub", 3, time.Minute)
+	b.now = func() time.Time { return now }
+	fail := func() error { return errDown }
+	calls := 0
+	succeed := func() error { calls++; return nil }
+
+	for i := 0; i < 2; i++ {
+		Do(b, fail)
+	}
+	if b.Status().State != Closed {
+		t.Fatalf("opened after 2 failures: %+v", b.Status())
+	}
+	Do(b, succeed) // resets the count
+	for i := 0; i < 3; i++ {
+		Do(b, fail)
+	}
+	if s := b.Status(); s.State != Open || s.LastError != "connection refused" {
+		t.Fatalf("after 3 failures: %+v", s)
+	}
+
+	if err := Do(b, succeed); !errors.Is(err, ErrOpen) || calls != 1 {
+		t.Errorf("open breaker let a call through: %v", err)
+	}
+
+	// After the cooldown one probe goes through; a failure reopens
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("second call during probe = %v", err)
+	}
+	b.Record(errDown)
+	if b.Status().State != Open {
+		t.Fatalf("failed probe left %s", b.Status().State)
+	}
+
+	now = now.Add(time.Minute)
+	if err := Do(b, succeed); err != nil || b.Status().State != Closed {
+		t.Errorf("successful probe = %v, state %s", err, b.Status().State)
+	}
+}
+
+func TestBreakerIgnoresCancellation(t *testing.T) {
+	b := New("claude", 1, time.Minute)
+	Do(b, func() error { return context.Canceled })
+	if b.Status().State != Closed {
+		t.Errorf("cancelled call opened the breaker")
+	}
+	Do(b, func() error { return fmt.Errorf("budget spent: %w", ErrSkipped) })
+	if b.Status().State != Closed {
+		t.Errorf("skipped call opened the breaker")
+	}
+
+	var nilBreaker *Breaker
+	if got, err := Call(nilBreaker, func() (int, error) { return 1, nil }); got != 1 || err != nil {
+		t.Errorf("nil breaker = %d, %v", got, err)
+	}
+}
+
+func TestBreakerCountsOnlyServiceFailures(t *testing.T) {
+	b := New("confighub", 1, time.Minute)
+	Do(b, func() error { return &errkind.HTTPError{Service: "confighub", StatusCode: 404} })
+	if s := b.Status(); s.State != Closed || s.Failures != 0 {
+		t.Errorf("missing unit counted against ConfigHub: %+v", s)
+	}
+	Do(b, func() error { return &errkind.HTTPError{Service: "confighub", StatusCode: 401} })
+	if s := b.Status(); s.State != Open || s.LastErrorKind != errkind.Auth {
+		t.Errorf("rejected token = %+v, want open with kind auth", s)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	open := New("opencost", 1, time.Minute)
+	Do(open, func() error { return errDown })
+
+	rec := httptest.NewRecorder()
+	Handler(New("confighub", 5, time.Minute), open, nil).ServeHTTP(rec, httptest.NewRequest("GET", "/api/breakers", nil))
+	var body struct {
+		Breakers []Status `json:"breakers"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Breakers) != 2 || body.Breakers[0].State != Closed || body.Breakers[1].State != Open || body.Breakers[1].OpenedAt == nil {
+		t.Errorf("breakers = %+v", body.Breakers)
+	}
+}
@@ -0,0 +1,132 @@
+// Package bulk runs a ConfigHub operation over many units and reports what
+// happened to each, instead of one error for the lot. The SDK's
+// BulkPatchUnits and BulkApplyUnits fail or succeed as a whole; running the
+// operation per unit tells which units failed and why:
+//
+//	report := bulk.Run(ctx, "apply", slugs, func(ctx context.Context, unit string) error {
+//		return cub.ApplyUnit(spaceID, ids[unit])
+//	})
+//	for _, f := range report.Failed {
+//		slog.Error("Failed to apply unit", logging.Unit(f.Unit), logging.Err(f.Err))
+//	}
+//
+// Units failing with a retryable error (see pkg/errkind) are run again as a
+// subset once the others are done, up to Attempts passes, waiting
+// errkind.Delay between passes. Runs over more than ProgressEvery units log
+// their progress every ProgressEvery units.
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+// Attempts is how many passes Run makes over units failing with a retryable
+// error
+const Attempts = 3
+
+// ProgressEvery is how many units Run finishes between progress logs
+const ProgressEvery = 25
+
+// Failure is a unit the operation failed for
+type Failure struct {
+	Unit     string
+	Err      error // of the last attempt
+	Kind     errkind.Kind
+	Attempts int
+}
+
+// Report is the outcome of a Run, per unit
+type Report struct {
+	Op        string
+	Total     int
+	Succeeded []string  // in the order they succeeded
+	Failed    []Failure // in the order of the units given to Run
+	Retried   int       // units run again after a retryable error
+}
+
+// Partial reports whether the operation failed for some units but not all
+func (r Report) Partial() bool {
+	return len(r.Failed) > 0 && len(r.Succeeded) > 0
+}
+
+// Err returns nil when every unit succeeded, otherwise an error naming the
+// failed units and wrapping their errors
+func (r Report) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(r.Failed))
+	for _, f := range r.Failed {
+		errs = append(errs, fmt.Errorf("%s: %w", f.Unit, f.Err))
+	}
+	return fmt.Errorf("%s failed for %d of %d units: %w", r.Op, len(r.Failed), r.Total, errors.Join(errs...))
+}
+
+// Run calls fn for each unit and returns what happened to each. A cancelled
+// ctx fails the units not yet run.
+func Run(ctx context.Context, op string, units []string, fn func(ctx context.Context, unit string) error) Report {
+	r := Report{Op: op, Total: len(units), Succeeded: make([]string, 0, len(units))}
+	errs := make(map[string]error)
+	attempts := make(map[string]int)
+	finished := 0
+
+	pending := units
+	for pass := 1; len(pending) > 0; pass++ {
+		var retry []string
+		var last error
+		for _, unit := range pending {
+			err := ctx.Err()
+			if err == nil {
+				attempts[unit]++
+				err = fn(ctx, unit)
+			}
+			if err == nil {
+				delete(errs, unit)
+				r.Succeeded = append(r.Succeeded, unit)
+			} else {
+				errs[unit] = err
+				if pass < Attempts && retryable(ctx, err) {
+					retry = append(retry, unit)
+					last = err
+					continue
+				}
+			}
+			finished++
+			if r.Total > ProgressEvery && (finished%ProgressEvery == 0 || finished == r.Total) {
+				slog.Info("Bulk progress", "op", op, "done", finished, "total", r.Total, "failed", len(errs)-len(retry))
+			}
+		}
+		if len(retry) == 0 {
+			break
+		}
+
+		slog.Warn("Retrying failed units", "op", op, "units", len(retry), "pass", pass+1, "error", last)
+		r.Retried += len(retry)
+		timer := time.NewTimer(errkind.Delay(last, pass))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		pending = retry
+	}
+
+	for _, unit := range units {
+		if err, failed := errs[unit]; failed {
+			r.Failed = append(r.Failed, Failure{Unit: unit, Err: err, Kind: errkind.Of(err), Attempts: attempts[unit]})
+			delete(errs, unit) // a unit given twice is reported once
+		}
+	}
+	return r
+}
+
+func retryable(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && errkind.IsRetryable(err) && !errors.Is(err, breaker.ErrOpen)
+}
@@ -0,0 +1,72 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+func TestRunRetriesFailedSubset(t *testing.T) {
+	flaky := &errkind.HTTPError{Service: "confighub", StatusCode: 503, RetryAfter: time.Millisecond}
+	invalid := &errkind.HTTPError{Service: "confighub", StatusCode: 422}
+	calls := map[string]int{}
+	report := Run(context.Background(), "patch", []string{"web", "api", "worker", "cache"}, func(ctx context.Context, unit string) error {
+		calls[unit]++
+		switch {
+		case unit == "api" && calls[unit] == 1:
+			return flaky // recovers on the second pass
+		case unit == "worker":
+			return fmt.Errorf("patch: %w", invalid)
+		case unit == "cache":
+			return flaky
+		}
+		return nil
+	})
+
+	if !reflect.DeepEqual(report.Succeeded, []string{"web", "api"}) {
+		t.Errorf("Succeeded = %v", report.Succeeded)
+	}
+	if want := map[string]int{"web": 1, "api": 2, "worker": 1, "cache": Attempts}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if len(report.Failed) != 2 || report.Failed[0].Unit != "worker" || report.Failed[0].Kind != errkind.Permanent ||
+		report.Failed[1].Unit != "cache" || report.Failed[1].Attempts != Attempts {
+		t.Errorf("Failed = %+v", report.Failed)
+	}
+	if report.Retried != 3 || !report.Partial() {
+		t.Errorf("Retried = %d, Partial = %v", report.Retried, report.Partial())
+	}
+
+	err := report.Err()
+	if !errors.Is(err, invalid) || !strings.HasPrefix(err.Error(), "patch failed for 2 of 4 units: worker: ") {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestRunSucceeds(t *testing.T) {
+	units := make([]string, 2*ProgressEvery+1)
+	for i := range units {
+		units[i] = fmt.Sprintf("unit-%d", i)
+	}
+	report := Run(context.Background(), "apply", units, func(context.Context, string) error { return nil })
+	if report.Err() != nil || len(report.Succeeded) != len(units) || report.Partial() {
+		t.Errorf("Run = %+v", report)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	report := Run(ctx, "apply", []string{"web", "api"}, func(ctx context.Context, unit string) error {
+		cancel()
+		return nil
+	})
+	if len(report.Failed) != 1 || report.Failed[0].Unit != "api" || !errors.Is(report.Failed[0].Err, context.Canceled) || report.Failed[0].Attempts != 0 {
+		t.Errorf("Failed = %+v", report.Failed)
+	}
+}
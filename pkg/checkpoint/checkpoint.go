@@ -0,0 +1,224 @@
+// Package checkpoint keeps the apps' runtime state across restarts: drift
+// reports, applied recommendations, trigger timestamps, space monitors. Each
+// app registers the parts of its state it wants back, and a Checkpointer
+// periodically writes them, as one JSON document, to the ConfigHub unit
+// checkpoint-<app> of the space named by CHECKPOINT_SPACE:
+//
+//	cp := checkpoint.New("drift-detector", writer, reader)
+//	cp.Register("detector/"+space, d.checkpointState, d.restoreState)
+//	cp.Restore(ctx) // at startup, before the first cycle
+//	go cp.Run(ctx, time.Minute)
+//
+// A part whose data fails to restore is skipped, so a changed type only
+// loses that part. Without a store nothing is saved.
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// Label marks the ConfigHub units holding checkpoints; its value is the app
+const Label = "checkpoint"
+
+// DefaultInterval is how often Run saves when given no interval
+const DefaultInterval = time.Minute
+
+// Writer stores a checkpoint as the ConfigHub unit with the given slug,
+// creating it or replacing its data and labels
+type Writer func(ctx context.Context, slug string, labels map[string]string, data string) error
+
+// Reader returns the data of the ConfigHub units matching a where clause
+type Reader func(ctx context.Context, where string) ([]string, error)
+
+// SaveFunc returns a part of the state, to be encoded as JSON. An error
+// leaves the last checkpoint in place.
+type SaveFunc func() (interface{}, error)
+
+// RestoreFunc puts back a part of the state from its saved JSON
+type RestoreFunc func(data json.RawMessage) error
+
+// Document is what a checkpoint unit holds
+type Document struct {
+	App     string                     `json:"app"`
+	SavedAt time.Time                  `json:"saved_at"`
+	Parts   map[string]json.RawMessage `json:"parts"`
+}
+
+type part struct {
+	save    SaveFunc
+	restore RestoreFunc
+}
+
+// Checkpointer saves and restores one app's state. It is safe for
+// concurrent use; a nil Checkpointer saves nothing.
+type Checkpointer struct {
+	app    string
+	writer Writer
+	reader Reader
+	now    func() time.Time
+
+	// Leader, when set, reports whether this replica may save, so standby
+	// replicas don't overwrite the leader's checkpoint
+	Leader func() bool
+
+	mu    sync.Mutex
+	parts map[string]part
+	last  []byte // parts of the last save, to skip writing unchanged state
+}
+
+// New returns app's Checkpointer, stored through writer and reader; without
+// them it saves and restores nothing
+func New(app string, writer Writer, reader Reader) *Checkpointer {
+	return &Checkpointer{app: app, writer: writer, reader: reader, now: time.Now, parts: map[string]part{}}
+}
+
+// Slug names the unit of app's checkpoint
+func Slug(app string) string {
+	return "checkpoint-" + app
+}
+
+// Labels are the unit labels of app's checkpoint
+func Labels(app string) map[string]string {
+	return map[string]string{Label: app}
+}
+
+// Where returns the ConfigHub where clause of app's checkpoint unit
+func Where(app string) string {
+	return fmt.Sprintf("Labels['%s'] = '%s'", Label, app)
+}
+
+// Register adds the part of the state called name, saved by save and put
+// back by restore. Register every part before calling Restore.
+func (c *Checkpointer) Register(name string, save SaveFunc, restore RestoreFunc) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.parts[name] = part{save: save, restore: restore}
+	c.mu.Unlock()
+}
+
+// Restore reads the last checkpoint and hands each registered part its
+// data. A missing checkpoint is not an error; parts that fail to restore are
+// logged and skipped.
+func (c *Checkpointer) Restore(ctx context.Context) error {
+	if c == nil || c.reader == nil {
+		return nil
+	}
+	data, err := c.reader(ctx, Where(c.app))
+	if err != nil {
+		return fmt.Errorf("read checkpoint: %w", err)
+	}
+	if len(data) == 0 || data[0] == "" {
+		return nil
+	}
+	var doc Document
+	if err := json.Unmarshal([]byte(data[0]), &doc); err != nil {
+		return fmt.Errorf("decode checkpoint: %w", err)
+	}
+
+	c.mu.Lock()
+	parts := make(map[string]part, len(c.parts))
+	for name, p := range c.parts {
+		parts[name] = p
+	}
+	c.mu.Unlock()
+
+	restored := 0
+	for name, raw := range doc.Parts {
+		p, ok := parts[name]
+		if !ok {
+			continue
+		}
+		if err := p.restore(raw); err != nil {
+			slog.Warn("Failed to restore checkpoint part", "part", name, logging.Err(err))
+			continue
+		}
+		restored++
+	}
+	slog.Info("Restored checkpoint", "parts", restored, "saved_at", doc.SavedAt.Format(time.RFC3339))
+	return nil
+}
+
+// Save writes the registered parts to the checkpoint unit, unless they are
+// unchanged since the last save or this replica is not the leader
+func (c *Checkpointer) Save(ctx context.Context) error {
+	if c == nil || c.writer == nil || (c.Leader != nil && !c.Leader()) {
+		return nil
+	}
+	c.mu.Lock()
+	saves := make(map[string]SaveFunc, len(c.parts))
+	for name, p := range c.parts {
+		saves[name] = p.save
+	}
+	c.mu.Unlock()
+
+	// Saved outside the lock: the save funcs take the app's own locks
+	doc := Document{App: c.app, SavedAt: c.now().UTC(), Parts: make(map[string]json.RawMessage, len(saves))}
+	for name, save := range saves {
+		v, err := save()
+		if err != nil {
+			return fmt.Errorf("checkpoint part %s: %w", name, err)
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode checkpoint part %s: %w", name, err)
+		}
+		doc.Parts[name] = raw
+	}
+	parts, _ := json.Marshal(doc.Parts)
+
+	c.mu.Lock()
+	unchanged := bytes.Equal(parts, c.last)
+	c.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encode checkpoint: %w", err)
+	}
+	if err := c.writer(ctx, Slug(c.app), Labels(c.app), string(data)); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	c.mu.Lock()
+	c.last = parts
+	c.mu.Unlock()
+	return nil
+}
+
+// Run saves every interval until ctx is done, then once more so a pod that
+// is shutting down leaves its latest state
+func (c *Checkpointer) Run(ctx context.Context, interval time.Duration) {
+	if c == nil || c.writer == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flush, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := c.Save(flush); err != nil {
+				slog.Warn("Failed to save checkpoint", logging.Err(err))
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := c.Save(ctx); err != nil {
+				slog.Warn("Failed to save checkpoint", logging.Err(err))
+			}
+		}
+	}
+}
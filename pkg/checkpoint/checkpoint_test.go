@@ -0,0 +1,129 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// store is an in-memory ConfigHub space holding units by slug
+type store struct {
+	units  map[string]string
+	labels map[string]map[string]string
+	writes int
+}
+
+func newStore() *store {
+	return &store{units: map[string]string{}, labels: map[string]map[string]string{}}
+}
+
+func (s *store) write(_ context.Context, slug string, labels map[string]string, data string) error {
+	s.units[slug], s.labels[slug] = data, labels
+	s.writes++
+	return nil
+}
+
+func (s *store) read(_ context.Context, where string) ([]string, error) {
+	var data []string
+	for slug, labels := range s.labels {
+		if where == Where(labels[Label]) {
+			data = append(data, s.units[slug])
+		}
+	}
+	return data, nil
+}
+
+func TestSaveRestore(t *testing.T) {
+	s := newStore()
+	counts := map[string]int{"frontend": 2}
+	seen := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	cp := New("drift-detector", s.write, s.read)
+	cp.Register("counts", func() (interface{}, error) { return counts, nil }, func(data json.RawMessage) error {
+		return json.Unmarshal(data, &counts)
+	})
+	cp.Register("seen", func() (interface{}, error) { return seen, nil }, func(data json.RawMessage) error {
+		return json.Unmarshal(data, &seen)
+	})
+	ctx := context.Background()
+	if err := cp.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Save(ctx); err != nil || s.writes != 1 {
+		t.Errorf("saving unchanged state wrote %d times, err %v", s.writes, err)
+	}
+	if s.labels["checkpoint-drift-detector"][Label] != "drift-detector" {
+		t.Errorf("units = %v", s.labels)
+	}
+
+	// A restarted app gets its state back; parts it no longer registers, and
+	// parts that fail to decode, are skipped
+	restored := map[string]int{}
+	next := New("drift-detector", s.write, s.read)
+	next.Register("counts", nil, func(data json.RawMessage) error { return json.Unmarshal(data, &restored) })
+	next.Register("seen", nil, func(json.RawMessage) error { return errors.New("changed type") })
+	if err := next.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if restored["frontend"] != 2 {
+		t.Errorf("restored %v", restored)
+	}
+
+	// Other apps' checkpoints are not read
+	other := New("cost-optimizer", s.write, s.read)
+	other.Register("counts", nil, func(json.RawMessage) error {
+		t.Error("restored another app's checkpoint")
+		return nil
+	})
+	if err := other.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeader(t *testing.T) {
+	s := newStore()
+	cp := New("cost-impact-monitor", s.write, s.read)
+	cp.Register("n", func() (interface{}, error) { return 1, nil }, nil)
+	leader := false
+	cp.Leader = func() bool { return leader }
+
+	ctx := context.Background()
+	cp.Save(ctx)
+	if s.writes != 0 {
+		t.Error("Expected a standby replica not to save")
+	}
+	leader = true
+	cp.Save(ctx)
+	if s.writes != 1 {
+		t.Error("Expected the leader to save")
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := newStore()
+	cp := New("cost-optimizer", s.write, s.read)
+	cp.Register("n", func() (interface{}, error) { return 1, nil }, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cp.Run(ctx, time.Hour) // saves once more when stopped
+	if !strings.Contains(s.units["checkpoint-cost-optimizer"], `"parts":{"n":1}`) {
+		t.Errorf("checkpoint = %q", s.units["checkpoint-cost-optimizer"])
+	}
+}
+
+func TestWithoutStore(t *testing.T) {
+	var nilCheckpointer *Checkpointer
+	nilCheckpointer.Register("n", nil, nil)
+	for _, cp := range []*Checkpointer{nilCheckpointer, New("drift-detector", nil, nil)} {
+		if err := cp.Save(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := cp.Restore(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package claudestub is a stand-in for the Claude client, selected in each app
+// with CLAUDE_MODE=stub. It answers from canned responders instead of calling
+// the API, so demos and tests exercise the AI paths without a key.
+//
+// Each app registers responders for its own prompts, since only the app knows
+// the schema its prompt asks for. A prompt no responder recognizes gets a
+// short plain-text assessment.
+package claudestub
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes accepted by the apps' claude_mode setting
+const (
+	ModeAPI  = "api"
+	ModeStub = "stub"
+)
+
+// ValidateMode rejects claude_mode values other than ModeAPI and ModeStub
+func ValidateMode(mode string) error {
+	if mode != ModeAPI && mode != ModeStub {
+		return fmt.Errorf("claude_mode must be %q or %q, got %q", ModeAPI, ModeStub, mode)
+	}
+	return nil
+}
+
+// Responder answers prompts it recognizes. data is the payload passed to
+// AnalyzeJSON, nil for Complete.
+type Responder struct {
+	Name   string // reported in recorded calls
+	Match  func(prompt string) bool
+	Answer func(prompt string, data interface{}) (string, error)
+}
+
+// Contains matches prompts containing marker
+func Contains(marker string) func(string) bool {
+	return func(prompt string) bool { return strings.Contains(prompt, marker) }
+}
+
+// Call is one answered prompt
+type Call struct {
+	Timestamp time.Time `json:"timestamp"`
+	Responder string    `json:"responder"`
+	Prompt    string    `json:"prompt"`
+	Response  string    `json:"response"`
+}
+
+// maxCalls bounds the history kept for RecentCalls
+const maxCalls = 20
+
+// Client answers prompts from its responders, in order
+type Client struct {
+	responders []Responder
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// New returns a client trying responders in order
+func New(responders ...Responder) *Client {
+	return &Client{responders: responders}
+}
+
+// Complete answers a prompt
+func (c *Client) Complete(prompt string) (string, error) {
+	return c.answer(prompt, nil)
+}
+
+// AnalyzeJSON answers a prompt about data
+func (c *Client) AnalyzeJSON(prompt string, data interface{}) (string, error) {
+	return c.answer(prompt, data)
+}
+
+// RecentCalls returns the last answered prompts, oldest first
+func (c *Client) RecentCalls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+func (c *Client) answer(prompt string, data interface{}) (string, error) {
+	name, response := "default", defaultResponse
+	for _, r := range c.responders {
+		if !r.Match(prompt) {
+			continue
+		}
+		answer, err := r.Answer(prompt, data)
+		if err != nil {
+			return "", fmt.Errorf("stub %s: %w", r.Name, err)
+		}
+		name, response = r.Name, answer
+		break
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Timestamp: time.Now(), Responder: name, Prompt: prompt, Response: response})
+	if len(c.calls) > maxCalls {
+		c.calls = c.calls[len(c.calls)-maxCalls:]
+	}
+	return response, nil
+}
+
+const defaultResponse = `[stub] Assessment: the change is within normal bounds for this environment. ` +
+	`Review resource requests against observed usage before applying, and roll out ` +
+	`gradually if the service is customer-facing. (CLAUDE_MODE=stub; no API call was made.)`
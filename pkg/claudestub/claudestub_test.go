@@ -0,0 +1,68 @@
+package claudestub
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClientDispatch(t *testing.T) {
+	c := New(
+		Responder{
+			Name:  "drift",
+			Match: Contains("configuration drift"),
+			Answer: func(prompt string, data interface{}) (string, error) {
+				return `{"has_drift": true}`, nil
+			},
+		},
+		Responder{
+			Name:  "cost",
+			Match: Contains("cost optimization"),
+			Answer: func(prompt string, data interface{}) (string, error) {
+				if data == nil {
+					return "", errors.New("no usage data")
+				}
+				return `{"recommendations": []}`, nil
+			},
+		},
+	)
+
+	if got, err := c.Complete("Analyze this configuration drift"); err != nil || got != `{"has_drift": true}` {
+		t.Errorf("drift prompt = %q, %v", got, err)
+	}
+	if got, err := c.AnalyzeJSON("Provide cost optimization", []int{1}); err != nil || got != `{"recommendations": []}` {
+		t.Errorf("cost prompt = %q, %v", got, err)
+	}
+	if _, err := c.Complete("Provide cost optimization"); err == nil || !strings.Contains(err.Error(), "stub cost") {
+		t.Errorf("responder error = %v", err)
+	}
+	if got, _ := c.Complete("Assess this deployment"); !strings.Contains(got, "CLAUDE_MODE=stub") {
+		t.Errorf("unrecognized prompt = %q", got)
+	}
+
+	calls := c.RecentCalls()
+	if len(calls) != 3 || calls[0].Responder != "drift" || calls[1].Responder != "cost" || calls[2].Responder != "default" {
+		t.Errorf("calls = %+v", calls)
+	}
+}
+
+func TestRecentCallsBounded(t *testing.T) {
+	c := New()
+	for i := 0; i < maxCalls+5; i++ {
+		c.Complete("prompt")
+	}
+	if n := len(c.RecentCalls()); n != maxCalls {
+		t.Errorf("kept %d calls, want %d", n, maxCalls)
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	for _, mode := range []string{ModeAPI, ModeStub} {
+		if err := ValidateMode(mode); err != nil {
+			t.Errorf("ValidateMode(%q) = %v", mode, err)
+		}
+	}
+	if err := ValidateMode("mock"); err == nil {
+		t.Error("ValidateMode accepted mock")
+	}
+}
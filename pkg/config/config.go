@@ -0,0 +1,289 @@
+// Package config loads an app's settings from a YAML file with environment
+// variable overrides, validates them and reports the effective values.
+//
+// Apps describe their settings as a struct whose fields carry yaml and env
+// tags, fill it with defaults and pass it to Load:
+//
+//	type Config struct {
+//		Space    string        `yaml:"cub_space" env:"CUB_SPACE"`
+//		AutoFix  bool          `yaml:"auto_fix" env:"AUTO_FIX"`
+//		Interval time.Duration `yaml:"interval" env:"INTERVAL"`
+//		Token    string        `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+//	}
+//
+// Values come from, in increasing precedence: the defaults, the file and the
+// environment. A missing file is not an error, so env-only deployments keep
+// working. If the struct has a Validate() error method it is called last.
+//
+// Secrets can also be read from files (see ReadSecrets), such as a mounted
+// Kubernetes Secret or the files written by the Vault Agent injector.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source says where a setting's value came from
+type Source string
+
+const (
+	FromDefault Source = "default"
+	FromFile    Source = "file"
+	FromEnv     Source = "env"
+)
+
+// Setting is the effective value of one field
+type Setting struct {
+	Key    string // YAML key, dotted for nested sections
+	Env    string // overriding environment variable, if any
+	Value  string // formatted value; secrets are masked
+	Source Source
+}
+
+// Effective lists the settings an app runs with
+type Effective struct {
+	Path     string // config file, empty when it does not exist
+	Settings []Setting
+
+	secretsDir  string            // directory given to ReadSecrets
+	secretFiles map[string][]byte // contents of the files read by ReadSecrets
+}
+
+// Validator is implemented by configs with cross-field rules
+type Validator interface {
+	Validate() error
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// File is the config file of app: <APP>_CONFIG_FILE when set
+// (DRIFT_DETECTOR_CONFIG_FILE for drift-detector), else CONFIG_FILE, else
+// def. Apps sharing one process (devops-apps combined) each read their own
+// file through the first.
+func File(app, def string) string {
+	env := strings.ToUpper(strings.ReplaceAll(app, "-", "_")) + "_CONFIG_FILE"
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	return def
+}
+
+// Load fills cfg, a pointer to a struct holding defaults, from the YAML file
+// at path and then the environment, and validates the result
+func Load(path string, cfg interface{}) (*Effective, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+
+	effective := &Effective{}
+	fromFile := map[string]bool{}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read config: %w", err)
+	default:
+		effective.Path = path
+		if err := decodeFile(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+		collectKeys(raw, "", fromFile)
+	}
+
+	if err := walk(v.Elem(), "", func(key string, field reflect.StructField, value reflect.Value) error {
+		setting := Setting{Key: key, Env: field.Tag.Get("env"), Source: FromDefault}
+		if fromFile[key] {
+			setting.Source = FromFile
+		}
+		if env, ok := os.LookupEnv(setting.Env); ok && setting.Env != "" {
+			if err := setValue(value, env); err != nil {
+				return fmt.Errorf("%s: %w", setting.Env, err)
+			}
+			setting.Source = FromEnv
+		}
+		setting.Value = format(value, field.Tag.Get("secret") == "true")
+		effective.Settings = append(effective.Settings, setting)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if validator, ok := cfg.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	return effective, nil
+}
+
+// decodeFile decodes YAML into cfg, rejecting keys the app doesn't know
+func decodeFile(data []byte, cfg interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) { // an empty file sets nothing
+		return err
+	}
+	return nil
+}
+
+// collectKeys records the dotted keys set in a YAML document
+func collectKeys(raw map[string]interface{}, prefix string, keys map[string]bool) {
+	for k, v := range raw {
+		key := prefix + k
+		keys[key] = true
+		if nested, ok := v.(map[string]interface{}); ok {
+			collectKeys(nested, key+".", keys)
+		}
+	}
+}
+
+// walk calls fn for every leaf field of a struct, descending into nested
+// structs; fields without a yaml tag are skipped
+func walk(v reflect.Value, prefix string, fn func(key string, field reflect.StructField, value reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := prefix + name
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			if err := walk(v.Field(i), key+".", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(key, field, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setValue parses an environment value into a field
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// format renders a field for the startup report
+func format(v reflect.Value, secret bool) string {
+	if secret {
+		if v.IsZero() {
+			return "(unset)"
+		}
+		return "****"
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	if v.Kind() == reflect.String && v.String() == "" {
+		return `""`
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Log prints the effective configuration, one setting per line, sorted by key
+func (e *Effective) Log(logf func(format string, args ...interface{})) {
+	source := "no config file"
+	if e.Path != "" {
+		source = e.Path
+	}
+	logf("⚙️  Effective configuration (%s):", source)
+
+	settings := append([]Setting(nil), e.Settings...)
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+
+	width := 0
+	for _, s := range settings {
+		width = max(width, len(s.Key))
+	}
+	for _, s := range settings {
+		origin := string(s.Source)
+		if s.Source == FromEnv {
+			origin = "env " + s.Env
+		}
+		logf("   %-*s = %s (%s)", width, s.Key, s.Value, origin)
+	}
+}
+
+// Get returns the setting with the given key
+func (e *Effective) Get(key string) (Setting, bool) {
+	for _, s := range e.Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Space    string        `yaml:"cub_space" env:"TEST_CUB_SPACE"`
+	AutoFix  bool          `yaml:"auto_fix" env:"TEST_AUTO_FIX"`
+	Workers  int           `yaml:"workers" env:"TEST_WORKERS"`
+	Ratio    float64       `yaml:"ratio" env:"TEST_RATIO"`
+	Interval time.Duration `yaml:"interval" env:"TEST_INTERVAL"`
+	Targets  []string      `yaml:"targets" env:"TEST_TARGETS"`
+	Token    string        `yaml:"token" env:"TEST_TOKEN" secret:"true"`
+	Leader   struct {
+		Enabled bool   `yaml:"enabled" env:"TEST_LEADER_ELECT"`
+		Lease   string `yaml:"lease"`
+	} `yaml:"leader"`
+	internal string
+}
+
+func (c *testConfig) Validate() error {
+	if c.Workers < 1 {
+		return errors.New("workers must be at least 1")
+	}
+	return nil
+}
+
+func defaults() *testConfig {
+	cfg := &testConfig{Space: "default-space", Workers: 8, Interval: time.Minute}
+	cfg.Leader.Lease = "app"
+	return cfg
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+cub_space: from-file
+workers: 4
+interval: 5m
+targets: [eu, us]
+leader:
+  enabled: true
+`)
+	t.Setenv("TEST_CUB_SPACE", "from-env")
+	t.Setenv("TEST_TOKEN", "s3cret")
+	t.Setenv("TEST_RATIO", "0.5")
+
+	cfg := defaults()
+	effective, err := Load(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Space != "from-env" || cfg.Workers != 4 || cfg.Interval != 5*time.Minute || cfg.Ratio != 0.5 {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if len(cfg.Targets) != 2 || !cfg.Leader.Enabled || cfg.Leader.Lease != "app" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+
+	want := map[string]Setting{
+		"cub_space":      {Value: "from-env", Source: FromEnv},
+		"workers":        {Value: "4", Source: FromFile},
+		"auto_fix":       {Value: "false", Source: FromDefault},
+		"token":          {Value: "****", Source: FromEnv},
+		"targets":        {Value: "[eu, us]", Source: FromFile},
+		"leader.enabled": {Value: "true", Source: FromFile},
+		"leader.lease":   {Value: "app", Source: FromDefault},
+	}
+	for key, w := range want {
+		got, ok := effective.Get(key)
+		if !ok || got.Value != w.Value || got.Source != w.Source {
+			t.Errorf("%s = %+v, want value %q from %s", key, got, w.Value, w.Source)
+		}
+	}
+	if _, ok := effective.Get("internal"); ok {
+		t.Error("untagged field should not be reported")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Setenv("TEST_TARGETS", "a, b,")
+	t.Setenv("TEST_AUTO_FIX", "true")
+
+	cfg := defaults()
+	effective, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effective.Path != "" || cfg.Space != "default-space" || !cfg.AutoFix {
+		t.Errorf("path=%q cfg=%+v", effective.Path, cfg)
+	}
+	if strings.Join(cfg.Targets, "|") != "a|b" {
+		t.Errorf("targets = %q", cfg.Targets)
+	}
+}
+
+func TestFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DRIFT_DETECTOR_CONFIG_FILE", "")
+	if got := File("drift-detector", "/etc/drift-detector/config.yaml"); got != "/etc/drift-detector/config.yaml" {
+		t.Errorf("File without env = %q", got)
+	}
+	t.Setenv("CONFIG_FILE", "apps.yaml")
+	if got := File("drift-detector", "/etc/drift-detector/config.yaml"); got != "apps.yaml" {
+		t.Errorf("File with CONFIG_FILE = %q", got)
+	}
+	t.Setenv("DRIFT_DETECTOR_CONFIG_FILE", "drift.yaml")
+	if got := File("drift-detector", "/etc/drift-detector/config.yaml"); got != "drift.yaml" {
+		t.Errorf("File with DRIFT_DETECTOR_CONFIG_FILE = %q", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want string
+	}{
+		{"unknown key", "cub_spaces: x\n", nil, "cub_spaces"},
+		{"bad yaml type", "workers: many\n", nil, "`many` into int"},
+		{"bad env int", "", map[string]string{"TEST_WORKERS": "many"}, "TEST_WORKERS"},
+		{"bad env bool", "", map[string]string{"TEST_AUTO_FIX": "yes please"}, "TEST_AUTO_FIX"},
+		{"bad env duration", "", map[string]string{"TEST_INTERVAL": "5"}, "TEST_INTERVAL"},
+		{"validation", "workers: 0\n", nil, "workers must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(writeConfig(t, tt.file), defaults())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveLog(t *testing.T) {
+	effective := &Effective{Settings: []Setting{
+		{Key: "workers", Value: "8", Source: FromDefault},
+		{Key: "cub_space", Env: "CUB_SPACE", Value: "prod", Source: FromEnv},
+	}}
+
+	var lines []string
+	effective.Log(func(format string, args ...interface{}) {
+		lines = append(lines, strings.TrimRight(fmt.Sprintf(format, args...), " "))
+	})
+
+	want := []string{
+		"⚙️  Effective configuration (no config file):",
+		"   cub_space = prod (env CUB_SPACE)",
+		"   workers   = 8 (default)",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("log =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReadSecrets(t *testing.T) {
+	t.Setenv("TEST_TOKEN", "from-env")
+	cfg := defaults()
+	effective, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := effective.ReadSecrets(dir, cfg); err != nil || cfg.Token != "from-env" {
+		t.Fatalf("without file: token = %q, err = %v", cfg.Token, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test-token"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := effective.ReadSecrets(dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "from-file" {
+		t.Errorf("token = %q, want the file's", cfg.Token)
+	}
+	for _, s := range effective.Settings {
+		if s.Key == "token" && (s.Source != FromSecretFile || s.Value != "****") {
+			t.Errorf("setting = %+v", s)
+		}
+	}
+
+	if got, err := effective.ReadSecretFile("test-token"); err != nil || got != "from-file" {
+		t.Errorf("ReadSecretFile = %q, %v", got, err)
+	}
+	if got, err := effective.ReadSecretFile("missing"); err != nil || got != "" {
+		t.Errorf("ReadSecretFile(missing) = %q, %v", got, err)
+	}
+}
+
+func TestWatchSecrets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test-token")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	effective := &Effective{}
+	if err := effective.ReadSecrets(dir, defaults()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed := make(chan string, 1)
+	go effective.WatchSecrets(ctx, 10*time.Millisecond, func(path string) {
+		changed <- path
+	})
+
+	if err := os.WriteFile(path, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-changed:
+		if got != path {
+			t.Errorf("changed %q, want %q", got, path)
+		}
+	case <-ctx.Done():
+		t.Fatal("rotation not noticed")
+	}
+}
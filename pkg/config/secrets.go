@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FromSecretFile marks a secret read from the secrets directory
+const FromSecretFile Source = "secret file"
+
+// ReadSecrets overrides cfg's secret fields with files in dir, each named
+// after the field's environment variable in lower case with dashes
+// (CUB_TOKEN is read from dir/cub-token). That is the layout of a mounted
+// Kubernetes Secret, including one synced by the external-secrets operator,
+// and of the files the Vault Agent injector writes. Missing or empty files
+// leave the field as it is; an empty dir reads nothing.
+//
+// The files are remembered for WatchSecrets.
+func (e *Effective) ReadSecrets(dir string, cfg interface{}) error {
+	if dir == "" {
+		return nil
+	}
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+
+	e.secretsDir, e.secretFiles = dir, map[string][]byte{}
+	return walk(v.Elem(), "", func(key string, field reflect.StructField, value reflect.Value) error {
+		env := field.Tag.Get("env")
+		if field.Tag.Get("secret") != "true" || env == "" || value.Kind() != reflect.String {
+			return nil
+		}
+		path := filepath.Join(dir, strings.ReplaceAll(strings.ToLower(env), "_", "-"))
+		data, err := readSecretFile(path)
+		if err != nil {
+			return err
+		}
+		e.secretFiles[path] = data
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return nil
+		}
+
+		value.SetString(secret)
+		for i := range e.Settings {
+			if e.Settings[i].Key == key {
+				e.Settings[i].Source = FromSecretFile
+				e.Settings[i].Value = format(value, true)
+			}
+		}
+		return nil
+	})
+}
+
+// readSecretFile returns the file's contents, nil when it does not exist
+func readSecretFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secret: %w", err)
+	}
+	return data, nil
+}
+
+// ReadSecretFile returns the trimmed contents of the file called name in the
+// secrets directory given to ReadSecrets, for secrets that are not config
+// fields (such as per-team tokens). It returns "" when there is no secrets
+// directory or no such file. The file is watched like the others.
+func (e *Effective) ReadSecretFile(name string) (string, error) {
+	if e.secretsDir == "" {
+		return "", nil
+	}
+	path := filepath.Join(e.secretsDir, name)
+	data, err := readSecretFile(path)
+	if err != nil {
+		return "", err
+	}
+	e.secretFiles[path] = data
+	return strings.TrimSpace(string(data)), nil
+}
+
+// WatchSecrets checks the files read by ReadSecrets every interval, until ctx
+// is done, and calls onChange with the path of each file that was created,
+// rotated or removed since it was last read. The SDK clients keep the token
+// they were built with, so apps restart on a change.
+func (e *Effective) WatchSecrets(ctx context.Context, interval time.Duration, onChange func(path string)) {
+	if len(e.secretFiles) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for path, last := range e.secretFiles {
+			data, err := readSecretFile(path)
+			if err != nil || bytes.Equal(data, last) {
+				continue // an unreadable file is retried on the next tick
+			}
+			e.secretFiles[path] = data
+			onChange(path)
+		}
+	}
+}
+
+// RestartOnRotation is an onChange for WatchSecrets: it sends the process
+// SIGTERM, so the app shuts down as usual and Kubernetes starts it again
+// with the new credentials
+func RestartOnRotation(path string) {
+	slog.Warn("Credentials rotated, restarting to use them", "file", path)
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(syscall.SIGTERM)
+	}
+}
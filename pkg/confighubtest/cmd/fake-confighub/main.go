@@ -0,0 +1,41 @@
+// Command fake-confighub serves the in-memory ConfigHub fake, so the apps can
+// run their full flows without credentials:
+//
+//	fake-confighub -addr :9090 -seed seed.yaml &
+//	CUB_API_URL=http://localhost:9090 CUB_TOKEN=fake devops-apps impact
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/monadic/devops-examples/pkg/confighubtest"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	token := flag.String("token", "fake", "bearer token clients must send; empty accepts any")
+	seed := flag.String("seed", "", "YAML file of spaces and units to start with")
+	flag.Parse()
+
+	logging.Setup("fake-confighub")
+
+	server := &confighubtest.Server{Token: *token}
+	if *seed != "" {
+		data, err := os.ReadFile(*seed)
+		if err != nil {
+			logging.Fatal("Failed to read seed", logging.Err(err))
+		}
+		if err := server.LoadSeed(data); err != nil {
+			logging.Fatal("Failed to load seed", "file", *seed, logging.Err(err))
+		}
+	}
+
+	slog.Info("Fake ConfigHub listening", "addr", *addr, "spaces", len(server.Spaces()))
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		logging.Fatal("Fake ConfigHub stopped", logging.Err(err))
+	}
+}
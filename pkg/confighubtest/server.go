@@ -0,0 +1,703 @@
+// Package confighubtest is an in-memory fake of the ConfigHub API, for running
+// the apps' full flows in tests and CI without credentials. Point an app at it
+// with CUB_API_URL (or sdk.NewConfigHubClient(server.URL, server.Token)).
+//
+// It serves the endpoints the devops-sdk client uses, with bodies in the API's
+// field names (SpaceID, Slug, Labels, ...):
+//
+//	GET, POST   /space                        list, create spaces
+//	GET         /space/{space}                get a space
+//	GET, POST   /space/{space}/set            list, create sets
+//	POST        /space/{space}/filter         create a filter
+//	GET, POST   /space/{space}/unit           list (?where=, ?filter=), create units
+//	PATCH       /space/{space}/unit           bulk patch (?where=)
+//	POST        /space/{space}/unit/apply     bulk apply (?where=, ?dry_run=)
+//	GET, PATCH  /space/{space}/unit/{unit}    get, update a unit (data, labels, sets)
+//	POST        /space/{space}/unit/{unit}/apply
+//	GET         /space/{space}/unit/{unit}/livestate
+//	POST        /space/{space}/changeset      create a change set
+//	POST        /target                       create a target
+//
+// GETs below /space carry an ETag that changes with any write to the space
+// (or, for /space itself, with a new space) and answer If-None-Match with
+// 304 Not Modified.
+//
+// Where clauses support the subset the apps use, joined with AND:
+// Labels['k'] = 'v', Labels.k = 'v', Field = 'v', Field != 'v' and
+// SetIDs contains 'id'. Anything else is rejected with 400 so an unsupported
+// query fails the test instead of matching nothing.
+package confighubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Space is a ConfigHub space
+type Space struct {
+	SpaceID     uuid.UUID
+	Slug        string
+	DisplayName string
+	Labels      map[string]string
+}
+
+// Unit is a ConfigHub unit; Data holds its YAML manifest
+type Unit struct {
+	UnitID          uuid.UUID
+	SpaceID         uuid.UUID
+	Slug            string
+	DisplayName     string
+	Data            string
+	Labels          map[string]string
+	Annotations     map[string]string
+	SetIDs          []uuid.UUID
+	HeadRevisionNum int64
+	LiveState       *LiveState
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// LiveState is what the worker last reported for a unit
+type LiveState struct {
+	Status        string
+	DriftDetected bool
+}
+
+// Set groups units in a space
+type Set struct {
+	SetID       uuid.UUID
+	SpaceID     uuid.UUID
+	Slug        string
+	DisplayName string
+	Labels      map[string]string
+}
+
+// Filter is a saved where clause
+type Filter struct {
+	FilterID    uuid.UUID
+	SpaceID     uuid.UUID
+	Slug        string
+	DisplayName string
+	From        string
+	Where       string
+	Select      []string
+}
+
+// Target is where a worker applies units
+type Target struct {
+	TargetID    uuid.UUID
+	Slug        string
+	DisplayName string
+	TargetType  string
+	Config      map[string]string
+}
+
+// ChangeSet groups unit revisions
+type ChangeSet struct {
+	ChangeSetID uuid.UUID
+	SpaceID     uuid.UUID
+	DisplayName string
+	Description string
+	Labels      map[string]string
+}
+
+// Server is the fake. NewServer starts one on a local port; the zero value is
+// an http.Handler for serving it elsewhere (see cmd/fake-confighub). All
+// methods are safe for concurrent use.
+type Server struct {
+	URL   string
+	Token string // required as "Authorization: Bearer <Token>"; empty accepts any request
+
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	spaces     []*Space
+	units      []*Unit
+	sets       []*Set
+	filters    []*Filter
+	targets    []*Target
+	changeSets []*ChangeSet
+	applied    []string // "space/unit" slugs, in apply order
+
+	revision int64               // advances on every write
+	changed  map[uuid.UUID]int64 // revision of each space's last write; uuid.Nil for the space list
+}
+
+// NewServer starts a fake that requires token; call Close when done
+func NewServer(token string) *Server {
+	s := &Server{Token: token}
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// AddSpace seeds a space and returns it
+func (s *Server) AddSpace(slug string, labels map[string]string) *Space {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	space := &Space{SpaceID: uuid.New(), Slug: slug, DisplayName: slug, Labels: copyLabels(labels)}
+	s.spaces = append(s.spaces, space)
+	s.bump(uuid.Nil)
+	return copySpace(space)
+}
+
+// AddUnit seeds a unit in a space and returns it
+func (s *Server) AddUnit(spaceID uuid.UUID, slug, data string, labels map[string]string) *Unit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	unit := &Unit{
+		UnitID: uuid.New(), SpaceID: spaceID, Slug: slug, DisplayName: slug, Data: data,
+		Labels: copyLabels(labels), HeadRevisionNum: 1, CreatedAt: now, UpdatedAt: now,
+	}
+	s.units = append(s.units, unit)
+	s.bump(spaceID)
+	return copyUnit(unit)
+}
+
+// AddSet seeds a set in a space and returns it
+func (s *Server) AddSet(spaceID uuid.UUID, slug string, labels map[string]string) *Set {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := &Set{SetID: uuid.New(), SpaceID: spaceID, Slug: slug, DisplayName: slug, Labels: copyLabels(labels)}
+	s.sets = append(s.sets, set)
+	s.bump(spaceID)
+	copied := *set
+	return &copied
+}
+
+// AddToSet puts a unit into a set
+func (s *Server) AddToSet(unitID, setID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if unit := s.findUnit(uuid.Nil, unitID.String()); unit != nil {
+		unit.SetIDs = append(unit.SetIDs, setID)
+		s.bump(unit.SpaceID)
+	}
+}
+
+// SetLiveState sets what a unit's worker reports, e.g. to simulate drift
+func (s *Server) SetLiveState(unitID uuid.UUID, state LiveState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if unit := s.findUnit(uuid.Nil, unitID.String()); unit != nil {
+		unit.LiveState = &state
+		s.bump(unit.SpaceID)
+	}
+}
+
+// Spaces returns copies of all spaces
+func (s *Server) Spaces() []*Space {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	spaces := make([]*Space, len(s.spaces))
+	for i, space := range s.spaces {
+		spaces[i] = copySpace(space)
+	}
+	return spaces
+}
+
+// Units returns copies of a space's units
+func (s *Server) Units(spaceID uuid.UUID) []*Unit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var units []*Unit
+	for _, unit := range s.units {
+		if unit.SpaceID == spaceID {
+			units = append(units, copyUnit(unit))
+		}
+	}
+	return units
+}
+
+// Sets returns copies of a space's sets
+func (s *Server) Sets(spaceID uuid.UUID) []*Set {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setsIn(spaceID)
+}
+
+func (s *Server) setsIn(spaceID uuid.UUID) []*Set {
+	sets := []*Set{}
+	for _, set := range s.sets {
+		if set.SpaceID == spaceID {
+			copied := *set
+			sets = append(sets, &copied)
+		}
+	}
+	return sets
+}
+
+// Applied returns the units applied so far as "space/unit", in order
+func (s *Server) Applied() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.applied...)
+}
+
+// ServeHTTP serves the ConfigHub API from memory
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case len(parts) == 1 && parts[0] == "target" && r.Method == http.MethodPost:
+		s.createTarget(w, r)
+	case len(parts) >= 1 && parts[0] == "space":
+		if s.notModified(w, r, parts[1:]) {
+			return
+		}
+		s.serveSpace(w, r, parts[1:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// notModified sets the ETag of a GET below /space and answers 304 when the
+// client has it already. Other methods mark the space changed. s.mu is held.
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request, parts []string) bool {
+	key := uuid.Nil
+	if len(parts) > 0 {
+		space := s.findSpace(parts[0])
+		if space == nil {
+			return false
+		}
+		key = space.SpaceID
+	}
+	if r.Method != http.MethodGet {
+		s.bump(key)
+		return false
+	}
+	etag := fmt.Sprintf(`"%s-%d"`, key, s.changed[key])
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// bump marks a space, or with uuid.Nil the space list, changed; s.mu is held
+func (s *Server) bump(spaceID uuid.UUID) {
+	if s.changed == nil {
+		s.changed = map[uuid.UUID]int64{}
+	}
+	s.revision++
+	s.changed[spaceID] = s.revision
+}
+
+// serveSpace handles /space and everything below it; s.mu is held
+func (s *Server) serveSpace(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.spaces)
+		case http.MethodPost:
+			s.createSpace(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	space := s.findSpace(parts[0])
+	if space == nil {
+		http.Error(w, fmt.Sprintf("space %s not found", parts[0]), http.StatusNotFound)
+		return
+	}
+
+	route := r.Method + " " + strings.Join(parts[1:], "/")
+	switch {
+	case route == "GET ":
+		writeJSON(w, http.StatusOK, space)
+	case route == "GET set":
+		writeJSON(w, http.StatusOK, s.setsIn(space.SpaceID))
+	case route == "POST set":
+		s.createSet(w, r, space)
+	case route == "POST filter":
+		s.createFilter(w, r, space)
+	case route == "GET unit":
+		s.listUnits(w, r, space)
+	case route == "POST unit":
+		s.createUnit(w, r, space)
+	case route == "PATCH unit":
+		s.bulkPatch(w, r, space)
+	case route == "POST unit/apply":
+		s.bulkApply(w, r, space)
+	case route == "POST changeset":
+		s.createChangeSet(w, r, space)
+	case len(parts) >= 3 && parts[1] == "unit":
+		unit := s.findUnit(space.SpaceID, parts[2])
+		if unit == nil {
+			http.Error(w, fmt.Sprintf("unit %s not found", parts[2]), http.StatusNotFound)
+			return
+		}
+		s.serveUnit(w, r, space, unit, strings.Join(parts[3:], "/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveUnit handles /space/{space}/unit/{unit}/...; s.mu is held
+func (s *Server) serveUnit(w http.ResponseWriter, r *http.Request, space *Space, unit *Unit, rest string) {
+	switch r.Method + " " + rest {
+	case "GET ":
+		writeJSON(w, http.StatusOK, unit)
+	case "PATCH ", "PUT ":
+		var req struct {
+			Data   string
+			Labels map[string]string
+			SetIDs *[]uuid.UUID // nil leaves the unit's sets alone
+		}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Data != "" {
+			unit.Data = req.Data
+		}
+		if req.Labels != nil {
+			unit.Labels = copyLabels(req.Labels)
+		}
+		if req.SetIDs != nil {
+			unit.SetIDs = append([]uuid.UUID(nil), *req.SetIDs...)
+		}
+		s.touch(unit)
+		writeJSON(w, http.StatusOK, unit)
+	case "POST apply":
+		s.apply(space, unit)
+		writeJSON(w, http.StatusOK, unit)
+	case "GET livestate":
+		state := unit.LiveState
+		if state == nil {
+			state = &LiveState{Status: "Unknown"}
+		}
+		writeJSON(w, http.StatusOK, state)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) createSpace(w http.ResponseWriter, r *http.Request) {
+	var req Space
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Slug == "" {
+		http.Error(w, "Slug is required", http.StatusBadRequest)
+		return
+	}
+	if s.findSpace(req.Slug) != nil {
+		http.Error(w, fmt.Sprintf("space %s already exists", req.Slug), http.StatusConflict)
+		return
+	}
+	space := &Space{SpaceID: uuid.New(), Slug: req.Slug, DisplayName: req.DisplayName, Labels: copyLabels(req.Labels)}
+	s.spaces = append(s.spaces, space)
+	writeJSON(w, http.StatusCreated, space)
+}
+
+func (s *Server) createSet(w http.ResponseWriter, r *http.Request, space *Space) {
+	var req Set
+	if !readJSON(w, r, &req) {
+		return
+	}
+	for _, set := range s.sets {
+		if set.SpaceID == space.SpaceID && set.Slug == req.Slug {
+			http.Error(w, fmt.Sprintf("set %s already exists", req.Slug), http.StatusConflict)
+			return
+		}
+	}
+	set := &Set{SetID: uuid.New(), SpaceID: space.SpaceID, Slug: req.Slug, DisplayName: req.DisplayName, Labels: copyLabels(req.Labels)}
+	s.sets = append(s.sets, set)
+	writeJSON(w, http.StatusCreated, set)
+}
+
+func (s *Server) createFilter(w http.ResponseWriter, r *http.Request, space *Space) {
+	var req Filter
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if _, err := parseWhere(req.Where); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.FilterID = uuid.New()
+	req.SpaceID = space.SpaceID
+	s.filters = append(s.filters, &req)
+	writeJSON(w, http.StatusCreated, &req)
+}
+
+func (s *Server) createTarget(w http.ResponseWriter, r *http.Request) {
+	var req Target
+	if !readJSON(w, r, &req) {
+		return
+	}
+	req.TargetID = uuid.New()
+	s.targets = append(s.targets, &req)
+	writeJSON(w, http.StatusCreated, &req)
+}
+
+func (s *Server) createChangeSet(w http.ResponseWriter, r *http.Request, space *Space) {
+	var req ChangeSet
+	if !readJSON(w, r, &req) {
+		return
+	}
+	req.ChangeSetID = uuid.New()
+	req.SpaceID = space.SpaceID
+	s.changeSets = append(s.changeSets, &req)
+	writeJSON(w, http.StatusCreated, &req)
+}
+
+func (s *Server) createUnit(w http.ResponseWriter, r *http.Request, space *Space) {
+	var req Unit
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if s.findUnit(space.SpaceID, req.Slug) != nil {
+		http.Error(w, fmt.Sprintf("unit %s already exists", req.Slug), http.StatusConflict)
+		return
+	}
+	now := time.Now().UTC()
+	unit := &Unit{
+		UnitID: uuid.New(), SpaceID: space.SpaceID, Slug: req.Slug, DisplayName: req.DisplayName,
+		Data: req.Data, Labels: copyLabels(req.Labels), SetIDs: req.SetIDs,
+		HeadRevisionNum: 1, CreatedAt: now, UpdatedAt: now,
+	}
+	s.units = append(s.units, unit)
+	writeJSON(w, http.StatusCreated, unit)
+}
+
+func (s *Server) listUnits(w http.ResponseWriter, r *http.Request, space *Space) {
+	where := r.URL.Query().Get("where")
+	if id := r.URL.Query().Get("filter"); id != "" {
+		filter := s.findFilter(id)
+		if filter == nil {
+			http.Error(w, fmt.Sprintf("filter %s not found", id), http.StatusNotFound)
+			return
+		}
+		where = joinWhere(filter.Where, where)
+	}
+	units, ok := s.selectUnits(w, space, where)
+	if ok {
+		writeJSON(w, http.StatusOK, units)
+	}
+}
+
+func (s *Server) bulkPatch(w http.ResponseWriter, r *http.Request, space *Space) {
+	var patch map[string]interface{}
+	if !readJSON(w, r, &patch) {
+		return
+	}
+	units, ok := s.selectUnits(w, space, r.URL.Query().Get("where"))
+	if !ok {
+		return
+	}
+	for _, unit := range units {
+		data, err := mergeYAML(unit.Data, patch)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("patch unit %s: %v", unit.Slug, err), http.StatusUnprocessableEntity)
+			return
+		}
+		unit.Data = data
+		s.touch(unit)
+	}
+	writeJSON(w, http.StatusOK, units)
+}
+
+func (s *Server) bulkApply(w http.ResponseWriter, r *http.Request, space *Space) {
+	units, ok := s.selectUnits(w, space, r.URL.Query().Get("where"))
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("dry_run") != "true" {
+		for _, unit := range units {
+			s.apply(space, unit)
+		}
+	}
+	writeJSON(w, http.StatusOK, units)
+}
+
+// selectUnits returns the space's units matching where, writing a 400 for an
+// unsupported clause
+func (s *Server) selectUnits(w http.ResponseWriter, space *Space, where string) ([]*Unit, bool) {
+	match, err := parseWhere(where)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	units := []*Unit{}
+	for _, unit := range s.units {
+		if unit.SpaceID == space.SpaceID && match(unit) {
+			units = append(units, unit)
+		}
+	}
+	return units, true
+}
+
+func (s *Server) apply(space *Space, unit *Unit) {
+	unit.LiveState = &LiveState{Status: "Applied"}
+	s.applied = append(s.applied, space.Slug+"/"+unit.Slug)
+}
+
+func (s *Server) touch(unit *Unit) {
+	unit.HeadRevisionNum++
+	unit.UpdatedAt = time.Now().UTC()
+}
+
+// findSpace looks a space up by ID or slug
+func (s *Server) findSpace(ref string) *Space {
+	for _, space := range s.spaces {
+		if space.SpaceID.String() == ref || space.Slug == ref {
+			return space
+		}
+	}
+	return nil
+}
+
+// findUnit looks a unit up by ID or slug; a nil spaceID searches every space
+func (s *Server) findUnit(spaceID uuid.UUID, ref string) *Unit {
+	for _, unit := range s.units {
+		if spaceID != uuid.Nil && unit.SpaceID != spaceID {
+			continue
+		}
+		if unit.UnitID.String() == ref || unit.Slug == ref {
+			return unit
+		}
+	}
+	return nil
+}
+
+func (s *Server) findFilter(id string) *Filter {
+	for _, filter := range s.filters {
+		if filter.FilterID.String() == id {
+			return filter
+		}
+	}
+	return nil
+}
+
+// mergeYAML applies a JSON merge patch to a YAML document
+func mergeYAML(data string, patch map[string]interface{}) (string, error) {
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return "", err
+	}
+	merged, err := yaml.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return "", err
+	}
+	return string(merged), nil
+}
+
+func mergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(doc, k)
+		case map[string]interface{}:
+			existing, _ := doc[k].(map[string]interface{})
+			doc[k] = mergePatch(existing, v)
+		default:
+			doc[k] = v
+		}
+	}
+	return doc
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "decode request: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
+
+func copySpace(space *Space) *Space {
+	copied := *space
+	copied.Labels = copyLabels(space.Labels)
+	return &copied
+}
+
+func copyUnit(unit *Unit) *Unit {
+	copied := *unit
+	copied.Labels = copyLabels(unit.Labels)
+	copied.SetIDs = append([]uuid.UUID(nil), unit.SetIDs...)
+	if unit.LiveState != nil {
+		state := *unit.LiveState
+		copied.LiveState = &state
+	}
+	return &copied
+}
+
+// Seed is initial content for a fake, usually read from YAML:
+//
+//	spaces:
+//	- slug: demo
+//	  labels: {environment: dev}
+//	  units:
+//	  - slug: backend
+//	    labels: {cpu: 500m, memory: 1Gi, replicas: "2"}
+//	    data: |
+//	      apiVersion: apps/v1
+//	      kind: Deployment
+type Seed struct {
+	Spaces []struct {
+		Slug   string            `yaml:"slug"`
+		Labels map[string]string `yaml:"labels"`
+		Units  []struct {
+			Slug   string            `yaml:"slug"`
+			Labels map[string]string `yaml:"labels"`
+			Data   string            `yaml:"data"`
+		} `yaml:"units"`
+	} `yaml:"spaces"`
+}
+
+// LoadSeed adds the spaces and units of a YAML seed
+func (s *Server) LoadSeed(data []byte) error {
+	var seed Seed
+	if err := yaml.Unmarshal(data, &seed); err != nil {
+		return fmt.Errorf("parse seed: %w", err)
+	}
+	for _, sp := range seed.Spaces {
+		space := s.AddSpace(sp.Slug, sp.Labels)
+		for _, u := range sp.Units {
+			s.AddUnit(space.SpaceID, u.Slug, u.Data, u.Labels)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package confighubtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// call sends a JSON request to the fake and decodes the response into out
+func call(t *testing.T, s *Server, method, path string, body, out interface{}) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, err := http.NewRequest(method, s.URL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAuth(t *testing.T) {
+	s := NewServer("secret")
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/space")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated request = %d, want 401", resp.StatusCode)
+	}
+	if code := call(t, s, "GET", "/space", nil, nil); code != http.StatusOK {
+		t.Errorf("authenticated request = %d, want 200", code)
+	}
+}
+
+func TestSpacesSetsAndUnits(t *testing.T) {
+	s := NewServer("token")
+	defer s.Close()
+
+	var space Space
+	if code := call(t, s, "POST", "/space", Space{Slug: "drift-test", Labels: map[string]string{"app": "drift"}}, &space); code != http.StatusCreated {
+		t.Fatalf("create space = %d", code)
+	}
+	if code := call(t, s, "POST", "/space", Space{Slug: "drift-test"}, nil); code != http.StatusConflict {
+		t.Errorf("duplicate space = %d, want 409", code)
+	}
+	var got Space
+	call(t, s, "GET", "/space/"+space.SpaceID.String(), nil, &got)
+	if got.Slug != "drift-test" || got.Labels["app"] != "drift" {
+		t.Errorf("get space = %+v", got)
+	}
+
+	var set Set
+	call(t, s, "POST", "/space/drift-test/set", Set{Slug: "critical-services"}, &set)
+	var sets []Set
+	call(t, s, "GET", "/space/drift-test/set", nil, &sets)
+	if len(sets) != 1 || sets[0].SetID != set.SetID {
+		t.Errorf("sets = %+v", sets)
+	}
+
+	base := "/space/" + space.SpaceID.String() + "/unit"
+	call(t, s, "POST", base, Unit{Slug: "backend", Data: "spec:\n  replicas: 2\n", Labels: map[string]string{"tier": "critical"}, SetIDs: []uuid.UUID{set.SetID}}, nil)
+	call(t, s, "POST", base, Unit{Slug: "frontend", Data: "spec:\n  replicas: 1\n", Labels: map[string]string{"tier": "web"}}, nil)
+
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"", []string{"backend", "frontend"}},
+		{"Labels['tier'] = 'critical'", []string{"backend"}},
+		{"Labels.tier != 'critical'", []string{"frontend"}},
+		{"SetIDs contains '" + set.SetID.String() + "' AND Slug = 'backend'", []string{"backend"}},
+		{"SetIDs contains '" + set.SetID.String() + "' and Labels['tier'] = 'web'", nil},
+	}
+	for _, tt := range tests {
+		var units []Unit
+		if code := call(t, s, "GET", base+"?where="+url.QueryEscape(tt.where), nil, &units); code != http.StatusOK {
+			t.Errorf("where %q = %d", tt.where, code)
+			continue
+		}
+		var slugs []string
+		for _, u := range units {
+			slugs = append(slugs, u.Slug)
+		}
+		if strings.Join(slugs, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("where %q = %v, want %v", tt.where, slugs, tt.want)
+		}
+	}
+
+	if code := call(t, s, "GET", base+"?where="+url.QueryEscape("Labels.cost > '10'"), nil, nil); code != http.StatusBadRequest {
+		t.Errorf("unsupported where = %d, want 400", code)
+	}
+
+	// A unit joins a set by update; updating only its labels keeps its sets
+	var frontend Unit
+	call(t, s, "PATCH", base+"/frontend", map[string]interface{}{"SetIDs": []uuid.UUID{set.SetID}}, nil)
+	call(t, s, "PATCH", base+"/frontend", map[string]interface{}{"Labels": map[string]string{"tier": "web", "team": "storefront"}}, &frontend)
+	if len(frontend.SetIDs) != 1 || frontend.SetIDs[0] != set.SetID || frontend.Data != "spec:\n  replicas: 1\n" {
+		t.Errorf("updated unit = %+v", frontend)
+	}
+
+	var filter Filter
+	call(t, s, "POST", "/space/drift-test/filter", Filter{Slug: "critical", From: "Unit", Where: "Labels['tier'] = 'critical'"}, &filter)
+	var filtered []Unit
+	call(t, s, "GET", base+"?filter="+filter.FilterID.String(), nil, &filtered)
+	if len(filtered) != 1 || filtered[0].Slug != "backend" {
+		t.Errorf("filtered units = %+v", filtered)
+	}
+}
+
+func TestBulkPatchAndApply(t *testing.T) {
+	s := NewServer("")
+	defer s.Close()
+
+	space := s.AddSpace("cost", nil)
+	backend := s.AddUnit(space.SpaceID, "backend", "spec:\n  replicas: 2\n  template:\n    image: api:1\n", map[string]string{"tier": "critical"})
+	s.AddUnit(space.SpaceID, "frontend", "spec:\n  replicas: 1\n", nil)
+	base := "/space/" + space.SpaceID.String() + "/unit"
+
+	where := "?where=" + url.QueryEscape("Labels['tier'] = 'critical'")
+	patch := map[string]interface{}{"spec": map[string]interface{}{"replicas": 3}}
+	if code := call(t, s, "PATCH", base+where, patch, nil); code != http.StatusOK {
+		t.Fatalf("bulk patch = %d", code)
+	}
+
+	var unit Unit
+	call(t, s, "GET", base+"/backend", nil, &unit)
+	if !strings.Contains(unit.Data, "replicas: 3") || !strings.Contains(unit.Data, "image: api:1") {
+		t.Errorf("patched data = %q", unit.Data)
+	}
+	if unit.HeadRevisionNum != 2 {
+		t.Errorf("revision = %d, want 2", unit.HeadRevisionNum)
+	}
+
+	call(t, s, "POST", base+"/apply"+where+"&dry_run=true", nil, nil)
+	if applied := s.Applied(); len(applied) != 0 {
+		t.Errorf("dry run applied %v", applied)
+	}
+	call(t, s, "POST", base+"/apply"+where, nil, nil)
+	call(t, s, "POST", base+"/frontend/apply", nil, nil)
+	if applied := strings.Join(s.Applied(), ","); applied != "cost/backend,cost/frontend" {
+		t.Errorf("applied = %s", applied)
+	}
+
+	s.SetLiveState(backend.UnitID, LiveState{Status: "Drifted", DriftDetected: true})
+	var state LiveState
+	call(t, s, "GET", base+"/"+backend.UnitID.String()+"/livestate", nil, &state)
+	if !state.DriftDetected {
+		t.Errorf("live state = %+v", state)
+	}
+
+	call(t, s, "PATCH", base+"/frontend", map[string]interface{}{"Labels": map[string]string{"tier": "web"}}, nil)
+	if units := s.Units(space.SpaceID); units[1].Labels["tier"] != "web" || units[1].Data != "spec:\n  replicas: 1\n" {
+		t.Errorf("updated unit = %+v", units[1])
+	}
+}
+
+func TestETag(t *testing.T) {
+	s := NewServer("")
+	defer s.Close()
+	s.AddSpace("payments", nil)
+	other := s.AddSpace("other", nil)
+
+	get := func(path, etag string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, s.URL+path, nil)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get("ETag")
+	}
+	units := "/space/payments/unit"
+	_, etag := get(units, "")
+	if code, _ := get(units, etag); code != http.StatusNotModified {
+		t.Errorf("unchanged listing = %d, want 304", code)
+	}
+	_, spacesETag := get("/space", "")
+
+	// Writes to another space leave this one's ETag alone
+	s.AddUnit(other.SpaceID, "web", "", nil)
+	if code, _ := get(units, etag); code != http.StatusNotModified {
+		t.Errorf("listing after a write elsewhere = %d, want 304", code)
+	}
+	call(t, s, "POST", units, Unit{Slug: "api"}, nil)
+	if code, changed := get(units, etag); code != http.StatusOK || changed == etag {
+		t.Errorf("listing after a write = %d with ETag %s", code, changed)
+	}
+	if code, _ := get("/space", spacesETag); code != http.StatusNotModified {
+		t.Errorf("space list after a unit write = %d, want 304", code)
+	}
+	s.AddSpace("billing", nil)
+	if code, _ := get("/space", spacesETag); code != http.StatusOK {
+		t.Errorf("space list after a new space = %d, want 200", code)
+	}
+}
+
+func TestLoadSeed(t *testing.T) {
+	var s Server
+	err := s.LoadSeed([]byte(`
+spaces:
+- slug: demo
+  labels: {environment: dev}
+  units:
+  - slug: backend
+    labels: {cpu: 500m, replicas: "2"}
+    data: |
+      kind: Deployment
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spaces := s.Spaces()
+	if len(spaces) != 1 || spaces[0].Labels["environment"] != "dev" {
+		t.Fatalf("spaces = %+v", spaces)
+	}
+	units := s.Units(spaces[0].SpaceID)
+	if len(units) != 1 || units[0].Labels["cpu"] != "500m" || units[0].Data != "kind: Deployment\n" {
+		t.Errorf("units = %+v", units)
+	}
+
+	if err := s.LoadSeed([]byte("spaces: {")); err == nil {
+		t.Error("invalid seed accepted")
+	}
+}
@@ -0,0 +1,92 @@
+package confighubtest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	andPattern      = regexp.MustCompile(`(?i)\s+AND\s+`)
+	containsPattern = regexp.MustCompile(`^SetIDs\s+contains\s+'([^']*)'$`)
+	comparePattern  = regexp.MustCompile(`^(?:Labels\['([^']+)'\]|Labels\.([\w.-]+)|(\w+))\s*(=|!=)\s*'([^']*)'$`)
+)
+
+// parseWhere compiles a where clause into a unit predicate; empty matches everything
+func parseWhere(where string) (func(*Unit) bool, error) {
+	var preds []func(*Unit) bool
+	if strings.TrimSpace(where) != "" {
+		for _, clause := range andPattern.Split(strings.TrimSpace(where), -1) {
+			pred, err := parseClause(clause)
+			if err != nil {
+				return nil, err
+			}
+			preds = append(preds, pred)
+		}
+	}
+	return func(u *Unit) bool {
+		for _, pred := range preds {
+			if !pred(u) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func parseClause(clause string) (func(*Unit) bool, error) {
+	clause = strings.TrimSpace(clause)
+
+	if m := containsPattern.FindStringSubmatch(clause); m != nil {
+		id := m[1]
+		return func(u *Unit) bool {
+			for _, setID := range u.SetIDs {
+				if setID.String() == id {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	m := comparePattern.FindStringSubmatch(clause)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported where clause %q", clause)
+	}
+	label, field, op, value := m[1]+m[2], m[3], m[4], m[5]
+
+	var get func(*Unit) string
+	switch {
+	case label != "":
+		get = func(u *Unit) string { return u.Labels[label] }
+	case field == "UnitID":
+		get = func(u *Unit) string { return u.UnitID.String() }
+	case field == "SpaceID":
+		get = func(u *Unit) string { return u.SpaceID.String() }
+	case field == "Slug":
+		get = func(u *Unit) string { return u.Slug }
+	case field == "DisplayName":
+		get = func(u *Unit) string { return u.DisplayName }
+	case field == "HeadRevisionNum":
+		get = func(u *Unit) string { return strconv.FormatInt(u.HeadRevisionNum, 10) }
+	default:
+		return nil, fmt.Errorf("unsupported where field %q", field)
+	}
+
+	if op == "!=" {
+		return func(u *Unit) bool { return get(u) != value }, nil
+	}
+	return func(u *Unit) bool { return get(u) == value }, nil
+}
+
+// joinWhere ANDs two clauses, either of which may be empty
+func joinWhere(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + " AND " + b
+}
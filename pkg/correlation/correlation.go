@@ -0,0 +1,61 @@
+// Package correlation ties together what the apps do about one incident, so
+// an operator can follow "replicas drifted → cost spiked → fix applied"
+// across the drift-detector, cost-impact-monitor and ConfigHub.
+//
+// The drift-detector starts a correlation ID when it first sees drift in a
+// space and keeps it until the space is clean again. The ID travels in the
+// context of the calls that follow, and the shared packages copy it into
+// what they produce: events on the bus, drift stream snapshots, audit
+// entries, notifications, unit labels and log records.
+//
+//	ctx = correlation.With(ctx, correlation.New())
+//	slog.Warn("Drift detected", logging.Correlation(correlation.FromContext(ctx)))
+//
+// Search for the ID in logs, or list its audit entries at
+// /api/v1/audit?correlation=<id>, to see the whole story.
+package correlation
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Label is the ConfigHub unit label holding the correlation ID of the units
+// and change sets an app creates for an incident
+const Label = "correlation-id"
+
+// New returns a fresh correlation ID
+func New() string {
+	return uuid.NewString()
+}
+
+type contextKey struct{}
+
+// With returns ctx carrying id; an empty id leaves ctx as it is
+func With(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the correlation ID of ctx, "" when there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Labels adds the correlation ID of ctx to labels, allocating them if
+// needed, and returns them; without an ID labels are returned unchanged
+func Labels(ctx context.Context, labels map[string]string) map[string]string {
+	id := FromContext(ctx)
+	if id == "" {
+		return labels
+	}
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+	labels[Label] = id
+	return labels
+}
@@ -0,0 +1,39 @@
+package correlation
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if id := FromContext(ctx); id != "" {
+		t.Errorf("FromContext of a bare context = %q, want none", id)
+	}
+	if With(ctx, "") != ctx {
+		t.Error("With an empty ID changed the context")
+	}
+
+	id := New()
+	if other := New(); other == id {
+		t.Errorf("New returned %q twice", id)
+	}
+	if got := FromContext(With(ctx, id)); got != id {
+		t.Errorf("FromContext = %q, want %q", got, id)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	if labels := Labels(context.Background(), nil); labels != nil {
+		t.Errorf("Labels without an ID = %v, want nil", labels)
+	}
+
+	ctx := With(context.Background(), "abc")
+	labels := Labels(ctx, map[string]string{"type": "drift-correction"})
+	if labels[Label] != "abc" || labels["type"] != "drift-correction" {
+		t.Errorf("Labels = %v", labels)
+	}
+	if labels := Labels(ctx, nil); labels[Label] != "abc" {
+		t.Errorf("Labels(nil) = %v", labels)
+	}
+}
@@ -0,0 +1,368 @@
+// Package costhistory keeps a point per cost analysis - the monthly cost,
+// potential savings, and open and applied recommendations - so an app can
+// show whether costs trend down once recommendations are applied:
+//
+//	GET /api/v1/history?since=168h           the points, oldest first
+//	GET /api/v1/trends?since=720h&bucket=day  averages per day and the change
+//
+// Points of the last day are kept as recorded; older ones are thinned to the
+// last of each hour, and those past the retention dropped. The series is
+// written, as one JSON document, to the ConfigHub unit cost-history-<app> of
+// the space named by HISTORY_SPACE, or to a file in a directory; without
+// either it is kept in memory only.
+package costhistory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+)
+
+// Label marks the ConfigHub units holding cost histories; its value is the
+// app
+const Label = "cost-history"
+
+// DefaultRetention is how long points are kept when no retention is given
+const DefaultRetention = 90 * 24 * time.Hour
+
+// recent is how long points are kept as recorded before being thinned
+const recent = 24 * time.Hour
+
+// Writer stores the history as the ConfigHub unit with the given slug,
+// creating it or replacing its data and labels
+type Writer func(ctx context.Context, slug string, labels map[string]string, data string) error
+
+// Reader returns the data of the ConfigHub units matching a where clause
+type Reader func(ctx context.Context, where string) ([]string, error)
+
+// Point is one analysis
+type Point struct {
+	Time             time.Time `json:"time"`
+	MonthlyCost      float64   `json:"monthly_cost"`
+	PotentialSavings float64   `json:"potential_savings"`
+	Recommendations  int       `json:"recommendations"` // not applied yet
+	Applied          int       `json:"applied"`         // applied so far
+}
+
+// Document is what a history unit holds
+type Document struct {
+	App    string  `json:"app"`
+	Points []Point `json:"points"`
+}
+
+// History is one app's cost series. It is safe for concurrent use; a nil
+// History records nothing.
+type History struct {
+	app       string
+	writer    Writer
+	reader    Reader
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	points []Point // oldest first
+}
+
+// New returns app's history, kept for retention (DefaultRetention when not
+// positive) and stored through writer and reader; without them it is kept
+// in memory only
+func New(app string, writer Writer, reader Reader, retention time.Duration) *History {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &History{app: app, writer: writer, reader: reader, retention: retention, now: time.Now}
+}
+
+// Slug names the unit of app's history
+func Slug(app string) string {
+	return "cost-history-" + app
+}
+
+// Where returns the ConfigHub where clause of app's history unit
+func Where(app string) string {
+	return fmt.Sprintf("Labels['%s'] = '%s'", Label, app)
+}
+
+// Dir returns a Writer and Reader keeping the history as a JSON file in dir,
+// for a history that survives restarts without ConfigHub
+func Dir(dir string) (Writer, Reader, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("create history dir: %w", err)
+	}
+	writer := func(_ context.Context, slug string, _ map[string]string, data string) error {
+		tmp := filepath.Join(dir, slug+".json.tmp")
+		if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, filepath.Join(dir, slug+".json"))
+	}
+	reader := func(context.Context, string) ([]string, error) {
+		paths, err := filepath.Glob(filepath.Join(dir, "cost-history-*.json"))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]string, 0, len(paths))
+		for _, path := range paths {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, string(b))
+		}
+		return data, nil
+	}
+	return writer, reader, nil
+}
+
+// Load reads the stored history, merged with any points recorded since
+// startup. A missing history is not an error.
+func (h *History) Load(ctx context.Context) error {
+	if h == nil || h.reader == nil {
+		return nil
+	}
+	data, err := h.reader(ctx, Where(h.app))
+	if err != nil {
+		return fmt.Errorf("read cost history: %w", err)
+	}
+	for _, d := range data {
+		var doc Document
+		if err := json.Unmarshal([]byte(d), &doc); err != nil {
+			return fmt.Errorf("decode cost history: %w", err)
+		}
+		if doc.App != h.app {
+			continue
+		}
+		h.mu.Lock()
+		h.points = compact(append(doc.Points, h.points...), h.now(), h.retention)
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// Record adds a point and writes the history
+func (h *History) Record(ctx context.Context, p Point) error {
+	if h == nil {
+		return nil
+	}
+	if p.Time.IsZero() {
+		p.Time = h.now()
+	}
+	h.mu.Lock()
+	h.points = compact(append(h.points, p), h.now(), h.retention)
+	doc := Document{App: h.app, Points: append([]Point(nil), h.points...)}
+	h.mu.Unlock()
+
+	if h.writer == nil {
+		return nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encode cost history: %w", err)
+	}
+	if err := h.writer(ctx, Slug(h.app), map[string]string{Label: h.app}, string(data)); err != nil {
+		return fmt.Errorf("write cost history: %w", err)
+	}
+	return nil
+}
+
+// Points returns the points recorded since since, oldest first
+func (h *History) Points(since time.Time) []Point {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	start := sort.Search(len(h.points), func(i int) bool { return !h.points[i].Time.Before(since) })
+	return append([]Point{}, h.points[start:]...)
+}
+
+// compact sorts points, drops those past the retention and keeps the last
+// of each hour of those older than a day
+func compact(points []Point, now time.Time, retention time.Duration) []Point {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	kept := points[:0]
+	for i, p := range points {
+		age := now.Sub(p.Time)
+		if age > retention {
+			continue
+		}
+		if age > recent && i+1 < len(points) && now.Sub(points[i+1].Time) > recent &&
+			points[i+1].Time.Truncate(time.Hour).Equal(p.Time.Truncate(time.Hour)) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// Buckets the trends are averaged over
+var buckets = map[string]time.Duration{"hour": time.Hour, "day": 24 * time.Hour, "week": 7 * 24 * time.Hour}
+
+// Bucket is the points of one hour, day or week
+type Bucket struct {
+	Start            time.Time `json:"start"`
+	MonthlyCost      float64   `json:"monthly_cost"`      // average
+	PotentialSavings float64   `json:"potential_savings"` // average
+	Applied          int       `json:"applied"`           // at the end of the bucket
+	Points           int       `json:"points"`
+}
+
+// Trends is the body of GET /api/v1/trends
+type Trends struct {
+	Bucket  string   `json:"bucket"`
+	Buckets []Bucket `json:"buckets"` // oldest first
+	// Change of the monthly cost from the first bucket to the last, and the
+	// least-squares slope of all points, in dollars per day
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+	SlopePerDay   float64 `json:"slope_per_day"`
+	Direction     string  `json:"direction"` // down, up, or flat within 1%
+}
+
+// Trend averages points, oldest first, into buckets of the given size;
+// days and weeks are UTC, weeks starting on Monday
+func Trend(points []Point, bucket string) (Trends, error) {
+	size, ok := buckets[bucket]
+	if !ok {
+		return Trends{}, fmt.Errorf("bucket %q is not hour, day or week", bucket)
+	}
+	t := Trends{Bucket: bucket, Buckets: []Bucket{}, Direction: "flat"}
+	for _, p := range points {
+		start := p.Time.UTC().Truncate(size)
+		if n := len(t.Buckets); n == 0 || !t.Buckets[n-1].Start.Equal(start) {
+			t.Buckets = append(t.Buckets, Bucket{Start: start})
+		}
+		b := &t.Buckets[len(t.Buckets)-1]
+		b.MonthlyCost += p.MonthlyCost
+		b.PotentialSavings += p.PotentialSavings
+		b.Applied = p.Applied
+		b.Points++
+	}
+	for i := range t.Buckets {
+		b := &t.Buckets[i]
+		b.MonthlyCost /= float64(b.Points)
+		b.PotentialSavings /= float64(b.Points)
+	}
+	if len(t.Buckets) < 2 {
+		return t, nil
+	}
+
+	first, last := t.Buckets[0].MonthlyCost, t.Buckets[len(t.Buckets)-1].MonthlyCost
+	t.Change = last - first
+	if first > 0 {
+		t.ChangePercent = t.Change / first * 100
+	}
+	t.SlopePerDay = slope(points)
+	switch {
+	case t.ChangePercent <= -1:
+		t.Direction = "down"
+	case t.ChangePercent >= 1:
+		t.Direction = "up"
+	}
+	return t, nil
+}
+
+// slope is the least-squares change of the monthly cost per day
+func slope(points []Point) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+	origin := points[0].Time
+	var n, sx, sy, sxx, sxy float64
+	for _, p := range points {
+		x := p.Time.Sub(origin).Hours() / 24
+		n++
+		sx += x
+		sy += p.MonthlyCost
+		sxx += x * x
+		sxy += x * p.MonthlyCost
+	}
+	d := n*sxx - sx*sx
+	if d == 0 || math.IsNaN(d) {
+		return 0
+	}
+	return (n*sxy - sx*sy) / d
+}
+
+// HistoryOperation documents GET /api/v1/history
+var HistoryOperation = api.Operation{
+	Summary:  "Cost of each analysis, oldest first",
+	Query:    []api.Param{{Name: "since", Description: "a duration like 168h or an RFC 3339 time; the whole history when unset"}},
+	Response: []Point{},
+}
+
+// TrendsOperation documents GET /api/v1/trends
+var TrendsOperation = api.Operation{
+	Summary: "Cost averaged per hour, day or week, and its change",
+	Query: []api.Param{
+		{Name: "since", Description: "a duration like 720h or an RFC 3339 time; the whole history when unset"},
+		{Name: "bucket", Description: "hour, day (default) or week"},
+	},
+	Response: Trends{},
+}
+
+// ServeHistory lists the points (GET /api/v1/history)
+func (h *History) ServeHistory(w http.ResponseWriter, r *http.Request) {
+	points, ok := h.query(w, r)
+	if ok {
+		writeJSON(w, points)
+	}
+}
+
+// ServeTrends averages the points into buckets (GET /api/v1/trends)
+func (h *History) ServeTrends(w http.ResponseWriter, r *http.Request) {
+	points, ok := h.query(w, r)
+	if !ok {
+		return
+	}
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	trends, err := Trend(points, bucket)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, trends)
+}
+
+// query returns the points since the since parameter, or writes the error
+func (h *History) query(w http.ResponseWriter, r *http.Request) ([]Point, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+			since = t
+		} else {
+			http.Error(w, fmt.Sprintf("since %q is neither a duration nor an RFC 3339 time", s), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	points := h.Points(since)
+	if points == nil {
+		points = []Point{}
+	}
+	return points, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,156 @@
+package costhistory
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	writer, reader, err := Dir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New("cost-optimizer", writer, reader, 7*24*time.Hour)
+	h.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for _, p := range []Point{
+		{Time: now.Add(-8 * 24 * time.Hour), MonthlyCost: 900},            // past the retention
+		{Time: now.Add(-48*time.Hour - 30*time.Minute), MonthlyCost: 510}, // thinned: same hour as the next
+		{Time: now.Add(-48*time.Hour - 10*time.Minute), MonthlyCost: 500},
+		{Time: now.Add(-time.Hour - 20*time.Minute), MonthlyCost: 420}, // recent: kept as recorded
+		{Time: now.Add(-time.Hour - 10*time.Minute), MonthlyCost: 410},
+	} {
+		if err := h.Record(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := h.Points(time.Time{})
+	want := []float64{500, 420, 410}
+	if len(got) != len(want) {
+		t.Fatalf("points = %+v", got)
+	}
+	for i, p := range got {
+		if p.MonthlyCost != want[i] {
+			t.Errorf("point %d costs %.0f, want %.0f", i, p.MonthlyCost, want[i])
+		}
+	}
+	if since := h.Points(now.Add(-2 * time.Hour)); len(since) != 2 {
+		t.Errorf("points of the last 2h = %+v", since)
+	}
+
+	// A restarted app gets its history back; other apps' are not read
+	restarted := New("cost-optimizer", writer, reader, 7*24*time.Hour)
+	restarted.now = h.now
+	other := New("cost-impact-monitor", writer, reader, 0)
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.Points(time.Time{})) != 3 || len(other.Points(time.Time{})) != 0 {
+		t.Errorf("restored %d and %d points", len(restarted.Points(time.Time{})), len(other.Points(time.Time{})))
+	}
+}
+
+func TestTrend(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) // a Monday, when weeks start
+	points := []Point{
+		{Time: day.Add(1 * time.Hour), MonthlyCost: 1000},
+		{Time: day.Add(13 * time.Hour), MonthlyCost: 980},
+		{Time: day.Add(25 * time.Hour), MonthlyCost: 900, Applied: 2},
+		{Time: day.Add(49 * time.Hour), MonthlyCost: 800, Applied: 3},
+	}
+	tests := []struct {
+		bucket        string
+		wantBuckets   int
+		wantDirection string
+	}{
+		{"day", 3, "down"},
+		{"week", 1, "flat"},
+		{"hour", 4, "down"},
+	}
+	for _, tt := range tests {
+		trends, err := Trend(points, tt.bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(trends.Buckets) != tt.wantBuckets || trends.Direction != tt.wantDirection {
+			t.Errorf("%s: %d buckets going %s, want %d going %s", tt.bucket, len(trends.Buckets), trends.Direction, tt.wantBuckets, tt.wantDirection)
+		}
+	}
+
+	trends, _ := Trend(points, "day")
+	if trends.Buckets[0].MonthlyCost != 990 || trends.Buckets[2].Applied != 3 || trends.Change != -190 {
+		t.Errorf("trends = %+v", trends)
+	}
+	if trends.SlopePerDay >= 0 || math.Abs(trends.ChangePercent+19.19) > 0.01 {
+		t.Errorf("slope %.2f/day, change %.2f%%", trends.SlopePerDay, trends.ChangePercent)
+	}
+	if _, err := Trend(points, "month"); err == nil {
+		t.Error("Expected an unknown bucket to fail")
+	}
+}
+
+func TestServe(t *testing.T) {
+	h := New("cost-optimizer", nil, nil, 0)
+	now := time.Now()
+	h.Record(context.Background(), Point{Time: now.Add(-3 * time.Hour), MonthlyCost: 100})
+	h.Record(context.Background(), Point{Time: now.Add(-time.Hour), MonthlyCost: 90})
+
+	tests := []struct {
+		name, url  string
+		wantStatus int
+		wantPoints int
+	}{
+		{"history", "/api/v1/history", 200, 2},
+		{"since", "/api/v1/history?since=2h", 200, 1},
+		{"bad since", "/api/v1/history?since=yesterday", 400, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHistory(rec, httptest.NewRequest("GET", tt.url, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var points []Point
+			if tt.wantStatus == 200 {
+				if err := json.Unmarshal(rec.Body.Bytes(), &points); err != nil || len(points) != tt.wantPoints {
+					t.Errorf("points = %s, err %v", rec.Body, err)
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeTrends(rec, httptest.NewRequest("GET", "/api/v1/trends?bucket=hour", nil))
+	var trends Trends
+	if err := json.Unmarshal(rec.Body.Bytes(), &trends); err != nil || len(trends.Buckets) != 2 || trends.Direction != "down" {
+		t.Errorf("trends = %s, err %v", rec.Body, err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeTrends(rec, httptest.NewRequest("GET", "/api/v1/trends?bucket=year", nil))
+	if rec.Code != 400 {
+		t.Errorf("unknown bucket: status %d", rec.Code)
+	}
+}
+
+func TestNilHistory(t *testing.T) {
+	var h *History
+	if err := h.Record(context.Background(), Point{MonthlyCost: 1}); err != nil {
+		t.Error(err)
+	}
+	if err := h.Load(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if h.Points(time.Time{}) != nil {
+		t.Error("Expected no points")
+	}
+}
@@ -0,0 +1,185 @@
+// Package costmodel prices Kubernetes workloads for the DevOps apps. The
+// rates live here only, so every app and dashboard shows the same cost for
+// the same workload.
+//
+// Rates are on-demand list prices of a provider's managed Kubernetes,
+// spread per vCPU and per GB from a general-purpose node: AWS EKS on
+// m5.large, GCP GKE on e2-standard-2, Azure AKS on D2s v3, in each
+// provider's main regions. A month is 720 hours. Network traffic is not
+// measured; breakdowns estimate it as a share of compute.
+package costmodel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HoursPerMonth is the billing month hourly rates are charged for
+const HoursPerMonth = 24 * 30
+
+// Providers priced
+const (
+	AWS   = "aws"
+	GCP   = "gcp"
+	Azure = "azure"
+)
+
+// NetworkShare is network spend estimated as a share of compute, where no
+// traffic is measured
+const NetworkShare = 0.05
+
+// Pricing is what one provider charges in one region
+type Pricing struct {
+	Provider string `json:"provider"`
+	Service  string `json:"service"` // the managed Kubernetes priced, e.g. AWS EKS
+	Region   string `json:"region"`
+
+	CPUHourly      float64 `json:"cpu_hourly"`      // per vCPU
+	MemoryHourly   float64 `json:"memory_hourly"`   // per GB
+	StorageMonthly float64 `json:"storage_monthly"` // per GB of persistent volume
+	GPUHourly      float64 `json:"gpu_hourly"`      // per GPU
+	EgressGB       float64 `json:"egress_gb"`       // per GB transferred out
+	// Managed control plane, per cluster; free on GKE zonal and AKS free tier
+	ControlPlaneHourly float64 `json:"control_plane_hourly"`
+}
+
+// Default is AWS EKS in us-east-1, used where no provider is configured
+var Default = prices[AWS][0]
+
+// prices are each provider's regions, its reference region first. GPU
+// rates are an NVIDIA T4's share of a GPU instance.
+var prices = map[string][]Pricing{
+	AWS: {
+		{Provider: AWS, Service: "AWS EKS", Region: "us-east-1", CPUHourly: 0.024, MemoryHourly: 0.006, StorageMonthly: 0.10, GPUHourly: 0.35, EgressGB: 0.09, ControlPlaneHourly: 0.10},
+		{Provider: AWS, Service: "AWS EKS", Region: "us-west-2", CPUHourly: 0.024, MemoryHourly: 0.006, StorageMonthly: 0.10, GPUHourly: 0.35, EgressGB: 0.09, ControlPlaneHourly: 0.10},
+		{Provider: AWS, Service: "AWS EKS", Region: "eu-west-1", CPUHourly: 0.026, MemoryHourly: 0.0065, StorageMonthly: 0.11, GPUHourly: 0.38, EgressGB: 0.09, ControlPlaneHourly: 0.10},
+	},
+	GCP: {
+		{Provider: GCP, Service: "GCP GKE", Region: "us-central1", CPUHourly: 0.021, MemoryHourly: 0.0055, StorageMonthly: 0.04, GPUHourly: 0.35, EgressGB: 0.12},
+		{Provider: GCP, Service: "GCP GKE", Region: "us-east1", CPUHourly: 0.021, MemoryHourly: 0.0055, StorageMonthly: 0.04, GPUHourly: 0.35, EgressGB: 0.12},
+		{Provider: GCP, Service: "GCP GKE", Region: "us-west1", CPUHourly: 0.021, MemoryHourly: 0.0055, StorageMonthly: 0.04, GPUHourly: 0.35, EgressGB: 0.12},
+		{Provider: GCP, Service: "GCP GKE", Region: "europe-west1", CPUHourly: 0.023, MemoryHourly: 0.006, StorageMonthly: 0.04, GPUHourly: 0.35, EgressGB: 0.12},
+		{Provider: GCP, Service: "GCP GKE", Region: "europe-west4", CPUHourly: 0.023, MemoryHourly: 0.006, StorageMonthly: 0.044, GPUHourly: 0.35, EgressGB: 0.12},
+		{Provider: GCP, Service: "GCP GKE", Region: "asia-southeast1", CPUHourly: 0.026, MemoryHourly: 0.0068, StorageMonthly: 0.048, GPUHourly: 0.37, EgressGB: 0.12},
+	},
+	Azure: {
+		{Provider: Azure, Service: "Azure AKS", Region: "eastus", CPUHourly: 0.025, MemoryHourly: 0.006, StorageMonthly: 0.05, GPUHourly: 0.53, EgressGB: 0.087},
+		{Provider: Azure, Service: "Azure AKS", Region: "eastus2", CPUHourly: 0.025, MemoryHourly: 0.006, StorageMonthly: 0.05, GPUHourly: 0.53, EgressGB: 0.087},
+		{Provider: Azure, Service: "Azure AKS", Region: "westus2", CPUHourly: 0.025, MemoryHourly: 0.006, StorageMonthly: 0.05, GPUHourly: 0.53, EgressGB: 0.087},
+		{Provider: Azure, Service: "Azure AKS", Region: "westeurope", CPUHourly: 0.030, MemoryHourly: 0.0072, StorageMonthly: 0.06, GPUHourly: 0.62, EgressGB: 0.087},
+		{Provider: Azure, Service: "Azure AKS", Region: "northeurope", CPUHourly: 0.028, MemoryHourly: 0.0067, StorageMonthly: 0.056, GPUHourly: 0.58, EgressGB: 0.087},
+		{Provider: Azure, Service: "Azure AKS", Region: "southeastasia", CPUHourly: 0.031, MemoryHourly: 0.0075, StorageMonthly: 0.06, GPUHourly: 0.64, EgressGB: 0.12},
+	},
+}
+
+// clusterTypes are each provider's managed Kubernetes, as the apps report a
+// cluster's type
+var clusterTypes = map[string]string{AWS: "eks", GCP: "gke", Azure: "aks"}
+
+// ClusterType returns the managed Kubernetes of provider: eks, gke or aks
+func ClusterType(provider string) string {
+	return clusterTypes[strings.ToLower(provider)]
+}
+
+// ForCluster returns the provider running a cluster of type eks, gke or aks,
+// or "" for kind and other clusters no provider prices
+func ForCluster(clusterType string) string {
+	for provider, t := range clusterTypes {
+		if strings.EqualFold(t, clusterType) {
+			return provider
+		}
+	}
+	return ""
+}
+
+// ForProviderID returns the provider of a node from its spec.providerID,
+// e.g. aws:///us-east-1a/i-0abc or gce://project/us-central1-a/node, or ""
+// when it names none
+func ForProviderID(id string) string {
+	scheme, _, found := strings.Cut(id, "://")
+	if !found {
+		return ""
+	}
+	switch strings.ToLower(scheme) {
+	case "aws":
+		return AWS
+	case "gce":
+		return GCP
+	case "azure":
+		return Azure
+	}
+	return ""
+}
+
+// Providers lists the providers priced
+func Providers() []string {
+	names := make([]string, 0, len(prices))
+	for name := range prices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns a provider's pricing in region. An empty or unpriced
+// region gets the provider's reference region; the returned Region says
+// which was used.
+func Lookup(provider, region string) (Pricing, error) {
+	regions, ok := prices[strings.ToLower(provider)]
+	if !ok {
+		return Pricing{}, fmt.Errorf("unknown cloud provider %q (one of %s)", provider, strings.Join(Providers(), ", "))
+	}
+	for _, p := range regions {
+		if p.Region == region {
+			return p, nil
+		}
+	}
+	return regions[0], nil
+}
+
+// OrDefault is p, or Default when p was never set
+func (p Pricing) OrDefault() Pricing {
+	if p.Provider == "" {
+		return Default
+	}
+	return p
+}
+
+// Resources are what a workload requests: per replica, times replicas
+type Resources struct {
+	CPUCores  float64
+	MemoryGB  float64
+	StorageGB float64
+	GPUs      int
+	Replicas  int
+}
+
+// Breakdown is a workload's monthly cost by resource
+type Breakdown struct {
+	CPU     float64 `json:"cpu"`
+	Memory  float64 `json:"memory"`
+	Storage float64 `json:"storage"`
+	GPU     float64 `json:"gpu"`
+}
+
+// Total is the sum of the breakdown
+func (b Breakdown) Total() float64 {
+	return b.CPU + b.Memory + b.Storage + b.GPU
+}
+
+// Breakdown prices r for a month
+func (p Pricing) Breakdown(r Resources) Breakdown {
+	n := float64(r.Replicas)
+	return Breakdown{
+		CPU:     r.CPUCores * p.CPUHourly * HoursPerMonth * n,
+		Memory:  r.MemoryGB * p.MemoryHourly * HoursPerMonth * n,
+		Storage: r.StorageGB * p.StorageMonthly * n,
+		GPU:     float64(r.GPUs) * p.GPUHourly * HoursPerMonth * n,
+	}
+}
+
+// MonthlyCost prices r for a month
+func (p Pricing) MonthlyCost(r Resources) float64 {
+	return p.Breakdown(r).Total()
+}
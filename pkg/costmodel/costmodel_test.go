@@ -0,0 +1,83 @@
+package costmodel
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		provider, region, wantRegion string
+	}{
+		{"aws", "eu-west-1", "eu-west-1"},
+		{"AWS", "", "us-east-1"},
+		{"aws", "ap-south-1", "us-east-1"}, // not priced: the reference region
+		{"gcp", "", "us-central1"},
+		{"azure", "eastus", "eastus"},
+		{"gcp", "europe-west1", "europe-west1"},
+		{"azure", "westeurope", "westeurope"},
+	}
+	for _, tt := range tests {
+		p, err := Lookup(tt.provider, tt.region)
+		if err != nil {
+			t.Fatalf("%s/%s: %v", tt.provider, tt.region, err)
+		}
+		if p.Region != tt.wantRegion || p.CPUHourly <= 0 || p.MemoryHourly <= 0 {
+			t.Errorf("%s/%s: got %+v, want region %s", tt.provider, tt.region, p, tt.wantRegion)
+		}
+	}
+	if _, err := Lookup("oracle", ""); err == nil || err.Error() != `unknown cloud provider "oracle" (one of aws, azure, gcp)` {
+		t.Errorf("unknown provider: %v", err)
+	}
+	if Default.Provider != AWS || Default.Region != "us-east-1" {
+		t.Errorf("default pricing is %s/%s", Default.Provider, Default.Region)
+	}
+}
+
+func TestForCluster(t *testing.T) {
+	tests := []struct {
+		clusterType, providerID, want string
+	}{
+		{"eks", "aws:///us-east-1a/i-0abc", AWS},
+		{"gke", "gce://shop/us-central1-a/gke-pool-1", GCP},
+		{"AKS", "azure:///subscriptions/1/resourceGroups/mc/providers/Microsoft.Compute/virtualMachineScaleSets/aks/virtualMachines/0", Azure},
+		{"kind", "kind://docker/kind/kind-control-plane", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := ForCluster(tt.clusterType); got != tt.want {
+			t.Errorf("ForCluster(%q) = %q, want %q", tt.clusterType, got, tt.want)
+		}
+		if got := ForProviderID(tt.providerID); got != tt.want {
+			t.Errorf("ForProviderID(%q) = %q, want %q", tt.providerID, got, tt.want)
+		}
+		if tt.want != "" && !strings.EqualFold(ClusterType(tt.want), tt.clusterType) {
+			t.Errorf("ClusterType(%q) = %q, want %q", tt.want, ClusterType(tt.want), tt.clusterType)
+		}
+	}
+}
+
+func TestMonthlyCost(t *testing.T) {
+	// 2 replicas of 500m, 1Gi, 10Gi and a GPU at us-east-1 rates
+	b := Default.Breakdown(Resources{CPUCores: 0.5, MemoryGB: 1, StorageGB: 10, GPUs: 1, Replicas: 2})
+	want := Breakdown{CPU: 2 * 8.64, Memory: 2 * 4.32, Storage: 2 * 1, GPU: 2 * 252}
+	for name, pair := range map[string][2]float64{
+		"cpu": {b.CPU, want.CPU}, "memory": {b.Memory, want.Memory}, "storage": {b.Storage, want.Storage}, "gpu": {b.GPU, want.GPU},
+	} {
+		if math.Abs(pair[0]-pair[1]) > 0.001 {
+			t.Errorf("%s = %.2f, want %.2f", name, pair[0], pair[1])
+		}
+	}
+	if got := Default.MonthlyCost(Resources{CPUCores: 0.5, MemoryGB: 1, StorageGB: 10, GPUs: 1, Replicas: 2}); math.Abs(got-want.Total()) > 0.001 {
+		t.Errorf("monthly cost %.2f, want %.2f", got, want.Total())
+	}
+	if got := Default.MonthlyCost(Resources{CPUCores: 1}); got != 0 {
+		t.Errorf("no replicas should cost nothing, got %.2f", got)
+	}
+
+	gcp, _ := Lookup(GCP, "")
+	if gcp.MonthlyCost(Resources{CPUCores: 2, MemoryGB: 8, Replicas: 1}) >= Default.MonthlyCost(Resources{CPUCores: 2, MemoryGB: 8, Replicas: 1}) {
+		t.Error("a GKE node should be priced below an EKS one")
+	}
+}
@@ -0,0 +1,304 @@
+// Package driftstream streams detected drift from the drift-detector to the
+// cost-impact-monitor over gRPC, so drift such as replicas bumped from 2 to 5
+// shows up as a pending cost impact as soon as it is detected, instead of
+// each app polling and disagreeing about the cluster's state.
+//
+// Every message is a Snapshot of one space: all the drift currently detected
+// in it, or none once it has been fixed. A subscriber first gets the latest
+// snapshot of each space it may see, then every new one:
+//
+//	service DriftStream {
+//	  rpc Subscribe(SubscribeRequest) returns (stream Snapshot);
+//	}
+//
+// Messages are encoded as JSON (content subtype "json") rather than
+// protobuf, so neither side needs generated code. With teams, subscribers
+// send a team's API token as "authorization: Bearer <token>" metadata and
+// get the snapshots of that team's spaces.
+package driftstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/tenants"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the gRPC service of the stream
+const ServiceName = "driftstream.v1.DriftStream"
+
+// Item is one drifted field
+type Item struct {
+	UnitID   string `json:"unit_id"`
+	UnitSlug string `json:"unit_slug"`
+	Resource string `json:"resource"` // e.g. Deployment/backend-api
+	Field    string `json:"field"`    // e.g. spec.replicas
+	Expected string `json:"expected"` // value in ConfigHub
+	Actual   string `json:"actual"`   // value in the cluster
+}
+
+// Snapshot is the drift detected in a space by one detection run
+type Snapshot struct {
+	Space      string    `json:"space"`
+	Team       string    `json:"team,omitempty"`
+	Namespace  string    `json:"namespace"`
+	DetectedAt time.Time `json:"detected_at"`
+	Items      []Item    `json:"items"` // empty once the space has no drift
+	// CorrelationID identifies the drift from when it was first detected
+	// until it is gone; the snapshot that clears it carries it too
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// SubscribeRequest selects the spaces to stream; none selects every space
+// the subscriber may see
+type SubscribeRequest struct {
+	Spaces []string `json:"spaces,omitempty"`
+}
+
+// codec encodes messages as JSON
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (codec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (codec) Name() string                               { return "json" }
+
+// streamer is the handler type of the service
+type streamer interface {
+	subscribe(req *SubscribeRequest, stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*streamer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Subscribe",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			var req SubscribeRequest
+			if err := stream.RecvMsg(&req); err != nil {
+				return err
+			}
+			return srv.(streamer).subscribe(&req, stream)
+		},
+	}},
+}
+
+// Hub publishes the drift-detector's snapshots to subscribers. It is safe
+// for concurrent use; a nil Hub publishes nothing.
+type Hub struct {
+	teams *tenants.Registry
+
+	mu          sync.Mutex
+	latest      map[string]Snapshot // by space
+	subscribers map[*subscriber]bool
+}
+
+// subscriber holds the snapshots not yet sent to one stream, the latest per
+// space, so a slow subscriber skips stale snapshots but never misses the
+// current one
+type subscriber struct {
+	team    *tenants.Team
+	spaces  map[string]bool // empty for all
+	pending map[string]Snapshot
+	wake    chan struct{}
+}
+
+func (s *subscriber) wants(snapshot Snapshot) bool {
+	return s.team.OwnsSpace(snapshot.Space) && (len(s.spaces) == 0 || s.spaces[snapshot.Space])
+}
+
+// NewHub returns a hub; with teams, subscribers need a team's API token
+func NewHub(teams *tenants.Registry) *Hub {
+	return &Hub{teams: teams, latest: map[string]Snapshot{}, subscribers: map[*subscriber]bool{}}
+}
+
+// Publish sends the drift of a space to its subscribers, replacing the
+// space's previous snapshot
+func (h *Hub) Publish(snapshot Snapshot) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latest[snapshot.Space] = snapshot
+	for s := range h.subscribers {
+		if s.wants(snapshot) {
+			s.pending[snapshot.Space] = snapshot
+			notify(s.wake)
+		}
+	}
+}
+
+func notify(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Serve serves the stream on addr until ctx is done or the listener fails.
+// Subscriptions never end on their own, so stopping closes them rather than
+// waiting; subscribers reconnect to another replica.
+func (h *Hub) Serve(ctx context.Context, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	h.Register(server)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.Stop()
+		case <-stopped:
+		}
+	}()
+	if err := server.Serve(lis); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// Register adds the stream to a gRPC server whose codec encodes JSON
+func (h *Hub) Register(server *grpc.Server) {
+	server.RegisterService(&serviceDesc, h)
+}
+
+func (h *Hub) subscribe(req *SubscribeRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	var team *tenants.Team
+	if h.teams != nil {
+		if team = h.teams.AuthenticateToken("", bearer(ctx)); team == nil {
+			return status.Error(codes.Unauthenticated, "a team's API token is required")
+		}
+	}
+
+	s := &subscriber{team: team, spaces: map[string]bool{}, pending: map[string]Snapshot{}, wake: make(chan struct{}, 1)}
+	for _, space := range req.Spaces {
+		s.spaces[space] = true
+	}
+	h.mu.Lock()
+	for space, snapshot := range h.latest {
+		if s.wants(snapshot) {
+			s.pending[space] = snapshot
+		}
+	}
+	h.subscribers[s] = true
+	h.mu.Unlock()
+	notify(s.wake)
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.subscribers, s)
+		h.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.wake:
+		}
+		h.mu.Lock()
+		pending := s.pending
+		s.pending = map[string]Snapshot{}
+		h.mu.Unlock()
+
+		spaces := make([]string, 0, len(pending))
+		for space := range pending {
+			spaces = append(spaces, space)
+		}
+		sort.Strings(spaces)
+		for _, space := range spaces {
+			if err := stream.SendMsg(pending[space]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// bearer returns the bearer token of a call's metadata
+func bearer(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// Subscribe streams snapshots from the drift-detector at addr to handle
+// until ctx ends or the stream breaks. token is a team's API token, needed
+// when the detector serves teams.
+func Subscribe(ctx context.Context, addr, token string, req SubscribeRequest, handle func(Snapshot)) error {
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/Subscribe", grpc.ForceCodec(codec{}))
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	if err := stream.SendMsg(&req); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	for {
+		var snapshot Snapshot
+		if err := stream.RecvMsg(&snapshot); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("receive drift: %w", err)
+		}
+		handle(snapshot)
+	}
+}
+
+// Follow subscribes again whenever the stream breaks, waiting from a second
+// up to a minute between attempts, until ctx ends
+func Follow(ctx context.Context, addr, token string, req SubscribeRequest, handle func(Snapshot)) {
+	wait := time.Second
+	for {
+		start := time.Now()
+		err := Subscribe(ctx, addr, token, req, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			wait = time.Second // the stream was up, retry quickly
+		}
+		slog.Warn("Drift stream broke, resubscribing", "addr", addr, "retry_in", wait, logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > time.Minute {
+			wait = time.Minute
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package driftstream
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/tenants"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func serve(t *testing.T, hub *Hub) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	hub.Register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+// receive subscribes and returns a channel of the snapshots received
+func receive(t *testing.T, addr, token string, req SubscribeRequest) (<-chan Snapshot, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	snapshots, errs := make(chan Snapshot, 10), make(chan error, 1)
+	go func() {
+		errs <- Subscribe(ctx, addr, token, req, func(s Snapshot) { snapshots <- s })
+	}()
+	return snapshots, errs
+}
+
+func next(t *testing.T, snapshots <-chan Snapshot) Snapshot {
+	t.Helper()
+	select {
+	case s := <-snapshots:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a snapshot")
+		return Snapshot{}
+	}
+}
+
+func TestStream(t *testing.T) {
+	hub := NewHub(nil)
+	addr := serve(t, hub)
+
+	replicas := Item{UnitSlug: "backend-api", Resource: "Deployment/backend-api", Field: "spec.replicas", Expected: "2", Actual: "5"}
+	hub.Publish(Snapshot{Space: "acme-prod", Items: []Item{replicas}, CorrelationID: "incident-1"})
+
+	// The latest snapshot is replayed, then new ones follow
+	snapshots, _ := receive(t, addr, "", SubscribeRequest{})
+	if s := next(t, snapshots); s.Space != "acme-prod" || len(s.Items) != 1 || s.Items[0] != replicas || s.CorrelationID != "incident-1" {
+		t.Fatalf("Unexpected replayed snapshot %+v", s)
+	}
+	hub.Publish(Snapshot{Space: "acme-prod"})
+	if s := next(t, snapshots); len(s.Items) != 0 {
+		t.Errorf("Expected the fixed space to have no drift, got %+v", s.Items)
+	}
+
+	// A subscriber only gets the spaces it asked for
+	filtered, _ := receive(t, addr, "", SubscribeRequest{Spaces: []string{"acme-dev"}})
+	hub.Publish(Snapshot{Space: "acme-staging", Items: []Item{replicas}})
+	hub.Publish(Snapshot{Space: "acme-dev", Items: []Item{replicas}})
+	if s := next(t, filtered); s.Space != "acme-dev" {
+		t.Errorf("Expected only acme-dev, got %s", s.Space)
+	}
+
+	var nilHub *Hub
+	nilHub.Publish(Snapshot{Space: "ignored"})
+}
+
+func TestStreamTeams(t *testing.T) {
+	teams, err := tenants.Config{Teams: []*tenants.Team{
+		{Name: "payments", Spaces: []string{"payments-*"}, CubToken: "payments-cub", APIToken: "payments-token"},
+		{Name: "platform", Spaces: []string{"*"}, CubToken: "platform-cub", APIToken: "platform-token"},
+	}}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub(teams)
+	addr := serve(t, hub)
+	hub.Publish(Snapshot{Space: "search-prod", Team: "search"})
+	hub.Publish(Snapshot{Space: "payments-prod", Team: "payments"})
+
+	payments, _ := receive(t, addr, "payments-token", SubscribeRequest{})
+	if s := next(t, payments); s.Space != "payments-prod" {
+		t.Errorf("Expected payments to see only its space, got %s", s.Space)
+	}
+	select {
+	case s := <-payments:
+		t.Errorf("Unexpected snapshot for payments: %+v", s)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	platform, _ := receive(t, addr, "platform-token", SubscribeRequest{})
+	if a, b := next(t, platform), next(t, platform); a.Space != "payments-prod" || b.Space != "search-prod" {
+		t.Errorf("Expected platform to see both spaces in order, got %s and %s", a.Space, b.Space)
+	}
+
+	_, errs := receive(t, addr, "wrong", SubscribeRequest{})
+	select {
+	case err := <-errs:
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected Unauthenticated, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a bad token to be refused")
+	}
+}
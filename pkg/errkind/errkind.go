@@ -0,0 +1,187 @@
+// Package errkind sorts the errors of ConfigHub, Kubernetes and AI provider
+// calls into three kinds, so every app retries, alerts and reports health
+// the same way:
+//
+//	Retryable  rate limits, timeouts, dropped connections, 5xx: try again later
+//	Permanent  a bad manifest, a missing unit, any other 4xx: the same call fails again
+//	Auth       401 and 403: the token or its permissions need fixing
+//
+// Kinds come from the error chain: *HTTPError and Kubernetes API statuses by
+// their code, network errors as Retryable, JSON and YAML decoding errors as
+// Permanent, and any error wrapped by Mark as marked. An error that shows
+// none of these is Retryable, so an unrecognized failure is retried and
+// counted against the service rather than ignored.
+//
+//	if errkind.Of(err) == errkind.Auth {
+//		// page someone; retrying won't help
+//	}
+package errkind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Kind is how a failed call should be handled
+type Kind string
+
+const (
+	Retryable Kind = "retryable"
+	Permanent Kind = "permanent"
+	Auth      Kind = "auth"
+)
+
+// HTTPError is a non-2xx answer from an HTTP API
+type HTTPError struct {
+	Service    string        // confighub, claude, opencost, ...
+	StatusCode int           // 429, 503, ...
+	Message    string        // the API's error message, if it sent one
+	RetryAfter time.Duration // from the Retry-After header, 0 without one
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("%s: %d %s", e.Service, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// FromResponse returns the HTTPError of a response whose status is not 2xx,
+// reading Retry-After from its headers
+func FromResponse(service string, resp *http.Response, message string) *HTTPError {
+	e := &HTTPError{Service: service, StatusCode: resp.StatusCode, Message: message}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
+
+type marked struct {
+	kind Kind
+	err  error
+}
+
+func (m *marked) Error() string { return m.err.Error() }
+func (m *marked) Unwrap() error { return m.err }
+
+// Mark makes Of report kind for err and errors wrapping it, such as a
+// manifest the app's own validation rejected
+func Mark(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &marked{kind: kind, err: err}
+}
+
+// Status texts of errors that carry a code only in their message, such as
+// the ConfigHub SDK's
+var statusText = regexp.MustCompile(`(?i)\b(?:status(?: code)?:?|HTTP)\s*([1-5]\d\d)\b`)
+
+// Of returns the kind of err, "" for nil
+func Of(err error) Kind {
+	if err == nil {
+		return ""
+	}
+	var m *marked
+	if errors.As(err, &m) {
+		return m.kind
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return ofStatus(httpErr.StatusCode)
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		return ofStatus(int(status.Status().Code))
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Permanent // the caller gave up; trying again won't help it
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET):
+		return Retryable
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Retryable
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || isYAMLError(err) {
+		return Permanent
+	}
+	if m := statusText.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return ofStatus(code)
+	}
+	return Retryable
+}
+
+func ofStatus(code int) Kind {
+	switch {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return Auth
+	case code == http.StatusRequestTimeout, code == http.StatusConflict,
+		code == http.StatusTooEarly, code == http.StatusTooManyRequests, code >= 500:
+		return Retryable
+	case code >= 400:
+		return Permanent
+	}
+	return Retryable
+}
+
+// isYAMLError matches yaml.v3's type errors and its parse errors, which
+// are plain errors prefixed "yaml: "
+func isYAMLError(err error) bool {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		return true
+	}
+	for next := errors.Unwrap(err); next != nil; next = errors.Unwrap(err) {
+		err = next
+	}
+	return strings.HasPrefix(err.Error(), "yaml: ")
+}
+
+// IsRetryable reports whether the same call may succeed later
+func IsRetryable(err error) bool { return Of(err) == Retryable }
+
+// IsPermanent reports whether the same call will fail again
+func IsPermanent(err error) bool { return Of(err) == Permanent }
+
+// IsAuth reports whether the call's credentials were rejected
+func IsAuth(err error) bool { return Of(err) == Auth }
+
+// Delay is how long to wait before retry number attempt (from 1) of a call
+// that failed with err: the server's Retry-After if it sent one, else 200ms
+// doubling up to 5s
+func Delay(err error, attempt int) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return httpErr.RetryAfter
+	}
+	d := 200 * time.Millisecond
+	for i := 1; i < attempt && d < 5*time.Second; i++ {
+		d *= 2
+	}
+	if d > 5*time.Second {
+		d = 5 * time.Second
+	}
+	return d
+}
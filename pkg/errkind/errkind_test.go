@@ -0,0 +1,75 @@
+package errkind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestOf(t *testing.T) {
+	var manifest map[string]string
+	jsonErr := json.Unmarshal([]byte("{"), &manifest)
+	yamlErr := yaml.Unmarshal([]byte("a: [1"), &manifest)
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"nil", nil, ""},
+		{"429", &HTTPError{Service: "claude", StatusCode: 429}, Retryable},
+		{"503 wrapped", fmt.Errorf("list units: %w", &HTTPError{Service: "confighub", StatusCode: 503}), Retryable},
+		{"404", &HTTPError{Service: "confighub", StatusCode: 404}, Permanent},
+		{"401", &HTTPError{Service: "confighub", StatusCode: 401}, Auth},
+		{"k8s not found", apierrors.NewNotFound(deployments, "web"), Permanent},
+		{"k8s forbidden", apierrors.NewForbidden(deployments, "web", errors.New("rbac")), Auth},
+		{"k8s conflict", apierrors.NewConflict(deployments, "web", errors.New("stale")), Retryable},
+		{"k8s throttled", apierrors.NewTooManyRequests("slow down", 1), Retryable},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), Retryable},
+		{"deadline", context.DeadlineExceeded, Retryable},
+		{"cancelled", fmt.Errorf("detect: %w", context.Canceled), Permanent},
+		{"bad json", fmt.Errorf("decode: %w", jsonErr), Permanent},
+		{"bad yaml", fmt.Errorf("parse manifest: %w", yamlErr), Permanent},
+		{"status in text", errors.New("create unit: status 422: invalid"), Permanent},
+		{"marked", fmt.Errorf("apply: %w", Mark(Permanent, errors.New("missing image"))), Permanent},
+		{"unknown", errors.New("something odd"), Retryable},
+	} {
+		if got := Of(tc.err); got != tc.want {
+			t.Errorf("%s: Of(%v) = %q, want %q", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestFromResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Retry-After", "7")
+	rec.WriteHeader(http.StatusTooManyRequests)
+	err := FromResponse("claude", rec.Result(), "rate limited")
+
+	if err.Error() != "claude: 429 Too Many Requests: rate limited" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if d := Delay(err, 1); d != 7*time.Second {
+		t.Errorf("Delay = %s, want Retry-After's 7s", d)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	err := errors.New("reset")
+	for attempt, want := range map[int]time.Duration{1: 200 * time.Millisecond, 2: 400 * time.Millisecond, 10: 5 * time.Second} {
+		if got := Delay(err, attempt); got != want {
+			t.Errorf("Delay(attempt %d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
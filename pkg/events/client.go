@@ -0,0 +1,286 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// The client below speaks the text protocol of core NATS
+// (https://docs.nats.io/reference/reference-protocols/nats-protocol):
+// CONNECT, PUB and SUB from the client; INFO, MSG, PING, PONG, +OK and -ERR
+// from the server. That is all publishing and subscribing need.
+
+const (
+	defaultPort    = "4222"
+	dialTimeout    = 5 * time.Second
+	writeTimeout   = 2 * time.Second
+	maxReconnectIn = 30 * time.Second
+)
+
+var errNotConnected = errors.New("not connected to NATS")
+
+// connectOptions is the CONNECT message
+type connectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+type subscription struct {
+	subject string
+	handle  func(subject string, payload []byte)
+}
+
+// client keeps one connection to a NATS server, redialing and subscribing
+// again whenever it drops
+type client struct {
+	addr    string
+	options connectOptions
+
+	mu      sync.Mutex
+	conn    net.Conn // nil while disconnected
+	w       *bufio.Writer
+	subs    map[int]*subscription // by sid
+	lastSID int
+	closed  bool
+	done    chan struct{}
+}
+
+func newClient(rawURL, token, name string) (*client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "nats" && u.Scheme != "tcp") {
+		return nil, fmt.Errorf("NATS URL %q is not nats://host[:port]", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	options := connectOptions{Name: name, Lang: "go", Version: "1.0.0", Protocol: 1, AuthToken: token}
+	if u.User != nil {
+		options.User = u.User.Username()
+		options.Pass, _ = u.User.Password()
+	}
+	return &client{addr: addr, options: options, subs: map[int]*subscription{}, done: make(chan struct{})}, nil
+}
+
+// run connects and reads messages until close, reconnecting with backoff
+func (c *client) run() {
+	wait := time.Second
+	for {
+		conn, r, err := c.dial()
+		if err == nil {
+			slog.Info("Connected to NATS", "addr", c.addr)
+			wait = time.Second
+			err = c.read(conn, r)
+		}
+		if c.isClosed() {
+			return
+		}
+		slog.Warn("NATS connection lost, reconnecting", "addr", c.addr, "retry_in", wait, logging.Err(err))
+		select {
+		case <-c.done:
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maxReconnectIn {
+			wait = maxReconnectIn
+		}
+	}
+}
+
+// dial connects, authenticates and subscribes again to every subject
+func (c *client) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", c.addr, dialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	fail := func(err error) (net.Conn, *bufio.Reader, error) {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	line, err := readLine(r)
+	if err != nil {
+		return fail(err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fail(fmt.Errorf("expected INFO from NATS, got %q", line))
+	}
+	options, _ := json.Marshal(c.options)
+	fmt.Fprintf(w, "CONNECT %s\r\n", options)
+
+	// Hold the lock until connected so no subscription is missed
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for sid, sub := range c.subs {
+		fmt.Fprintf(w, "SUB %s %d\r\n", sub.subject, sid)
+	}
+	w.WriteString("PING\r\n")
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+
+	// The PONG answering our PING confirms CONNECT and SUBs were accepted
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return fail(err)
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			return fail(fmt.Errorf("NATS refused the connection: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	c.conn, c.w = conn, w
+	return conn, r, nil
+}
+
+// read dispatches messages until the connection fails
+func (c *client) read(conn net.Conn, r *bufio.Reader) error {
+	defer func() {
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn, c.w = nil, nil
+		}
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			if err := c.deliver(line, r); err != nil {
+				return err
+			}
+		case line == "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			slog.Warn("NATS error", "error", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// deliver reads the payload of "MSG <subject> <sid> [reply-to] <#bytes>"
+// and hands it to the subscription
+func (c *client) deliver(line string, r *bufio.Reader) error {
+	fields := strings.Fields(line)
+	if len(fields) != 4 && len(fields) != 5 {
+		return fmt.Errorf("malformed NATS message %q", line)
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return fmt.Errorf("malformed NATS message %q", line)
+	}
+	payload := make([]byte, size+2) // with the trailing \r\n
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	sid, _ := strconv.Atoi(fields[2])
+
+	c.mu.Lock()
+	sub := c.subs[sid]
+	c.mu.Unlock()
+	if sub != nil {
+		sub.handle(fields[1], payload[:size])
+	}
+	return nil
+}
+
+func (c *client) publish(subject string, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return errNotConnected
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(payload))
+	c.w.Write(payload)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+func (c *client) subscribe(subject string, handle func(subject string, payload []byte)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSID++
+	c.subs[c.lastSID] = &subscription{subject: subject, handle: handle}
+	if c.conn != nil {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		fmt.Fprintf(c.w, "SUB %s %d\r\n", subject, c.lastSID)
+		c.w.Flush() // on failure the reader reconnects and subscribes again
+	}
+}
+
+func (c *client) write(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return errNotConnected
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	c.w.WriteString(s)
+	return c.w.Flush()
+}
+
+func (c *client) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+func (c *client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.done)
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// readLine reads one protocol line without its \r\n
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,140 @@
+// Package events publishes the apps' normalized events on NATS, so the apps
+// and user automation can react to each other without point-to-point
+// integrations. Each event is JSON on the subject <prefix>.<type>:
+//
+//	devops.drift.detected               drift-detector found drift in a space
+//	devops.drift.resolved               a space that had drift has none left
+//	devops.cost.recommendation.created  cost-optimizer stored a recommendation
+//	devops.change.pending               cost-impact-monitor priced a change about to deploy
+//
+// For example, `nats sub 'devops.>'` follows them all. The bus is optional:
+// without a NATS URL, or a nil Bus, publishing does nothing. It speaks the
+// core NATS protocol itself (see client.go) rather than pulling in the NATS
+// client library.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/tenants"
+)
+
+// Event types
+const (
+	DriftDetected         = "drift.detected"
+	DriftResolved         = "drift.resolved"
+	RecommendationCreated = "cost.recommendation.created"
+	ChangePending         = "change.pending"
+)
+
+// DefaultPrefix starts every subject unless configured otherwise
+const DefaultPrefix = "devops"
+
+// Event is one normalized event
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Source        string          `json:"source"` // app that published it
+	Time          time.Time       `json:"time"`
+	Space         string          `json:"space,omitempty"`
+	Team          string          `json:"team,omitempty"`
+	Subject       string          `json:"subject,omitempty"`        // unit or resource the event is about
+	CorrelationID string          `json:"correlation_id,omitempty"` // the incident it belongs to, see pkg/correlation
+	Data          json.RawMessage `json:"data,omitempty"`
+}
+
+// Bus publishes and subscribes to events. It is safe for concurrent use; a
+// nil Bus does nothing.
+type Bus struct {
+	source string
+	prefix string
+	client *client
+}
+
+// Connect returns the bus of app source on the NATS server at url
+// (nats://[user:pass@]host[:port]), authenticating with token if set. It
+// connects in the background and reconnects whenever the connection drops,
+// so a NATS outage never stops the app; events published meanwhile are
+// dropped. An empty url returns a nil Bus.
+func Connect(url, token, prefix, source string) (*Bus, error) {
+	if url == "" {
+		return nil, nil
+	}
+	c, err := newClient(url, token, source)
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	go c.run()
+	return &Bus{source: source, prefix: prefix, client: c}, nil
+}
+
+// Publish sends an event of type typ about subject in space, with data as
+// its payload. The team and correlation ID come from ctx. Failures are logged.
+func (b *Bus) Publish(ctx context.Context, typ, space, subject string, data interface{}) {
+	if b == nil {
+		return
+	}
+	e := Event{
+		ID:      uuid.NewString(),
+		Type:    typ,
+		Source:  b.source,
+		Time:    time.Now().UTC(),
+		Space:   space,
+		Subject: subject,
+
+		CorrelationID: correlation.FromContext(ctx),
+	}
+	if team := tenants.FromContext(ctx); team != nil {
+		e.Team = team.Name
+	}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			slog.Warn("Failed to encode event", "type", typ, logging.Err(err))
+			return
+		}
+		e.Data = raw
+	}
+	payload, _ := json.Marshal(e)
+	if err := b.client.publish(b.prefix+"."+typ, payload); err != nil {
+		slog.Warn("Failed to publish event", "type", typ, logging.Err(err))
+	}
+}
+
+// Subscribe calls handle with every event whose type matches pattern, a
+// type or a NATS wildcard such as "drift.*"; it keeps the subscription
+// across reconnects
+func (b *Bus) Subscribe(pattern string, handle func(Event)) {
+	if b == nil {
+		return
+	}
+	b.client.subscribe(b.prefix+"."+pattern, func(subject string, payload []byte) {
+		var e Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			slog.Warn("Ignoring malformed event", "subject", subject, logging.Err(err))
+			return
+		}
+		if e.Type == "" {
+			e.Type = strings.TrimPrefix(subject, b.prefix+".")
+		}
+		handle(e)
+	})
+}
+
+// Close disconnects from NATS
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.client.close()
+}
@@ -0,0 +1,229 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/tenants"
+)
+
+// fakeNATS routes PUBs to matching SUBs across its connections, enough of
+// the protocol to check the client against
+type fakeNATS struct {
+	t   *testing.T
+	lis net.Listener
+
+	mu       sync.Mutex
+	conns    map[net.Conn]map[string]string // sid -> subject
+	connects []connectOptions
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{t: t, lis: lis, conns: map[net.Conn]map[string]string{}}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATS) url() string { return "nats://" + s.lis.Addr().String() }
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	s.conns[conn] = map[string]string{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			var options connectOptions
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &options)
+			s.mu.Lock()
+			s.connects = append(s.connects, options)
+			s.mu.Unlock()
+		case line == "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case fields[0] == "SUB":
+			s.mu.Lock()
+			s.conns[conn][fields[2]] = fields[1]
+			s.mu.Unlock()
+		case fields[0] == "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.route(fields[1], payload[:size])
+		}
+	}
+}
+
+func (s *fakeNATS) route(subject string, payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, subs := range s.conns {
+		for sid, pattern := range subs {
+			if matchSubject(pattern, subject) {
+				fmt.Fprintf(conn, "MSG %s %s %d\r\n%s\r\n", subject, sid, len(payload), payload)
+			}
+		}
+	}
+}
+
+// dropAll closes every client connection, as a server restart would
+func (s *fakeNATS) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *fakeNATS) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, subs := range s.conns {
+		n += len(subs)
+	}
+	return n
+}
+
+func matchSubject(pattern, subject string) bool {
+	p, t := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, token := range p {
+		if token == ">" {
+			return len(t) > i
+		}
+		if i >= len(t) || (token != "*" && token != t[i]) {
+			return false
+		}
+	}
+	return len(p) == len(t)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	server := newFakeNATS(t)
+	drift, err := Connect(server.url(), "s3cret", "", "drift-detector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drift.Close()
+	monitor, err := Connect(server.url(), "s3cret", "", "cost-impact-monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer monitor.Close()
+
+	received := make(chan Event, 10)
+	monitor.Subscribe("drift.*", func(e Event) { received <- e })
+	waitFor(t, "the subscription", func() bool { return server.subscribers() == 1 })
+	waitFor(t, "the publisher", func() bool { return drift.client.isConnected() })
+
+	ctx := tenants.WithTeam(context.Background(), &tenants.Team{Name: "payments"})
+	ctx = correlation.With(ctx, "incident-1")
+	drift.Publish(ctx, DriftDetected, "payments-prod", "Deployment/api", map[string]string{"spec.replicas": "5"})
+	drift.Publish(ctx, RecommendationCreated, "payments-prod", "api", nil) // not a drift.* event
+
+	select {
+	case e := <-received:
+		if e.Type != DriftDetected || e.Source != "drift-detector" || e.Space != "payments-prod" ||
+			e.Team != "payments" || e.Subject != "Deployment/api" || e.CorrelationID != "incident-1" || string(e.Data) != `{"spec.replicas":"5"}` {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the event")
+	}
+	select {
+	case e := <-received:
+		t.Errorf("Unexpected %s event", e.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.mu.Lock()
+	if len(server.connects) != 2 || server.connects[0].AuthToken != "s3cret" {
+		t.Errorf("Unexpected CONNECTs %+v", server.connects)
+	}
+	server.mu.Unlock()
+
+	// After the server drops the connections the subscription comes back
+	server.dropAll()
+	waitFor(t, "the reconnects", func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return len(server.connects) == 4
+	})
+	waitFor(t, "the resubscription", func() bool { return server.subscribers() == 1 && drift.client.isConnected() })
+	drift.Publish(context.Background(), DriftResolved, "payments-prod", "", nil)
+	select {
+	case e := <-received:
+		if e.Type != DriftResolved {
+			t.Errorf("Expected drift.resolved, got %s", e.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the event after reconnecting")
+	}
+}
+
+func TestConnect(t *testing.T) {
+	if bus, err := Connect("", "", "", "app"); bus != nil || err != nil {
+		t.Errorf("Expected no bus without a URL, got %v, %v", bus, err)
+	}
+	if _, err := Connect("http://nats:4222", "", "", "app"); err == nil {
+		t.Error("Expected an error for a non-NATS URL")
+	}
+	c, err := newClient("nats://alice:pw@nats.example", "", "app")
+	if err != nil || c.addr != "nats.example:4222" || c.options.User != "alice" || c.options.Pass != "pw" {
+		t.Errorf("newClient = %+v, %v", c, err)
+	}
+
+	var bus *Bus
+	bus.Publish(context.Background(), DriftDetected, "", "", nil)
+	bus.Subscribe(">", func(Event) {})
+	bus.Close()
+}
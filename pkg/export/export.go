@@ -0,0 +1,257 @@
+// Package export writes the apps' records - cost snapshots, drift events,
+// deployment history - to S3 or GCS for analysis in Athena or BigQuery. Apps
+// Add rows as they produce them; every interval the exporter writes the
+// buffered rows as one Parquet or gzipped JSON Lines object per dataset, day
+// and space, partitioned the way both engines read as Hive partitions:
+//
+//	s3://devops-exports/prod/drift_events/dt=2026-10-17/space=payments/drift-detector-20261017T120000Z-1.parquet
+//
+// Rows are structs; their json tags name the columns. The export file
+// (EXPORT_CONFIG, see export.example.yaml) picks the bucket and format;
+// without it nothing is exported.
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Datasets written by the apps
+const (
+	CostSnapshots     = "cost_snapshots"     // cost-impact-monitor: each space's cost after every analysis
+	DriftEvents       = "drift_events"       // drift-detector: each drifted field found
+	DeploymentHistory = "deployment_history" // cost-impact-monitor: predicted against actual cost of each deployment
+)
+
+// Formats of the written objects
+const (
+	Parquet = "parquet"
+	JSONL   = "jsonl"
+)
+
+// NoSpace is the partition of rows without a space, Hive's null partition
+const NoSpace = "__HIVE_DEFAULT_PARTITION__"
+
+// Config is the export file
+type Config struct {
+	Bucket          string        `yaml:"bucket"`   // s3://bucket/prefix or gs://bucket/prefix
+	Format          string        `yaml:"format"`   // parquet (default) or jsonl
+	Interval        time.Duration `yaml:"interval"` // how often buffered rows are written, 15m when unset
+	Region          string        `yaml:"region"`   // us-east-1 for s3://, auto for gs:// when unset
+	Endpoint        string        `yaml:"endpoint"` // S3-compatible endpoint (MinIO, a proxy); objects go under <endpoint>/<bucket>
+	AccessKeyID     string        `yaml:"access_key_id"`
+	SecretAccessKey string        `yaml:"secret_access_key"`
+	SessionToken    string        `yaml:"session_token"`
+	MaxRows         int           `yaml:"max_rows"` // rows buffered while the bucket is unreachable, 100000 when unset
+}
+
+// Exporter buffers rows and writes them to the bucket. A nil Exporter drops
+// them.
+type Exporter struct {
+	app    string
+	config Config
+	prefix string
+	store  interface {
+		put(ctx context.Context, key string, body []byte, contentType string) error
+	}
+
+	mu      sync.Mutex
+	pending map[partition][]interface{}
+	rows    int
+	dropped int
+	seq     int
+}
+
+// partition is where a row is written: its dataset, day and space
+type partition struct{ dataset, day, space string }
+
+// Load reads the export file at path, expanding ${VAR}s and taking the keys
+// from the secret files export-access-key-id and export-secret-access-key
+// when set (see config.Effective.ReadSecretFile). A missing file returns a
+// nil Exporter.
+func Load(path, app string, secret func(name string) (string, error)) (*Exporter, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read export config: %w", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &cfg); err != nil {
+		return nil, fmt.Errorf("parse export config: %w", err)
+	}
+	for file, value := range map[string]*string{"export-access-key-id": &cfg.AccessKeyID, "export-secret-access-key": &cfg.SecretAccessKey} {
+		v, err := secret(file)
+		if err != nil {
+			return nil, fmt.Errorf("export config: %w", err)
+		}
+		if v != "" {
+			*value = v
+		}
+	}
+	return New(app, cfg)
+}
+
+// New validates cfg and returns an Exporter of app's rows
+func New(app string, cfg Config) (*Exporter, error) {
+	if cfg.Format == "" {
+		cfg.Format = Parquet
+	}
+	if cfg.Format != Parquet && cfg.Format != JSONL {
+		return nil, fmt.Errorf("export config: format must be parquet or jsonl, not %q", cfg.Format)
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 15 * time.Minute
+	}
+	if cfg.Interval < time.Minute {
+		return nil, fmt.Errorf("export config: interval %s is below 1m", cfg.Interval)
+	}
+	if cfg.MaxRows == 0 {
+		cfg.MaxRows = 100000
+	}
+	b, prefix, err := newBucket(cfg.Bucket, cfg.Region, cfg.Endpoint, credentials{cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken})
+	if err != nil {
+		return nil, fmt.Errorf("export config: %w", err)
+	}
+	return &Exporter{app: app, config: cfg, prefix: prefix, store: b, pending: map[partition][]interface{}{}}, nil
+}
+
+// Add buffers rows of dataset for space, dated today. Once max_rows are
+// buffered further rows are dropped, and counted in the next write's log.
+func (e *Exporter) Add(dataset, space string, rows ...interface{}) {
+	if e == nil || len(rows) == 0 {
+		return
+	}
+	if space == "" {
+		space = NoSpace
+	}
+	p := partition{dataset, time.Now().UTC().Format("2006-01-02"), space}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if room := e.config.MaxRows - e.rows; len(rows) > room {
+		e.dropped += len(rows) - max(room, 0)
+		rows = rows[:max(room, 0)]
+	}
+	e.pending[p] = append(e.pending[p], rows...)
+	e.rows += len(rows)
+}
+
+// Run writes the buffered rows every interval until ctx is done, then once
+// more
+func (e *Exporter) Run(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flush, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			e.logFlush(flush)
+			cancel()
+			return
+		case <-ticker.C:
+			e.logFlush(ctx)
+		}
+	}
+}
+
+func (e *Exporter) logFlush(ctx context.Context) {
+	if err := e.Flush(ctx); err != nil {
+		slog.Error("Failed to export rows, keeping them for the next write", logging.Err(err))
+	}
+}
+
+// Flush writes the buffered rows, one object per partition. Rows of a
+// partition that failed stay buffered for the next Flush.
+func (e *Exporter) Flush(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	pending, dropped := e.pending, e.dropped
+	e.pending, e.rows, e.dropped = map[partition][]interface{}{}, 0, 0
+	e.seq++
+	seq := e.seq
+	e.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("Export buffer was full, rows were dropped", "rows", dropped, "max_rows", e.config.MaxRows)
+	}
+
+	parts := make([]partition, 0, len(pending))
+	for p := range pending {
+		parts = append(parts, p)
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		a, b := parts[i], parts[j]
+		if a.dataset != b.dataset {
+			return a.dataset < b.dataset
+		}
+		if a.day != b.day {
+			return a.day < b.day
+		}
+		return a.space < b.space
+	})
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	var errs []error
+	for _, p := range parts {
+		rows := pending[p]
+		key := path.Join(e.prefix, p.dataset, "dt="+p.day, "space="+p.space, fmt.Sprintf("%s-%s-%d.%s", e.app, stamp, seq, e.extension()))
+		err := e.write(ctx, key, rows)
+		if err == nil {
+			slog.Debug("Exported rows", "key", key, "rows", len(rows))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("write %s: %w", key, err))
+		e.mu.Lock()
+		e.pending[p] = append(rows, e.pending[p]...)
+		e.rows += len(rows)
+		e.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Exporter) extension() string {
+	if e.config.Format == JSONL {
+		return "jsonl.gz"
+	}
+	return "parquet"
+}
+
+func (e *Exporter) write(ctx context.Context, key string, rows []interface{}) error {
+	if e.config.Format == Parquet {
+		body, err := encodeParquet(rows)
+		if err != nil {
+			return err
+		}
+		return e.store.put(ctx, key, body, "application/vnd.apache.parquet")
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return e.store.put(ctx, key, buf.Bytes(), "application/gzip")
+}
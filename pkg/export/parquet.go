@@ -0,0 +1,326 @@
+package export
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Parquet physical and converted types, encodings and the page type used
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	dataPage = 0
+)
+
+// column is one flat, required column of a row struct
+type column struct {
+	name      string
+	field     []int
+	kind      int32 // physical type
+	converted int32 // -1 for none
+}
+
+// columns flattens a row struct into columns named by its json tags. Strings
+// and anything with a text form (IDs) are UTF8 strings, times millisecond
+// timestamps, and slices, maps and nested structs their JSON.
+func columns(t reflect.Type) ([]column, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("rows must be structs, not %s", t)
+	}
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		col := column{name: name, field: f.Index, kind: parquetByteArray, converted: convertedUTF8}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft == reflect.TypeOf(time.Time{}):
+			col.kind, col.converted = parquetInt64, convertedTimestampMillis
+		case ft.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()):
+		case ft.Kind() == reflect.Bool:
+			col.kind, col.converted = parquetBoolean, -1
+		case ft.Kind() >= reflect.Int && ft.Kind() <= reflect.Uint64:
+			col.kind, col.converted = parquetInt64, -1
+		case ft.Kind() == reflect.Float32 || ft.Kind() == reflect.Float64:
+			col.kind, col.converted = parquetDouble, -1
+		}
+		cols = append(cols, col)
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("%s has no exported fields", t)
+	}
+	return cols, nil
+}
+
+// plain appends v to a column's PLAIN-encoded values; booleans are packed
+// by the caller
+func (c column) plain(buf *bytes.Buffer, v reflect.Value) error {
+	var b [8]byte
+	switch {
+	case c.converted == convertedTimestampMillis:
+		binary.LittleEndian.PutUint64(b[:], uint64(v.Interface().(time.Time).UnixMilli()))
+		buf.Write(b[:])
+	case c.kind == parquetInt64:
+		n := int64(0)
+		if v.CanInt() {
+			n = v.Int()
+		} else {
+			n = int64(v.Uint())
+		}
+		binary.LittleEndian.PutUint64(b[:], uint64(n))
+		buf.Write(b[:])
+	case c.kind == parquetDouble:
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(v.Float()))
+		buf.Write(b[:])
+	default:
+		var s []byte
+		var err error
+		switch value := v.Interface().(type) {
+		case string:
+			s = []byte(value)
+		case encoding.TextMarshaler:
+			s, err = value.MarshalText()
+		default:
+			if v.Kind() == reflect.String {
+				s = []byte(v.String())
+			} else {
+				s, err = json.Marshal(value)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+		buf.Write(b[:4])
+		buf.Write(s)
+	}
+	return nil
+}
+
+// encodeParquet writes rows, structs of one type, as a Parquet file of one
+// uncompressed row group with a PLAIN data page per column
+func encodeParquet(rows []interface{}) ([]byte, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows")
+	}
+	t := reflect.TypeOf(rows[0])
+	cols, err := columns(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString("PAR1")
+	chunks := make([]func(*thrift), len(cols))
+	total := int64(0)
+	for i, col := range cols {
+		var values bytes.Buffer
+		var bits byte
+		for n, row := range rows {
+			v := reflect.ValueOf(row)
+			if v.Type() != t {
+				return nil, fmt.Errorf("row %d is a %s, not %s", n, v.Type(), t)
+			}
+			field := v.FieldByIndex(col.field)
+			if field.Kind() == reflect.Pointer {
+				if field.IsNil() {
+					field = reflect.Zero(field.Type().Elem())
+				} else {
+					field = field.Elem()
+				}
+			}
+			if col.kind == parquetBoolean {
+				if field.Bool() {
+					bits |= 1 << (n % 8)
+				}
+				if n%8 == 7 || n == len(rows)-1 {
+					values.WriteByte(bits)
+					bits = 0
+				}
+				continue
+			}
+			if err := col.plain(&values, field); err != nil {
+				return nil, err
+			}
+		}
+
+		var header thrift
+		header.i32(1, dataPage)
+		header.i32(2, int32(values.Len()))
+		header.i32(3, int32(values.Len()))
+		header.begin(5)
+		header.i32(1, int32(len(rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.stop()
+
+		offset := int64(out.Len())
+		size := int64(header.buf.Len() + values.Len())
+		out.Write(header.buf.Bytes())
+		out.Write(values.Bytes())
+		total += size
+
+		col := col
+		chunks[i] = func(t *thrift) {
+			t.i64(2, offset)
+			t.begin(3)
+			t.i32(1, col.kind)
+			t.list(2, thriftI32, 2)
+			t.varint(int64(encodingPlain))
+			t.varint(int64(encodingRLE))
+			t.list(3, thriftBinary, 1)
+			t.bytes(col.name)
+			t.i32(4, 0) // uncompressed
+			t.i64(5, int64(len(rows)))
+			t.i64(6, size)
+			t.i64(7, size)
+			t.i64(9, offset)
+			t.end()
+		}
+	}
+
+	var meta thrift
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(cols)+1)
+	meta.elem(func(t *thrift) {
+		t.binary(4, "schema")
+		t.i32(5, int32(len(cols)))
+	})
+	for _, col := range cols {
+		col := col
+		meta.elem(func(t *thrift) {
+			t.i32(1, col.kind)
+			t.i32(3, 0) // required
+			t.binary(4, col.name)
+			if col.converted >= 0 {
+				t.i32(6, col.converted)
+			}
+		})
+	}
+	meta.i64(3, int64(len(rows)))
+	meta.list(4, thriftStruct, 1)
+	meta.elem(func(t *thrift) {
+		t.list(1, thriftStruct, len(chunks))
+		for _, chunk := range chunks {
+			t.elem(chunk)
+		}
+		t.i64(2, total)
+		t.i64(3, int64(len(rows)))
+	})
+	meta.binary(6, "devops-examples pkg/export")
+	meta.stop()
+
+	out.Write(meta.buf.Bytes())
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(meta.buf.Len()))
+	out.Write(n[:])
+	out.WriteString("PAR1")
+	return out.Bytes(), nil
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thrift writes the Thrift compact protocol Parquet's metadata is encoded in
+type thrift struct {
+	buf  bytes.Buffer
+	last []int16 // previous field ID of each open struct
+	id   int16
+}
+
+func (t *thrift) field(id int16, typ byte) {
+	if delta := id - t.id; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.id = id
+}
+
+// varint writes a zigzag varint, as i16, i32 and i64 values are
+func (t *thrift) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], uint64((v<<1)^(v>>63)))])
+}
+
+func (t *thrift) bytes(s string) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], uint64(len(s)))])
+	t.buf.WriteString(s)
+}
+
+func (t *thrift) i32(id int16, v int32) { t.field(id, thriftI32); t.varint(int64(v)) }
+func (t *thrift) i64(id int16, v int64) { t.field(id, thriftI64); t.varint(v) }
+func (t *thrift) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.bytes(s)
+}
+
+// begin opens a struct field, end closes it
+func (t *thrift) begin(id int16) {
+	t.field(id, thriftStruct)
+	t.last = append(t.last, t.id)
+	t.id = 0
+}
+
+func (t *thrift) end() {
+	t.stop()
+	t.id = t.last[len(t.last)-1]
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thrift) stop() { t.buf.WriteByte(0) }
+
+// list starts a list field of n elements of typ
+func (t *thrift) list(id int16, typ byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | typ)
+		return
+	}
+	t.buf.WriteByte(0xf0 | typ)
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
+
+// elem writes one struct element of a list
+func (t *thrift) elem(fields func(*thrift)) {
+	t.last = append(t.last, t.id)
+	t.id = 0
+	fields(t)
+	t.end()
+}
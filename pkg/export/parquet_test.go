@@ -0,0 +1,145 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type testRow struct {
+	ID       uuid.UUID `json:"id"`
+	Space    string    `json:"space"`
+	Cost     float64   `json:"cost"`
+	Changes  int       `json:"changes"`
+	Risky    bool      `json:"risky"`
+	At       time.Time `json:"at"`
+	Factors  []string  `json:"factors,omitempty"`
+	internal string
+	Skipped  string `json:"-"`
+}
+
+func TestEncodeParquet(t *testing.T) {
+	at := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	id := uuid.MustParse("7b1d4c6e-0000-4000-8000-000000000001")
+	rows := []interface{}{
+		testRow{ID: id, Space: "payments", Cost: 12.5, Changes: 3, Risky: true, At: at, Factors: []string{"replicas"}},
+		testRow{Space: "search", Cost: 0.25, At: at.Add(time.Hour)},
+	}
+	data, err := encodeParquet(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("Missing the PAR1 magic")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := readStruct(t, bytes.NewReader(data[len(data)-8-size:len(data)-8]))
+
+	if meta[3] != int64(2) {
+		t.Errorf("num_rows = %v, want 2", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	var names []string
+	for _, el := range schema[1:] {
+		names = append(names, string(el.(map[int16]interface{})[4].([]byte)))
+	}
+	if got := len(names); got != 7 || names[0] != "id" || names[5] != "at" || names[6] != "factors" {
+		t.Fatalf("Columns = %v", names)
+	}
+	if root := schema[0].(map[int16]interface{}); root[5] != int64(7) {
+		t.Errorf("Root schema = %v", root)
+	}
+
+	// Read each column's data page back through its chunk's offset
+	chunks := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	values := map[string][]byte{}
+	for i, chunk := range chunks {
+		cmd := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+		r := bytes.NewReader(data[cmd[9].(int64):])
+		header := readStruct(t, r)
+		page := make([]byte, header[3].(int64))
+		r.Read(page)
+		if n := header[5].(map[int16]interface{})[1]; n != int64(2) {
+			t.Errorf("%s page has %v values", names[i], n)
+		}
+		values[names[i]] = page
+	}
+	if got := values["space"]; !bytes.Equal(got, []byte("\x08\x00\x00\x00payments\x06\x00\x00\x00search")) {
+		t.Errorf("space = %q", got)
+	}
+	if got := values["id"]; !bytes.Contains(got, []byte(id.String())) {
+		t.Errorf("id = %q", got)
+	}
+	if got := math.Float64frombits(binary.LittleEndian.Uint64(values["cost"][8:])); got != 0.25 {
+		t.Errorf("cost[1] = %v", got)
+	}
+	if got := int64(binary.LittleEndian.Uint64(values["at"])); got != at.UnixMilli() {
+		t.Errorf("at[0] = %v", got)
+	}
+	if got := values["risky"]; !bytes.Equal(got, []byte{1}) {
+		t.Errorf("risky = %v", got)
+	}
+	if got := values["factors"]; !bytes.Contains(got, []byte(`["replicas"]`)) || !bytes.Contains(got, []byte("null")) {
+		t.Errorf("factors = %q", got)
+	}
+
+	if _, err := encodeParquet([]interface{}{testRow{}, struct{ A string }{}}); err == nil {
+		t.Error("Expected rows of different types to fail")
+	}
+}
+
+// readStruct decodes a Thrift compact struct into its fields by ID
+func readStruct(t *testing.T, r *bytes.Reader) map[int16]interface{} {
+	t.Helper()
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("Truncated struct: %v", err)
+		}
+		if b == 0 {
+			return fields
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, _ := binary.ReadUvarint(r)
+			id = int16(int64(v>>1) ^ -int64(v&1))
+		}
+		fields[id] = readValue(t, r, b&0x0f)
+	}
+}
+
+func readValue(t *testing.T, r *bytes.Reader, typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		v, _ := binary.ReadUvarint(r)
+		return int64(v>>1) ^ -int64(v&1)
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(r)
+		b := make([]byte, n)
+		r.Read(b)
+		return b
+	case thriftList:
+		h, _ := r.ReadByte()
+		n := int(h >> 4)
+		if n == 15 {
+			v, _ := binary.ReadUvarint(r)
+			n = int(v)
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = readValue(t, r, h&0x0f)
+		}
+		return list
+	case thriftStruct:
+		return readStruct(t, r)
+	}
+	t.Fatalf("Unexpected Thrift type %d", typ)
+	return nil
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+// bucket writes objects with the S3 API, signed with AWS Signature Version
+// 4. GCS speaks it too at storage.googleapis.com, with HMAC keys.
+type bucket struct {
+	service string // s3 or gcs, for errors
+	base    string // URL the object key is appended to
+	region  string
+	key     credentials
+	client  *http.Client
+}
+
+type credentials struct{ id, secret, token string }
+
+// newBucket resolves where objects of an s3:// or gs:// URL are written:
+// AWS's virtual-hosted endpoint of the bucket, or the path under endpoint
+func newBucket(name, region, endpoint string, key credentials) (*bucket, string, error) {
+	u, err := url.Parse(name)
+	if err != nil || u.Host == "" {
+		return nil, "", fmt.Errorf("bucket %q is not an s3:// or gs:// URL", name)
+	}
+	b := &bucket{region: region, key: key, client: &http.Client{Timeout: 5 * time.Minute}}
+	switch u.Scheme {
+	case "s3":
+		b.service = "s3"
+		if b.region == "" {
+			b.region = "us-east-1"
+		}
+		if endpoint == "" {
+			endpoint = "https://" + u.Host + ".s3." + b.region + ".amazonaws.com"
+		} else {
+			endpoint = strings.TrimSuffix(endpoint, "/") + "/" + u.Host
+		}
+	case "gs":
+		b.service = "gcs"
+		if b.region == "" {
+			b.region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/" + u.Host
+	default:
+		return nil, "", fmt.Errorf("bucket %q is not an s3:// or gs:// URL", name)
+	}
+	if key.id == "" || key.secret == "" {
+		return nil, "", fmt.Errorf("bucket %s needs an access key ID and secret", name)
+	}
+	b.base = endpoint
+	return b, strings.Trim(u.Path, "/"), nil
+}
+
+// put uploads one object
+func (b *bucket) put(ctx context.Context, key string, body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.base+"/"+escapePath(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	b.sign(req, body, time.Now().UTC())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errkind.FromResponse(b.service, resp, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// sign adds the Signature Version 4 headers, signing the host, date and
+// payload hash (and session token)
+func (b *bucket) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	stamp := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	headers := []string{"host:" + req.URL.Host, "x-amz-content-sha256:" + payload, "x-amz-date:" + stamp}
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	if b.key.token != "" {
+		req.Header.Set("X-Amz-Security-Token", b.key.token)
+		headers = append(headers, "x-amz-security-token:"+b.key.token)
+		signed += ";x-amz-security-token"
+	}
+
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, strings.Join(headers, "\n") + "\n", signed, payload}, "\n")
+	scope := now.Format("20060102") + "/" + b.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + b.key.secret)
+	for _, part := range []string{now.Format("20060102"), b.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.key.id, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath escapes an object key as Signature Version 4 expects: every
+// byte but unreserved ones and the slashes between segments
+func escapePath(key string) string {
+	var sb strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,201 @@
+// Package flags switches the apps' risky behaviours on and off without a
+// restart. Each flag defaults to the app's own setting (config file or
+// environment, e.g. AUTO_FIX); a ConfigHub unit can override it for every
+// replica of every app at once:
+//
+//	# unit "feature-flags" in the space named by FLAGS_SPACE
+//	auto_fix: false                           # all apps
+//	cost-optimizer/auto_apply_optimizations: true  # one app only
+//
+// The unit is re-read every FLAGS_REFRESH; deleting a key (or the unit)
+// returns the flag to its default.
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Flags shared by the apps
+const (
+	AutoFix    = "auto_fix"                 // drift-detector applies its fixes
+	AutoApply  = "auto_apply_optimizations" // cost-optimizer applies recommendations
+	CostGating = "cost_gating"              // cost-impact-monitor escalates and blocks risky changes
+)
+
+// Unit is the slug of the ConfigHub unit holding overrides
+const Unit = "feature-flags"
+
+// Source returns the overrides document, empty when there is none
+type Source func(ctx context.Context) (string, error)
+
+// State is a flag's current value and where it came from
+type State struct {
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"` // "default" or "confighub"
+}
+
+// Set is an app's flags. It is safe for concurrent use.
+type Set struct {
+	app      string
+	defaults map[string]bool
+	source   Source
+
+	mu        sync.RWMutex
+	overrides map[string]bool
+}
+
+// New returns the flags of app with their defaults; a nil source never overrides them
+func New(app string, defaults map[string]bool, source Source) *Set {
+	return &Set{app: app, defaults: defaults, source: source, overrides: map[string]bool{}}
+}
+
+// Enabled reports whether a flag is on; unknown flags are off
+func (s *Set) Enabled(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if v, ok := s.overrides[name]; ok {
+		return v
+	}
+	return s.defaults[name]
+}
+
+// Snapshot returns every known flag
+func (s *Set) Snapshot() map[string]State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	states := make(map[string]State, len(s.defaults))
+	for name, v := range s.defaults {
+		states[name] = State{Enabled: v, Source: "default"}
+	}
+	for name, v := range s.overrides {
+		states[name] = State{Enabled: v, Source: "confighub"}
+	}
+	return states
+}
+
+// Refresh re-reads the overrides. On error the previous overrides stay.
+func (s *Set) Refresh(ctx context.Context) error {
+	if s.source == nil {
+		return nil
+	}
+	data, err := s.source(ctx)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+	overrides, err := Parse(s.app, data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	before := s.overrides
+	s.overrides = overrides
+	s.mu.Unlock()
+
+	s.logChanges(before, overrides)
+	return nil
+}
+
+// Watch refreshes every interval until ctx is done
+func (s *Set) Watch(ctx context.Context, interval time.Duration) {
+	if s.source == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Refresh(ctx); err != nil {
+			slog.Warn("Failed to refresh feature flags", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logChanges reports flags whose effective value or source changed
+func (s *Set) logChanges(before, after map[string]bool) {
+	names := map[string]bool{}
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		old, hadOld := before[name]
+		v, has := after[name]
+		switch {
+		case has && (!hadOld || old != v):
+			slog.Info("Feature flag overridden", "flag", name, "enabled", v)
+		case hadOld && !has:
+			slog.Info("Feature flag override removed", "flag", name, "enabled", s.defaults[name])
+		}
+	}
+}
+
+// Parse reads an overrides document for app. Keys prefixed "<app>/" apply to
+// that app only and win over unprefixed ones; other apps' keys are ignored.
+func Parse(app, data string) (map[string]bool, error) {
+	var doc map[string]bool
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, fmt.Errorf("parse flags: %w", err)
+	}
+
+	overrides := map[string]bool{}
+	scoped := map[string]bool{}
+	for key, v := range doc {
+		prefix, name, found := strings.Cut(key, "/")
+		switch {
+		case !found:
+			overrides[key] = v
+		case prefix == app:
+			scoped[name] = v
+		}
+	}
+	for name, v := range scoped {
+		overrides[name] = v
+	}
+	return overrides, nil
+}
+
+// Listing is the body of GET /api/v1/flags
+type Listing struct {
+	Flags map[string]State `json:"flags"`
+}
+
+// Operations document the flags route of an app's API
+var Operations = []api.Operation{{Summary: "Feature flags, their defaults and overrides", Response: Listing{}}}
+
+// ServeHTTP returns the flags as JSON
+func (s *Set) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Listing{Flags: s.Snapshot()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,95 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseScopesToApp(t *testing.T) {
+	got, err := Parse("cost-optimizer", `
+auto_fix: false
+cost_gating: true
+cost-optimizer/cost_gating: false
+drift-detector/auto_fix: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{AutoFix: false, CostGating: false}
+	if len(got) != len(want) {
+		t.Fatalf("overrides = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %t, want %t", k, got[k], v)
+		}
+	}
+
+	if _, err := Parse("drift-detector", "auto_fix: maybe"); err == nil {
+		t.Error("non-boolean flag accepted")
+	}
+	if got, err := Parse("drift-detector", ""); err != nil || len(got) != 0 {
+		t.Errorf("empty document = %v, %v", got, err)
+	}
+}
+
+func TestSetRefresh(t *testing.T) {
+	doc, readErr := "auto_fix: true", error(nil)
+	s := New("drift-detector", map[string]bool{AutoFix: false}, func(context.Context) (string, error) {
+		return doc, readErr
+	})
+	ctx := context.Background()
+
+	if s.Enabled(AutoFix) {
+		t.Fatal("auto_fix on before refresh")
+	}
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Enabled(AutoFix) || s.Snapshot()[AutoFix].Source != "confighub" {
+		t.Errorf("after override: %v", s.Snapshot())
+	}
+
+	// A failed read keeps the last known overrides
+	readErr = errors.New("confighub unavailable")
+	if err := s.Refresh(ctx); err == nil {
+		t.Error("read error not returned")
+	}
+	if !s.Enabled(AutoFix) {
+		t.Error("override lost on read error")
+	}
+
+	// Removing the key returns to the default
+	doc, readErr = "", nil
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Enabled(AutoFix) || s.Snapshot()[AutoFix].Source != "default" {
+		t.Errorf("after removal: %v", s.Snapshot())
+	}
+	if s.Enabled("unknown") {
+		t.Error("unknown flag enabled")
+	}
+}
+
+func TestSetWithoutSource(t *testing.T) {
+	s := New("cost-optimizer", map[string]bool{AutoApply: true}, nil)
+	if err := s.Refresh(context.Background()); err != nil || !s.Enabled(AutoApply) {
+		t.Errorf("Refresh = %v, enabled = %t", err, s.Enabled(AutoApply))
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/api/flags", nil))
+	var body struct {
+		Flags map[string]State `json:"flags"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if st := body.Flags[AutoApply]; !st.Enabled || st.Source != "default" {
+		t.Errorf("served %+v", body.Flags)
+	}
+}
@@ -0,0 +1,272 @@
+// Package health reports whether an app can reach what it depends on. Each
+// app registers a probe per dependency (the Kubernetes API, ConfigHub, the
+// AI provider, OpenCost, metrics-server) and mounts the checker next to its
+// liveness endpoint:
+//
+//	checker := health.New()
+//	checker.Register("kubernetes", health.Required, health.Kubernetes(clientset.Discovery()))
+//	checker.Register("confighub", health.Required, health.Breaker(cubBreaker))
+//	checker.Register("claude", health.Optional, health.Breaker(claudeBreaker))
+//	checker.Mount(mux)
+//
+// GET /health/ready serves the report as JSON: 200 while the app is ok or
+// degraded (running on fallbacks because an optional dependency failed),
+// 503 while a required one is down. /health stays the liveness probe, so an
+// outage takes pods out of their Service instead of restarting them.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+	"github.com/monadic/devops-examples/pkg/shared"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// Status is the state of a dependency, or of the app as a whole
+type Status string
+
+const (
+	OK       Status = "ok"
+	Degraded Status = "degraded" // working, with failures or on fallbacks
+	Down     Status = "down"
+)
+
+// Impact says what a dependency being down does to the app
+type Impact int
+
+const (
+	Required Impact = iota // the app can't do its work without it
+	Optional               // the app falls back without it
+)
+
+// ErrDegraded is wrapped by probe errors that mark a dependency degraded
+// rather than down
+var ErrDegraded = errors.New("degraded")
+
+// Probe checks one dependency; nil means healthy
+type Probe func(ctx context.Context) error
+
+// Dependency is the result of one probe
+type Dependency struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Latency  string `json:"latency"`
+}
+
+// Report is the state of an app's dependencies
+type Report struct {
+	Status       Status       `json:"status"`
+	CheckedAt    time.Time    `json:"checked_at"`
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+type registered struct {
+	name   string
+	impact Impact
+	probe  Probe
+}
+
+// Checker runs the registered probes. Reports are reused for a few seconds
+// so that frequent readiness probes and metric scrapes don't load the
+// dependencies.
+type Checker struct {
+	Timeout time.Duration // per probe
+	MaxAge  time.Duration // a report is reused for
+
+	mu     sync.Mutex
+	probes []registered
+	last   *Report
+}
+
+// New returns a checker with no dependencies, always ok. Apps sharing one
+// process (see pkg/shared) share one checker, so /health/ready covers all
+// of their dependencies.
+func New() *Checker {
+	return shared.Value("health", func() *Checker {
+		return &Checker{Timeout: 5 * time.Second, MaxAge: 10 * time.Second}
+	})
+}
+
+// Register adds a dependency. Registering a name again replaces its probe.
+func (c *Checker) Register(name string, impact Impact, probe Probe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.probes {
+		if c.probes[i].name == name {
+			c.probes[i] = registered{name, impact, probe}
+			c.last = nil
+			return
+		}
+	}
+	c.probes = append(c.probes, registered{name, impact, probe})
+	c.last = nil
+}
+
+// Check probes every dependency at once, or returns the last report while
+// it is younger than MaxAge. A nil Checker is always ok.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c == nil {
+		return Report{Status: OK, CheckedAt: time.Now(), Dependencies: []Dependency{}}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last != nil && time.Since(c.last.CheckedAt) < c.MaxAge {
+		return *c.last
+	}
+
+	report := Report{Status: OK, CheckedAt: time.Now(), Dependencies: make([]Dependency, len(c.probes))}
+	var wg sync.WaitGroup
+	for i, p := range c.probes {
+		wg.Add(1)
+		go func(i int, p registered) {
+			defer wg.Done()
+			report.Dependencies[i] = c.run(ctx, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	for _, d := range report.Dependencies {
+		switch {
+		case d.Status == Down && d.Required:
+			report.Status = Down
+		case d.Status != OK && report.Status == OK:
+			report.Status = Degraded
+		}
+	}
+	c.last = &report
+	return report
+}
+
+// run calls one probe, giving up on it after the timeout
+func (c *Checker) run(ctx context.Context, p registered) Dependency {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- p.probe(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("no answer within %s", c.Timeout)
+	}
+
+	d := Dependency{Name: p.name, Required: p.impact == Required, Status: OK, Latency: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		d.Status, d.Error = Down, err.Error()
+		if errors.Is(err, ErrDegraded) {
+			d.Status = Degraded
+		}
+	}
+	return d
+}
+
+// ServeHTTP serves the report, with 503 while the app is down
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == Down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// mounted holds the muxes serving a checker, as mounting twice panics
+var mounted sync.Map
+
+// Mount serves the report at /health/ready on mux; mounting on the same mux
+// again does nothing
+func (c *Checker) Mount(mux *http.ServeMux) {
+	if _, ok := mounted.LoadOrStore(mux, true); !ok {
+		mux.Handle("/health/ready", c)
+	}
+}
+
+// Live is the liveness endpoint: the process is up and serving
+func Live(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// Breaker reports a dependency by the breaker guarding its calls, so the
+// report reflects the app's own calls without making any: down while the
+// breaker is open or probing or the last call's credentials were rejected,
+// degraded while failures are counting up
+func Breaker(b *breaker.Breaker) Probe {
+	return func(context.Context) error {
+		if b == nil {
+			return nil
+		}
+		s := b.Status()
+		switch {
+		case s.LastErrorKind == errkind.Auth:
+			return fmt.Errorf("credentials rejected: %s", s.LastError)
+		case s.State != breaker.Closed:
+			return fmt.Errorf("circuit %s: %s", s.State, s.LastError)
+		case s.Failures > 0:
+			return fmt.Errorf("%w: %d consecutive failures: %s", ErrDegraded, s.Failures, s.LastError)
+		}
+		return nil
+	}
+}
+
+// VersionGetter is satisfied by client-go's discovery client
+type VersionGetter interface {
+	ServerVersion() (*version.Info, error)
+}
+
+// Kubernetes checks that the API server answers
+func Kubernetes(d VersionGetter) Probe {
+	return func(context.Context) error {
+		_, err := d.ServerVersion()
+		return err
+	}
+}
+
+// ResourceLister is satisfied by client-go's discovery client
+type ResourceLister interface {
+	ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error)
+}
+
+// MetricsServer checks that the resource metrics API (metrics.k8s.io) is
+// registered and served
+func MetricsServer(d ResourceLister) Probe {
+	return func(context.Context) error {
+		_, err := d.ServerResourcesForGroupVersion("metrics.k8s.io/v1beta1")
+		return err
+	}
+}
+
+// HTTP checks that url answers a GET without a server error. Any other
+// answer, such as 401 from an API without a token, shows it is reachable.
+func HTTP(client *http.Client, url string) Probe {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
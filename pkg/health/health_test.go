@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+var errDown = errors.New("connection refused")
+
+func healthy(context.Context) error { return nil }
+func failing(context.Context) error { return errDown }
+
+func TestCheckerLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		required Probe
+		optional Probe
+		want     Status
+	}{
+		{"all up", healthy, healthy, OK},
+		{"optional down", healthy, failing, Degraded},
+		{"required degraded", func(context.Context) error { return ErrDegraded }, healthy, Degraded},
+		{"required down", failing, healthy, Down},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.Register("confighub", Required, tt.required)
+			c.Register("claude", Optional, tt.optional)
+			report := c.Check(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("status = %s, want %s: %+v", report.Status, tt.want, report.Dependencies)
+			}
+			if len(report.Dependencies) != 2 || report.Dependencies[0].Name != "confighub" || !report.Dependencies[0].Required {
+				t.Errorf("dependencies = %+v, want confighub then claude", report.Dependencies)
+			}
+		})
+	}
+}
+
+func TestCheckerTimesOutAndCaches(t *testing.T) {
+	var calls atomic.Int32
+	c := New()
+	c.Timeout = 10 * time.Millisecond
+	c.Register("opencost", Optional, func(ctx context.Context) error {
+		calls.Add(1)
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond) // ignores its context
+		return nil
+	})
+
+	report := c.Check(context.Background())
+	if d := report.Dependencies[0]; d.Status != Down || d.Error == "" {
+		t.Errorf("hung probe = %+v, want down", d)
+	}
+	if report.Status != Degraded {
+		t.Errorf("status = %s, want degraded", report.Status)
+	}
+	c.Check(context.Background())
+	if n := calls.Load(); n != 1 {
+		t.Errorf("probed %d times, want the report reused", n)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	c := New()
+	c.Register("kubernetes", Required, failing)
+	mux := http.NewServeMux()
+	c.Mount(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("code = %d, want 503", rec.Code)
+	}
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != Down || report.Dependencies[0].Error != "connection refused" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestNilChecker(t *testing.T) {
+	var c *Checker
+	mux := http.NewServeMux()
+	c.Mount(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusOK || c.Check(context.Background()).Status != OK {
+		t.Errorf("code = %d, want 200", rec.Code)
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	b := breaker.New("confighub", 2, time.Minute)
+	probe := Breaker(b)
+	if err := probe(context.Background()); err != nil {
+		t.Errorf("closed breaker: %v", err)
+	}
+	breaker.Do(b, func() error { return errDown })
+	if err := probe(context.Background()); !errors.Is(err, ErrDegraded) {
+		t.Errorf("after a failure: %v, want degraded", err)
+	}
+	breaker.Do(b, func() error { return errDown })
+	if err := probe(context.Background()); err == nil || errors.Is(err, ErrDegraded) {
+		t.Errorf("open breaker: %v, want down", err)
+	}
+	authFailed := breaker.New("confighub", 5, time.Minute)
+	breaker.Do(authFailed, func() error { return &errkind.HTTPError{Service: "confighub", StatusCode: 401} })
+	if err := Breaker(authFailed)(context.Background()); err == nil || errors.Is(err, ErrDegraded) {
+		t.Errorf("rejected token: %v, want down", err)
+	}
+	if err := Breaker(nil)(context.Background()); err != nil {
+		t.Errorf("nil breaker: %v", err)
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	code := http.StatusUnauthorized
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(code) }))
+	defer srv.Close()
+
+	probe := HTTP(nil, srv.URL)
+	if err := probe(context.Background()); err != nil {
+		t.Errorf("401 is reachable: %v", err)
+	}
+	code = http.StatusBadGateway
+	if err := probe(context.Background()); err == nil {
+		t.Error("502 passed")
+	}
+}
@@ -0,0 +1,116 @@
+// Package lifecycle runs an app's long-lived parts - HTTP servers, check
+// loops, informers, watchers - under one context that SIGINT or SIGTERM
+// cancels, and stops them in order instead of exiting mid-request:
+//
+//	ctx, stop := lifecycle.Signals()
+//	defer stop()
+//	group := lifecycle.New(ctx)
+//	group.Serve(":8080", handler)
+//	group.Informers(informerFactory)
+//	group.Go(monitor.run) // returns when its context is done
+//	if err := group.Wait(); err != nil {
+//		logging.Fatal("Monitor stopped", logging.Err(err))
+//	}
+//
+// The group stops when the signal arrives or any part fails: the others see
+// their context cancelled, servers stop accepting connections and get Grace
+// to finish the requests in flight, and informer factories are shut down.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultGrace is how long a stopping server waits for requests in flight,
+// within the 30s Kubernetes gives a pod between SIGTERM and SIGKILL
+const DefaultGrace = 10 * time.Second
+
+// Signals returns a context cancelled on SIGINT or SIGTERM
+func Signals() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// Group runs an app's parts until its context is done or one of them fails
+type Group struct {
+	Grace time.Duration // for servers to finish requests in flight
+
+	ctx context.Context
+	g   *errgroup.Group
+}
+
+// New returns a group whose parts stop when ctx is done
+func New(ctx context.Context) *Group {
+	g, ctx := errgroup.WithContext(ctx)
+	return &Group{Grace: DefaultGrace, ctx: ctx, g: g}
+}
+
+// Context is cancelled when the group stops. Calls made outside the parts,
+// such as watchers started with go, should use it.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Go runs part until the group stops. It should return nil once its
+// context is done; an error stops the whole group.
+func (g *Group) Go(part func(ctx context.Context) error) {
+	g.g.Go(func() error { return part(g.ctx) })
+}
+
+// Serve serves handler on addr until the group stops
+func (g *Group) Serve(addr string, handler http.Handler) {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	g.Go(func(ctx context.Context) error { return Serve(ctx, srv, g.Grace) })
+}
+
+// InformerFactory is satisfied by client-go's shared and dynamic informer
+// factories
+type InformerFactory interface {
+	Start(stopCh <-chan struct{})
+	Shutdown()
+}
+
+// Informers starts the factory's informers and shuts them down, waiting for
+// their goroutines, when the group stops
+func (g *Group) Informers(factory InformerFactory) {
+	factory.Start(g.ctx.Done())
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		factory.Shutdown()
+		return nil
+	})
+}
+
+// Wait blocks until every part has returned and gives the first error. A
+// stop by signal is not an error.
+func (g *Group) Wait() error {
+	return g.g.Wait()
+}
+
+// Serve runs srv until ctx is done, then stops it, giving the requests in
+// flight up to grace to finish. It returns nil after such a stop and the
+// listener's error otherwise.
+func Serve(ctx context.Context, srv *http.Server, grace time.Duration) error {
+	failed := make(chan error, 1)
+	go func() { failed <- srv.ListenAndServe() }()
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if listenErr := <-failed; !errors.Is(listenErr, http.ErrServerClosed) && err == nil {
+		err = listenErr
+	}
+	return err
+}
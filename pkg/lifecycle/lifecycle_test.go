@@ -0,0 +1,112 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type factory struct {
+	started  <-chan struct{}
+	shutdown bool
+}
+
+func (f *factory) Start(stopCh <-chan struct{}) { f.started = stopCh }
+func (f *factory) Shutdown()                    { f.shutdown = true }
+
+func TestGroupStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	group := New(ctx)
+	f := &factory{}
+	group.Informers(f)
+	ran := false
+	group.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		ran = true
+		return nil
+	})
+
+	cancel()
+	if err := group.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if !ran || !f.shutdown {
+		t.Errorf("part returned %v, informers shut down %v", ran, f.shutdown)
+	}
+	select {
+	case <-f.started:
+	default:
+		t.Error("informers' stop channel still open")
+	}
+}
+
+func TestGroupStopsOnFailure(t *testing.T) {
+	group := New(context.Background())
+	errBroken := errors.New("broken")
+	group.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	group.Go(func(context.Context) error { return errBroken })
+
+	if err := group.Wait(); !errors.Is(err, errBroken) {
+		t.Errorf("Wait = %v, want %v", err, errBroken)
+	}
+}
+
+func TestServeFinishesRequestsInFlight(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	entered := make(chan struct{})
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, srv, time.Second) }()
+
+	var resp *http.Response
+	got := make(chan error, 1)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if resp, err = http.Get("http://" + addr); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		got <- err
+	}()
+	<-entered
+	cancel()
+
+	if err := <-got; err != nil {
+		t.Fatalf("request in flight failed: %v", err)
+	}
+	resp.Body.Close()
+	if err := <-served; err != nil {
+		t.Errorf("Serve = %v, want nil after shutdown", err)
+	}
+}
+
+func TestServeReportsListenErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	srv := &http.Server{Addr: ln.Addr().String()}
+	if err := Serve(context.Background(), srv, time.Second); err == nil {
+		t.Error("Serve on a port in use returned nil")
+	}
+}
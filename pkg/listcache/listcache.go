@@ -0,0 +1,253 @@
+// Package listcache saves the apps downloading ConfigHub listings that have
+// not changed. Every app lists spaces and units on each cycle; the Transport
+// remembers each listing with its ETag or Last-Modified, keyed by space, and
+// asks again with If-None-Match / If-Modified-Since, so an unchanged space
+// costs one 304 without a body and the remembered listing is replayed:
+//
+//	cache := listcache.Install()
+//	reg.Collect(metrics.ListCache(cache))
+//
+// Install wraps http.DefaultTransport, which the SDK's ConfigHub client sends
+// through. Only GETs below the API's /space are cached; a successful write
+// to a space drops what is remembered for it. Listings the server sends
+// without validators are passed through untouched.
+package listcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultURL is the ConfigHub API the SDK uses when CUB_API_URL is unset
+const DefaultURL = "https://hub.confighub.com/api"
+
+// MaxEntries bounds the listings remembered per space; the least recently
+// used goes first
+const MaxEntries = 64
+
+// AllSpaces is the space the space listing itself is counted under
+const AllSpaces = "all"
+
+// Stats counts one space's listings
+type Stats struct {
+	Requests    int64 `json:"requests"`     // listings fetched from ConfigHub
+	NotModified int64 `json:"not_modified"` // of those, answered 304 from the cache
+	BytesSaved  int64 `json:"bytes_saved"`  // body bytes the 304s did not transfer
+}
+
+// Transport is an http.RoundTripper caching ConfigHub listings. It is safe
+// for concurrent use.
+type Transport struct {
+	Base   http.RoundTripper // nil uses http.DefaultTransport as it was when wrapped
+	Prefix string            // API URL whose /space requests are cached
+
+	mu     sync.Mutex
+	spaces map[string]map[string]*entry // space -> request key -> listing
+	stats  map[string]*Stats
+	clock  int64 // advances on every use, for LRU eviction
+}
+
+type entry struct {
+	etag, lastModified string
+	header             http.Header
+	body               []byte
+	used               int64
+}
+
+// New caches listings of the ConfigHub API at apiURL sent through base
+func New(base http.RoundTripper, apiURL string) *Transport {
+	return &Transport{Base: base, Prefix: strings.TrimSuffix(apiURL, "/")}
+}
+
+// Install makes http.DefaultTransport cache listings of the API at
+// CUB_API_URL and returns it; calling it again returns the same Transport
+func Install() *Transport {
+	if t, ok := http.DefaultTransport.(*Transport); ok {
+		return t
+	}
+	apiURL := os.Getenv("CUB_API_URL")
+	if apiURL == "" {
+		apiURL = DefaultURL
+	}
+	t := New(http.DefaultTransport, apiURL)
+	http.DefaultTransport = t
+	return t
+}
+
+// RoundTrip sends req, conditionally when its listing is remembered
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	space, ok := t.spaceOf(req)
+	if !ok {
+		return base.RoundTrip(req)
+	}
+	if req.Method != http.MethodGet {
+		resp, err := base.RoundTrip(req)
+		if err == nil && resp.StatusCode < 300 {
+			t.drop(space)
+		}
+		return resp, err
+	}
+
+	key := requestKey(req)
+	cached := t.lookup(space, key)
+	if cached != nil && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		req = req.Clone(req.Context())
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		t.count(space, true, len(cached.body))
+		header := cached.header.Clone()
+		return &http.Response{
+			Status: "200 OK", StatusCode: http.StatusOK,
+			Proto: resp.Proto, ProtoMajor: resp.ProtoMajor, ProtoMinor: resp.ProtoMinor,
+			Header: header, Body: io.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)), Request: req,
+		}, nil
+
+	case resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""):
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		t.store(space, key, &entry{
+			etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified"),
+			header: resp.Header.Clone(), body: body,
+		})
+
+	default:
+		t.forget(space, key)
+	}
+	t.count(space, false, 0)
+	return resp, nil
+}
+
+// Stats returns the counters of each space, AllSpaces for the space listing
+func (t *Transport) Stats() map[string]Stats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make(map[string]Stats, len(t.stats))
+	for space, s := range t.stats {
+		stats[space] = *s
+	}
+	return stats
+}
+
+// spaceOf returns the space a request to the API is about: the ID or slug
+// after /space, or AllSpaces for /space itself
+func (t *Transport) spaceOf(req *http.Request) (string, bool) {
+	url := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	rest, ok := strings.CutPrefix(url, t.Prefix)
+	if !ok {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	switch {
+	case parts[0] != "space":
+		return "", false
+	case len(parts) == 1:
+		return AllSpaces, true
+	default:
+		return parts[1], true
+	}
+}
+
+// requestKey tells listings apart by URL and by token, so teams with their
+// own tokens never see each other's listings
+func requestKey(req *http.Request) string {
+	token := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	return req.URL.String() + "\x00" + hex.EncodeToString(token[:8])
+}
+
+func (t *Transport) lookup(space, key string) *entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.spaces[space][key]
+	if e != nil {
+		t.clock++
+		e.used = t.clock
+	}
+	return e
+}
+
+func (t *Transport) store(space, key string, e *entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.spaces == nil {
+		t.spaces = map[string]map[string]*entry{}
+	}
+	entries := t.spaces[space]
+	if entries == nil {
+		entries = map[string]*entry{}
+		t.spaces[space] = entries
+	}
+	if _, ok := entries[key]; !ok && len(entries) >= MaxEntries {
+		var oldest string
+		for k, other := range entries {
+			if oldest == "" || other.used < entries[oldest].used {
+				oldest = k
+			}
+		}
+		delete(entries, oldest)
+	}
+	t.clock++
+	e.used = t.clock
+	entries[key] = e
+}
+
+func (t *Transport) forget(space, key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.spaces[space], key)
+}
+
+func (t *Transport) drop(space string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.spaces, space)
+}
+
+func (t *Transport) count(space string, notModified bool, saved int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stats == nil {
+		t.stats = map[string]*Stats{}
+	}
+	s, ok := t.stats[space]
+	if !ok {
+		s = &Stats{}
+		t.stats[space] = s
+	}
+	s.Requests++
+	if notModified {
+		s.NotModified++
+		s.BytesSaved += int64(saved)
+	}
+}
@@ -0,0 +1,114 @@
+package listcache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/confighubtest"
+)
+
+func TestTransport(t *testing.T) {
+	hub := confighubtest.NewServer("token")
+	defer hub.Close()
+	space := hub.AddSpace("payments", nil)
+	hub.AddUnit(space.SpaceID, "api", "kind: Deployment", nil)
+
+	cache := New(http.DefaultTransport, hub.URL)
+	client := &http.Client{Transport: cache}
+	get := func(path, token string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, hub.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	units := "/space/" + space.SpaceID.String() + "/unit"
+
+	code, first := get(units, "token")
+	if code != http.StatusOK || !strings.Contains(first, `"Slug":"api"`) {
+		t.Fatalf("first listing = %d %s", code, first)
+	}
+	if code, again := get(units, "token"); code != http.StatusOK || again != first {
+		t.Errorf("unchanged listing = %d %s, want the cached %s", code, again, first)
+	}
+	get("/space", "token")
+	get("/space", "token")
+
+	s := cache.Stats()[space.SpaceID.String()]
+	if s.Requests != 2 || s.NotModified != 1 || s.BytesSaved != int64(len(first)) {
+		t.Errorf("space stats = %+v", s)
+	}
+	if s := cache.Stats()[AllSpaces]; s.Requests != 2 || s.NotModified != 1 {
+		t.Errorf("space list stats = %+v", s)
+	}
+
+	// A change on the server is fetched in full
+	hub.AddUnit(space.SpaceID, "web", "kind: Deployment", nil)
+	if _, changed := get(units, "token"); !strings.Contains(changed, `"Slug":"web"`) {
+		t.Errorf("changed listing = %s", changed)
+	}
+
+	// Another token never gets the cached listing
+	if code, _ := get(units, "other"); code != http.StatusUnauthorized {
+		t.Errorf("other token = %d, want 401", code)
+	}
+
+	// A write through the transport drops the space's listings
+	req, _ := http.NewRequest(http.MethodPost, hub.URL+units+"/api/apply", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(cache.spaces[space.SpaceID.String()]) != 0 {
+		t.Error("Expected a write to drop the space's listings")
+	}
+}
+
+func TestEviction(t *testing.T) {
+	cache := New(nil, "http://confighub")
+	for i := 0; i <= MaxEntries; i++ {
+		cache.store("payments", fmt.Sprint("key-", i), &entry{})
+		if i == 1 {
+			cache.lookup("payments", "key-0") // still in use, so key-1 is older
+		}
+	}
+	entries := cache.spaces["payments"]
+	if len(entries) != MaxEntries || entries["key-0"] == nil || entries["key-1"] != nil {
+		t.Errorf("Expected key-1 evicted of %d entries", len(entries))
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	cache := New(nil, "https://hub.confighub.com/api")
+	for _, url := range []string{"https://hub.confighub.com/api/target", "https://opencost:9003/space/x", "https://hub.confighub.com/api/space/x/unit"} {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		_, ok := cache.spaceOf(req)
+		if want := strings.HasSuffix(url, "/unit"); ok != want {
+			t.Errorf("spaceOf(%s) = %v, want %v", url, ok, want)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	saved := http.DefaultTransport
+	defer func() { http.DefaultTransport = saved }()
+	t.Setenv("CUB_API_URL", "http://confighub/api/")
+
+	cache := Install()
+	if http.DefaultTransport != http.RoundTripper(cache) || cache.Prefix != "http://confighub/api" || cache.Base != saved {
+		t.Errorf("Install = %+v", cache)
+	}
+	if Install() != cache {
+		t.Error("Expected a second Install to return the installed Transport")
+	}
+}
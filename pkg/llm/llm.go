@@ -0,0 +1,272 @@
+// Package llm calls the AI model behind the apps' drift analyses, risk
+// assessments and cost recommendations: Claude, an OpenAI-compatible endpoint
+// (OpenAI, vLLM, LiteLLM, Azure OpenAI) or a local Ollama model for
+// air-gapped clusters. Each app sets the provider, model, token limit and
+// temperature, and a monthly token budget. Once the budget is spent, calls
+// fail at once with ErrBudgetExhausted and the apps fall back to their
+// rule-based analyses until the month ends, so AI spend has a ceiling
+// whatever the cluster size.
+//
+// Usage is counted in memory from the token counts the API reports. A
+// restart starts the month's count again, so budgets are per process
+// lifetime within a month; size them with the replica count in mind.
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/tracing"
+)
+
+// Providers accepted by the apps' llm_provider setting
+const (
+	ProviderClaude = "claude"
+	ProviderOpenAI = "openai" // any endpoint speaking OpenAI's chat completions API
+	ProviderOllama = "ollama"
+)
+
+// DefaultBaseURLs are the API roots used when llm_base_url is empty
+var DefaultBaseURLs = map[string]string{
+	ProviderClaude: "https://api.anthropic.com/v1",
+	ProviderOpenAI: "https://api.openai.com/v1",
+	ProviderOllama: "http://localhost:11434/v1",
+}
+
+// DefaultModels are the models used when llm_model is empty
+var DefaultModels = map[string]string{
+	ProviderClaude: "claude-sonnet-4-5",
+	ProviderOpenAI: "gpt-4o-mini",
+	ProviderOllama: "llama3.1",
+}
+
+// Defaults of the apps' other llm_* settings. Prices are US dollars per
+// million tokens of the default Claude model.
+const (
+	DefaultMaxTokens   = 4096
+	DefaultInputPrice  = 3.0
+	DefaultOutputPrice = 15.0
+)
+
+// ErrBudgetExhausted is returned once the month's token budget is spent. It
+// wraps breaker.ErrSkipped: the API was not called, so a breaker around the
+// client doesn't count it as a failure.
+var ErrBudgetExhausted = fmt.Errorf("monthly AI token budget exhausted: %w", breaker.ErrSkipped)
+
+// Config selects the provider, model and limits of an app's calls
+type Config struct {
+	Provider    string
+	BaseURL     string  // empty uses the provider's, see DefaultBaseURLs
+	Model       string  // empty uses the provider's, see DefaultModels
+	MaxTokens   int     // per response
+	Temperature float64 // 0 to 1
+	// Input plus output tokens allowed per calendar month (UTC); 0 is unlimited
+	MonthlyTokenBudget int64
+	// Dollars per million tokens, for the spend estimate
+	InputPrice  float64
+	OutputPrice float64
+}
+
+// Validate rejects settings the API would refuse
+func (c Config) Validate() error {
+	if _, ok := DefaultBaseURLs[c.Provider]; !ok {
+		return fmt.Errorf("llm_provider must be %q, %q or %q, got %q", ProviderClaude, ProviderOpenAI, ProviderOllama, c.Provider)
+	}
+	if c.MaxTokens < 1 {
+		return fmt.Errorf("llm_max_tokens must be at least 1, got %d", c.MaxTokens)
+	}
+	if c.Temperature < 0 || c.Temperature > 1 {
+		return fmt.Errorf("llm_temperature must be between 0 and 1, got %g", c.Temperature)
+	}
+	if c.MonthlyTokenBudget < 0 {
+		return fmt.Errorf("llm_monthly_token_budget must not be negative, got %d", c.MonthlyTokenBudget)
+	}
+	if c.InputPrice < 0 || c.OutputPrice < 0 {
+		return fmt.Errorf("llm_input_price and llm_output_price must not be negative")
+	}
+	return nil
+}
+
+// NeedsKey reports whether the provider can't be called without an API key:
+// Claude and OpenAI itself need one, a local Ollama or a self-hosted
+// OpenAI-compatible endpoint may not
+func (c Config) NeedsKey() bool {
+	return c.Provider == ProviderClaude || (c.Provider == ProviderOpenAI && c.BaseURL == "")
+}
+
+// Usage is the month's spend, served by the apps at /api/v1/llm/usage
+type Usage struct {
+	Month         string  `json:"month"` // e.g. 2026-10
+	Provider      string  `json:"provider"`
+	Model         string  `json:"model"`
+	Calls         int     `json:"calls"`
+	InputTokens   int64   `json:"input_tokens"`
+	OutputTokens  int64   `json:"output_tokens"`
+	Budget        int64   `json:"monthly_token_budget"` // 0 is unlimited
+	Rejected      int     `json:"rejected_calls"`       // refused because the budget was spent
+	EstimatedCost float64 `json:"estimated_cost_usd"`
+}
+
+// Tokens is the month's input plus output tokens
+func (u Usage) Tokens() int64 { return u.InputTokens + u.OutputTokens }
+
+// Call is one answered prompt
+type Call struct {
+	Timestamp time.Time `json:"timestamp"`
+	Prompt    string    `json:"prompt"`
+	Response  string    `json:"response"`
+}
+
+// maxCalls bounds the history kept for RecentCalls
+const maxCalls = 20
+
+// Client calls the configured provider. It is safe for concurrent use; a
+// nil Client reports no usage.
+type Client struct {
+	config   Config
+	provider provider
+	http     *http.Client
+	now      func() time.Time
+
+	mu    sync.Mutex
+	usage Usage
+	calls []Call
+}
+
+// New returns a client calling cfg's provider with apiKey, which may be
+// empty for providers that don't need one
+func New(apiKey string, cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURLs[cfg.Provider]
+	}
+	if cfg.Model == "" {
+		cfg.Model = DefaultModels[cfg.Provider]
+	}
+	base := strings.TrimSuffix(cfg.BaseURL, "/")
+	var p provider = openAIChat{url: base + "/chat/completions", apiKey: apiKey}
+	if cfg.Provider == ProviderClaude {
+		p = claudeMessages{url: base + "/messages", apiKey: apiKey}
+	}
+	return &Client{
+		config:   cfg,
+		provider: p,
+		http:     &http.Client{Timeout: 2 * time.Minute, Transport: tracing.WrapTransport(http.DefaultTransport)},
+		now:      time.Now,
+	}
+}
+
+// Complete answers a prompt
+func (c *Client) Complete(prompt string) (string, error) {
+	maxTokens, err := c.reserve()
+	if err != nil {
+		return "", err
+	}
+	answer, err := c.provider.complete(c.http, prompt, c.config.Model, maxTokens, c.config.Temperature)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", c.config.Provider, err)
+	}
+	c.record(prompt, answer.text, answer.inputTokens, answer.outputTokens)
+	slog.Debug("AI call", "provider", c.config.Provider, "model", c.config.Model, "input_tokens", answer.inputTokens,
+		"output_tokens", answer.outputTokens, "prompt", prompt, "response", answer.text)
+	return answer.text, nil
+}
+
+// AnalyzeJSON answers a prompt about data, which is appended as JSON
+func (c *Client) AnalyzeJSON(prompt string, data interface{}) (string, error) {
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode data: %w", err)
+	}
+	return c.Complete(prompt + "\n\n" + string(encoded))
+}
+
+// reserve checks the budget before a call and returns the response's token
+// limit, lowered to what is left of the budget
+func (c *Client) reserve() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollMonth()
+
+	budget := c.config.MonthlyTokenBudget
+	if budget == 0 {
+		return c.config.MaxTokens, nil
+	}
+	left := budget - c.usage.Tokens()
+	if left <= 0 {
+		if c.usage.Rejected == 0 {
+			slog.Warn("AI token budget exhausted, using fallbacks until the month ends",
+				"month", c.usage.Month, "budget", budget, "tokens", c.usage.Tokens())
+		}
+		c.usage.Rejected++
+		return 0, ErrBudgetExhausted
+	}
+	if left < int64(c.config.MaxTokens) {
+		return int(left), nil
+	}
+	return c.config.MaxTokens, nil
+}
+
+// record adds a call's tokens to the month's usage; c.mu must not be held
+func (c *Client) record(prompt, text string, input, output int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollMonth()
+	c.usage.Calls++
+	c.usage.InputTokens += input
+	c.usage.OutputTokens += output
+	c.usage.EstimatedCost += (float64(input)*c.config.InputPrice + float64(output)*c.config.OutputPrice) / 1e6
+
+	c.calls = append(c.calls, Call{Timestamp: c.now(), Prompt: prompt, Response: text})
+	if len(c.calls) > maxCalls {
+		c.calls = c.calls[len(c.calls)-maxCalls:]
+	}
+}
+
+// rollMonth starts a new count when the month changes; c.mu must be held
+func (c *Client) rollMonth() {
+	month := c.now().UTC().Format("2006-01")
+	if c.usage.Month != month {
+		c.usage = Usage{Month: month, Provider: c.config.Provider, Model: c.config.Model, Budget: c.config.MonthlyTokenBudget}
+	}
+}
+
+// Usage returns the month's spend
+func (c *Client) Usage() Usage {
+	if c == nil {
+		return Usage{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollMonth()
+	return c.usage
+}
+
+// RecentCalls returns the last answered prompts, oldest first
+func (c *Client) RecentCalls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// Operations document the LLM usage route of an app's API
+var Operations = []api.Operation{{Summary: "The month's LLM calls, tokens and estimated spend", Response: Usage{}}}
+
+// ServeHTTP serves the month's usage as JSON; without a client (no API key,
+// or claude_mode stub) the usage is empty
+func (c *Client) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c.Usage()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+// fakeAPI serves the Claude Messages and OpenAI chat completions APIs,
+// answering "ok" for 100 input and 50 output tokens, and records the requests
+func fakeAPI(t *testing.T, requests *[]request) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case r.URL.Path == "/v1/messages" && r.Header.Get("X-Api-Key") == "key" && r.Header.Get("Anthropic-Version") != "":
+			*requests = append(*requests, req)
+			w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":100,"output_tokens":50}}`))
+		case r.URL.Path == "/v1/chat/completions" && r.Header.Get("Authorization") != "Bearer wrong":
+			*requests = append(*requests, req)
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":100,"completion_tokens":50}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(key string, cfg Config, now *time.Time) *Client {
+	c := New(key, cfg)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestProviders(t *testing.T) {
+	var requests []request
+	server := fakeAPI(t, &requests)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	for _, provider := range []string{ProviderClaude, ProviderOpenAI, ProviderOllama} {
+		requests = nil
+		cfg := Config{Provider: provider, BaseURL: server.URL + "/v1/", MaxTokens: 1000, Temperature: 0.2, InputPrice: 1, OutputPrice: 5}
+		c := newTestClient("key", cfg, &now)
+
+		answer, err := c.AnalyzeJSON("Assess this", map[string]int{"replicas": 3})
+		if err != nil || answer != "ok" {
+			t.Fatalf("%s: AnalyzeJSON = %q, %v", provider, answer, err)
+		}
+		req := requests[0]
+		if req.Model != DefaultModels[provider] || req.MaxTokens != 1000 || req.Temperature != 0.2 ||
+			!strings.Contains(req.Messages[0].Content, `"replicas": 3`) {
+			t.Errorf("%s: unexpected request %+v", provider, req)
+		}
+
+		usage := c.Usage()
+		want := Usage{Month: "2026-10", Provider: provider, Model: DefaultModels[provider], Calls: 1, InputTokens: 100, OutputTokens: 50, EstimatedCost: 0.00035}
+		if usage != want {
+			t.Errorf("%s: usage = %+v, want %+v", provider, usage, want)
+		}
+		if calls := c.RecentCalls(); len(calls) != 1 || calls[0].Response != "ok" {
+			t.Errorf("%s: unexpected recent calls %+v", provider, calls)
+		}
+
+		if _, err := newTestClient("wrong", cfg, &now).Complete("hi"); err == nil || !strings.Contains(err.Error(), "invalid x-api-key") || !errkind.IsAuth(err) {
+			t.Errorf("%s: expected the API's auth error, got %v", provider, err)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	var requests []request
+	server := fakeAPI(t, &requests)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	c := newTestClient("key", Config{Provider: ProviderClaude, BaseURL: server.URL + "/v1", MaxTokens: 1000, MonthlyTokenBudget: 400}, &now)
+
+	// 150 tokens a call: later calls may only use what is left
+	for i := 0; i < 3; i++ {
+		if _, err := c.Complete("hi"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if requests[0].MaxTokens != 400 || requests[1].MaxTokens != 250 || requests[2].MaxTokens != 100 {
+		t.Errorf("Expected max_tokens lowered to the budget left, got %+v", requests)
+	}
+
+	b := breaker.New("claude", 1, time.Minute)
+	_, err := breaker.Call(b, func() (string, error) { return c.Complete("hi") })
+	if !errors.Is(err, ErrBudgetExhausted) || len(requests) != 3 {
+		t.Fatalf("Expected the spent budget to refuse the call, got %v after %d requests", err, len(requests))
+	}
+	if b.Status().State != breaker.Closed {
+		t.Errorf("A spent budget opened the breaker")
+	}
+	if usage := c.Usage(); usage.Rejected != 1 || usage.Tokens() != 450 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+
+	// A new month starts a new budget
+	now = now.AddDate(0, 1, 0)
+	if _, err := c.Complete("hi"); err != nil {
+		t.Errorf("Expected the budget to reset, got %v", err)
+	}
+	if usage := c.Usage(); usage.Month != "2026-11" || usage.Calls != 1 {
+		t.Errorf("Unexpected usage after the month ended %+v", usage)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	var c *Client
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/llm/usage", nil))
+	var usage Usage
+	if err := json.NewDecoder(rec.Body).Decode(&usage); err != nil || usage.Calls != 0 {
+		t.Errorf("Expected empty usage without a client, got %+v, %v", usage, err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	valid := Config{Provider: ProviderOllama, MaxTokens: DefaultMaxTokens}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	for name, cfg := range map[string]Config{
+		"provider":    {Provider: "bard", MaxTokens: 1},
+		"max tokens":  {Provider: ProviderClaude},
+		"temperature": {Provider: ProviderClaude, MaxTokens: 1, Temperature: 1.5},
+		"budget":      {Provider: ProviderClaude, MaxTokens: 1, MonthlyTokenBudget: -1},
+		"price":       {Provider: ProviderClaude, MaxTokens: 1, OutputPrice: -1},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if !(Config{Provider: ProviderClaude}).NeedsKey() || !(Config{Provider: ProviderOpenAI}).NeedsKey() ||
+		(Config{Provider: ProviderOpenAI, BaseURL: "http://vllm:8000/v1"}).NeedsKey() || (Config{Provider: ProviderOllama}).NeedsKey() {
+		t.Errorf("Unexpected NeedsKey")
+	}
+}
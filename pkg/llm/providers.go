@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+// provider speaks one API's wire format
+type provider interface {
+	complete(client *http.Client, prompt, model string, maxTokens int, temperature float64) (answer, error)
+}
+
+// answer is a provider's reply and the tokens it was charged
+type answer struct {
+	text         string
+	inputTokens  int64
+	outputTokens int64
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type request struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature"`
+	Messages    []message `json:"messages"`
+}
+
+// apiError is the error body of both APIs
+type apiError struct {
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// claudeMessages calls Anthropic's Messages API
+type claudeMessages struct {
+	url    string
+	apiKey string
+}
+
+const claudeAPIVersion = "2023-06-01"
+
+func (p claudeMessages) complete(client *http.Client, prompt, model string, maxTokens int, temperature float64) (answer, error) {
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	headers := map[string]string{"X-Api-Key": p.apiKey, "Anthropic-Version": claudeAPIVersion}
+	req := request{Model: model, MaxTokens: maxTokens, Temperature: temperature, Messages: []message{{Role: "user", Content: prompt}}}
+	if err := postJSON(client, "claude", p.url, headers, req, &resp); err != nil {
+		return answer{}, err
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return answer{text: text.String(), inputTokens: resp.Usage.InputTokens, outputTokens: resp.Usage.OutputTokens}, nil
+}
+
+// openAIChat calls an OpenAI-compatible chat completions endpoint, which
+// Ollama also serves under /v1
+type openAIChat struct {
+	url    string
+	apiKey string // optional for self-hosted endpoints
+}
+
+func (p openAIChat) complete(client *http.Client, prompt, model string, maxTokens int, temperature float64) (answer, error) {
+	var resp struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int64 `json:"prompt_tokens"`
+			CompletionTokens int64 `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	req := request{Model: model, MaxTokens: maxTokens, Temperature: temperature, Messages: []message{{Role: "user", Content: prompt}}}
+	if err := postJSON(client, "llm", p.url, headers, req, &resp); err != nil {
+		return answer{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return answer{}, fmt.Errorf("no choices in response")
+	}
+	return answer{text: resp.Choices[0].Message.Content, inputTokens: resp.Usage.PromptTokens, outputTokens: resp.Usage.CompletionTokens}, nil
+}
+
+// postJSON posts body to url and decodes a successful response into out.
+// Other responses are returned as *errkind.HTTPError of service.
+func postJSON(client *http.Client, service, url string, headers map[string]string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failed apiError
+		if json.Unmarshal(data, &failed) == nil && failed.Error != nil {
+			return errkind.FromResponse(service, resp, failed.Error.Message)
+		}
+		return errkind.FromResponse(service, resp, "")
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package logging sets up the structured logger shared by the DevOps apps.
+//
+// Each app calls Setup once at startup and then logs through log/slog:
+//
+//	logging.Setup("drift-detector")
+//	slog.Info("Drift detected", logging.Space(space), logging.Unit(unit), "items", n)
+//
+// Every record carries the app name. LOG_FORMAT selects "text" (default) or
+// "json" output and LOG_LEVEL one of debug, info (default), warn or error.
+// The standard log package is redirected to the same handler, so libraries
+// that still use it, the SDK included, share the format. When apps share
+// one process (see pkg/shared) the first Setup, devops-apps', keeps the
+// default.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/shared"
+)
+
+// Attribute keys shared by the apps, so log queries work across the suite
+const (
+	AppKey         = "app"
+	SpaceKey       = "space"
+	UnitKey        = "unit"
+	ClusterKey     = "cluster"
+	ErrorKey       = "error"
+	CorrelationKey = "correlation_id"
+)
+
+// Options configure a logger; the zero value logs text at info level
+type Options struct {
+	Format string // "text" or "json"
+	Level  string // "debug", "info", "warn" or "error"
+}
+
+// OptionsFromEnv reads LOG_FORMAT and LOG_LEVEL
+func OptionsFromEnv() Options {
+	return Options{Format: os.Getenv("LOG_FORMAT"), Level: os.Getenv("LOG_LEVEL")}
+}
+
+// New builds a logger for app writing to w
+func New(w io.Writer, app string, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (text or json)", opts.Format)
+	}
+	return slog.New(handler).With(AppKey, app), nil
+}
+
+// Setup makes the app's logger, configured from the environment, the
+// default for slog and the standard log package. An invalid LOG_FORMAT or
+// LOG_LEVEL is reported and replaced by the defaults rather than stopping
+// the app.
+func Setup(app string) *slog.Logger {
+	logger, err := New(os.Stderr, app, OptionsFromEnv())
+	if err != nil {
+		logger, _ = New(os.Stderr, app, Options{})
+		logger.Warn("Invalid logging configuration, using defaults", Err(err))
+	}
+	shared.Value("logging", func() bool {
+		slog.SetDefault(logger)
+		return true
+	})
+	return logger
+}
+
+// ParseLevel reads a level name; empty means info
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (debug, info, warn or error)", s)
+}
+
+// Space tags a record with a ConfigHub space
+func Space(name string) slog.Attr { return slog.String(SpaceKey, name) }
+
+// Unit tags a record with a ConfigHub unit
+func Unit(name string) slog.Attr { return slog.String(UnitKey, name) }
+
+// Cluster tags a record with a Kubernetes cluster or target
+func Cluster(name string) slog.Attr { return slog.String(ClusterKey, name) }
+
+// Correlation tags a record with the correlation ID of an incident, see
+// pkg/correlation
+func Correlation(id string) slog.Attr { return slog.String(CorrelationKey, id) }
+
+// Err records an error
+func Err(err error) slog.Attr { return slog.Any(ErrorKey, err) }
+
+// Fatal logs msg at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// StdLogger returns a standard library logger that writes through logger
+// at info level, for APIs that take a *log.Logger such as sdk.DevOpsApp
+func StdLogger(logger *slog.Logger) *log.Logger {
+	return slog.NewLogLogger(logger.Handler(), slog.LevelInfo)
+}
+
+// Printf adapts logger to Printf-style callbacks, logging at info level
+func Printf(logger *slog.Logger) func(format string, args ...interface{}) {
+	return func(format string, args ...interface{}) {
+		logger.Info(fmt.Sprintf(format, args...))
+	}
+}
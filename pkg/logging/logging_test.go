@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewJSONWithCommonFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "drift-detector", Options{Format: "json", Level: "debug"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	logger.Debug("Drift detected", Space("prod"), Unit("backend-api"), Cluster("eu-1"), Correlation("c-1"), Err(errors.New("boom")), "items", 2)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	want := map[string]interface{}{
+		"level": "DEBUG", "msg": "Drift detected", "app": "drift-detector",
+		"space": "prod", "unit": "backend-api", "cluster": "eu-1", "correlation_id": "c-1", "error": "boom", "items": float64(2),
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("%s = %v, want %v", k, record[k], v)
+		}
+	}
+}
+
+func TestLevelFiltersRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "cost-optimizer", Options{Level: "warn"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown", "savings", 42.5)
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("info record logged at warn level:\n%s", out)
+	}
+	if !strings.Contains(out, `level=WARN msg=shown app=cost-optimizer savings=42.5`) {
+		t.Errorf("unexpected text output:\n%s", out)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "app", Options{Format: "xml"}); err == nil {
+		t.Error("format xml accepted")
+	}
+	if _, err := New(&bytes.Buffer{}, "app", Options{Level: "verbose"}); err == nil {
+		t.Error("level verbose accepted")
+	}
+	if level, err := ParseLevel("WARNING"); err != nil || level != slog.LevelWarn {
+		t.Errorf("ParseLevel(WARNING) = %v, %v", level, err)
+	}
+}
+
+func TestStdLoggerAndPrintfShareHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, "cost-impact-monitor", Options{Format: "json"})
+
+	StdLogger(logger).Printf("from the SDK: %d spaces", 3)
+	Printf(logger)("config %s = %v", "leader_elect", true)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2:\n%s", len(lines), buf.String())
+	}
+	for i, want := range []string{"from the SDK: 3 spaces", "config leader_elect = true"} {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatalf("record %d is not JSON: %v", i, err)
+		}
+		if record["msg"] != want || record["app"] != "cost-impact-monitor" || record["level"] != "INFO" {
+			t.Errorf("record %d = %v", i, record)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+)
+
+// GrafanaOptions name the generated dashboard and its data source
+type GrafanaOptions struct {
+	Title      string // "DevOps apps" when empty
+	UID        string // "devops-apps" when empty; importing again replaces the dashboard
+	Datasource string // UID of the Prometheus data source selected by default; empty picks the default one
+}
+
+// panel is one graph or stat of the dashboard. Every series selector has
+// label matchers, which is how GrafanaMetrics finds the metric names.
+type panel struct {
+	title, description string
+	kind               string // timeseries or stat
+	unit               string
+	queries            []query
+}
+
+type query struct{ expr, legend string }
+
+// grafanaRows are the dashboard's rows. Metric names must be those the
+// registry and the apps' collectors serve; the tests of pkg/metrics,
+// drift-detector and cost-impact-monitor check them against GrafanaMetrics.
+var grafanaRows = []struct {
+	title  string
+	panels []panel
+}{
+	{"Cost", []panel{
+		{"Monthly cost", "Estimated monthly cost of the analyzed workloads, by space (cost-optimizer).", "timeseries", "currencyUSD", []query{
+			{`sum by (space) (cost_monthly_dollars{cluster=~"$cluster"})`, "{{space}}"},
+		}},
+		{"Potential savings", "Monthly savings of the open recommendations, by space (cost-optimizer).", "timeseries", "currencyUSD", []query{
+			{`sum by (space) (cost_potential_savings_dollars{cluster=~"$cluster"})`, "{{space}}"},
+		}},
+		{"Costliest workloads", "The ten workloads with the highest estimated monthly cost (cost-optimizer).", "timeseries", "currencyUSD", []query{
+			{`topk(10, sum by (namespace, kind, name) (cost_workload_monthly_dollars{cluster=~"$cluster"}))`, "{{namespace}}/{{name}}"},
+		}},
+		{"Open recommendations", "Recommendations not applied yet, by priority.", "stat", "none", []query{
+			{`sum by (priority) (cost_recommendations{cluster=~"$cluster"})`, "{{priority}}"},
+		}},
+		{"Current and projected cost", "Monthly cost of the spaces now and once their pending changes deploy (cost-impact-monitor).", "timeseries", "currencyUSD", []query{
+			{`sum(impact_monthly_cost_dollars{cluster=~"$cluster",space=~"$space"})`, "current"},
+			{`sum(impact_projected_monthly_cost_dollars{cluster=~"$cluster",space=~"$space"})`, "projected"},
+		}},
+		{"Pending changes", "Unit changes awaiting deployment, and those assessed as high or critical risk.", "timeseries", "none", []query{
+			{`sum(impact_pending_changes{cluster=~"$cluster",space=~"$space"})`, "pending"},
+			{`sum(impact_high_risk_changes{cluster=~"$cluster",space=~"$space"})`, "high risk"},
+		}},
+		{"AI spend this month", "Estimated spend on the AI provider, by app.", "stat", "currencyUSD", []query{
+			{`sum by (app) (llm_month_cost_dollars{cluster=~"$cluster"})`, "{{app}}"},
+		}},
+	}},
+	{"Drift", []panel{
+		{"Drifted fields", "Fields differing from their units at the last detection, by space.", "timeseries", "none", []query{
+			{`sum by (space) (drift_items{cluster=~"$cluster",space=~"$space"})`, "{{space}}"},
+		}},
+		{"Spaces with drift", "Spaces whose last detection found drift.", "stat", "none", []query{
+			{`sum(drift_detected{cluster=~"$cluster",space=~"$space"})`, "spaces"},
+		}},
+		{"Proposed fixes", "Fixes proposed for the drift found, by space.", "timeseries", "none", []query{
+			{`sum by (space) (drift_fixes_proposed{cluster=~"$cluster",space=~"$space"})`, "{{space}}"},
+		}},
+		{"Time since last detection", "Seconds since each space was last checked.", "stat", "s", []query{
+			{`time() - max by (space) (drift_last_check_timestamp_seconds{cluster=~"$cluster",space=~"$space"})`, "{{space}}"},
+		}},
+	}},
+	{"Prediction accuracy", []panel{
+		{"Accurate predictions", "Share of deployments whose actual cost was within tolerance of the prediction, by space.", "timeseries", "percentunit", []query{
+			{`avg by (space) (impact_prediction_accuracy_ratio{cluster=~"$cluster",space=~"$space"})`, "{{space}}"},
+		}},
+		{"Prediction error", "Mean absolute percentage error of the cost predictions, by space.", "timeseries", "percentunit", []query{
+			{`avg by (space) (impact_prediction_error_ratio{cluster=~"$cluster",space=~"$space"})`, "{{space}}"},
+		}},
+		{"Scored deployments", "Deployments whose prediction was compared with the actual cost.", "stat", "none", []query{
+			{`sum(impact_prediction_records{cluster=~"$cluster",space=~"$space"})`, "deployments"},
+		}},
+	}},
+	{"Operations", []panel{
+		{"Cycles", "Detection and analysis runs per minute, by app and result.", "timeseries", "none", []query{
+			{`sum by (app, result) (rate(devops_cycles_total{cluster=~"$cluster"}[$__rate_interval])) * 60`, "{{app}} {{result}}"},
+		}},
+		{"Cycle duration", "Mean duration of a run, by app.", "timeseries", "s", []query{
+			{`sum by (app) (rate(devops_cycle_duration_seconds_sum{cluster=~"$cluster"}[$__rate_interval])) / sum by (app) (rate(devops_cycle_duration_seconds_count{cluster=~"$cluster"}[$__rate_interval]))`, "{{app}}"},
+		}},
+		{"Errors", "Failed calls and cycles per minute, by source and kind.", "timeseries", "none", []query{
+			{`sum by (source, kind) (rate(devops_errors_total{cluster=~"$cluster"}[$__rate_interval])) * 60`, "{{source}} {{kind}}"},
+		}},
+		{"External call latency", "Mean latency of ConfigHub, AI and OpenCost calls, by service.", "timeseries", "s", []query{
+			{`sum by (service) (rate(devops_external_call_duration_seconds_sum{cluster=~"$cluster"}[$__rate_interval])) / sum by (service) (rate(devops_external_calls_total{cluster=~"$cluster"}[$__rate_interval]))`, "{{service}}"},
+		}},
+		{"ConfigHub requests", "Requests sent and delayed by the rate limiter, per second.", "timeseries", "reqps", []query{
+			{`sum(rate(confighub_requests_total{cluster=~"$cluster"}[$__rate_interval]))`, "sent"},
+			{`sum(rate(confighub_requests_throttled_total{cluster=~"$cluster"}[$__rate_interval]))`, "throttled"},
+		}},
+		{"Open circuit breakers", "Services whose calls fail fast.", "stat", "none", []query{
+			{`sum by (service) (circuit_breaker_open{cluster=~"$cluster"})`, "{{service}}"},
+		}},
+	}},
+}
+
+// Grafana returns the dashboard of the suite's metrics as JSON for Grafana's
+// import, with the cluster and space as variables
+func Grafana(opts GrafanaOptions) ([]byte, error) {
+	if opts.Title == "" {
+		opts.Title = "DevOps apps"
+	}
+	if opts.UID == "" {
+		opts.UID = "devops-apps"
+	}
+	datasource := map[string]string{"type": "prometheus", "uid": "${datasource}"}
+	var panels []map[string]interface{}
+	id, y := 0, 0
+	for _, row := range grafanaRows {
+		id++
+		panels = append(panels, map[string]interface{}{
+			"id": id, "type": "row", "title": row.title, "collapsed": false, "panels": []interface{}{},
+			"gridPos": map[string]int{"h": 1, "w": 24, "x": 0, "y": y},
+		})
+		y++
+		for i, p := range row.panels {
+			id++
+			targets := make([]map[string]interface{}, len(p.queries))
+			for j, q := range p.queries {
+				targets[j] = map[string]interface{}{
+					"refId": string(rune('A' + j)), "datasource": datasource, "expr": q.expr, "legendFormat": q.legend,
+				}
+			}
+			panels = append(panels, map[string]interface{}{
+				"id": id, "type": p.kind, "title": p.title, "description": p.description,
+				"datasource":  datasource,
+				"targets":     targets,
+				"fieldConfig": map[string]interface{}{"defaults": map[string]interface{}{"unit": p.unit}, "overrides": []interface{}{}},
+				"gridPos":     map[string]int{"h": 8, "w": 12, "x": 12 * (i % 2), "y": y + 8*(i/2)},
+			})
+		}
+		y += 8 * ((len(row.panels) + 1) / 2)
+	}
+
+	current := map[string]interface{}{}
+	if opts.Datasource != "" {
+		current = map[string]interface{}{"value": opts.Datasource}
+	}
+	variable := func(name, query string) map[string]interface{} {
+		return map[string]interface{}{
+			"name": name, "type": "query", "datasource": datasource, "query": query, "refresh": 2,
+			"includeAll": true, "multi": true, "allValue": ".*", "current": map[string]interface{}{},
+		}
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"uid": opts.UID, "title": opts.Title, "tags": []string{"devops-apps"},
+		"schemaVersion": 39, "editable": true, "refresh": "1m",
+		"time":   map[string]string{"from": "now-24h", "to": "now"},
+		"panels": panels,
+		"templating": map[string]interface{}{"list": []map[string]interface{}{
+			{"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus", "current": current},
+			variable("cluster", "label_values(devops_app_info, cluster)"),
+			variable("space", `label_values(devops_cycles_total{cluster=~"$cluster"}, space)`),
+		}},
+	}, "", "  ")
+}
+
+// selector matches the metric name of a series selector
+var selector = regexp.MustCompile(`([a-zA-Z_:][a-zA-Z0-9_:]*)\{`)
+
+// GrafanaMetrics returns the metric names the dashboard queries, sorted
+func GrafanaMetrics() []string {
+	seen := map[string]bool{}
+	for _, row := range grafanaRows {
+		for _, p := range row.panels {
+			for _, q := range p.queries {
+				for _, m := range selector.FindAllStringSubmatch(q.expr, -1) {
+					seen[m[1]] = true
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
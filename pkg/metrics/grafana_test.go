@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+)
+
+func TestGrafana(t *testing.T) {
+	data, err := Grafana(GrafanaOptions{Datasource: "prom-eu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dashboard struct {
+		UID    string
+		Title  string
+		Panels []struct {
+			ID      int
+			Type    string
+			Targets []struct{ Expr string }
+		}
+		Templating struct {
+			List []struct {
+				Name    string
+				Current map[string]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatalf("Grafana returned invalid JSON: %v", err)
+	}
+	if dashboard.UID != "devops-apps" || dashboard.Title != "DevOps apps" {
+		t.Errorf("UID, Title = %q, %q", dashboard.UID, dashboard.Title)
+	}
+	if v := dashboard.Templating.List[0]; v.Name != "datasource" || v.Current["value"] != "prom-eu" {
+		t.Errorf("Data source variable = %+v", v)
+	}
+	ids := map[int]bool{}
+	for _, p := range dashboard.Panels {
+		if ids[p.ID] {
+			t.Errorf("Panel ID %d used twice", p.ID)
+		}
+		ids[p.ID] = true
+		if p.Type != "row" && len(p.Targets) == 0 {
+			t.Errorf("Panel %d has no queries", p.ID)
+		}
+	}
+}
+
+// TestGrafanaMetrics checks the dashboard's common metrics against what a
+// registry serves; the apps' tests check their own prefixes
+func TestGrafanaMetrics(t *testing.T) {
+	reg := New("cost-optimizer", "2.0.0", "prod-eu")
+	reg.Cycle("optimize", "payments")(nil)
+	reg.Call("confighub", "ListUnits", time.Second, nil)
+	reg.Error("confighub", errors.New("timeout"))
+	limiter := ratelimit.New(0, 1)
+	ratelimit.Call(context.Background(), limiter, "ListUnits", "", func() (int, error) { return 1, nil })
+	reg.Collect(Limiter(limiter))
+	reg.Collect(Breakers(breaker.New("confighub", 3, time.Minute)))
+	reg.Collect(LLM(llm.New("key", llm.Config{Provider: llm.ProviderOllama, Model: "llama3.1"})))
+	body := scrape(t, reg)
+
+	names := GrafanaMetrics()
+	if len(names) < 20 {
+		t.Fatalf("GrafanaMetrics = %v, want every queried metric", names)
+	}
+	for _, name := range names {
+		switch {
+		case strings.HasPrefix(name, "drift_"), strings.HasPrefix(name, "cost_"), strings.HasPrefix(name, "impact_"):
+		case !strings.Contains(body, "\n"+name+"{"):
+			t.Errorf("The dashboard queries %s, which the registry doesn't serve", name)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+// Package metrics gives every app the same /metrics, so one Grafana dashboard
+// covers the suite. Each sample carries the labels app, version and cluster;
+// cycle metrics add the space. Common series are:
+//
+//	devops_app_info                          1 per app, for joins
+//	devops_external_calls_total              ConfigHub, Claude and OpenCost calls by operation and result
+//	devops_external_call_duration_seconds    their latency (summary)
+//	devops_cycles_total                      detection and analysis runs by space and result
+//	devops_cycle_duration_seconds            their duration (summary)
+//	devops_errors_total                      failed calls and cycles, by source and kind (pkg/errkind)
+//	confighub_requests_*                     the rate limiter's counters
+//	confighub_list_*                         listings fetched and answered 304 from pkg/listcache, by space
+//	circuit_breaker_open                     1 while a breaker fails calls fast
+//	llm_month_*                              this month's AI tokens, budget and estimated spend
+//
+// External calls are counted from the spans of pkg/tracing, so any call
+// wrapped in tracing.Call or tracing.Do is measured whether or not traces are
+// exported. The registry is mounted on http.DefaultServeMux, which the SDK's
+// health server serves, so /metrics sits on each app's health port. Apps
+// sharing one process (see pkg/shared) share one registry, labelled
+// app="devops-apps".
+//
+// For SaaS-only observability stacks a Pusher also pushes the registry to
+// Datadog or New Relic every interval, with extra tags, configured by a
+// sinks file (see LoadSinks). Counters and summaries are pushed as their
+// increase since the previous push.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/shared"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Services whose spans count as external calls, by span name prefix
+var services = map[string]bool{"confighub": true, "claude": true, "opencost": true}
+
+// Labels of one sample
+type Labels map[string]string
+
+// Emit writes one sample of a metric family
+type Emit func(name, help, kind string, labels Labels, value float64)
+
+// Collector reports samples computed at scrape time, such as the rate
+// limiter's counters
+type Collector func(emit Emit)
+
+// Registry holds an app's metrics. It is safe for concurrent use; a nil
+// Registry records nothing.
+type Registry struct {
+	common Labels
+
+	mu         sync.Mutex
+	families   map[string]*family
+	collectors []Collector
+}
+
+type family struct {
+	help, kind string
+	samples    map[string]*sample // by rendered labels
+}
+
+type sample struct {
+	labels Labels
+	value  float64
+	count  float64 // summaries only
+}
+
+// New returns the registry of app at version running in cluster
+func New(app, version, cluster string) *Registry {
+	return &Registry{
+		common:   Labels{"app": app, "version": version, "cluster": cluster},
+		families: map[string]*family{},
+	}
+}
+
+// Setup returns the registry of an app, counts its traced external calls
+// and mounts it at /metrics on http.DefaultServeMux
+func Setup(app, version, cluster string) *Registry {
+	return shared.Value("metrics", func() *Registry {
+		if shared.Enabled() {
+			app = shared.App
+		}
+		r := New(app, version, cluster)
+		tracing.Observe(r.observeSpan)
+		http.Handle("/metrics", r)
+		return r
+	})
+}
+
+// Profile mounts net/http/pprof's handlers under /debug/pprof/ on mux. The
+// apps call it for http.DefaultServeMux only when their pprof setting is on:
+// profiles expose the process's internals and a CPU profile costs a slice
+// of a core while it runs.
+func Profile(mux *http.ServeMux) {
+	if _, mounted := profiled.LoadOrStore(mux, true); mounted {
+		return
+	}
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// profiled holds the muxes Profile mounted on, as mounting twice panics
+var profiled sync.Map
+
+// Add adds delta to a counter
+func (r *Registry) Add(name, help string, labels Labels, delta float64) {
+	r.update(name, help, "counter", labels, func(s *sample) { s.value += delta })
+}
+
+// Observe adds one observation to a summary, exported as name_sum and name_count
+func (r *Registry) Observe(name, help string, labels Labels, value float64) {
+	r.update(name, help, "summary", labels, func(s *sample) {
+		s.value += value
+		s.count++
+	})
+}
+
+func (r *Registry) update(name, help, kind string, labels Labels, apply func(*sample)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{help: help, kind: kind, samples: map[string]*sample{}}
+		r.families[name] = f
+	}
+	key := render(labels)
+	s, ok := f.samples[key]
+	if !ok {
+		s = &sample{labels: labels}
+		f.samples[key] = s
+	}
+	apply(s)
+}
+
+// Collect adds a collector run on every scrape
+func (r *Registry) Collect(c Collector) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Call counts one call to an external service
+func (r *Registry) Call(service, operation string, elapsed time.Duration, err error) {
+	r.Add("devops_external_calls_total", "Calls to ConfigHub, Claude and OpenCost.",
+		Labels{"service": service, "operation": operation, "result": result(err)}, 1)
+	r.Observe("devops_external_call_duration_seconds", "Latency of calls to ConfigHub, Claude and OpenCost.",
+		Labels{"service": service, "operation": operation}, elapsed.Seconds())
+	if err != nil {
+		r.Error(service, err)
+	}
+}
+
+// Cycle starts timing one run of an app's periodic work in space (empty for
+// all of the app's spaces); call the returned function with the run's error
+func (r *Registry) Cycle(cycle, space string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		r.Add("devops_cycles_total", "Detection and analysis runs.",
+			Labels{"cycle": cycle, "space": space, "result": result(err)}, 1)
+		r.Observe("devops_cycle_duration_seconds", "Duration of detection and analysis runs.",
+			Labels{"cycle": cycle, "space": space}, time.Since(start).Seconds())
+		if err != nil {
+			r.Error(cycle, err)
+		}
+	}
+}
+
+// Error counts a failure in source: a service, a cycle or another part of
+// the app. Its kind tells alerts that can wait for retries (retryable) from
+// those that need a person (auth, permanent).
+func (r *Registry) Error(source string, err error) {
+	r.Add("devops_errors_total", "Failed calls, cycles and other errors.", Labels{"source": source, "kind": string(errkind.Of(err))}, 1)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// observeSpan counts the spans of external calls, named "<service>.<operation>"
+func (r *Registry) observeSpan(name string, attrs []attribute.KeyValue, elapsed time.Duration, err error) {
+	service, operation, found := strings.Cut(name, ".")
+	if found && services[service] {
+		r.Call(service, operation, elapsed, err)
+	}
+}
+
+// Limiter exports a rate limiter's counters
+func Limiter(l *ratelimit.Limiter) Collector {
+	return func(emit Emit) {
+		for call, s := range l.Stats() {
+			labels := Labels{"call": call}
+			emit("confighub_requests_total", "ConfigHub requests sent.", "counter", labels, float64(s.Requests))
+			emit("confighub_requests_throttled_total", "ConfigHub requests delayed by the rate limiter.", "counter", labels, float64(s.Throttled))
+			emit("confighub_requests_coalesced_total", "ConfigHub reads answered by a concurrent identical request.", "counter", labels, float64(s.Coalesced))
+			emit("confighub_requests_retried_total", "ConfigHub reads sent again after a retryable error.", "counter", labels, float64(s.Retried))
+			emit("confighub_throttle_wait_seconds_total", "Time spent waiting for the rate limiter.", "counter", labels, s.Wait.Seconds())
+		}
+	}
+}
+
+// ListCache exports how many ConfigHub listings each space cost and how
+// many of them were unchanged
+func ListCache(t *listcache.Transport) Collector {
+	return func(emit Emit) {
+		for space, s := range t.Stats() {
+			labels := Labels{"space": space}
+			emit("confighub_list_requests_total", "ConfigHub listings fetched.", "counter", labels, float64(s.Requests))
+			emit("confighub_list_not_modified_total", "ConfigHub listings answered 304 Not Modified from the cache.", "counter", labels, float64(s.NotModified))
+			emit("confighub_list_bytes_saved_total", "Listing bytes not transferred thanks to 304s.", "counter", labels, float64(s.BytesSaved))
+		}
+	}
+}
+
+// Breakers exports whether each breaker is failing calls fast
+func Breakers(breakers ...*breaker.Breaker) Collector {
+	return func(emit Emit) {
+		for _, b := range breakers {
+			if b == nil {
+				continue
+			}
+			status := b.Status()
+			open := 0.0
+			if status.State != breaker.Closed {
+				open = 1
+			}
+			emit("circuit_breaker_open", "1 while a circuit breaker fails calls fast or probes.", "gauge", Labels{"service": status.Service}, open)
+		}
+	}
+}
+
+// LLM exports the month's AI usage of c; a nil client exports nothing
+func LLM(c *llm.Client) Collector {
+	return func(emit Emit) {
+		if c == nil {
+			return
+		}
+		usage := c.Usage()
+		labels := Labels{"provider": usage.Provider, "model": usage.Model}
+		emit("llm_month_input_tokens", "AI input tokens used this month.", "gauge", labels, float64(usage.InputTokens))
+		emit("llm_month_output_tokens", "AI output tokens used this month.", "gauge", labels, float64(usage.OutputTokens))
+		emit("llm_month_token_budget", "AI tokens allowed per month, 0 for no limit.", "gauge", labels, float64(usage.Budget))
+		emit("llm_month_rejected_calls", "AI calls refused this month because the budget was spent.", "gauge", labels, float64(usage.Rejected))
+		emit("llm_month_cost_dollars", "Estimated AI spend this month.", "gauge", labels, usage.EstimatedCost)
+	}
+}
+
+// Point is one exported sample
+type Point struct {
+	Family string // the metric; summaries export Name Family_sum and Family_count
+	Name   string
+	Help   string
+	Kind   string // counter, gauge or summary
+	Labels Labels // the sample's labels with app, version and cluster
+	Value  float64
+}
+
+// Points returns every sample, the recorded ones and the collectors', by
+// family, then labels, with a summary's _sum before its _count
+func (r *Registry) Points() []Point {
+	if r == nil {
+		return nil
+	}
+	var points []Point
+	seen := map[string]bool{}
+	add := func(family, help, kind, suffix string, labels Labels, value float64) {
+		all := r.all(labels)
+		// Apps sharing a registry may collect the same limiter or breaker
+		key := family + suffix + render(all)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		points = append(points, Point{Family: family, Name: family + suffix, Help: help, Kind: kind, Labels: all, Value: value})
+	}
+
+	add("devops_app_info", "The app, its version and cluster.", "gauge", "", nil, 1)
+
+	r.mu.Lock()
+	for name, f := range r.families {
+		for _, s := range f.samples {
+			if f.kind == "summary" {
+				add(name, f.help, f.kind, "_sum", s.labels, s.value)
+				add(name, f.help, f.kind, "_count", s.labels, s.count)
+				continue
+			}
+			add(name, f.help, f.kind, "", s.labels, s.value)
+		}
+	}
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c(func(name, help, kind string, labels Labels, value float64) {
+			add(name, help, kind, "", labels, value)
+		})
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		if a.Family != b.Family {
+			return a.Family < b.Family
+		}
+		if la, lb := render(a.Labels), render(b.Labels); la != lb {
+			return la < lb
+		}
+		return a.Name > b.Name // _sum before _count
+	})
+	return points
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r == nil {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	family := ""
+	for _, p := range r.Points() {
+		if p.Family != family {
+			family = p.Family
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", p.Family, p.Help, p.Family, p.Kind)
+		}
+		fmt.Fprintf(w, "%s%s %g\n", p.Name, render(p.Labels), p.Value)
+	}
+}
+
+// all returns the common labels plus a sample's
+func (r *Registry) all(extra Labels) Labels {
+	all := Labels{}
+	for k, v := range r.common {
+		all[k] = v
+	}
+	for k, v := range extra {
+		all[k] = v
+	}
+	return all
+}
+
+// render writes labels as {k="v",...} in key order
+func render(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/llm"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/tracing"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestRegistry(t *testing.T) {
+	reg := New("drift-detector", "1.2.0", "prod-eu")
+	common := `app="drift-detector",cluster="prod-eu"`
+
+	done := reg.Cycle("detect", "payments-prod")
+	done(nil)
+	reg.Cycle("detect", "payments-prod")(errors.New("list units: timeout"))
+	reg.Call("claude", "Analyze", 2*time.Second, nil)
+
+	limiter := ratelimit.New(0, 1)
+	if _, err := ratelimit.Call(context.Background(), limiter, "ListUnits", "", func() (int, error) { return 1, nil }); err != nil {
+		t.Fatal(err)
+	}
+	opencost := breaker.New("opencost", 1, time.Minute)
+	opencost.Record(errors.New("connection refused"))
+	reg.Collect(Limiter(limiter))
+	reg.Collect(Limiter(limiter)) // as apps sharing a registry and a limiter do
+	reg.Collect(ListCache(listcache.New(nil, "http://confighub")))
+	reg.Collect(ListCache(nil))
+	reg.Collect(Breakers(breaker.New("confighub", 3, time.Minute), opencost, nil))
+	reg.Collect(LLM(llm.New("key", llm.Config{Provider: llm.ProviderOllama, Model: "llama3.1", MonthlyTokenBudget: 1000})))
+	reg.Collect(LLM(nil))
+
+	body := scrape(t, reg)
+	for _, want := range []string{
+		`devops_app_info{` + common + `,version="1.2.0"} 1`,
+		`devops_cycles_total{` + common + `,cycle="detect",result="ok",space="payments-prod",version="1.2.0"} 1`,
+		`devops_cycles_total{` + common + `,cycle="detect",result="error",space="payments-prod",version="1.2.0"} 1`,
+		`devops_cycle_duration_seconds_count{` + common + `,cycle="detect",space="payments-prod",version="1.2.0"} 2`,
+		`devops_external_call_duration_seconds_sum{` + common + `,operation="Analyze",service="claude",version="1.2.0"} 2`,
+		`devops_errors_total{` + common + `,kind="retryable",source="detect",version="1.2.0"} 1`,
+		`confighub_requests_total{app="drift-detector",call="ListUnits",cluster="prod-eu",version="1.2.0"} 1`,
+		`circuit_breaker_open{` + common + `,service="confighub",version="1.2.0"} 0`,
+		`circuit_breaker_open{` + common + `,service="opencost",version="1.2.0"} 1`,
+		`llm_month_token_budget{` + common + `,model="llama3.1",provider="ollama",version="1.2.0"} 1000`,
+		"# TYPE devops_cycle_duration_seconds summary",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %s in:\n%s", want, body)
+		}
+	}
+	if strings.Count(body, `confighub_requests_total{app="drift-detector",call="ListUnits"`) != 1 {
+		t.Errorf("Expected a sample collected twice once:\n%s", body)
+	}
+	if strings.Count(body, "# TYPE circuit_breaker_open gauge") != 1 {
+		t.Errorf("Expected one TYPE line per family:\n%s", body)
+	}
+
+	var nilReg *Registry
+	nilReg.Cycle("detect", "")(errors.New("ignored"))
+	nilReg.Error("confighub", errors.New("ignored"))
+}
+
+func TestSpans(t *testing.T) {
+	reg := New("cost-optimizer", "dev", "")
+	tracing.Observe(reg.observeSpan)
+	defer tracing.Observe(nil)
+
+	ctx := context.Background()
+	tracing.Call(ctx, "confighub.ListUnits", func() (int, error) { return 0, nil })
+	tracing.Do(ctx, "opencost.Allocation", func(context.Context) error { return errors.New("503") })
+	tracing.Do(ctx, "k8s.ListPodMetrics", func(context.Context) error { return nil })
+
+	body := scrape(t, reg)
+	for _, want := range []string{
+		`devops_external_calls_total{app="cost-optimizer",cluster="",operation="ListUnits",result="ok",service="confighub",version="dev"} 1`,
+		`devops_external_calls_total{app="cost-optimizer",cluster="",operation="Allocation",result="error",service="opencost",version="dev"} 1`,
+		`devops_errors_total{app="cost-optimizer",cluster="",kind="retryable",source="opencost",version="dev"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %s in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "k8s") {
+		t.Errorf("Expected Kubernetes calls not to count as external:\n%s", body)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	mux := http.NewServeMux()
+	Profile(mux)
+	for path, want := range map[string]string{
+		"/debug/pprof/":             "goroutine",
+		"/debug/pprof/heap?debug=1": "heap profile",
+		"/debug/pprof/cmdline":      "metrics.test",
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: expected 200 with %q, got %d", path, want, rec.Code)
+		}
+	}
+}
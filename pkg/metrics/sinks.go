@@ -0,0 +1,380 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/errkind"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPushInterval is how often metrics are pushed when the sinks file
+// sets no interval
+const DefaultPushInterval = time.Minute
+
+// SinksConfig is the metrics sinks file: SaaS backends the registry is
+// pushed to besides being served at /metrics.
+//
+//	interval: 1m
+//	tags: {env: prod, team: platform}
+//	metrics: [drift_, cost_, impact_, devops_cycles]
+//	datadog:
+//	  api_key: ${DD_API_KEY}
+//	  site: datadoghq.eu
+//	newrelic:
+//	  license_key: ${NEW_RELIC_LICENSE_KEY}
+//	  region: eu
+type SinksConfig struct {
+	Interval time.Duration     `yaml:"interval"`
+	Tags     map[string]string `yaml:"tags"`    // added to every point
+	Metrics  []string          `yaml:"metrics"` // name prefixes pushed; empty pushes every metric
+	Datadog  *DatadogConfig    `yaml:"datadog"`
+	NewRelic *NewRelicConfig   `yaml:"newrelic"`
+}
+
+// DatadogConfig selects the Datadog site metrics are submitted to
+type DatadogConfig struct {
+	APIKey string `yaml:"api_key"`
+	Site   string `yaml:"site"` // datadoghq.com when empty
+	URL    string `yaml:"url"`  // replaces the site's series endpoint, e.g. for a proxy
+}
+
+// NewRelicConfig selects the New Relic region metrics are submitted to
+type NewRelicConfig struct {
+	LicenseKey string `yaml:"license_key"`
+	Region     string `yaml:"region"` // us when empty, or eu
+	URL        string `yaml:"url"`    // replaces the region's Metric API endpoint
+}
+
+// Pusher pushes a registry to the configured sinks every interval. A nil
+// Pusher pushes nothing.
+type Pusher struct {
+	registry *Registry
+	config   SinksConfig
+	sinks    []sink
+	client   *http.Client
+
+	mu   sync.Mutex
+	last map[string]float64 // cumulative values at the last push, by point
+}
+
+// sink submits one push's points to a backend
+type sink interface {
+	name() string
+	send(ctx context.Context, client *http.Client, points []pushed, at time.Time, interval time.Duration) error
+}
+
+// pushed is a point as sent: counters and summary parts carry the increase
+// since the previous push
+type pushed struct {
+	name  string
+	count bool
+	tags  Labels
+	value float64
+}
+
+// LoadSinks reads the sinks file at path, expanding ${VAR}s and looking up
+// the secret files datadog-api-key and newrelic-license-key with secret
+// (see config.Effective.ReadSecretFile), and returns a Pusher of r. A
+// missing file returns a nil Pusher.
+func LoadSinks(path string, secret func(name string) (string, error), r *Registry) (*Pusher, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read metrics sinks config: %w", err)
+	}
+	var cfg SinksConfig
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &cfg); err != nil {
+		return nil, fmt.Errorf("parse metrics sinks config: %w", err)
+	}
+	for _, s := range []struct {
+		file  string
+		value *string
+	}{{"datadog-api-key", apiKey(&cfg.Datadog)}, {"newrelic-license-key", licenseKey(&cfg.NewRelic)}} {
+		value, err := secret(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("metrics sinks config: %w", err)
+		}
+		if value != "" {
+			*s.value = value
+		}
+	}
+	return NewPusher(r, cfg)
+}
+
+// apiKey returns where the Datadog key goes, so a secret file alone enables
+// the sink
+func apiKey(c **DatadogConfig) *string {
+	if *c == nil {
+		*c = &DatadogConfig{}
+	}
+	return &(*c).APIKey
+}
+
+func licenseKey(c **NewRelicConfig) *string {
+	if *c == nil {
+		*c = &NewRelicConfig{}
+	}
+	return &(*c).LicenseKey
+}
+
+// NewPusher validates cfg and returns a Pusher of r to its sinks; a sink
+// without a key is left out
+func NewPusher(r *Registry, cfg SinksConfig) (*Pusher, error) {
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultPushInterval
+	}
+	if cfg.Interval < 10*time.Second {
+		return nil, fmt.Errorf("metrics sinks config: interval %s is below 10s", cfg.Interval)
+	}
+	p := &Pusher{registry: r, config: cfg, client: &http.Client{Timeout: 30 * time.Second}, last: map[string]float64{}}
+	if d := cfg.Datadog; d != nil && d.APIKey != "" {
+		url := d.URL
+		if url == "" {
+			site := d.Site
+			if site == "" {
+				site = "datadoghq.com"
+			}
+			url = "https://api." + site + "/api/v2/series"
+		}
+		p.sinks = append(p.sinks, datadog{url: url, key: d.APIKey})
+	}
+	if n := cfg.NewRelic; n != nil && n.LicenseKey != "" {
+		url := n.URL
+		switch {
+		case url != "":
+		case n.Region == "" || strings.EqualFold(n.Region, "us"):
+			url = "https://metric-api.newrelic.com/metric/v1"
+		case strings.EqualFold(n.Region, "eu"):
+			url = "https://metric-api.eu.newrelic.com/metric/v1"
+		default:
+			return nil, fmt.Errorf("metrics sinks config: newrelic region must be us or eu, not %q", n.Region)
+		}
+		p.sinks = append(p.sinks, newRelic{url: url, key: n.LicenseKey})
+	}
+	if len(p.sinks) == 0 {
+		return nil, errors.New("metrics sinks config: no sink has a key")
+	}
+	return p, nil
+}
+
+// Sinks names the backends pushed to
+func (p *Pusher) Sinks() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, len(p.sinks))
+	for i, s := range p.sinks {
+		names[i] = s.name()
+	}
+	return names
+}
+
+// Run pushes every interval until ctx is done, then once more so the last
+// cycle isn't lost. Only one Pusher of a registry runs at a time, so apps
+// sharing a registry (see pkg/shared) push it once.
+func (p *Pusher) Run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	if _, busy := pushing.LoadOrStore(p.registry, p); busy {
+		return
+	}
+	defer pushing.Delete(p.registry)
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	p.Push(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			flush, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			p.Push(flush)
+			cancel()
+			return
+		case <-ticker.C:
+			p.Push(ctx)
+		}
+	}
+}
+
+// pushing holds the registries a Pusher runs for
+var pushing sync.Map
+
+// Push sends the registry's points to every sink once. Failures are logged
+// and counted in devops_errors_total by sink; the next push still sends
+// only what changed since this one.
+func (p *Pusher) Push(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	points := p.points()
+	if len(points) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, s := range p.sinks {
+		if err := s.send(ctx, p.client, points, now, p.config.Interval); err != nil {
+			slog.Error("Failed to push metrics", "sink", s.name(), logging.Err(err))
+			p.registry.Error(s.name(), err)
+		}
+	}
+}
+
+// points returns what to push: the wanted points with the configured tags,
+// counters as their increase since the previous push. A counter's first
+// push only records its value, as its increase is unknown.
+func (p *Pusher) points() []pushed {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []pushed
+	for _, pt := range p.registry.Points() {
+		if !p.wanted(pt.Name) {
+			continue
+		}
+		tags := Labels{}
+		for k, v := range pt.Labels {
+			tags[k] = v
+		}
+		for k, v := range p.config.Tags {
+			tags[k] = v
+		}
+		if pt.Kind == "gauge" {
+			out = append(out, pushed{name: pt.Name, tags: tags, value: pt.Value})
+			continue
+		}
+		key := pt.Name + render(pt.Labels)
+		last, seen := p.last[key]
+		p.last[key] = pt.Value
+		if !seen {
+			continue
+		}
+		delta := pt.Value - last
+		if delta < 0 { // the counter was reset
+			delta = pt.Value
+		}
+		out = append(out, pushed{name: pt.Name, count: true, tags: tags, value: delta})
+	}
+	return out
+}
+
+func (p *Pusher) wanted(name string) bool {
+	if len(p.config.Metrics) == 0 {
+		return true
+	}
+	for _, prefix := range p.config.Metrics {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// datadog submits to the v2 series API
+type datadog struct{ url, key string }
+
+func (datadog) name() string { return "datadog" }
+
+func (d datadog) send(ctx context.Context, client *http.Client, points []pushed, at time.Time, interval time.Duration) error {
+	type point struct {
+		Timestamp int64   `json:"timestamp"`
+		Value     float64 `json:"value"`
+	}
+	type series struct {
+		Metric   string   `json:"metric"`
+		Type     int      `json:"type"` // 1 count, 3 gauge
+		Interval int64    `json:"interval,omitempty"`
+		Points   []point  `json:"points"`
+		Tags     []string `json:"tags"`
+	}
+	body := struct {
+		Series []series `json:"series"`
+	}{}
+	for _, pt := range points {
+		s := series{Metric: pt.name, Type: 3, Points: []point{{at.Unix(), pt.value}}, Tags: tagList(pt.tags)}
+		if pt.count {
+			s.Type, s.Interval = 1, int64(interval.Seconds())
+		}
+		body.Series = append(body.Series, s)
+	}
+	return post(ctx, client, "datadog", d.url, map[string]string{"DD-API-KEY": d.key}, body)
+}
+
+// newRelic submits to the Metric API
+type newRelic struct{ url, key string }
+
+func (newRelic) name() string { return "newrelic" }
+
+func (n newRelic) send(ctx context.Context, client *http.Client, points []pushed, at time.Time, interval time.Duration) error {
+	type metric struct {
+		Name       string            `json:"name"`
+		Type       string            `json:"type"`
+		Value      float64           `json:"value"`
+		Attributes map[string]string `json:"attributes"`
+	}
+	type batch struct {
+		Common struct {
+			Timestamp  int64 `json:"timestamp"`
+			IntervalMs int64 `json:"interval.ms"`
+		} `json:"common"`
+		Metrics []metric `json:"metrics"`
+	}
+	var b batch
+	b.Common.Timestamp, b.Common.IntervalMs = at.UnixMilli(), interval.Milliseconds()
+	for _, pt := range points {
+		kind := "gauge"
+		if pt.count {
+			kind = "count"
+		}
+		b.Metrics = append(b.Metrics, metric{Name: pt.name, Type: kind, Value: pt.value, Attributes: pt.tags})
+	}
+	return post(ctx, client, "newrelic", n.url, map[string]string{"Api-Key": n.key}, []batch{b})
+}
+
+// tagList renders labels as Datadog's key:value tags, in key order
+func tagList(labels Labels) []string {
+	tags := make([]string, 0, len(labels))
+	for k, v := range labels {
+		tags = append(tags, k+":"+v)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func post(ctx context.Context, client *http.Client, service, url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errkind.FromResponse(service, resp, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// backend records the bodies and key header posted to it
+type backend struct {
+	*httptest.Server
+	header string
+	keys   []string
+	bodies []string
+}
+
+func newBackend(t *testing.T, header string) *backend {
+	b := &backend{header: header}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		b.keys = append(b.keys, r.Header.Get(b.header))
+		b.bodies = append(b.bodies, string(data))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func TestPush(t *testing.T) {
+	dd, nr := newBackend(t, "DD-API-KEY"), newBackend(t, "Api-Key")
+	reg := New("drift-detector", "1.2.0", "prod-eu")
+	drifted := 3.0
+	reg.Collect(func(emit Emit) {
+		emit("drift_units", "Drifted units.", "gauge", Labels{"space": "payments"}, drifted)
+	})
+	reg.Cycle("detect", "payments")(nil)
+
+	p, err := NewPusher(reg, SinksConfig{
+		Tags:     map[string]string{"env": "prod"},
+		Metrics:  []string{"drift_", "devops_cycles_total"},
+		Datadog:  &DatadogConfig{APIKey: "dd-key", URL: dd.URL},
+		NewRelic: &NewRelicConfig{LicenseKey: "nr-key", URL: nr.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(p.Sinks(), ","); got != "datadog,newrelic" {
+		t.Errorf("Sinks = %s", got)
+	}
+
+	ctx := context.Background()
+	p.Push(ctx)
+	reg.Cycle("detect", "payments")(nil)
+	reg.Cycle("detect", "payments")(nil)
+	drifted = 1
+	p.Push(ctx)
+
+	if len(dd.bodies) != 2 || dd.keys[0] != "dd-key" || len(nr.bodies) != 2 || nr.keys[0] != "nr-key" {
+		t.Fatalf("Datadog got %d pushes with %v, New Relic %d with %v", len(dd.bodies), dd.keys, len(nr.bodies), nr.keys)
+	}
+
+	var series struct {
+		Series []struct {
+			Metric string
+			Type   int
+			Points []struct{ Value float64 }
+			Tags   []string
+		}
+	}
+	json.Unmarshal([]byte(dd.bodies[0]), &series)
+	if len(series.Series) != 1 || series.Series[0].Metric != "drift_units" {
+		t.Errorf("First Datadog push = %s, want the gauge only, counters have no increase yet", dd.bodies[0])
+	}
+	json.Unmarshal([]byte(dd.bodies[1]), &series)
+	got := map[string]float64{}
+	for _, s := range series.Series {
+		got[s.Metric] = s.Points[0].Value
+		if !containsString(s.Tags, "env:prod") || !containsString(s.Tags, "space:payments") {
+			t.Errorf("%s tags = %v", s.Metric, s.Tags)
+		}
+	}
+	if len(got) != 2 || got["drift_units"] != 1 || got["devops_cycles_total"] != 2 {
+		t.Errorf("Second Datadog push = %v, want the gauge and the counter's increase", got)
+	}
+
+	var batches []struct {
+		Common struct {
+			IntervalMs int64 `json:"interval.ms"`
+		}
+		Metrics []struct {
+			Name       string
+			Type       string
+			Value      float64
+			Attributes map[string]string
+		}
+	}
+	json.Unmarshal([]byte(nr.bodies[1]), &batches)
+	if len(batches) != 1 || batches[0].Common.IntervalMs != DefaultPushInterval.Milliseconds() || len(batches[0].Metrics) != 2 {
+		t.Fatalf("New Relic push = %s", nr.bodies[1])
+	}
+	for _, m := range batches[0].Metrics {
+		if m.Name == "devops_cycles_total" && (m.Type != "count" || m.Value != 2) || m.Attributes["app"] != "drift-detector" {
+			t.Errorf("New Relic metric = %+v", m)
+		}
+	}
+}
+
+func TestPushFailure(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid API key", http.StatusForbidden)
+	}))
+	defer down.Close()
+	reg := New("cost-optimizer", "2.0.0", "")
+	p, _ := NewPusher(reg, SinksConfig{Datadog: &DatadogConfig{APIKey: "bad", URL: down.URL}})
+	p.Push(context.Background())
+
+	if body := scrape(t, reg); !strings.Contains(body, `devops_errors_total{app="cost-optimizer",cluster="",kind="auth",source="datadog",version="2.0.0"} 1`) {
+		t.Errorf("Expected the failed push counted:\n%s", body)
+	}
+}
+
+func TestLoadSinks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics-sinks.yaml")
+	noSecrets := func(string) (string, error) { return "", nil }
+
+	if p, err := LoadSinks(path, noSecrets, nil); p != nil || err != nil {
+		t.Errorf("LoadSinks(missing) = %v, %v, want nil", p, err)
+	}
+
+	t.Setenv("TEST_DD_KEY", "from-env")
+	os.WriteFile(path, []byte("interval: 30s\ndatadog:\n  api_key: ${TEST_DD_KEY}\n  site: datadoghq.eu\n"), 0o600)
+	p, err := LoadSinks(path, noSecrets, nil)
+	if err != nil || len(p.sinks) != 1 || p.sinks[0] != (datadog{url: "https://api.datadoghq.eu/api/v2/series", key: "from-env"}) {
+		t.Errorf("LoadSinks = %+v, %v", p, err)
+	}
+
+	secrets := func(name string) (string, error) {
+		if name == "newrelic-license-key" {
+			return "from-file", nil
+		}
+		return "", nil
+	}
+	os.WriteFile(path, []byte("newrelic:\n  region: eu\n"), 0o600)
+	if p, err := LoadSinks(path, secrets, nil); err != nil || len(p.sinks) != 1 || p.sinks[0] != (newRelic{url: "https://metric-api.eu.newrelic.com/metric/v1", key: "from-file"}) {
+		t.Errorf("LoadSinks with a secret file = %+v, %v", p, err)
+	}
+
+	keys := func(name string) (string, error) { return name, nil }
+	if p, err := LoadSinks("sinks.example.yaml", keys, nil); err != nil || len(p.Sinks()) != 2 || len(p.config.Metrics) != 4 {
+		t.Errorf("LoadSinks(sinks.example.yaml) = %+v, %v", p, err)
+	}
+
+	for name, text := range map[string]string{
+		"no key":     "datadog:\n  site: datadoghq.com\n",
+		"short":      "interval: 1s\ndatadog:\n  api_key: k\n",
+		"bad region": "newrelic:\n  license_key: k\n  region: apac\n",
+		"not yaml":   "datadog: [",
+	} {
+		os.WriteFile(path, []byte(text), 0o600)
+		if _, err := LoadSinks(path, noSecrets, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
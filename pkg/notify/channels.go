@@ -0,0 +1,286 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/monadic/devops-examples/pkg/webhook"
+)
+
+// DefaultSlackTemplate renders a notification as a Slack mrkdwn message
+const DefaultSlackTemplate = `{{severityEmoji .Severity}} *{{.Title}}* ({{.App}})
+{{.Summary}}{{range .SortedFields}}
+• {{.Name}}: {{.Value}}{{end}}{{if .CorrelationID}}
+• correlation: {{.CorrelationID}}{{end}}{{if .URL}}
+<{{.URL}}|Details>{{end}}{{range .Links}}
+<{{.URL}}|{{.Text}}>{{end}}`
+
+// DefaultPagerDutyTemplate renders the PagerDuty incident summary
+const DefaultPagerDutyTemplate = `[{{.App}}] {{.Title}}: {{.Summary}}`
+
+// templateFuncs are available to every channel template
+var templateFuncs = template.FuncMap{
+	"severityEmoji": func(s Severity) string {
+		switch s {
+		case Critical:
+			return "🚨"
+		case Warning:
+			return "⚠️"
+		default:
+			return "ℹ️"
+		}
+	},
+	"upper": strings.ToUpper,
+}
+
+// ParseTemplate parses a channel message template; fields of Notification
+// and SortedFields are available, plus severityEmoji and upper
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+func render(t *template.Template, n Notification) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, n); err != nil {
+		return "", fmt.Errorf("render %s: %w", t.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// Webhook posts the notification as JSON, or the rendered template as the
+// body when one is set
+type Webhook struct {
+	ChannelName string
+	URL         string
+	Headers     map[string]string
+	Keys        webhook.Keys       // sign the body in X-Signature-256 when set
+	Template    *template.Template // optional
+	Client      *http.Client
+}
+
+func (w *Webhook) Name() string { return w.ChannelName }
+
+func (w *Webhook) Send(ctx context.Context, n Notification) error {
+	var body []byte
+	var err error
+	if w.Template != nil {
+		var text string
+		text, err = render(w.Template, n)
+		body = []byte(text)
+	} else {
+		body, err = json.Marshal(n)
+	}
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.Client, w.URL, w.Headers, w.Keys, body)
+}
+
+// Slack posts to a Slack incoming webhook
+type Slack struct {
+	ChannelName string
+	URL         string
+	Template    *template.Template // defaults to DefaultSlackTemplate
+	Client      *http.Client
+}
+
+func (s *Slack) Name() string { return s.ChannelName }
+
+func (s *Slack) Send(ctx context.Context, n Notification) error {
+	t := s.Template
+	if t == nil {
+		t = template.Must(ParseTemplate("slack", DefaultSlackTemplate))
+	}
+	text, err := render(t, n)
+	if err != nil {
+		return err
+	}
+	message := map[string]interface{}{"text": text}
+	if len(n.Links) > 0 {
+		// The text stays the fallback of clients without blocks
+		section := text
+		if len(section) > slackSectionLimit {
+			section = section[:slackSectionLimit-3] + "..."
+		}
+		buttons := make([]map[string]interface{}, 0, len(n.Links))
+		for _, link := range n.Links {
+			buttons = append(buttons, map[string]interface{}{
+				"type": "button",
+				"text": map[string]string{"type": "plain_text", "text": link.Text},
+				"url":  link.URL,
+			})
+		}
+		message["blocks"] = []map[string]interface{}{
+			{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": section}},
+			{"type": "actions", "elements": buttons},
+		}
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.Client, s.URL, nil, nil, body)
+}
+
+// slackSectionLimit is the most text a Slack section block holds
+const slackSectionLimit = 3000
+
+// Teams posts an Adaptive Card to a Microsoft Teams incoming webhook or
+// workflow: the title, the summary (or the rendered template), the fields
+// as facts and the links as buttons
+type Teams struct {
+	ChannelName string
+	URL         string
+	Template    *template.Template // optional, replaces the summary
+	Client      *http.Client
+}
+
+func (t *Teams) Name() string { return t.ChannelName }
+
+func (t *Teams) Send(ctx context.Context, n Notification) error {
+	text := n.Summary
+	if t.Template != nil {
+		var err error
+		if text, err = render(t.Template, n); err != nil {
+			return err
+		}
+	}
+
+	facts := make([]map[string]string, 0, len(n.Fields)+1)
+	for _, f := range n.SortedFields() {
+		facts = append(facts, map[string]string{"title": f.Name, "value": f.Value})
+	}
+	if n.CorrelationID != "" {
+		facts = append(facts, map[string]string{"title": "correlation", "value": n.CorrelationID})
+	}
+	actions := make([]map[string]string, 0, len(n.Links)+1)
+	if n.URL != "" {
+		actions = append(actions, map[string]string{"type": "Action.OpenUrl", "title": "Details", "url": n.URL})
+	}
+	for _, link := range n.Links {
+		actions = append(actions, map[string]string{"type": "Action.OpenUrl", "title": link.Text, "url": link.URL})
+	}
+
+	content := []map[string]interface{}{
+		{"type": "TextBlock", "text": n.Title, "weight": "Bolder", "size": "Medium", "wrap": true},
+		{"type": "TextBlock", "text": fmt.Sprintf("%s · %s", n.App, n.Severity), "isSubtle": true, "spacing": "None"},
+		{"type": "TextBlock", "text": text, "wrap": true},
+	}
+	if len(facts) > 0 {
+		content = append(content, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    content,
+		"actions": actions,
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, t.Client, t.URL, nil, nil, body)
+}
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty triggers incidents through the Events API v2. The dedup key is
+// passed on, so PagerDuty groups repeats into one incident.
+type PagerDuty struct {
+	ChannelName string
+	RoutingKey  string
+	URL         string             // defaults to PagerDutyEventsURL
+	Template    *template.Template // summary, defaults to DefaultPagerDutyTemplate
+	Client      *http.Client
+}
+
+func (p *PagerDuty) Name() string { return p.ChannelName }
+
+func (p *PagerDuty) Send(ctx context.Context, n Notification) error {
+	t := p.Template
+	if t == nil {
+		t = template.Must(ParseTemplate("pagerduty", DefaultPagerDutyTemplate))
+	}
+	summary, err := render(t, n)
+	if err != nil {
+		return err
+	}
+	if len(summary) > 1024 { // Events API limit
+		summary = summary[:1021] + "..."
+	}
+
+	details := make(map[string]string, len(n.Fields)+2)
+	for k, v := range n.Fields {
+		details[k] = v
+	}
+	if n.URL != "" {
+		details["url"] = n.URL
+	}
+	if n.CorrelationID != "" {
+		details["correlation_id"] = n.CorrelationID
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    n.key(),
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         n.App,
+			"severity":       n.Severity.String(),
+			"class":          n.Kind,
+			"timestamp":      n.Time.Format("2006-01-02T15:04:05Z07:00"),
+			"custom_details": details,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	url := p.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+	return postJSON(ctx, p.Client, url, nil, nil, body)
+}
+
+// postJSON posts body, signed with keys, and fails on non-2xx responses
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, keys webhook.Keys, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	keys.SignRequest(req, body)
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
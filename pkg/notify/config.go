@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/webhook"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the YAML notification configuration shared by all apps
+type Config struct {
+	Channels    []ChannelConfig `yaml:"channels"`
+	Routes      []RouteConfig   `yaml:"routes"`
+	DedupWindow time.Duration   `yaml:"dedup_window"` // default 1h
+}
+
+// ChannelConfig describes one destination. URLs, routing keys, header values
+// and secrets may reference environment variables as ${NAME}.
+type ChannelConfig struct {
+	Name       string            `yaml:"name"`
+	Type       string            `yaml:"type"` // "slack", "teams", "webhook", "pagerduty"
+	URL        string            `yaml:"url"`
+	RoutingKey string            `yaml:"routing_key"` // pagerduty
+	Headers    map[string]string `yaml:"headers"`     // webhook
+	Secret     string            `yaml:"secret"`      // webhook: HMAC keys signing the body, comma separated while rotating
+	Template   string            `yaml:"template"`    // optional text/template
+}
+
+// RouteConfig maps severities (and optionally apps and kinds) to channels
+type RouteConfig struct {
+	MinSeverity Severity `yaml:"min_severity"`
+	Apps        []string `yaml:"apps"`
+	Kinds       []string `yaml:"kinds"`
+	Channels    []string `yaml:"channels"`
+}
+
+// Load reads a notification config and builds its notifier. A missing file
+// yields a notifier that sends nothing.
+func Load(path string) (*Notifier, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Build()
+}
+
+// LoadConfig reads a notification config without building it, for apps
+// adding channels of their own. A missing file yields an empty config.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("read notify config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse notify config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Build validates the config and creates its channels
+func (c Config) Build() (*Notifier, error) {
+	channels := make(map[string]Channel, len(c.Channels))
+	for i, cc := range c.Channels {
+		ch, err := cc.build()
+		if err != nil {
+			return nil, fmt.Errorf("channel %d (%s): %w", i, cc.Name, err)
+		}
+		if _, dup := channels[cc.Name]; dup {
+			return nil, fmt.Errorf("channel %d: duplicate name %q", i, cc.Name)
+		}
+		channels[cc.Name] = ch
+	}
+
+	routes := make([]Route, 0, len(c.Routes))
+	for i, rc := range c.Routes {
+		if len(rc.Channels) == 0 {
+			return nil, fmt.Errorf("route %d: no channels", i)
+		}
+		route := Route{MinSeverity: rc.MinSeverity, Apps: rc.Apps, Kinds: rc.Kinds}
+		for _, name := range rc.Channels {
+			ch, ok := channels[name]
+			if !ok {
+				return nil, fmt.Errorf("route %d: unknown channel %q", i, name)
+			}
+			route.Channels = append(route.Channels, ch)
+		}
+		routes = append(routes, route)
+	}
+
+	window := c.DedupWindow
+	if window == 0 {
+		window = time.Hour
+	}
+	return New(routes, window), nil
+}
+
+func (c ChannelConfig) build() (Channel, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	var tmpl *template.Template
+	if c.Template != "" {
+		var err error
+		if tmpl, err = ParseTemplate(c.Name, c.Template); err != nil {
+			return nil, fmt.Errorf("parse template: %w", err)
+		}
+	}
+	url := os.ExpandEnv(c.URL)
+
+	switch c.Type {
+	case "slack":
+		if url == "" {
+			return nil, fmt.Errorf("slack channel requires url")
+		}
+		return &Slack{ChannelName: c.Name, URL: url, Template: tmpl}, nil
+
+	case "teams":
+		if url == "" {
+			return nil, fmt.Errorf("teams channel requires url")
+		}
+		return &Teams{ChannelName: c.Name, URL: url, Template: tmpl}, nil
+
+	case "webhook":
+		if url == "" {
+			return nil, fmt.Errorf("webhook channel requires url")
+		}
+		headers := make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			headers[k] = os.ExpandEnv(v)
+		}
+		keys := webhook.ParseKeys(os.ExpandEnv(c.Secret))
+		return &Webhook{ChannelName: c.Name, URL: url, Headers: headers, Keys: keys, Template: tmpl}, nil
+
+	case "pagerduty":
+		key := os.ExpandEnv(c.RoutingKey)
+		if key == "" {
+			return nil, fmt.Errorf("pagerduty channel requires routing_key")
+		}
+		return &PagerDuty{ChannelName: c.Name, RoutingKey: key, URL: url, Template: tmpl}, nil
+	}
+
+	return nil, fmt.Errorf("unknown type %q", c.Type)
+}
@@ -0,0 +1,267 @@
+// Package notify sends notifications from DevOps apps to Slack, Microsoft
+// Teams, generic webhooks and PagerDuty.
+//
+// Apps describe what happened as a Notification; a Notifier loaded from YAML
+// routes it by severity (and optionally app and kind) to named channels,
+// renders it with each channel's template and drops repeats of the same
+// notification within a dedup window:
+//
+//	channels:
+//	  - name: team-slack
+//	    type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	  - name: team-teams
+//	    type: teams
+//	    url: ${TEAMS_WEBHOOK_URL}
+//	  - name: oncall
+//	    type: pagerduty
+//	    routing_key: ${PAGERDUTY_ROUTING_KEY}
+//	routes:
+//	  - min_severity: critical
+//	    channels: [oncall, team-slack]
+//	  - min_severity: warning
+//	    apps: [cost-impact-monitor]
+//	    channels: [team-slack]
+//	dedup_window: 1h
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity ranks notifications for routing
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Critical
+)
+
+// ParseSeverity reads "info", "warning" or "critical"
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return Info, nil
+	case "warning":
+		return Warning, nil
+	case "critical":
+		return Critical, nil
+	}
+	return Info, fmt.Errorf("unknown severity %q (info, warning or critical)", s)
+}
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	default:
+		return "info"
+	}
+}
+
+// MarshalText encodes the severity by name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// Notification is one thing an app wants people to know about
+type Notification struct {
+	App      string            `json:"app"`
+	Kind     string            `json:"kind"` // e.g. "drift", "cost-warning", "spend-alert"
+	Severity Severity          `json:"severity"`
+	Title    string            `json:"title"`
+	Summary  string            `json:"summary"`
+	Fields   map[string]string `json:"fields,omitempty"` // space, unit, cost delta, ...
+	URL      string            `json:"url,omitempty"`    // where to look
+	// Links are the actions to take, shown as buttons where the channel
+	// has them: review on the dashboard, apply, ...
+	Links []Link `json:"links,omitempty"`
+	// DedupKey identifies repeats; notifications with the same key are sent
+	// once per dedup window. Defaults to app, kind and title.
+	DedupKey string    `json:"dedup_key,omitempty"`
+	Time     time.Time `json:"time"`
+	// CorrelationID names the incident the notification is about, so it
+	// can be matched with the other apps' logs and audit entries
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// key returns the dedup key of the notification
+func (n Notification) key() string {
+	if n.DedupKey != "" {
+		return n.App + "/" + n.DedupKey
+	}
+	return n.App + "/" + n.Kind + "/" + n.Title
+}
+
+// SortedFields returns the non-empty fields ordered by name, for stable rendering
+func (n Notification) SortedFields() []Field {
+	fields := make([]Field, 0, len(n.Fields))
+	for name, value := range n.Fields {
+		if value != "" {
+			fields = append(fields, Field{name, value})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+// Link is an action a notification offers
+type Link struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// Field is one named detail of a notification
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Channel delivers notifications to one destination
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// Route sends notifications at or above MinSeverity to Channels. Apps and
+// Kinds, when set, limit the route to those apps and kinds.
+type Route struct {
+	MinSeverity Severity
+	Apps        []string
+	Kinds       []string
+	Channels    []Channel
+}
+
+func (r Route) matches(n Notification) bool {
+	return n.Severity >= r.MinSeverity && contains(r.Apps, n.App) && contains(r.Kinds, n.Kind)
+}
+
+// contains reports whether list is empty or holds s
+func contains(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier routes notifications to channels. The zero value and a nil
+// Notifier drop everything, so apps can notify unconditionally.
+type Notifier struct {
+	routes      []Route
+	dedupWindow time.Duration
+	timeout     time.Duration
+	now         func() time.Time
+
+	mu   sync.Mutex
+	sent map[string]time.Time // dedup key -> last sent
+}
+
+// New builds a notifier from routes; dedupWindow 0 disables dedup
+func New(routes []Route, dedupWindow time.Duration) *Notifier {
+	return &Notifier{
+		routes:      routes,
+		dedupWindow: dedupWindow,
+		timeout:     10 * time.Second,
+		now:         time.Now,
+		sent:        make(map[string]time.Time),
+	}
+}
+
+// Enabled reports whether any route is configured
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.routes) > 0
+}
+
+// Notify sends n to every channel of every matching route, each channel at
+// most once. It returns the delivery errors of all channels combined.
+func (n *Notifier) Notify(ctx context.Context, note Notification) error {
+	if !n.Enabled() {
+		return nil
+	}
+	if note.Time.IsZero() {
+		note.Time = n.now()
+	}
+
+	var channels []Channel
+	seen := make(map[string]bool)
+	for _, route := range n.routes {
+		if !route.matches(note) {
+			continue
+		}
+		for _, ch := range route.Channels {
+			if !seen[ch.Name()] {
+				seen[ch.Name()] = true
+				channels = append(channels, ch)
+			}
+		}
+	}
+	if len(channels) == 0 || n.duplicate(note) {
+		return nil
+	}
+
+	var errs []error
+	for _, ch := range channels {
+		sendCtx, cancel := context.WithTimeout(ctx, n.timeout)
+		if err := ch.Send(sendCtx, note); err != nil {
+			errs = append(errs, fmt.Errorf("notify %s: %w", ch.Name(), err))
+		}
+		cancel()
+	}
+	if len(errs) == len(channels) {
+		n.forget(note) // nothing got through; let the next attempt retry
+	}
+	return errors.Join(errs...)
+}
+
+// duplicate reports whether note was already sent within the dedup window,
+// and records it as sent otherwise
+func (n *Notifier) duplicate(note Notification) bool {
+	if n.dedupWindow <= 0 {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	for key, at := range n.sent {
+		if now.Sub(at) >= n.dedupWindow {
+			delete(n.sent, key)
+		}
+	}
+	if _, ok := n.sent[note.key()]; ok {
+		return true
+	}
+	n.sent[note.key()] = now
+	return false
+}
+
+func (n *Notifier) forget(note Notification) {
+	n.mu.Lock()
+	delete(n.sent, note.key())
+	n.mu.Unlock()
+}
@@ -0,0 +1,330 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/webhook"
+)
+
+// recorder is a channel that remembers what it was sent
+type recorder struct {
+	name string
+	err  error
+	mu   sync.Mutex
+	got  []Notification
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Send(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, n)
+	return r.err
+}
+
+func TestNotifierRouting(t *testing.T) {
+	slack := &recorder{name: "slack"}
+	pager := &recorder{name: "pager"}
+	drift := &recorder{name: "drift"}
+	n := New([]Route{
+		{MinSeverity: Critical, Channels: []Channel{pager, slack}},
+		{MinSeverity: Warning, Channels: []Channel{slack}},
+		{MinSeverity: Info, Apps: []string{"drift-detector"}, Kinds: []string{"drift"}, Channels: []Channel{drift}},
+	}, 0)
+
+	notes := []Notification{
+		{App: "cost-impact-monitor", Kind: "cost-warning", Severity: Info, Title: "a"},
+		{App: "cost-impact-monitor", Kind: "cost-warning", Severity: Warning, Title: "b"},
+		{App: "cost-optimizer", Kind: "recommendation", Severity: Critical, Title: "c"},
+		{App: "drift-detector", Kind: "drift", Severity: Info, Title: "d"},
+	}
+	for _, note := range notes {
+		if err := n.Notify(context.Background(), note); err != nil {
+			t.Fatalf("Notify(%s): %v", note.Title, err)
+		}
+	}
+
+	titles := func(r *recorder) string {
+		var s []string
+		for _, n := range r.got {
+			s = append(s, n.Title)
+		}
+		return strings.Join(s, ",")
+	}
+	if got := titles(slack); got != "b,c" {
+		t.Errorf("slack got %q, want b,c (once each)", got)
+	}
+	if got := titles(pager); got != "c" {
+		t.Errorf("pager got %q, want c", got)
+	}
+	if got := titles(drift); got != "d" {
+		t.Errorf("drift got %q, want d", got)
+	}
+}
+
+func TestNotifierDedup(t *testing.T) {
+	ch := &recorder{name: "slack"}
+	n := New([]Route{{Channels: []Channel{ch}}}, time.Hour)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+
+	send := func(key string) {
+		t.Helper()
+		if err := n.Notify(context.Background(), Notification{App: "app", Title: "t", DedupKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("space-1")
+	send("space-1")
+	send("space-2")
+	if len(ch.got) != 2 {
+		t.Fatalf("sent %d notifications, want 2 (repeat suppressed)", len(ch.got))
+	}
+
+	now = now.Add(time.Hour)
+	send("space-1")
+	if len(ch.got) != 3 {
+		t.Fatalf("sent %d notifications, want 3 (window expired)", len(ch.got))
+	}
+
+	// A notification no channel accepted is retried
+	ch.err = errors.New("down")
+	if err := n.Notify(context.Background(), Notification{App: "app", Title: "t", DedupKey: "space-3"}); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	ch.err = nil
+	send("space-3")
+	if len(ch.got) != 5 {
+		t.Fatalf("sent %d notifications, want 5 (failed send retried)", len(ch.got))
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	var n *Notifier
+	if n.Enabled() {
+		t.Error("nil notifier should be disabled")
+	}
+	if err := n.Notify(context.Background(), Notification{Title: "x"}); err != nil {
+		t.Errorf("nil notifier: %v", err)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	var bodies []map[string]interface{}
+	var headers []http.Header
+	var raw [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("body is not JSON: %s", data)
+		}
+		bodies = append(bodies, body)
+		headers = append(headers, r.Header)
+		raw = append(raw, data)
+	}))
+	defer server.Close()
+
+	note := Notification{
+		App:      "cost-impact-monitor",
+		Kind:     "cost-warning",
+		Severity: Critical,
+		Title:    "Cost increase in prod",
+		Summary:  "+$500.00/month",
+		Fields:   map[string]string{"unit": "api", "space": "prod"},
+		DedupKey: "prod/api",
+		Time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+
+		CorrelationID: "incident-1",
+	}
+
+	slack := &Slack{ChannelName: "slack", URL: server.URL}
+	hook := &Webhook{ChannelName: "hook", URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t"}, Keys: webhook.ParseKeys("s3cret")}
+	pager := &PagerDuty{ChannelName: "pd", RoutingKey: "key", URL: server.URL}
+	for _, ch := range []Channel{slack, hook, pager} {
+		if err := ch.Send(context.Background(), note); err != nil {
+			t.Fatalf("%s: %v", ch.Name(), err)
+		}
+	}
+
+	text := bodies[0]["text"].(string)
+	want := "🚨 *Cost increase in prod* (cost-impact-monitor)\n+$500.00/month\n• space: prod\n• unit: api\n• correlation: incident-1"
+	if text != want {
+		t.Errorf("slack text = %q, want %q", text, want)
+	}
+
+	if bodies[1]["severity"] != "critical" || bodies[1]["title"] != note.Title || bodies[1]["correlation_id"] != "incident-1" {
+		t.Errorf("webhook body = %v", bodies[1])
+	}
+	if headers[1].Get("Authorization") != "Bearer t" {
+		t.Errorf("webhook header = %q", headers[1].Get("Authorization"))
+	}
+	if sig := headers[1].Get(webhook.SignatureHeader); !webhook.ParseKeys("s3cret").Verify(raw[1], sig) {
+		t.Errorf("webhook signature = %q", sig)
+	}
+	if sig := headers[0].Get(webhook.SignatureHeader); sig != "" {
+		t.Errorf("slack signature = %q, want none", sig)
+	}
+
+	payload := bodies[2]["payload"].(map[string]interface{})
+	if bodies[2]["routing_key"] != "key" || bodies[2]["dedup_key"] != "cost-impact-monitor/prod/api" {
+		t.Errorf("pagerduty event = %v", bodies[2])
+	}
+	details := payload["custom_details"].(map[string]interface{})
+	if payload["severity"] != "critical" || payload["summary"] != "[cost-impact-monitor] Cost increase in prod: +$500.00/month" ||
+		details["correlation_id"] != "incident-1" {
+		t.Errorf("pagerduty payload = %v", payload)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("body is not JSON: %v", err)
+		}
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	note := Notification{
+		App:      "cost-optimizer",
+		Kind:     "recommendation",
+		Severity: Warning,
+		Title:    "2 recommendations save $300.00/month",
+		Summary:  "deployment/web: $200.00/month",
+		Fields:   map[string]string{"cluster": "prod"},
+		URL:      "https://costs.example.com",
+		Links:    []Link{{Text: "Review deployment/web", URL: "https://costs.example.com/#rec-1"}},
+	}
+	for _, ch := range []Channel{&Slack{ChannelName: "slack", URL: server.URL}, &Teams{ChannelName: "teams", URL: server.URL}} {
+		if err := ch.Send(context.Background(), note); err != nil {
+			t.Fatalf("%s: %v", ch.Name(), err)
+		}
+	}
+
+	slack := bodies[0]
+	if text := slack["text"].(string); !strings.HasSuffix(text, "<https://costs.example.com|Details>\n<https://costs.example.com/#rec-1|Review deployment/web>") {
+		t.Errorf("slack text = %q", text)
+	}
+	blocks := slack["blocks"].([]interface{})
+	button := blocks[1].(map[string]interface{})["elements"].([]interface{})[0].(map[string]interface{})
+	if len(blocks) != 2 || button["url"] != "https://costs.example.com/#rec-1" ||
+		button["text"].(map[string]interface{})["text"] != "Review deployment/web" {
+		t.Errorf("slack blocks = %v", blocks)
+	}
+
+	attachment := bodies[1]["attachments"].([]interface{})[0].(map[string]interface{})
+	card := attachment["content"].(map[string]interface{})
+	if bodies[1]["type"] != "message" || attachment["contentType"] != "application/vnd.microsoft.card.adaptive" || card["type"] != "AdaptiveCard" {
+		t.Fatalf("teams body = %v", bodies[1])
+	}
+	content := card["body"].([]interface{})
+	if title := content[0].(map[string]interface{})["text"]; title != note.Title {
+		t.Errorf("teams title = %v", title)
+	}
+	if text := content[2].(map[string]interface{})["text"]; text != note.Summary {
+		t.Errorf("teams text = %v", text)
+	}
+	fact := content[3].(map[string]interface{})["facts"].([]interface{})[0].(map[string]interface{})
+	if fact["title"] != "cluster" || fact["value"] != "prod" {
+		t.Errorf("teams facts = %v", content[3])
+	}
+	actions := card["actions"].([]interface{})
+	if len(actions) != 2 || actions[0].(map[string]interface{})["url"] != note.URL ||
+		actions[1].(map[string]interface{})["title"] != "Review deployment/web" {
+		t.Errorf("teams actions = %v", actions)
+	}
+}
+
+func TestChannelError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := (&Slack{ChannelName: "slack", URL: server.URL}).Send(context.Background(), Notification{})
+	if err == nil || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Errorf("err = %v, want the response body", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("TEST_SLACK_URL", "https://hooks.slack.test/abc")
+	dir := t.TempDir()
+
+	n, err := Load(filepath.Join(dir, "missing.yaml"))
+	if err != nil || n.Enabled() {
+		t.Fatalf("missing file: %v, enabled=%v", err, n.Enabled())
+	}
+
+	path := filepath.Join(dir, "notify.yaml")
+	config := `channels:
+  - name: team
+    type: slack
+    url: ${TEST_SLACK_URL}
+    template: "{{upper .Title}}"
+  - name: oncall
+    type: pagerduty
+    routing_key: abc
+  - name: ms
+    type: teams
+    url: https://teams.test/hook
+routes:
+  - min_severity: critical
+    channels: [oncall, team]
+  - min_severity: warning
+    kinds: [drift]
+    channels: [team]
+dedup_window: 30m
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.dedupWindow != 30*time.Minute || len(n.routes) != 2 {
+		t.Fatalf("window=%v routes=%d", n.dedupWindow, len(n.routes))
+	}
+	if n.routes[0].MinSeverity != Critical || n.routes[1].Kinds[0] != "drift" {
+		t.Errorf("routes = %+v", n.routes)
+	}
+	if slack := n.routes[1].Channels[0].(*Slack); slack.URL != "https://hooks.slack.test/abc" || slack.Template == nil {
+		t.Errorf("slack channel = %+v", slack)
+	}
+
+	bad := map[string]string{
+		"unknown channel":  "routes:\n  - channels: [nope]\n",
+		"unknown type":     "channels:\n  - name: x\n    type: email\n",
+		"missing url":      "channels:\n  - name: x\n    type: slack\n",
+		"teams no url":     "channels:\n  - name: x\n    type: teams\n",
+		"bad severity":     "routes:\n  - min_severity: urgent\n    channels: [x]\n",
+		"bad template":     "channels:\n  - name: x\n    type: slack\n    url: http://x\n    template: \"{{\"\n",
+		"duplicate name":   "channels:\n  - {name: x, type: slack, url: http://x}\n  - {name: x, type: slack, url: http://y}\n",
+		"route no channel": "routes:\n  - min_severity: info\n",
+	}
+	for name, config := range bad {
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
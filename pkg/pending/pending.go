@@ -0,0 +1,556 @@
+// Package pending queues the apps' actions that wait for someone's approval
+// or for another attempt: drift fixes and optimizations the policy wants
+// approved (see pkg/policy), fixes that failed, changes cost-impact-monitor
+// blocked. The queue is stored as units of the space named by PENDING_SPACE,
+// or as files in PENDING_DIR, so it survives restarts, and every app serves
+// the whole queue:
+//
+//	GET  /api/v1/queue?app=drift-detector&state=waiting
+//	POST /api/v1/queue/{id}/approve   {"approver": "alice", "note": "..."}
+//	POST /api/v1/queue/{id}/retry
+//	POST /api/v1/queue/{id}/expire
+//
+// An action approved or retried through any app is run by the app that
+// queued it, with the handler it registered for the action's kind, the next
+// time it processes its queue. Actions nobody approves expire.
+//
+// Without a space or directory the queue is kept in memory.
+package pending
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/correlation"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// States of an action
+const (
+	Waiting  = "waiting"  // for approval
+	Approved = "approved" // to be run by its app
+	Retrying = "retrying" // failed; run again at NextAttempt
+	Done     = "done"
+	Failed   = "failed"  // gave up after MaxAttempts
+	Expired  = "expired" // nobody approved it in time, or someone dropped it
+)
+
+// Label marks the ConfigHub units holding queued actions
+const Label = "pending-action"
+
+// Defaults of a new Queue
+const (
+	DefaultTTL         = 7 * 24 * time.Hour
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = 5 * time.Minute
+)
+
+// Action is one queued action
+type Action struct {
+	ID            string          `json:"id"`
+	App           string          `json:"app"`
+	Kind          string          `json:"kind"`            // "fix", "optimization", "apply"; see pkg/policy
+	Space         string          `json:"space,omitempty"` // slug of the target's space
+	Target        string          `json:"target"`          // unit acted on
+	Input         json.RawMessage `json:"input,omitempty"`
+	Reason        string          `json:"reason"` // why it waits
+	State         string          `json:"state"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttempt   *time.Time      `json:"next_attempt,omitempty"`
+	ApprovedBy    string          `json:"approved_by,omitempty"`
+	Note          string          `json:"note,omitempty"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	ExpiresAt     time.Time       `json:"expires_at"`
+}
+
+// Open reports whether the action still waits for something
+func (a Action) Open() bool {
+	return a.State == Waiting || a.State == Approved || a.State == Retrying
+}
+
+// Handler runs an approved or retried action
+type Handler func(ctx context.Context, a Action) error
+
+// Writer stores an action as the ConfigHub unit with the given slug,
+// creating it or replacing its data and labels
+type Writer func(ctx context.Context, slug string, labels map[string]string, data string) error
+
+// Reader returns the data of the ConfigHub units matching a where clause
+type Reader func(ctx context.Context, where string) ([]string, error)
+
+// Queue is an app's view of the shared queue. It is safe for concurrent
+// use; a nil Queue queues nothing.
+type Queue struct {
+	app    string
+	writer Writer
+	reader Reader
+	now    func() time.Time
+
+	TTL         time.Duration // how long an action waits for approval
+	MaxAttempts int           // runs before an action has failed
+	Backoff     time.Duration // wait before the first retry, doubled on each
+
+	mu       sync.Mutex
+	actions  map[string]*Action // what this process has seen, by ID
+	handlers map[string]Handler
+}
+
+// New returns app's queue, stored through writer and reader; nil keeps it in
+// memory
+func New(app string, writer Writer, reader Reader) *Queue {
+	return &Queue{
+		app: app, writer: writer, reader: reader, now: time.Now,
+		TTL: DefaultTTL, MaxAttempts: DefaultMaxAttempts, Backoff: DefaultBackoff,
+		actions:  map[string]*Action{},
+		handlers: map[string]Handler{},
+	}
+}
+
+// Dir returns a Writer and Reader keeping actions as JSON files in dir, for
+// a queue that survives restarts without ConfigHub
+func Dir(dir string) (Writer, Reader, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("create pending dir: %w", err)
+	}
+	writer := func(_ context.Context, slug string, _ map[string]string, data string) error {
+		tmp := filepath.Join(dir, slug+".json.tmp")
+		if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, filepath.Join(dir, slug+".json"))
+	}
+	reader := func(context.Context, string) ([]string, error) {
+		paths, err := filepath.Glob(filepath.Join(dir, "pending-*.json"))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]string, 0, len(paths))
+		for _, path := range paths {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, string(b))
+		}
+		return data, nil
+	}
+	return writer, reader, nil
+}
+
+// Handle registers the handler running the app's actions of kind
+func (q *Queue) Handle(kind string, h Handler) {
+	q.mu.Lock()
+	q.handlers[kind] = h
+	q.mu.Unlock()
+}
+
+// Slug names the unit of an action
+func Slug(a Action) string {
+	return "pending-" + a.ID
+}
+
+// Labels are the unit labels of an action, the fields a Query filters on in
+// ConfigHub
+func Labels(a Action) map[string]string {
+	return map[string]string{Label: "true", "app": a.App, "kind": a.Kind, "state": a.State}
+}
+
+// Query selects actions; empty fields match everything
+type Query struct {
+	App    string
+	Kind   string
+	State  string
+	Space  string
+	Target string
+}
+
+// Where returns the ConfigHub where clause of the units q may match
+func (q Query) Where() string {
+	clauses := []string{fmt.Sprintf("Labels['%s'] = 'true'", Label)}
+	for _, l := range []struct{ label, value string }{{"app", q.App}, {"kind", q.Kind}, {"state", q.State}} {
+		if l.value != "" {
+			clauses = append(clauses, fmt.Sprintf("Labels['%s'] = '%s'", l.label, strings.ReplaceAll(l.value, "'", "''")))
+		}
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// Matches reports whether a is selected by q
+func (q Query) Matches(a Action) bool {
+	return (q.App == "" || a.App == q.App) &&
+		(q.Kind == "" || a.Kind == q.Kind) &&
+		(q.State == "" || a.State == q.State) &&
+		(q.Space == "" || a.Space == q.Space) &&
+		(q.Target == "" || a.Target == q.Target)
+}
+
+// Wait queues an action on target in space for approval. An open action of
+// the app with the same kind and target is updated instead, so detections
+// repeated every cycle queue it once.
+func (q *Queue) Wait(ctx context.Context, kind, space, target string, input interface{}, reason string) (Action, error) {
+	return q.add(ctx, kind, space, target, input, reason, Waiting)
+}
+
+// Retry queues an action that failed to run again after the backoff
+func (q *Queue) Retry(ctx context.Context, kind, space, target string, input interface{}, err error) (Action, error) {
+	return q.add(ctx, kind, space, target, input, err.Error(), Retrying)
+}
+
+func (q *Queue) add(ctx context.Context, kind, space, target string, input interface{}, reason, state string) (Action, error) {
+	if q == nil {
+		return Action{}, nil
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return Action{}, fmt.Errorf("encode input: %w", err)
+	}
+	now := q.now().UTC()
+
+	existing, err := q.List(ctx, Query{App: q.app, Kind: kind, Space: space, Target: target})
+	if err != nil {
+		return Action{}, err
+	}
+	var a Action
+	for _, e := range existing {
+		if e.Open() {
+			a = e
+			break
+		}
+	}
+	if a.ID == "" {
+		a = Action{
+			ID: uuid.NewString(), App: q.app, Kind: kind, Space: space, Target: target, State: state,
+			CorrelationID: correlation.FromContext(ctx), CreatedAt: now, ExpiresAt: now.Add(q.TTL),
+		}
+	}
+	a.Input, a.Reason, a.UpdatedAt = data, reason, now
+	if state == Retrying && a.State != Approved {
+		a.State, a.LastError = Retrying, reason
+		a.NextAttempt = q.nextAttempt(a.Attempts)
+	}
+	return a, q.save(ctx, a)
+}
+
+// nextAttempt is when to run an action again after attempts runs
+func (q *Queue) nextAttempt(attempts int) *time.Time {
+	wait := q.Backoff
+	for i := 1; i < attempts && wait < 24*time.Hour; i++ {
+		wait *= 2
+	}
+	t := q.now().UTC().Add(wait)
+	return &t
+}
+
+// save stores a and remembers it
+func (q *Queue) save(ctx context.Context, a Action) error {
+	q.mu.Lock()
+	copied := a
+	q.actions[a.ID] = &copied
+	q.mu.Unlock()
+
+	if q.writer == nil {
+		return nil
+	}
+	data, _ := json.Marshal(a)
+	if err := q.writer(ctx, Slug(a), Labels(a), string(data)); err != nil {
+		return fmt.Errorf("store pending action: %w", err)
+	}
+	return nil
+}
+
+// List returns the actions selected by sel, oldest first. Without a store,
+// or when it cannot be read, only the actions this process has seen are
+// listed.
+func (q *Queue) List(ctx context.Context, sel Query) ([]Action, error) {
+	if q == nil {
+		return nil, nil
+	}
+	if q.reader != nil {
+		data, err := q.reader(ctx, sel.Where())
+		if err != nil {
+			slog.Warn("Failed to read pending actions, listing known ones", logging.Err(err))
+		} else {
+			q.mu.Lock()
+			for _, d := range data {
+				var a Action
+				if err := json.Unmarshal([]byte(d), &a); err != nil {
+					q.mu.Unlock()
+					return nil, fmt.Errorf("decode pending action: %w", err)
+				}
+				q.actions[a.ID] = &a
+			}
+			q.mu.Unlock()
+		}
+	}
+
+	q.mu.Lock()
+	var actions []Action
+	for _, a := range q.actions {
+		if sel.Matches(*a) {
+			actions = append(actions, *a)
+		}
+	}
+	q.mu.Unlock()
+	sort.Slice(actions, func(i, j int) bool { return actions[i].CreatedAt.Before(actions[j].CreatedAt) })
+	return actions, nil
+}
+
+// get returns the action with id, reading the store first
+func (q *Queue) get(ctx context.Context, id string) (Action, error) {
+	actions, err := q.List(ctx, Query{})
+	if err != nil {
+		return Action{}, err
+	}
+	for _, a := range actions {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return Action{}, fmt.Errorf("no pending action %s", id)
+}
+
+// Approve lets an action waiting for approval run
+func (q *Queue) Approve(ctx context.Context, id, approver, note string) (Action, error) {
+	return q.transition(ctx, id, func(a *Action) error {
+		if a.State != Waiting {
+			return fmt.Errorf("%s is %s and needs no approval", a.ID, a.State)
+		}
+		a.State, a.ApprovedBy, a.Note = Approved, approver, note
+		return nil
+	})
+}
+
+// RetryNow runs a retrying or failed action again on the next pass
+func (q *Queue) RetryNow(ctx context.Context, id string) (Action, error) {
+	return q.transition(ctx, id, func(a *Action) error {
+		if a.State != Retrying && a.State != Failed {
+			return fmt.Errorf("%s is %s; only retrying and failed actions are retried", a.ID, a.State)
+		}
+		now := q.now().UTC()
+		a.State, a.NextAttempt = Retrying, &now
+		if a.Attempts >= q.MaxAttempts {
+			a.Attempts = q.MaxAttempts - 1 // one more go
+		}
+		return nil
+	})
+}
+
+// Expire drops an open action
+func (q *Queue) Expire(ctx context.Context, id string) (Action, error) {
+	return q.transition(ctx, id, func(a *Action) error {
+		if !a.Open() {
+			return fmt.Errorf("%s is already %s", a.ID, a.State)
+		}
+		a.State, a.NextAttempt = Expired, nil
+		return nil
+	})
+}
+
+// Done closes the app's open actions of kind on target, taken some other way
+func (q *Queue) Done(ctx context.Context, kind, space, target string) error {
+	actions, err := q.List(ctx, Query{App: q.app, Kind: kind, Space: space, Target: target})
+	if err != nil {
+		return err
+	}
+	for _, a := range actions {
+		if !a.Open() {
+			continue
+		}
+		a.State, a.NextAttempt, a.UpdatedAt = Done, nil, q.now().UTC()
+		if err := q.save(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Queue) transition(ctx context.Context, id string, change func(*Action) error) (Action, error) {
+	if q == nil {
+		return Action{}, fmt.Errorf("no pending action %s", id)
+	}
+	a, err := q.get(ctx, id)
+	if err != nil {
+		return Action{}, err
+	}
+	if err := change(&a); err != nil {
+		return a, err
+	}
+	a.UpdatedAt = q.now().UTC()
+	return a, q.save(ctx, a)
+}
+
+// Process runs the app's approved actions and the retries that are due,
+// and expires the actions that waited too long. It returns how many ran.
+func (q *Queue) Process(ctx context.Context) int {
+	if q == nil {
+		return 0
+	}
+	actions, err := q.List(ctx, Query{App: q.app})
+	if err != nil {
+		slog.Warn("Failed to list pending actions", logging.Err(err))
+		return 0
+	}
+	now := q.now().UTC()
+	ran := 0
+	for _, a := range actions {
+		switch {
+		case a.State == Waiting && now.After(a.ExpiresAt):
+			a.State = Expired
+		case a.State == Approved, a.State == Retrying && a.NextAttempt != nil && !now.Before(*a.NextAttempt):
+			q.mu.Lock()
+			handler := q.handlers[a.Kind]
+			q.mu.Unlock()
+			if handler == nil {
+				continue
+			}
+			a.Attempts++
+			ran++
+			actx := correlation.With(ctx, a.CorrelationID)
+			if a.ApprovedBy != "" {
+				actx = audit.WithActor(actx, a.ApprovedBy) // the approver takes the action
+			}
+			if err := handler(actx, a); err != nil {
+				a.LastError = err.Error()
+				a.State, a.NextAttempt = Retrying, q.nextAttempt(a.Attempts)
+				if a.Attempts >= q.MaxAttempts {
+					a.State, a.NextAttempt = Failed, nil
+				}
+				slog.Warn("Pending action failed", "kind", a.Kind, logging.Unit(a.Target), "attempts", a.Attempts, logging.Err(err))
+			} else {
+				a.State, a.LastError, a.NextAttempt = Done, "", nil
+				slog.Info("Pending action done", "kind", a.Kind, logging.Unit(a.Target), "attempts", a.Attempts)
+			}
+		default:
+			continue
+		}
+		a.UpdatedAt = now
+		if err := q.save(ctx, a); err != nil {
+			slog.Warn("Failed to store pending action", logging.Unit(a.Target), logging.Err(err))
+		}
+	}
+	return ran
+}
+
+// Start processes the queue every interval until ctx is done
+func (q *Queue) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.Process(ctx)
+		}
+	}
+}
+
+// Listing is the body of GET /api/v1/queue
+type Listing struct {
+	Actions []Action `json:"actions"`
+}
+
+// Approval is the body of POST /api/v1/queue/{id}/approve; signed-in users
+// approve as themselves
+type Approval struct {
+	Approver string `json:"approver"`
+	Note     string `json:"note"`
+}
+
+// Operations document the queue routes of an app's API
+var Operations = []api.Operation{
+	{Path: "/queue", Summary: "Queued actions", Response: Listing{},
+		Query: []api.Param{{Name: "app"}, {Name: "kind"}, {Name: "state"}, {Name: "space"}, {Name: "target"}}},
+	{Method: http.MethodPost, Path: "/queue/{id}/approve", Summary: "Approve an action", Request: Approval{}, Response: Action{}},
+	{Method: http.MethodPost, Path: "/queue/{id}/retry", Summary: "Retry an action now", Response: Action{}},
+	{Method: http.MethodPost, Path: "/queue/{id}/expire", Summary: "Give up on an action", Response: Action{}},
+}
+
+// ServeHTTP lists actions (GET /api/v1/queue?app=&kind=&state=&space=&target=)
+// and approves, retries or expires one (POST /api/v1/queue/{id}/approve|retry|expire)
+func (q *Queue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, api.Prefix+"/queue"), "/"), "/")
+	if parts[0] == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		params := r.URL.Query()
+		actions, err := q.List(r.Context(), Query{
+			App: params.Get("app"), Kind: params.Get("kind"), State: params.Get("state"),
+			Space: params.Get("space"), Target: params.Get("target"),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if actions == nil {
+			actions = []Action{}
+		}
+		writeJSON(w, Listing{Actions: actions})
+		return
+	}
+
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var a Action
+	var err error
+	switch parts[1] {
+	case "approve":
+		var req Approval
+		decodeErr := json.NewDecoder(r.Body).Decode(&req)
+		if user := auth.FromContext(r.Context()); user != nil {
+			req.Approver = user.Email // signed-in users approve as themselves
+		}
+		if decodeErr != nil || req.Approver == "" {
+			http.Error(w, "approver is required", http.StatusBadRequest)
+			return
+		}
+		a, err = q.Approve(r.Context(), parts[0], req.Approver, req.Note)
+	case "retry":
+		a, err = q.RetryNow(r.Context(), parts[0])
+	case "expire":
+		a, err = q.Expire(r.Context(), parts[0])
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case err != nil && a.ID == "":
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeJSON(w, a)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
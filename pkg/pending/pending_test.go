@@ -0,0 +1,258 @@
+package pending
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+)
+
+// fakeHub keeps units by slug and answers where clauses on the app and state labels
+type fakeHub struct {
+	units map[string]map[string]string // slug -> labels, plus "data"
+	down  bool
+}
+
+func (h *fakeHub) write(ctx context.Context, slug string, labels map[string]string, data string) error {
+	if h.down {
+		return errors.New("confighub unavailable")
+	}
+	unit := map[string]string{"data": data}
+	for k, v := range labels {
+		unit[k] = v
+	}
+	h.units[slug] = unit
+	return nil
+}
+
+func (h *fakeHub) read(ctx context.Context, where string) ([]string, error) {
+	if h.down {
+		return nil, errors.New("confighub unavailable")
+	}
+	var data []string
+	for _, unit := range h.units {
+		if unit[Label] != "true" ||
+			strings.Contains(where, "Labels['app']") && !strings.Contains(where, "'"+unit["app"]+"'") ||
+			strings.Contains(where, "Labels['state']") && !strings.Contains(where, "'"+unit["state"]+"'") {
+			continue
+		}
+		data = append(data, unit["data"])
+	}
+	return data, nil
+}
+
+func TestWaitApproveProcess(t *testing.T) {
+	hub := &fakeHub{units: map[string]map[string]string{}}
+	q := New("drift-detector", hub.write, hub.read)
+	ctx := context.Background()
+
+	var ran []Action
+	var actor string
+	q.Handle("fix", func(ctx context.Context, a Action) error {
+		ran = append(ran, a)
+		actor = audit.Actor(ctx)
+		return nil
+	})
+
+	a, err := q.Wait(ctx, "fix", "apps", "backend", map[string]int{"replicas": 3}, "policy asks for approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := q.Wait(ctx, "fix", "apps", "backend", map[string]int{"replicas": 4}, "policy asks for approval")
+	if again.ID != a.ID || string(again.Input) != `{"replicas":4}` || len(hub.units) != 1 {
+		t.Errorf("Wait again = %+v, %d units, want the same action updated", again, len(hub.units))
+	}
+
+	if n := q.Process(ctx); n != 0 {
+		t.Errorf("Process = %d before approval, want 0", n)
+	}
+
+	// Another app, or this one after a restart, approves it from the store
+	other := New("cost-optimizer", hub.write, hub.read)
+	if _, err := other.Approve(ctx, a.ID, "alice", "looks fine"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Approve(ctx, a.ID, "bob", ""); err == nil {
+		t.Error("Expected approving twice to fail")
+	}
+	if n := other.Process(ctx); n != 0 {
+		t.Errorf("cost-optimizer ran %d of drift-detector's actions", n)
+	}
+
+	restarted := New("drift-detector", hub.write, hub.read)
+	restarted.Handle("fix", q.handlers["fix"])
+	if n := restarted.Process(ctx); n != 1 || len(ran) != 1 || actor != "alice" {
+		t.Fatalf("Process = %d, ran %+v as %s", n, ran, actor)
+	}
+	actions, _ := restarted.List(ctx, Query{State: Done})
+	if len(actions) != 1 || actions[0].ApprovedBy != "alice" || actions[0].Attempts != 1 {
+		t.Errorf("List(done) = %+v", actions)
+	}
+
+	next, _ := restarted.Wait(ctx, "fix", "apps", "backend", nil, "drifted again")
+	if next.ID == a.ID {
+		t.Error("Expected a done action not to be reopened")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	hub := &fakeHub{units: map[string]map[string]string{}}
+	q := New("cost-optimizer", hub.write, hub.read)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	q.MaxAttempts = 3
+	ctx := context.Background()
+
+	failures := 0
+	q.Handle("optimization", func(context.Context, Action) error {
+		failures++
+		return errors.New("apply failed")
+	})
+
+	a, _ := q.Retry(ctx, "optimization", "apps", "api", nil, errors.New("timeout"))
+	if a.State != Retrying || a.NextAttempt == nil || !a.NextAttempt.Equal(now.Add(DefaultBackoff)) {
+		t.Fatalf("Retry = %+v", a)
+	}
+	if q.Process(ctx) != 0 {
+		t.Error("Expected no retry before the backoff")
+	}
+
+	for i := 1; i <= 3; i++ {
+		now = now.Add(24 * time.Hour)
+		q.Process(ctx)
+	}
+	actions, _ := q.List(ctx, Query{})
+	if failures != 3 || len(actions) != 1 || actions[0].State != Failed || actions[0].LastError != "apply failed" {
+		t.Fatalf("After %d failures: %+v", failures, actions)
+	}
+
+	if _, err := q.RetryNow(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	q.Handle("optimization", func(context.Context, Action) error { return nil })
+	if q.Process(ctx) != 1 {
+		t.Error("Expected RetryNow to run the failed action once more")
+	}
+	if actions, _ := q.List(ctx, Query{State: Done}); len(actions) != 1 {
+		t.Errorf("List(done) = %+v", actions)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	q := New("drift-detector", nil, nil)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	stale, _ := q.Wait(ctx, "fix", "apps", "backend", nil, "needs approval")
+	dropped, _ := q.Wait(ctx, "fix", "apps", "frontend", nil, "needs approval")
+	if _, err := q.Expire(ctx, dropped.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Expire(ctx, dropped.ID); err == nil {
+		t.Error("Expected expiring twice to fail")
+	}
+
+	elsewhere, _ := q.Wait(ctx, "fix", "apps", "api", nil, "needs approval")
+	if err := q.Done(ctx, "fix", "apps", "api"); err != nil {
+		t.Fatal(err)
+	}
+	if actions, _ := q.List(ctx, Query{State: Done}); len(actions) != 1 || actions[0].ID != elsewhere.ID {
+		t.Errorf("List(done) = %+v, want the action taken elsewhere", actions)
+	}
+
+	now = now.Add(DefaultTTL + time.Minute)
+	q.Process(ctx)
+	actions, _ := q.List(ctx, Query{State: Expired})
+	expired := map[string]bool{}
+	for _, a := range actions {
+		expired[a.ID] = true
+	}
+	if want := map[string]bool{stale.ID: true, dropped.ID: true}; len(actions) != 2 || !reflect.DeepEqual(expired, want) {
+		t.Errorf("List(expired) = %+v, want %s and %s", actions, stale.ID, dropped.ID)
+	}
+	if _, err := q.Approve(ctx, stale.ID, "alice", ""); err == nil {
+		t.Error("Expected an expired action not to be approved")
+	}
+}
+
+func TestDir(t *testing.T) {
+	writer, reader, err := Dir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := New("drift-detector", writer, reader).Wait(context.Background(), "fix", "apps", "backend", nil, "needs approval")
+
+	actions, err := New("drift-detector", writer, reader).List(context.Background(), Query{})
+	if err != nil || len(actions) != 1 || actions[0].ID != a.ID {
+		t.Errorf("List after restart = %+v, %v", actions, err)
+	}
+}
+
+func TestListWithoutStore(t *testing.T) {
+	hub := &fakeHub{units: map[string]map[string]string{}}
+	q := New("drift-detector", hub.write, hub.read)
+	ctx := context.Background()
+	a, _ := q.Wait(ctx, "fix", "apps", "backend", nil, "needs approval")
+
+	hub.down = true
+	if actions, err := q.List(ctx, Query{}); err != nil || len(actions) != 1 || actions[0].ID != a.ID {
+		t.Errorf("List with ConfigHub down = %+v, %v, want the known action", actions, err)
+	}
+	if _, err := q.Wait(ctx, "fix", "apps", "frontend", nil, "needs approval"); err == nil {
+		t.Error("Expected an error storing with ConfigHub down")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	q := New("cost-impact-monitor", nil, nil)
+	ctx := context.Background()
+	a, _ := q.Wait(ctx, "apply", "apps", "payments", nil, "blocked")
+	q.Retry(ctx, "apply", "apps", "checkout", nil, errors.New("timeout"))
+
+	rec := httptest.NewRecorder()
+	q.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/queue?state=waiting", nil))
+	var list struct{ Actions []Action }
+	json.NewDecoder(rec.Body).Decode(&list)
+	if rec.Code != http.StatusOK || len(list.Actions) != 1 || list.Actions[0].ID != a.ID {
+		t.Fatalf("GET = %d %+v", rec.Code, list)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		user   *auth.User
+		status int
+	}{
+		{"no approver", "/api/v1/queue/" + a.ID + "/approve", `{}`, nil, http.StatusBadRequest},
+		{"unknown", "/api/v1/queue/nope/approve", `{"approver":"alice"}`, nil, http.StatusNotFound},
+		{"retry waiting", "/api/v1/queue/" + a.ID + "/retry", ``, nil, http.StatusConflict},
+		{"bad verb", "/api/v1/queue/" + a.ID + "/delete", ``, nil, http.StatusNotFound},
+		{"signed in", "/api/v1/queue/" + a.ID + "/approve", `{"approver":"mallory"}`, &auth.User{Email: "bob@example.com"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		if tt.user != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+		}
+		rec := httptest.NewRecorder()
+		q.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+
+	actions, _ := q.List(ctx, Query{State: Approved})
+	if len(actions) != 1 || actions[0].ApprovedBy != "bob@example.com" {
+		t.Errorf("Approved = %+v, want approved by the signed-in user", actions)
+	}
+}
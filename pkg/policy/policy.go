@@ -0,0 +1,359 @@
+// Package policy decides, in one place, whether the apps may change things on
+// their own: drift-detector and security-drift-detector applying fixes,
+// cost-optimizer applying recommendations and cost-impact-monitor approving
+// changes. A rule file lists rules matching an action by app, kind,
+// environment, severity, cost delta and time window; the first match decides
+// whether the action is allowed, denied or needs a human's approval:
+//
+//	rules:
+//	  - name: no-weekend-fixes
+//	    kinds: [fix]
+//	    environments: [production]
+//	    window: {days: [sat, sun]}
+//	    decision: deny
+//	  - name: safe-optimizations
+//	    apps: [cost-optimizer]
+//	    max_severity: low
+//	    cost_below: -20        # saves more than $20/month
+//	    decision: allow
+//	default: deny
+//
+// The AUTO_FIX and AUTO_APPLY_OPTIMIZATIONS switches still turn the apps'
+// remediation on; the policy decides which of their actions go through.
+// Every decision is recorded in the audit trail as policy.decided.
+//
+// Score is the shared risk scoring: the severity of a change by its monthly
+// cost delta and environment.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Verdicts of a decision
+const (
+	Allow   = "allow"   // the app goes ahead on its own
+	Deny    = "deny"    // the app leaves it alone
+	Approve = "approve" // a human has to approve it first
+)
+
+// Kinds of action
+const (
+	Fix          = "fix"          // re-apply a drifted unit
+	Optimization = "optimization" // apply a cost recommendation
+	Deployment   = "deployment"   // let a pending change through
+)
+
+// Levels are the severities, lowest first
+var Levels = []string{"low", "medium", "high", "critical"}
+
+// Rank orders severities: 1 for low to 4 for critical, 0 when unknown
+func Rank(level string) int {
+	for i, l := range Levels {
+		if strings.EqualFold(l, level) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Score rates a change by how much it moves monthly cost and whether it
+// goes to production, returning its severity and the reasons for it
+func Score(costDelta float64, environment string) (string, []string) {
+	level, factors := "low", []string{}
+	switch {
+	case costDelta > 500:
+		level = "critical"
+		factors = append(factors, "Very high cost increase")
+	case costDelta > 200:
+		level = "high"
+		factors = append(factors, "Significant cost increase")
+	case costDelta > 50:
+		level = "medium"
+		factors = append(factors, "Moderate cost increase")
+	}
+	if environment == "production" {
+		if level == "low" {
+			level = "medium"
+		}
+		factors = append(factors, "Production environment")
+	}
+	return level, factors
+}
+
+// Action is something an app would do on its own
+type Action struct {
+	App         string    `json:"app"`
+	Kind        string    `json:"kind"`
+	Target      string    `json:"target"`                // unit acted on
+	Environment string    `json:"environment,omitempty"` // the unit's "env" label
+	Severity    string    `json:"severity"`
+	CostDelta   float64   `json:"cost_delta"` // monthly; savings are negative
+	Time        time.Time `json:"time"`
+}
+
+// Decision is the verdict on an action and the rule that gave it
+type Decision struct {
+	Verdict string `json:"verdict"`
+	Rule    string `json:"rule"` // "default" when no rule matched
+}
+
+// Allowed reports whether the app may go ahead on its own
+func (d Decision) Allowed() bool {
+	return d.Verdict == Allow
+}
+
+// Window limits a rule to some days and hours
+type Window struct {
+	Days     []string `yaml:"days"`     // "mon" … "sun"; empty is every day
+	From     string   `yaml:"from"`     // "HH:MM", inclusive; empty is midnight
+	To       string   `yaml:"to"`       // "HH:MM", exclusive; before From crosses midnight
+	Timezone string   `yaml:"timezone"` // IANA name; empty is UTC
+}
+
+// Rule decides the actions it matches; empty fields match anything
+type Rule struct {
+	Name         string   `yaml:"name"`
+	Apps         []string `yaml:"apps"`
+	Kinds        []string `yaml:"kinds"`
+	Environments []string `yaml:"environments"`
+	MinSeverity  string   `yaml:"min_severity"`
+	MaxSeverity  string   `yaml:"max_severity"`
+	CostAbove    *float64 `yaml:"cost_above"` // cost delta strictly above
+	CostBelow    *float64 `yaml:"cost_below"` // cost delta strictly below
+	Window       *Window  `yaml:"window"`
+	Decision     string   `yaml:"decision"`
+}
+
+// Policy is a rule file. The zero Policy denies everything.
+type Policy struct {
+	Rules   []Rule `yaml:"rules"`
+	Default string `yaml:"default"` // verdict when no rule matches; empty is deny
+}
+
+// Default reproduces the apps' behaviour before there were rule files:
+// drift fixes go ahead, recommendations are applied when low risk and
+// saving more than $20/month, and pending changes are left to the
+// escalation policy
+func Default() *Policy {
+	savings := -20.0
+	return &Policy{
+		Rules: []Rule{
+			{Name: "drift-fixes", Apps: []string{"drift-detector", "security-drift-detector"}, Kinds: []string{Fix}, Decision: Allow},
+			{Name: "safe-optimizations", Apps: []string{"cost-optimizer"}, Kinds: []string{Optimization}, MaxSeverity: "low", CostBelow: &savings, Decision: Allow},
+			{Name: "escalation", Apps: []string{"cost-impact-monitor"}, Kinds: []string{Deployment}, Decision: Allow},
+		},
+		Default: Deny,
+	}
+}
+
+// Load reads and validates a rule file; a missing file yields Default
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// validate checks verdicts, severities and windows
+func (p *Policy) validate() error {
+	if p.Default == "" {
+		p.Default = Deny
+	}
+	if !verdict(p.Default) {
+		return fmt.Errorf("default: unknown decision %q", p.Default)
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func verdict(v string) bool {
+	return v == Allow || v == Deny || v == Approve
+}
+
+func (r *Rule) validate() error {
+	if !verdict(r.Decision) {
+		return fmt.Errorf("decision must be allow, deny or approve, not %q", r.Decision)
+	}
+	for _, level := range []string{r.MinSeverity, r.MaxSeverity} {
+		if level != "" && Rank(level) == 0 {
+			return fmt.Errorf("unknown severity %q", level)
+		}
+	}
+	if r.MinSeverity != "" && r.MaxSeverity != "" && Rank(r.MinSeverity) > Rank(r.MaxSeverity) {
+		return fmt.Errorf("min_severity is above max_severity")
+	}
+	if r.Window == nil {
+		return nil
+	}
+	for _, day := range r.Window.Days {
+		if weekday(day) < 0 {
+			return fmt.Errorf("window: unknown day %q", day)
+		}
+	}
+	if _, err := time.LoadLocation(r.Window.Timezone); err != nil {
+		return fmt.Errorf("window: %w", err)
+	}
+	if _, err := minutes(r.Window.From, 0); err != nil {
+		return fmt.Errorf("window from: %w", err)
+	}
+	if _, err := minutes(r.Window.To, 24*60); err != nil {
+		return fmt.Errorf("window to: %w", err)
+	}
+	return nil
+}
+
+func weekday(day string) time.Weekday {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(day, d.String()[:3]) || strings.EqualFold(day, d.String()) {
+			return d
+		}
+	}
+	return -1
+}
+
+// minutes parses "HH:MM" into minutes into the day
+func minutes(clock string, empty int) (int, error) {
+	if clock == "" {
+		return empty, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Decide returns the verdict of the first rule matching a, or the default.
+// A nil Policy decides by Default.
+func (p *Policy) Decide(a Action) Decision {
+	if p == nil {
+		p = Default()
+	}
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	for _, r := range p.Rules {
+		if r.matches(a) {
+			return Decision{Verdict: r.Decision, Rule: r.Name}
+		}
+	}
+	verdict := p.Default
+	if verdict == "" {
+		verdict = Deny
+	}
+	return Decision{Verdict: verdict, Rule: "default"}
+}
+
+func (r Rule) matches(a Action) bool {
+	switch {
+	case !matchAny(r.Apps, a.App), !matchAny(r.Kinds, a.Kind), !matchAny(r.Environments, a.Environment):
+		return false
+	case r.MinSeverity != "" && Rank(a.Severity) < Rank(r.MinSeverity):
+		return false
+	case r.MaxSeverity != "" && Rank(a.Severity) > Rank(r.MaxSeverity):
+		return false
+	case r.CostAbove != nil && a.CostDelta <= *r.CostAbove:
+		return false
+	case r.CostBelow != nil && a.CostDelta >= *r.CostBelow:
+		return false
+	}
+	return r.Window == nil || r.Window.contains(a.Time)
+}
+
+// matchAny reports whether list is empty or holds v or "*"
+func matchAny(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == "*" || strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether t falls in the window; windows that do not parse
+// (see validate) contain nothing
+func (w *Window) contains(t time.Time) bool {
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	from, err := minutes(w.From, 0)
+	if err != nil {
+		return false
+	}
+	to, err := minutes(w.To, 24*60)
+	if err != nil {
+		return false
+	}
+
+	t = t.In(location)
+	day := t.Weekday()
+	minute := t.Hour()*60 + t.Minute()
+	if from > to && minute < to {
+		day = (day + 6) % 7 // the small hours belong to the window opened the day before
+	}
+	if len(w.Days) > 0 {
+		found := false
+		for _, d := range w.Days {
+			found = found || weekday(d) == day
+		}
+		if !found {
+			return false
+		}
+	}
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// Check decides a, records the decision in the app's audit trail and logs
+// actions held back
+func (p *Policy) Check(ctx context.Context, log *audit.Log, a Action) Decision {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	d := p.Decide(a)
+	log.Record(ctx, audit.PolicyDecided, a.Target, struct {
+		Action
+		Decision
+	}{a, d}, nil)
+	if !d.Allowed() {
+		slog.Info("Policy holds back action", "app", a.App, "kind", a.Kind, logging.Unit(a.Target),
+			"severity", a.Severity, "cost_delta", a.CostDelta, "verdict", d.Verdict, "rule", d.Rule)
+	}
+	return d
+}
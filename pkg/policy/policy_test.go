@@ -0,0 +1,148 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		delta float64
+		env   string
+		want  string
+	}{
+		{10, "dev", "low"},
+		{10, "production", "medium"},
+		{-300, "dev", "low"},
+		{120, "dev", "medium"},
+		{300, "production", "high"},
+		{501, "staging", "critical"},
+	}
+	for _, tt := range tests {
+		if got, factors := Score(tt.delta, tt.env); got != tt.want || (got != "low" && len(factors) == 0) {
+			t.Errorf("Score(%v, %s) = %s, %v, want %s", tt.delta, tt.env, got, factors, tt.want)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	p := Default()
+	tests := []struct {
+		action Action
+		want   string
+	}{
+		{Action{App: "drift-detector", Kind: Fix, Severity: "medium"}, Allow},
+		{Action{App: "security-drift-detector", Kind: Fix, Severity: "high"}, Allow},
+		{Action{App: "cost-optimizer", Kind: Optimization, Severity: "low", CostDelta: -45}, Allow},
+		{Action{App: "cost-optimizer", Kind: Optimization, Severity: "low", CostDelta: -20}, Deny},
+		{Action{App: "cost-optimizer", Kind: Optimization, Severity: "medium", CostDelta: -500}, Deny},
+		{Action{App: "cost-impact-monitor", Kind: Deployment, Severity: "critical", CostDelta: 900}, Allow},
+		{Action{App: "orphan-cleaner", Kind: Fix}, Deny},
+	}
+	for _, tt := range tests {
+		if got := p.Decide(tt.action); got.Verdict != tt.want {
+			t.Errorf("Decide(%+v) = %+v, want %s", tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if p, err := Load(filepath.Join(dir, "missing.yaml")); err != nil || len(p.Rules) != len(Default().Rules) {
+		t.Fatalf("Load(missing) = %+v, %v, want the default", p, err)
+	}
+
+	if p, err := Load("policy.example.yaml"); err != nil || len(p.Rules) != 5 {
+		t.Errorf("Load(policy.example.yaml) = %+v, %v", p, err)
+	}
+
+	path := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(path, []byte(`
+rules:
+  - name: no-weekend-fixes
+    kinds: [fix]
+    environments: [production]
+    window: {days: [sat, sun]}
+    decision: deny
+  - name: night-changes
+    kinds: [deployment]
+    window: {from: "22:00", to: "06:00", days: [fri], timezone: Europe/Berlin}
+    decision: approve
+  - kinds: [fix]
+    max_severity: high
+    decision: allow
+default: approve
+`), 0o644)
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saturday := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	friday := time.Date(2026, 10, 16, 21, 0, 0, 0, time.UTC) // 23:00 in Berlin
+	saturdayNight := time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		action Action
+		want   Decision
+	}{
+		{Action{Kind: Fix, Environment: "production", Severity: "low", Time: saturday}, Decision{Deny, "no-weekend-fixes"}},
+		{Action{Kind: Fix, Environment: "production", Severity: "low", Time: monday}, Decision{Allow, "rule-3"}},
+		{Action{Kind: Fix, Environment: "staging", Severity: "critical", Time: monday}, Decision{Approve, "default"}},
+		{Action{Kind: Deployment, Time: friday}, Decision{Approve, "night-changes"}},
+		{Action{Kind: Deployment, Time: saturdayNight}, Decision{Approve, "night-changes"}}, // 04:00 Saturday, Friday's night
+		{Action{Kind: Deployment, Time: monday}, Decision{Approve, "default"}},
+	}
+	for _, tt := range tests {
+		if got := p.Decide(tt.action); got != tt.want {
+			t.Errorf("Decide(%+v) = %+v, want %+v", tt.action, got, tt.want)
+		}
+	}
+	if p.Decide(Action{Kind: Deployment, Time: monday.Add(4 * time.Hour)}).Rule != "default" {
+		t.Error("Expected the night window to be closed on Monday afternoon")
+	}
+
+	for name, doc := range map[string]string{
+		"decision": "rules: [{decision: maybe}]",
+		"severity": "rules: [{decision: allow, max_severity: huge}]",
+		"range":    "rules: [{decision: allow, min_severity: high, max_severity: low}]",
+		"day":      "rules: [{decision: allow, window: {days: [someday]}}]",
+		"clock":    "rules: [{decision: allow, window: {from: '25:00'}}]",
+		"timezone": "rules: [{decision: allow, window: {timezone: Mars/Olympus}}]",
+		"default":  "default: sometimes",
+	} {
+		os.WriteFile(path, []byte(doc), 0o644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("Expected an error for a bad %s", name)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	log := audit.New("cost-optimizer", nil, nil)
+	var p *Policy // the default
+	a := Action{App: "cost-optimizer", Kind: Optimization, Target: "api", Severity: "low", CostDelta: -45}
+	if d := p.Check(context.Background(), log, a); !d.Allowed() {
+		t.Errorf("Check = %+v, want allowed", d)
+	}
+
+	entries, _, _ := log.Entries(context.Background(), audit.Query{Action: audit.PolicyDecided})
+	if len(entries) != 1 || entries[0].Target != "api" {
+		t.Fatalf("Entries = %+v", entries)
+	}
+	var input map[string]interface{}
+	json.Unmarshal(entries[0].Input, &input)
+	if input["verdict"] != Allow || input["rule"] != "safe-optimizations" || input["kind"] != Optimization {
+		t.Errorf("Input = %s", entries[0].Input)
+	}
+	if !strings.Contains(string(entries[0].Input), `"cost_delta":-45`) {
+		t.Errorf("Input = %s, want the cost delta", entries[0].Input)
+	}
+}
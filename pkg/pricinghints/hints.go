@@ -0,0 +1,164 @@
+// Package pricinghints defines the label schema DevOps apps use to price
+// ConfigHub units without parsing their manifests.
+//
+// A unit describes what one replica requests, in Kubernetes resource format,
+// and how many replicas run:
+//
+//	cpu: "500m"        CPU per replica (Kubernetes quantity)
+//	memory: "1Gi"      memory per replica (Kubernetes quantity)
+//	storage: "20Gi"    persistent storage per replica (Kubernetes quantity)
+//	gpu: "1"           GPUs per replica (whole number)
+//	replicas: "3"      replica count (whole number, default 1)
+//
+// The same keys may be set as annotations; labels win when both are present.
+package pricinghints
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/costmodel"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Schema keys
+const (
+	KeyCPU      = "cpu"
+	KeyMemory   = "memory"
+	KeyStorage  = "storage"
+	KeyGPU      = "gpu"
+	KeyReplicas = "replicas"
+)
+
+// HoursPerMonth is the billing month used for hourly rates
+const HoursPerMonth = costmodel.HoursPerMonth
+
+// Hints are the parsed pricing hints of a unit
+type Hints struct {
+	CPUCores  float64 `json:"cpu_cores"`  // per replica
+	MemoryGB  float64 `json:"memory_gb"`  // per replica
+	StorageGB float64 `json:"storage_gb"` // per replica
+	GPUs      int     `json:"gpus"`       // per replica
+	Replicas  int     `json:"replicas"`
+}
+
+// HasResources reports whether any resource hint was given
+func (h Hints) HasResources() bool {
+	return h.CPUCores > 0 || h.MemoryGB > 0 || h.StorageGB > 0 || h.GPUs > 0
+}
+
+// FieldError is one invalid hint
+type FieldError struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s=%q: %s", e.Key, e.Value, e.Reason)
+}
+
+// ValidationError lists every invalid hint on a unit
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "invalid pricing hints: " + strings.Join(msgs, "; ")
+}
+
+// Parse reads hints from a unit's labels and annotations. Invalid hints are
+// left out of the result and reported together as a ValidationError, so
+// callers can still price the unit from the valid ones.
+func Parse(labels, annotations map[string]string) (Hints, error) {
+	h := Hints{Replicas: 1}
+	var errs ValidationError
+
+	lookup := func(key string) (string, bool) {
+		if v, ok := labels[key]; ok {
+			return v, true
+		}
+		v, ok := annotations[key]
+		return v, ok
+	}
+
+	quantities := []struct {
+		key   string
+		scale float64
+		dst   *float64
+	}{
+		{KeyCPU, 1, &h.CPUCores},
+		{KeyMemory, 1 << 30, &h.MemoryGB},
+		{KeyStorage, 1 << 30, &h.StorageGB},
+	}
+	for _, q := range quantities {
+		value, ok := lookup(q.key)
+		if !ok {
+			continue
+		}
+		v, err := ParseQuantity(q.key, value)
+		if err != nil {
+			errs = append(errs, err.(FieldError))
+			continue
+		}
+		*q.dst = v / q.scale
+	}
+
+	counts := []struct {
+		key string
+		min int
+		dst *int
+	}{
+		{KeyGPU, 0, &h.GPUs},
+		{KeyReplicas, 0, &h.Replicas},
+	}
+	for _, c := range counts {
+		value, ok := lookup(c.key)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < c.min {
+			errs = append(errs, FieldError{Key: c.key, Value: value, Reason: "must be a whole number of at least 0"})
+			continue
+		}
+		*c.dst = n
+	}
+
+	if len(errs) > 0 {
+		return h, errs
+	}
+	return h, nil
+}
+
+// ParseQuantity parses a Kubernetes resource quantity hint into base units
+// (cores for cpu, bytes for memory and storage)
+func ParseQuantity(key, value string) (float64, error) {
+	q, err := resource.ParseQuantity(strings.TrimSpace(value))
+	if err != nil {
+		return 0, FieldError{Key: key, Value: value, Reason: "not a Kubernetes quantity (e.g. 500m, 2, 512Mi, 10Gi)"}
+	}
+	if q.Sign() < 0 {
+		return 0, FieldError{Key: key, Value: value, Reason: "must not be negative"}
+	}
+	return q.AsApproximateFloat64(), nil
+}
+
+// Rates are the prices hints are charged at, a provider's from costmodel
+type Rates = costmodel.Pricing
+
+// DefaultRates match AWS EKS on-demand pricing in us-east-1
+var DefaultRates = costmodel.Default
+
+// Resources are the hints as costmodel prices them
+func (h Hints) Resources() costmodel.Resources {
+	return costmodel.Resources{CPUCores: h.CPUCores, MemoryGB: h.MemoryGB, StorageGB: h.StorageGB, GPUs: h.GPUs, Replicas: h.Replicas}
+}
+
+// MonthlyCost prices the hints for all replicas
+func (h Hints) MonthlyCost(r Rates) float64 {
+	return r.MonthlyCost(h.Resources())
+}
@@ -0,0 +1,85 @@
+package pricinghints
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	labels := map[string]string{"cpu": "500m", "memory": "2Gi", "replicas": "3"}
+	annotations := map[string]string{"storage": "10Gi", "gpu": "1", "cpu": "4"}
+
+	h, err := Parse(labels, annotations)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := Hints{CPUCores: 0.5, MemoryGB: 2, StorageGB: 10, GPUs: 1, Replicas: 3}
+	if h != want {
+		t.Errorf("hints = %+v, want %+v", h, want)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	h, err := Parse(nil, nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if h.Replicas != 1 || h.HasResources() {
+		t.Errorf("hints = %+v, want one replica and no resources", h)
+	}
+}
+
+func TestParseReportsEveryInvalidHint(t *testing.T) {
+	h, err := Parse(map[string]string{
+		"cpu":      "two",
+		"memory":   "-1Gi",
+		"replicas": "2.5",
+		"storage":  "5Gi",
+	}, nil)
+
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want ValidationError", err)
+	}
+	if len(verr) != 3 {
+		t.Fatalf("got %d field errors, want 3: %v", len(verr), verr)
+	}
+	for i, key := range []string{"cpu", "memory", "replicas"} {
+		if verr[i].Key != key {
+			t.Errorf("error %d is for %s, want %s", i, verr[i].Key, key)
+		}
+	}
+
+	// Valid hints are still usable
+	if h.StorageGB != 5 || h.Replicas != 1 {
+		t.Errorf("hints = %+v, want storage kept and default replicas", h)
+	}
+}
+
+func TestMonthlyCost(t *testing.T) {
+	h := Hints{CPUCores: 1, MemoryGB: 4, StorageGB: 20, GPUs: 1, Replicas: 2}
+
+	// per replica: 1*0.024*720 + 4*0.006*720 + 0.35*720 + 20*0.10 = 17.28 + 17.28 + 252 + 2
+	want := 2 * (17.28 + 17.28 + 252 + 2)
+	if got := h.MonthlyCost(DefaultRates); math.Abs(got-want) > 0.001 {
+		t.Errorf("MonthlyCost = %.3f, want %.3f", got, want)
+	}
+}
+
+// BenchmarkMonthlyCost converts a unit's hint labels to its monthly cost,
+// which the cost apps do for every unit of every space on each cycle
+func BenchmarkMonthlyCost(b *testing.B) {
+	labels := map[string]string{"cpu": "500m", "memory": "2Gi", "storage": "10Gi", "replicas": "3"}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		h, err := Parse(labels, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if h.MonthlyCost(DefaultRates) <= 0 {
+			b.Fatal("Expected a cost")
+		}
+	}
+}
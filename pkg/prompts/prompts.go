@@ -0,0 +1,247 @@
+// Package prompts renders the apps' AI prompts from Go templates
+// (text/template). Each app builds its prompts in; a ConfigHub unit can
+// replace one without a rebuild:
+//
+//	# unit "prompt-drift-analysis" in the space named by PROMPTS_SPACE
+//	Explain this drift to an on-call engineer, then reply in JSON ...
+//	{{json .Items}}
+//
+// The unit's revision is the prompt's version, so every edit in ConfigHub
+// is kept and reverting the unit rolls the prompt back. Units are re-read
+// every PROMPTS_REFRESH. A template that fails to parse or render is logged
+// and the built-in one used instead; deleting the unit restores it for good.
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/logging"
+)
+
+// UnitPrefix starts the slug of every prompt unit; the rest is the prompt's name
+const UnitPrefix = "prompt-"
+
+// Prompt is a built-in template. Templates may call json to indent a value.
+type Prompt struct {
+	Name string
+	Text string
+}
+
+// Unit is a template read from ConfigHub
+type Unit struct {
+	Slug     string
+	Data     string
+	Revision int64
+}
+
+// Source returns the units of the prompts space, prompt units or not
+type Source func(ctx context.Context) ([]Unit, error)
+
+// State is a prompt's current template and where it came from
+type State struct {
+	Source   string `json:"source"`  // "builtin" or "confighub"
+	Version  int64  `json:"version"` // unit revision; 0 for the built-in template
+	Template string `json:"template"`
+}
+
+// override is a parsed template from ConfigHub
+type override struct {
+	text    string
+	version int64
+	tmpl    *template.Template
+}
+
+// Set is an app's prompts. It is safe for concurrent use, and a nil Set
+// renders the built-in templates.
+type Set struct {
+	builtins map[string]Prompt
+	source   Source
+
+	mu        sync.RWMutex
+	overrides map[string]override
+}
+
+// New returns the prompts of an app; a nil source never overrides them
+func New(builtins []Prompt, source Source) *Set {
+	s := &Set{builtins: map[string]Prompt{}, source: source, overrides: map[string]override{}}
+	for _, p := range builtins {
+		s.builtins[p.Name] = p
+	}
+	return s
+}
+
+// Render executes p's template from ConfigHub, or p itself when there is
+// none or it fails
+func (s *Set) Render(p Prompt, data interface{}) (string, error) {
+	if s != nil {
+		s.mu.RLock()
+		o, ok := s.overrides[p.Name]
+		s.mu.RUnlock()
+		if ok {
+			text, err := execute(o.tmpl, data)
+			if err == nil {
+				return text, nil
+			}
+			slog.Warn("Prompt from ConfigHub failed, using the built-in one", "prompt", p.Name, "version", o.version, logging.Err(err))
+		}
+	}
+
+	tmpl, err := parse(p.Name, p.Text)
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+// Snapshot returns every built-in prompt and the template it renders with
+func (s *Set) Snapshot() map[string]State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	states := make(map[string]State, len(s.builtins))
+	for name, p := range s.builtins {
+		states[name] = State{Source: "builtin", Template: p.Text}
+	}
+	for name, o := range s.overrides {
+		states[name] = State{Source: "confighub", Version: o.version, Template: o.text}
+	}
+	return states
+}
+
+// Refresh re-reads the templates. On a read error the previous ones stay;
+// units that don't parse, or name no built-in prompt, are skipped.
+func (s *Set) Refresh(ctx context.Context) error {
+	if s.source == nil {
+		return nil
+	}
+	units, err := s.source(ctx)
+	if err != nil {
+		return fmt.Errorf("read prompts: %w", err)
+	}
+
+	overrides := map[string]override{}
+	for _, u := range units {
+		name, ok := strings.CutPrefix(u.Slug, UnitPrefix)
+		if !ok {
+			continue
+		}
+		if _, known := s.builtins[name]; !known {
+			slog.Debug("Ignoring unit of an unknown prompt", "unit", u.Slug)
+			continue
+		}
+		tmpl, err := parse(name, u.Data)
+		if err != nil {
+			slog.Warn("Ignoring prompt that does not parse", "prompt", name, "version", u.Revision, logging.Err(err))
+			continue
+		}
+		overrides[name] = override{text: u.Data, version: u.Revision, tmpl: tmpl}
+	}
+
+	s.mu.Lock()
+	before := s.overrides
+	s.overrides = overrides
+	s.mu.Unlock()
+
+	logChanges(before, overrides)
+	return nil
+}
+
+// Watch refreshes every interval until ctx is done
+func (s *Set) Watch(ctx context.Context, interval time.Duration) {
+	if s.source == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Refresh(ctx); err != nil {
+			slog.Warn("Failed to refresh prompts", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Listing is the body of GET /api/v1/prompts
+type Listing struct {
+	Prompts map[string]State `json:"prompts"`
+}
+
+// Operations document the prompts route of an app's API
+var Operations = []api.Operation{{Summary: "AI prompt templates and the versions in use", Response: Listing{}}}
+
+// ServeHTTP returns the prompts as JSON
+func (s *Set) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Listing{Prompts: s.Snapshot()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// logChanges reports prompts whose version changed
+func logChanges(before, after map[string]override) {
+	names := map[string]bool{}
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		old, hadOld := before[name]
+		o, has := after[name]
+		switch {
+		case has && (!hadOld || old.version != o.version || old.text != o.text):
+			slog.Info("Prompt loaded from ConfigHub", "prompt", name, "version", o.version)
+		case hadOld && !has:
+			slog.Info("Prompt reverted to the built-in one", "prompt", name)
+		}
+	}
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+}
+
+func parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render prompt %s: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
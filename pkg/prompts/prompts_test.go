@@ -0,0 +1,101 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+var greeting = Prompt{Name: "greeting", Text: "Hello {{.Name}}, items:\n{{json .Items}}"}
+
+type greetingData struct {
+	Name  string
+	Items []string
+}
+
+func TestRenderBuiltin(t *testing.T) {
+	data := greetingData{Name: "ops", Items: []string{"a"}}
+	want := "Hello ops, items:\n[\n  \"a\"\n]"
+
+	var nilSet *Set
+	for _, s := range []*Set{nilSet, New([]Prompt{greeting}, nil)} {
+		got, err := s.Render(greeting, data)
+		if err != nil || got != want {
+			t.Errorf("Render = %q, %v, want %q", got, err, want)
+		}
+	}
+
+	if _, err := New(nil, nil).Render(Prompt{Name: "broken", Text: "{{.Name"}, data); err == nil {
+		t.Error("unparsable built-in rendered")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	units, readErr := []Unit{
+		{Slug: "prompt-greeting", Data: "Hi {{.Name}}", Revision: 3},
+		{Slug: "prompt-unknown", Data: "ignored"},
+		{Slug: "feature-flags", Data: "auto_fix: true"},
+	}, error(nil)
+	s := New([]Prompt{greeting}, func(context.Context) ([]Unit, error) { return units, readErr })
+	ctx := context.Background()
+	data := greetingData{Name: "ops"}
+
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Render(greeting, data); got != "Hi ops" {
+		t.Errorf("Render = %q, want the ConfigHub template", got)
+	}
+	snap := s.Snapshot()
+	if st := snap["greeting"]; st.Source != "confighub" || st.Version != 3 || len(snap) != 1 {
+		t.Errorf("Snapshot = %+v", snap)
+	}
+
+	// A template that fails to render falls back to the built-in one
+	units[0] = Unit{Slug: "prompt-greeting", Data: "Hi {{.Team}}", Revision: 4}
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Render(greeting, data); err != nil || got != "Hello ops, items:\nnull" {
+		t.Errorf("Render = %q, %v, want the built-in template", got, err)
+	}
+
+	// A read error keeps the templates; one that doesn't parse is skipped
+	readErr = errors.New("confighub unavailable")
+	if err := s.Refresh(ctx); err == nil {
+		t.Error("read error not returned")
+	}
+	if s.Snapshot()["greeting"].Version != 4 {
+		t.Error("template lost on read error")
+	}
+	units[0], readErr = Unit{Slug: "prompt-greeting", Data: "{{if}}", Revision: 5}, nil
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Snapshot()["greeting"]; st.Source != "builtin" || st.Version != 0 {
+		t.Errorf("unparsable template used: %+v", st)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := New([]Prompt{greeting}, nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/api/prompts", nil))
+	var body struct {
+		Prompts map[string]State `json:"prompts"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if st := body.Prompts["greeting"]; st.Source != "builtin" || st.Template != greeting.Text {
+		t.Errorf("served %+v", body.Prompts)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", "/api/prompts", nil))
+	if rec.Code != 405 {
+		t.Errorf("POST = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,211 @@
+// Package ratelimit keeps an app's ConfigHub traffic within a budget. The
+// apps list spaces and units on timers, informer events and webhooks, often
+// for the same space at the same moment; a Limiter makes those calls share
+// one token bucket and collapses identical concurrent reads into one request:
+//
+//	units, err := ratelimit.Call(ctx, limiter, "ListUnits", spaceID.String(), func() ([]*sdk.Unit, error) {
+//		return app.Cub.ListUnits(params)
+//	})
+//
+// A limiter guarded by a circuit breaker (see Guard) fails fast while
+// ConfigHub is down, without spending tokens. Reads failing with a
+// retryable error (see pkg/errkind) are sent again, up to Attempts times. Counters for requests,
+// throttled requests and coalesced reads are served in the Prometheus text
+// format by the Limiter's ServeHTTP.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// Stats counts one kind of call
+type Stats struct {
+	Requests  int64         `json:"requests"`  // calls sent to ConfigHub
+	Throttled int64         `json:"throttled"` // of those, calls that waited for a token
+	Coalesced int64         `json:"coalesced"` // calls answered by another caller's request
+	Retried   int64         `json:"retried"`   // requests sent again after a retryable error
+	Wait      time.Duration `json:"wait"`      // total time spent waiting for tokens
+}
+
+// Attempts is how many times Call sends a read that keeps failing with a
+// retryable error
+const Attempts = 3
+
+// Limiter is a token bucket shared by all of an app's ConfigHub calls. It is
+// safe for concurrent use; a nil Limiter runs every call immediately.
+type Limiter struct {
+	bucket  *rate.Limiter
+	group   singleflight.Group
+	breaker *breaker.Breaker
+
+	mu    sync.Mutex
+	stats map[string]*Stats
+}
+
+// New returns a limiter allowing perSecond calls with bursts of burst.
+// perSecond <= 0 disables throttling but keeps coalescing and counters.
+func New(perSecond float64, burst int) *Limiter {
+	limit := rate.Limit(perSecond)
+	if perSecond <= 0 {
+		limit = rate.Inf
+	}
+	return &Limiter{bucket: rate.NewLimiter(limit, burst), stats: map[string]*Stats{}}
+}
+
+// Guard makes calls through l fail with breaker.ErrOpen while b is open. A
+// coalesced call counts once towards b.
+func (l *Limiter) Guard(b *breaker.Breaker) *Limiter {
+	l.breaker = b
+	return l
+}
+
+// Wait blocks until a call named name may be sent, or ctx is done
+func (l *Limiter) Wait(ctx context.Context, name string) error {
+	if l == nil {
+		return nil
+	}
+	reservation := l.bucket.Reserve()
+	if !reservation.OK() {
+		return fmt.Errorf("%s: rate limiter burst is zero", name)
+	}
+	delay := reservation.Delay()
+
+	l.mu.Lock()
+	s := l.statsFor(name)
+	s.Requests++
+	if delay > 0 {
+		s.Throttled++
+		s.Wait += delay
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
+
+// Call runs fn once a token is available, again after retryable errors. Concurrent calls with the same
+// name and key share a single fn call and its result, so results must be
+// treated as read-only. An empty key never coalesces.
+func Call[T any](ctx context.Context, l *Limiter, name, key string, fn func() (T, error)) (T, error) {
+	if l == nil {
+		return fn()
+	}
+	run := func() (T, error) {
+		for attempt := 1; ; attempt++ {
+			result, err := breaker.Call(l.breaker, func() (T, error) {
+				if err := l.Wait(ctx, name); err != nil {
+					var zero T
+					return zero, err
+				}
+				return fn()
+			})
+			if err == nil || attempt == Attempts || !errkind.IsRetryable(err) || errors.Is(err, breaker.ErrOpen) || ctx.Err() != nil {
+				return result, err
+			}
+			timer := time.NewTimer(errkind.Delay(err, attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return result, err
+			}
+			l.mu.Lock()
+			l.statsFor(name).Retried++
+			l.mu.Unlock()
+		}
+	}
+	if key == "" {
+		return run()
+	}
+
+	ran := false // only the caller whose fn ran sent a request
+	v, err, _ := l.group.Do(name+"\x00"+key, func() (interface{}, error) {
+		ran = true
+		return run()
+	})
+	if !ran {
+		l.mu.Lock()
+		l.statsFor(name).Coalesced++
+		l.mu.Unlock()
+	}
+	result, _ := v.(T)
+	return result, err
+}
+
+// Stats returns the counters of each call name
+func (l *Limiter) Stats() map[string]Stats {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]Stats, len(l.stats))
+	for name, s := range l.stats {
+		stats[name] = *s
+	}
+	return stats
+}
+
+// statsFor returns name's counters; l.mu must be held
+func (l *Limiter) statsFor(name string) *Stats {
+	s, ok := l.stats[name]
+	if !ok {
+		s = &Stats{}
+		l.stats[name] = s
+	}
+	return s
+}
+
+// ServeHTTP writes the counters in the Prometheus text format
+func (l *Limiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stats := l.Stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics := []struct {
+		name, help, kind string
+		value            func(Stats) string
+	}{
+		{"confighub_requests_total", "ConfigHub requests sent.", "counter",
+			func(s Stats) string { return fmt.Sprint(s.Requests) }},
+		{"confighub_requests_throttled_total", "ConfigHub requests delayed by the rate limiter.", "counter",
+			func(s Stats) string { return fmt.Sprint(s.Throttled) }},
+		{"confighub_requests_coalesced_total", "ConfigHub reads answered by a concurrent identical request.", "counter",
+			func(s Stats) string { return fmt.Sprint(s.Coalesced) }},
+		{"confighub_requests_retried_total", "ConfigHub reads sent again after a retryable error.", "counter",
+			func(s Stats) string { return fmt.Sprint(s.Retried) }},
+		{"confighub_throttle_wait_seconds_total", "Time spent waiting for the rate limiter.", "counter",
+			func(s Stats) string { return fmt.Sprintf("%g", s.Wait.Seconds()) }},
+	}
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{call=%q} %s\n", m.name, name, m.value(stats[name]))
+		}
+	}
+}
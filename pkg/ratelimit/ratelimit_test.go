@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/errkind"
+)
+
+func TestWaitThrottles(t *testing.T) {
+	l := New(50, 1)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "ListSpaces"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("3 calls at 50/s with burst 1 took %s", elapsed)
+	}
+	s := l.Stats()["ListSpaces"]
+	if s.Requests != 3 || s.Throttled != 2 || s.Wait <= 0 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	slow := New(0.001, 1)
+	slow.Wait(ctx, "ListUnits") // uses up the only token
+	if err := slow.Wait(cancelled, "ListUnits"); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled wait = %v", err)
+	}
+}
+
+func TestCallCoalesces(t *testing.T) {
+	l := New(0, 1)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() ([]string, error) {
+		calls.Add(1)
+		<-release
+		return []string{"backend"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = Call(context.Background(), l, "ListUnits", "space-1", fn)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond) // let every caller join the in-flight call
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+	for i, r := range results {
+		if len(r) != 1 || r[0] != "backend" {
+			t.Errorf("caller %d got %v", i, r)
+		}
+	}
+	if s := l.Stats()["ListUnits"]; s.Requests != 1 || s.Coalesced != 4 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	// Different keys and empty keys are separate requests
+	Call(context.Background(), l, "ListUnits", "space-2", func() (int, error) { return 0, nil })
+	Call(context.Background(), l, "ListUnits", "", func() (int, error) { return 0, nil })
+	if s := l.Stats()["ListUnits"]; s.Requests != 3 {
+		t.Errorf("requests = %d, want 3", s.Requests)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	got, err := Call(context.Background(), l, "ListSpaces", "all", func() (string, error) { return "ok", nil })
+	if got != "ok" || err != nil {
+		t.Errorf("Call = %q, %v", got, err)
+	}
+	if err := l.Wait(context.Background(), "ListSpaces"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	l := New(0, 1)
+	l.Wait(context.Background(), "ListUnits")
+
+	rec := httptest.NewRecorder()
+	l.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE confighub_requests_total counter",
+		`confighub_requests_total{call="ListUnits"} 1`,
+		`confighub_requests_throttled_total{call="ListUnits"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	b := breaker.New("confighub", 1, time.Minute)
+	l := New(0, 1).Guard(b)
+	down := func() (int, error) { return 0, errors.New("503 Service Unavailable") }
+
+	Call(context.Background(), l, "ListSpaces", "all", down)
+	calls := 0
+	_, err := Call(context.Background(), l, "ListSpaces", "all", func() (int, error) { calls++; return 1, nil })
+	if !errors.Is(err, breaker.ErrOpen) || calls != 0 {
+		t.Errorf("open breaker = %v after %d calls", err, calls)
+	}
+	if s := l.Stats()["ListSpaces"]; s.Requests != 1 {
+		t.Errorf("requests = %d, want only the failed one", s.Requests)
+	}
+}
+
+func TestCallRetries(t *testing.T) {
+	l := New(0, 1)
+	ctx := context.Background()
+
+	calls := 0
+	got, err := Call(ctx, l, "ListUnits", "payments", func() (int, error) {
+		if calls++; calls < 2 {
+			return 0, &errkind.HTTPError{Service: "confighub", StatusCode: 503}
+		}
+		return 7, nil
+	})
+	if got != 7 || err != nil || calls != 2 {
+		t.Errorf("Call = %d, %v after %d calls, want 7 after a retry", got, err, calls)
+	}
+
+	calls = 0
+	_, err = Call(ctx, l, "ListUnits", "billing", func() (int, error) {
+		calls++
+		return 0, &errkind.HTTPError{Service: "confighub", StatusCode: 404}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("missing space = %v after %d calls, want no retry", err, calls)
+	}
+	if s := l.Stats()["ListUnits"]; s.Retried != 1 || s.Requests != 3 {
+		t.Errorf("stats = %+v, want 1 retried of 3 requests", s)
+	}
+}
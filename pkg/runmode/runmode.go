@@ -0,0 +1,158 @@
+// Package runmode lets an app run once as a job instead of as a service.
+//
+// Started with --mode=job (or RUN_MODE=job) an app runs one full analysis,
+// writing to ConfigHub whatever its cycle writes, stores the result as a
+// unit of the space JOB_SPACE names when set, prints the result as JSON to
+// stdout and exits, with status 1 when the analysis or storing it failed.
+// Teams that don't need continuous monitoring run the apps as Kubernetes
+// CronJobs this way instead of as always-on Deployments.
+package runmode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+)
+
+// The modes an app runs in
+const (
+	Service = "service"
+	Job     = "job"
+)
+
+const (
+	// EnvVar selects the mode when no --mode flag is given
+	EnvVar = "RUN_MODE"
+	// SpaceEnvVar names the space a job stores its result in
+	SpaceEnvVar = "JOB_SPACE"
+	// Label marks the units holding job results
+	Label = "job-result"
+)
+
+// Writer creates the unit with the given slug, labels and data, like
+// audit.Writer
+type Writer func(ctx context.Context, slug string, labels map[string]string, data string) error
+
+// Result is what a job prints and stores
+type Result[R any] struct {
+	App       string    `json:"app"`
+	Mode      string    `json:"mode"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+	Error     string    `json:"error,omitempty"`
+	Report    R         `json:"report"`
+}
+
+// Mode returns the mode the process was started in: the value of a --mode
+// flag, else of RUN_MODE, else Service
+func Mode() string {
+	return mode(os.Args[1:], os.Getenv)
+}
+
+// IsJob reports whether the process runs as a job
+func IsJob() bool {
+	return Mode() == Job
+}
+
+// Space returns the slug of the space job results are stored in, empty
+// when they aren't stored
+func Space() string {
+	return os.Getenv(SpaceEnvVar)
+}
+
+// mode scans args itself rather than using the flag package, since the apps
+// leave their arguments to subcommands such as "demo"
+func mode(args []string, getenv func(string) string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "mode" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		return normalize(value)
+	}
+	return normalize(getenv(EnvVar))
+}
+
+func normalize(value string) string {
+	if strings.EqualFold(strings.TrimSpace(value), Job) {
+		return Job
+	}
+	return Service
+}
+
+// Once runs analyze once and writes the result, with report's value after
+// it, as JSON to w and through store when not nil. The result is written
+// even when the analysis failed, so the failure can be inspected; the
+// returned error is the analysis' joined with storing's.
+func Once[R any](ctx context.Context, app string, analyze func(context.Context) error, report func() R, w io.Writer, store Writer) error {
+	started := time.Now()
+	err := analyze(ctx)
+	result := Result[R]{
+		App:       app,
+		Mode:      Job,
+		StartedAt: started.UTC(),
+		Duration:  time.Since(started).Round(time.Millisecond).String(),
+		Report:    report(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	data, merr := json.MarshalIndent(result, "", "  ")
+	if merr != nil {
+		return errors.Join(err, fmt.Errorf("encode result: %w", merr))
+	}
+	if _, werr := fmt.Fprintf(w, "%s\n", data); werr != nil {
+		err = errors.Join(err, fmt.Errorf("print result: %w", werr))
+	}
+	if store != nil {
+		status := "succeeded"
+		if result.Error != "" {
+			status = "failed"
+		}
+		slug := fmt.Sprintf("%s-job-%s", app, started.UTC().Format("20060102-150405"))
+		labels := map[string]string{Label: "true", "app": app, "status": status}
+		if serr := store(ctx, slug, labels, string(data)); serr != nil {
+			err = errors.Join(err, fmt.Errorf("store result: %w", serr))
+		}
+	}
+	return err
+}
+
+// Store returns the Writer a job stores its result with, as units of the
+// space with slug space; nil without one, so the result is only printed.
+// listSpaces and create are the ConfigHub client's, called through limit
+// and so failing fast while its breaker is open; spaceOf returns a listed
+// space's slug and ID.
+func Store[S any](limit *ratelimit.Limiter, space string, listSpaces func() ([]S, error), spaceOf func(S) (string, uuid.UUID),
+	create func(space uuid.UUID, slug string, labels map[string]string, data string) error) Writer {
+	if space == "" {
+		return nil
+	}
+	return func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		spaces, err := ratelimit.Call(ctx, limit, "ListSpaces", "all", listSpaces)
+		if err != nil {
+			return fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if spaceSlug, id := spaceOf(s); spaceSlug == space {
+				_, err := ratelimit.Call(ctx, limit, "CreateUnit", "", func() (struct{}, error) {
+					return struct{}{}, create(id, slug, labels, data)
+				})
+				return err
+			}
+		}
+		return fmt.Errorf("job space %s not found", space)
+	}
+}
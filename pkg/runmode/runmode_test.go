@@ -0,0 +1,129 @@
+package runmode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+)
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  string
+		want string
+	}{
+		{"default", nil, "", Service},
+		{"flag", []string{"--mode=job"}, "", Job},
+		{"single dash", []string{"-mode", "job"}, "", Job},
+		{"after subcommand", []string{"cost", "--mode", "JOB"}, "", Job},
+		{"env", nil, "job", Job},
+		{"flag over env", []string{"--mode=service"}, "job", Service},
+		{"unknown", []string{"--mode=batch"}, "", Service},
+		{"other flag", []string{"--model=job"}, "", Service},
+	}
+	for _, tt := range tests {
+		got := mode(tt.args, func(string) string { return tt.env })
+		if got != tt.want {
+			t.Errorf("%s: mode = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOnce(t *testing.T) {
+	type report struct{ Findings int }
+	findings := 0
+	analyze := func(context.Context) error {
+		findings = 3
+		return nil
+	}
+
+	var out bytes.Buffer
+	var stored map[string]string
+	var storedSlug string
+	store := func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		storedSlug, stored = slug, labels
+		return nil
+	}
+	err := Once(context.Background(), "orphan-cleaner", analyze, func() report { return report{findings} }, &out, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Result[report]
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.App != "orphan-cleaner" || result.Mode != Job || result.Report.Findings != 3 || result.Error != "" {
+		t.Errorf("Result = %+v", result)
+	}
+	if !strings.HasPrefix(storedSlug, "orphan-cleaner-job-") || stored[Label] != "true" || stored["status"] != "succeeded" {
+		t.Errorf("Stored %s with %v", storedSlug, stored)
+	}
+}
+
+func TestOnceFailed(t *testing.T) {
+	var out bytes.Buffer
+	var status string
+	store := func(ctx context.Context, slug string, labels map[string]string, data string) error {
+		status = labels["status"]
+		return errors.New("confighub unavailable")
+	}
+	analyze := func(context.Context) error { return errors.New("cluster unreachable") }
+
+	err := Once(context.Background(), "quota-advisor", analyze, func() *struct{} { return nil }, &out, store)
+	if err == nil || !strings.Contains(err.Error(), "cluster unreachable") || !strings.Contains(err.Error(), "store result") {
+		t.Errorf("Once = %v, want the analysis and store errors", err)
+	}
+	if !strings.Contains(out.String(), `"error": "cluster unreachable"`) || status != "failed" {
+		t.Errorf("Printed %s, stored status %q", out.String(), status)
+	}
+}
+
+func TestStore(t *testing.T) {
+	type space struct {
+		slug string
+		id   uuid.UUID
+	}
+	jobs := space{"jobs", uuid.New()}
+	list := func() ([]space, error) { return []space{{"platform", uuid.New()}, jobs}, nil }
+	of := func(s space) (string, uuid.UUID) { return s.slug, s.id }
+	var created []string
+	create := func(space uuid.UUID, slug string, labels map[string]string, data string) error {
+		if space != jobs.id {
+			t.Errorf("created %s in %s, want %s", slug, space, jobs.id)
+		}
+		created = append(created, slug)
+		return nil
+	}
+	ctx := context.Background()
+
+	if Store(nil, "", list, of, create) != nil {
+		t.Error("Store without a space is not nil")
+	}
+	if err := Store(nil, "jobs", list, of, create)(ctx, "quota-advisor-job-1", nil, "{}"); err != nil {
+		t.Errorf("store: %v", err)
+	}
+	if err := Store(nil, "reports", list, of, create)(ctx, "quota-advisor-job-2", nil, "{}"); err == nil {
+		t.Error("stored in a missing space")
+	}
+
+	open := breaker.New("confighub", 1, time.Hour)
+	open.Record(errors.New("confighub unavailable"))
+	limited := ratelimit.New(0, 1).Guard(open)
+	if err := Store(limited, "jobs", list, of, create)(ctx, "quota-advisor-job-3", nil, "{}"); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("store with the breaker open: %v, want %v", err, breaker.ErrOpen)
+	}
+	if !reflect.DeepEqual(created, []string{"quota-advisor-job-1"}) {
+		t.Errorf("created %v", created)
+	}
+}
@@ -0,0 +1,202 @@
+// Package sethelper manages ConfigHub sets through the API, for the install
+// scripts and tests that used `cub set` commands the CLI does not have:
+//
+//	devops-apps set create -space prod -label tier=critical critical-services
+//	devops-apps set add-unit -space prod critical-services backend-api frontend
+//	devops-apps set get -space prod critical-services | jq -r .set_id
+//
+// Creating a set that already exists returns the existing one, and adding or
+// removing a unit that is already in or out of the set changes nothing, so
+// scripts can run the commands again without `|| true`.
+package sethelper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is wrapped by the errors for a missing space, set or unit
+var ErrNotFound = errors.New("not found")
+
+// Hub is the part of ConfigHub sets are managed through
+type Hub interface {
+	// FindSpace returns the ID of the space with slug or ID ref, or false
+	// when there is none
+	FindSpace(ref string) (uuid.UUID, bool, error)
+	Sets(space uuid.UUID) ([]Set, error)
+	CreateSet(space uuid.UUID, set Set) (uuid.UUID, error)
+	Units(space uuid.UUID) ([]Unit, error)
+	// SetUnitSets replaces the sets unit is in, keeping its data and labels
+	SetUnitSets(space uuid.UUID, unit Unit, setIDs []uuid.UUID) error
+}
+
+// Set is a ConfigHub set
+type Set struct {
+	ID          uuid.UUID         `json:"set_id"`
+	Slug        string            `json:"slug"`
+	DisplayName string            `json:"display_name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Units       []string          `json:"units"` // slugs of the units in the set, filled by List and Get
+}
+
+// Unit is a ConfigHub unit; its data and labels are passed back unchanged
+// when its sets are
+type Unit struct {
+	ID     uuid.UUID
+	Slug   string
+	SetIDs []uuid.UUID
+	Data   string
+	Labels map[string]string
+}
+
+// space is a space's sets and units
+type space struct {
+	id    uuid.UUID
+	sets  []Set
+	units []Unit
+}
+
+func load(hub Hub, ref string) (*space, error) {
+	id, found, err := hub.FindSpace(ref)
+	if err != nil {
+		return nil, fmt.Errorf("find space: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("space %s: %w", ref, ErrNotFound)
+	}
+	s := &space{id: id}
+	if s.sets, err = hub.Sets(id); err != nil {
+		return nil, fmt.Errorf("list sets: %w", err)
+	}
+	if s.units, err = hub.Units(id); err != nil {
+		return nil, fmt.Errorf("list units: %w", err)
+	}
+
+	members := make(map[uuid.UUID][]string, len(s.sets))
+	for _, u := range s.units {
+		for _, setID := range u.SetIDs {
+			members[setID] = append(members[setID], u.Slug)
+		}
+	}
+	for i := range s.sets {
+		s.sets[i].Units = members[s.sets[i].ID]
+		if s.sets[i].Units == nil {
+			s.sets[i].Units = []string{}
+		}
+		sort.Strings(s.sets[i].Units)
+	}
+	sort.Slice(s.sets, func(i, j int) bool { return s.sets[i].Slug < s.sets[j].Slug })
+	return s, nil
+}
+
+func (s *space) set(slug string) (Set, error) {
+	for _, set := range s.sets {
+		if set.Slug == slug {
+			return set, nil
+		}
+	}
+	return Set{}, fmt.Errorf("set %s: %w", slug, ErrNotFound)
+}
+
+func (s *space) unit(slug string) (Unit, error) {
+	for _, u := range s.units {
+		if u.Slug == slug {
+			return u, nil
+		}
+	}
+	return Unit{}, fmt.Errorf("unit %s: %w", slug, ErrNotFound)
+}
+
+// List returns the sets of space, by slug, with their units
+func List(hub Hub, space string) ([]Set, error) {
+	s, err := load(hub, space)
+	if err != nil {
+		return nil, err
+	}
+	return s.sets, nil
+}
+
+// Get returns the set with slug in space, with its units
+func Get(hub Hub, space, slug string) (Set, error) {
+	s, err := load(hub, space)
+	if err != nil {
+		return Set{}, err
+	}
+	return s.set(slug)
+}
+
+// Create creates set in space unless a set with its slug is already there,
+// and returns the set and whether it was created
+func Create(hub Hub, space string, set Set) (Set, bool, error) {
+	s, err := load(hub, space)
+	if err != nil {
+		return Set{}, false, err
+	}
+	if existing, err := s.set(set.Slug); err == nil {
+		return existing, false, nil
+	}
+	if set.DisplayName == "" {
+		set.DisplayName = set.Slug
+	}
+	if set.ID, err = hub.CreateSet(s.id, set); err != nil {
+		return Set{}, false, fmt.Errorf("create set %s: %w", set.Slug, err)
+	}
+	set.Units = []string{}
+	return set, true, nil
+}
+
+// AddUnits puts the units with slugs units into set and returns how many
+// were not in it yet
+func AddUnits(hub Hub, space, set string, units ...string) (int, error) {
+	return change(hub, space, set, units, true)
+}
+
+// RemoveUnits takes the units with slugs units out of set and returns how
+// many were in it
+func RemoveUnits(hub Hub, space, set string, units ...string) (int, error) {
+	return change(hub, space, set, units, false)
+}
+
+func change(hub Hub, ref, slug string, units []string, add bool) (int, error) {
+	s, err := load(hub, ref)
+	if err != nil {
+		return 0, err
+	}
+	set, err := s.set(slug)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, unitSlug := range units {
+		u, err := s.unit(unitSlug)
+		if err != nil {
+			return changed, err
+		}
+		setIDs := make([]uuid.UUID, 0, len(u.SetIDs)+1)
+		member := false
+		for _, id := range u.SetIDs {
+			if id == set.ID {
+				member = true
+				if !add {
+					continue
+				}
+			}
+			setIDs = append(setIDs, id)
+		}
+		if member == add {
+			continue
+		}
+		if add {
+			setIDs = append(setIDs, set.ID)
+		}
+		if err := hub.SetUnitSets(s.id, u, setIDs); err != nil {
+			return changed, fmt.Errorf("update sets of unit %s: %w", u.Slug, err)
+		}
+		changed++
+	}
+	return changed, nil
+}
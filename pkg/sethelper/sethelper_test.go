@@ -0,0 +1,127 @@
+package sethelper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// memoryHub is an in-memory ConfigHub with one space, "prod"
+type memoryHub struct {
+	space   uuid.UUID
+	sets    []Set
+	units   []Unit
+	updates int
+}
+
+func newMemoryHub(units ...string) *memoryHub {
+	h := &memoryHub{space: uuid.New()}
+	for _, slug := range units {
+		h.units = append(h.units, Unit{ID: uuid.New(), Slug: slug, Data: "kind: Deployment", Labels: map[string]string{"app": slug}})
+	}
+	return h
+}
+
+func (h *memoryHub) FindSpace(ref string) (uuid.UUID, bool, error) {
+	return h.space, ref == "prod" || ref == h.space.String(), nil
+}
+
+func (h *memoryHub) Sets(space uuid.UUID) ([]Set, error) {
+	return append([]Set(nil), h.sets...), nil
+}
+
+func (h *memoryHub) CreateSet(space uuid.UUID, set Set) (uuid.UUID, error) {
+	set.ID = uuid.New()
+	h.sets = append(h.sets, set)
+	return set.ID, nil
+}
+
+func (h *memoryHub) Units(space uuid.UUID) ([]Unit, error) {
+	return append([]Unit(nil), h.units...), nil
+}
+
+func (h *memoryHub) SetUnitSets(space uuid.UUID, unit Unit, setIDs []uuid.UUID) error {
+	for i := range h.units {
+		if h.units[i].ID == unit.ID {
+			h.units[i] = unit
+			h.units[i].SetIDs = setIDs
+			h.updates++
+			return nil
+		}
+	}
+	return fmt.Errorf("no unit %s", unit.ID)
+}
+
+func TestCreateIsIdempotent(t *testing.T) {
+	hub := newMemoryHub()
+	set, created, err := Create(hub, "prod", Set{Slug: "critical-services", Labels: map[string]string{"tier": "critical"}})
+	if err != nil || !created || set.ID == uuid.Nil || set.DisplayName != "critical-services" {
+		t.Fatalf("Create = %+v, %v, %v", set, created, err)
+	}
+
+	again, created, err := Create(hub, "prod", Set{Slug: "critical-services"})
+	if err != nil || created || again.ID != set.ID || again.Labels["tier"] != "critical" {
+		t.Errorf("Create again = %+v, %v, %v; want the existing set", again, created, err)
+	}
+	if len(hub.sets) != 1 {
+		t.Errorf("Expected one set, got %d", len(hub.sets))
+	}
+
+	if _, _, err := Create(hub, "staging", Set{Slug: "critical-services"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Create in a missing space = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAddAndRemoveUnits(t *testing.T) {
+	hub := newMemoryHub("backend-api", "frontend", "cache")
+	if _, _, err := Create(hub, "prod", Set{Slug: "critical-services"}); err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := Create(hub, "prod", Set{Slug: "cost-tracked"})
+	if _, err := AddUnits(hub, "prod", "cost-tracked", "backend-api"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := AddUnits(hub, "prod", "critical-services", "backend-api", "frontend")
+	if err != nil || n != 2 {
+		t.Fatalf("AddUnits = %d, %v", n, err)
+	}
+	if n, _ := AddUnits(hub, "prod", "critical-services", "frontend"); n != 0 {
+		t.Errorf("Adding a member again changed %d units", n)
+	}
+	set, err := Get(hub, "prod", "critical-services")
+	if err != nil || !reflect.DeepEqual(set.Units, []string{"backend-api", "frontend"}) {
+		t.Errorf("Get = %+v, %v", set, err)
+	}
+	backend := hub.units[0]
+	if len(backend.SetIDs) != 2 || backend.Data != "kind: Deployment" || backend.Labels["app"] != "backend-api" {
+		t.Errorf("Expected backend-api in both sets with its data and labels, got %+v", backend)
+	}
+
+	n, err = RemoveUnits(hub, "prod", "critical-services", "backend-api", "cache")
+	if err != nil || n != 1 {
+		t.Errorf("RemoveUnits = %d, %v", n, err)
+	}
+	if ids := hub.units[0].SetIDs; len(ids) != 1 || ids[0] != other.ID {
+		t.Errorf("Expected backend-api left in cost-tracked only, got %v", ids)
+	}
+
+	sets, err := List(hub, "prod")
+	if err != nil || len(sets) != 2 || sets[0].Slug != "cost-tracked" || !reflect.DeepEqual(sets[1].Units, []string{"frontend"}) {
+		t.Errorf("List = %+v, %v", sets, err)
+	}
+
+	updates := hub.updates
+	if _, err := AddUnits(hub, "prod", "critical-services", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddUnits of a missing unit = %v, want ErrNotFound", err)
+	}
+	if _, err := AddUnits(hub, "prod", "missing", "frontend"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddUnits to a missing set = %v, want ErrNotFound", err)
+	}
+	if hub.updates != updates {
+		t.Error("Expected failed calls to update nothing")
+	}
+}
@@ -0,0 +1,93 @@
+// Package shared lets apps running in one process share what each would
+// otherwise build for itself: the ConfigHub rate limiter and circuit
+// breaker, the metrics registry, the health checker and the logging setup.
+// The ConfigHub client and the Kubernetes informers stay per app, as the
+// SDK builds them for each. devops-apps combined calls Enable before
+// starting its apps. A standalone app never does, so Value builds a fresh
+// value on every call, as before:
+//
+//	cubLimit := shared.Value("confighub-limiter", func() *ratelimit.Limiter {
+//		return ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker)
+//	})
+//
+// Values are shared by key, so the first app to ask decides their settings.
+package shared
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// App is the app name metrics and logs carry in combined mode
+const App = "devops-apps"
+
+var (
+	enabled atomic.Bool
+
+	mu      sync.Mutex
+	values  = map[string]*value{}
+	primary string // the app keeping its health port
+)
+
+type value struct {
+	once sync.Once
+	v    interface{}
+}
+
+// Enable makes Value share values by key for the rest of the process.
+// The primary app keeps its health port (see HealthPort).
+func Enable(primaryApp string) {
+	mu.Lock()
+	primary = primaryApp
+	mu.Unlock()
+	enabled.Store(true)
+}
+
+// Enabled reports whether apps share one process
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Value returns the value stored under key, building it on first use. When
+// sharing is off it returns build() every time. build must not ask for its
+// own key.
+func Value[T any](key string, build func() T) T {
+	if !Enabled() {
+		return build()
+	}
+	mu.Lock()
+	entry, ok := values[key]
+	if !ok {
+		entry = &value{}
+		values[key] = entry
+	}
+	mu.Unlock()
+
+	entry.once.Do(func() { entry.v = build() })
+	return entry.v.(T)
+}
+
+// HealthPort is the port app's SDK health server listens on. In combined
+// mode the primary app keeps port and the others get 0, a free port: every
+// health server serves http.DefaultServeMux, so one well-known port covers
+// all the apps.
+func HealthPort(app string, port int) int {
+	if !Enabled() {
+		return port
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if app != primary {
+		return 0
+	}
+	return port
+}
+
+// reset turns sharing off and forgets every value, for tests
+func reset() {
+	enabled.Store(false)
+	mu.Lock()
+	defer mu.Unlock()
+	values = map[string]*value{}
+	primary = ""
+}
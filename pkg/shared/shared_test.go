@@ -0,0 +1,51 @@
+package shared
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	defer reset()
+	builds := 0
+	build := func() *int {
+		builds++
+		n := builds
+		return &n
+	}
+
+	if a, b := Value("limiter", build), Value("limiter", build); a == b || builds != 2 {
+		t.Errorf("Expected a fresh value per call while sharing is off, got %d builds", builds)
+	}
+	if HealthPort("drift-detector", 8080) != 8080 || HealthPort("cost-optimizer", 8080) != 8080 {
+		t.Error("Expected every app to keep its health port while sharing is off")
+	}
+
+	Enable("cost-impact-monitor")
+	builds = 0
+	var wg sync.WaitGroup
+	got := make([]*int, 10)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = Value("limiter", build)
+		}(i)
+	}
+	wg.Wait()
+	for _, v := range got {
+		if v != got[0] {
+			t.Fatal("Expected every caller to get the same value")
+		}
+	}
+	if builds != 1 {
+		t.Errorf("builds = %d, want 1", builds)
+	}
+	if other := Value("breaker", build); other == got[0] {
+		t.Error("Expected another key to get its own value")
+	}
+
+	if drift, impact := HealthPort("drift-detector", 8080), HealthPort("cost-impact-monitor", 8082); drift != 0 || impact != 8082 {
+		t.Errorf("HealthPort = %d, %d, want 0, 8082", drift, impact)
+	}
+}
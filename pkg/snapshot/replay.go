@@ -0,0 +1,313 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/confighubtest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Token is the ConfigHub token of a replay
+const Token = "replay"
+
+const metricsGroupVersion = "metrics.k8s.io/v1beta1"
+
+// resource is a recorded kind as the Kubernetes API serves it
+type resource struct {
+	groupVersion string // "v1", "apps/v1", ...
+	name         string // plural, as in the path
+	kind         string
+	namespaced   bool
+	items        func(*Snapshot) []metav1.Object
+}
+
+var resources = []resource{
+	{"v1", "namespaces", "Namespace", false, func(s *Snapshot) []metav1.Object { return pointers(s.Namespaces) }},
+	{"v1", "nodes", "Node", false, func(s *Snapshot) []metav1.Object { return pointers(s.Nodes) }},
+	{"v1", "pods", "Pod", true, func(s *Snapshot) []metav1.Object { return pointers(s.Pods) }},
+	{"v1", "services", "Service", true, func(s *Snapshot) []metav1.Object { return pointers(s.Services) }},
+	{"v1", "configmaps", "ConfigMap", true, func(s *Snapshot) []metav1.Object { return pointers(s.ConfigMaps) }},
+	{"v1", "resourcequotas", "ResourceQuota", true, func(s *Snapshot) []metav1.Object { return pointers(s.ResourceQuotas) }},
+	{"apps/v1", "deployments", "Deployment", true, func(s *Snapshot) []metav1.Object { return pointers(s.Deployments) }},
+	{metricsGroupVersion, "pods", "PodMetrics", true, func(s *Snapshot) []metav1.Object { return pointers(s.PodMetrics) }},
+	{metricsGroupVersion, "nodes", "NodeMetrics", false, func(s *Snapshot) []metav1.Object { return pointers(s.NodeMetrics) }},
+}
+
+// Replay is a snapshot served in place of the live cluster and ConfigHub
+type Replay struct {
+	// Env points an app at the replay: KUBECONFIG, K8S_CONTEXT,
+	// CUB_API_URL and CUB_TOKEN
+	Env       map[string]string
+	ConfigHub *confighubtest.Server
+
+	kube *httptest.Server
+	done chan struct{}
+}
+
+// Start serves snap on local ports and writes the kubeconfig for it into
+// dir; call Close when done
+func Start(snap *Snapshot, dir string) (*Replay, error) {
+	r := &Replay{ConfigHub: confighubtest.NewServer(Token), done: make(chan struct{})}
+	seed(r.ConfigHub, snap.Spaces)
+	r.kube = httptest.NewServer(snap.handler(r.done))
+
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(fmt.Sprintf(kubeconfigTemplate, r.kube.URL)), 0o600); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("write kubeconfig: %w", err)
+	}
+	r.Env = map[string]string{
+		"KUBECONFIG":  kubeconfig,
+		"K8S_CONTEXT": "snapshot",
+		"CUB_API_URL": r.ConfigHub.URL,
+		"CUB_TOKEN":   Token,
+	}
+	return r, nil
+}
+
+// Close stops both servers, ending open watches
+func (r *Replay) Close() {
+	close(r.done)
+	r.kube.Close()
+	r.ConfigHub.Close()
+}
+
+const kubeconfigTemplate = `apiVersion: v1
+kind: Config
+clusters:
+- name: snapshot
+  cluster:
+    server: %s
+users:
+- name: snapshot
+  user: {}
+contexts:
+- name: snapshot
+  context:
+    cluster: snapshot
+    user: snapshot
+current-context: snapshot
+`
+
+// seed adds the recorded spaces to a ConfigHub fake
+func seed(hub *confighubtest.Server, spaces []Space) {
+	for _, sp := range spaces {
+		space := hub.AddSpace(sp.Slug, sp.Labels)
+		sets := make(map[string]uuid.UUID, len(sp.Sets))
+		for _, set := range sp.Sets {
+			sets[set.Slug] = hub.AddSet(space.SpaceID, set.Slug, set.Labels).SetID
+		}
+		for _, u := range sp.Units {
+			unit := hub.AddUnit(space.SpaceID, u.Slug, u.Data, u.Labels)
+			for _, slug := range u.Sets {
+				if id, ok := sets[slug]; ok {
+					hub.AddToSet(unit.UnitID, id)
+				}
+			}
+		}
+	}
+}
+
+// handler serves the snapshot as a read-only Kubernetes API. Watches stay
+// open without events until the client's timeout or done.
+func (s *Snapshot) handler(done <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, "the snapshot is read-only")
+			return
+		}
+		path := strings.Trim(r.URL.Path, "/")
+		switch path {
+		case "version":
+			version := s.ServerVersion
+			if version == "" {
+				version = "v1.29.0"
+			}
+			major, minor := "1", ""
+			if parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3); len(parts) >= 2 {
+				major, minor = parts[0], parts[1]
+			}
+			writeJSON(w, map[string]string{"major": major, "minor": minor, "gitVersion": version, "platform": "snapshot"})
+			return
+		case "api":
+			writeJSON(w, metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+			return
+		case "apis":
+			list := metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}}
+			for _, gv := range s.groupVersions() {
+				if group, version, ok := strings.Cut(gv, "/"); ok {
+					v := metav1.GroupVersionForDiscovery{GroupVersion: gv, Version: version}
+					list.Groups = append(list.Groups, metav1.APIGroup{Name: group, Versions: []metav1.GroupVersionForDiscovery{v}, PreferredVersion: v})
+				}
+			}
+			writeJSON(w, list)
+			return
+		}
+
+		var groupVersion string
+		var rest []string
+		parts := strings.Split(path, "/")
+		switch {
+		case len(parts) >= 2 && parts[0] == "api":
+			groupVersion, rest = parts[1], parts[2:]
+		case len(parts) >= 3 && parts[0] == "apis":
+			groupVersion, rest = parts[1]+"/"+parts[2], parts[3:]
+		default:
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "not recorded: "+r.URL.Path)
+			return
+		}
+		if !s.serves(groupVersion) {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "not recorded: "+groupVersion)
+			return
+		}
+		if len(rest) == 0 {
+			s.discover(w, groupVersion)
+			return
+		}
+
+		namespace := ""
+		if rest[0] == "namespaces" && len(rest) >= 3 {
+			namespace, rest = rest[1], rest[2:]
+		}
+		res, ok := lookup(groupVersion, rest[0])
+		if !ok || len(rest) > 2 || (namespace != "" && !res.namespaced) {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "not recorded: "+r.URL.Path)
+			return
+		}
+		if len(rest) == 2 {
+			for _, obj := range res.items(s) {
+				if obj.GetName() == rest[1] && obj.GetNamespace() == namespace {
+					writeJSON(w, obj)
+					return
+				}
+			}
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, fmt.Sprintf("%s %q not found", res.name, rest[1]))
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("watch") == "true" || query.Get("watch") == "1" {
+			watch(w, r, done)
+			return
+		}
+		labelSelector, err := labels.Parse(query.Get("labelSelector"))
+		if err != nil {
+			writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+			return
+		}
+		fieldSelector, err := fields.ParseSelector(query.Get("fieldSelector"))
+		if err != nil {
+			writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+			return
+		}
+		items := []metav1.Object{}
+		for _, obj := range res.items(s) {
+			if (namespace == "" || obj.GetNamespace() == namespace) &&
+				labelSelector.Matches(labels.Set(obj.GetLabels())) && fieldSelector.Matches(fieldSet(obj)) {
+				items = append(items, obj)
+			}
+		}
+		writeJSON(w, map[string]interface{}{
+			"apiVersion": groupVersion,
+			"kind":       res.kind + "List",
+			"metadata":   map[string]string{"resourceVersion": "1"},
+			"items":      items,
+		})
+	})
+}
+
+// groupVersions lists the API versions the snapshot serves; the metrics
+// API only when metrics-server was recorded
+func (s *Snapshot) groupVersions() []string {
+	versions := []string{"v1", "apps/v1"}
+	if len(s.PodMetrics) > 0 || len(s.NodeMetrics) > 0 {
+		versions = append(versions, metricsGroupVersion)
+	}
+	return versions
+}
+
+func (s *Snapshot) serves(groupVersion string) bool {
+	for _, gv := range s.groupVersions() {
+		if gv == groupVersion {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Snapshot) discover(w http.ResponseWriter, groupVersion string) {
+	list := metav1.APIResourceList{TypeMeta: metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"}, GroupVersion: groupVersion}
+	for _, res := range resources {
+		if res.groupVersion == groupVersion {
+			list.APIResources = append(list.APIResources, metav1.APIResource{
+				Name: res.name, Namespaced: res.namespaced, Kind: res.kind, Verbs: metav1.Verbs{"get", "list", "watch"},
+			})
+		}
+	}
+	writeJSON(w, list)
+}
+
+func lookup(groupVersion, name string) (resource, bool) {
+	for _, res := range resources {
+		if res.groupVersion == groupVersion && res.name == name {
+			return res, true
+		}
+	}
+	return resource{}, false
+}
+
+// fieldSet is what field selectors can match on
+func fieldSet(obj metav1.Object) fields.Set {
+	set := fields.Set{"metadata.name": obj.GetName(), "metadata.namespace": obj.GetNamespace()}
+	if pod, ok := obj.(*corev1.Pod); ok {
+		set["spec.nodeName"] = pod.Spec.NodeName
+		set["status.phase"] = string(pod.Status.Phase)
+	}
+	return set
+}
+
+// watch holds a watch open without events; the snapshot never changes
+func watch(w http.ResponseWriter, r *http.Request, done <-chan struct{}) {
+	timeout := 5 * time.Minute
+	if seconds, err := strconv.Atoi(r.URL.Query().Get("timeoutSeconds")); err == nil && seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-r.Context().Done():
+	case <-done:
+	case <-timer.C:
+	}
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure, Message: message, Reason: reason, Code: int32(code),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
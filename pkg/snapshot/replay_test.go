@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+func TestReplay(t *testing.T) {
+	snap := &Snapshot{
+		ServerVersion: "v1.28.3",
+		Deployments: []appsv1.Deployment{
+			{ObjectMeta: meta("shop", "api", map[string]string{"app": "api"})},
+			{ObjectMeta: meta("shop", "web", map[string]string{"app": "web"})},
+			{ObjectMeta: meta("billing", "worker", nil)},
+		},
+		Pods: []corev1.Pod{{ObjectMeta: meta("shop", "api-1", nil), Spec: corev1.PodSpec{NodeName: "node-1"}}},
+		PodMetrics: []metricsv1beta1.PodMetrics{{
+			ObjectMeta: meta("shop", "api-1", nil),
+			Containers: []metricsv1beta1.ContainerMetrics{{Name: "api", Usage: corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("250m")}}},
+		}},
+		Spaces: []Space{{
+			Slug:  "shop",
+			Sets:  []Set{{Slug: "critical-services"}},
+			Units: []Unit{{Slug: "api", Data: "kind: Deployment", Sets: []string{"critical-services"}}, {Slug: "web", Data: "kind: Deployment"}},
+		}},
+	}
+	replay, err := Start(snap, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+
+	config, err := clientcmd.BuildConfigFromFlags("", replay.Env["KUBECONFIG"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kube := kubernetes.NewForConfigOrDie(config)
+	ctx := context.Background()
+
+	if version, err := kube.Discovery().ServerVersion(); err != nil || version.GitVersion != "v1.28.3" || version.Minor != "28" {
+		t.Errorf("ServerVersion = %+v, %v", version, err)
+	}
+	if _, err := kube.Discovery().ServerResourcesForGroupVersion("metrics.k8s.io/v1beta1"); err != nil {
+		t.Errorf("Expected the metrics API to be served: %v", err)
+	}
+
+	deployments, err := kube.AppsV1().Deployments("shop").List(ctx, metav1.ListOptions{LabelSelector: "app=api"})
+	if err != nil || len(deployments.Items) != 1 || deployments.Items[0].Name != "api" {
+		t.Errorf("List deployments = %+v, %v", deployments, err)
+	}
+	if all, err := kube.AppsV1().Deployments("").List(ctx, metav1.ListOptions{}); err != nil || len(all.Items) != 3 {
+		t.Errorf("List all deployments = %+v, %v", all, err)
+	}
+	if pods, err := kube.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "spec.nodeName=node-1"}); err != nil || len(pods.Items) != 1 {
+		t.Errorf("List pods on node-1 = %+v, %v", pods, err)
+	}
+	if _, err := kube.AppsV1().Deployments("shop").Get(ctx, "missing", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Get missing = %v, want NotFound", err)
+	}
+	if _, err := kube.CoreV1().Secrets("shop").List(ctx, metav1.ListOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("List secrets = %v, want NotFound", err)
+	}
+	if err := kube.AppsV1().Deployments("shop").Delete(ctx, "api", metav1.DeleteOptions{}); !apierrors.IsMethodNotSupported(err) {
+		t.Errorf("Delete = %v, want MethodNotAllowed", err)
+	}
+
+	metrics := metricsclient.NewForConfigOrDie(config)
+	usage, err := metrics.MetricsV1beta1().PodMetricses("shop").Get(ctx, "api-1", metav1.GetOptions{})
+	if err != nil || usage.Containers[0].Usage.Cpu().MilliValue() != 250 {
+		t.Errorf("Get pod metrics = %+v, %v", usage, err)
+	}
+
+	// Informers list, then watch until stopped
+	factory := informers.NewSharedInformerFactory(kube, time.Hour)
+	informer := factory.Apps().V1().Deployments().Informer()
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) || len(informer.GetStore().List()) != 3 {
+		t.Error("Expected the informer to sync the three deployments")
+	}
+
+	spaces := replay.ConfigHub.Spaces()
+	if len(spaces) != 1 || spaces[0].Slug != "shop" {
+		t.Fatalf("ConfigHub spaces = %+v", spaces)
+	}
+	sets := replay.ConfigHub.Sets(spaces[0].SpaceID)
+	units := replay.ConfigHub.Units(spaces[0].SpaceID)
+	if len(sets) != 1 || len(units) != 2 || len(units[0].SetIDs) != 1 || units[0].SetIDs[0] != sets[0].SetID || len(units[1].SetIDs) != 0 {
+		t.Errorf("ConfigHub sets = %+v, units = %+v", sets, units)
+	}
+	if replay.Env["CUB_API_URL"] != replay.ConfigHub.URL || replay.Env["CUB_TOKEN"] != Token {
+		t.Errorf("Env = %v", replay.Env)
+	}
+}
@@ -0,0 +1,230 @@
+// Package snapshot records what the apps read from a cluster and ConfigHub -
+// workloads, nodes, metrics-server usage, units and sets - to a file, and
+// replays it in place of the live services. A snapshot makes a bug report
+// reproducible, runs a demo without a cluster, and pins the analysis logic
+// against real-world captures in regression tests:
+//
+//	devops-apps snapshot -space prod -namespace shop -o shop.json.gz
+//	devops-apps -replay shop.json.gz drift
+//
+// Replay serves the snapshot as a read-only Kubernetes API (list, get and
+// watch of the recorded kinds) with a kubeconfig pointing at it, and its
+// spaces from a confighubtest fake, so the apps run unchanged. Writes to
+// ConfigHub land in the fake; writes to Kubernetes are refused. Secrets are
+// never recorded.
+package snapshot
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// EnvVar names the snapshot devops-apps replays instead of the live
+// cluster and ConfigHub
+const EnvVar = "REPLAY_SNAPSHOT"
+
+// Snapshot is a recording of a cluster and the ConfigHub spaces describing it
+type Snapshot struct {
+	CapturedAt    time.Time `json:"captured_at"`
+	ServerVersion string    `json:"server_version,omitempty"` // e.g. v1.29.2
+	Namespace     string    `json:"namespace,omitempty"`      // the one recorded, "" for all
+
+	Namespaces     []corev1.Namespace     `json:"namespaces,omitempty"`
+	Nodes          []corev1.Node          `json:"nodes,omitempty"`
+	Deployments    []appsv1.Deployment    `json:"deployments,omitempty"`
+	Pods           []corev1.Pod           `json:"pods,omitempty"`
+	Services       []corev1.Service       `json:"services,omitempty"`
+	ConfigMaps     []corev1.ConfigMap     `json:"configmaps,omitempty"`
+	ResourceQuotas []corev1.ResourceQuota `json:"resourcequotas,omitempty"`
+
+	// Usage from metrics-server; empty when it was not installed
+	PodMetrics  []metricsv1beta1.PodMetrics  `json:"pod_metrics,omitempty"`
+	NodeMetrics []metricsv1beta1.NodeMetrics `json:"node_metrics,omitempty"`
+
+	Spaces []Space `json:"spaces,omitempty"`
+}
+
+// Space is a recorded ConfigHub space
+type Space struct {
+	Slug   string            `json:"slug"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Sets   []Set             `json:"sets,omitempty"`
+	Units  []Unit            `json:"units"`
+}
+
+// Set is a recorded ConfigHub set
+type Set struct {
+	Slug   string            `json:"slug"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Unit is a recorded ConfigHub unit
+type Unit struct {
+	Slug   string            `json:"slug"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Data   string            `json:"data"`
+	Sets   []string          `json:"sets,omitempty"` // slugs
+}
+
+// Capture records the workloads of namespace ("" for all), the cluster's
+// nodes and namespaces, and their usage when metrics is not nil and
+// metrics-server answers. The caller adds the ConfigHub spaces.
+func Capture(ctx context.Context, kube kubernetes.Interface, metrics metricsclient.Interface, namespace string) (*Snapshot, error) {
+	s := &Snapshot{CapturedAt: time.Now().UTC(), Namespace: namespace}
+	if version, err := kube.Discovery().ServerVersion(); err == nil {
+		s.ServerVersion = version.GitVersion
+	}
+
+	list := metav1.ListOptions{}
+	namespaces, err := kube.CoreV1().Namespaces().List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+	s.Namespaces = namespaces.Items
+	nodes, err := kube.CoreV1().Nodes().List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+	s.Nodes = nodes.Items
+	deployments, err := kube.AppsV1().Deployments(namespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	s.Deployments = deployments.Items
+	pods, err := kube.CoreV1().Pods(namespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	s.Pods = pods.Items
+	services, err := kube.CoreV1().Services(namespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+	s.Services = services.Items
+	configMaps, err := kube.CoreV1().ConfigMaps(namespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list configmaps: %w", err)
+	}
+	s.ConfigMaps = configMaps.Items
+	quotas, err := kube.CoreV1().ResourceQuotas(namespace).List(ctx, list)
+	if err != nil {
+		return nil, fmt.Errorf("list resourcequotas: %w", err)
+	}
+	s.ResourceQuotas = quotas.Items
+
+	// metrics-server is optional, as it is for the apps
+	if metrics != nil {
+		if podMetrics, err := metrics.MetricsV1beta1().PodMetricses(namespace).List(ctx, list); err == nil {
+			s.PodMetrics = podMetrics.Items
+		}
+		if nodeMetrics, err := metrics.MetricsV1beta1().NodeMetricses().List(ctx, list); err == nil {
+			s.NodeMetrics = nodeMetrics.Items
+		}
+	}
+
+	for _, obj := range s.objects() {
+		obj.SetManagedFields(nil) // large and read by no app
+	}
+	return s, nil
+}
+
+// Load reads a snapshot written by Save
+func Load(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("read snapshot %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	s := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, fmt.Errorf("read snapshot %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the snapshot as JSON, gzipped when path ends in .gz
+func (s *Snapshot) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(s)
+	if gz != nil && err == nil {
+		err = gz.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write snapshot %s: %w", path, err)
+	}
+	return nil
+}
+
+// Clientset returns a fake clientset holding the recorded Kubernetes
+// objects, for tests of code that takes a kubernetes.Interface
+func (s *Snapshot) Clientset() *fake.Clientset {
+	var objects []runtime.Object
+	for _, r := range resources {
+		if r.groupVersion == metricsGroupVersion {
+			continue // not served by the core clientset
+		}
+		for _, obj := range r.items(s) {
+			objects = append(objects, obj.(runtime.Object).DeepCopyObject())
+		}
+	}
+	return fake.NewSimpleClientset(objects...)
+}
+
+// objects returns every recorded Kubernetes object
+func (s *Snapshot) objects() []metav1.Object {
+	var all []metav1.Object
+	for _, r := range resources {
+		all = append(all, r.items(s)...)
+	}
+	return all
+}
+
+// pointers returns pointers to items as metav1.Objects
+func pointers[T any, P interface {
+	*T
+	metav1.Object
+}](items []T) []metav1.Object {
+	objects := make([]metav1.Object, len(items))
+	for i := range items {
+		objects[i] = P(&items[i])
+	}
+	return objects
+}
@@ -0,0 +1,65 @@
+package snapshot
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func meta(namespace, name string, labels map[string]string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: namespace, Name: name, Labels: labels,
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+	}
+}
+
+func TestCaptureSaveLoad(t *testing.T) {
+	replicas := int32(3)
+	live := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: meta("", "shop", nil)},
+		&corev1.Node{ObjectMeta: meta("", "node-1", nil)},
+		&appsv1.Deployment{ObjectMeta: meta("shop", "api", map[string]string{"app": "api"}), Spec: appsv1.DeploymentSpec{Replicas: &replicas}},
+		&appsv1.Deployment{ObjectMeta: meta("billing", "worker", nil)},
+		&corev1.Pod{ObjectMeta: meta("shop", "api-1", map[string]string{"app": "api"})},
+		&corev1.Secret{ObjectMeta: meta("shop", "api-token", nil)},
+	)
+	snap, err := Capture(context.Background(), live, nil, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Namespaces) != 1 || len(snap.Nodes) != 1 || len(snap.Deployments) != 1 || len(snap.Pods) != 1 {
+		t.Fatalf("Capture = %+v", snap)
+	}
+	if snap.Deployments[0].ManagedFields != nil {
+		t.Error("Expected managed fields to be dropped")
+	}
+	snap.Spaces = []Space{{Slug: "shop", Sets: []Set{{Slug: "critical-services"}}, Units: []Unit{{Slug: "api", Data: "kind: Deployment", Sets: []string{"critical-services"}}}}}
+
+	for _, name := range []string{"shop.json", "shop.json.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := snap.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded.Spaces, snap.Spaces) || *loaded.Deployments[0].Spec.Replicas != 3 || !loaded.CapturedAt.Equal(snap.CapturedAt) {
+			t.Errorf("Load(%s) = %+v", name, loaded)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing snapshot")
+	}
+
+	deployments, err := snap.Clientset().AppsV1().Deployments("shop").List(context.Background(), metav1.ListOptions{})
+	if err != nil || len(deployments.Items) != 1 || deployments.Items[0].Name != "api" {
+		t.Errorf("Clientset deployments = %+v, %v", deployments, err)
+	}
+}
@@ -0,0 +1,283 @@
+// Package tenants lets one deployment of an app serve several teams. Each
+// team gets its own ConfigHub token, sees only its own spaces (and
+// namespaces) and reads the app's API and dashboard with its own token:
+//
+//	teams:
+//	  - name: payments
+//	    spaces: [payments-dev, payments-prod]
+//	    namespaces: [payments]
+//	  - name: platform
+//	    spaces: ["*"] # every space its token can list
+//
+// Tokens are not kept in the file. A team's ConfigHub token is read from
+// <name>-cub-token and its API token from <name>-api-token in the app's
+// SECRETS_DIR; cub_token and api_token may instead reference environment
+// variables as ${NAME}, for local runs.
+//
+// Behind pkg/auth, users signed in through the identity provider act as the
+// first team listing one of their groups in groups; API tokens keep working
+// for scripts and the other apps.
+//
+// Without a teams file an app runs single-tenant: one global token and
+// unauthenticated, unfiltered views.
+package tenants
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/monadic/devops-examples/pkg/auth"
+	"gopkg.in/yaml.v3"
+)
+
+// Team is one tenant
+type Team struct {
+	Name       string   `yaml:"name"`
+	Spaces     []string `yaml:"spaces"`     // slugs or path.Match patterns; "*" is every space
+	Namespaces []string `yaml:"namespaces"` // Kubernetes namespaces, same syntax
+	CubToken   string   `yaml:"cub_token"`  // usually left to <name>-cub-token
+	APIToken   string   `yaml:"api_token"`  // usually left to <name>-api-token
+	Groups     []string `yaml:"groups"`     // identity provider groups acting as the team (pkg/auth)
+}
+
+// Registry is the teams of one deployment. A nil Registry is single-tenant.
+type Registry struct {
+	teams []*Team
+}
+
+// Config is the teams file
+type Config struct {
+	Teams []*Team `yaml:"teams"`
+}
+
+var teamName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// Load reads the teams file at path and each team's tokens, looking up
+// secret files with secret (see config.Effective.ReadSecretFile). A missing
+// file returns a nil Registry.
+func Load(path string, secret func(name string) (string, error)) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read teams config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse teams config: %w", err)
+	}
+	for _, t := range cfg.Teams {
+		t.CubToken, t.APIToken = os.ExpandEnv(t.CubToken), os.ExpandEnv(t.APIToken)
+		for _, s := range []struct {
+			file  string
+			token *string
+		}{{t.Name + "-cub-token", &t.CubToken}, {t.Name + "-api-token", &t.APIToken}} {
+			value, err := secret(s.file)
+			if err != nil {
+				return nil, fmt.Errorf("team %s: %w", t.Name, err)
+			}
+			if value != "" {
+				*s.token = value
+			}
+		}
+	}
+	return cfg.Build()
+}
+
+// Build validates the config
+func (c Config) Build() (*Registry, error) {
+	if len(c.Teams) == 0 {
+		return nil, errors.New("teams config has no teams")
+	}
+	seen := map[string]bool{}
+	for i, t := range c.Teams {
+		switch {
+		case !teamName.MatchString(t.Name):
+			return nil, fmt.Errorf("team %d: name %q must be lower-case letters, digits and dashes", i, t.Name)
+		case seen[t.Name]:
+			return nil, fmt.Errorf("team %d: duplicate name %q", i, t.Name)
+		case len(t.Spaces) == 0:
+			return nil, fmt.Errorf("team %s: no spaces", t.Name)
+		case t.CubToken == "":
+			return nil, fmt.Errorf("team %s: no ConfigHub token (%s-cub-token in SECRETS_DIR)", t.Name, t.Name)
+		case t.APIToken == "":
+			return nil, fmt.Errorf("team %s: no API token (%s-api-token in SECRETS_DIR)", t.Name, t.Name)
+		}
+		for _, pattern := range append(append([]string{}, t.Spaces...), t.Namespaces...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("team %s: bad pattern %q", t.Name, pattern)
+			}
+		}
+		seen[t.Name] = true
+	}
+	return &Registry{teams: c.Teams}, nil
+}
+
+// Teams returns the teams in file order
+func (r *Registry) Teams() []*Team {
+	if r == nil {
+		return nil
+	}
+	return r.teams
+}
+
+// Team returns the team called name, or nil
+func (r *Registry) Team(name string) *Team {
+	for _, t := range r.Teams() {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// matches reports whether name matches one of patterns
+func matches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnsSpace reports whether the team may see the space. A nil Team (single-
+// tenant) sees everything.
+func (t *Team) OwnsSpace(slug string) bool {
+	return t == nil || matches(t.Spaces, slug)
+}
+
+// OwnsNamespace reports whether the team may see the namespace
+func (t *Team) OwnsNamespace(namespace string) bool {
+	return t == nil || matches(t.Namespaces, namespace)
+}
+
+// Unrestricted reports whether the team sees every space, and so may see
+// data that belongs to no space (Terraform plans, cluster-wide activity)
+func (t *Team) Unrestricted() bool {
+	if t == nil {
+		return true
+	}
+	for _, pattern := range t.Spaces {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns the team a request's credentials belong to: an API
+// token as a bearer token, or the team name and API token as basic auth (so
+// browsers can open the dashboards). It returns nil when none matches.
+func (r *Registry) Authenticate(req *http.Request) *Team {
+	var name, token string
+	if user, password, ok := req.BasicAuth(); ok {
+		name, token = user, password
+	} else if bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return r.AuthenticateToken(name, token)
+}
+
+// HasToken reports whether req carries a team's API token, so auth.Guard
+// can let the other apps and scripts through (see Guard.AcceptTokens)
+func (r *Registry) HasToken(req *http.Request) bool {
+	return r.Authenticate(req) != nil
+}
+
+// ForGroups returns the first team listing one of groups, or nil
+func (r *Registry) ForGroups(groups []string) *Team {
+	for _, t := range r.Teams() {
+		for _, want := range t.Groups {
+			for _, g := range groups {
+				if g == want {
+					return t
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// AuthenticateToken returns the team whose API token is token, and whose
+// name is name unless that is empty. It returns nil when none matches.
+func (r *Registry) AuthenticateToken(name, token string) *Team {
+	if token == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	var found *Team
+	for _, t := range r.Teams() {
+		want := sha256.Sum256([]byte(t.APIToken))
+		// Compare every team's token so timing doesn't tell which one matched
+		if subtle.ConstantTimeCompare(sum[:], want[:]) == 1 && (name == "" || name == t.Name) && found == nil {
+			found = t
+		}
+	}
+	return found
+}
+
+type contextKey struct{}
+
+// WithTeam returns ctx carrying team
+func WithTeam(ctx context.Context, team *Team) context.Context {
+	return context.WithValue(ctx, contextKey{}, team)
+}
+
+// FromContext returns the team a request was authenticated as, nil when
+// the app is single-tenant
+func FromContext(ctx context.Context) *Team {
+	team, _ := ctx.Value(contextKey{}).(*Team)
+	return team
+}
+
+// Protect requires team credentials on every request to next except those
+// under the public path prefixes (metrics, webhooks with their own
+// signatures, static files), and stores the team in the request context.
+// A user signed in by pkg/auth needs a team through their groups instead. A
+// nil Registry returns next unchanged.
+func (r *Registry) Protect(realm string, next http.Handler, public ...string) http.Handler {
+	if r == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for _, prefix := range public {
+			if strings.HasPrefix(req.URL.Path, prefix) {
+				next.ServeHTTP(w, req)
+				return
+			}
+		}
+		if user := auth.FromContext(req.Context()); user != nil {
+			team := r.ForGroups(user.Groups)
+			if team == nil {
+				http.Error(w, user.Email+" is in no team's groups", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(WithTeam(req.Context(), team)))
+			return
+		}
+		team := r.Authenticate(req)
+		if team == nil {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+			http.Error(w, "team credentials required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(WithTeam(req.Context(), team)))
+	})
+}
+
+// Forbidden answers a request for data outside the team's spaces
+func Forbidden(w http.ResponseWriter) {
+	http.Error(w, "not visible to this team", http.StatusForbidden)
+}
@@ -0,0 +1,160 @@
+package tenants
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monadic/devops-examples/pkg/auth"
+)
+
+func writeTeams(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "teams.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// secrets serves secret files from a map
+func secrets(files map[string]string) func(string) (string, error) {
+	return func(name string) (string, error) { return files[name], nil }
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("PLATFORM_API_TOKEN", "platform-api")
+	path := writeTeams(t, `
+teams:
+  - name: payments
+    spaces: [payments-*]
+    namespaces: [payments]
+  - name: platform
+    spaces: ["*"]
+    cub_token: inline-cub
+    api_token: ${PLATFORM_API_TOKEN}
+`)
+	r, err := Load(path, secrets(map[string]string{
+		"payments-cub-token": "payments-cub",
+		"payments-api-token": "payments-api",
+		"platform-cub-token": "platform-cub",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payments, platform := r.Team("payments"), r.Team("platform")
+	if payments.CubToken != "payments-cub" || payments.APIToken != "payments-api" {
+		t.Errorf("payments tokens = %q, %q", payments.CubToken, payments.APIToken)
+	}
+	if platform.CubToken != "platform-cub" || platform.APIToken != "platform-api" {
+		t.Errorf("platform tokens = %q, %q; the file wins, env is expanded", platform.CubToken, platform.APIToken)
+	}
+
+	if !payments.OwnsSpace("payments-prod") || payments.OwnsSpace("checkout-prod") || payments.Unrestricted() {
+		t.Error("payments sees the wrong spaces")
+	}
+	if !payments.OwnsNamespace("payments") || payments.OwnsNamespace("kube-system") {
+		t.Error("payments sees the wrong namespaces")
+	}
+	if !platform.OwnsSpace("checkout-prod") || !platform.Unrestricted() {
+		t.Error("platform does not see every space")
+	}
+	var single *Team
+	if !single.OwnsSpace("anything") || !single.Unrestricted() {
+		t.Error("single-tenant view is restricted")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	r, err := Load(filepath.Join(t.TempDir(), "teams.yaml"), secrets(nil))
+	if err != nil || r != nil {
+		t.Errorf("Load = %v, %v; want single-tenant", r, err)
+	}
+	if r.Teams() != nil || r.Team("payments") != nil {
+		t.Error("nil registry has teams")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tokens := secrets(map[string]string{"a-cub-token": "c", "a-api-token": "a"})
+	tests := []struct{ name, file, want string }{
+		{"no teams", "teams: []", "no teams"},
+		{"bad name", "teams: [{name: Team_A, spaces: [x]}]", "lower-case"},
+		{"duplicate", "teams: [{name: a, spaces: [x]}, {name: a, spaces: [y]}]", "duplicate"},
+		{"no spaces", "teams: [{name: a}]", "no spaces"},
+		{"no token", "teams: [{name: b, spaces: [x]}]", "b-cub-token"},
+		{"bad pattern", "teams: [{name: a, spaces: ['[']}]", "bad pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeTeams(t, tt.file), tokens)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// signIn stands in for pkg/auth having signed the request in
+func signIn(groups ...string) func(*http.Request) {
+	return func(req *http.Request) {
+		*req = *req.WithContext(auth.WithUser(req.Context(), &auth.User{Email: "dev@example.com", Groups: groups, Role: auth.RoleOperator}))
+	}
+}
+
+func TestProtect(t *testing.T) {
+	r, err := Config{Teams: []*Team{
+		{Name: "payments", Spaces: []string{"payments-*"}, CubToken: "c1", APIToken: "payments-secret", Groups: []string{"payments-devs"}},
+		{Name: "platform", Spaces: []string{"*"}, CubToken: "c2", APIToken: "platform-secret"},
+	}}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := r.Protect("test", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if team := FromContext(req.Context()); team != nil {
+			w.Write([]byte(team.Name))
+		}
+	}), "/metrics")
+
+	tests := []struct {
+		name, path string
+		auth       func(*http.Request)
+		status     int
+		team       string
+	}{
+		{"bearer", "/api/spaces", func(req *http.Request) { req.Header.Set("Authorization", "Bearer platform-secret") }, 200, "platform"},
+		{"basic", "/", func(req *http.Request) { req.SetBasicAuth("payments", "payments-secret") }, 200, "payments"},
+		{"basic wrong team", "/", func(req *http.Request) { req.SetBasicAuth("platform", "payments-secret") }, 401, ""},
+		{"wrong token", "/api/spaces", func(req *http.Request) { req.Header.Set("Authorization", "Bearer nope") }, 401, ""},
+		{"anonymous", "/api/spaces", func(*http.Request) {}, 401, ""},
+		{"public", "/metrics", func(*http.Request) {}, 200, ""},
+		{"signed in", "/", signIn("payments-devs"), 200, "payments"},
+		{"signed in without a team", "/", signIn("sales"), 403, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			tt.auth(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status || rec.Body.String() != tt.team && rec.Code == 200 {
+				t.Errorf("status %d body %q, want %d %q", rec.Code, rec.Body.String(), tt.status, tt.team)
+			}
+			if rec.Code == 401 && !strings.Contains(rec.Header().Get("WWW-Authenticate"), `realm="test"`) {
+				t.Error("no basic auth challenge")
+			}
+		})
+	}
+
+	var single *Registry
+	rec := httptest.NewRecorder()
+	open := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	single.Protect("test", open).ServeHTTP(rec, httptest.NewRequest("GET", "/api/spaces", nil))
+	if rec.Code != 200 {
+		t.Errorf("single-tenant registry asked for credentials: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,193 @@
+// Package tracing instruments the DevOps apps with OpenTelemetry so a slow
+// detection or analysis cycle can be followed end to end: the cycle is the
+// root span and its ConfigHub, Kubernetes and Claude calls are children.
+//
+// Tracing is off unless an OTLP endpoint is configured with the standard
+// variables, e.g.
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT=http://otel-collector:4318
+//
+// Spans are then exported over OTLP/HTTP; the other OTEL_* variables
+// (headers, sampler, resource attributes) apply as usual. Without an
+// endpoint every helper here is a cheap no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/monadic/devops-examples/pkg/tracing"
+
+// Attribute keys shared by the apps, so traces can be filtered the same way
+// whichever app produced them
+const (
+	SpaceKey       = attribute.Key("confighub.space")
+	UnitKey        = attribute.Key("confighub.unit")
+	ClusterKey     = attribute.Key("k8s.cluster.name")
+	CorrelationKey = attribute.Key("devops.correlation_id")
+)
+
+// Enabled reports whether an OTLP endpoint is configured
+func Enabled() bool {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider for an app. The returned
+// function flushes buffered spans and should run before the app exits.
+func Setup(ctx context.Context, service, version string) (shutdown func(context.Context) error, err error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(service), semconv.ServiceVersion(version)),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win
+	)
+	if err != nil {
+		return nil, fmt.Errorf("build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Observer is told of every span started by Start, Do or Call when it ends:
+// its name, attributes, duration and the error given to End. It runs whether
+// or not spans are exported, so metrics don't depend on tracing being on.
+type Observer func(name string, attrs []attribute.KeyValue, elapsed time.Duration, err error)
+
+var observer atomic.Pointer[Observer]
+
+// Observe installs o for the spans started from now on; nil removes it
+func Observe(o Observer) {
+	if o == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&o)
+}
+
+// observedSpan reports to the observer when it ends
+type observedSpan struct {
+	trace.Span
+	observe Observer
+	name    string
+	attrs   []attribute.KeyValue
+	start   time.Time
+	err     error
+}
+
+func (s *observedSpan) End(options ...trace.SpanEndOption) {
+	s.Span.End(options...)
+	s.observe(s.name, s.attrs, time.Since(s.start), s.err)
+}
+
+// Start begins a span named name as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+	if o := observer.Load(); o != nil {
+		return ctx, &observedSpan{Span: span, observe: *o, name: name, attrs: attrs, start: time.Now()}
+	}
+	return ctx, span
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if s, ok := span.(*observedSpan); ok {
+		s.err = err
+	}
+	span.End()
+}
+
+// Do runs fn in a span named name
+func Do(ctx context.Context, name string, fn func(ctx context.Context) error, attrs ...attribute.KeyValue) error {
+	ctx, span := Start(ctx, name, attrs...)
+	err := fn(ctx)
+	End(span, err)
+	return err
+}
+
+// Call runs fn in a span named name and returns its result. It wraps
+// client calls that don't take a context, such as the SDK's ConfigHub and
+// Claude clients:
+//
+//	units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+//		return app.Cub.ListUnits(params)
+//	})
+func Call[T any](ctx context.Context, name string, fn func() (T, error), attrs ...attribute.KeyValue) (T, error) {
+	_, span := Start(ctx, name, attrs...)
+	result, err := fn()
+	End(span, err)
+	return result, err
+}
+
+// WrapTransport traces every request sent through rt and propagates the
+// trace context to the server. Its signature matches rest.Config.Wrap, so
+// Kubernetes clients are instrumented with config.Wrap(tracing.WrapTransport).
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{base: rt}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(instrumentationName).Start(req.Context(), "HTTP "+req.Method+" "+req.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		))
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		End(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	span.End()
+	return resp, nil
+}
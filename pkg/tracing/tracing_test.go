@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a provider that keeps finished spans in memory
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestSetupWithoutEndpointIsNoop(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	if Enabled() {
+		t.Fatal("Enabled() = true without an endpoint")
+	}
+	shutdown, err := Setup(context.Background(), "test", "1.0.0")
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	if !Enabled() {
+		t.Error("Enabled() = false with OTEL_EXPORTER_OTLP_ENDPOINT set")
+	}
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	if Enabled() {
+		t.Error("Enabled() = true with OTEL_SDK_DISABLED")
+	}
+}
+
+func TestCallNestsUnderCycle(t *testing.T) {
+	recorder := record(t)
+
+	ctx, cycle := Start(context.Background(), "drift.cycle", SpaceKey.String("prod"))
+	n, err := Call(ctx, "confighub.ListUnits", func() (int, error) { return 3, nil })
+	if err != nil || n != 3 {
+		t.Fatalf("Call = %d, %v", n, err)
+	}
+	failure := errors.New("rate limited")
+	if err := Do(ctx, "claude.Complete", func(context.Context) error { return failure }); err != failure {
+		t.Fatalf("Do error = %v", err)
+	}
+	End(cycle, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	root := spans[2]
+	if root.Name() != "drift.cycle" || root.Status().Code == codes.Error {
+		t.Errorf("root span = %s (%v)", root.Name(), root.Status())
+	}
+	for _, child := range spans[:2] {
+		if child.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the cycle", child.Name())
+		}
+	}
+	if spans[1].Name() != "claude.Complete" || spans[1].Status().Code != codes.Error {
+		t.Errorf("failed call span = %s (%v), want error status", spans[1].Name(), spans[1].Status())
+	}
+}
+
+func TestWrapTransportPropagatesContext(t *testing.T) {
+	recorder := record(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cycle := Start(context.Background(), "impact.cycle")
+	client := &http.Client{Transport: WrapTransport(nil)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/pods", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	End(cycle, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	httpSpan := spans[0]
+	if traceparent == "" || httpSpan.SpanContext().TraceID() != cycle.SpanContext().TraceID() {
+		t.Errorf("trace context not propagated: traceparent=%q", traceparent)
+	}
+	if httpSpan.Status().Code != codes.Error {
+		t.Errorf("5xx response status = %v, want error", httpSpan.Status())
+	}
+}
+
+func TestObserve(t *testing.T) {
+	type observed struct {
+		name string
+		err  error
+	}
+	var spans []observed
+	Observe(func(name string, attrs []attribute.KeyValue, elapsed time.Duration, err error) {
+		spans = append(spans, observed{name, err})
+	})
+	t.Cleanup(func() { Observe(nil) })
+
+	failure := errors.New("unavailable")
+	ctx, cycle := Start(context.Background(), "drift.detect", SpaceKey.String("prod"))
+	Call(ctx, "confighub.ListUnits", func() (int, error) { return 0, failure })
+	Do(ctx, "confighub.ApplyUnit", func(context.Context) error { return nil })
+	cycle.End()
+
+	want := []observed{{"confighub.ListUnits", failure}, {"confighub.ApplyUnit", nil}, {"drift.detect", nil}}
+	if len(spans) != len(want) {
+		t.Fatalf("observed %+v, want %+v", spans, want)
+	}
+	for i := range want {
+		if spans[i] != want[i] {
+			t.Errorf("span %d = %+v, want %+v", i, spans[i], want[i])
+		}
+	}
+
+	Observe(nil)
+	_, span := Start(context.Background(), "drift.detect")
+	span.End()
+	if len(spans) != len(want) {
+		t.Error("observed a span after Observe(nil)")
+	}
+}
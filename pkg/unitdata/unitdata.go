@@ -0,0 +1,287 @@
+// Package unitdata parses the Kubernetes manifests ConfigHub units hold in
+// their Data, JSON or YAML, and reads their fields without type assertions.
+//
+// Parse checks the fields the apps find a resource by - kind and
+// metadata.name - and reports all that are missing or malformed at once,
+// naming the unit:
+//
+//	m, err := unitdata.Parse(unit.Slug, unit.Data)
+//	if err != nil {
+//		return err // unit web: invalid manifest: kind: missing; metadata.name: is a number, not a string
+//	}
+//	replicas, found, err := m.Int("spec", "replicas")
+//
+// The accessors return whether a field is set and an error naming the unit
+// and field when it has the wrong type, so a malformed unit is skipped or
+// reported instead of panicking the app that reads it. Units holding
+// settings rather than a manifest are read with ParseObject, which has the
+// same accessors but requires no fields.
+package unitdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is one malformed field of a manifest
+type FieldError struct {
+	Path   string `json:"path"` // dotted, e.g. spec.replicas
+	Reason string `json:"reason"`
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Reason
+}
+
+// Error is what is wrong with a unit's data. Either the data could not be
+// parsed, or Fields lists every malformed field.
+type Error struct {
+	Unit   string       `json:"unit"`
+	Fields []FieldError `json:"fields,omitempty"`
+	Err    error        `json:"-"` // why the data could not be parsed
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("unit %s: %v", e.Unit, e.Err)
+	}
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("unit %s: invalid manifest: %s", e.Unit, strings.Join(msgs, "; "))
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Manifest is the parsed data of one unit, a manifest or other object
+type Manifest struct {
+	unit string
+	obj  map[string]interface{}
+}
+
+// Parse reads the manifest in a unit's data. The first document of a
+// multi-document YAML is used.
+func Parse(unit, data string) (*Manifest, error) {
+	m, err := ParseObject(unit, data)
+	if err != nil {
+		return nil, err
+	}
+	var fields []FieldError
+	if _, _, err := m.String("apiVersion"); err != nil {
+		fields = append(fields, err.(*Error).Fields...)
+	}
+	for _, path := range [][]string{{"kind"}, {"metadata", "name"}} {
+		v, found, err := m.String(path...)
+		switch {
+		case err != nil:
+			fields = append(fields, err.(*Error).Fields...)
+		case !found || v == "":
+			fields = append(fields, FieldError{Path: strings.Join(path, "."), Reason: "missing"})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, &Error{Unit: unit, Fields: fields}
+	}
+	return m, nil
+}
+
+// ParseObject reads any object from a unit's data, without checking it is
+// a manifest
+func ParseObject(unit, data string) (*Manifest, error) {
+	trimmed := strings.TrimSpace(data)
+	if trimmed == "" {
+		return nil, &Error{Unit: unit, Err: errors.New("no data")}
+	}
+	var obj interface{}
+	var err error
+	if strings.HasPrefix(trimmed, "{") {
+		err = json.Unmarshal([]byte(trimmed), &obj) // YAML would parse it too, slower
+	} else {
+		err = yaml.NewDecoder(strings.NewReader(data)).Decode(&obj)
+	}
+	if err != nil {
+		return nil, &Error{Unit: unit, Err: fmt.Errorf("parse data: %w", err)}
+	}
+	root, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, &Error{Unit: unit, Err: fmt.Errorf("data is %s, not an object", typeName(obj))}
+	}
+
+	return &Manifest{unit: unit, obj: root}, nil
+}
+
+// Unit is the slug the manifest was parsed for
+func (m *Manifest) Unit() string { return m.unit }
+
+// Object is the whole manifest, for callers walking it themselves
+func (m *Manifest) Object() map[string]interface{} { return m.obj }
+
+// APIVersion is empty when unset; Kind and Name are checked by Parse, and
+// empty when unset for ParseObject
+func (m *Manifest) APIVersion() string { s, _, _ := m.String("apiVersion"); return s }
+func (m *Manifest) Kind() string       { s, _, _ := m.String("kind"); return s }
+func (m *Manifest) Name() string       { s, _, _ := m.String("metadata", "name"); return s }
+
+// Namespace is metadata.namespace, or def when the manifest has none
+func (m *Manifest) Namespace(def string) string {
+	if s, _, _ := m.String("metadata", "namespace"); s != "" {
+		return s
+	}
+	return def
+}
+
+// Labels and Annotations are the manifest's metadata maps, nil when unset;
+// values that aren't strings are left out
+func (m *Manifest) Labels() map[string]string      { return m.stringMap("metadata", "labels") }
+func (m *Manifest) Annotations() map[string]string { return m.stringMap("metadata", "annotations") }
+
+func (m *Manifest) stringMap(fields ...string) map[string]string {
+	v, _, _ := m.Map(fields...)
+	if v == nil {
+		return nil
+	}
+	out := make(map[string]string, len(v))
+	for k, val := range v {
+		if s, ok := val.(string); ok {
+			out[k] = s
+		}
+	}
+	return out
+}
+
+// Get returns the value at fields and whether it is set. It fails when a
+// parent of the field is not an object.
+func (m *Manifest) Get(fields ...string) (interface{}, bool, error) {
+	var cur interface{} = m.obj
+	for i, f := range fields {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false, m.fieldError(fields[:i], fmt.Sprintf("is %s, not an object", typeName(cur)))
+		}
+		if cur, ok = obj[f]; !ok || cur == nil {
+			return nil, false, nil
+		}
+	}
+	return cur, true, nil
+}
+
+// String returns the string at fields
+func (m *Manifest) String(fields ...string) (string, bool, error) {
+	v, found, err := m.Get(fields...)
+	if !found || err != nil {
+		return "", found, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", true, m.fieldError(fields, fmt.Sprintf("is %s, not a string", typeName(v)))
+	}
+	return s, true, nil
+}
+
+// Int returns the whole number at fields
+func (m *Manifest) Int(fields ...string) (int64, bool, error) {
+	v, found, err := m.Get(fields...)
+	if !found || err != nil {
+		return 0, found, err
+	}
+	switch n := v.(type) {
+	case int:
+		return int64(n), true, nil
+	case int64:
+		return n, true, nil
+	case float64:
+		if n == math.Trunc(n) {
+			return int64(n), true, nil
+		}
+	}
+	return 0, true, m.fieldError(fields, fmt.Sprintf("is %s, not a whole number", typeName(v)))
+}
+
+// Float returns the number at fields
+func (m *Manifest) Float(fields ...string) (float64, bool, error) {
+	v, found, err := m.Get(fields...)
+	if !found || err != nil {
+		return 0, found, err
+	}
+	switch n := v.(type) {
+	case int:
+		return float64(n), true, nil
+	case int64:
+		return float64(n), true, nil
+	case float64:
+		return n, true, nil
+	}
+	return 0, true, m.fieldError(fields, fmt.Sprintf("is %s, not a number", typeName(v)))
+}
+
+// Bool returns the boolean at fields
+func (m *Manifest) Bool(fields ...string) (bool, bool, error) {
+	v, found, err := m.Get(fields...)
+	if !found || err != nil {
+		return false, found, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, true, m.fieldError(fields, fmt.Sprintf("is %s, not a boolean", typeName(v)))
+	}
+	return b, true, nil
+}
+
+// Map returns the object at fields
+func (m *Manifest) Map(fields ...string) (map[string]interface{}, bool, error) {
+	v, found, err := m.Get(fields...)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, true, m.fieldError(fields, fmt.Sprintf("is %s, not an object", typeName(v)))
+	}
+	return obj, true, nil
+}
+
+// Slice returns the list at fields
+func (m *Manifest) Slice(fields ...string) ([]interface{}, bool, error) {
+	v, found, err := m.Get(fields...)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, true, m.fieldError(fields, fmt.Sprintf("is %s, not a list", typeName(v)))
+	}
+	return list, true, nil
+}
+
+func (m *Manifest) fieldError(fields []string, reason string) error {
+	path := strings.Join(fields, ".")
+	if path == "" {
+		path = "(root)"
+	}
+	return &Error{Unit: m.unit, Fields: []FieldError{{Path: path, Reason: reason}}}
+}
+
+// typeName describes a decoded value for error messages
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int, int64, float64:
+		return "a number"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprintf("%T", v)
+}
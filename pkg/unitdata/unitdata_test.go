@@ -0,0 +1,115 @@
+package unitdata
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    tier: critical
+  annotations:
+    cpu: 500m
+spec:
+  replicas: 3
+  paused: false
+  template:
+    spec:
+      containers:
+      - name: web
+`
+
+func TestParse(t *testing.T) {
+	for _, data := range []string{deployment, `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web", "labels": {"app": "web", "tier": "critical"}, "annotations": {"cpu": "500m"}}, "spec": {"replicas": 3, "paused": false, "template": {"spec": {"containers": [{"name": "web"}]}}}}`} {
+		m, err := Parse("web", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.APIVersion() != "apps/v1" || m.Kind() != "Deployment" || m.Name() != "web" || m.Namespace("default") != "default" {
+			t.Errorf("header read as %s %s %s %s", m.APIVersion(), m.Kind(), m.Name(), m.Namespace("default"))
+		}
+		if !reflect.DeepEqual(m.Labels(), map[string]string{"app": "web", "tier": "critical"}) || m.Annotations()["cpu"] != "500m" {
+			t.Errorf("metadata read as %v, %v", m.Labels(), m.Annotations())
+		}
+		if n, found, err := m.Int("spec", "replicas"); n != 3 || !found || err != nil {
+			t.Errorf("spec.replicas = %d, %t, %v", n, found, err)
+		}
+		if f, _, err := m.Float("spec", "replicas"); f != 3 || err != nil {
+			t.Errorf("spec.replicas as a float = %g, %v", f, err)
+		}
+		if b, found, err := m.Bool("spec", "paused"); b || !found || err != nil {
+			t.Errorf("spec.paused = %t, %t, %v", b, found, err)
+		}
+		if list, _, err := m.Slice("spec", "template", "spec", "containers"); len(list) != 1 || err != nil {
+			t.Errorf("containers = %v, %v", list, err)
+		}
+		if _, found, err := m.String("spec", "strategy", "type"); found || err != nil {
+			t.Errorf("an unset field should be not found without error, got %t, %v", found, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"empty", "  \n", "unit bad: no data"},
+		{"syntax", "kind: [Deployment", "unit bad: parse data:"},
+		{"not an object", "- a\n- b\n", "unit bad: data is a list, not an object"},
+		{"fields", "apiVersion: 1\nmetadata:\n  name: 42\n", "unit bad: invalid manifest: apiVersion: is a number, not a string; kind: missing; metadata.name: is a number, not a string"},
+		{"json", `{"kind": "Service", "metadata": {}`, "unit bad: parse data:"},
+		{"metadata", "apiVersion: v1\nkind: Service\nmetadata: web\n", "unit bad: invalid manifest: metadata: is a string, not an object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("bad", tt.data)
+			var uerr *Error
+			if !errors.As(err, &uerr) || uerr.Unit != "bad" {
+				t.Fatalf("want a unit error, got %v", err)
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	m, err := ParseObject("opencost-config", `{"enabled": true, "url": "http://opencost:9003"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _, err := m.String("url"); url != "http://opencost:9003" || err != nil {
+		t.Errorf("url = %q, %v", url, err)
+	}
+	if m.Kind() != "" || m.Name() != "" {
+		t.Error("a settings object has no kind or name")
+	}
+	if _, err := ParseObject("opencost-config", "[1, 2]"); err == nil {
+		t.Error("a list is not an object")
+	}
+}
+
+func TestAccessorTypeErrors(t *testing.T) {
+	m, err := Parse("web", deployment+"status: ready\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := m.Int("metadata", "name"); !found || err == nil || err.Error() != "unit web: invalid manifest: metadata.name: is a string, not a whole number" {
+		t.Errorf("Int of a string: %t, %v", found, err)
+	}
+	if _, _, err := m.Int("status", "replicas"); err == nil || !strings.Contains(err.Error(), "status: is a string, not an object") {
+		t.Errorf("walking through a string should name it, got %v", err)
+	}
+
+	m, _ = Parse("web", "apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\nspec:\n  replicas: 1.5\n")
+	if _, _, err := m.Int("spec", "replicas"); err == nil {
+		t.Error("1.5 is not a whole number")
+	}
+}
@@ -0,0 +1,79 @@
+// Package webhook signs the webhooks the apps send and verifies the ones they
+// receive, with an HMAC-SHA256 of the body in the "sha256=<hex>" form GitHub
+// and ConfigHub use.
+//
+// A secret may hold several keys separated by commas, to rotate them without
+// dropping webhooks: senders sign with every key, one signature per key in
+// the header, and receivers accept a signature made with any of theirs. Add
+// the new key on both sides, then remove the old one.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// SignatureHeader carries the signatures of the request body
+const SignatureHeader = "X-Signature-256"
+
+// Keys are the shared secrets of one webhook, the newest first
+type Keys [][]byte
+
+// ParseKeys splits a secret into its comma separated keys, ignoring blanks
+func ParseKeys(secret string) Keys {
+	var keys Keys
+	for _, key := range strings.Split(secret, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, []byte(key))
+		}
+	}
+	return keys
+}
+
+// Sign returns the header value signing body with every key, or "" without
+// keys
+func (k Keys) Sign(body []byte) string {
+	sigs := make([]string, 0, len(k))
+	for _, key := range k {
+		sigs = append(sigs, "sha256="+hex.EncodeToString(mac(key, body)))
+	}
+	return strings.Join(sigs, ", ")
+}
+
+// SignRequest sets the signature header of a request with body; without keys
+// the request goes unsigned
+func (k Keys) SignRequest(req *http.Request, body []byte) {
+	if sig := k.Sign(body); sig != "" {
+		req.Header.Set(SignatureHeader, sig)
+	}
+}
+
+// Verify reports whether any signature in header is body's HMAC under one of
+// the keys. Without keys nothing verifies.
+func (k Keys) Verify(body []byte, header string) bool {
+	for _, sig := range strings.Split(header, ",") {
+		hexSig, ok := strings.CutPrefix(strings.TrimSpace(sig), "sha256=")
+		if !ok {
+			continue
+		}
+		got, err := hex.DecodeString(hexSig)
+		if err != nil {
+			continue
+		}
+		for _, key := range k {
+			if hmac.Equal(got, mac(key, body)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mac(key, body []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys := ParseKeys(" new , ,old,")
+	if len(keys) != 2 || string(keys[0]) != "new" || string(keys[1]) != "old" {
+		t.Errorf("ParseKeys = %q", keys)
+	}
+	if keys := ParseKeys(""); keys != nil {
+		t.Errorf("ParseKeys(\"\") = %q, want none", keys)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"unit":"backend"}`)
+	// HMAC-SHA256 of body under "s3cret", as GitHub or ConfigHub would send it
+	const signed = "sha256=579efc3d48732031d237f11e5cc8ba5ab21a8ce0faa9b7931c1f992c1d1c3058"
+
+	tests := []struct {
+		name   string
+		keys   string
+		header string
+		want   bool
+	}{
+		{"valid", "s3cret", signed, true},
+		{"signed here", "s3cret", ParseKeys("s3cret").Sign(body), true},
+		{"wrong key", "s3cret", ParseKeys("other").Sign(body), false},
+		{"rotating receiver", "new,s3cret", ParseKeys("s3cret").Sign(body), true},
+		{"rotating sender", "new", ParseKeys("s3cret,new").Sign(body), true},
+		{"rotated away", "new", ParseKeys("s3cret").Sign(body), false},
+		{"missing prefix", "s3cret", strings.TrimPrefix(ParseKeys("s3cret").Sign(body), "sha256="), false},
+		{"not hex", "s3cret", "sha256=zz", false},
+		{"empty", "s3cret", "", false},
+		{"no keys", "", ParseKeys("").Sign(body), false},
+		{"other body", "s3cret", ParseKeys("s3cret").Sign([]byte(`{}`)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseKeys(tt.keys).Verify(body, tt.header); got != tt.want {
+				t.Errorf("Verify(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	body := []byte(`{}`)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+	ParseKeys("a,b").SignRequest(req, body)
+	sig := req.Header.Get(SignatureHeader)
+	if strings.Count(sig, "sha256=") != 2 || !ParseKeys("b").Verify(body, sig) {
+		t.Errorf("%s = %q, want one signature per key", SignatureHeader, sig)
+	}
+
+	req.Header.Del(SignatureHeader)
+	Keys(nil).SignRequest(req, body)
+	if _, ok := req.Header[SignatureHeader]; ok {
+		t.Error("Signed a request without keys")
+	}
+}
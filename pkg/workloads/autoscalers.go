@@ -0,0 +1,143 @@
+package workloads
+
+import (
+	"fmt"
+	"math"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// HorizontalPodAutoscaler is the kind of an HPA, which is not a workload
+// but scales one
+const HorizontalPodAutoscaler = "HorizontalPodAutoscaler"
+
+// DefaultTargetCPUUtilization is the CPU utilization an HPA without metrics
+// scales to, in percent of the pods' requests
+const DefaultTargetCPUUtilization = 80
+
+// MaxTargetCPUUtilization is the highest CPU utilization target Rescale
+// recommends, leaving the pods headroom while new ones start
+const MaxTargetCPUUtilization = 90
+
+// Autoscaler returns the HPA scaling the workload of kind named name in
+// namespace, nil when none does
+func (c Cluster) Autoscaler(namespace, kind, name string) *autoscalingv2.HorizontalPodAutoscaler {
+	for i := range c.Autoscalers {
+		hpa := &c.Autoscalers[i]
+		ref := hpa.Spec.ScaleTargetRef
+		if hpa.Namespace == namespace && ref.Kind == kind && ref.Name == name {
+			return hpa
+		}
+	}
+	return nil
+}
+
+// TargetCPUUtilization returns the CPU utilization hpa scales to, in
+// percent, 0 when it scales on other metrics only
+func TargetCPUUtilization(hpa autoscalingv2.HorizontalPodAutoscaler) int32 {
+	if len(hpa.Spec.Metrics) == 0 {
+		return DefaultTargetCPUUtilization
+	}
+	for _, m := range hpa.Spec.Metrics {
+		if cpuUtilization(m) && m.Resource.Target.AverageUtilization != nil {
+			return *m.Resource.Target.AverageUtilization
+		}
+	}
+	return 0
+}
+
+// cpuUtilization tells whether m scales on the CPU utilization of the pods
+func cpuUtilization(m autoscalingv2.MetricSpec) bool {
+	return m.Type == autoscalingv2.ResourceMetricSourceType && m.Resource != nil &&
+		m.Resource.Name == corev1.ResourceCPU && m.Resource.Target.Type == autoscalingv2.UtilizationMetricType
+}
+
+// Rescale returns the change of hpa that lets its workload run replicas
+// pods, instead of setting the workload's replicas the HPA would undo:
+//
+//	minReplicas              lowered to replicas when it is above
+//	targetCPUUtilization     raised so that the load the HPA now spreads
+//	                         over its current replicas fills replicas pods,
+//	                         up to MaxTargetCPUUtilization
+//	maxReplicas              lowered to replicas when the HPA sits at its
+//	                         maximum, where the cap and not the target
+//	                         decides how many pods run
+//
+// It reports false when hpa already allows replicas pods at its target.
+func Rescale(hpa autoscalingv2.HorizontalPodAutoscaler, replicas int32) (Change, bool) {
+	if replicas < 1 {
+		replicas = 1
+	}
+	minReplicas := int32(1)
+	if hpa.Spec.MinReplicas != nil {
+		minReplicas = *hpa.Spec.MinReplicas
+	}
+	current := hpa.Status.CurrentReplicas
+
+	var change Change
+	if replicas < minReplicas {
+		change.MinReplicas = &replicas
+	}
+	if target := TargetCPUUtilization(hpa); target > 0 && current > replicas {
+		raised := int32(math.Ceil(float64(target) * float64(current) / float64(replicas)))
+		if raised > MaxTargetCPUUtilization {
+			raised = MaxTargetCPUUtilization
+		}
+		if raised > target {
+			change.TargetCPUUtilization = &raised
+		}
+	}
+	if current >= hpa.Spec.MaxReplicas && replicas < hpa.Spec.MaxReplicas {
+		change.MaxReplicas = &replicas
+	}
+	return change, !change.Empty()
+}
+
+// autoscalerPatch returns the merge patch making manifest, an HPA's, as
+// change says. The CPU utilization target is set in the HPA's metrics,
+// which the patch holds all of, and added to them when they have none.
+func autoscalerPatch(manifest map[string]interface{}, change Change) (map[string]interface{}, error) {
+	if change.CPU != "" || change.Memory != "" || change.Replicas != nil {
+		return nil, fmt.Errorf("a %s has no requests or replicas, only bounds", HorizontalPodAutoscaler)
+	}
+	spec := make(map[string]interface{})
+	if change.MinReplicas != nil {
+		spec["minReplicas"] = int64(*change.MinReplicas)
+	}
+	if change.MaxReplicas != nil {
+		spec["maxReplicas"] = int64(*change.MaxReplicas)
+	}
+	if change.TargetCPUUtilization != nil {
+		target := map[string]interface{}{"type": string(autoscalingv2.UtilizationMetricType), "averageUtilization": int64(*change.TargetCPUUtilization)}
+		list, _ := lookup(manifest, []string{"spec", "metrics"}).([]interface{})
+		metrics := make([]interface{}, 0, len(list)+1)
+		found := false
+		for _, m := range list {
+			metric, ok := m.(map[string]interface{})
+			if !ok || metric["type"] != string(autoscalingv2.ResourceMetricSourceType) {
+				metrics = append(metrics, m)
+				continue
+			}
+			res, _ := metric["resource"].(map[string]interface{})
+			old, _ := res["target"].(map[string]interface{})
+			if res["name"] != string(corev1.ResourceCPU) || old["type"] != string(autoscalingv2.UtilizationMetricType) {
+				metrics = append(metrics, m)
+				continue
+			}
+			found = true
+			metrics = append(metrics, map[string]interface{}{
+				"type":     metric["type"],
+				"resource": map[string]interface{}{"name": res["name"], "target": target},
+			})
+		}
+		if !found {
+			metrics = append(metrics, map[string]interface{}{
+				"type":     string(autoscalingv2.ResourceMetricSourceType),
+				"resource": map[string]interface{}{"name": string(corev1.ResourceCPU), "target": target},
+			})
+		}
+		spec["metrics"] = metrics
+	}
+	return map[string]interface{}{"spec": spec}, nil
+}
@@ -0,0 +1,167 @@
+package workloads
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func hpa(name, kind, target string, minReplicas *int32, maxReplicas, current int32, metrics ...autoscalingv2.MetricSpec) autoscalingv2.HorizontalPodAutoscaler {
+	return autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: meta(name),
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: kind, Name: target},
+			MinReplicas:    minReplicas,
+			MaxReplicas:    maxReplicas,
+			Metrics:        metrics,
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: current},
+	}
+}
+
+func cpuTarget(percent int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name:   corev1.ResourceCPU,
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &percent},
+		},
+	}
+}
+
+func TestAutoscaler(t *testing.T) {
+	cluster := Cluster{Autoscalers: []autoscalingv2.HorizontalPodAutoscaler{
+		hpa("api", Deployment, "api", nil, 10, 3),
+		hpa("db", StatefulSet, "db", nil, 5, 3),
+	}}
+	if got := cluster.Autoscaler("shop", Deployment, "api"); got == nil || got.Name != "api" {
+		t.Errorf("api: got %v", got)
+	}
+	for _, miss := range [][3]string{{"shop", StatefulSet, "api"}, {"other", Deployment, "api"}, {"shop", Deployment, "web"}} {
+		if got := cluster.Autoscaler(miss[0], miss[1], miss[2]); got != nil {
+			t.Errorf("%v: got %s", miss, got.Name)
+		}
+	}
+
+	m, ok := cluster.Manifest(Key("shop", HorizontalPodAutoscaler, "api"))
+	if !ok || m["apiVersion"] != "autoscaling/v2" || m["kind"] != HorizontalPodAutoscaler {
+		t.Errorf("manifest: %v %v", m, ok)
+	}
+}
+
+func TestTargetCPUUtilization(t *testing.T) {
+	memory := autoscalingv2.MetricSpec{Type: autoscalingv2.ResourceMetricSourceType, Resource: &autoscalingv2.ResourceMetricSource{
+		Name: corev1.ResourceMemory, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType},
+	}}
+	tests := []struct {
+		name    string
+		metrics []autoscalingv2.MetricSpec
+		want    int32
+	}{
+		{"no metrics", nil, DefaultTargetCPUUtilization},
+		{"cpu", []autoscalingv2.MetricSpec{memory, cpuTarget(60)}, 60},
+		{"memory only", []autoscalingv2.MetricSpec{memory}, 0},
+	}
+	for _, tt := range tests {
+		if got := TargetCPUUtilization(hpa("api", Deployment, "api", nil, 10, 3, tt.metrics...)); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRescale(t *testing.T) {
+	tests := []struct {
+		name     string
+		hpa      autoscalingv2.HorizontalPodAutoscaler
+		replicas int32
+		want     string // min/max/target, - for unchanged
+	}{
+		{"raise target", hpa("api", Deployment, "api", int32p(2), 10, 6, cpuTarget(50)), 4, "-/-/75"},
+		{"target capped", hpa("api", Deployment, "api", int32p(2), 10, 6, cpuTarget(60)), 3, "-/-/90"},
+		{"default target", hpa("api", Deployment, "api", int32p(2), 10, 5), 4, "-/-/90"},
+		{"lower min", hpa("api", Deployment, "api", int32p(4), 10, 4, cpuTarget(70)), 2, "2/-/90"},
+		{"at max", hpa("api", Deployment, "api", int32p(2), 6, 6, cpuTarget(90)), 4, "-/4/-"},
+		{"no cpu target", hpa("api", Deployment, "api", int32p(3), 10, 5, autoscalingv2.MetricSpec{Type: autoscalingv2.PodsMetricSourceType}), 2, "2/-/-"},
+		{"zero replicas", hpa("api", Deployment, "api", int32p(2), 10, 2, cpuTarget(40)), 0, "1/-/80"},
+		{"allowed already", hpa("api", Deployment, "api", int32p(1), 10, 3, cpuTarget(80)), 3, ""},
+	}
+	show := func(p *int32) string {
+		if p == nil {
+			return "-"
+		}
+		return strconv.Itoa(int(*p))
+	}
+	for _, tt := range tests {
+		change, ok := Rescale(tt.hpa, tt.replicas)
+		got := ""
+		if ok {
+			got = show(change.MinReplicas) + "/" + show(change.MaxReplicas) + "/" + show(change.TargetCPUUtilization)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAutoscalerPatch(t *testing.T) {
+	manifest := func(metrics string) map[string]interface{} {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(`{"kind": "HorizontalPodAutoscaler", "spec": {"maxReplicas": 10, "metrics": `+metrics+`}}`), &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	pods := `{"type": "Pods", "pods": {"metric": {"name": "rps"}}}`
+	cpu := `{"type": "Resource", "resource": {"name": "cpu", "target": {"type": "Utilization", "averageUtilization": 50}}}`
+
+	tests := []struct {
+		name     string
+		manifest map[string]interface{}
+		change   Change
+		want     string
+		err      string
+	}{
+		{
+			name:     "bounds and target",
+			manifest: manifest(`[` + pods + `, ` + cpu + `]`),
+			change:   Change{MinReplicas: int32p(1), MaxReplicas: int32p(6), TargetCPUUtilization: int32p(75)},
+			want: `{"spec": {"minReplicas": 1, "maxReplicas": 6, "metrics": [` + pods + `,
+				{"type": "Resource", "resource": {"name": "cpu", "target": {"type": "Utilization", "averageUtilization": 75}}}]}}`,
+		},
+		{
+			name:     "target added",
+			manifest: manifest(`null`),
+			change:   Change{TargetCPUUtilization: int32p(90)},
+			want:     `{"spec": {"metrics": [{"type": "Resource", "resource": {"name": "cpu", "target": {"type": "Utilization", "averageUtilization": 90}}}]}}`,
+		},
+		{name: "requests", manifest: manifest(`[]`), change: Change{CPU: "100m"}, err: "no requests or replicas"},
+		{name: "bounds of a deployment", manifest: map[string]interface{}{"kind": Deployment}, change: Change{MinReplicas: int32p(1)}, err: "no autoscaling bounds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := Patch(tt.manifest, tt.change)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(patch)
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			wantJSON, _ := json.Marshal(want)
+			if string(got) != string(wantJSON) {
+				t.Errorf("got %s\nwant %s", got, wantJSON)
+			}
+		})
+	}
+}
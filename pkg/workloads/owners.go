@@ -0,0 +1,129 @@
+package workloads
+
+import (
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// Key identifies a workload: namespace/Kind/name
+func Key(namespace, kind, name string) string {
+	return namespace + "/" + kind + "/" + name
+}
+
+// Key identifies w
+func (w Workload) Key() string {
+	return Key(w.Namespace, w.Kind, w.Name)
+}
+
+// Owners maps the namespace/name of each of the cluster's pods to the Key of
+// the workload running it, following controller references: a pod's
+// ReplicaSet to its Deployment and its Job to its CronJob. A ReplicaSet
+// missing from the listing is matched to its Deployment by the pod's
+// pod-template-hash label.
+func (c Cluster) Owners() map[string]string {
+	replicaSets := make(map[string]string, len(c.ReplicaSets))
+	for _, rs := range c.ReplicaSets {
+		replicaSets[rs.Namespace+"/"+rs.Name] = controller(rs.ObjectMeta, "Deployment")
+	}
+	jobs := make(map[string]string, len(c.Jobs))
+	for _, j := range c.Jobs {
+		jobs[j.Namespace+"/"+j.Name] = controller(j.ObjectMeta, "CronJob")
+	}
+
+	owners := make(map[string]string, len(c.Pods))
+	for _, p := range c.Pods {
+		kind, name := Pod, p.Name
+		if ref := metav1.GetControllerOfNoCopy(&p); ref != nil {
+			kind, name = ref.Kind, ref.Name
+			switch kind {
+			case "ReplicaSet":
+				deployment, listed := replicaSets[p.Namespace+"/"+name]
+				if hash := p.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; !listed && hash != "" {
+					deployment = strings.TrimSuffix(name, "-"+hash)
+				}
+				if deployment != "" {
+					kind, name = Deployment, deployment
+				}
+			case Job:
+				if cronJob := jobs[p.Namespace+"/"+name]; cronJob != "" {
+					kind, name = CronJob, cronJob
+				}
+			}
+		}
+		owners[p.Namespace+"/"+p.Name] = Key(p.Namespace, kind, name)
+	}
+	return owners
+}
+
+// Attribute groups pod metrics by the Key of the workload running each pod,
+// as told by Owners. Pods missing from the cluster's listing, like those
+// started since, go to the workload whose name is the longest prefix of
+// theirs.
+func (c Cluster) Attribute(metrics []metricsv1beta1.PodMetrics) map[string][]metricsv1beta1.PodMetrics {
+	owners := c.Owners()
+	var list []Workload
+	byWorkload := make(map[string][]metricsv1beta1.PodMetrics)
+	for _, m := range metrics {
+		key, ok := owners[m.Namespace+"/"+m.Name]
+		if !ok {
+			if list == nil {
+				list = c.Workloads()
+			}
+			if key, ok = longestPrefix(list, m.Namespace, m.Name); !ok {
+				continue
+			}
+		}
+		byWorkload[key] = append(byWorkload[key], m)
+	}
+	return byWorkload
+}
+
+// longestPrefix is the Key of the workload of namespace owning the pod name
+// with the longest name
+func longestPrefix(list []Workload, namespace, name string) (string, bool) {
+	var best *Workload
+	for i, w := range list {
+		if w.Namespace == namespace && w.Owns(name) && (best == nil || len(w.Name) > len(best.Name)) {
+			best = &list[i]
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.Key(), true
+}
+
+// Restarts counts the container restarts since a time of the pods of the
+// workload with key. All restarts of a pod started since count; a pod
+// started before counts once per container that last restarted since, its
+// earlier restarts being indistinguishable.
+func (c Cluster) Restarts(key string, since time.Time) int {
+	owners := c.Owners()
+	restarts := 0
+	for _, p := range c.Pods {
+		if owners[p.Namespace+"/"+p.Name] != key {
+			continue
+		}
+		started := !p.CreationTimestamp.Time.Before(since)
+		for _, status := range append(p.Status.InitContainerStatuses, p.Status.ContainerStatuses...) {
+			switch last := status.LastTerminationState.Terminated; {
+			case started:
+				restarts += int(status.RestartCount)
+			case last != nil && !last.FinishedAt.Time.Before(since):
+				restarts++
+			}
+		}
+	}
+	return restarts
+}
+
+// Requests sums what pods of spec request, for utilization against usage
+func Requests(spec corev1.PodSpec) (cpuMillicores, memoryBytes int64) {
+	w := fromPodSpec("", metav1.ObjectMeta{}, 1, spec)
+	return w.CPUMillicores, w.MemoryBytes
+}
@@ -0,0 +1,120 @@
+package workloads
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+func TestOwners(t *testing.T) {
+	pod := func(name string, labels map[string]string, owner ...metav1.OwnerReference) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: meta(name, owner...)}
+		p.Labels = labels
+		return p
+	}
+	cluster := Cluster{
+		// api and api-gateway share a prefix; so do their pods
+		ReplicaSets: []appsv1.ReplicaSet{
+			{ObjectMeta: meta("api-5d8f", ownedBy("Deployment", "api"))},
+			{ObjectMeta: meta("api-gateway-7c4b", ownedBy("Deployment", "api-gateway"))},
+			{ObjectMeta: meta("bare-rs")},
+		},
+		Jobs: []batchv1.Job{{ObjectMeta: meta("report-2901", ownedBy("CronJob", "report"))}},
+		Pods: []corev1.Pod{
+			pod("api-5d8f-x1", nil, ownedBy("ReplicaSet", "api-5d8f")),
+			pod("api-gateway-7c4b-y2", nil, ownedBy("ReplicaSet", "api-gateway-7c4b")),
+			pod("api-gateway-9e1a-z3", map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "9e1a"}, ownedBy("ReplicaSet", "api-gateway-9e1a")),
+			pod("bare-rs-q4", nil, ownedBy("ReplicaSet", "bare-rs")),
+			pod("report-2901-k5", nil, ownedBy("Job", "report-2901")),
+			pod("db-0", nil, ownedBy("StatefulSet", "db")),
+			pod("debug", nil),
+		},
+	}
+	want := map[string]string{
+		"shop/api-5d8f-x1":         "shop/Deployment/api",
+		"shop/api-gateway-7c4b-y2": "shop/Deployment/api-gateway",
+		"shop/api-gateway-9e1a-z3": "shop/Deployment/api-gateway", // ReplicaSet not listed
+		"shop/bare-rs-q4":          "shop/ReplicaSet/bare-rs",
+		"shop/report-2901-k5":      "shop/CronJob/report",
+		"shop/db-0":                "shop/StatefulSet/db",
+		"shop/debug":               "shop/Pod/debug",
+	}
+	if got := cluster.Owners(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	cluster := Cluster{
+		Deployments: []appsv1.Deployment{{ObjectMeta: meta("api")}, {ObjectMeta: meta("api-gateway")}},
+		ReplicaSets: []appsv1.ReplicaSet{{ObjectMeta: meta("api-gateway-7c4b", ownedBy("Deployment", "api-gateway"))}},
+		Pods:        []corev1.Pod{{ObjectMeta: meta("api-gateway-7c4b-y2", ownedBy("ReplicaSet", "api-gateway-7c4b"))}},
+	}
+	metric := func(namespace, name string) metricsv1beta1.PodMetrics {
+		return metricsv1beta1.PodMetrics{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	got := cluster.Attribute([]metricsv1beta1.PodMetrics{
+		metric("shop", "api-gateway-7c4b-y2"), // listed
+		metric("shop", "api-gateway-7c4b-n6"), // started since: longest prefix
+		metric("shop", "api-5d8f-x1"),
+		metric("shop", "unknown-1"),
+		metric("other", "api-5d8f-x1"),
+	})
+	names := make(map[string][]string)
+	for key, pods := range got {
+		for _, p := range pods {
+			names[key] = append(names[key], p.Name)
+		}
+		sort.Strings(names[key])
+	}
+	want := map[string][]string{
+		"shop/Deployment/api-gateway": {"api-gateway-7c4b-n6", "api-gateway-7c4b-y2"},
+		"shop/Deployment/api":         {"api-5d8f-x1"},
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	spec := podSpec("250m", "128Mi")
+	spec.Containers = append(spec.Containers, podSpec("500m", "64Mi").Containers...)
+	if cpu, memory := Requests(spec); cpu != 750 || memory != 192*1024*1024 {
+		t.Errorf("got %d millicores, %d bytes", cpu, memory)
+	}
+}
+
+func TestRestarts(t *testing.T) {
+	applied := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	pod := func(name string, created time.Time, restarts int32, lastRestart time.Time) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: meta(name, ownedBy("ReplicaSet", "api-5d8f"))}
+		p.CreationTimestamp = metav1.NewTime(created)
+		status := corev1.ContainerStatus{RestartCount: restarts}
+		if restarts > 0 {
+			status.LastTerminationState.Terminated = &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(lastRestart)}
+		}
+		p.Status.ContainerStatuses = []corev1.ContainerStatus{status}
+		return p
+	}
+	cluster := Cluster{
+		ReplicaSets: []appsv1.ReplicaSet{{ObjectMeta: meta("api-5d8f", ownedBy("Deployment", "api"))}},
+		Pods: []corev1.Pod{
+			pod("api-5d8f-new", applied.Add(time.Minute), 3, applied.Add(time.Hour)),    // all since
+			pod("api-5d8f-old", applied.Add(-time.Hour), 7, applied.Add(time.Hour)),     // at least one since
+			pod("api-5d8f-calm", applied.Add(-time.Hour), 2, applied.Add(-time.Minute)), // none since
+		},
+	}
+	if got := cluster.Restarts(Key("shop", "Deployment", "api"), applied); got != 4 {
+		t.Errorf("got %d restarts, want 4", got)
+	}
+	if got := cluster.Restarts(Key("shop", "Deployment", "web"), applied); got != 0 {
+		t.Errorf("another workload: got %d restarts", got)
+	}
+}
@@ -0,0 +1,254 @@
+package workloads
+
+import (
+	"fmt"
+	"math"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Change is what to set on a workload: the requests of its pods, split
+// across their containers in proportion to what each requests now, and its
+// replicas; or on an HPA, its bounds. Unset fields are left as they are.
+type Change struct {
+	CPU      string // e.g. 250m
+	Memory   string // e.g. 256Mi
+	Replicas *int32
+
+	// Of an HPA
+	MinReplicas          *int32
+	MaxReplicas          *int32
+	TargetCPUUtilization *int32 // percent of the pods' requests
+}
+
+// Empty tells whether c changes nothing
+func (c Change) Empty() bool {
+	return c.CPU == "" && c.Memory == "" && c.Replicas == nil &&
+		c.MinReplicas == nil && c.MaxReplicas == nil && c.TargetCPUUtilization == nil
+}
+
+// Manifest returns the manifest of the workload with key, as read from the
+// cluster but for its status and the fields the API server sets, for a
+// ConfigHub unit to hold. Only workloads whose pod template can change -
+// Deployments, StatefulSets, DaemonSets and CronJobs - have one, and so do
+// the HPAs scaling them.
+func (c Cluster) Manifest(key string) (map[string]interface{}, bool) {
+	var obj runtime.Object
+	for i := range c.Deployments {
+		if d := &c.Deployments[i]; Key(d.Namespace, Deployment, d.Name) == key {
+			obj = withKind(d.DeepCopy(), "apps/v1", Deployment)
+		}
+	}
+	for i := range c.StatefulSets {
+		if s := &c.StatefulSets[i]; Key(s.Namespace, StatefulSet, s.Name) == key {
+			obj = withKind(s.DeepCopy(), "apps/v1", StatefulSet)
+		}
+	}
+	for i := range c.DaemonSets {
+		if d := &c.DaemonSets[i]; Key(d.Namespace, DaemonSet, d.Name) == key {
+			obj = withKind(d.DeepCopy(), "apps/v1", DaemonSet)
+		}
+	}
+	for i := range c.CronJobs {
+		if cj := &c.CronJobs[i]; Key(cj.Namespace, CronJob, cj.Name) == key {
+			obj = withKind(cj.DeepCopy(), "batch/v1", CronJob)
+		}
+	}
+	for i := range c.Autoscalers {
+		if hpa := &c.Autoscalers[i]; Key(hpa.Namespace, HorizontalPodAutoscaler, hpa.Name) == key {
+			obj = withKind(hpa.DeepCopy(), "autoscaling/v2", HorizontalPodAutoscaler)
+		}
+	}
+	if obj == nil {
+		return nil, false
+	}
+	manifest, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, false
+	}
+	delete(manifest, "status")
+	if metadata, ok := manifest["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields"} {
+			delete(metadata, field)
+		}
+	}
+	return manifest, true
+}
+
+// withKind sets the type of obj, which objects listed by a typed client
+// lack
+func withKind(obj runtime.Object, apiVersion, kind string) runtime.Object {
+	obj.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(apiVersion, kind))
+	return obj
+}
+
+// Patch returns the merge patch making manifest, a workload's or an HPA's,
+// as change says. A merge patch replaces lists whole, so the patch holds
+// all the pod's containers as manifest has them, but for their requests.
+func Patch(manifest map[string]interface{}, change Change) (map[string]interface{}, error) {
+	if change.Empty() {
+		return nil, fmt.Errorf("nothing to change")
+	}
+	kind, _ := manifest["kind"].(string)
+	if kind == HorizontalPodAutoscaler {
+		return autoscalerPatch(manifest, change)
+	}
+	if change.MinReplicas != nil || change.MaxReplicas != nil || change.TargetCPUUtilization != nil {
+		return nil, fmt.Errorf("a %s has no autoscaling bounds; its %s has", kind, HorizontalPodAutoscaler)
+	}
+	path, ok := podSpecPath[kind]
+	if !ok {
+		return nil, fmt.Errorf("cannot change the pods of a %q", kind)
+	}
+	patch := make(map[string]interface{})
+
+	if change.Replicas != nil {
+		if kind != Deployment && kind != StatefulSet {
+			return nil, fmt.Errorf("cannot scale a %s", kind)
+		}
+		patch["spec"] = map[string]interface{}{"replicas": int64(*change.Replicas)}
+	}
+
+	if change.CPU != "" || change.Memory != "" {
+		spec, _ := lookup(manifest, path).(map[string]interface{})
+		list, _ := spec["containers"].([]interface{})
+		if len(list) == 0 {
+			return nil, fmt.Errorf("%s has no containers", kind)
+		}
+		containers := make([]map[string]interface{}, len(list))
+		for i, c := range list {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("container %d is not an object", i)
+			}
+			containers[i] = withRequests(container)
+		}
+		for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: change.CPU, corev1.ResourceMemory: change.Memory} {
+			if value == "" {
+				continue
+			}
+			if err := split(containers, name, value); err != nil {
+				return nil, err
+			}
+		}
+
+		patched := make([]interface{}, len(containers))
+		for i, c := range containers {
+			patched[i] = c
+		}
+		at := patch
+		for _, field := range path {
+			next, ok := at[field].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				at[field] = next
+			}
+			at = next
+		}
+		at["containers"] = patched
+	}
+	return patch, nil
+}
+
+// podSpecPath is where the pod spec of each kind Manifest returns is
+var podSpecPath = map[string][]string{
+	Deployment:  {"spec", "template", "spec"},
+	StatefulSet: {"spec", "template", "spec"},
+	DaemonSet:   {"spec", "template", "spec"},
+	CronJob:     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// lookup returns the field at path of obj, nil when it is not there
+func lookup(obj map[string]interface{}, path []string) interface{} {
+	var v interface{} = obj
+	for _, field := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[field]
+	}
+	return v
+}
+
+// withRequests copies container deeply enough to set its requests
+func withRequests(container map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(container))
+	for k, v := range container {
+		c[k] = v
+	}
+	resources := make(map[string]interface{})
+	if r, ok := container["resources"].(map[string]interface{}); ok {
+		for k, v := range r {
+			resources[k] = v
+		}
+	}
+	requests := make(map[string]interface{})
+	if r, ok := resources["requests"].(map[string]interface{}); ok {
+		for k, v := range r {
+			requests[k] = v
+		}
+	}
+	resources["requests"] = requests
+	c["resources"] = resources
+	return c
+}
+
+// requestsOf returns the requests of a container withRequests copied
+func requestsOf(container map[string]interface{}) map[string]interface{} {
+	return container["resources"].(map[string]interface{})["requests"].(map[string]interface{})
+}
+
+// split sets the requests of name of containers to sum to value, each
+// keeping its share; the first container gets it all when none requests it
+func split(containers []map[string]interface{}, name corev1.ResourceName, value string) error {
+	total, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	current := make([]int64, len(containers))
+	var sum int64
+	for i, c := range containers {
+		v, ok := requestsOf(c)[string(name)]
+		if !ok {
+			continue
+		}
+		q, err := resource.ParseQuantity(fmt.Sprint(v))
+		if err != nil {
+			return fmt.Errorf("container %d: %s: %w", i, name, err)
+		}
+		current[i] = amount(q, name)
+		sum += current[i]
+	}
+	if sum == 0 || len(containers) == 1 {
+		requestsOf(containers[0])[string(name)] = total.String()
+		return nil
+	}
+	for i, c := range containers {
+		if current[i] == 0 {
+			continue
+		}
+		share := int64(math.Round(float64(amount(total, name)) * float64(current[i]) / float64(sum)))
+		requestsOf(c)[string(name)] = quantity(share, name)
+	}
+	return nil
+}
+
+// amount is q in millicores for CPU, bytes otherwise
+func amount(q resource.Quantity, name corev1.ResourceName) int64 {
+	if name == corev1.ResourceCPU {
+		return q.MilliValue()
+	}
+	return q.Value()
+}
+
+// quantity formats an amount of name
+func quantity(amount int64, name corev1.ResourceName) string {
+	if name == corev1.ResourceCPU {
+		return resource.NewMilliQuantity(amount, resource.DecimalSI).String()
+	}
+	return resource.NewQuantity(amount, resource.BinarySI).String()
+}
@@ -0,0 +1,147 @@
+package workloads
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestManifest(t *testing.T) {
+	api := appsv1.Deployment{ObjectMeta: meta("api"), Spec: appsv1.DeploymentSpec{Replicas: int32p(2)}}
+	api.Spec.Template.Spec = podSpec("250m", "128Mi")
+	api.UID, api.ResourceVersion, api.Generation = "1f2e", "812", 3
+	api.CreationTimestamp = metav1.Now()
+	api.Status.ReadyReplicas = 2
+	cluster := Cluster{
+		Deployments: []appsv1.Deployment{api},
+		CronJobs:    []batchv1.CronJob{{ObjectMeta: meta("report")}},
+		Jobs:        []batchv1.Job{{ObjectMeta: meta("backfill")}},
+	}
+
+	m, ok := cluster.Manifest(Key("shop", Deployment, "api"))
+	if !ok {
+		t.Fatal("no manifest for the deployment")
+	}
+	if m["apiVersion"] != "apps/v1" || m["kind"] != Deployment {
+		t.Errorf("type %v %v", m["apiVersion"], m["kind"])
+	}
+	if _, ok := m["status"]; ok {
+		t.Error("status kept")
+	}
+	metadata := m["metadata"].(map[string]interface{})
+	if !reflect.DeepEqual(metadata, map[string]interface{}{"name": "api", "namespace": "shop"}) {
+		t.Errorf("metadata %v", metadata)
+	}
+	if cluster.Deployments[0].Kind != "" {
+		t.Error("cluster's deployment changed")
+	}
+
+	if m, ok := cluster.Manifest(Key("shop", CronJob, "report")); !ok || m["apiVersion"] != "batch/v1" {
+		t.Errorf("cronjob: %v %v", m, ok)
+	}
+	for _, key := range []string{Key("shop", Job, "backfill"), Key("shop", Deployment, "web")} {
+		if _, ok := cluster.Manifest(key); ok {
+			t.Errorf("%s: got a manifest", key)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	manifest := func(kind, containers string) map[string]interface{} {
+		spec := `{"template": {"spec": {"containers": ` + containers + `}}}`
+		if kind == CronJob {
+			spec = `{"jobTemplate": {"spec": ` + spec + `}}`
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(`{"kind": "`+kind+`", "spec": `+spec+`}`), &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	one := `[{"name": "web", "image": "web:1", "resources": {"requests": {"cpu": "1", "memory": "1Gi"}, "limits": {"cpu": "2"}}}]`
+	two := `[{"name": "web", "image": "web:1", "resources": {"requests": {"cpu": "750m", "memory": "768Mi"}}},
+		{"name": "proxy", "image": "proxy:1", "resources": {"requests": {"cpu": "250m"}}}]`
+
+	tests := []struct {
+		name     string
+		manifest map[string]interface{}
+		change   Change
+		want     string // JSON
+		err      string
+	}{
+		{
+			name:     "one container",
+			manifest: manifest(Deployment, one),
+			change:   Change{CPU: "500m", Memory: "512Mi"},
+			want: `{"spec": {"template": {"spec": {"containers": [{"name": "web", "image": "web:1",
+				"resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": "2"}}}]}}}}`,
+		},
+		{
+			name:     "split by share",
+			manifest: manifest(StatefulSet, two),
+			change:   Change{CPU: "500m", Memory: "256Mi"},
+			want: `{"spec": {"template": {"spec": {"containers": [
+				{"name": "web", "image": "web:1", "resources": {"requests": {"cpu": "375m", "memory": "256Mi"}}},
+				{"name": "proxy", "image": "proxy:1", "resources": {"requests": {"cpu": "125m"}}}]}}}}`,
+		},
+		{
+			name:     "none requested",
+			manifest: manifest(DaemonSet, `[{"name": "agent"}, {"name": "log"}]`),
+			change:   Change{Memory: "64Mi"},
+			want: `{"spec": {"template": {"spec": {"containers": [
+				{"name": "agent", "resources": {"requests": {"memory": "64Mi"}}},
+				{"name": "log", "resources": {"requests": {}}}]}}}}`,
+		},
+		{
+			name:     "cronjob",
+			manifest: manifest(CronJob, one),
+			change:   Change{CPU: "100m"},
+			want: `{"spec": {"jobTemplate": {"spec": {"template": {"spec": {"containers": [{"name": "web", "image": "web:1",
+				"resources": {"requests": {"cpu": "100m", "memory": "1Gi"}, "limits": {"cpu": "2"}}}]}}}}}}`,
+		},
+		{
+			name:     "replicas and requests",
+			manifest: manifest(Deployment, one),
+			change:   Change{CPU: "1500m", Replicas: int32p(1)},
+			want: `{"spec": {"replicas": 1, "template": {"spec": {"containers": [{"name": "web", "image": "web:1",
+				"resources": {"requests": {"cpu": "1500m", "memory": "1Gi"}, "limits": {"cpu": "2"}}}]}}}}`,
+		},
+		{name: "nothing", manifest: manifest(Deployment, one), err: "nothing to change"},
+		{name: "scale daemonset", manifest: manifest(DaemonSet, one), change: Change{Replicas: int32p(1)}, err: "cannot scale"},
+		{name: "job", manifest: manifest(Job, one), change: Change{CPU: "1"}, err: "cannot change the pods"},
+		{name: "no containers", manifest: manifest(Deployment, `[]`), change: Change{CPU: "1"}, err: "no containers"},
+		{name: "bad quantity", manifest: manifest(Deployment, one), change: Change{Memory: "lots"}, err: "memory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := json.Marshal(tt.manifest)
+			patch, err := Patch(tt.manifest, tt.change)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(patch)
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			wantJSON, _ := json.Marshal(want)
+			if string(got) != string(wantJSON) {
+				t.Errorf("got %s\nwant %s", got, wantJSON)
+			}
+			if after, _ := json.Marshal(tt.manifest); string(after) != string(before) {
+				t.Error("manifest changed")
+			}
+		})
+	}
+}
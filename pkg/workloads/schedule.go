@@ -0,0 +1,140 @@
+package workloads
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Average days in a month, as the cost model prices it
+const daysPerMonth = float64(month) / float64(24*time.Hour)
+
+// macros are the schedules cron names
+var macros = map[string]time.Duration{
+	"@yearly":   12 * month,
+	"@annually": 12 * month,
+	"@monthly":  month,
+	"@weekly":   7 * 24 * time.Hour,
+	"@daily":    24 * time.Hour,
+	"@midnight": 24 * time.Hour,
+	"@hourly":   time.Hour,
+}
+
+// names of the months and weekdays, for the month and day-of-week fields
+var names = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// Interval is the average time between the runs of a CronJob schedule: a
+// five-field cron expression, a macro like @daily, or @every <duration>. A
+// CRON_TZ or TZ prefix is ignored.
+func Interval(schedule string) (time.Duration, bool) {
+	fields := strings.Fields(schedule)
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		fields = fields[1:]
+	}
+	if len(fields) == 1 {
+		every, ok := macros[fields[0]]
+		return every, ok
+	}
+	if len(fields) == 2 && fields[0] == "@every" {
+		every, err := time.ParseDuration(fields[1])
+		return every, err == nil
+	}
+	if len(fields) != 5 {
+		return 0, false
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var counts [5]int
+	for i, field := range fields {
+		values, ok := match(field, bounds[i][0], bounds[i][1])
+		if !ok {
+			return 0, false
+		}
+		if i == 4 && values[7] {
+			delete(values, 7) // 0 and 7 are both Sunday
+			values[0] = true
+		}
+		counts[i] = len(values)
+	}
+
+	// Share of days with runs; cron runs on either day field when both are
+	// restricted
+	dom, dow := !everyDay(fields[2]), !everyDay(fields[4])
+	days := 1.0
+	switch {
+	case dom && dow:
+		days = minFloat(1, float64(counts[2])/daysPerMonth+float64(counts[4])/7)
+	case dom:
+		days = minFloat(1, float64(counts[2])/daysPerMonth)
+	case dow:
+		days = float64(counts[4]) / 7
+	}
+	perDay := float64(counts[0]*counts[1]) * days * float64(counts[3]) / 12
+	if perDay == 0 {
+		return 0, false
+	}
+	return time.Duration(float64(24*time.Hour) / perDay), true
+}
+
+// everyDay tells whether a day field matches every day
+func everyDay(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// match returns the values of [low, high] a cron field matches
+func match(field string, low, high int) (map[int]bool, bool) {
+	matched := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return nil, false
+			}
+			step, part = s, part[:i]
+		}
+		from, to := low, high
+		if part != "*" && part != "?" {
+			bounds := strings.SplitN(part, "-", 2)
+			var ok bool
+			if from, ok = value(bounds[0]); !ok {
+				return nil, false
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, ok = value(bounds[1]); !ok {
+					return nil, false
+				}
+			} else if step > 1 {
+				to = high // a/n runs from a to the end
+			}
+		}
+		if from < low || to > high || from > to {
+			return nil, false
+		}
+		for v := from; v <= to; v += step {
+			matched[v] = true
+		}
+	}
+	return matched, true
+}
+
+// value parses a number or a month or weekday name
+func value(s string) (int, bool) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, true
+	}
+	v, err := strconv.Atoi(s)
+	return v, err == nil
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
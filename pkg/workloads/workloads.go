@@ -0,0 +1,224 @@
+// Package workloads reads what runs in a cluster - Deployments,
+// StatefulSets, DaemonSets, Jobs, CronJobs and standalone Pods - as
+// Workloads with what each requests, so an app prices the whole cluster
+// rather than its Deployments only:
+//
+//	Deployment, StatefulSet  requests times spec.replicas; a StatefulSet's
+//	                         volume claim templates add storage per replica
+//	DaemonSet                requests times the nodes it is scheduled on
+//	Job                      requests times its active pods, while it runs
+//	CronJob                  requests times parallelism, for the share of
+//	                         the month its runs take
+//	Pod                      requests of a pod no controller owns
+//
+// A pod's requests are those of all its containers, or of its largest init
+// container when that is more. Pod metrics are attributed to workloads by
+// the pods' controller references, not by their names.
+//
+// Manifest turns a workload into the manifest a ConfigHub unit holds, and
+// Patch changes the requests and replicas of such a manifest. A workload an
+// HPA scales has its replicas decided by the HPA: Rescale changes the HPA's
+// bounds instead.
+package workloads
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/monadic/devops-examples/pkg/costmodel"
+)
+
+// Kinds of workload
+const (
+	Deployment  = "Deployment"
+	StatefulSet = "StatefulSet"
+	DaemonSet   = "DaemonSet"
+	Job         = "Job"
+	CronJob     = "CronJob"
+	Pod         = "Pod"
+)
+
+// DefaultRunDuration is how long a CronJob run is assumed to take when none
+// of its Jobs has completed and it sets no deadline
+const DefaultRunDuration = 5 * time.Minute
+
+// month is the month the cost model prices
+const month = time.Duration(costmodel.HoursPerMonth * float64(time.Hour))
+
+// Workload is a controller, or a standalone pod, and what its pods request
+type Workload struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Replicas  int32 // pods running at once
+
+	// Requests of one pod
+	CPUMillicores int64
+	MemoryBytes   int64
+	StorageBytes  int64 // of its persistent volume claims
+
+	// Share of the month the pods run: 1 but for CronJobs
+	DutyCycle float64
+}
+
+// Cluster is what the workloads are read from
+type Cluster struct {
+	Deployments  []appsv1.Deployment
+	StatefulSets []appsv1.StatefulSet
+	DaemonSets   []appsv1.DaemonSet
+	Jobs         []batchv1.Job
+	CronJobs     []batchv1.CronJob
+	Pods         []corev1.Pod
+	ReplicaSets  []appsv1.ReplicaSet // to tell the Deployment of a pod
+	Nodes        int                 // for DaemonSets not yet scheduled
+
+	// HPAs, which scale workloads rather than run pods
+	Autoscalers []autoscalingv2.HorizontalPodAutoscaler
+}
+
+// Workloads lists the cluster's workloads, in the order of the kinds above.
+// Suspended CronJobs, finished Jobs, pods that are done and pods owned by a
+// controller are left out.
+func (c Cluster) Workloads() []Workload {
+	var list []Workload
+	for _, d := range c.Deployments {
+		list = append(list, fromPodSpec(Deployment, d.ObjectMeta, replicas(d.Spec.Replicas), d.Spec.Template.Spec))
+	}
+	for _, s := range c.StatefulSets {
+		w := fromPodSpec(StatefulSet, s.ObjectMeta, replicas(s.Spec.Replicas), s.Spec.Template.Spec)
+		for _, claim := range s.Spec.VolumeClaimTemplates {
+			if storage, ok := claim.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+				w.StorageBytes += storage.Value()
+			}
+		}
+		list = append(list, w)
+	}
+	for _, d := range c.DaemonSets {
+		n := d.Status.DesiredNumberScheduled
+		if d.Status.ObservedGeneration == 0 {
+			n = int32(c.Nodes) // not reconciled yet: assume every node
+		}
+		list = append(list, fromPodSpec(DaemonSet, d.ObjectMeta, n, d.Spec.Template.Spec))
+	}
+	for _, j := range c.Jobs {
+		if j.Status.Active == 0 || controller(j.ObjectMeta, "CronJob") != "" {
+			continue
+		}
+		list = append(list, fromPodSpec(Job, j.ObjectMeta, j.Status.Active, j.Spec.Template.Spec))
+	}
+	for _, cj := range c.CronJobs {
+		if cj.Spec.Suspend != nil && *cj.Spec.Suspend {
+			continue
+		}
+		spec := cj.Spec.JobTemplate.Spec
+		w := fromPodSpec(CronJob, cj.ObjectMeta, replicas(spec.Parallelism), spec.Template.Spec)
+		w.DutyCycle = DutyCycle(cj, c.Jobs)
+		list = append(list, w)
+	}
+	for _, p := range c.Pods {
+		if metav1.GetControllerOf(&p) != nil || p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		list = append(list, fromPodSpec(Pod, p.ObjectMeta, 1, p.Spec))
+	}
+	return list
+}
+
+// Resources returns what w requests over the month, for the cost model
+func (w Workload) Resources() costmodel.Resources {
+	const gb = 1024 * 1024 * 1024
+	return costmodel.Resources{
+		CPUCores:  float64(w.CPUMillicores) / 1000 * w.DutyCycle,
+		MemoryGB:  float64(w.MemoryBytes) / gb * w.DutyCycle,
+		StorageGB: float64(w.StorageBytes) / gb,
+		Replicas:  int(w.Replicas),
+	}
+}
+
+// Owns tells whether the pod named name is one of w's
+func (w Workload) Owns(name string) bool {
+	if w.Kind == Pod {
+		return name == w.Name
+	}
+	return strings.HasPrefix(name, w.Name+"-")
+}
+
+// DutyCycle is the share of the month cronJob's runs take: how often its
+// schedule fires times how long its completed Jobs took on average. It is
+// capped at 1 unless the CronJob allows its runs to overlap.
+func DutyCycle(cronJob batchv1.CronJob, jobs []batchv1.Job) float64 {
+	every, ok := Interval(cronJob.Spec.Schedule)
+	if !ok || every <= 0 {
+		return 1 // unknown schedule: price it as always running
+	}
+	duty := float64(runDuration(cronJob, jobs)) / float64(every)
+	if policy := cronJob.Spec.ConcurrencyPolicy; policy == batchv1.ForbidConcurrent || policy == batchv1.ReplaceConcurrent {
+		duty = math.Min(duty, 1)
+	}
+	return duty
+}
+
+// runDuration averages the runs of cronJob's completed Jobs
+func runDuration(cronJob batchv1.CronJob, jobs []batchv1.Job) time.Duration {
+	var total time.Duration
+	var n int
+	for _, j := range jobs {
+		if j.Namespace != cronJob.Namespace || controller(j.ObjectMeta, "CronJob") != cronJob.Name ||
+			j.Status.StartTime == nil || j.Status.CompletionTime == nil {
+			continue
+		}
+		total += j.Status.CompletionTime.Sub(j.Status.StartTime.Time)
+		n++
+	}
+	if n > 0 {
+		return total / time.Duration(n)
+	}
+	if deadline := cronJob.Spec.JobTemplate.Spec.ActiveDeadlineSeconds; deadline != nil {
+		return time.Duration(*deadline) * time.Second
+	}
+	return DefaultRunDuration
+}
+
+// fromPodSpec is a workload of n pods of spec
+func fromPodSpec(kind string, meta metav1.ObjectMeta, n int32, spec corev1.PodSpec) Workload {
+	w := Workload{Kind: kind, Name: meta.Name, Namespace: meta.Namespace, Replicas: n, DutyCycle: 1}
+	for _, c := range spec.Containers {
+		w.CPUMillicores += c.Resources.Requests.Cpu().MilliValue()
+		w.MemoryBytes += c.Resources.Requests.Memory().Value()
+	}
+	for _, c := range spec.InitContainers {
+		w.CPUMillicores = max64(w.CPUMillicores, c.Resources.Requests.Cpu().MilliValue())
+		w.MemoryBytes = max64(w.MemoryBytes, c.Resources.Requests.Memory().Value())
+	}
+	return w
+}
+
+// controller is the name of meta's controller when it is of the kind
+func controller(meta metav1.ObjectMeta, kind string) string {
+	if ref := metav1.GetControllerOfNoCopy(&meta); ref != nil && ref.Kind == kind {
+		return ref.Name
+	}
+	return ""
+}
+
+// replicas is a spec's replica count, 1 when unset
+func replicas(n *int32) int32 {
+	if n == nil {
+		return 1
+	}
+	return *n
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
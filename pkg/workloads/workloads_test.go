@@ -0,0 +1,182 @@
+package workloads
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func podSpec(cpu, memory string) corev1.PodSpec {
+	return corev1.PodSpec{Containers: []corev1.Container{{
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}},
+	}}}
+}
+
+func meta(name string, owner ...metav1.OwnerReference) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: "shop", OwnerReferences: owner}
+}
+
+func ownedBy(kind, name string) metav1.OwnerReference {
+	controller := true
+	return metav1.OwnerReference{Kind: kind, Name: name, Controller: &controller}
+}
+
+func int32p(n int32) *int32 { return &n }
+
+func TestWorkloads(t *testing.T) {
+	sidecar := podSpec("250m", "128Mi")
+	sidecar.Containers = append(sidecar.Containers, podSpec("250m", "128Mi").Containers...)
+	sidecar.InitContainers = podSpec("100m", "1Gi").Containers
+
+	db := appsv1.StatefulSet{ObjectMeta: meta("db"), Spec: appsv1.StatefulSetSpec{
+		Replicas: int32p(3),
+		Template: corev1.PodTemplateSpec{Spec: podSpec("1", "2Gi")},
+		VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}},
+		}}},
+	}}
+	start := metav1.NewTime(time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(6 * time.Minute))
+	cluster := Cluster{
+		Deployments: []appsv1.Deployment{{ObjectMeta: meta("web"), Spec: appsv1.DeploymentSpec{
+			Replicas: int32p(2), Template: corev1.PodTemplateSpec{Spec: sidecar},
+		}}},
+		StatefulSets: []appsv1.StatefulSet{db},
+		DaemonSets: []appsv1.DaemonSet{
+			{ObjectMeta: meta("logs"), Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: podSpec("100m", "64Mi")}},
+				Status: appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 4}},
+			{ObjectMeta: meta("new"), Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: podSpec("100m", "64Mi")}}},
+		},
+		Jobs: []batchv1.Job{
+			{ObjectMeta: meta("migrate"), Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: podSpec("500m", "256Mi")}},
+				Status: batchv1.JobStatus{Active: 2}},
+			{ObjectMeta: meta("done"), Status: batchv1.JobStatus{Succeeded: 1}},
+			{ObjectMeta: meta("report-1", ownedBy("CronJob", "report")),
+				Status: batchv1.JobStatus{StartTime: &start, CompletionTime: &end}},
+		},
+		CronJobs: []batchv1.CronJob{
+			{ObjectMeta: meta("report"), Spec: batchv1.CronJobSpec{Schedule: "0 * * * *", JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: podSpec("2", "4Gi")}},
+			}}},
+			{ObjectMeta: meta("paused"), Spec: batchv1.CronJobSpec{Schedule: "@daily", Suspend: func() *bool { b := true; return &b }()}},
+		},
+		Pods: []corev1.Pod{
+			{ObjectMeta: meta("debug"), Spec: podSpec("200m", "100Mi"), Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			{ObjectMeta: meta("web-abc-123", ownedBy("ReplicaSet", "web-abc")), Spec: podSpec("1", "1Gi")},
+			{ObjectMeta: meta("once"), Status: corev1.PodStatus{Phase: corev1.PodSucceeded}},
+		},
+		Nodes: 3,
+	}
+
+	const mi = 1024 * 1024
+	want := []Workload{
+		{Kind: Deployment, Name: "web", Replicas: 2, CPUMillicores: 500, MemoryBytes: 1024 * mi, DutyCycle: 1},
+		{Kind: StatefulSet, Name: "db", Replicas: 3, CPUMillicores: 1000, MemoryBytes: 2048 * mi, StorageBytes: 10240 * mi, DutyCycle: 1},
+		{Kind: DaemonSet, Name: "logs", Replicas: 4, CPUMillicores: 100, MemoryBytes: 64 * mi, DutyCycle: 1},
+		{Kind: DaemonSet, Name: "new", Replicas: 3, CPUMillicores: 100, MemoryBytes: 64 * mi, DutyCycle: 1},
+		{Kind: Job, Name: "migrate", Replicas: 2, CPUMillicores: 500, MemoryBytes: 256 * mi, DutyCycle: 1},
+		{Kind: CronJob, Name: "report", Replicas: 1, CPUMillicores: 2000, MemoryBytes: 4096 * mi, DutyCycle: 0.1},
+		{Kind: Pod, Name: "debug", Replicas: 1, CPUMillicores: 200, MemoryBytes: 100 * mi, DutyCycle: 1},
+	}
+	got := cluster.Workloads()
+	if len(got) != len(want) {
+		t.Fatalf("got %d workloads, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		w.Namespace = "shop"
+		g := got[i]
+		if math.Abs(g.DutyCycle-w.DutyCycle) > 1e-9 {
+			t.Errorf("%s/%s: duty cycle %v, want %v", w.Kind, w.Name, g.DutyCycle, w.DutyCycle)
+		}
+		g.DutyCycle = w.DutyCycle
+		if g != w {
+			t.Errorf("got %+v, want %+v", g, w)
+		}
+	}
+}
+
+func TestResources(t *testing.T) {
+	w := Workload{Replicas: 2, CPUMillicores: 1500, MemoryBytes: 2 << 30, StorageBytes: 10 << 30, DutyCycle: 0.5}
+	r := w.Resources()
+	if r.CPUCores != 0.75 || r.MemoryGB != 1 || r.StorageGB != 10 || r.Replicas != 2 {
+		t.Errorf("got %+v", r)
+	}
+}
+
+func TestOwns(t *testing.T) {
+	tests := []struct {
+		kind, name, pod string
+		want            bool
+	}{
+		{Deployment, "web", "web-7d9f8-x2k4p", true},
+		{StatefulSet, "db", "db-0", true},
+		{DaemonSet, "web", "webhook-abcde", false},
+		{Pod, "debug", "debug", true},
+		{Pod, "debug", "debug-1", false},
+	}
+	for _, tt := range tests {
+		if got := (Workload{Kind: tt.kind, Name: tt.name}).Owns(tt.pod); got != tt.want {
+			t.Errorf("%s %s owns %s: got %v", tt.kind, tt.name, tt.pod, got)
+		}
+	}
+}
+
+func TestDutyCycle(t *testing.T) {
+	deadline := int64(1800)
+	tests := []struct {
+		name     string
+		schedule string
+		policy   batchv1.ConcurrencyPolicy
+		deadline *int64
+		want     float64
+	}{
+		{"default run", "@hourly", "", nil, 5.0 / 60},
+		{"deadline", "@daily", "", &deadline, 0.5 / 24},
+		{"overlapping", "* * * * *", batchv1.AllowConcurrent, nil, 5},
+		{"forbidden overlap", "* * * * *", batchv1.ForbidConcurrent, nil, 1},
+		{"unknown schedule", "whenever", "", nil, 1},
+	}
+	for _, tt := range tests {
+		cj := batchv1.CronJob{Spec: batchv1.CronJobSpec{Schedule: tt.schedule, ConcurrencyPolicy: tt.policy}}
+		cj.Spec.JobTemplate.Spec.ActiveDeadlineSeconds = tt.deadline
+		if got := DutyCycle(cj, nil); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInterval(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     time.Duration
+	}{
+		{"@hourly", time.Hour},
+		{"@every 90m", 90 * time.Minute},
+		{"*/15 * * * *", 15 * time.Minute},
+		{"0 9-17 * * *", 24 * time.Hour / 9},
+		{"30 2 * * MON-FRI", 7 * 24 * time.Hour / 5},
+		{"0 0 * * 0,7", 7 * 24 * time.Hour},
+		{"CRON_TZ=Europe/Paris 0 3 * * *", 24 * time.Hour},
+		{"0 0 1 */3 *", time.Duration(float64(12*month) / 4)},
+	}
+	for _, tt := range tests {
+		got, ok := Interval(tt.schedule)
+		if !ok || math.Abs(float64(got-tt.want)) > float64(time.Second) {
+			t.Errorf("%s: got %v, %v, want %v", tt.schedule, got, ok, tt.want)
+		}
+	}
+	for _, schedule := range []string{"", "@often", "61 * * * *", "* * * *", "*/0 * * * *"} {
+		if _, ok := Interval(schedule); ok {
+			t.Errorf("%q: want no interval", schedule)
+		}
+	}
+}
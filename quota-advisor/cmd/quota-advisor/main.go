@@ -0,0 +1,10 @@
+// Command quota-advisor predicts when the cluster's ResourceQuotas run out
+// and recommends new limits as ConfigHub unit patches. The same app runs as
+// "devops-apps quotas".
+package main
+
+import quotaadvisor "github.com/monadic/devops-examples/quota-advisor"
+
+func main() {
+	quotaadvisor.Main()
+}
@@ -0,0 +1,126 @@
+package quotaadvisor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Config holds the quota advisor's settings. They are read from CONFIG_FILE
+// (default /etc/quota-advisor/config.yaml); each can be overridden by the
+// environment variable in its env tag.
+type Config struct {
+	CubAPIURL   string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken    string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir  string `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Port        int    `yaml:"quota_port" env:"QUOTA_PORT"`
+	AuthConfig  string `yaml:"auth_config" env:"AUTH_CONFIG"`   // OIDC login for the dashboard; a missing file leaves it open
+	ClusterName string `yaml:"cluster_name" env:"CLUSTER_NAME"` // cluster label of the /metrics samples
+
+	// Spaces whose ResourceQuota units get the proposed patches, and the
+	// namespace of units that don't name one
+	Spaces    []string `yaml:"cub_spaces" env:"CUB_SPACES"`
+	Namespace string   `yaml:"namespace" env:"NAMESPACE"`
+	// Quotas sampled: in watch_namespace (empty for all) but not in
+	// exclude_namespaces
+	WatchNamespace    string   `yaml:"watch_namespace" env:"WATCH_NAMESPACE"`
+	ExcludeNamespaces []string `yaml:"exclude_namespaces" env:"EXCLUDE_NAMESPACES"`
+
+	// Usage is sampled every run_interval and the trend fitted over the
+	// last window, once the samples span min_history
+	RunInterval time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+	Window      time.Duration `yaml:"window" env:"WINDOW"`
+	MinHistory  time.Duration `yaml:"min_history" env:"MIN_HISTORY"`
+	// A quota predicted to run out within horizon is raised to cover the
+	// usage predicted at the horizon plus headroom; one whose peak stayed
+	// below shrink_below of it over a whole window is lowered to the peak
+	// plus headroom
+	Horizon     time.Duration `yaml:"horizon" env:"HORIZON"`
+	Headroom    float64       `yaml:"headroom" env:"HEADROOM"`
+	ShrinkBelow float64       `yaml:"shrink_below" env:"SHRINK_BELOW"`
+
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:  "https://hub.confighub.com/api",
+		Port:       8090,
+		AuthConfig: "/etc/quota-advisor/auth.yaml",
+
+		Namespace:         "default",
+		ExcludeNamespaces: []string{"kube-system", "kube-public", "kube-node-lease"},
+
+		RunInterval: 5 * time.Minute,
+		Window:      24 * time.Hour,
+		MinHistory:  time.Hour,
+		Horizon:     7 * 24 * time.Hour,
+		Headroom:    0.2,
+		ShrinkBelow: 0.3,
+
+		CubRateLimit:     5,
+		CubRateBurst:     10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if len(c.Spaces) == 0 {
+		return fmt.Errorf("cub_spaces is required")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("quota_port %d is not a valid port", c.Port)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.MinHistory < c.RunInterval || c.Window < c.MinHistory {
+		return fmt.Errorf("need run_interval <= min_history <= window, got %s, %s and %s", c.RunInterval, c.MinHistory, c.Window)
+	}
+	if c.Horizon <= 0 {
+		return fmt.Errorf("horizon must be positive, got %s", c.Horizon)
+	}
+	if c.Headroom < 0 {
+		return fmt.Errorf("headroom must not be negative, got %g", c.Headroom)
+	}
+	if c.ShrinkBelow < 0 || c.ShrinkBelow >= 1 {
+		return fmt.Errorf("shrink_below must be in [0, 1), got %g", c.ShrinkBelow)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/quota-advisor/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
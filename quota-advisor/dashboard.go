@@ -0,0 +1,109 @@
+package quotaadvisor
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+)
+
+// webFiles holds the dashboard page template
+//
+//go:embed web/index.html.tmpl
+var webFiles embed.FS
+
+var dashboardTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+	"dollars": func(f float64) string { return fmt.Sprintf("%+.2f", f) },
+	"date": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04")
+	},
+	"ago": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).ParseFS(webFiles, "web/index.html.tmpl"))
+
+// handler serves the dashboard, its API under /api/v1 and /metrics
+func (a *Advisor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		report := a.Report()
+		if report == nil {
+			http.Error(w, "quotas have not been checked yet", http.StatusServiceUnavailable)
+			return
+		}
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	})
+	v1 := api.New(mux, "quota-advisor")
+	v1.HandleFunc("/quotas", a.handleQuotas, api.Operation{
+		Summary: "Latest quota report",
+		Query: []api.Param{
+			{Name: "status", Description: "exhausted, at-risk, oversized, learning or ok"},
+			{Name: "namespace", Description: "one namespace's"},
+		},
+		Response: Report{},
+	})
+	v1.Handle("/breakers", breaker.Handler(a.cubBreaker), breaker.Operations...)
+	mux.HandleFunc("/health", health.Live)
+	a.health.Mount(mux)
+	mux.Handle("/metrics", a.metrics)
+	return mux
+}
+
+// handleQuotas serves the latest report as JSON, filtered by ?status= and
+// ?namespace=
+func (a *Advisor) handleQuotas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := a.Report()
+	if report == nil {
+		http.Error(w, "quotas have not been checked yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	status, namespace := r.URL.Query().Get("status"), r.URL.Query().Get("namespace")
+	if _, ok := statusRank[status]; status != "" && !ok {
+		http.Error(w, "status must be exhausted, at-risk, oversized, learning or ok", http.StatusBadRequest)
+		return
+	}
+	if status != "" || namespace != "" {
+		filtered := *report
+		filtered.Usages, filtered.MonthlyCost = []Usage{}, 0
+		for _, u := range report.Usages {
+			if (status == "" || u.Status == status) && (namespace == "" || u.Namespace == namespace) {
+				filtered.Usages = append(filtered.Usages, u)
+				if u.Recommendation != nil {
+					filtered.MonthlyCost += u.Recommendation.MonthlyCost
+				}
+			}
+		}
+		report = &filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,246 @@
+// Package quotaadvisor samples the consumption of the cluster's
+// ResourceQuotas, predicts from the trend when each will run out, and
+// recommends new limits as ConfigHub unit patches priced at what they let
+// the namespace spend.
+package quotaadvisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/client-go/kubernetes"
+)
+
+const version = "1.0.0"
+
+// Report is the result of the latest check, served at GET /api/v1/quotas
+type Report struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	Usages    []Usage        `json:"usages"`
+	ByStatus  map[string]int `json:"by_status"`
+	// Sum of the recommendations' monthly cost
+	MonthlyCost float64 `json:"monthly_cost"`
+	Degraded    string  `json:"degraded,omitempty"` // why the units could not be read
+}
+
+type Advisor struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	clientset  kubernetes.Interface
+	history    *history // only touched by check
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu     sync.RWMutex
+	report *Report
+}
+
+// Main samples the quotas every run_interval until interrupted, or once with
+// --mode=job. It is the entry point of cmd/quota-advisor and of "devops-apps
+// quotas".
+func Main() {
+	logger := logging.Setup("quota-advisor")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "quota-advisor", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "quota-advisor",
+		Version:     version,
+		Description: "Predicts ResourceQuota exhaustion and recommends new limits",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	advisor := &Advisor{
+		app:        app,
+		config:     cfg,
+		clientset:  app.K8s.Clientset,
+		history:    newHistory(cfg.Window),
+		metrics:    metrics.New("quota-advisor", version, cfg.ClusterName),
+		cubLimit:   ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker),
+		cubBreaker: cubBreaker,
+	}
+	advisor.metrics.Collect(metrics.Limiter(advisor.cubLimit))
+	advisor.metrics.Collect(metrics.ListCache(listcache.Install()))
+	advisor.metrics.Collect(metrics.Breakers(cubBreaker))
+	advisor.health = health.New()
+	advisor.health.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	advisor.health.Register("confighub", health.Optional, health.Breaker(cubBreaker))
+	advisor.metrics.Collect(advisor.collect)
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, advisor.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "quota-advisor", advisor.check, advisor.Report, os.Stdout, store); err != nil {
+			logging.Fatal("Quota check failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Quota dashboard listening", "addr", addr)
+	group.Serve(addr, guard.Protect(advisor.handler(), "/metrics", "/health"))
+	group.Go(advisor.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Quota dashboard stopped", logging.Err(err))
+	}
+}
+
+// run checks now and every run_interval until interrupted
+func (a *Advisor) run(ctx context.Context) error {
+	ticker := time.NewTicker(a.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.check(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Quota check failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check samples the quotas and recommends new limits. Without the units
+// the recommendations come with kubectl commands only.
+func (a *Advisor) check(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "quotas.check")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := a.metrics.Cycle("check", "")
+	defer func() { cycleDone(err) }()
+
+	quotas, err := readQuotas(ctx, a.clientset, a.config)
+	if err != nil {
+		return err
+	}
+	report := &Report{CheckedAt: time.Now(), ByStatus: map[string]int{}}
+	for status := range statusRank {
+		report.ByStatus[status] = 0
+	}
+	report.Usages = analyze(quotas, a.history, a.config, report.CheckedAt)
+	units, err := a.quotaUnits(ctx)
+	if err != nil {
+		report.Degraded = fmt.Sprintf("units not read: %v", err)
+		slog.Warn("Recommending without the quota units", logging.Err(err))
+	}
+	proposePatches(report.Usages, units)
+
+	if report.Usages == nil {
+		report.Usages = []Usage{}
+	}
+	for _, u := range report.Usages {
+		report.ByStatus[u.Status]++
+		if u.Recommendation != nil {
+			report.MonthlyCost += u.Recommendation.MonthlyCost
+		}
+	}
+	a.mu.Lock()
+	a.report = report
+	a.mu.Unlock()
+	slog.Info("Quotas checked", "resources", len(report.Usages),
+		"exhausted", report.ByStatus[StatusExhausted], "at_risk", report.ByStatus[StatusAtRisk], "oversized", report.ByStatus[StatusOversized])
+	return nil
+}
+
+// quotaUnits reads the ResourceQuota units of the configured spaces
+func (a *Advisor) quotaUnits(ctx context.Context) (map[string][]quotaUnit, error) {
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, a.cubLimit, "ListSpaces", "all", a.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list spaces: %w", err)
+	}
+	ids := map[string]uuid.UUID{}
+	for _, s := range spaces {
+		ids[s.Slug] = s.SpaceID
+	}
+
+	units := map[string][]quotaUnit{}
+	for _, slug := range a.config.Spaces {
+		id, ok := ids[slug]
+		if !ok {
+			return nil, fmt.Errorf("space %s not found", slug)
+		}
+		list, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, a.cubLimit, "ListUnits", id.String(), func() ([]*sdk.Unit, error) {
+				return a.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: id})
+			})
+		}, tracing.SpaceKey.String(slug))
+		if err != nil {
+			return nil, fmt.Errorf("list units of %s: %w", slug, err)
+		}
+		quotaUnits(slug, list, a.config.Namespace, units)
+	}
+	return units, nil
+}
+
+// Report returns the latest report, nil before the first check
+func (a *Advisor) Report() *Report {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.report
+}
+
+// collect exports each quota resource's consumption and predicted exhaustion
+func (a *Advisor) collect(emit metrics.Emit) {
+	report := a.Report()
+	if report == nil {
+		return
+	}
+	for _, u := range report.Usages {
+		labels := metrics.Labels{"namespace": u.Namespace, "quota": u.Quota, "resource": u.Resource}
+		emit("quota_used_ratio", "Share of the quota's hard limit in use.", "gauge", labels, u.Ratio)
+		if u.ExhaustedAt != nil {
+			emit("quota_exhaustion_seconds", "Predicted time until the quota runs out at its current trend.", "gauge", labels, time.Until(*u.ExhaustedAt).Seconds())
+		}
+	}
+	for status, n := range report.ByStatus {
+		emit("quota_resources", "Quota resources by status at the latest check.", "gauge", metrics.Labels{"status": status}, float64(n))
+	}
+	emit("quota_recommendations_monthly_cost_dollars", "Monthly cost of applying every recommended quota change.", "gauge", nil, report.MonthlyCost)
+}
@@ -0,0 +1,184 @@
+package quotaadvisor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Statuses of a quota resource, most urgent first
+const (
+	StatusExhausted = "exhausted" // used has reached hard
+	StatusAtRisk    = "at-risk"   // predicted to run out within horizon
+	StatusOversized = "oversized" // peak stayed below shrink_below of hard
+	StatusLearning  = "learning"  // samples don't span min_history yet
+	StatusOK        = "ok"
+)
+
+var statusRank = map[string]int{StatusExhausted: 0, StatusAtRisk: 1, StatusOversized: 2, StatusLearning: 3, StatusOK: 4}
+
+// Usage is the consumption of one resource of a ResourceQuota. Amounts are
+// in cores for CPU, bytes for memory and storage, and counts otherwise.
+type Usage struct {
+	Namespace string  `json:"namespace"`
+	Quota     string  `json:"quota"`
+	Resource  string  `json:"resource"` // e.g. requests.cpu
+	Hard      string  `json:"hard"`     // as in the quota
+	Used      string  `json:"used"`
+	Ratio     float64 `json:"ratio"` // used / hard
+	// The least-squares trend of the samples, their peak and the time they span
+	TrendPerDay float64       `json:"trend_per_day"`
+	Peak        float64       `json:"peak"`
+	History     time.Duration `json:"history"`
+	ExhaustedAt *time.Time    `json:"exhausted_at,omitempty"` // predicted, when used is growing
+
+	Status         string          `json:"status"`
+	Recommendation *Recommendation `json:"recommendation,omitempty"`
+
+	hard, used float64
+}
+
+// Key names the quota resource as namespace/quota/resource
+func (u Usage) Key() string {
+	return u.Namespace + "/" + u.Quota + "/" + u.Resource
+}
+
+type sample struct {
+	at   time.Time
+	used float64
+}
+
+// history keeps the samples of each quota resource within the window. It
+// lives in memory, so a restart starts learning again.
+type history struct {
+	window  time.Duration
+	samples map[string][]sample // by Usage.Key
+}
+
+func newHistory(window time.Duration) *history {
+	return &history{window: window, samples: map[string][]sample{}}
+}
+
+// add records a sample and returns the samples of key within the window
+func (h *history) add(key string, at time.Time, used float64) []sample {
+	samples := append(h.samples[key], sample{at, used})
+	for len(samples) > 1 && at.Sub(samples[0].at) > h.window {
+		samples = samples[1:]
+	}
+	h.samples[key] = samples
+	return samples
+}
+
+// keep forgets the quota resources not in keys, deleted since
+func (h *history) keep(keys map[string]bool) {
+	for key := range h.samples {
+		if !keys[key] {
+			delete(h.samples, key)
+		}
+	}
+}
+
+// slope is the least-squares change of used per second
+func slope(samples []sample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	var sx, sy, sxx, sxy float64
+	n := float64(len(samples))
+	for _, s := range samples {
+		x := s.at.Sub(samples[0].at).Seconds()
+		sx, sy, sxx, sxy = sx+x, sy+s.used, sxx+x*x, sxy+x*s.used
+	}
+	if d := n*sxx - sx*sx; d != 0 {
+		return (n*sxy - sx*sy) / d
+	}
+	return 0
+}
+
+// readQuotas lists the ResourceQuotas of the watched namespaces
+func readQuotas(ctx context.Context, clientset kubernetes.Interface, cfg Config) ([]corev1.ResourceQuota, error) {
+	list, err := clientset.CoreV1().ResourceQuotas(cfg.WatchNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list resource quotas: %w", err)
+	}
+	excluded := map[string]bool{}
+	for _, ns := range cfg.ExcludeNamespaces {
+		excluded[ns] = true
+	}
+	var quotas []corev1.ResourceQuota
+	for _, q := range list.Items {
+		if !excluded[q.Namespace] {
+			quotas = append(quotas, q)
+		}
+	}
+	return quotas, nil
+}
+
+// analyze samples the usage of each quota resource at now and classifies
+// it by its trend, most urgent and fullest first. A hard limit of zero
+// forbids the resource and is skipped.
+func analyze(quotas []corev1.ResourceQuota, h *history, cfg Config, now time.Time) []Usage {
+	var usages []Usage
+	seen := map[string]bool{}
+	for _, q := range quotas {
+		for name, hardQ := range q.Status.Hard {
+			usedQ := q.Status.Used[name]
+			u := Usage{
+				Namespace: q.Namespace, Quota: q.Name, Resource: string(name),
+				Hard: hardQ.String(), Used: usedQ.String(),
+				hard: hardQ.AsApproximateFloat64(), used: usedQ.AsApproximateFloat64(),
+			}
+			if u.hard <= 0 {
+				continue
+			}
+			seen[u.Key()] = true
+			samples := h.add(u.Key(), now, u.used)
+			perSecond := slope(samples)
+			for _, s := range samples {
+				if s.used > u.Peak {
+					u.Peak = s.used
+				}
+			}
+			u.Ratio, u.TrendPerDay, u.History = u.used/u.hard, perSecond*86400, now.Sub(samples[0].at)
+
+			var left time.Duration
+			if perSecond > 0 && u.used < u.hard {
+				left = time.Duration((u.hard - u.used) / perSecond * float64(time.Second))
+				at := now.Add(left)
+				u.ExhaustedAt = &at
+			}
+			switch {
+			case u.used >= u.hard:
+				u.Status = StatusExhausted
+			case u.History < cfg.MinHistory:
+				u.Status = StatusLearning
+			case u.ExhaustedAt != nil && left <= cfg.Horizon:
+				u.Status = StatusAtRisk
+			case u.History >= cfg.Window-cfg.RunInterval && u.Peak < cfg.ShrinkBelow*u.hard:
+				u.Status = StatusOversized
+			default:
+				u.Status = StatusOK
+			}
+			u.Recommendation = recommend(u, perSecond, cfg)
+			usages = append(usages, u)
+		}
+	}
+	h.keep(seen)
+
+	sort.Slice(usages, func(i, j int) bool {
+		a, b := usages[i], usages[j]
+		if statusRank[a.Status] != statusRank[b.Status] {
+			return statusRank[a.Status] < statusRank[b.Status]
+		}
+		if a.Ratio != b.Ratio {
+			return a.Ratio > b.Ratio
+		}
+		return a.Key() < b.Key()
+	})
+	return usages
+}
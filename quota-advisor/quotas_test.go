@@ -0,0 +1,120 @@
+package quotaadvisor
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// quota is a ResourceQuota with status hard and used of "resource=quantity"
+func quota(namespace, name string, hard, used map[string]string) corev1.ResourceQuota {
+	q := corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Status:     corev1.ResourceQuotaStatus{Hard: corev1.ResourceList{}, Used: corev1.ResourceList{}},
+	}
+	for r, v := range hard {
+		q.Status.Hard[corev1.ResourceName(r)] = resource.MustParse(v)
+	}
+	for r, v := range used {
+		q.Status.Used[corev1.ResourceName(r)] = resource.MustParse(v)
+	}
+	return q
+}
+
+func TestSlope(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples []sample
+	for i, used := range []float64{1, 3, 5, 7} {
+		samples = append(samples, sample{start.Add(time.Duration(i) * time.Hour), used})
+	}
+	if got := slope(samples) * 3600; math.Abs(got-2) > 1e-9 {
+		t.Errorf("slope %g per hour, want 2", got)
+	}
+	if got := slope(samples[:1]); got != 0 {
+		t.Errorf("one sample: slope %g, want 0", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := newHistory(2 * time.Hour)
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= 4; i++ {
+		h.add("a", start.Add(time.Duration(i)*time.Hour), float64(i))
+	}
+	h.add("b", start, 1)
+	if got := h.samples["a"]; len(got) != 3 || got[0].used != 2 {
+		t.Errorf("samples beyond the window kept: %+v", got)
+	}
+	h.keep(map[string]bool{"a": true})
+	if _, ok := h.samples["b"]; ok {
+		t.Error("deleted quota resource kept")
+	}
+}
+
+func TestReadQuotas(t *testing.T) {
+	q1, q2 := quota("shop", "compute", nil, nil), quota("kube-system", "compute", nil, nil)
+	clientset := fake.NewSimpleClientset(&q1, &q2)
+	quotas, err := readQuotas(context.Background(), clientset, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotas) != 1 || quotas[0].Namespace != "shop" {
+		t.Errorf("got %+v, want only shop's quota", quotas)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	cfg := DefaultConfig() // window 24h, min history 1h, horizon 7 days
+	h := newHistory(cfg.Window)
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var usages []Usage
+	for i := 0; i <= 24; i++ {
+		now := start.Add(time.Duration(i) * time.Hour)
+		usages = analyze([]corev1.ResourceQuota{
+			// +1 core a day towards 20: runs out in 6 days
+			quota("shop", "compute", map[string]string{"requests.cpu": "20", "pods": "50"},
+				map[string]string{"requests.cpu": resource.NewMilliQuantity(int64(13000+i*1000/24), resource.DecimalSI).String(), "pods": "10"}),
+			quota("batch", "compute", map[string]string{"requests.memory": "64Gi"}, map[string]string{"requests.memory": "64Gi"}),
+			quota("empty", "frozen", map[string]string{"requests.cpu": "0"}, nil),
+		}, h, cfg, now)
+		if i == 0 {
+			for _, u := range usages {
+				if u.Status != StatusLearning && u.Status != StatusExhausted {
+					t.Errorf("first sample: %s is %s", u.Key(), u.Status)
+				}
+			}
+		}
+	}
+
+	got := map[string]string{}
+	for _, u := range usages {
+		got[u.Key()] = u.Status
+	}
+	want := map[string]string{
+		"batch/compute/requests.memory": StatusExhausted,
+		"shop/compute/requests.cpu":     StatusAtRisk,
+		"shop/compute/pods":             StatusOversized, // 10 of 50 for a whole window
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if usages[0].Key() != "batch/compute/requests.memory" {
+		t.Errorf("not most urgent first: %s", usages[0].Key())
+	}
+
+	cpu := usages[1]
+	if math.Abs(cpu.TrendPerDay-1) > 0.01 {
+		t.Errorf("trend %g cores a day, want 1", cpu.TrendPerDay)
+	}
+	if left := cpu.ExhaustedAt.Sub(start.Add(24 * time.Hour)); left < 5*24*time.Hour || left > 7*24*time.Hour {
+		t.Errorf("runs out in %s, want about 6 days", left)
+	}
+}
@@ -0,0 +1,165 @@
+package quotaadvisor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/pricinghints"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// Recommendation is a new hard limit for a quota resource
+type Recommendation struct {
+	Hard   string `json:"hard"`
+	Reason string `json:"reason"`
+	// What the change lets the namespace request each month, priced at the
+	// pricinghints rates; negative when lowered, 0 for unpriced resources
+	MonthlyCost float64         `json:"monthly_cost"`
+	Patches     []ProposedPatch `json:"patches,omitempty"`
+	Command     string          `json:"command"` // kubectl patch for a quota no unit describes
+}
+
+// ProposedPatch is a change to a ResourceQuota unit, for an operator to
+// review and apply
+type ProposedPatch struct {
+	Space       string      `json:"space"`
+	UnitID      uuid.UUID   `json:"unit_id"`
+	UnitSlug    string      `json:"unit_slug"`
+	PatchPath   string      `json:"patch_path"`
+	PatchValue  interface{} `json:"patch_value"`
+	Explanation string      `json:"explanation"`
+}
+
+// recommend sizes the hard limit of a quota resource that is exhausted,
+// at risk or oversized: to the usage predicted at the horizon plus
+// headroom, or for an oversized one to its peak plus headroom. Nil leaves
+// the limit as it is.
+func recommend(u Usage, perSecond float64, cfg Config) *Recommendation {
+	var target float64
+	var reason string
+	switch u.Status {
+	case StatusExhausted, StatusAtRisk:
+		target = u.used
+		if perSecond > 0 && u.History >= cfg.MinHistory {
+			target += perSecond * cfg.Horizon.Seconds()
+		}
+		target *= 1 + cfg.Headroom
+		reason = fmt.Sprintf("covers the usage predicted in %s plus %.0f%% headroom", cfg.Horizon, cfg.Headroom*100)
+		if u.Status == StatusExhausted {
+			reason = "exhausted; new workloads are rejected. Raising it " + reason
+		}
+	case StatusOversized:
+		target = u.Peak * (1 + cfg.Headroom)
+		reason = fmt.Sprintf("peak usage over %s was %.0f%% of the limit; lowering it to the peak plus %.0f%% headroom frees the capacity for other namespaces", u.History.Round(time.Hour), u.Peak/u.hard*100, cfg.Headroom*100)
+	default:
+		return nil
+	}
+	hard, value := roundUp(u.Resource, target)
+	if hard == u.hard || (u.Status == StatusOversized && hard >= u.hard) {
+		return nil
+	}
+	return &Recommendation{
+		Hard:        value,
+		Reason:      reason,
+		MonthlyCost: monthlyCost(u.Resource, hard-u.hard),
+		Command:     fmt.Sprintf(`kubectl patch resourcequota %s -n %s --type merge -p '{"spec":{"hard":{"%s":"%s"}}}'`, u.Quota, u.Namespace, u.Resource, value),
+	}
+}
+
+// baseResource drops the requests. or limits. prefix of a quota resource
+func baseResource(name string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, "requests."), "limits.")
+}
+
+// roundUp rounds an amount of a quota resource up to a tidy limit: tenths
+// of a core, whole Gi, or a whole count
+func roundUp(name string, v float64) (float64, string) {
+	switch base := baseResource(name); {
+	case base == "cpu":
+		milli := int64(math.Ceil(v*10)) * 100
+		return float64(milli) / 1000, resource.NewMilliQuantity(milli, resource.DecimalSI).String()
+	case base == "memory", base == "storage", base == "ephemeral-storage", strings.HasSuffix(name, ".storageclass.storage.k8s.io/requests.storage"):
+		gi := int64(math.Ceil(v / (1 << 30)))
+		return float64(gi << 30), resource.NewQuantity(gi<<30, resource.BinarySI).String()
+	default:
+		n := int64(math.Ceil(v))
+		return float64(n), strconv.FormatInt(n, 10)
+	}
+}
+
+// monthlyCost prices a change in a quota resource. Only requests are
+// priced: limits reserve nothing, and counts cost nothing by themselves.
+func monthlyCost(name string, change float64) float64 {
+	h := pricinghints.Hints{Replicas: 1}
+	switch {
+	case name == "cpu", name == "requests.cpu":
+		h.CPUCores = change
+	case name == "memory", name == "requests.memory":
+		h.MemoryGB = change / (1 << 30)
+	case name == "requests.storage", strings.HasSuffix(name, ".storageclass.storage.k8s.io/requests.storage"):
+		h.StorageGB = change / (1 << 30)
+	case name == "requests.nvidia.com/gpu":
+		h.GPUs = int(change)
+	default:
+		return 0
+	}
+	return h.MonthlyCost(pricinghints.DefaultRates)
+}
+
+// quotaUnit is a ResourceQuota unit
+type quotaUnit struct {
+	Space string
+	ID    uuid.UUID
+	Slug  string
+}
+
+// quotaUnits adds the ResourceQuota units of a space to into, by
+// namespace/name
+func quotaUnits(space string, units []*sdk.Unit, defaultNamespace string, into map[string][]quotaUnit) {
+	for _, u := range units {
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(u.Data), &obj); err != nil || obj.Kind != "ResourceQuota" {
+			continue
+		}
+		ns := obj.Metadata.Namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		key := ns + "/" + obj.Metadata.Name
+		into[key] = append(into[key], quotaUnit{Space: space, ID: u.UnitID, Slug: u.Slug})
+	}
+}
+
+// pointerEscaper escapes a JSON pointer token, as resource names like
+// requests.nvidia.com/gpu contain a slash
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// proposePatches adds the patch of each recommendation to the units of its quota
+func proposePatches(usages []Usage, units map[string][]quotaUnit) {
+	for i := range usages {
+		u := &usages[i]
+		if u.Recommendation == nil {
+			continue
+		}
+		for _, unit := range units[u.Namespace+"/"+u.Quota] {
+			u.Recommendation.Patches = append(u.Recommendation.Patches, ProposedPatch{
+				Space: unit.Space, UnitID: unit.ID, UnitSlug: unit.Slug,
+				PatchPath:   "/spec/hard/" + pointerEscaper.Replace(u.Resource),
+				PatchValue:  u.Recommendation.Hard,
+				Explanation: fmt.Sprintf("%s from %s: %s", u.Resource, u.Hard, u.Recommendation.Reason),
+			})
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package quotaadvisor
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestRoundUp(t *testing.T) {
+	for _, tc := range []struct {
+		resource string
+		v        float64
+		want     string
+	}{
+		{"requests.cpu", 1.23, "1300m"},
+		{"limits.cpu", 4, "4"},
+		{"requests.memory", 5.5 * (1 << 30), "6Gi"},
+		{"gold.storageclass.storage.k8s.io/requests.storage", 100 << 30, "100Gi"},
+		{"pods", 12.1, "13"},
+	} {
+		if _, got := roundUp(tc.resource, tc.v); got != tc.want {
+			t.Errorf("%s %g: got %s, want %s", tc.resource, tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestMonthlyCost(t *testing.T) {
+	for _, tc := range []struct {
+		resource string
+		change   float64
+		want     float64
+	}{
+		{"requests.cpu", 2, 2 * 0.024 * 720},
+		{"memory", -4 << 30, -4 * 0.006 * 720},
+		{"requests.storage", 100 << 30, 10},
+		{"limits.cpu", 2, 0},
+		{"pods", 10, 0},
+	} {
+		if got := monthlyCost(tc.resource, tc.change); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s %g: got %g, want %g", tc.resource, tc.change, got, tc.want)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	cfg := DefaultConfig() // horizon 7 days, 20% headroom
+	perDay := 1.0 / 86400
+
+	r := recommend(Usage{Namespace: "shop", Quota: "compute", Resource: "requests.cpu", Status: StatusAtRisk, History: 24 * time.Hour, hard: 20, used: 14}, perDay, cfg)
+	if r == nil || r.Hard != "25200m" {
+		t.Fatalf("at risk: got %+v, want (14 + 7) * 1.2 = 25200m", r)
+	}
+	if want := 5.2 * 0.024 * 720; math.Abs(r.MonthlyCost-want) > 1e-9 {
+		t.Errorf("monthly cost %g, want %g", r.MonthlyCost, want)
+	}
+	if r.Command != `kubectl patch resourcequota compute -n shop --type merge -p '{"spec":{"hard":{"requests.cpu":"25200m"}}}'` {
+		t.Errorf("command %s", r.Command)
+	}
+
+	// exhausted while still learning: sized from the current usage
+	r = recommend(Usage{Resource: "pods", Status: StatusExhausted, hard: 10, used: 10}, 0, cfg)
+	if r == nil || r.Hard != "12" {
+		t.Errorf("exhausted: got %+v, want 12", r)
+	}
+
+	r = recommend(Usage{Resource: "requests.memory", Status: StatusOversized, History: 24 * time.Hour, Peak: 10 << 30, hard: 64 << 30}, 0, cfg)
+	if r == nil || r.Hard != "12Gi" || r.MonthlyCost >= 0 {
+		t.Errorf("oversized: got %+v, want 12Gi saving money", r)
+	}
+
+	if r := recommend(Usage{Resource: "pods", Status: StatusOK, hard: 10, used: 2}, 0, cfg); r != nil {
+		t.Errorf("ok: got %+v", r)
+	}
+}
+
+func TestProposePatches(t *testing.T) {
+	units := map[string][]quotaUnit{}
+	quotaUnits("prod", []*sdk.Unit{
+		{UnitID: uuid.New(), Slug: "shop-quota", Data: "apiVersion: v1\nkind: ResourceQuota\nmetadata:\n  name: compute\n  namespace: shop\nspec:\n  hard:\n    requests.cpu: \"20\"\n"},
+		{UnitID: uuid.New(), Slug: "gpu-quota", Data: "apiVersion: v1\nkind: ResourceQuota\nmetadata:\n  name: gpus\n"},
+		{UnitID: uuid.New(), Slug: "shop", Data: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: compute\n  namespace: shop\n"},
+	}, "ml", units)
+
+	usages := []Usage{
+		{Namespace: "shop", Quota: "compute", Resource: "requests.cpu", Hard: "20", Recommendation: &Recommendation{Hard: "25200m", Reason: "grows"}},
+		{Namespace: "ml", Quota: "gpus", Resource: "requests.nvidia.com/gpu", Hard: "4", Recommendation: &Recommendation{Hard: "6", Reason: "grows"}},
+		{Namespace: "other", Quota: "compute", Resource: "pods", Hard: "10", Recommendation: &Recommendation{Hard: "12"}},
+	}
+	proposePatches(usages, units)
+
+	var got []string
+	for _, u := range usages {
+		for _, p := range u.Recommendation.Patches {
+			got = append(got, p.Space+"/"+p.UnitSlug+" "+p.PatchPath+"="+p.PatchValue.(string))
+		}
+	}
+	want := []string{
+		"prod/shop-quota /spec/hard/requests.cpu=25200m",
+		"prod/gpu-quota /spec/hard/requests.nvidia.com~1gpu=6",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	a := &Advisor{config: DefaultConfig(), metrics: metrics.New("quota-advisor", version, "test")}
+	handler := a.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/quotas", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first check: status %d, want 503", rec.Code)
+	}
+
+	a.report = &Report{
+		CheckedAt: time.Now(),
+		Usages: []Usage{
+			{Namespace: "shop", Quota: "compute", Resource: "requests.cpu", Status: StatusAtRisk, Recommendation: &Recommendation{Hard: "25", MonthlyCost: 86.4}},
+			{Namespace: "batch", Quota: "compute", Resource: "requests.memory", Status: StatusOversized, Recommendation: &Recommendation{Hard: "12Gi", MonthlyCost: -224.64}},
+			{Namespace: "shop", Quota: "compute", Resource: "pods", Status: StatusOK},
+		},
+		ByStatus:    map[string]int{StatusAtRisk: 1, StatusOversized: 1, StatusOK: 1},
+		MonthlyCost: 86.4 - 224.64,
+	}
+
+	for _, tc := range []struct {
+		query string
+		code  int
+		want  []string
+		cost  float64
+	}{
+		{query: "", code: http.StatusOK, want: []string{"requests.cpu", "requests.memory", "pods"}, cost: 86.4 - 224.64},
+		{query: "?status=at-risk", code: http.StatusOK, want: []string{"requests.cpu"}, cost: 86.4},
+		{query: "?namespace=shop", code: http.StatusOK, want: []string{"requests.cpu", "pods"}, cost: 86.4},
+		{query: "?status=full", code: http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/quotas"+tc.query, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.query, rec.Code, tc.code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var report Report
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, u := range report.Usages {
+			got = append(got, u.Resource)
+		}
+		if !reflect.DeepEqual(got, tc.want) || math.Abs(report.MonthlyCost-tc.cost) > 1e-9 {
+			t.Errorf("%s: got %v costing %g, want %v costing %g", tc.query, got, report.MonthlyCost, tc.want, tc.cost)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("dashboard: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,181 @@
+package secretrotation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/pending"
+)
+
+// Report lists the Secrets of the latest scan, served at GET /api/v1/secrets
+type Report struct {
+	ScannedAt time.Time      `json:"scanned_at"`
+	Error     string         `json:"error,omitempty"` // of the latest scan
+	ByStatus  map[string]int `json:"by_status"`
+	Secrets   []Secret       `json:"secrets"` // most urgent first
+}
+
+// handler serves the API under /api/v1, /metrics and /health
+func (m *Monitor) handler() http.Handler {
+	mux := http.NewServeMux()
+	v1 := api.New(mux, "secret-rotation-monitor")
+	v1.HandleFunc("/secrets", m.handleSecrets, api.Operation{
+		Summary: "Secrets referenced by the workloads, most urgent first",
+		Query: []api.Param{
+			{Name: "status", Description: "missing, overdue, due or ok"},
+			{Name: "namespace", Description: "one namespace's"},
+		},
+		Response: Report{},
+	})
+	v1.HandleFunc("/rotations", m.handleRotations, api.Operation{
+		Summary:  "Rotation requests, oldest Secret first",
+		Query:    []api.Param{{Name: "status", Description: "pending (default), approved, rejected, rotated, resolved or all"}},
+		Response: []Request{},
+	})
+	v1.HandleFunc("/rotations/", m.handleDecision,
+		api.Operation{Method: http.MethodPost, Path: "/rotations/{slug}/approve", Summary: "Rotate a Secret", Request: pending.Approval{}, Response: Request{}},
+		api.Operation{Method: http.MethodPost, Path: "/rotations/{slug}/reject", Summary: "Decline a rotation", Request: pending.Approval{}, Response: Request{}},
+	)
+	v1.Handle("/audit", m.audit, audit.Operations...)
+	v1.Handle("/breakers", breaker.Handler(m.cubBreaker), breaker.Operations...)
+	mux.Handle("/metrics", m.metrics)
+	mux.HandleFunc("/health", health.Live)
+	m.health.Mount(mux)
+	return m.audit.Calls(mux)
+}
+
+// handleSecrets lists the referenced Secrets, filtered by ?status= and
+// ?namespace=
+func (m *Monitor) handleSecrets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status, namespace := r.URL.Query().Get("status"), r.URL.Query().Get("namespace")
+	if _, ok := statusRank[status]; status != "" && !ok {
+		http.Error(w, "status must be missing, overdue, due or ok", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, m.Report(status, namespace))
+}
+
+// Report returns the last scan with the Secrets of the given status and
+// namespace, every one when empty
+func (m *Monitor) Report(status, namespace string) Report {
+	m.mu.RLock()
+	report := Report{ScannedAt: m.scannedAt, Error: m.scanError, ByStatus: map[string]int{}, Secrets: []Secret{}}
+	for s := range statusRank {
+		report.ByStatus[s] = 0
+	}
+	for _, s := range m.secrets {
+		report.ByStatus[s.Status]++
+		if (status == "" || s.Status == status) && (namespace == "" || s.Namespace == namespace) {
+			report.Secrets = append(report.Secrets, s)
+		}
+	}
+	m.mu.RUnlock()
+	return report
+}
+
+// handleRotations lists the rotation requests, oldest Secret first:
+// ?status=pending (the default; "all" for every status)
+func (m *Monitor) handleRotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = RequestPending
+	}
+	switch status {
+	case "all", RequestPending, RequestApproved, RequestRejected, RequestRotated, RequestResolved:
+	default:
+		http.Error(w, "status must be pending, approved, rejected, rotated, resolved or all", http.StatusBadRequest)
+		return
+	}
+
+	requests := []Request{}
+	m.mu.RLock()
+	for _, req := range m.requests {
+		if status == "all" || req.Status == status {
+			requests = append(requests, *req)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.Secret.AgeDays != b.Secret.AgeDays {
+			return a.Secret.AgeDays > b.Secret.AgeDays
+		}
+		return a.Slug < b.Slug
+	})
+	writeJSON(w, requests)
+}
+
+// handleDecision serves POST /api/v1/rotations/{slug}/approve and
+// POST /api/v1/rotations/{slug}/reject. Signed-in users decide as themselves;
+// otherwise the body names the approver:
+//
+//	{"approver": "alice@example.com", "note": "rotate with the v2 credentials"}
+func (m *Monitor) handleDecision(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, api.Prefix+"/rotations/"), "/")
+	if len(parts) != 2 || (parts[1] != "approve" && parts[1] != "reject") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req pending.Approval
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if user := auth.FromContext(r.Context()); user != nil {
+		req.Approver = user.Email // signed-in users decide as themselves
+	}
+	if req.Approver == "" {
+		http.Error(w, "approver is required", http.StatusBadRequest)
+		return
+	}
+
+	approve := parts[1] == "approve"
+	rotation, err := m.decide(r.Context(), parts[0], approve, req.Approver, req.Note)
+	switch {
+	case errors.Is(err, errNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, errNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if approve {
+		m.audit.Record(audit.WithActor(r.Context(), req.Approver), audit.RotationApproved, rotation.Secret.Resource(), req, err)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	m.notifyRequest(rotation)
+	writeJSON(w, rotation)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
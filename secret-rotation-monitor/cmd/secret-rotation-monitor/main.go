@@ -0,0 +1,10 @@
+// Command secret-rotation-monitor tracks the age of the Secrets ConfigHub
+// units reference and drives their rotation through approved requests. The
+// same app runs as "devops-apps secrets".
+package main
+
+import secretrotation "github.com/monadic/devops-examples/secret-rotation-monitor"
+
+func main() {
+	secretrotation.Main()
+}
@@ -0,0 +1,121 @@
+package secretrotation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Config holds the secret rotation monitor's settings. They are read from
+// CONFIG_FILE (default /etc/secret-rotation-monitor/config.yaml); each can
+// be overridden by the environment variable in its env tag.
+type Config struct {
+	CubAPIURL    string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken     string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir   string `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Port         int    `yaml:"rotation_port" env:"ROTATION_PORT"`
+	AuthConfig   string `yaml:"auth_config" env:"AUTH_CONFIG"`     // OIDC login for the API; a missing file leaves it open
+	NotifyConfig string `yaml:"notify_config" env:"NOTIFY_CONFIG"` // notification routing; a missing file sends nothing
+	ClusterName  string `yaml:"cluster_name" env:"CLUSTER_NAME"`   // cluster label of the /metrics samples
+
+	// Spaces whose units reference the Secrets tracked, and the namespace of
+	// units that don't name one
+	Spaces    []string `yaml:"cub_spaces" env:"CUB_SPACES"`
+	Namespace string   `yaml:"namespace" env:"NAMESPACE"`
+	// Rotation policy: a Secret is overdue once max_age old and due within
+	// warn_before of it. A Secret's rotation-max-age annotation overrides
+	// max_age.
+	MaxAge     time.Duration `yaml:"max_age" env:"MAX_AGE"`
+	WarnBefore time.Duration `yaml:"warn_before" env:"WARN_BEFORE"`
+
+	// Space the rotation requests are stored in as units, created when missing
+	RequestsSpace string `yaml:"requests_space" env:"REQUESTS_SPACE"`
+	// A rejected request is raised again this long after the rejection
+	RejectFor   time.Duration `yaml:"reject_for" env:"REJECT_FOR"`
+	AuditSpace  string        `yaml:"audit_space" env:"AUDIT_SPACE"` // where audit entries are written; empty keeps them in memory
+	RunInterval time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:    "https://hub.confighub.com/api",
+		Port:         8093,
+		AuthConfig:   "/etc/secret-rotation-monitor/auth.yaml",
+		NotifyConfig: "/etc/secret-rotation-monitor/notify.yaml",
+
+		Namespace:  "default",
+		MaxAge:     90 * 24 * time.Hour,
+		WarnBefore: 14 * 24 * time.Hour,
+
+		RequestsSpace: "secret-rotation-monitor",
+		RejectFor:     30 * 24 * time.Hour,
+		RunInterval:   time.Hour,
+
+		CubRateLimit:     5,
+		CubRateBurst:     10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if len(c.Spaces) == 0 {
+		return fmt.Errorf("cub_spaces is required: the Secrets tracked are those the units reference")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.MaxAge <= 0 {
+		return fmt.Errorf("max_age must be positive, got %s", c.MaxAge)
+	}
+	if c.WarnBefore < 0 || c.WarnBefore >= c.MaxAge {
+		return fmt.Errorf("warn_before must be between 0 and max_age, got %s", c.WarnBefore)
+	}
+	if c.RequestsSpace == "" {
+		return fmt.Errorf("requests_space is required")
+	}
+	if c.RejectFor <= 0 {
+		return fmt.Errorf("reject_for must be positive, got %s", c.RejectFor)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("rotation_port %d is not a valid port", c.Port)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/secret-rotation-monitor/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
@@ -0,0 +1,379 @@
+// Package secretrotation tracks the age of the Secrets ConfigHub units
+// reference, flags those past their rotation policy, and drives their
+// rotation through requests an operator approves. Each request is a unit of
+// the requests space; approving it rotates a Secret whose values the
+// monitor may generate, or asks the Secret's owners to rotate it, and a
+// later scan closes it once the Secret has changed.
+package secretrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/notify"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/client-go/kubernetes"
+)
+
+const version = "1.0.0"
+
+var (
+	errNotFound   = errors.New("no such rotation request")
+	errNotPending = errors.New("rotation request already decided")
+)
+
+type Monitor struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	clientset  kubernetes.Interface
+	store      Store
+	rotate     func(ctx context.Context, s Secret, now time.Time) error
+	notifier   *notify.Notifier
+	audit      *audit.Log
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu        sync.RWMutex
+	secrets   []Secret            // of the latest scan, most urgent first
+	requests  map[string]*Request // by slug
+	unitIDs   map[string]uuid.UUID
+	scannedAt time.Time
+	scanError string // of the latest scan, when it failed
+}
+
+// Main scans the Secrets every run_interval until interrupted, or once with
+// --mode=job. It is the entry point of cmd/secret-rotation-monitor and of
+// "devops-apps secrets".
+func Main() {
+	logger := logging.Setup("secret-rotation-monitor")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "secret-rotation-monitor", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "secret-rotation-monitor",
+		Version:     version,
+		Description: "Tracks the age of the Secrets units reference and drives their rotation",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+	notifier, err := notify.Load(cfg.NotifyConfig)
+	if err != nil {
+		logging.Fatal("Failed to load notify config", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	cubLimit := ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker)
+	monitor := newMonitor(cfg)
+	monitor.app = app
+	monitor.clientset = app.K8s.Clientset
+	monitor.rotate = func(ctx context.Context, s Secret, now time.Time) error {
+		return rotateSecret(ctx, app.K8s.Clientset, s, now)
+	}
+	monitor.notifier = notifier
+	monitor.audit = newAuditLog(app.Cub, cubLimit, cfg.AuditSpace)
+	monitor.metrics = metrics.New("secret-rotation-monitor", version, cfg.ClusterName)
+	monitor.cubLimit, monitor.cubBreaker = cubLimit, cubBreaker
+	monitor.metrics.Collect(metrics.Limiter(cubLimit))
+	monitor.metrics.Collect(metrics.ListCache(listcache.Install()))
+	monitor.metrics.Collect(metrics.Breakers(cubBreaker))
+	monitor.health = health.New()
+	monitor.health.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	monitor.health.Register("confighub", health.Optional, health.Breaker(cubBreaker))
+	monitor.metrics.Collect(monitor.collect)
+
+	if err := monitor.initialize(); err != nil {
+		logging.Fatal("Failed to initialize ConfigHub resources", logging.Err(err))
+	}
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, monitor.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "secret-rotation-monitor", monitor.scan, func() Report { return monitor.Report("", "") }, os.Stdout, store); err != nil {
+			logging.Fatal("Secret scan failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Secret rotation API listening", "addr", addr)
+	group.Serve(addr, guard.Protect(monitor.handler(), "/metrics", "/health"))
+	group.Go(monitor.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Secret rotation API stopped", logging.Err(err))
+	}
+}
+
+func newMonitor(cfg Config) *Monitor {
+	return &Monitor{config: cfg, requests: map[string]*Request{}, unitIDs: map[string]uuid.UUID{}}
+}
+
+// initialize finds the requests space, creating it when missing, and reads
+// the requests already in it
+func (m *Monitor) initialize() error {
+	ctx := context.Background()
+	spaces, err := ratelimit.Call(ctx, m.cubLimit, "ListSpaces", "all", m.app.Cub.ListSpaces)
+	if err != nil {
+		return fmt.Errorf("list spaces: %w", err)
+	}
+	for _, s := range spaces {
+		if s.Slug == m.config.RequestsSpace {
+			m.store = cubStore{cub: m.app.Cub, limit: m.cubLimit, space: s.SpaceID}
+			slog.Info("Using existing requests space", logging.Space(s.Slug), "space_id", s.SpaceID)
+			return m.loadRequests(ctx)
+		}
+	}
+
+	space, err := m.app.Cub.CreateSpace(sdk.CreateSpaceRequest{
+		Slug:        m.config.RequestsSpace,
+		DisplayName: "Secret Rotation Requests",
+		Labels: map[string]string{
+			"app":  "secret-rotation-monitor",
+			"team": "security",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create space: %w", err)
+	}
+	m.store = cubStore{cub: m.app.Cub, limit: m.cubLimit, space: space.SpaceID}
+	slog.Info("Created requests space", logging.Space(space.Slug), "space_id", space.SpaceID)
+	return nil
+}
+
+// run scans now and every run_interval until interrupted
+func (m *Monitor) run(ctx context.Context) error {
+	ticker := time.NewTicker(m.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.scan(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Secret scan failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan reads the Secrets the units reference, classifies them and updates
+// the requests. A scan that can't read the units or the cluster changes
+// nothing, so no request is closed for lack of its Secret.
+func (m *Monitor) scan(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "secrets.scan")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := m.metrics.Cycle("scan", "")
+	defer func() { cycleDone(err) }()
+	defer func() {
+		m.mu.Lock()
+		m.scanError = ""
+		if err != nil {
+			m.scanError = err.Error()
+		}
+		m.mu.Unlock()
+	}()
+
+	refs, err := m.references(ctx)
+	if err != nil {
+		return err
+	}
+	secrets, err := readSecrets(ctx, m.clientset, refs, m.config.MaxAge)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	classify(secrets, m.config.WarnBefore, now)
+	written, err := m.request(ctx, secrets, now)
+	m.mu.Lock()
+	m.secrets, m.scannedAt = secrets, now
+	m.mu.Unlock()
+	for _, s := range secrets {
+		if s.Status == StatusDue || s.Status == StatusMissing {
+			m.notifySecret(s)
+		}
+	}
+	for _, r := range written {
+		m.notifyRequest(r)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("Secrets scanned", "secrets", len(secrets), "requests_written", len(written))
+	return nil
+}
+
+// references returns the Secrets the units of the configured spaces use,
+// by namespace/name
+func (m *Monitor) references(ctx context.Context) (map[string]*Secret, error) {
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, m.cubLimit, "ListSpaces", "all", m.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list spaces: %w", err)
+	}
+	ids := map[string]uuid.UUID{}
+	for _, s := range spaces {
+		ids[s.Slug] = s.SpaceID
+	}
+
+	refs := map[string]*Secret{}
+	for _, slug := range m.config.Spaces {
+		id, ok := ids[slug]
+		if !ok {
+			return nil, fmt.Errorf("space %s not found", slug)
+		}
+		units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, m.cubLimit, "ListUnits", id.String(), func() ([]*sdk.Unit, error) {
+				return m.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: id})
+			})
+		}, tracing.SpaceKey.String(slug))
+		if err != nil {
+			return nil, fmt.Errorf("list units of %s: %w", slug, err)
+		}
+		secretRefs(slug, units, m.config.Namespace, refs)
+	}
+	return refs, nil
+}
+
+// consumers lists a Secret's consumers for a notification
+func consumers(s Secret) string {
+	var names []string
+	for _, c := range s.Consumers {
+		names = append(names, fmt.Sprintf("%s/%s (%s %s, %s)", c.Space, c.UnitSlug, c.Kind, c.Name, c.Use))
+	}
+	return strings.Join(names, ", ")
+}
+
+// notifySecret warns about a Secret due for rotation or missing, once per
+// status and rotation
+func (m *Monitor) notifySecret(s Secret) {
+	if !m.notifier.Enabled() {
+		return
+	}
+	n := notify.Notification{
+		App:      "secret-rotation-monitor",
+		Kind:     "secret-rotation",
+		Severity: notify.Info,
+		Title:    fmt.Sprintf("Secret %s is due for rotation in %d days", s.Resource(), s.DaysLeft),
+		Summary:  fmt.Sprintf("Last rotated %d days ago; the policy is %d days", s.AgeDays, int(s.MaxAge.Hours()/24)),
+		Fields:   map[string]string{"consumers": consumers(s), "rotated_at": s.RotatedAt.Format(time.RFC3339)},
+		DedupKey: fmt.Sprintf("secret-rotation/%s/%s/%s", s.Resource(), s.Status, s.RotatedAt.Format(time.RFC3339)),
+	}
+	if s.Status == StatusMissing {
+		n.Severity = notify.Warning
+		n.Title = fmt.Sprintf("Secret %s is referenced but does not exist", s.Resource())
+		n.Summary = "Pods using it fail to start until it is created"
+		delete(n.Fields, "rotated_at")
+	}
+	if err := m.notifier.Notify(context.Background(), n); err != nil {
+		slog.Warn("Failed to send secret rotation notification", logging.Err(err))
+	}
+}
+
+// notifyRequest tells the owners about a request: raised, approved, or
+// closed by a rotation
+func (m *Monitor) notifyRequest(r Request) {
+	if !m.notifier.Enabled() {
+		return
+	}
+	s := r.Secret
+	n := notify.Notification{
+		App:  "secret-rotation-monitor",
+		Kind: "secret-rotation",
+		Fields: map[string]string{
+			"request":    r.Slug,
+			"consumers":  consumers(s),
+			"rotated_at": s.RotatedAt.Format(time.RFC3339),
+			"decided_by": r.DecidedBy,
+			"note":       r.Note,
+		},
+		DedupKey: fmt.Sprintf("secret-rotation/%s/%s/%s", r.Slug, r.Status, r.RequestedAt.Format(time.RFC3339)),
+	}
+	switch r.Status {
+	case RequestPending:
+		n.Severity = notify.Warning
+		n.Title = fmt.Sprintf("Secret %s is overdue for rotation", s.Resource())
+		n.Summary = fmt.Sprintf("Last rotated %d days ago, past its %d-day policy; approve or reject request %s", s.AgeDays, int(s.MaxAge.Hours()/24), r.Slug)
+	case RequestApproved:
+		n.Severity = notify.Warning
+		n.Title = fmt.Sprintf("Rotate Secret %s", s.Resource())
+		n.Summary = fmt.Sprintf("Rotation approved by %s; restart the consumers reading it into environment variables once it is rotated", r.DecidedBy)
+	case RequestRotated:
+		n.Severity = notify.Info
+		n.Title = fmt.Sprintf("Secret %s was rotated", s.Resource())
+		n.Summary = "Restart the consumers reading it into environment variables to pick up the new values"
+	default:
+		return
+	}
+	if err := m.notifier.Notify(context.Background(), n); err != nil {
+		slog.Warn("Failed to send secret rotation notification", logging.Err(err))
+	}
+}
+
+// collect exports each Secret's age and the requests by status
+func (m *Monitor) collect(emit metrics.Emit) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byStatus := map[string]int{StatusMissing: 0, StatusOverdue: 0, StatusDue: 0, StatusOK: 0}
+	for _, s := range m.secrets {
+		byStatus[s.Status]++
+		if s.Status != StatusMissing {
+			emit("secret_age_days", "Days since the Secret was last rotated.", "gauge",
+				metrics.Labels{"namespace": s.Namespace, "secret": s.Name}, float64(s.AgeDays))
+		}
+	}
+	for status, n := range byStatus {
+		emit("secrets", "Referenced Secrets by rotation status.", "gauge", metrics.Labels{"status": status}, float64(n))
+	}
+	requests := map[string]int{RequestPending: 0, RequestApproved: 0, RequestRejected: 0, RequestRotated: 0, RequestResolved: 0}
+	for _, r := range m.requests {
+		requests[r.Status]++
+	}
+	for status, n := range requests {
+		emit("secret_rotation_requests", "Rotation requests by status.", "gauge", metrics.Labels{"status": status}, float64(n))
+	}
+}
@@ -0,0 +1,269 @@
+package secretrotation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RequestType is the "type" label of the units holding rotation requests
+const RequestType = "rotation-request"
+
+// Statuses of a rotation request, in its unit's "status" label
+const (
+	RequestPending  = "pending"  // the Secret is overdue; waiting for approval
+	RequestApproved = "approved" // its owners were asked to rotate it
+	RequestRejected = "rejected" // left as it is; raised again after reject_for
+	RequestRotated  = "rotated"  // rotated since the request was raised
+	RequestResolved = "resolved" // no longer overdue or referenced without a rotation, e.g. after a policy change
+)
+
+// Request is the rotation of one overdue Secret, decided by an operator
+type Request struct {
+	Secret      Secret     `json:"secret"` // as last scanned
+	Slug        string     `json:"slug"`   // of its unit, and its ID in the API
+	Status      string     `json:"status"`
+	RequestedAt time.Time  `json:"requested_at"`
+	DecidedBy   string     `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	ClosedAt    *time.Time `json:"closed_at,omitempty"` // when it was found rotated or resolved
+	Error       string     `json:"error,omitempty"`     // of the last failed generation
+}
+
+// Store keeps one unit per request in the requests space, so decisions
+// survive restarts and have ConfigHub's history
+type Store interface {
+	// Units returns the request units by slug
+	Units(ctx context.Context) (map[string]StoredUnit, error)
+	Create(ctx context.Context, slug string, labels map[string]string, data string) (uuid.UUID, error)
+	Update(ctx context.Context, id uuid.UUID, labels map[string]string, data string) error
+}
+
+// StoredUnit is a request unit in ConfigHub
+type StoredUnit struct {
+	ID     uuid.UUID
+	Labels map[string]string
+	Data   string
+}
+
+// requestSlug is the slug of a Secret's request unit
+func requestSlug(s Secret) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, fmt.Sprintf("rotate-%s-%s", s.Namespace, s.Name))
+}
+
+// open reports whether a request still waits for its Secret's rotation
+func (r *Request) open() bool {
+	return r.Status == RequestPending || r.Status == RequestApproved || r.Status == RequestRejected
+}
+
+// loadRequests reads the stored requests; called once, before the first scan
+func (m *Monitor) loadRequests(ctx context.Context) error {
+	units, err := m.store.Units(ctx)
+	if err != nil {
+		return fmt.Errorf("list request units: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for slug, u := range units {
+		var r Request
+		if err := json.Unmarshal([]byte(u.Data), &r); err != nil {
+			return fmt.Errorf("read request %s: %w", slug, err)
+		}
+		m.requests[slug] = &r
+		m.unitIDs[slug] = u.ID
+	}
+	return nil
+}
+
+// request brings the requests in line with the Secrets of a scan: an
+// overdue Secret gets a pending request unless it has an open one, a
+// rejection older than reject_for is raised again, and open requests whose
+// Secret was rotated since, or is no longer overdue or referenced, are
+// closed. It returns the requests written; new pending ones are notified.
+func (m *Monitor) request(ctx context.Context, secrets []Secret, now time.Time) ([]Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changed []*Request
+	seen := map[string]bool{}
+	for _, s := range secrets {
+		slug := requestSlug(s)
+		seen[slug] = true
+		r, ok := m.requests[slug]
+		switch {
+		case ok && r.open() && s.RotatedAt.After(r.RequestedAt):
+			r.Secret, r.Status, r.ClosedAt = s, RequestRotated, &now
+		case s.Status != StatusOverdue:
+			if !ok || !r.open() || r.Status == RequestRejected {
+				continue
+			}
+			r.Secret, r.Status, r.ClosedAt = s, RequestResolved, &now
+		case !ok, !r.open(), r.Status == RequestRejected && now.Sub(*r.DecidedAt) >= m.config.RejectFor:
+			r = &Request{Secret: s, Slug: slug, Status: RequestPending, RequestedAt: now}
+			m.requests[slug] = r
+		case r.Status != RequestRejected && (r.Secret.AgeDays != s.AgeDays || len(r.Secret.Consumers) != len(s.Consumers)):
+			r.Secret = s
+		default:
+			continue
+		}
+		changed = append(changed, r)
+	}
+	for slug, r := range m.requests {
+		if !seen[slug] && (r.Status == RequestPending || r.Status == RequestApproved) {
+			r.Status, r.ClosedAt = RequestResolved, &now
+			changed = append(changed, r)
+		}
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Slug < changed[j].Slug })
+	written := make([]Request, 0, len(changed))
+	for _, r := range changed {
+		if err := m.save(ctx, r); err != nil {
+			return written, err
+		}
+		written = append(written, *r)
+	}
+	return written, nil
+}
+
+// decide approves or rejects the pending request with slug. Approving a
+// Secret with generated keys rotates it now; when that fails the request
+// stays pending. Approving any other Secret hands its rotation to its
+// owners, and the request stays approved until a scan finds it rotated.
+func (m *Monitor) decide(ctx context.Context, slug string, approve bool, actor, note string) (Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.requests[slug]
+	if !ok {
+		return Request{}, errNotFound
+	}
+	if r.Status != RequestPending {
+		return *r, fmt.Errorf("%w: %s is %s", errNotPending, slug, r.Status)
+	}
+
+	now := time.Now()
+	switch {
+	case !approve:
+		r.Status = RequestRejected
+	case len(r.Secret.Generate) > 0:
+		if err := m.rotate(ctx, r.Secret, now); err != nil {
+			r.Error = err.Error()
+			m.save(ctx, r)
+			return *r, fmt.Errorf("rotate %s: %w", r.Secret.Resource(), err)
+		}
+		r.Status, r.Error, r.ClosedAt = RequestRotated, "", &now
+		r.Secret.RotatedAt, r.Secret.Status, r.Secret.AgeDays = now, StatusOK, 0
+		r.Secret.DaysLeft = int(r.Secret.MaxAge.Hours() / 24)
+	default:
+		r.Status = RequestApproved
+	}
+	r.DecidedBy, r.DecidedAt, r.Note = actor, &now, note
+	return *r, m.save(ctx, r)
+}
+
+// save writes a request's unit
+func (m *Monitor) save(ctx context.Context, r *Request) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	labels := map[string]string{
+		"type":      RequestType,
+		"status":    r.Status,
+		"namespace": r.Secret.Namespace,
+	}
+	if id, ok := m.unitIDs[r.Slug]; ok {
+		err = m.store.Update(ctx, id, labels, string(data))
+	} else {
+		var id uuid.UUID
+		if id, err = m.store.Create(ctx, r.Slug, labels, string(data)); err == nil {
+			m.unitIDs[r.Slug] = id
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("write request %s: %w", r.Slug, err)
+	}
+	return nil
+}
+
+// rotateSecret replaces the generated keys of a Secret with new random
+// values and marks it rotated at now. Pods reading the Secret from a volume
+// see the new values; those reading it into environment variables need a
+// restart.
+func rotateSecret(ctx context.Context, clientset kubernetes.Interface, s Secret, now time.Time) error {
+	secret, err := clientset.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for _, key := range s.Generate {
+		value := make([]byte, 32)
+		if _, err := rand.Read(value); err != nil {
+			return err
+		}
+		secret.Data[key] = []byte(base64.RawURLEncoding.EncodeToString(value))
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[rotatedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	_, err = clientset.CoreV1().Secrets(s.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// cubStore is a Store in the ConfigHub space with ID space
+type cubStore struct {
+	cub   *sdk.ConfigHubClient
+	limit *ratelimit.Limiter
+	space uuid.UUID
+}
+
+func (s cubStore) Units(ctx context.Context) (map[string]StoredUnit, error) {
+	where := fmt.Sprintf("Labels['type'] = '%s'", RequestType)
+	units, err := ratelimit.Call(ctx, s.limit, "ListUnits", s.space.String()+"/"+where, func() ([]*sdk.Unit, error) {
+		return s.cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.space, Where: where})
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]StoredUnit, len(units))
+	for _, u := range units {
+		stored[u.Slug] = StoredUnit{ID: u.UnitID, Labels: u.Labels, Data: u.Data}
+	}
+	return stored, nil
+}
+
+func (s cubStore) Create(ctx context.Context, slug string, labels map[string]string, data string) (uuid.UUID, error) {
+	u, err := s.cub.CreateUnit(s.space, sdk.CreateUnitRequest{Slug: slug, Data: data, Labels: labels})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return u.UnitID, nil
+}
+
+func (s cubStore) Update(ctx context.Context, id uuid.UUID, labels map[string]string, data string) error {
+	_, err := s.cub.UpdateUnit(s.space, id, sdk.UpdateUnitRequest{Data: data, Labels: labels})
+	return err
+}
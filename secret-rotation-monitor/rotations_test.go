@@ -0,0 +1,214 @@
+package secretrotation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// memoryStore is a Store in memory
+type memoryStore struct {
+	units  map[string]StoredUnit
+	writes []string // "slug=status"
+}
+
+func (s *memoryStore) Units(context.Context) (map[string]StoredUnit, error) {
+	return s.units, nil
+}
+
+func (s *memoryStore) Create(_ context.Context, slug string, labels map[string]string, data string) (uuid.UUID, error) {
+	id := uuid.New()
+	s.units[slug] = StoredUnit{ID: id, Labels: labels, Data: data}
+	s.writes = append(s.writes, slug+"="+labels["status"])
+	return id, nil
+}
+
+func (s *memoryStore) Update(_ context.Context, id uuid.UUID, labels map[string]string, data string) error {
+	for slug, u := range s.units {
+		if u.ID == id {
+			s.units[slug] = StoredUnit{ID: id, Labels: labels, Data: data}
+			s.writes = append(s.writes, slug+"="+labels["status"])
+			return nil
+		}
+	}
+	return fmt.Errorf("unit %s not found", id)
+}
+
+func newTestMonitor(store *memoryStore) (*Monitor, *[]string) {
+	var rotated []string
+	m := newMonitor(DefaultConfig())
+	m.store = store
+	m.rotate = func(_ context.Context, s Secret, _ time.Time) error {
+		if s.Name == "locked" {
+			return errors.New("forbidden")
+		}
+		rotated = append(rotated, s.Resource())
+		return nil
+	}
+	m.audit = audit.New("secret-rotation-monitor", nil, nil)
+	m.metrics = metrics.New("secret-rotation-monitor", version, "test")
+	return m, &rotated
+}
+
+// scanned is a Secret as classified at at, last rotated days before it
+func scanned(name string, days int, at time.Time, generate ...string) Secret {
+	s := Secret{Namespace: "shop", Name: name, RotatedAt: at.Add(-time.Duration(days) * 24 * time.Hour), MaxAge: 90 * 24 * time.Hour, Generate: generate}
+	secrets := []Secret{s}
+	classify(secrets, 14*24*time.Hour, at)
+	return secrets[0]
+}
+
+func TestRequest(t *testing.T) {
+	store := &memoryStore{units: map[string]StoredUnit{}}
+	m, _ := newTestMonitor(store)
+	ctx := context.Background()
+	scan := func(at time.Time, secrets ...Secret) []string {
+		t.Helper()
+		store.writes = nil
+		if _, err := m.request(ctx, secrets, at); err != nil {
+			t.Fatal(err)
+		}
+		return store.writes
+	}
+
+	if got := scan(now, scanned("db", 100, now), scanned("api", 80, now), scanned("cache", 95, now)); !reflect.DeepEqual(got, []string{"rotate-shop-cache=pending", "rotate-shop-db=pending"}) {
+		t.Errorf("first scan wrote %v; want requests for the overdue Secrets only", got)
+	}
+	if got := scan(now, scanned("db", 100, now), scanned("cache", 95, now)); len(got) != 0 {
+		t.Errorf("unchanged scan wrote %v", got)
+	}
+	if _, err := m.decide(ctx, "rotate-shop-cache", false, "alice@example.com", "rotated with the migration next week"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a day later db was rotated; cache is still rejected
+	later := now.Add(24 * time.Hour)
+	if got := scan(later, scanned("db", 0, later), scanned("cache", 96, later)); !reflect.DeepEqual(got, []string{"rotate-shop-db=rotated"}) {
+		t.Errorf("scan after the rotation wrote %v", got)
+	}
+	if r := m.requests["rotate-shop-db"]; r.ClosedAt == nil || !r.ClosedAt.Equal(later) {
+		t.Errorf("rotated request = %+v", r)
+	}
+
+	// the rejection is raised again after reject_for
+	expired := now.Add(m.config.RejectFor + time.Hour)
+	if got := scan(expired, scanned("db", 30, expired), scanned("cache", 126, expired)); !reflect.DeepEqual(got, []string{"rotate-shop-cache=pending"}) {
+		t.Errorf("scan after reject_for wrote %v", got)
+	}
+	// and resolved once no unit references the Secret
+	if got := scan(expired, scanned("db", 30, expired)); !reflect.DeepEqual(got, []string{"rotate-shop-cache=resolved"}) {
+		t.Errorf("scan without the Secret wrote %v", got)
+	}
+
+	// a restarted monitor reads the requests back
+	restarted, _ := newTestMonitor(store)
+	if err := restarted.loadRequests(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r := restarted.requests["rotate-shop-db"]; r == nil || r.Status != RequestRotated || r.Secret.Name != "db" {
+		t.Errorf("reloaded request = %+v", r)
+	}
+}
+
+func TestDecisionAPI(t *testing.T) {
+	store := &memoryStore{units: map[string]StoredUnit{}}
+	m, rotated := newTestMonitor(store)
+	if _, err := m.request(context.Background(), []Secret{
+		scanned("db", 100, now),
+		scanned("session", 120, now, "key"),
+		scanned("locked", 95, now, "key"),
+	}, now); err != nil {
+		t.Fatal(err)
+	}
+	handler := m.handler()
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		code       int
+		status     string
+	}{
+		{path: "/api/v1/rotations/rotate-shop-db/approve", body: `{}`, code: http.StatusBadRequest},
+		{path: "/api/v1/rotations/rotate-shop-missing/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusNotFound},
+		{path: "/api/v1/rotations/rotate-shop-db/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusOK, status: RequestApproved},
+		{path: "/api/v1/rotations/rotate-shop-db/reject", body: `{"approver": "bob@example.com"}`, code: http.StatusConflict},
+		{path: "/api/v1/rotations/rotate-shop-session/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusOK, status: RequestRotated},
+		{path: "/api/v1/rotations/rotate-shop-locked/approve", body: `{"approver": "alice@example.com"}`, code: http.StatusBadGateway},
+	} {
+		rec := post(tc.path, tc.body)
+		if rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d: %s", tc.path, rec.Code, tc.code, rec.Body)
+			continue
+		}
+		if tc.status == "" {
+			continue
+		}
+		var r Request
+		if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != tc.status || r.DecidedBy != "alice@example.com" {
+			t.Errorf("%s: got %+v", tc.path, r)
+		}
+	}
+	if !reflect.DeepEqual(*rotated, []string{"shop/session"}) {
+		t.Errorf("rotated %v", *rotated)
+	}
+	if r := m.requests["rotate-shop-locked"]; r.Status != RequestPending || r.Error == "" {
+		t.Errorf("failed rotation left %+v; want it pending with the error", r)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rotations?status=all", nil))
+	var requests []Request
+	if err := json.Unmarshal(rec.Body.Bytes(), &requests); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 3 || requests[0].Slug != "rotate-shop-db" || requests[2].Slug != "rotate-shop-session" {
+		t.Errorf("listed %+v; want all three, oldest Secret first", requests)
+	}
+	for _, path := range []string{"/api/v1/rotations?status=done", "/api/v1/secrets?status=old"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", path, rec.Code)
+		}
+	}
+
+	entries, _, _ := m.audit.Entries(context.Background(), audit.Query{Action: audit.RotationApproved})
+	if len(entries) != 3 {
+		t.Errorf("audit has %d approvals, want 3 including the failed one", len(entries))
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	clientset := fake.NewSimpleClientset(secret("session", 120, map[string]string{generateAnnotation: "key"}))
+	s := scanned("session", 120, now, "key")
+	if err := rotateSecret(context.Background(), clientset, s, now); err != nil {
+		t.Fatal(err)
+	}
+	got, err := clientset.CoreV1().Secrets("shop").Get(context.Background(), "session", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Data["key"]) != 43 || !rotatedAt(got).Equal(now) {
+		t.Errorf("rotated secret = %+v", got)
+	}
+}
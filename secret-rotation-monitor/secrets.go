@@ -0,0 +1,268 @@
+package secretrotation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/monadic/devops-sdk"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Annotations of a Secret read and written by the monitor
+const (
+	// RFC 3339 time of the last rotation, set by whatever rotates the
+	// Secret; without it the last write of its data counts
+	rotatedAtAnnotation = "devops.confighub.com/rotated-at"
+	// Go duration overriding max_age for the Secret
+	maxAgeAnnotation = "devops.confighub.com/rotation-max-age"
+	// Comma-separated keys holding random values the monitor may generate
+	// itself when a rotation is approved
+	generateAnnotation = "devops.confighub.com/rotation-generate"
+)
+
+// Rotation statuses of a Secret, most urgent first
+const (
+	StatusMissing = "missing" // referenced by a unit but not in the cluster
+	StatusOverdue = "overdue" // older than its max age
+	StatusDue     = "due"     // within warn_before of its max age
+	StatusOK      = "ok"
+)
+
+var statusRank = map[string]int{StatusMissing: 0, StatusOverdue: 1, StatusDue: 2, StatusOK: 3}
+
+// Consumer is a unit's resource using a Secret
+type Consumer struct {
+	Space    string `json:"space"`
+	UnitSlug string `json:"unit_slug"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	// How it uses the Secret: env, volume, image-pull, tls or declared
+	// for a Secret unit
+	Use string `json:"use"`
+}
+
+// Secret is a Secret referenced by the units, with its rotation status
+type Secret struct {
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Consumers []Consumer    `json:"consumers"`
+	RotatedAt time.Time     `json:"rotated_at,omitempty"`
+	MaxAge    time.Duration `json:"max_age"`
+	Generate  []string      `json:"generate,omitempty"` // keys the monitor can rotate itself
+	Error     string        `json:"error,omitempty"`    // why its annotations could not be read
+
+	Status   string `json:"status"`
+	AgeDays  int    `json:"age_days"`
+	DaysLeft int    `json:"days_left"` // until overdue, negative once it is
+}
+
+// Resource names the Secret as namespace/name
+func (s Secret) Resource() string {
+	return s.Namespace + "/" + s.Name
+}
+
+// workload is the part of a unit holding pod templates and Secret names
+type workload struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		corev1.PodSpec                         // Pod
+		Template       *corev1.PodTemplateSpec `json:"template"` // Deployment, StatefulSet, DaemonSet, ReplicaSet, Job
+		JobTemplate    *struct {               // CronJob
+			Spec struct {
+				Template corev1.PodTemplateSpec `json:"template"`
+			} `json:"spec"`
+		} `json:"jobTemplate"`
+		TLS []struct {
+			SecretName string `json:"secretName"`
+		} `json:"tls"` // Ingress
+	} `json:"spec"`
+	Secrets          []corev1.ObjectReference      `json:"secrets"`          // ServiceAccount
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets"` // ServiceAccount
+}
+
+// secretRefs adds the Secrets each unit of a space uses to refs, by
+// namespace/name
+func secretRefs(space string, units []*sdk.Unit, defaultNamespace string, refs map[string]*Secret) {
+	for _, u := range units {
+		var w workload
+		if err := yaml.Unmarshal([]byte(u.Data), &w); err != nil || w.Kind == "" {
+			continue
+		}
+		ns := w.Metadata.Namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		use := func(name, how string) {
+			if name == "" {
+				return
+			}
+			s := refs[ns+"/"+name]
+			if s == nil {
+				s = &Secret{Namespace: ns, Name: name}
+				refs[ns+"/"+name] = s
+			}
+			c := Consumer{Space: space, UnitSlug: u.Slug, Kind: w.Kind, Name: w.Metadata.Name, Use: how}
+			for _, existing := range s.Consumers {
+				if existing == c {
+					return
+				}
+			}
+			s.Consumers = append(s.Consumers, c)
+		}
+
+		switch w.Kind {
+		case "Secret":
+			use(w.Metadata.Name, "declared")
+		case "ServiceAccount":
+			for _, r := range w.Secrets {
+				use(r.Name, "token")
+			}
+			for _, r := range w.ImagePullSecrets {
+				use(r.Name, "image-pull")
+			}
+		case "Ingress":
+			for _, tls := range w.Spec.TLS {
+				use(tls.SecretName, "tls")
+			}
+		case "Pod":
+			podSecrets(&w.Spec.PodSpec, use)
+		case "CronJob":
+			if w.Spec.JobTemplate != nil {
+				podSecrets(&w.Spec.JobTemplate.Spec.Template.Spec, use)
+			}
+		default:
+			if w.Spec.Template != nil {
+				podSecrets(&w.Spec.Template.Spec, use)
+			}
+		}
+	}
+}
+
+// podSecrets calls use with each Secret a pod spec reads
+func podSecrets(spec *corev1.PodSpec, use func(name, how string)) {
+	for _, v := range spec.Volumes {
+		if v.Secret != nil {
+			use(v.Secret.SecretName, "volume")
+		}
+		if v.Projected != nil {
+			for _, s := range v.Projected.Sources {
+				if s.Secret != nil {
+					use(s.Secret.Name, "volume")
+				}
+			}
+		}
+	}
+	for _, c := range append(append([]corev1.Container(nil), spec.InitContainers...), spec.Containers...) {
+		for _, from := range c.EnvFrom {
+			if from.SecretRef != nil {
+				use(from.SecretRef.Name, "env")
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				use(env.ValueFrom.SecretKeyRef.Name, "env")
+			}
+		}
+	}
+	for _, r := range spec.ImagePullSecrets {
+		use(r.Name, "image-pull")
+	}
+}
+
+// readSecrets fills in each referenced Secret's last rotation and policy
+// from the cluster; a Secret that does not exist is left without a rotation
+// time
+func readSecrets(ctx context.Context, clientset kubernetes.Interface, refs map[string]*Secret, maxAge time.Duration) ([]Secret, error) {
+	var secrets []Secret
+	for _, s := range refs {
+		s.MaxAge = maxAge
+		secret, err := clientset.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return nil, fmt.Errorf("get secret %s: %w", s.Resource(), err)
+		default:
+			s.RotatedAt = rotatedAt(secret)
+			if v := secret.Annotations[maxAgeAnnotation]; v != "" {
+				if d, err := time.ParseDuration(v); err == nil && d > 0 {
+					s.MaxAge = d
+				} else {
+					s.Error = fmt.Sprintf("%s %q is not a positive duration", maxAgeAnnotation, v)
+				}
+			}
+			for _, key := range strings.Split(secret.Annotations[generateAnnotation], ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					s.Generate = append(s.Generate, key)
+				}
+			}
+		}
+		sort.Slice(s.Consumers, func(i, j int) bool {
+			a, b := s.Consumers[i], s.Consumers[j]
+			return a.Space+"/"+a.UnitSlug < b.Space+"/"+b.UnitSlug
+		})
+		secrets = append(secrets, *s)
+	}
+	return secrets, nil
+}
+
+// rotatedAt returns when a Secret was last rotated: its rotated-at
+// annotation, else the last write of its data, else its creation
+func rotatedAt(s *corev1.Secret) time.Time {
+	if t, err := time.Parse(time.RFC3339, s.Annotations[rotatedAtAnnotation]); err == nil {
+		return t
+	}
+	last := s.CreationTimestamp.Time
+	for _, f := range s.ManagedFields {
+		if f.Time == nil || f.FieldsV1 == nil || !f.Time.After(last) {
+			continue
+		}
+		if fields := string(f.FieldsV1.Raw); strings.Contains(fields, `"f:data"`) || strings.Contains(fields, `"f:stringData"`) {
+			last = f.Time.Time
+		}
+	}
+	return last
+}
+
+// classify sets the status, age and days left of each Secret at now and
+// sorts them most urgent first, oldest first within a status
+func classify(secrets []Secret, warnBefore time.Duration, now time.Time) {
+	for i := range secrets {
+		s := &secrets[i]
+		if s.RotatedAt.IsZero() {
+			s.Status = StatusMissing
+			continue
+		}
+		age := now.Sub(s.RotatedAt)
+		s.AgeDays = int(age.Hours() / 24)
+		s.DaysLeft = int((s.MaxAge - age).Hours() / 24)
+		switch {
+		case age >= s.MaxAge:
+			s.Status = StatusOverdue
+		case age >= s.MaxAge-warnBefore:
+			s.Status = StatusDue
+		default:
+			s.Status = StatusOK
+		}
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		a, b := secrets[i], secrets[j]
+		if statusRank[a.Status] != statusRank[b.Status] {
+			return statusRank[a.Status] < statusRank[b.Status]
+		}
+		if a.DaysLeft != b.DaysLeft {
+			return a.DaysLeft < b.DaysLeft
+		}
+		return a.Resource() < b.Resource()
+	})
+}
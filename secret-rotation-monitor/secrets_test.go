@@ -0,0 +1,157 @@
+package secretrotation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// now is when the tests scan; decisions are taken at the wall clock
+var now = time.Now().UTC().Truncate(time.Second)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: shop
+spec:
+  template:
+    spec:
+      imagePullSecrets:
+      - name: registry
+      volumes:
+      - name: tls
+        secret:
+          secretName: api-tls
+      containers:
+      - name: api
+        envFrom:
+        - secretRef:
+            name: api-env
+        env:
+        - name: DB_PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: db
+              key: password
+        - name: DB_USER
+          valueFrom:
+            secretKeyRef:
+              name: db
+              key: user
+`
+
+func TestSecretRefs(t *testing.T) {
+	units := []*sdk.Unit{
+		{UnitID: uuid.New(), Slug: "api", Data: deployment},
+		{UnitID: uuid.New(), Slug: "report", Data: "apiVersion: batch/v1\nkind: CronJob\nmetadata:\n  name: report\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers:\n          - name: r\n            env:\n            - name: TOKEN\n              valueFrom:\n                secretKeyRef: {name: report-token, key: token}\n"},
+		{UnitID: uuid.New(), Slug: "web", Data: "apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: web\n  namespace: shop\nspec:\n  tls:\n  - secretName: web-tls\n"},
+		{UnitID: uuid.New(), Slug: "db-secret", Data: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\n  namespace: shop\n"},
+		{UnitID: uuid.New(), Slug: "config", Data: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"},
+		{UnitID: uuid.New(), Slug: "notes", Data: "not: [kubernetes"},
+	}
+	refs := map[string]*Secret{}
+	secretRefs("prod", units, "default", refs)
+
+	got := map[string][]string{}
+	for key, s := range refs {
+		for _, c := range s.Consumers {
+			got[key] = append(got[key], c.UnitSlug+":"+c.Use)
+		}
+	}
+	want := map[string][]string{
+		"shop/registry":        {"api:image-pull"},
+		"shop/api-tls":         {"api:volume"},
+		"shop/api-env":         {"api:env"},
+		"shop/db":              {"api:env", "db-secret:declared"}, // one consumer for both keys
+		"default/report-token": {"report:env"},
+		"shop/web-tls":         {"web:tls"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRotatedAt(t *testing.T) {
+	created := metav1.NewTime(now.Add(-100 * 24 * time.Hour))
+	dataWrite := metav1.NewTime(now.Add(-10 * 24 * time.Hour))
+	labelWrite := metav1.NewTime(now.Add(-time.Hour))
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		CreationTimestamp: created,
+		ManagedFields: []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Time: &dataWrite, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:password":{}}}`)}},
+			{Manager: "labeler", Time: &labelWrite, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{}}}`)}},
+		},
+	}}
+	if got := rotatedAt(s); !got.Equal(dataWrite.Time) {
+		t.Errorf("from managed fields: got %s, want the data write %s", got, dataWrite.Time)
+	}
+	s.Annotations = map[string]string{rotatedAtAnnotation: "2026-05-30T00:00:00Z"}
+	if got := rotatedAt(s); !got.Equal(time.Date(2026, 5, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("from the annotation: got %s", got)
+	}
+	if got := rotatedAt(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created}}); !got.Equal(created.Time) {
+		t.Errorf("never written: got %s, want its creation", got)
+	}
+}
+
+// secret is a Secret last rotated days ago, with annotations
+func secret(name string, days int, annotations map[string]string) *corev1.Secret {
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[rotatedAtAnnotation] = now.Add(-time.Duration(days) * 24 * time.Hour).Format(time.RFC3339)
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name, Annotations: annotations}}
+}
+
+func TestReadAndClassify(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		secret("db", 120, nil),
+		secret("api-env", 80, map[string]string{generateAnnotation: "session-key, csrf-key"}),
+		secret("api-tls", 20, map[string]string{maxAgeAnnotation: "360h"}),
+		secret("registry", 5, map[string]string{maxAgeAnnotation: "soon"}),
+	)
+	refs := map[string]*Secret{}
+	for _, name := range []string{"db", "api-env", "api-tls", "registry", "gone"} {
+		refs["shop/"+name] = &Secret{Namespace: "shop", Name: name}
+	}
+	cfg := DefaultConfig()
+	secrets, err := readSecrets(context.Background(), clientset, refs, cfg.MaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	classify(secrets, cfg.WarnBefore, now)
+
+	var got []string
+	for _, s := range secrets {
+		got = append(got, s.Name+"="+s.Status)
+	}
+	// api-tls is past its 15 day policy; registry keeps the default
+	want := []string{"gone=missing", "db=overdue", "api-tls=overdue", "api-env=due", "registry=ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, s := range secrets {
+		switch s.Name {
+		case "db":
+			if s.AgeDays != 120 || s.DaysLeft != -30 {
+				t.Errorf("db: age %d, %d days left", s.AgeDays, s.DaysLeft)
+			}
+		case "api-env":
+			if !reflect.DeepEqual(s.Generate, []string{"session-key", "csrf-key"}) || s.DaysLeft != 10 {
+				t.Errorf("api-env: %+v", s)
+			}
+		case "registry":
+			if s.Error == "" || s.MaxAge != cfg.MaxAge {
+				t.Errorf("registry: invalid max age not reported: %+v", s)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package securitydrift
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/pending"
+)
+
+// SecurityReport is the result of the latest detection, served at GET /api/v1/security
+type SecurityReport struct {
+	CheckedAt   time.Time      `json:"checked_at"`
+	Space       string         `json:"space"`
+	Deployments int            `json:"deployments"` // units checked against the cluster
+	Findings    []Finding      `json:"findings"`
+	BySeverity  map[string]int `json:"by_severity"`
+	Degraded    string         `json:"degraded,omitempty"` // why the report may be out of date
+}
+
+// recordReport keeps the outcome of a detection run for the API
+func (d *SecurityDetector) recordReport(findings []Finding, checked int) {
+	report := &SecurityReport{
+		CheckedAt:   time.Now(),
+		Space:       d.spaceSlug,
+		Deployments: checked,
+		Findings:    findings,
+		BySeverity:  map[string]int{SeverityCritical: 0, SeverityHigh: 0, SeverityMedium: 0},
+	}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	for _, f := range findings {
+		report.BySeverity[f.Severity]++
+	}
+
+	d.mu.Lock()
+	d.report = report
+	d.mu.Unlock()
+}
+
+// markDegraded flags the current report as stale until the next completed run
+func (d *SecurityDetector) markDegraded(reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.report == nil || d.report.Degraded == reason {
+		return
+	}
+	report := *d.report
+	report.Degraded = reason
+	d.report = &report
+}
+
+// handleReport returns the latest report, or 503 until the first detection
+// has run. ?severity=high keeps the findings of that severity and above.
+func (d *SecurityDetector) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.mu.RLock()
+	report := d.report
+	d.mu.RUnlock()
+	if report == nil {
+		http.Error(w, "security drift detection has not run yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	if severity := r.URL.Query().Get("severity"); severity != "" {
+		rank, ok := severityRank[severity]
+		if !ok {
+			http.Error(w, "severity must be critical, high or medium", http.StatusBadRequest)
+			return
+		}
+		filtered := *report
+		filtered.Findings = []Finding{}
+		for _, f := range report.Findings {
+			if severityRank[f.Severity] <= rank {
+				filtered.Findings = append(filtered.Findings, f)
+			}
+		}
+		report = &filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// apiHandler serves the security API under /api/v1. With guard, users sign
+// in through the identity provider.
+func (d *SecurityDetector) apiHandler(guard *auth.Guard) http.Handler {
+	mux := http.NewServeMux()
+	v1 := api.New(mux, "security-drift-detector")
+	v1.HandleFunc("/security", d.handleReport, api.Operation{
+		Summary:  "Latest security drift report",
+		Query:    []api.Param{{Name: "severity", Description: "findings of this severity and above"}},
+		Response: SecurityReport{},
+	})
+	v1.Handle("/flags", d.flags, flags.Operations...)
+	v1.Handle("/audit", d.audit, audit.Operations...)
+	v1.Handle("/queue/", d.pending, pending.Operations...)
+	v1.Handle("/breakers", breaker.Handler(d.cubBreaker), breaker.Operations...)
+	mux.Handle("/metrics", d.metrics)
+	return guard.Protect(d.audit.Calls(mux), "/metrics")
+}
@@ -0,0 +1,233 @@
+package securitydrift
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Checks run against every Deployment unit
+const (
+	CheckImage          = "image"                      // a container runs another image than the unit's
+	CheckImageDigest    = "image-digest"               // pods run another digest than the unit pins, or a tag moved
+	CheckContainer      = "container"                  // a container the unit doesn't have
+	CheckPrivileged     = "privileged"                 // a container became privileged
+	CheckEscalation     = "allow-privilege-escalation" // privilege escalation was allowed
+	CheckRunAsNonRoot   = "run-as-non-root"            // runAsNonRoot was dropped
+	CheckCapabilities   = "capabilities"               // Linux capabilities were added
+	CheckHostNamespaces = "host-namespaces"            // hostNetwork, hostPID or hostIPC was turned on
+	CheckHostPath       = "host-path"                  // a hostPath volume was mounted
+)
+
+// Severities, most severe first
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+)
+
+var severityRank = map[string]int{SeverityCritical: 0, SeverityHigh: 1, SeverityMedium: 2}
+
+// criticalCapabilities give a container the run of its node
+var criticalCapabilities = map[corev1.Capability]bool{"ALL": true, "SYS_ADMIN": true, "NET_ADMIN": true, "SYS_PTRACE": true, "SYS_MODULE": true}
+
+// Finding is one security-relevant difference between a unit and the live
+// cluster. Only changes that weaken the unit are findings: a container
+// dropping a capability the unit grants is not reported.
+type Finding struct {
+	UnitID      uuid.UUID `json:"unit_id"`
+	UnitSlug    string    `json:"unit_slug"`
+	Environment string    `json:"environment,omitempty"` // the unit's "env" label
+	Resource    string    `json:"resource"`
+	Container   string    `json:"container,omitempty"`
+	Check       string    `json:"check"`
+	Severity    string    `json:"severity"`
+	Expected    string    `json:"expected"`
+	Actual      string    `json:"actual"`
+}
+
+// checkDeployment compares the live Deployment and its pods with the unit's.
+// allowedHostPaths are mounts that are never findings.
+func checkDeployment(desired, live *appsv1.Deployment, pods []*corev1.Pod, allowedHostPaths []string) []Finding {
+	want, got := &desired.Spec.Template.Spec, &live.Spec.Template.Spec
+	var findings []Finding
+	add := func(container, check, severity, expected, actual string) {
+		findings = append(findings, Finding{
+			Resource: "Deployment/" + desired.Name, Container: container,
+			Check: check, Severity: severity, Expected: expected, Actual: actual,
+		})
+	}
+
+	for _, ns := range []struct {
+		name       string
+		want, have bool
+	}{
+		{"hostNetwork", want.HostNetwork, got.HostNetwork},
+		{"hostPID", want.HostPID, got.HostPID},
+		{"hostIPC", want.HostIPC, got.HostIPC},
+	} {
+		if ns.have && !ns.want {
+			add("", CheckHostNamespaces, SeverityCritical, ns.name+": false", ns.name+": true")
+		}
+	}
+
+	allowed := map[string]bool{}
+	for _, p := range allowedHostPaths {
+		allowed[p] = true
+	}
+	for _, v := range want.Volumes {
+		if v.HostPath != nil {
+			allowed[v.HostPath.Path] = true
+		}
+	}
+	for _, v := range got.Volumes {
+		if v.HostPath != nil && !allowed[v.HostPath.Path] {
+			add("", CheckHostPath, SeverityCritical, "no hostPath "+v.HostPath.Path, fmt.Sprintf("volume %s mounts %s", v.Name, v.HostPath.Path))
+		}
+	}
+
+	wantContainers := containersByName(want)
+	digests := runningDigests(pods)
+	for _, c := range append(append([]corev1.Container(nil), got.InitContainers...), got.Containers...) {
+		w, ok := wantContainers[c.Name]
+		if !ok {
+			add(c.Name, CheckContainer, SeverityHigh, "not in the unit", c.Image)
+			continue
+		}
+		if c.Image != w.Image {
+			add(c.Name, CheckImage, SeverityHigh, w.Image, c.Image)
+		}
+		if d := digests[c.Name]; len(d) > 0 && c.Image == w.Image {
+			if pinned := imageDigest(w.Image); pinned != "" {
+				for _, running := range d {
+					if running != pinned {
+						add(c.Name, CheckImageDigest, SeverityCritical, pinned, running)
+					}
+				}
+			} else if len(d) > 1 {
+				add(c.Name, CheckImageDigest, SeverityMedium, "one digest for "+w.Image, strings.Join(d, ", "))
+			}
+		}
+
+		if privileged(c) && !privileged(w) {
+			add(c.Name, CheckPrivileged, SeverityCritical, "privileged: false", "privileged: true")
+		}
+		if escalation(c) && !escalation(w) {
+			add(c.Name, CheckEscalation, SeverityHigh, "allowPrivilegeEscalation: false", "allowPrivilegeEscalation: true")
+		}
+		if runAsNonRoot(want, w) && !runAsNonRoot(got, c) {
+			add(c.Name, CheckRunAsNonRoot, SeverityHigh, "runAsNonRoot: true", "runAsNonRoot: unset or false")
+		}
+		granted := map[corev1.Capability]bool{}
+		for _, capability := range addedCapabilities(w) {
+			granted[capability] = true
+		}
+		for _, capability := range addedCapabilities(c) {
+			if granted[capability] {
+				continue
+			}
+			severity := SeverityHigh
+			if criticalCapabilities[capability] {
+				severity = SeverityCritical
+			}
+			add(c.Name, CheckCapabilities, severity, "no "+string(capability), "adds "+string(capability))
+		}
+	}
+
+	sortFindings(findings)
+	return findings
+}
+
+// sortFindings orders findings by severity, then by unit, resource and container
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] < severityRank[b.Severity]
+		}
+		if a.UnitSlug != b.UnitSlug {
+			return a.UnitSlug < b.UnitSlug
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Container < b.Container
+	})
+}
+
+func containersByName(spec *corev1.PodSpec) map[string]corev1.Container {
+	containers := make(map[string]corev1.Container, len(spec.Containers)+len(spec.InitContainers))
+	for _, c := range spec.InitContainers {
+		containers[c.Name] = c
+	}
+	for _, c := range spec.Containers {
+		containers[c.Name] = c
+	}
+	return containers
+}
+
+// runningDigests returns the image digests the pods' containers run, by
+// container name and sorted
+func runningDigests(pods []*corev1.Pod) map[string][]string {
+	seen := map[string]map[string]bool{}
+	for _, pod := range pods {
+		for _, s := range append(append([]corev1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+			digest := imageDigest(s.ImageID)
+			if digest == "" {
+				continue
+			}
+			if seen[s.Name] == nil {
+				seen[s.Name] = map[string]bool{}
+			}
+			seen[s.Name][digest] = true
+		}
+	}
+	digests := make(map[string][]string, len(seen))
+	for name, set := range seen {
+		for digest := range set {
+			digests[name] = append(digests[name], digest)
+		}
+		sort.Strings(digests[name])
+	}
+	return digests
+}
+
+// imageDigest returns the sha256:... part of an image reference or a
+// container status' imageID (docker-pullable://repo@sha256:...), or ""
+func imageDigest(ref string) string {
+	if i := strings.LastIndex(ref, "@"); i >= 0 && strings.HasPrefix(ref[i+1:], "sha256:") {
+		return ref[i+1:]
+	}
+	return ""
+}
+
+func privileged(c corev1.Container) bool {
+	return c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged
+}
+
+// escalation reports whether the container may gain privileges. Kubernetes
+// allows it unless told otherwise, so only an explicit false is safe.
+func escalation(c corev1.Container) bool {
+	sc := c.SecurityContext
+	return sc == nil || sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation
+}
+
+// runAsNonRoot reports whether the container must run as non-root, set on
+// the container or inherited from the pod
+func runAsNonRoot(pod *corev1.PodSpec, c corev1.Container) bool {
+	if c.SecurityContext != nil && c.SecurityContext.RunAsNonRoot != nil {
+		return *c.SecurityContext.RunAsNonRoot
+	}
+	return pod.SecurityContext != nil && pod.SecurityContext.RunAsNonRoot != nil && *pod.SecurityContext.RunAsNonRoot
+}
+
+func addedCapabilities(c corev1.Container) []corev1.Capability {
+	if c.SecurityContext == nil || c.SecurityContext.Capabilities == nil {
+		return nil
+	}
+	return c.SecurityContext.Capabilities.Add
+}
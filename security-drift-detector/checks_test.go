@@ -0,0 +1,165 @@
+package securitydrift
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const pinned = "registry.example.com/api@sha256:1111"
+
+func deployment(spec corev1.PodSpec) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api", Generation: 1},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+			Template: corev1.PodTemplateSpec{Spec: spec},
+		},
+	}
+}
+
+// hardened is a unit's pod spec that every check passes against itself
+func hardened() corev1.PodSpec {
+	no, yes := false, true
+	return corev1.PodSpec{
+		SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &yes},
+		Containers: []corev1.Container{{
+			Name:  "api",
+			Image: pinned,
+			SecurityContext: &corev1.SecurityContext{
+				AllowPrivilegeEscalation: &no,
+				Capabilities:             &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE"}},
+			},
+		}},
+		Volumes: []corev1.Volume{{Name: "certs", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/etc/ssl/certs"}}}},
+	}
+}
+
+func pod(name string, digests map[string]string) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: name, Labels: map[string]string{"app": "api"}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	for container, digest := range digests {
+		p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name: container, ImageID: "docker-pullable://registry.example.com/api@" + digest,
+		})
+	}
+	return p
+}
+
+func TestCheckDeployment(t *testing.T) {
+	yes, no := true, false
+	for _, tc := range []struct {
+		name    string
+		change  func(*corev1.PodSpec)
+		pods    []*corev1.Pod
+		allowed []string
+		want    []string // check/severity of each finding
+	}{
+		{name: "unchanged", change: func(*corev1.PodSpec) {}, pods: []*corev1.Pod{pod("api-1", map[string]string{"api": "sha256:1111"})}},
+		{
+			name:   "privileged",
+			change: func(s *corev1.PodSpec) { s.Containers[0].SecurityContext.Privileged = &yes },
+			want:   []string{"privileged/critical"},
+		},
+		{
+			name: "escalation and root",
+			change: func(s *corev1.PodSpec) {
+				s.Containers[0].SecurityContext.AllowPrivilegeEscalation = nil
+				s.SecurityContext = nil
+			},
+			want: []string{"allow-privilege-escalation/high", "run-as-non-root/high"},
+		},
+		{
+			name:   "runAsNonRoot overridden on the container",
+			change: func(s *corev1.PodSpec) { s.Containers[0].SecurityContext.RunAsNonRoot = &no },
+			want:   []string{"run-as-non-root/high"},
+		},
+		{
+			name: "capabilities",
+			change: func(s *corev1.PodSpec) {
+				s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"NET_BIND_SERVICE", "SYS_ADMIN", "CHOWN"}
+			},
+			want: []string{"capabilities/critical", "capabilities/high"},
+		},
+		{
+			name: "host namespaces and paths",
+			change: func(s *corev1.PodSpec) {
+				s.HostPID = true
+				s.Volumes = append(s.Volumes,
+					corev1.Volume{Name: "root", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}},
+					corev1.Volume{Name: "logs", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/log"}}})
+			},
+			allowed: []string{"/var/log"},
+			want:    []string{"host-namespaces/critical", "host-path/critical"},
+		},
+		{
+			name: "image and extra container",
+			change: func(s *corev1.PodSpec) {
+				s.Containers[0].Image = "registry.example.com/api:debug"
+				s.Containers = append(s.Containers, corev1.Container{Name: "shell", Image: "busybox"})
+			},
+			want: []string{"image/high", "container/high"},
+		},
+		{
+			name:   "digest other than the pinned one",
+			change: func(*corev1.PodSpec) {},
+			pods:   []*corev1.Pod{pod("api-1", map[string]string{"api": "sha256:1111"}), pod("api-2", map[string]string{"api": "sha256:2222"})},
+			want:   []string{"image-digest/critical"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			live := hardened()
+			tc.change(&live)
+			findings := checkDeployment(deployment(hardened()), deployment(live), tc.pods, tc.allowed)
+
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.Check+"/"+f.Severity)
+				if f.Resource != "Deployment/api" {
+					t.Errorf("resource = %s", f.Resource)
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("findings = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("findings = %v, want %v", got, tc.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCheckMovedTag(t *testing.T) {
+	unit := hardened()
+	unit.Containers[0].Image = "registry.example.com/api:1.4"
+	pods := []*corev1.Pod{pod("api-1", map[string]string{"api": "sha256:1111"}), pod("api-2", map[string]string{"api": "sha256:2222"})}
+
+	findings := checkDeployment(deployment(unit), deployment(unit), pods, nil)
+	if len(findings) != 1 || findings[0].Check != CheckImageDigest || findings[0].Severity != SeverityMedium ||
+		findings[0].Actual != "sha256:1111, sha256:2222" {
+		t.Errorf("findings = %+v, want one for the tag running two digests", findings)
+	}
+	if findings := checkDeployment(deployment(unit), deployment(unit), pods[:1], nil); len(findings) != 0 {
+		t.Errorf("findings = %+v for one digest", findings)
+	}
+}
+
+func TestParseDeployment(t *testing.T) {
+	d, err := parseDeployment("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n  namespace: payments\n")
+	if err != nil || d == nil || d.Name != "api" || d.Namespace != "payments" {
+		t.Errorf("parseDeployment = %+v, %v", d, err)
+	}
+	if d, err := parseDeployment(`{"kind": "Service", "metadata": {"name": "api"}}`); d != nil || err != nil {
+		t.Errorf("Service parsed as %+v, %v", d, err)
+	}
+	if _, err := parseDeployment("kind: [Deployment"); err == nil {
+		t.Error("parsed invalid YAML")
+	}
+}
@@ -0,0 +1,10 @@
+// Command security-drift-detector reports and corrects security-relevant
+// changes made to Kubernetes outside ConfigHub. The same app runs as
+// "devops-apps security".
+package main
+
+import securitydrift "github.com/monadic/devops-examples/security-drift-detector"
+
+func main() {
+	securitydrift.Main()
+}
@@ -0,0 +1,115 @@
+package securitydrift
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Config holds the security drift detector's settings. They are read from
+// CONFIG_FILE (default /etc/security-drift-detector/config.yaml); each can be
+// overridden by the environment variable in its env tag.
+type Config struct {
+	Space        string        `yaml:"cub_space" env:"CUB_SPACE"`
+	CubAPIURL    string        `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken     string        `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir   string        `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Namespace    string        `yaml:"namespace" env:"NAMESPACE"`     // of units that don't name one
+	AutoFix      bool          `yaml:"auto_fix" env:"AUTO_FIX"`       // re-apply units whose live resources drifted
+	APIPort      int           `yaml:"security_api_port" env:"SECURITY_API_PORT"`
+	NotifyConfig string        `yaml:"notify_config" env:"NOTIFY_CONFIG"`
+	PolicyConfig string        `yaml:"policy_config" env:"POLICY_CONFIG"` // auto-fix rules, see pkg/policy; a missing file keeps the built-in ones
+	AuthConfig   string        `yaml:"auth_config" env:"AUTH_CONFIG"`     // OIDC login for the API; a missing file leaves it open
+	RunInterval  time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+	FlagsSpace   string        `yaml:"flags_space" env:"FLAGS_SPACE"` // space of the feature-flags unit; empty disables overrides
+	FlagsRefresh time.Duration `yaml:"flags_refresh" env:"FLAGS_REFRESH"`
+	AuditSpace   string        `yaml:"audit_space" env:"AUDIT_SPACE"`       // space audit entries are written to; empty keeps them in memory
+	PendingSpace string        `yaml:"pending_space" env:"PENDING_SPACE"`   // space corrections awaiting approval or retry are queued in, see pkg/pending
+	PendingDir   string        `yaml:"pending_dir" env:"PENDING_DIR"`       // directory they are queued in without a space; empty keeps them in memory
+	ClusterName  string        `yaml:"cluster_name" env:"CLUSTER_NAME"`     // cluster label of the /metrics samples
+	CubRateLimit float64       `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int           `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+
+	// hostPath mounts expected on top of the units' own, e.g. /var/log for a
+	// log agent deployed outside ConfigHub
+	AllowedHostPaths []string `yaml:"allowed_host_paths" env:"ALLOWED_HOST_PATHS"`
+
+	// Namespace and label selector the informers watch, to shrink their
+	// caches on big clusters; empty watches every namespace and object
+	WatchNamespace string `yaml:"watch_namespace" env:"WATCH_NAMESPACE"`
+	WatchSelector  string `yaml:"watch_selector" env:"WATCH_SELECTOR"`
+
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		Space:        "security-drift-detector",
+		CubAPIURL:    "https://hub.confighub.com/api",
+		Namespace:    "default",
+		APIPort:      8086,
+		NotifyConfig: "/etc/security-drift-detector/notify.yaml",
+		PolicyConfig: "/etc/security-drift-detector/policy.yaml",
+		AuthConfig:   "/etc/security-drift-detector/auth.yaml",
+		RunInterval:  5 * time.Minute,
+		FlagsRefresh: 30 * time.Second,
+		CubRateLimit: 5,
+		CubRateBurst: 10,
+
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if c.Space == "" {
+		return fmt.Errorf("cub_space is required")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.APIPort < 1 || c.APIPort > 65535 {
+		return fmt.Errorf("security_api_port %d is not a valid port", c.APIPort)
+	}
+	if _, err := labels.Parse(c.WatchSelector); err != nil {
+		return fmt.Errorf("watch_selector: %w", err)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.FlagsRefresh <= 0 {
+		return fmt.Errorf("flags_refresh must be positive, got %s", c.FlagsRefresh)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/security-drift-detector/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
@@ -0,0 +1,67 @@
+package securitydrift
+
+import (
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newInformerFactory returns the informer factory of the detector, limited
+// to WATCH_NAMESPACE and WATCH_SELECTOR when they are set. Its caches hold
+// stripped objects, see stripForCache.
+func newInformerFactory(clientset kubernetes.Interface, cfg Config) informers.SharedInformerFactory {
+	options := []informers.SharedInformerOption{informers.WithTransform(stripForCache)}
+	if cfg.WatchNamespace != "" {
+		options = append(options, informers.WithNamespace(cfg.WatchNamespace))
+	}
+	if cfg.WatchSelector != "" {
+		options = append(options, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = cfg.WatchSelector
+		}))
+	}
+	return informers.NewSharedInformerFactoryWithOptions(clientset, 10*time.Minute, options...)
+}
+
+// stripForCache drops what the checks never read before an object is
+// cached. Unlike drift-detector the checks read the cache itself: a
+// Deployment keeps its spec, a pod only its labels and the image digests of
+// its container statuses, which is all that's needed of the pods of a big
+// cluster.
+func stripForCache(obj interface{}) (interface{}, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		stripped, err := stripForCache(tombstone.Obj)
+		tombstone.Obj = stripped
+		return tombstone, err
+	}
+	if accessor, ok := obj.(metav1.Object); ok {
+		accessor.SetManagedFields(nil)
+	}
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		o.Status = appsv1.DeploymentStatus{}
+	case *corev1.Pod:
+		o.Spec = corev1.PodSpec{}
+		o.Status = corev1.PodStatus{
+			Phase:                 o.Status.Phase,
+			InitContainerStatuses: digestsOnly(o.Status.InitContainerStatuses),
+			ContainerStatuses:     digestsOnly(o.Status.ContainerStatuses),
+		}
+	}
+	return obj, nil
+}
+
+func digestsOnly(statuses []corev1.ContainerStatus) []corev1.ContainerStatus {
+	if len(statuses) == 0 {
+		return nil
+	}
+	stripped := make([]corev1.ContainerStatus, len(statuses))
+	for i, s := range statuses {
+		stripped[i] = corev1.ContainerStatus{Name: s.Name, Image: s.Image, ImageID: s.ImageID}
+	}
+	return stripped
+}
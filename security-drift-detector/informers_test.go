@@ -0,0 +1,37 @@
+package securitydrift
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStripForCache(t *testing.T) {
+	managed := []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}
+	d := deployment(hardened())
+	d.ManagedFields = managed
+	d.Status.Replicas = 3
+	obj, _ := stripForCache(d)
+	if stripped := obj.(*appsv1.Deployment); stripped.ManagedFields != nil || stripped.Status.Replicas != 0 ||
+		len(stripped.Spec.Template.Spec.Containers) != 1 {
+		t.Errorf("Expected the Deployment's spec only, got %+v", stripped)
+	}
+
+	p := pod("api-1", map[string]string{"api": "sha256:1111"})
+	p.ManagedFields = managed
+	p.Spec = hardened()
+	p.Status.ContainerStatuses[0].RestartCount = 4
+	p.Status.PodIP = "10.0.0.7"
+	obj, _ = stripForCache(cache.DeletedFinalStateUnknown{Key: "payments/api-1", Obj: p})
+	stripped := obj.(cache.DeletedFinalStateUnknown).Obj.(*corev1.Pod)
+	if stripped.ManagedFields != nil || len(stripped.Spec.Containers) != 0 || stripped.Status.PodIP != "" ||
+		stripped.Status.Phase != corev1.PodRunning || stripped.Labels["app"] != "api" {
+		t.Errorf("Expected the pod's labels, phase and statuses only, got %+v", stripped)
+	}
+	if s := stripped.Status.ContainerStatuses; len(s) != 1 || s[0].RestartCount != 0 || imageDigest(s[0].ImageID) != "sha256:1111" {
+		t.Errorf("Expected the container's image digest only, got %+v", s)
+	}
+}
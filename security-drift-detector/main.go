@@ -0,0 +1,557 @@
+// Package securitydrift detects security-relevant drift: changes made to
+// live Deployments outside ConfigHub that weaken them - another image or
+// image digest, privileged containers, dropped runAsNonRoot, added
+// capabilities, host namespaces and new hostPath mounts. Each Deployment
+// unit of the space is checked against the informer cache whenever a
+// Deployment or pod changes. With auto_fix the drifted units are applied
+// again, so ConfigHub's version replaces the change.
+package securitydrift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/bulk"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/flags"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/notify"
+	"github.com/monadic/devops-examples/pkg/pending"
+	"github.com/monadic/devops-examples/pkg/policy"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+const version = "1.0.0"
+
+// settleDelay lets a rollout, which changes many pods at once, finish
+// before the detection it triggered runs
+const settleDelay = 5 * time.Second
+
+type SecurityDetector struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	spaceID    uuid.UUID
+	spaceSlug  string
+	notifier   *notify.Notifier
+	flags      *flags.Set
+	audit      *audit.Log
+	policy     *policy.Policy // which corrections go ahead
+	pending    *pending.Queue // corrections awaiting approval or retry
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter // throttles and coalesces ConfigHub reads
+	cubBreaker *breaker.Breaker
+
+	deployments appslisters.DeploymentLister // the informer caches the checks read
+	pods        corelisters.PodLister
+	wake        chan struct{} // a watched object changed
+
+	mu     sync.RWMutex
+	report *SecurityReport // latest detection, served by the API
+}
+
+// Main runs the security drift detector until it is interrupted, or once
+// with --mode=job. It is the entry point of cmd/security-drift-detector and
+// of "devops-apps security".
+func Main() {
+	logger := logging.Setup("security-drift-detector")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "security-drift-detector", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "security-drift-detector",
+		Version:     version,
+		Description: "Detects security-relevant drift between ConfigHub and Kubernetes",
+		RunInterval: cfg.RunInterval,
+		HealthPort:  8080,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+	reg := metrics.Setup("security-drift-detector", version, cfg.ClusterName)
+
+	notifier, err := notify.Load(cfg.NotifyConfig)
+	if err != nil {
+		logging.Fatal("Failed to load notify config", logging.Err(err))
+	}
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+	pol, err := policy.Load(cfg.PolicyConfig)
+	if err != nil {
+		logging.Fatal("Failed to load policy", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	cubLimit := ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker)
+	detector := &SecurityDetector{
+		app:        app,
+		config:     cfg,
+		notifier:   notifier,
+		flags:      flags.New("security-drift-detector", map[string]bool{flags.AutoFix: cfg.AutoFix}, flagsSource(app.Cub, cubLimit, cfg.FlagsSpace)),
+		audit:      newAuditLog(app.Cub, cubLimit, cfg.AuditSpace),
+		policy:     pol,
+		metrics:    reg,
+		cubLimit:   cubLimit,
+		cubBreaker: cubBreaker,
+		wake:       make(chan struct{}, 1),
+	}
+	if detector.pending, err = newPendingQueue(app.Cub, cubLimit, cfg.PendingSpace, cfg.PendingDir); err != nil {
+		logging.Fatal("Failed to set up the pending queue", logging.Err(err))
+	}
+	detector.handlePending()
+	reg.Collect(metrics.Limiter(cubLimit))
+	reg.Collect(metrics.ListCache(listcache.Install()))
+	reg.Collect(metrics.Breakers(cubBreaker))
+	checker := health.New()
+	checker.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	checker.Register("confighub", health.Required, health.Breaker(cubBreaker))
+	checker.Mount(http.DefaultServeMux)
+
+	if err := detector.initialize(); err != nil {
+		logging.Fatal("Failed to initialize ConfigHub resources", logging.Err(err))
+	}
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "security-drift-detector", detector.detectOnce, detector.Report, os.Stdout, store); err != nil {
+			logging.Fatal("Security drift detection failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go detector.flags.Watch(group.Context(), cfg.FlagsRefresh)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	go detector.pending.Start(group.Context(), time.Minute)
+	apiAddr := fmt.Sprintf(":%d", cfg.APIPort)
+	slog.Info("Security API listening", "addr", apiAddr)
+	group.Serve(apiAddr, detector.apiHandler(guard))
+	group.Go(detector.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Security drift detector stopped", logging.Err(err))
+	}
+}
+
+// initialize finds the space of the units to check, creating it when missing
+func (d *SecurityDetector) initialize() error {
+	spaces, err := ratelimit.Call(context.Background(), d.cubLimit, "ListSpaces", "all", d.app.Cub.ListSpaces)
+	if err != nil {
+		return fmt.Errorf("list spaces: %w", err)
+	}
+	for _, s := range spaces {
+		if s.Slug == d.config.Space {
+			d.spaceID, d.spaceSlug = s.SpaceID, s.Slug
+			slog.Info("Using existing space", logging.Space(s.Slug), "space_id", s.SpaceID)
+			return nil
+		}
+	}
+
+	space, err := d.app.Cub.CreateSpace(sdk.CreateSpaceRequest{
+		Slug:        d.config.Space,
+		DisplayName: "Security Drift Detector Space",
+		Labels: map[string]string{
+			"app":  "security-drift-detector",
+			"team": "security",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create space: %w", err)
+	}
+	d.spaceID, d.spaceSlug = space.SpaceID, space.Slug
+	slog.Info("Created new space", logging.Space(space.Slug), "space_id", space.SpaceID)
+	return nil
+}
+
+// run watches Deployments and pods and runs a detection on every change
+// that matters to the checks, and every run_interval, until ctx is done
+func (d *SecurityDetector) run(ctx context.Context) error {
+	shutdown, err := d.startInformers(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer shutdown()
+	slog.Info("Informers started, watching Deployments and pods", "version", version)
+
+	ticker := time.NewTicker(d.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.detect(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Security drift detection failed", logging.Space(d.spaceSlug), logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		case <-d.wake:
+			select {
+			case <-ctx.Done():
+				slog.Info("Received shutdown signal")
+				return nil
+			case <-time.After(settleDelay):
+			}
+		}
+	}
+}
+
+// startInformers starts the Deployment and pod informers and waits for
+// their caches; the returned func shuts them down
+func (d *SecurityDetector) startInformers(ctx context.Context) (func(), error) {
+	factory := newInformerFactory(d.app.K8s.Clientset, d.config)
+	deployments := factory.Apps().V1().Deployments()
+	pods := factory.Core().V1().Pods()
+	deployments.Informer().AddEventHandler(&eventHandler{detector: d})
+	pods.Informer().AddEventHandler(&eventHandler{detector: d})
+	d.deployments, d.pods = deployments.Lister(), pods.Lister()
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), deployments.Informer().HasSynced, pods.Informer().HasSynced) {
+		factory.Shutdown()
+		return nil, fmt.Errorf("failed to sync caches")
+	}
+	return factory.Shutdown, nil
+}
+
+// detectOnce fills the informer caches and detects drift once, for
+// --mode=job
+func (d *SecurityDetector) detectOnce(ctx context.Context) error {
+	shutdown, err := d.startInformers(ctx)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+	return d.detect(ctx)
+}
+
+// Report returns the latest report, nil before the first detection
+func (d *SecurityDetector) Report() *SecurityReport {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.report
+}
+
+// trigger asks for a detection; requests made before it starts are coalesced
+func (d *SecurityDetector) trigger() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// detect checks every Deployment unit of the space against the cluster
+func (d *SecurityDetector) detect(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "security.detect", tracing.SpaceKey.String(d.spaceSlug))
+	defer func() { tracing.End(span, err) }()
+	cycleDone := d.metrics.Cycle("detect", d.spaceSlug)
+	defer func() { cycleDone(err) }()
+
+	units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+		return ratelimit.Call(ctx, d.cubLimit, "ListUnits", d.spaceID.String(), func() ([]*sdk.Unit, error) {
+			return d.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: d.spaceID})
+		})
+	})
+	if err != nil {
+		if errors.Is(err, breaker.ErrOpen) {
+			d.markDegraded("ConfigHub unavailable, showing the last completed detection")
+		}
+		return fmt.Errorf("list units: %w", err)
+	}
+
+	findings, checked := d.evaluate(units)
+	d.recordReport(findings, checked)
+	if len(findings) == 0 {
+		slog.Info("No security drift", logging.Space(d.spaceSlug), "deployments", checked)
+		return nil
+	}
+
+	for _, f := range findings {
+		slog.Warn("Security drift", logging.Unit(f.UnitSlug), "resource", f.Resource, "container", f.Container,
+			"check", f.Check, "severity", f.Severity, "expected", f.Expected, "actual", f.Actual)
+	}
+	d.notifyFindings(findings)
+	if d.flags.Enabled(flags.AutoFix) {
+		d.correct(ctx, findings)
+	}
+	return nil
+}
+
+// evaluate runs the checks on the Deployment units that are deployed and
+// returns the findings and how many units were checked
+func (d *SecurityDetector) evaluate(units []*sdk.Unit) ([]Finding, int) {
+	var findings []Finding
+	checked := 0
+	for _, unit := range units {
+		desired, err := parseDeployment(unit.Data)
+		if err != nil {
+			slog.Debug("Skipping unit that does not parse", logging.Unit(unit.Slug), logging.Err(err))
+			continue
+		}
+		if desired == nil {
+			continue
+		}
+		namespace := desired.Namespace
+		if namespace == "" {
+			namespace = d.config.Namespace
+		}
+
+		live, err := d.deployments.Deployments(namespace).Get(desired.Name)
+		if apierrors.IsNotFound(err) {
+			continue // not deployed, or outside watch_namespace
+		}
+		if err != nil {
+			slog.Warn("Failed to read live Deployment", logging.Unit(unit.Slug), logging.Err(err))
+			continue
+		}
+		pods, err := d.runningPods(live)
+		if err != nil {
+			slog.Warn("Failed to list pods", logging.Unit(unit.Slug), logging.Err(err))
+		}
+
+		for _, f := range checkDeployment(desired, live, pods, d.config.AllowedHostPaths) {
+			f.UnitID, f.UnitSlug, f.Environment = unit.UnitID, unit.Slug, unit.Labels["env"]
+			findings = append(findings, f)
+		}
+		checked++
+	}
+	sortFindings(findings)
+	return findings, checked
+}
+
+// runningPods returns the running pods of a Deployment
+func (d *SecurityDetector) runningPods(deployment *appsv1.Deployment) ([]*corev1.Pod, error) {
+	if deployment.Spec.Selector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("selector: %w", err)
+	}
+	pods, err := d.pods.Pods(deployment.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	running := pods[:0]
+	for _, p := range pods {
+		if p.Status.Phase == corev1.PodRunning {
+			running = append(running, p)
+		}
+	}
+	return running, nil
+}
+
+// parseDeployment reads a unit's YAML or JSON; nil when it is not a Deployment
+func parseDeployment(data string) (*appsv1.Deployment, error) {
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal([]byte(data), &meta); err != nil {
+		return nil, err
+	}
+	if meta.Kind != "Deployment" {
+		return nil, nil
+	}
+	var deployment appsv1.Deployment
+	if err := yaml.Unmarshal([]byte(data), &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// notifyFindings sends the findings to the configured notification channels.
+// The same findings are only announced once per dedup window.
+func (d *SecurityDetector) notifyFindings(findings []Finding) {
+	if !d.notifier.Enabled() {
+		return
+	}
+
+	severity := notify.Warning
+	fields := map[string]string{
+		"space":    d.spaceSlug,
+		"findings": fmt.Sprintf("%d", len(findings)),
+		"auto_fix": fmt.Sprintf("%t", d.flags.Enabled(flags.AutoFix)),
+	}
+	keys := make([]string, 0, len(findings))
+	for _, f := range findings {
+		if f.Severity == SeverityCritical {
+			severity = notify.Critical
+		}
+		fields[strings.TrimSpace(f.UnitSlug+" "+f.Container)+" "+f.Check] = fmt.Sprintf("%s: expected %s, actual %s", f.Severity, f.Expected, f.Actual)
+		keys = append(keys, fmt.Sprintf("%s/%s/%s/%s=%s", f.UnitSlug, f.Resource, f.Container, f.Check, f.Actual))
+	}
+	sort.Strings(keys)
+
+	err := d.notifier.Notify(context.Background(), notify.Notification{
+		App:      "security-drift-detector",
+		Kind:     "security-drift",
+		Severity: severity,
+		Title:    fmt.Sprintf("Security drift in %s", d.spaceSlug),
+		Summary:  fmt.Sprintf("%d security-relevant changes made outside ConfigHub", len(findings)),
+		Fields:   fields,
+		DedupKey: "security-drift/" + strings.Join(keys, ","),
+	})
+	if err != nil {
+		slog.Warn("Failed to send security drift notification", logging.Err(err))
+	}
+}
+
+// correct applies the drifted units the policy allows again, in one
+// ChangeSet like drift-detector's corrections, so their ConfigHub version
+// replaces the change made in the cluster. Units the policy wants approved,
+// and units that fail to apply, are queued.
+func (d *SecurityDetector) correct(ctx context.Context, findings []Finding) {
+	byUnit := map[uuid.UUID][]Finding{}
+	for _, f := range findings {
+		byUnit[f.UnitID] = append(byUnit[f.UnitID], f)
+	}
+	for id, unitFindings := range byUnit {
+		worst := unitFindings[0] // findings are sorted most severe first
+		decision := d.policy.Check(ctx, d.audit, policy.Action{
+			App: "security-drift-detector", Kind: policy.Fix, Target: worst.UnitSlug,
+			Environment: worst.Environment, Severity: worst.Severity,
+		})
+		if decision.Verdict == policy.Approve {
+			reason := fmt.Sprintf("policy rule %s asks for approval", decision.Rule)
+			if _, err := d.pending.Wait(ctx, policy.Fix, d.spaceSlug, worst.UnitSlug, unitFindings, reason); err != nil {
+				slog.Warn("Failed to queue re-apply for approval", logging.Unit(worst.UnitSlug), logging.Err(err))
+			}
+		}
+		if !decision.Allowed() {
+			delete(byUnit, id)
+		}
+	}
+	if len(byUnit) == 0 {
+		return
+	}
+
+	changeSet, err := tracing.Call(ctx, "confighub.CreateChangeSet", func() (*sdk.ChangeSet, error) {
+		return d.app.Cub.CreateChangeSet(d.spaceID, sdk.CreateChangeSetRequest{
+			DisplayName: fmt.Sprintf("Security Drift Corrections - %s", time.Now().Format("2006-01-02 15:04")),
+			Description: fmt.Sprintf("Re-applied %d units changed outside ConfigHub", len(byUnit)),
+			Labels: map[string]string{
+				"type":      "security-correction",
+				"automated": "true",
+			},
+		})
+	})
+	if err != nil {
+		slog.Warn("Failed to create ChangeSet", logging.Err(err))
+	} else if changeSet != nil {
+		slog.Info("Created ChangeSet for security corrections", "changeset_id", changeSet.ChangeSetID)
+	}
+
+	bySlug := make(map[string][]Finding, len(byUnit))
+	units := make([]string, 0, len(byUnit))
+	for _, unitFindings := range byUnit {
+		slug := unitFindings[0].UnitSlug
+		bySlug[slug] = unitFindings
+		units = append(units, slug)
+	}
+	sort.Strings(units)
+
+	report := bulk.Run(ctx, "re-apply", units, func(ctx context.Context, slug string) error {
+		return d.reapply(ctx, bySlug[slug][0].UnitID, slug)
+	})
+	for _, slug := range report.Succeeded {
+		d.audit.Record(ctx, audit.FixApplied, slug, bySlug[slug], nil)
+		slog.Info("Re-applied unit", logging.Unit(slug), "findings", len(bySlug[slug]))
+	}
+	for _, f := range report.Failed {
+		d.audit.Record(ctx, audit.FixApplied, f.Unit, bySlug[f.Unit], f.Err)
+		slog.Error("Failed to re-apply unit", logging.Unit(f.Unit), "attempts", f.Attempts, "kind", f.Kind, logging.Err(f.Err))
+		if _, err := d.pending.Retry(ctx, policy.Fix, d.spaceSlug, f.Unit, bySlug[f.Unit], f.Err); err != nil {
+			slog.Warn("Failed to queue re-apply for retry", logging.Unit(f.Unit), logging.Err(err))
+		}
+	}
+	if report.Partial() {
+		slog.Warn("Security corrections partially applied", "applied", len(report.Succeeded), "failed", len(report.Failed))
+	}
+}
+
+// reapply applies a unit's ConfigHub version to the cluster
+func (d *SecurityDetector) reapply(ctx context.Context, unitID uuid.UUID, slug string) error {
+	return tracing.Do(ctx, "confighub.ApplyUnit", func(context.Context) error {
+		return breaker.Do(d.cubBreaker, func() error { return d.app.Cub.ApplyUnit(d.spaceID, unitID) })
+	}, tracing.UnitKey.String(slug))
+}
+
+// eventHandler triggers a detection when a Deployment's spec or the image
+// digests of a pod change, not on status updates and resyncs
+type eventHandler struct {
+	detector *SecurityDetector
+}
+
+func (h *eventHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	if !isInInitialList {
+		h.detector.trigger()
+	}
+}
+
+func (h *eventHandler) OnUpdate(oldObj, newObj interface{}) {
+	if changed(oldObj, newObj) {
+		h.detector.trigger()
+	}
+}
+
+func (h *eventHandler) OnDelete(obj interface{}) {
+	h.detector.trigger()
+}
+
+// changed reports whether an update can change the findings
+func changed(oldObj, newObj interface{}) bool {
+	switch n := newObj.(type) {
+	case *appsv1.Deployment:
+		o, ok := oldObj.(*appsv1.Deployment)
+		return !ok || o.Generation != n.Generation
+	case *corev1.Pod:
+		o, ok := oldObj.(*corev1.Pod)
+		return !ok || o.Status.Phase != n.Status.Phase ||
+			!reflect.DeepEqual(o.Status.ContainerStatuses, n.Status.ContainerStatuses) ||
+			!reflect.DeepEqual(o.Status.InitContainerStatuses, n.Status.InitContainerStatuses)
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package securitydrift
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// newTestDetector returns a detector reading the given objects as its informer caches
+func newTestDetector(t *testing.T, objects ...interface{}) *SecurityDetector {
+	deployments := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objects {
+		store := deployments
+		if _, ok := obj.(*corev1.Pod); ok {
+			store = pods
+		}
+		if err := store.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Namespace = "payments"
+	return &SecurityDetector{
+		config:      cfg,
+		spaceSlug:   "security-test",
+		deployments: appslisters.NewDeploymentLister(deployments),
+		pods:        corelisters.NewPodLister(pods),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	live := hardened()
+	yes := true
+	live.Containers[0].SecurityContext.Privileged = &yes
+	stale := pod("api-0", map[string]string{"api": "sha256:0000"})
+	stale.Status.Phase = corev1.PodSucceeded
+	d := newTestDetector(t, deployment(live), pod("api-1", map[string]string{"api": "sha256:1111"}), stale)
+
+	unitID := uuid.New()
+	desired := deployment(hardened())
+	desired.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}
+	unitData, err := yaml.Marshal(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := []*sdk.Unit{
+		{UnitID: unitID, Slug: "payments-api", Data: string(unitData)},
+		{Slug: "payments-svc", Data: "apiVersion: v1\nkind: Service\nmetadata:\n  name: api\n"},
+		{Slug: "not-deployed", Data: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: worker\n"},
+		{Slug: "broken", Data: "{"},
+	}
+
+	findings, checked := d.evaluate(units)
+	if checked != 1 {
+		t.Errorf("checked %d units, want only payments-api", checked)
+	}
+	if len(findings) != 1 || findings[0].Check != CheckPrivileged || findings[0].UnitID != unitID || findings[0].UnitSlug != "payments-api" {
+		t.Errorf("findings = %+v, want payments-api privileged (the succeeded pod's digest ignored)", findings)
+	}
+}
+
+func TestHandleReport(t *testing.T) {
+	d := newTestDetector(t)
+
+	rec := httptest.NewRecorder()
+	d.handleReport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/security", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the first detection, got %d", rec.Code)
+	}
+
+	d.recordReport([]Finding{
+		{UnitSlug: "payments-api", Check: CheckPrivileged, Severity: SeverityCritical},
+		{UnitSlug: "payments-api", Check: CheckImage, Severity: SeverityHigh},
+		{UnitSlug: "payments-api", Check: CheckImageDigest, Severity: SeverityMedium},
+	}, 2)
+	d.markDegraded("ConfigHub unavailable")
+
+	rec = httptest.NewRecorder()
+	d.handleReport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/security?severity=high", nil))
+	var report SecurityReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Space != "security-test" || report.Deployments != 2 || report.Degraded == "" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.Findings) != 2 || report.BySeverity[SeverityMedium] != 1 {
+		t.Errorf("Expected critical and high findings of all three counted, got %+v", report)
+	}
+
+	for _, tc := range []struct {
+		method, url string
+		want        int
+	}{
+		{http.MethodGet, "/api/v1/security?severity=low", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/security", http.StatusMethodNotAllowed},
+	} {
+		rec = httptest.NewRecorder()
+		d.handleReport(rec, httptest.NewRequest(tc.method, tc.url, nil))
+		if rec.Code != tc.want {
+			t.Errorf("%s %s = %d, want %d", tc.method, tc.url, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestEventHandler(t *testing.T) {
+	d := newTestDetector(t)
+	h := &eventHandler{detector: d}
+	triggered := func() bool {
+		select {
+		case <-d.wake:
+			return true
+		default:
+			return false
+		}
+	}
+
+	old := deployment(hardened())
+	resync := old.DeepCopy()
+	h.OnUpdate(old, resync)
+	h.OnAdd(old, true)
+	if triggered() {
+		t.Error("resync or initial list triggered a detection")
+	}
+
+	edited := old.DeepCopy()
+	edited.Generation++
+	h.OnUpdate(old, edited)
+	h.OnUpdate(old, edited) // coalesced
+	if !triggered() || triggered() {
+		t.Error("spec change did not trigger exactly one detection")
+	}
+
+	running := pod("api-1", map[string]string{"api": "sha256:1111"})
+	repulled := pod("api-1", map[string]string{"api": "sha256:2222"})
+	h.OnUpdate(running, repulled)
+	if !triggered() {
+		t.Error("new image digest did not trigger a detection")
+	}
+}
@@ -0,0 +1,42 @@
+package slomonitor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// newAuditLog returns a log reading the entries the other apps wrote to the
+// space with slug space. The monitor changes nothing, so it records nothing.
+func newAuditLog(cub *sdk.ConfigHubClient, limit *ratelimit.Limiter, space string) *audit.Log {
+	if cub == nil || space == "" {
+		return audit.New("slo-monitor", nil, nil)
+	}
+	reader := func(ctx context.Context, where string) ([]string, error) {
+		spaces, err := ratelimit.Call(ctx, limit, "ListSpaces", "all", cub.ListSpaces)
+		if err != nil {
+			return nil, fmt.Errorf("list spaces: %w", err)
+		}
+		for _, s := range spaces {
+			if s.Slug != space {
+				continue
+			}
+			units, err := ratelimit.Call(ctx, limit, "ListUnits", s.SpaceID.String()+"/"+where, func() ([]*sdk.Unit, error) {
+				return cub.ListUnits(sdk.ListUnitsParams{SpaceID: s.SpaceID, Where: where})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("list units: %w", err)
+			}
+			data := make([]string, 0, len(units))
+			for _, u := range units {
+				data = append(data, u.Data)
+			}
+			return data, nil
+		}
+		return nil, fmt.Errorf("audit space %s not found", space)
+	}
+	return audit.New("slo-monitor", nil, reader)
+}
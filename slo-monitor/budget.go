@@ -0,0 +1,73 @@
+package slomonitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Budget statuses, most urgent first
+const (
+	StatusExhausted = "exhausted" // the window's error budget is spent
+	StatusBurning   = "burning"   // a burn rate reached fast_burn or slow_burn
+	StatusNoData    = "no-data"   // the SLI has no value or could not be queried
+	StatusOK        = "ok"
+)
+
+var statusRank = map[string]int{StatusExhausted: 0, StatusBurning: 1, StatusNoData: 2, StatusOK: 3}
+
+// Budget is the error budget of an SLO. A burn rate of 1 spends exactly the
+// budget over the SLO's window; 14.4 spends 2% of a 30 day budget an hour.
+type Budget struct {
+	SLO
+	SLI       float64 `json:"sli"`       // over the window
+	Remaining float64 `json:"remaining"` // share of the budget left, negative once overspent
+	Burn1h    float64 `json:"burn_1h"`
+	Burn6h    float64 `json:"burn_6h"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	// Changes to the service over the lookback, newest first
+	Changes []Change `json:"changes"`
+}
+
+// burnRate is the SLO's burn rate over the d before at; ok is false without data
+func burnRate(ctx context.Context, q querier, slo SLO, d time.Duration, at time.Time) (float64, bool, error) {
+	sli, ok, err := q.Query(ctx, slo.query(d), at)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	return (1 - sli) / (1 - slo.Objective), true, nil
+}
+
+// evaluate reads an SLO's budget and burn rates at now
+func evaluate(ctx context.Context, q querier, slo SLO, cfg Config, now time.Time) Budget {
+	b := Budget{SLO: slo, Status: StatusNoData, Changes: []Change{}}
+	sli, ok, err := q.Query(ctx, slo.query(slo.Window), now)
+	if err != nil {
+		b.Error = err.Error()
+		return b
+	}
+	if !ok {
+		return b
+	}
+	b.SLI = sli
+	b.Remaining = 1 - (1-sli)/(1-slo.Objective)
+
+	for _, w := range []struct {
+		d    time.Duration
+		into *float64
+	}{{time.Hour, &b.Burn1h}, {6 * time.Hour, &b.Burn6h}} {
+		if *w.into, _, err = burnRate(ctx, q, slo, w.d, now); err != nil {
+			b.Error = fmt.Sprintf("burn rate over %s: %v", promDuration(w.d), err)
+		}
+	}
+	switch {
+	case b.Remaining <= 0:
+		b.Status = StatusExhausted
+	case b.Burn1h >= cfg.FastBurn, b.Burn6h >= cfg.SlowBurn:
+		b.Status = StatusBurning
+	default:
+		b.Status = StatusOK
+	}
+	return b
+}
@@ -0,0 +1,142 @@
+package slomonitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/audit"
+	sdk "github.com/monadic/devops-sdk"
+	"sigs.k8s.io/yaml"
+)
+
+// Where a change was found
+const (
+	SourceConfigHub = "confighub" // a unit's latest revision
+	SourceAudit     = "audit"     // an action another app recorded
+)
+
+// unitUpdated is the action of a change found on a unit
+const unitUpdated = "unit.updated"
+
+// crossLinked are the audit actions that change a service's units
+var crossLinked = map[string]bool{audit.FixApplied: true, audit.OptimizationApplied: true}
+
+// Change is a change to a service, with the SLO's burn rate over
+// compare_window before and after it
+type Change struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	Action   string    `json:"action"`        // unit.updated, fix.applied or optimization.applied
+	App      string    `json:"app,omitempty"` // that recorded the action
+	Actor    string    `json:"actor,omitempty"`
+	Space    string    `json:"space,omitempty"`
+	Unit     string    `json:"unit"`
+	Revision int64     `json:"revision,omitempty"`
+
+	BurnBefore float64 `json:"burn_before"`
+	BurnAfter  float64 `json:"burn_after"`
+	// The compare window after the change has not passed yet; BurnAfter
+	// covers the time since
+	Pending    bool `json:"pending,omitempty"`
+	Regression bool `json:"regression"`
+	// A cost optimization, such as a right-sizing
+	Optimization bool `json:"optimization"`
+}
+
+// serviceUnit is a unit describing a service's resources
+type serviceUnit struct {
+	Space     string
+	Slug      string
+	Revision  int64
+	UpdatedAt time.Time
+}
+
+// serviceUnits indexes the units of a space by the namespace/name of the
+// resource they describe, a service's Deployment, Service, HPA and so on
+func serviceUnits(space string, units []*sdk.Unit, defaultNamespace string, into map[string][]serviceUnit) {
+	for _, u := range units {
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(u.Data), &obj); err != nil || obj.Kind == "" || obj.Metadata.Name == "" {
+			continue
+		}
+		ns := obj.Metadata.Namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		key := ns + "/" + obj.Metadata.Name
+		into[key] = append(into[key], serviceUnit{Space: space, Slug: u.Slug, Revision: u.HeadRevisionNum, UpdatedAt: u.UpdatedAt})
+	}
+}
+
+// changesOf returns the changes to an SLO's service since since: the
+// latest update of each of its units, and the fixes and optimizations
+// recorded on them. An update within a minute after a recorded action on
+// the same unit is that action.
+func changesOf(slo SLO, units map[string][]serviceUnit, entries []audit.Entry, since time.Time) []Change {
+	var changes []Change
+	recorded := map[string][]time.Time{} // unit slug -> action times
+	slugs := map[string]bool{}
+	for _, u := range units[slo.Key()] {
+		slugs[u.Slug] = true
+	}
+	for _, e := range entries {
+		if !crossLinked[e.Action] || !slugs[e.Target] || e.Result != "ok" || e.Time.Before(since) {
+			continue
+		}
+		recorded[e.Target] = append(recorded[e.Target], e.Time)
+		changes = append(changes, Change{
+			Time: e.Time, Source: SourceAudit, Action: e.Action, App: e.App, Actor: e.Actor, Unit: e.Target,
+			Optimization: e.Action == audit.OptimizationApplied,
+		})
+	}
+	for _, u := range units[slo.Key()] {
+		if u.UpdatedAt.Before(since) || u.UpdatedAt.IsZero() {
+			continue
+		}
+		duplicate := false
+		for _, t := range recorded[u.Slug] {
+			if d := u.UpdatedAt.Sub(t); d >= 0 && d <= time.Minute {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			changes = append(changes, Change{Time: u.UpdatedAt, Source: SourceConfigHub, Action: unitUpdated, Space: u.Space, Unit: u.Slug, Revision: u.Revision})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Time.After(changes[j].Time) })
+	return changes
+}
+
+// compare sets the burn rate over compare_window before and after each
+// change and flags the regressions: a burn rate after that reaches
+// regression_burn and at least doubles the one before
+func compare(ctx context.Context, q querier, slo SLO, changes []Change, cfg Config, now time.Time) error {
+	for i := range changes {
+		c := &changes[i]
+		before, _, err := burnRate(ctx, q, slo, cfg.CompareWindow, c.Time)
+		if err != nil {
+			return fmt.Errorf("burn rate before %s: %w", c.Unit, err)
+		}
+		end, window := c.Time.Add(cfg.CompareWindow), cfg.CompareWindow
+		if end.After(now) {
+			end, window, c.Pending = now, now.Sub(c.Time).Truncate(time.Second), true
+		}
+		var after float64
+		if window >= time.Second {
+			if after, _, err = burnRate(ctx, q, slo, window, end); err != nil {
+				return fmt.Errorf("burn rate after %s: %w", c.Unit, err)
+			}
+		}
+		c.BurnBefore, c.BurnAfter = before, after
+		c.Regression = after >= cfg.RegressionBurn && after >= 2*before
+	}
+	return nil
+}
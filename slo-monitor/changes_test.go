@@ -0,0 +1,215 @@
+package slomonitor
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+var checkout = SLO{Service: "checkout", Namespace: "shop", Objective: 0.999, Window: 30 * 24 * time.Hour, SLI: "good[$range]"}
+
+// rangeProm answers an SLI query by the range in it
+type rangeProm map[string]float64
+
+func (r rangeProm) Query(_ context.Context, query string, _ time.Time) (float64, bool, error) {
+	for rng, v := range r {
+		if strings.Contains(query, "["+rng+"]") {
+			return v, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// timeProm answers any SLI query at or after a change with after, and
+// before it with before
+type timeProm struct {
+	change        time.Time
+	before, after float64
+}
+
+func (p timeProm) Query(_ context.Context, _ string, at time.Time) (float64, bool, error) {
+	if at.After(p.change) {
+		return p.after, true, nil
+	}
+	return p.before, true, nil
+}
+
+func TestEvaluate(t *testing.T) {
+	cfg := DefaultConfig() // fast burn 14.4 over 1h, slow 6 over 6h
+	for _, tc := range []struct {
+		name      string
+		sli       rangeProm
+		status    string
+		remaining float64
+	}{
+		{name: "healthy", sli: rangeProm{"30d": 0.9995, "1h": 0.9995, "6h": 0.9995}, status: StatusOK, remaining: 0.5},
+		{name: "fast burn", sli: rangeProm{"30d": 0.9992, "1h": 0.98, "6h": 0.9995}, status: StatusBurning, remaining: 0.2},
+		{name: "slow burn", sli: rangeProm{"30d": 0.9992, "1h": 0.999, "6h": 0.993}, status: StatusBurning, remaining: 0.2},
+		{name: "exhausted", sli: rangeProm{"30d": 0.998, "1h": 0.999, "6h": 0.999}, status: StatusExhausted, remaining: -1},
+		{name: "no data", sli: rangeProm{}, status: StatusNoData},
+	} {
+		b := evaluate(context.Background(), tc.sli, checkout, cfg, time.Now())
+		if b.Status != tc.status || math.Abs(b.Remaining-tc.remaining) > 1e-6 {
+			t.Errorf("%s: status %s with %g left, want %s with %g", tc.name, b.Status, b.Remaining, tc.status, tc.remaining)
+		}
+	}
+	b := evaluate(context.Background(), rangeProm{"30d": 0.9992, "1h": 0.98, "6h": 0.9995}, checkout, cfg, time.Now())
+	if math.Abs(b.Burn1h-20) > 1e-6 || math.Abs(b.Burn6h-0.5) > 1e-6 {
+		t.Errorf("burn rates %g and %g, want 20 and 0.5", b.Burn1h, b.Burn6h)
+	}
+}
+
+func TestChangesOf(t *testing.T) {
+	now := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	units := map[string][]serviceUnit{}
+	serviceUnits("prod", []*sdk.Unit{
+		{UnitID: uuid.New(), Slug: "checkout-deploy", HeadRevisionNum: 7, UpdatedAt: now.Add(-2 * time.Hour),
+			Data: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: checkout\n  namespace: shop\n"},
+		{UnitID: uuid.New(), Slug: "checkout-hpa", HeadRevisionNum: 3, UpdatedAt: now.Add(-5*time.Hour + 30*time.Second),
+			Data: "apiVersion: autoscaling/v2\nkind: HorizontalPodAutoscaler\nmetadata:\n  name: checkout\n  namespace: shop\n"},
+		{UnitID: uuid.New(), Slug: "checkout-old", UpdatedAt: now.Add(-48 * time.Hour),
+			Data: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: checkout\n  namespace: shop\n"},
+		{UnitID: uuid.New(), Slug: "search", UpdatedAt: now.Add(-time.Hour),
+			Data: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: search\n  namespace: shop\n"},
+	}, "default", units)
+	entries := []audit.Entry{
+		{Time: now.Add(-5 * time.Hour), App: "cost-optimizer", Actor: "system", Action: audit.OptimizationApplied, Target: "checkout-hpa", Result: "ok"},
+		{Time: now.Add(-3 * time.Hour), App: "drift-detector", Actor: "system", Action: audit.FixApplied, Target: "checkout-deploy", Result: "error"},
+		{Time: now.Add(-time.Hour), App: "cost-optimizer", Action: audit.OptimizationApplied, Target: "search", Result: "ok"},
+		{Time: now.Add(-time.Hour), App: "cost-impact-monitor", Action: audit.UnitCreated, Target: "checkout-deploy", Result: "ok"},
+	}
+
+	var got []string
+	for _, c := range changesOf(checkout, units, entries, now.Add(-24*time.Hour)) {
+		got = append(got, c.Action+" "+c.Unit)
+	}
+	// the HPA's update is the optimization's; the failed fix changed nothing
+	want := []string{"unit.updated checkout-deploy", "optimization.applied checkout-hpa"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	cfg := DefaultConfig() // compare 1h, regression at burn 2 and double
+	now := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	change := now.Add(-3 * time.Hour)
+
+	for _, tc := range []struct {
+		name          string
+		before, after float64 // SLIs
+		regression    bool
+	}{
+		{name: "right-sizing broke it", before: 0.9995, after: 0.996, regression: true},
+		{name: "already burning", before: 0.997, after: 0.996},
+		{name: "harmless", before: 0.9995, after: 0.9992},
+	} {
+		changes := []Change{{Time: change, Action: audit.OptimizationApplied, Unit: "checkout-deploy", Optimization: true}}
+		if err := compare(context.Background(), timeProm{change, tc.before, tc.after}, checkout, changes, cfg, now); err != nil {
+			t.Fatal(err)
+		}
+		if changes[0].Regression != tc.regression || changes[0].Pending {
+			t.Errorf("%s: got %+v", tc.name, changes[0])
+		}
+	}
+
+	// a change 10 minutes ago is compared over those 10 minutes
+	changes := []Change{{Time: now.Add(-10 * time.Minute), Unit: "checkout-deploy"}}
+	if err := compare(context.Background(), timeProm{now.Add(-10 * time.Minute), 0.9995, 0.99}, checkout, changes, cfg, now); err != nil {
+		t.Fatal(err)
+	}
+	if !changes[0].Pending || !changes[0].Regression {
+		t.Errorf("recent change: got %+v", changes[0])
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := &Monitor{config: DefaultConfig(), metrics: metrics.New("slo-monitor", version, "test")}
+	handler := m.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/slos", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first check: status %d, want 503", rec.Code)
+	}
+
+	now := time.Now()
+	search := SLO{Service: "search", Namespace: "shop", Objective: 0.99, Window: 30 * 24 * time.Hour}
+	m.report = &Report{
+		CheckedAt: now,
+		Budgets: []Budget{
+			{SLO: checkout, Status: StatusBurning, Changes: []Change{
+				{Time: now.Add(-time.Hour), Action: audit.OptimizationApplied, Unit: "checkout-deploy", Optimization: true, Regression: true},
+				{Time: now.Add(-3 * time.Hour), Action: unitUpdated, Unit: "checkout-hpa"},
+			}},
+			{SLO: search, Status: StatusOK, Changes: []Change{
+				{Time: now.Add(-2 * time.Hour), Action: audit.OptimizationApplied, Unit: "search", Optimization: true},
+			}},
+		},
+		ByStatus:    map[string]int{StatusBurning: 1, StatusOK: 1},
+		Regressions: 1,
+	}
+
+	for _, tc := range []struct {
+		path string
+		code int
+		want []string // services of budgets, or units of changes
+	}{
+		{path: "/api/v1/slos", code: http.StatusOK, want: []string{"checkout", "search"}},
+		{path: "/api/v1/slos?status=ok", code: http.StatusOK, want: []string{"search"}},
+		{path: "/api/v1/slos?status=fine", code: http.StatusBadRequest},
+		{path: "/api/v1/changes", code: http.StatusOK, want: []string{"checkout-deploy", "search", "checkout-hpa"}},
+		{path: "/api/v1/changes?optimization=true&regression=false", code: http.StatusOK, want: []string{"search"}},
+		{path: "/api/v1/changes?regression=true", code: http.StatusOK, want: []string{"checkout-deploy"}},
+		{path: "/api/v1/changes?service=checkout", code: http.StatusOK, want: []string{"checkout-deploy", "checkout-hpa"}},
+		{path: "/api/v1/changes?regression=maybe", code: http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.path, rec.Code, tc.code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var got []string
+		if strings.HasPrefix(tc.path, "/api/v1/slos") {
+			var report Report
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			for _, b := range report.Budgets {
+				got = append(got, b.Service)
+			}
+		} else {
+			var changes []ServiceChange
+			if err := json.NewDecoder(rec.Body).Decode(&changes); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range changes {
+				got = append(got, c.Unit)
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.path, got, tc.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("dashboard: status %d: %s", rec.Code, rec.Body)
+	}
+}
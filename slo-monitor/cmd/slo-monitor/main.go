@@ -0,0 +1,10 @@
+// Command slo-monitor tracks the error budgets of services' SLOs and
+// cross-links their burn with the ConfigHub changes and cost optimizations
+// made to them. The same app runs as "devops-apps slo".
+package main
+
+import slomonitor "github.com/monadic/devops-examples/slo-monitor"
+
+func main() {
+	slomonitor.Main()
+}
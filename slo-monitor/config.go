@@ -0,0 +1,120 @@
+package slomonitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/config"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+// Config holds the SLO monitor's settings. They are read from CONFIG_FILE
+// (default /etc/slo-monitor/config.yaml); each can be overridden by the
+// environment variable in its env tag.
+type Config struct {
+	CubAPIURL   string `yaml:"cub_api_url" env:"CUB_API_URL"`
+	CubToken    string `yaml:"cub_token" env:"CUB_TOKEN" secret:"true"`
+	SecretsDir  string `yaml:"secrets_dir" env:"SECRETS_DIR"` // token files (cub-token) overriding the above
+	Port        int    `yaml:"slo_port" env:"SLO_PORT"`
+	AuthConfig  string `yaml:"auth_config" env:"AUTH_CONFIG"`   // OIDC login for the dashboard; a missing file leaves it open
+	ClusterName string `yaml:"cluster_name" env:"CLUSTER_NAME"` // cluster label of the /metrics samples
+
+	// Prometheus the SLIs are queried from, and the file defining the SLOs
+	PrometheusURL string `yaml:"prometheus_url" env:"PROMETHEUS_URL"`
+	SLOsConfig    string `yaml:"slos_config" env:"SLOS_CONFIG"`
+
+	// Changes cross-linked with the budgets: updates of the units of
+	// cub_spaces describing a service, and the fixes and optimizations the
+	// other apps recorded in audit_space, over the last lookback
+	Spaces     []string      `yaml:"cub_spaces" env:"CUB_SPACES"`
+	Namespace  string        `yaml:"namespace" env:"NAMESPACE"` // of units that don't name one
+	AuditSpace string        `yaml:"audit_space" env:"AUDIT_SPACE"`
+	Lookback   time.Duration `yaml:"lookback" env:"LOOKBACK"`
+	// A change is a regression when the burn rate over compare_window after
+	// it reaches regression_burn and at least doubles the rate before
+	CompareWindow  time.Duration `yaml:"compare_window" env:"COMPARE_WINDOW"`
+	RegressionBurn float64       `yaml:"regression_burn" env:"REGRESSION_BURN"`
+	// Burn rates over the last hour and six hours at which a budget is
+	// burning; the defaults spend 2% and 5% of a 30 day budget
+	FastBurn    float64       `yaml:"fast_burn" env:"FAST_BURN"`
+	SlowBurn    float64       `yaml:"slow_burn" env:"SLOW_BURN"`
+	RunInterval time.Duration `yaml:"run_interval" env:"RUN_INTERVAL"`
+
+	CubRateLimit float64 `yaml:"cub_rate_limit" env:"CUB_RATE_LIMIT"` // ConfigHub reads per second; 0 is unlimited
+	CubRateBurst int     `yaml:"cub_rate_burst" env:"CUB_RATE_BURST"`
+	// Consecutive ConfigHub failures before calls fail fast, and how long
+	// until a probe call is let through
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+}
+
+// DefaultConfig returns the settings used when neither file nor environment
+// sets them
+func DefaultConfig() Config {
+	return Config{
+		CubAPIURL:  "https://hub.confighub.com/api",
+		Port:       8091,
+		AuthConfig: "/etc/slo-monitor/auth.yaml",
+
+		PrometheusURL: "http://prometheus.monitoring.svc:9090",
+		SLOsConfig:    "/etc/slo-monitor/slos.yaml",
+
+		Namespace:      "default",
+		Lookback:       24 * time.Hour,
+		CompareWindow:  time.Hour,
+		RegressionBurn: 2,
+		FastBurn:       14.4,
+		SlowBurn:       6,
+		RunInterval:    time.Minute,
+
+		CubRateLimit:     5,
+		CubRateBurst:     10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Validate checks settings that would otherwise fail later at runtime
+func (c *Config) Validate() error {
+	if c.PrometheusURL == "" {
+		return fmt.Errorf("prometheus_url is required")
+	}
+	if c.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("slo_port %d is not a valid port", c.Port)
+	}
+	if c.Lookback <= 0 || c.CompareWindow <= 0 {
+		return fmt.Errorf("lookback and compare_window must be positive, got %s and %s", c.Lookback, c.CompareWindow)
+	}
+	if c.RegressionBurn <= 0 || c.FastBurn <= 0 || c.SlowBurn <= 0 {
+		return fmt.Errorf("regression_burn, fast_burn and slow_burn must be positive, got %g, %g and %g", c.RegressionBurn, c.FastBurn, c.SlowBurn)
+	}
+	if c.RunInterval <= 0 {
+		return fmt.Errorf("run_interval must be positive, got %s", c.RunInterval)
+	}
+	if c.CubRateLimit < 0 {
+		return fmt.Errorf("cub_rate_limit must not be negative, got %g", c.CubRateLimit)
+	}
+	if c.CubRateBurst < 1 {
+		return fmt.Errorf("cub_rate_burst must be at least 1, got %d", c.CubRateBurst)
+	}
+	if c.BreakerThreshold < 1 {
+		return fmt.Errorf("breaker_threshold must be at least 1, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive, got %s", c.BreakerCooldown)
+	}
+	return nil
+}
+
+// loadConfig reads the config file named by CONFIG_FILE plus environment overrides
+func loadConfig() (Config, *config.Effective, error) {
+	cfg := DefaultConfig()
+	effective, err := config.Load(sdk.GetEnvOrDefault("CONFIG_FILE", "/etc/slo-monitor/config.yaml"), &cfg)
+	if err != nil {
+		return cfg, effective, err
+	}
+	return cfg, effective, effective.ReadSecrets(cfg.SecretsDir, &cfg)
+}
@@ -0,0 +1,167 @@
+package slomonitor
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+)
+
+// webFiles holds the dashboard page template
+//
+//go:embed web/index.html.tmpl
+var webFiles embed.FS
+
+var dashboardTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.2f%%", f*100) },
+	"burn":    func(f float64) string { return fmt.Sprintf("%.1fx", f) },
+	"time":    func(t time.Time) string { return t.Format("Jan 2 15:04") },
+	"ago": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).ParseFS(webFiles, "web/index.html.tmpl"))
+
+// ServiceChange is a change listed by GET /api/v1/changes
+type ServiceChange struct {
+	Service   string `json:"service"`
+	Namespace string `json:"namespace"`
+	Change
+}
+
+// handler serves the dashboard, its API under /api/v1 and /metrics
+func (m *Monitor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		report := m.Report()
+		if report == nil {
+			http.Error(w, "SLOs have not been checked yet", http.StatusServiceUnavailable)
+			return
+		}
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	})
+	v1 := api.New(mux, "slo-monitor")
+	v1.HandleFunc("/slos", m.handleSLOs, api.Operation{
+		Summary:  "Latest SLO report",
+		Query:    []api.Param{{Name: "status", Description: "exhausted, burning, no-data or ok"}},
+		Response: Report{},
+	})
+	v1.HandleFunc("/changes", m.handleChanges, api.Operation{
+		Summary: "Changes of every service, newest first",
+		Query: []api.Param{
+			{Name: "regression", Description: "true for those followed by a regression"},
+			{Name: "optimization", Description: "true for cost optimizations"},
+			{Name: "service", Description: "one service's"},
+		},
+		Response: []ServiceChange{},
+	})
+	v1.Handle("/audit", m.audit, audit.Operations...)
+	v1.Handle("/breakers", breaker.Handler(m.cubBreaker), breaker.Operations...)
+	mux.HandleFunc("/health", health.Live)
+	m.health.Mount(mux)
+	mux.Handle("/metrics", m.metrics)
+	return mux
+}
+
+// handleSLOs serves the latest report as JSON, filtered by ?status=
+func (m *Monitor) handleSLOs(w http.ResponseWriter, r *http.Request) {
+	report, ok := m.latest(w, r)
+	if !ok {
+		return
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		if _, ok := statusRank[status]; !ok {
+			http.Error(w, "status must be exhausted, burning, no-data or ok", http.StatusBadRequest)
+			return
+		}
+		filtered := *report
+		filtered.Budgets = []Budget{}
+		for _, b := range report.Budgets {
+			if b.Status == status {
+				filtered.Budgets = append(filtered.Budgets, b)
+			}
+		}
+		report = &filtered
+	}
+	writeJSON(w, report)
+}
+
+// handleChanges lists the changes of every service, newest first:
+// ?regression=true keeps those followed by a regression,
+// ?optimization=true the cost optimizations, ?service= one service's
+func (m *Monitor) handleChanges(w http.ResponseWriter, r *http.Request) {
+	report, ok := m.latest(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	flags := map[string]*bool{}
+	for _, name := range []string{"regression", "optimization"} {
+		if v := query.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, name+" must be true or false", http.StatusBadRequest)
+				return
+			}
+			flags[name] = &b
+		}
+	}
+
+	changes := []ServiceChange{}
+	for _, b := range report.Budgets {
+		if s := query.Get("service"); s != "" && s != b.Service {
+			continue
+		}
+		for _, c := range b.Changes {
+			if f := flags["regression"]; f != nil && c.Regression != *f {
+				continue
+			}
+			if f := flags["optimization"]; f != nil && c.Optimization != *f {
+				continue
+			}
+			changes = append(changes, ServiceChange{Service: b.Service, Namespace: b.Namespace, Change: c})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Time.After(changes[j].Time) })
+	writeJSON(w, changes)
+}
+
+// latest returns the latest report of a GET, or writes why there is none
+func (m *Monitor) latest(w http.ResponseWriter, r *http.Request) (*Report, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	report := m.Report()
+	if report == nil {
+		http.Error(w, "SLOs have not been checked yet", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	return report, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,290 @@
+// Package slomonitor tracks the error budgets of services' SLOs from
+// Prometheus SLIs and cross-links their burn with the ConfigHub changes and
+// cost optimizations made to the services, so a team can see whether a
+// right-sizing was followed by an SLO regression.
+package slomonitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/audit"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+const version = "1.0.0"
+
+// Report is the result of the latest check, served at GET /api/v1/slos
+type Report struct {
+	CheckedAt   time.Time      `json:"checked_at"`
+	Budgets     []Budget       `json:"budgets"` // most urgent first
+	ByStatus    map[string]int `json:"by_status"`
+	Regressions int            `json:"regressions"` // changes followed by a regression
+	// Why changes may be missing: units or audit entries not read
+	Degraded []string `json:"degraded,omitempty"`
+}
+
+type Monitor struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	slos       []SLO
+	prometheus querier
+	audit      *audit.Log // read only: the other apps' actions
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu     sync.RWMutex
+	report *Report
+}
+
+// Main checks the SLOs every run_interval until interrupted, or once with
+// --mode=job. It is the entry point of cmd/slo-monitor and of "devops-apps
+// slo".
+func Main() {
+	logger := logging.Setup("slo-monitor")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+	slos, err := loadSLOs(cfg.SLOsConfig, cfg.Namespace)
+	if err != nil {
+		logging.Fatal("Failed to load SLOs", logging.Err(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "slo-monitor", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "slo-monitor",
+		Version:     version,
+		Description: "Tracks SLO error budgets against ConfigHub changes",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	cubLimit := ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker)
+	monitor := &Monitor{
+		app:        app,
+		config:     cfg,
+		slos:       slos,
+		prometheus: newPromClient(cfg.PrometheusURL),
+		audit:      newAuditLog(app.Cub, cubLimit, cfg.AuditSpace),
+		metrics:    metrics.New("slo-monitor", version, cfg.ClusterName),
+		cubLimit:   cubLimit,
+		cubBreaker: cubBreaker,
+	}
+	monitor.metrics.Collect(metrics.Limiter(cubLimit))
+	monitor.metrics.Collect(metrics.ListCache(listcache.Install()))
+	monitor.metrics.Collect(metrics.Breakers(cubBreaker))
+	monitor.health = health.New()
+	monitor.health.Register("confighub", health.Optional, health.Breaker(cubBreaker))
+	monitor.health.Register("prometheus", health.Required, health.HTTP(nil, strings.TrimSuffix(cfg.PrometheusURL, "/")+"/-/ready"))
+	monitor.metrics.Collect(monitor.collect)
+	slog.Info("SLOs loaded", "slos", len(slos), "prometheus", cfg.PrometheusURL)
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, monitor.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "slo-monitor", monitor.check, monitor.Report, os.Stdout, store); err != nil {
+			logging.Fatal("SLO check failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("SLO dashboard listening", "addr", addr)
+	group.Serve(addr, guard.Protect(monitor.handler(), "/metrics", "/health"))
+	group.Go(monitor.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("SLO dashboard stopped", logging.Err(err))
+	}
+}
+
+// run checks now and every run_interval until interrupted
+func (m *Monitor) run(ctx context.Context) error {
+	ticker := time.NewTicker(m.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.check(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("SLO check failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check reads each SLO's budget and the changes to its service. Without
+// the units or the audit entries the budgets are still read, with the
+// changes found.
+func (m *Monitor) check(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "slo.check")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := m.metrics.Cycle("check", "")
+	defer func() { cycleDone(err) }()
+
+	now := time.Now()
+	report := &Report{CheckedAt: now, ByStatus: map[string]int{}}
+	for status := range statusRank {
+		report.ByStatus[status] = 0
+	}
+	units := map[string][]serviceUnit{}
+	if len(m.config.Spaces) > 0 {
+		if units, err = m.units(ctx); err != nil {
+			report.Degraded = append(report.Degraded, fmt.Sprintf("unit changes not read: %v", err))
+		}
+	}
+	var entries []audit.Entry
+	if m.config.AuditSpace != "" {
+		var source string
+		entries, source, err = m.audit.Entries(ctx, audit.Query{Since: now.Add(-m.config.Lookback)})
+		if err == nil && source != "confighub" {
+			err = fmt.Errorf("audit space %s could not be read", m.config.AuditSpace)
+		}
+		if err != nil {
+			report.Degraded = append(report.Degraded, fmt.Sprintf("fixes and optimizations not read: %v", err))
+		}
+	}
+	for _, msg := range report.Degraded {
+		slog.Warn("Checking SLOs without all changes", "reason", msg)
+	}
+
+	for _, slo := range m.slos {
+		b := evaluate(ctx, m.prometheus, slo, m.config, now)
+		b.Changes = changesOf(slo, units, entries, now.Add(-m.config.Lookback))
+		if err := compare(ctx, m.prometheus, slo, b.Changes, m.config, now); err != nil && b.Error == "" {
+			b.Error = err.Error()
+		}
+		if b.Changes == nil {
+			b.Changes = []Change{}
+		}
+		for _, c := range b.Changes {
+			if c.Regression {
+				report.Regressions++
+			}
+		}
+		report.ByStatus[b.Status]++
+		report.Budgets = append(report.Budgets, b)
+	}
+	sort.SliceStable(report.Budgets, func(i, j int) bool {
+		a, b := report.Budgets[i], report.Budgets[j]
+		if statusRank[a.Status] != statusRank[b.Status] {
+			return statusRank[a.Status] < statusRank[b.Status]
+		}
+		return a.Remaining < b.Remaining
+	})
+
+	m.mu.Lock()
+	m.report = report
+	m.mu.Unlock()
+	slog.Info("SLOs checked", "slos", len(report.Budgets), "exhausted", report.ByStatus[StatusExhausted],
+		"burning", report.ByStatus[StatusBurning], "regressions", report.Regressions)
+	return nil
+}
+
+// units reads the units of the configured spaces by the resource they describe
+func (m *Monitor) units(ctx context.Context) (map[string][]serviceUnit, error) {
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, m.cubLimit, "ListSpaces", "all", m.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list spaces: %w", err)
+	}
+	ids := map[string]uuid.UUID{}
+	for _, s := range spaces {
+		ids[s.Slug] = s.SpaceID
+	}
+
+	units := map[string][]serviceUnit{}
+	for _, slug := range m.config.Spaces {
+		id, ok := ids[slug]
+		if !ok {
+			return nil, fmt.Errorf("space %s not found", slug)
+		}
+		list, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, m.cubLimit, "ListUnits", id.String(), func() ([]*sdk.Unit, error) {
+				return m.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: id})
+			})
+		}, tracing.SpaceKey.String(slug))
+		if err != nil {
+			return nil, fmt.Errorf("list units of %s: %w", slug, err)
+		}
+		serviceUnits(slug, list, m.config.Namespace, units)
+	}
+	return units, nil
+}
+
+// Report returns the latest report, nil before the first check
+func (m *Monitor) Report() *Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.report
+}
+
+// collect exports each SLO's budget, burn rates and regressions
+func (m *Monitor) collect(emit metrics.Emit) {
+	report := m.Report()
+	if report == nil {
+		return
+	}
+	for _, b := range report.Budgets {
+		if b.Status == StatusNoData {
+			continue
+		}
+		labels := metrics.Labels{"service": b.Service, "namespace": b.Namespace}
+		emit("slo_error_budget_remaining_ratio", "Share of the SLO window's error budget left, negative once overspent.", "gauge", labels, b.Remaining)
+		for window, burn := range map[string]float64{"1h": b.Burn1h, "6h": b.Burn6h} {
+			emit("slo_burn_rate", "Error budget burn rate; 1 spends the budget exactly over the SLO window.", "gauge",
+				metrics.Labels{"service": b.Service, "namespace": b.Namespace, "window": window}, burn)
+		}
+		regressions := 0
+		for _, c := range b.Changes {
+			if c.Regression {
+				regressions++
+			}
+		}
+		emit("slo_change_regressions", "Changes within the lookback followed by a burn rate regression.", "gauge", labels, float64(regressions))
+	}
+}
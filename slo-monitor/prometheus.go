@@ -0,0 +1,76 @@
+package slomonitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// querier evaluates a PromQL expression at a time. ok is false when it has
+// no value, as when nothing was measured.
+type querier interface {
+	Query(ctx context.Context, query string, at time.Time) (value float64, ok bool, err error)
+}
+
+// promClient queries the Prometheus HTTP API
+type promClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newPromClient(baseURL string) *promClient {
+	return &promClient{baseURL: strings.TrimSuffix(baseURL, "/"), http: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// Query returns the first sample of an instant query
+func (p *promClient) Query(ctx context.Context, query string, at time.Time) (float64, bool, error) {
+	params := url.Values{"query": {query}, "time": {strconv.FormatInt(at.Unix(), 10)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("query prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Value [2]interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return 0, false, fmt.Errorf("prometheus returned %s: %w", resp.Status, err)
+	}
+	if body.Status != "success" {
+		return 0, false, fmt.Errorf("prometheus: %s", body.Error)
+	}
+	if body.Data.ResultType != "vector" {
+		return 0, false, fmt.Errorf("prometheus returned a %s, want a vector", body.Data.ResultType)
+	}
+	if len(body.Data.Result) == 0 {
+		return 0, false, nil
+	}
+	s, _ := body.Data.Result[0].Value[1].(string)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("prometheus returned value %q: %w", s, err)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false, nil // no events: 0/0
+	}
+	return v, true, nil
+}
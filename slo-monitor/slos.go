@@ -0,0 +1,94 @@
+package slomonitor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SLO is the share of good events a service promises over a rolling window
+type SLO struct {
+	Service     string        `yaml:"service" json:"service"`
+	Namespace   string        `yaml:"namespace" json:"namespace"`
+	Description string        `yaml:"description" json:"description,omitempty"`
+	Objective   float64       `yaml:"objective" json:"objective"` // e.g. 0.999
+	Window      time.Duration `yaml:"window" json:"window"`       // default 720h
+	// PromQL ratio of good events to all events over $range, e.g.
+	// sum(rate(http_requests_total{job="checkout",code!~"5.."}[$range])) / sum(rate(http_requests_total{job="checkout"}[$range]))
+	SLI string `yaml:"sli" json:"sli"`
+}
+
+// loadSLOs reads the SLOs file at path:
+//
+//	slos:
+//	  - service: checkout
+//	    namespace: shop
+//	    objective: 0.999
+//	    sli: sum(rate(http_requests_total{job="checkout",code!~"5.."}[$range])) / sum(rate(http_requests_total{job="checkout"}[$range]))
+//
+// SLOs that don't name a namespace are in defaultNamespace.
+func loadSLOs(path, defaultNamespace string) ([]SLO, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read SLOs config: %w", err)
+	}
+	var file struct {
+		SLOs []SLO `yaml:"slos"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse SLOs config: %w", err)
+	}
+	if len(file.SLOs) == 0 {
+		return nil, fmt.Errorf("%s defines no SLOs", path)
+	}
+	seen := map[string]bool{}
+	for i := range file.SLOs {
+		s := &file.SLOs[i]
+		if s.Service == "" {
+			return nil, fmt.Errorf("SLO %d has no service", i)
+		}
+		if s.Namespace == "" {
+			s.Namespace = defaultNamespace
+		}
+		if s.Window == 0 {
+			s.Window = 30 * 24 * time.Hour
+		}
+		if seen[s.Key()] {
+			return nil, fmt.Errorf("SLO %s is defined twice", s.Key())
+		}
+		seen[s.Key()] = true
+		if s.Objective <= 0 || s.Objective >= 1 {
+			return nil, fmt.Errorf("SLO %s: objective must be between 0 and 1, got %g", s.Key(), s.Objective)
+		}
+		if !strings.Contains(s.SLI, "$range") {
+			return nil, fmt.Errorf("SLO %s: sli must use $range", s.Key())
+		}
+	}
+	return file.SLOs, nil
+}
+
+// Key names the SLO as namespace/service
+func (s SLO) Key() string {
+	return s.Namespace + "/" + s.Service
+}
+
+// query is the SLI over the d before the evaluation time
+func (s SLO) query(d time.Duration) string {
+	return strings.ReplaceAll(s.SLI, "$range", promDuration(d))
+}
+
+// promDuration writes d as a PromQL duration in its largest whole unit
+func promDuration(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
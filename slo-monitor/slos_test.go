@@ -0,0 +1,104 @@
+package slomonitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSLOs(t *testing.T) {
+	const sli = `sum(rate(ok[$range])) / sum(rate(all[$range]))`
+	for _, tc := range []struct {
+		name, file, err string
+	}{
+		{name: "valid", file: "slos:\n- service: checkout\n  namespace: shop\n  objective: 0.999\n  window: 168h\n  sli: " + sli + "\n- service: search\n  objective: 0.99\n  sli: " + sli + "\n"},
+		{name: "empty", file: "slos: []\n", err: "defines no SLOs"},
+		{name: "no service", file: "slos:\n- objective: 0.99\n  sli: " + sli + "\n", err: "has no service"},
+		{name: "objective", file: "slos:\n- service: a\n  objective: 99.9\n  sli: " + sli + "\n", err: "objective must be between 0 and 1"},
+		{name: "no range", file: "slos:\n- service: a\n  objective: 0.99\n  sli: up\n", err: "must use $range"},
+		{name: "twice", file: "slos:\n- service: a\n  objective: 0.99\n  sli: " + sli + "\n- service: a\n  namespace: default\n  objective: 0.9\n  sli: " + sli + "\n", err: "defined twice"},
+	} {
+		path := filepath.Join(t.TempDir(), "slos.yaml")
+		if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		slos, err := loadSLOs(path, "default")
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(slos) != 2 || slos[0].Window != 7*24*time.Hour || slos[1].Key() != "default/search" || slos[1].Window != 30*24*time.Hour {
+			t.Errorf("%s: got %+v", tc.name, slos)
+		}
+		if got := slos[0].query(time.Hour); got != `sum(rate(ok[1h])) / sum(rate(all[1h]))` {
+			t.Errorf("query %s", got)
+		}
+	}
+
+	if _, err := loadSLOs(filepath.Join(t.TempDir(), "missing.yaml"), "default"); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestPromDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		30 * 24 * time.Hour: "30d",
+		6 * time.Hour:       "6h",
+		90 * time.Minute:    "90m",
+		1234 * time.Second:  "1234s",
+	} {
+		if got := promDuration(d); got != want {
+			t.Errorf("%s: got %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestPromClient(t *testing.T) {
+	at := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" || r.URL.Query().Get("time") != "1893456000" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("query") {
+		case "good":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1893456000,"0.9995"]}]}}`))
+		case "empty":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		case "nan":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1893456000,"NaN"]}]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+		}
+	}))
+	defer server.Close()
+	p := newPromClient(server.URL + "/")
+
+	for _, tc := range []struct {
+		query string
+		want  float64
+		ok    bool
+		err   bool
+	}{
+		{query: "good", want: 0.9995, ok: true},
+		{query: "empty"},
+		{query: "nan"},
+		{query: "bad(", err: true},
+	} {
+		v, ok, err := p.Query(context.Background(), tc.query, at)
+		if (err != nil) != tc.err || ok != tc.ok || v != tc.want {
+			t.Errorf("%s: got %g %v %v", tc.query, v, ok, err)
+		}
+	}
+}
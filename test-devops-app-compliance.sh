@@ -132,7 +132,9 @@ echo ""
 
 # Build the app
 echo -n "Building $APP_NAME ... "
-if cd $APP_DIR && go build -o app-binary 2>/dev/null; then
+BUILD_PKG=.
+[ -d "$APP_DIR/cmd/$APP_NAME" ] && BUILD_PKG=./cmd/$APP_NAME
+if cd $APP_DIR && go build -o app-binary $BUILD_PKG 2>/dev/null; then
     echo -e "${GREEN}SUCCESS${NC}"
     ((STATIC_PASSED++))
 else
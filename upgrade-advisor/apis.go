@@ -0,0 +1,117 @@
+package upgradeadvisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Deprecation is an API version of a kind that Kubernetes deprecated and
+// removes, from the Kubernetes deprecated API migration guide
+type Deprecation struct {
+	APIVersion   string `json:"api_version"`
+	Kind         string `json:"kind"`
+	DeprecatedIn int    `json:"deprecated_in"` // minor version of 1.x
+	RemovedIn    int    `json:"removed_in"`
+	Replacement  string `json:"replacement,omitempty"` // apiVersion; empty when the API is gone
+	Note         string `json:"note,omitempty"`        // what the migration can't do for you
+	// No patch is generated: the new version needs changes only a person
+	// can make, which Note describes
+	Manual bool `json:"manual,omitempty"`
+
+	migrate migration
+}
+
+// Deprecations is every deprecated API the advisor knows
+var Deprecations = []Deprecation{
+	// 1.16
+	{APIVersion: "extensions/v1beta1", Kind: "Deployment", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "extensions/v1beta1", Kind: "DaemonSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "extensions/v1beta1", Kind: "ReplicaSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "extensions/v1beta1", Kind: "NetworkPolicy", DeprecatedIn: 9, RemovedIn: 16, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: 10, RemovedIn: 16, Replacement: "policy/v1beta1", Note: "policy/v1beta1 is itself removed in 1.25"},
+	{APIVersion: "apps/v1beta1", Kind: "Deployment", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "apps/v1beta1", Kind: "StatefulSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "apps/v1beta2", Kind: "Deployment", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "apps/v1beta2", Kind: "StatefulSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "apps/v1beta2", Kind: "DaemonSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+	{APIVersion: "apps/v1beta2", Kind: "ReplicaSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1", migrate: migrateWorkload},
+
+	// 1.22
+	{APIVersion: "extensions/v1beta1", Kind: "Ingress", DeprecatedIn: 14, RemovedIn: 22, Replacement: "networking.k8s.io/v1", migrate: migrateIngress},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", DeprecatedIn: 19, RemovedIn: 22, Replacement: "networking.k8s.io/v1", migrate: migrateIngress},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", DeprecatedIn: 19, RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", DeprecatedIn: 16, RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1",
+		Manual: true, Note: "v1 requires sideEffects and admissionReviewVersions on each webhook"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", DeprecatedIn: 16, RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1",
+		Manual: true, Note: "v1 requires sideEffects and admissionReviewVersions on each webhook"},
+	{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", DeprecatedIn: 16, RemovedIn: 22, Replacement: "apiextensions.k8s.io/v1",
+		Manual: true, Note: "convert by hand: v1 needs a structural schema per version in spec.versions[].schema"},
+	{APIVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", DeprecatedIn: 14, RemovedIn: 22, Replacement: "scheduling.k8s.io/v1"},
+	{APIVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", DeprecatedIn: 19, RemovedIn: 22, Replacement: "certificates.k8s.io/v1",
+		Manual: true, Note: "v1 requires spec.signerName"},
+	{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", DeprecatedIn: 14, RemovedIn: 22, Replacement: "coordination.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", DeprecatedIn: 19, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", DeprecatedIn: 19, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", DeprecatedIn: 17, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", DeprecatedIn: 19, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+
+	// 1.25
+	{APIVersion: "batch/v1beta1", Kind: "CronJob", DeprecatedIn: 21, RemovedIn: 25, Replacement: "batch/v1"},
+	{APIVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", DeprecatedIn: 21, RemovedIn: 25, Replacement: "discovery.k8s.io/v1",
+		Note: "endpoints[].topology became nodeName and zone"},
+	{APIVersion: "events.k8s.io/v1beta1", Kind: "Event", DeprecatedIn: 19, RemovedIn: 25, Replacement: "events.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", DeprecatedIn: 22, RemovedIn: 25, Replacement: "autoscaling/v2", migrate: migrateHPA},
+	{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", DeprecatedIn: 21, RemovedIn: 25, Replacement: "policy/v1",
+		Note: "an empty spec.selector now selects every pod of the namespace instead of none"},
+	{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: 21, RemovedIn: 25,
+		Note: "removed without a replacement: enforce Pod Security Admission with pod-security.kubernetes.io labels on the namespace"},
+	{APIVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", DeprecatedIn: 20, RemovedIn: 25, Replacement: "node.k8s.io/v1"},
+
+	// 1.26
+	{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", DeprecatedIn: 23, RemovedIn: 26, Replacement: "autoscaling/v2"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", DeprecatedIn: 23, RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1beta3",
+		Note: "v1beta3 is removed in 1.32; move to v1 once the cluster runs 1.29"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", DeprecatedIn: 23, RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1beta3",
+		Note: "v1beta3 is removed in 1.32; move to v1 once the cluster runs 1.29"},
+
+	// 1.27
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", DeprecatedIn: 24, RemovedIn: 27, Replacement: "storage.k8s.io/v1"},
+
+	// 1.29
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", DeprecatedIn: 26, RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", DeprecatedIn: 26, RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1",
+		Note: "v1 renamed spec.limited.assuredConcurrencyShares to nominalConcurrencyShares"},
+
+	// 1.32
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", DeprecatedIn: 29, RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", DeprecatedIn: 29, RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// deprecation returns the deprecation of an apiVersion and kind
+func deprecation(apiVersion, kind string) (Deprecation, bool) {
+	for _, d := range Deprecations {
+		if d.APIVersion == apiVersion && d.Kind == kind {
+			return d, true
+		}
+	}
+	return Deprecation{}, false
+}
+
+// parseVersion returns the minor version of a Kubernetes 1.x version such
+// as 1.29, v1.29 or 1.29.3
+func parseVersion(v string) (int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("%q is not a Kubernetes 1.x version", v)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return 0, fmt.Errorf("%q is not a Kubernetes 1.x version", v)
+	}
+	return minor, nil
+}
@@ -0,0 +1,10 @@
+// Command upgrade-advisor scans ConfigHub units for Kubernetes APIs
+// deprecated or removed by an upcoming version and proposes the patches
+// migrating them. The same app runs as "devops-apps upgrade".
+package main
+
+import upgradeadvisor "github.com/monadic/devops-examples/upgrade-advisor"
+
+func main() {
+	upgradeadvisor.Main()
+}
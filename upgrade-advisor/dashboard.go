@@ -0,0 +1,133 @@
+package upgradeadvisor
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/monadic/devops-examples/pkg/api"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/health"
+)
+
+// webFiles holds the dashboard page template
+//
+//go:embed web/index.html.tmpl
+var webFiles embed.FS
+
+var dashboardTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+	"json": func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	},
+	"ago": func(t time.Time) string {
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+}).ParseFS(webFiles, "web/index.html.tmpl"))
+
+// handler serves the dashboard, its API under /api/v1 and /metrics
+func (a *Advisor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		report, ok := a.reportFor(w, r)
+		if !ok {
+			return
+		}
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	})
+	v1 := api.New(mux, "upgrade-advisor")
+	v1.HandleFunc("/upgrade", a.handleUpgrade, api.Operation{
+		Summary: "Deprecated APIs the units use, for an upgrade",
+		Query: []api.Param{
+			{Name: "target", Description: "Kubernetes version, the configured one when unset"},
+			{Name: "status", Description: "removed or deprecated"},
+			{Name: "space", Description: "one space's"},
+		},
+		Response: Report{},
+	})
+	v1.HandleFunc("/deprecations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, Deprecations)
+	}, api.Operation{Summary: "Known API deprecations and removals", Response: Deprecations})
+	v1.Handle("/breakers", breaker.Handler(a.cubBreaker), breaker.Operations...)
+	mux.HandleFunc("/health", health.Live)
+	a.health.Mount(mux)
+	mux.Handle("/metrics", a.metrics)
+	return mux
+}
+
+// handleUpgrade serves the readiness report as JSON, for ?target= instead
+// of target_version, with the findings filtered by ?space= and ?status=
+func (a *Advisor) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report, ok := a.reportFor(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	status, space := query.Get("status"), query.Get("space")
+	if status != "" && status != StatusRemoved && status != StatusDeprecated {
+		http.Error(w, "status must be removed or deprecated", http.StatusBadRequest)
+		return
+	}
+	if status != "" || space != "" {
+		filtered := *report
+		filtered.Findings = []Finding{}
+		for _, f := range report.Findings {
+			if (status == "" || f.Status == status) && (space == "" || f.Space == space) {
+				filtered.Findings = append(filtered.Findings, f)
+			}
+		}
+		report = &filtered
+	}
+	writeJSON(w, report)
+}
+
+// reportFor returns the report for the request's ?target=, or writes why
+// there is none
+func (a *Advisor) reportFor(w http.ResponseWriter, r *http.Request) (*Report, bool) {
+	var report *Report
+	if v := r.URL.Query().Get("target"); v != "" {
+		target, err := parseVersion(v)
+		if err != nil {
+			http.Error(w, "target: "+err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		report = a.ReportFor(target)
+	} else {
+		report = a.Report()
+	}
+	if report == nil {
+		http.Error(w, "units have not been scanned yet", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	return report, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,261 @@
+// Package upgradeadvisor scans the units of ConfigHub spaces for Kubernetes
+// APIs deprecated or removed by an upcoming Kubernetes version, estimates
+// each space's readiness for the upgrade, and generates the patches that
+// migrate the units to the replacement APIs, for review.
+package upgradeadvisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/auth"
+	"github.com/monadic/devops-examples/pkg/breaker"
+	"github.com/monadic/devops-examples/pkg/config"
+	"github.com/monadic/devops-examples/pkg/health"
+	"github.com/monadic/devops-examples/pkg/lifecycle"
+	"github.com/monadic/devops-examples/pkg/listcache"
+	"github.com/monadic/devops-examples/pkg/logging"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	"github.com/monadic/devops-examples/pkg/ratelimit"
+	"github.com/monadic/devops-examples/pkg/runmode"
+	"github.com/monadic/devops-examples/pkg/tracing"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+const version = "1.0.0"
+
+// Report is the readiness of the scanned spaces for a target version,
+// served at GET /api/v1/upgrade
+type Report struct {
+	ScannedAt time.Time        `json:"scanned_at"`
+	Target    string           `json:"target"`
+	Spaces    []SpaceReadiness `json:"spaces"`
+	Findings  []Finding        `json:"findings"` // blockers first
+	Blockers  int              `json:"blockers"`
+	Warnings  int              `json:"warnings"`
+	Ready     bool             `json:"ready"` // every space scanned and without blockers
+}
+
+// scan is the units read by the latest scan
+type scan struct {
+	at        time.Time
+	resources []resource
+	errors    map[string]string // why a space could not be read, by slug
+}
+
+type Advisor struct {
+	app        *sdk.DevOpsApp
+	config     Config
+	target     int // minor version of target_version
+	metrics    *metrics.Registry
+	cubLimit   *ratelimit.Limiter
+	cubBreaker *breaker.Breaker
+	health     *health.Checker
+
+	mu     sync.RWMutex
+	last   *scan
+	report *Report // for target_version
+}
+
+// Main scans the spaces every run_interval until interrupted, or once with
+// --mode=job. It is the entry point of cmd/upgrade-advisor and of
+// "devops-apps upgrade".
+func Main() {
+	logger := logging.Setup("upgrade-advisor")
+
+	cfg, effective, err := loadConfig()
+	if err != nil {
+		logging.Fatal("Failed to load config", logging.Err(err))
+	}
+	target, _ := parseVersion(cfg.TargetVersion) // validated by loadConfig
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "upgrade-advisor", version)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	app, err := sdk.NewDevOpsApp(sdk.DevOpsAppConfig{
+		Name:        "upgrade-advisor",
+		Version:     version,
+		Description: "Finds deprecated Kubernetes APIs in ConfigHub units before an upgrade",
+		RunInterval: cfg.RunInterval,
+		CubToken:    cfg.CubToken,
+		CubBaseURL:  cfg.CubAPIURL,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize app", logging.Err(err))
+	}
+	app.Logger = logging.StdLogger(logger)
+	effective.Log(logging.Printf(logger))
+
+	guard, err := auth.Load(context.Background(), cfg.AuthConfig, effective.ReadSecretFile)
+	if err != nil {
+		logging.Fatal("Failed to load auth config", logging.Err(err))
+	}
+
+	cubBreaker := breaker.New("confighub", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	advisor := &Advisor{
+		app:        app,
+		config:     cfg,
+		target:     target,
+		metrics:    metrics.New("upgrade-advisor", version, cfg.ClusterName),
+		cubLimit:   ratelimit.New(cfg.CubRateLimit, cfg.CubRateBurst).Guard(cubBreaker),
+		cubBreaker: cubBreaker,
+	}
+	advisor.metrics.Collect(metrics.Limiter(advisor.cubLimit))
+	advisor.metrics.Collect(metrics.ListCache(listcache.Install()))
+	advisor.metrics.Collect(metrics.Breakers(cubBreaker))
+	advisor.health = health.New()
+	advisor.health.Register("kubernetes", health.Required, health.Kubernetes(app.K8s.Clientset.Discovery()))
+	advisor.health.Register("confighub", health.Optional, health.Breaker(cubBreaker))
+	advisor.metrics.Collect(advisor.collect)
+
+	ctx, stop := lifecycle.Signals()
+	defer stop()
+	if runmode.IsJob() {
+		store := newJobStore(app.Cub, advisor.cubLimit, runmode.Space())
+		if err := runmode.Once(ctx, "upgrade-advisor", advisor.scan, advisor.Report, os.Stdout, store); err != nil {
+			logging.Fatal("Upgrade scan failed", logging.Err(err))
+		}
+		return
+	}
+	group := lifecycle.New(ctx)
+	go effective.WatchSecrets(group.Context(), 30*time.Second, config.RestartOnRotation)
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	slog.Info("Upgrade dashboard listening", "addr", addr)
+	group.Serve(addr, guard.Protect(advisor.handler(), "/metrics", "/health"))
+	group.Go(advisor.run)
+	if err := group.Wait(); err != nil {
+		logging.Fatal("Upgrade dashboard stopped", logging.Err(err))
+	}
+}
+
+// run scans now and every run_interval until interrupted
+func (a *Advisor) run(ctx context.Context) error {
+	ticker := time.NewTicker(a.config.RunInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.scan(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Upgrade scan failed", logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Received shutdown signal")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan reads the units of every space and assesses them for target_version.
+// A space that can't be read is reported as not ready; the scan only fails
+// when none could be read.
+func (a *Advisor) scan(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "upgrade.scan")
+	defer func() { tracing.End(span, err) }()
+	cycleDone := a.metrics.Cycle("scan", "")
+	defer func() { cycleDone(err) }()
+
+	s := &scan{at: time.Now(), errors: map[string]string{}}
+	spaces, err := tracing.Call(ctx, "confighub.ListSpaces", func() ([]*sdk.Space, error) {
+		return ratelimit.Call(ctx, a.cubLimit, "ListSpaces", "all", a.app.Cub.ListSpaces)
+	})
+	if err != nil {
+		return fmt.Errorf("list spaces: %w", err)
+	}
+	ids := map[string]uuid.UUID{}
+	for _, sp := range spaces {
+		ids[sp.Slug] = sp.SpaceID
+	}
+	for _, slug := range a.config.Spaces {
+		id, ok := ids[slug]
+		if !ok {
+			s.errors[slug] = "space not found"
+			continue
+		}
+		units, err := tracing.Call(ctx, "confighub.ListUnits", func() ([]*sdk.Unit, error) {
+			return ratelimit.Call(ctx, a.cubLimit, "ListUnits", id.String(), func() ([]*sdk.Unit, error) {
+				return a.app.Cub.ListUnits(sdk.ListUnitsParams{SpaceID: id})
+			})
+		}, tracing.SpaceKey.String(slug))
+		if err != nil {
+			s.errors[slug] = fmt.Sprintf("list units: %v", err)
+			continue
+		}
+		s.resources = append(s.resources, parseUnits(slug, units, a.config.Namespace)...)
+	}
+	for slug, msg := range s.errors {
+		slog.Warn("Space not scanned", "space", slug, "reason", msg)
+	}
+	if len(s.errors) == len(a.config.Spaces) {
+		return fmt.Errorf("no space could be read")
+	}
+
+	report := a.assess(s, a.target)
+	a.mu.Lock()
+	a.last, a.report = s, report
+	a.mu.Unlock()
+	slog.Info("Units scanned", "target", report.Target, "resources", len(s.resources),
+		"blockers", report.Blockers, "warnings", report.Warnings)
+	return nil
+}
+
+// assess builds the report of a scan for the target minor version
+func (a *Advisor) assess(s *scan, target int) *Report {
+	findings, spaces := assess(s.resources, a.config.Spaces, target)
+	report := &Report{ScannedAt: s.at, Target: fmt.Sprintf("1.%d", target), Spaces: spaces, Findings: findings, Ready: true}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	for i := range report.Spaces {
+		sr := &report.Spaces[i]
+		if msg, ok := s.errors[sr.Space]; ok {
+			sr.Error, sr.Ready, sr.Readiness = msg, false, 0
+		}
+		report.Blockers += sr.Blockers
+		report.Warnings += sr.Warnings
+		report.Ready = report.Ready && sr.Ready
+	}
+	return report
+}
+
+// Report returns the readiness for target_version, nil before the first
+// scan
+func (a *Advisor) Report() *Report {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.report
+}
+
+// ReportFor returns the readiness of the latest scan for another target
+// minor version, nil before the first scan
+func (a *Advisor) ReportFor(target int) *Report {
+	a.mu.RLock()
+	s := a.last
+	a.mu.RUnlock()
+	if s == nil {
+		return nil
+	}
+	return a.assess(s, target)
+}
+
+// collect exports each space's readiness for target_version
+func (a *Advisor) collect(emit metrics.Emit) {
+	report := a.Report()
+	if report == nil {
+		return
+	}
+	for _, sr := range report.Spaces {
+		labels := metrics.Labels{"space": sr.Space, "target": report.Target}
+		emit("upgrade_blockers", "Units using APIs removed by the target Kubernetes version.", "gauge", labels, float64(sr.Blockers))
+		emit("upgrade_warnings", "Units using APIs deprecated by the target Kubernetes version.", "gauge", labels, float64(sr.Warnings))
+		emit("upgrade_readiness_ratio", "Share of a space's resources ready for the target Kubernetes version.", "gauge", labels, sr.Readiness)
+	}
+}
@@ -0,0 +1,229 @@
+package upgradeadvisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// patchOp is an RFC 6902 JSON Patch operation
+type patchOp struct {
+	Op    string      `json:"op"` // add, replace or remove
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// migration returns the operations, beyond the new apiVersion, that carry
+// a resource over to the replacement version, and notes on what it could
+// not carry over
+type migration func(obj map[string]interface{}) ([]patchOp, []string)
+
+// migrationOps returns the JSON Patch migrating obj per d
+func migrationOps(d Deprecation, obj map[string]interface{}) ([]patchOp, []string) {
+	ops := []patchOp{{Op: "replace", Path: "/apiVersion", Value: d.Replacement}}
+	if d.migrate == nil {
+		return ops, nil
+	}
+	more, notes := d.migrate(obj)
+	return append(ops, more...), notes
+}
+
+// migrateWorkload adds the spec.selector apps/v1 requires, defaulted before
+// from the pod template's labels
+func migrateWorkload(obj map[string]interface{}) ([]patchOp, []string) {
+	if _, ok := lookup(obj, "spec", "selector").(map[string]interface{}); ok {
+		return nil, nil
+	}
+	labels, ok := lookup(obj, "spec", "template", "metadata", "labels").(map[string]interface{})
+	if !ok || len(labels) == 0 {
+		return nil, []string{"apps/v1 requires spec.selector and the pod template has no labels to select; set both"}
+	}
+	return []patchOp{{Op: "add", Path: "/spec/selector", Value: map[string]interface{}{"matchLabels": labels}}}, nil
+}
+
+// migrateIngress moves backends to networking.k8s.io/v1's service.name and
+// service.port, spec.backend to spec.defaultBackend, and sets the pathType
+// v1 requires to ImplementationSpecific, the old behavior
+func migrateIngress(obj map[string]interface{}) ([]patchOp, []string) {
+	var ops []patchOp
+	if backend, ok := lookup(obj, "spec", "backend").(map[string]interface{}); ok {
+		ops = append(ops,
+			patchOp{Op: "remove", Path: "/spec/backend"},
+			patchOp{Op: "add", Path: "/spec/defaultBackend", Value: ingressBackend(backend)})
+	}
+	rules, _ := lookup(obj, "spec", "rules").([]interface{})
+	for i, r := range rules {
+		paths, _ := lookup(r, "http", "paths").([]interface{})
+		for j, p := range paths {
+			base := fmt.Sprintf("/spec/rules/%d/http/paths/%d", i, j)
+			if backend, ok := lookup(p, "backend").(map[string]interface{}); ok && backend["serviceName"] != nil {
+				ops = append(ops, patchOp{Op: "replace", Path: base + "/backend", Value: ingressBackend(backend)})
+			}
+			if lookup(p, "pathType") == nil {
+				ops = append(ops, patchOp{Op: "add", Path: base + "/pathType", Value: "ImplementationSpecific"})
+			}
+		}
+	}
+	return ops, nil
+}
+
+// ingressBackend converts a serviceName/servicePort backend; a resource
+// backend is unchanged
+func ingressBackend(b map[string]interface{}) map[string]interface{} {
+	if b["serviceName"] == nil {
+		return b
+	}
+	port := map[string]interface{}{}
+	switch p := b["servicePort"].(type) {
+	case float64:
+		port["number"] = int64(p)
+	case string:
+		if n, err := strconv.Atoi(p); err == nil {
+			port["number"] = int64(n)
+		} else {
+			port["name"] = p
+		}
+	}
+	return map[string]interface{}{"service": map[string]interface{}{"name": b["serviceName"], "port": port}}
+}
+
+// hpaMetricKeys are the fields holding each type of HPA metric
+var hpaMetricKeys = map[string]string{"Resource": "resource", "Pods": "pods", "Object": "object", "External": "external"}
+
+// migrateHPA moves autoscaling/v2beta1 metric targets into autoscaling/v2's
+// metric and target blocks
+func migrateHPA(obj map[string]interface{}) ([]patchOp, []string) {
+	var ops []patchOp
+	var notes []string
+	metrics, _ := lookup(obj, "spec", "metrics").([]interface{})
+	for i, m := range metrics {
+		metric, _ := m.(map[string]interface{})
+		typ, _ := metric["type"].(string)
+		key := hpaMetricKeys[typ]
+		old, ok := metric[key].(map[string]interface{})
+		if !ok {
+			notes = append(notes, fmt.Sprintf("metric %d of type %q is not converted", i, typ))
+			continue
+		}
+		converted := map[string]interface{}{}
+		if typ == "Resource" {
+			converted["name"] = old["name"]
+		} else {
+			m := map[string]interface{}{"name": old["metricName"]}
+			if s := old["selector"]; s != nil {
+				m["selector"] = s
+			}
+			if s := old["metricSelector"]; s != nil {
+				m["selector"] = s
+			}
+			converted["metric"] = m
+			if o := old["target"]; typ == "Object" && o != nil {
+				converted["describedObject"] = o
+			}
+		}
+		switch {
+		case old["targetAverageUtilization"] != nil:
+			converted["target"] = map[string]interface{}{"type": "Utilization", "averageUtilization": old["targetAverageUtilization"]}
+		case old["targetAverageValue"] != nil:
+			converted["target"] = map[string]interface{}{"type": "AverageValue", "averageValue": old["targetAverageValue"]}
+		case old["averageValue"] != nil:
+			converted["target"] = map[string]interface{}{"type": "AverageValue", "averageValue": old["averageValue"]}
+		case old["targetValue"] != nil:
+			converted["target"] = map[string]interface{}{"type": "Value", "value": old["targetValue"]}
+		}
+		ops = append(ops, patchOp{Op: "replace", Path: fmt.Sprintf("/spec/metrics/%d/%s", i, key), Value: converted})
+	}
+	return ops, notes
+}
+
+// lookup returns the value at a path of nested maps, nil when missing
+func lookup(v interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// applyOps returns a copy of obj with the add, replace and remove
+// operations applied
+func applyOps(obj map[string]interface{}, ops []patchOp) (map[string]interface{}, error) {
+	doc, err := copyJSON(obj)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if op.Op != "add" && op.Op != "replace" && op.Op != "remove" {
+			return nil, fmt.Errorf("%s %s: unsupported operation", op.Op, op.Path)
+		}
+		// values may share maps with obj, which must not change
+		if op.Value, err = copyJSON(op.Value); err != nil {
+			return nil, err
+		}
+		if doc, err = applyOp(doc, strings.Split(op.Path, "/")[1:], op); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return doc.(map[string]interface{}), nil
+}
+
+// copyJSON returns a deep copy of v as decoded JSON: maps, slices, strings,
+// float64 numbers and booleans
+func copyJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c interface{}
+	return c, json.Unmarshal(data, &c)
+}
+
+// applyOp applies op at the pointer tokens below doc and returns doc
+func applyOp(doc interface{}, tokens []string, op patchOp) (interface{}, error) {
+	token := strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[0])
+	last := len(tokens) == 1
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok && (!last || op.Op != "add") {
+			return nil, fmt.Errorf("no %s", token)
+		}
+		if !last {
+			var err error
+			node[token], err = applyOp(child, tokens[1:], op)
+			return node, err
+		}
+		if op.Op == "remove" {
+			delete(node, token)
+		} else {
+			node[token] = op.Value
+		}
+		return node, nil
+	case []interface{}:
+		if last && op.Op == "add" && token == "-" {
+			return append(node, op.Value), nil
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i > len(node) || (i == len(node) && (!last || op.Op != "add")) {
+			return nil, fmt.Errorf("no element %s", token)
+		}
+		if !last {
+			node[i], err = applyOp(node[i], tokens[1:], op)
+			return node, err
+		}
+		switch op.Op {
+		case "add":
+			node = append(node[:i], append([]interface{}{op.Value}, node[i:]...)...)
+		case "remove":
+			node = append(node[:i], node[i+1:]...)
+		default:
+			node[i] = op.Value
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("cannot descend into %s", token)
+}
@@ -0,0 +1,210 @@
+package upgradeadvisor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func parse(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &obj); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestMigrateIngress(t *testing.T) {
+	obj := parse(t, `
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  backend:
+    serviceName: fallback
+    servicePort: 80
+  rules:
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /
+        backend:
+          serviceName: web
+          servicePort: http
+      - path: /api
+        pathType: Prefix
+        backend:
+          serviceName: api
+          servicePort: "8080"
+`)
+	d, _ := deprecation("extensions/v1beta1", "Ingress")
+	ops, notes := migrationOps(d, obj)
+	if len(notes) != 0 {
+		t.Errorf("notes %v", notes)
+	}
+	migrated, err := applyOps(obj, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parse(t, `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  defaultBackend:
+    service:
+      name: fallback
+      port:
+        number: 80
+  rules:
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: web
+            port:
+              name: http
+      - path: /api
+        pathType: Prefix
+        backend:
+          service:
+            name: api
+            port:
+              number: 8080
+`)
+	if !reflect.DeepEqual(migrated, want) {
+		got, _ := yaml.Marshal(migrated)
+		t.Errorf("migrated to\n%s", got)
+	}
+	if obj["apiVersion"] != "extensions/v1beta1" {
+		t.Error("applyOps changed the original")
+	}
+}
+
+func TestMigrateHPA(t *testing.T) {
+	obj := parse(t, `
+apiVersion: autoscaling/v2beta1
+kind: HorizontalPodAutoscaler
+spec:
+  metrics:
+  - type: Resource
+    resource:
+      name: cpu
+      targetAverageUtilization: 70
+  - type: Pods
+    pods:
+      metricName: requests
+      targetAverageValue: "100"
+  - type: External
+    external:
+      metricName: queue_depth
+      metricSelector:
+        matchLabels:
+          queue: orders
+      targetValue: "30"
+`)
+	d, _ := deprecation("autoscaling/v2beta1", "HorizontalPodAutoscaler")
+	migrated, err := applyOps(obj, mustOps(migrationOps(d, obj)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parse(t, `
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+spec:
+  metrics:
+  - type: Resource
+    resource:
+      name: cpu
+      target:
+        type: Utilization
+        averageUtilization: 70
+  - type: Pods
+    pods:
+      metric:
+        name: requests
+      target:
+        type: AverageValue
+        averageValue: "100"
+  - type: External
+    external:
+      metric:
+        name: queue_depth
+        selector:
+          matchLabels:
+            queue: orders
+      target:
+        type: Value
+        value: "30"
+`)
+	if !reflect.DeepEqual(migrated, want) {
+		got, _ := yaml.Marshal(migrated)
+		t.Errorf("migrated to\n%s", got)
+	}
+}
+
+func mustOps(ops []patchOp, _ []string) []patchOp { return ops }
+
+func TestMigrateWorkload(t *testing.T) {
+	d, _ := deprecation("apps/v1beta1", "Deployment")
+
+	obj := parse(t, "apiVersion: apps/v1beta1\nkind: Deployment\nspec:\n  template:\n    metadata:\n      labels:\n        app: web\n")
+	ops, notes := migrationOps(d, obj)
+	want := []patchOp{
+		{Op: "replace", Path: "/apiVersion", Value: "apps/v1"},
+		{Op: "add", Path: "/spec/selector", Value: map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}}},
+	}
+	if !reflect.DeepEqual(ops, want) || len(notes) != 0 {
+		t.Errorf("got %+v, notes %v", ops, notes)
+	}
+
+	// a selector is kept as it is
+	obj = parse(t, "apiVersion: apps/v1beta1\nkind: Deployment\nspec:\n  selector:\n    matchLabels:\n      app: web\n")
+	if ops, _ := migrationOps(d, obj); len(ops) != 1 {
+		t.Errorf("with a selector: got %+v", ops)
+	}
+
+	// without template labels there is nothing to select by
+	obj = parse(t, "apiVersion: apps/v1beta1\nkind: Deployment\nspec: {}\n")
+	if ops, notes := migrationOps(d, obj); len(ops) != 1 || len(notes) != 1 || !strings.Contains(notes[0], "selector") {
+		t.Errorf("without labels: got %+v, notes %v", ops, notes)
+	}
+}
+
+func TestApplyOps(t *testing.T) {
+	obj := map[string]interface{}{"a/b": map[string]interface{}{"list": []interface{}{"x", "y"}}}
+	got, err := applyOps(obj, []patchOp{
+		{Op: "add", Path: "/a~1b/list/-", Value: "z"},
+		{Op: "replace", Path: "/a~1b/list/0", Value: "w"},
+		{Op: "remove", Path: "/a~1b/list/1"},
+		{Op: "add", Path: "/a~1b/list/1", Value: "v"},
+		{Op: "add", Path: "/c~0d", Value: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"a/b": map[string]interface{}{"list": []interface{}{"w", "v", "z"}}, "c~d": float64(1)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v", got)
+	}
+
+	for _, op := range []patchOp{
+		{Op: "replace", Path: "/missing", Value: 1},
+		{Op: "remove", Path: "/a~1b/list/5"},
+		{Op: "add", Path: "/a~1b/list/x", Value: 1},
+		{Op: "remove", Path: "/missing"},
+		{Op: "move", Path: "/a~1b"},
+	} {
+		if _, err := applyOps(obj, []patchOp{op}); err == nil {
+			t.Errorf("%+v: no error", op)
+		}
+	}
+}
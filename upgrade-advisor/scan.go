@@ -0,0 +1,179 @@
+package upgradeadvisor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	sdk "github.com/monadic/devops-sdk"
+	"sigs.k8s.io/yaml"
+)
+
+// Finding statuses
+const (
+	StatusRemoved    = "removed"    // gone in the target version: blocks the upgrade
+	StatusDeprecated = "deprecated" // still served in the target version, removed later
+)
+
+// resource is a Kubernetes resource kept in a unit
+type resource struct {
+	Space      string
+	UnitID     uuid.UUID
+	UnitSlug   string
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	obj        map[string]interface{}
+}
+
+// Finding is a unit using a deprecated API, with its migration
+type Finding struct {
+	Space       string      `json:"space"`
+	UnitID      uuid.UUID   `json:"unit_id"`
+	UnitSlug    string      `json:"unit_slug"`
+	Namespace   string      `json:"namespace"`
+	Name        string      `json:"name"`
+	Status      string      `json:"status"`
+	Deprecation Deprecation `json:"deprecation"`
+	// JSON Patch of the unit to the replacement version, and the unit's
+	// YAML once patched, for review
+	Patches  []ProposedPatch `json:"patches,omitempty"`
+	Migrated string          `json:"migrated,omitempty"`
+	Notes    []string        `json:"notes,omitempty"`
+}
+
+// ProposedPatch is one operation of a unit's migration, for an operator to
+// review and apply
+type ProposedPatch struct {
+	UnitID      uuid.UUID   `json:"unit_id"`
+	UnitSlug    string      `json:"unit_slug"`
+	Op          string      `json:"op"`
+	PatchPath   string      `json:"patch_path"`
+	PatchValue  interface{} `json:"patch_value,omitempty"`
+	Explanation string      `json:"explanation"`
+}
+
+// SpaceReadiness is how ready a space's units are for the target version
+type SpaceReadiness struct {
+	Space     string  `json:"space"`
+	Resources int     `json:"resources"` // units holding a Kubernetes resource
+	Blockers  int     `json:"blockers"`  // using APIs removed by the target
+	Warnings  int     `json:"warnings"`  // using APIs deprecated by the target
+	Ready     bool    `json:"ready"`
+	Readiness float64 `json:"readiness"` // share of resources without a blocker
+	Error     string  `json:"error,omitempty"`
+}
+
+// parseUnits returns the Kubernetes resources of a space's units; units
+// holding something else are skipped
+func parseUnits(space string, units []*sdk.Unit, defaultNamespace string) []resource {
+	var resources []resource
+	for _, u := range units {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(u.Data), &obj); err != nil {
+			continue
+		}
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		if apiVersion == "" || kind == "" {
+			continue
+		}
+		r := resource{Space: space, UnitID: u.UnitID, UnitSlug: u.Slug, APIVersion: apiVersion, Kind: kind, obj: obj}
+		r.Name, _ = lookup(obj, "metadata", "name").(string)
+		r.Namespace, _ = lookup(obj, "metadata", "namespace").(string)
+		if r.Namespace == "" {
+			r.Namespace = defaultNamespace
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+// assess finds the resources using APIs removed or deprecated by the target
+// minor version, with their migrations, and each space's readiness
+func assess(resources []resource, spaces []string, target int) ([]Finding, []SpaceReadiness) {
+	readiness := map[string]*SpaceReadiness{}
+	for _, s := range spaces {
+		readiness[s] = &SpaceReadiness{Space: s}
+	}
+	var findings []Finding
+	for _, r := range resources {
+		sr := readiness[r.Space]
+		if sr == nil {
+			sr = &SpaceReadiness{Space: r.Space}
+			readiness[r.Space] = sr
+		}
+		sr.Resources++
+		d, ok := deprecation(r.APIVersion, r.Kind)
+		if !ok || d.DeprecatedIn > target {
+			continue
+		}
+		f := Finding{Space: r.Space, UnitID: r.UnitID, UnitSlug: r.UnitSlug, Namespace: r.Namespace, Name: r.Name, Status: StatusDeprecated, Deprecation: d}
+		if d.RemovedIn <= target {
+			f.Status = StatusRemoved
+			sr.Blockers++
+		} else {
+			sr.Warnings++
+		}
+		migrate(&f, r)
+		findings = append(findings, f)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Status != b.Status {
+			return a.Status == StatusRemoved
+		}
+		if a.Deprecation.RemovedIn != b.Deprecation.RemovedIn {
+			return a.Deprecation.RemovedIn < b.Deprecation.RemovedIn
+		}
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.UnitSlug < b.UnitSlug
+	})
+	var out []SpaceReadiness
+	for _, sr := range readiness {
+		sr.Ready, sr.Readiness = sr.Blockers == 0, 1
+		if sr.Resources > 0 {
+			sr.Readiness = float64(sr.Resources-sr.Blockers) / float64(sr.Resources)
+		}
+		out = append(out, *sr)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Space < out[j].Space })
+	return findings, out
+}
+
+// migrate adds a finding's patches and the migrated YAML; an API removed
+// without a replacement or needing a manual conversion gets notes only
+func migrate(f *Finding, r resource) {
+	d := f.Deprecation
+	if d.Note != "" {
+		f.Notes = append(f.Notes, d.Note)
+	}
+	if d.Replacement == "" || d.Manual {
+		return
+	}
+	ops, notes := migrationOps(d, r.obj)
+	f.Notes = append(f.Notes, notes...)
+	for _, op := range ops {
+		explanation := fmt.Sprintf("%s replaces %s, removed in 1.%d", d.Replacement, d.APIVersion, d.RemovedIn)
+		if op.Path != "/apiVersion" {
+			explanation = fmt.Sprintf("field moved or required in %s", d.Replacement)
+		}
+		f.Patches = append(f.Patches, ProposedPatch{
+			UnitID: r.UnitID, UnitSlug: r.UnitSlug, Op: op.Op, PatchPath: op.Path, PatchValue: op.Value, Explanation: explanation,
+		})
+	}
+	migrated, err := applyOps(r.obj, ops)
+	if err == nil {
+		var data []byte
+		if data, err = yaml.Marshal(migrated); err == nil {
+			f.Migrated = string(data)
+		}
+	}
+	if err != nil {
+		f.Notes = append(f.Notes, fmt.Sprintf("the patches could not be previewed: %v", err))
+	}
+}
@@ -0,0 +1,175 @@
+package upgradeadvisor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monadic/devops-examples/pkg/metrics"
+	sdk "github.com/monadic/devops-sdk"
+)
+
+func TestParseVersion(t *testing.T) {
+	for v, want := range map[string]int{"1.29": 29, "v1.32": 32, "1.30.4": 30} {
+		if got, err := parseVersion(v); err != nil || got != want {
+			t.Errorf("%s: got %d, %v", v, got, err)
+		}
+	}
+	for _, v := range []string{"", "1", "2.0", "1.x", "1.-1"} {
+		if _, err := parseVersion(v); err == nil {
+			t.Errorf("%q: no error", v)
+		}
+	}
+}
+
+func TestDeprecations(t *testing.T) {
+	seen := map[string]bool{}
+	for _, d := range Deprecations {
+		key := d.APIVersion + "/" + d.Kind
+		if seen[key] {
+			t.Errorf("%s listed twice", key)
+		}
+		seen[key] = true
+		if d.DeprecatedIn >= d.RemovedIn {
+			t.Errorf("%s: deprecated in 1.%d, removed in 1.%d", key, d.DeprecatedIn, d.RemovedIn)
+		}
+		if d.Replacement == "" && d.Note == "" {
+			t.Errorf("%s: neither a replacement nor a note", key)
+		}
+	}
+}
+
+// units holds one resource per unit, by slug
+func units(t *testing.T, data map[string]string) []*sdk.Unit {
+	t.Helper()
+	var list []*sdk.Unit
+	for slug, d := range data {
+		list = append(list, &sdk.Unit{UnitID: uuid.New(), Slug: slug, Data: d})
+	}
+	return list
+}
+
+var (
+	pdb     = "apiVersion: policy/v1beta1\nkind: PodDisruptionBudget\nmetadata:\n  name: web\n  namespace: shop\nspec:\n  minAvailable: 1\n"
+	psp     = "apiVersion: policy/v1beta1\nkind: PodSecurityPolicy\nmetadata:\n  name: restricted\n"
+	flow    = "apiVersion: flowcontrol.apiserver.k8s.io/v1beta3\nkind: FlowSchema\nmetadata:\n  name: batch\n"
+	deploy  = "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"
+	notYAML = "not: [kubernetes"
+)
+
+func TestAssess(t *testing.T) {
+	var resources []resource
+	resources = append(resources, parseUnits("prod", units(t, map[string]string{"pdb": pdb, "psp": psp, "flow": flow, "deploy": deploy, "notes": notYAML}), "default")...)
+	resources = append(resources, parseUnits("dev", units(t, map[string]string{"deploy": deploy}), "default")...)
+	if len(resources) != 5 {
+		t.Fatalf("parsed %d resources, want 5", len(resources))
+	}
+
+	// 1.24: the PDB and PSP are deprecated, v1beta3 FlowSchemas don't exist yet
+	findings, spaces := assess(resources, []string{"prod", "dev", "empty"}, 24)
+	if len(findings) != 2 || findings[0].Status != StatusDeprecated || findings[1].Status != StatusDeprecated {
+		t.Fatalf("1.24: got %+v", findings)
+	}
+	if len(spaces) != 3 || spaces[0].Space != "dev" || !spaces[0].Ready || spaces[1].Space != "empty" || spaces[1].Readiness != 1 ||
+		spaces[2].Resources != 4 || spaces[2].Warnings != 2 || !spaces[2].Ready {
+		t.Errorf("1.24: got %+v", spaces)
+	}
+
+	// 1.32: everything of prod but the Deployment blocks
+	findings, spaces = assess(resources, []string{"prod", "dev"}, 32)
+	if len(findings) != 3 {
+		t.Fatalf("1.32: got %+v", findings)
+	}
+	for _, f := range findings {
+		if f.Status != StatusRemoved {
+			t.Errorf("%s: %s", f.UnitSlug, f.Status)
+		}
+	}
+	if findings[0].Deprecation.RemovedIn != 25 || findings[2].UnitSlug != "flow" {
+		t.Errorf("1.32: not sorted by removal: %+v", findings)
+	}
+	if p := spaces[1]; p.Space != "prod" || p.Blockers != 3 || p.Ready || p.Readiness != 0.25 {
+		t.Errorf("1.32: got %+v", p)
+	}
+
+	for _, f := range findings {
+		switch f.UnitSlug {
+		case "pdb":
+			if f.Namespace != "shop" || len(f.Patches) != 1 || f.Patches[0].PatchValue != "policy/v1" ||
+				!strings.Contains(f.Migrated, "apiVersion: policy/v1\n") || len(f.Notes) != 1 {
+				t.Errorf("pdb: got %+v", f)
+			}
+		case "psp":
+			if f.Namespace != "default" || len(f.Patches) != 0 || f.Migrated != "" || !strings.Contains(f.Notes[0], "Pod Security Admission") {
+				t.Errorf("psp: got %+v", f)
+			}
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Spaces = []string{"prod", "dev"}
+	a := &Advisor{config: cfg, target: 24, metrics: metrics.New("upgrade-advisor", version, "test")}
+	handler := a.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/upgrade", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first scan: status %d, want 503", rec.Code)
+	}
+
+	s := &scan{at: time.Now(), errors: map[string]string{"dev": "space not found"}}
+	s.resources = parseUnits("prod", units(t, map[string]string{"pdb": pdb, "psp": psp, "flow": flow}), "default")
+	a.last, a.report = s, a.assess(s, 24)
+
+	for _, tc := range []struct {
+		path     string
+		code     int
+		findings []string
+	}{
+		{path: "/api/v1/upgrade", code: http.StatusOK, findings: []string{"pdb", "psp"}},
+		{path: "/api/v1/upgrade?target=1.32&status=removed", code: http.StatusOK, findings: []string{"pdb", "psp", "flow"}},
+		{path: "/api/v1/upgrade?target=v1.20", code: http.StatusOK, findings: []string{}},
+		{path: "/api/v1/upgrade?target=1.32&space=dev", code: http.StatusOK, findings: []string{}},
+		{path: "/api/v1/upgrade?target=latest", code: http.StatusBadRequest},
+		{path: "/api/v1/upgrade?status=gone", code: http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.path, rec.Code, tc.code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var report Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		var got []string
+		for _, f := range report.Findings {
+			got = append(got, f.UnitSlug)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.findings, ",") {
+			t.Errorf("%s: findings %v, want %v", tc.path, got, tc.findings)
+		}
+		// dev was not read, so no target is ready
+		if report.Ready || report.Spaces[0].Space != "dev" || report.Spaces[0].Error == "" {
+			t.Errorf("%s: got %+v", tc.path, report.Spaces)
+		}
+	}
+
+	for _, path := range []string{"/", "/?target=1.25", "/api/v1/deprecations", "/health"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status %d", path, rec.Code)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 #!/bin/bash
 
 # Complete verification of DevOps as Apps system
+# For repeatable tests against a throwaway kind cluster, see e2e/
 set -e
 
 echo "========================================="
@@ -125,9 +126,9 @@ check_service 8081 "Cost Optimizer"
 echo ""
 echo "6. API HEALTH CHECK"
 echo "-------------------"
-if curl -s http://localhost:8082/api/health > /dev/null 2>&1; then
-    HEALTH_SCORE=$(curl -s http://localhost:8082/api/health | jq '.health_score')
-    STATUS=$(curl -s http://localhost:8082/api/health | jq -r '.status')
+if curl -s http://localhost:8082/api/v1/health > /dev/null 2>&1; then
+    HEALTH_SCORE=$(curl -s http://localhost:8082/api/v1/health | jq '.health_score')
+    STATUS=$(curl -s http://localhost:8082/api/v1/health | jq -r '.status')
 
     if [ "$HEALTH_SCORE" -ge 90 ]; then
         echo -e "${GREEN}✅ Health Check: $STATUS (Score: $HEALTH_SCORE/100)${NC}"
@@ -138,7 +139,7 @@ if curl -s http://localhost:8082/api/health > /dev/null 2>&1; then
     fi
 
     # Show any issues
-    ISSUES=$(curl -s http://localhost:8082/api/health | jq -r '.issues[]' 2>/dev/null)
+    ISSUES=$(curl -s http://localhost:8082/api/v1/health | jq -r '.issues[]' 2>/dev/null)
     if [ -n "$ISSUES" ]; then
         echo "   Issues found:"
         echo "$ISSUES" | while read issue; do
@@ -173,11 +174,11 @@ if [ "$OVERALL_STATUS" = "PASS" ]; then
     echo -e "${GREEN}✅ SYSTEM READY${NC}"
     echo ""
     echo "Dashboard URL: http://localhost:8082"
-    echo "Health API: http://localhost:8082/api/health"
+    echo "Health API: http://localhost:8082/api/v1/health"
     echo ""
     echo "Quick commands:"
     echo "  • View dashboard: open http://localhost:8082"
-    echo "  • Check health: curl http://localhost:8082/api/health | jq '.'"
+    echo "  • Check health: curl http://localhost:8082/api/v1/health | jq '.'"
     echo "  • Run compliance: ./test-app-compliance-quick.sh"
 else
     echo -e "${RED}❌ SYSTEM NOT READY${NC}"